@@ -0,0 +1,403 @@
+// Package api provides the control channel to the cm agent on instances
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+const (
+	// agentPingInterval is how often agents are pinged; one that misses
+	// answering for agentReadTimeout is dropped
+	agentPingInterval = 30 * time.Second
+	agentReadTimeout  = 90 * time.Second
+	agentWriteTimeout = 10 * time.Second
+
+	// agentCallTimeout bounds exec, health and Docker requests
+	agentCallTimeout = 10 * time.Minute
+)
+
+// errAgentNotConnected is returned for instances whose agent has no
+// control channel open
+var errAgentNotConnected = errors.New("the instance's agent isn't connected")
+
+// agentMessage is a request to an agent or a reply to one; replies carry
+// the request's ID. Requests are exec, logs, health, docker and cancel;
+// every request ends with a result, and logs also stream log lines before it.
+type agentMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+
+	// Requests
+	Command   []string `json:"command,omitempty"`   // exec: the command and its arguments
+	Container string   `json:"container,omitempty"` // exec, logs, docker: a container, or the host if empty
+	Action    string   `json:"action,omitempty"`    // docker: list, start, stop, restart or remove
+	Tail      int      `json:"tail,omitempty"`      // logs: lines of history, 0 for all
+	Follow    bool     `json:"follow,omitempty"`    // logs: keep streaming new lines
+
+	// Replies
+	Stdout     string          `json:"stdout,omitempty"`
+	Stderr     string          `json:"stderr,omitempty"`
+	ExitCode   int             `json:"exit_code,omitempty"`
+	Line       string          `json:"line,omitempty"`
+	Health     json.RawMessage `json:"health,omitempty"`
+	Containers json.RawMessage `json:"containers,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// agentConn is an agent's open control channel
+type agentConn struct {
+	conn        *websocket.Conn
+	connectedAt time.Time
+	writeMu     sync.Mutex // Serializes writes to the connection
+
+	mu      sync.Mutex
+	pending map[string]chan agentMessage // Replies of requests in progress, by ID
+	closed  bool
+}
+
+func (a *agentConn) send(msg agentMessage) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	_ = a.conn.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+	return a.conn.WriteJSON(msg)
+}
+
+// deliver hands a reply to its request, dropping it if the requester
+// doesn't keep up, and finishes the request with its result
+func (a *agentConn) deliver(msg agentMessage) {
+	a.mu.Lock()
+	replies, ok := a.pending[msg.ID]
+	if ok && msg.Type == "result" {
+		delete(a.pending, msg.ID)
+	}
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case replies <- msg:
+	case <-time.After(agentWriteTimeout):
+	}
+	if msg.Type == "result" {
+		close(replies)
+	}
+}
+
+// close fails the requests in progress
+func (a *agentConn) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	for id, replies := range a.pending {
+		close(replies)
+		delete(a.pending, id)
+	}
+}
+
+// agentHub tracks the control channels of connected agents
+type agentHub struct {
+	mu    sync.Mutex
+	conns map[string]*agentConn // By instance or host ID
+}
+
+func newAgentHub() *agentHub {
+	return &agentHub{conns: make(map[string]*agentConn)}
+}
+
+// get returns the control channel of an instance's agent
+func (h *agentHub) get(instanceID string) (*agentConn, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.conns[instanceID]
+	return a, ok
+}
+
+// request sends a request to an instance's agent and returns the channel
+// its replies arrive on, closed after the result or if the agent
+// disconnects; done abandons the request
+func (h *agentHub) request(instanceID string, msg agentMessage) (replies <-chan agentMessage, done func(), err error) {
+	a, ok := h.get(instanceID)
+	if !ok {
+		return nil, nil, errAgentNotConnected
+	}
+	msg.ID = uuid.New().String()
+	ch := make(chan agentMessage, 64)
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil, nil, errAgentNotConnected
+	}
+	a.pending[msg.ID] = ch
+	a.mu.Unlock()
+
+	done = func() {
+		a.mu.Lock()
+		_, inProgress := a.pending[msg.ID]
+		delete(a.pending, msg.ID)
+		a.mu.Unlock()
+		if inProgress {
+			_ = a.send(agentMessage{ID: msg.ID, Type: "cancel"})
+		}
+	}
+	if err := a.send(msg); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return ch, done, nil
+}
+
+// call sends a request to an instance's agent and waits for its result
+func (h *agentHub) call(ctx context.Context, instanceID string, msg agentMessage) (*agentMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, agentCallTimeout)
+	defer cancel()
+	replies, done, err := h.request(instanceID, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case reply, ok := <-replies:
+			if !ok {
+				return nil, errAgentNotConnected
+			}
+			if reply.Type != "result" {
+				continue
+			}
+			if reply.Error != "" {
+				return nil, errors.New(reply.Error)
+			}
+			return &reply, nil
+		}
+	}
+}
+
+// agentInstance returns the instance whose agent token authenticates the
+// request
+func (s *Server) agentInstance(c echo.Context) (*db.Instance, error) {
+	instance, err := s.db.GetInstanceByID(c.Param("id"))
+	token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if err != nil || instance.AgentTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(hashAgentToken(token)), []byte(instance.AgentTokenHash)) != 1 {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "invalid agent token")
+	}
+	return instance, nil
+}
+
+// HandleAgentWebSocket accepts the control channel of the cm agent on an
+// instance, which dials out so instances behind NAT can be reached
+func (s *Server) HandleAgentWebSocket(c echo.Context) error {
+	instance, err := s.agentInstance(c)
+	if err != nil {
+		return err
+	}
+	return s.serveAgent(c, instance.ID, "instance_id", func(ctx context.Context) {
+		s.mountAttachedVolumes(ctx, instance.ID)
+	})
+}
+
+// serveAgent keeps the control channel of the agent on an instance or host
+// until it disconnects; connected runs once the channel is usable, with a
+// context that ends with it. logKey names the ID in logs.
+func (s *Server) serveAgent(c echo.Context, id, logKey string, connected func(ctx context.Context)) error {
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		log.Printf("Agent WebSocket upgrade failed: %v", err)
+		return err
+	}
+	defer conn.Close()
+	s.metrics.wsSessions.add(1, "agent")
+	defer s.metrics.wsSessions.add(-1, "agent")
+
+	a := &agentConn{conn: conn, connectedAt: time.Now().UTC(), pending: make(map[string]chan agentMessage)}
+	s.agents.mu.Lock()
+	if old, ok := s.agents.conns[id]; ok {
+		// A reconnecting agent replaces its stale channel
+		old.conn.Close()
+	}
+	s.agents.conns[id] = a
+	s.agents.mu.Unlock()
+	s.log.Info("agent connected", logKey, id)
+	defer func() {
+		s.agents.mu.Lock()
+		if s.agents.conns[id] == a {
+			delete(s.agents.conns, id)
+		}
+		s.agents.mu.Unlock()
+		a.close()
+		s.log.Info("agent disconnected", logKey, id)
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(agentReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(agentReadTimeout))
+	})
+	ctx, cancel := context.WithCancel(detachedContext(c))
+	defer cancel()
+	go connected(ctx)
+	go func() {
+		ticker := time.NewTicker(agentPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(agentWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var msg agentMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return nil
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(agentReadTimeout))
+		a.deliver(msg)
+	}
+}
+
+// agentError maps a failed agent request to a response
+func agentError(err error) error {
+	if errors.Is(err, errAgentNotConnected) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+}
+
+// execInstance runs a command on an instance, or in one of its containers,
+// through its agent
+func (s *Server) execInstance(c echo.Context) error {
+	instance := c.Get("instance").(*db.Instance)
+
+	var req struct {
+		Command   []string `json:"command"`
+		Container string   `json:"container"`
+	}
+	if err := c.Bind(&req); err != nil || len(req.Command) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "command is required")
+	}
+	reply, err := s.agents.call(c.Request().Context(), instance.ID, agentMessage{
+		Type:      "exec",
+		Command:   req.Command,
+		Container: req.Container,
+	})
+	if err != nil {
+		return agentError(err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stdout":    reply.Stdout,
+		"stderr":    reply.Stderr,
+		"exit_code": reply.ExitCode,
+	})
+}
+
+// getInstanceHealth returns the health its agent reports for an instance
+func (s *Server) getInstanceHealth(c echo.Context) error {
+	instance := c.Get("instance").(*db.Instance)
+	a, ok := s.agents.get(instance.ID)
+	if !ok {
+		return agentError(errAgentNotConnected)
+	}
+	reply, err := s.agents.call(c.Request().Context(), instance.ID, agentMessage{Type: "health"})
+	if err != nil {
+		return agentError(err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"agent_connected_at": a.connectedAt,
+		"last_heartbeat_at":  instance.LastHeartbeatAt,
+		"health":             reply.Health,
+	})
+}
+
+// listInstanceContainers returns the containers on an instance
+func (s *Server) listInstanceContainers(c echo.Context) error {
+	instance := c.Get("instance").(*db.Instance)
+	reply, err := s.agents.call(c.Request().Context(), instance.ID, agentMessage{Type: "docker", Action: "list"})
+	if err != nil {
+		return agentError(err)
+	}
+	return c.JSONBlob(http.StatusOK, containersJSON(reply.Containers))
+}
+
+// containerAction starts, stops, restarts or removes a container on an
+// instance and returns its containers afterwards
+func (s *Server) containerAction(c echo.Context) error {
+	instance := c.Get("instance").(*db.Instance)
+	action := c.Param("action")
+	switch action {
+	case "start", "stop", "restart", "remove":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "action must be start, stop, restart or remove")
+	}
+	reply, err := s.agents.call(c.Request().Context(), instance.ID, agentMessage{
+		Type:      "docker",
+		Action:    action,
+		Container: c.Param("container"),
+	})
+	if err != nil {
+		return agentError(err)
+	}
+	return c.JSONBlob(http.StatusOK, containersJSON(reply.Containers))
+}
+
+// containersJSON returns an agent's container list, an empty list if it
+// omitted one
+func containersJSON(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return []byte("[]")
+	}
+	return raw
+}
+
+// agentLogs collects the last lines of an instance's system journal, or of
+// one of its containers, through its agent
+func (s *Server) agentLogs(ctx context.Context, instanceID, container string, tail int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, agentCallTimeout)
+	defer cancel()
+	replies, done, err := s.agents.request(instanceID, agentMessage{Type: "logs", Container: container, Tail: tail})
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	var sb strings.Builder
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case reply, ok := <-replies:
+			if !ok {
+				return "", errAgentNotConnected
+			}
+			switch {
+			case reply.Type == "log":
+				sb.WriteString(reply.Line)
+				sb.WriteByte('\n')
+			case reply.Error != "":
+				return "", errors.New(reply.Error)
+			default:
+				return sb.String(), nil
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// agentInstance creates an instance owned by a user with an agent token,
+// and returns the token
+func (f *teamFixture) agentInstance(owner string) (*db.Instance, string) {
+	f.t.Helper()
+	inst := f.instance(owner, false)
+	token, hash := newAgentToken()
+	inst.AgentTokenHash = hash
+	if err := f.s.db.UpdateInstance(inst); err != nil {
+		f.t.Fatal(err)
+	}
+	return inst, token
+}
+
+// dialAgent opens an instance's control channel the way the cm agent does
+func dialAgent(srv *httptest.Server, instanceID, token string) (*websocket.Conn, *http.Response, error) {
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/instances/" + instanceID + "/agent"
+	return websocket.DefaultDialer.Dial(u, http.Header{"Authorization": {"Bearer " + token}})
+}
+
+// waitForAgent waits until an instance's control channel is registered
+func waitForAgent(t *testing.T, s *Server, instanceID string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, ok := s.agents.get(instanceID); ok {
+			return
+		}
+	}
+	t.Fatal("agent never connected")
+}
+
+func TestAgentAuthentication(t *testing.T) {
+	f := newTeamFixture(t)
+	srv := httptest.NewServer(f.s.echo)
+	defer srv.Close()
+	inst, token := f.agentInstance("member")
+	other, otherToken := f.agentInstance("owner")
+	noToken := f.instance("viewer", false)
+
+	tests := []struct {
+		name       string
+		instanceID string
+		token      string
+	}{
+		{"wrong token", inst.ID, "not-the-token"},
+		{"no token", inst.ID, ""},
+		{"another instance's token", inst.ID, otherToken},
+		{"instance without a token", noToken.ID, ""},
+		{"unknown instance", "inst-missing", token},
+	}
+	for _, tt := range tests {
+		conn, resp, err := dialAgent(srv, tt.instanceID, tt.token)
+		if err == nil {
+			conn.Close()
+			t.Errorf("%s: control channel opened", tt.name)
+			continue
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: dial = %v, want 401", tt.name, err)
+		}
+	}
+	if _, ok := f.s.agents.get(inst.ID); ok {
+		t.Error("a rejected agent was registered")
+	}
+
+	conn, _, err := dialAgent(srv, other.ID, otherToken)
+	if err != nil {
+		t.Fatalf("agent with its own token: %v", err)
+	}
+	conn.Close()
+}
+
+func TestExecInstance(t *testing.T) {
+	f := newTeamFixture(t)
+	srv := httptest.NewServer(f.s.echo)
+	defer srv.Close()
+	inst, token := f.agentInstance("member")
+	path := "/api/v1/instances/" + inst.ID + "/exec"
+
+	// Without an agent there is nobody to run the command
+	f.do("member", http.MethodPost, path, `{"command":["uname","-a"]}`, http.StatusConflict, nil)
+	f.do("member", http.MethodPost, path, `{"command":[]}`, http.StatusBadRequest, nil)
+
+	conn, _, err := dialAgent(srv, inst.ID, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitForAgent(t, f.s, inst.ID)
+	requests := make(chan agentMessage, 1)
+	go func() {
+		for {
+			var msg agentMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type != "exec" {
+				continue
+			}
+			requests <- msg
+			if msg.Command[0] == "hang-up" {
+				conn.Close()
+				return
+			}
+			_ = conn.WriteJSON(agentMessage{ID: msg.ID, Type: "result", Stdout: "Linux\n", ExitCode: 2})
+		}
+	}()
+
+	var out struct {
+		Stdout   string `json:"stdout"`
+		ExitCode int    `json:"exit_code"`
+	}
+	f.do("member", http.MethodPost, path, `{"command":["uname","-a"],"container":"web"}`, http.StatusOK, &out)
+	if out.Stdout != "Linux\n" || out.ExitCode != 2 {
+		t.Errorf("exec = %+v, want the agent's result", out)
+	}
+	if req := <-requests; strings.Join(req.Command, " ") != "uname -a" || req.Container != "web" {
+		t.Errorf("agent got %+v, want the command in container web", req)
+	}
+
+	// Others can't run commands on the instance
+	f.do("outsider", http.MethodPost, path, `{"command":["id"]}`, http.StatusNotFound, nil)
+
+	// An agent that disconnects mid-command fails it
+	f.do("member", http.MethodPost, path, `{"command":["hang-up"]}`, http.StatusConflict, nil)
+}
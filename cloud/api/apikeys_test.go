@@ -0,0 +1,162 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/api/v1/billing/usage", ScopeBillingRead},
+		{http.MethodGet, "/api/v1/billing/invoices/:id/pdf", ScopeBillingRead},
+		{http.MethodPut, "/api/v1/billing/budget", ScopeAll},
+		{http.MethodPost, "/api/v1/billing/portal", ScopeAll},
+		{http.MethodGet, "/api/v1/instances", ScopeRead},
+		{http.MethodHead, "/api/v1/instances/:id", ScopeRead},
+		{http.MethodGet, "/api/v1/instances/:id/logs", ScopeRead},
+		{http.MethodPost, "/api/v1/instances", ScopeInstancesWrite},
+		{http.MethodPost, "/api/v1/instances/:id/start", ScopeInstancesWrite},
+		{http.MethodDelete, "/api/v1/instances/:id", ScopeInstancesWrite},
+		{http.MethodGet, "/api/v1/instances/:id/terminal", ScopeInstancesWrite}, // A shell, so not read-only
+		{http.MethodGet, "/api/v1/teams", ScopeRead},
+		{http.MethodPost, "/api/v1/api-keys", ScopeAll},
+		{http.MethodDelete, "/api/v1/api-keys/:id", ScopeAll},
+	}
+	for _, tt := range tests {
+		if got := requiredScope(tt.method, tt.path); got != tt.want {
+			t.Errorf("requiredScope(%s, %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes, scope string
+		want          bool
+	}{
+		{"all", ScopeBillingRead, true},
+		{"all", ScopeAll, true},
+		{"read", ScopeRead, true},
+		{"read", ScopeBillingRead, false}, // Billing isn't part of read
+		{"read", ScopeInstancesWrite, false},
+		{"instances:write,read", ScopeInstancesWrite, true},
+		{"billing:read, read", ScopeBillingRead, true},
+		{"billing:read", ScopeAll, false},
+		{"", ScopeRead, false},
+		{"reader", ScopeRead, false},
+	}
+	for _, tt := range tests {
+		if got := hasScope(tt.scopes, tt.scope); got != tt.want {
+			t.Errorf("hasScope(%q, %q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+// createAPIKey creates a key for a user and returns it with its ID
+func (f *teamFixture) createAPIKey(user, body string) (key, id string) {
+	f.t.Helper()
+	var resp struct {
+		Key string `json:"key"`
+		ID  string `json:"id"`
+	}
+	f.do(user, http.MethodPost, "/api/v1/api-keys", body, http.StatusCreated, &resp)
+	return resp.Key, resp.ID
+}
+
+// doAPIKey sends a request authenticated by an API key and returns its status
+func (f *teamFixture) doAPIKey(key, method, path string) int {
+	f.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+	f.s.echo.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	f := newTeamFixture(t)
+	inst := f.instance("member", false)
+	read, _ := f.createAPIKey("member", `{"name":"ci","scopes":["read"]}`)
+	billing, _ := f.createAPIKey("member", `{"name":"finance","scopes":["billing:read"]}`)
+
+	tests := []struct {
+		key          string
+		method, path string
+		want         int
+	}{
+		{read, http.MethodGet, "/api/v1/instances", http.StatusOK},
+		{read, http.MethodGet, "/api/v1/instances/" + inst.ID, http.StatusOK},
+		{read, http.MethodPost, "/api/v1/instances/" + inst.ID + "/stop", http.StatusForbidden},
+		{read, http.MethodDelete, "/api/v1/instances/" + inst.ID, http.StatusForbidden},
+		{read, http.MethodGet, "/api/v1/billing/usage", http.StatusForbidden},
+		{read, http.MethodPost, "/api/v1/api-keys", http.StatusForbidden},
+		{billing, http.MethodGet, "/api/v1/billing/budget", http.StatusOK},
+		{billing, http.MethodPut, "/api/v1/billing/budget", http.StatusForbidden},
+		{billing, http.MethodGet, "/api/v1/instances", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := f.doAPIKey(tt.key, tt.method, tt.path); got != tt.want {
+			t.Errorf("%s %s with key %s... = %d, want %d", tt.method, tt.path, tt.key[:apiKeyPrefixLen], got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeAPIKey(t *testing.T) {
+	f := newTeamFixture(t)
+	key, id := f.createAPIKey("member", `{"name":"ci"}`)
+	status := func(err error) int {
+		if err == nil {
+			return http.StatusOK
+		}
+		return err.(*echo.HTTPError).Code
+	}
+
+	userID, err := f.s.authorizeAPIKey(key, http.MethodGet, "/api/v1/instances")
+	if err != nil || userID != f.users["member"].ID {
+		t.Fatalf("authorizeAPIKey = %q, %v, want the member", userID, err)
+	}
+
+	// Another secret behind the same prefix
+	forged := key[:apiKeyPrefixLen] + strings.Repeat("A", len(key)-apiKeyPrefixLen)
+	if _, err := f.s.authorizeAPIKey(forged, http.MethodGet, "/api/v1/instances"); status(err) != http.StatusUnauthorized {
+		t.Errorf("wrong secret with a valid prefix = %v, want 401", err)
+	}
+	if _, err := f.s.authorizeAPIKey("cm_short", http.MethodGet, "/api/v1/instances"); status(err) != http.StatusUnauthorized {
+		t.Errorf("malformed key = %v, want 401", err)
+	}
+
+	// Other users can't delete the key
+	f.do("outsider", http.MethodDelete, "/api/v1/api-keys/"+id, "", http.StatusNotFound, nil)
+	if _, err := f.s.authorizeAPIKey(key, http.MethodGet, "/api/v1/instances"); err != nil {
+		t.Errorf("key deleted by another user: %v", err)
+	}
+
+	// Expired keys are refused
+	keys, err := f.s.db.ListAPIKeysByUser(f.users["member"].ID)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("member's keys = %v, %v, want one", keys, err)
+	}
+	expired := time.Now().UTC().Add(-time.Minute)
+	keys[0].ExpiresAt = &expired
+	if err := f.s.db.UpdateAPIKey(&keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.s.authorizeAPIKey(key, http.MethodGet, "/api/v1/instances"); status(err) != http.StatusUnauthorized {
+		t.Errorf("expired key = %v, want 401", err)
+	}
+
+	// Its owner can
+	f.do("member", http.MethodDelete, "/api/v1/api-keys/"+id, "", http.StatusOK, nil)
+	if _, err := f.s.authorizeAPIKey(key, http.MethodGet, "/api/v1/instances"); status(err) != http.StatusUnauthorized {
+		t.Errorf("deleted key = %v, want 401", err)
+	}
+}
@@ -0,0 +1,558 @@
+// Package api provides usage metering, budgets and spend alerts
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+const (
+	// budgetCheckInterval is how often budgets are enforced
+	budgetCheckInterval = time.Minute
+	// defaultAlertThresholds are the percentages of a budget alerted at
+	defaultAlertThresholds = "50,80,100"
+)
+
+// monthStart returns the start of t's calendar month in UTC, which budgets
+// and usage are counted by
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// usageType is what an instance type's runtime is billed as
+func usageType(instanceType string) string {
+	if strings.HasPrefix(instanceType, "gpu") {
+		return "gpu"
+	}
+	return "cpu"
+}
+
+// meteredSince returns when the runtime of a running instance not yet
+// recorded as usage began
+func (s *Server) meteredSince(inst *db.Instance) time.Time {
+	since := inst.CreatedAt
+	if inst.StartedAt != nil && inst.StartedAt.After(since) {
+		since = *inst.StartedAt
+	}
+	if last, err := s.db.GetLastUsageRecord(inst.ID); err == nil && last.PeriodEnd.After(since) {
+		since = last.PeriodEnd
+	}
+	return since
+}
+
+// usageRecords splits an instance's runtime from since to until into one
+// record per calendar month
+func usageRecords(inst *db.Instance, since, until time.Time) []db.UsageRecord {
+	var records []db.UsageRecord
+	for since.Before(until) {
+		end := monthStart(since).AddDate(0, 1, 0)
+		if end.After(until) {
+			end = until
+		}
+		hours := end.Sub(since).Hours()
+		records = append(records, db.UsageRecord{
+			ID:          uuid.New().String(),
+			UserID:      inst.OwnerID,
+			TeamID:      inst.TeamID,
+			InstanceID:  inst.ID,
+			Type:        usageType(inst.InstanceType),
+			Quantity:    hours,
+			Unit:        "hours",
+			UnitPrice:   inst.HourlyRate,
+			TotalCost:   hours * inst.HourlyRate,
+			Timestamp:   since,
+			PeriodStart: since,
+			PeriodEnd:   end,
+		})
+		since = end
+	}
+	return records
+}
+
+// meterInstance records a running instance's usage up to until. Instances
+// are metered when they stop or are deleted and at the end of each month;
+// the runtime in between counts towards spend as it accrues.
+func (s *Server) meterInstance(inst *db.Instance, until time.Time) error {
+	s.metering.Lock()
+	defer s.metering.Unlock()
+	for _, record := range usageRecords(inst, s.meteredSince(inst), until) {
+		if err := s.db.CreateUsageRecord(&record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// monthlyUsage is the usage of a user's or a team's instances this month
+type monthlyUsage struct {
+	CPUHours float64
+	GPUHours float64
+	Cost     float64
+	Running  int
+}
+
+// usageThisMonth adds the runtime of running instances not yet recorded to
+// this month's usage records
+func (s *Server) usageThisMonth(records []db.UsageRecord, instances []db.Instance, now time.Time) monthlyUsage {
+	var usage monthlyUsage
+	add := func(typ string, hours, cost float64) {
+		if typ == "gpu" {
+			usage.GPUHours += hours
+		} else {
+			usage.CPUHours += hours
+		}
+		usage.Cost += cost
+	}
+
+	for _, r := range records {
+		add(r.Type, r.Quantity, r.TotalCost)
+	}
+	start := monthStart(now)
+	for i := range instances {
+		inst := &instances[i]
+		if inst.Status != "running" {
+			continue
+		}
+		usage.Running++
+		since := s.meteredSince(inst)
+		if since.Before(start) {
+			since = start
+		}
+		if hours := now.Sub(since).Hours(); hours > 0 {
+			add(usageType(inst.InstanceType), hours, hours*inst.HourlyRate)
+		}
+	}
+	return usage
+}
+
+// userUsage returns the usage of the instances a user owns this month, and
+// the instances
+func (s *Server) userUsage(userID string, now time.Time) (monthlyUsage, []db.Instance, error) {
+	records, err := s.db.GetUsageByUserAndPeriod(userID, monthStart(now), now)
+	if err != nil {
+		return monthlyUsage{}, nil, err
+	}
+	instances, err := s.db.ListInstancesByUser(userID)
+	if err != nil {
+		return monthlyUsage{}, nil, err
+	}
+	return s.usageThisMonth(records, instances, now), instances, nil
+}
+
+// teamUsage returns the usage of a team's instances this month, and the
+// instances
+func (s *Server) teamUsage(teamID string, now time.Time) (monthlyUsage, []db.Instance, error) {
+	records, err := s.db.GetUsageByTeamAndPeriod(teamID, monthStart(now), now)
+	if err != nil {
+		return monthlyUsage{}, nil, err
+	}
+	instances, err := s.db.ListInstancesByTeam(teamID)
+	if err != nil {
+		return monthlyUsage{}, nil, err
+	}
+	return s.usageThisMonth(records, instances, now), instances, nil
+}
+
+// budgetUsage returns the usage a budget covers, and its instances
+func (s *Server) budgetUsage(budget *db.Budget, now time.Time) (monthlyUsage, []db.Instance, error) {
+	if budget.TeamID != nil {
+		return s.teamUsage(*budget.TeamID, now)
+	}
+	return s.userUsage(*budget.UserID, now)
+}
+
+// budgetThresholds parses a budget's alert percentages in ascending order
+func budgetThresholds(thresholds string) ([]int, error) {
+	if strings.TrimSpace(thresholds) == "" {
+		thresholds = defaultAlertThresholds
+	}
+	var percents []int
+	for _, field := range strings.Split(thresholds, ",") {
+		percent, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || percent <= 0 || percent > 1000 {
+			return nil, fmt.Errorf("alert thresholds must be percentages like 50,80,100")
+		}
+		percents = append(percents, percent)
+	}
+	sort.Ints(percents)
+	return percents, nil
+}
+
+// budgetRequest is the editable part of a budget
+type budgetRequest struct {
+	MonthlyLimit    float64 `json:"monthly_limit"`
+	HardStop        bool    `json:"hard_stop"`
+	AlertThresholds string  `json:"alert_thresholds"`
+	AlertEmail      string  `json:"alert_email"`
+	WebhookURL      string  `json:"webhook_url"`
+}
+
+// apply validates the request and copies it into a budget
+func (r *budgetRequest) apply(budget *db.Budget) error {
+	if r.MonthlyLimit < 0 {
+		return fmt.Errorf("monthly_limit must not be negative")
+	}
+	thresholds, err := budgetThresholds(r.AlertThresholds)
+	if err != nil {
+		return err
+	}
+	if r.AlertEmail != "" {
+		if _, err := mail.ParseAddress(r.AlertEmail); err != nil {
+			return fmt.Errorf("invalid alert_email %q", r.AlertEmail)
+		}
+	}
+	if r.WebhookURL != "" {
+		if err := checkWebhookURL("webhook_url", r.WebhookURL); err != nil {
+			return err
+		}
+	}
+
+	fields := make([]string, len(thresholds))
+	for i, percent := range thresholds {
+		fields[i] = strconv.Itoa(percent)
+	}
+	budget.MonthlyLimit = r.MonthlyLimit
+	budget.HardStop = r.HardStop
+	budget.AlertThresholds = strings.Join(fields, ",")
+	budget.AlertEmail = r.AlertEmail
+	budget.WebhookURL = r.WebhookURL
+	if budget.WebhookURL == "" {
+		budget.WebhookSecret = ""
+	}
+	return nil
+}
+
+// budgetResponse is a budget with the spend it has covered this month
+type budgetResponse struct {
+	*db.Budget
+	Spent         float64 `json:"spent"`
+	Period        string  `json:"period"`                   // "2006-01"
+	WebhookSecret string  `json:"webhook_secret,omitempty"` // Only when created
+}
+
+// writeBudget responds with a budget and its spend this month, and a newly
+// created webhook signing secret, if any
+func (s *Server) writeBudget(c echo.Context, budget *db.Budget, secret string) error {
+	now := time.Now().UTC()
+	usage, _, err := s.budgetUsage(budget, now)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get usage")
+	}
+	return c.JSON(http.StatusOK, budgetResponse{Budget: budget, Spent: usage.Cost, Period: now.Format("2006-01"), WebhookSecret: secret})
+}
+
+// getBudget returns the signed-in user's budget, or an empty one
+func (s *Server) getBudget(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	budget, err := s.db.GetUserBudget(userID)
+	if err != nil {
+		budget = &db.Budget{UserID: &userID, AlertThresholds: defaultAlertThresholds}
+	}
+	return s.writeBudget(c, budget, "")
+}
+
+// updateBudget sets the budget for the instances the signed-in user owns
+func (s *Server) updateBudget(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	budget, err := s.db.GetUserBudget(userID)
+	if err != nil {
+		budget = &db.Budget{UserID: &userID}
+	}
+	return s.saveBudget(c, budget, userID)
+}
+
+// getTeamBudget returns a team's budget to its members
+func (s *Server) getTeamBudget(c echo.Context) error {
+	teamID := c.Param("id")
+	budget, err := s.db.GetTeamBudget(teamID)
+	if err != nil {
+		budget = &db.Budget{TeamID: &teamID, AlertThresholds: defaultAlertThresholds}
+	}
+	return s.writeBudget(c, budget, "")
+}
+
+// updateTeamBudget sets a team's budget; only its owner and admins may
+func (s *Server) updateTeamBudget(c echo.Context) error {
+	teamID := c.Param("id")
+	userID := c.Get("user_id").(string)
+	budget, err := s.db.GetTeamBudget(teamID)
+	if err != nil {
+		budget = &db.Budget{TeamID: &teamID}
+	}
+	return s.saveBudget(c, budget, userID)
+}
+
+func (s *Server) saveBudget(c echo.Context, budget *db.Budget, userID string) error {
+	var req budgetRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if err := req.apply(budget); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	// Alerts are signed like webhook events; the secret is shown once
+	var secret string
+	if budget.WebhookURL != "" && budget.WebhookSecret == "" {
+		secret = "whsec_" + randomToken()
+		var err error
+		if budget.WebhookSecret, err = encryptCredentialData(map[string]string{"secret": secret}, s.config.JWTSecret); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt secret")
+		}
+	}
+	budget.UpdatedAt = time.Now().UTC()
+	budget.UpdatedBy = userID
+	if err := s.db.SaveBudget(budget); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save budget")
+	}
+	return s.writeBudget(c, budget, secret)
+}
+
+// exhaustedBudget returns the hard-stop budget, if any, that keeps an
+// instance of a user or team from running
+func (s *Server) exhaustedBudget(userID string, teamID *string) *db.Budget {
+	now := time.Now().UTC()
+	var budgets []*db.Budget
+	if budget, err := s.db.GetUserBudget(userID); err == nil {
+		budgets = append(budgets, budget)
+	}
+	if teamID != nil {
+		if budget, err := s.db.GetTeamBudget(*teamID); err == nil {
+			budgets = append(budgets, budget)
+		}
+	}
+	for _, budget := range budgets {
+		if !budget.HardStop || budget.MonthlyLimit <= 0 {
+			continue
+		}
+		if usage, _, err := s.budgetUsage(budget, now); err == nil && usage.Cost >= budget.MonthlyLimit {
+			return budget
+		}
+	}
+	return nil
+}
+
+// budgetExhaustedError is returned when a hard-stop budget refuses to run
+// an instance
+func budgetExhaustedError(budget *db.Budget) error {
+	return echo.NewHTTPError(http.StatusPaymentRequired,
+		fmt.Sprintf("the monthly budget of $%.2f is used up; raise it to run instances this month", budget.MonthlyLimit))
+}
+
+// enforceBudgets meters usage, alerts on budget thresholds and stops the
+// instances of exhausted hard-stop budgets until ctx ends
+func (s *Server) enforceBudgets(ctx context.Context) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkBudgets(ctx, now.UTC())
+		}
+	}
+}
+
+// checkBudgets closes the previous month's usage of running instances, then
+// alerts and stops as budgets require
+func (s *Server) checkBudgets(ctx context.Context, now time.Time) {
+	start := monthStart(now)
+	running, err := s.db.ListInstancesByStatus("running")
+	if err != nil {
+		s.log.Error("budgets: failed to list instances", "error", err)
+		return
+	}
+	for i := range running {
+		if s.meteredSince(&running[i]).Before(start) {
+			if err := s.meterInstance(&running[i], start); err != nil {
+				s.log.Error("budgets: failed to meter instance", "instance_id", running[i].ID, "error", err)
+			}
+		}
+	}
+
+	budgets, err := s.db.ListBudgets()
+	if err != nil {
+		s.log.Error("budgets: failed to list budgets", "error", err)
+		return
+	}
+	period := now.Format("2006-01")
+	for i := range budgets {
+		budget := &budgets[i]
+		usage, instances, err := s.budgetUsage(budget, now)
+		if err != nil {
+			s.log.Error("budgets: failed to get usage", "budget_id", budget.ID, "error", err)
+			continue
+		}
+
+		alerted := 0
+		if budget.AlertPeriod == period {
+			alerted = budget.AlertedPercent
+		}
+		percent := usage.Cost / budget.MonthlyLimit * 100
+		thresholds, _ := budgetThresholds(budget.AlertThresholds)
+		if budget.HardStop {
+			// Owners always hear about a hard stop
+			thresholds = append(thresholds, 100)
+		}
+		crossed := 0
+		for _, threshold := range thresholds {
+			if percent >= float64(threshold) && threshold > crossed {
+				crossed = threshold
+			}
+		}
+		// Reaching the limit is recorded even if it isn't a threshold, so
+		// budget.exceeded fires once a month
+		recorded := crossed
+		if percent >= 100 && recorded < 100 {
+			recorded = 100
+		}
+		if recorded > alerted {
+			if err := s.db.SetBudgetAlerted(budget.ID, period, recorded); err != nil {
+				s.log.Error("budgets: failed to record alert", "budget_id", budget.ID, "error", err)
+				continue
+			}
+			if crossed > alerted {
+				go s.sendBudgetAlert(*budget, usage.Cost, crossed, period)
+			}
+			if recorded >= 100 && alerted < 100 {
+				s.emitBudgetExceeded(budget, usage.Cost, period)
+			}
+		}
+
+		if !budget.HardStop || usage.Cost < budget.MonthlyLimit {
+			continue
+		}
+		reason := fmt.Sprintf("stopped: monthly budget of $%.2f reached", budget.MonthlyLimit)
+		for j := range instances {
+			inst := &instances[j]
+			if inst.Status != "running" {
+				continue
+			}
+			s.log.Info("budget stopping instance", "instance_id", inst.ID, "budget_id", budget.ID)
+			if err := s.setInstanceRunning(ctx, inst, false, reason); err != nil {
+				s.log.Error("budget failed to stop instance", "instance_id", inst.ID, "error", err)
+			}
+		}
+	}
+}
+
+// sendBudgetAlert tells the budget's owners that spend crossed a threshold:
+// over the WebSocket hub, by email and to the budget's webhook
+func (s *Server) sendBudgetAlert(budget db.Budget, spent float64, threshold int, period string) {
+	var name, email string
+	var recipients []string
+	if budget.TeamID != nil {
+		team, err := s.db.GetTeamByID(*budget.TeamID)
+		if err != nil {
+			s.log.Error("budgets: failed to get team", "team_id", *budget.TeamID, "error", err)
+			return
+		}
+		name = "Team " + team.Name
+		if owner, err := s.db.GetUserByID(team.OwnerID); err == nil {
+			email = owner.Email
+		}
+		admins, _ := s.db.ListTeamAdmins(team.ID)
+		for _, member := range admins {
+			recipients = append(recipients, member.UserID)
+		}
+	} else {
+		user, err := s.db.GetUserByID(*budget.UserID)
+		if err != nil {
+			s.log.Error("budgets: failed to get user", "user_id", *budget.UserID, "error", err)
+			return
+		}
+		name, email = user.Email, user.Email
+		recipients = []string{user.ID}
+	}
+	if budget.AlertEmail != "" {
+		email = budget.AlertEmail
+	}
+
+	text := fmt.Sprintf("%s has spent $%.2f of its $%.2f monthly cloud budget for %s (%.0f%%).",
+		name, spent, budget.MonthlyLimit, period, spent/budget.MonthlyLimit*100)
+	if budget.HardStop && spent >= budget.MonthlyLimit {
+		text += " Running instances are being stopped and new ones refused until the budget is raised or the month ends."
+	}
+
+	// "text" makes the payload a valid Slack or Mattermost webhook message
+	payload := map[string]interface{}{
+		"event":     "budget_alert",
+		"budget_id": budget.ID,
+		"threshold": threshold,
+		"spent":     spent,
+		"limit":     budget.MonthlyLimit,
+		"period":    period,
+		"hard_stop": budget.HardStop,
+		"text":      text,
+	}
+	if budget.TeamID != nil {
+		payload["team_id"] = *budget.TeamID
+	} else {
+		payload["user_id"] = *budget.UserID
+	}
+
+	if s.wsHub != nil {
+		for _, userID := range recipients {
+			s.wsHub.SendToUser(userID, WSMessage{Type: "budget_alert", Payload: payload})
+		}
+	}
+
+	if email != "" && s.config.SMTPAddr != "" {
+		subject := fmt.Sprintf("Cloud budget at %d%%", threshold)
+		if err := s.sendMail(email, subject, text); err != nil {
+			s.log.Error("budgets: failed to email alert", "budget_id", budget.ID, "error", err)
+		}
+	}
+
+	if budget.WebhookURL != "" {
+		if budget.WebhookSecret == "" {
+			// Saved before alerts were signed; saving the budget again adds a secret
+			s.log.Error("budgets: webhook has no signing secret; save the budget again", "budget_id", budget.ID)
+			return
+		}
+		body, _ := json.Marshal(payload)
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		if _, err := s.postSigned(ctx, budget.WebhookURL, budget.WebhookSecret, uuid.New().String(), "budget_alert", body); err != nil {
+			s.log.Error("budgets: webhook failed", "budget_id", budget.ID, "error", err)
+		}
+	}
+}
+
+// emitBudgetExceeded records that a month's spend reached a budget's limit
+func (s *Server) emitBudgetExceeded(budget *db.Budget, spent float64, period string) {
+	data := map[string]interface{}{
+		"budget_id": budget.ID,
+		"spent":     spent,
+		"limit":     budget.MonthlyLimit,
+		"period":    period,
+		"hard_stop": budget.HardStop,
+	}
+	var userID string
+	if budget.TeamID != nil {
+		data["owner"] = "Team " + *budget.TeamID
+		if team, err := s.db.GetTeamByID(*budget.TeamID); err == nil {
+			data["owner"] = "Team " + team.Name
+		}
+	} else {
+		userID = *budget.UserID
+		data["owner"] = userID
+		if user, err := s.db.GetUserByID(userID); err == nil {
+			data["owner"] = user.Email
+		}
+	}
+	s.emitEvent(EventBudgetExceeded, userID, budget.TeamID, "", data)
+}
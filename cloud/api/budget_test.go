@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+// budgetNow is when budgets are checked: mid-month, so an instance started
+// that morning accrues in one month
+var budgetNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// stubProvider starts and stops instances at once
+type stubProvider struct {
+	providers.Provider
+}
+
+func (stubProvider) Name() providers.ProviderType                       { return "stub" }
+func (stubProvider) StartInstance(ctx context.Context, id string) error { return nil }
+func (stubProvider) StopInstance(ctx context.Context, id string) error  { return nil }
+
+// newBudgetFixture is a team fixture whose instances run at stubProvider
+func newBudgetFixture(t *testing.T) *teamFixture {
+	f := newTeamFixture(t)
+	f.s.stop() // The tests check budgets themselves
+	f.s.providers.Register(stubProvider{})
+	return f
+}
+
+// runningInstance creates an instance of a user, in the team if shared,
+// running since a time at an hourly rate
+func (f *teamFixture) runningInstance(owner string, shared bool, since time.Time, rate float64) *db.Instance {
+	f.t.Helper()
+	inst := f.instance(owner, shared)
+	inst.Provider = "stub"
+	inst.Status = "running"
+	inst.CreatedAt = since
+	inst.StartedAt = &since
+	inst.HourlyRate = rate
+	if err := f.s.db.UpdateInstance(inst); err != nil {
+		f.t.Fatal(err)
+	}
+	return inst
+}
+
+// spend records usage of an instance costing cost, ending at a time
+func (f *teamFixture) spend(inst *db.Instance, cost float64, at time.Time) {
+	f.t.Helper()
+	record := &db.UsageRecord{ID: uuid.New().String(), UserID: inst.OwnerID, TeamID: inst.TeamID, InstanceID: inst.ID,
+		Type: "cpu", Quantity: 1, Unit: "hours", UnitPrice: cost, TotalCost: cost,
+		Timestamp: at, PeriodStart: at.Add(-time.Hour), PeriodEnd: at}
+	if err := f.s.db.CreateUsageRecord(record); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func TestBudgetSpend(t *testing.T) {
+	earlier := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	lastMonth := time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		team      bool      // A team budget over shared instances, else the member's own
+		recorded  float64   // Recorded this month
+		lastMonth float64   // Recorded the month before
+		started   time.Time // Of a running instance at $1 an hour; zero for none
+		want      float64
+	}{
+		{"nothing spent", false, 0, 0, time.Time{}, 0},
+		{"recorded usage", false, 40, 0, time.Time{}, 40},
+		{"running time not yet recorded", false, 90, 0, budgetNow.Add(-15 * time.Hour), 105},
+		{"last month doesn't count", false, 10, 500, time.Time{}, 10},
+		{"running since last month", false, 0, 0, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), 13*24 + 12},
+		{"team budget", true, 120, 0, budgetNow.Add(-6 * time.Hour), 126},
+	}
+	for _, tt := range tests {
+		f := newBudgetFixture(t)
+		inst := f.instance("member", tt.team)
+		if !tt.started.IsZero() {
+			inst = f.runningInstance("admin", tt.team, tt.started, 1)
+		}
+		if tt.recorded > 0 {
+			f.spend(inst, tt.recorded, earlier)
+		}
+		if tt.lastMonth > 0 {
+			f.spend(inst, tt.lastMonth, lastMonth)
+		}
+		// Private spend isn't the team's, and the team's isn't the member's
+		// unless they own the instance
+		f.spend(f.instance("owner", !tt.team), 1000, earlier)
+
+		owner := inst.OwnerID
+		budget := &db.Budget{UserID: &owner, MonthlyLimit: 100}
+		if tt.team {
+			budget = &db.Budget{TeamID: &f.teamID, MonthlyLimit: 100}
+		}
+		usage, _, err := f.s.budgetUsage(budget, budgetNow)
+		if err != nil || math.Abs(usage.Cost-tt.want) > 0.001 {
+			t.Errorf("%s: spent = %.4f, %v, want %.4f", tt.name, usage.Cost, err, tt.want)
+		}
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       db.Budget // Of the member, with a limit of $100
+		spent        float64
+		wantAlerts   []int // Thresholds alerted, in order
+		wantAlerted  int
+		wantExceeded int // budget.exceeded events
+		wantStopped  bool
+	}{
+		{"under every threshold", db.Budget{}, 40, nil, 0, 0, false},
+		{"one threshold", db.Budget{}, 60, []int{50}, 50, 0, false},
+		{"two thresholds at once alert the higher", db.Budget{}, 85, []int{80}, 80, 0, false},
+		{"limit reached", db.Budget{}, 100, []int{100}, 100, 1, false},
+		{"limit reached past the last threshold", db.Budget{AlertThresholds: "50,80"}, 110, []int{80}, 100, 1, false},
+		{"already alerted this month", db.Budget{AlertPeriod: "2026-10", AlertedPercent: 80}, 85, nil, 80, 0, false},
+		{"alerted last month", db.Budget{AlertPeriod: "2026-09", AlertedPercent: 100}, 60, []int{50}, 50, 0, false},
+		{"hard stop under the limit", db.Budget{HardStop: true}, 99, []int{80}, 80, 0, false},
+		{"hard stop over the limit", db.Budget{HardStop: true}, 120, []int{100}, 100, 1, true},
+		{"hard stop always alerts at the limit", db.Budget{HardStop: true, AlertThresholds: "50"}, 100, []int{100}, 100, 1, true},
+		{"hard stop over the limit last month", db.Budget{HardStop: true, AlertPeriod: "2026-09", AlertedPercent: 100}, 30, nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		f := newBudgetFixture(t)
+		receiver := newWebhookReceiver(t)
+		memberID := f.users["member"].ID
+		inst := f.runningInstance("member", false, budgetNow.Add(-time.Hour), 0)
+		f.spend(inst, tt.spent, budgetNow.Add(-2*time.Hour))
+
+		secret, err := encryptCredentialData(map[string]string{"secret": "whsec_test"}, f.s.config.JWTSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		budget := tt.budget
+		budget.ID = uuid.New().String()
+		budget.UserID = &memberID
+		budget.MonthlyLimit = 100
+		if budget.AlertThresholds == "" {
+			budget.AlertThresholds = defaultAlertThresholds
+		}
+		budget.WebhookURL, budget.WebhookSecret = receiver.URL, secret
+		if err := f.s.db.SaveBudget(&budget); err != nil {
+			t.Fatal(err)
+		}
+
+		// Checking again alerts nothing more
+		f.s.checkBudgets(context.Background(), budgetNow)
+		f.s.checkBudgets(context.Background(), budgetNow.Add(time.Minute))
+
+		// Alerts are sent in the background
+		received := func() [][]byte {
+			receiver.mu.Lock()
+			defer receiver.mu.Unlock()
+			return append([][]byte(nil), receiver.bodies...)
+		}
+		for deadline := time.Now().Add(5 * time.Second); len(received()) < len(tt.wantAlerts) && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond) // For any alert too many
+		var alerts []int
+		for _, body := range received() {
+			var payload struct{ Threshold int }
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			alerts = append(alerts, payload.Threshold)
+		}
+		if fmt.Sprint(alerts) != fmt.Sprint(tt.wantAlerts) {
+			t.Errorf("%s: alerts = %v, want %v", tt.name, alerts, tt.wantAlerts)
+		}
+
+		saved, err := f.s.db.GetUserBudget(memberID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.wantAlerted > 0 && (saved.AlertPeriod != "2026-10" || saved.AlertedPercent != tt.wantAlerted) {
+			t.Errorf("%s: alerted %d%% in %q, want %d%% in 2026-10", tt.name, saved.AlertedPercent, saved.AlertPeriod, tt.wantAlerted)
+		}
+		events, err := f.s.db.ListEventsForUser(memberID, nil, 0, []string{EventBudgetExceeded}, 10)
+		if err != nil || len(events) != tt.wantExceeded {
+			t.Errorf("%s: %d budget.exceeded events, %v, want %d", tt.name, len(events), err, tt.wantExceeded)
+		}
+
+		got, err := f.s.db.GetInstanceByID(inst.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stopped := got.Status == "stopped"; stopped != tt.wantStopped {
+			t.Errorf("%s: instance %s (%s), want stopped %v", tt.name, got.Status, got.StatusReason, tt.wantStopped)
+		}
+		if tt.wantStopped && !strings.Contains(got.StatusReason, "budget") {
+			t.Errorf("%s: stopped because %q, want the budget", tt.name, got.StatusReason)
+		}
+	}
+}
+
+func TestBudgetBlocksStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		team     bool // The team's budget and a shared instance, else the member's own
+		hardStop bool
+		spent    float64
+		want     int
+	}{
+		{"under the limit", false, true, 50, http.StatusOK},
+		{"hard stop at the limit", false, true, 100, http.StatusPaymentRequired},
+		{"hard stop over the limit", false, true, 150, http.StatusPaymentRequired},
+		{"soft budget over the limit", false, false, 150, http.StatusOK},
+		{"team hard stop at the limit", true, true, 100, http.StatusPaymentRequired},
+		{"team soft budget over the limit", true, false, 150, http.StatusOK},
+	}
+	for _, tt := range tests {
+		f := newBudgetFixture(t)
+		inst := f.instance("member", tt.team)
+		inst.Provider = "stub"
+		if err := f.s.db.UpdateInstance(inst); err != nil {
+			t.Fatal(err)
+		}
+		// exhaustedBudget counts this month's spend up to now
+		f.spend(inst, tt.spent, time.Now().UTC())
+
+		budget := &db.Budget{ID: uuid.New().String(), MonthlyLimit: 100, HardStop: tt.hardStop, AlertThresholds: defaultAlertThresholds}
+		if tt.team {
+			budget.TeamID = &f.teamID
+		} else {
+			budget.UserID = &f.users["member"].ID
+		}
+		if err := f.s.db.SaveBudget(budget); err != nil {
+			t.Fatal(err)
+		}
+
+		f.do("member", http.MethodPost, "/api/v1/instances/"+inst.ID+"/start", "", tt.want, nil)
+	}
+}
@@ -0,0 +1,131 @@
+// Package api provides the API server's configuration from the environment
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJWTSecret is the JWT secret when JWT_SECRET isn't set; it signs
+// tokens and encrypts cloud credentials, so production must set its own
+const DefaultJWTSecret = "dev-secret-key-change-in-production"
+
+// ConfigFromEnv returns the server configuration set by environment
+// variables, with defaults for those that aren't set
+func ConfigFromEnv() (Config, error) {
+	var errs []error
+	config := Config{
+		Port:      8080,
+		JWTSecret: getEnv("JWT_SECRET", DefaultJWTSecret),
+
+		// OAuth (optional - will work without)
+		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
+		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
+
+		// OIDC single sign-on (optional)
+		OIDCIssuerURL:           getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:            getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:        getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:         getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCName:                getEnv("OIDC_NAME", "SSO"),
+		OIDCScopes:              getEnvList("OIDC_SCOPES"),
+		OIDCGroupsClaim:         getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupTeams:          getEnv("OIDC_GROUP_TEAMS", ""),
+		OIDCAllowedDomains:      getEnvList("OIDC_ALLOWED_DOMAINS"),
+		OIDCDisableProvisioning: getEnv("OIDC_JIT", "true") == "false",
+
+		// Database
+		DatabaseDriver:          getEnv("DB_DRIVER", "sqlite"),
+		DatabaseURL:             getEnv("DATABASE_URL", ""),
+		DatabaseManualMigrate:   getEnv("DB_AUTO_MIGRATE", "true") == "false",
+		DatabaseMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25, &errs),
+		DatabaseMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10, &errs),
+		DatabaseConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute, &errs),
+		DatabaseConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute, &errs),
+
+		// Stripe
+		StripeSecretKey: getEnv("STRIPE_SECRET_KEY", ""),
+
+		// Observability
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		// Email notifications
+		SMTPAddr:     getEnv("SMTP_ADDR", ""),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		// Volume snapshots (optional)
+		SnapshotRepository:      getEnv("SNAPSHOT_REPOSITORY", ""),
+		SnapshotAccessKeyID:     getEnv("SNAPSHOT_ACCESS_KEY_ID", ""),
+		SnapshotSecretAccessKey: getEnv("SNAPSHOT_SECRET_ACCESS_KEY", ""),
+		SnapshotRoleARN:         getEnv("SNAPSHOT_ROLE_ARN", ""),
+		SnapshotRegion:          getEnv("SNAPSHOT_REGION", "us-east-1"),
+		SnapshotSTSEndpoint:     getEnv("SNAPSHOT_STS_ENDPOINT", ""),
+
+		// GitHub App for pull request environments (optional)
+		GitHubAppID:         getEnv("GITHUB_APP_ID", ""),
+		GitHubAppPrivateKey: getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubWebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
+		GitHubAPIURL:        getEnv("GITHUB_API_URL", "https://api.github.com"),
+
+		// Provider plugins (optional)
+		ProviderPluginDir: getEnv("PROVIDER_PLUGIN_DIR", ""),
+
+		// Development only: demo user for unauthenticated requests
+		DevMode: getEnv("CM_DEV_MODE", "") == "true",
+	}
+	if path := os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE"); path != "" && config.GitHubAppPrivateKey == "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid GITHUB_APP_PRIVATE_KEY_FILE: %w", err))
+		}
+		config.GitHubAppPrivateKey = string(key)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma- or space-separated variable
+func getEnvList(key string) []string {
+	return strings.FieldsFunc(os.Getenv(key), func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+func getEnvInt(key string, defaultValue int, errs *[]error) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("invalid %s %q: want a number", key, value))
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration, errs *[]error) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("invalid %s %q: want a duration such as 30m", key, value))
+	}
+	return d
+}
@@ -0,0 +1,207 @@
+// Package api provides the OAuth device authorization flow used by the CLI
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// deviceCodeTTL is how long a device code can be approved
+	deviceCodeTTL = 10 * time.Minute
+	// devicePollInterval is the minimum time between token polls
+	devicePollInterval = 5 * time.Second
+	// userCodeAlphabet omits vowels and look-alike characters
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// deviceAuth is a pending device authorization
+type deviceAuth struct {
+	userCode string
+	userID   string // Set once approved
+	denied   bool
+	expires  time.Time
+	lastPoll time.Time
+}
+
+// deviceStore holds pending device authorizations in memory; codes are
+// short-lived, so losing them on restart only means the CLI has to retry
+type deviceStore struct {
+	mu      sync.Mutex
+	pending map[string]*deviceAuth // By device code
+}
+
+func newDeviceStore() *deviceStore {
+	return &deviceStore{pending: make(map[string]*deviceAuth)}
+}
+
+// byUserCode returns the pending authorization with a user code
+func (d *deviceStore) byUserCode(userCode string) *deviceAuth {
+	for _, auth := range d.pending {
+		if auth.userCode == userCode {
+			return auth
+		}
+	}
+	return nil
+}
+
+// expire removes authorizations past their expiry
+func (d *deviceStore) expire(now time.Time) {
+	for code, auth := range d.pending {
+		if now.After(auth.expires) {
+			delete(d.pending, code)
+		}
+	}
+}
+
+// newUserCode returns a code like "BDFG-HJKL" for the user to type
+func newUserCode() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	code := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, userCodeAlphabet[int(v)%len(userCodeAlphabet)])
+	}
+	return string(code)
+}
+
+// normalizeUserCode uppercases a user code and restores its dash
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// DeviceCodeResponse starts a device authorization (RFC 8628)
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// createDeviceCode issues a device code for the CLI and a user code to
+// approve in the browser
+func (s *Server) createDeviceCode(c echo.Context) error {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	deviceCode := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	s.devices.mu.Lock()
+	s.devices.expire(now)
+	userCode := newUserCode()
+	for s.devices.byUserCode(userCode) != nil {
+		userCode = newUserCode()
+	}
+	s.devices.pending[deviceCode] = &deviceAuth{userCode: userCode, expires: now.Add(deviceCodeTTL)}
+	s.devices.mu.Unlock()
+
+	verificationURI := publicBaseURL(c) + "/device"
+
+	return c.JSON(http.StatusOK, DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?code=" + userCode,
+		ExpiresIn:               int64(deviceCodeTTL.Seconds()),
+		Interval:                int64(devicePollInterval.Seconds()),
+	})
+}
+
+// deviceError is an RFC 8628 token error, such as authorization_pending
+func deviceError(c echo.Context, code string) error {
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": code})
+}
+
+// pollDeviceToken exchanges an approved device code for tokens
+func (s *Server) pollDeviceToken(c echo.Context) error {
+	var req struct {
+		DeviceCode string `json:"device_code"`
+	}
+	if err := c.Bind(&req); err != nil || req.DeviceCode == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "device_code is required")
+	}
+
+	now := time.Now()
+	s.devices.mu.Lock()
+	auth, ok := s.devices.pending[req.DeviceCode]
+	if !ok || now.After(auth.expires) {
+		delete(s.devices.pending, req.DeviceCode)
+		s.devices.mu.Unlock()
+		return deviceError(c, "expired_token")
+	}
+	if auth.denied {
+		delete(s.devices.pending, req.DeviceCode)
+		s.devices.mu.Unlock()
+		return deviceError(c, "access_denied")
+	}
+	if auth.userID == "" {
+		tooSoon := now.Sub(auth.lastPoll) < devicePollInterval
+		auth.lastPoll = now
+		s.devices.mu.Unlock()
+		if tooSoon {
+			return deviceError(c, "slow_down")
+		}
+		return deviceError(c, "authorization_pending")
+	}
+	// Approved codes can only be exchanged once
+	delete(s.devices.pending, req.DeviceCode)
+	s.devices.mu.Unlock()
+
+	user, err := s.db.GetUserByID(auth.userID)
+	if err != nil {
+		return deviceError(c, "access_denied")
+	}
+	accessToken, refreshToken, err := s.generateTokenPair(user)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate tokens")
+	}
+
+	return c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    3600,
+		TokenType:    "Bearer",
+		User:         user,
+	})
+}
+
+// approveDevice approves or denies a user code as the signed-in user
+func (s *Server) approveDevice(c echo.Context) error {
+	var req struct {
+		UserCode string `json:"user_code"`
+		Deny     bool   `json:"deny"`
+	}
+	if err := c.Bind(&req); err != nil || req.UserCode == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "user_code is required")
+	}
+	userID := c.Get("user_id").(string)
+
+	s.devices.mu.Lock()
+	defer s.devices.mu.Unlock()
+	s.devices.expire(time.Now())
+	auth := s.devices.byUserCode(normalizeUserCode(req.UserCode))
+	if auth == nil || auth.userID != "" || auth.denied {
+		return echo.NewHTTPError(http.StatusNotFound, "invalid or expired code")
+	}
+	if req.Deny {
+		auth.denied = true
+		return c.JSON(http.StatusOK, map[string]string{"status": "denied"})
+	}
+	auth.userID = userID
+	return c.JSON(http.StatusOK, map[string]string{"status": "approved"})
+}
@@ -0,0 +1,531 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+const (
+	// defaultPREnvironmentType is the instance type of pull request
+	// environments by default
+	defaultPREnvironmentType = "cpu-medium"
+
+	// prEnvironmentTimeout is how long cloning a pull request's branch and
+	// starting its dev container may take once its instance is up
+	prEnvironmentTimeout = time.Hour
+
+	// maxGitHubPayload bounds webhook deliveries; GitHub caps them at 25 MB
+	maxGitHubPayload = 25 << 20
+)
+
+var (
+	// githubRepoPattern matches a repository's owner/name
+	githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
+	// githubLoginPattern matches user logins; bots' aren't, and have no keys
+	githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+	// githubHTTPClient talks to the GitHub API
+	githubHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// githubApp authenticates as the GitHub App to act in the installations
+// of linked repositories
+type githubApp struct {
+	appID  string
+	key    *rsa.PrivateKey
+	apiURL string
+}
+
+func newGitHubApp(cfg Config) (*githubApp, error) {
+	if cfg.GitHubWebhookSecret == "" {
+		return nil, errors.New("GITHUB_WEBHOOK_SECRET is required with GITHUB_APP_ID")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(cfg.GitHubAppPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return &githubApp{appID: cfg.GitHubAppID, key: key, apiURL: strings.TrimSuffix(cfg.GitHubAPIURL, "/")}, nil
+}
+
+// installationToken returns a token acting as the app in an installation,
+// valid for an hour
+func (g *githubApp) installationToken(ctx context.Context, installationID int64) (string, error) {
+	now := time.Now()
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer: g.appID,
+		// Backdated for clock drift; GitHub allows ten minutes at most
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}).SignedString(g.key)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	if err := g.do(ctx, appToken, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", installationID), nil, &created); err != nil {
+		return "", err
+	}
+	return created.Token, nil
+}
+
+// do sends a request to the GitHub API and decodes its response into out,
+// if given
+func (g *githubApp) do(ctx context.Context, token, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := githubHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+		return fmt.Errorf("GitHub answered %s %s with %s: %s", method, path, resp.Status, failure.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// userKeys returns a user's public SSH keys on GitHub
+func (g *githubApp) userKeys(ctx context.Context, token, login string) ([]string, error) {
+	if !githubLoginPattern.MatchString(login) {
+		return nil, nil
+	}
+	var keys []struct {
+		Key string `json:"key"`
+	}
+	if err := g.do(ctx, token, http.MethodGet, "/users/"+login+"/keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if key := strings.TrimSpace(k.Key); key != "" && !strings.ContainsAny(key, "\r\n") {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+// validGitHubSignature checks a delivery's X-Hub-Signature-256 header: the
+// hex HMAC-SHA256 of its payload
+func validGitHubSignature(secret, header string, body []byte) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// githubPullRequestEvent is the part of a pull_request delivery used here
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Head struct {
+			Ref  string `json:"ref"`
+			SHA  string `json:"sha"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		Name     string `json:"name"`
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+// githubWebhook receives the GitHub App's deliveries: pull requests opened
+// in linked repositories get dev environments, which are deleted when the
+// pull requests are merged or closed
+func (s *Server) githubWebhook(c echo.Context) error {
+	if s.github == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "the GitHub App isn't configured")
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxGitHubPayload))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if !validGitHubSignature(s.config.GitHubWebhookSecret, c.Request().Header.Get("X-Hub-Signature-256"), body) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid signature")
+	}
+	if c.Request().Header.Get("X-GitHub-Event") != "pull_request" {
+		// Pings and events the app doesn't act on
+		return c.NoContent(http.StatusNoContent)
+	}
+	var event githubPullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid payload")
+	}
+
+	switch event.Action {
+	case "opened", "reopened":
+		return s.openPREnvironment(c, &event)
+	case "closed":
+		return s.closePREnvironment(c, &event)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// openPREnvironment starts creating the dev environment of a pull request
+// opened in a linked repository
+func (s *Server) openPREnvironment(c echo.Context, event *githubPullRequestEvent) error {
+	repoName := strings.ToLower(event.Repository.FullName)
+	link, err := s.db.GetRepoLinkByName(repoName)
+	if err != nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	head := event.PullRequest.Head
+	if !strings.EqualFold(head.Repo.FullName, event.Repository.FullName) {
+		// Forks' code isn't run on the team's instances
+		s.log.Info("pull request environment skipped for a fork", "repo", repoName, "number", event.Number, "fork", head.Repo.FullName)
+		return c.NoContent(http.StatusNoContent)
+	}
+	cloneURL, err := url.Parse(event.Repository.CloneURL)
+	if err != nil || cloneURL.Scheme != "https" || !gitRefPattern.MatchString(head.Ref) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid payload")
+	}
+
+	var commentID int64
+	if existing, err := s.db.GetPREnvironment(repoName, event.Number); err == nil {
+		if existing.Status != "failed" {
+			// A redelivery
+			return c.NoContent(http.StatusNoContent)
+		}
+		// Reopening retries a failed environment, in the same comment
+		_ = s.db.DeletePREnvironment(existing.ID)
+		commentID = existing.CommentID
+	}
+
+	now := time.Now().UTC()
+	env := &db.PREnvironment{
+		ID:             "pr-" + uuid.New().String()[:8],
+		RepoID:         link.ID,
+		TeamID:         link.TeamID,
+		Repo:           repoName,
+		Number:         event.Number,
+		Branch:         head.Ref,
+		HeadSHA:        head.SHA,
+		Author:         event.PullRequest.User.Login,
+		InstallationID: event.Installation.ID,
+		CommentID:      commentID,
+		Status:         "provisioning",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.db.CreatePREnvironment(env); err != nil {
+		// A concurrent redelivery
+		return c.NoContent(http.StatusNoContent)
+	}
+	go s.runPREnvironment(detachedContext(c), env, link, cloneURL, event.Repository.Name, publicBaseURL(c))
+	return c.NoContent(http.StatusAccepted)
+}
+
+// closePREnvironment deletes the dev environment of a merged or closed
+// pull request. One still being created is deleted once it's created.
+func (s *Server) closePREnvironment(c echo.Context, event *githubPullRequestEvent) error {
+	env, err := s.db.GetPREnvironment(strings.ToLower(event.Repository.FullName), event.Number)
+	if err != nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+	if err := s.db.DeletePREnvironment(env.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete environment")
+	}
+	if env.Status != "provisioning" {
+		go s.teardownPREnvironment(detachedContext(c), env)
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// runPREnvironment creates the instance of a pull request's environment,
+// clones the branch on it and starts its dev container, and comments how to
+// connect on the pull request
+func (s *Server) runPREnvironment(ctx context.Context, env *db.PREnvironment, link *db.RepoLink, cloneURL *url.URL, name, cloudURL string) {
+	s.commentPR(ctx, env, fmt.Sprintf("⏳ Creating a dev environment for `%s`...", env.Branch))
+	inst := &db.Instance{
+		ID:           "inst-" + uuid.New().String()[:8],
+		OwnerID:      link.CreatedBy,
+		TeamID:       &link.TeamID,
+		Name:         strings.Trim(remoteNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-") + fmt.Sprintf("-pr-%d", env.Number),
+		Provider:     link.Provider,
+		InstanceType: link.InstanceType,
+		Region:       link.Region,
+	}
+	env.InstanceID = &inst.ID
+	env.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdatePREnvironment(env)
+
+	err := func() error {
+		provider, err := s.providers.Get(providers.ProviderType(link.Provider))
+		if err != nil {
+			return fmt.Errorf("unsupported provider: %s", link.Provider)
+		}
+		if budget := s.exhaustedBudget(link.CreatedBy, &link.TeamID); budget != nil {
+			return errors.New("the team's budget is exhausted")
+		}
+		if err := s.launchInstance(ctx, inst, provider, cloudURL); err != nil {
+			return err
+		}
+		token, err := s.github.installationToken(ctx, env.InstallationID)
+		if err != nil {
+			return err
+		}
+		keys, err := s.github.userKeys(ctx, token, env.Author)
+		if err != nil {
+			s.log.Warn("failed to get the pull request author's SSH keys", "author", env.Author, "error", err)
+		}
+		script := prEnvironmentScript(env, cloneURL, token, sshUser(inst.Provider), workspaceName(name), keys)
+		_, err = s.instanceJob(ctx, inst.ID, "/var/lib/cm/pr-environments/"+env.ID, script, prEnvironmentTimeout)
+		return err
+	}()
+
+	if current, gerr := s.db.GetPREnvironment(env.Repo, env.Number); gerr != nil || current.ID != env.ID {
+		// Closed while it was created
+		s.destroyInstance(inst)
+		s.commentPR(ctx, env, "🧹 The dev environment of this pull request was deleted, as the pull request was closed.")
+		return
+	}
+	if err != nil {
+		s.log.Error("pull request environment failed", "repo", env.Repo, "number", env.Number, "error", err)
+		s.destroyInstance(inst)
+		env.InstanceID = nil
+		env.Status, env.StatusReason = "failed", truncate(err.Error(), 1000)
+		s.commentPR(ctx, env, "⚠️ Couldn't create a dev environment for this pull request:\n\n```\n"+truncate(err.Error(), 1000)+"\n```")
+	} else {
+		env.Status = "ready"
+		s.commentPR(ctx, env, prEnvironmentComment(env, inst, workspaceName(name), cloudURL))
+	}
+	env.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdatePREnvironment(env)
+}
+
+// teardownPREnvironment deletes the instance of a closed pull request's
+// environment and says so on the pull request
+func (s *Server) teardownPREnvironment(ctx context.Context, env *db.PREnvironment) {
+	if env.InstanceID != nil {
+		if inst, err := s.db.GetInstanceByID(*env.InstanceID); err == nil {
+			s.destroyInstance(inst)
+		}
+	}
+	if env.Status == "ready" {
+		s.commentPR(ctx, env, "🧹 The dev environment of this pull request was deleted.")
+	}
+}
+
+// commentPR posts the environment's comment on its pull request, or
+// updates it once posted. Failing to comment only gets logged.
+func (s *Server) commentPR(ctx context.Context, env *db.PREnvironment, body string) {
+	token, err := s.github.installationToken(ctx, env.InstallationID)
+	if err == nil {
+		if env.CommentID == 0 {
+			var comment struct {
+				ID int64 `json:"id"`
+			}
+			err = s.github.do(ctx, token, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", env.Repo, env.Number), map[string]string{"body": body}, &comment)
+			env.CommentID = comment.ID
+		} else {
+			err = s.github.do(ctx, token, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", env.Repo, env.CommentID), map[string]string{"body": body}, nil)
+		}
+	}
+	if err != nil {
+		s.log.Warn("failed to comment on pull request", "repo", env.Repo, "number", env.Number, "error", err)
+	}
+}
+
+// remoteNameInvalid matches what instance and workspace names can't have
+var remoteNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// workspaceName names the directory a repository is cloned into
+func workspaceName(repo string) string {
+	if name := strings.Trim(remoteNameInvalid.ReplaceAllString(repo, "-"), ".-"); name != "" {
+		return name
+	}
+	return "workspace"
+}
+
+// prEnvironmentScript returns the script that authorizes the author's SSH
+// keys for the login user, clones the pull request's branch into
+// ~/workspace/<name> and starts its dev container as the user
+func prEnvironmentScript(env *db.PREnvironment, cloneURL *url.URL, token, user, name string, keys []string) string {
+	authenticated := *cloneURL
+	authenticated.User = url.UserPassword("x-access-token", token)
+	start := "cd workspace/" + shellQuote(name) + " && if [ -f cm-workspace.yaml ]; then cm up; else cm exec true; fi"
+
+	var sb strings.Builder
+	sb.WriteString("set -e\n")
+	sb.WriteString("command -v git >/dev/null || { apt-get update -qq && apt-get install -y -qq git; } >/dev/null 2>&1\n")
+	sb.WriteString("u=" + shellQuote(user) + "\nhome=$(getent passwd \"$u\" | cut -d: -f6)\n")
+	sb.WriteString("log=$(mktemp); trap 'rm -f \"$log\"' EXIT\n")
+	if len(keys) > 0 {
+		sb.WriteString("mkdir -p \"$home/.ssh\"\nprintf '%s\\n'")
+		for _, key := range keys {
+			sb.WriteString(" " + shellQuote(key))
+		}
+		sb.WriteString(" >> \"$home/.ssh/authorized_keys\"\n")
+		sb.WriteString("chown -R \"$u\" \"$home/.ssh\"; chmod 700 \"$home/.ssh\"; chmod 600 \"$home/.ssh/authorized_keys\"\n")
+	}
+	sb.WriteString("d=\"$home/workspace/\"" + shellQuote(name) + "\nrm -rf \"$d\"; mkdir -p \"$home/workspace\"\n")
+	// The token is kept out of errors and out of the clone's remote
+	sb.WriteString("git clone --quiet --depth 1 --branch " + shellQuote(env.Branch) + " " + shellQuote(authenticated.String()) +
+		" \"$d\" > \"$log\" 2>&1 || { sed 's|://[^@/]*@|://|' \"$log\" >&2; exit 1; }\n")
+	sb.WriteString("git -C \"$d\" remote set-url origin " + shellQuote(cloneURL.String()) + "\n")
+	sb.WriteString("chown -R \"$u\" \"$home/workspace\"\n")
+	sb.WriteString("su - \"$u\" -c " + shellQuote(start) + " > \"$log\" 2>&1 || { tail -c 2000 \"$log\" >&2; exit 1; }\n")
+	return sb.String()
+}
+
+// prEnvironmentComment tells how to connect to a ready environment
+func prEnvironmentComment(env *db.PREnvironment, inst *db.Instance, name, cloudURL string) string {
+	sha := env.HeadSHA
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	port := inst.SSHPort
+	if port == 0 {
+		port = 22
+	}
+	var sb strings.Builder
+	sb.WriteString("### 🚀 Dev environment ready\n\n")
+	fmt.Fprintf(&sb, "`%s` at %s is running in its dev container on instance `%s` (%s %s).\n\n", env.Branch, sha, inst.ID, inst.Provider, inst.InstanceType)
+	fmt.Fprintf(&sb, "@%s's GitHub SSH keys are authorized on it. Connect with [cm](https://github.com/UPwith-me/Container-Maker):\n\n", env.Author)
+	sb.WriteString("```sh\n")
+	fmt.Fprintf(&sb, "cm cloud ssh %s                               # Then: cd workspace/%s && cm shell\n", inst.ID, name)
+	fmt.Fprintf(&sb, "cm cloud ssh %s -- -N -L 3000:localhost:3000  # Forward port 3000\n", inst.ID)
+	sb.WriteString("```\n\n")
+	if inst.PublicIP != "" {
+		fmt.Fprintf(&sb, "or with plain SSH: `ssh -p %d %s@%s`\n\n", port, sshUser(inst.Provider), inst.PublicIP)
+	}
+	fmt.Fprintf(&sb, "The team can also [open it in the dashboard](%s/instances/%s), which has a terminal. It's deleted when this pull request is merged or closed.\n", cloudURL, inst.ID)
+	return sb.String()
+}
+
+// listGitHubRepos lists the repositories linked to a team
+func (s *Server) listGitHubRepos(c echo.Context) error {
+	repos, err := s.db.ListRepoLinks(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list repositories")
+	}
+	return c.JSON(http.StatusOK, repos)
+}
+
+// linkGitHubRepo links a repository to a team; its pull requests get
+// environments owned by the admin who links it
+func (s *Server) linkGitHubRepo(c echo.Context) error {
+	var req struct {
+		Repo         string `json:"repo"` // owner/name
+		Provider     string `json:"provider"`
+		InstanceType string `json:"instance_type"`
+		Region       string `json:"region"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if !githubRepoPattern.MatchString(req.Repo) {
+		return echo.NewHTTPError(http.StatusBadRequest, "repo must be owner/name")
+	}
+	if _, err := s.providers.Get(providers.ProviderType(req.Provider)); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported provider: "+req.Provider)
+	}
+	if req.InstanceType == "" {
+		req.InstanceType = defaultPREnvironmentType
+	}
+	repoName := strings.ToLower(req.Repo)
+	if _, err := s.db.GetRepoLinkByName(repoName); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, req.Repo+" is already linked to a team")
+	}
+
+	now := time.Now().UTC()
+	repo := &db.RepoLink{
+		ID:           "gh-" + uuid.New().String()[:8],
+		TeamID:       c.Param("id"),
+		CreatedBy:    c.Get("user_id").(string),
+		Repo:         repoName,
+		Provider:     req.Provider,
+		InstanceType: req.InstanceType,
+		Region:       req.Region,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.db.CreateRepoLink(repo); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to link repository")
+	}
+	return c.JSON(http.StatusCreated, repo)
+}
+
+// unlinkGitHubRepo unlinks a repository from a team; its open pull
+// requests' environments stay until they're closed
+func (s *Server) unlinkGitHubRepo(c echo.Context) error {
+	repo, err := s.db.GetRepoLinkByID(c.Param("repoId"))
+	if err != nil || repo.TeamID != c.Param("id") {
+		return echo.NewHTTPError(http.StatusNotFound, "repository not found")
+	}
+	if err := s.db.DeleteRepoLink(repo.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unlink repository")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listPREnvironments lists a team's pull request environments
+func (s *Server) listPREnvironments(c echo.Context) error {
+	envs, err := s.db.ListPREnvironments(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list environments")
+	}
+	return c.JSON(http.StatusOK, envs)
+}
@@ -0,0 +1,568 @@
+// Package api provides the hosts provider, which runs instances on the
+// machines users and teams registered
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+// Labels of the containers of instances on hosts; they let a host's usage
+// be read back from its containers
+const (
+	poolLabelInstance = "cm.instance"
+	poolLabelOwner    = "cm.owner"
+	poolLabelCPUs     = "cm.cpus"
+	poolLabelMemory   = "cm.memory-mb"
+	poolLabelGPUs     = "cm.gpus"
+)
+
+// poolRegion is the one region of the hosts provider
+const poolRegion = "pool"
+
+// poolTypes are the sizes instances on hosts are given; GPU types take one
+// whole GPU of whatever model the host has
+var poolTypes = []providers.InstancePricing{
+	{Type: providers.InstanceTypeCPUSmall, VCPU: 2, MemoryGB: 4},
+	{Type: providers.InstanceTypeCPUMedium, VCPU: 4, MemoryGB: 8},
+	{Type: providers.InstanceTypeCPULarge, VCPU: 8, MemoryGB: 16},
+	{Type: providers.InstanceTypeGPUT4, VCPU: 4, MemoryGB: 16, GPUType: "any"},
+	{Type: providers.InstanceTypeGPUA10, VCPU: 8, MemoryGB: 32, GPUType: "any"},
+	{Type: providers.InstanceTypeGPUA100, VCPU: 8, MemoryGB: 80, GPUType: "any"},
+}
+
+// hostPool is the hosts provider. It places each instance on a connected
+// host of its owner, or of its team, with room for its type, and runs it
+// there as a Docker container through the host's agent.
+type hostPool struct {
+	s *Server
+
+	mu       sync.Mutex           // Serializes placements, so two don't take the same room
+	reserved map[string]hostUsage // Of placements whose containers are being created, by host ID
+}
+
+func newHostPool(s *Server) *hostPool {
+	return &hostPool{s: s, reserved: make(map[string]hostUsage)}
+}
+
+// hostUsage is what instances take of a host
+type hostUsage struct {
+	vcpu     int
+	memoryMB int64
+	gpus     []int // Device indexes
+}
+
+func (u hostUsage) plus(v hostUsage) hostUsage {
+	return hostUsage{
+		vcpu:     u.vcpu + v.vcpu,
+		memoryMB: u.memoryMB + v.memoryMB,
+		gpus:     append(append([]int(nil), u.gpus...), v.gpus...),
+	}
+}
+
+func (p *hostPool) Name() providers.ProviderType {
+	return providers.ProviderHosts
+}
+
+func (p *hostPool) DisplayName() string {
+	return "Your Machines"
+}
+
+func (p *hostPool) Description() string {
+	return "Run instances as Docker containers on machines you or your team registered, such as idle lab GPUs."
+}
+
+func (p *hostPool) Website() string {
+	return "https://github.com/UPwith-me/Container-Maker"
+}
+
+func (p *hostPool) Features() []string {
+	return []string{"on-premises", "free", "gpu", "devcontainers"}
+}
+
+func (p *hostPool) RequiredCredentials() []string {
+	return []string{} // Hosts are registered instead
+}
+
+func (p *hostPool) Configure(credentials map[string]string) error {
+	return nil
+}
+
+func (p *hostPool) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+func (p *hostPool) Regions() []providers.Region {
+	return []providers.Region{
+		{ID: poolRegion, Name: "Registered machines", Country: "Local", Available: true, GPUAvailable: true},
+	}
+}
+
+func (p *hostPool) InstanceTypes() []providers.InstancePricing {
+	return poolTypes
+}
+
+// hostContainer splits the provider ID of an instance into its host's ID
+// and its container's name
+func (p *hostPool) hostContainer(id string) (hostID, name string, err error) {
+	hostID, name, ok := strings.Cut(id, "/")
+	if !ok {
+		return "", "", fmt.Errorf("%q isn't the ID of an instance on a host", id)
+	}
+	return hostID, name, nil
+}
+
+// docker runs the docker CLI on a host and returns its output
+func (p *hostPool) docker(ctx context.Context, hostID string, args ...string) (string, error) {
+	reply, err := p.s.agents.call(ctx, hostID, agentMessage{Type: "exec", Command: append([]string{"docker"}, args...)})
+	if errors.Is(err, errAgentNotConnected) {
+		return "", errors.New("the host's agent isn't connected")
+	}
+	if err != nil {
+		return "", err
+	}
+	if reply.ExitCode != 0 {
+		if msg := strings.TrimSpace(reply.Stderr); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("docker %s exited with %d", args[0], reply.ExitCode)
+	}
+	return reply.Stdout, nil
+}
+
+// usage reads what the instances on a host take of it from their
+// containers, and removes the containers of deleted instances
+func (p *hostPool) usage(ctx context.Context, host *db.Host) (hostUsage, error) {
+	out, err := p.docker(ctx, host.ID, "ps", "-a", "--filter", "label="+poolLabelInstance, "--format",
+		`{{.Names}}|{{.Label "`+poolLabelInstance+`"}}|{{.Label "`+poolLabelCPUs+`"}}|{{.Label "`+poolLabelMemory+`"}}|{{.Label "`+poolLabelGPUs+`"}}`)
+	if err != nil {
+		return hostUsage{}, err
+	}
+	var used hostUsage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 5 {
+			continue
+		}
+		if _, err := p.s.db.GetInstanceByID(fields[1]); errors.Is(err, gorm.ErrRecordNotFound) {
+			if _, err := p.docker(ctx, host.ID, "rm", "-f", fields[0]); err != nil {
+				p.s.log.Error("failed to remove container of deleted instance", "host_id", host.ID, "container", fields[0], "error", err)
+			} else {
+				p.s.log.Info("removed container of deleted instance", "host_id", host.ID, "container", fields[0])
+			}
+			continue
+		}
+		vcpu, _ := strconv.Atoi(fields[2])
+		memoryMB, _ := strconv.ParseInt(fields[3], 10, 64)
+		used.vcpu += vcpu
+		used.memoryMB += memoryMB
+		for _, gpu := range strings.Split(fields[4], ",") {
+			if index, err := strconv.Atoi(gpu); err == nil {
+				used.gpus = append(used.gpus, index)
+			}
+		}
+	}
+	return used, nil
+}
+
+// place picks the connected host with the least room left after taking
+// size, and the GPUs it gives, and reserves them until release is called
+func (p *hostPool) place(ctx context.Context, config providers.InstanceConfig, size providers.InstancePricing) (*db.Host, []int, func(), error) {
+	hosts, err := p.s.db.ListPoolHosts(config.OwnerID, config.TeamID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, nil, nil, errors.New("no hosts are registered; add one with 'cm cloud host add'")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *db.Host
+	var bestGPUs []int
+	var bestLeft [2]int64
+	for i := range hosts {
+		host := &hosts[i]
+		if _, online := p.s.agents.get(host.ID); !online || host.VCPU == 0 {
+			continue
+		}
+		used, err := p.usage(ctx, host)
+		if err != nil {
+			p.s.log.Error("failed to read host containers", "host_id", host.ID, "error", err)
+			continue
+		}
+		used = used.plus(p.reserved[host.ID])
+
+		left := [2]int64{int64(host.VCPU - used.vcpu - size.VCPU), host.MemoryMB - used.memoryMB - int64(size.MemoryGB)*1024}
+		if left[0] < 0 || left[1] < 0 {
+			continue
+		}
+		var gpus []int
+		if size.GPUType != "" {
+			taken := make(map[int]bool)
+			for _, index := range used.gpus {
+				taken[index] = true
+			}
+			for index := range hostGPUs(host) {
+				if !taken[index] {
+					gpus = []int{index}
+					break
+				}
+			}
+			if gpus == nil {
+				continue
+			}
+		}
+		if best == nil || left[0] < bestLeft[0] || (left[0] == bestLeft[0] && left[1] < bestLeft[1]) {
+			best, bestGPUs, bestLeft = host, gpus, left
+		}
+	}
+	if best == nil {
+		need := fmt.Sprintf("%d vCPUs and %d GB of memory", size.VCPU, size.MemoryGB)
+		if size.GPUType != "" {
+			need += " and a GPU"
+		}
+		return nil, nil, nil, fmt.Errorf("no connected host has %s free for a %s instance", need, size.Type)
+	}
+
+	reservation := hostUsage{vcpu: size.VCPU, memoryMB: int64(size.MemoryGB) * 1024, gpus: bestGPUs}
+	p.reserved[best.ID] = p.reserved[best.ID].plus(reservation)
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		r := p.reserved[best.ID]
+		r.vcpu -= reservation.vcpu
+		r.memoryMB -= reservation.memoryMB
+		r.gpus = slices.DeleteFunc(r.gpus, func(index int) bool { return slices.Contains(reservation.gpus, index) })
+		if r.vcpu == 0 {
+			delete(p.reserved, best.ID)
+		} else {
+			p.reserved[best.ID] = r
+		}
+	}
+	return best, bestGPUs, release, nil
+}
+
+// hostGPUs returns the GPU models of a host, by device index
+func hostGPUs(host *db.Host) []string {
+	if host.GPUs == "" {
+		return nil
+	}
+	return strings.Split(host.GPUs, ",")
+}
+
+func (p *hostPool) CreateInstance(ctx context.Context, config providers.InstanceConfig) (*providers.Instance, error) {
+	var size providers.InstancePricing
+	for _, t := range poolTypes {
+		if t.Type == config.Type {
+			size = t
+		}
+	}
+	if size.Type == "" {
+		return nil, fmt.Errorf("unknown instance type %q", config.Type)
+	}
+	// Containers are told from those of deleted instances by their
+	// instance's ID
+	instanceID := config.Env["CM_INSTANCE_ID"]
+	if instanceID == "" {
+		return nil, errors.New("instances on hosts are created by the control plane")
+	}
+	host, gpus, release, err := p.place(ctx, config, size)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	name := "cm-" + instanceID
+	args := []string{
+		"run", "-d",
+		"--name", name,
+		"--hostname", config.Name,
+		"--restart", "unless-stopped",
+		"--cpus", strconv.Itoa(size.VCPU),
+		"--memory", fmt.Sprintf("%dg", size.MemoryGB),
+		"--label", poolLabelInstance + "=" + instanceID,
+		"--label", poolLabelOwner + "=" + config.OwnerID,
+		"--label", poolLabelCPUs + "=" + strconv.Itoa(size.VCPU),
+		"--label", fmt.Sprintf("%s=%d", poolLabelMemory, size.MemoryGB*1024),
+	}
+	var gpuNames []string
+	if len(gpus) > 0 {
+		indexes := make([]string, len(gpus))
+		for i, index := range gpus {
+			indexes[i] = strconv.Itoa(index)
+			gpuNames = append(gpuNames, hostGPUs(host)[index])
+		}
+		devices := strings.Join(indexes, ",")
+		args = append(args, "--label", poolLabelGPUs+"="+devices, "--gpus", `"device=`+devices+`"`)
+	}
+	if requestID := providers.RequestID(ctx); requestID != "" {
+		args = append(args, "--label", "cm.request-id="+requestID)
+	}
+	keys := make([]string, 0, len(config.Env))
+	for k := range config.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+config.Env[k])
+	}
+	args = append(args, "-p", "22")
+	for _, port := range config.Ports {
+		args = append(args, "-p", strconv.Itoa(port))
+	}
+	image := config.Image
+	if image == "" {
+		image = "ubuntu:22.04"
+	}
+	args = append(args, image, "sleep", "infinity")
+	if _, err := p.docker(ctx, host.ID, args...); err != nil {
+		return nil, fmt.Errorf("failed to create the container on %s: %w", host.Name, err)
+	}
+
+	ports, err := p.ports(ctx, host.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	inst := &providers.Instance{
+		ID:           host.ID + "/" + name,
+		Name:         config.Name,
+		Type:         config.Type,
+		Status:       providers.StatusRunning,
+		Provider:     providers.ProviderHosts,
+		Region:       poolRegion,
+		PublicIP:     host.Address,
+		SSHPort:      ports[22],
+		ExposedPorts: ports,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		OwnerID:      config.OwnerID,
+		TeamID:       config.TeamID,
+		Metadata:     map[string]string{"host_id": host.ID, "host": host.Name},
+	}
+	if len(gpuNames) > 0 {
+		inst.Metadata["gpus"] = strings.Join(gpuNames, ",")
+	}
+	return inst, nil
+}
+
+// ports returns the host ports a container's ports are published on
+func (p *hostPool) ports(ctx context.Context, hostID, name string) (map[int]int, error) {
+	out, err := p.docker(ctx, hostID, "port", name)
+	if err != nil {
+		return nil, err
+	}
+	ports := make(map[int]int)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		// 22/tcp -> 0.0.0.0:32768
+		container, host, ok := strings.Cut(line, " -> ")
+		if !ok {
+			continue
+		}
+		containerPort, err1 := strconv.Atoi(strings.TrimSuffix(container, "/tcp"))
+		hostPort, err2 := strconv.Atoi(host[strings.LastIndex(host, ":")+1:])
+		if err1 == nil && err2 == nil {
+			ports[containerPort] = hostPort
+		}
+	}
+	return ports, nil
+}
+
+func (p *hostPool) GetInstance(ctx context.Context, id string) (*providers.Instance, error) {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	host, err := p.s.db.GetHostByID(hostID)
+	if err != nil {
+		return nil, fmt.Errorf("instance not found: %s", id)
+	}
+	out, err := p.docker(ctx, hostID, "inspect", "--format", "{{.State.Status}}|{{.Config.Hostname}}", name)
+	if err != nil {
+		return nil, err
+	}
+	state, hostname, _ := strings.Cut(strings.TrimSpace(out), "|")
+	status := providers.StatusRunning
+	if state != "running" {
+		status = providers.StatusStopped
+	}
+	return &providers.Instance{
+		ID:       id,
+		Name:     hostname,
+		Status:   status,
+		Provider: providers.ProviderHosts,
+		Region:   poolRegion,
+		PublicIP: host.Address,
+		Metadata: map[string]string{"host_id": host.ID, "host": host.Name},
+	}, nil
+}
+
+// ListInstances lists the containers of an owner's instances on the
+// connected hosts they or their teams registered
+func (p *hostPool) ListInstances(ctx context.Context, ownerID string) ([]*providers.Instance, error) {
+	teamIDs, err := p.s.db.TeamIDsByUser(ownerID, db.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := p.s.db.ListHostsForUser(ownerID, teamIDs)
+	if err != nil {
+		return nil, err
+	}
+	var instances []*providers.Instance
+	for _, host := range hosts {
+		if _, online := p.s.agents.get(host.ID); !online {
+			continue
+		}
+		out, err := p.docker(ctx, host.ID, "ps", "-a", "--filter", "label="+poolLabelOwner+"="+ownerID, "--format", "{{.Names}}|{{.State}}")
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			name, state, ok := strings.Cut(line, "|")
+			if !ok {
+				continue
+			}
+			status := providers.StatusRunning
+			if state != "running" {
+				status = providers.StatusStopped
+			}
+			instances = append(instances, &providers.Instance{
+				ID:       host.ID + "/" + name,
+				Name:     name,
+				Status:   status,
+				Provider: providers.ProviderHosts,
+				Region:   poolRegion,
+				PublicIP: host.Address,
+				OwnerID:  ownerID,
+			})
+		}
+	}
+	return instances, nil
+}
+
+func (p *hostPool) StartInstance(ctx context.Context, id string) error {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return err
+	}
+	_, err = p.docker(ctx, hostID, "start", name)
+	return err
+}
+
+func (p *hostPool) StopInstance(ctx context.Context, id string) error {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return err
+	}
+	_, err = p.docker(ctx, hostID, "stop", name)
+	return err
+}
+
+func (p *hostPool) DeleteInstance(ctx context.Context, id string) error {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return err
+	}
+	_, err = p.docker(ctx, hostID, "rm", "-f", name)
+	return err
+}
+
+func (p *hostPool) GetSSHEndpoint(ctx context.Context, id string) (string, int, error) {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return "", 0, err
+	}
+	host, err := p.s.db.GetHostByID(hostID)
+	if err != nil {
+		return "", 0, fmt.Errorf("instance not found: %s", id)
+	}
+	ports, err := p.ports(ctx, hostID, name)
+	if err != nil {
+		return "", 0, err
+	}
+	return host.Address, ports[22], nil
+}
+
+// ExecCommand runs a command in an instance's container through the host's
+// agent
+func (p *hostPool) ExecCommand(ctx context.Context, id string, command []string) (string, string, int, error) {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return "", "", 0, err
+	}
+	reply, err := p.s.agents.call(ctx, hostID, agentMessage{Type: "exec", Container: name, Command: command})
+	if err != nil {
+		return "", "", 0, err
+	}
+	return reply.Stdout, reply.Stderr, reply.ExitCode, nil
+}
+
+func (p *hostPool) GetLogs(ctx context.Context, id string, tail int) (string, error) {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return "", err
+	}
+	return p.s.agentLogs(ctx, hostID, name, tail)
+}
+
+// StreamLogs sends an instance's log lines, new ones as they are written,
+// until ctx is done or the host's agent disconnects
+func (p *hostPool) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
+	hostID, name, err := p.hostContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	replies, done, err := p.s.agents.request(hostID, agentMessage{Type: "logs", Container: name, Tail: 100, Follow: true})
+	if err != nil {
+		return nil, err
+	}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case reply, ok := <-replies:
+				if !ok || reply.Type != "log" {
+					return
+				}
+				select {
+				case lines <- reply.Line:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return lines, nil
+}
+
+// releaseHostInstance removes the container of an instance on a host when
+// the instance is deleted; one the host's agent can't remove now is
+// removed when the agent next connects
+func (s *Server) releaseHostInstance(ctx context.Context, instance *db.Instance) {
+	if instance.Provider != string(providers.ProviderHosts) || instance.ProviderID == "" {
+		return
+	}
+	err := s.callProvider(ctx, s.pool, "delete_instance", func(ctx context.Context) error {
+		return s.pool.DeleteInstance(ctx, instance.ProviderID)
+	})
+	if err != nil {
+		s.log.Warn("failed to remove instance container", "instance_id", instance.ID, "error", err)
+	}
+}
@@ -0,0 +1,192 @@
+// Package api provides hosts, the machines users and teams register to run
+// instances on
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// hostResponse is a host with whether its agent is connected
+type hostResponse struct {
+	db.Host
+	Online bool `json:"online"`
+}
+
+func (s *Server) hostResponse(host *db.Host) hostResponse {
+	_, online := s.agents.get(host.ID)
+	return hostResponse{Host: *host, Online: online}
+}
+
+// requireHost loads the host named by :id into the context as "host" if the
+// signed-in user has the access to it
+func (s *Server) requireHost(need access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			host, err := s.db.GetHostByID(c.Param("id"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "host not found")
+			}
+			if err := s.resourceAccess(c.Get("user_id").(string), host.OwnerID, host.TeamID, need); err != nil {
+				if err == errNotFound {
+					return echo.NewHTTPError(http.StatusNotFound, "host not found")
+				}
+				return err
+			}
+			c.Set("host", host)
+			return next(c)
+		}
+	}
+}
+
+// listHosts lists the signed-in user's hosts and those of their teams
+func (s *Server) listHosts(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	teamIDs, err := s.db.TeamIDsByUser(userID, db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	hosts, err := s.db.ListHostsForUser(userID, teamIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list hosts")
+	}
+	result := make([]hostResponse, len(hosts))
+	for i := range hosts {
+		result[i] = s.hostResponse(&hosts[i])
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) getHost(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.hostResponse(c.Get("host").(*db.Host)))
+}
+
+// createHost registers a machine and returns the token its agent connects
+// with, which isn't shown again, and the command that starts the agent
+func (s *Server) createHost(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	var req struct {
+		Name    string  `json:"name"`
+		Address string  `json:"address"` // Where instances' SSH ports are reached; the agent's IP by default
+		TeamID  *string `json:"team_id"` // Runs the team's instances
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required and at most 100 characters")
+	}
+	teamID, err := s.teamScope(c, req.TeamID, db.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash := newAgentToken()
+	now := time.Now().UTC()
+	host := &db.Host{
+		ID:        "host-" + uuid.New().String()[:8],
+		OwnerID:   userID,
+		TeamID:    teamID,
+		Name:      req.Name,
+		Address:   strings.TrimSpace(req.Address),
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.CreateHost(host); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create host")
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"host":    s.hostResponse(host),
+		"token":   token,
+		"command": fmt.Sprintf("CM_CLOUD_URL=%s CM_HOST_ID=%s CM_AGENT_TOKEN=%s cm agent start", publicBaseURL(c), host.ID, token),
+	})
+}
+
+// deleteHost unregisters a host that runs no instances and disconnects its
+// agent
+func (s *Server) deleteHost(c echo.Context) error {
+	host := c.Get("host").(*db.Host)
+	count, err := s.db.CountHostInstances(host.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count instances")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "the host still runs instances; delete them first")
+	}
+	if err := s.db.DeleteHost(host.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete host")
+	}
+	if a, ok := s.agents.get(host.ID); ok {
+		a.conn.Close()
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandleHostAgentWebSocket accepts the control channel of the cm agent on
+// a host, which authenticates with the host's token
+func (s *Server) HandleHostAgentWebSocket(c echo.Context) error {
+	host, err := s.db.GetHostByID(c.Param("id"))
+	token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+	if err != nil || subtle.ConstantTimeCompare([]byte(hashAgentToken(token)), []byte(host.TokenHash)) != 1 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid agent token")
+	}
+	ip := c.RealIP()
+	return s.serveAgent(c, host.ID, "host_id", func(ctx context.Context) {
+		s.hostConnected(ctx, host.ID, ip)
+	})
+}
+
+// hostConnected records the capacity a host's agent reports and removes
+// the containers of instances deleted while it was away
+func (s *Server) hostConnected(ctx context.Context, hostID, ip string) {
+	reply, err := s.agents.call(ctx, hostID, agentMessage{Type: "health"})
+	if err != nil {
+		s.log.Error("failed to read host capacity", "host_id", hostID, "error", err)
+		return
+	}
+	var health struct {
+		CPUs          int      `json:"cpus"`
+		MemoryTotalMB int64    `json:"memory_total_mb"`
+		GPUs          []string `json:"gpus"`
+		DockerVersion string   `json:"docker_version"`
+	}
+	if err := json.Unmarshal(reply.Health, &health); err != nil {
+		s.log.Error("failed to read host capacity", "host_id", hostID, "error", err)
+		return
+	}
+
+	host, err := s.db.GetHostByID(hostID)
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	host.VCPU = health.CPUs
+	host.MemoryMB = health.MemoryTotalMB
+	host.GPUs = strings.Join(health.GPUs, ",")
+	host.DockerVersion = health.DockerVersion
+	host.LastSeenAt = &now
+	host.UpdatedAt = now
+	if host.Address == "" {
+		host.Address = ip
+	}
+	if err := s.db.UpdateHost(host); err != nil {
+		s.log.Error("failed to record host capacity", "host_id", hostID, "error", err)
+		return
+	}
+
+	if _, err := s.pool.usage(ctx, host); err != nil {
+		s.log.Error("failed to read host containers", "host_id", hostID, "error", err)
+	}
+}
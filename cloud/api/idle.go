@@ -0,0 +1,340 @@
+// Package api provides idle detection and auto-shutdown of cloud instances
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+const (
+	// idleCheckInterval is how often idle policies are enforced
+	idleCheckInterval = time.Minute
+	// defaultWarnMinutes is how long before a stop the owner is warned
+	defaultWarnMinutes = 10
+)
+
+// weekdayNames are the day names idle policies schedule by
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// newAgentToken returns a token for the cm agent on an instance to report
+// activity with, and its hash to store
+func newAgentToken() (token, hash string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashAgentToken(token)
+}
+
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// instanceHeartbeat records activity reported by the cm agent on an
+// instance, which authenticates with the instance's agent token
+func (s *Server) instanceHeartbeat(c echo.Context) error {
+	instance, err := s.agentInstance(c)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Active      bool    `json:"active"`       // Someone or something used the instance since the last heartbeat
+		SSHSessions int     `json:"ssh_sessions"` // Open SSH connections
+		Load        float64 `json:"load"`         // One-minute load average per CPU
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+
+	now := time.Now().UTC()
+	instance.LastHeartbeatAt = &now
+	if req.Active {
+		instance.LastActivityAt = &now
+	}
+	if err := s.db.UpdateInstance(instance); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record heartbeat")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// idlePolicyRequest is the editable part of an idle policy
+type idlePolicyRequest struct {
+	IdleMinutes int    `json:"idle_minutes"`
+	WarnMinutes *int   `json:"warn_minutes"`
+	ShutdownAt  string `json:"shutdown_at"`
+	Timezone    string `json:"timezone"`
+	Weekdays    string `json:"weekdays"`
+}
+
+// apply validates the request and copies it into a policy
+func (r *idlePolicyRequest) apply(policy *db.IdlePolicy) error {
+	if r.IdleMinutes < 0 {
+		return fmt.Errorf("idle_minutes must not be negative")
+	}
+	warn := defaultWarnMinutes
+	if r.WarnMinutes != nil {
+		warn = *r.WarnMinutes
+	}
+	if warn < 0 {
+		return fmt.Errorf("warn_minutes must not be negative")
+	}
+	if r.ShutdownAt != "" {
+		if _, err := time.Parse("15:04", r.ShutdownAt); err != nil {
+			return fmt.Errorf("shutdown_at must be a time like 19:30")
+		}
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	var days []string
+	for _, day := range strings.Split(strings.ToLower(r.Weekdays), ",") {
+		if day = strings.TrimSpace(day); day == "" {
+			continue
+		}
+		if _, ok := weekdayNames[day]; !ok {
+			return fmt.Errorf("unknown weekday %q; use mon, tue, wed, thu, fri, sat or sun", day)
+		}
+		days = append(days, day)
+	}
+
+	policy.IdleMinutes = r.IdleMinutes
+	policy.WarnMinutes = warn
+	policy.ShutdownAt = r.ShutdownAt
+	policy.Timezone = r.Timezone
+	policy.Weekdays = strings.Join(days, ",")
+	return nil
+}
+
+// getIdlePolicy returns the signed-in user's policy, or an empty one
+func (s *Server) getIdlePolicy(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	policy, err := s.db.GetUserIdlePolicy(userID)
+	if err != nil {
+		policy = &db.IdlePolicy{UserID: &userID, WarnMinutes: defaultWarnMinutes}
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// updateIdlePolicy sets the policy for the signed-in user's instances
+func (s *Server) updateIdlePolicy(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	policy, err := s.db.GetUserIdlePolicy(userID)
+	if err != nil {
+		policy = &db.IdlePolicy{UserID: &userID}
+	}
+	return s.saveIdlePolicy(c, policy, userID)
+}
+
+// getTeamIdlePolicy returns a team's policy to its members
+func (s *Server) getTeamIdlePolicy(c echo.Context) error {
+	teamID := c.Param("id")
+	policy, err := s.db.GetTeamIdlePolicy(teamID)
+	if err != nil {
+		policy = &db.IdlePolicy{TeamID: &teamID, WarnMinutes: defaultWarnMinutes}
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// updateTeamIdlePolicy sets a team's policy; only its owner and admins may
+func (s *Server) updateTeamIdlePolicy(c echo.Context) error {
+	teamID := c.Param("id")
+	userID := c.Get("user_id").(string)
+	policy, err := s.db.GetTeamIdlePolicy(teamID)
+	if err != nil {
+		policy = &db.IdlePolicy{TeamID: &teamID}
+	}
+	return s.saveIdlePolicy(c, policy, userID)
+}
+
+func (s *Server) saveIdlePolicy(c echo.Context, policy *db.IdlePolicy, userID string) error {
+	var req idlePolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if err := req.apply(policy); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	policy.UpdatedAt = time.Now().UTC()
+	policy.UpdatedBy = userID
+	if err := s.db.SaveIdlePolicy(policy); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save idle policy")
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// idleStop is when and why a policy stops an instance
+type idleStop struct {
+	at     time.Time
+	reason string // "idle" or "schedule"
+}
+
+// nextIdleStop returns the earliest stop a policy schedules for a running
+// instance, which may be in the past. Idle stops only apply to instances
+// whose agent has reported in, since others can't report activity.
+func nextIdleStop(policy *db.IdlePolicy, inst *db.Instance, now time.Time) (idleStop, bool) {
+	var stop idleStop
+	found := false
+	consider := func(at time.Time, reason string) {
+		if !found || at.Before(stop.at) {
+			stop, found = idleStop{at: at, reason: reason}, true
+		}
+	}
+
+	started := inst.CreatedAt
+	if inst.StartedAt != nil {
+		started = *inst.StartedAt
+	}
+
+	if policy.IdleMinutes > 0 && inst.LastHeartbeatAt != nil {
+		lastActive := started
+		if inst.LastActivityAt != nil && inst.LastActivityAt.After(lastActive) {
+			lastActive = *inst.LastActivityAt
+		}
+		consider(lastActive.Add(time.Duration(policy.IdleMinutes)*time.Minute), "idle")
+	}
+
+	if policy.ShutdownAt != "" {
+		loc, err := time.LoadLocation(policy.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		clock, _ := time.Parse("15:04", policy.ShutdownAt)
+		days := map[time.Weekday]bool{}
+		for _, day := range strings.Split(policy.Weekdays, ",") {
+			if wd, ok := weekdayNames[day]; ok {
+				days[wd] = true
+			}
+		}
+
+		// The latest shutdown that already passed still applies to an
+		// instance running since before it, unless the policy is newer; the
+		// next one is the upcoming stop otherwise
+		local := now.In(loc)
+		for offset := -7; offset <= 7; offset++ {
+			day := local.AddDate(0, 0, offset)
+			at := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			if len(days) > 0 && !days[at.Weekday()] {
+				continue
+			}
+			if !at.After(now) {
+				if at.After(started) && at.After(policy.UpdatedAt) {
+					consider(at, "schedule")
+				}
+				continue
+			}
+			consider(at, "schedule")
+			break
+		}
+	}
+	return stop, found
+}
+
+// idleEnforcer remembers the stops owners were warned about, so each
+// warning is pushed once
+type idleEnforcer struct {
+	mu     sync.Mutex
+	warned map[string]time.Time // Stop time by instance ID
+}
+
+// enforceIdlePolicies stops idle and off-hours instances until ctx ends
+func (s *Server) enforceIdlePolicies(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkIdlePolicies(ctx, now)
+		}
+	}
+}
+
+// checkIdlePolicies warns about and performs the stops policies schedule
+func (s *Server) checkIdlePolicies(ctx context.Context, now time.Time) {
+	instances, err := s.db.ListInstancesByStatus("running")
+	if err != nil {
+		s.log.Error("idle policies: failed to list instances", "error", err)
+		return
+	}
+
+	s.idle.mu.Lock()
+	defer s.idle.mu.Unlock()
+	running := map[string]bool{}
+	for i := range instances {
+		inst := &instances[i]
+		running[inst.ID] = true
+
+		policy := s.idlePolicyFor(inst)
+		if policy == nil {
+			continue
+		}
+		stop, ok := nextIdleStop(policy, inst, now)
+		if !ok {
+			continue
+		}
+
+		if !now.Before(stop.at) {
+			reason := fmt.Sprintf("stopped after %d idle minutes", policy.IdleMinutes)
+			if stop.reason == "schedule" {
+				reason = "stopped for off-hours at " + policy.ShutdownAt
+			}
+			s.log.Info("idle policy stopping instance", "instance_id", inst.ID, "reason", reason)
+			if err := s.setInstanceRunning(ctx, inst, false, reason); err != nil {
+				s.log.Error("idle policy failed to stop instance", "instance_id", inst.ID, "error", err)
+			}
+			delete(s.idle.warned, inst.ID)
+			continue
+		}
+
+		warnAt := stop.at.Add(-time.Duration(policy.WarnMinutes) * time.Minute)
+		if !now.Before(warnAt) && !s.idle.warned[inst.ID].Equal(stop.at) {
+			s.idle.warned[inst.ID] = stop.at
+			s.wsHub.SendToUser(inst.OwnerID, WSMessage{
+				Type: "instance_stop_warning",
+				Payload: map[string]interface{}{
+					"instance_id": inst.ID,
+					"name":        inst.Name,
+					"reason":      stop.reason,
+					"stop_at":     stop.at.UTC(),
+				},
+			})
+		}
+	}
+
+	for id := range s.idle.warned {
+		if !running[id] {
+			delete(s.idle.warned, id)
+		}
+	}
+}
+
+// idlePolicyFor returns the policy of an instance's team, or else of its
+// owner, or nil
+func (s *Server) idlePolicyFor(inst *db.Instance) *db.IdlePolicy {
+	if inst.TeamID != nil {
+		if policy, err := s.db.GetTeamIdlePolicy(*inst.TeamID); err == nil {
+			return policy
+		}
+	}
+	if policy, err := s.db.GetUserIdlePolicy(inst.OwnerID); err == nil {
+		return policy
+	}
+	return nil
+}
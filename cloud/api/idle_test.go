@@ -0,0 +1,90 @@
+package api
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // Timezone cases run without system zoneinfo
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+func TestNextIdleStop(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) // A Wednesday
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, time.UTC) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	started := at(14, 9, 0)
+
+	tests := []struct {
+		name       string
+		policy     db.IdlePolicy
+		heartbeat  *time.Time
+		activity   *time.Time
+		want       time.Time // Zero for no stop
+		wantReason string
+	}{
+		{"no policy", db.IdlePolicy{}, ptr(now), nil, time.Time{}, ""},
+		{"idle without an agent", db.IdlePolicy{IdleMinutes: 30}, nil, ptr(at(14, 10, 0)), time.Time{}, ""},
+		{"idle since start", db.IdlePolicy{IdleMinutes: 30}, ptr(now), nil, at(14, 9, 30), "idle"},
+		{"idle since last activity", db.IdlePolicy{IdleMinutes: 30}, ptr(now), ptr(at(14, 11, 50)), at(14, 12, 20), "idle"},
+		{"activity before the start", db.IdlePolicy{IdleMinutes: 30}, ptr(now), ptr(at(13, 23, 0)), at(14, 9, 30), "idle"},
+		{"shutdown later today", db.IdlePolicy{ShutdownAt: "19:00"}, nil, nil, at(14, 19, 0), "schedule"},
+		{"idle before the shutdown", db.IdlePolicy{IdleMinutes: 30, ShutdownAt: "19:00"}, ptr(now), ptr(at(14, 11, 50)), at(14, 12, 20), "idle"},
+		{"shutdown passed while running", db.IdlePolicy{ShutdownAt: "11:00"}, nil, nil, at(14, 11, 0), "schedule"},
+		{"shutdown passed before the start", db.IdlePolicy{ShutdownAt: "08:00"}, nil, nil, at(15, 8, 0), "schedule"},
+		{"shutdown passed before the policy", db.IdlePolicy{ShutdownAt: "11:00", UpdatedAt: at(14, 11, 30)}, nil, nil, at(15, 11, 0), "schedule"},
+		{"weekdays only", db.IdlePolicy{ShutdownAt: "19:00", Weekdays: "mon,fri"}, nil, nil, at(16, 19, 0), "schedule"},
+		{"weekend after a weekday start", db.IdlePolicy{ShutdownAt: "08:00", Weekdays: "sat,sun"}, nil, nil, at(17, 8, 0), "schedule"},
+		{"timezone", db.IdlePolicy{ShutdownAt: "19:00", Timezone: "America/New_York"}, nil, nil, at(14, 23, 0), "schedule"},
+		{"timezone past midnight UTC", db.IdlePolicy{ShutdownAt: "09:00", Timezone: "Asia/Tokyo"}, nil, nil, at(15, 0, 0), "schedule"},
+		{"unknown timezone is UTC", db.IdlePolicy{ShutdownAt: "19:00", Timezone: "Mars/Olympus"}, nil, nil, at(14, 19, 0), "schedule"},
+	}
+	for _, tt := range tests {
+		policy := tt.policy
+		if policy.UpdatedAt.IsZero() {
+			policy.UpdatedAt = now.AddDate(0, -1, 0)
+		}
+		inst := &db.Instance{CreatedAt: at(1, 0, 0), StartedAt: ptr(started), LastHeartbeatAt: tt.heartbeat, LastActivityAt: tt.activity}
+		stop, ok := nextIdleStop(&policy, inst, now)
+		if ok != !tt.want.IsZero() {
+			t.Errorf("%s: stop found = %v, want %v", tt.name, ok, !tt.want.IsZero())
+			continue
+		}
+		if ok && (!stop.at.Equal(tt.want) || stop.reason != tt.wantReason) {
+			t.Errorf("%s: stop = %s (%s), want %s (%s)", tt.name, stop.at.UTC(), stop.reason, tt.want, tt.wantReason)
+		}
+	}
+}
+
+func TestIdlePolicyRequest(t *testing.T) {
+	warn := func(n int) *int { return &n }
+	tests := []struct {
+		req      idlePolicyRequest
+		wantErr  bool
+		weekdays string
+	}{
+		{idlePolicyRequest{IdleMinutes: 30}, false, ""},
+		{idlePolicyRequest{ShutdownAt: "19:30", Timezone: "Europe/Berlin", Weekdays: "Mon, tue,,FRI"}, false, "mon,tue,fri"},
+		{idlePolicyRequest{IdleMinutes: -1}, true, ""},
+		{idlePolicyRequest{WarnMinutes: warn(-5)}, true, ""},
+		{idlePolicyRequest{ShutdownAt: "7pm"}, true, ""},
+		{idlePolicyRequest{ShutdownAt: "25:00"}, true, ""},
+		{idlePolicyRequest{Timezone: "Nowhere/City"}, true, ""},
+		{idlePolicyRequest{Weekdays: "mon,someday"}, true, ""},
+	}
+	for _, tt := range tests {
+		var policy db.IdlePolicy
+		err := tt.req.apply(&policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("apply(%+v) = %v, wantErr %v", tt.req, err, tt.wantErr)
+			continue
+		}
+		if err == nil && policy.Weekdays != tt.weekdays {
+			t.Errorf("apply(%+v) weekdays = %q, want %q", tt.req, policy.Weekdays, tt.weekdays)
+		}
+	}
+
+	var policy db.IdlePolicy
+	if err := (&idlePolicyRequest{}).apply(&policy); err != nil || policy.WarnMinutes != defaultWarnMinutes {
+		t.Errorf("default warning = %d, %v, want %d", policy.WarnMinutes, err, defaultWarnMinutes)
+	}
+}
@@ -0,0 +1,144 @@
+// Package api provides structured request logging for the Cloud Control Plane
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+// newLogger returns the server's logger, writing one JSON object per line
+func newLogger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+}
+
+// pluginLogger returns the logger of provider plugins and their processes,
+// whose lines go to the server's log at their own levels
+func (s *Server) pluginLogger() hclog.Logger {
+	logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{Name: "provider-plugins", Output: io.Discard})
+	logger.RegisterSink(hclogSink{log: s.log})
+	return logger
+}
+
+// hclogSink writes hclog lines to a slog logger
+type hclogSink struct {
+	log *slog.Logger
+}
+
+func (h hclogSink) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
+	lvl := slog.LevelInfo
+	switch level {
+	case hclog.Trace, hclog.Debug:
+		lvl = slog.LevelDebug
+	case hclog.Warn:
+		lvl = slog.LevelWarn
+	case hclog.Error:
+		lvl = slog.LevelError
+	}
+	h.log.Log(context.Background(), lvl, msg, append([]interface{}{"logger", name}, args...)...)
+}
+
+// requestID returns the ID the RequestID middleware gave a request
+func requestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
+
+// propagateRequestID puts the request ID into the request's context, where
+// provider calls pick it up
+func propagateRequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		c.SetRequest(req.WithContext(providers.WithRequestID(req.Context(), requestID(c))))
+		return next(c)
+	}
+}
+
+// detachedContext returns a context for work outliving the request, such as
+// asynchronous provisioning or WebSocket sessions, keeping its request ID
+func detachedContext(c echo.Context) context.Context {
+	return providers.WithRequestID(context.Background(), requestID(c))
+}
+
+// requestLogger logs every request as one structured line
+func (s *Server) requestLogger() echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogMethod:    true,
+		LogURI:       true,
+		LogRoutePath: true,
+		LogStatus:    true,
+		LogLatency:   true,
+		LogRequestID: true,
+		LogRemoteIP:  true,
+		LogError:     true,
+		HandleError:  true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			attrs := []slog.Attr{
+				slog.String("request_id", v.RequestID),
+				slog.String("method", v.Method),
+				slog.String("uri", redactURI(v.URI)),
+				slog.String("route", v.RoutePath),
+				slog.Int("status", v.Status),
+				slog.Float64("latency_ms", float64(v.Latency.Microseconds())/1000),
+				slog.String("remote_ip", v.RemoteIP),
+			}
+			if userID, ok := c.Get("user_id").(string); ok {
+				attrs = append(attrs, slog.String("user_id", userID))
+			}
+			level := slog.LevelInfo
+			if v.Error != nil {
+				attrs = append(attrs, slog.String("error", v.Error.Error()))
+			}
+			if v.Status >= 500 {
+				level = slog.LevelError
+			}
+			s.log.LogAttrs(c.Request().Context(), level, "request", attrs...)
+			return nil
+		},
+	})
+}
+
+// redactURI hides the token query parameter WebSockets authenticate with
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || !u.Query().Has("token") {
+		return uri
+	}
+	q := u.Query()
+	q.Set("token", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// callProvider runs a provider call, timing it and logging it with the
+// request ID in ctx
+func (s *Server) callProvider(ctx context.Context, provider providers.Provider, operation string, call func(context.Context) error) error {
+	start := time.Now()
+	err := call(ctx)
+	took := time.Since(start)
+
+	result := "ok"
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("request_id", providers.RequestID(ctx)),
+		slog.String("provider", string(provider.Name())),
+		slog.String("operation", operation),
+		slog.Float64("duration_ms", float64(took.Microseconds())/1000),
+	}
+	if err != nil {
+		result = "error"
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.metrics.providerCalls.observe(took.Seconds(), string(provider.Name()), operation, result)
+	s.log.LogAttrs(ctx, level, "provider call", attrs...)
+	return err
+}
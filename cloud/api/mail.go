@@ -0,0 +1,48 @@
+// Package api provides email delivery for notifications
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// sendMail sends a plain-text email through the configured SMTP server
+func (s *Server) sendMail(to, subject, body string) error {
+	if s.config.SMTPAddr == "" {
+		return fmt.Errorf("SMTP is not configured")
+	}
+	host, _, err := net.SplitHostPort(s.config.SMTPAddr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", s.config.SMTPAddr, err)
+	}
+	from := s.config.SMTPFrom
+	if from == "" {
+		from = s.config.SMTPUsername
+	}
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", to, err)
+	}
+
+	var auth smtp.Auth
+	if s.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.config.SMTPUsername, s.config.SMTPPassword, host)
+	}
+
+	// Header values must not contain line breaks, which would start new headers
+	header := strings.NewReplacer("\r", "", "\n", " ")
+	msg := "From: " + header.Replace(from) + "\r\n" +
+		"To: " + header.Replace(to) + "\r\n" +
+		"Subject: " + header.Replace(subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"
+	return smtp.SendMail(s.config.SMTPAddr, auth, fromAddr.Address, []string{toAddr.Address}, []byte(msg))
+}
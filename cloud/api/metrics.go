@@ -0,0 +1,225 @@
+// Package api exposes Prometheus metrics for the Cloud Control Plane
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultBuckets are the latency buckets in seconds, as Prometheus client
+// libraries use by default
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics holds the server's metrics, exposed in the Prometheus text format
+// on /metrics
+type Metrics struct {
+	requests      *histogramVec
+	dbQueries     *histogramVec
+	providerCalls *histogramVec
+	wsSessions    *gaugeVec
+}
+
+// NewMetrics creates the server's metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: newHistogramVec("cm_http_request_duration_seconds",
+			"Latency of HTTP requests by method, route and status.", "method", "route", "status"),
+		dbQueries: newHistogramVec("cm_db_query_duration_seconds",
+			"Latency of database queries by operation and table.", "operation", "table"),
+		providerCalls: newHistogramVec("cm_provider_call_duration_seconds",
+			"Latency of cloud provider calls by provider, operation and result.", "provider", "operation", "result"),
+		wsSessions: newGaugeVec("cm_websocket_sessions",
+			"Open WebSocket sessions by kind (events, terminal, logs).", "kind"),
+	}
+}
+
+// middleware times every request by its route, not its path, so IDs in
+// paths don't multiply the series
+func (m *Metrics) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := c.Response().Status
+		if err != nil {
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			} else if !c.Response().Committed {
+				status = http.StatusInternalServerError
+			}
+		}
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.observe(time.Since(start).Seconds(), c.Request().Method, route, strconv.Itoa(status))
+		return err
+	}
+}
+
+// observeQuery records a database query; it is registered with the database
+func (m *Metrics) observeQuery(operation, table string, took time.Duration, _ error) {
+	if table == "" {
+		table = "unknown"
+	}
+	m.dbQueries.observe(took.Seconds(), operation, table)
+}
+
+// metricsHandler serves the metrics; instance counts are read from the database
+// on every scrape
+func (s *Server) metricsHandler(c echo.Context) error {
+	if token := s.config.MetricsToken; token != "" && c.Request().Header.Get("Authorization") != "Bearer "+token {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid metrics token")
+	}
+
+	var sb strings.Builder
+	s.metrics.requests.write(&sb)
+	s.metrics.dbQueries.write(&sb)
+	s.metrics.providerCalls.write(&sb)
+	s.metrics.wsSessions.write(&sb)
+
+	instances := newGaugeVec("cm_instances", "Instances by provider and status.", "provider", "status")
+	if counts, err := s.db.CountInstances(); err == nil {
+		for _, ic := range counts {
+			instances.set(float64(ic.Count), ic.Provider, ic.Status)
+		}
+	}
+	instances.write(&sb)
+
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+}
+
+// series is one label combination of a metric
+type series struct {
+	labels []string
+	value  float64  // Gauges
+	counts []uint64 // Histograms, per bucket
+	sum    float64  // Histograms
+	count  uint64   // Histograms
+}
+
+// histogramVec is a histogram partitioned by labels
+type histogramVec struct {
+	name, help string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newHistogramVec(name, help string, labelNames ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labelNames: labelNames, buckets: defaultBuckets, series: map[string]*series{}}
+}
+
+func (h *histogramVec) observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labels, "\xff")
+	s, ok := h.series[key]
+	if !ok {
+		s = &series{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, s := range sortedSeries(h.series) {
+		labels := formatLabels(h.labelNames, s.labels)
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, labels, formatFloat(b), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, labels, s.count)
+	}
+}
+
+// gaugeVec is a gauge partitioned by labels
+type gaugeVec struct {
+	name, help string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newGaugeVec(name, help string, labelNames ...string) *gaugeVec {
+	return &gaugeVec{name: name, help: help, labelNames: labelNames, series: map[string]*series{}}
+}
+
+func (g *gaugeVec) get(labels []string) *series {
+	key := strings.Join(labels, "\xff")
+	s, ok := g.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		g.series[key] = s
+	}
+	return s
+}
+
+func (g *gaugeVec) add(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labels).value += v
+}
+
+func (g *gaugeVec) set(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labels).value = v
+}
+
+func (g *gaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, s := range sortedSeries(g.series) {
+		fmt.Fprintf(w, "%s{%s} %s\n", g.name, formatLabels(g.labelNames, s.labels), formatFloat(s.value))
+	}
+}
+
+func sortedSeries(m map[string]*series) []*series {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = m[k]
+	}
+	return out
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// TestUpgradeBaselineDatabase starts the server on a database created by
+// the last release before versioned migrations, with a key it stored
+// unhashed
+func TestUpgradeBaselineDatabase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "cloud.db")
+	schema, err := os.ReadFile("testdata/baseline_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const legacyKey = "cm_0123456789abcdef0123456789abcdef"
+	old, err := db.Open(db.Config{Driver: "sqlite", DSN: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		string(schema),
+		`INSERT INTO users (id, email, name, created_at, updated_at) VALUES ('user-1', 'old@example.com', 'Old', '2026-01-01', '2026-01-01')`,
+		`INSERT INTO team_members (id, team_id, user_id, role) VALUES ('m1', 'team-1', 'user-1', 'owner'), ('m2', 'team-1', 'user-1', 'owner')`,
+		`INSERT INTO api_keys (id, user_id, name, key_prefix, key_hash, scopes, created_at) VALUES ('key-1', 'user-1', 'ci', '` + legacyKey[:11] + `', '` + legacyKey + `', 'read,write', '2026-01-01')`,
+	} {
+		if err := old.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(Config{JWTSecret: "test", DatabaseURL: path})
+	if err != nil {
+		t.Fatalf("server didn't start on a baseline database: %v", err)
+	}
+	defer s.stop()
+
+	migrator, err := s.db.SchemaMigrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := migrator.Version(context.Background()); err != nil || version != migrator.Latest() {
+		t.Errorf("schema version = %d, %v, want %d", version, err, migrator.Latest())
+	}
+
+	// The key stored unhashed was hashed and still works
+	if userID, err := s.authorizeAPIKey(legacyKey, http.MethodGet, "/api/v1/instances"); err != nil || userID != "user-1" {
+		t.Errorf("legacy key = %q, %v, want user-1", userID, err)
+	}
+
+	// Columns and tables added since can be written
+	now := time.Now().UTC()
+	userID := "user-1"
+	inst := &db.Instance{ID: "inst-1", OwnerID: "user-1", Name: "dev", Status: "running", AgentTokenHash: "hash", LastHeartbeatAt: &now, CreatedAt: now, UpdatedAt: now}
+	if err := s.db.CreateInstance(inst); err != nil {
+		t.Errorf("CreateInstance: %v", err)
+	}
+	if members, err := s.db.ListTeamMembers("team-1"); err != nil || len(members) != 1 {
+		t.Errorf("team members = %d, %v, want the duplicate removed", len(members), err)
+	}
+	if err := s.db.Create(&db.Budget{ID: "budget-1", UserID: &userID, MonthlyLimit: 10}).Error; err != nil {
+		t.Errorf("create budget: %v", err)
+	}
+	if err := s.db.Create(&db.IdlePolicy{ID: "idle-1", UserID: &userID, IdleMinutes: 30}).Error; err != nil {
+		t.Errorf("create idle policy: %v", err)
+	}
+}
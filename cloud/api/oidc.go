@@ -0,0 +1,632 @@
+// Package api provides OpenID Connect single sign-on with just-in-time
+// user provisioning and group-to-team mapping
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+const (
+	// oidcStateTTL is how long a sign-in may take at the identity provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcDiscoveryTTL is how long the issuer's metadata and keys are
+	// cached; unknown key IDs refetch the keys sooner
+	oidcDiscoveryTTL = time.Hour
+	// oidcKeyRefetchInterval limits refetching keys for unknown key IDs
+	oidcKeyRefetchInterval = time.Minute
+	// oidcStateCookie binds a sign-in's state to the browser that started it
+	oidcStateCookie = "cm_oidc_state"
+)
+
+// oidcHTTPClient talks to the identity provider
+var oidcHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// oidcMetadata is the part of an issuer's discovery document used here
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLogin is a sign-in waiting for the identity provider to redirect back
+type oidcLogin struct {
+	nonce    string
+	verifier string // PKCE code verifier
+	expires  time.Time
+}
+
+// oidcClient caches the issuer's metadata and signing keys and holds
+// pending sign-ins in memory; a restart only means signing in again
+type oidcClient struct {
+	mu          sync.Mutex
+	metadata    *oidcMetadata
+	fetched     time.Time
+	keys        map[string]interface{} // By key ID
+	keysFetched time.Time
+	logins      map[string]*oidcLogin // By state
+}
+
+func newOIDCClient() *oidcClient {
+	return &oidcClient{logins: make(map[string]*oidcLogin)}
+}
+
+// groupTeam is a team an identity provider group grants a role in
+type groupTeam struct {
+	slug string
+	role string
+}
+
+// parseGroupTeams parses a group-to-team mapping such as
+// "cm-admins=platform:admin,engineering=platform" into each group's teams;
+// the role defaults to member and can't be owner
+func parseGroupTeams(spec string) (map[string][]groupTeam, error) {
+	mapping := make(map[string][]groupTeam)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, team, ok := strings.Cut(entry, "=")
+		slug, role, _ := strings.Cut(team, ":")
+		group, slug, role = strings.TrimSpace(group), strings.TrimSpace(slug), strings.TrimSpace(role)
+		if role == "" {
+			role = db.RoleMember
+		}
+		if !ok || group == "" || slug == "" {
+			return nil, fmt.Errorf("invalid group mapping %q; want group=team-slug[:role]", entry)
+		}
+		if role != db.RoleAdmin && role != db.RoleMember && role != db.RoleViewer {
+			return nil, fmt.Errorf("invalid role %q in group mapping %q; use admin, member or viewer", role, entry)
+		}
+		mapping[group] = append(mapping[group], groupTeam{slug: slug, role: role})
+	}
+	return mapping, nil
+}
+
+// oidcEnabled reports whether OIDC sign-in is configured
+func (s *Server) oidcEnabled() bool {
+	return s.config.OIDCIssuerURL != "" && s.config.OIDCClientID != ""
+}
+
+// oidcDiscover returns the issuer's metadata, fetching it if it's stale
+func (s *Server) oidcDiscover() (*oidcMetadata, error) {
+	o := s.oidc
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.metadata != nil && time.Since(o.fetched) < oidcDiscoveryTTL {
+		return o.metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(s.config.OIDCIssuerURL, "/")
+	var metadata oidcMetadata
+	if err := oidcGetJSON(issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC issuer %q doesn't match the configured %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC issuer metadata lacks an authorization, token or JWKS endpoint")
+	}
+	o.metadata, o.fetched = &metadata, time.Now()
+	o.keys = nil
+	return o.metadata, nil
+}
+
+// oidcKey returns the issuer's signing key with an ID, refetching the keys
+// when they're stale or don't include it
+func (s *Server) oidcKey(metadata *oidcMetadata, kid string) (interface{}, error) {
+	o := s.oidc
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if key, ok := o.keys[kid]; ok && time.Since(o.keysFetched) < oidcDiscoveryTTL {
+		return key, nil
+	}
+	if o.keys != nil && time.Since(o.keysFetched) < oidcKeyRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := oidcGetJSON(metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+	o.keys, o.keysFetched = make(map[string]interface{}), time.Now()
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			o.keys[jwk.Kid] = key
+		}
+	}
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// jsonWebKey is an RSA or EC public key from a JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func oidcGetJSON(u string, v interface{}) error {
+	resp, err := oidcHTTPClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// oidcRedirectURI is where the identity provider sends users back to
+func (s *Server) oidcRedirectURI(c echo.Context) string {
+	if s.config.OIDCRedirectURL != "" {
+		return s.config.OIDCRedirectURL
+	}
+	return s.getOAuthRedirectURI(c, "oidc")
+}
+
+// newOIDCStateCookie returns the cookie holding a sign-in's state until the
+// identity provider redirects back; a negative maxAge deletes it
+func newOIDCStateCookie(c echo.Context, state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   maxAge,
+		Secure:   c.Scheme() == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode, // Sent on the identity provider's top-level redirect
+	}
+}
+
+// oidcLoginRedirect sends the dashboard back to the login page with an error
+func oidcLoginRedirect(c echo.Context, message string) error {
+	return c.Redirect(http.StatusFound, "/login?error="+url.QueryEscape(message))
+}
+
+// listAuthProviders tells the login page which sign-in options are set up
+func (s *Server) listAuthProviders(c echo.Context) error {
+	name := s.config.OIDCName
+	if name == "" {
+		name = "SSO"
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"github":    s.config.GitHubClientID != "",
+		"google":    s.config.GoogleClientID != "",
+		"oidc":      s.oidcEnabled(),
+		"oidc_name": name,
+	})
+}
+
+// oidcAuthorize starts an OIDC sign-in with the authorization code flow
+// and PKCE
+func (s *Server) oidcAuthorize(c echo.Context) error {
+	if !s.oidcEnabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "single sign-on is not configured")
+	}
+	metadata, err := s.oidcDiscover()
+	if err != nil {
+		s.log.Error("OIDC discovery failed", "error", err)
+		return oidcLoginRedirect(c, "Single sign-on is unavailable")
+	}
+
+	state, nonce, verifier := randomToken(), randomToken(), randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+	now := time.Now()
+	s.oidc.mu.Lock()
+	for st, login := range s.oidc.logins {
+		if now.After(login.expires) {
+			delete(s.oidc.logins, st)
+		}
+	}
+	s.oidc.logins[state] = &oidcLogin{nonce: nonce, verifier: verifier, expires: now.Add(oidcStateTTL)}
+	s.oidc.mu.Unlock()
+
+	scopes := append([]string{"openid"}, s.config.OIDCScopes...)
+	if len(s.config.OIDCScopes) == 0 {
+		scopes = append(scopes, "email", "profile")
+	}
+	params := url.Values{
+		"client_id":             {s.config.OIDCClientID},
+		"redirect_uri":          {s.oidcRedirectURI(c)},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	c.SetCookie(newOIDCStateCookie(c, state, int(oidcStateTTL/time.Second)))
+	sep := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return c.Redirect(http.StatusFound, metadata.AuthorizationEndpoint+sep+params.Encode())
+}
+
+// oidcCallback completes an OIDC sign-in: it exchanges the code, verifies
+// the ID token, provisions or links the user and syncs their teams
+func (s *Server) oidcCallback(c echo.Context) error {
+	if !s.oidcEnabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "single sign-on is not configured")
+	}
+	if e := c.QueryParam("error"); e != "" {
+		msg := c.QueryParam("error_description")
+		if msg == "" {
+			msg = e
+		}
+		return oidcLoginRedirect(c, "Sign-in was refused: "+msg)
+	}
+
+	state := c.QueryParam("state")
+	s.oidc.mu.Lock()
+	login, ok := s.oidc.logins[state]
+	delete(s.oidc.logins, state)
+	s.oidc.mu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		return oidcLoginRedirect(c, "Sign-in expired; please try again")
+	}
+	// A state started in another browser means someone is trying to sign
+	// this one in to their account
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(newOIDCStateCookie(c, "", -1))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return oidcLoginRedirect(c, "Sign-in was started in another browser; please try again")
+	}
+
+	metadata, err := s.oidcDiscover()
+	if err != nil {
+		s.log.Error("OIDC discovery failed", "error", err)
+		return oidcLoginRedirect(c, "Single sign-on is unavailable")
+	}
+	rawIDToken, err := s.oidcExchange(metadata, c.QueryParam("code"), login.verifier, s.oidcRedirectURI(c))
+	if err != nil {
+		s.log.Error("OIDC code exchange failed", "error", err)
+		return oidcLoginRedirect(c, "Sign-in failed at the identity provider")
+	}
+	claims, err := s.verifyIDToken(metadata, rawIDToken, login.nonce)
+	if err != nil {
+		s.log.Warn("OIDC ID token rejected", "error", err)
+		return oidcLoginRedirect(c, "Sign-in failed: the identity token was invalid")
+	}
+
+	user, err := s.findOrCreateOIDCUser(claims)
+	if err != nil {
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			return oidcLoginRedirect(c, fmt.Sprint(he.Message))
+		}
+		s.log.Error("OIDC user provisioning failed", "error", err)
+		return oidcLoginRedirect(c, "Failed to create your account")
+	}
+	if err := s.syncOIDCTeams(user, claimStrings(claims[s.oidcGroupsClaim()])); err != nil {
+		s.log.Error("OIDC team sync failed", "user_id", user.ID, "error", err)
+	}
+
+	accessToken, refreshToken, err := s.generateTokenPair(user)
+	if err != nil {
+		return oidcLoginRedirect(c, "Failed to sign in")
+	}
+	frontendURL := fmt.Sprintf("/auth/callback?access_token=%s&refresh_token=%s", accessToken, refreshToken)
+	return c.Redirect(http.StatusFound, frontendURL)
+}
+
+// oidcExchange trades an authorization code for an ID token
+func (s *Server) oidcExchange(metadata *oidcMetadata, code, verifier, redirectURI string) (string, error) {
+	if code == "" {
+		return "", fmt.Errorf("missing code")
+	}
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.config.OIDCClientID), url.QueryEscape(s.config.OIDCClientSecret))
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tokenResp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if tokenResp.Error != "" {
+		return "", fmt.Errorf("%s: %s", tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned no ID token")
+	}
+	return tokenResp.IDToken, nil
+}
+
+// verifyIDToken checks an ID token's signature, issuer, audience, expiry
+// and nonce and returns its claims
+func (s *Server) verifyIDToken(metadata *oidcMetadata, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.oidcKey(metadata, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(s.config.OIDCClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("missing subject")
+	}
+	return claims, nil
+}
+
+func (s *Server) oidcGroupsClaim() string {
+	if s.config.OIDCGroupsClaim != "" {
+		return s.config.OIDCGroupsClaim
+	}
+	return "groups"
+}
+
+// claimStrings returns a claim that's a string or a list of strings
+func claimStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// findOrCreateOIDCUser returns the user an ID token is for: the one already
+// linked to its subject, an existing account with the same verified email,
+// or, unless provisioning is off, a new account
+func (s *Server) findOrCreateOIDCUser(claims jwt.MapClaims) (*db.User, error) {
+	sub, _ := claims["sub"].(string)
+	subject := strings.TrimSuffix(s.config.OIDCIssuerURL, "/") + "#" + sub
+	email, _ := claims["email"].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	emailVerified, _ := claims["email_verified"].(bool)
+	name, _ := claims["name"].(string)
+	picture, _ := claims["picture"].(string)
+
+	if len(s.config.OIDCAllowedDomains) > 0 {
+		// Anyone can claim an address at the IdP without verifying it
+		if !emailVerified {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "The identity provider hasn't verified your email address")
+		}
+		_, domain, _ := strings.Cut(email, "@")
+		allowed := false
+		for _, d := range s.config.OIDCAllowedDomains {
+			allowed = allowed || strings.EqualFold(strings.TrimSpace(d), domain)
+		}
+		if !allowed {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Your email domain may not sign in here")
+		}
+	}
+
+	user, err := s.db.GetUserByOIDCSubject(subject)
+	if err == nil {
+		if !user.IsActive {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Your account is disabled")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if email == "" {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "The identity provider didn't share your email address")
+	}
+
+	user, err = s.db.GetUserByEmail(email)
+	if err == nil {
+		// Linking by email alone would let anyone who can set an
+		// unverified email at the IdP take over the account
+		if !emailVerified {
+			return nil, echo.NewHTTPError(http.StatusConflict, "An account with your email already exists, and the identity provider hasn't verified the email")
+		}
+		if !user.IsActive {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "Your account is disabled")
+		}
+		user.OIDCSubject = subject
+		if user.AvatarURL == "" {
+			user.AvatarURL = picture
+		}
+		return user, s.db.UpdateUser(user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if s.config.OIDCDisableProvisioning {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "You don't have an account here; ask an administrator to create one")
+	}
+
+	if name == "" {
+		name, _ = claims["preferred_username"].(string)
+	}
+	now := time.Now().UTC()
+	user = &db.User{
+		ID:            uuid.New().String(),
+		Email:         email,
+		Name:          name,
+		AvatarURL:     picture,
+		OIDCSubject:   subject,
+		EmailVerified: emailVerified,
+		IsActive:      true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.db.CreateUser(user); err != nil {
+		return nil, err
+	}
+	s.log.Info("provisioned user from OIDC", "user_id", user.ID, "email", email)
+	return user, nil
+}
+
+// syncOIDCTeams makes a user's membership of each mapped team match their
+// groups: they get the highest role their groups grant, and lose
+// membership none of them grant. Owners are left alone, as are teams the
+// mapping doesn't name.
+func (s *Server) syncOIDCTeams(user *db.User, groups []string) error {
+	if s.config.OIDCGroupTeams == "" {
+		return nil
+	}
+	mapping, err := parseGroupTeams(s.config.OIDCGroupTeams)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]string) // Role by team slug
+	for _, teams := range mapping {
+		for _, t := range teams {
+			want[t.slug] = ""
+		}
+	}
+	for _, group := range groups {
+		for _, t := range mapping[group] {
+			if db.RoleRank(t.role) > db.RoleRank(want[t.slug]) {
+				want[t.slug] = t.role
+			}
+		}
+	}
+
+	var errs []error
+	for slug, role := range want {
+		team, err := s.db.GetTeamBySlug(slug)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("team %s: %w", slug, err))
+			continue
+		}
+		member, err := s.db.GetTeamMember(team.ID, user.ID)
+		switch {
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			errs = append(errs, err)
+		case err != nil && role != "":
+			errs = append(errs, s.db.AddTeamMember(&db.TeamMember{
+				ID: uuid.New().String(), TeamID: team.ID, UserID: user.ID, Role: role, JoinedAt: time.Now().UTC(),
+			}))
+		case err != nil || member.Role == db.RoleOwner || member.Role == role:
+		case role == "":
+			errs = append(errs, s.db.RemoveTeamMember(team.ID, user.ID))
+		default:
+			member.Role = role
+			errs = append(errs, s.db.UpdateTeamMember(member))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// randomToken returns 32 random bytes, URL-safe encoded
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
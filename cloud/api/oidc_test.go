@@ -0,0 +1,260 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// oidcFixture is a server signing in through a fake identity provider,
+// whose token endpoint returns an ID token with the fixture's claims
+type oidcFixture struct {
+	t      *testing.T
+	s      *Server
+	idp    *httptest.Server
+	claims jwt.MapClaims // "nonce" defaults to the sign-in's
+	nonce  string
+}
+
+func newOIDCFixture(t *testing.T, configure func(*Config)) *oidcFixture {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &oidcFixture{t: t}
+	f.idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 f.idp.URL,
+				"authorization_endpoint": f.idp.URL + "/authorize",
+				"token_endpoint":         f.idp.URL + "/token",
+				"jwks_uri":               f.idp.URL + "/jwks",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA", "kid": "k1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			claims := jwt.MapClaims{"nonce": f.nonce}
+			for k, v := range f.claims {
+				claims[k] = v
+			}
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = "k1"
+			signed, err := token.SignedString(key)
+			if err != nil {
+				t.Error(err)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.idp.Close)
+
+	cfg := Config{
+		JWTSecret:     "test",
+		DatabaseURL:   filepath.Join(t.TempDir(), "cloud.db"),
+		OIDCIssuerURL: f.idp.URL,
+		OIDCClientID:  "cm",
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	if f.s, err = NewServer(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// validClaims returns claims the server accepts for a subject
+func (f *oidcFixture) validClaims(sub, email string, verified bool) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": f.idp.URL, "aud": "cm", "sub": sub, "exp": time.Now().Add(time.Hour).Unix(),
+		"email": email, "email_verified": verified, "name": "Dev",
+	}
+}
+
+// authorize starts a sign-in and returns its state and state cookie
+func (f *oidcFixture) authorize() (string, *http.Cookie) {
+	f.t.Helper()
+	rec := httptest.NewRecorder()
+	f.s.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc", nil))
+	if rec.Code != http.StatusFound {
+		f.t.Fatalf("authorize = %d, want a redirect", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+	f.nonce = location.Query().Get("nonce")
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if state == "" || cookie == nil || cookie.Value != state || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		f.t.Fatalf("authorize set state %q and cookie %+v, want an HttpOnly SameSite=Lax cookie with the state", state, cookie)
+	}
+	return state, cookie
+}
+
+// callback completes a sign-in and returns where the browser is sent: the
+// dashboard's /auth/callback, or /login with an error
+func (f *oidcFixture) callback(state string, cookie *http.Cookie) string {
+	f.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	f.s.echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		f.t.Fatalf("callback = %d %s, want a redirect", rec.Code, rec.Body.String())
+	}
+	return rec.Header().Get("Location")
+}
+
+// signIn signs in with claims and returns where the browser is sent
+func (f *oidcFixture) signIn(claims jwt.MapClaims) string {
+	f.t.Helper()
+	f.claims = claims
+	state, cookie := f.authorize()
+	return f.callback(state, cookie)
+}
+
+func signedIn(location string) bool {
+	return strings.HasPrefix(location, "/auth/callback?access_token=")
+}
+
+func loginError(location string) string {
+	u, _ := url.Parse(location)
+	return u.Query().Get("error")
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	f := newOIDCFixture(t, nil)
+	if location := f.signIn(f.validClaims("alice", "alice@example.com", true)); !signedIn(location) {
+		t.Fatalf("sign-in sent to %s, want signed in", location)
+	}
+	user, err := f.s.db.GetUserByEmail("alice@example.com")
+	if err != nil || user.OIDCSubject != f.idp.URL+"#alice" {
+		t.Fatalf("provisioned user = %+v, %v, want one linked to the subject", user, err)
+	}
+}
+
+func TestOIDCTokenRejected(t *testing.T) {
+	f := newOIDCFixture(t, nil)
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "another-client" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	} {
+		claims := f.validClaims("alice", "alice@example.com", true)
+		mutate(claims)
+		if got := loginError(f.signIn(claims)); got != "Sign-in failed: the identity token was invalid" {
+			t.Errorf("%s: login error = %q, want the token rejected", name, got)
+		}
+	}
+	if _, err := f.s.db.GetUserByEmail("alice@example.com"); err == nil {
+		t.Error("a rejected token provisioned a user")
+	}
+}
+
+func TestOIDCState(t *testing.T) {
+	f := newOIDCFixture(t, nil)
+	f.claims = f.validClaims("alice", "alice@example.com", true)
+
+	// Each state signs in once
+	state, cookie := f.authorize()
+	if location := f.callback(state, cookie); !signedIn(location) {
+		t.Fatalf("sign-in sent to %s, want signed in", location)
+	}
+	if got := loginError(f.callback(state, cookie)); got != "Sign-in expired; please try again" {
+		t.Errorf("reused state: login error = %q, want expired", got)
+	}
+	if got := loginError(f.callback("made-up", &http.Cookie{Name: oidcStateCookie, Value: "made-up"})); got != "Sign-in expired; please try again" {
+		t.Errorf("unknown state: login error = %q, want expired", got)
+	}
+
+	// A state started in another browser: no cookie, or another sign-in's
+	state, _ = f.authorize()
+	if got := loginError(f.callback(state, nil)); !strings.Contains(got, "another browser") {
+		t.Errorf("state without its cookie: login error = %q, want it refused", got)
+	}
+	state, _ = f.authorize()
+	_, other := f.authorize()
+	if got := loginError(f.callback(state, other)); !strings.Contains(got, "another browser") {
+		t.Errorf("state with another sign-in's cookie: login error = %q, want it refused", got)
+	}
+}
+
+func TestOIDCAllowedDomains(t *testing.T) {
+	f := newOIDCFixture(t, func(cfg *Config) { cfg.OIDCAllowedDomains = []string{"example.com"} })
+	for _, tc := range []struct {
+		email    string
+		verified bool
+		want     string // Login error; "" signs in
+	}{
+		{"alice@example.com", true, ""},
+		{"bob@EXAMPLE.com", true, ""},
+		{"eve@other.com", true, "Your email domain may not sign in here"},
+		{"eve@example.com", false, "The identity provider hasn't verified your email address"},
+		{"", true, "Your email domain may not sign in here"},
+	} {
+		location := f.signIn(f.validClaims("sub-"+tc.email, tc.email, tc.verified))
+		switch {
+		case tc.want == "" && !signedIn(location):
+			t.Errorf("%q (verified %v) sent to %s, want signed in", tc.email, tc.verified, location)
+		case tc.want != "" && loginError(location) != tc.want:
+			t.Errorf("%q (verified %v): login error = %q, want %q", tc.email, tc.verified, loginError(location), tc.want)
+		}
+	}
+}
+
+func TestOIDCEmailLinking(t *testing.T) {
+	f := newOIDCFixture(t, nil)
+	existing := &db.User{ID: "user-alice", Email: "alice@example.com", Name: "Alice", IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := f.s.db.CreateUser(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unverified email can't take over the account
+	location := f.signIn(f.validClaims("attacker", "alice@example.com", false))
+	if signedIn(location) || !strings.Contains(loginError(location), "hasn't verified the email") {
+		t.Errorf("unverified email sent to %s, want it refused", location)
+	}
+	if user, _ := f.s.db.GetUserByID("user-alice"); user.OIDCSubject != "" {
+		t.Errorf("account linked to %q by an unverified email", user.OIDCSubject)
+	}
+
+	// A verified one links it
+	if location := f.signIn(f.validClaims("alice", "Alice@Example.com", true)); !signedIn(location) {
+		t.Fatalf("verified email sent to %s, want signed in", location)
+	}
+	if user, _ := f.s.db.GetUserByID("user-alice"); user.OIDCSubject != f.idp.URL+"#alice" {
+		t.Errorf("account subject = %q, want it linked", user.OIDCSubject)
+	}
+}
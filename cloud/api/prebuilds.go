@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+const (
+	// defaultBuilderType is the instance type prebuilds build on by default
+	defaultBuilderType = "cpu-large"
+
+	// prebuildTimeout is how long a build may take once its builder is up
+	prebuildTimeout = 3 * time.Hour
+)
+
+var (
+	// gitRefPattern and configPathPattern keep refs and paths that end up in
+	// the builder's script plain
+	gitRefPattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,254}$`)
+	configPathPattern = regexp.MustCompile(`^[A-Za-z0-9._][A-Za-z0-9._/-]{0,254}$`)
+
+	// imageRepositoryPattern matches an image repository without a tag: an
+	// optional registry host, then lowercase path components
+	imageRepositoryPattern = regexp.MustCompile(`^([A-Za-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+	// prebuildHashPattern is what 'cm prebuild hash' prints
+	prebuildHashPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	// repoNameInvalid matches what a repository's name can't have in an
+	// image name
+	repoNameInvalid = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// getTeamRegistry returns the registry a team's prebuilt images are pushed
+// to, without its password
+func (s *Server) getTeamRegistry(c echo.Context) error {
+	team, err := s.db.GetTeamByID(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"url":          team.RegistryURL,
+		"username":     team.RegistryUsername,
+		"has_password": team.RegistryPassword != "",
+	})
+}
+
+// updateTeamRegistry sets the registry a team's prebuilt images are pushed
+// to; an empty URL removes it
+func (s *Server) updateTeamRegistry(c echo.Context) error {
+	var req struct {
+		URL      string `json:"url"` // Repository prefix, e.g. ghcr.io/acme/devcontainers
+		Username string `json:"username"`
+		Password string `json:"password"` // Or a token with push access
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	team, err := s.db.GetTeamByID(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+
+	req.URL = strings.TrimSuffix(strings.TrimSpace(req.URL), "/")
+	team.RegistryURL, team.RegistryUsername, team.RegistryPassword = "", "", ""
+	if req.URL != "" {
+		if strings.Contains(req.URL, "://") || !imageRepositoryPattern.MatchString(req.URL) {
+			return echo.NewHTTPError(http.StatusBadRequest, "url must be a repository prefix like ghcr.io/acme/devcontainers, without a scheme")
+		}
+		if req.Username == "" || req.Password == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "username and password are required")
+		}
+		password, err := encryptCredentialData(map[string]string{"password": req.Password}, s.config.JWTSecret)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt password")
+		}
+		team.RegistryURL, team.RegistryUsername, team.RegistryPassword = req.URL, req.Username, password
+	}
+	team.UpdatedAt = time.Now().UTC()
+	if err := s.db.UpdateTeam(team); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save registry")
+	}
+	return s.getTeamRegistry(c)
+}
+
+// registryHost returns the registry an image repository is on, or "" for
+// Docker Hub
+func registryHost(repository string) string {
+	host, _, found := strings.Cut(repository, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return host
+	}
+	return ""
+}
+
+// requirePrebuild loads the prebuild named by :id into the context as
+// "prebuild" if the signed-in user has the access to it
+func (s *Server) requirePrebuild(need access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			prebuild, err := s.db.GetPrebuildByID(c.Param("id"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "prebuild not found")
+			}
+			if err := s.resourceAccess(c.Get("user_id").(string), prebuild.CreatedBy, &prebuild.TeamID, need); err != nil {
+				if err == errNotFound {
+					return echo.NewHTTPError(http.StatusNotFound, "prebuild not found")
+				}
+				return err
+			}
+			c.Set("prebuild", prebuild)
+			return next(c)
+		}
+	}
+}
+
+// listPrebuilds lists the prebuilds of the signed-in user's teams
+func (s *Server) listPrebuilds(c echo.Context) error {
+	teamIDs, err := s.db.TeamIDsByUser(c.Get("user_id").(string), db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	prebuilds, err := s.db.ListPrebuilds(teamIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list prebuilds")
+	}
+	return c.JSON(http.StatusOK, prebuilds)
+}
+
+func (s *Server) getPrebuild(c echo.Context) error {
+	return c.JSON(http.StatusOK, c.Get("prebuild"))
+}
+
+// lookupPrebuild returns the newest prebuilt image of ?hash= in the
+// signed-in user's teams, which the CLI pulls instead of building
+func (s *Server) lookupPrebuild(c echo.Context) error {
+	hash := c.QueryParam("hash")
+	if !prebuildHashPattern.MatchString(hash) {
+		return echo.NewHTTPError(http.StatusBadRequest, "hash must be a prebuild hash")
+	}
+	teamIDs, err := s.db.TeamIDsByUser(c.Get("user_id").(string), db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	prebuild, err := s.db.FindPrebuildImage(teamIDs, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "no prebuilt image")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to look up prebuilds")
+	}
+	return c.JSON(http.StatusOK, map[string]string{"id": prebuild.ID, "image": prebuild.Image})
+}
+
+// createPrebuild starts building a repository's devcontainer image on a
+// builder instance, to be pushed to the team's registry
+func (s *Server) createPrebuild(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	var req struct {
+		TeamID       string `json:"team_id"`
+		RepoURL      string `json:"repo_url"`
+		Ref          string `json:"ref"`
+		ConfigPath   string `json:"config_path"`
+		GitToken     string `json:"git_token"` // For private repositories
+		Provider     string `json:"provider"`
+		InstanceType string `json:"instance_type"`
+		Region       string `json:"region"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if req.TeamID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "team_id is required; images are pushed to the team's registry")
+	}
+	teamID, err := s.teamScope(c, &req.TeamID, db.RoleMember)
+	if err != nil {
+		return err
+	}
+	repo, err := url.Parse(req.RepoURL)
+	if err != nil || repo.Scheme != "https" || repo.Host == "" || strings.Trim(repo.Path, "/") == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "repo_url must be an https URL of a git repository")
+	}
+	if repo.User != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "repo_url must not contain credentials; use git_token")
+	}
+	if req.Ref != "" && !gitRefPattern.MatchString(req.Ref) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid ref")
+	}
+	if req.ConfigPath != "" && (!configPathPattern.MatchString(req.ConfigPath) || strings.Contains(req.ConfigPath, "..")) {
+		return echo.NewHTTPError(http.StatusBadRequest, "config_path must be a path within the repository")
+	}
+	if req.InstanceType == "" {
+		req.InstanceType = defaultBuilderType
+	}
+
+	team, err := s.db.GetTeamByID(*teamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+	if team.RegistryURL == "" {
+		return echo.NewHTTPError(http.StatusConflict, "the team has no registry; set one with 'cm cloud prebuild registry'")
+	}
+	provider, err := s.providers.Get(providers.ProviderType(req.Provider))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported provider: "+req.Provider)
+	}
+	if budget := s.exhaustedBudget(userID, teamID); budget != nil {
+		return budgetExhaustedError(budget)
+	}
+
+	now := time.Now().UTC()
+	prebuild := &db.Prebuild{
+		ID:           "pb-" + uuid.New().String()[:8],
+		TeamID:       *teamID,
+		CreatedBy:    userID,
+		RepoURL:      repo.String(),
+		Ref:          req.Ref,
+		ConfigPath:   req.ConfigPath,
+		Provider:     string(provider.Name()),
+		InstanceType: req.InstanceType,
+		Region:       req.Region,
+		Status:       "building",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if req.GitToken != "" {
+		token, err := encryptCredentialData(map[string]string{"token": req.GitToken}, s.config.JWTSecret)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt token")
+		}
+		prebuild.GitToken = token
+	}
+	if err := s.db.CreatePrebuild(prebuild); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create prebuild")
+	}
+	go s.runPrebuild(detachedContext(c), prebuild, team, provider, publicBaseURL(c))
+	return c.JSON(http.StatusAccepted, prebuild)
+}
+
+// deletePrebuild forgets a prebuild; its image stays in the registry
+func (s *Server) deletePrebuild(c echo.Context) error {
+	prebuild := c.Get("prebuild").(*db.Prebuild)
+	if prebuild.Status == "building" {
+		return echo.NewHTTPError(http.StatusConflict, "the prebuild is still building")
+	}
+	if err := s.db.DeletePrebuild(prebuild.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete prebuild")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// runPrebuild creates a builder instance in the prebuild's team, builds
+// and pushes the image on it, records the outcome and deletes the builder
+func (s *Server) runPrebuild(ctx context.Context, prebuild *db.Prebuild, team *db.Team, provider providers.Provider, cloudURL string) {
+	builder := &db.Instance{
+		ID:           "inst-" + uuid.New().String()[:8],
+		OwnerID:      prebuild.CreatedBy,
+		TeamID:       &prebuild.TeamID,
+		Name:         "prebuild-" + prebuild.ID,
+		Provider:     prebuild.Provider,
+		InstanceType: prebuild.InstanceType,
+		Region:       prebuild.Region,
+	}
+	prebuild.InstanceID = &builder.ID
+	prebuild.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdatePrebuild(prebuild)
+
+	image, err := func() (string, error) {
+		defer s.destroyInstance(builder)
+		if err := s.launchInstance(ctx, builder, provider, cloudURL); err != nil {
+			return "", fmt.Errorf("builder: %w", err)
+		}
+		script, repository, err := s.prebuildScript(prebuild, team)
+		if err != nil {
+			return "", err
+		}
+		out, err := s.instanceJob(ctx, builder.ID, "/var/lib/cm/prebuilds/"+prebuild.ID, script, prebuildTimeout)
+		if err != nil {
+			return "", err
+		}
+		hash := strings.TrimSpace(out)
+		if !prebuildHashPattern.MatchString(hash) {
+			return "", fmt.Errorf("unexpected prebuild hash %q", truncate(hash, 100))
+		}
+		prebuild.ConfigHash = hash
+		return repository + ":" + hash, nil
+	}()
+
+	finished := time.Now().UTC()
+	if err != nil {
+		s.log.Error("prebuild failed", "prebuild_id", prebuild.ID, "error", err)
+		prebuild.Status, prebuild.StatusReason = "failed", truncate(err.Error(), 1000)
+	} else {
+		prebuild.Status, prebuild.Image = "ready", image
+	}
+	prebuild.InstanceID = nil
+	prebuild.UpdatedAt, prebuild.FinishedAt = finished, &finished
+	_ = s.db.UpdatePrebuild(prebuild)
+}
+
+// prebuildScript returns the script that builds and pushes a prebuild's
+// image on its builder and prints its hash, and the image's repository
+func (s *Server) prebuildScript(prebuild *db.Prebuild, team *db.Team) (script, repository string, err error) {
+	creds, err := decryptCredentialData(team.RegistryPassword, s.config.JWTSecret)
+	if err != nil {
+		return "", "", errors.New("failed to decrypt the registry password")
+	}
+	cloneURL, _ := url.Parse(prebuild.RepoURL)
+	if prebuild.GitToken != "" {
+		token, err := decryptCredentialData(prebuild.GitToken, s.config.JWTSecret)
+		if err != nil {
+			return "", "", errors.New("failed to decrypt the git token")
+		}
+		cloneURL.User = url.UserPassword("oauth2", token["token"])
+	}
+	name := repoNameInvalid.ReplaceAllString(strings.ToLower(strings.TrimSuffix(path.Base(cloneURL.Path), ".git")), "-")
+	repository = team.RegistryURL + "/" + strings.Trim(name, "._-")
+
+	clone := "git clone --quiet --depth 1"
+	if prebuild.Ref != "" {
+		clone += " --branch " + shellQuote(prebuild.Ref)
+	}
+	config := ""
+	if prebuild.ConfigPath != "" {
+		config = " --config " + shellQuote(prebuild.ConfigPath)
+	}
+	login := "docker login --username " + shellQuote(team.RegistryUsername) + " --password-stdin"
+	if host := registryHost(repository); host != "" {
+		login += " " + shellQuote(host)
+	}
+	work := "/var/lib/cm/prebuild-work/" + prebuild.ID
+	script = "set -e\n" +
+		"command -v git >/dev/null || { apt-get update -qq && apt-get install -y -qq git; } >/dev/null 2>&1\n" +
+		"w=" + shellQuote(work) + "\nrm -rf \"$w\"; mkdir -p \"$w\"\ntrap 'rm -rf \"$w\"' EXIT\n" +
+		// The token is kept out of errors
+		clone + " " + shellQuote(cloneURL.String()) + " \"$w/src\" > \"$w/log\" 2>&1 || { sed 's|://[^@/]*@|://|' \"$w/log\" >&2; exit 1; }\n" +
+		"export DOCKER_CONFIG=\"$w/docker\"\n" +
+		"printf '%s' " + shellQuote(creds["password"]) + " | " + login + " >/dev/null\n" +
+		"cd \"$w/src\"\n" +
+		"cm prebuild" + config + " --push " + shellQuote(repository) + " > \"$w/log\" 2>&1 || { tail -c 2000 \"$w/log\" >&2; exit 1; }\n" +
+		"cm prebuild hash" + config + "\n"
+	return script, repository, nil
+}
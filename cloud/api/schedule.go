@@ -0,0 +1,250 @@
+// Package api provides scheduled creation and stopping of cloud instances
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+// scheduleCheckInterval is how often instance schedules are enforced
+const scheduleCheckInterval = time.Minute
+
+// scheduleRequest is the schedule an instance is created with
+type scheduleRequest struct {
+	CreateAt  *time.Time `json:"create_at"`  // Provisions it then rather than now
+	StopAt    *time.Time `json:"stop_at"`    // Stops it then
+	StopAfter string     `json:"stop_after"` // Stops it this long after it's created, e.g. "4h"
+}
+
+// resolve returns when to create and stop an instance; a nil create time
+// means now
+func (r scheduleRequest) resolve(now time.Time) (createAt, stopAt *time.Time, err error) {
+	from := now
+	if r.CreateAt != nil && r.CreateAt.After(now) {
+		at := r.CreateAt.UTC()
+		createAt, from = &at, at
+	}
+	stopAt, err = stopTime(r.StopAt, r.StopAfter, from)
+	return createAt, stopAt, err
+}
+
+// stopTime returns the stop time given as a time or as a duration after
+// from, or nil if neither is
+func stopTime(at *time.Time, after string, from time.Time) (*time.Time, error) {
+	if at != nil && after != "" {
+		return nil, errors.New("give either stop_at or stop_after")
+	}
+	if after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil || d <= 0 {
+			return nil, errors.New("stop_after must be a positive duration such as 4h or 90m")
+		}
+		stop := from.Add(d).UTC()
+		return &stop, nil
+	}
+	if at == nil {
+		return nil, nil
+	}
+	if !at.After(from) {
+		return nil, errors.New("stop_at must be after the instance is created")
+	}
+	stop := at.UTC()
+	return &stop, nil
+}
+
+// updateInstanceSchedule changes when an instance is created, while it's
+// scheduled, and when it stops
+func (s *Server) updateInstanceSchedule(c echo.Context) error {
+	instance := c.Get("instance").(*db.Instance)
+	var req struct {
+		CreateAt  *time.Time `json:"create_at"`  // Only while the instance is scheduled
+		StopAt    *time.Time `json:"stop_at"`    // Stops it then
+		StopAfter string     `json:"stop_after"` // Stops it this long after now, or after it's created
+		Postpone  string     `json:"postpone"`   // Moves the stop time this much later
+		NoStop    bool       `json:"no_stop"`    // Clears the stop time
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	options := 0
+	for _, set := range []bool{req.StopAt != nil, req.StopAfter != "", req.Postpone != "", req.NoStop} {
+		if set {
+			options++
+		}
+	}
+	if options > 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "give only one of stop_at, stop_after, postpone and no_stop")
+	}
+
+	now := time.Now().UTC()
+	from := now
+	if req.CreateAt != nil {
+		if instance.Status != "scheduled" {
+			return echo.NewHTTPError(http.StatusConflict, "the instance is already created")
+		}
+		at := req.CreateAt.UTC()
+		if at.Before(now) {
+			at = now
+		}
+		instance.CreateAt = &at
+	}
+	if instance.Status == "scheduled" && instance.CreateAt != nil && instance.CreateAt.After(from) {
+		from = *instance.CreateAt
+	}
+
+	switch {
+	case req.NoStop:
+		instance.StopAt = nil
+	case req.Postpone != "":
+		d, err := time.ParseDuration(req.Postpone)
+		if err != nil || d <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "postpone must be a positive duration such as 1h")
+		}
+		if instance.StopAt == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "the instance has no stop time to postpone")
+		}
+		stop := instance.StopAt.Add(d)
+		instance.StopAt = &stop
+	case req.StopAt != nil || req.StopAfter != "":
+		stop, err := stopTime(req.StopAt, req.StopAfter, from)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		instance.StopAt = stop
+	}
+	if instance.StopAt != nil && !instance.StopAt.After(from) {
+		return echo.NewHTTPError(http.StatusBadRequest, "the stop time must be after the instance is created")
+	}
+
+	instance.UpdatedAt = now
+	if err := s.db.UpdateInstance(instance); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update instance")
+	}
+	return c.JSON(http.StatusOK, instance)
+}
+
+// enforceSchedules creates and stops instances at their scheduled times
+// until ctx ends
+func (s *Server) enforceSchedules(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkSchedules(ctx, now.UTC())
+		}
+	}
+}
+
+// checkSchedules provisions the scheduled instances that are due, and
+// warns about and performs scheduled stops
+func (s *Server) checkSchedules(ctx context.Context, now time.Time) {
+	due, err := s.db.ListDueScheduledInstances(now)
+	if err != nil {
+		s.log.Error("schedules: failed to list scheduled instances", "error", err)
+	}
+	for i := range due {
+		inst := &due[i]
+		if budget := s.exhaustedBudget(inst.OwnerID, inst.TeamID); budget != nil {
+			if claimed, err := s.db.ClaimScheduledInstance(inst.ID); err == nil && claimed {
+				inst.Status = "error"
+				inst.StatusReason = "not created: the monthly budget is used up"
+				inst.PendingConfig = ""
+				inst.UpdatedAt = now
+				_ = s.db.UpdateInstance(inst)
+			}
+			continue
+		}
+		s.log.Info("schedule creating instance", "instance_id", inst.ID)
+		if err := s.provisionScheduledInstance(ctx, inst); err != nil {
+			s.log.Error("schedule failed to create instance", "instance_id", inst.ID, "error", err)
+		}
+	}
+
+	stopping, err := s.db.ListInstancesStoppingBy(now.Add(defaultWarnMinutes * time.Minute))
+	if err != nil {
+		s.log.Error("schedules: failed to list stopping instances", "error", err)
+		return
+	}
+
+	s.schedules.mu.Lock()
+	defer s.schedules.mu.Unlock()
+	pending := map[string]bool{}
+	for i := range stopping {
+		inst := &stopping[i]
+		stopAt := *inst.StopAt
+		if !now.Before(stopAt) {
+			s.log.Info("schedule stopping instance", "instance_id", inst.ID)
+			if err := s.setInstanceRunning(ctx, inst, false, "stopped at its scheduled stop time"); err != nil {
+				s.log.Error("schedule failed to stop instance", "instance_id", inst.ID, "error", err)
+			}
+			delete(s.schedules.warned, inst.ID)
+			continue
+		}
+
+		pending[inst.ID] = true
+		if !s.schedules.warned[inst.ID].Equal(stopAt) {
+			s.schedules.warned[inst.ID] = stopAt
+			s.wsHub.SendToUser(inst.OwnerID, WSMessage{
+				Type: "instance_stop_warning",
+				Payload: map[string]interface{}{
+					"instance_id": inst.ID,
+					"name":        inst.Name,
+					"reason":      "scheduled",
+					"stop_at":     stopAt.UTC(),
+				},
+			})
+		}
+	}
+
+	for id := range s.schedules.warned {
+		if !pending[id] {
+			delete(s.schedules.warned, id)
+		}
+	}
+}
+
+// provisionScheduledInstance claims a scheduled instance and provisions it
+// now, in the background
+func (s *Server) provisionScheduledInstance(ctx context.Context, inst *db.Instance) error {
+	provider, err := s.providers.Get(providers.ProviderType(inst.Provider))
+	if err != nil {
+		return err
+	}
+	var spec provisionSpec
+	if inst.PendingConfig != "" {
+		if err := json.Unmarshal([]byte(inst.PendingConfig), &spec); err != nil {
+			return err
+		}
+	}
+	claimed, err := s.db.ClaimScheduledInstance(inst.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("the instance is no longer scheduled")
+	}
+
+	// Only the hash of the token made at creation was kept, so make another
+	agentToken, agentTokenHash := newAgentToken()
+	now := time.Now().UTC()
+	inst.Status = "provisioning"
+	inst.AgentTokenHash = agentTokenHash
+	inst.CreateAt = &now
+	inst.UpdatedAt = now
+	if err := s.db.UpdateInstance(inst); err != nil {
+		return err
+	}
+	go s.provisionInstance(ctx, inst, provider, spec, agentToken)
+	return nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleResolve(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	at := func(hour, min int) *time.Time {
+		t := time.Date(2026, 10, 14, hour, min, 0, 0, time.UTC)
+		return &t
+	}
+	berlin := time.FixedZone("CEST", 2*60*60)
+	local := time.Date(2026, 10, 14, 18, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name       string
+		req        scheduleRequest
+		wantCreate *time.Time // nil for now
+		wantStop   *time.Time // nil for no stop
+		wantErr    bool
+	}{
+		{"nothing scheduled", scheduleRequest{}, nil, nil, false},
+		{"create later", scheduleRequest{CreateAt: at(14, 0)}, at(14, 0), nil, false},
+		{"create in the past is now", scheduleRequest{CreateAt: at(11, 0)}, nil, nil, false},
+		{"create now is now", scheduleRequest{CreateAt: at(12, 0)}, nil, nil, false},
+		{"stop at", scheduleRequest{StopAt: at(18, 0)}, nil, at(18, 0), false},
+		{"stop at in another zone", scheduleRequest{StopAt: &local}, nil, at(16, 0), false},
+		{"stop after", scheduleRequest{StopAfter: "4h"}, nil, at(16, 0), false},
+		{"stop after a later create", scheduleRequest{CreateAt: at(14, 0), StopAfter: "90m"}, at(14, 0), at(15, 30), false},
+		{"stop after a past create", scheduleRequest{CreateAt: at(9, 0), StopAfter: "1h"}, nil, at(13, 0), false},
+		{"stop at after a later create", scheduleRequest{CreateAt: at(14, 0), StopAt: at(18, 0)}, at(14, 0), at(18, 0), false},
+		{"stop at before a later create", scheduleRequest{CreateAt: at(14, 0), StopAt: at(13, 0)}, nil, nil, true},
+		{"stop at when created", scheduleRequest{CreateAt: at(14, 0), StopAt: at(14, 0)}, nil, nil, true},
+		{"stop at in the past", scheduleRequest{StopAt: at(11, 0)}, nil, nil, true},
+		{"both stop options", scheduleRequest{StopAt: at(18, 0), StopAfter: "4h"}, nil, nil, true},
+		{"stop after not a duration", scheduleRequest{StopAfter: "4 hours"}, nil, nil, true},
+		{"stop after zero", scheduleRequest{StopAfter: "0s"}, nil, nil, true},
+		{"stop after negative", scheduleRequest{StopAfter: "-1h"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		createAt, stopAt, err := tt.req.resolve(now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolve = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !sameTime(createAt, tt.wantCreate) || !sameTime(stopAt, tt.wantStop) {
+			t.Errorf("%s: resolve = create %v, stop %v, want create %v, stop %v", tt.name, createAt, stopAt, tt.wantCreate, tt.wantStop)
+		}
+		for _, got := range []*time.Time{createAt, stopAt} {
+			if got != nil && got.Location() != time.UTC {
+				t.Errorf("%s: resolve returned %v, want UTC", tt.name, got)
+			}
+		}
+	}
+}
+
+// sameTime reports whether two optional times are both unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
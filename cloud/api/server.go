@@ -3,11 +3,14 @@ package api
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -17,6 +20,7 @@ import (
 
 	"github.com/UPwith-me/Container-Maker/cloud/db"
 	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin"
 	"github.com/UPwith-me/Container-Maker/cloud/ui"
 	// Import UI package
 )
@@ -24,6 +28,7 @@ import (
 // Config holds API server configuration
 type Config struct {
 	Port            int
+	Addr            string // Listen address; ":<Port>" if empty
 	JWTSecret       string
 	StripeSecretKey string
 
@@ -33,9 +38,78 @@ type Config struct {
 	GoogleClientID     string
 	GoogleClientSecret string
 
+	// OpenID Connect single sign-on; enabled by an issuer and client ID
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string   // Callback URL registered at the IdP; derived from the request if empty
+	OIDCName         string   // Login button label; defaults to "SSO"
+	OIDCScopes       []string // Requested besides openid; defaults to email and profile
+	OIDCGroupsClaim  string   // ID token claim listing the user's groups; defaults to "groups"
+	OIDCGroupTeams   string   // Group-to-team mapping: "group=team-slug[:role],..."
+	// OIDCAllowedDomains limits sign-in to these email domains, if set
+	OIDCAllowedDomains []string
+	// OIDCDisableProvisioning only signs in users who already have an account
+	OIDCDisableProvisioning bool
+
 	// Database
 	DatabaseURL    string
 	DatabaseDriver string // sqlite or postgres
+
+	// DatabaseManualMigrate refuses to start on a schema with pending
+	// migrations instead of applying them; run cm-server migrate first
+	DatabaseManualMigrate bool
+
+	// Connection pool; zero keeps the driver's defaults
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+	DatabaseConnMaxIdleTime time.Duration
+
+	// Observability
+	MetricsToken string // Bearer token required by /metrics, if set
+
+	// Email notifications (budget alerts); disabled without SMTPAddr
+	SMTPAddr     string // host:port
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string // Defaults to SMTPUsername
+
+	// Restic repository for snapshots of volumes whose provider can't
+	// snapshot them, e.g. "s3:s3.amazonaws.com/bucket/cm"; each volume gets
+	// its own repository under it. Disabled if empty. The access key only
+	// assumes SnapshotRoleARN, for credentials limited to one volume's
+	// repository that are all an instance is given.
+	SnapshotRepository      string
+	SnapshotAccessKeyID     string
+	SnapshotSecretAccessKey string
+	SnapshotRoleARN         string
+	SnapshotRegion          string // Of STS; defaults to us-east-1
+	SnapshotSTSEndpoint     string // For S3-compatible stores; defaults to AWS STS
+
+	// GitHub App that creates dev environments for the pull requests of
+	// linked repositories; disabled without an app ID
+	GitHubAppID         string
+	GitHubAppPrivateKey string // PEM
+	GitHubWebhookSecret string // Verifies the app's webhook deliveries
+	GitHubAPIURL        string // For GitHub Enterprise; defaults to https://api.github.com
+
+	// ProviderPluginDir holds out-of-tree provider plugins, executables
+	// named cm-provider-*, which are started with the server
+	ProviderPluginDir string
+
+	// TLS; plain HTTP unless a certificate, domains or SelfSigned is set
+	TLSCertFile      string // PEM certificate to serve, with TLSKeyFile
+	TLSKeyFile       string
+	TLSDomains       []string // Get certificates for these from Let's Encrypt
+	TLSEmail         string   // Let's Encrypt account contact, optional
+	TLSSelfSigned    bool     // Serve a generated self-signed certificate
+	TLSDir           string   // Keeps Let's Encrypt and self-signed certificates
+	HTTPRedirectAddr string   // With TLSDomains, answers HTTP challenges and redirects to HTTPS here, e.g. ":80"
+
+	// DevMode lets unauthenticated dashboards and unknown API keys in as a
+	// demo user; never enable it in production
+	DevMode bool
 }
 
 // Server is the API server
@@ -45,27 +119,29 @@ type Server struct {
 	db        *db.Database
 	providers *providers.Manager
 	wsHub     *WSHub
+	metrics   *Metrics
+	log       *slog.Logger
+	devices   *deviceStore
+	oidc      *oidcClient
+	// webhookWake prompts delivery of newly queued webhook events
+	webhookWake chan struct{}
+	idle        *idleEnforcer
+	schedules   *idleEnforcer // Warnings of scheduled stops
+	agents      *agentHub
+	pool        *hostPool
+	github      *githubApp // Nil unless the GitHub App is configured
+	metering    sync.Mutex // Serializes usage metering, so runtime is recorded once
+	stop        context.CancelFunc
+	plugins     *plugin.Plugins
 
 	// Legacy in-memory stores (to be removed after full DB migration)
 	instances map[string]map[string]interface{}
 	apiKeys   map[string]map[string]interface{}
 }
 
-// NewServer creates a new API server
-func NewServer(cfg Config) (*Server, error) {
-	e := echo.New()
-	e.HideBanner = true
-
-	// Middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-API-Key"},
-	}))
-	e.Use(middleware.RequestID())
-
-	// Initialize database
+// DatabaseConfig returns the database configuration, the local SQLite
+// database unless a driver or URL is set
+func (cfg Config) DatabaseConfig() db.Config {
 	dbConfig := db.DefaultSQLiteConfig()
 	if cfg.DatabaseDriver != "" {
 		dbConfig.Driver = cfg.DatabaseDriver
@@ -73,8 +149,25 @@ func NewServer(cfg Config) (*Server, error) {
 	if cfg.DatabaseURL != "" {
 		dbConfig.DSN = cfg.DatabaseURL
 	}
+	dbConfig.ManualMigrate = cfg.DatabaseManualMigrate
+	dbConfig.MaxOpenConns = cfg.DatabaseMaxOpenConns
+	dbConfig.MaxIdleConns = cfg.DatabaseMaxIdleConns
+	dbConfig.ConnMaxLifetime = cfg.DatabaseConnMaxLifetime
+	dbConfig.ConnMaxIdleTime = cfg.DatabaseConnMaxIdleTime
+	return dbConfig
+}
+
+// NewServer creates a new API server
+func NewServer(cfg Config) (*Server, error) {
+	e := echo.New()
+	e.HideBanner = true
+
+	if _, err := parseGroupTeams(cfg.OIDCGroupTeams); err != nil {
+		return nil, fmt.Errorf("invalid OIDC_GROUP_TEAMS: %w", err)
+	}
 
-	database, err := db.New(dbConfig)
+	// Initialize database
+	database, err := db.New(cfg.DatabaseConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
@@ -87,19 +180,75 @@ func NewServer(cfg Config) (*Server, error) {
 	go wsHub.Run()
 
 	s := &Server{
-		echo:      e,
-		config:    cfg,
-		db:        database,
-		providers: providerManager,
-		wsHub:     wsHub,
-		instances: make(map[string]map[string]interface{}),
-		apiKeys:   make(map[string]map[string]interface{}),
+		echo:        e,
+		config:      cfg,
+		db:          database,
+		providers:   providerManager,
+		wsHub:       wsHub,
+		metrics:     NewMetrics(),
+		log:         newLogger(),
+		instances:   make(map[string]map[string]interface{}),
+		apiKeys:     make(map[string]map[string]interface{}),
+		devices:     newDeviceStore(),
+		oidc:        newOIDCClient(),
+		webhookWake: make(chan struct{}, 1),
+		idle:        &idleEnforcer{warned: make(map[string]time.Time)},
+		schedules:   &idleEnforcer{warned: make(map[string]time.Time)},
+		agents:      newAgentHub(),
+	}
+
+	// Middleware; the request ID comes first so every later one can log it
+	e.Use(middleware.RequestID())
+	e.Use(propagateRequestID)
+	e.Use(s.requestLogger())
+	e.Use(middleware.Recover())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-API-Key"},
+	}))
+	e.Use(s.metrics.middleware)
+
+	if err := database.ObserveQueries(s.metrics.observeQuery); err != nil {
+		return nil, fmt.Errorf("failed to observe database queries: %w", err)
+	}
+
+	if cfg.GitHubAppID != "" {
+		if s.github, err = newGitHubApp(cfg); err != nil {
+			return nil, fmt.Errorf("invalid GitHub App configuration: %w", err)
+		}
+	}
+
+	// Machines users and teams registered, served through their agents
+	s.pool = newHostPool(s)
+	providerManager.Register(s.pool)
+
+	// Out-of-tree providers, before saved credentials configure them
+	if cfg.ProviderPluginDir != "" {
+		var errs []error
+		s.plugins, errs = plugin.Load(cfg.ProviderPluginDir, providerManager, s.pluginLogger())
+		for _, err := range errs {
+			s.log.Error("failed to load provider plugin", "error", err)
+		}
 	}
 
 	// Load saved configuration from database
 	s.loadSavedConfig()
+	if err := s.hashLegacyAPIKeys(); err != nil {
+		return nil, fmt.Errorf("failed to hash API keys: %w", err)
+	}
+
+	// Builders of prebuilds interrupted by a restart aren't polled anymore
+	if _, err := database.FailInterruptedPrebuilds(time.Now().UTC()); err != nil {
+		s.log.Error("failed to fail interrupted prebuilds", "error", err)
+	}
 
 	s.setupRoutes()
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	go s.enforceIdlePolicies(ctx)
+	go s.enforceBudgets(ctx)
+	go s.deliverWebhooks(ctx)
+	go s.enforceSchedules(ctx)
 	return s, nil
 }
 
@@ -132,6 +281,9 @@ func (s *Server) setupRoutes() {
 	// Health check
 	s.echo.GET("/health", s.healthCheck)
 
+	// Prometheus metrics
+	s.echo.GET("/metrics", s.metricsHandler)
+
 	// Serve Frontend (Embedded)
 	distFS, err := ui.DistDir()
 	if err == nil {
@@ -183,6 +335,11 @@ func (s *Server) setupRoutes() {
 	v1.GET("/auth/github/callback", s.githubCallback)
 	v1.GET("/auth/google", s.googleOAuth)
 	v1.GET("/auth/google/callback", s.googleCallback)
+	v1.GET("/auth/oidc", s.oidcAuthorize)
+	v1.GET("/auth/oidc/callback", s.oidcCallback)
+	v1.GET("/auth/providers", s.listAuthProviders)
+	v1.POST("/auth/device/code", s.createDeviceCode)
+	v1.POST("/auth/device/token", s.pollDeviceToken)
 
 	// WebSocket endpoint (supports token via query param)
 	v1.GET("/ws", s.HandleWebSocket)
@@ -194,6 +351,7 @@ func (s *Server) setupRoutes() {
 	// User
 	protected.GET("/user", s.getCurrentUser)
 	protected.PUT("/user", s.updateUser)
+	protected.POST("/auth/device/approve", s.approveDevice)
 
 	// API Keys
 	protected.GET("/api-keys", s.listAPIKeys)
@@ -203,44 +361,115 @@ func (s *Server) setupRoutes() {
 	// Cloud Credentials
 	protected.GET("/credentials", s.listCredentials)
 	protected.POST("/credentials", s.addCredential)
-	protected.DELETE("/credentials/:id", s.deleteCredential)
-	protected.POST("/credentials/:id/verify", s.verifyCredential)
+	protected.DELETE("/credentials/:id", s.deleteCredential, s.requireCredential(accessManage))
+	protected.POST("/credentials/:id/verify", s.verifyCredential, s.requireCredential(accessUse))
 
 	// Instances
 	protected.GET("/instances", s.listInstances)
 	protected.POST("/instances", s.createInstance)
-	protected.GET("/instances/:id", s.getInstance)
-	protected.POST("/instances/:id/start", s.startInstance)
-	protected.POST("/instances/:id/stop", s.stopInstance)
-	protected.DELETE("/instances/:id", s.deleteInstance)
-	protected.GET("/instances/:id/logs", s.getInstanceLogs)
-	protected.GET("/instances/:id/ssh", s.getSSHConfig)
+	protected.GET("/instances/:id", s.getInstance, s.requireInstance(accessRead))
+	protected.POST("/instances/:id/start", s.startInstance, s.requireInstance(accessUse))
+	protected.POST("/instances/:id/stop", s.stopInstance, s.requireInstance(accessUse))
+	protected.DELETE("/instances/:id", s.deleteInstance, s.requireInstance(accessManage))
+	protected.POST("/instances/:id/transfer", s.transferInstance, s.requireInstance(accessManage))
+	protected.PUT("/instances/:id/schedule", s.updateInstanceSchedule, s.requireInstance(accessUse))
+	protected.GET("/instances/:id/logs", s.getInstanceLogs, s.requireInstance(accessRead))
+	protected.GET("/instances/:id/ssh", s.getSSHConfig, s.requireInstance(accessUse))
+	protected.POST("/instances/:id/exec", s.execInstance, s.requireInstance(accessUse))
+	protected.GET("/instances/:id/health", s.getInstanceHealth, s.requireInstance(accessRead))
+	protected.GET("/instances/:id/containers", s.listInstanceContainers, s.requireInstance(accessRead))
+	protected.POST("/instances/:id/containers/:container/:action", s.containerAction, s.requireInstance(accessUse))
 
 	// Terminal and log streaming WebSockets (uses query param auth)
 	v1.GET("/instances/:id/terminal", s.HandleTerminalWebSocket)
 	v1.GET("/instances/:id/logs/stream", s.HandleLogStreamWebSocket)
 
+	// Activity heartbeats and the control channel of the agent on an
+	// instance (use its agent token)
+	v1.POST("/instances/:id/heartbeat", s.instanceHeartbeat)
+	v1.GET("/instances/:id/agent", s.HandleAgentWebSocket)
+
+	// Volumes and snapshots
+	protected.GET("/volumes", s.listVolumes)
+	protected.POST("/volumes", s.createVolume)
+	protected.GET("/volumes/:id", s.getVolume, s.requireVolume(accessRead))
+	protected.DELETE("/volumes/:id", s.deleteVolume, s.requireVolume(accessManage))
+	protected.POST("/volumes/:id/attach", s.attachVolumeHandler, s.requireVolume(accessUse))
+	protected.POST("/volumes/:id/detach", s.detachVolumeHandler, s.requireVolume(accessUse))
+	protected.POST("/volumes/:id/snapshots", s.snapshotVolume, s.requireVolume(accessUse))
+	protected.POST("/volumes/:id/restore", s.restoreVolume, s.requireVolume(accessUse))
+	protected.GET("/snapshots", s.listSnapshots)
+	protected.DELETE("/snapshots/:id", s.deleteSnapshot)
+
+	// Hosts, and the control channel of their agents (uses the host's
+	// token)
+	protected.GET("/hosts", s.listHosts)
+	protected.POST("/hosts", s.createHost)
+	protected.GET("/hosts/:id", s.getHost, s.requireHost(accessRead))
+	protected.DELETE("/hosts/:id", s.deleteHost, s.requireHost(accessManage))
+	v1.GET("/hosts/:id/agent", s.HandleHostAgentWebSocket)
+
+	// Prebuilt devcontainer images
+	protected.GET("/prebuilds", s.listPrebuilds)
+	protected.POST("/prebuilds", s.createPrebuild)
+	protected.GET("/prebuilds/lookup", s.lookupPrebuild)
+	protected.GET("/prebuilds/:id", s.getPrebuild, s.requirePrebuild(accessRead))
+	protected.DELETE("/prebuilds/:id", s.deletePrebuild, s.requirePrebuild(accessManage))
+
+	// Pull request environments; GitHub signs its webhook deliveries
+	v1.POST("/github/webhook", s.githubWebhook)
+
+	// Idle policies
+	protected.GET("/idle-policy", s.getIdlePolicy)
+	protected.PUT("/idle-policy", s.updateIdlePolicy)
+
 	// Providers
 	protected.GET("/providers", s.listProviders)
 	protected.GET("/providers/:name/regions", s.listRegions)
 	protected.GET("/providers/:name/types", s.listInstanceTypes)
+	protected.GET("/providers/:name/capabilities", s.getCapabilities)
+	protected.POST("/estimates", s.createEstimate)
 
 	// Teams
 	protected.GET("/teams", s.listTeams)
 	protected.POST("/teams", s.createTeam)
-	protected.GET("/teams/:id", s.getTeam)
-	protected.PUT("/teams/:id", s.updateTeam)
-	protected.POST("/teams/:id/members", s.addTeamMember)
+	protected.GET("/teams/:id", s.getTeam, s.requireTeam(db.RoleViewer))
+	protected.PUT("/teams/:id", s.updateTeam, s.requireTeam(db.RoleAdmin))
+	protected.DELETE("/teams/:id", s.deleteTeam, s.requireTeam(db.RoleOwner))
+	protected.POST("/teams/:id/transfer", s.transferTeam, s.requireTeam(db.RoleOwner))
+	protected.POST("/teams/:id/members", s.addTeamMember, s.requireTeam(db.RoleAdmin))
+	protected.PUT("/teams/:id/members/:userId", s.updateTeamMember, s.requireTeam(db.RoleAdmin))
 	protected.DELETE("/teams/:id/members/:userId", s.removeTeamMember)
+	protected.GET("/teams/:id/idle-policy", s.getTeamIdlePolicy, s.requireTeam(db.RoleViewer))
+	protected.PUT("/teams/:id/idle-policy", s.updateTeamIdlePolicy, s.requireTeam(db.RoleAdmin))
+	protected.GET("/teams/:id/budget", s.getTeamBudget, s.requireTeam(db.RoleViewer))
+	protected.PUT("/teams/:id/budget", s.updateTeamBudget, s.requireTeam(db.RoleAdmin))
+	protected.GET("/teams/:id/registry", s.getTeamRegistry, s.requireTeam(db.RoleViewer))
+	protected.PUT("/teams/:id/registry", s.updateTeamRegistry, s.requireTeam(db.RoleAdmin))
+	protected.GET("/teams/:id/github-repos", s.listGitHubRepos, s.requireTeam(db.RoleViewer))
+	protected.POST("/teams/:id/github-repos", s.linkGitHubRepo, s.requireTeam(db.RoleAdmin))
+	protected.DELETE("/teams/:id/github-repos/:repoId", s.unlinkGitHubRepo, s.requireTeam(db.RoleAdmin))
+	protected.GET("/teams/:id/pr-environments", s.listPREnvironments, s.requireTeam(db.RoleViewer))
 
 	// Billing
 	protected.GET("/billing/usage", s.getUsage)
+	protected.GET("/billing/budget", s.getBudget)
+	protected.PUT("/billing/budget", s.updateBudget)
 	protected.GET("/billing/invoices", s.listInvoices)
 	protected.POST("/billing/subscription", s.updateSubscription)
 	protected.POST("/billing/portal", s.createBillingPortalSession)
 	protected.POST("/billing/setup-intent", s.createSetupIntent)
 	protected.GET("/billing/invoices/:id/pdf", s.getInvoicePdfUrl)
 
+	// Events and webhooks
+	protected.GET("/events", s.listEvents)
+	protected.GET("/webhooks", s.listWebhooks)
+	protected.POST("/webhooks", s.createWebhook)
+	protected.PUT("/webhooks/:id", s.updateWebhook, s.requireWebhook)
+	protected.DELETE("/webhooks/:id", s.deleteWebhook, s.requireWebhook)
+	protected.GET("/webhooks/:id/deliveries", s.listWebhookDeliveries, s.requireWebhook)
+	protected.POST("/webhooks/:id/ping", s.pingWebhook, s.requireWebhook)
+
 	// Admin
 	protected.GET("/admin/config", s.getAdminConfig)
 	protected.PUT("/admin/config", s.updateAdminConfig)
@@ -251,15 +480,76 @@ func (s *Server) setupRoutes() {
 
 // Start starts the API server
 func (s *Server) Start() error {
-	return s.echo.Start(fmt.Sprintf(":%d", s.config.Port))
+	return s.echo.Start(s.addr())
+}
+
+func (s *Server) addr() string {
+	if s.config.Addr != "" {
+		return s.config.Addr
+	}
+	return fmt.Sprintf(":%d", s.config.Port)
 }
 
-// Shutdown gracefully stops the server
+// Run serves, over TLS if configured, until ctx is done, then shuts down
+// gracefully
+func (s *Server) Run(ctx context.Context) error {
+	tlsConfig, challenges, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		if tlsConfig == nil {
+			errc <- s.echo.Start(s.addr())
+			return
+		}
+		s.echo.TLSServer.Addr = s.addr()
+		s.echo.TLSServer.TLSConfig = tlsConfig
+		errc <- s.echo.StartServer(s.echo.TLSServer)
+	}()
+	if challenges != nil && s.config.HTTPRedirectAddr != "" {
+		s.echo.Server.Addr = s.config.HTTPRedirectAddr
+		s.echo.Server.Handler = challenges
+		go func() {
+			if err := s.echo.Server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Warn("HTTP challenge and redirect listener failed", "addr", s.config.HTTPRedirectAddr, "error", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, http.ErrServerClosed) {
+			_ = s.Shutdown(context.Background())
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.Shutdown(shutdownCtx)
+}
+
+// shutdownTimeout is how long Shutdown lets open requests finish
+const shutdownTimeout = 30 * time.Second
+
+// Shutdown gracefully stops the server: it stops accepting requests, waits
+// for open ones, then stops background work and provider plugins and closes
+// the database
 func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.echo.Shutdown(ctx)
+	if s.stop != nil {
+		s.stop()
+	}
+	if s.plugins != nil {
+		s.plugins.Kill()
+	}
 	if s.db != nil {
 		s.db.Close()
 	}
-	return s.echo.Shutdown(ctx)
+	return err
 }
 
 // ---- Auth Middleware ----
@@ -276,7 +566,7 @@ func (s *Server) authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		if authHeader == "" {
 			apiKey := c.Request().Header.Get("X-API-Key")
 			if apiKey != "" {
-				return s.validateAPIKey(c, apiKey, next)
+				return s.authenticateAPIKey(c, apiKey, next)
 			}
 			return echo.NewHTTPError(http.StatusUnauthorized, "missing authorization")
 		}
@@ -303,29 +593,6 @@ func (s *Server) authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func (s *Server) validateAPIKey(c echo.Context, apiKey string, next echo.HandlerFunc) error {
-	if strings.HasPrefix(apiKey, "cm_") {
-		// Look up in database
-		key, err := s.db.GetAPIKeyByKey(apiKey)
-		if err == nil && key != nil {
-			c.Set("user_id", key.UserID)
-			c.Set("api_key", apiKey)
-			return next(c)
-		}
-		// Fallback for demo
-		c.Set("user_id", "demo-user")
-		c.Set("api_key", apiKey)
-		return next(c)
-	}
-	return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
-}
-
-func (s *Server) generateAPIKey() string {
-	b := make([]byte, 32)
-	_, _ = rand.Read(b)
-	return "cm_" + base64.RawURLEncoding.EncodeToString(b)
-}
-
 // ---- Handlers ----
 
 func (s *Server) healthCheck(c echo.Context) error {
@@ -356,60 +623,14 @@ func (s *Server) updateUser(c echo.Context) error {
 	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
 }
 
-// API Key handlers
-func (s *Server) listAPIKeys(c echo.Context) error {
-	userID := c.Get("user_id").(string)
-	keys, err := s.db.ListAPIKeysByUser(userID)
-	if err != nil {
-		return c.JSON(http.StatusOK, []interface{}{})
-	}
-	return c.JSON(http.StatusOK, keys)
-}
-
-func (s *Server) createAPIKey(c echo.Context) error {
-	userID := c.Get("user_id").(string)
-
-	var req struct {
-		Name   string `json:"name"`
-		Scopes string `json:"scopes"`
-	}
-	_ = c.Bind(&req)
-
-	key := s.generateAPIKey()
-
-	apiKey := &db.APIKey{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		Name:      req.Name,
-		KeyPrefix: key[:11], // cm_ + first 8 chars
-		KeyHash:   key,      // Should be hashed in production
-		Scopes:    req.Scopes,
-		CreatedAt: time.Now().UTC(),
-	}
-
-	if err := s.db.CreateAPIKey(apiKey); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API key")
-	}
-
-	return c.JSON(http.StatusCreated, map[string]string{
-		"key":     key,
-		"id":      apiKey.ID,
-		"warning": "This key will only be shown once. Save it securely.",
-	})
-}
-
-func (s *Server) deleteAPIKey(c echo.Context) error {
-	id := c.Param("id")
-	if err := s.db.DeleteAPIKey(id); err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "API key not found")
-	}
-	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
-}
-
 // Credential handlers
 func (s *Server) listCredentials(c echo.Context) error {
 	userID := c.Get("user_id").(string)
-	creds, err := s.db.ListCredentialsByUser(userID)
+	teamIDs, err := s.db.TeamIDsByUser(userID, db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	creds, err := s.db.ListCredentialsForUser(userID, teamIDs)
 	if err != nil {
 		return c.JSON(http.StatusOK, []interface{}{})
 	}
@@ -423,10 +644,15 @@ func (s *Server) addCredential(c echo.Context) error {
 		Provider string            `json:"provider"`
 		Name     string            `json:"name"`
 		Data     map[string]string `json:"data"`
+		TeamID   *string           `json:"team_id"` // Shares it with the team's members
 	}
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	teamID, err := s.teamScope(c, req.TeamID, db.RoleAdmin)
+	if err != nil {
+		return err
+	}
 
 	// Encrypt the credential data using AES-256-GCM
 	encryptedData, err := encryptCredentialData(req.Data, s.config.JWTSecret)
@@ -437,6 +663,7 @@ func (s *Server) addCredential(c echo.Context) error {
 	cred := &db.CloudCredential{
 		ID:            uuid.New().String(),
 		UserID:        userID,
+		TeamID:        teamID,
 		Provider:      req.Provider,
 		Name:          req.Name,
 		EncryptedData: encryptedData,
@@ -452,22 +679,16 @@ func (s *Server) addCredential(c echo.Context) error {
 }
 
 func (s *Server) deleteCredential(c echo.Context) error {
-	id := c.Param("id")
-	if err := s.db.DeleteCredential(id); err != nil {
+	cred := c.Get("credential").(*db.CloudCredential)
+	if err := s.db.DeleteCredential(cred.ID); err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
 	}
 	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
 }
 
 func (s *Server) verifyCredential(c echo.Context) error {
-	id := c.Param("id")
 	ctx := c.Request().Context()
-
-	// Get the credential
-	cred, err := s.db.GetCredentialByID(id)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "credential not found")
-	}
+	cred := c.Get("credential").(*db.CloudCredential)
 
 	// Decrypt the credential data
 	credData, err := decryptCredentialData(cred.EncryptedData, s.config.JWTSecret)
@@ -482,7 +703,9 @@ func (s *Server) verifyCredential(c echo.Context) error {
 	}
 
 	// Configure the provider with credentials
-	if err := provider.Configure(credData); err != nil {
+	if err := s.callProvider(ctx, provider, "configure", func(context.Context) error {
+		return provider.Configure(credData)
+	}); err != nil {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"verified": false,
 			"error":    err.Error(),
@@ -490,7 +713,12 @@ func (s *Server) verifyCredential(c echo.Context) error {
 	}
 
 	// Test availability
-	if !provider.IsAvailable(ctx) {
+	if err := s.callProvider(ctx, provider, "is_available", func(ctx context.Context) error {
+		if !provider.IsAvailable(ctx) {
+			return errors.New("provider not available")
+		}
+		return nil
+	}); err != nil {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"verified": false,
 			"error":    "Provider not available with given credentials",
@@ -513,7 +741,11 @@ func (s *Server) verifyCredential(c echo.Context) error {
 func (s *Server) listInstances(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 
-	instances, err := s.db.ListInstancesByUser(userID)
+	teamIDs, err := s.db.TeamIDsByUser(userID, db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	instances, err := s.db.ListInstancesForUser(userID, teamIDs)
 	if err != nil {
 		return c.JSON(http.StatusOK, []db.Instance{})
 	}
@@ -523,153 +755,405 @@ func (s *Server) listInstances(c echo.Context) error {
 
 func (s *Server) createInstance(c echo.Context) error {
 	userID := c.Get("user_id").(string)
-	ctx := c.Request().Context()
+	// Provisioning outlives the request, so it must not use its context
+	ctx := detachedContext(c)
 
 	var req struct {
-		Name         string `json:"name"`
-		Provider     string `json:"provider"`
-		InstanceType string `json:"instance_type"`
-		Region       string `json:"region"`
+		Name         string  `json:"name"`
+		Provider     string  `json:"provider"`
+		InstanceType string  `json:"instance_type"`
+		Region       string  `json:"region"`
+		SSHPublicKey string  `json:"ssh_public_key"` // Authorized for the instance's login user
+		TeamID       *string `json:"team_id"`        // Creates it in this team
+		scheduleRequest
 	}
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
 	}
+	teamID, err := s.teamScope(c, req.TeamID, db.RoleMember)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	createAt, stopAt, err := req.scheduleRequest.resolve(now)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
 	// Get the provider
 	provider, err := s.providers.Get(providers.ProviderType(req.Provider))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "unsupported provider: "+req.Provider)
 	}
+	if budget := s.exhaustedBudget(userID, teamID); budget != nil {
+		return budgetExhaustedError(budget)
+	}
+
+	// The agent on the instance reports activity with its own token
+	agentToken, agentTokenHash := newAgentToken()
 
 	// Create instance in database first
 	dbInstance := &db.Instance{
-		ID:           "inst-" + uuid.New().String()[:8],
-		OwnerID:      userID,
-		Name:         req.Name,
-		Provider:     req.Provider,
-		InstanceType: req.InstanceType,
-		Region:       req.Region,
-		Status:       "provisioning",
-		HourlyRate:   0.0,
-		CreatedAt:    time.Now().UTC(),
-		UpdatedAt:    time.Now().UTC(),
-	}
-
-	// Get pricing
-	for _, pricing := range provider.InstanceTypes() {
-		if string(pricing.Type) == req.InstanceType {
-			dbInstance.HourlyRate = pricing.HourlyRate
-			break
-		}
+		ID:             "inst-" + uuid.New().String()[:8],
+		OwnerID:        userID,
+		TeamID:         teamID,
+		Name:           req.Name,
+		Provider:       req.Provider,
+		InstanceType:   req.InstanceType,
+		Region:         req.Region,
+		Status:         "provisioning",
+		HourlyRate:     s.hourlyRate(ctx, provider, req.InstanceType, req.Region),
+		AgentTokenHash: agentTokenHash,
+		StopAt:         stopAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	spec := provisionSpec{SSHPublicKey: req.SSHPublicKey, CloudURL: publicBaseURL(c)}
+	if createAt != nil {
+		// The scheduler provisions it then, with the spec kept till then
+		pending, _ := json.Marshal(spec)
+		dbInstance.Status = "scheduled"
+		dbInstance.CreateAt = createAt
+		dbInstance.PendingConfig = string(pending)
 	}
 
 	if err := s.db.CreateInstance(dbInstance); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create instance")
 	}
+	s.emitInstanceEvent(EventInstanceCreated, dbInstance, "")
 
 	// Actually create the instance via provider (async)
-	go func() {
-		config := providers.InstanceConfig{
-			Name:   req.Name,
-			Type:   providers.InstanceType(req.InstanceType),
-			Region: req.Region,
-			Image:  "ubuntu:22.04",
-		}
-
-		providerInst, err := provider.CreateInstance(ctx, config)
-		if err != nil {
-			dbInstance.Status = "error"
-			dbInstance.StatusReason = err.Error()
-		} else {
-			dbInstance.Status = string(providerInst.Status)
-			dbInstance.PublicIP = providerInst.PublicIP
-			dbInstance.ProviderID = providerInst.ID
-			dbInstance.SSHPort = providerInst.SSHPort
-		}
-		dbInstance.UpdatedAt = time.Now().UTC()
-		_ = s.db.UpdateInstance(dbInstance)
-	}()
+	if createAt == nil {
+		go s.provisionInstance(ctx, dbInstance, provider, spec, agentToken)
+	}
 
 	return c.JSON(http.StatusCreated, dbInstance)
 }
 
-func (s *Server) getInstance(c echo.Context) error {
-	id := c.Param("id")
+// agentConnectTimeout is how long the agent of an instance the control
+// plane launches may take to connect after the instance is created
+const agentConnectTimeout = 15 * time.Minute
 
-	instance, err := s.db.GetInstanceByID(id)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+// provisionSpec is what provisioning an instance needs besides its record
+type provisionSpec struct {
+	SSHPublicKey string `json:"ssh_public_key,omitempty"`
+	CloudURL     string `json:"cloud_url"` // The control plane as the creator reached it
+}
+
+// hourlyRate returns what an instance type costs in a region, preferring
+// the live USD price there to the provider's table
+func (s *Server) hourlyRate(ctx context.Context, provider providers.Provider, instanceType, region string) float64 {
+	var rate float64
+	for _, pricing := range provider.InstanceTypes() {
+		if string(pricing.Type) == instanceType {
+			rate = pricing.HourlyRate
+			break
+		}
 	}
-	return c.JSON(http.StatusOK, instance)
+	if caps, err := s.providers.Capabilities(ctx, provider.Name(), false); err == nil {
+		if offer, ok := caps.Offer(region, providers.InstanceType(instanceType)); ok && offer.Currency == "USD" {
+			rate = offer.HourlyRate
+		}
+	}
+	return rate
 }
 
-func (s *Server) startInstance(c echo.Context) error {
-	id := c.Param("id")
+// provisionInstance creates an instance at its provider and records the
+// outcome
+func (s *Server) provisionInstance(ctx context.Context, dbInstance *db.Instance, provider providers.Provider, spec provisionSpec, agentToken string) {
+	config := providers.InstanceConfig{
+		Name:         dbInstance.Name,
+		Type:         providers.InstanceType(dbInstance.InstanceType),
+		Region:       dbInstance.Region,
+		Image:        "ubuntu:22.04",
+		SSHPublicKey: spec.SSHPublicKey,
+		OwnerID:      dbInstance.OwnerID,
+		Env: map[string]string{
+			"CM_CLOUD_URL":   spec.CloudURL,
+			"CM_INSTANCE_ID": dbInstance.ID,
+			"CM_AGENT_TOKEN": agentToken,
+		},
+	}
 
-	instance, err := s.db.GetInstanceByID(id)
+	if dbInstance.TeamID != nil {
+		config.TeamID = *dbInstance.TeamID
+	}
+
+	var providerInst *providers.Instance
+	err := s.callProvider(ctx, provider, "create_instance", func(ctx context.Context) error {
+		var err error
+		providerInst, err = provider.CreateInstance(ctx, config)
+		return err
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+		dbInstance.Status = "error"
+		dbInstance.StatusReason = err.Error()
+	} else {
+		dbInstance.Status = string(providerInst.Status)
+		dbInstance.PublicIP = providerInst.PublicIP
+		dbInstance.ProviderID = providerInst.ID
+		dbInstance.SSHPort = providerInst.SSHPort
+		if zone := providerInst.Metadata["availability_zone"]; zone != "" {
+			// Zonal volumes are created where the instance is
+			dbInstance.Zone = zone
+		}
+	}
+	now := time.Now().UTC()
+	if dbInstance.Status == "running" {
+		// Usage is metered from here
+		dbInstance.StartedAt = &now
+	}
+	dbInstance.PendingConfig = ""
+	dbInstance.UpdatedAt = now
+	_ = s.db.UpdateInstance(dbInstance)
+	if dbInstance.Status == "running" {
+		s.emitInstanceEvent(EventInstanceReady, dbInstance, "")
 	}
+}
 
-	instance.Status = "running"
+// launchInstance creates an instance the control plane runs jobs on, such
+// as a prebuild's builder, from its name, owner, team, provider, type and
+// region, and waits for it to be created and its agent to connect. It's
+// recorded before it's created, so destroy it even if launching fails.
+func (s *Server) launchInstance(ctx context.Context, inst *db.Instance, provider providers.Provider, cloudURL string) error {
+	agentToken, agentTokenHash := newAgentToken()
 	now := time.Now().UTC()
-	instance.StartedAt = &now
-	_ = s.db.UpdateInstance(instance)
+	if inst.ID == "" {
+		inst.ID = "inst-" + uuid.New().String()[:8]
+	}
+	inst.Status = "provisioning"
+	inst.HourlyRate = s.hourlyRate(ctx, provider, inst.InstanceType, inst.Region)
+	inst.AgentTokenHash = agentTokenHash
+	inst.CreatedAt, inst.UpdatedAt = now, now
+	if err := s.db.CreateInstance(inst); err != nil {
+		return fmt.Errorf("failed to create the instance: %w", err)
+	}
+	s.emitInstanceEvent(EventInstanceCreated, inst, "")
 
-	return c.JSON(http.StatusOK, instance)
+	s.provisionInstance(ctx, inst, provider, provisionSpec{CloudURL: cloudURL}, agentToken)
+	if inst.Status == "error" {
+		return fmt.Errorf("failed to create the instance: %s", inst.StatusReason)
+	}
+	deadline := time.Now().Add(agentConnectTimeout)
+	for {
+		if _, ok := s.agents.get(inst.ID); ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the instance's agent didn't connect within %s", agentConnectTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// destroyInstance meters an instance the control plane launched, then
+// deletes it at its provider and from the database
+func (s *Server) destroyInstance(inst *db.Instance) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if inst.Status == "running" {
+		if err := s.meterInstance(inst, time.Now().UTC()); err != nil {
+			s.log.Error("failed to meter instance", "instance_id", inst.ID, "error", err)
+		}
+	}
+	s.releaseVolumes(ctx, inst)
+	if provider, err := s.providers.Get(providers.ProviderType(inst.Provider)); err == nil && inst.ProviderID != "" {
+		err := s.callProvider(ctx, provider, "delete_instance", func(ctx context.Context) error {
+			return provider.DeleteInstance(ctx, inst.ProviderID)
+		})
+		if err != nil {
+			s.log.Error("failed to delete instance", "instance_id", inst.ID, "error", err)
+		}
+	}
+	_ = s.db.DeleteInstance(inst.ID)
+}
+
+func (s *Server) getInstance(c echo.Context) error {
+	return c.JSON(http.StatusOK, c.Get("instance"))
+}
+
+func (s *Server) startInstance(c echo.Context) error {
+	return s.startOrStopInstance(c, true)
 }
 
 func (s *Server) stopInstance(c echo.Context) error {
-	id := c.Param("id")
+	return s.startOrStopInstance(c, false)
+}
+
+func (s *Server) startOrStopInstance(c echo.Context, run bool) error {
+	instance := c.Get("instance").(*db.Instance)
+	if run {
+		if budget := s.exhaustedBudget(instance.OwnerID, instance.TeamID); budget != nil {
+			return budgetExhaustedError(budget)
+		}
+	}
+	if instance.Status == "scheduled" {
+		// Starting a scheduled instance creates it now
+		if !run {
+			return echo.NewHTTPError(http.StatusConflict, "the instance isn't created yet; delete it to cancel")
+		}
+		if err := s.provisionScheduledInstance(detachedContext(c), instance); err != nil {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return c.JSON(http.StatusOK, instance)
+	}
+	if err := s.setInstanceRunning(c.Request().Context(), instance, run, ""); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, instance)
+}
 
-	instance, err := s.db.GetInstanceByID(id)
+// setInstanceRunning starts or stops an instance at its provider, records
+// its new status with a reason, if any, and notifies the owner
+func (s *Server) setInstanceRunning(ctx context.Context, instance *db.Instance, run bool, reason string) error {
+	provider, err := s.providers.Get(providers.ProviderType(instance.Provider))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+		return err
+	}
+	operation, status := "stop_instance", "stopped"
+	if run {
+		operation, status = "start_instance", "running"
+	}
+	err = s.callProvider(ctx, provider, operation, func(ctx context.Context) error {
+		if run {
+			return provider.StartInstance(ctx, instance.ProviderID)
+		}
+		return provider.StopInstance(ctx, instance.ProviderID)
+	})
+	if err != nil {
+		return err
 	}
 
-	instance.Status = "stopped"
 	now := time.Now().UTC()
-	instance.StoppedAt = &now
-	_ = s.db.UpdateInstance(instance)
+	if !run && instance.Status == "running" {
+		if err := s.meterInstance(instance, now); err != nil {
+			s.log.Error("failed to meter instance", "instance_id", instance.ID, "error", err)
+		}
+	}
+	instance.Status = status
+	instance.StatusReason = reason
+	instance.UpdatedAt = now
+	if instance.StopAt != nil && !instance.StopAt.After(now) {
+		// A stop time that passed doesn't carry over to the next run
+		instance.StopAt = nil
+	}
+	if run {
+		instance.StartedAt = &now
+	} else {
+		instance.StoppedAt = &now
+	}
+	if err := s.db.UpdateInstance(instance); err != nil {
+		return err
+	}
 
-	return c.JSON(http.StatusOK, instance)
+	details := map[string]interface{}{}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	s.NotifyInstanceUpdate(instance.OwnerID, instance.ID, status, details)
+	if run {
+		s.emitInstanceEvent(EventInstanceReady, instance, "")
+	} else {
+		s.emitInstanceEvent(EventInstanceStopped, instance, reason)
+	}
+	return nil
+}
+
+// publicBaseURL returns the control plane's URL as the client reached it
+func publicBaseURL(c echo.Context) string {
+	scheme := "https"
+	if c.Request().TLS == nil {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request().Host)
 }
 
 func (s *Server) deleteInstance(c echo.Context) error {
-	id := c.Param("id")
-	if err := s.db.DeleteInstance(id); err != nil {
+	instance := c.Get("instance").(*db.Instance)
+	if instance.Status == "running" {
+		if err := s.meterInstance(instance, time.Now().UTC()); err != nil {
+			s.log.Error("failed to meter instance", "instance_id", instance.ID, "error", err)
+		}
+	}
+	s.releaseVolumes(c.Request().Context(), instance)
+	s.releaseHostInstance(c.Request().Context(), instance)
+	if err := s.db.DeleteInstance(instance.ID); err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+// getInstanceLogs returns the last ?tail= lines (default 100) of an instance's logs
 func (s *Server) getInstanceLogs(c echo.Context) error {
-	return c.JSON(http.StatusOK, map[string]string{
-		"logs": "Initializing system...\nLoading drivers...\nStarting services...\nReady.",
+	instance := c.Get("instance").(*db.Instance)
+
+	tail := 100
+	if v := c.QueryParam("tail"); v != "" {
+		var err error
+		if tail, err = strconv.Atoi(v); err != nil || tail < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "tail must be a non-negative number")
+		}
+	}
+
+	if _, connected := s.agents.get(instance.ID); connected {
+		logs, err := s.agentLogs(c.Request().Context(), instance.ID, "", tail)
+		if err != nil {
+			return agentError(err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"logs": logs})
+	}
+
+	provider, err := s.providers.Get(providers.ProviderType(instance.Provider))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	var logs string
+	err = s.callProvider(c.Request().Context(), provider, "get_logs", func(ctx context.Context) error {
+		var err error
+		logs, err = provider.GetLogs(ctx, instance.ProviderID, tail)
+		return err
 	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"logs": logs})
+}
+
+// sshUsers are the login users of providers whose images don't use "ubuntu"
+var sshUsers = map[string]string{
+	string(providers.ProviderHetzner): "root",
+	string(providers.ProviderDocker):  "root",
+	string(providers.ProviderHosts):   "root",
 }
 
 func (s *Server) getSSHConfig(c echo.Context) error {
-	id := c.Param("id")
-	instance, _ := s.db.GetInstanceByID(id)
-
-	host := "34.201.12.45"
-	port := 22
-	if instance != nil {
-		host = instance.PublicIP
-		port = instance.SSHPort
-		if port == 0 {
-			port = 22
-		}
+	instance := c.Get("instance").(*db.Instance)
+
+	port := instance.SSHPort
+	if port == 0 {
+		port = 22
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"host": host,
+		"host": instance.PublicIP,
 		"port": port,
-		"user": "ubuntu",
+		"user": sshUser(instance.Provider),
 	})
 }
 
+// sshUser returns the login user of a provider's instances
+func sshUser(provider string) string {
+	if user, ok := sshUsers[provider]; ok {
+		return user
+	}
+	return "ubuntu"
+}
+
 // Provider handlers
 func (s *Server) listProviders(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -683,86 +1167,87 @@ func (s *Server) listProviders(c echo.Context) error {
 	return c.JSON(http.StatusOK, result)
 }
 
-func (s *Server) listRegions(c echo.Context) error {
-	name := c.Param("name")
-	provider, err := s.providers.Get(providers.ProviderType(name))
+// getCapabilities returns a provider's live pricing and availability, or its
+// static tables if they can't be discovered; ?refresh=true bypasses the cache
+func (s *Server) getCapabilities(c echo.Context) error {
+	caps, err := s.providers.Capabilities(c.Request().Context(), providers.ProviderType(c.Param("name")), c.QueryParam("refresh") == "true")
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
 	}
-	return c.JSON(http.StatusOK, provider.Regions())
+	return c.JSON(http.StatusOK, caps)
 }
 
-func (s *Server) listInstanceTypes(c echo.Context) error {
-	name := c.Param("name")
-	provider, err := s.providers.Get(providers.ProviderType(name))
+// createEstimate projects what an instance type costs over some hours with
+// each provider, and finds the cheapest GPU offer meeting the requirements
+func (s *Server) createEstimate(c echo.Context) error {
+	var req providers.EstimateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	estimate, err := s.providers.Estimate(c.Request().Context(), req)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	return c.JSON(http.StatusOK, provider.InstanceTypes())
-}
-
-// Team handlers
-func (s *Server) listTeams(c echo.Context) error {
-	return c.JSON(http.StatusOK, []interface{}{})
+	return c.JSON(http.StatusOK, estimate)
 }
 
-func (s *Server) createTeam(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
-}
-
-func (s *Server) getTeam(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
-}
-
-func (s *Server) updateTeam(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
-}
-
-func (s *Server) addTeamMember(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
+func (s *Server) listRegions(c echo.Context) error {
+	caps, err := s.providers.Capabilities(c.Request().Context(), providers.ProviderType(c.Param("name")), false)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
+	}
+	return c.JSON(http.StatusOK, caps.Regions)
 }
 
-func (s *Server) removeTeamMember(c echo.Context) error {
-	return c.JSON(http.StatusNotImplemented, map[string]string{"error": "not implemented"})
+// listInstanceTypes returns the types available in ?region= at their price
+// there, or anywhere at their lowest price
+func (s *Server) listInstanceTypes(c echo.Context) error {
+	caps, err := s.providers.Capabilities(c.Request().Context(), providers.ProviderType(c.Param("name")), false)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Provider not found")
+	}
+	return c.JSON(http.StatusOK, caps.Pricing(c.QueryParam("region")))
 }
 
 // Billing handlers
+
+// getUsage returns the metered usage of the signed-in user's instances this
+// month, a forecast for the month and the budget, if any
 func (s *Server) getUsage(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 
-	now := time.Now()
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-
-	records, _ := s.db.GetUsageByUserAndPeriod(userID, startOfMonth, now)
-
-	var totalCost float64
-	var cpuHours, gpuHours float64
-
-	for _, r := range records {
-		totalCost += r.TotalCost
-		if strings.Contains(r.Type, "cpu") {
-			cpuHours += r.Quantity
-		} else if strings.Contains(r.Type, "gpu") {
-			gpuHours += r.Quantity
-		}
+	now := time.Now().UTC()
+	usage, _, err := s.userUsage(userID, now)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get usage")
 	}
 
-	// Default demo values
-	if totalCost == 0 {
-		cpuHours = 124.5
-		gpuHours = 12.0
-		totalCost = 45.20
+	// Forecast at the rate of the month so far
+	start := monthStart(now)
+	elapsed := now.Sub(start).Hours()
+	month := start.AddDate(0, 1, 0).Sub(start).Hours()
+	forecast := usage.Cost
+	if elapsed > 0 {
+		forecast = usage.Cost / elapsed * month
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	result := map[string]interface{}{
 		"current_month": map[string]interface{}{
-			"cpu_hours":  cpuHours,
-			"gpu_hours":  gpuHours,
-			"total_cost": totalCost,
-			"instances":  3,
-			"forecast":   totalCost * 2,
+			"cpu_hours":  usage.CPUHours,
+			"gpu_hours":  usage.GPUHours,
+			"total_cost": usage.Cost,
+			"instances":  usage.Running,
+			"forecast":   forecast,
 		},
-	})
+	}
+	if budget, err := s.db.GetUserBudget(userID); err == nil && budget.MonthlyLimit > 0 {
+		result["budget"] = map[string]interface{}{
+			"monthly_limit": budget.MonthlyLimit,
+			"spent":         usage.Cost,
+			"hard_stop":     budget.HardStop,
+		}
+	}
+	return c.JSON(http.StatusOK, result)
 }
 
 func (s *Server) listInvoices(c echo.Context) error {
@@ -0,0 +1,486 @@
+// Package api provides teams, member roles and access to shared resources
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// access is what a request does with an instance or credential
+type access int
+
+const (
+	accessRead   access = iota // View it, its logs or its usage
+	accessUse                  // Start, stop, SSH into or provision with it
+	accessManage               // Delete or transfer it
+)
+
+// minTeamRole is the least role a team member needs for an access to the
+// team's instances and credentials
+var minTeamRole = map[access]string{
+	accessRead:   db.RoleViewer,
+	accessUse:    db.RoleMember,
+	accessManage: db.RoleAdmin,
+}
+
+// slugPattern matches characters team slugs may not contain
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// resourceAccess reports whether a user may access a resource they own, or
+// that is shared with teamID. A resource the user can't even read answers
+// 404 so its existence isn't revealed; one they can read answers 403.
+func (s *Server) resourceAccess(userID, ownerID string, teamID *string, need access) error {
+	if ownerID == userID {
+		return nil
+	}
+	if teamID != nil {
+		if member, err := s.db.GetTeamMember(*teamID, userID); err == nil {
+			if db.RoleRank(member.Role) >= db.RoleRank(minTeamRole[need]) {
+				return nil
+			}
+			if db.RoleRank(member.Role) >= db.RoleRank(minTeamRole[accessRead]) {
+				return echo.NewHTTPError(http.StatusForbidden, "your team role doesn't allow this")
+			}
+		}
+	}
+	return errNotFound
+}
+
+// errNotFound hides resources the user has no access to
+var errNotFound = echo.NewHTTPError(http.StatusNotFound, "not found")
+
+// instanceAccess reports whether a user may access an instance
+func (s *Server) instanceAccess(userID string, instance *db.Instance, need access) error {
+	if err := s.resourceAccess(userID, instance.OwnerID, instance.TeamID, need); err != nil {
+		if err == errNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// requireInstance loads the instance named by :id into the context as
+// "instance" if the signed-in user has the access to it
+func (s *Server) requireInstance(need access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			instance, err := s.db.GetInstanceByID(c.Param("id"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+			}
+			if err := s.instanceAccess(c.Get("user_id").(string), instance, need); err != nil {
+				return err
+			}
+			c.Set("instance", instance)
+			return next(c)
+		}
+	}
+}
+
+// requireCredential loads the credential named by :id into the context as
+// "credential" if the signed-in user has the access to it
+func (s *Server) requireCredential(need access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cred, err := s.db.GetCredentialByID(c.Param("id"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "credential not found")
+			}
+			if err := s.resourceAccess(c.Get("user_id").(string), cred.UserID, cred.TeamID, need); err != nil {
+				if err == errNotFound {
+					return echo.NewHTTPError(http.StatusNotFound, "credential not found")
+				}
+				return err
+			}
+			c.Set("credential", cred)
+			return next(c)
+		}
+	}
+}
+
+// requireTeam loads the signed-in user's membership in the team named by
+// :id into the context as "team_member" if their role is at least minRole
+func (s *Server) requireTeam(minRole string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			member, err := s.db.GetTeamMember(c.Param("id"), c.Get("user_id").(string))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+			}
+			if db.RoleRank(member.Role) < db.RoleRank(minRole) {
+				return echo.NewHTTPError(http.StatusForbidden, "this needs the "+minRole+" role in the team")
+			}
+			c.Set("team_member", member)
+			return next(c)
+		}
+	}
+}
+
+// teamScope checks that the signed-in user has at least minRole in a team
+// a resource is created in; a nil or empty team ID means no team
+func (s *Server) teamScope(c echo.Context, teamID *string, minRole string) (*string, error) {
+	if teamID == nil || *teamID == "" {
+		return nil, nil
+	}
+	member, err := s.db.GetTeamMember(*teamID, c.Get("user_id").(string))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+	if db.RoleRank(member.Role) < db.RoleRank(minRole) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "this needs the "+minRole+" role in the team")
+	}
+	return teamID, nil
+}
+
+// teamResponse is a team with the signed-in user's role in it
+type teamResponse struct {
+	db.Team
+	Role string `json:"role"`
+}
+
+// teamMemberResponse is a member with their user's details
+type teamMemberResponse struct {
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Team handlers
+func (s *Server) listTeams(c echo.Context) error {
+	memberships, err := s.db.ListTeamsByUser(c.Get("user_id").(string))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	teams := make([]teamResponse, 0, len(memberships))
+	for _, m := range memberships {
+		teams = append(teams, teamResponse{Team: m.Team, Role: m.Role})
+	}
+	return c.JSON(http.StatusOK, teams)
+}
+
+func (s *Server) createTeam(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+	if req.Slug == "" {
+		req.Slug = req.Name
+	}
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(req.Slug), "-"), "-")
+	if slug == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "slug must contain letters or digits")
+	}
+	if _, err := s.db.GetTeamBySlug(slug); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "a team with slug "+slug+" already exists")
+	}
+
+	now := time.Now().UTC()
+	team := &db.Team{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Slug:      slug,
+		OwnerID:   userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.CreateTeam(team); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create team")
+	}
+	return c.JSON(http.StatusCreated, teamResponse{Team: *team, Role: db.RoleOwner})
+}
+
+func (s *Server) getTeam(c echo.Context) error {
+	member := c.Get("team_member").(*db.TeamMember)
+	team, err := s.db.GetTeamByID(member.TeamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+	members, err := s.db.ListTeamMembers(team.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list members")
+	}
+
+	result := make([]teamMemberResponse, 0, len(members))
+	for _, m := range members {
+		result = append(result, teamMemberResponse{
+			UserID:   m.UserID,
+			Email:    m.User.Email,
+			Name:     m.User.Name,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"team":    teamResponse{Team: *team, Role: member.Role},
+		"members": result,
+	})
+}
+
+func (s *Server) updateTeam(c echo.Context) error {
+	member := c.Get("team_member").(*db.TeamMember)
+	team, err := s.db.GetTeamByID(member.TeamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		team.Name = name
+	}
+	team.UpdatedAt = time.Now().UTC()
+	if err := s.db.UpdateTeam(team); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update team")
+	}
+	return c.JSON(http.StatusOK, teamResponse{Team: *team, Role: member.Role})
+}
+
+// deleteTeam deletes a team once it has no instances left
+func (s *Server) deleteTeam(c echo.Context) error {
+	teamID := c.Get("team_member").(*db.TeamMember).TeamID
+	count, err := s.db.CountTeamInstances(teamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count instances")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "the team still has instances; delete or transfer them first")
+	}
+	if count, err = s.db.CountTeamVolumes(teamID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count volumes")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "the team still has volumes or snapshots; delete them first")
+	}
+	if count, err = s.db.CountTeamHosts(teamID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count hosts")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "the team still has hosts; delete them first")
+	}
+	if err := s.db.DeleteTeam(teamID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete team")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// assignableRole checks that an actor may give a member a role: admins
+// manage members and viewers, owners also admins. Ownership moves with
+// transferTeam instead.
+func assignableRole(actor *db.TeamMember, role string) error {
+	switch role {
+	case db.RoleAdmin, db.RoleMember, db.RoleViewer:
+	case db.RoleOwner:
+		return echo.NewHTTPError(http.StatusBadRequest, "a team has one owner; transfer ownership instead")
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "role must be admin, member or viewer")
+	}
+	if role == db.RoleAdmin && actor.Role != db.RoleOwner {
+		return echo.NewHTTPError(http.StatusForbidden, "only the team owner can make admins")
+	}
+	return nil
+}
+
+func (s *Server) addTeamMember(c echo.Context) error {
+	actor := c.Get("team_member").(*db.TeamMember)
+
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if req.Role == "" {
+		req.Role = db.RoleMember
+	}
+	if err := assignableRole(actor, req.Role); err != nil {
+		return err
+	}
+	user, err := s.db.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "no user with email "+req.Email)
+	}
+	if _, err := s.db.GetTeamMember(actor.TeamID, user.ID); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, req.Email+" is already a member")
+	}
+
+	member := &db.TeamMember{
+		ID:       uuid.New().String(),
+		TeamID:   actor.TeamID,
+		UserID:   user.ID,
+		Role:     req.Role,
+		JoinedAt: time.Now().UTC(),
+	}
+	if err := s.db.AddTeamMember(member); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add member")
+	}
+	return c.JSON(http.StatusCreated, teamMemberResponse{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Name:     user.Name,
+		Role:     member.Role,
+		JoinedAt: member.JoinedAt,
+	})
+}
+
+// targetMember returns the member named by :userId whom the actor may
+// change: admins change members and viewers, the owner everyone but itself
+func (s *Server) targetMember(c echo.Context, actor *db.TeamMember) (*db.TeamMember, error) {
+	target, err := s.db.GetTeamMember(actor.TeamID, c.Param("userId"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "member not found")
+	}
+	if target.Role == db.RoleOwner {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "the owner's membership can't be changed; transfer ownership first")
+	}
+	if target.Role == db.RoleAdmin && actor.Role != db.RoleOwner {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "only the team owner can change admins")
+	}
+	return target, nil
+}
+
+func (s *Server) updateTeamMember(c echo.Context) error {
+	actor := c.Get("team_member").(*db.TeamMember)
+	target, err := s.targetMember(c, actor)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if err := assignableRole(actor, req.Role); err != nil {
+		return err
+	}
+	target.Role = req.Role
+	if err := s.db.UpdateTeamMember(target); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update member")
+	}
+	return c.JSON(http.StatusOK, target)
+}
+
+// removeTeamMember removes a member; any member but the owner may leave
+func (s *Server) removeTeamMember(c echo.Context) error {
+	teamID := c.Param("id")
+	userID := c.Get("user_id").(string)
+	actor, err := s.db.GetTeamMember(teamID, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+
+	if c.Param("userId") != userID {
+		if db.RoleRank(actor.Role) < db.RoleRank(db.RoleAdmin) {
+			return echo.NewHTTPError(http.StatusForbidden, "this needs the admin role in the team")
+		}
+		if _, err := s.targetMember(c, actor); err != nil {
+			return err
+		}
+	} else if actor.Role == db.RoleOwner {
+		return echo.NewHTTPError(http.StatusForbidden, "the owner can't leave; transfer ownership first")
+	}
+
+	if err := s.db.RemoveTeamMember(teamID, c.Param("userId")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove member")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// transferTeam makes another member the team's owner
+func (s *Server) transferTeam(c echo.Context) error {
+	actor := c.Get("team_member").(*db.TeamMember)
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if _, err := s.db.GetTeamMember(actor.TeamID, req.UserID); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "the new owner must be a member of the team")
+	}
+	team, err := s.db.GetTeamByID(actor.TeamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Team not found")
+	}
+	if req.UserID == team.OwnerID {
+		return c.JSON(http.StatusOK, teamResponse{Team: *team, Role: db.RoleOwner})
+	}
+	if err := s.db.TransferTeamOwnership(team, req.UserID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to transfer ownership")
+	}
+	return c.JSON(http.StatusOK, teamResponse{Team: *team, Role: db.RoleAdmin})
+}
+
+// transferInstance moves an instance into or out of a team, or to another
+// owner. The new owner must be a member of the instance's resulting team,
+// with a role that may use instances.
+func (s *Server) transferInstance(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	instance := c.Get("instance").(*db.Instance)
+
+	var req struct {
+		TeamID  *string `json:"team_id"`  // "" moves the instance out of its team
+		OwnerID string  `json:"owner_id"` // Defaults to the current owner
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+
+	teamID := instance.TeamID
+	if req.TeamID != nil {
+		var err error
+		if teamID, err = s.teamScope(c, req.TeamID, db.RoleMember); err != nil {
+			return err
+		}
+	}
+	ownerID := instance.OwnerID
+	if req.OwnerID != "" {
+		ownerID = req.OwnerID
+	}
+
+	if ownerID != userID || instance.OwnerID != userID {
+		// Handing an instance to someone else, or taking one over, stays
+		// within a team whose members may use it
+		if teamID == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "instances can only change owner within a team")
+		}
+		member, err := s.db.GetTeamMember(*teamID, ownerID)
+		if err != nil || db.RoleRank(member.Role) < db.RoleRank(db.RoleMember) {
+			return echo.NewHTTPError(http.StatusBadRequest, "the new owner must be a member of the team")
+		}
+	}
+
+	instance.OwnerID = ownerID
+	instance.TeamID = teamID
+	instance.UpdatedAt = time.Now().UTC()
+	if err := s.db.UpdateInstance(instance); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to transfer instance")
+	}
+	return c.JSON(http.StatusOK, instance)
+}
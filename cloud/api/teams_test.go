@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// teamFixture is a server with a team whose owner, admin, member and viewer
+// are signed in, and an outsider who isn't in the team
+type teamFixture struct {
+	t      *testing.T
+	s      *Server
+	teamID string
+	users  map[string]*db.User
+	tokens map[string]string
+}
+
+func newTeamFixture(t *testing.T) *teamFixture {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	s, err := NewServer(Config{
+		JWTSecret:   "test",
+		DatabaseURL: filepath.Join(t.TempDir(), "cloud.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &teamFixture{t: t, s: s, users: map[string]*db.User{}, tokens: map[string]string{}}
+	for _, name := range []string{"owner", "admin", "member", "viewer", "outsider"} {
+		user := &db.User{ID: "user-" + name, Email: name + "@example.com", Name: name, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := s.db.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+		token, _, err := s.generateTokenPair(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.users[name], f.tokens[name] = user, token
+	}
+
+	var team teamResponse
+	f.do("owner", http.MethodPost, "/api/v1/teams", `{"name":"Platform Team"}`, http.StatusCreated, &team)
+	if team.Slug != "platform-team" || team.Role != db.RoleOwner {
+		t.Fatalf("created team = %+v, want slug platform-team and role owner", team)
+	}
+	f.teamID = team.ID
+	for _, role := range []string{db.RoleAdmin, db.RoleMember, db.RoleViewer} {
+		f.do("owner", http.MethodPost, "/api/v1/teams/"+f.teamID+"/members",
+			`{"email":"`+role+`@example.com","role":"`+role+`"}`, http.StatusCreated, nil)
+	}
+	return f
+}
+
+// do sends a request as a user, checks its status and decodes its response
+// into out, if given
+func (f *teamFixture) do(user, method, path, body string, want int, out interface{}) {
+	f.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.tokens[user])
+	rec := httptest.NewRecorder()
+	f.s.echo.ServeHTTP(rec, req)
+	if rec.Code != want {
+		f.t.Fatalf("%s %s as %s = %d %s, want %d", method, path, user, rec.Code, rec.Body.String(), want)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+// instance creates an instance owned by a user, in the team if shared
+func (f *teamFixture) instance(owner string, shared bool) *db.Instance {
+	f.t.Helper()
+	inst := &db.Instance{ID: "inst-" + owner, OwnerID: f.users[owner].ID, Name: owner, Status: "stopped", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if shared {
+		inst.ID += "-team"
+		inst.TeamID = &f.teamID
+	}
+	if err := f.s.db.CreateInstance(inst); err != nil {
+		f.t.Fatal(err)
+	}
+	return inst
+}
+
+func TestInstanceAccess(t *testing.T) {
+	f := newTeamFixture(t)
+	shared := f.instance("member", true)
+	private := f.instance("member", false)
+
+	tests := []struct {
+		user     string
+		instance *db.Instance
+		need     access
+		want     int // 0 for allowed
+	}{
+		{"member", private, accessManage, 0},
+		{"owner", private, accessRead, http.StatusNotFound},
+		{"member", shared, accessManage, 0},
+		{"owner", shared, accessManage, 0},
+		{"admin", shared, accessManage, 0},
+		{"viewer", shared, accessRead, 0},
+		{"viewer", shared, accessUse, http.StatusForbidden},
+		{"outsider", shared, accessRead, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		err := f.s.instanceAccess(f.users[tt.user].ID, tt.instance, tt.need)
+		got := 0
+		if err != nil {
+			got = err.(*echo.HTTPError).Code
+		}
+		if got != tt.want {
+			t.Errorf("instanceAccess(%s, %s, %d) = %d, want %d", tt.user, tt.instance.ID, tt.need, got, tt.want)
+		}
+	}
+
+	// Members list the team's instances along with their own
+	var outsiderList []db.Instance
+	f.do("outsider", http.MethodGet, "/api/v1/instances", "", http.StatusOK, &outsiderList)
+	if len(outsiderList) != 0 {
+		t.Errorf("outsider lists %d instances, want 0", len(outsiderList))
+	}
+	var viewerList []db.Instance
+	f.do("viewer", http.MethodGet, "/api/v1/instances", "", http.StatusOK, &viewerList)
+	if len(viewerList) != 1 || viewerList[0].ID != shared.ID {
+		t.Errorf("viewer lists %v, want only %s", viewerList, shared.ID)
+	}
+
+	f.do("viewer", http.MethodGet, "/api/v1/instances/"+shared.ID+"/ssh", "", http.StatusForbidden, nil)
+	f.do("member", http.MethodGet, "/api/v1/instances/"+shared.ID+"/ssh", "", http.StatusOK, nil)
+	f.do("outsider", http.MethodDelete, "/api/v1/instances/"+shared.ID, "", http.StatusNotFound, nil)
+}
+
+func TestTeamMemberRoles(t *testing.T) {
+	f := newTeamFixture(t)
+	members := "/api/v1/teams/" + f.teamID + "/members/"
+
+	// Admins manage members and viewers, not admins or the owner
+	f.do("admin", http.MethodPost, "/api/v1/teams/"+f.teamID+"/members", `{"email":"outsider@example.com","role":"admin"}`, http.StatusForbidden, nil)
+	f.do("admin", http.MethodPut, members+f.users["viewer"].ID, `{"role":"member"}`, http.StatusOK, nil)
+	f.do("admin", http.MethodPut, members+f.users["owner"].ID, `{"role":"member"}`, http.StatusForbidden, nil)
+	f.do("member", http.MethodPut, members+f.users["viewer"].ID, `{"role":"viewer"}`, http.StatusForbidden, nil)
+	f.do("owner", http.MethodPost, "/api/v1/teams/"+f.teamID+"/members", `{"email":"member@example.com"}`, http.StatusConflict, nil)
+
+	// Anyone but the owner may leave
+	f.do("owner", http.MethodDelete, members+f.users["owner"].ID, "", http.StatusForbidden, nil)
+	f.do("viewer", http.MethodDelete, members+f.users["viewer"].ID, "", http.StatusNoContent, nil)
+	f.do("viewer", http.MethodGet, "/api/v1/teams/"+f.teamID, "", http.StatusNotFound, nil)
+
+	// Transferring ownership makes the old owner an admin
+	f.do("admin", http.MethodPost, "/api/v1/teams/"+f.teamID+"/transfer", `{"user_id":"`+f.users["member"].ID+`"}`, http.StatusForbidden, nil)
+	f.do("owner", http.MethodPost, "/api/v1/teams/"+f.teamID+"/transfer", `{"user_id":"`+f.users["member"].ID+`"}`, http.StatusOK, nil)
+	var got struct {
+		Team    teamResponse         `json:"team"`
+		Members []teamMemberResponse `json:"members"`
+	}
+	f.do("owner", http.MethodGet, "/api/v1/teams/"+f.teamID, "", http.StatusOK, &got)
+	if got.Team.OwnerID != f.users["member"].ID || got.Team.Role != db.RoleAdmin {
+		t.Errorf("after transfer team = %+v, want owner %s and role admin", got.Team, f.users["member"].ID)
+	}
+
+	// A team with instances can't be deleted
+	f.instance("admin", true)
+	f.do("member", http.MethodDelete, "/api/v1/teams/"+f.teamID, "", http.StatusConflict, nil)
+}
+
+func TestTransferInstance(t *testing.T) {
+	f := newTeamFixture(t)
+	inst := f.instance("member", false)
+	path := "/api/v1/instances/" + inst.ID + "/transfer"
+
+	// Into the team, then to another of its members
+	f.do("outsider", http.MethodPost, path, `{"team_id":"`+f.teamID+`"}`, http.StatusNotFound, nil)
+	f.do("member", http.MethodPost, path, `{"team_id":"`+f.teamID+`"}`, http.StatusOK, nil)
+	f.do("member", http.MethodPost, path, `{"owner_id":"`+f.users["viewer"].ID+`"}`, http.StatusBadRequest, nil)
+	f.do("member", http.MethodPost, path, `{"owner_id":"`+f.users["outsider"].ID+`"}`, http.StatusBadRequest, nil)
+	f.do("member", http.MethodPost, path, `{"owner_id":"`+f.users["admin"].ID+`"}`, http.StatusOK, nil)
+
+	// The old owner still reaches it through the team, but can't manage it
+	f.do("member", http.MethodGet, "/api/v1/instances/"+inst.ID, "", http.StatusOK, nil)
+	f.do("member", http.MethodPost, path, `{"team_id":""}`, http.StatusForbidden, nil)
+
+	var moved db.Instance
+	f.do("admin", http.MethodPost, path, `{"team_id":""}`, http.StatusOK, &moved)
+	if moved.OwnerID != f.users["admin"].ID || moved.TeamID != nil {
+		t.Errorf("after moving out of the team instance = %+v, want owner %s and no team", moved, f.users["admin"].ID)
+	}
+	f.do("member", http.MethodGet, "/api/v1/instances/"+inst.ID, "", http.StatusNotFound, nil)
+}
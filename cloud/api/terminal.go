@@ -5,6 +5,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/cloud/providers"
@@ -25,26 +26,44 @@ type LogLine struct {
 	Message   string `json:"message"`
 }
 
+// queryUserID returns the user a WebSocket's token query parameter, a JWT or
+// an API key, authenticates; browsers can't set headers on WebSockets.
+// Requests without a valid token are the demo user in dev mode only.
+func (s *Server) queryUserID(c echo.Context) (string, error) {
+	token := c.QueryParam("token")
+	if strings.HasPrefix(token, "cm_") && token != "cm_demo" {
+		return s.authorizeAPIKey(token, c.Request().Method, c.Path())
+	}
+	if token != "" {
+		if claims, err := s.validateJWT(token); err == nil {
+			return claims.UserID, nil
+		}
+	}
+	if s.config.DevMode {
+		return "demo", nil
+	}
+	return "", echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing token")
+}
+
 // HandleTerminalWebSocket handles WebSocket connections for terminal access
 func (s *Server) HandleTerminalWebSocket(c echo.Context) error {
 	instanceID := c.Param("id")
 
 	// Authenticate
-	token := c.QueryParam("token")
-	userID := "demo"
-	if token != "" && token != "cm_demo" {
-		if claims, err := s.validateJWT(token); err == nil {
-			userID = claims.UserID
-		}
+	userID, err := s.queryUserID(c)
+	if err != nil {
+		return err
 	}
 
-	// Verify instance ownership
+	// Verify the user may access the instance
 	instance, err := s.db.GetInstanceByID(instanceID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
 	}
-	if instance.OwnerID != userID && userID != "demo" {
-		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	if userID != "demo" {
+		if err := s.instanceAccess(userID, instance, accessUse); err != nil {
+			return err
+		}
 	}
 
 	// Upgrade connection
@@ -54,6 +73,8 @@ func (s *Server) HandleTerminalWebSocket(c echo.Context) error {
 		return err
 	}
 	defer conn.Close()
+	s.metrics.wsSessions.add(1, "terminal")
+	defer s.metrics.wsSessions.add(-1, "terminal")
 
 	// Send welcome message
 	_ = conn.WriteJSON(TerminalMessage{
@@ -65,9 +86,10 @@ func (s *Server) HandleTerminalWebSocket(c echo.Context) error {
 		Content: "Container ID: " + instance.ProviderID,
 	})
 
-	// Get provider for this instance
+	// Commands run through the instance's agent when it is connected, and
+	// through its provider otherwise
 	provider, err := s.providers.Get(providers.ProviderType(instance.Provider))
-	if err != nil {
+	if _, connected := s.agents.get(instance.ID); err != nil && !connected {
 		_ = conn.WriteJSON(TerminalMessage{
 			Type:    "error",
 			Content: "Provider not available: " + instance.Provider,
@@ -87,8 +109,23 @@ func (s *Server) HandleTerminalWebSocket(c echo.Context) error {
 
 		if msg.Type == "command" {
 			// Execute command in container
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			stdout, stderr, exitCode, err := provider.ExecCommand(ctx, instance.ProviderID, []string{"sh", "-c", msg.Content})
+			var stdout, stderr string
+			var exitCode int
+			ctx, cancel := context.WithTimeout(detachedContext(c), 30*time.Second)
+			command := []string{"sh", "-c", msg.Content}
+			var err error
+			if _, connected := s.agents.get(instance.ID); connected {
+				var reply *agentMessage
+				if reply, err = s.agents.call(ctx, instance.ID, agentMessage{Type: "exec", Command: command}); err == nil {
+					stdout, stderr, exitCode = reply.Stdout, reply.Stderr, reply.ExitCode
+				}
+			} else {
+				err = s.callProvider(ctx, provider, "exec_command", func(ctx context.Context) error {
+					var err error
+					stdout, stderr, exitCode, err = provider.ExecCommand(ctx, instance.ProviderID, command)
+					return err
+				})
+			}
 			cancel()
 
 			if err != nil {
@@ -122,21 +159,20 @@ func (s *Server) HandleLogStreamWebSocket(c echo.Context) error {
 	instanceID := c.Param("id")
 
 	// Authenticate
-	token := c.QueryParam("token")
-	userID := "demo"
-	if token != "" && token != "cm_demo" {
-		if claims, err := s.validateJWT(token); err == nil {
-			userID = claims.UserID
-		}
+	userID, err := s.queryUserID(c)
+	if err != nil {
+		return err
 	}
 
-	// Verify instance ownership
+	// Verify the user may access the instance
 	instance, err := s.db.GetInstanceByID(instanceID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
 	}
-	if instance.OwnerID != userID && userID != "demo" {
-		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	if userID != "demo" {
+		if err := s.instanceAccess(userID, instance, accessRead); err != nil {
+			return err
+		}
 	}
 
 	// Upgrade connection
@@ -146,6 +182,43 @@ func (s *Server) HandleLogStreamWebSocket(c echo.Context) error {
 		return err
 	}
 	defer conn.Close()
+	s.metrics.wsSessions.add(1, "logs")
+	defer s.metrics.wsSessions.add(-1, "logs")
+
+	// Stream logs
+	ctx, cancel := context.WithCancel(detachedContext(c))
+	defer cancel()
+
+	// Through the instance's agent when it is connected
+	if replies, done, err := s.agents.request(instance.ID, agentMessage{Type: "logs", Tail: 100, Follow: true}); err == nil {
+		defer done()
+		go func() {
+			// Stop streaming when the client goes away
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case reply, ok := <-replies:
+				if !ok || reply.Type != "log" {
+					if ok && reply.Error != "" {
+						_ = conn.WriteJSON(LogLine{Timestamp: time.Now().Format(time.RFC3339), Level: "error", Message: reply.Error})
+					}
+					_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return nil
+				}
+				if err := conn.WriteJSON(parseLogLine(reply.Line)); err != nil {
+					return nil
+				}
+			}
+		}
+	}
 
 	// Get provider for this instance
 	provider, err := s.providers.Get(providers.ProviderType(instance.Provider))
@@ -153,11 +226,12 @@ func (s *Server) HandleLogStreamWebSocket(c echo.Context) error {
 		return nil
 	}
 
-	// Stream logs
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	logChan, err := provider.StreamLogs(ctx, instance.ProviderID)
+	var logChan <-chan string
+	err = s.callProvider(ctx, provider, "stream_logs", func(ctx context.Context) error {
+		var err error
+		logChan, err = provider.StreamLogs(ctx, instance.ProviderID)
+		return err
+	})
 	if err != nil {
 		// Send error and fallback to simulated logs
 		_ = conn.WriteJSON(LogLine{
@@ -182,10 +256,13 @@ func (s *Server) HandleLogStreamWebSocket(c echo.Context) error {
 	for line := range logChan {
 		logLine := parseLogLine(line)
 		if err := conn.WriteJSON(logLine); err != nil {
-			break
+			return nil
 		}
 	}
 
+	// The stream ended, e.g. because the container exited
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
 	return nil
 }
 
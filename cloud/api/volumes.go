@@ -0,0 +1,876 @@
+// Package api provides volumes that keep work across instances, and their
+// snapshots
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+)
+
+const (
+	// defaultVolumeSizeGB is the size of volumes created without one
+	defaultVolumeSizeGB = 10
+	// maxVolumeSizeGB bounds volume sizes
+	maxVolumeSizeGB = 10240
+
+	// resticImage runs restic on instances for snapshots of volumes whose
+	// provider can't snapshot them
+	resticImage = "restic/restic:0.17.3"
+
+	// jobPollInterval is how often a job running on an instance is checked,
+	// and volumeJobTimeout how long a snapshot or restore may take
+	jobPollInterval  = 10 * time.Second
+	volumeJobTimeout = 6 * time.Hour
+)
+
+var (
+	// volumeNamePattern is what volume names may look like; they name mount
+	// points
+	volumeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+	// mountPathPattern is what mount paths may contain
+	mountPathPattern = regexp.MustCompile(`^(/[A-Za-z0-9_.-]+)+$`)
+)
+
+// systemDirs are top-level directories volumes may not be mounted in
+var systemDirs = map[string]bool{
+	"bin": true, "boot": true, "dev": true, "etc": true, "lib": true, "lib32": true, "lib64": true,
+	"proc": true, "root": true, "run": true, "sbin": true, "sys": true, "tmp": true, "usr": true, "var": true,
+}
+
+// validMountPath checks where a volume is to be mounted on an instance
+func validMountPath(p string) error {
+	first, _, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	if !mountPathPattern.MatchString(p) || path.Clean(p) != p || systemDirs[first] {
+		return errors.New("mount_path must be an absolute path outside system directories, e.g. /mnt/data")
+	}
+	return nil
+}
+
+// requireVolume loads the volume named by :id into the context as "volume"
+// if the signed-in user has the access to it
+func (s *Server) requireVolume(need access) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			volume, err := s.db.GetVolumeByID(c.Param("id"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, "volume not found")
+			}
+			if err := s.resourceAccess(c.Get("user_id").(string), volume.OwnerID, volume.TeamID, need); err != nil {
+				if err == errNotFound {
+					return echo.NewHTTPError(http.StatusNotFound, "volume not found")
+				}
+				return err
+			}
+			c.Set("volume", volume)
+			return next(c)
+		}
+	}
+}
+
+// snapshotAccess loads a snapshot if the signed-in user has the access to it
+func (s *Server) snapshotAccess(c echo.Context, id string, need access) (*db.VolumeSnapshot, error) {
+	snapshot, err := s.db.GetVolumeSnapshotByID(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "snapshot not found")
+	}
+	if err := s.resourceAccess(c.Get("user_id").(string), snapshot.OwnerID, snapshot.TeamID, need); err != nil {
+		if err == errNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "snapshot not found")
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// volumeProvider returns a provider that has volumes
+func (s *Server) volumeProvider(name string) (providers.Provider, providers.VolumeProvider, error) {
+	provider, err := s.providers.Get(providers.ProviderType(name))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "unsupported provider: "+name)
+	}
+	volumes, ok := provider.(providers.VolumeProvider)
+	if !ok {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, provider.DisplayName()+" has no volumes")
+	}
+	return provider, volumes, nil
+}
+
+// listVolumes lists the signed-in user's volumes and those of their teams
+func (s *Server) listVolumes(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	teamIDs, err := s.db.TeamIDsByUser(userID, db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	volumes, err := s.db.ListVolumesForUser(userID, teamIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list volumes")
+	}
+	return c.JSON(http.StatusOK, volumes)
+}
+
+func (s *Server) getVolume(c echo.Context) error {
+	return c.JSON(http.StatusOK, c.Get("volume"))
+}
+
+// createVolume creates a volume, empty or from a provider snapshot, and
+// attaches it to an instance if one is given
+func (s *Server) createVolume(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	var req struct {
+		Name       string  `json:"name"`
+		Provider   string  `json:"provider"`
+		Region     string  `json:"region"`
+		SizeGB     int     `json:"size_gb"`
+		TeamID     *string `json:"team_id"`     // Creates it in this team
+		SnapshotID string  `json:"snapshot_id"` // Restores this provider snapshot
+		InstanceID string  `json:"instance_id"` // Creates it next to this instance and attaches it
+		MountPath  string  `json:"mount_path"`  // Where to mount it on the instance
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if !volumeNamePattern.MatchString(req.Name) {
+		return echo.NewHTTPError(http.StatusBadRequest, "name must be lowercase letters, digits, '.', '_' or '-'")
+	}
+	teamID, err := s.teamScope(c, req.TeamID, db.RoleMember)
+	if err != nil {
+		return err
+	}
+	if req.SizeGB == 0 {
+		req.SizeGB = defaultVolumeSizeGB
+	}
+	if req.SizeGB < 1 || req.SizeGB > maxVolumeSizeGB {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("size_gb must be between 1 and %d", maxVolumeSizeGB))
+	}
+
+	config := providers.VolumeConfig{Name: req.Name, Region: req.Region, SizeGB: req.SizeGB, OwnerID: userID}
+	if req.SnapshotID != "" {
+		snapshot, err := s.snapshotAccess(c, req.SnapshotID, accessUse)
+		if err != nil {
+			return err
+		}
+		if snapshot.Method != "provider" || snapshot.Status != "completed" {
+			return echo.NewHTTPError(http.StatusConflict, "only completed provider snapshots make volumes; restore restic snapshots into a volume instead")
+		}
+		if req.Provider == "" {
+			req.Provider = snapshot.Provider
+		}
+		if config.Region == "" {
+			config.Region = snapshot.Region
+		}
+		if req.Provider != snapshot.Provider || config.Region != snapshot.Region {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("the snapshot is in %s %s", snapshot.Provider, snapshot.Region))
+		}
+		config.SnapshotID = snapshot.ProviderID
+		config.SizeGB = max(config.SizeGB, snapshot.SizeGB)
+	}
+	var instance *db.Instance
+	if req.InstanceID != "" {
+		if instance, err = s.db.GetInstanceByID(req.InstanceID); err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+		}
+		if err := s.instanceAccess(userID, instance, accessUse); err != nil {
+			return err
+		}
+		if req.Provider == "" {
+			req.Provider = instance.Provider
+		}
+		if config.Region == "" {
+			config.Region = instance.Region
+		}
+		config.Zone = instance.Zone
+		if err := volumeFits(req.Provider, config.Region, instance); err != nil {
+			return err
+		}
+	}
+	if req.MountPath != "" {
+		if err := validMountPath(req.MountPath); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+	provider, volumes, err := s.volumeProvider(req.Provider)
+	if err != nil {
+		return err
+	}
+
+	var created *providers.Volume
+	err = s.callProvider(c.Request().Context(), provider, "create_volume", func(ctx context.Context) error {
+		var err error
+		created, err = volumes.CreateVolume(ctx, config)
+		return err
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	now := time.Now().UTC()
+	volume := &db.Volume{
+		ID:         "vol-" + uuid.New().String()[:8],
+		OwnerID:    userID,
+		TeamID:     teamID,
+		Name:       req.Name,
+		Provider:   req.Provider,
+		Region:     created.Region,
+		Zone:       created.Zone,
+		SizeGB:     created.SizeGB,
+		ProviderID: created.ID,
+		Status:     "available",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if volume.SizeGB == 0 {
+		volume.SizeGB = config.SizeGB
+	}
+	if err := s.db.CreateVolume(volume); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create volume")
+	}
+
+	if instance != nil {
+		if err := s.attachVolume(c.Request().Context(), volume, instance, req.MountPath); err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("volume %s was created, but attaching it failed: %v", volume.ID, err))
+		}
+	}
+	return c.JSON(http.StatusCreated, volume)
+}
+
+// volumeFits checks that a volume of a provider and region can be attached
+// to an instance
+func volumeFits(provider, region string, instance *db.Instance) error {
+	if instance.ProviderID == "" {
+		return echo.NewHTTPError(http.StatusConflict, "the instance isn't created yet")
+	}
+	if provider != instance.Provider || (region != "" && instance.Region != "" && region != instance.Region) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("the instance is in %s %s; volumes attach within a provider's region", instance.Provider, instance.Region))
+	}
+	return nil
+}
+
+// attachVolumeHandler attaches a volume to an instance and mounts it there
+func (s *Server) attachVolumeHandler(c echo.Context) error {
+	volume := c.Get("volume").(*db.Volume)
+	var req struct {
+		InstanceID string `json:"instance_id"`
+		MountPath  string `json:"mount_path"` // Defaults to /mnt/volumes/<name>
+	}
+	if err := c.Bind(&req); err != nil || req.InstanceID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "instance_id is required")
+	}
+	if volume.InstanceID != nil {
+		return echo.NewHTTPError(http.StatusConflict, "the volume is attached to "+*volume.InstanceID+"; detach it first")
+	}
+	instance, err := s.db.GetInstanceByID(req.InstanceID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Instance not found")
+	}
+	if err := s.instanceAccess(c.Get("user_id").(string), instance, accessUse); err != nil {
+		return err
+	}
+	if err := volumeFits(volume.Provider, volume.Region, instance); err != nil {
+		return err
+	}
+	if req.MountPath != "" {
+		if err := validMountPath(req.MountPath); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+	if err := s.attachVolume(c.Request().Context(), volume, instance, req.MountPath); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, volume)
+}
+
+// attachVolume attaches a volume at its provider and mounts it if the
+// instance's agent is connected; otherwise the agent mounts it when it
+// connects
+func (s *Server) attachVolume(ctx context.Context, volume *db.Volume, instance *db.Instance, mountPath string) error {
+	provider, volumes, err := s.volumeProvider(volume.Provider)
+	if err != nil {
+		return err
+	}
+	var attached *providers.Volume
+	err = s.callProvider(ctx, provider, "attach_volume", func(ctx context.Context) error {
+		var err error
+		attached, err = volumes.AttachVolume(ctx, volume.ProviderID, instance.ProviderID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if mountPath == "" {
+		mountPath = "/mnt/volumes/" + volume.Name
+	}
+	volume.InstanceID = &instance.ID
+	volume.Device = attached.Device
+	volume.MountPath = mountPath
+	volume.Mounted = false
+	volume.Status = "in-use"
+	volume.StatusReason = ""
+	volume.UpdatedAt = time.Now().UTC()
+	if err := s.db.UpdateVolume(volume); err != nil {
+		return err
+	}
+	s.mountVolume(ctx, volume)
+	return nil
+}
+
+// mountVolume mounts an attached volume through its instance's agent and
+// records the outcome
+func (s *Server) mountVolume(ctx context.Context, volume *db.Volume) {
+	if _, ok := s.agents.get(*volume.InstanceID); !ok {
+		volume.StatusReason = "mounted when the instance's agent connects"
+	} else if _, err := s.rootExec(ctx, *volume.InstanceID, mountScript(volume.Device, volume.MountPath)); err != nil {
+		volume.StatusReason = "failed to mount: " + err.Error()
+	} else {
+		volume.Mounted = true
+		volume.StatusReason = ""
+	}
+	volume.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdateVolume(volume)
+}
+
+// mountAttachedVolumes mounts the volumes attached to an instance whose
+// agent just connected
+func (s *Server) mountAttachedVolumes(ctx context.Context, instanceID string) {
+	volumes, err := s.db.ListVolumesByInstance(instanceID)
+	if err != nil {
+		s.log.Error("failed to list attached volumes", "instance_id", instanceID, "error", err)
+		return
+	}
+	for i := range volumes {
+		if volumes[i].Device != "" {
+			// Also after a reboot, which keeps the mount only if fstab did
+			s.mountVolume(ctx, &volumes[i])
+		}
+	}
+}
+
+// detachVolumeHandler unmounts a volume and detaches it from its instance
+func (s *Server) detachVolumeHandler(c echo.Context) error {
+	volume := c.Get("volume").(*db.Volume)
+	if volume.InstanceID == nil {
+		return echo.NewHTTPError(http.StatusConflict, "the volume isn't attached")
+	}
+	if volume.Status == "snapshotting" || volume.Status == "restoring" {
+		return echo.NewHTTPError(http.StatusConflict, "the volume is "+volume.Status+"; try again when it's done")
+	}
+	if _, ok := s.agents.get(*volume.InstanceID); ok {
+		if _, err := s.rootExec(c.Request().Context(), *volume.InstanceID, unmountScript(volume.MountPath)); err != nil {
+			return echo.NewHTTPError(http.StatusConflict, "failed to unmount the volume: "+err.Error())
+		}
+	}
+	if err := s.detachVolume(c.Request().Context(), volume); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, volume)
+}
+
+// detachVolume detaches a volume at its provider and records it available
+func (s *Server) detachVolume(ctx context.Context, volume *db.Volume) error {
+	provider, volumes, err := s.volumeProvider(volume.Provider)
+	if err != nil {
+		return err
+	}
+	err = s.callProvider(ctx, provider, "detach_volume", func(ctx context.Context) error {
+		return volumes.DetachVolume(ctx, volume.ProviderID)
+	})
+	if err != nil {
+		return err
+	}
+	volume.InstanceID = nil
+	volume.Device = ""
+	volume.Mounted = false
+	volume.Status = "available"
+	volume.StatusReason = ""
+	volume.UpdatedAt = time.Now().UTC()
+	return s.db.UpdateVolume(volume)
+}
+
+// releaseVolumes detaches the volumes of an instance being deleted, so
+// they can be attached elsewhere
+func (s *Server) releaseVolumes(ctx context.Context, instance *db.Instance) {
+	volumes, err := s.db.ListVolumesByInstance(instance.ID)
+	if err != nil {
+		s.log.Error("failed to list attached volumes", "instance_id", instance.ID, "error", err)
+		return
+	}
+	for i := range volumes {
+		if err := s.detachVolume(ctx, &volumes[i]); err != nil {
+			s.log.Error("failed to detach volume", "volume_id", volumes[i].ID, "instance_id", instance.ID, "error", err)
+			volumes[i].InstanceID = nil
+			volumes[i].Mounted = false
+			volumes[i].Status = "error"
+			volumes[i].StatusReason = "its instance was deleted, but detaching failed: " + err.Error()
+			_ = s.db.UpdateVolume(&volumes[i])
+		}
+	}
+}
+
+// deleteVolume deletes a detached volume; its snapshots are kept
+func (s *Server) deleteVolume(c echo.Context) error {
+	volume := c.Get("volume").(*db.Volume)
+	if volume.InstanceID != nil {
+		return echo.NewHTTPError(http.StatusConflict, "the volume is attached to "+*volume.InstanceID+"; detach it first")
+	}
+	provider, volumes, err := s.volumeProvider(volume.Provider)
+	if err != nil {
+		return err
+	}
+	err = s.callProvider(c.Request().Context(), provider, "delete_volume", func(ctx context.Context) error {
+		return volumes.DeleteVolume(ctx, volume.ProviderID)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	if err := s.db.DeleteVolume(volume.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete volume")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listSnapshots lists the snapshots the signed-in user can see, or
+// ?volume_id='s
+func (s *Server) listSnapshots(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	teamIDs, err := s.db.TeamIDsByUser(userID, db.RoleViewer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+	}
+	snapshots, err := s.db.ListVolumeSnapshotsForUser(userID, teamIDs, c.QueryParam("volume_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list snapshots")
+	}
+	return c.JSON(http.StatusOK, snapshots)
+}
+
+// snapshotVolume snapshots a volume at its provider, or else with restic
+// from its instance into the snapshot repository
+func (s *Server) snapshotVolume(c echo.Context) error {
+	volume := c.Get("volume").(*db.Volume)
+	var req struct {
+		Description string `json:"description"`
+	}
+	if err := c.Bind(&req); err != nil || len(req.Description) > 255 {
+		return echo.NewHTTPError(http.StatusBadRequest, "description is up to 255 characters")
+	}
+	provider, _, err := s.volumeProvider(volume.Provider)
+	if err != nil {
+		return err
+	}
+	snapshot := &db.VolumeSnapshot{
+		ID:          "snap-" + uuid.New().String()[:8],
+		VolumeID:    volume.ID,
+		OwnerID:     volume.OwnerID,
+		TeamID:      volume.TeamID,
+		Provider:    volume.Provider,
+		Region:      volume.Region,
+		SizeGB:      volume.SizeGB,
+		Description: req.Description,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if snapshotter, ok := provider.(providers.VolumeSnapshotter); ok {
+		err := s.callProvider(c.Request().Context(), provider, "snapshot_volume", func(ctx context.Context) error {
+			var err error
+			snapshot.ProviderID, err = snapshotter.SnapshotVolume(ctx, volume.ProviderID, "cm "+volume.ID+" "+req.Description)
+			return err
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+		}
+		snapshot.Method, snapshot.Status = "provider", "completed"
+		if err := s.db.CreateVolumeSnapshot(snapshot); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record snapshot")
+		}
+		return c.JSON(http.StatusCreated, snapshot)
+	}
+
+	if err := s.resticReady(volume); err != nil {
+		return err
+	}
+	snapshot.Method, snapshot.Status = "restic", "pending"
+	if err := s.db.CreateVolumeSnapshot(snapshot); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record snapshot")
+	}
+	volume.Status = "snapshotting"
+	volume.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdateVolume(volume)
+	go s.resticBackup(detachedContext(c), volume, snapshot)
+	return c.JSON(http.StatusAccepted, snapshot)
+}
+
+// resticReady checks that restic can run for a volume now
+func (s *Server) resticReady(volume *db.Volume) error {
+	if s.config.SnapshotRepository == "" {
+		return echo.NewHTTPError(http.StatusConflict, "this provider can't snapshot volumes and no snapshot repository is configured")
+	}
+	if s.config.SnapshotRoleARN == "" {
+		return echo.NewHTTPError(http.StatusConflict, "the snapshot repository has no SNAPSHOT_ROLE_ARN to issue volume credentials with")
+	}
+	if volume.InstanceID == nil || !volume.Mounted {
+		return echo.NewHTTPError(http.StatusConflict, "attach the volume to a running instance first; it's snapshotted from there")
+	}
+	if volume.Status != "in-use" {
+		return echo.NewHTTPError(http.StatusConflict, "the volume is "+volume.Status+"; try again when it's done")
+	}
+	if _, ok := s.agents.get(*volume.InstanceID); !ok {
+		return agentError(errAgentNotConnected)
+	}
+	return nil
+}
+
+// resticBackup snapshots a volume with restic and records the outcome
+func (s *Server) resticBackup(ctx context.Context, volume *db.Volume, snapshot *db.VolumeSnapshot) {
+	var stdout string
+	script, err := s.resticScript(ctx, volume, "restic backup /data --host cm --tag "+volume.ID+" --json --quiet")
+	if err == nil {
+		stdout, err = s.volumeJob(ctx, *volume.InstanceID, snapshot.ID, script)
+	}
+	if err == nil {
+		snapshot.ProviderID, err = resticSnapshotID(stdout)
+	}
+	if err != nil {
+		s.log.Error("volume snapshot failed", "volume_id", volume.ID, "snapshot_id", snapshot.ID, "error", err)
+		snapshot.Status, snapshot.StatusReason = "error", truncate(err.Error(), 500)
+	} else {
+		snapshot.Status = "completed"
+	}
+	_ = s.db.UpdateVolumeSnapshot(snapshot)
+	s.finishVolumeJob(volume.ID)
+}
+
+// finishVolumeJob marks a volume in use again after a snapshot or restore
+func (s *Server) finishVolumeJob(volumeID string) {
+	volume, err := s.db.GetVolumeByID(volumeID)
+	if err != nil {
+		return
+	}
+	volume.Status = "in-use"
+	volume.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdateVolume(volume)
+}
+
+// resticSnapshotID finds the snapshot ID in restic backup's JSON summary
+func resticSnapshotID(stdout string) (string, error) {
+	for _, line := range strings.Split(stdout, "\n") {
+		var msg struct {
+			MessageType string `json:"message_type"`
+			SnapshotID  string `json:"snapshot_id"`
+		}
+		if json.Unmarshal([]byte(line), &msg) == nil && msg.MessageType == "summary" && msg.SnapshotID != "" {
+			return msg.SnapshotID, nil
+		}
+	}
+	return "", errors.New("restic reported no snapshot")
+}
+
+// restoreVolume restores a restic snapshot of a volume into it, over its
+// current files
+func (s *Server) restoreVolume(c echo.Context) error {
+	volume := c.Get("volume").(*db.Volume)
+	var req struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := c.Bind(&req); err != nil || req.SnapshotID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "snapshot_id is required")
+	}
+	snapshot, err := s.snapshotAccess(c, req.SnapshotID, accessRead)
+	if err != nil {
+		return err
+	}
+	if snapshot.Method != "restic" {
+		return echo.NewHTTPError(http.StatusConflict, "provider snapshots are restored by creating a volume from them")
+	}
+	if snapshot.VolumeID != volume.ID || snapshot.Status != "completed" {
+		return echo.NewHTTPError(http.StatusConflict, "only completed snapshots of this volume can be restored into it")
+	}
+	if err := s.resticReady(volume); err != nil {
+		return err
+	}
+
+	volume.Status = "restoring"
+	volume.StatusReason = ""
+	volume.UpdatedAt = time.Now().UTC()
+	_ = s.db.UpdateVolume(volume)
+	go func(ctx context.Context) {
+		script, err := s.resticScript(ctx, volume, "restic restore "+snapshot.ProviderID+" --target /")
+		if err == nil {
+			_, err = s.volumeJob(ctx, *volume.InstanceID, "restore-"+snapshot.ID, script)
+		}
+		s.finishVolumeJob(volume.ID)
+		if err != nil {
+			s.log.Error("volume restore failed", "volume_id", volume.ID, "snapshot_id", snapshot.ID, "error", err)
+			if v, getErr := s.db.GetVolumeByID(volume.ID); getErr == nil {
+				v.StatusReason = truncate("restoring "+snapshot.ID+" failed: "+err.Error(), 255)
+				_ = s.db.UpdateVolume(v)
+			}
+		}
+	}(detachedContext(c))
+	return c.JSON(http.StatusAccepted, volume)
+}
+
+// deleteSnapshot deletes a snapshot at its provider or from the restic
+// repository
+func (s *Server) deleteSnapshot(c echo.Context) error {
+	snapshot, err := s.snapshotAccess(c, c.Param("id"), accessManage)
+	if err != nil {
+		return err
+	}
+	if snapshot.Status == "pending" {
+		return echo.NewHTTPError(http.StatusConflict, "the snapshot is still being taken")
+	}
+
+	switch {
+	case snapshot.ProviderID == "":
+		// A failed snapshot left nothing behind
+	case snapshot.Method == "provider":
+		provider, err := s.providers.Get(providers.ProviderType(snapshot.Provider))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		snapshotter, ok := provider.(providers.VolumeSnapshotter)
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, provider.DisplayName()+" has no snapshots")
+		}
+		err = s.callProvider(c.Request().Context(), provider, "delete_snapshot", func(ctx context.Context) error {
+			return snapshotter.DeleteSnapshot(ctx, snapshot.ProviderID)
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+		}
+	default:
+		// Restic snapshots are forgotten from the volume's instance; those of
+		// deleted volumes stay in the repository until it's pruned
+		if volume, err := s.db.GetVolumeByID(snapshot.VolumeID); err == nil {
+			if err := s.resticReady(volume); err != nil {
+				return err
+			}
+			ctx := c.Request().Context()
+			script, err := s.resticScript(ctx, volume, "restic forget "+snapshot.ProviderID+" --prune")
+			if err == nil {
+				_, err = s.volumeJob(ctx, *volume.InstanceID, "forget-"+snapshot.ID, script)
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+			}
+		}
+	}
+	if err := s.db.DeleteVolumeSnapshot(snapshot.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete snapshot")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// resticScript returns a shell script that runs restic commands against a
+// volume's repository, creating it first if needed. Each volume has its own
+// repository, password and credentials, so an instance can reach only its
+// volume's snapshots.
+func (s *Server) resticScript(ctx context.Context, volume *db.Volume, commands string) (string, error) {
+	creds, err := s.snapshotCredentials(ctx, volume)
+	if err != nil {
+		return "", fmt.Errorf("failed to issue snapshot credentials: %w", err)
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.JWTSecret))
+	mac.Write([]byte("volume-snapshots:" + volume.ID))
+	env := []string{
+		"RESTIC_REPOSITORY=" + shellQuote(strings.TrimSuffix(s.config.SnapshotRepository, "/")+"/"+volume.ID),
+		"RESTIC_PASSWORD=" + shellQuote(hex.EncodeToString(mac.Sum(nil))),
+		"AWS_ACCESS_KEY_ID=" + shellQuote(aws.ToString(creds.AccessKeyId)),
+		"AWS_SECRET_ACCESS_KEY=" + shellQuote(aws.ToString(creds.SecretAccessKey)),
+		"AWS_SESSION_TOKEN=" + shellQuote(aws.ToString(creds.SessionToken)),
+	}
+	return "set -e\nexport " + strings.Join(env, " ") + "\n" +
+		"restic() { docker run --rm -e RESTIC_REPOSITORY -e RESTIC_PASSWORD -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY -e AWS_SESSION_TOKEN " +
+		"-v " + shellQuote(volume.MountPath) + ":/data " + resticImage + " \"$@\"; }\n" +
+		"restic cat config >/dev/null 2>&1 || restic init >/dev/null\n" +
+		commands + "\n", nil
+}
+
+// snapshotCredentials assumes the snapshot role with a session policy that
+// allows only a volume's repository, for as long as a volume job may run
+func (s *Server) snapshotCredentials(ctx context.Context, volume *db.Volume) (*ststypes.Credentials, error) {
+	if s.config.SnapshotRoleARN == "" {
+		return nil, errors.New("no SNAPSHOT_ROLE_ARN is configured")
+	}
+	bucket, prefix, err := snapshotBucket(s.config.SnapshotRepository)
+	if err != nil {
+		return nil, err
+	}
+	region := s.config.SnapshotRegion
+	if region == "" {
+		region = "us-east-1"
+	}
+	options := sts.Options{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(s.config.SnapshotAccessKeyID, s.config.SnapshotSecretAccessKey, ""),
+	}
+	if s.config.SnapshotSTSEndpoint != "" {
+		options.BaseEndpoint = aws.String(s.config.SnapshotSTSEndpoint)
+	}
+	out, err := sts.New(options).AssumeRole(ctx, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(s.config.SnapshotRoleARN),
+		RoleSessionName: aws.String("cm-volume-" + volume.ID),
+		Policy:          aws.String(snapshotPolicy(bucket, path.Join(prefix, volume.ID))),
+		DurationSeconds: aws.Int32(int32(volumeJobTimeout / time.Second)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Credentials == nil {
+		return nil, errors.New("STS returned no credentials")
+	}
+	return out.Credentials, nil
+}
+
+// snapshotBucket returns the S3 bucket and key prefix of a restic S3
+// repository such as s3:s3.amazonaws.com/bucket/cm or
+// s3:https://minio:9000/bucket
+func snapshotBucket(repository string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(repository, "s3:")
+	if !ok {
+		return "", "", errors.New("the snapshot repository must be an S3 repository, s3:<host>/<bucket>[/<prefix>]")
+	}
+	if _, after, ok := strings.Cut(rest, "://"); ok {
+		rest = after
+	}
+	parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return "", "", errors.New("the snapshot repository names no bucket: " + repository)
+	}
+	if len(parts) == 3 {
+		prefix = strings.Trim(parts[2], "/")
+	}
+	return parts[1], prefix, nil
+}
+
+// snapshotPolicy returns an IAM session policy that allows restic to use
+// the repository under one key prefix of a bucket, and nothing else
+func snapshotPolicy(bucket, prefix string) string {
+	type statement struct {
+		Effect    string      `json:"Effect"`
+		Action    []string    `json:"Action"`
+		Resource  string      `json:"Resource"`
+		Condition interface{} `json:"Condition,omitempty"`
+	}
+	policy := struct {
+		Version   string      `json:"Version"`
+		Statement []statement `json:"Statement"`
+	}{
+		Version: "2012-10-17",
+		Statement: []statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject"}, Resource: "arn:aws:s3:::" + bucket + "/" + prefix + "/*"},
+			{Effect: "Allow", Action: []string{"s3:ListBucket"}, Resource: "arn:aws:s3:::" + bucket,
+				Condition: map[string]map[string][]string{"StringLike": {"s3:prefix": {prefix, prefix + "/*"}}}},
+			{Effect: "Allow", Action: []string{"s3:GetBucketLocation"}, Resource: "arn:aws:s3:::" + bucket},
+		},
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// mountScript mounts a volume's device, formatting it if it's blank, and
+// keeps it mounted across reboots
+func mountScript(device, mountPath string) string {
+	return "set -e\ndev=" + shellQuote(device) + " dir=" + shellQuote(mountPath) + `
+i=0
+while [ ! -b "$dev" ]; do
+  i=$((i+1)); [ "$i" -le 60 ] || { echo "device $dev didn't appear" >&2; exit 1; }
+  sleep 1
+done
+blkid "$dev" >/dev/null 2>&1 || mkfs.ext4 -q "$dev"
+mkdir -p "$dir"
+mountpoint -q "$dir" || mount "$dev" "$dir"
+grep -qs " $dir " /etc/fstab || echo "$dev $dir ext4 defaults,nofail 0 2" >> /etc/fstab
+`
+}
+
+// unmountScript unmounts a volume and forgets its fstab entry
+func unmountScript(mountPath string) string {
+	return "set -e\ndir=" + shellQuote(mountPath) + `
+sed -i "\| $dir |d" /etc/fstab
+! mountpoint -q "$dir" || umount "$dir"
+`
+}
+
+// rootExec runs a script as root on an instance through its agent and
+// returns its output
+func (s *Server) rootExec(ctx context.Context, instanceID, script string) (string, error) {
+	reply, err := s.agents.call(ctx, instanceID, agentMessage{
+		Type: "exec",
+		// The agent runs as root or as a user with passwordless sudo
+		Command: []string{"sh", "-c", `if [ "$(id -u)" = 0 ]; then exec sh -c "$1"; else exec sudo -n sh -c "$1"; fi`, "cm-root", script},
+	})
+	if err != nil {
+		return "", err
+	}
+	if reply.ExitCode != 0 {
+		msg := strings.TrimSpace(reply.Stderr)
+		if i := strings.LastIndex(msg, "\n"); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return "", fmt.Errorf("exit status %d: %s", reply.ExitCode, msg)
+	}
+	return reply.Stdout, nil
+}
+
+// volumeJob runs a snapshot or restore script on an instance as a job
+func (s *Server) volumeJob(ctx context.Context, instanceID, jobID, script string) (string, error) {
+	return s.instanceJob(ctx, instanceID, "/var/lib/cm/volume-jobs/"+jobID, script, volumeJobTimeout)
+}
+
+// instanceJob runs a script as root on an instance in the background, with
+// dir for its output, so it isn't bound by the agent's exec timeout, and
+// polls until it finishes. A restarting agent only delays the polling.
+func (s *Server) instanceJob(ctx context.Context, instanceID, dir, script string, timeout time.Duration) (string, error) {
+	start := "set -e\nd=" + shellQuote(dir) + "\nrm -rf \"$d\"; mkdir -p \"$d\"\nprintf '%s' " + shellQuote(script) + " > \"$d/script\"\n" +
+		`setsid sh -c 'sh "$1/script" > "$1/out" 2> "$1/err"; echo $? > "$1/exit"' cm-job "$d" < /dev/null > /dev/null 2>&1 &` + "\n"
+	if _, err := s.rootExec(ctx, instanceID, start); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(timeout)
+	poll := "d=" + shellQuote(dir) + "\n[ -f \"$d/exit\" ] || exit 0\ncode=$(cat \"$d/exit\"); echo done; cat \"$d/out\"; tail -c 2000 \"$d/err\" >&2; rm -rf \"$d\"; exit \"$code\"\n"
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(jobPollInterval):
+		}
+		out, err := s.rootExec(ctx, instanceID, poll)
+		switch {
+		case errors.Is(err, errAgentNotConnected):
+		case err != nil:
+			return "", err
+		case strings.HasPrefix(out, "done\n"):
+			return strings.TrimPrefix(out, "done\n"), nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("still running after %s", timeout)
+		}
+	}
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+func TestSnapshotBucket(t *testing.T) {
+	tests := []struct {
+		repository     string
+		bucket, prefix string
+		wantErr        bool
+	}{
+		{"s3:s3.amazonaws.com/snapshots/cm", "snapshots", "cm", false},
+		{"s3:s3.eu-west-1.amazonaws.com/snapshots", "snapshots", "", false},
+		{"s3:https://minio.internal:9000/snapshots/a/b/", "snapshots", "a/b", false},
+		{"s3:s3.amazonaws.com/", "", "", true},
+		{"s3:s3.amazonaws.com", "", "", true},
+		{"b2:snapshots:cm", "", "", true},
+		{"/srv/restic", "", "", true},
+	}
+	for _, tt := range tests {
+		bucket, prefix, err := snapshotBucket(tt.repository)
+		if (err != nil) != tt.wantErr || bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("snapshotBucket(%q) = %q, %q, %v, want %q, %q", tt.repository, bucket, prefix, err, tt.bucket, tt.prefix)
+		}
+	}
+}
+
+func TestResticScriptCredentials(t *testing.T) {
+	const (
+		operatorKeyID  = "AKIAOPERATOR"
+		operatorSecret = "operator-secret-never-on-instances"
+	)
+	var policy, roleARN string
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRole" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+operatorKeyID+"/") {
+			http.Error(w, "not signed by the operator key", http.StatusForbidden)
+			return
+		}
+		policy, roleARN = r.Form.Get("Policy"), r.Form.Get("RoleArn")
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>
+<Credentials><AccessKeyId>ASIAVOLUME</AccessKeyId><SecretAccessKey>volume-secret</SecretAccessKey><SessionToken>volume-session</SessionToken><Expiration>2026-10-16T18:00:00Z</Expiration></Credentials>
+</AssumeRoleResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></AssumeRoleResponse>`))
+	}))
+	defer sts.Close()
+
+	s := &Server{config: Config{
+		JWTSecret:               "test",
+		SnapshotRepository:      "s3:s3.amazonaws.com/snapshots/cm",
+		SnapshotAccessKeyID:     operatorKeyID,
+		SnapshotSecretAccessKey: operatorSecret,
+		SnapshotRoleARN:         "arn:aws:iam::123456789012:role/cm-snapshots",
+		SnapshotSTSEndpoint:     sts.URL,
+	}}
+	volume := &db.Volume{ID: "vol-1", MountPath: "/mnt/volumes/data"}
+	script, err := s.resticScript(context.Background(), volume, "restic snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(script, operatorSecret) || strings.Contains(script, operatorKeyID) {
+		t.Errorf("script has the operator's credentials:\n%s", script)
+	}
+	for _, want := range []string{"AWS_ACCESS_KEY_ID='ASIAVOLUME'", "AWS_SECRET_ACCESS_KEY='volume-secret'", "AWS_SESSION_TOKEN='volume-session'", "-e AWS_SESSION_TOKEN"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script lacks %s:\n%s", want, script)
+		}
+	}
+	if roleARN != s.config.SnapshotRoleARN {
+		t.Errorf("assumed role %q, want %q", roleARN, s.config.SnapshotRoleARN)
+	}
+
+	// The credentials reach only the volume's repository
+	var doc struct {
+		Statement []struct {
+			Action    []string
+			Resource  string
+			Condition map[string]map[string][]string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		t.Fatalf("session policy %q: %v", policy, err)
+	}
+	for _, st := range doc.Statement {
+		switch st.Resource {
+		case "arn:aws:s3:::snapshots/cm/vol-1/*":
+		case "arn:aws:s3:::snapshots":
+			if st.Action[0] == "s3:ListBucket" && strings.Join(st.Condition["StringLike"]["s3:prefix"], ",") != "cm/vol-1,cm/vol-1/*" {
+				t.Errorf("listing isn't limited to the volume: %v", st.Condition)
+			}
+		default:
+			t.Errorf("session policy allows %v on %s", st.Action, st.Resource)
+		}
+	}
+
+	// Without the role there are no credentials to give
+	s.config.SnapshotRoleARN = ""
+	if _, err := s.resticScript(context.Background(), volume, "restic snapshots"); err == nil {
+		t.Error("script made without a role to assume")
+	}
+}
@@ -0,0 +1,694 @@
+// Package api provides webhooks and the events API for instance and budget
+// events
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// Event types
+const (
+	EventInstanceCreated = "instance.created" // Provisioning started
+	EventInstanceReady   = "instance.ready"   // Provisioned or started, and running
+	EventInstanceStopped = "instance.stopped" // Stopped by a user, an idle policy or a budget
+	EventBudgetExceeded  = "budget.exceeded"  // A month's spend reached the budget's limit
+)
+
+var eventTypes = map[string]bool{
+	EventInstanceCreated: true, EventInstanceReady: true, EventInstanceStopped: true, EventBudgetExceeded: true,
+}
+
+const (
+	// webhookDispatchInterval is how often due retries are delivered; new
+	// events are delivered right away
+	webhookDispatchInterval = 15 * time.Second
+	// webhookTimeout bounds each delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookBatchSize and webhookConcurrency bound a dispatch run
+	webhookBatchSize   = 100
+	webhookConcurrency = 8
+	// eventRetention is how long events and deliveries are kept
+	eventRetention = 30 * 24 * time.Hour
+
+	defaultEventsLimit = 100
+	maxEventsLimit     = 500
+	// webhookDeliveriesListed is how many recent deliveries are listed
+	webhookDeliveriesListed = 50
+)
+
+// webhookRetryDelays are the waits before each retry of a failed delivery;
+// a delivery fails for good after the last
+var webhookRetryDelays = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour,
+}
+
+// webhookHTTPClient delivers webhooks; redirects count as failures. It
+// never connects to the control plane's own network: the address is checked
+// as it's dialed, after DNS resolution, so a name that resolves to a public
+// address when the webhook is saved and a private one later (DNS rebinding)
+// is still refused. There's no proxy, which would dial on its behalf.
+var webhookHTTPClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: refusePrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// errPrivateAddress refuses a webhook URL, or a connection, to a loopback,
+// private, link-local or otherwise internal address
+var errPrivateAddress = errors.New("webhook URLs must not point to a private network address")
+
+// refusePrivateAddress is a net.Dialer Control hook that refuses to connect
+// to internal addresses
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// sharedAddressSpace is carrier-grade NAT, 100.64.0.0/10, which
+// net.IP.IsPrivate leaves out
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether an address is on the public internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// checkWebhookURL validates a webhook URL given as field: http or https,
+// and resolving only to public addresses
+func checkWebhookURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%s must be an http or https URL", field)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%s: can't resolve %s", field, u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("%s: %w", field, errPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// eventResponse is an event as the events API lists it and webhooks
+// receive it
+type eventResponse struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	UserID     string          `json:"user_id,omitempty"`
+	TeamID     *string         `json:"team_id,omitempty"`
+	InstanceID string          `json:"instance_id,omitempty"`
+	Data       json.RawMessage `json:"data"`
+	Text       string          `json:"text"` // Makes the payload a valid Slack or Mattermost message
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func newEventResponse(event *db.Event) eventResponse {
+	data := json.RawMessage(event.Data)
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	return eventResponse{
+		ID:         event.ID,
+		Type:       event.Type,
+		UserID:     event.UserID,
+		TeamID:     event.TeamID,
+		InstanceID: event.InstanceID,
+		Data:       data,
+		Text:       eventText(event.Type, data),
+		CreatedAt:  event.CreatedAt,
+	}
+}
+
+// eventText describes an event in a sentence
+func eventText(eventType string, raw json.RawMessage) string {
+	var data map[string]interface{}
+	_ = json.Unmarshal(raw, &data)
+	str := func(key string) string {
+		v, _ := data[key].(string)
+		return v
+	}
+	instance := fmt.Sprintf("Instance %s (%s)", str("name"), str("instance_id"))
+	switch eventType {
+	case EventInstanceCreated:
+		return fmt.Sprintf("%s is being created on %s", instance, str("provider"))
+	case EventInstanceReady:
+		if ip := str("public_ip"); ip != "" {
+			return fmt.Sprintf("%s is ready at %s", instance, ip)
+		}
+		return instance + " is ready"
+	case EventInstanceStopped:
+		if reason := str("reason"); reason != "" {
+			return instance + " " + reason
+		}
+		return instance + " stopped"
+	case EventBudgetExceeded:
+		spent, _ := data["spent"].(float64)
+		limit, _ := data["limit"].(float64)
+		return fmt.Sprintf("%s has spent $%.2f of its $%.2f monthly cloud budget for %s", str("owner"), spent, limit, str("period"))
+	case "ping":
+		return "Container-Maker webhook test"
+	}
+	return eventType
+}
+
+// emitEvent records an event about a user's, or a team's, resources and
+// queues it for their webhooks that subscribe to its type. Failures are
+// logged; they never fail what the event is about.
+func (s *Server) emitEvent(eventType, userID string, teamID *string, instanceID string, data map[string]interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		s.log.Error("events: failed to encode event", "type", eventType, "error", err)
+		return
+	}
+	event := &db.Event{
+		Type:       eventType,
+		UserID:     userID,
+		TeamID:     teamID,
+		InstanceID: instanceID,
+		Data:       string(body),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.db.CreateEvent(event); err != nil {
+		s.log.Error("events: failed to record event", "type", eventType, "error", err)
+		return
+	}
+
+	webhooks, err := s.db.ListWebhooks(userID, teamID)
+	if err != nil {
+		s.log.Error("events: failed to list webhooks", "event_id", event.ID, "error", err)
+		return
+	}
+	var deliveries []db.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Active || !subscribes(webhook.Events, eventType) {
+			continue
+		}
+		deliveries = append(deliveries, db.WebhookDelivery{
+			ID:            uuid.New().String(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     eventType,
+			Status:        "pending",
+			NextAttemptAt: &event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	if err := s.db.CreateWebhookDeliveries(deliveries); err != nil {
+		s.log.Error("events: failed to queue webhook deliveries", "event_id", event.ID, "error", err)
+		return
+	}
+	if len(deliveries) > 0 {
+		select {
+		case s.webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// emitInstanceEvent records an event about an instance
+func (s *Server) emitInstanceEvent(eventType string, inst *db.Instance, reason string) {
+	data := map[string]interface{}{
+		"instance_id":   inst.ID,
+		"name":          inst.Name,
+		"provider":      inst.Provider,
+		"region":        inst.Region,
+		"instance_type": inst.InstanceType,
+		"status":        inst.Status,
+		"public_ip":     inst.PublicIP,
+		"owner_id":      inst.OwnerID,
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	s.emitEvent(eventType, inst.OwnerID, inst.TeamID, inst.ID, data)
+}
+
+// subscribes reports whether comma-separated event types, every type if
+// empty, include one
+func subscribes(events, eventType string) bool {
+	if strings.TrimSpace(events) == "" {
+		return true
+	}
+	for _, e := range strings.Split(events, ",") {
+		if strings.TrimSpace(e) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhook returns the X-CM-Signature header for a payload: the time it
+// was signed and the hex HMAC-SHA256 of "<time>.<payload>"
+func signWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errEndpointStatus is a delivery the endpoint answered without a 2xx status
+var errEndpointStatus = errors.New("endpoint didn't accept the delivery")
+
+// postWebhook sends a signed payload to a webhook and returns the response
+// status; an error means the attempt failed
+func (s *Server) postWebhook(ctx context.Context, webhook *db.Webhook, deliveryID, eventType string, body []byte) (int, error) {
+	return s.postSigned(ctx, webhook.URL, webhook.Secret, deliveryID, eventType, body)
+}
+
+// postSigned POSTs a payload to target, signed with an encrypted secret,
+// and returns the response status; an error means the attempt failed
+func (s *Server) postSigned(ctx context.Context, target, encryptedSecret, deliveryID, eventType string, body []byte) (int, error) {
+	secret, err := decryptCredentialData(encryptedSecret, s.config.JWTSecret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt signing secret: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Container-Maker-Webhooks/1.0")
+	req.Header.Set("X-CM-Event", eventType)
+	req.Header.Set("X-CM-Delivery", deliveryID)
+	req.Header.Set("X-CM-Signature", signWebhook(secret["secret"], time.Now(), body))
+
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("%w: it returned %s", errEndpointStatus, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliverWebhooks delivers queued webhook deliveries, retrying failures,
+// and prunes old events until ctx ends
+func (s *Server) deliverWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.webhookWake:
+		}
+		now := time.Now().UTC()
+		s.dispatchWebhooks(ctx, now)
+		if now.Sub(pruned) >= time.Hour {
+			if err := s.db.DeleteEventsBefore(now.Add(-eventRetention)); err != nil {
+				s.log.Error("webhooks: failed to prune events", "error", err)
+			}
+			pruned = now
+		}
+	}
+}
+
+// dispatchWebhooks attempts the deliveries that are due
+func (s *Server) dispatchWebhooks(ctx context.Context, now time.Time) {
+	due, err := s.db.ListDueWebhookDeliveries(now, webhookBatchSize)
+	if err != nil {
+		s.log.Error("webhooks: failed to list deliveries", "error", err)
+		return
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, webhookConcurrency)
+	for i := range due {
+		delivery := &due[i]
+		claimed, err := s.db.ClaimWebhookDelivery(delivery, now.Add(2*webhookTimeout))
+		if err != nil {
+			s.log.Error("webhooks: failed to claim delivery", "delivery_id", delivery.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			s.attemptDelivery(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+// attemptDelivery makes a claimed attempt at a delivery and records the
+// outcome: delivered, retried later or failed for good
+func (s *Server) attemptDelivery(ctx context.Context, delivery *db.WebhookDelivery) {
+	var status int
+	var err error
+	final := false // Retrying can't help
+	webhook, werr := s.db.GetWebhookByID(delivery.WebhookID)
+	event, eerr := s.db.GetEventByID(delivery.EventID)
+	switch {
+	case werr != nil || !webhook.Active:
+		err, final = fmt.Errorf("webhook was deleted or disabled"), true
+	case eerr != nil:
+		err, final = fmt.Errorf("event no longer exists"), true
+	default:
+		body, _ := json.Marshal(newEventResponse(event))
+		status, err = s.postWebhook(ctx, webhook, delivery.ID, delivery.EventType, body)
+	}
+
+	now := time.Now().UTC()
+	delivery.ResponseCode = status
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = "delivered"
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case final || delivery.Attempts > len(webhookRetryDelays):
+		delivery.Status = "failed"
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookRetryDelays[delivery.Attempts-1])
+		delivery.NextAttemptAt = &next
+	}
+	if err != nil {
+		delivery.LastError = truncate(err.Error(), 500)
+		s.log.Warn("webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID,
+			"attempt", delivery.Attempts, "status", delivery.Status, "error", err)
+	}
+	if err := s.db.UpdateWebhookDelivery(delivery); err != nil {
+		s.log.Error("webhooks: failed to record delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// ---- Events API ----
+
+// listEvents returns the events about the signed-in user's resources and
+// their teams' after ?cursor=, oldest first. ?team_id= narrows them to one
+// team and ?type= to some comma-separated types; next_cursor continues.
+func (s *Server) listEvents(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var after int64
+	if v := c.QueryParam("cursor"); v != "" {
+		var err error
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "cursor must be an event ID")
+		}
+	}
+	limit := defaultEventsLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be 1 to %d", maxEventsLimit))
+		}
+		limit = n
+	}
+	var types []string
+	if v := c.QueryParam("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); !eventTypes[t] {
+				return echo.NewHTTPError(http.StatusBadRequest, "unknown event type: "+t)
+			}
+			types = append(types, t)
+		}
+	}
+
+	// Without a team the user's own events come too
+	owner := userID
+	var teamIDs []string
+	if v := c.QueryParam("team_id"); v != "" {
+		if _, err := s.teamScope(c, &v, db.RoleViewer); err != nil {
+			return err
+		}
+		owner, teamIDs = "", []string{v}
+	} else {
+		var err error
+		if teamIDs, err = s.db.TeamIDsByUser(userID, db.RoleViewer); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list teams")
+		}
+	}
+
+	events, err := s.db.ListEventsForUser(owner, teamIDs, after, types, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list events")
+	}
+	resp := make([]eventResponse, len(events))
+	for i := range events {
+		resp[i] = newEventResponse(&events[i])
+		after = events[i].ID
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events":      resp,
+		"next_cursor": strconv.FormatInt(after, 10),
+		"has_more":    len(events) == limit,
+	})
+}
+
+// ---- Webhook handlers ----
+
+// webhookResponse is a webhook with its event types listed
+type webhookResponse struct {
+	db.Webhook
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"` // Only when created
+}
+
+func newWebhookResponse(webhook *db.Webhook) webhookResponse {
+	resp := webhookResponse{Webhook: *webhook, Events: []string{}}
+	for _, e := range strings.Split(webhook.Events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			resp.Events = append(resp.Events, e)
+		}
+	}
+	return resp
+}
+
+// webhookRequest creates or changes a webhook; omitted fields are kept
+type webhookRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"` // Empty for every type
+	Active      *bool     `json:"active"`
+	TeamID      *string   `json:"team_id"` // Creates it for this team's events
+}
+
+func (r *webhookRequest) apply(webhook *db.Webhook) error {
+	if r.URL != nil {
+		if err := checkWebhookURL("url", *r.URL); err != nil {
+			return err
+		}
+		webhook.URL = *r.URL
+	}
+	if r.Description != nil {
+		webhook.Description = *r.Description
+	}
+	if r.Events != nil {
+		for _, e := range *r.Events {
+			if !eventTypes[e] {
+				return fmt.Errorf("unknown event type: %s", e)
+			}
+		}
+		webhook.Events = strings.Join(*r.Events, ",")
+	}
+	if r.Active != nil {
+		webhook.Active = *r.Active
+	}
+	return nil
+}
+
+// requireWebhook loads the webhook named by :id into the context as
+// "webhook" if the signed-in user may manage it: their own, or as a team
+// admin the team's
+func (s *Server) requireWebhook(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		webhook, err := s.db.GetWebhookByID(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		if err := s.resourceAccess(c.Get("user_id").(string), webhook.UserID, webhook.TeamID, accessManage); err != nil {
+			if err == errNotFound {
+				return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+			}
+			return err
+		}
+		c.Set("webhook", webhook)
+		return next(c)
+	}
+}
+
+// listWebhooks lists the signed-in user's webhooks, or ?team_id='s
+func (s *Server) listWebhooks(c echo.Context) error {
+	var teamID *string
+	if v := c.QueryParam("team_id"); v != "" {
+		teamID = &v
+	}
+	teamID, err := s.teamScope(c, teamID, db.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	webhooks, err := s.db.ListWebhooks(c.Get("user_id").(string), teamID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list webhooks")
+	}
+	resp := make([]webhookResponse, len(webhooks))
+	for i := range webhooks {
+		resp[i] = newWebhookResponse(&webhooks[i])
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// createWebhook creates a webhook and returns its signing secret, which
+// isn't shown again
+func (s *Server) createWebhook(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	var req webhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if req.URL == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+	teamID, err := s.teamScope(c, req.TeamID, db.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	webhook := &db.Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		TeamID:    teamID,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := req.apply(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	secret := "whsec_" + randomToken()
+	if webhook.Secret, err = encryptCredentialData(map[string]string{"secret": secret}, s.config.JWTSecret); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt secret")
+	}
+	if err := s.db.CreateWebhook(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook")
+	}
+
+	resp := newWebhookResponse(webhook)
+	resp.Secret = secret
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) updateWebhook(c echo.Context) error {
+	webhook := c.Get("webhook").(*db.Webhook)
+	var req webhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request")
+	}
+	if err := req.apply(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	webhook.UpdatedAt = time.Now().UTC()
+	if err := s.db.UpdateWebhook(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update webhook")
+	}
+	return c.JSON(http.StatusOK, newWebhookResponse(webhook))
+}
+
+func (s *Server) deleteWebhook(c echo.Context) error {
+	if err := s.db.DeleteWebhook(c.Get("webhook").(*db.Webhook).ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete webhook")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listWebhookDeliveries lists a webhook's recent deliveries, newest first
+func (s *Server) listWebhookDeliveries(c echo.Context) error {
+	deliveries, err := s.db.ListWebhookDeliveries(c.Get("webhook").(*db.Webhook).ID, webhookDeliveriesListed)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list deliveries")
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// pingWebhook sends a webhook a signed test event right away and reports
+// whether its endpoint accepted it. The endpoint's status and error text
+// aren't passed on, so pings can't be used to probe what answers where.
+func (s *Server) pingWebhook(c echo.Context) error {
+	webhook := c.Get("webhook").(*db.Webhook)
+	deliveryID := uuid.New().String()
+	body, _ := json.Marshal(eventResponse{
+		Type:      "ping",
+		UserID:    webhook.UserID,
+		TeamID:    webhook.TeamID,
+		Data:      json.RawMessage(fmt.Sprintf(`{"webhook_id":%q}`, webhook.ID)),
+		Text:      eventText("ping", nil),
+		CreatedAt: time.Now().UTC(),
+	})
+	_, err := s.postWebhook(c.Request().Context(), webhook, deliveryID, "ping", body)
+	resp := map[string]interface{}{"delivery_id": deliveryID, "delivered": err == nil}
+	if err != nil {
+		s.log.Warn("webhook ping failed", "webhook_id", webhook.ID, "error", err)
+		resp["error"] = webhookFailure(err)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// webhookFailure describes why a delivery failed without the details
+func webhookFailure(err error) string {
+	switch {
+	case errors.Is(err, errPrivateAddress):
+		return errPrivateAddress.Error()
+	case errors.Is(err, errEndpointStatus):
+		return "the endpoint didn't answer with a 2xx status"
+	}
+	return "the endpoint couldn't be reached"
+}
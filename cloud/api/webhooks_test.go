@@ -0,0 +1,398 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+)
+
+// verifySignature checks an X-CM-Signature header the way the README tells
+// receivers to
+func verifySignature(header, secret string, body []byte) bool {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + string(body)))
+	return ts != "" && hmac.Equal([]byte(sig), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"type":"instance.ready"}`)
+	header := signWebhook("whsec_test", time.Unix(1700000000, 0), body)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Fatalf("signature = %q, want t=1700000000,v1=...", header)
+	}
+	if !verifySignature(header, "whsec_test", body) {
+		t.Error("signature doesn't verify")
+	}
+	if verifySignature(header, "whsec_other", body) {
+		t.Error("signature verifies with another secret")
+	}
+	if verifySignature(header, "whsec_test", []byte(`{"type":"instance.stopped"}`)) {
+		t.Error("signature verifies for another body")
+	}
+	if verifySignature(strings.Replace(header, "t=1700000000", "t=1700000001", 1), "whsec_test", body) {
+		t.Error("signature verifies for another time")
+	}
+}
+
+// webhookReceiver is an endpoint answering with status and recording what
+// it receives
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	r := &webhookReceiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+
+	// The receiver is on loopback, which webhookHTTPClient refuses
+	client := webhookHTTPClient
+	webhookHTTPClient = &http.Client{Timeout: webhookTimeout}
+	t.Cleanup(func() { webhookHTTPClient = client })
+	return r
+}
+
+// webhook adds a webhook for a user directly, as the API refuses the
+// receiver's loopback URL
+func (f *teamFixture) webhook(user, url, secret string) *db.Webhook {
+	f.t.Helper()
+	encrypted, err := encryptCredentialData(map[string]string{"secret": secret}, f.s.config.JWTSecret)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	webhook := &db.Webhook{ID: uuid.New().String(), UserID: f.users[user].ID, URL: url, Secret: encrypted, Active: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := f.s.db.CreateWebhook(webhook); err != nil {
+		f.t.Fatal(err)
+	}
+	return webhook
+}
+
+// queueDelivery records an event and queues it for a webhook, due now
+func (f *teamFixture) queueDelivery(webhook *db.Webhook) *db.WebhookDelivery {
+	f.t.Helper()
+	now := time.Now().UTC()
+	event := &db.Event{Type: EventInstanceReady, UserID: webhook.UserID, InstanceID: "inst-1", Data: `{"instance_id":"inst-1","name":"dev"}`, CreatedAt: now}
+	if err := f.s.db.CreateEvent(event); err != nil {
+		f.t.Fatal(err)
+	}
+	delivery := db.WebhookDelivery{ID: uuid.New().String(), WebhookID: webhook.ID, EventID: event.ID, EventType: event.Type,
+		Status: "pending", NextAttemptAt: &now, CreatedAt: now}
+	if err := f.s.db.CreateWebhookDeliveries([]db.WebhookDelivery{delivery}); err != nil {
+		f.t.Fatal(err)
+	}
+	return &delivery
+}
+
+// delivery reloads a webhook's only delivery
+func (f *teamFixture) delivery(webhook *db.Webhook) db.WebhookDelivery {
+	f.t.Helper()
+	deliveries, err := f.s.db.ListWebhookDeliveries(webhook.ID, 10)
+	if err != nil || len(deliveries) != 1 {
+		f.t.Fatalf("deliveries = %v, %v; want one", deliveries, err)
+	}
+	return deliveries[0]
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop() // The test dispatches deliveries itself
+	receiver := newWebhookReceiver(t)
+	webhook := f.webhook("member", receiver.URL, "whsec_member")
+	queued := f.queueDelivery(webhook)
+
+	f.s.dispatchWebhooks(context.Background(), time.Now().UTC())
+
+	if len(receiver.requests) != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", len(receiver.requests))
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	if got := req.Header.Get("X-CM-Event"); got != EventInstanceReady {
+		t.Errorf("X-CM-Event = %q, want %q", got, EventInstanceReady)
+	}
+	if got := req.Header.Get("X-CM-Delivery"); got != queued.ID {
+		t.Errorf("X-CM-Delivery = %q, want %q", got, queued.ID)
+	}
+	if !verifySignature(req.Header.Get("X-CM-Signature"), "whsec_member", body) {
+		t.Errorf("X-CM-Signature %q doesn't verify", req.Header.Get("X-CM-Signature"))
+	}
+	if !strings.Contains(string(body), `"text":"Instance dev (inst-1) is ready"`) {
+		t.Errorf("body = %s, want the event's text", body)
+	}
+
+	got := f.delivery(webhook)
+	if got.Status != "delivered" || got.Attempts != 1 || got.ResponseCode != http.StatusOK || got.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v, want delivered on the first attempt", got)
+	}
+}
+
+func TestWebhookRetrySchedule(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop()
+	receiver := newWebhookReceiver(t)
+	receiver.status = http.StatusServiceUnavailable
+	webhook := f.webhook("member", receiver.URL, "whsec_member")
+	f.queueDelivery(webhook)
+
+	for attempt := 1; attempt <= len(webhookRetryDelays); attempt++ {
+		before := time.Now().UTC()
+		// Far enough ahead that every retry is due
+		f.s.dispatchWebhooks(context.Background(), before.Add(24*time.Hour))
+
+		got := f.delivery(webhook)
+		if got.Status != "pending" || got.Attempts != attempt || got.ResponseCode != http.StatusServiceUnavailable {
+			t.Fatalf("after attempt %d delivery = %+v, want pending with a 503", attempt, got)
+		}
+		want := before.Add(webhookRetryDelays[attempt-1])
+		if got.NextAttemptAt == nil || got.NextAttemptAt.Before(want) || got.NextAttemptAt.After(want.Add(time.Minute)) {
+			t.Fatalf("after attempt %d next attempt = %v, want %v later", attempt, got.NextAttemptAt, webhookRetryDelays[attempt-1])
+		}
+
+		// Not due before then
+		f.s.dispatchWebhooks(context.Background(), got.NextAttemptAt.Add(-time.Second))
+		if again := f.delivery(webhook); again.Attempts != attempt {
+			t.Fatalf("delivery attempted again %v early", time.Second)
+		}
+	}
+
+	f.s.dispatchWebhooks(context.Background(), time.Now().UTC().Add(24*time.Hour))
+	got := f.delivery(webhook)
+	if got.Status != "failed" || got.Attempts != len(webhookRetryDelays)+1 || got.NextAttemptAt != nil {
+		t.Errorf("after the last retry delivery = %+v, want failed", got)
+	}
+	if !strings.Contains(got.LastError, "503") {
+		t.Errorf("last error = %q, want the endpoint's status", got.LastError)
+	}
+	if len(receiver.requests) != len(webhookRetryDelays)+1 {
+		t.Errorf("endpoint got %d requests, want %d", len(receiver.requests), len(webhookRetryDelays)+1)
+	}
+
+	// A deleted webhook's delivery fails without a request
+	receiver.status = http.StatusOK
+	other := f.webhook("member", receiver.URL, "whsec_other")
+	f.queueDelivery(other)
+	other.Active = false
+	if err := f.s.db.UpdateWebhook(other); err != nil {
+		t.Fatal(err)
+	}
+	f.s.dispatchWebhooks(context.Background(), time.Now().UTC())
+	if got := f.delivery(other); got.Status != "failed" || got.Attempts != 1 {
+		t.Errorf("delivery to a disabled webhook = %+v, want failed after one attempt", got)
+	}
+}
+
+func TestEventsCursorPaging(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop()
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		event := &db.Event{Type: EventInstanceCreated, UserID: f.users["member"].ID, Data: `{}`, CreatedAt: time.Now().UTC()}
+		if err := f.s.db.CreateEvent(event); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, event.ID)
+	}
+	// Someone else's event is never listed
+	if err := f.s.db.CreateEvent(&db.Event{Type: EventInstanceCreated, UserID: f.users["outsider"].ID, Data: `{}`, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+
+	type page struct {
+		Events     []eventResponse `json:"events"`
+		NextCursor string          `json:"next_cursor"`
+		HasMore    bool            `json:"has_more"`
+	}
+	var got []int64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("paging doesn't end")
+		}
+		var p page
+		f.do("member", http.MethodGet, "/api/v1/events?limit=2&cursor="+cursor, "", http.StatusOK, &p)
+		for _, e := range p.Events {
+			got = append(got, e.ID)
+		}
+		if len(p.Events) > 0 && p.NextCursor != fmt.Sprint(p.Events[len(p.Events)-1].ID) {
+			t.Errorf("next_cursor = %s, want the page's last event %d", p.NextCursor, p.Events[len(p.Events)-1].ID)
+		}
+		if !p.HasMore {
+			break
+		}
+		cursor = p.NextCursor
+	}
+	if fmt.Sprint(got) != fmt.Sprint(ids) {
+		t.Errorf("paged events = %v, want %v oldest first", got, ids)
+	}
+
+	var last page
+	f.do("member", http.MethodGet, "/api/v1/events?cursor="+fmt.Sprint(ids[4]), "", http.StatusOK, &last)
+	if len(last.Events) != 0 || last.HasMore || last.NextCursor != fmt.Sprint(ids[4]) {
+		t.Errorf("page after the last event = %+v, want it empty with the same cursor", last)
+	}
+	f.do("member", http.MethodGet, "/api/v1/events?cursor=abc", "", http.StatusBadRequest, nil)
+	f.do("member", http.MethodGet, "/api/v1/events?limit=0", "", http.StatusBadRequest, nil)
+}
+
+func TestWebhookURLPrivateAddresses(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		private bool
+		invalid bool
+	}{
+		{url: "https://93.184.216.34/hook"},
+		{url: "http://127.0.0.1:8080/hook", private: true},
+		{url: "http://localhost/hook", private: true},
+		{url: "http://10.1.2.3/hook", private: true},
+		{url: "http://192.168.1.1/hook", private: true},
+		{url: "http://172.16.0.1/hook", private: true},
+		{url: "http://169.254.169.254/latest/meta-data/", private: true},
+		{url: "http://100.64.0.1/hook", private: true},
+		{url: "http://0.0.0.0/hook", private: true},
+		{url: "http://[::1]/hook", private: true},
+		{url: "http://[fd00::1]/hook", private: true},
+		{url: "http://[fe80::1]/hook", private: true},
+		{url: "http://[::ffff:127.0.0.1]/hook", private: true},
+		{url: "ftp://93.184.216.34/hook", invalid: true},
+		{url: "not a url", invalid: true},
+	} {
+		err := checkWebhookURL("url", tc.url)
+		switch {
+		case tc.private && !errors.Is(err, errPrivateAddress):
+			t.Errorf("checkWebhookURL(%q) = %v, want a private address error", tc.url, err)
+		case tc.invalid && err == nil:
+			t.Errorf("checkWebhookURL(%q) = nil, want an error", tc.url)
+		case !tc.private && !tc.invalid && err != nil:
+			t.Errorf("checkWebhookURL(%q) = %v, want nil", tc.url, err)
+		}
+	}
+}
+
+func TestWebhookPrivateAddressRefused(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop()
+	f.do("member", http.MethodPost, "/api/v1/webhooks", `{"url":"http://169.254.169.254/latest/meta-data/"}`, http.StatusBadRequest, nil)
+	f.do("member", http.MethodPut, "/api/v1/billing/budget", `{"monthly_limit":100,"webhook_url":"http://127.0.0.1:9000/"}`, http.StatusBadRequest, nil)
+
+	// A URL that passed the check but now resolves privately is refused as
+	// it's dialed; ping doesn't say what, if anything, answered there
+	var answered bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		answered = true
+		http.Error(w, "secret internal status", http.StatusTeapot)
+	}))
+	defer internal.Close()
+	webhook := f.webhook("member", internal.URL, "whsec_member")
+
+	var resp map[string]interface{}
+	f.do("member", http.MethodPost, "/api/v1/webhooks/"+webhook.ID+"/ping", "", http.StatusOK, &resp)
+	if answered {
+		t.Error("ping reached a loopback address")
+	}
+	if resp["delivered"] != false || resp["error"] != errPrivateAddress.Error() {
+		t.Errorf("ping = %v, want undelivered with a private address error", resp)
+	}
+	if _, ok := resp["response_code"]; ok {
+		t.Errorf("ping = %v, want no response code", resp)
+	}
+}
+
+func TestPingHidesEndpointDetails(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop()
+	receiver := newWebhookReceiver(t)
+	receiver.status = http.StatusTeapot
+	webhook := f.webhook("member", receiver.URL, "whsec_member")
+
+	var resp map[string]interface{}
+	f.do("member", http.MethodPost, "/api/v1/webhooks/"+webhook.ID+"/ping", "", http.StatusOK, &resp)
+	if resp["delivered"] != false || resp["error"] != "the endpoint didn't answer with a 2xx status" {
+		t.Errorf("ping = %v, want undelivered without the endpoint's status", resp)
+	}
+	if _, ok := resp["response_code"]; ok {
+		t.Errorf("ping = %v, want no response code", resp)
+	}
+
+	receiver.status = http.StatusNoContent
+	f.do("member", http.MethodPost, "/api/v1/webhooks/"+webhook.ID+"/ping", "", http.StatusOK, &resp)
+	if resp["delivered"] != true {
+		t.Errorf("ping = %v, want delivered", resp)
+	}
+}
+
+func TestBudgetAlertSigned(t *testing.T) {
+	f := newTeamFixture(t)
+	f.s.stop()
+	receiver := newWebhookReceiver(t)
+
+	// The secret is shown once, when the webhook is set
+	var saved budgetResponse
+	f.do("member", http.MethodPut, "/api/v1/billing/budget", `{"monthly_limit":100,"webhook_url":"https://93.184.216.34/hook"}`, http.StatusOK, &saved)
+	if !strings.HasPrefix(saved.WebhookSecret, "whsec_") {
+		t.Fatalf("webhook_secret = %q, want a new secret", saved.WebhookSecret)
+	}
+	var again budgetResponse
+	f.do("member", http.MethodPut, "/api/v1/billing/budget", `{"monthly_limit":200,"webhook_url":"https://93.184.216.34/hook"}`, http.StatusOK, &again)
+	if again.WebhookSecret != "" {
+		t.Errorf("webhook_secret = %q on a later save, want it hidden", again.WebhookSecret)
+	}
+
+	budget, err := f.s.db.GetUserBudget(f.users["member"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget.WebhookURL = receiver.URL
+	f.s.sendBudgetAlert(*budget, 160, 80, "2026-10")
+
+	if len(receiver.requests) != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", len(receiver.requests))
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	if req.Header.Get("X-CM-Event") != "budget_alert" || req.Header.Get("X-CM-Delivery") == "" {
+		t.Errorf("headers = %v, want a budget_alert delivery", req.Header)
+	}
+	if !verifySignature(req.Header.Get("X-CM-Signature"), saved.WebhookSecret, body) {
+		t.Errorf("X-CM-Signature %q doesn't verify with the budget's secret", req.Header.Get("X-CM-Signature"))
+	}
+
+	// Unsigned alerts aren't sent
+	budget.WebhookSecret = ""
+	f.s.sendBudgetAlert(*budget, 200, 100, "2026-10")
+	if len(receiver.requests) != 1 {
+		t.Errorf("endpoint got an alert without a signing secret")
+	}
+}
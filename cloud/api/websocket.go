@@ -158,6 +158,7 @@ func (s *Server) HandleWebSocket(c echo.Context) error {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return err
 	}
+	s.metrics.wsSessions.add(1, "events")
 
 	client := &Client{
 		conn:   conn,
@@ -218,6 +219,7 @@ func (s *Server) wsWritePump(client *Client) {
 // wsReadPump reads messages from client
 func (s *Server) wsReadPump(client *Client) {
 	defer func() {
+		s.metrics.wsSessions.add(-1, "events")
 		s.wsHub.unregister <- client
 		client.conn.Close()
 	}()
@@ -2,6 +2,8 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,6 +13,8 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db/migrations"
 )
 
 // Database wraps the GORM database connection
@@ -23,6 +27,16 @@ type Config struct {
 	Driver string // "sqlite" or "postgres"
 	DSN    string // Data Source Name
 	Debug  bool   // Enable query logging
+
+	// ManualMigrate leaves pending migrations to cm-server migrate and
+	// refuses to connect until they're applied
+	ManualMigrate bool
+
+	// Connection pool; zero keeps the driver's defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 // DefaultSQLiteConfig returns config for local SQLite database
@@ -38,8 +52,44 @@ func DefaultSQLiteConfig() Config {
 	}
 }
 
-// New creates a new database connection
+// New connects to the database and brings its schema up to date, or with
+// ManualMigrate checks that it is
 func New(cfg Config) (*Database, error) {
+	d, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := d.SchemaMigrator()
+	if err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	ctx := context.Background()
+	if cfg.ManualMigrate {
+		version, err := migrator.Version(ctx)
+		switch {
+		case err != nil:
+		case version > migrator.Latest():
+			err = fmt.Errorf("database schema version %d is newer than this server's %d; upgrade the server", version, migrator.Latest())
+		case version < migrator.Latest():
+			err = fmt.Errorf("database schema is at version %d but this server needs %d; run cm-server migrate", version, migrator.Latest())
+		}
+		if err != nil {
+			_ = d.Close()
+			return nil, err
+		}
+		return d, nil
+	}
+	if err := migrator.Up(ctx, nil); err != nil {
+		_ = d.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return d, nil
+}
+
+// Open connects to the database without touching its schema
+func Open(cfg Config) (*Database, error) {
 	var dialector gorm.Dialector
 
 	switch cfg.Driver {
@@ -66,25 +116,35 @@ func New(cfg Config) (*Database, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Run auto migrations
-	if err := db.AutoMigrate(
-		&User{},
-		&Team{},
-		&TeamMember{},
-		&APIKey{},
-		&CloudCredential{},
-		&Instance{},
-		&UsageRecord{},
-		&Invoice{},
-		&Session{},
-		&SystemConfig{},
-	); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	}
 
 	return &Database{db}, nil
 }
 
+// SchemaMigrator returns the versioned schema migrator for the database
+func (d *Database) SchemaMigrator() (*migrations.Migrator, error) {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrations.New(sqlDB, d.Dialector.Name())
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	sqlDB, err := d.DB.DB()
@@ -94,6 +154,38 @@ func (d *Database) Close() error {
 	return sqlDB.Close()
 }
 
+// ObserveQueries calls observe after every query with its operation
+// (create, query, update, delete, row or raw), table, duration and error
+func (d *Database) ObserveQueries(observe func(operation, table string, took time.Duration, err error)) error {
+	const startKey = "cm:query_start"
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+	finish := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if v, ok := tx.InstanceGet(startKey); ok {
+				observe(operation, tx.Statement.Table, time.Since(v.(time.Time)), tx.Error)
+			}
+		}
+	}
+
+	cb := d.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("cm:start_create", start),
+		cb.Create().After("gorm:create").Register("cm:observe_create", finish("create")),
+		cb.Query().Before("gorm:query").Register("cm:start_query", start),
+		cb.Query().After("gorm:query").Register("cm:observe_query", finish("query")),
+		cb.Update().Before("gorm:update").Register("cm:start_update", start),
+		cb.Update().After("gorm:update").Register("cm:observe_update", finish("update")),
+		cb.Delete().Before("gorm:delete").Register("cm:start_delete", start),
+		cb.Delete().After("gorm:delete").Register("cm:observe_delete", finish("delete")),
+		cb.Row().Before("gorm:row").Register("cm:start_row", start),
+		cb.Row().After("gorm:row").Register("cm:observe_row", finish("row")),
+		cb.Raw().Before("gorm:raw").Register("cm:start_raw", start),
+		cb.Raw().After("gorm:raw").Register("cm:observe_raw", finish("raw")),
+	)
+}
+
 // ---- User Operations ----
 
 func (d *Database) CreateUser(user *User) error {
@@ -116,6 +208,15 @@ func (d *Database) GetUserByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
+// GetUserByOIDCSubject returns the user signed in through OIDC as a subject
+func (d *Database) GetUserByOIDCSubject(subject string) (*User, error) {
+	var user User
+	if err := d.Where("oidc_subject = ?", subject).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (d *Database) GetUserByStripeCustomerID(customerID string) (*User, error) {
 	var user User
 	if err := d.Where("stripe_customer_id = ?", customerID).First(&user).Error; err != nil {
@@ -134,12 +235,33 @@ func (d *Database) CreateAPIKey(key *APIKey) error {
 	return d.Create(key).Error
 }
 
-func (d *Database) GetAPIKeyByKey(key string) (*APIKey, error) {
-	var apiKey APIKey
-	if err := d.Where("key_hash = ?", key).First(&apiKey).Error; err != nil {
+// ListAPIKeysByPrefix returns the keys whose key starts with prefix, the
+// candidates for a presented key's salted hash
+func (d *Database) ListAPIKeysByPrefix(prefix string) ([]APIKey, error) {
+	var keys []APIKey
+	if err := d.Where("key_prefix = ?", prefix).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ListUnsaltedAPIKeys returns keys stored before keys were hashed, whose
+// KeyHash is still the key itself
+func (d *Database) ListUnsaltedAPIKeys() ([]APIKey, error) {
+	var keys []APIKey
+	if err := d.Where("key_salt = ? OR key_salt IS NULL", "").Find(&keys).Error; err != nil {
 		return nil, err
 	}
-	return &apiKey, nil
+	return keys, nil
+}
+
+func (d *Database) UpdateAPIKey(key *APIKey) error {
+	return d.Save(key).Error
+}
+
+// TouchAPIKey records when a key was last used
+func (d *Database) TouchAPIKey(id string, at time.Time) error {
+	return d.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
 }
 
 func (d *Database) ListAPIKeysByUser(userID string) ([]APIKey, error) {
@@ -150,8 +272,16 @@ func (d *Database) ListAPIKeysByUser(userID string) ([]APIKey, error) {
 	return keys, nil
 }
 
-func (d *Database) DeleteAPIKey(id string) error {
-	return d.Where("id = ?", id).Delete(&APIKey{}).Error
+// DeleteAPIKey deletes one of a user's keys
+func (d *Database) DeleteAPIKey(userID, id string) error {
+	result := d.Where("id = ? AND user_id = ?", id, userID).Delete(&APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // ---- Instance Operations ----
@@ -184,6 +314,580 @@ func (d *Database) DeleteInstance(id string) error {
 	return d.Where("id = ?", id).Delete(&Instance{}).Error
 }
 
+// ListInstancesForUser returns the instances a user owns and those of the
+// given teams
+func (d *Database) ListInstancesForUser(userID string, teamIDs []string) ([]Instance, error) {
+	var instances []Instance
+	query := d.Where("owner_id = ?", userID)
+	if len(teamIDs) > 0 {
+		query = query.Or("team_id IN ?", teamIDs)
+	}
+	if err := query.Order("created_at DESC").Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// ListInstancesByTeam returns a team's instances
+func (d *Database) ListInstancesByTeam(teamID string) ([]Instance, error) {
+	var instances []Instance
+	if err := d.Where("team_id = ?", teamID).Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// ListInstancesByStatus returns every user's instances in a status
+func (d *Database) ListInstancesByStatus(status string) ([]Instance, error) {
+	var instances []Instance
+	if err := d.Where("status = ?", status).Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// ListDueScheduledInstances returns the scheduled instances whose time to
+// be provisioned has come
+func (d *Database) ListDueScheduledInstances(now time.Time) ([]Instance, error) {
+	var instances []Instance
+	if err := d.Where("status = ? AND create_at <= ?", "scheduled", now).Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// ClaimScheduledInstance moves a scheduled instance to provisioning; false
+// means it's no longer scheduled, e.g. another server claimed it first
+func (d *Database) ClaimScheduledInstance(id string) (bool, error) {
+	result := d.Model(&Instance{}).Where("id = ? AND status = ?", id, "scheduled").
+		Updates(map[string]interface{}{"status": "provisioning", "updated_at": time.Now().UTC()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListInstancesStoppingBy returns the running instances scheduled to stop
+// by t
+func (d *Database) ListInstancesStoppingBy(t time.Time) ([]Instance, error) {
+	var instances []Instance
+	if err := d.Where("status = ? AND stop_at <= ?", "running", t).Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+func (d *Database) CreateHost(host *Host) error {
+	return d.Create(host).Error
+}
+
+func (d *Database) GetHostByID(id string) (*Host, error) {
+	var host Host
+	if err := d.Where("id = ?", id).First(&host).Error; err != nil {
+		return nil, err
+	}
+	return &host, nil
+}
+
+func (d *Database) UpdateHost(host *Host) error {
+	return d.Save(host).Error
+}
+
+func (d *Database) DeleteHost(id string) error {
+	return d.Where("id = ?", id).Delete(&Host{}).Error
+}
+
+// ListHostsForUser returns the user's hosts and those of the given teams
+func (d *Database) ListHostsForUser(userID string, teamIDs []string) ([]Host, error) {
+	var hosts []Host
+	query := d.Where("owner_id = ?", userID)
+	if len(teamIDs) > 0 {
+		query = query.Or("team_id IN ?", teamIDs)
+	}
+	if err := query.Order("created_at DESC").Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// ListPoolHosts returns the hosts that run a team's instances, or with an
+// empty teamID those that run a user's own
+func (d *Database) ListPoolHosts(userID, teamID string) ([]Host, error) {
+	var hosts []Host
+	query := d.Where("owner_id = ? AND team_id IS NULL", userID)
+	if teamID != "" {
+		query = d.Where("team_id = ?", teamID)
+	}
+	if err := query.Order("created_at").Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// CountHostInstances counts the instances placed on a host, whose provider
+// IDs start with the host's ID
+func (d *Database) CountHostInstances(hostID string) (int64, error) {
+	var count int64
+	err := d.Model(&Instance{}).Where("provider = ? AND provider_id LIKE ?", "hosts", hostID+"/%").Count(&count).Error
+	return count, err
+}
+
+// CountTeamHosts counts a team's hosts
+func (d *Database) CountTeamHosts(teamID string) (int64, error) {
+	var count int64
+	err := d.Model(&Host{}).Where("team_id = ?", teamID).Count(&count).Error
+	return count, err
+}
+
+func (d *Database) CreateRepoLink(repo *RepoLink) error {
+	return d.Create(repo).Error
+}
+
+func (d *Database) GetRepoLinkByID(id string) (*RepoLink, error) {
+	var repo RepoLink
+	if err := d.Where("id = ?", id).First(&repo).Error; err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// GetRepoLinkByName returns the link of a repository by its owner/name
+func (d *Database) GetRepoLinkByName(name string) (*RepoLink, error) {
+	var repo RepoLink
+	if err := d.Where("repo = ?", name).First(&repo).Error; err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// ListRepoLinks returns the repositories linked to a team
+func (d *Database) ListRepoLinks(teamID string) ([]RepoLink, error) {
+	repos := []RepoLink{}
+	if err := d.Where("team_id = ?", teamID).Order("repo").Find(&repos).Error; err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+func (d *Database) DeleteRepoLink(id string) error {
+	return d.Where("id = ?", id).Delete(&RepoLink{}).Error
+}
+
+func (d *Database) CreatePREnvironment(env *PREnvironment) error {
+	return d.Create(env).Error
+}
+
+// GetPREnvironment returns the environment of a pull request
+func (d *Database) GetPREnvironment(repo string, number int) (*PREnvironment, error) {
+	var env PREnvironment
+	if err := d.Where("repo = ? AND number = ?", repo, number).First(&env).Error; err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// ListPREnvironments returns a team's environments, newest first
+func (d *Database) ListPREnvironments(teamID string) ([]PREnvironment, error) {
+	envs := []PREnvironment{}
+	if err := d.Where("team_id = ?", teamID).Order("created_at DESC").Find(&envs).Error; err != nil {
+		return nil, err
+	}
+	return envs, nil
+}
+
+func (d *Database) UpdatePREnvironment(env *PREnvironment) error {
+	return d.Save(env).Error
+}
+
+func (d *Database) DeletePREnvironment(id string) error {
+	return d.Where("id = ?", id).Delete(&PREnvironment{}).Error
+}
+
+func (d *Database) CreatePrebuild(prebuild *Prebuild) error {
+	return d.Create(prebuild).Error
+}
+
+func (d *Database) GetPrebuildByID(id string) (*Prebuild, error) {
+	var prebuild Prebuild
+	if err := d.Where("id = ?", id).First(&prebuild).Error; err != nil {
+		return nil, err
+	}
+	return &prebuild, nil
+}
+
+func (d *Database) UpdatePrebuild(prebuild *Prebuild) error {
+	return d.Save(prebuild).Error
+}
+
+func (d *Database) DeletePrebuild(id string) error {
+	return d.Where("id = ?", id).Delete(&Prebuild{}).Error
+}
+
+// ListPrebuilds returns the prebuilds of the given teams, newest first
+func (d *Database) ListPrebuilds(teamIDs []string) ([]Prebuild, error) {
+	prebuilds := []Prebuild{}
+	if len(teamIDs) == 0 {
+		return prebuilds, nil
+	}
+	if err := d.Where("team_id IN ?", teamIDs).Order("created_at DESC").Find(&prebuilds).Error; err != nil {
+		return nil, err
+	}
+	return prebuilds, nil
+}
+
+// FindPrebuildImage returns the newest ready prebuild of a configuration
+// hash in the given teams
+func (d *Database) FindPrebuildImage(teamIDs []string, configHash string) (*Prebuild, error) {
+	if len(teamIDs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var prebuild Prebuild
+	err := d.Where("team_id IN ? AND config_hash = ? AND status = ?", teamIDs, configHash, "ready").
+		Order("finished_at DESC").First(&prebuild).Error
+	if err != nil {
+		return nil, err
+	}
+	return &prebuild, nil
+}
+
+// FailInterruptedPrebuilds marks the prebuilds that were building when the
+// control plane stopped as failed, and returns them
+func (d *Database) FailInterruptedPrebuilds(now time.Time) ([]Prebuild, error) {
+	var prebuilds []Prebuild
+	if err := d.Where("status = ?", "building").Find(&prebuilds).Error; err != nil {
+		return nil, err
+	}
+	for i := range prebuilds {
+		prebuilds[i].Status = "failed"
+		prebuilds[i].StatusReason = "interrupted by a control plane restart"
+		prebuilds[i].UpdatedAt = now
+		prebuilds[i].FinishedAt = &now
+		if err := d.Save(&prebuilds[i]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return prebuilds, nil
+}
+
+func (d *Database) CreateVolume(volume *Volume) error {
+	return d.Create(volume).Error
+}
+
+func (d *Database) GetVolumeByID(id string) (*Volume, error) {
+	var volume Volume
+	if err := d.Where("id = ?", id).First(&volume).Error; err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+func (d *Database) UpdateVolume(volume *Volume) error {
+	return d.Save(volume).Error
+}
+
+func (d *Database) DeleteVolume(id string) error {
+	return d.Where("id = ?", id).Delete(&Volume{}).Error
+}
+
+// ListVolumesForUser returns the user's volumes and those of the given teams
+func (d *Database) ListVolumesForUser(userID string, teamIDs []string) ([]Volume, error) {
+	var volumes []Volume
+	query := d.Where("owner_id = ?", userID)
+	if len(teamIDs) > 0 {
+		query = query.Or("team_id IN ?", teamIDs)
+	}
+	if err := query.Order("created_at DESC").Find(&volumes).Error; err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// ListVolumesByInstance returns the volumes attached to an instance
+func (d *Database) ListVolumesByInstance(instanceID string) ([]Volume, error) {
+	var volumes []Volume
+	if err := d.Where("instance_id = ?", instanceID).Find(&volumes).Error; err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// CountTeamVolumes counts a team's volumes and volume snapshots
+func (d *Database) CountTeamVolumes(teamID string) (int64, error) {
+	var volumes, snapshots int64
+	if err := d.Model(&Volume{}).Where("team_id = ?", teamID).Count(&volumes).Error; err != nil {
+		return 0, err
+	}
+	err := d.Model(&VolumeSnapshot{}).Where("team_id = ?", teamID).Count(&snapshots).Error
+	return volumes + snapshots, err
+}
+
+func (d *Database) CreateVolumeSnapshot(snapshot *VolumeSnapshot) error {
+	return d.Create(snapshot).Error
+}
+
+func (d *Database) GetVolumeSnapshotByID(id string) (*VolumeSnapshot, error) {
+	var snapshot VolumeSnapshot
+	if err := d.Where("id = ?", id).First(&snapshot).Error; err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (d *Database) UpdateVolumeSnapshot(snapshot *VolumeSnapshot) error {
+	return d.Save(snapshot).Error
+}
+
+func (d *Database) DeleteVolumeSnapshot(id string) error {
+	return d.Where("id = ?", id).Delete(&VolumeSnapshot{}).Error
+}
+
+// ListVolumeSnapshotsForUser returns the snapshots of the user and of the
+// given teams, newest first, only those of volumeID if it isn't empty
+func (d *Database) ListVolumeSnapshotsForUser(userID string, teamIDs []string, volumeID string) ([]VolumeSnapshot, error) {
+	var snapshots []VolumeSnapshot
+	scope := d.Where("owner_id = ?", userID)
+	if len(teamIDs) > 0 {
+		scope = scope.Or("team_id IN ?", teamIDs)
+	}
+	query := d.Where(scope)
+	if volumeID != "" {
+		query = query.Where("volume_id = ?", volumeID)
+	}
+	if err := query.Order("created_at DESC").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// InstanceCount is the number of instances of a provider in a status
+type InstanceCount struct {
+	Provider string
+	Status   string
+	Count    int64
+}
+
+// CountInstances counts the instances per provider and status
+func (d *Database) CountInstances() ([]InstanceCount, error) {
+	var counts []InstanceCount
+	err := d.Model(&Instance{}).
+		Select("provider, status, count(*) as count").
+		Group("provider, status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// ---- Team Operations ----
+
+// CreateTeam creates a team with its owner as the first member
+func (d *Database) CreateTeam(team *Team) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(team).Error; err != nil {
+			return err
+		}
+		return tx.Create(&TeamMember{
+			ID:       generateUUID(),
+			TeamID:   team.ID,
+			UserID:   team.OwnerID,
+			Role:     RoleOwner,
+			JoinedAt: team.CreatedAt,
+		}).Error
+	})
+}
+
+// GetTeamBySlug returns the team with a slug
+func (d *Database) GetTeamBySlug(slug string) (*Team, error) {
+	var team Team
+	if err := d.Where("slug = ?", slug).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (d *Database) UpdateTeam(team *Team) error {
+	return d.Save(team).Error
+}
+
+// DeleteTeam deletes a team with its memberships, idle policy, budget and
+// webhooks
+func (d *Database) DeleteTeam(id string) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		webhooks := tx.Model(&Webhook{}).Select("id").Where("team_id = ?", id)
+		if err := tx.Where("webhook_id IN (?)", webhooks).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&TeamMember{}, &IdlePolicy{}, &Budget{}, &Webhook{}} {
+			if err := tx.Where("team_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(&Team{}).Error
+	})
+}
+
+// ListTeamsByUser returns a user's memberships with their teams
+func (d *Database) ListTeamsByUser(userID string) ([]TeamMember, error) {
+	var members []TeamMember
+	if err := d.Preload("Team").Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.user_id = ?", userID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListTeamMembers returns a team's members with their users
+func (d *Database) ListTeamMembers(teamID string) ([]TeamMember, error) {
+	var members []TeamMember
+	if err := d.Preload("User").Where("team_id = ?", teamID).Order("joined_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// TeamIDsByUser returns the IDs of the teams a user is a member of with at
+// least minRole
+func (d *Database) TeamIDsByUser(userID, minRole string) ([]string, error) {
+	var roles []string
+	for _, role := range []string{RoleOwner, RoleAdmin, RoleMember, RoleViewer} {
+		if RoleRank(role) >= RoleRank(minRole) {
+			roles = append(roles, role)
+		}
+	}
+	var ids []string
+	err := d.Model(&TeamMember{}).Where("user_id = ? AND role IN ?", userID, roles).Pluck("team_id", &ids).Error
+	return ids, err
+}
+
+func (d *Database) AddTeamMember(member *TeamMember) error {
+	return d.Create(member).Error
+}
+
+func (d *Database) UpdateTeamMember(member *TeamMember) error {
+	return d.Save(member).Error
+}
+
+func (d *Database) RemoveTeamMember(teamID, userID string) error {
+	return d.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&TeamMember{}).Error
+}
+
+// TransferTeamOwnership makes a member the owner of a team; the previous
+// owner stays on as an admin
+func (d *Database) TransferTeamOwnership(team *Team, newOwnerID string) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&TeamMember{}).Where("team_id = ? AND user_id = ?", team.ID, team.OwnerID).
+			Update("role", RoleAdmin).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&TeamMember{}).Where("team_id = ? AND user_id = ?", team.ID, newOwnerID).
+			Update("role", RoleOwner).Error; err != nil {
+			return err
+		}
+		team.OwnerID = newOwnerID
+		return tx.Save(team).Error
+	})
+}
+
+// CountTeamInstances counts a team's instances that aren't terminated
+func (d *Database) CountTeamInstances(teamID string) (int64, error) {
+	var count int64
+	err := d.Model(&Instance{}).Where("team_id = ? AND status <> ?", teamID, "terminated").Count(&count).Error
+	return count, err
+}
+
+// GetTeamMember returns a user's membership in a team
+func (d *Database) GetTeamMember(teamID, userID string) (*TeamMember, error) {
+	var member TeamMember
+	if err := d.Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetTeamByID returns a team
+func (d *Database) GetTeamByID(id string) (*Team, error) {
+	var team Team
+	if err := d.Where("id = ?", id).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// ListTeamAdmins returns the owners and admins of a team
+func (d *Database) ListTeamAdmins(teamID string) ([]TeamMember, error) {
+	var members []TeamMember
+	if err := d.Where("team_id = ? AND role IN ?", teamID, []string{"owner", "admin"}).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ---- Idle Policy Operations ----
+
+// GetUserIdlePolicy returns the policy for a user's own instances
+func (d *Database) GetUserIdlePolicy(userID string) (*IdlePolicy, error) {
+	var policy IdlePolicy
+	if err := d.Where("user_id = ?", userID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetTeamIdlePolicy returns the policy for a team's instances
+func (d *Database) GetTeamIdlePolicy(teamID string) (*IdlePolicy, error) {
+	var policy IdlePolicy
+	if err := d.Where("team_id = ?", teamID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SaveIdlePolicy creates or updates a policy
+func (d *Database) SaveIdlePolicy(policy *IdlePolicy) error {
+	if policy.ID == "" {
+		policy.ID = generateUUID()
+	}
+	return d.Save(policy).Error
+}
+
+// ---- Budget Operations ----
+
+// GetUserBudget returns the budget for a user's instances
+func (d *Database) GetUserBudget(userID string) (*Budget, error) {
+	var budget Budget
+	if err := d.Where("user_id = ?", userID).First(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// GetTeamBudget returns the budget for a team's instances
+func (d *Database) GetTeamBudget(teamID string) (*Budget, error) {
+	var budget Budget
+	if err := d.Where("team_id = ?", teamID).First(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// ListBudgets returns every budget with a limit
+func (d *Database) ListBudgets() ([]Budget, error) {
+	var budgets []Budget
+	if err := d.Where("monthly_limit > 0").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+// SaveBudget creates or updates a budget
+func (d *Database) SaveBudget(budget *Budget) error {
+	if budget.ID == "" {
+		budget.ID = generateUUID()
+	}
+	return d.Save(budget).Error
+}
+
+// SetBudgetAlerted records the highest threshold alerted in a month
+func (d *Database) SetBudgetAlerted(id, period string, percent int) error {
+	return d.Model(&Budget{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"alert_period": period, "alerted_percent": percent}).Error
+}
+
 // ---- Cloud Credential Operations ----
 
 func (d *Database) CreateCredential(cred *CloudCredential) error {
@@ -198,6 +902,20 @@ func (d *Database) ListCredentialsByUser(userID string) ([]CloudCredential, erro
 	return creds, nil
 }
 
+// ListCredentialsForUser returns a user's own credentials and those shared
+// with the given teams
+func (d *Database) ListCredentialsForUser(userID string, teamIDs []string) ([]CloudCredential, error) {
+	var creds []CloudCredential
+	query := d.Where("user_id = ? AND team_id IS NULL", userID)
+	if len(teamIDs) > 0 {
+		query = query.Or("team_id IN ?", teamIDs)
+	}
+	if err := query.Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
 func (d *Database) GetCredentialByID(id string) (*CloudCredential, error) {
 	var cred CloudCredential
 	if err := d.Where("id = ?", id).First(&cred).Error; err != nil {
@@ -224,6 +942,24 @@ func (d *Database) GetUsageByUserAndPeriod(userID string, start, end time.Time)
 	return records, nil
 }
 
+// GetUsageByTeamAndPeriod returns the usage of a team's instances
+func (d *Database) GetUsageByTeamAndPeriod(teamID string, start, end time.Time) ([]UsageRecord, error) {
+	var records []UsageRecord
+	if err := d.Where("team_id = ? AND timestamp >= ? AND timestamp <= ?", teamID, start, end).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetLastUsageRecord returns an instance's most recent usage record
+func (d *Database) GetLastUsageRecord(instanceID string) (*UsageRecord, error) {
+	var record UsageRecord
+	if err := d.Where("instance_id = ?", instanceID).Order("period_end DESC").First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
 func (d *Database) CreateInvoice(invoice *Invoice) error {
 	return d.Create(invoice).Error
 }
@@ -236,6 +972,133 @@ func (d *Database) ListInvoicesByUser(userID string) ([]Invoice, error) {
 	return invoices, nil
 }
 
+// ---- Event & Webhook Operations ----
+
+// CreateEvent records an event, setting its ID
+func (d *Database) CreateEvent(event *Event) error {
+	return d.Create(event).Error
+}
+
+// GetEventByID returns an event
+func (d *Database) GetEventByID(id int64) (*Event, error) {
+	var event Event
+	if err := d.Where("id = ?", id).First(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ListEventsForUser returns up to limit events after a cursor, oldest
+// first: those about the user's own resources and those of the given
+// teams, optionally only of some types
+func (d *Database) ListEventsForUser(userID string, teamIDs []string, after int64, types []string, limit int) ([]Event, error) {
+	var events []Event
+	scope := d.Where("user_id = ? AND team_id IS NULL", userID)
+	if len(teamIDs) > 0 {
+		scope = scope.Or("team_id IN ?", teamIDs)
+	}
+	query := d.Where(scope).Where("id > ?", after)
+	if len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	if err := query.Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteEventsBefore deletes events and webhook deliveries older than t
+func (d *Database) DeleteEventsBefore(t time.Time) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("created_at < ?", t).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("created_at < ?", t).Delete(&Event{}).Error
+	})
+}
+
+func (d *Database) CreateWebhook(webhook *Webhook) error {
+	return d.Create(webhook).Error
+}
+
+func (d *Database) GetWebhookByID(id string) (*Webhook, error) {
+	var webhook Webhook
+	if err := d.Where("id = ?", id).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (d *Database) UpdateWebhook(webhook *Webhook) error {
+	return d.Save(webhook).Error
+}
+
+// DeleteWebhook deletes a webhook with its deliveries
+func (d *Database) DeleteWebhook(id string) error {
+	return d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&Webhook{}).Error
+	})
+}
+
+// ListWebhooks returns a team's webhooks, or with a nil team a user's own
+func (d *Database) ListWebhooks(userID string, teamID *string) ([]Webhook, error) {
+	var webhooks []Webhook
+	query := d.Where("user_id = ? AND team_id IS NULL", userID)
+	if teamID != nil {
+		query = d.Where("team_id = ?", *teamID)
+	}
+	if err := query.Order("created_at").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// CreateWebhookDeliveries queues deliveries
+func (d *Database) CreateWebhookDeliveries(deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.Create(&deliveries).Error
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries whose next
+// attempt is due, the longest waiting first
+func (d *Database) ListDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := d.Where("status = ? AND next_attempt_at <= ?", "pending", now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimWebhookDelivery counts an attempt at a due delivery and pushes its
+// next attempt back to until, so other servers sharing the database skip
+// it meanwhile; false means another server claimed it first
+func (d *Database) ClaimWebhookDelivery(delivery *WebhookDelivery, until time.Time) (bool, error) {
+	result := d.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, "pending", delivery.Attempts).
+		Updates(map[string]interface{}{"attempts": delivery.Attempts + 1, "next_attempt_at": until})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	delivery.Attempts++
+	delivery.NextAttemptAt = &until
+	return true, nil
+}
+
+func (d *Database) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	return d.Save(delivery).Error
+}
+
+// ListWebhookDeliveries returns a webhook's latest deliveries, newest first
+func (d *Database) ListWebhookDeliveries(webhookID string, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := d.Where("webhook_id = ?", webhookID).Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
 // ---- Session Operations ----
 
 func (d *Database) CreateSession(session *Session) error {
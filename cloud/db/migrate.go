@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/UPwith-me/Container-Maker/cloud/db/migrations"
+)
+
+// Migrate applies a database's pending migrations, logging each one and
+// the version the schema ends at
+func Migrate(cfg Config, logf func(format string, args ...interface{})) error {
+	d, err := Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	migrator, err := d.SchemaMigrator()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	before, err := migrator.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if err := migrator.Up(ctx, func(m migrations.Migration) {
+		logf("✅ Applied %04d_%s", m.Version, m.Name)
+	}); err != nil {
+		return err
+	}
+	if before == migrator.Latest() {
+		logf("📦 Database schema is up to date (version %d)", before)
+	} else {
+		logf("📦 Database schema migrated from version %d to %d", before, migrator.Latest())
+	}
+	return nil
+}
+
+// PrintMigrationStatus writes a database's migrations and when each was
+// applied to w
+func PrintMigrationStatus(cfg Config, w io.Writer) error {
+	d, err := Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	migrator, err := d.SchemaMigrator()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	fmt.Fprintf(w, "📦 Database: %s\n", cfg.Driver)
+	for _, st := range statuses {
+		applied := "pending"
+		if st.AppliedAt != nil {
+			applied = "applied " + st.AppliedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "   %04d  %-28s %s\n", st.Version, st.Name, applied)
+	}
+	return nil
+}
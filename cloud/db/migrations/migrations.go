@@ -0,0 +1,259 @@
+// Package migrations provides the versioned schema migrations for the Cloud
+// Control Plane database
+//
+// Migrations are SQL files embedded from a directory per driver, named
+// NNNN_description.sql. Every driver has the same versions. A migration is
+// applied in a transaction along with its row in schema_migrations, so a
+// failed migration leaves the database at the previous version.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// advisoryLockID keeps concurrent servers from migrating a Postgres
+// database at the same time
+const advisoryLockID int64 = 7_366_572_411
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Status is a migration and when it was applied, if it was
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// queryer is a *sql.DB or a *sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Migrator applies a driver's migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+// New returns a migrator for a database using a driver, sqlite or postgres
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := load(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+// load reads a driver's migrations in version order
+func load(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s", driver)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		version, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		n, err := strconv.Atoi(version)
+		if !ok || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		data, err := files.ReadFile(path.Join(driver, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: n, Name: name, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing for driver %s", i+1, driver)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest returns the version the migrations bring a database to
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the latest version applied to the database, 0 for a
+// database that has never been migrated
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	return m.version(ctx, m.db)
+}
+
+func (m *Migrator) version(ctx context.Context, q queryer) (int, error) {
+	applied, err := m.applied(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Status returns every migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i].Migration = migration
+		if at, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations in order, calling applied after each, and
+// refuses a database migrated by a newer version of the server
+func (m *Migrator) Up(ctx context.Context, applied func(Migration)) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.driver == "postgres" {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+			return fmt.Errorf("failed to lock for migration: %w", err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID)
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, m.createTable()); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	// Read the version under the lock, after any concurrent migration
+	version, err := m.version(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("database schema version %d is newer than this server's %d; upgrade the server", version, m.Latest())
+	}
+
+	for _, migration := range m.migrations[version:] {
+		if err := m.apply(ctx, conn, migration); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if applied != nil {
+			applied(migration)
+		}
+	}
+	return nil
+}
+
+// apply runs a migration and records it in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, m.bind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+		migration.Version, migration.Name, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied returns the applied versions and when they were applied
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	exists, err := m.tableExists(ctx, q)
+	if err != nil || !exists {
+		return map[int]time.Time{}, err
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) tableExists(ctx context.Context, q queryer) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	if m.driver == "postgres" {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'"
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) createTable() string {
+	timestamp := "datetime"
+	if m.driver == "postgres" {
+		timestamp = "timestamptz"
+	}
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at ` + timestamp + ` NOT NULL
+	)`
+}
+
+// bind rewrites ? placeholders as $n for Postgres
+func (m *Migrator) bind(query string) string {
+	if m.driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,191 @@
+-- The schema of the last release before versioned migrations, as its
+-- AutoMigrate created it. Databases from that release already have it, so
+-- every statement is a no-op on them; later changes are migrations of
+-- their own.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" varchar(36),
+    "email" varchar(255),
+    "name" varchar(255),
+    "password_hash" varchar(255),
+    "avatar_url" varchar(500),
+    "git_hub_id" varchar(50),
+    "google_id" varchar(50),
+    "stripe_customer_id" varchar(50),
+    "email_verified" boolean DEFAULT false,
+    "is_active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users"("email");
+CREATE INDEX IF NOT EXISTS "idx_users_git_hub_id" ON "users"("git_hub_id");
+CREATE INDEX IF NOT EXISTS "idx_users_google_id" ON "users"("google_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "teams" (
+    "id" varchar(36),
+    "name" varchar(255),
+    "slug" varchar(100),
+    "owner_id" varchar(36),
+    "stripe_customer_id" varchar(50),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_teams_slug" ON "teams"("slug");
+CREATE INDEX IF NOT EXISTS "idx_teams_owner_id" ON "teams"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_teams_deleted_at" ON "teams"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "team_members" (
+    "id" varchar(36),
+    "team_id" varchar(36),
+    "user_id" varchar(36),
+    "role" varchar(50) DEFAULT 'member',
+    "joined_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_team_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_teams_members" FOREIGN KEY ("team_id") REFERENCES "teams"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_team_members_team_id" ON "team_members"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_team_members_user_id" ON "team_members"("user_id");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "name" varchar(100),
+    "key_prefix" varchar(10),
+    "key_hash" varchar(255),
+    "scopes" varchar(500),
+    "last_used_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_api_keys" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys"("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_deleted_at" ON "api_keys"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "cloud_credentials" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "provider" varchar(50),
+    "name" varchar(100),
+    "encrypted_data" text,
+    "is_verified" boolean DEFAULT false,
+    "last_verified" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_credentials" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_cloud_credentials_user_id" ON "cloud_credentials"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_cloud_credentials_deleted_at" ON "cloud_credentials"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "instances" (
+    "id" varchar(36),
+    "owner_id" varchar(36),
+    "team_id" varchar(36),
+    "name" varchar(100),
+    "provider" varchar(50),
+    "region" varchar(50),
+    "zone" varchar(50),
+    "instance_type" varchar(50),
+    "status" varchar(50) DEFAULT 'pending',
+    "status_reason" varchar(255),
+    "public_ip" varchar(50),
+    "private_ip" varchar(50),
+    "ssh_port" bigint DEFAULT 22,
+    "provider_id" varchar(100),
+    "provider_data" text,
+    "hourly_rate" decimal(10,4),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "started_at" timestamptz,
+    "stopped_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_teams_instances" FOREIGN KEY ("team_id") REFERENCES "teams"("id"),
+    CONSTRAINT "fk_users_instances" FOREIGN KEY ("owner_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_instances_owner_id" ON "instances"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_instances_team_id" ON "instances"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_instances_deleted_at" ON "instances"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "usage_records" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "instance_id" varchar(36),
+    "type" varchar(50),
+    "quantity" decimal(20,6),
+    "unit" varchar(20),
+    "unit_price" decimal(10,6),
+    "total_cost" decimal(10,4),
+    "timestamp" timestamptz,
+    "period_start" timestamptz,
+    "period_end" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_usage_records_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_usage_records_instance" FOREIGN KEY ("instance_id") REFERENCES "instances"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_usage_records_user_id" ON "usage_records"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_instance_id" ON "usage_records"("instance_id");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_timestamp" ON "usage_records"("timestamp");
+
+CREATE TABLE IF NOT EXISTS "invoices" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "number" varchar(50),
+    "status" varchar(20),
+    "subtotal" bigint,
+    "tax" bigint,
+    "total" bigint,
+    "amount_paid" bigint,
+    "amount_due" bigint,
+    "currency" varchar(3) DEFAULT 'USD',
+    "stripe_invoice_id" varchar(50),
+    "stripe_payment_intent_id" varchar(50),
+    "invoice_url" varchar(500),
+    "period_start" timestamptz,
+    "period_end" timestamptz,
+    "due_date" timestamptz,
+    "paid_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_invoices_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_invoices_user_id" ON "invoices"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invoices_number" ON "invoices"("number");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "token" varchar(255),
+    "user_agent" varchar(500),
+    "ip_address" varchar(50),
+    "created_at" timestamptz,
+    "expires_at" timestamptz,
+    "last_active_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_token" ON "sessions"("token");
+
+CREATE TABLE IF NOT EXISTS "system_configs" (
+    "id" varchar(36),
+    "key" varchar(100),
+    "value" text,
+    "is_secret" boolean DEFAULT false,
+    "description" varchar(500),
+    "updated_at" timestamptz,
+    "updated_by" varchar(36),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_system_configs_key" ON "system_configs"("key");
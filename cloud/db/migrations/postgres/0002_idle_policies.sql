@@ -0,0 +1,22 @@
+-- Instances report heartbeats and activity from their agent, and idle
+-- policies stop them when idle or off hours.
+
+ALTER TABLE "instances" ADD COLUMN IF NOT EXISTS "agent_token_hash" varchar(64);
+ALTER TABLE "instances" ADD COLUMN IF NOT EXISTS "last_heartbeat_at" timestamptz;
+ALTER TABLE "instances" ADD COLUMN IF NOT EXISTS "last_activity_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "idle_policies" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "team_id" varchar(36),
+    "idle_minutes" bigint,
+    "warn_minutes" bigint,
+    "shutdown_at" varchar(5),
+    "timezone" varchar(64),
+    "weekdays" varchar(50),
+    "updated_at" timestamptz,
+    "updated_by" varchar(36),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idle_policies_user_id" ON "idle_policies"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idle_policies_team_id" ON "idle_policies"("team_id");
@@ -0,0 +1,22 @@
+-- Usage is metered per team as well as per user, against monthly budgets.
+
+ALTER TABLE "usage_records" ADD COLUMN IF NOT EXISTS "team_id" varchar(36);
+CREATE INDEX IF NOT EXISTS "idx_usage_records_team_id" ON "usage_records"("team_id");
+
+CREATE TABLE IF NOT EXISTS "budgets" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "team_id" varchar(36),
+    "monthly_limit" decimal(10,2),
+    "hard_stop" boolean DEFAULT false,
+    "alert_thresholds" varchar(50),
+    "alert_email" varchar(255),
+    "webhook_url" varchar(500),
+    "alert_period" varchar(7),
+    "alerted_percent" bigint,
+    "updated_at" timestamptz,
+    "updated_by" varchar(36),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_budgets_user_id" ON "budgets"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_budgets_team_id" ON "budgets"("team_id");
@@ -0,0 +1,7 @@
+-- API keys are stored as salted hashes and looked up by their prefix, which
+-- now includes cm_. Keys stored before have no salt; the server hashes them
+-- when it starts.
+
+ALTER TABLE "api_keys" ALTER COLUMN "key_prefix" TYPE varchar(16);
+ALTER TABLE "api_keys" ADD COLUMN IF NOT EXISTS "key_salt" varchar(32);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_key_prefix" ON "api_keys"("key_prefix");
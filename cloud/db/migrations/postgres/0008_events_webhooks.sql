@@ -0,0 +1,48 @@
+-- Events for the events API, and webhooks with their delivery queue.
+
+CREATE TABLE IF NOT EXISTS "events" (
+    "id" bigserial PRIMARY KEY,
+    "type" varchar(50),
+    "user_id" varchar(36),
+    "team_id" varchar(36),
+    "instance_id" varchar(36),
+    "data" text,
+    "created_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_events_user_id_id" ON "events"("user_id", "id");
+CREATE INDEX IF NOT EXISTS "idx_events_team_id_id" ON "events"("team_id", "id");
+CREATE INDEX IF NOT EXISTS "idx_events_created_at" ON "events"("created_at");
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" varchar(36),
+    "user_id" varchar(36),
+    "team_id" varchar(36),
+    "url" varchar(500),
+    "description" varchar(255),
+    "events" varchar(500),
+    "secret" text,
+    "active" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_team_id" ON "webhooks"("team_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" varchar(36),
+    "webhook_id" varchar(36),
+    "event_id" bigint,
+    "event_type" varchar(50),
+    "status" varchar(20),
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "response_code" bigint,
+    "last_error" varchar(500),
+    "created_at" timestamptz,
+    "delivered_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries"("webhook_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_created_at" ON "webhook_deliveries"("created_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status_next_attempt_at" ON "webhook_deliveries"("status", "next_attempt_at");
@@ -0,0 +1,45 @@
+-- Volumes that move between instances, and their snapshots.
+
+CREATE TABLE IF NOT EXISTS "volumes" (
+    "id" varchar(36),
+    "owner_id" varchar(36),
+    "team_id" varchar(36),
+    "name" varchar(100),
+    "provider" varchar(50),
+    "region" varchar(50),
+    "zone" varchar(50),
+    "size_gb" bigint,
+    "provider_id" varchar(100),
+    "status" varchar(20),
+    "status_reason" varchar(255),
+    "instance_id" varchar(36),
+    "device" varchar(255),
+    "mount_path" varchar(255),
+    "mounted" boolean,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_volumes_owner_id" ON "volumes"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_volumes_team_id" ON "volumes"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_volumes_instance_id" ON "volumes"("instance_id");
+
+CREATE TABLE IF NOT EXISTS "volume_snapshots" (
+    "id" varchar(36),
+    "volume_id" varchar(36),
+    "owner_id" varchar(36),
+    "team_id" varchar(36),
+    "method" varchar(20),
+    "provider" varchar(50),
+    "region" varchar(50),
+    "size_gb" bigint,
+    "provider_id" varchar(100),
+    "description" varchar(255),
+    "status" varchar(20),
+    "status_reason" varchar(500),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_volume_id" ON "volume_snapshots"("volume_id");
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_owner_id" ON "volume_snapshots"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_team_id" ON "volume_snapshots"("team_id");
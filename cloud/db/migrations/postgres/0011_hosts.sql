@@ -0,0 +1,20 @@
+-- Machines registered to run instances on, through their agents.
+
+CREATE TABLE IF NOT EXISTS "hosts" (
+    "id" varchar(36),
+    "owner_id" varchar(36),
+    "team_id" varchar(36),
+    "name" varchar(100),
+    "address" varchar(255),
+    "token_hash" varchar(64),
+    "vcpu" bigint,
+    "memory_mb" bigint,
+    "gpus" varchar(1000),
+    "docker_version" varchar(50),
+    "last_seen_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_hosts_owner_id" ON "hosts"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_hosts_team_id" ON "hosts"("team_id");
@@ -0,0 +1,30 @@
+-- Teams' registries, and the prebuilt devcontainer images pushed to them.
+
+ALTER TABLE "teams" ADD COLUMN IF NOT EXISTS "registry_url" varchar(255);
+ALTER TABLE "teams" ADD COLUMN IF NOT EXISTS "registry_username" varchar(255);
+ALTER TABLE "teams" ADD COLUMN IF NOT EXISTS "registry_password" text;
+
+CREATE TABLE IF NOT EXISTS "prebuilds" (
+    "id" varchar(36),
+    "team_id" varchar(36),
+    "created_by" varchar(36),
+    "repo_url" varchar(500),
+    "ref" varchar(255),
+    "config_path" varchar(255),
+    "git_token" text,
+    "provider" varchar(50),
+    "instance_type" varchar(50),
+    "region" varchar(50),
+    "instance_id" varchar(36),
+    "status" varchar(20),
+    "status_reason" varchar(1000),
+    "config_hash" varchar(64),
+    "image" varchar(500),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "finished_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_team_id" ON "prebuilds"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_status" ON "prebuilds"("status");
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_config_hash" ON "prebuilds"("config_hash");
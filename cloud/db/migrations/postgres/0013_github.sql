@@ -0,0 +1,39 @@
+-- GitHub repositories linked to teams, and the dev environments of their
+-- pull requests.
+
+CREATE TABLE IF NOT EXISTS "repo_links" (
+    "id" varchar(36),
+    "team_id" varchar(36),
+    "created_by" varchar(36),
+    "repo" varchar(255),
+    "provider" varchar(50),
+    "instance_type" varchar(50),
+    "region" varchar(50),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_repo_links_team_id" ON "repo_links"("team_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_repo_links_repo" ON "repo_links"("repo");
+
+CREATE TABLE IF NOT EXISTS "pr_environments" (
+    "id" varchar(36),
+    "repo_id" varchar(36),
+    "team_id" varchar(36),
+    "repo" varchar(255),
+    "number" bigint,
+    "branch" varchar(255),
+    "head_sha" varchar(40),
+    "author" varchar(100),
+    "instance_id" varchar(36),
+    "installation_id" bigint,
+    "comment_id" bigint,
+    "status" varchar(20),
+    "status_reason" varchar(1000),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_pr_environments_repo_id" ON "pr_environments"("repo_id");
+CREATE INDEX IF NOT EXISTS "idx_pr_environments_team_id" ON "pr_environments"("team_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_pr_environments_repo_number" ON "pr_environments"("repo", "number");
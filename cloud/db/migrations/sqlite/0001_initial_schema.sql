@@ -0,0 +1,191 @@
+-- The schema of the last release before versioned migrations, as its
+-- AutoMigrate created it. Databases from that release already have it, so
+-- every statement is a no-op on them; later changes are migrations of
+-- their own.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" text,
+    "email" text,
+    "name" text,
+    "password_hash" text,
+    "avatar_url" text,
+    "git_hub_id" text,
+    "google_id" text,
+    "stripe_customer_id" text,
+    "email_verified" numeric DEFAULT false,
+    "is_active" numeric DEFAULT true,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users"("email");
+CREATE INDEX IF NOT EXISTS "idx_users_git_hub_id" ON "users"("git_hub_id");
+CREATE INDEX IF NOT EXISTS "idx_users_google_id" ON "users"("google_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "teams" (
+    "id" text,
+    "name" text,
+    "slug" text,
+    "owner_id" text,
+    "stripe_customer_id" text,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_teams_slug" ON "teams"("slug");
+CREATE INDEX IF NOT EXISTS "idx_teams_owner_id" ON "teams"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_teams_deleted_at" ON "teams"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "team_members" (
+    "id" text,
+    "team_id" text,
+    "user_id" text,
+    "role" text DEFAULT 'member',
+    "joined_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_team_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_teams_members" FOREIGN KEY ("team_id") REFERENCES "teams"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_team_members_team_id" ON "team_members"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_team_members_user_id" ON "team_members"("user_id");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" text,
+    "user_id" text,
+    "name" text,
+    "key_prefix" text,
+    "key_hash" text,
+    "scopes" text,
+    "last_used_at" datetime,
+    "expires_at" datetime,
+    "created_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_api_keys" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys"("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_deleted_at" ON "api_keys"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "cloud_credentials" (
+    "id" text,
+    "user_id" text,
+    "provider" text,
+    "name" text,
+    "encrypted_data" text,
+    "is_verified" numeric DEFAULT false,
+    "last_verified" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_credentials" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_cloud_credentials_user_id" ON "cloud_credentials"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_cloud_credentials_deleted_at" ON "cloud_credentials"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "instances" (
+    "id" text,
+    "owner_id" text,
+    "team_id" text,
+    "name" text,
+    "provider" text,
+    "region" text,
+    "zone" text,
+    "instance_type" text,
+    "status" text DEFAULT 'pending',
+    "status_reason" text,
+    "public_ip" text,
+    "private_ip" text,
+    "ssh_port" integer DEFAULT 22,
+    "provider_id" text,
+    "provider_data" text,
+    "hourly_rate" decimal(10,4),
+    "created_at" datetime,
+    "updated_at" datetime,
+    "started_at" datetime,
+    "stopped_at" datetime,
+    "deleted_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_teams_instances" FOREIGN KEY ("team_id") REFERENCES "teams"("id"),
+    CONSTRAINT "fk_users_instances" FOREIGN KEY ("owner_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_instances_owner_id" ON "instances"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_instances_team_id" ON "instances"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_instances_deleted_at" ON "instances"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "usage_records" (
+    "id" text,
+    "user_id" text,
+    "instance_id" text,
+    "type" text,
+    "quantity" decimal(20,6),
+    "unit" text,
+    "unit_price" decimal(10,6),
+    "total_cost" decimal(10,4),
+    "timestamp" datetime,
+    "period_start" datetime,
+    "period_end" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_usage_records_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_usage_records_instance" FOREIGN KEY ("instance_id") REFERENCES "instances"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_usage_records_user_id" ON "usage_records"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_instance_id" ON "usage_records"("instance_id");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_timestamp" ON "usage_records"("timestamp");
+
+CREATE TABLE IF NOT EXISTS "invoices" (
+    "id" text,
+    "user_id" text,
+    "number" text,
+    "status" text,
+    "subtotal" integer,
+    "tax" integer,
+    "total" integer,
+    "amount_paid" integer,
+    "amount_due" integer,
+    "currency" text DEFAULT 'USD',
+    "stripe_invoice_id" text,
+    "stripe_payment_intent_id" text,
+    "invoice_url" text,
+    "period_start" datetime,
+    "period_end" datetime,
+    "due_date" datetime,
+    "paid_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_invoices_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_invoices_user_id" ON "invoices"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invoices_number" ON "invoices"("number");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" text,
+    "user_id" text,
+    "token" text,
+    "user_agent" text,
+    "ip_address" text,
+    "created_at" datetime,
+    "expires_at" datetime,
+    "last_active_at" datetime,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_token" ON "sessions"("token");
+
+CREATE TABLE IF NOT EXISTS "system_configs" (
+    "id" text,
+    "key" text,
+    "value" text,
+    "is_secret" numeric DEFAULT false,
+    "description" text,
+    "updated_at" datetime,
+    "updated_by" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_system_configs_key" ON "system_configs"("key");
@@ -0,0 +1,22 @@
+-- Instances report heartbeats and activity from their agent, and idle
+-- policies stop them when idle or off hours.
+
+ALTER TABLE "instances" ADD COLUMN "agent_token_hash" text;
+ALTER TABLE "instances" ADD COLUMN "last_heartbeat_at" datetime;
+ALTER TABLE "instances" ADD COLUMN "last_activity_at" datetime;
+
+CREATE TABLE IF NOT EXISTS "idle_policies" (
+    "id" text,
+    "user_id" text,
+    "team_id" text,
+    "idle_minutes" integer,
+    "warn_minutes" integer,
+    "shutdown_at" text,
+    "timezone" text,
+    "weekdays" text,
+    "updated_at" datetime,
+    "updated_by" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idle_policies_user_id" ON "idle_policies"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idle_policies_team_id" ON "idle_policies"("team_id");
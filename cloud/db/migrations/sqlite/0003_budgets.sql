@@ -0,0 +1,22 @@
+-- Usage is metered per team as well as per user, against monthly budgets.
+
+ALTER TABLE "usage_records" ADD COLUMN "team_id" text;
+CREATE INDEX IF NOT EXISTS "idx_usage_records_team_id" ON "usage_records"("team_id");
+
+CREATE TABLE IF NOT EXISTS "budgets" (
+    "id" text,
+    "user_id" text,
+    "team_id" text,
+    "monthly_limit" decimal(10,2),
+    "hard_stop" numeric DEFAULT false,
+    "alert_thresholds" text,
+    "alert_email" text,
+    "webhook_url" text,
+    "alert_period" text,
+    "alerted_percent" integer,
+    "updated_at" datetime,
+    "updated_by" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_budgets_user_id" ON "budgets"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_budgets_team_id" ON "budgets"("team_id");
@@ -0,0 +1,10 @@
+-- A user is in a team once, and cloud credentials can be shared with a team.
+
+DELETE FROM "team_members" WHERE "id" NOT IN (
+    SELECT MIN("id") FROM "team_members" GROUP BY "team_id", "user_id"
+);
+DROP INDEX IF EXISTS "idx_team_members_team_id";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_team_members_team_user" ON "team_members"("team_id", "user_id");
+
+ALTER TABLE "cloud_credentials" ADD COLUMN "team_id" text;
+CREATE INDEX IF NOT EXISTS "idx_cloud_credentials_team_id" ON "cloud_credentials"("team_id");
@@ -0,0 +1,5 @@
+-- API keys are stored as salted hashes and looked up by their prefix. Keys
+-- stored before have no salt; the server hashes them when it starts.
+
+ALTER TABLE "api_keys" ADD COLUMN "key_salt" text;
+CREATE INDEX IF NOT EXISTS "idx_api_keys_key_prefix" ON "api_keys"("key_prefix");
@@ -0,0 +1,10 @@
+-- Indexes for the instance lists, the idle, budget and metering sweeps over
+-- instances by status, and usage totals over a period.
+
+CREATE INDEX IF NOT EXISTS "idx_instances_status" ON "instances"("status");
+CREATE INDEX IF NOT EXISTS "idx_instances_owner_status" ON "instances"("owner_id", "status");
+CREATE INDEX IF NOT EXISTS "idx_instances_team_status" ON "instances"("team_id", "status");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_user_timestamp" ON "usage_records"("user_id", "timestamp");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_team_timestamp" ON "usage_records"("team_id", "timestamp");
+CREATE INDEX IF NOT EXISTS "idx_usage_records_instance_period" ON "usage_records"("instance_id", "period_end");
+CREATE INDEX IF NOT EXISTS "idx_sessions_expires_at" ON "sessions"("expires_at");
@@ -0,0 +1,4 @@
+-- Users signed in through OIDC single sign-on keep the issuer's subject.
+
+ALTER TABLE "users" ADD COLUMN "oidc_subject" text;
+CREATE INDEX IF NOT EXISTS "idx_users_oidc_subject" ON "users"("oidc_subject");
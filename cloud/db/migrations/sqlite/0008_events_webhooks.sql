@@ -0,0 +1,48 @@
+-- Events for the events API, and webhooks with their delivery queue.
+
+CREATE TABLE IF NOT EXISTS "events" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "type" text,
+    "user_id" text,
+    "team_id" text,
+    "instance_id" text,
+    "data" text,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_events_user_id_id" ON "events"("user_id", "id");
+CREATE INDEX IF NOT EXISTS "idx_events_team_id_id" ON "events"("team_id", "id");
+CREATE INDEX IF NOT EXISTS "idx_events_created_at" ON "events"("created_at");
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" text,
+    "user_id" text,
+    "team_id" text,
+    "url" text,
+    "description" text,
+    "events" text,
+    "secret" text,
+    "active" numeric DEFAULT true,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks"("user_id");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_team_id" ON "webhooks"("team_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" text,
+    "webhook_id" text,
+    "event_id" integer,
+    "event_type" text,
+    "status" text,
+    "attempts" integer,
+    "next_attempt_at" datetime,
+    "response_code" integer,
+    "last_error" text,
+    "created_at" datetime,
+    "delivered_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries"("webhook_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_created_at" ON "webhook_deliveries"("created_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status_next_attempt_at" ON "webhook_deliveries"("status", "next_attempt_at");
@@ -0,0 +1,7 @@
+-- Instances can be scheduled to be created later and to stop at a time.
+
+ALTER TABLE "instances" ADD COLUMN "create_at" datetime;
+ALTER TABLE "instances" ADD COLUMN "stop_at" datetime;
+ALTER TABLE "instances" ADD COLUMN "pending_config" text;
+CREATE INDEX IF NOT EXISTS "idx_instances_create_at" ON "instances"("create_at");
+CREATE INDEX IF NOT EXISTS "idx_instances_stop_at" ON "instances"("stop_at");
@@ -0,0 +1,45 @@
+-- Volumes that move between instances, and their snapshots.
+
+CREATE TABLE IF NOT EXISTS "volumes" (
+    "id" text,
+    "owner_id" text,
+    "team_id" text,
+    "name" text,
+    "provider" text,
+    "region" text,
+    "zone" text,
+    "size_gb" integer,
+    "provider_id" text,
+    "status" text,
+    "status_reason" text,
+    "instance_id" text,
+    "device" text,
+    "mount_path" text,
+    "mounted" numeric,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_volumes_owner_id" ON "volumes"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_volumes_team_id" ON "volumes"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_volumes_instance_id" ON "volumes"("instance_id");
+
+CREATE TABLE IF NOT EXISTS "volume_snapshots" (
+    "id" text,
+    "volume_id" text,
+    "owner_id" text,
+    "team_id" text,
+    "method" text,
+    "provider" text,
+    "region" text,
+    "size_gb" integer,
+    "provider_id" text,
+    "description" text,
+    "status" text,
+    "status_reason" text,
+    "created_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_volume_id" ON "volume_snapshots"("volume_id");
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_owner_id" ON "volume_snapshots"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_volume_snapshots_team_id" ON "volume_snapshots"("team_id");
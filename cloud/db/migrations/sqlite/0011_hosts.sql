@@ -0,0 +1,20 @@
+-- Machines registered to run instances on, through their agents.
+
+CREATE TABLE IF NOT EXISTS "hosts" (
+    "id" text,
+    "owner_id" text,
+    "team_id" text,
+    "name" text,
+    "address" text,
+    "token_hash" text,
+    "vcpu" integer,
+    "memory_mb" integer,
+    "gpus" text,
+    "docker_version" text,
+    "last_seen_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_hosts_owner_id" ON "hosts"("owner_id");
+CREATE INDEX IF NOT EXISTS "idx_hosts_team_id" ON "hosts"("team_id");
@@ -0,0 +1,30 @@
+-- Teams' registries, and the prebuilt devcontainer images pushed to them.
+
+ALTER TABLE "teams" ADD COLUMN "registry_url" text;
+ALTER TABLE "teams" ADD COLUMN "registry_username" text;
+ALTER TABLE "teams" ADD COLUMN "registry_password" text;
+
+CREATE TABLE IF NOT EXISTS "prebuilds" (
+    "id" text,
+    "team_id" text,
+    "created_by" text,
+    "repo_url" text,
+    "ref" text,
+    "config_path" text,
+    "git_token" text,
+    "provider" text,
+    "instance_type" text,
+    "region" text,
+    "instance_id" text,
+    "status" text,
+    "status_reason" text,
+    "config_hash" text,
+    "image" text,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "finished_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_team_id" ON "prebuilds"("team_id");
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_status" ON "prebuilds"("status");
+CREATE INDEX IF NOT EXISTS "idx_prebuilds_config_hash" ON "prebuilds"("config_hash");
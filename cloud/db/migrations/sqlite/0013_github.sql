@@ -0,0 +1,39 @@
+-- GitHub repositories linked to teams, and the dev environments of their
+-- pull requests.
+
+CREATE TABLE IF NOT EXISTS "repo_links" (
+    "id" text,
+    "team_id" text,
+    "created_by" text,
+    "repo" text,
+    "provider" text,
+    "instance_type" text,
+    "region" text,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_repo_links_team_id" ON "repo_links"("team_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_repo_links_repo" ON "repo_links"("repo");
+
+CREATE TABLE IF NOT EXISTS "pr_environments" (
+    "id" text,
+    "repo_id" text,
+    "team_id" text,
+    "repo" text,
+    "number" integer,
+    "branch" text,
+    "head_sha" text,
+    "author" text,
+    "instance_id" text,
+    "installation_id" integer,
+    "comment_id" integer,
+    "status" text,
+    "status_reason" text,
+    "created_at" datetime,
+    "updated_at" datetime,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_pr_environments_repo_id" ON "pr_environments"("repo_id");
+CREATE INDEX IF NOT EXISTS "idx_pr_environments_team_id" ON "pr_environments"("team_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_pr_environments_repo_number" ON "pr_environments"("repo", "number");
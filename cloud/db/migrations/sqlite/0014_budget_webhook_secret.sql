@@ -0,0 +1,3 @@
+-- Budget alerts are signed like webhook events, with a secret per budget.
+
+ALTER TABLE "budgets" ADD COLUMN "webhook_secret" text;
@@ -8,6 +8,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// The schema is created by the SQL migrations in cloud/db/migrations, not
+// from these models: a new field or index needs a migration for each driver.
+
 // SystemConfig stores system-wide configuration (OAuth, Stripe, etc.)
 // This is encrypted and stored in the database, not environment variables
 type SystemConfig struct {
@@ -45,6 +48,9 @@ type User struct {
 	GitHubID string `gorm:"size:50;index" json:"-"`
 	GoogleID string `gorm:"size:50;index" json:"-"`
 
+	// OIDC single sign-on: the issuer's subject identifier
+	OIDCSubject string `gorm:"column:oidc_subject;size:255;index" json:"-"`
+
 	// Stripe
 	StripeCustomerID string `gorm:"size:50" json:"-"`
 
@@ -89,6 +95,11 @@ type Team struct {
 	// Stripe
 	StripeCustomerID string `gorm:"size:50" json:"-"`
 
+	// Registry prebuilt images are pushed to
+	RegistryURL      string `gorm:"size:255" json:"registry_url,omitempty"` // Repository prefix, e.g. ghcr.io/acme/devcontainers
+	RegistryUsername string `gorm:"size:255" json:"registry_username,omitempty"`
+	RegistryPassword string `gorm:"type:text" json:"-"` // Encrypted
+
 	// Timestamps
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -99,12 +110,35 @@ type Team struct {
 	Instances []Instance   `gorm:"foreignKey:TeamID" json:"-"`
 }
 
+// Team roles, from most to least privileged
+const (
+	RoleOwner  = "owner"  // Everything, including deleting the team and promoting admins
+	RoleAdmin  = "admin"  // Manages members, team credentials, policies and all team instances
+	RoleMember = "member" // Creates and uses team instances
+	RoleViewer = "viewer" // Sees team instances and their logs
+)
+
+// RoleRank orders roles by privilege; unknown roles rank 0
+func RoleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 4
+	case RoleAdmin:
+		return 3
+	case RoleMember:
+		return 2
+	case RoleViewer:
+		return 1
+	}
+	return 0
+}
+
 // TeamMember represents a user's membership in a team
 type TeamMember struct {
 	ID       string    `gorm:"primaryKey;size:36" json:"id"`
-	TeamID   string    `gorm:"size:36;index" json:"team_id"`
-	UserID   string    `gorm:"size:36;index" json:"user_id"`
-	Role     string    `gorm:"size:50;default:'member'" json:"role"` // owner, admin, member
+	TeamID   string    `gorm:"size:36;uniqueIndex:idx_team_members_team_user" json:"team_id"`
+	UserID   string    `gorm:"size:36;index;uniqueIndex:idx_team_members_team_user" json:"user_id"`
+	Role     string    `gorm:"size:50;default:'member'" json:"role"` // owner, admin, member, viewer
 	JoinedAt time.Time `json:"joined_at"`
 
 	// Relations
@@ -117,11 +151,12 @@ type APIKey struct {
 	ID        string `gorm:"primaryKey;size:36" json:"id"`
 	UserID    string `gorm:"size:36;index" json:"user_id"`
 	Name      string `gorm:"size:100" json:"name"`
-	KeyPrefix string `gorm:"size:10" json:"key_prefix"` // First 8 chars for display
-	KeyHash   string `gorm:"size:255;uniqueIndex" json:"-"`
+	KeyPrefix string `gorm:"size:16;index" json:"key_prefix"` // cm_ and the first 8 chars, for display and lookup
+	KeySalt   string `gorm:"size:32" json:"-"`
+	KeyHash   string `gorm:"size:255;uniqueIndex" json:"-"` // SHA-256 of the salt and the key
 
 	// Permissions
-	Scopes string `gorm:"size:500" json:"scopes"` // Comma-separated: read,write,admin
+	Scopes string `gorm:"size:500" json:"scopes"` // Comma-separated: all, read, instances:write, billing:read
 
 	// Timestamps
 	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
@@ -135,10 +170,11 @@ type APIKey struct {
 
 // CloudCredential stores encrypted cloud provider credentials
 type CloudCredential struct {
-	ID       string `gorm:"primaryKey;size:36" json:"id"`
-	UserID   string `gorm:"size:36;index" json:"user_id"`
-	Provider string `gorm:"size:50" json:"provider"` // aws, gcp, azure, etc.
-	Name     string `gorm:"size:100" json:"name"`
+	ID       string  `gorm:"primaryKey;size:36" json:"id"`
+	UserID   string  `gorm:"size:36;index" json:"user_id"`
+	TeamID   *string `gorm:"size:36;index" json:"team_id,omitempty"` // Shared with the team's members
+	Provider string  `gorm:"size:50" json:"provider"`                // aws, gcp, azure, etc.
+	Name     string  `gorm:"size:100" json:"name"`
 
 	// Encrypted credentials (JSON blob encrypted with user's key)
 	EncryptedData string `gorm:"type:text" json:"-"`
@@ -170,7 +206,7 @@ type Instance struct {
 	InstanceType string `gorm:"size:50" json:"instance_type"`
 
 	// Status
-	Status       string `gorm:"size:50;default:'pending'" json:"status"` // pending, provisioning, running, stopped, terminated, error
+	Status       string `gorm:"size:50;default:'pending'" json:"status"` // pending, scheduled, provisioning, running, stopped, terminated, error
 	StatusReason string `gorm:"size:255" json:"status_reason,omitempty"`
 
 	// Networking
@@ -185,6 +221,16 @@ type Instance struct {
 	// Pricing
 	HourlyRate float64 `gorm:"type:decimal(10,4)" json:"hourly_rate"`
 
+	// Schedule
+	CreateAt      *time.Time `gorm:"index" json:"create_at,omitempty"` // Provisioned then; the status is "scheduled" until
+	StopAt        *time.Time `gorm:"index" json:"stop_at,omitempty"`   // Stopped then, unless postponed
+	PendingConfig string     `gorm:"type:text" json:"-"`               // JSON of what provisioning a scheduled instance needs
+
+	// Activity reported by the cm agent on the instance
+	AgentTokenHash  string     `gorm:"size:64" json:"-"` // SHA-256 of the token the agent authenticates with
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+	LastActivityAt  *time.Time `json:"last_activity_at,omitempty"`
+
 	// Timestamps
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -197,14 +243,207 @@ type Instance struct {
 	Team  *Team `gorm:"foreignKey:TeamID" json:"-"`
 }
 
+// Host is a machine a user or team registered to run instances on. Its cm
+// agent connects with the host's token and reports its capacity, and
+// instances placed on it run as Docker containers.
+type Host struct {
+	ID      string  `gorm:"primaryKey;size:36" json:"id"`
+	OwnerID string  `gorm:"size:36;index" json:"owner_id"`
+	TeamID  *string `gorm:"size:36;index" json:"team_id,omitempty"` // Runs the team's instances instead of the owner's
+
+	Name      string `gorm:"size:100" json:"name"`
+	Address   string `gorm:"size:255" json:"address"` // Where instances' SSH ports are reached; the agent's IP unless set
+	TokenHash string `gorm:"size:64" json:"-"`        // SHA-256 of the token its agent authenticates with
+
+	// Capacity, as its agent last reported it
+	VCPU          int        `gorm:"column:vcpu" json:"vcpu"`
+	MemoryMB      int64      `json:"memory_mb"`
+	GPUs          string     `gorm:"column:gpus;size:1000" json:"gpus,omitempty"` // Comma-separated GPU models, by device index
+	DockerVersion string     `gorm:"size:50" json:"docker_version,omitempty"`
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"` // When its agent last connected
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RepoLink links a GitHub repository to a team: the repository's pull
+// requests get dev environments, created in the team through the GitHub App
+type RepoLink struct {
+	ID        string `gorm:"primaryKey;size:36" json:"id"`
+	TeamID    string `gorm:"size:36;index" json:"team_id"`
+	CreatedBy string `gorm:"size:36" json:"created_by"`        // Owns the environments' instances
+	Repo      string `gorm:"size:255;uniqueIndex" json:"repo"` // owner/name, lowercase
+
+	// Instances of the environments
+	Provider     string `gorm:"size:50" json:"provider"`
+	InstanceType string `gorm:"size:50" json:"instance_type"`
+	Region       string `gorm:"size:50" json:"region"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PREnvironment is the dev environment of an open pull request in a linked
+// repository: an instance running the branch's dev container
+type PREnvironment struct {
+	ID         string  `gorm:"primaryKey;size:36" json:"id"`
+	RepoID     string  `gorm:"size:36;index" json:"repo_id"`
+	TeamID     string  `gorm:"size:36;index" json:"team_id"`
+	Repo       string  `gorm:"size:255;uniqueIndex:idx_pr_environments_repo_number" json:"repo"`
+	Number     int     `gorm:"uniqueIndex:idx_pr_environments_repo_number" json:"number"`
+	Branch     string  `gorm:"size:255" json:"branch"`
+	HeadSHA    string  `gorm:"size:40" json:"head_sha"`
+	Author     string  `gorm:"size:100" json:"author"` // GitHub login, whose SSH keys are authorized
+	InstanceID *string `gorm:"size:36" json:"instance_id,omitempty"`
+
+	InstallationID int64 `json:"-"` // Of the GitHub App, which comments as it
+	CommentID      int64 `json:"comment_id,omitempty"`
+
+	Status       string `gorm:"size:20" json:"status"` // provisioning, ready, failed
+	StatusReason string `gorm:"size:1000" json:"status_reason,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Prebuild is a job that builds a repository's devcontainer image, with
+// its features installed, on a builder instance and pushes it to its team's
+// registry. The CLI pulls the image of a configuration's hash instead of
+// building it.
+type Prebuild struct {
+	ID        string `gorm:"primaryKey;size:36" json:"id"`
+	TeamID    string `gorm:"size:36;index" json:"team_id"`
+	CreatedBy string `gorm:"size:36" json:"created_by"`
+
+	RepoURL    string `gorm:"size:500" json:"repo_url"`
+	Ref        string `gorm:"size:255" json:"ref,omitempty"`         // Branch or tag; the default branch if empty
+	ConfigPath string `gorm:"size:255" json:"config_path,omitempty"` // devcontainer.json in the repository, if not the default
+	GitToken   string `gorm:"type:text" json:"-"`                    // Encrypted, for private repositories
+
+	// Builder
+	Provider     string  `gorm:"size:50" json:"provider"`
+	InstanceType string  `gorm:"size:50" json:"instance_type"`
+	Region       string  `gorm:"size:50" json:"region"`
+	InstanceID   *string `gorm:"size:36" json:"instance_id,omitempty"` // While building
+
+	Status       string `gorm:"size:20;index" json:"status"` // building, ready, failed
+	StatusReason string `gorm:"size:1000" json:"status_reason,omitempty"`
+	ConfigHash   string `gorm:"size:64;index" json:"config_hash,omitempty"`
+	Image        string `gorm:"size:500" json:"image,omitempty"`
+
+	// Timestamps
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Volume is block storage that outlives instances and moves between them
+type Volume struct {
+	ID      string  `gorm:"primaryKey;size:36" json:"id"`
+	OwnerID string  `gorm:"size:36;index" json:"owner_id"`
+	TeamID  *string `gorm:"size:36;index" json:"team_id,omitempty"`
+
+	Name       string `gorm:"size:100" json:"name"`
+	Provider   string `gorm:"size:50" json:"provider"`
+	Region     string `gorm:"size:50" json:"region"`
+	Zone       string `gorm:"size:50" json:"zone,omitempty"`
+	SizeGB     int    `json:"size_gb"`
+	ProviderID string `gorm:"size:100" json:"provider_id,omitempty"`
+
+	Status       string `gorm:"size:20" json:"status"` // available, in-use, error
+	StatusReason string `gorm:"size:255" json:"status_reason,omitempty"`
+
+	// Attachment
+	InstanceID *string `gorm:"size:36;index" json:"instance_id,omitempty"`
+	Device     string  `gorm:"size:255" json:"-"` // Linux device path on the instance
+	MountPath  string  `gorm:"size:255" json:"mount_path,omitempty"`
+	Mounted    bool    `json:"mounted"` // Mounted by the instance's agent
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VolumeSnapshot is a point-in-time copy of a volume, kept by its provider
+// or in the restic repository. Snapshots outlive their volume.
+type VolumeSnapshot struct {
+	ID       string  `gorm:"primaryKey;size:36" json:"id"`
+	VolumeID string  `gorm:"size:36;index" json:"volume_id"`
+	OwnerID  string  `gorm:"size:36;index" json:"owner_id"`
+	TeamID   *string `gorm:"size:36;index" json:"team_id,omitempty"`
+
+	Method      string `gorm:"size:20" json:"method"` // provider or restic
+	Provider    string `gorm:"size:50" json:"provider"`
+	Region      string `gorm:"size:50" json:"region"`
+	SizeGB      int    `json:"size_gb"`
+	ProviderID  string `gorm:"size:100" json:"provider_id,omitempty"` // Provider or restic snapshot ID
+	Description string `gorm:"size:255" json:"description,omitempty"`
+
+	Status       string `gorm:"size:20" json:"status"` // pending, completed, error
+	StatusReason string `gorm:"size:500" json:"status_reason,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdlePolicy stops the running instances of a user, or of a team, that are
+// idle or still running at the end of the working day
+type IdlePolicy struct {
+	ID     string  `gorm:"primaryKey;size:36" json:"id"`
+	UserID *string `gorm:"size:36;uniqueIndex" json:"user_id,omitempty"`
+	TeamID *string `gorm:"size:36;uniqueIndex" json:"team_id,omitempty"` // Applies to the team's instances instead of the owner's policy
+
+	// Idle shutdown
+	IdleMinutes int `json:"idle_minutes"` // Stop after this long without activity; 0 disables
+	WarnMinutes int `json:"warn_minutes"` // Warn the owner this long before stopping
+
+	// Off-hours shutdown
+	ShutdownAt string `gorm:"size:5" json:"shutdown_at,omitempty"` // "HH:MM"; empty disables
+	Timezone   string `gorm:"size:64" json:"timezone,omitempty"`   // IANA name, UTC if empty
+	Weekdays   string `gorm:"size:50" json:"weekdays,omitempty"`   // e.g. "mon,tue,wed,thu,fri"; every day if empty
+
+	// Timestamps
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `gorm:"size:36" json:"updated_by,omitempty"`
+}
+
+// Budget caps the monthly spend of a user's, or a team's, instances and
+// alerts as the spend crosses thresholds
+type Budget struct {
+	ID     string  `gorm:"primaryKey;size:36" json:"id"`
+	UserID *string `gorm:"size:36;uniqueIndex" json:"user_id,omitempty"`
+	TeamID *string `gorm:"size:36;uniqueIndex" json:"team_id,omitempty"`
+
+	// Limit
+	MonthlyLimit float64 `gorm:"type:decimal(10,2)" json:"monthly_limit"` // USD per calendar month (UTC); 0 disables
+	HardStop     bool    `gorm:"default:false" json:"hard_stop"`          // Stop running instances and refuse new ones at the limit
+
+	// Alerts
+	AlertThresholds string `gorm:"size:50" json:"alert_thresholds"`       // Percentages of the limit, e.g. "50,80,100"
+	AlertEmail      string `gorm:"size:255" json:"alert_email,omitempty"` // The owner's email if empty
+	WebhookURL      string `gorm:"size:500" json:"webhook_url,omitempty"` // Receives a signed JSON POST per alert
+	WebhookSecret   string `gorm:"type:text" json:"-"`                    // Encrypted HMAC signing key
+
+	// Alert state
+	AlertPeriod    string `gorm:"size:7" json:"-"` // Month of the last alert, "2006-01"
+	AlertedPercent int    `json:"-"`               // Highest threshold alerted in AlertPeriod
+
+	// Timestamps
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `gorm:"size:36" json:"updated_by,omitempty"`
+}
+
 // UsageRecord tracks resource usage for billing
 type UsageRecord struct {
-	ID         string `gorm:"primaryKey;size:36" json:"id"`
-	UserID     string `gorm:"size:36;index" json:"user_id"`
-	InstanceID string `gorm:"size:36;index" json:"instance_id"`
+	ID         string  `gorm:"primaryKey;size:36" json:"id"`
+	UserID     string  `gorm:"size:36;index" json:"user_id"`
+	TeamID     *string `gorm:"size:36;index" json:"team_id,omitempty"`
+	InstanceID string  `gorm:"size:36;index" json:"instance_id"`
 
 	// Usage
-	Type      string  `gorm:"size:50" json:"type"` // compute, storage, network
+	Type      string  `gorm:"size:50" json:"type"` // cpu or gpu compute, storage, network
 	Quantity  float64 `gorm:"type:decimal(20,6)" json:"quantity"`
 	Unit      string  `gorm:"size:20" json:"unit"` // hours, gb, requests
 	UnitPrice float64 `gorm:"type:decimal(10,6)" json:"unit_price"`
@@ -276,3 +515,53 @@ type Session struct {
 	// Relations
 	User User `gorm:"foreignKey:UserID" json:"-"`
 }
+
+// Event is something that happened to an instance or budget, kept for the
+// events API and delivered to matching webhooks
+type Event struct {
+	ID         int64   `gorm:"primaryKey;autoIncrement" json:"id"` // Increases with each event; the events API's cursor
+	Type       string  `gorm:"size:50" json:"type"`                // e.g. instance.created, budget.exceeded
+	UserID     string  `gorm:"size:36" json:"user_id,omitempty"`   // The owner of what it's about; team events go by TeamID
+	TeamID     *string `gorm:"size:36" json:"team_id,omitempty"`
+	InstanceID string  `gorm:"size:36" json:"instance_id,omitempty"`
+	Data       string  `gorm:"type:text" json:"-"` // JSON details
+
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Webhook receives a signed JSON POST for each of a user's, or a team's,
+// events of the types it subscribes to
+type Webhook struct {
+	ID     string  `gorm:"primaryKey;size:36" json:"id"`
+	UserID string  `gorm:"size:36;index" json:"user_id"` // Creator; the owner unless TeamID is set
+	TeamID *string `gorm:"size:36;index" json:"team_id,omitempty"`
+
+	URL         string `gorm:"size:500" json:"url"`
+	Description string `gorm:"size:255" json:"description,omitempty"`
+	Events      string `gorm:"size:500" json:"events"` // Comma-separated event types; every type if empty
+	Secret      string `gorm:"type:text" json:"-"`     // Encrypted HMAC signing key
+	Active      bool   `gorm:"default:true" json:"active"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an event queued for, or delivered to, a webhook
+type WebhookDelivery struct {
+	ID        string `gorm:"primaryKey;size:36" json:"id"`
+	WebhookID string `gorm:"size:36;index" json:"webhook_id"`
+	EventID   int64  `json:"event_id"`
+	EventType string `gorm:"size:50" json:"event_type"`
+
+	// State
+	Status        string     `gorm:"size:20" json:"status"` // pending, delivered or failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // While pending
+	ResponseCode  int        `json:"response_code,omitempty"`   // Of the last attempt
+	LastError     string     `gorm:"size:500" json:"last_error,omitempty"`
+
+	// Timestamps
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
@@ -3,25 +3,56 @@ package providers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	pricingtypes "github.com/aws/aws-sdk-go-v2/service/pricing/types"
 )
 
+// awsSecurityGroup is the security group instances are launched into
+const awsSecurityGroup = "cm-dev-environments"
+
+// awsInstanceTypes maps compute tiers to EC2 instance types
+var awsInstanceTypes = map[InstanceType]string{
+	InstanceTypeCPUSmall:  "t3.medium",
+	InstanceTypeCPUMedium: "t3.xlarge",
+	InstanceTypeCPULarge:  "t3.2xlarge",
+	InstanceTypeGPUT4:     "g4dn.xlarge",
+	InstanceTypeGPUA10:    "g5.2xlarge",
+	InstanceTypeGPUA100:   "p4d.24xlarge",
+}
+
 // AWSProvider implements the Provider interface for Amazon Web Services
 type AWSProvider struct {
-	mu          sync.RWMutex
-	configured  bool
-	accessKeyID string
-	secretKey   string
-	region      string
-	instances   map[string]*Instance
+	mu           sync.RWMutex
+	configured   bool
+	accessKeyID  string
+	secretKey    string
+	sessionToken string
+	region       string
+	endpoint     string // Optional, e.g. http://localhost:4566 for LocalStack
+	amiID        string // Optional, overrides AMI selection
+	amis         map[string]string
 }
 
 // NewAWSProvider creates a new AWS provider
 func NewAWSProvider() *AWSProvider {
 	return &AWSProvider{
-		instances: make(map[string]*Instance),
-		region:    "us-east-1",
+		amis:   make(map[string]string),
+		region: "us-east-1",
 	}
 }
 
@@ -32,29 +63,40 @@ func (p *AWSProvider) Description() string {
 }
 func (p *AWSProvider) Website() string { return "https://aws.amazon.com" }
 func (p *AWSProvider) Features() []string {
-	return []string{"ec2", "gpu", "spot-instances", "auto-scaling", "global-regions"}
+	return []string{"ec2", "ebs-volumes", "gpu", "spot-instances", "auto-scaling", "global-regions"}
 }
 func (p *AWSProvider) RequiredCredentials() []string {
 	return []string{"access_key_id", "secret_access_key", "region"}
 }
 
+// Configure also accepts the optional session_token, endpoint and ami_id
 func (p *AWSProvider) Configure(credentials map[string]string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	p.accessKeyID = credentials["access_key_id"]
 	p.secretKey = credentials["secret_access_key"]
-	if region, ok := credentials["region"]; ok {
+	p.sessionToken = credentials["session_token"]
+	p.endpoint = credentials["endpoint"]
+	p.amiID = credentials["ami_id"]
+	if region, ok := credentials["region"]; ok && region != "" {
 		p.region = region
 	}
+	p.amis = make(map[string]string)
 	p.configured = p.accessKeyID != "" && p.secretKey != ""
 	return nil
 }
 
+// IsAvailable checks the credentials against the EC2 API
 func (p *AWSProvider) IsAvailable(ctx context.Context) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.configured
+	client, err := p.client("")
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	return err == nil
 }
 
 func (p *AWSProvider) Regions() []Region {
@@ -81,45 +123,447 @@ func (p *AWSProvider) InstanceTypes() []InstancePricing {
 	}
 }
 
-func (p *AWSProvider) CreateInstance(ctx context.Context, config InstanceConfig) (*Instance, error) {
+// config returns the SDK configuration for a region, or the configured
+// region if empty, and the endpoint override
+func (p *AWSProvider) config(region string) (aws.Config, string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	if !p.configured {
-		return nil, fmt.Errorf("AWS provider not configured")
+		return aws.Config{}, "", fmt.Errorf("AWS provider not configured")
 	}
-	// TODO: Implement actual AWS EC2 SDK call
-	return nil, fmt.Errorf("AWS CreateInstance not yet implemented - requires AWS SDK")
+	if region == "" {
+		region = p.region
+	}
+	return awsConfig(p.accessKeyID, p.secretKey, p.sessionToken, region), p.endpoint, nil
 }
 
-func (p *AWSProvider) GetInstance(ctx context.Context, id string) (*Instance, error) {
+// client returns an EC2 client for a region, or the configured region if empty
+func (p *AWSProvider) client(region string) (*ec2Client, error) {
+	cfg, endpoint, err := p.config(region)
+	if err != nil {
+		return nil, err
+	}
+	return newEC2Client(cfg, endpoint), nil
+}
+
+// EC2 instance, EBS volume and snapshot IDs are only unique within a
+// region, so they are identified as "<region>/<id>"
+func awsInstanceID(region, id string) string {
+	return region + "/" + id
+}
+
+func parseAWSInstanceID(id string) (region, instanceID string) {
+	if region, instanceID, ok := strings.Cut(id, "/"); ok {
+		return region, instanceID
+	}
+	return "", id
+}
+
+func (p *AWSProvider) CreateInstance(ctx context.Context, config InstanceConfig) (*Instance, error) {
+	client, err := p.client(config.Region)
+	if err != nil {
+		return nil, err
+	}
+	ec2Type, ok := awsInstanceTypes[config.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported instance type for AWS: %s", config.Type)
+	}
+
+	imageID, err := p.selectAMI(ctx, client, config.Type)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := ensureSecurityGroup(ctx, client, config.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &ec2.RunInstancesInput{
+		ImageId:          aws.String(imageID),
+		InstanceType:     types.InstanceType(ec2Type),
+		MinCount:         aws.Int32(1),
+		MaxCount:         aws.Int32(1),
+		SecurityGroupIds: []string{groupID},
+		UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte(awsUserData(config)))),
+	}
+	if config.SSHPublicKey != "" {
+		keyName, err := ensureKeyPair(ctx, client, config.SSHPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		input.KeyName = aws.String(keyName)
+	}
+
+	tags := map[string]string{
+		"Name":       config.Name,
+		"cm:managed": "true",
+		"cm:type":    string(config.Type),
+	}
+	if config.OwnerID != "" {
+		tags["cm:owner"] = config.OwnerID
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		tags["cm:request-id"] = requestID
+	}
+	input.TagSpecifications = tagSpecifications(types.ResourceTypeInstance, tags)
+
+	resp, err := client.RunInstances(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch instance: %w", err)
+	}
+	if len(resp.Instances) == 0 {
+		return nil, fmt.Errorf("failed to launch instance: no instance returned")
+	}
+	return p.toInstance(client.region, &resp.Instances[0]), nil
+}
+
+// tagSpecifications tags the resource a request creates
+func tagSpecifications(resourceType types.ResourceType, tags map[string]string) []types.TagSpecification {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	spec := types.TagSpecification{ResourceType: resourceType}
+	for _, k := range keys {
+		spec.Tags = append(spec.Tags, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return []types.TagSpecification{spec}
+}
+
+// selectAMI picks the newest image for an instance type: the Deep Learning
+// Base AMI, which ships NVIDIA drivers, for GPU types and Ubuntu otherwise
+func (p *AWSProvider) selectAMI(ctx context.Context, client *ec2Client, t InstanceType) (string, error) {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
-	if inst, ok := p.instances[id]; ok {
-		return inst, nil
+	override := p.amiID
+	cached := p.amis[client.region+"/"+string(t)]
+	p.mu.RUnlock()
+	if override != "" {
+		return override, nil
+	}
+	if cached != "" {
+		return cached, nil
+	}
+
+	owner, name := "099720109477", "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*" // Canonical
+	if strings.HasPrefix(string(t), "gpu-") {
+		owner, name = "amazon", "Deep Learning Base OSS Nvidia Driver GPU AMI (Ubuntu 22.04) *"
+	}
+	resp, err := client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{owner},
+		Filters: []types.Filter{
+			{Name: aws.String("name"), Values: []string{name}},
+			{Name: aws.String("state"), Values: []string{"available"}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find an AMI: %w", err)
+	}
+	if len(resp.Images) == 0 {
+		return "", fmt.Errorf("no AMI matching %q in %s", name, client.region)
+	}
+	newest := resp.Images[0]
+	for _, img := range resp.Images[1:] {
+		if aws.ToString(img.CreationDate) > aws.ToString(newest.CreationDate) {
+			newest = img
+		}
+	}
+
+	imageID := aws.ToString(newest.ImageId)
+	p.mu.Lock()
+	p.amis[client.region+"/"+string(t)] = imageID
+	p.mu.Unlock()
+	return imageID, nil
+}
+
+// ensureSecurityGroup returns the ID of the security group of the default
+// VPC that allows SSH and the given ports, creating it if needed
+func ensureSecurityGroup(ctx context.Context, client *ec2Client, ports []int) (string, error) {
+	described, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{{Name: aws.String("group-name"), Values: []string{awsSecurityGroup}}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up security group: %w", err)
+	}
+
+	var groupID string
+	if len(described.SecurityGroups) > 0 {
+		groupID = aws.ToString(described.SecurityGroups[0].GroupId)
+	} else {
+		created, err := client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+			GroupName:   aws.String(awsSecurityGroup),
+			Description: aws.String("Container-Maker development environments"),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create security group: %w", err)
+		}
+		groupID = aws.ToString(created.GroupId)
+	}
+
+	for _, port := range append([]int{22}, ports...) {
+		_, err := client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId: aws.String(groupID),
+			IpPermissions: []types.IpPermission{{
+				IpProtocol: aws.String("tcp"),
+				FromPort:   aws.Int32(int32(port)),
+				ToPort:     aws.Int32(int32(port)),
+				IpRanges:   []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			}},
+		})
+		if err != nil && !isEC2Error(err, "InvalidPermission.Duplicate") {
+			return "", fmt.Errorf("failed to open port %d: %w", port, err)
+		}
+	}
+	return groupID, nil
+}
+
+// ensureKeyPair imports an SSH public key, named after its hash so each key
+// is imported once, and returns its name
+func ensureKeyPair(ctx context.Context, client *ec2Client, publicKey string) (string, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	sum := sha256.Sum256([]byte(publicKey))
+	name := "cm-" + hex.EncodeToString(sum[:8])
+
+	_, err := client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{KeyNames: []string{name}})
+	if err == nil {
+		return name, nil
+	}
+	if !isEC2Error(err, "InvalidKeyPair.NotFound") {
+		return "", fmt.Errorf("failed to look up key pair: %w", err)
+	}
+
+	// The SDK encodes the key material in base64
+	_, err = client.ImportKeyPair(ctx, &ec2.ImportKeyPairInput{
+		KeyName:           aws.String(name),
+		PublicKeyMaterial: []byte(publicKey),
+	})
+	if err != nil && !isEC2Error(err, "InvalidKeyPair.Duplicate") {
+		return "", fmt.Errorf("failed to import key pair: %w", err)
+	}
+	return name, nil
+}
+
+// awsUserData returns the bootstrap script run on first boot: it installs
+// Docker and the cm CLI, and the cm agent as a service started at every boot
+func awsUserData(config InstanceConfig) string {
+	var sb strings.Builder
+	sb.WriteString(`#!/bin/bash
+set -euxo pipefail
+
+if ! command -v docker >/dev/null; then
+  curl -fsSL https://get.docker.com | sh
+fi
+usermod -aG docker ubuntu || true
+
+arch=$(uname -m)
+case "$arch" in
+  aarch64) arch=arm64 ;;
+  *) arch=amd64 ;;
+esac
+curl -fsSLo /usr/local/bin/cm "https://github.com/UPwith-me/Container-Maker/releases/latest/download/cm-linux-$arch"
+chmod +x /usr/local/bin/cm
+`)
+
+	if len(config.Env) > 0 {
+		keys := make([]string, 0, len(config.Env))
+		for k := range config.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("\ncat > /etc/profile.d/cm-env.sh <<'CM_ENV'\n")
+		for _, k := range keys {
+			if !envNamePattern.MatchString(k) {
+				continue
+			}
+			fmt.Fprintf(&sb, "export %s=%s\n", k, shellQuote(config.Env[k]))
+		}
+		sb.WriteString("CM_ENV\n")
+	}
+
+	if config.Image != "" {
+		fmt.Fprintf(&sb, "\ndocker pull %s || true\n", shellQuote(config.Image))
+	}
+	fmt.Fprintf(&sb, "\ncat > /etc/systemd/system/cm-agent.service <<'CM_UNIT'\n%sCM_UNIT\n", agentUnit("ubuntu"))
+	sb.WriteString("systemctl daemon-reload\nsystemctl enable --now cm-agent\n")
+	return sb.String()
+}
+
+// agentUnit returns the systemd unit that runs the cm agent as user at
+// every boot; a login shell gives it the environment in /etc/profile.d
+func agentUnit(user string) string {
+	return fmt.Sprintf(`[Unit]
+Description=Container-Maker agent
+Wants=network-online.target docker.service
+After=network-online.target docker.service
+
+[Service]
+User=%s
+ExecStart=/bin/bash -lc 'exec cm agent run'
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, user)
+}
+
+// envNamePattern matches the environment variable names a shell accepts
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// toInstance converts an EC2 instance
+func (p *AWSProvider) toInstance(region string, i *types.Instance) *Instance {
+	tag := func(key string) string {
+		for _, t := range i.Tags {
+			if aws.ToString(t.Key) == key {
+				return aws.ToString(t.Value)
+			}
+		}
+		return ""
+	}
+	var state types.InstanceStateName
+	if i.State != nil {
+		state = i.State.Name
+	}
+	var zone string
+	if i.Placement != nil {
+		zone = aws.ToString(i.Placement.AvailabilityZone)
+	}
+	inst := &Instance{
+		ID:        awsInstanceID(region, aws.ToString(i.InstanceId)),
+		Name:      tag("Name"),
+		Type:      InstanceType(tag("cm:type")),
+		Status:    awsStatus(state),
+		Provider:  ProviderAWS,
+		Region:    region,
+		PublicIP:  aws.ToString(i.PublicIpAddress),
+		PrivateIP: aws.ToString(i.PrivateIpAddress),
+		SSHPort:   22,
+		CreatedAt: aws.ToTime(i.LaunchTime),
+		UpdatedAt: time.Now(),
+		OwnerID:   tag("cm:owner"),
+		Metadata: map[string]string{
+			"ec2_instance_id":   aws.ToString(i.InstanceId),
+			"ec2_instance_type": string(i.InstanceType),
+			"image_id":          aws.ToString(i.ImageId),
+			"availability_zone": zone,
+		},
+	}
+	for _, pricing := range p.InstanceTypes() {
+		if pricing.Type == inst.Type {
+			inst.HourlyRate = pricing.HourlyRate
+			break
+		}
+	}
+	return inst
+}
+
+// awsStatus maps EC2 instance states
+func awsStatus(state types.InstanceStateName) InstanceStatus {
+	switch state {
+	case types.InstanceStateNamePending:
+		return StatusProvisioning
+	case types.InstanceStateNameRunning:
+		return StatusRunning
+	case types.InstanceStateNameStopping:
+		return StatusStopping
+	case types.InstanceStateNameStopped:
+		return StatusStopped
+	case types.InstanceStateNameShuttingDown:
+		return StatusTerminating
+	case types.InstanceStateNameTerminated:
+		return StatusTerminated
+	default:
+		return StatusError
+	}
+}
+
+func (p *AWSProvider) GetInstance(ctx context.Context, id string) (*Instance, error) {
+	region, instanceID := parseAWSInstanceID(id)
+	client, err := p.client(region)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	if isEC2Error(err, "InvalidInstanceID.NotFound") {
+		return nil, fmt.Errorf("instance not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resp.Reservations {
+		for i := range r.Instances {
+			return p.toInstance(client.region, &r.Instances[i]), nil
+		}
 	}
 	return nil, fmt.Errorf("instance not found: %s", id)
 }
 
+// ListInstances lists the instances in the configured region
 func (p *AWSProvider) ListInstances(ctx context.Context, ownerID string) ([]*Instance, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	client, err := p.client("")
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []types.Filter{
+		{Name: aws.String("tag:cm:managed"), Values: []string{"true"}},
+		{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+	}
+	if ownerID != "" {
+		filters = append(filters, types.Filter{Name: aws.String("tag:cm:owner"), Values: []string{ownerID}})
+	}
+
 	result := make([]*Instance, 0)
-	for _, inst := range p.instances {
-		if inst.OwnerID == ownerID {
-			result = append(result, inst)
+	pages := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{Filters: filters})
+	for pages.HasMorePages() {
+		resp, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Reservations {
+			for i := range r.Instances {
+				result = append(result, p.toInstance(client.region, &r.Instances[i]))
+			}
 		}
 	}
 	return result, nil
 }
 
+// instanceAction performs an action on a single instance
+func (p *AWSProvider) instanceAction(ctx context.Context, id string, action func(client *ec2Client, instanceIDs []string) error) error {
+	region, instanceID := parseAWSInstanceID(id)
+	client, err := p.client(region)
+	if err != nil {
+		return err
+	}
+	return action(client, []string{instanceID})
+}
+
 func (p *AWSProvider) StartInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("AWS StartInstance not yet implemented")
+	return p.instanceAction(ctx, id, func(client *ec2Client, ids []string) error {
+		_, err := client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: ids})
+		return err
+	})
 }
 
 func (p *AWSProvider) StopInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("AWS StopInstance not yet implemented")
+	return p.instanceAction(ctx, id, func(client *ec2Client, ids []string) error {
+		_, err := client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: ids})
+		return err
+	})
 }
 
 func (p *AWSProvider) DeleteInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("AWS DeleteInstance not yet implemented")
+	return p.instanceAction(ctx, id, func(client *ec2Client, ids []string) error {
+		_, err := client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: ids})
+		return err
+	})
 }
 
 func (p *AWSProvider) GetSSHEndpoint(ctx context.Context, id string) (string, int, error) {
@@ -127,17 +571,348 @@ func (p *AWSProvider) GetSSHEndpoint(ctx context.Context, id string) (string, in
 	if err != nil {
 		return "", 0, err
 	}
+	if inst.PublicIP == "" {
+		return "", 0, fmt.Errorf("instance %s has no public IP (status: %s)", id, inst.Status)
+	}
 	return inst.PublicIP, inst.SSHPort, nil
 }
 
 func (p *AWSProvider) ExecCommand(ctx context.Context, id string, command []string) (string, string, int, error) {
-	return "", "", 1, fmt.Errorf("ExecCommand not implemented for AWS")
+	return "", "", 1, fmt.Errorf("ExecCommand not supported for AWS; connect over SSH")
+}
+
+// consoleOutput returns the instance's serial console output, which includes
+// the bootstrap script's output
+func (p *AWSProvider) consoleOutput(ctx context.Context, id string) (string, error) {
+	region, instanceID := parseAWSInstanceID(id)
+	client, err := p.client(region)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{InstanceId: aws.String(instanceID)})
+	if err != nil {
+		return "", err
+	}
+	output, err := base64.StdEncoding.DecodeString(aws.ToString(resp.Output))
+	if err != nil {
+		return "", fmt.Errorf("invalid console output: %w", err)
+	}
+	return string(output), nil
 }
 
 func (p *AWSProvider) GetLogs(ctx context.Context, id string, tail int) (string, error) {
-	return "", fmt.Errorf("GetLogs not implemented for AWS")
+	output, err := p.consoleOutput(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if tail > 0 {
+		lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+		if len(lines) > tail {
+			output = strings.Join(lines[len(lines)-tail:], "\n") + "\n"
+		}
+	}
+	return output, nil
 }
 
+// StreamLogs polls the console output, which EC2 updates every few minutes
+// at most, and sends what's new
 func (p *AWSProvider) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
-	return nil, fmt.Errorf("StreamLogs not implemented for AWS")
+	output, err := p.consoleOutput(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string, 100)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+
+		seen := ""
+		for {
+			// The output is the latest 64 KB, so continue after what was seen if
+			// it's still there and send everything otherwise
+			next := output
+			if seen != "" {
+				if i := strings.LastIndex(output, seen); i >= 0 {
+					next = output[i+len(seen):]
+				}
+			}
+			if next != "" {
+				select {
+				case ch <- next:
+				case <-ctx.Done():
+					return
+				}
+				seen = lastLines(output, 5)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if latest, err := p.consoleOutput(ctx, id); err == nil {
+				output = latest
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// lastLines returns the last n lines of s
+func lastLines(s string, n int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
+
+// DiscoverCapabilities reads on-demand Linux prices from the Price List API
+// and which types each region offers from EC2
+func (p *AWSProvider) DiscoverCapabilities(ctx context.Context) (*Capabilities, error) {
+	cfg, endpoint, err := p.config("")
+	if err != nil {
+		return nil, err
+	}
+	prices, err := fetchAWSPrices(ctx, newPricingClient(cfg, endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	regions := p.Regions()
+	offered := make([]map[string]bool, len(regions))
+	var wg sync.WaitGroup
+	for i, r := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			rc, err := p.client(region)
+			if err == nil {
+				offered[i] = fetchAWSOfferings(ctx, rc)
+			}
+		}(i, r.ID)
+	}
+	wg.Wait()
+
+	caps := &Capabilities{Provider: ProviderAWS, Types: p.InstanceTypes()}
+	for i, r := range regions {
+		r.Available, r.GPUAvailable = len(offered[i]) > 0, false
+		for _, t := range caps.Types {
+			available := offered[i][awsInstanceTypes[t.Type]]
+			if available && t.Type.IsGPU() {
+				r.GPUAvailable = true
+			}
+			rate, ok := prices[r.ID][awsInstanceTypes[t.Type]]
+			if !ok {
+				rate = t.HourlyRate
+			}
+			caps.Offers = append(caps.Offers, Offer{Region: r.ID, Type: t.Type, HourlyRate: rate, Currency: "USD", Available: available})
+		}
+		caps.Regions = append(caps.Regions, r)
+	}
+	return caps, nil
+}
+
+// fetchAWSPrices returns the on-demand hourly Linux price of each EC2 type
+// used, by region
+func fetchAWSPrices(ctx context.Context, client *pricing.Client) (map[string]map[string]float64, error) {
+	match := func(field, value string) pricingtypes.Filter {
+		return pricingtypes.Filter{Type: pricingtypes.FilterTypeTermMatch, Field: aws.String(field), Value: aws.String(value)}
+	}
+	prices := make(map[string]map[string]float64)
+	for _, ec2Type := range awsInstanceTypes {
+		pages := pricing.NewGetProductsPaginator(client, &pricing.GetProductsInput{
+			ServiceCode:   aws.String("AmazonEC2"),
+			FormatVersion: aws.String("aws_v1"),
+			MaxResults:    aws.Int32(100),
+			Filters: []pricingtypes.Filter{
+				match("instanceType", ec2Type),
+				match("operatingSystem", "Linux"),
+				match("tenancy", "Shared"),
+				match("preInstalledSw", "NA"),
+				match("capacitystatus", "Used"),
+				match("licenseModel", "No License required"),
+			},
+		})
+		for pages.HasMorePages() {
+			resp, err := pages.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range resp.PriceList {
+				region, rate, ok := parseAWSPrice(item)
+				if !ok {
+					continue
+				}
+				if prices[region] == nil {
+					prices[region] = make(map[string]float64)
+				}
+				prices[region][ec2Type] = rate
+			}
+		}
+	}
+	return prices, nil
+}
+
+// parseAWSPrice reads the region and on-demand hourly USD price of a Price
+// List product
+func parseAWSPrice(item string) (region string, rate float64, ok bool) {
+	var product struct {
+		Product struct {
+			Attributes struct {
+				RegionCode string `json:"regionCode"`
+			} `json:"attributes"`
+		} `json:"product"`
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					PricePerUnit struct {
+						USD string `json:"USD"`
+					} `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if json.Unmarshal([]byte(item), &product) != nil || product.Product.Attributes.RegionCode == "" {
+		return "", 0, false
+	}
+	for _, term := range product.Terms.OnDemand {
+		for _, dim := range term.PriceDimensions {
+			if rate, err := strconv.ParseFloat(dim.PricePerUnit.USD, 64); err == nil && rate > 0 {
+				return product.Product.Attributes.RegionCode, rate, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// fetchAWSOfferings returns the EC2 types used that a region offers; none if
+// the region can't be queried, e.g. because it isn't enabled
+func fetchAWSOfferings(ctx context.Context, client *ec2Client) map[string]bool {
+	var ec2Types []string
+	for _, ec2Type := range awsInstanceTypes {
+		ec2Types = append(ec2Types, ec2Type)
+	}
+	resp, err := client.DescribeInstanceTypeOfferings(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: types.LocationTypeRegion,
+		Filters:      []types.Filter{{Name: aws.String("instance-type"), Values: ec2Types}},
+	})
+	if err != nil {
+		return nil
+	}
+	offered := make(map[string]bool)
+	for _, o := range resp.InstanceTypeOfferings {
+		offered[string(o.InstanceType)] = true
+	}
+	return offered
+}
+
+// CreateVolume creates a gp3 EBS volume. EBS volumes are zonal and default
+// to the region's first zone, so give the zone of the instance to attach to.
+func (p *AWSProvider) CreateVolume(ctx context.Context, config VolumeConfig) (*Volume, error) {
+	client, err := p.client(config.Region)
+	if err != nil {
+		return nil, err
+	}
+	zone := config.Zone
+	if zone == "" {
+		zone = client.region + "a"
+	}
+	input := &ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(zone),
+		VolumeType:       types.VolumeTypeGp3,
+	}
+	if config.SizeGB > 0 {
+		input.Size = aws.Int32(int32(config.SizeGB))
+	}
+	if config.SnapshotID != "" {
+		_, snapshotID := parseAWSInstanceID(config.SnapshotID)
+		input.SnapshotId = aws.String(snapshotID)
+	}
+	tags := map[string]string{"Name": config.Name, "cm:managed": "true"}
+	if config.OwnerID != "" {
+		tags["cm:owner"] = config.OwnerID
+	}
+	input.TagSpecifications = tagSpecifications(types.ResourceTypeVolume, tags)
+
+	resp, err := client.CreateVolume(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	return &Volume{
+		ID:     awsInstanceID(client.region, aws.ToString(resp.VolumeId)),
+		Region: client.region,
+		Zone:   aws.ToString(resp.AvailabilityZone),
+		SizeGB: int(aws.ToInt32(resp.Size)),
+	}, nil
+}
+
+// AttachVolume attaches a volume to an instance in its zone. On the Nitro
+// instances used, the volume's device is named after its ID.
+func (p *AWSProvider) AttachVolume(ctx context.Context, volumeID, instanceID string) (*Volume, error) {
+	region, ebsID := parseAWSInstanceID(volumeID)
+	_, ec2ID := parseAWSInstanceID(instanceID)
+	client, err := p.client(region)
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.AttachVolume(ctx, &ec2.AttachVolumeInput{VolumeId: aws.String(ebsID), InstanceId: aws.String(ec2ID), Device: aws.String("/dev/sdf")})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach volume: %w", err)
+	}
+	return &Volume{
+		ID:     volumeID,
+		Region: client.region,
+		Device: "/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_" + strings.ReplaceAll(ebsID, "-", ""),
+	}, nil
+}
+
+func (p *AWSProvider) DetachVolume(ctx context.Context, volumeID string) error {
+	region, ebsID := parseAWSInstanceID(volumeID)
+	client, err := p.client(region)
+	if err != nil {
+		return err
+	}
+	_, err = client.DetachVolume(ctx, &ec2.DetachVolumeInput{VolumeId: aws.String(ebsID)})
+	return err
+}
+
+func (p *AWSProvider) DeleteVolume(ctx context.Context, volumeID string) error {
+	region, ebsID := parseAWSInstanceID(volumeID)
+	client, err := p.client(region)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteVolume(ctx, &ec2.DeleteVolumeInput{VolumeId: aws.String(ebsID)})
+	return err
+}
+
+// SnapshotVolume starts an EBS snapshot, which is usable while it completes
+func (p *AWSProvider) SnapshotVolume(ctx context.Context, volumeID, description string) (string, error) {
+	region, ebsID := parseAWSInstanceID(volumeID)
+	client, err := p.client(region)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+		VolumeId:          aws.String(ebsID),
+		Description:       aws.String(description),
+		TagSpecifications: tagSpecifications(types.ResourceTypeSnapshot, map[string]string{"cm:managed": "true"}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot volume: %w", err)
+	}
+	return awsInstanceID(client.region, aws.ToString(resp.SnapshotId)), nil
+}
+
+func (p *AWSProvider) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	region, ebsID := parseAWSInstanceID(snapshotID)
+	client, err := p.client(region)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{SnapshotId: aws.String(ebsID)})
+	return err
 }
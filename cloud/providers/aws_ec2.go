@@ -0,0 +1,79 @@
+// Package providers provides the AWS SDK clients of the AWS provider
+package providers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// awsMaxAttempts bounds the tries of a call the SDK retries: throttling
+	// such as RequestLimitExceeded, server errors and dropped connections.
+	// EC2 throttles per account, so bursts of launches back off for longer
+	// than the SDK's default of three tries.
+	awsMaxAttempts = 8
+	awsMaxBackoff  = 20 * time.Second
+
+	// awsPricingRegion serves the Price List API, which prices every region
+	awsPricingRegion = "us-east-1"
+)
+
+// ec2Client is an EC2 client for one region
+type ec2Client struct {
+	*ec2.Client
+	region string
+}
+
+// awsConfig returns the SDK configuration of the provider's credentials in
+// a region. It doesn't read the environment or ~/.aws, as each user of the
+// control plane brings their own credentials.
+func awsConfig(accessKeyID, secretKey, sessionToken, region string) aws.Config {
+	return aws.Config{
+		Region:      region,
+		Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(accessKeyID, secretKey, sessionToken)),
+		HTTPClient:  &http.Client{Timeout: 60 * time.Second},
+		Retryer: func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = awsMaxAttempts
+				o.MaxBackoff = awsMaxBackoff
+			})
+		},
+	}
+}
+
+// newEC2Client returns an EC2 client for a configuration, calling endpoint
+// instead of EC2's if set, e.g. for LocalStack
+func newEC2Client(cfg aws.Config, endpoint string) *ec2Client {
+	client := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &ec2Client{Client: client, region: cfg.Region}
+}
+
+// newPricingClient returns a Price List API client for a configuration,
+// calling endpoint instead of the API's if set
+func newPricingClient(cfg aws.Config, endpoint string) *pricing.Client {
+	cfg.Region = awsPricingRegion
+	return pricing.NewFromConfig(cfg, func(o *pricing.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+}
+
+// isEC2Error reports whether err is an EC2 error with the given code, such
+// as InvalidKeyPair.NotFound
+func isEC2Error(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
@@ -0,0 +1,142 @@
+//go:build integration
+
+package providers
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newLocalStackProvider returns an AWS provider talking to LocalStack, e.g.
+//
+//	docker run -d -p 4566:4566 localstack/localstack
+//	LOCALSTACK_ENDPOINT=http://localhost:4566 go test -tags integration ./cloud/providers
+func newLocalStackProvider(t *testing.T) *AWSProvider {
+	t.Helper()
+	endpoint := os.Getenv("LOCALSTACK_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("LOCALSTACK_ENDPOINT not set")
+	}
+
+	p := NewAWSProvider()
+	err := p.Configure(map[string]string{
+		"access_key_id":     "test",
+		"secret_access_key": "test",
+		"region":            "us-east-1",
+		"endpoint":          endpoint,
+		"ami_id":            os.Getenv("LOCALSTACK_AMI_ID"), // Selected by DescribeImages if empty
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsAvailable(context.Background()) {
+		t.Fatalf("LocalStack not reachable at %s", endpoint)
+	}
+	return p
+}
+
+func TestAWSInstanceLifecycle(t *testing.T) {
+	p := newLocalStackProvider(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	ctx = WithRequestID(ctx, "test-request")
+
+	inst, err := p.CreateInstance(ctx, InstanceConfig{
+		Name:         "cm-integration",
+		Type:         InstanceTypeCPUSmall,
+		Region:       "us-east-1",
+		SSHPublicKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIntegrationTestKeyIntegrationTestKey cm@test",
+		Ports:        []int{8080},
+		Env:          map[string]string{"GREETING": "it's alive"},
+		OwnerID:      "user-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance() error = %v", err)
+	}
+	defer func() { _ = p.DeleteInstance(context.Background(), inst.ID) }()
+
+	if !strings.HasPrefix(inst.ID, "us-east-1/i-") {
+		t.Errorf("ID = %q, want us-east-1/i-...", inst.ID)
+	}
+	if inst.Name != "cm-integration" || inst.OwnerID != "user-1" || inst.Type != InstanceTypeCPUSmall {
+		t.Errorf("instance = %+v", inst)
+	}
+	if inst.HourlyRate == 0 {
+		t.Error("HourlyRate not set")
+	}
+
+	// A second instance reuses the security group and key pair
+	second, err := p.CreateInstance(ctx, InstanceConfig{
+		Name:         "cm-integration-2",
+		Type:         InstanceTypeCPUSmall,
+		SSHPublicKey: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIntegrationTestKeyIntegrationTestKey cm@test",
+		OwnerID:      "user-2",
+	})
+	if err != nil {
+		t.Fatalf("second CreateInstance() error = %v", err)
+	}
+	defer func() { _ = p.DeleteInstance(context.Background(), second.ID) }()
+
+	list, err := p.ListInstances(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListInstances() error = %v", err)
+	}
+	if len(list) != 1 || list[0].ID != inst.ID {
+		t.Errorf("ListInstances(user-1) = %v, want only %s", list, inst.ID)
+	}
+
+	steps := []struct {
+		name   string
+		action func(context.Context, string) error
+		want   []InstanceStatus
+	}{
+		{"stop", p.StopInstance, []InstanceStatus{StatusStopping, StatusStopped}},
+		{"start", p.StartInstance, []InstanceStatus{StatusProvisioning, StatusRunning}},
+		{"delete", p.DeleteInstance, []InstanceStatus{StatusTerminating, StatusTerminated}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.action(ctx, inst.ID); err != nil {
+				t.Fatalf("%s error = %v", step.name, err)
+			}
+			got, err := p.GetInstance(ctx, inst.ID)
+			if err != nil {
+				t.Fatalf("GetInstance() error = %v", err)
+			}
+			if got.Status != step.want[0] && got.Status != step.want[1] {
+				t.Errorf("status = %s, want one of %v", got.Status, step.want)
+			}
+		})
+	}
+}
+
+func TestAWSSSHEndpointAndLogs(t *testing.T) {
+	p := newLocalStackProvider(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	inst, err := p.CreateInstance(ctx, InstanceConfig{Name: "cm-integration-ssh", Type: InstanceTypeCPUSmall})
+	if err != nil {
+		t.Fatalf("CreateInstance() error = %v", err)
+	}
+	defer func() { _ = p.DeleteInstance(context.Background(), inst.ID) }()
+
+	host, port, err := p.GetSSHEndpoint(ctx, inst.ID)
+	if err != nil {
+		t.Fatalf("GetSSHEndpoint() error = %v", err)
+	}
+	if host == "" || port != 22 {
+		t.Errorf("GetSSHEndpoint() = %s:%d", host, port)
+	}
+
+	if _, err := p.GetLogs(ctx, inst.ID, 10); err != nil {
+		t.Errorf("GetLogs() error = %v", err)
+	}
+
+	if _, err := p.GetInstance(ctx, "us-east-1/i-0000000000000000"); err == nil {
+		t.Error("GetInstance() of an unknown ID should fail")
+	}
+}
@@ -0,0 +1,158 @@
+// Package providers provides discovery of live pricing and availability
+package providers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// capabilityTTL is how long discovered capabilities are cached
+	capabilityTTL = time.Hour
+	// fallbackTTL is how long the static fallback is cached after discovery failed
+	fallbackTTL = 5 * time.Minute
+)
+
+// Offer is an instance type's price and availability in one region
+type Offer struct {
+	Region     string       `json:"region"`
+	Type       InstanceType `json:"type"`
+	HourlyRate float64      `json:"hourly_rate"`
+	Currency   string       `json:"currency"`
+	Available  bool         `json:"available"`
+}
+
+// Capabilities are a provider's regions, instance types and per-region offers
+type Capabilities struct {
+	Provider  ProviderType      `json:"provider"`
+	Regions   []Region          `json:"regions"`
+	Types     []InstancePricing `json:"types"`
+	Offers    []Offer           `json:"offers"`
+	Live      bool              `json:"live"` // False for the static tables
+	FetchedAt time.Time         `json:"fetched_at"`
+	Error     string            `json:"error,omitempty"` // Why discovery failed, if it did
+}
+
+// CapabilityDiscoverer is implemented by providers that can query their live
+// pricing and availability
+type CapabilityDiscoverer interface {
+	DiscoverCapabilities(ctx context.Context) (*Capabilities, error)
+}
+
+// StaticCapabilities returns a provider's capabilities from its static
+// tables: every type is offered at its listed price in every available
+// region, and GPU types only where the region has GPUs
+func StaticCapabilities(p Provider) *Capabilities {
+	caps := &Capabilities{
+		Provider:  p.Name(),
+		Regions:   p.Regions(),
+		Types:     p.InstanceTypes(),
+		FetchedAt: time.Now(),
+	}
+	for _, r := range caps.Regions {
+		for _, t := range caps.Types {
+			caps.Offers = append(caps.Offers, Offer{
+				Region:     r.ID,
+				Type:       t.Type,
+				HourlyRate: t.HourlyRate,
+				Currency:   "USD",
+				Available:  r.Available && (t.GPUType == "" || r.GPUAvailable),
+			})
+		}
+	}
+	return caps
+}
+
+// IsGPU reports whether an instance type has a GPU
+func (t InstanceType) IsGPU() bool {
+	return strings.HasPrefix(string(t), "gpu-")
+}
+
+// Offer returns the offer of a type in a region
+func (c *Capabilities) Offer(region string, t InstanceType) (Offer, bool) {
+	for _, o := range c.Offers {
+		if o.Region == region && o.Type == t {
+			return o, true
+		}
+	}
+	return Offer{}, false
+}
+
+// Pricing returns the types available in a region at their price there. With
+// no region it returns every type available anywhere at its lowest price.
+func (c *Capabilities) Pricing(region string) []InstancePricing {
+	rates := make(map[InstanceType]float64)
+	for _, o := range c.Offers {
+		if !o.Available || (region != "" && o.Region != region) {
+			continue
+		}
+		if rate, ok := rates[o.Type]; !ok || o.HourlyRate < rate {
+			rates[o.Type] = o.HourlyRate
+		}
+	}
+
+	result := make([]InstancePricing, 0, len(rates))
+	for _, t := range c.Types {
+		if rate, ok := rates[t.Type]; ok {
+			t.HourlyRate = rate
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// sortOffers orders offers by region, then type
+func sortOffers(offers []Offer) {
+	sort.SliceStable(offers, func(i, j int) bool {
+		if offers[i].Region != offers[j].Region {
+			return offers[i].Region < offers[j].Region
+		}
+		return offers[i].Type < offers[j].Type
+	})
+}
+
+type cachedCapabilities struct {
+	caps    *Capabilities
+	expires time.Time
+}
+
+// Capabilities returns a provider's capabilities, discovering them live when
+// the provider supports it and falling back to its static tables otherwise.
+// Results are cached; refresh bypasses the cache.
+func (m *Manager) Capabilities(ctx context.Context, name ProviderType, refresh bool) (*Capabilities, error) {
+	provider, err := m.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.capsMu.Lock()
+	cached, ok := m.caps[name]
+	m.capsMu.Unlock()
+	if ok && !refresh && time.Now().Before(cached.expires) {
+		return cached.caps, nil
+	}
+
+	discoverer, ok := provider.(CapabilityDiscoverer)
+	if !ok {
+		return StaticCapabilities(provider), nil
+	}
+
+	caps, err := discoverer.DiscoverCapabilities(ctx)
+	ttl := capabilityTTL
+	if err != nil {
+		caps = StaticCapabilities(provider)
+		caps.Error = err.Error()
+		ttl = fallbackTTL
+	} else {
+		caps.Live = true
+		caps.FetchedAt = time.Now()
+		sortOffers(caps.Offers)
+	}
+
+	m.capsMu.Lock()
+	m.caps[name] = cachedCapabilities{caps: caps, expires: time.Now().Add(ttl)}
+	m.capsMu.Unlock()
+	return caps, nil
+}
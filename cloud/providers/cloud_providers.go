@@ -415,84 +415,6 @@ func (p *VultrProvider) StreamLogs(ctx context.Context, id string) (<-chan strin
 	return nil, nil
 }
 
-// ---- Hetzner Provider ----
-
-type HetznerProvider struct {
-	mu         sync.RWMutex
-	configured bool
-	apiToken   string
-}
-
-func NewHetznerProvider() *HetznerProvider     { return &HetznerProvider{} }
-func (p *HetznerProvider) Name() ProviderType  { return ProviderHetzner }
-func (p *HetznerProvider) DisplayName() string { return "Hetzner Cloud" }
-func (p *HetznerProvider) Description() string {
-	return "European cloud with exceptional price-performance ratio."
-}
-func (p *HetznerProvider) Website() string { return "https://www.hetzner.com/cloud" }
-func (p *HetznerProvider) Features() []string {
-	return []string{"cloud-servers", "dedicated", "load-balancers", "volumes"}
-}
-func (p *HetznerProvider) RequiredCredentials() []string { return []string{"api_token"} }
-
-func (p *HetznerProvider) Configure(creds map[string]string) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.apiToken = creds["api_token"]
-	p.configured = p.apiToken != ""
-	return nil
-}
-
-func (p *HetznerProvider) IsAvailable(ctx context.Context) bool { return p.configured }
-
-func (p *HetznerProvider) Regions() []Region {
-	return []Region{
-		{ID: "nbg1", Name: "Nuremberg", Country: "DE", Available: true, GPUAvailable: false},
-		{ID: "fsn1", Name: "Falkenstein", Country: "DE", Available: true, GPUAvailable: false},
-		{ID: "hel1", Name: "Helsinki", Country: "FI", Available: true, GPUAvailable: false},
-		{ID: "ash", Name: "Ashburn, VA", Country: "US", Available: true, GPUAvailable: false},
-	}
-}
-
-func (p *HetznerProvider) InstanceTypes() []InstancePricing {
-	return []InstancePricing{
-		{Type: InstanceTypeCPUSmall, HourlyRate: 0.0049, VCPU: 2, MemoryGB: 4},  // CX21
-		{Type: InstanceTypeCPUMedium, HourlyRate: 0.0098, VCPU: 4, MemoryGB: 8}, // CX31
-		{Type: InstanceTypeCPULarge, HourlyRate: 0.0196, VCPU: 8, MemoryGB: 16}, // CX41
-	}
-}
-
-func (p *HetznerProvider) CreateInstance(ctx context.Context, config InstanceConfig) (*Instance, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-func (p *HetznerProvider) GetInstance(ctx context.Context, id string) (*Instance, error) {
-	return nil, fmt.Errorf("not found")
-}
-func (p *HetznerProvider) ListInstances(ctx context.Context, ownerID string) ([]*Instance, error) {
-	return nil, nil
-}
-func (p *HetznerProvider) StartInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("not implemented")
-}
-func (p *HetznerProvider) StopInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("not implemented")
-}
-func (p *HetznerProvider) DeleteInstance(ctx context.Context, id string) error {
-	return fmt.Errorf("not implemented")
-}
-func (p *HetznerProvider) GetSSHEndpoint(ctx context.Context, id string) (string, int, error) {
-	return "", 0, nil
-}
-func (p *HetznerProvider) ExecCommand(ctx context.Context, id string, cmd []string) (string, string, int, error) {
-	return "", "", 1, nil
-}
-func (p *HetznerProvider) GetLogs(ctx context.Context, id string, tail int) (string, error) {
-	return "", nil
-}
-func (p *HetznerProvider) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
-	return nil, nil
-}
-
 // ---- OCI (Oracle) Provider ----
 
 type OCIProvider struct {
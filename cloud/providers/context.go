@@ -0,0 +1,20 @@
+package providers
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the API request a
+// provider call serves, so providers can tag and log their work with it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the API request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
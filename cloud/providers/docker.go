@@ -104,6 +104,11 @@ func (p *DockerProvider) CreateInstance(ctx context.Context, config InstanceConf
 	// Add SSH port (22 -> random high port)
 	args = append(args, "-p", "22")
 
+	// Tag the container with the API request that created it
+	if requestID := RequestID(ctx); requestID != "" {
+		args = append(args, "--label", "cm.request-id="+requestID)
+	}
+
 	// Add image
 	image := config.Image
 	if image == "" {
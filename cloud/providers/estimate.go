@@ -0,0 +1,128 @@
+// Package providers provides cost estimates of instances across providers
+package providers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxEstimateHours bounds estimates to a year of running
+const maxEstimateHours = 24 * 366
+
+// EstimateRequest asks what an instance type costs over some hours, and
+// which GPU offer meeting the requirements costs least
+type EstimateRequest struct {
+	Type   InstanceType `json:"instance_type"` // Optional; without it only the cheapest GPU is found
+	Region string       `json:"region"`        // Preferred; providers without it are priced in their cheapest region
+	Hours  float64      `json:"hours"`
+
+	// Requirements of the cheapest GPU offer
+	MinVCPU        int    `json:"min_vcpu"`
+	MinMemoryGB    int    `json:"min_memory_gb"`
+	MinGPUMemoryGB int    `json:"min_gpu_memory_gb"`
+	GPUType        string `json:"gpu_type"` // A GPU model, e.g. A100
+}
+
+// CostEstimate is what an offer costs over the hours estimated
+type CostEstimate struct {
+	InstancePricing
+	Provider ProviderType `json:"provider"`
+	Region   string       `json:"region"`
+	Currency string       `json:"currency"`
+	Total    float64      `json:"total"`
+	Live     bool         `json:"live"` // False for prices from the static tables
+}
+
+// Estimate is the cost of an instance type with each provider that offers
+// it, and the cheapest GPU offer meeting the requirements
+type Estimate struct {
+	Hours       float64        `json:"hours"`
+	Estimates   []CostEstimate `json:"estimates"` // Cheapest first; those not in USD last
+	CheapestGPU *CostEstimate  `json:"cheapest_gpu,omitempty"`
+}
+
+// meets reports whether a GPU offer meets the requirements of an estimate
+func (r *EstimateRequest) meets(t InstancePricing) bool {
+	return t.GPUType != "" &&
+		t.VCPU >= r.MinVCPU &&
+		t.MemoryGB >= r.MinMemoryGB &&
+		t.GPUMemoryGB >= r.MinGPUMemoryGB &&
+		(r.GPUType == "" || strings.Contains(strings.ToLower(t.GPUType), strings.ToLower(r.GPUType)))
+}
+
+// Estimate prices an instance type with every provider that offers it, in
+// the region asked for where a provider has it and in its cheapest region
+// otherwise, and finds the cheapest GPU offer meeting the requirements.
+// Only offers in USD are compared for the cheapest GPU, as rates aren't
+// converted. Free providers, like Docker and registered hosts, are left out.
+func (m *Manager) Estimate(ctx context.Context, req EstimateRequest) (*Estimate, error) {
+	if req.Hours <= 0 || req.Hours > maxEstimateHours {
+		return nil, errors.New("hours must be more than 0 and at most a year")
+	}
+
+	result := &Estimate{Hours: req.Hours, Estimates: []CostEstimate{}}
+	for _, p := range m.List() {
+		caps, err := m.Capabilities(ctx, p.Name(), false)
+		if err != nil {
+			continue
+		}
+		types := make(map[InstanceType]InstancePricing)
+		for _, t := range caps.Types {
+			types[t.Type] = t
+		}
+
+		// The cheapest offer of each type, preferring the region asked for
+		best := make(map[InstanceType]Offer)
+		for _, o := range caps.Offers {
+			if !o.Available || o.HourlyRate <= 0 {
+				continue
+			}
+			cur, ok := best[o.Type]
+			inRegion := req.Region != "" && o.Region == req.Region
+			curInRegion := ok && req.Region != "" && cur.Region == req.Region
+			if !ok || (inRegion && !curInRegion) || (inRegion == curInRegion && o.HourlyRate < cur.HourlyRate) {
+				best[o.Type] = o
+			}
+		}
+
+		for typ, o := range best {
+			t := types[typ]
+			t.HourlyRate = o.HourlyRate
+			estimate := CostEstimate{
+				InstancePricing: t,
+				Provider:        p.Name(),
+				Region:          o.Region,
+				Currency:        o.Currency,
+				Total:           math.Round(o.HourlyRate*req.Hours*100) / 100,
+				Live:            caps.Live,
+			}
+			if typ == req.Type {
+				result.Estimates = append(result.Estimates, estimate)
+			}
+			if estimate.Currency == "USD" && req.meets(t) && (result.CheapestGPU == nil || cheaper(estimate, *result.CheapestGPU)) {
+				result.CheapestGPU = &estimate
+			}
+		}
+	}
+
+	sort.Slice(result.Estimates, func(i, j int) bool {
+		a, b := result.Estimates[i], result.Estimates[j]
+		if (a.Currency == "USD") != (b.Currency == "USD") {
+			return a.Currency == "USD"
+		}
+		return cheaper(a, b)
+	})
+	return result, nil
+}
+
+// cheaper orders estimates by total, then by provider so ties come out the
+// same every time
+func cheaper(a, b CostEstimate) bool {
+	if a.Total != b.Total {
+		return a.Total < b.Total
+	}
+	return a.Provider < b.Provider
+}
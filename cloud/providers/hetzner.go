@@ -0,0 +1,583 @@
+// Package providers provides the Hetzner Cloud provider implementation
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"gopkg.in/yaml.v3"
+)
+
+// hetznerServerTypes maps compute tiers to Hetzner server types
+var hetznerServerTypes = map[InstanceType]string{
+	InstanceTypeCPUSmall:  "cx22",
+	InstanceTypeCPUMedium: "cx32",
+	InstanceTypeCPULarge:  "cx42",
+}
+
+// HetznerProvider implements the Provider interface for Hetzner Cloud.
+// Ownership is tracked with server labels.
+type HetznerProvider struct {
+	mu         sync.RWMutex
+	configured bool
+	apiToken   string
+	endpoint   string // Optional, overrides the API base URL
+	http       *http.Client
+}
+
+// NewHetznerProvider creates a new Hetzner Cloud provider
+func NewHetznerProvider() *HetznerProvider {
+	return &HetznerProvider{http: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (p *HetznerProvider) Name() ProviderType  { return ProviderHetzner }
+func (p *HetznerProvider) DisplayName() string { return "Hetzner Cloud" }
+func (p *HetznerProvider) Description() string {
+	return "European cloud with exceptional price-performance ratio."
+}
+func (p *HetznerProvider) Website() string { return "https://www.hetzner.com/cloud" }
+func (p *HetznerProvider) Features() []string {
+	return []string{"cloud-servers", "dedicated", "load-balancers", "volumes"}
+}
+func (p *HetznerProvider) RequiredCredentials() []string { return []string{"api_token"} }
+
+// Configure also accepts the optional endpoint
+func (p *HetznerProvider) Configure(creds map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apiToken = creds["api_token"]
+	p.endpoint = strings.TrimSuffix(creds["endpoint"], "/")
+	p.configured = p.apiToken != ""
+	return nil
+}
+
+// IsAvailable checks the API token against the API
+func (p *HetznerProvider) IsAvailable(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := p.client()
+	if err != nil {
+		return false
+	}
+	_, _, err = client.Server.List(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{PerPage: 1}})
+	return err == nil
+}
+
+func (p *HetznerProvider) Regions() []Region {
+	return []Region{
+		{ID: "nbg1", Name: "Nuremberg", Country: "DE", Available: true, GPUAvailable: false},
+		{ID: "fsn1", Name: "Falkenstein", Country: "DE", Available: true, GPUAvailable: false},
+		{ID: "hel1", Name: "Helsinki", Country: "FI", Available: true, GPUAvailable: false},
+		{ID: "ash", Name: "Ashburn, VA", Country: "US", Available: true, GPUAvailable: false},
+	}
+}
+
+func (p *HetznerProvider) InstanceTypes() []InstancePricing {
+	return []InstancePricing{
+		{Type: InstanceTypeCPUSmall, HourlyRate: 0.0049, VCPU: 2, MemoryGB: 4},  // CX22
+		{Type: InstanceTypeCPUMedium, HourlyRate: 0.0098, VCPU: 4, MemoryGB: 8}, // CX32
+		{Type: InstanceTypeCPULarge, HourlyRate: 0.0196, VCPU: 8, MemoryGB: 16}, // CX42
+	}
+}
+
+// client returns an API client. It retries requests that were rate limited
+// or failed on a conflict or a gateway error.
+func (p *HetznerProvider) client() (*hcloud.Client, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.configured {
+		return nil, fmt.Errorf("Hetzner provider not configured")
+	}
+	opts := []hcloud.ClientOption{
+		hcloud.WithToken(p.apiToken),
+		hcloud.WithHTTPClient(p.http),
+		hcloud.WithApplication("container-maker", ""),
+	}
+	if p.endpoint != "" {
+		opts = append(opts, hcloud.WithEndpoint(p.endpoint))
+	}
+	return hcloud.NewClient(opts...), nil
+}
+
+// hetznerID parses the ID of a server or volume
+func hetznerID(kind, id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Hetzner %s ID: %s", kind, id)
+	}
+	return n, nil
+}
+
+// invalidLabelChars matches what Hetzner label values may not contain
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// hetznerLabel makes s a valid label value
+func hetznerLabel(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-_.")
+}
+
+// invalidNameChars matches what server names, which must be hostnames, may not contain
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func (p *HetznerProvider) CreateInstance(ctx context.Context, config InstanceConfig) (*Instance, error) {
+	serverType, ok := hetznerServerTypes[config.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported instance type for Hetzner: %s", config.Type)
+	}
+
+	userData, err := hetznerCloudInit(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Server names are unique per project, so add a suffix
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(config.Name), "-"), "-")
+	if name == "" {
+		name = "cm"
+	}
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	name += "-" + uuid.New().String()[:6]
+
+	labels := map[string]string{
+		"cm-managed": "true",
+		"cm-type":    string(config.Type),
+	}
+	if config.OwnerID != "" {
+		labels["cm-owner"] = hetznerLabel(config.OwnerID)
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		labels["cm-request-id"] = hetznerLabel(requestID)
+	}
+
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	opts := hcloud.ServerCreateOpts{
+		Name:       name,
+		ServerType: &hcloud.ServerType{Name: serverType},
+		Image:      &hcloud.Image{Name: "ubuntu-22.04"},
+		UserData:   userData,
+		Labels:     labels,
+	}
+	if config.Region != "" {
+		opts.Location = &hcloud.Location{Name: config.Region}
+	}
+	if config.SSHPublicKey != "" {
+		key, err := ensureSSHKey(ctx, client, config.SSHPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		opts.SSHKeys = []*hcloud.SSHKey{key}
+	}
+
+	result, _, err := client.Server.Create(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	return p.toInstance(result.Server), nil
+}
+
+// ensureSSHKey uploads an SSH public key, named after its hash so each key
+// is uploaded once
+func ensureSSHKey(ctx context.Context, client *hcloud.Client, publicKey string) (*hcloud.SSHKey, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	sum := sha256.Sum256([]byte(publicKey))
+	name := "cm-" + hex.EncodeToString(sum[:8])
+
+	key, _, err := client.SSHKey.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SSH key: %w", err)
+	}
+	if key != nil {
+		return key, nil
+	}
+
+	key, _, err = client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{Name: name, PublicKey: publicKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload SSH key: %w", err)
+	}
+	return key, nil
+}
+
+// cloudConfig is the subset of cloud-init's #cloud-config used to bootstrap servers
+type cloudConfig struct {
+	PackageUpdate bool            `yaml:"package_update"`
+	Packages      []string        `yaml:"packages"`
+	WriteFiles    []cloudInitFile `yaml:"write_files,omitempty"`
+	RunCmd        []string        `yaml:"runcmd"`
+}
+
+type cloudInitFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Permissions string `yaml:"permissions"`
+}
+
+// hetznerCloudInit returns the cloud-init config run on first boot: it
+// installs Docker and the cm CLI, and the cm agent as a service started at
+// every boot
+func hetznerCloudInit(config InstanceConfig) (string, error) {
+	cc := cloudConfig{
+		PackageUpdate: true,
+		Packages:      []string{"curl", "ca-certificates"},
+		RunCmd: []string{
+			"command -v docker >/dev/null || curl -fsSL https://get.docker.com | sh",
+			`arch=$(uname -m); [ "$arch" = aarch64 ] && arch=arm64 || arch=amd64; curl -fsSLo /usr/local/bin/cm "https://github.com/UPwith-me/Container-Maker/releases/latest/download/cm-linux-$arch"`,
+			"chmod +x /usr/local/bin/cm",
+		},
+	}
+
+	if len(config.Env) > 0 {
+		keys := make([]string, 0, len(config.Env))
+		for k := range config.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var sb strings.Builder
+		for _, k := range keys {
+			if envNamePattern.MatchString(k) {
+				fmt.Fprintf(&sb, "export %s=%s\n", k, shellQuote(config.Env[k]))
+			}
+		}
+		cc.WriteFiles = append(cc.WriteFiles, cloudInitFile{
+			Path:        "/etc/profile.d/cm-env.sh",
+			Content:     sb.String(),
+			Permissions: "0644",
+		})
+	}
+	if config.Image != "" {
+		cc.RunCmd = append(cc.RunCmd, "docker pull "+shellQuote(config.Image)+" || true")
+	}
+	cc.WriteFiles = append(cc.WriteFiles, cloudInitFile{
+		Path:        "/etc/systemd/system/cm-agent.service",
+		Content:     agentUnit("root"),
+		Permissions: "0644",
+	})
+	cc.RunCmd = append(cc.RunCmd, "systemctl daemon-reload", "systemctl enable --now cm-agent")
+
+	data, err := yaml.Marshal(cc)
+	if err != nil {
+		return "", err
+	}
+	return "#cloud-config\n" + string(data), nil
+}
+
+// toInstance converts a Hetzner server
+func (p *HetznerProvider) toInstance(s *hcloud.Server) *Instance {
+	inst := &Instance{
+		ID:        strconv.FormatInt(s.ID, 10),
+		Name:      s.Name,
+		Type:      InstanceType(s.Labels["cm-type"]),
+		Status:    hetznerStatus(s.Status),
+		Provider:  ProviderHetzner,
+		SSHPort:   22,
+		CreatedAt: s.Created,
+		UpdatedAt: time.Now(),
+		OwnerID:   s.Labels["cm-owner"],
+		Metadata:  map[string]string{},
+	}
+	if s.Location != nil {
+		inst.Region = s.Location.Name
+	}
+	if !s.PublicNet.IPv4.IsUnspecified() {
+		inst.PublicIP = s.PublicNet.IPv4.IP.String()
+	}
+	if len(s.PrivateNet) > 0 && s.PrivateNet[0].IP != nil {
+		inst.PrivateIP = s.PrivateNet[0].IP.String()
+	}
+	if s.ServerType != nil {
+		inst.Metadata["server_type"] = s.ServerType.Name
+	}
+	for _, pricing := range p.InstanceTypes() {
+		if pricing.Type == inst.Type {
+			inst.HourlyRate = pricing.HourlyRate
+			break
+		}
+	}
+	return inst
+}
+
+// hetznerStatus maps Hetzner server statuses
+func hetznerStatus(status hcloud.ServerStatus) InstanceStatus {
+	switch status {
+	case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting, hcloud.ServerStatusMigrating, hcloud.ServerStatusRebuilding:
+		return StatusProvisioning
+	case hcloud.ServerStatusRunning:
+		return StatusRunning
+	case hcloud.ServerStatusStopping:
+		return StatusStopping
+	case hcloud.ServerStatusOff:
+		return StatusStopped
+	case hcloud.ServerStatusDeleting:
+		return StatusTerminating
+	default:
+		return StatusError
+	}
+}
+
+func (p *HetznerProvider) GetInstance(ctx context.Context, id string) (*Instance, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	serverID, err := hetznerID("server", id)
+	if err != nil {
+		return nil, err
+	}
+	server, _, err := client.Server.GetByID(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, fmt.Errorf("instance not found: %s", id)
+	}
+	return p.toInstance(server), nil
+}
+
+func (p *HetznerProvider) ListInstances(ctx context.Context, ownerID string) ([]*Instance, error) {
+	selector := "cm-managed==true"
+	if ownerID != "" {
+		selector += ",cm-owner==" + hetznerLabel(ownerID)
+	}
+
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	servers, err := client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector, PerPage: 50},
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Instance, 0, len(servers))
+	for _, s := range servers {
+		result = append(result, p.toInstance(s))
+	}
+	return result, nil
+}
+
+// serverAction performs an action on a server
+func (p *HetznerProvider) serverAction(id string, action func(client *hcloud.Client, server *hcloud.Server) error) error {
+	client, err := p.client()
+	if err != nil {
+		return err
+	}
+	serverID, err := hetznerID("server", id)
+	if err != nil {
+		return err
+	}
+	return action(client, &hcloud.Server{ID: serverID})
+}
+
+func (p *HetznerProvider) StartInstance(ctx context.Context, id string) error {
+	return p.serverAction(id, func(client *hcloud.Client, server *hcloud.Server) error {
+		_, _, err := client.Server.Poweron(ctx, server)
+		return err
+	})
+}
+
+// StopInstance shuts the server down gracefully
+func (p *HetznerProvider) StopInstance(ctx context.Context, id string) error {
+	return p.serverAction(id, func(client *hcloud.Client, server *hcloud.Server) error {
+		_, _, err := client.Server.Shutdown(ctx, server)
+		return err
+	})
+}
+
+func (p *HetznerProvider) DeleteInstance(ctx context.Context, id string) error {
+	return p.serverAction(id, func(client *hcloud.Client, server *hcloud.Server) error {
+		_, err := client.Server.Delete(ctx, server)
+		return err
+	})
+}
+
+func (p *HetznerProvider) GetSSHEndpoint(ctx context.Context, id string) (string, int, error) {
+	inst, err := p.GetInstance(ctx, id)
+	if err != nil {
+		return "", 0, err
+	}
+	if inst.PublicIP == "" {
+		return "", 0, fmt.Errorf("instance %s has no public IP (status: %s)", id, inst.Status)
+	}
+	return inst.PublicIP, inst.SSHPort, nil
+}
+
+func (p *HetznerProvider) ExecCommand(ctx context.Context, id string, cmd []string) (string, string, int, error) {
+	return "", "", 1, fmt.Errorf("ExecCommand not supported for Hetzner; connect over SSH")
+}
+
+// GetLogs is unsupported: Hetzner has no API for a server's console output
+func (p *HetznerProvider) GetLogs(ctx context.Context, id string, tail int) (string, error) {
+	return "", fmt.Errorf("logs not supported for Hetzner; connect over SSH")
+}
+
+func (p *HetznerProvider) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
+	return nil, fmt.Errorf("logs not supported for Hetzner; connect over SSH")
+}
+
+// DiscoverCapabilities reads locations and server type prices from the API.
+// Hetzner prices are in EUR, excluding VAT.
+func (p *HetznerProvider) DiscoverCapabilities(ctx context.Context) (*Capabilities, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	locations, err := client.Location.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serverTypes, err := client.ServerType.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := &Capabilities{Provider: ProviderHetzner}
+	for _, l := range locations {
+		caps.Regions = append(caps.Regions, Region{ID: l.Name, Name: l.City, Country: l.Country, Available: true})
+	}
+
+	for _, t := range p.InstanceTypes() {
+		for _, st := range serverTypes {
+			if st.Name != hetznerServerTypes[t.Type] {
+				continue
+			}
+			t.VCPU, t.MemoryGB = st.Cores, int(st.Memory)
+			prices := make(map[string]float64)
+			for _, price := range st.Pricings {
+				if rate, err := strconv.ParseFloat(price.Hourly.Net, 64); err == nil && price.Location != nil {
+					prices[price.Location.Name] = rate
+				}
+			}
+			for _, r := range caps.Regions {
+				rate, ok := prices[r.ID]
+				caps.Offers = append(caps.Offers, Offer{Region: r.ID, Type: t.Type, HourlyRate: rate, Currency: "EUR", Available: ok})
+			}
+			caps.Types = append(caps.Types, t)
+		}
+	}
+	return caps, nil
+}
+
+// hetznerVolume converts a Hetzner volume
+func hetznerVolume(v *hcloud.Volume) *Volume {
+	volume := &Volume{ID: strconv.FormatInt(v.ID, 10), SizeGB: v.Size, Device: v.LinuxDevice}
+	if v.Location != nil {
+		volume.Region = v.Location.Name
+	}
+	return volume
+}
+
+// CreateVolume creates an ext4-formatted volume; Hetzner volumes are 10 GB
+// at least
+func (p *HetznerProvider) CreateVolume(ctx context.Context, config VolumeConfig) (*Volume, error) {
+	if config.SnapshotID != "" {
+		return nil, fmt.Errorf("Hetzner volumes can't be created from snapshots")
+	}
+	if config.Region == "" {
+		return nil, fmt.Errorf("a location is required for Hetzner volumes")
+	}
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(config.Name), "-"), "-")
+	if name == "" {
+		name = "cm"
+	}
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	labels := map[string]string{"cm-managed": "true"}
+	if config.OwnerID != "" {
+		labels["cm-owner"] = hetznerLabel(config.OwnerID)
+	}
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := client.Volume.Create(ctx, hcloud.VolumeCreateOpts{
+		Name:     name + "-" + uuid.New().String()[:6],
+		Size:     max(config.SizeGB, 10),
+		Location: &hcloud.Location{Name: config.Region},
+		Format:   hcloud.Ptr(hcloud.VolumeFormatExt4),
+		Labels:   labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	return hetznerVolume(result.Volume), nil
+}
+
+// AttachVolume attaches a volume to a server in its location, without
+// mounting it
+func (p *HetznerProvider) AttachVolume(ctx context.Context, volumeID, instanceID string) (*Volume, error) {
+	serverID, err := hetznerID("server", instanceID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := hetznerID("volume", volumeID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = client.Volume.AttachWithOpts(ctx, &hcloud.Volume{ID: id}, hcloud.VolumeAttachOpts{
+		Server:    &hcloud.Server{ID: serverID},
+		Automount: hcloud.Ptr(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach volume: %w", err)
+	}
+	volume, _, err := client.Volume.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if volume == nil {
+		return nil, fmt.Errorf("volume not found: %s", volumeID)
+	}
+	return hetznerVolume(volume), nil
+}
+
+// volumeAction performs an action on a volume
+func (p *HetznerProvider) volumeAction(volumeID string, action func(client *hcloud.Client, volume *hcloud.Volume) error) error {
+	client, err := p.client()
+	if err != nil {
+		return err
+	}
+	id, err := hetznerID("volume", volumeID)
+	if err != nil {
+		return err
+	}
+	return action(client, &hcloud.Volume{ID: id})
+}
+
+func (p *HetznerProvider) DetachVolume(ctx context.Context, volumeID string) error {
+	return p.volumeAction(volumeID, func(client *hcloud.Client, volume *hcloud.Volume) error {
+		_, _, err := client.Volume.Detach(ctx, volume)
+		return err
+	})
+}
+
+func (p *HetznerProvider) DeleteVolume(ctx context.Context, volumeID string) error {
+	return p.volumeAction(volumeID, func(client *hcloud.Client, volume *hcloud.Volume) error {
+		_, err := client.Volume.Delete(ctx, volume)
+		return err
+	})
+}
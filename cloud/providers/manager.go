@@ -11,12 +11,16 @@ import (
 type Manager struct {
 	mu        sync.RWMutex
 	providers map[ProviderType]Provider
+
+	capsMu sync.Mutex
+	caps   map[ProviderType]cachedCapabilities
 }
 
 // NewManager creates a new provider manager
 func NewManager() *Manager {
 	return &Manager{
 		providers: make(map[ProviderType]Provider),
+		caps:      make(map[ProviderType]cachedCapabilities),
 	}
 }
 
@@ -75,6 +79,9 @@ func (m *Manager) InitializeWithCredentials(creds map[ProviderType]map[string]st
 			if err := provider.Configure(credentials); err != nil {
 				return fmt.Errorf("failed to configure %s: %w", providerType, err)
 			}
+			m.capsMu.Lock()
+			delete(m.caps, providerType)
+			m.capsMu.Unlock()
 		}
 	}
 	return nil
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin/providerpb"
+)
+
+// configureTimeout bounds Configure, which has no context of its own
+const configureTimeout = 30 * time.Second
+
+// client is a Provider served by a plugin. Its description is asked once,
+// when the plugin starts.
+type client struct {
+	rpc  providerpb.ProviderClient
+	info *providerpb.InfoResponse
+
+	regions []providers.Region
+	types   []providers.InstancePricing
+}
+
+// newClient asks a plugin for its provider's description
+func newClient(ctx context.Context, rpc providerpb.ProviderClient) (*client, error) {
+	info, err := rpc.Info(ctx, &providerpb.InfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if info.Name == "" {
+		return nil, errors.New("the plugin's provider has no name")
+	}
+	return &client{
+		rpc:     rpc,
+		info:    info,
+		regions: regionsFromProto(info.Regions),
+		types:   pricingFromProto(info.InstanceTypes),
+	}, nil
+}
+
+// outgoing passes the request ID of a call on to the plugin
+func outgoing(ctx context.Context) context.Context {
+	if id := providers.RequestID(ctx); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, requestIDHeader, id)
+	}
+	return ctx
+}
+
+// callError returns the provider's own errors as they were, and says which
+// plugin failed otherwise
+func (c *client) callError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.Unknown:
+		return errors.New(st.Message())
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return fmt.Errorf("%s plugin: %s", c.info.Name, st.Message())
+}
+
+func (c *client) Name() providers.ProviderType               { return providers.ProviderType(c.info.Name) }
+func (c *client) DisplayName() string                        { return c.info.DisplayName }
+func (c *client) Description() string                        { return c.info.Description }
+func (c *client) Website() string                            { return c.info.Website }
+func (c *client) Features() []string                         { return c.info.Features }
+func (c *client) RequiredCredentials() []string              { return c.info.RequiredCredentials }
+func (c *client) Regions() []providers.Region                { return c.regions }
+func (c *client) InstanceTypes() []providers.InstancePricing { return c.types }
+
+func (c *client) Configure(credentials map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), configureTimeout)
+	defer cancel()
+	if _, err := c.rpc.Configure(ctx, &providerpb.ConfigureRequest{Credentials: credentials}); err != nil {
+		return c.callError(err)
+	}
+	return nil
+}
+
+func (c *client) IsAvailable(ctx context.Context) bool {
+	resp, err := c.rpc.IsAvailable(outgoing(ctx), &providerpb.Empty{})
+	return err == nil && resp.Available
+}
+
+func (c *client) CreateInstance(ctx context.Context, config providers.InstanceConfig) (*providers.Instance, error) {
+	req, err := configToProto(config)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := c.rpc.CreateInstance(outgoing(ctx), &providerpb.CreateInstanceRequest{Config: req})
+	if err != nil {
+		return nil, c.callError(err)
+	}
+	return instanceFromProto(inst), nil
+}
+
+func (c *client) GetInstance(ctx context.Context, id string) (*providers.Instance, error) {
+	inst, err := c.rpc.GetInstance(outgoing(ctx), &providerpb.InstanceRequest{Id: id})
+	if err != nil {
+		return nil, c.callError(err)
+	}
+	return instanceFromProto(inst), nil
+}
+
+func (c *client) ListInstances(ctx context.Context, ownerID string) ([]*providers.Instance, error) {
+	resp, err := c.rpc.ListInstances(outgoing(ctx), &providerpb.ListInstancesRequest{OwnerId: ownerID})
+	if err != nil {
+		return nil, c.callError(err)
+	}
+	instances := make([]*providers.Instance, len(resp.Instances))
+	for i, inst := range resp.Instances {
+		instances[i] = instanceFromProto(inst)
+	}
+	return instances, nil
+}
+
+func (c *client) StartInstance(ctx context.Context, id string) error {
+	if _, err := c.rpc.StartInstance(outgoing(ctx), &providerpb.InstanceRequest{Id: id}); err != nil {
+		return c.callError(err)
+	}
+	return nil
+}
+
+func (c *client) StopInstance(ctx context.Context, id string) error {
+	if _, err := c.rpc.StopInstance(outgoing(ctx), &providerpb.InstanceRequest{Id: id}); err != nil {
+		return c.callError(err)
+	}
+	return nil
+}
+
+func (c *client) DeleteInstance(ctx context.Context, id string) error {
+	if _, err := c.rpc.DeleteInstance(outgoing(ctx), &providerpb.InstanceRequest{Id: id}); err != nil {
+		return c.callError(err)
+	}
+	return nil
+}
+
+func (c *client) GetSSHEndpoint(ctx context.Context, id string) (string, int, error) {
+	resp, err := c.rpc.GetSSHEndpoint(outgoing(ctx), &providerpb.InstanceRequest{Id: id})
+	if err != nil {
+		return "", 0, c.callError(err)
+	}
+	return resp.Host, int(resp.Port), nil
+}
+
+func (c *client) ExecCommand(ctx context.Context, id string, command []string) (string, string, int, error) {
+	resp, err := c.rpc.ExecCommand(outgoing(ctx), &providerpb.ExecRequest{Id: id, Command: command})
+	if err != nil {
+		return "", "", 0, c.callError(err)
+	}
+	return resp.Stdout, resp.Stderr, int(resp.ExitCode), nil
+}
+
+func (c *client) GetLogs(ctx context.Context, id string, tail int) (string, error) {
+	resp, err := c.rpc.GetLogs(outgoing(ctx), &providerpb.LogsRequest{Id: id, Tail: int32(tail)})
+	if err != nil {
+		return "", c.callError(err)
+	}
+	return resp.Logs, nil
+}
+
+// StreamLogs sends log lines until the plugin's stream ends or ctx is done
+func (c *client) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
+	stream, err := c.rpc.StreamLogs(outgoing(ctx), &providerpb.InstanceRequest{Id: id})
+	if err != nil {
+		return nil, c.callError(err)
+	}
+	// The plugin sends headers once the provider's stream is open; without
+	// them the call ended, with its error
+	if md, err := stream.Header(); err != nil {
+		return nil, c.callError(err)
+	} else if md == nil {
+		if _, err := stream.Recv(); err != io.EOF {
+			return nil, c.callError(err)
+		}
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- msg.Line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, nil
+}
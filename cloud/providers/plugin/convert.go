@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin/providerpb"
+)
+
+// Conversions between the providers types and their protocol messages
+
+func regionsToProto(regions []providers.Region) []*providerpb.Region {
+	out := make([]*providerpb.Region, len(regions))
+	for i, r := range regions {
+		out[i] = &providerpb.Region{Id: r.ID, Name: r.Name, Country: r.Country, Available: r.Available, GpuAvailable: r.GPUAvailable}
+	}
+	return out
+}
+
+func regionsFromProto(regions []*providerpb.Region) []providers.Region {
+	out := make([]providers.Region, len(regions))
+	for i, r := range regions {
+		out[i] = providers.Region{ID: r.Id, Name: r.Name, Country: r.Country, Available: r.Available, GPUAvailable: r.GpuAvailable}
+	}
+	return out
+}
+
+func pricingToProto(types []providers.InstancePricing) []*providerpb.InstancePricing {
+	out := make([]*providerpb.InstancePricing, len(types))
+	for i, t := range types {
+		out[i] = &providerpb.InstancePricing{
+			Type:        string(t.Type),
+			HourlyRate:  t.HourlyRate,
+			Vcpu:        int32(t.VCPU),
+			MemoryGb:    int32(t.MemoryGB),
+			GpuType:     t.GPUType,
+			GpuMemoryGb: int32(t.GPUMemoryGB),
+		}
+	}
+	return out
+}
+
+func pricingFromProto(types []*providerpb.InstancePricing) []providers.InstancePricing {
+	out := make([]providers.InstancePricing, len(types))
+	for i, t := range types {
+		out[i] = providers.InstancePricing{
+			Type:        providers.InstanceType(t.Type),
+			HourlyRate:  t.HourlyRate,
+			VCPU:        int(t.Vcpu),
+			MemoryGB:    int(t.MemoryGb),
+			GPUType:     t.GpuType,
+			GPUMemoryGB: int(t.GpuMemoryGb),
+		}
+	}
+	return out
+}
+
+func configToProto(c providers.InstanceConfig) (*providerpb.InstanceConfig, error) {
+	out := &providerpb.InstanceConfig{
+		Name:         c.Name,
+		Type:         string(c.Type),
+		Image:        c.Image,
+		Region:       c.Region,
+		SshPublicKey: c.SSHPublicKey,
+		Env:          c.Env,
+		Ports:        intsToProto(c.Ports),
+		OwnerId:      c.OwnerID,
+		TeamId:       c.TeamID,
+	}
+	for _, v := range c.Volumes {
+		out.Volumes = append(out.Volumes, &providerpb.VolumeMount{Name: v.Name, MountPath: v.MountPath, SizeGb: int32(v.SizeGB)})
+	}
+	if dc := c.DevContainer; dc != nil {
+		out.Devcontainer = &providerpb.DevContainerSpec{
+			Image:             dc.Image,
+			PostCreateCommand: dc.PostCreateCommand,
+			ForwardPorts:      intsToProto(dc.ForwardPorts),
+		}
+		if dc.Features != nil {
+			features, err := json.Marshal(dc.Features)
+			if err != nil {
+				return nil, err
+			}
+			out.Devcontainer.FeaturesJson = string(features)
+		}
+	}
+	return out, nil
+}
+
+func configFromProto(c *providerpb.InstanceConfig) (providers.InstanceConfig, error) {
+	out := providers.InstanceConfig{
+		Name:         c.GetName(),
+		Type:         providers.InstanceType(c.GetType()),
+		Image:        c.GetImage(),
+		Region:       c.GetRegion(),
+		SSHPublicKey: c.GetSshPublicKey(),
+		Env:          c.GetEnv(),
+		Ports:        intsFromProto(c.GetPorts()),
+		OwnerID:      c.GetOwnerId(),
+		TeamID:       c.GetTeamId(),
+	}
+	for _, v := range c.GetVolumes() {
+		out.Volumes = append(out.Volumes, providers.VolumeMount{Name: v.Name, MountPath: v.MountPath, SizeGB: int(v.SizeGb)})
+	}
+	if dc := c.GetDevcontainer(); dc != nil {
+		out.DevContainer = &providers.DevContainerSpec{
+			Image:             dc.Image,
+			PostCreateCommand: dc.PostCreateCommand,
+			ForwardPorts:      intsFromProto(dc.ForwardPorts),
+		}
+		if dc.FeaturesJson != "" {
+			if err := json.Unmarshal([]byte(dc.FeaturesJson), &out.DevContainer.Features); err != nil {
+				return out, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func instanceToProto(i *providers.Instance) *providerpb.Instance {
+	out := &providerpb.Instance{
+		Id:         i.ID,
+		Name:       i.Name,
+		Type:       string(i.Type),
+		Status:     string(i.Status),
+		Provider:   string(i.Provider),
+		Region:     i.Region,
+		PublicIp:   i.PublicIP,
+		PrivateIp:  i.PrivateIP,
+		SshPort:    int32(i.SSHPort),
+		OwnerId:    i.OwnerID,
+		TeamId:     i.TeamID,
+		Metadata:   i.Metadata,
+		HourlyRate: i.HourlyRate,
+		TotalCost:  i.TotalCost,
+	}
+	if !i.CreatedAt.IsZero() {
+		out.CreatedAt = timestamppb.New(i.CreatedAt)
+	}
+	if !i.UpdatedAt.IsZero() {
+		out.UpdatedAt = timestamppb.New(i.UpdatedAt)
+	}
+	if len(i.ExposedPorts) > 0 {
+		out.ExposedPorts = make(map[int32]int32, len(i.ExposedPorts))
+		for container, host := range i.ExposedPorts {
+			out.ExposedPorts[int32(container)] = int32(host)
+		}
+	}
+	return out
+}
+
+func instanceFromProto(i *providerpb.Instance) *providers.Instance {
+	out := &providers.Instance{
+		ID:         i.Id,
+		Name:       i.Name,
+		Type:       providers.InstanceType(i.Type),
+		Status:     providers.InstanceStatus(i.Status),
+		Provider:   providers.ProviderType(i.Provider),
+		Region:     i.Region,
+		PublicIP:   i.PublicIp,
+		PrivateIP:  i.PrivateIp,
+		SSHPort:    int(i.SshPort),
+		OwnerID:    i.OwnerId,
+		TeamID:     i.TeamId,
+		Metadata:   i.Metadata,
+		HourlyRate: i.HourlyRate,
+		TotalCost:  i.TotalCost,
+	}
+	if i.CreatedAt != nil {
+		out.CreatedAt = i.CreatedAt.AsTime()
+	}
+	if i.UpdatedAt != nil {
+		out.UpdatedAt = i.UpdatedAt.AsTime()
+	}
+	if len(i.ExposedPorts) > 0 {
+		out.ExposedPorts = make(map[int]int, len(i.ExposedPorts))
+		for container, host := range i.ExposedPorts {
+			out.ExposedPorts[int(container)] = int(host)
+		}
+	}
+	return out
+}
+
+func intsToProto(ints []int) []int32 {
+	if ints == nil {
+		return nil
+	}
+	out := make([]int32, len(ints))
+	for i, v := range ints {
+		out[i] = int32(v)
+	}
+	return out
+}
+
+func intsFromProto(ints []int32) []int {
+	if ints == nil {
+		return nil
+	}
+	out := make([]int, len(ints))
+	for i, v := range ints {
+		out[i] = int(v)
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+// Package plugin runs cloud providers out of tree, as plugin processes that
+// serve the providerpb gRPC protocol through hashicorp/go-plugin
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin/providerpb"
+)
+
+const (
+	// ProtocolVersion is the newest plugin protocol version; the control
+	// plane and a plugin agree on the newest version both speak
+	ProtocolVersion = 1
+
+	// FilePrefix starts the names of provider plugin executables, e.g.
+	// cm-provider-openstack
+	FilePrefix = "cm-provider-"
+
+	// pluginName is the name providers are dispensed under
+	pluginName = "provider"
+
+	// infoTimeout bounds asking a newly started plugin for its provider's
+	// description
+	infoTimeout = 10 * time.Second
+)
+
+// Handshake is shared by the control plane and its plugins. The cookie only
+// keeps plugins from being run by hand; it isn't a security measure.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  ProtocolVersion,
+	MagicCookieKey:   "CM_PROVIDER_PLUGIN",
+	MagicCookieValue: "4d3c1b0e-cm-provider",
+}
+
+// pluginSets are the plugins of each protocol version, for serving impl or,
+// with nil, for dispensing clients
+func pluginSets(impl providers.Provider) map[int]goplugin.PluginSet {
+	return map[int]goplugin.PluginSet{
+		1: {pluginName: &providerPlugin{impl: impl}},
+	}
+}
+
+// providerPlugin carries a Provider over gRPC
+type providerPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	impl providers.Provider
+}
+
+func (p *providerPlugin) GRPCServer(_ *goplugin.GRPCBroker, s *grpc.Server) error {
+	providerpb.RegisterProviderServer(s, &server{impl: p.impl})
+	return nil
+}
+
+func (p *providerPlugin) GRPCClient(_ context.Context, _ *goplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return providerpb.NewProviderClient(c), nil
+}
+
+// Serve serves a provider from a plugin's main function until the control
+// plane stops the plugin
+func Serve(p providers.Provider) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig:  Handshake,
+		VersionedPlugins: pluginSets(p),
+		GRPCServer:       goplugin.DefaultGRPCServer,
+	})
+}
+
+// Plugins are the running provider plugin processes
+type Plugins struct {
+	clients []*goplugin.Client
+}
+
+// Load starts the provider plugins in dir, the executables named
+// cm-provider-*, and registers their providers with m. A plugin that fails
+// to start, speaks no protocol version the control plane does, or names a
+// provider m already has is stopped and skipped, and its error returned
+// with the others.
+func Load(dir string, m *providers.Manager, logger hclog.Logger) (*Plugins, []error) {
+	loaded := &Plugins{}
+	paths, err := goplugin.Discover(FilePrefix+"*", dir)
+	if err != nil {
+		return loaded, []error{err}
+	}
+
+	var errs []error
+	for _, path := range paths {
+		file := filepath.Base(path)
+		if strings.HasSuffix(file, ".sha256") || strings.HasPrefix(file, ".") {
+			continue
+		}
+		c, provider, err := start(path, logger)
+		if err == nil {
+			if _, taken := m.Get(provider.Name()); taken == nil {
+				err = fmt.Errorf("provider %q is already registered", provider.Name())
+			}
+		}
+		if err != nil {
+			if c != nil {
+				c.Kill()
+			}
+			errs = append(errs, fmt.Errorf("provider plugin %s: %w", file, err))
+			continue
+		}
+		m.Register(provider)
+		loaded.clients = append(loaded.clients, c)
+		logger.Info("loaded provider plugin", "path", path, "provider", provider.Name(), "protocol", c.NegotiatedVersion())
+	}
+	return loaded, errs
+}
+
+// start runs a plugin executable and asks it for its provider
+func start(path string, logger hclog.Logger) (*goplugin.Client, *client, error) {
+	cmd := exec.Command(path)
+	// The control plane's environment holds its secrets; plugins get their
+	// configuration as provider credentials instead
+	for _, key := range []string{"PATH", "HOME", "TMPDIR", "SYSTEMROOT"} {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	c := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		VersionedPlugins: pluginSets(nil),
+		Cmd:              cmd,
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		AutoMTLS:         true,
+		SkipHostEnv:      true,
+		Logger:           logger.Named(filepath.Base(path)),
+	})
+	rpc, err := c.Client()
+	if err != nil {
+		return c, nil, err
+	}
+	raw, err := rpc.Dispense(pluginName)
+	if err != nil {
+		return c, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), infoTimeout)
+	defer cancel()
+	provider, err := newClient(ctx, raw.(providerpb.ProviderClient))
+	return c, provider, err
+}
+
+// Kill stops the plugin processes
+func (p *Plugins) Kill() {
+	for _, c := range p.clients {
+		c.Kill()
+	}
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	goplugin "github.com/hashicorp/go-plugin"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin/providerpb"
+)
+
+// fakeProvider describes itself like the Docker provider and answers
+// instance calls from memory
+type fakeProvider struct {
+	providers.Provider
+}
+
+func (fakeProvider) Name() providers.ProviderType { return "fake" }
+
+func (fakeProvider) CreateInstance(ctx context.Context, config providers.InstanceConfig) (*providers.Instance, error) {
+	return &providers.Instance{
+		ID:           "i-1",
+		Name:         config.Name,
+		Type:         config.Type,
+		Status:       providers.StatusRunning,
+		Provider:     "fake",
+		Region:       config.Region,
+		SSHPort:      2222,
+		ExposedPorts: map[int]int{8080: 32768},
+		CreatedAt:    time.Date(2026, 5, 4, 8, 30, 0, 0, time.UTC),
+		Metadata: map[string]string{
+			"request_id": providers.RequestID(ctx),
+			"feature":    config.DevContainer.Features["ghcr.io/devcontainers/features/go:1"].(map[string]interface{})["version"].(string),
+		},
+	}, nil
+}
+
+func (fakeProvider) GetInstance(context.Context, string) (*providers.Instance, error) {
+	return nil, errors.New("instance not found")
+}
+
+func (fakeProvider) StreamLogs(ctx context.Context, id string) (<-chan string, error) {
+	if id != "i-1" {
+		return nil, errors.New("no logs for " + id)
+	}
+	lines := make(chan string, 2)
+	lines <- "one"
+	lines <- "two"
+	close(lines)
+	return lines, nil
+}
+
+func dispense(t *testing.T) *client {
+	t.Helper()
+	impl := fakeProvider{Provider: providers.NewDockerProvider()}
+	rpc, _ := goplugin.TestPluginGRPCConn(t, false, pluginSets(impl)[ProtocolVersion])
+	t.Cleanup(func() { rpc.Close() })
+	raw, err := rpc.Dispense(pluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newClient(context.Background(), raw.(providerpb.ProviderClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPluginProvider(t *testing.T) {
+	c := dispense(t)
+	docker := providers.NewDockerProvider()
+	if c.Name() != "fake" || c.DisplayName() != docker.DisplayName() {
+		t.Errorf("name = %q, %q, want fake, %q", c.Name(), c.DisplayName(), docker.DisplayName())
+	}
+	if !reflect.DeepEqual(c.Regions(), docker.Regions()) || !reflect.DeepEqual(c.InstanceTypes(), docker.InstanceTypes()) {
+		t.Errorf("regions and types = %+v, %+v, want the Docker provider's", c.Regions(), c.InstanceTypes())
+	}
+
+	ctx := providers.WithRequestID(context.Background(), "req-1")
+	inst, err := c.CreateInstance(ctx, providers.InstanceConfig{
+		Name:   "dev",
+		Type:   providers.InstanceTypeCPUSmall,
+		Region: "local",
+		DevContainer: &providers.DevContainerSpec{
+			Features: map[string]interface{}{"ghcr.io/devcontainers/features/go:1": map[string]interface{}{"version": "1.24"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &providers.Instance{
+		ID:           "i-1",
+		Name:         "dev",
+		Type:         providers.InstanceTypeCPUSmall,
+		Status:       providers.StatusRunning,
+		Provider:     "fake",
+		Region:       "local",
+		SSHPort:      2222,
+		ExposedPorts: map[int]int{8080: 32768},
+		CreatedAt:    time.Date(2026, 5, 4, 8, 30, 0, 0, time.UTC),
+		Metadata:     map[string]string{"request_id": "req-1", "feature": "1.24"},
+	}
+	if !reflect.DeepEqual(inst, want) {
+		t.Errorf("created instance = %+v, want %+v", inst, want)
+	}
+
+	if _, err := c.GetInstance(ctx, "i-2"); err == nil || err.Error() != "instance not found" {
+		t.Errorf("GetInstance error = %v, want the provider's own", err)
+	}
+	if err := c.StopInstance(ctx, "i-1"); err == nil {
+		t.Error("StopInstance of a provider without it succeeded")
+	}
+}
+
+func TestPluginStreamLogs(t *testing.T) {
+	c := dispense(t)
+	lines, err := c.StreamLogs(context.Background(), "i-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	if !reflect.DeepEqual(got, []string{"one", "two"}) {
+		t.Errorf("lines = %q, want one, two", got)
+	}
+
+	if _, err := c.StreamLogs(context.Background(), "i-2"); err == nil || err.Error() != "no logs for i-2" {
+		t.Errorf("StreamLogs error = %v, want the provider's own", err)
+	}
+}
@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/UPwith-me/Container-Maker/cloud/providers"
+	"github.com/UPwith-me/Container-Maker/cloud/providers/plugin/providerpb"
+)
+
+// requestIDHeader carries the API request ID of a provider call to the
+// plugin
+const requestIDHeader = "x-request-id"
+
+// server serves a Provider in a plugin process
+type server struct {
+	providerpb.UnimplementedProviderServer
+	impl providers.Provider
+}
+
+// callContext returns the context of a call with its request ID, for the
+// provider to tag its work with
+func callContext(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDHeader); len(ids) > 0 {
+			return providers.WithRequestID(ctx, ids[0])
+		}
+	}
+	return ctx
+}
+
+func (s *server) Info(context.Context, *providerpb.InfoRequest) (*providerpb.InfoResponse, error) {
+	return &providerpb.InfoResponse{
+		Name:                string(s.impl.Name()),
+		DisplayName:         s.impl.DisplayName(),
+		Description:         s.impl.Description(),
+		Website:             s.impl.Website(),
+		Features:            s.impl.Features(),
+		RequiredCredentials: s.impl.RequiredCredentials(),
+		Regions:             regionsToProto(s.impl.Regions()),
+		InstanceTypes:       pricingToProto(s.impl.InstanceTypes()),
+	}, nil
+}
+
+func (s *server) Configure(_ context.Context, req *providerpb.ConfigureRequest) (*providerpb.Empty, error) {
+	return &providerpb.Empty{}, s.impl.Configure(req.Credentials)
+}
+
+func (s *server) IsAvailable(ctx context.Context, _ *providerpb.Empty) (*providerpb.IsAvailableResponse, error) {
+	return &providerpb.IsAvailableResponse{Available: s.impl.IsAvailable(callContext(ctx))}, nil
+}
+
+func (s *server) CreateInstance(ctx context.Context, req *providerpb.CreateInstanceRequest) (*providerpb.Instance, error) {
+	config, err := configFromProto(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := s.impl.CreateInstance(callContext(ctx), config)
+	if err != nil {
+		return nil, err
+	}
+	return instanceToProto(inst), nil
+}
+
+func (s *server) GetInstance(ctx context.Context, req *providerpb.InstanceRequest) (*providerpb.Instance, error) {
+	inst, err := s.impl.GetInstance(callContext(ctx), req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return instanceToProto(inst), nil
+}
+
+func (s *server) ListInstances(ctx context.Context, req *providerpb.ListInstancesRequest) (*providerpb.ListInstancesResponse, error) {
+	instances, err := s.impl.ListInstances(callContext(ctx), req.OwnerId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &providerpb.ListInstancesResponse{}
+	for _, inst := range instances {
+		resp.Instances = append(resp.Instances, instanceToProto(inst))
+	}
+	return resp, nil
+}
+
+func (s *server) StartInstance(ctx context.Context, req *providerpb.InstanceRequest) (*providerpb.Empty, error) {
+	return &providerpb.Empty{}, s.impl.StartInstance(callContext(ctx), req.Id)
+}
+
+func (s *server) StopInstance(ctx context.Context, req *providerpb.InstanceRequest) (*providerpb.Empty, error) {
+	return &providerpb.Empty{}, s.impl.StopInstance(callContext(ctx), req.Id)
+}
+
+func (s *server) DeleteInstance(ctx context.Context, req *providerpb.InstanceRequest) (*providerpb.Empty, error) {
+	return &providerpb.Empty{}, s.impl.DeleteInstance(callContext(ctx), req.Id)
+}
+
+func (s *server) GetSSHEndpoint(ctx context.Context, req *providerpb.InstanceRequest) (*providerpb.SSHEndpoint, error) {
+	host, port, err := s.impl.GetSSHEndpoint(callContext(ctx), req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &providerpb.SSHEndpoint{Host: host, Port: int32(port)}, nil
+}
+
+func (s *server) ExecCommand(ctx context.Context, req *providerpb.ExecRequest) (*providerpb.ExecResponse, error) {
+	stdout, stderr, exitCode, err := s.impl.ExecCommand(callContext(ctx), req.Id, req.Command)
+	if err != nil {
+		return nil, err
+	}
+	return &providerpb.ExecResponse{Stdout: stdout, Stderr: stderr, ExitCode: int32(exitCode)}, nil
+}
+
+func (s *server) GetLogs(ctx context.Context, req *providerpb.LogsRequest) (*providerpb.LogsResponse, error) {
+	logs, err := s.impl.GetLogs(callContext(ctx), req.Id, int(req.Tail))
+	if err != nil {
+		return nil, err
+	}
+	return &providerpb.LogsResponse{Logs: logs}, nil
+}
+
+func (s *server) StreamLogs(req *providerpb.InstanceRequest, stream grpc.ServerStreamingServer[providerpb.LogLine]) error {
+	lines, err := s.impl.StreamLogs(callContext(stream.Context()), req.Id)
+	if err != nil {
+		return err
+	}
+	// Tells the client the stream is open before the first line
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for line := range lines {
+		if err := stream.Send(&providerpb.LogLine{Line: line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
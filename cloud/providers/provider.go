@@ -50,6 +50,7 @@ const (
 	ProviderLambdaLabs   ProviderType = "lambdalabs"   // Lambda Labs (GPU)
 	ProviderRunpod       ProviderType = "runpod"       // RunPod (GPU)
 	ProviderVast         ProviderType = "vast"         // Vast.ai (GPU)
+	ProviderHosts        ProviderType = "hosts"        // Machines registered by users and teams
 )
 
 // InstanceConfig defines the configuration for creating an instance
@@ -63,6 +64,8 @@ type InstanceConfig struct {
 	Ports        []int             `json:"ports"`        // Exposed ports
 	Volumes      []VolumeMount     `json:"volumes"`      // Persistent volumes
 	DevContainer *DevContainerSpec `json:"devcontainer"` // Optional devcontainer.json
+	OwnerID      string            `json:"owner_id,omitempty"`
+	TeamID       string            `json:"team_id,omitempty"`
 }
 
 // VolumeMount defines a persistent storage mount
@@ -212,5 +215,6 @@ func AvailableProviders() []ProviderType {
 		ProviderLambdaLabs,
 		ProviderRunpod,
 		ProviderVast,
+		ProviderHosts,
 	}
 }
@@ -0,0 +1,39 @@
+// Package providers defines block storage volumes that move between instances
+package providers
+
+import "context"
+
+// VolumeConfig defines a volume to create
+type VolumeConfig struct {
+	Name       string `json:"name"`
+	Region     string `json:"region"`
+	Zone       string `json:"zone,omitempty"` // Availability zone, where volumes are zonal
+	SizeGB     int    `json:"size_gb"`
+	SnapshotID string `json:"snapshot_id,omitempty"` // Restores this provider snapshot
+	OwnerID    string `json:"owner_id,omitempty"`
+}
+
+// Volume is a provider's block storage volume
+type Volume struct {
+	ID     string `json:"id"`
+	Region string `json:"region"`
+	Zone   string `json:"zone,omitempty"`
+	SizeGB int    `json:"size_gb"`
+	Device string `json:"device,omitempty"` // Linux device path on the instance it's attached to
+}
+
+// VolumeProvider is implemented by providers with block storage that can be
+// detached from one instance and attached to another
+type VolumeProvider interface {
+	CreateVolume(ctx context.Context, config VolumeConfig) (*Volume, error)
+	AttachVolume(ctx context.Context, volumeID, instanceID string) (*Volume, error)
+	DetachVolume(ctx context.Context, volumeID string) error
+	DeleteVolume(ctx context.Context, volumeID string) error
+}
+
+// VolumeSnapshotter is implemented by providers that snapshot volumes
+// themselves; other volumes are snapshotted with restic from the instance
+type VolumeSnapshotter interface {
+	SnapshotVolume(ctx context.Context, volumeID, description string) (snapshotID string, err error)
+	DeleteSnapshot(ctx context.Context, snapshotID string) error
+}
@@ -8,8 +8,10 @@ import CreateInstance from './pages/CreateInstance'
 import InstanceDetail from './pages/InstanceDetail'
 import Login from './pages/Login'
 import Register from './pages/Register'
+import AuthCallback from './pages/AuthCallback'
 import Billing from './pages/Billing'
 import Settings from './pages/Settings'
+import Device from './pages/Device'
 import Onboarding from './components/Onboarding'
 import { ThemeProvider } from './components/ThemeToggle'
 
@@ -22,7 +24,7 @@ function AppContent() {
     // const token = localStorage.getItem('access_token') // Unused
 
     // Show onboarding for new users (not completed and not on auth pages)
-    if (!completed && !window.location.pathname.includes('/login') && !window.location.pathname.includes('/register')) {
+    if (!completed && !window.location.pathname.includes('/login') && !window.location.pathname.includes('/register') && !window.location.pathname.includes('/auth/')) {
       setShowOnboarding(true)
     }
   }, [])
@@ -54,6 +56,7 @@ function AppContent() {
         {/* Public routes */}
         <Route path="/login" element={<Login />} />
         <Route path="/register" element={<Register />} />
+        <Route path="/auth/callback" element={<AuthCallback />} />
 
         {/* Protected routes with layout */}
         <Route element={<Layout />}>
@@ -63,6 +66,7 @@ function AppContent() {
           <Route path="/instances/:id" element={<InstanceDetail />} />
           <Route path="/billing" element={<Billing />} />
           <Route path="/settings" element={<Settings />} />
+          <Route path="/device" element={<Device />} />
         </Route>
 
         {/* Catch all */}
@@ -1,4 +1,5 @@
-import { Outlet, Link, useLocation } from 'react-router-dom'
+import { useCallback } from 'react'
+import { Outlet, Link, useLocation, useNavigate } from 'react-router-dom'
 import { motion } from 'framer-motion'
 import {
     Box,
@@ -13,9 +14,32 @@ import {
 import { cn } from '@/lib/utils'
 import { ThemeToggle } from '@/components/ThemeToggle'
 import CommandPalette from '@/components/CommandPalette'
+import { useCloudAlerts } from '@/hooks/useWebSocket'
+import { toast } from 'sonner'
 
 export default function Layout() {
     const location = useLocation()
+    const navigate = useNavigate()
+
+    // Warn before an idle policy stops an instance and as spend nears the
+    // budget, on every page
+    const onStopWarning = useCallback((name: string, reason: string, stopAt: Date) => {
+        const offHours = reason === 'schedule'
+        toast.warning(`${name} will be stopped ${offHours ? 'for off-hours' : 'because it is idle'} at ${stopAt.toLocaleTimeString()}`, {
+            description: offHours
+                ? 'Start it again afterwards if you still need it.'
+                : 'Connect to it or run a command to keep it running.',
+            duration: 60000,
+        })
+    }, [])
+    const onBudgetAlert = useCallback((text: string, hardStop: boolean) => {
+        const show = hardStop ? toast.error : toast.warning
+        show(text, {
+            action: { label: 'Billing', onClick: () => navigate('/billing') },
+            duration: 60000,
+        })
+    }, [navigate])
+    useCloudAlerts(onStopWarning, onBudgetAlert)
 
     const navItems = [
         { icon: LayoutDashboard, label: 'Overview', path: '/' },
@@ -140,3 +140,24 @@ export function useDashboardUpdates(
 
     return { connected }
 }
+
+// Hook for account alerts: an idle policy is about to stop an instance, or
+// spend crossed a budget threshold
+export function useCloudAlerts(
+    onStopWarning: (name: string, reason: string, stopAt: Date) => void,
+    onBudgetAlert: (text: string, hardStop: boolean) => void
+) {
+    const { lastMessage } = useWebSocket()
+
+    useEffect(() => {
+        if (!lastMessage) return
+
+        if (lastMessage.type === 'instance_stop_warning') {
+            const { name, reason, stop_at } = lastMessage.payload
+            onStopWarning(name, reason, new Date(stop_at))
+        } else if (lastMessage.type === 'budget_alert') {
+            const { text, hard_stop, spent, limit } = lastMessage.payload
+            onBudgetAlert(text, hard_stop && spent >= limit)
+        }
+    }, [lastMessage, onStopWarning, onBudgetAlert])
+}
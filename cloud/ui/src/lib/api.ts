@@ -113,12 +113,58 @@ export interface InstanceType {
     gpu_memory_gb?: number
 }
 
+export interface Offer {
+    region: string
+    type: string
+    hourly_rate: number
+    currency: string
+    available: boolean
+}
+
+export interface Capabilities {
+    provider: string
+    regions: Region[]
+    types: InstanceType[]
+    offers: Offer[]
+    live: boolean
+    fetched_at: string
+    error?: string
+}
+
+export interface CostEstimate extends InstanceType {
+    provider: string
+    region: string
+    currency: string
+    total: number
+    live: boolean
+}
+
+export interface EstimateRequest {
+    instance_type?: string
+    region?: string
+    hours: number
+    min_vcpu?: number
+    min_memory_gb?: number
+    min_gpu_memory_gb?: number
+    gpu_type?: string
+}
+
+export interface Estimate {
+    hours: number
+    estimates: CostEstimate[]
+    cheapest_gpu?: CostEstimate
+}
+
+export type APIKeyScope = 'all' | 'read' | 'instances:write' | 'billing:read'
+
 export interface APIKey {
     id: string
     name: string
     key_prefix: string
+    scopes: string
     created_at: string
     last_used_at?: string
+    expires_at?: string
 }
 
 export interface CloudCredential {
@@ -150,6 +196,30 @@ export interface UsageData {
         instances: number
         forecast: number
     }
+    budget?: {
+        monthly_limit: number
+        spent: number
+        hard_stop: boolean
+    }
+}
+
+export interface Budget {
+    monthly_limit: number
+    hard_stop: boolean
+    alert_thresholds: string
+    alert_email?: string
+    webhook_url?: string
+    webhook_secret?: string // Signs alerts; only when the webhook is set
+    spent?: number
+    period?: string
+}
+
+export interface IdlePolicy {
+    idle_minutes: number
+    warn_minutes: number
+    shutdown_at?: string
+    timezone?: string
+    weekdays?: string
 }
 
 export interface User {
@@ -174,6 +244,13 @@ export const api = {
             body: JSON.stringify({ email, password, name })
         }),
 
+    // Approve or deny a CLI login started with `cm cloud login`
+    approveDevice: (userCode: string, deny = false) =>
+        request<{ status: string }>('/auth/device/approve', {
+            method: 'POST',
+            body: JSON.stringify({ user_code: userCode, deny })
+        }),
+
     // User
     getCurrentUser: () => request<User>('/user'),
 
@@ -214,6 +291,15 @@ export const api = {
     getSSHConfig: (id: string) =>
         request<{ host: string; port: number; user: string }>(`/instances/${id}/ssh`),
 
+    // Idle policy: when running instances are stopped automatically
+    getIdlePolicy: () => request<IdlePolicy>('/idle-policy'),
+
+    updateIdlePolicy: (data: IdlePolicy) =>
+        request<IdlePolicy>('/idle-policy', {
+            method: 'PUT',
+            body: JSON.stringify(data)
+        }),
+
     // Providers
     getProviders: () => request<Provider[]>('/providers'),
 
@@ -222,13 +308,23 @@ export const api = {
     getProviderInstanceTypes: (name: string) =>
         request<InstanceType[]>(`/providers/${name}/types`),
 
+    getProviderCapabilities: (name: string, refresh = false) =>
+        request<Capabilities>(`/providers/${name}/capabilities${refresh ? '?refresh=true' : ''}`),
+
+    // Projected cost of a type with each provider, and the cheapest GPU offer
+    estimateCost: (data: EstimateRequest) =>
+        request<Estimate>('/estimates', {
+            method: 'POST',
+            body: JSON.stringify(data)
+        }),
+
     // API Keys
     getAPIKeys: () => request<APIKey[]>('/api-keys'),
 
-    createAPIKey: (name: string) =>
+    createAPIKey: (name: string, scopes: APIKeyScope[] = [], expiresInDays = 0) =>
         request<{ key: string; id: string }>('/api-keys', {
             method: 'POST',
-            body: JSON.stringify({ name })
+            body: JSON.stringify({ name, scopes, expires_in_days: expiresInDays })
         }),
 
     deleteAPIKey: (id: string) =>
@@ -257,6 +353,14 @@ export const api = {
     // Billing
     getUsage: () => request<UsageData>('/billing/usage'),
 
+    getBudget: () => request<Budget>('/billing/budget'),
+
+    updateBudget: (data: Budget) =>
+        request<Budget>('/billing/budget', {
+            method: 'PUT',
+            body: JSON.stringify(data)
+        }),
+
     getInvoices: () => request<Invoice[]>('/billing/invoices'),
 
     getInvoicePdfUrl: (id: string) =>
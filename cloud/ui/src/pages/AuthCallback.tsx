@@ -0,0 +1,30 @@
+import { useEffect } from 'react'
+import { useNavigate, useSearchParams } from 'react-router-dom'
+import { Loader2 } from 'lucide-react'
+import { toast } from 'sonner'
+
+// AuthCallback stores the tokens a single sign-on redirect hands over and
+// continues to the dashboard
+export default function AuthCallback() {
+    const navigate = useNavigate()
+    const [params] = useSearchParams()
+
+    useEffect(() => {
+        const accessToken = params.get('access_token')
+        const refreshToken = params.get('refresh_token')
+        if (!accessToken || !refreshToken) {
+            navigate('/login?error=' + encodeURIComponent('Sign-in failed'), { replace: true })
+            return
+        }
+        localStorage.setItem('access_token', accessToken)
+        localStorage.setItem('refresh_token', refreshToken)
+        toast.success('Welcome back!')
+        navigate('/', { replace: true })
+    }, [params, navigate])
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-background">
+            <Loader2 className="h-6 w-6 animate-spin text-muted-foreground" />
+        </div>
+    )
+}
@@ -10,9 +10,11 @@ import {
     Plus,
     ExternalLink,
     Loader2,
-    AlertCircle
+    AlertCircle,
+    Wallet
 } from 'lucide-react'
-import { api, type UsageData, type Invoice } from '@/lib/api'
+import { cn } from '@/lib/utils'
+import { api, type UsageData, type Invoice, type Budget } from '@/lib/api'
 import { toast } from 'sonner'
 
 export default function Billing() {
@@ -22,6 +24,9 @@ export default function Billing() {
     const [portalLoading, setPortalLoading] = useState(false)
     const [downloadingAll, setDownloadingAll] = useState(false)
     const [downloadingId, setDownloadingId] = useState<string | null>(null)
+    const [budget, setBudget] = useState<Budget>({ monthly_limit: 0, hard_stop: false, alert_thresholds: '50,80,100' })
+    const [budgetSaving, setBudgetSaving] = useState(false)
+    const [webhookSecret, setWebhookSecret] = useState<string | null>(null)
 
     useEffect(() => {
         loadData()
@@ -30,12 +35,14 @@ export default function Billing() {
     const loadData = async () => {
         setLoading(true)
         try {
-            const [usageData, invoiceData] = await Promise.all([
+            const [usageData, invoiceData, budgetData] = await Promise.all([
                 api.getUsage(),
-                api.getInvoices()
+                api.getInvoices(),
+                api.getBudget()
             ])
             setUsage(usageData)
             setInvoices(invoiceData || [])
+            setBudget(budgetData)
         } catch (e) {
             console.error('Failed to load billing data:', e)
         } finally {
@@ -43,6 +50,22 @@ export default function Billing() {
         }
     }
 
+    const saveBudget = async () => {
+        setBudgetSaving(true)
+        try {
+            const data = await api.updateBudget(budget)
+            if (data.webhook_secret) {
+                setWebhookSecret(data.webhook_secret)
+            }
+            setBudget(data)
+            toast.success('Budget updated!')
+        } catch (e: any) {
+            toast.error(e.message || 'Failed to update budget')
+        } finally {
+            setBudgetSaving(false)
+        }
+    }
+
     const openBillingPortal = async () => {
         setPortalLoading(true)
         try {
@@ -125,6 +148,7 @@ export default function Billing() {
     }
 
     const hasNoUsage = data.cpu_hours === 0 && data.gpu_hours === 0 && data.total_cost === 0
+    const budgetUsed = budget.monthly_limit > 0 ? (budget.spent || 0) / budget.monthly_limit * 100 : 0
 
     return (
         <div className="space-y-8">
@@ -208,6 +232,103 @@ export default function Billing() {
                 </div>
             </div>
 
+            {/* Monthly Budget */}
+            <div className="p-6 rounded-xl border border-border/40 bg-card/30">
+                <div className="flex items-center justify-between mb-2">
+                    <h3 className="text-lg font-semibold">Monthly Budget</h3>
+                    <Wallet className="h-4 w-4 text-emerald-500" />
+                </div>
+                <p className="text-sm text-muted-foreground mb-6">
+                    Get alerted as spend crosses each threshold, by email and webhook. With hard stop, running instances are stopped and new ones refused once the limit is reached.
+                </p>
+
+                {budget.monthly_limit > 0 && (
+                    <div className="mb-6">
+                        <div className="flex justify-between text-sm mb-2">
+                            <span>${(budget.spent || 0).toFixed(2)} of ${budget.monthly_limit.toFixed(2)}</span>
+                            <span className="text-muted-foreground">{budgetUsed.toFixed(0)}%</span>
+                        </div>
+                        <div className="h-2 rounded-full bg-muted/40 overflow-hidden">
+                            <div
+                                className={cn(
+                                    "h-full rounded-full",
+                                    budgetUsed >= 100 ? "bg-red-500" : budgetUsed >= 80 ? "bg-amber-500" : "bg-emerald-500"
+                                )}
+                                style={{ width: `${Math.min(budgetUsed, 100)}%` }}
+                            />
+                        </div>
+                    </div>
+                )}
+
+                <div className="space-y-4">
+                    <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                        <div>
+                            <label className="block text-sm font-medium mb-2">Limit per month in USD (0 = none)</label>
+                            <input
+                                type="number"
+                                min={0}
+                                step="any"
+                                value={budget.monthly_limit}
+                                onChange={e => setBudget(prev => ({ ...prev, monthly_limit: Number(e.target.value) }))}
+                                className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                            />
+                        </div>
+                        <div>
+                            <label className="block text-sm font-medium mb-2">Alert at % of the limit</label>
+                            <input
+                                type="text"
+                                value={budget.alert_thresholds}
+                                onChange={e => setBudget(prev => ({ ...prev, alert_thresholds: e.target.value }))}
+                                placeholder="50,80,100"
+                                className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                            />
+                        </div>
+                        <div>
+                            <label className="block text-sm font-medium mb-2">Alert email</label>
+                            <input
+                                type="email"
+                                value={budget.alert_email || ''}
+                                onChange={e => setBudget(prev => ({ ...prev, alert_email: e.target.value }))}
+                                placeholder="Your account email"
+                                className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                            />
+                        </div>
+                        <div>
+                            <label className="block text-sm font-medium mb-2">Webhook URL</label>
+                            <input
+                                type="url"
+                                value={budget.webhook_url || ''}
+                                onChange={e => setBudget(prev => ({ ...prev, webhook_url: e.target.value }))}
+                                placeholder="https://hooks.slack.com/services/..."
+                                className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                            />
+                            {webhookSecret && (
+                                <p className="text-xs text-muted-foreground mt-2">
+                                    Signing secret: <code className="break-all">{webhookSecret}</code>. Save it now; it isn't shown again. Verify X-CM-Signature with it.
+                                </p>
+                            )}
+                        </div>
+                    </div>
+                    <label className="flex items-center gap-2 text-sm">
+                        <input
+                            type="checkbox"
+                            checked={budget.hard_stop}
+                            onChange={e => setBudget(prev => ({ ...prev, hard_stop: e.target.checked }))}
+                            className="accent-emerald-500"
+                        />
+                        Hard stop: stop instances when the limit is reached
+                    </label>
+                    <button
+                        onClick={saveBudget}
+                        disabled={budgetSaving}
+                        className="px-4 py-2 bg-emerald-500 hover:bg-emerald-600 text-white rounded-lg font-medium transition-colors flex items-center gap-2 disabled:opacity-50"
+                    >
+                        {budgetSaving && <Loader2 className="h-4 w-4 animate-spin" />}
+                        Save Budget
+                    </button>
+                </div>
+            </div>
+
             {/* Payment Method */}
             <div className="p-6 rounded-xl border border-border/40 bg-card/30">
                 <div className="flex items-center justify-between mb-6">
@@ -1,50 +1,118 @@
-import { useState, useEffect } from 'react'
+import { useState, useEffect, useRef } from 'react'
 import { useNavigate } from 'react-router-dom'
-import { Check, Cpu, Globe, Rocket, Box } from 'lucide-react'
-import { api, type Provider, type Region } from '@/lib/api'
+import { Check, Cpu, Globe, Rocket, Box, Calculator, Sparkles } from 'lucide-react'
+import { api, type Capabilities, type Estimate, type Provider } from '@/lib/api'
 import { cn } from '@/lib/utils'
 import { toast } from 'sonner'
 
-const instanceTypes = [
-    { id: 'cpu-small', name: 'CPU Small', vcpu: 2, ram: '4GB', price: '$0.02/hr', type: 'cpu' },
-    { id: 'cpu-medium', name: 'CPU Medium', vcpu: 4, ram: '8GB', price: '$0.04/hr', type: 'cpu' },
-    { id: 'gpu-t4', name: 'NVIDIA T4', vcpu: 4, ram: '16GB', gpu: '1x T4', price: '$0.50/hr', type: 'gpu' },
-    { id: 'gpu-a10', name: 'NVIDIA A10', vcpu: 8, ram: '32GB', gpu: '1x A10', price: '$1.50/hr', type: 'gpu' },
-]
+const typeNames: Record<string, string> = {
+    'cpu-small': 'CPU Small',
+    'cpu-medium': 'CPU Medium',
+    'cpu-large': 'CPU Large',
+    'gpu-t4': 'NVIDIA T4',
+    'gpu-a10': 'NVIDIA A10',
+    'gpu-a100': 'NVIDIA A100',
+}
+
+const currencySymbols: Record<string, string> = { USD: '$', EUR: '€' }
+
+function formatRate(rate: number, currency: string) {
+    return `${currencySymbols[currency] ?? currency + ' '}${rate < 0.1 ? rate.toFixed(4) : rate.toFixed(2)}/hr`
+}
+
+function formatTotal(total: number, currency: string) {
+    return `${currencySymbols[currency] ?? currency + ' '}${total.toFixed(2)}`
+}
 
 export default function CreateInstance() {
     const navigate = useNavigate()
     const [providers, setProviders] = useState<Provider[]>([])
-    const [regions, setRegions] = useState<Region[]>([])
+    const [capabilities, setCapabilities] = useState<Capabilities | null>(null)
     const [selectedProvider, setSelectedProvider] = useState('docker')
     const [selectedRegion, setSelectedRegion] = useState('')
     const [selectedType, setSelectedType] = useState('cpu-small')
     const [name, setName] = useState('')
     const [dockerImage, setDockerImage] = useState('ubuntu:latest')
     const [isSubmitting, setIsSubmitting] = useState(false)
+    const [hours, setHours] = useState(8)
+    const [estimate, setEstimate] = useState<Estimate | null>(null)
+    // Region to select once a suggested provider's regions have loaded
+    const pendingRegion = useRef('')
 
     useEffect(() => {
         api.getProviders().then(setProviders)
     }, [])
 
-    // Fetch regions when provider changes
+    // Project the cost of the selected type with every provider
+    useEffect(() => {
+        if (!selectedType || !(hours > 0)) {
+            setEstimate(null)
+            return
+        }
+        const timer = setTimeout(() => {
+            api.estimateCost({ instance_type: selectedType, region: selectedRegion, hours })
+                .then(setEstimate)
+                .catch(() => setEstimate(null))
+        }, 300)
+        return () => clearTimeout(timer)
+    }, [selectedType, selectedRegion, hours])
+
+    // Fetch regions, prices and availability when provider changes
     useEffect(() => {
         if (selectedProvider) {
-            api.getProviderRegions(selectedProvider)
+            api.getProviderCapabilities(selectedProvider)
                 .then(data => {
-                    setRegions(data || [])
-                    if (data && data.length > 0) {
-                        setSelectedRegion(data[0].id)
-                    }
+                    setCapabilities(data)
+                    const region = data.regions?.find(r => r.id === pendingRegion.current)
+                        ?? data.regions?.find(r => r.available) ?? data.regions?.[0]
+                    pendingRegion.current = ''
+                    setSelectedRegion(region?.id ?? '')
                 })
                 .catch(() => {
                     // Default region for providers without region support
-                    setRegions([{ id: 'local', name: 'Local', country: '', available: true, gpu_available: false }])
+                    setCapabilities({
+                        provider: selectedProvider,
+                        regions: [{ id: 'local', name: 'Local', country: '', available: true, gpu_available: false }],
+                        types: [],
+                        offers: [],
+                        live: false,
+                        fetched_at: '',
+                    })
                     setSelectedRegion('local')
                 })
         }
     }, [selectedProvider])
 
+    const regions = capabilities?.regions ?? []
+    const instanceTypes = (capabilities?.types ?? []).map(t => {
+        const offer = (capabilities?.offers ?? []).find(o => o.region === selectedRegion && o.type === t.type)
+        return {
+            id: t.type,
+            name: typeNames[t.type] ?? t.type,
+            vcpu: t.vcpu,
+            ram: `${t.memory_gb}GB`,
+            gpu: t.gpu_type ? `1x ${t.gpu_type}` : undefined,
+            type: t.gpu_type ? 'gpu' : 'cpu',
+            price: offer ? formatRate(offer.hourly_rate, offer.currency) : formatRate(t.hourly_rate, 'USD'),
+            available: offer ? offer.available : true,
+        }
+    })
+
+    const selectedOffer = capabilities?.offers.find(o => o.region === selectedRegion && o.type === selectedType)
+    const selectedEstimate = estimate?.estimates.find(e => e.provider === selectedProvider)
+    const otherEstimates = (estimate?.estimates ?? []).filter(e => e.provider !== selectedProvider).slice(0, 4)
+    const cheapestGPU = estimate?.cheapest_gpu
+    const switchToCheapestGPU = () => {
+        if (!cheapestGPU) return
+        if (cheapestGPU.provider === selectedProvider) {
+            setSelectedRegion(cheapestGPU.region)
+        } else {
+            pendingRegion.current = cheapestGPU.region
+            setSelectedProvider(cheapestGPU.provider)
+        }
+        setSelectedType(cheapestGPU.type)
+    }
+
     const handleSubmit = async () => {
         setIsSubmitting(true)
         try {
@@ -99,13 +167,19 @@ export default function CreateInstance() {
             {/* Step 2: Instance Type */}
             <section>
                 <h3 className="text-sm font-medium text-muted-foreground uppercase tracking-wider mb-4">2. Select Instance Type</h3>
+                {capabilities && !capabilities.live && capabilities.types.length > 0 && (
+                    <p className="text-xs text-muted-foreground -mt-2 mb-4">
+                        Estimated prices{capabilities.error && ` (live pricing unavailable: ${capabilities.error})`}
+                    </p>
+                )}
                 <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
                     {instanceTypes.map((t) => (
                         <button
                             key={t.id}
                             onClick={() => setSelectedType(t.id)}
+                            disabled={!t.available}
                             className={cn(
-                                "relative flex items-center gap-4 p-4 rounded-xl border text-left transition-all",
+                                "relative flex items-center gap-4 p-4 rounded-xl border text-left transition-all disabled:opacity-40 disabled:cursor-not-allowed",
                                 selectedType === t.id
                                     ? "bg-emerald-500/5 border-emerald-500"
                                     : "bg-card/50 border-border/40 hover:border-foreground/20"
@@ -123,7 +197,7 @@ export default function CreateInstance() {
                                     <span className="text-sm font-mono">{t.price}</span>
                                 </div>
                                 <div className="text-xs text-muted-foreground">
-                                    {t.vcpu} vCPU • {t.ram} RAM {t.gpu && `• ${t.gpu}`}
+                                    {t.vcpu} vCPU • {t.ram} RAM {t.gpu && `• ${t.gpu}`} {!t.available && '• Unavailable in this region'}
                                 </div>
                             </div>
                             {selectedType === t.id && (
@@ -161,7 +235,7 @@ export default function CreateInstance() {
                             >
                                 {regions.map(r => (
                                     <option key={r.id} value={r.id} disabled={!r.available}>
-                                        {r.name} {r.country && `(${r.country})`} {!r.available && '- Unavailable'}
+                                        {r.name} {r.country && `(${r.country})`} {!r.available ? '- Unavailable' : r.gpu_available && '- GPU'}
                                     </option>
                                 ))}
                             </select>
@@ -186,6 +260,77 @@ export default function CreateInstance() {
                 </div>
             </section>
 
+            {/* Step 4: Estimated cost */}
+            <section>
+                <h3 className="text-sm font-medium text-muted-foreground uppercase tracking-wider mb-4">4. Estimated Cost</h3>
+                <div className="p-6 rounded-xl border border-border/40 bg-card/30 space-y-5">
+                    <div className="flex flex-wrap items-end gap-6">
+                        <div>
+                            <label className="block text-sm font-medium mb-2 flex items-center gap-2">
+                                <Calculator className="h-4 w-4" />
+                                Expected Hours
+                            </label>
+                            <input
+                                type="number"
+                                min={1}
+                                value={hours}
+                                onChange={(e) => setHours(Number(e.target.value))}
+                                className="w-32 px-4 py-2 rounded-md bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500 transition-all"
+                            />
+                        </div>
+                        <div>
+                            <div className="text-sm text-muted-foreground mb-1">Projected total</div>
+                            <div className="text-2xl font-bold font-mono">
+                                {selectedEstimate
+                                    ? formatTotal(selectedEstimate.total, selectedEstimate.currency)
+                                    : estimate && selectedOffer?.hourly_rate === 0 ? 'Free' : '—'}
+                            </div>
+                        </div>
+                        {selectedEstimate && selectedEstimate.region !== selectedRegion && (
+                            <p className="text-xs text-muted-foreground">Priced in {selectedEstimate.region}, the provider's cheapest region</p>
+                        )}
+                    </div>
+
+                    {otherEstimates.length > 0 && (
+                        <div>
+                            <div className="text-xs text-muted-foreground mb-2">Same type elsewhere</div>
+                            <div className="grid grid-cols-2 md:grid-cols-4 gap-3">
+                                {otherEstimates.map(e => (
+                                    <div key={e.provider} className="p-3 rounded-lg border border-border/40 bg-background/50">
+                                        <div className="text-xs text-muted-foreground">{e.provider} • {e.region}</div>
+                                        <div className="font-mono font-semibold">{formatTotal(e.total, e.currency)}</div>
+                                    </div>
+                                ))}
+                            </div>
+                        </div>
+                    )}
+
+                    {cheapestGPU && (
+                        <div className="flex items-center justify-between gap-4 p-4 rounded-lg border border-purple-500/30 bg-purple-500/5">
+                            <div className="flex items-center gap-3">
+                                <Sparkles className="h-5 w-5 text-purple-500" />
+                                <div>
+                                    <div className="text-sm font-semibold">
+                                        Cheapest GPU: {cheapestGPU.gpu_type} on {cheapestGPU.provider} ({cheapestGPU.region})
+                                    </div>
+                                    <div className="text-xs text-muted-foreground">
+                                        {cheapestGPU.vcpu} vCPU • {cheapestGPU.memory_gb}GB RAM • {formatTotal(cheapestGPU.total, cheapestGPU.currency)} for {hours} hours
+                                    </div>
+                                </div>
+                            </div>
+                            {(cheapestGPU.provider !== selectedProvider || cheapestGPU.type !== selectedType || cheapestGPU.region !== selectedRegion) && (
+                                <button
+                                    onClick={switchToCheapestGPU}
+                                    className="text-sm font-medium text-purple-500 hover:text-purple-400 whitespace-nowrap"
+                                >
+                                    Use this
+                                </button>
+                            )}
+                        </div>
+                    )}
+                </div>
+            </section>
+
             <div className="flex justify-end pt-6 border-t border-border/40">
                 <button
                     onClick={handleSubmit}
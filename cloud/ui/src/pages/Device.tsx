@@ -0,0 +1,71 @@
+import { useState } from 'react'
+import { useSearchParams } from 'react-router-dom'
+import { Terminal, Loader2, CheckCircle, XCircle } from 'lucide-react'
+import { api } from '@/lib/api'
+import { toast } from 'sonner'
+
+// Device approves a CLI login: `cm cloud login` shows a code and opens this page
+export default function Device() {
+    const [searchParams] = useSearchParams()
+    const [code, setCode] = useState(searchParams.get('code') ?? '')
+    const [status, setStatus] = useState<'idle' | 'submitting' | 'approved' | 'denied'>('idle')
+
+    const submit = async (deny: boolean) => {
+        setStatus('submitting')
+        try {
+            await api.approveDevice(code.trim(), deny)
+            setStatus(deny ? 'denied' : 'approved')
+        } catch (e: any) {
+            toast.error(e.message || 'Invalid or expired code')
+            setStatus('idle')
+        }
+    }
+
+    if (status === 'approved' || status === 'denied') {
+        return (
+            <div className="max-w-md mx-auto mt-20 text-center space-y-4">
+                {status === 'approved'
+                    ? <CheckCircle className="h-12 w-12 mx-auto text-emerald-500" />
+                    : <XCircle className="h-12 w-12 mx-auto text-red-500" />}
+                <h2 className="text-2xl font-bold">{status === 'approved' ? 'CLI Authorized' : 'Request Denied'}</h2>
+                <p className="text-muted-foreground">You can close this window and return to the terminal.</p>
+            </div>
+        )
+    }
+
+    return (
+        <div className="max-w-md mx-auto mt-20 space-y-6">
+            <div className="text-center">
+                <Terminal className="h-12 w-12 mx-auto text-emerald-500 mb-4" />
+                <h2 className="text-2xl font-bold mb-2">Authorize the CLI</h2>
+                <p className="text-muted-foreground">
+                    Confirm this code matches the one shown by <code className="font-mono">cm cloud login</code>.
+                </p>
+            </div>
+            <input
+                type="text"
+                value={code}
+                onChange={(e) => setCode(e.target.value.toUpperCase())}
+                placeholder="XXXX-XXXX"
+                className="w-full px-4 py-3 rounded-md bg-background border border-border text-center text-2xl font-mono tracking-widest focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+            />
+            <div className="flex gap-3">
+                <button
+                    onClick={() => submit(true)}
+                    disabled={!code || status === 'submitting'}
+                    className="flex-1 px-4 py-2 rounded-lg border border-border hover:bg-muted/50 transition-all disabled:opacity-50"
+                >
+                    Deny
+                </button>
+                <button
+                    onClick={() => submit(false)}
+                    disabled={!code || status === 'submitting'}
+                    className="flex-1 bg-emerald-500 text-white hover:bg-emerald-600 px-4 py-2 rounded-lg font-bold flex items-center justify-center gap-2 transition-all disabled:opacity-50"
+                >
+                    {status === 'submitting' && <Loader2 className="h-4 w-4 animate-spin" />}
+                    Authorize
+                </button>
+            </div>
+        </div>
+    )
+}
@@ -1,15 +1,27 @@
-import { useState } from 'react'
-import { useNavigate, Link } from 'react-router-dom'
+import { useState, useEffect } from 'react'
+import { useNavigate, useSearchParams, Link } from 'react-router-dom'
 import { motion } from 'framer-motion'
-import { Mail, Lock, Github, Loader2, AlertCircle } from 'lucide-react'
+import { Mail, Lock, Github, Loader2, AlertCircle, KeyRound } from 'lucide-react'
 import { toast } from 'sonner'
 
 export default function Login() {
     const navigate = useNavigate()
+    const [searchParams] = useSearchParams()
     const [email, setEmail] = useState('')
     const [password, setPassword] = useState('')
     const [loading, setLoading] = useState(false)
-    const [error, setError] = useState('')
+    // Single sign-on redirects back here with an error if it fails
+    const [error, setError] = useState(searchParams.get('error') || '')
+    const [sso, setSSO] = useState<{ enabled: boolean; name: string }>({ enabled: false, name: 'SSO' })
+
+    useEffect(() => {
+        fetch('/api/v1/auth/providers')
+            .then(res => res.ok ? res.json() : null)
+            .then(data => {
+                if (data?.oidc) setSSO({ enabled: true, name: data.oidc_name || 'SSO' })
+            })
+            .catch(() => { })
+    }, [])
 
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault()
@@ -41,10 +53,10 @@ export default function Login() {
         }
     }
 
-    const handleOAuth = (provider: 'github' | 'google') => {
+    const handleOAuth = (provider: 'github' | 'google' | 'oidc') => {
         // Check if OAuth is configured by trying to start the flow
         // The backend will redirect appropriately
-        toast.loading(`Connecting to ${provider}...`)
+        toast.loading(`Connecting to ${provider === 'oidc' ? sso.name : provider}...`)
         window.location.href = `/api/v1/auth/${provider}`
     }
 
@@ -127,6 +139,16 @@ export default function Login() {
                         </div>
                     </div>
 
+                    {sso.enabled && (
+                        <button
+                            onClick={() => handleOAuth('oidc')}
+                            className="w-full mb-4 flex items-center justify-center gap-2 py-2.5 rounded-lg border border-border bg-background hover:bg-muted/50 transition-colors"
+                        >
+                            <KeyRound className="h-4 w-4" />
+                            <span className="text-sm font-medium">Sign in with {sso.name}</span>
+                        </button>
+                    )}
+
                     <div className="grid grid-cols-2 gap-4">
                         <button
                             onClick={() => handleOAuth('github')}
@@ -12,17 +12,24 @@ import {
     Loader2,
     CheckCircle,
     XCircle,
-    Settings2
+    Settings2,
+    Moon
 } from 'lucide-react'
 import { cn } from '@/lib/utils'
-import { api, type APIKey, type CloudCredential, type User as UserType } from '@/lib/api'
+import { api, type APIKey, type APIKeyScope, type CloudCredential, type IdlePolicy, type User as UserType } from '@/lib/api'
 import { toast } from 'sonner'
 import CredentialModal from '@/components/CredentialModal'
 import AdminTab from '@/components/AdminTab'
 import { RestartOnboardingButton } from '@/components/Onboarding'
 
+const keyScopes: { id: APIKeyScope; label: string }[] = [
+    { id: 'read', label: 'Read-only' },
+    { id: 'instances:write', label: 'Manage instances' },
+    { id: 'billing:read', label: 'Read billing' },
+]
+
 export default function Settings() {
-    const [activeTab, setActiveTab] = useState<'profile' | 'api-keys' | 'credentials' | 'admin'>('profile')
+    const [activeTab, setActiveTab] = useState<'profile' | 'api-keys' | 'credentials' | 'auto-shutdown' | 'admin'>('profile')
 
     // Profile state
     const [user, setUser] = useState<Partial<UserType>>({ name: '', email: '' })
@@ -32,6 +39,8 @@ export default function Settings() {
     // API Keys state
     const [apiKeys, setApiKeys] = useState<APIKey[]>([])
     const [newKeyName, setNewKeyName] = useState('')
+    const [newKeyScopes, setNewKeyScopes] = useState<APIKeyScope[]>([])
+    const [newKeyExpiry, setNewKeyExpiry] = useState(0)
     const [createdKey, setCreatedKey] = useState<string | null>(null)
     const [keyLoading, setKeyLoading] = useState(false)
     const [copied, setCopied] = useState(false)
@@ -41,11 +50,16 @@ export default function Settings() {
     const [credLoading, setCredLoading] = useState(false)
     const [modalProvider, setModalProvider] = useState<string | null>(null)
 
+    // Auto-shutdown state
+    const [idlePolicy, setIdlePolicy] = useState<IdlePolicy>({ idle_minutes: 0, warn_minutes: 10 })
+    const [policySaving, setPolicySaving] = useState(false)
+
     // Load data on mount
     useEffect(() => {
         loadProfile()
         loadAPIKeys()
         loadCredentials()
+        loadIdlePolicy()
     }, [])
 
     const loadProfile = async () => {
@@ -81,6 +95,28 @@ export default function Settings() {
         }
     }
 
+    const loadIdlePolicy = async () => {
+        try {
+            const data = await api.getIdlePolicy()
+            setIdlePolicy(data)
+        } catch (e) {
+            console.error('Failed to load idle policy:', e)
+        }
+    }
+
+    const saveIdlePolicy = async () => {
+        setPolicySaving(true)
+        try {
+            const data = await api.updateIdlePolicy(idlePolicy)
+            setIdlePolicy(data)
+            toast.success('Auto-shutdown updated!')
+        } catch (e: any) {
+            toast.error(e.message || 'Failed to update auto-shutdown')
+        } finally {
+            setPolicySaving(false)
+        }
+    }
+
     const saveProfile = async () => {
         setProfileSaving(true)
         try {
@@ -101,9 +137,10 @@ export default function Settings() {
 
         setKeyLoading(true)
         try {
-            const data = await api.createAPIKey(newKeyName)
+            const data = await api.createAPIKey(newKeyName, newKeyScopes, newKeyExpiry)
             setCreatedKey(data.key)
             setNewKeyName('')
+            setNewKeyScopes([])
             loadAPIKeys()
             toast.success('API key created!')
         } catch (e: any) {
@@ -152,6 +189,7 @@ export default function Settings() {
         { id: 'profile', label: 'Profile', icon: User },
         { id: 'api-keys', label: 'API Keys', icon: Key },
         { id: 'credentials', label: 'Cloud Credentials', icon: Cloud },
+        { id: 'auto-shutdown', label: 'Auto-shutdown', icon: Moon },
         { id: 'admin', label: 'Admin', icon: Settings2 },
     ] as const
 
@@ -265,6 +303,29 @@ export default function Settings() {
                                 Create
                             </button>
                         </div>
+                        <div className="mt-4 flex flex-wrap items-center gap-4 text-sm">
+                            <span className="text-muted-foreground">Scopes (none = full access):</span>
+                            {keyScopes.map(scope => (
+                                <label key={scope.id} className="flex items-center gap-2">
+                                    <input
+                                        type="checkbox"
+                                        checked={newKeyScopes.includes(scope.id)}
+                                        onChange={e => setNewKeyScopes(prev => e.target.checked ? [...prev, scope.id] : prev.filter(s => s !== scope.id))}
+                                    />
+                                    {scope.label}
+                                </label>
+                            ))}
+                            <select
+                                value={newKeyExpiry}
+                                onChange={e => setNewKeyExpiry(Number(e.target.value))}
+                                className="ml-auto px-3 py-1.5 rounded-lg bg-background border border-border"
+                            >
+                                <option value={0}>Never expires</option>
+                                <option value={30}>Expires in 30 days</option>
+                                <option value={90}>Expires in 90 days</option>
+                                <option value={365}>Expires in 1 year</option>
+                            </select>
+                        </div>
 
                         {createdKey && (
                             <div className="mt-4 p-4 rounded-lg bg-amber-500/10 border border-amber-500/20">
@@ -303,6 +364,16 @@ export default function Settings() {
                                         <div>
                                             <p className="font-medium">{key.name}</p>
                                             <p className="text-sm text-muted-foreground font-mono">{key.key_prefix}••••••••</p>
+                                            <p className="text-xs text-muted-foreground mt-1">
+                                                {key.scopes || 'all'}
+                                                {' · '}
+                                                {key.last_used_at ? `Last used ${new Date(key.last_used_at).toLocaleDateString()}` : 'Never used'}
+                                                {key.expires_at && (
+                                                    <span className={cn(new Date(key.expires_at) < new Date() && 'text-red-500')}>
+                                                        {' · '}Expires {new Date(key.expires_at).toLocaleDateString()}
+                                                    </span>
+                                                )}
+                                            </p>
                                         </div>
                                         <button
                                             onClick={() => deleteAPIKey(key.id)}
@@ -393,6 +464,81 @@ export default function Settings() {
                 </motion.div>
             )}
 
+            {/* Auto-shutdown Tab */}
+            {activeTab === 'auto-shutdown' && (
+                <motion.div initial={{ opacity: 0 }} animate={{ opacity: 1 }} className="space-y-6">
+                    <div className="p-6 rounded-xl border border-border/40 bg-card/30">
+                        <h3 className="text-lg font-semibold mb-2">Auto-shutdown</h3>
+                        <p className="text-muted-foreground text-sm mb-6">
+                            Stop running instances nobody uses, so they don't keep billing. An instance is idle while the cm agent on it reports no SSH sessions, container execs or CPU load.
+                        </p>
+                        <div className="space-y-4">
+                            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                                <div>
+                                    <label className="block text-sm font-medium mb-2">Stop after idle minutes (0 = off)</label>
+                                    <input
+                                        type="number"
+                                        min={0}
+                                        value={idlePolicy.idle_minutes}
+                                        onChange={e => setIdlePolicy(prev => ({ ...prev, idle_minutes: Number(e.target.value) }))}
+                                        className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                                    />
+                                </div>
+                                <div>
+                                    <label className="block text-sm font-medium mb-2">Warn minutes before stopping</label>
+                                    <input
+                                        type="number"
+                                        min={0}
+                                        value={idlePolicy.warn_minutes}
+                                        onChange={e => setIdlePolicy(prev => ({ ...prev, warn_minutes: Number(e.target.value) }))}
+                                        className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                                    />
+                                </div>
+                            </div>
+                            <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+                                <div>
+                                    <label className="block text-sm font-medium mb-2">Off-hours stop at</label>
+                                    <input
+                                        type="time"
+                                        value={idlePolicy.shutdown_at || ''}
+                                        onChange={e => setIdlePolicy(prev => ({ ...prev, shutdown_at: e.target.value }))}
+                                        className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                                    />
+                                </div>
+                                <div>
+                                    <label className="block text-sm font-medium mb-2">Time zone</label>
+                                    <input
+                                        type="text"
+                                        value={idlePolicy.timezone || ''}
+                                        onChange={e => setIdlePolicy(prev => ({ ...prev, timezone: e.target.value }))}
+                                        placeholder="UTC"
+                                        className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                                    />
+                                </div>
+                                <div>
+                                    <label className="block text-sm font-medium mb-2">Weekdays</label>
+                                    <input
+                                        type="text"
+                                        value={idlePolicy.weekdays || ''}
+                                        onChange={e => setIdlePolicy(prev => ({ ...prev, weekdays: e.target.value }))}
+                                        placeholder="Every day, or mon,tue,wed,thu,fri"
+                                        className="w-full px-4 py-2.5 rounded-lg bg-background border border-border focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500"
+                                    />
+                                </div>
+                            </div>
+                            <button
+                                onClick={saveIdlePolicy}
+                                disabled={policySaving}
+                                className="px-4 py-2 bg-emerald-500 hover:bg-emerald-600 text-white rounded-lg font-medium transition-colors flex items-center gap-2 disabled:opacity-50"
+                            >
+                                {policySaving && <Loader2 className="h-4 w-4 animate-spin" />}
+                                Save Changes
+                            </button>
+                        </div>
+                    </div>
+                </motion.div>
+            )}
+
             {/* Admin Tab */}
             {activeTab === 'admin' && (
                 <AdminTab />
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage the background agent that speeds up 'cm exec'",
+	Long: `Manage the optional background agent.
+
+'cm exec' skips its container checks while a project's container is known
+to be ready. Without the agent that knowledge expires after CM_READY_TTL
+(default 60s). The agent watches Docker events and invalidates it the
+moment a container stops, so the fast path stays valid until then.
+
+On a cloud instance the agent also tells the control plane once a minute
+whether the instance is in use (SSH sessions, container execs or CPU
+load), which idle policies ('cm cloud policy') stop instances by. It keeps
+an outbound WebSocket open to the control plane, over which the dashboard
+and 'cm cloud exec/logs' run commands, stream logs, check health and
+start or stop containers, so instances behind NAT or a firewall that
+blocks inbound connections can be managed without SSH. Instances start
+it at boot.
+
+On a machine registered with 'cm cloud host add', started with the
+CM_HOST_ID and CM_AGENT_TOKEN it prints, the agent reports the machine's
+CPUs, memory and GPUs instead, and the control plane runs instances there
+as containers over the same channel.
+
+EXAMPLES
+  cm agent start
+  cm agent status
+  cm agent stop`,
+}
+
+var agentStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the agent in the background",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if info, ok := runner.RunningAgent(); ok {
+			fmt.Printf("🤖 Agent already running (pid %d)\n", info.PID)
+			return nil
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		logPath := filepath.Join(home, ".cm", "agent.log")
+		if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+			return err
+		}
+		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer logFile.Close()
+
+		proc := exec.Command(exe, "agent", "run")
+		proc.Stdout = logFile
+		proc.Stderr = logFile
+		if err := proc.Start(); err != nil {
+			return fmt.Errorf("failed to start agent: %w", err)
+		}
+		_ = proc.Process.Release()
+
+		// Wait for the agent to record itself
+		for i := 0; i < 20; i++ {
+			if info, ok := runner.RunningAgent(); ok {
+				fmt.Printf("✅ Agent started (pid %d)\n", info.PID)
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return fmt.Errorf("agent did not start; see %s", logPath)
+	},
+}
+
+var agentRunCmd = &cobra.Command{
+	Use:    "run",
+	Short:  "Run the agent in the foreground",
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Outlive the terminal that started it
+		signal.Ignore(syscall.SIGHUP)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runner.RunAgent(ctx)
+	},
+}
+
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the agent",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runner.StopAgent(); err != nil {
+			return err
+		}
+		fmt.Println("🛑 Agent stopped")
+		return nil
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the agent is running",
+	Run: func(cmd *cobra.Command, args []string) {
+		info, ok := runner.RunningAgent()
+		if !ok {
+			fmt.Println("🤖 Agent is not running")
+			return
+		}
+		fmt.Printf("🤖 Agent running (pid %d, up %s)\n", info.PID, time.Since(info.StartedAt).Round(time.Second))
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(agentStartCmd)
+	agentCmd.AddCommand(agentRunCmd)
+	agentCmd.AddCommand(agentStopCmd)
+	agentCmd.AddCommand(agentStatusCmd)
+	rootCmd.AddCommand(agentCmd)
+}
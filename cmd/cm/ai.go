@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/ai"
+	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
 	"github.com/spf13/cobra"
 )
 
@@ -15,14 +21,19 @@ var aiCmd = &cobra.Command{
 	Short: "AI-powered features",
 	Long: `Use AI to analyze your project and generate optimal configurations.
 
-Requires AI to be enabled and an API key to be set:
+Requires AI to be enabled and, except for local models, an API key to be set:
   cm config set ai.enabled true
+  cm config set ai.provider openai  # openai, anthropic, azure, ollama or llamacpp
   cm config set ai.api_key sk-xxx
   cm config set ai.api_base https://api.openai.com/v1  # Optional
 
+Secrets such as API keys, tokens and passwords are redacted from everything
+sent to the provider.
+
 Examples:
   cm ai generate        # Generate devcontainer.json for current project
-  cm ai analyze         # Analyze project without generating`,
+  cm ai analyze         # Analyze project without generating
+  cm ai usage           # Show tokens used per provider`,
 }
 
 var aiGenerateCmd = &cobra.Command{
@@ -30,14 +41,25 @@ var aiGenerateCmd = &cobra.Command{
 	Short: "Generate devcontainer.json using AI",
 	Long: `Analyze your project and generate an optimal devcontainer.json configuration.
 
-The AI will:
-1. Detect programming languages
-2. Identify dependencies
-3. Choose the best base image
-4. Configure appropriate features
-5. Set up VS Code extensions
+The detector's report on the project (languages, versions, frameworks,
+package managers, GPU needs), its template recommendations and the config
+detection alone proposes are sent to the configured provider:
+
+  cm config set ai.provider openai     # Default; any OpenAI-compatible API
+  cm config set ai.provider anthropic
+  cm config set ai.provider azure      # Azure OpenAI; ai.model is the deployment
+  cm config set ai.provider ollama     # Local, no API key needed
+  cm config set ai.provider llamacpp   # Local llama.cpp server
 
-You will be asked to confirm before saving.`,
+The answer is validated against the devcontainer.json specification and
+shown as a diff before anything is written. Without AI, or when it fails,
+the best-scoring template for the detected project is proposed instead.
+
+Examples:
+  cm ai generate            # Preview and write
+  cm ai generate --dry-run  # Preview only
+  cm ai generate --no-ai    # Use the template scorer only`,
+	Args: cobra.NoArgs,
 	RunE: runAIGenerate,
 }
 
@@ -47,12 +69,26 @@ var aiAnalyzeCmd = &cobra.Command{
 	RunE:  runAIAnalyze,
 }
 
+var aiUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show AI token usage per provider",
+	Args:  cobra.NoArgs,
+	RunE:  runAIUsage,
+}
+
 var aiDryRun bool
+var aiYes bool
+var aiNoAI bool
+var aiUsageReset bool
 
 func init() {
 	aiGenerateCmd.Flags().BoolVar(&aiDryRun, "dry-run", false, "Show generated config without saving")
+	aiGenerateCmd.Flags().BoolVarP(&aiYes, "yes", "y", false, "Write without asking for confirmation")
+	aiGenerateCmd.Flags().BoolVar(&aiNoAI, "no-ai", false, "Propose the best-scoring template instead of asking AI")
 	aiCmd.AddCommand(aiGenerateCmd)
 	aiCmd.AddCommand(aiAnalyzeCmd)
+	aiUsageCmd.Flags().BoolVar(&aiUsageReset, "reset", false, "Clear the recorded usage")
+	aiCmd.AddCommand(aiUsageCmd)
 	rootCmd.AddCommand(aiCmd)
 }
 
@@ -66,39 +102,35 @@ func runAIGenerate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Create generator
-	gen, err := ai.NewGenerator()
+	existing, err := detect.ReadDevcontainer(projectDir)
 	if err != nil {
-		fmt.Printf("❌ %v\n", err)
-		fmt.Println()
-		fmt.Println("💡 To enable AI features:")
-		fmt.Println("   cm config set ai.enabled true")
-		fmt.Println("   cm config set ai.api_key <your-api-key>")
-		return nil
+		return err
 	}
 
-	fmt.Printf("📁 Analyzing project: %s\n", projectDir)
-	fmt.Println()
-
-	// Show spinner
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+	proposal, err := aiProposal(projectDir)
+	if err != nil {
+		if !aiNoAI {
+			fmt.Printf("⚠️  %v\n", err)
+			fmt.Println("   Falling back to the template scorer")
+			fmt.Println()
+		}
+		if proposal, err = scorerProposal(projectDir); err != nil {
+			return err
+		}
+	}
 
-	fmt.Print("⏳ Generating configuration... ")
+	if !proposal.Changes(existing) {
+		fmt.Println("✅ devcontainer.json already matches the generated config.")
+		return nil
+	}
 
-	config, err := gen.AnalyzeProject(ctx, projectDir)
-	if err != nil {
-		fmt.Println("❌")
-		return fmt.Errorf("generation failed: %w", err)
+	if existing == nil {
+		fmt.Println("📋 Proposed .devcontainer/devcontainer.json:")
+	} else {
+		fmt.Println("📋 Proposed changes to .devcontainer/devcontainer.json:")
 	}
-	fmt.Println("✅")
 	fmt.Println()
-
-	// Show config
-	fmt.Println("📄 Generated devcontainer.json:")
-	fmt.Println("─────────────────────────────────")
-	fmt.Println(config)
-	fmt.Println("─────────────────────────────────")
+	fmt.Print(proposal.Format(existing))
 	fmt.Println()
 
 	if aiDryRun {
@@ -107,17 +139,19 @@ func runAIGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Confirm
-	fmt.Print("💾 Save this configuration? [Y/n] ")
-	var response string
-	_, _ = fmt.Scanln(&response)
-
-	if response != "" && response != "y" && response != "Y" {
-		fmt.Println("❌ Cancelled")
-		return nil
+	if !aiYes {
+		fmt.Print("💾 Save this configuration? [Y/n] ")
+		var response string
+		_, _ = fmt.Scanln(&response)
+
+		if response != "" && response != "y" && response != "Y" {
+			fmt.Println("❌ Cancelled")
+			return nil
+		}
 	}
 
 	// Save
-	if err := gen.SaveConfig(projectDir, config); err != nil {
+	if err := proposal.Write(projectDir, existing); err != nil {
 		return fmt.Errorf("failed to save: %w", err)
 	}
 
@@ -128,6 +162,124 @@ func runAIGenerate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// aiProposal asks the configured AI provider for a devcontainer.json
+func aiProposal(projectDir string) (*detect.Proposal, error) {
+	if aiNoAI {
+		return nil, fmt.Errorf("AI disabled")
+	}
+
+	gen, err := ai.NewGenerator()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("📁 Analyzing project: %s\n", projectDir)
+	fmt.Printf("⏳ Generating configuration with %s...\n", gen.Provider())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	generated, err := gen.AnalyzeProject(ctx, projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("generation failed: %w", err)
+	}
+	fmt.Println()
+
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(generated), &config); err != nil {
+		return nil, fmt.Errorf("generation failed: %w", err)
+	}
+
+	proposal := &detect.Proposal{Config: config, Reasons: make(map[string]string)}
+	for key := range config {
+		proposal.Reasons[key] = "generated by " + gen.Provider()
+	}
+	return proposal, nil
+}
+
+// scorerProposal proposes the config of the best-scoring template that
+// exists, or the detection-based proposal when none does
+func scorerProposal(projectDir string) (*detect.Proposal, error) {
+	detector := detect.NewDetector(projectDir)
+	info, err := detector.Detect()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rec := range detector.RecommendTemplates() {
+		tmpl, ok := template.GetTemplate(rec.Template)
+		if !ok {
+			continue
+		}
+		rendered, err := tmpl.Render(nil)
+		if err != nil {
+			return nil, err
+		}
+		config, err := rendered.DevcontainerConfig(template.AppliedTemplate{Name: rendered.Name, Version: rendered.Version})
+		if err != nil {
+			return nil, err
+		}
+		config["name"] = info.Name
+
+		reason := fmt.Sprintf("template %s, %s confidence", rec.Template, rec.Confidence)
+		if len(rec.Reasons) > 0 {
+			reason += ": " + strings.Join(rec.Reasons, ", ")
+		}
+		proposal := &detect.Proposal{Config: config, Reasons: make(map[string]string)}
+		for key := range config {
+			proposal.Reasons[key] = reason
+		}
+		proposal.Reasons["name"] = "project directory"
+		return proposal, nil
+	}
+
+	return detect.Propose(projectDir)
+}
+
+func runAIUsage(cmd *cobra.Command, args []string) error {
+	if aiUsageReset {
+		if err := ai.ResetUsage(); err != nil {
+			return err
+		}
+		fmt.Println("✅ AI usage reset")
+		return nil
+	}
+
+	usage, err := ai.LoadUsage()
+	if err != nil {
+		return fmt.Errorf("failed to read AI usage: %w", err)
+	}
+	if len(usage) == 0 {
+		fmt.Println("No AI requests recorded yet")
+		return nil
+	}
+
+	providers := make([]string, 0, len(usage))
+	for name := range usage {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+
+	fmt.Println("📊 AI Token Usage")
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL\tLAST USED")
+	var total ai.UsageRecord
+	for _, name := range providers {
+		r := usage[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", name, r.Requests, r.PromptTokens, r.CompletionTokens,
+			r.PromptTokens+r.CompletionTokens, r.LastUsed.Format("2006-01-02 15:04"))
+		total.Requests += r.Requests
+		total.PromptTokens += r.PromptTokens
+		total.CompletionTokens += r.CompletionTokens
+	}
+	if len(providers) > 1 {
+		fmt.Fprintf(w, "total\t%d\t%d\t%d\t%d\t\n", total.Requests, total.PromptTokens, total.CompletionTokens,
+			total.PromptTokens+total.CompletionTokens)
+	}
+	return w.Flush()
+}
+
 func runAIAnalyze(cmd *cobra.Command, args []string) error {
 	projectDir, err := os.Getwd()
 	if err != nil {
@@ -3,52 +3,68 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/ai"
+	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/spf13/cobra"
+	"github.com/tailscale/hujson"
+	"golang.org/x/term"
 )
 
 var aiDebugCmd = &cobra.Command{
 	Use:   "debug [build-log]",
-	Short: "Diagnose build failures using AI",
-	Long: `Analyze container build logs to identify issues and suggest fixes.
+	Short: "Diagnose build and lifecycle hook failures using AI",
+	Long: `Analyze a failed image build or lifecycle hook to identify the root cause
+and propose patches to devcontainer.json or the Dockerfile.
 
-The AI will analyze error patterns, identify root causes, and provide
-actionable suggestions to fix build failures.
+The output of every failed build and lifecycle hook (postCreateCommand,
+postStartCommand, ...) is kept in .cm/failures, the last 10 per project.
+Without arguments, the most recent failure is analyzed.
+
+Proposed patches are shown as diffs; press the number of a fix to apply it,
+or any other key to leave the files unchanged.
 
 SOURCES:
-  - Pipe build output:    cm prepare 2>&1 | cm ai debug -
+  - Last failure:         cm ai debug
+  - Earlier failure:      cm ai debug --failure 2
   - Specify log file:     cm ai debug build.log
-  - Use last build:       cm ai debug
+  - Pipe build output:    cm prepare 2>&1 | cm ai debug -
 
 EXAMPLES:
-  # Analyze last build failure
+  # Analyze the last failed build or hook
   cm ai debug
 
-  # Analyze specific log file
-  cm ai debug /path/to/build.log
+  # List the recorded failures
+  cm ai debug --list
 
-  # Pipe build output directly
-  cm prepare 2>&1 | cm ai debug -
-
-  # Auto-fix mode (experimental)
+  # Apply the first proposed patch without asking
   cm ai debug --auto-fix`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runAIDebug,
 }
 
 var (
 	aiDebugAutoFix bool
 	aiDebugVerbose bool
+	aiDebugList    bool
+	aiDebugFailure int
 )
 
 func init() {
-	aiDebugCmd.Flags().BoolVar(&aiDebugAutoFix, "auto-fix", false, "Attempt to automatically apply fixes (experimental)")
+	aiDebugCmd.Flags().BoolVar(&aiDebugAutoFix, "auto-fix", false, "Apply the first proposed patch without asking")
 	aiDebugCmd.Flags().BoolVarP(&aiDebugVerbose, "verbose", "v", false, "Show detailed analysis")
+	aiDebugCmd.Flags().BoolVar(&aiDebugList, "list", false, "List the recorded failures")
+	aiDebugCmd.Flags().IntVar(&aiDebugFailure, "failure", 1, "Analyze the Nth most recent failure")
 	aiCmd.AddCommand(aiDebugCmd)
 }
 
@@ -76,8 +92,25 @@ type DebugAnalysis struct {
 type FixSuggestion struct {
 	Description string
 	Command     string
-	FileChange  *FileChange
-	Confidence  float64 // 0-1
+	FileChange  *FileChange      // Text patch, e.g. of the Dockerfile
+	Proposal    *detect.Proposal // Settings to merge into devcontainer.json
+	Confidence  float64          // 0-1
+}
+
+// patch reports whether the fix changes a file
+func (f FixSuggestion) patch() bool {
+	return f.FileChange != nil || f.Proposal != nil
+}
+
+// debugTarget is what is being debugged: the log, and the failure and files
+// it came from when known
+type debugTarget struct {
+	log        string
+	failure    *runner.Failure
+	projectDir string
+	dockerfile string                 // Dockerfile of the build, if any
+	configFile string                 // devcontainer.json, if any
+	config     map[string]interface{} // Parsed devcontainer.json
 }
 
 // FileChange represents a file modification
@@ -89,49 +122,70 @@ type FileChange struct {
 }
 
 func runAIDebug(cmd *cobra.Command, args []string) error {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if aiDebugList {
+		return listFailures(projectDir)
+	}
+
 	fmt.Println("🔍 Container-Maker Build Debugger")
 	fmt.Println()
 
-	// Get build log
-	var log string
-	var err error
-
+	target := &debugTarget{projectDir: projectDir}
 	if len(args) > 0 {
 		if args[0] == "-" {
-			// Read from stdin
-			log, err = readFromStdin()
+			target.log, err = readFromStdin()
+			if err != nil {
+				return err
+			}
 		} else {
-			// Read from file
 			data, readErr := os.ReadFile(args[0])
 			if readErr != nil {
 				return fmt.Errorf("failed to read log file: %w", readErr)
 			}
-			log = string(data)
+			target.log = string(data)
 		}
 	} else {
-		// Try to find last build log
-		log, err = getLastBuildLog()
-		if err != nil {
-			fmt.Println("💡 No recent build log found.")
+		failures, _ := runner.Failures(projectDir)
+		switch {
+		case aiDebugFailure < 1 || (len(failures) > 0 && aiDebugFailure > len(failures)):
+			return fmt.Errorf("--failure must be between 1 and %d", len(failures))
+		case len(failures) > 0:
+			f := failures[aiDebugFailure-1]
+			target.failure = &f
+			target.log = f.Output + "\n" + f.Error
+			fmt.Printf("📌 Failed %s: %s (%s)\n", f.Kind, f.Name, formatAge(f.At))
+			fmt.Printf("   $ %s\n", truncate(f.Command, 100))
 			fmt.Println()
-			fmt.Println("Usage:")
-			fmt.Println("  cm ai debug <logfile>      # Analyze a log file")
-			fmt.Println("  cm prepare 2>&1 | cm ai debug -  # Pipe build output")
-			return nil
+		default:
+			// Logs saved by hand in the usual places
+			if target.log, err = getLastBuildLog(); err != nil {
+				fmt.Println("💡 No failed build or lifecycle hook recorded.")
+				fmt.Println()
+				fmt.Println("Failures of 'cm run', 'cm shell' and 'cm prepare' are kept in .cm/failures.")
+				fmt.Println("Usage:")
+				fmt.Println("  cm ai debug <logfile>      # Analyze a log file")
+				fmt.Println("  cm prepare 2>&1 | cm ai debug -  # Pipe build output")
+				return nil
+			}
 		}
 	}
 
-	if err != nil {
-		return err
-	}
-
-	if log == "" {
+	if strings.TrimSpace(target.log) == "" {
 		return fmt.Errorf("empty build log")
 	}
+	target.locateFiles()
 
 	// Parse errors from log
 	fmt.Print("📋 Parsing build log... ")
-	errors := parseDockerBuildErrors(log)
+	errors := parseDockerBuildErrors(target.log)
+	if len(errors) == 0 && target.failure != nil {
+		// A failure was recorded even if its output matches no known pattern
+		errors = append(errors, BuildError{Type: "command", Command: target.failure.Command, Message: target.failure.Error})
+	}
 	fmt.Printf("found %d error(s)\n", len(errors))
 	fmt.Println()
 
@@ -152,32 +206,95 @@ func runAIDebug(cmd *cobra.Command, args []string) error {
 		if e.Command != "" {
 			fmt.Printf("   Command: %s\n", truncate(e.Command, 60))
 		}
+		if aiDebugVerbose {
+			for _, line := range e.Context {
+				fmt.Printf("   │ %s\n", line)
+			}
+		}
 	}
 	fmt.Println()
 
 	// Try AI analysis if available
-	analysis, aiErr := analyzeWithAI(context.Background(), errors, log)
+	analysis, aiErr := analyzeWithAI(cmd.Context(), errors, target)
 	if aiErr != nil {
 		// Fallback to rule-based analysis
 		fmt.Println("⚠️  AI analysis unavailable, using rule-based analysis")
+		if aiDebugVerbose {
+			fmt.Printf("   %v\n", aiErr)
+		}
+		fmt.Println()
 		analysis = analyzeWithRules(errors)
 	}
+	// Patches known to fix common failures come first
+	analysis.Fixes = append(proposePatches(errors, target), analysis.Fixes...)
 
 	// Display analysis
-	displayAnalysis(analysis)
+	displayAnalysis(analysis, target)
 
-	// Auto-fix if requested
-	if aiDebugAutoFix && len(analysis.Fixes) > 0 {
-		fmt.Println()
-		fmt.Printf("🔧 Attempting auto-fix: %s\n", analysis.Fixes[0].Description)
-		if err := applyFix(analysis.Fixes[0]); err != nil {
-			fmt.Printf("❌ Auto-fix failed: %v\n", err)
-		} else {
-			fmt.Println("✅ Fix applied! Try rebuilding with 'cm prepare'")
+	return offerPatches(analysis.Fixes, target)
+}
+
+// listFailures prints a project's recorded failures
+func listFailures(projectDir string) error {
+	failures, err := runner.Failures(projectDir)
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		fmt.Println("No failed build or lifecycle hook recorded")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tKIND\tNAME\tAGE\tERROR")
+	for i, f := range failures {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, f.Kind, f.Name, formatAge(f.At), truncate(f.Error, 60))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("💡 Analyze one with: cm ai debug --failure <#>")
+	return nil
+}
+
+// locateFiles finds the devcontainer.json and Dockerfile patches may apply to
+func (t *debugTarget) locateFiles() {
+	configDir := filepath.Join(t.projectDir, ".devcontainer")
+	if t.failure != nil {
+		if t.failure.ConfigDir != "" {
+			configDir = t.failure.ConfigDir
 		}
+		t.dockerfile = t.failure.Dockerfile
 	}
 
-	return nil
+	for _, name := range []string{"devcontainer.json", ".devcontainer.json"} {
+		path := filepath.Join(configDir, name)
+		if _, err := os.Stat(path); err == nil {
+			t.configFile = path
+			break
+		}
+	}
+	if t.configFile != "" {
+		if data, err := os.ReadFile(t.configFile); err == nil {
+			if std, err := hujson.Standardize(data); err == nil {
+				_ = json.Unmarshal(std, &t.config)
+			}
+		}
+	}
+
+	if t.dockerfile == "" && t.config != nil {
+		if build, ok := t.config["build"].(map[string]interface{}); ok {
+			if name, ok := build["dockerfile"].(string); ok {
+				t.dockerfile = filepath.Join(filepath.Dir(t.configFile), name)
+			}
+		}
+	}
+	if t.dockerfile == "" {
+		t.dockerfile = filepath.Join(configDir, "Dockerfile")
+	}
+	if _, err := os.Stat(t.dockerfile); err != nil {
+		t.dockerfile = ""
+	}
 }
 
 // readFromStdin reads build log from stdin
@@ -213,6 +330,9 @@ func getLastBuildLog() (string, error) {
 	return "", fmt.Errorf("no build log found")
 }
 
+// missingToolPattern matches shells reporting a command that is not installed
+var missingToolPattern = regexp.MustCompile(`(?i)(?:command not found: ([\w.+-]+)|([\w.+-]+): (?:command )?not found)`)
+
 // parseDockerBuildErrors extracts errors from Docker build output
 func parseDockerBuildErrors(log string) []BuildError {
 	var errors []BuildError
@@ -236,6 +356,21 @@ func parseDockerBuildErrors(log string) []BuildError {
 				}
 			},
 		},
+		// Tool missing from the image, e.g. "sh: 1: npm: not found"
+		{
+			missingToolPattern,
+			"missing-tool",
+			func(lines []string, i int) BuildError {
+				m := missingToolPattern.FindStringSubmatch(lines[i])
+				tool := m[1] + m[2]
+				return BuildError{
+					Type:    "missing-tool",
+					Command: tool,
+					Message: fmt.Sprintf("Command not found: %s", tool),
+					Context: getContext(lines, i, 3),
+				}
+			},
+		},
 		// Package not found (apt)
 		{
 			regexp.MustCompile(`(?i)E: Unable to locate package (.+)`),
@@ -362,17 +497,21 @@ func getContext(lines []string, index, count int) []string {
 	return lines[start:end]
 }
 
-// analyzeWithAI uses AI to analyze build errors
-func analyzeWithAI(ctx context.Context, errors []BuildError, _ string) (*DebugAnalysis, error) {
-	gen, err := ai.NewGenerator()
+// analyzeWithAI asks the configured AI provider for the root cause and for
+// patches to devcontainer.json or the Dockerfile
+func analyzeWithAI(ctx context.Context, errors []BuildError, target *debugTarget) (*DebugAnalysis, error) {
+	provider, err := ai.NewProvider()
 	if err != nil {
 		return nil, err
 	}
 
 	// Build prompt
 	var sb strings.Builder
-	sb.WriteString("Analyze these Docker build errors and provide fixes:\n\n")
-
+	if target.failure != nil {
+		sb.WriteString(fmt.Sprintf("A dev container %s failed: %s\n", target.failure.Kind, target.failure.Name))
+		sb.WriteString(fmt.Sprintf("Command: %s\nError: %s\n\n", target.failure.Command, target.failure.Error))
+	}
+	sb.WriteString("Detected errors:\n\n")
 	for i, e := range errors {
 		sb.WriteString(fmt.Sprintf("Error %d:\n", i+1))
 		sb.WriteString(fmt.Sprintf("  Type: %s\n", e.Type))
@@ -386,34 +525,87 @@ func analyzeWithAI(ctx context.Context, errors []BuildError, _ string) (*DebugAn
 		sb.WriteString("\n")
 	}
 
-	sb.WriteString(`
-Respond in this JSON format:
+	lines := strings.Split(strings.TrimSpace(target.log), "\n")
+	if len(lines) > 150 {
+		lines = lines[len(lines)-150:]
+	}
+	sb.WriteString("End of the output:\n```\n" + strings.Join(lines, "\n") + "\n```\n\n")
+
+	for _, file := range []struct{ name, path string }{{"devcontainer.json", target.configFile}, {"Dockerfile", target.dockerfile}} {
+		if file.path == "" {
+			continue
+		}
+		if data, err := os.ReadFile(file.path); err == nil {
+			sb.WriteString(fmt.Sprintf("%s:\n```\n%s\n```\n\n", file.name, data))
+		}
+	}
+
+	sb.WriteString(`Respond with only this JSON:
 {
   "rootCause": "Brief explanation of the root cause",
   "fixes": [
     {
       "description": "What to do",
       "command": "Command to run, if applicable",
+      "file": "devcontainer.json or Dockerfile, if a file must change",
+      "before": "Exact text of the file to replace",
+      "after": "Replacement text",
       "confidence": 0.9
     }
   ]
 }`)
 
 	// Call AI with timeout
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	response, err := gen.AnalyzeProject(ctx, ".")
+	completion, err := provider.Chat(ctx, []ai.ChatMessage{
+		{Role: "system", Content: "You debug dev container builds and lifecycle hooks. Propose minimal patches that use text present in the files verbatim."},
+		{Role: "user", Content: sb.String()},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse response (simplified - in production would properly parse JSON)
-	return &DebugAnalysis{
-		Summary:   "AI analysis complete",
-		RootCause: response,
-		Fixes:     []FixSuggestion{},
-	}, nil
+	content := completion.Content
+	if start, end := strings.Index(content, "{"), strings.LastIndex(content, "}"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	var response struct {
+		RootCause string `json:"rootCause"`
+		Fixes     []struct {
+			Description string  `json:"description"`
+			Command     string  `json:"command"`
+			File        string  `json:"file"`
+			Before      string  `json:"before"`
+			After       string  `json:"after"`
+			Confidence  float64 `json:"confidence"`
+		} `json:"fixes"`
+	}
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
+		return nil, fmt.Errorf("unusable AI response: %w", err)
+	}
+
+	analysis := &DebugAnalysis{
+		Summary:   fmt.Sprintf("Analyzed by %s", provider.Name()),
+		RootCause: response.RootCause,
+	}
+	for _, f := range response.Fixes {
+		fix := FixSuggestion{Description: f.Description, Command: f.Command, Confidence: f.Confidence}
+		path := target.configFile
+		if strings.EqualFold(f.File, "Dockerfile") {
+			path = target.dockerfile
+		}
+		// Only patches that apply cleanly are offered
+		if f.File != "" && f.Before != "" && path != "" {
+			change := &FileChange{File: path, Before: f.Before, After: f.After}
+			if checkFileChange(change) == nil {
+				fix.FileChange = change
+			}
+		}
+		analysis.Fixes = append(analysis.Fixes, fix)
+	}
+	return analysis, nil
 }
 
 // analyzeWithRules provides rule-based analysis without AI
@@ -459,6 +651,13 @@ func analyzeWithRules(errors []BuildError) *DebugAnalysis {
 				Command:     "docker build --check .",
 				Confidence:  0.8,
 			})
+		case "missing-tool":
+			analysis.RootCause = fmt.Sprintf("'%s' is not installed in the container", e.Command)
+			analysis.Fixes = append(analysis.Fixes, FixSuggestion{
+				Description: fmt.Sprintf("Install %s in the image or with a devcontainer feature", e.Command),
+				Command:     "cm feature list",
+				Confidence:  0.6,
+			})
 		case "command":
 			analysis.RootCause = "Command execution failed"
 			analysis.Fixes = append(analysis.Fixes, FixSuggestion{
@@ -472,8 +671,127 @@ func analyzeWithRules(errors []BuildError) *DebugAnalysis {
 	return analysis
 }
 
-// displayAnalysis shows the analysis results
-func displayAnalysis(analysis *DebugAnalysis) {
+// toolLanguages maps commands to the LanguageFeatures entry that installs them
+var toolLanguages = map[string]string{
+	"go": "Go", "gofmt": "Go",
+	"python": "Python", "python3": "Python", "pip": "Python", "pip3": "Python",
+	"node": "JavaScript", "npm": "JavaScript", "npx": "JavaScript", "yarn": "JavaScript", "pnpm": "JavaScript",
+	"cargo": "Rust", "rustc": "Rust", "rustup": "Rust",
+	"java": "Java", "javac": "Java", "mvn": "Java", "gradle": "Java",
+	"ruby": "Ruby", "gem": "Ruby", "bundle": "Ruby",
+	"php": "PHP", "composer": "PHP",
+}
+
+// toolFeatures are features for commands that are not a language toolchain
+var toolFeatures = map[string]string{
+	"docker":  "ghcr.io/devcontainers/features/docker-outside-of-docker:1",
+	"git":     "ghcr.io/devcontainers/features/git:1",
+	"gh":      "ghcr.io/devcontainers/features/github-cli:1",
+	"kubectl": "ghcr.io/devcontainers/features/kubectl-helm-minikube:1",
+	"helm":    "ghcr.io/devcontainers/features/kubectl-helm-minikube:1",
+	"az":      "ghcr.io/devcontainers/features/azure-cli:1",
+	"aws":     "ghcr.io/devcontainers/features/aws-cli:1",
+}
+
+// proposePatches returns concrete patches for failures with a known fix
+func proposePatches(errors []BuildError, target *debugTarget) []FixSuggestion {
+	var fixes []FixSuggestion
+	seen := make(map[string]bool)
+	// Hook failures are fixed in .devcontainer/devcontainer.json, which
+	// proposals are merged into
+	hook := target.failure != nil && target.failure.Kind == "hook" && target.config != nil &&
+		target.configFile == filepath.Join(target.projectDir, ".devcontainer", "devcontainer.json")
+
+	for _, e := range errors {
+		switch {
+		case e.Type == "missing-tool" && hook && !seen[e.Command]:
+			// Features are installed before lifecycle hooks run
+			seen[e.Command] = true
+			ref, options := toolFeatures[e.Command], map[string]interface{}{}
+			if lang, ok := toolLanguages[e.Command]; ok {
+				ref, options = detect.LanguageFeatures[lang].Feature, detect.LanguageFeatures[lang].Config
+			}
+			if ref == "" {
+				continue
+			}
+			if features, ok := target.config["features"].(map[string]interface{}); ok {
+				if _, ok := features[ref]; ok {
+					continue
+				}
+			}
+			p := &detect.Proposal{
+				Config:  map[string]interface{}{"features": map[string]interface{}{ref: options}},
+				Reasons: map[string]string{"features": fmt.Sprintf("installs %s, which %s could not find", e.Command, target.failure.Name)},
+			}
+			fixes = append(fixes, FixSuggestion{
+				Description: fmt.Sprintf("Add the feature that installs %s", e.Command),
+				Proposal:    p,
+				Confidence:  0.85,
+			})
+
+		case e.Type == "dependency" && strings.HasPrefix(e.Message, "Package not found") && target.dockerfile != "" && !seen["apt"]:
+			seen["apt"] = true
+			if change := aptUpdatePatch(target.dockerfile); change != nil {
+				fixes = append(fixes, FixSuggestion{
+					Description: "Refresh the package index in the same RUN as apt-get install",
+					FileChange:  change,
+					Confidence:  0.8,
+				})
+			}
+		}
+	}
+
+	// npm ci needs a lockfile; npm install creates one
+	if hook && strings.Contains(target.log, "npm ci") && strings.Contains(target.log, "package-lock.json") {
+		if command, ok := target.config[target.failure.Name].(string); ok && strings.Contains(command, "npm ci") {
+			fixes = append(fixes, FixSuggestion{
+				Description: "Use npm install, which works without a package-lock.json",
+				Proposal: &detect.Proposal{
+					Config:  map[string]interface{}{target.failure.Name: strings.Replace(command, "npm ci", "npm install", 1)},
+					Reasons: map[string]string{target.failure.Name: "npm ci requires a package-lock.json"},
+				},
+				Confidence: 0.8,
+			})
+		}
+	}
+
+	return fixes
+}
+
+// aptUpdatePatch adds apt-get update to the first RUN instruction that
+// installs packages without refreshing the package index
+func aptUpdatePatch(dockerfile string) *FileChange {
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(data), "\n")
+	for start := 0; start < len(lines); {
+		// Instructions span lines continued with a backslash
+		end := start
+		for end < len(lines)-1 && strings.HasSuffix(strings.TrimSpace(lines[end]), "\\") {
+			end++
+		}
+		instruction := strings.Join(lines[start:end+1], "\n")
+		if strings.Contains(instruction, "apt-get install") && !strings.Contains(instruction, "apt-get update") {
+			for i := start; i <= end; i++ {
+				if strings.Contains(lines[i], "apt-get install") {
+					return &FileChange{
+						File:    dockerfile,
+						Before:  lines[i],
+						After:   strings.Replace(lines[i], "apt-get install", "apt-get update && apt-get install", 1),
+						LineNum: i + 1,
+					}
+				}
+			}
+		}
+		start = end + 1
+	}
+	return nil
+}
+
+// displayAnalysis shows the analysis results, with a diff of each patch
+func displayAnalysis(analysis *DebugAnalysis, target *debugTarget) {
 	fmt.Println("🔍 Analysis Results:")
 	fmt.Println("─────────────────────")
 
@@ -497,14 +815,129 @@ func displayAnalysis(analysis *DebugAnalysis) {
 			if fix.Command != "" {
 				fmt.Printf("     $ %s\n", fix.Command)
 			}
+			if fix.FileChange != nil {
+				c := fix.FileChange
+				path := c.File
+				if rel, err := filepath.Rel(target.projectDir, c.File); err == nil {
+					path = rel
+				}
+				fmt.Printf("     📄 %s\n", path)
+				for _, line := range strings.Split(c.Before, "\n") {
+					fmt.Printf("     - %s\n", line)
+				}
+				for _, line := range strings.Split(c.After, "\n") {
+					fmt.Printf("     + %s\n", line)
+				}
+			}
+			if fix.Proposal != nil {
+				fmt.Println("     📄 .devcontainer/devcontainer.json")
+				for _, line := range strings.Split(strings.TrimRight(fix.Proposal.Format(target.config), "\n"), "\n") {
+					fmt.Printf("   %s\n", line)
+				}
+			}
+		}
+	}
+}
+
+// offerPatches applies the patch chosen with a single keypress, or the first
+// one with --auto-fix
+func offerPatches(fixes []FixSuggestion, target *debugTarget) error {
+	var patches []int
+	for i, fix := range fixes {
+		if fix.patch() {
+			patches = append(patches, i)
+		}
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+	fmt.Println()
+
+	choice := patches[0]
+	if !aiDebugAutoFix {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("💡 Run 'cm ai debug --auto-fix' to apply the first patch")
+			return nil
+		}
+		var keys []string
+		for _, i := range patches {
+			keys = append(keys, strconv.Itoa(i+1))
+		}
+		fmt.Printf("Apply fix [%s], or any other key to skip: ", strings.Join(keys, "/"))
+		key, err := readKey()
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(string(key))
+		if err != nil || n < 1 || n > len(fixes) || !fixes[n-1].patch() {
+			fmt.Println("No changes made")
+			return nil
+		}
+		choice = n - 1
+	}
+
+	fix := fixes[choice]
+	fmt.Printf("🔧 Applying: %s\n", fix.Description)
+	if err := applyFix(fix, target); err != nil {
+		return fmt.Errorf("failed to apply fix: %w", err)
+	}
+	if target.failure != nil && target.failure.Kind == "hook" {
+		fmt.Println("✅ Fix applied! Recreate the container with 'cm shell --rebuild'")
+	} else {
+		fmt.Println("✅ Fix applied! Try rebuilding with 'cm prepare'")
+	}
+	return nil
+}
+
+// readKey reads a single keypress from the terminal
+func readKey() (byte, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	var b [1]byte
+	if _, err := os.Stdin.Read(b[:]); err != nil {
+		return 0, err
+	}
+	if b[0] == 3 { // Ctrl-C
+		return 0, fmt.Errorf("interrupted")
+	}
+	return b[0], nil
+}
+
+// checkFileChange verifies a patch applies: the text to replace is in the
+// file, and a patched devcontainer.json still parses
+func checkFileChange(change *FileChange) error {
+	data, err := os.ReadFile(change.File)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), change.Before) {
+		return fmt.Errorf("%s no longer contains the text to replace", change.File)
+	}
+	if strings.HasSuffix(change.File, ".json") {
+		patched := strings.Replace(string(data), change.Before, change.After, 1)
+		if _, err := hujson.Standardize([]byte(patched)); err != nil {
+			return fmt.Errorf("patched %s would not be valid: %w", change.File, err)
 		}
 	}
+	return nil
 }
 
-// applyFix attempts to apply a fix
-func applyFix(fix FixSuggestion) error {
+// applyFix applies a fix's patch
+func applyFix(fix FixSuggestion, target *debugTarget) error {
+	if fix.Proposal != nil {
+		return fix.Proposal.Write(target.projectDir, target.config)
+	}
+
 	if fix.FileChange != nil {
-		// Apply file change
+		if err := checkFileChange(fix.FileChange); err != nil {
+			return err
+		}
 		data, err := os.ReadFile(fix.FileChange.File)
 		if err != nil {
 			return err
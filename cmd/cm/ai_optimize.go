@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/UPwith-me/Container-Maker/pkg/ai"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +23,12 @@ This command will:
   - Identify performance improvements
   - Suggest security enhancements
   - Recommend productivity boosts
+  - Inspect the Dockerfile, features and the built image's layers for ways
+    to shrink the image and speed up builds: apt caches, duplicate
+    toolchains, cache mounts, slimmer base images and multi-stage builds
+
+Suggestions are ranked by impact and estimated savings. With AI enabled,
+the configured provider is asked for further ideas.
 
 EXAMPLES:
   # Optimize current project config
@@ -29,7 +37,7 @@ EXAMPLES:
   # Optimize specific config file
   cm ai optimize .devcontainer/devcontainer.json
 
-  # Apply suggestions interactively
+  # Apply the suggestions that come with a patch
   cm ai optimize --apply`,
 	RunE: runAIOptimize,
 }
@@ -37,11 +45,13 @@ EXAMPLES:
 var (
 	optimizeApply   bool
 	optimizeVerbose bool
+	optimizeNoAI    bool
 )
 
 func init() {
 	aiOptimizeCmd.Flags().BoolVar(&optimizeApply, "apply", false, "Apply selected optimizations")
 	aiOptimizeCmd.Flags().BoolVarP(&optimizeVerbose, "verbose", "v", false, "Show verbose analysis")
+	aiOptimizeCmd.Flags().BoolVar(&optimizeNoAI, "no-ai", false, "Use the built-in analyzers only")
 	aiCmd.AddCommand(aiOptimizeCmd)
 }
 
@@ -106,6 +116,8 @@ func runAIOptimize(cmd *cobra.Command, args []string) error {
 	fmt.Println("📊 Analyzing for optimizations...")
 	optimizer := ai.NewOptimizer()
 	suggestions := optimizer.Analyze(string(data))
+	suggestions = append(suggestions, imageOptimizations(cmd.Context(), configPath, config)...)
+	ai.RankSuggestions(suggestions)
 
 	if len(suggestions) == 0 {
 		fmt.Println("✅ No optimizations suggested. Your config looks great!")
@@ -130,6 +142,58 @@ func runAIOptimize(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// imageOptimizations inspects the Dockerfile, features and history of the
+// image last built for the project
+func imageOptimizations(ctx context.Context, configPath string, config map[string]interface{}) []ai.OptimizationSuggestion {
+	configDir := filepath.Dir(configPath)
+	in := ai.ImageInput{Config: config}
+	if build, ok := config["build"].(map[string]interface{}); ok {
+		dockerfile, _ := build["dockerfile"].(string)
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		in.Dockerfile = filepath.Join(configDir, dockerfile)
+	}
+
+	stateDir := configDir
+	if filepath.Base(configDir) != ".devcontainer" {
+		stateDir = filepath.Join(configDir, ".devcontainer")
+	}
+	backend, image := "docker", ""
+	state, err := (&runner.PersistentRunner{StateFile: filepath.Join(stateDir, ".cm-state.json")}).LoadState()
+	if err == nil && state.ImageTag != "" {
+		image = state.ImageTag
+		if state.Backend != "" {
+			backend = state.Backend
+		}
+	} else if img, ok := config["image"].(string); ok {
+		image = img
+	}
+	if image != "" {
+		if layers, err := ai.ImageHistory(ctx, backend, image); err == nil {
+			in.Layers = layers
+		} else if optimizeVerbose {
+			fmt.Printf("⚠️  Image history unavailable: %v\n", err)
+		}
+	}
+
+	suggestions := ai.AnalyzeImage(in)
+	if optimizeNoAI {
+		return suggestions
+	}
+	provider, err := ai.NewProvider()
+	if err != nil {
+		return suggestions
+	}
+	fmt.Printf("🤖 Asking %s for more ideas...\n", provider.Name())
+	more, err := ai.SuggestImageOptimizations(ctx, provider, in, suggestions)
+	if err != nil {
+		fmt.Printf("⚠️  AI suggestions unavailable: %v\n", err)
+		return suggestions
+	}
+	return append(suggestions, more...)
+}
+
 func showDetailedAnalysis(config map[string]interface{}) {
 	fmt.Println("📋 Detailed Analysis:")
 	fmt.Println("─────────────────────")
@@ -185,8 +249,18 @@ func applyOptimizations(configPath string, config map[string]interface{}, sugges
 	fmt.Println("🔧 Applying optimizations...")
 
 	modified := false
+	patched := false
 
 	for _, s := range suggestions {
+		if s.Patch != nil {
+			fmt.Printf("  Patching %s: %s... ", s.Patch.File, s.Title)
+			if err := s.Patch.Apply(); err != nil {
+				fmt.Printf("✗ %v\n", err)
+				continue
+			}
+			fmt.Println("✓")
+			patched = true
+		}
 		if s.Apply == nil {
 			continue
 		}
@@ -197,6 +271,11 @@ func applyOptimizations(configPath string, config map[string]interface{}, sugges
 		modified = true
 	}
 
+	if !modified && patched {
+		fmt.Println()
+		fmt.Println("✅ Optimizations applied! Rebuild with 'cm prepare'")
+		return nil
+	}
 	if !modified {
 		fmt.Println("  No automatic fixes available for these suggestions.")
 		fmt.Println("  Please apply them manually based on the recommendations above.")
@@ -276,10 +355,10 @@ var aiLocalGenerateCmd = &cobra.Command{
 
 		// Generate config
 		cwd, _ := os.Getwd()
-		sg := &ai.SmartGenerator{}
+		gen := ai.NewGeneratorWithProvider(ollama)
 
 		fmt.Print("🔄 Generating configuration... ")
-		config, err := sg.GenerateConfig(cmd.Context(), cwd)
+		config, err := gen.AnalyzeProject(cmd.Context(), cwd)
 		if err != nil {
 			return err
 		}
@@ -4,33 +4,42 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
 	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
 	"github.com/spf13/cobra"
 )
 
 var analyticsCmd = &cobra.Command{
 	Use:   "analytics",
-	Short: "Manage anonymous usage statistics",
-	Long: `Manage anonymous usage statistics collection.
+	Short: "Record and report dev environment performance",
+	Long: `Record how long dev environments take to come up, and report the trends.
 
-Container-Maker can collect anonymous usage data to help improve the product.
-This is DISABLED by default and requires explicit opt-in.
+Recording is DISABLED by default and requires explicit opt-in. Once enabled,
+cm records on this machine only:
+- Cold start time of persistent containers (create until ready)
+- Image pull time
+- Build time and build cache hit rate
+- Lifecycle hook durations
 
-No personal information is collected. Only:
-- Command usage counts
-- Template popularity
-- Error frequencies
+Samples are kept in ~/.cm/metrics.jsonl. Nothing leaves the machine unless
+you configure an export endpoint and run 'cm analytics export'.
 
 Examples:
-  cm analytics enable   # Opt-in to analytics
+  cm analytics enable   # Opt-in to recording
+  cm analytics report   # Show trends of the last 30 days
   cm analytics disable  # Opt-out
   cm analytics status   # Check current status`,
 }
 
 var analyticsEnableCmd = &cobra.Command{
 	Use:   "enable",
-	Short: "Enable anonymous usage statistics",
+	Short: "Enable recording performance metrics",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := userconfig.Load()
 		if err != nil {
@@ -48,25 +57,23 @@ var analyticsEnableCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Println("✅ Anonymous usage statistics enabled")
-		fmt.Println("   Thank you for helping improve Container-Maker!")
+		fmt.Println("✅ Performance metrics enabled")
 		fmt.Println()
-		fmt.Println("📊 What we collect:")
-		fmt.Println("   - Command usage counts")
-		fmt.Println("   - Template popularity")
-		fmt.Println("   - Error frequencies")
+		fmt.Println("📊 What is recorded:")
+		fmt.Println("   - Cold start, image pull, build and lifecycle hook durations")
+		fmt.Println("   - Build cache hit rates")
 		fmt.Println()
-		fmt.Println("🔒 What we DON'T collect:")
-		fmt.Println("   - Personal information")
-		fmt.Println("   - Project names or contents")
-		fmt.Println("   - IP addresses")
+		fmt.Println("🔒 Samples stay in ~/.cm/metrics.jsonl unless you configure")
+		fmt.Println("   analytics.endpoint and run 'cm analytics export'.")
+		fmt.Println()
+		fmt.Println("   See the trends with: cm analytics report")
 		return nil
 	},
 }
 
 var analyticsDisableCmd = &cobra.Command{
 	Use:   "disable",
-	Short: "Disable anonymous usage statistics",
+	Short: "Disable recording performance metrics",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := userconfig.Load()
 		if err != nil {
@@ -79,7 +86,8 @@ var analyticsDisableCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Println("✅ Anonymous usage statistics disabled")
+		fmt.Println("✅ Performance metrics disabled")
+		fmt.Println("   Recorded samples are kept; delete them with 'cm analytics clear'")
 		return nil
 	},
 }
@@ -101,6 +109,13 @@ var analyticsStatusCmd = &cobra.Command{
 		} else {
 			fmt.Println("  Status: ❌ Disabled (opt-in required)")
 		}
+		samples, _ := metrics.Load()
+		fmt.Printf("  Samples: %d\n", len(samples))
+		if cfg.Analytics.Endpoint != "" {
+			fmt.Printf("  Export endpoint: %s\n", cfg.Analytics.Endpoint)
+		} else {
+			fmt.Println("  Export endpoint: (none, samples stay local)")
+		}
 		fmt.Println()
 		fmt.Println("  Enable with:  cm analytics enable")
 		fmt.Println("  Disable with: cm analytics disable")
@@ -109,6 +124,181 @@ var analyticsStatusCmd = &cobra.Command{
 	},
 }
 
+var (
+	analyticsDays     int
+	analyticsProject  string
+	analyticsFormat   string
+	analyticsEndpoint string
+)
+
+// metricLabels names the kinds of samples in reports
+var metricLabels = map[string]string{
+	metrics.KindColdStart: "Cold start",
+	metrics.KindPull:      "Image pull",
+	metrics.KindBuild:     "Build",
+	metrics.KindHook:      "Hook",
+}
+
+var analyticsReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show performance trends from the recorded metrics",
+	Long: `Show the median and 90th percentile of cold starts, image pulls, builds
+and lifecycle hooks, their build cache hit rate, and how they trend.
+
+TREND compares the median of the last 7 days with the days before; a
+slowdown of more than 10% is flagged. DAILY draws the median of each day.
+
+Examples:
+  cm analytics report
+  cm analytics report --days 7
+  cm analytics report --project .
+  cm analytics report --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(analyticsFormat); err != nil {
+			return err
+		}
+		if analyticsDays < 1 {
+			return fmt.Errorf("--days must be at least 1")
+		}
+
+		samples, err := loadMetrics()
+		if err != nil {
+			return err
+		}
+		summaries := metrics.Summarize(samples, analyticsDays, time.Now())
+
+		return output.Print(os.Stdout, analyticsFormat, summaries, func() error {
+			if len(summaries) == 0 {
+				if !metrics.Enabled() {
+					fmt.Println("No metrics recorded. Recording is opt-in: cm analytics enable")
+				} else {
+					fmt.Printf("No metrics recorded in the last %d days.\n", analyticsDays)
+				}
+				return nil
+			}
+
+			fmt.Printf("📊 Dev environment performance, last %d days\n\n", analyticsDays)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "METRIC\tRUNS\tFAILED\tMEDIAN\tP90\tTREND\tDAILY")
+			for _, sum := range summaries {
+				label := metricLabels[sum.Kind]
+				if sum.Name != "" {
+					label += " " + sum.Name
+				}
+				trend := "-"
+				if sum.Trend {
+					trend = fmt.Sprintf("%+.0f%%", sum.Change*100)
+					if sum.Change > 0.1 {
+						trend += " ⚠️"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", label, sum.Count, sum.Failed,
+					formatMetric(sum.Median), formatMetric(sum.P90), trend, metrics.Sparkline(sum.Daily))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			for _, sum := range summaries {
+				if rate, ok := sum.CacheHitRate(); ok {
+					fmt.Printf("\n🗃️  Build cache hit rate: %.0f%% (%d of %d steps)\n", rate*100, sum.Cached, sum.Steps)
+				}
+			}
+			return nil
+		})
+	},
+}
+
+var analyticsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Send the recorded metrics to the configured endpoint",
+	Long: `Send the recorded metrics as JSON to an endpoint, e.g. a team dashboard
+collecting dev environment performance. Project directories are reduced to
+their names.
+
+The endpoint comes from --endpoint or 'cm config set analytics.endpoint';
+without one the samples are printed instead, and nothing leaves the machine.
+
+Examples:
+  cm analytics export --endpoint https://metrics.example.com/cm
+  cm analytics export --days 7 > metrics.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		samples, err := loadMetrics()
+		if err != nil {
+			return err
+		}
+		since := time.Now().AddDate(0, 0, -analyticsDays)
+		var recent []metrics.Sample
+		for _, s := range samples {
+			if !s.At.Before(since) {
+				recent = append(recent, s)
+			}
+		}
+
+		cfg, err := userconfig.Load()
+		if err != nil {
+			return err
+		}
+		endpoint := analyticsEndpoint
+		if endpoint == "" {
+			endpoint = cfg.Analytics.Endpoint
+		}
+		if endpoint == "" {
+			return output.Print(os.Stdout, "json", recent, nil)
+		}
+
+		if err := metrics.Export(cmd.Context(), endpoint, cfg.Analytics.SessionID, recent); err != nil {
+			return err
+		}
+		fmt.Printf("📤 Exported %d samples to %s\n", len(recent), endpoint)
+		return nil
+	},
+}
+
+var analyticsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the recorded metrics",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := metrics.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("🧹 Recorded metrics deleted")
+		return nil
+	},
+}
+
+// loadMetrics loads the recorded samples, only those of --project when set;
+// pulls belong to no project and are always kept
+func loadMetrics() ([]metrics.Sample, error) {
+	samples, err := metrics.Load()
+	if err != nil || analyticsProject == "" {
+		return samples, err
+	}
+	project, err := filepath.Abs(analyticsProject)
+	if err != nil {
+		return nil, err
+	}
+	var kept []metrics.Sample
+	for _, s := range samples {
+		if s.Project == "" || s.Project == project {
+			kept = append(kept, s)
+		}
+	}
+	return kept, nil
+}
+
+// formatMetric rounds a duration for reports
+func formatMetric(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "-"
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
 func generateSessionID() string {
 	bytes := make([]byte, 8)
 	_, _ = rand.Read(bytes)
@@ -119,5 +309,15 @@ func init() {
 	analyticsCmd.AddCommand(analyticsEnableCmd)
 	analyticsCmd.AddCommand(analyticsDisableCmd)
 	analyticsCmd.AddCommand(analyticsStatusCmd)
+	analyticsCmd.AddCommand(analyticsReportCmd)
+	analyticsCmd.AddCommand(analyticsExportCmd)
+	analyticsCmd.AddCommand(analyticsClearCmd)
+
+	analyticsReportCmd.Flags().IntVar(&analyticsDays, "days", 30, "Report on this many days")
+	analyticsReportCmd.Flags().StringVar(&analyticsProject, "project", "", "Only report on this project directory")
+	analyticsReportCmd.Flags().StringVar(&analyticsFormat, "format", "", output.FlagUsage)
+	analyticsExportCmd.Flags().IntVar(&analyticsDays, "days", 30, "Export this many days")
+	analyticsExportCmd.Flags().StringVar(&analyticsProject, "project", "", "Only export this project directory")
+	analyticsExportCmd.Flags().StringVar(&analyticsEndpoint, "endpoint", "", "URL to POST the samples to (default: analytics.endpoint)")
 	rootCmd.AddCommand(analyticsCmd)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +25,7 @@ This command measures:
 - CLI startup time
 - Container startup time (if Docker available)
 - Config parsing time
+- 'cm exec' overhead over 'docker exec' (if a persistent container exists)
 
 Examples:
   cm benchmark
@@ -71,6 +74,21 @@ Examples:
 		}
 		fmt.Println()
 
+		// 5. cm exec overhead
+		fmt.Println("[5] cm exec Overhead")
+		cmExec, dockerExec, err := benchmarkExecOverhead(benchmarkIterations)
+		if err != nil {
+			fmt.Printf("    %s\n", err)
+		} else {
+			fmt.Printf("    cm exec:     %v (average over %d runs)\n", cmExec, benchmarkIterations)
+			fmt.Printf("    docker exec: %v\n", dockerExec)
+			fmt.Printf("    Overhead:    %v\n", cmExec-dockerExec)
+			if _, ok := runner.RunningAgent(); !ok {
+				fmt.Println("    Tip: 'cm agent start' keeps the fast path valid beyond its TTL")
+			}
+		}
+		fmt.Println()
+
 		fmt.Println("=== Benchmark Complete ===")
 		return nil
 	},
@@ -149,6 +167,51 @@ func benchmarkContainerStartup() (time.Duration, error) {
 	return time.Since(start), nil
 }
 
+// benchmarkExecOverhead times 'cm exec true' against 'docker exec <id> true'
+// in the current project's persistent container
+func benchmarkExecOverhead(iterations int) (time.Duration, time.Duration, error) {
+	data, err := os.ReadFile(filepath.Join(".devcontainer", ".cm-state.json"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("No persistent container (skipped; run 'cm shell' first)")
+	}
+	var state runner.ContainerState
+	if err := json.Unmarshal(data, &state); err != nil || state.ContainerID == "" {
+		return 0, 0, fmt.Errorf("No running persistent container (skipped)")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Warm up, which also writes the ready marker
+	if err := exec.Command(exe, "exec", "true").Run(); err != nil {
+		return 0, 0, fmt.Errorf("cm exec failed: %v", err)
+	}
+
+	timeRuns := func(name string, args ...string) (time.Duration, error) {
+		var total time.Duration
+		for i := 0; i < iterations; i++ {
+			start := time.Now()
+			if err := exec.Command(name, args...).Run(); err != nil {
+				return 0, fmt.Errorf("%s failed: %v", name, err)
+			}
+			total += time.Since(start)
+		}
+		return total / time.Duration(iterations), nil
+	}
+
+	cmTime, err := timeRuns(exe, "exec", "true")
+	if err != nil {
+		return 0, 0, err
+	}
+	dockerTime, err := timeRuns("docker", "exec", state.ContainerID, "true")
+	if err != nil {
+		return 0, 0, err
+	}
+	return cmTime, dockerTime, nil
+}
+
 // benchmarkVersion returns a simple version for testing
 // var benchmarkVersionCmd = &cobra.Command{
 // 	Use:    "version --short",
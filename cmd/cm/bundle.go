@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/bundle"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleConfig    string
+	bundleImages    []string
+	bundleFeatures  []string
+	bundleTemplates []string
+	bundleOutput    string
+	bundleBackend   string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Package images, features and templates for offline use",
+	Long: `Move everything a project needs onto a machine without registry access.
+
+'cm bundle create' runs where the network is available. It collects the
+image (or the Dockerfile's base images) and the features of devcontainer.json,
+including their dependencies, plus any extra images, features and templates,
+and writes them into one archive.
+
+'cm bundle load' runs on the offline machine. It loads the images into the
+container backend and the features and templates into cm's caches. Commands
+run with --offline (or CM_OFFLINE=1) then only use local assets and fail
+immediately, naming what is missing, instead of waiting on the network.
+
+EXAMPLES
+  cm bundle create -o project.tar.gz
+  cm bundle create --image postgres:16 --template ghcr.io/devcontainers/templates/go
+  cm bundle inspect project.tar.gz
+  cm bundle load project.tar.gz
+  cm shell --offline`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a bundle for the current project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := bundleConfig
+		if configPath == "" {
+			if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+				configPath = ".devcontainer/devcontainer.json"
+			} else if _, err := os.Stat("devcontainer.json"); err == nil {
+				configPath = "devcontainer.json"
+			}
+		}
+		if configPath == "" && len(bundleImages)+len(bundleFeatures)+len(bundleTemplates) == 0 {
+			return fmt.Errorf("no devcontainer.json found; use -c or name assets with --image, --feature or --template")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
+		defer cancel()
+
+		fmt.Printf("📦 Creating bundle %s\n", bundleOutput)
+		manifest, err := bundle.Create(ctx, bundle.CreateOptions{
+			ConfigFile: configPath,
+			Images:     bundleImages,
+			Features:   bundleFeatures,
+			Templates:  bundleTemplates,
+			Backend:    bundleBackend,
+			Output:     bundleOutput,
+			Log:        os.Stdout,
+		})
+		if err != nil {
+			return err
+		}
+
+		size := ""
+		if info, err := os.Stat(bundleOutput); err == nil {
+			size = fmt.Sprintf(" (%.1f MB)", float64(info.Size())/1024/1024)
+		}
+		fmt.Printf("\n✅ Bundle written to %s%s\n", bundleOutput, size)
+		printBundleManifest(manifest)
+		fmt.Printf("\n💡 On the offline machine: cm bundle load %s\n", bundleOutput)
+		return nil
+	},
+}
+
+var bundleLoadCmd = &cobra.Command{
+	Use:   "load <archive>",
+	Short: "Load a bundle into the local caches and container backend",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("📦 Loading bundle %s\n", args[0])
+		manifest, err := bundle.Load(context.Background(), args[0], bundleBackend, os.Stdout)
+		if err != nil {
+			return err
+		}
+		fmt.Println("✅ Bundle loaded")
+		printBundleManifest(manifest)
+		fmt.Println("\n💡 Run commands with --offline (or set CM_OFFLINE=1) to stay off the network")
+		return nil
+	},
+}
+
+var bundleInspectCmd = &cobra.Command{
+	Use:   "inspect <archive>",
+	Short: "List the contents of a bundle",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifest, err := bundle.Inspect(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📋 %s (created %s)\n", args[0], manifest.CreatedAt.Local().Format("2006-01-02 15:04"))
+		printBundleManifest(manifest)
+		return nil
+	},
+}
+
+func init() {
+	bundleCreateCmd.Flags().StringVarP(&bundleConfig, "config", "c", "", "Path to devcontainer.json")
+	bundleCreateCmd.Flags().StringArrayVar(&bundleImages, "image", nil, "Extra image to include (repeatable)")
+	bundleCreateCmd.Flags().StringArrayVar(&bundleFeatures, "feature", nil, "Extra feature to include (repeatable)")
+	bundleCreateCmd.Flags().StringArrayVar(&bundleTemplates, "template", nil, "OCI template to include (repeatable)")
+	bundleCreateCmd.Flags().StringVarP(&bundleOutput, "output", "o", "cm-bundle.tar.gz", "Archive to write")
+	bundleCmd.PersistentFlags().StringVar(&bundleBackend, "backend", "docker", "Container CLI to save and load images with (docker or podman)")
+
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleLoadCmd)
+	bundleCmd.AddCommand(bundleInspectCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
+
+func printBundleManifest(m *bundle.Manifest) {
+	fmt.Printf("\n   Images (%d)\n", len(m.Images))
+	for _, img := range m.Images {
+		fmt.Printf("     • %s\n", img)
+	}
+	fmt.Printf("   Features (%d)\n", len(m.Features))
+	for _, f := range m.Features {
+		fmt.Printf("     • %s\n", f.Ref)
+	}
+	fmt.Printf("   Templates (%d)\n", len(m.Templates))
+	for _, t := range m.Templates {
+		fmt.Printf("     • %s\n", t.Ref)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/ci"
+	"github.com/UPwith-me/Container-Maker/pkg/history"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+)
+
+const (
+	// ciExitSetup is the exit code of 'cm ci run' when the dev container
+	// couldn't be prepared, as docker run's when it fails
+	ciExitSetup = 125
+
+	// ciOutputTail is how much of the command's output goes in the report
+	ciOutputTail = 64 << 10
+)
+
+var (
+	ciCache     string
+	ciCacheFrom []string
+	ciCacheTo   string
+	ciJUnit     string
+
+	ciGenerateOutput   string
+	ciGenerateRun      []string
+	ciGenerateBranch   string
+	ciGeneratePrebuild bool
+	ciGenerateForce    bool
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Run CI jobs in the dev container",
+	Long: `Run CI jobs in the project's dev container, so CI uses the environment
+developers do.
+
+EXAMPLES
+  cm ci run -- make test
+  cm ci run --cache ghcr.io/acme/api-cache --junit cm-ci.xml -- make test
+  cm ci generate github`,
+}
+
+var ciRunCmd = &cobra.Command{
+	Use:   "run -- <command>",
+	Short: "Run a command in a fresh dev container, for CI",
+	Long: `Build or pull the dev container image, run the command in a new
+container without a terminal, and remove the container afterwards.
+
+--cache imports the image build's layer cache from a backend and exports it
+back: a registry repository (ghcr.io/acme/api-cache), or an S3 location
+(s3://bucket/name, with ?region= or AWS_REGION, and ?endpoint= for
+S3-compatible storage) whose credentials come from the AWS_* variables.
+--cache-from and --cache-to take a backend or a BuildKit spec (type=...)
+to import and export separately; they default to CM_CACHE_FROM and
+CM_CACHE_TO. Exporting with Docker's default builder creates a buildx
+builder, cm-cache, as that builder can't. The cache applies to images built
+from a Dockerfile, not to Docker Compose configurations.
+
+A summary of the two steps, preparing the dev container and running the
+command, is printed at the end, and --junit writes it as a JUnit XML report
+with the tail of the command's output.
+
+EXIT CODES
+  0    The command succeeded
+  125  The dev container couldn't be prepared: no or invalid configuration,
+       or the image build, pull or container start failed
+  *    The command's exit status otherwise
+
+EXAMPLES
+  cm ci run -- make test
+  cm ci run --cache ghcr.io/acme/api-cache -- npm test
+  cm ci run --cache s3://acme-ci/api?region=us-east-1 --junit cm-ci.xml -- go test ./...
+  cm ci run --cache-from ghcr.io/acme/api-cache --cache-to type=inline -- make lint`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		os.Exit(runCIJob(args))
+		return nil
+	},
+}
+
+var ciGenerateCmd = &cobra.Command{
+	Use:       "generate github|gitlab",
+	Short:     "Generate a CI configuration that runs checks in the dev container",
+	ValidArgs: []string{ci.GitHub, ci.GitLab},
+	Long: `Generate a GitHub Actions workflow or GitLab CI configuration, run from
+the repository root, that runs the project's checks with 'cm ci run'.
+
+The checks are the lint, vet, check, test and build targets of the
+Makefile, in that order, or the commands given with --run. When the image
+is built from a Dockerfile, its layer cache is kept in the platform's
+registry: pull requests import it and pushes to the default branch export
+it. With --prebuild, pushes to the default branch also push the prebuilt
+image ('cm prebuild --push') to <registry>/<repository>/devcontainer.
+
+The configuration is written to .github/workflows/devcontainer.yml or
+.gitlab-ci.yml unless -o says otherwise ("-" for stdout).
+
+EXAMPLES
+  cm ci generate github
+  cm ci generate gitlab --prebuild
+  cm ci generate github --run "make test" --run "npm run e2e" -o -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		platform := args[0]
+		if platform != ci.GitHub && platform != ci.GitLab {
+			return fmt.Errorf("unknown CI platform %q: want github or gitlab", platform)
+		}
+		dir, _ := os.Getwd()
+		p, err := ci.DetectProject(dir, configFile)
+		if err != nil {
+			return err
+		}
+		if len(ciGenerateRun) > 0 {
+			p.Commands = ciGenerateRun
+		}
+		if len(p.Commands) == 0 {
+			return fmt.Errorf("no Makefile with lint, vet, check, test or build targets found; pass the commands to run with --run")
+		}
+		p.Branch = ciGenerateBranch
+		if p.Branch == "" {
+			p.Branch = defaultGitBranch()
+		}
+		data, err := ci.Generate(platform, p, ciGeneratePrebuild)
+		if err != nil {
+			return err
+		}
+
+		if ciGenerateOutput == "-" {
+			_, err := os.Stdout.WriteString(data)
+			return err
+		}
+		output := ciGenerateOutput
+		if output == "" {
+			output = ci.DefaultPath(platform)
+		}
+		if _, err := os.Stat(output); err == nil && !ciGenerateForce {
+			return fmt.Errorf("%s already exists, use --force to overwrite", output)
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, []byte(data), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Wrote %s, running: %s\n", output, strings.Join(p.Commands, ", "))
+		return nil
+	},
+}
+
+// defaultGitBranch returns the repository's default branch: origin's, the
+// current one, or main
+func defaultGitBranch() string {
+	if out, err := exec.Command("git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output(); err == nil {
+		if branch := strings.TrimPrefix(strings.TrimSpace(string(out)), "origin/"); branch != "" {
+			return branch
+		}
+	}
+	if out, err := exec.Command("git", "branch", "--show-current").Output(); err == nil {
+		if branch := strings.TrimSpace(string(out)); branch != "" {
+			return branch
+		}
+	}
+	return "main"
+}
+
+// ciStep is a step of a CI job, as reported in its summary
+type ciStep struct {
+	Name     string
+	Duration time.Duration
+	Skipped  bool
+	Err      error  // The step couldn't run, or the command failed
+	ExitCode int    // The command's, for the run step
+	Output   string // The tail of the command's output
+}
+
+// runCIJob runs a command in a fresh dev container and returns the exit
+// code of cm ci run
+func runCIJob(command []string) int {
+	ctx := context.Background()
+	started := time.Now()
+	prepare := &ciStep{Name: "prepare"}
+	run := &ciStep{Name: strings.Join(command, " ")}
+	stdout, stderr, tail := ciCapture()
+
+	code := func() int {
+		fail := func(err error) int {
+			prepare.Err = err
+			run.Skipped = true
+			return ciExitSetup
+		}
+		cacheFrom, cacheTo, err := ciCacheSpecs()
+		if err != nil {
+			return fail(err)
+		}
+		if configFile == "" {
+			if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+				configFile = ".devcontainer/devcontainer.json"
+			} else if _, err := os.Stat("devcontainer.json"); err == nil {
+				configFile = "devcontainer.json"
+			} else {
+				return fail(fmt.Errorf("no devcontainer.json found"))
+			}
+		}
+		cfg, err := parseDevConfig(configFile)
+		if err != nil {
+			return fail(err)
+		}
+
+		if runner.IsComposeConfig(cfg) {
+			cr, err := runner.NewComposeRunner(cfg, filepath.Dir(configFile))
+			if err != nil {
+				return fail(err)
+			}
+			cr.NoTTY, cr.Stdout, cr.Stderr = true, stdout, stderr
+			t := time.Now()
+			err = cr.Up(ctx)
+			prepare.Duration = time.Since(t)
+			if err != nil {
+				return fail(fmt.Errorf("failed to start services: %w", err))
+			}
+			defer func() { _ = cr.Down(ctx) }()
+
+			t = time.Now()
+			err = cr.Exec(ctx, command)
+			run.Duration, run.Output = time.Since(t), tail.String()
+			if err != nil {
+				run.Err, run.ExitCode = err, history.ExitCode(err)
+			}
+			return run.ExitCode
+		}
+
+		r, err := runner.NewRunner(cfg)
+		if err != nil {
+			return fail(err)
+		}
+		r.CacheFrom, r.CacheTo = cacheFrom, cacheTo
+		r.NoTTY, r.Stdout, r.Stderr = true, stdout, stderr
+		t := time.Now()
+		r.Image, err = r.ResolveImage(ctx)
+		prepare.Duration = time.Since(t)
+		if err != nil {
+			return fail(err)
+		}
+
+		t = time.Now()
+		err = r.Run(ctx, command)
+		run.Duration, run.Output = time.Since(t), tail.String()
+		if err != nil {
+			// The container didn't start
+			run.Err = err
+			return ciExitSetup
+		}
+		if r.ExitCode != 0 {
+			run.Err, run.ExitCode = fmt.Errorf("exit status %d", r.ExitCode), r.ExitCode
+		}
+		return r.ExitCode
+	}()
+
+	steps := []*ciStep{prepare, run}
+	printCISummary(steps, code)
+	if ciJUnit != "" {
+		if err := writeCIJUnit(ciJUnit, "cm ci", steps, started); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write JUnit report: %v\n", err)
+		}
+	}
+	return code
+}
+
+// ciCacheSpecs returns the build cache imports and export of the flags
+func ciCacheSpecs() (from []string, to string, err error) {
+	if ciCache != "" {
+		f, t, err := runner.CacheSpecs(ciCache)
+		if err != nil {
+			return nil, "", err
+		}
+		from, to = append(from, f), t
+	}
+	for _, backend := range ciCacheFrom {
+		f, _, err := runner.CacheSpecs(backend)
+		if err != nil {
+			return nil, "", err
+		}
+		from = append(from, f)
+	}
+	if ciCacheTo != "" {
+		if _, to, err = runner.CacheSpecs(ciCacheTo); err != nil {
+			return nil, "", err
+		}
+	}
+	return from, to, nil
+}
+
+// ciCapture returns writers that pass the command's output through while
+// keeping its tail for the report
+func ciCapture() (stdout, stderr io.Writer, tail *ciTail) {
+	tail = &ciTail{}
+	return io.MultiWriter(os.Stdout, tail), io.MultiWriter(os.Stderr, tail), tail
+}
+
+// ciTail keeps the last ciOutputTail bytes written to it
+type ciTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *ciTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > ciOutputTail {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-ciOutputTail:]...)
+	}
+	return len(p), nil
+}
+
+func (t *ciTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// printCISummary prints the steps of a job and its exit code to stderr,
+// after the command's output
+func printCISummary(steps []*ciStep, code int) {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "── cm ci ──")
+	for _, s := range steps {
+		switch {
+		case s.Skipped:
+			fmt.Fprintf(os.Stderr, "⏭️  %s: skipped\n", s.Name)
+		case s.Err != nil:
+			fmt.Fprintf(os.Stderr, "❌ %s: %v (%s)\n", s.Name, s.Err, s.Duration.Round(100*time.Millisecond))
+		default:
+			fmt.Fprintf(os.Stderr, "✅ %s (%s)\n", s.Name, s.Duration.Round(100*time.Millisecond))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Exit code: %d\n", code)
+}
+
+// JUnit XML, as CI systems read it
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeCIJUnit writes a job's steps as a JUnit XML report of the named
+// suite: the command failing is a failure, the dev container not being
+// prepared an error
+func writeCIJUnit(path, name string, steps []*ciStep, started time.Time) error {
+	suite := junitTestSuite{
+		Name:      name,
+		Time:      fmt.Sprintf("%.3f", time.Since(started).Seconds()),
+		Timestamp: started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, s := range steps {
+		tc := junitTestCase{Name: s.Name, Classname: name, Time: fmt.Sprintf("%.3f", s.Duration.Seconds()), SystemOut: s.Output}
+		switch {
+		case s.Skipped:
+			tc.Skipped = &junitMessage{Message: "the dev container couldn't be prepared"}
+			if s.Err != nil {
+				tc.Skipped.Message = s.Err.Error()
+			}
+			suite.Skipped++
+		case s.ExitCode != 0:
+			tc.Failure = &junitMessage{Message: s.Err.Error(), Text: s.Output}
+			tc.SystemOut = ""
+			suite.Failures++
+		case s.Err != nil:
+			tc.Error = &junitMessage{Message: s.Err.Error()}
+			suite.Errors++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	report := junitTestSuites{
+		Name: suite.Name, Tests: suite.Tests, Failures: suite.Failures, Errors: suite.Errors,
+		Skipped: suite.Skipped, Time: suite.Time, Suites: []junitTestSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+func init() {
+	ciRunCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	ciRunCmd.Flags().StringVar(&ciCache, "cache", "", "Import and export the build cache: a registry repository or s3://bucket/name")
+	ciRunCmd.Flags().StringArrayVar(&ciCacheFrom, "cache-from", nil, "Import the build cache from a backend or BuildKit spec (repeatable)")
+	ciRunCmd.Flags().StringVar(&ciCacheTo, "cache-to", "", "Export the build cache to a backend or BuildKit spec")
+	ciRunCmd.Flags().StringVar(&ciJUnit, "junit", "", "Write a JUnit XML report to this file")
+	ciGenerateCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json, relative to the repository root")
+	ciGenerateCmd.Flags().StringVarP(&ciGenerateOutput, "output", "o", "", "Output file (\"-\" for stdout)")
+	ciGenerateCmd.Flags().StringArrayVar(&ciGenerateRun, "run", nil, "Command to run instead of the Makefile targets (repeatable)")
+	ciGenerateCmd.Flags().StringVar(&ciGenerateBranch, "branch", "", "Default branch (default: origin's)")
+	ciGenerateCmd.Flags().BoolVar(&ciGeneratePrebuild, "prebuild", false, "Push the prebuilt image on pushes to the default branch")
+	ciGenerateCmd.Flags().BoolVar(&ciGenerateForce, "force", false, "Overwrite an existing configuration")
+	ciCmd.AddCommand(ciRunCmd, ciGenerateCmd)
+	rootCmd.AddCommand(ciCmd)
+}
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/UPwith-me/Container-Maker/pkg/config"
 	"github.com/UPwith-me/Container-Maker/pkg/detect"
 	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/UPwith-me/Container-Maker/pkg/template"
@@ -36,6 +35,9 @@ var cloneNoShell bool
 
 func init() {
 	cloneCmd.Flags().StringVar(&cloneTemplate, "template", "", "Force use a specific template")
+	_ = cloneCmd.RegisterFlagCompletionFunc("template", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeTemplateNames(cmd, nil, toComplete)
+	})
 	cloneCmd.Flags().BoolVar(&cloneNoShell, "no-shell", false, "Don't enter shell after clone")
 	rootCmd.AddCommand(cloneCmd)
 }
@@ -104,7 +106,7 @@ func runClone(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("\n🐳 Starting dev container...")
 
-	cfg, err := config.ParseConfig(configPath)
+	cfg, err := parseDevConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to parse config: %w", err)
 	}
@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
 )
 
@@ -29,38 +34,52 @@ Container-Maker Cloud allows you to:
   • Share environments with your team
   • Pay-as-you-go billing
 
+Set CM_CLOUD_URL (or cm cloud login --url) to use a self-hosted control plane.
+
 Examples:
-  cm cloud login                    # Authenticate
-  cm cloud instances                # List running instances
-  cm cloud create --type gpu-t4     # Create GPU instance
-  cm cloud connect <id>             # SSH into instance
-  cm cloud delete <id>              # Terminate instance`,
+  cm cloud login                    # Authenticate in the browser
+  cm cloud list                     # List instances
+  cm cloud create --type gpu-t4     # Create GPU instance and wait for it
+  cm cloud create --stop-after 4h   # Create an instance that stops in 4 hours
+  cm cloud ssh <id>                 # SSH into instance
+  cm cloud dev                      # Run this project's dev container in the cloud
+  cm cloud policy --idle 30m        # Stop instances after 30 idle minutes
+  cm cloud budget --limit 200       # Alert as spend nears $200 a month
+  cm cloud logs <id> -f             # Follow instance logs
+  cm cloud exec <id> -- nvidia-smi  # Run a command through the instance's agent
+  cm cloud rm <id>                  # Terminate instance`,
 }
 
+var cloudLoginURL string
+
 var cloudLoginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with Container-Maker Cloud",
 	Long: `Login to Container-Maker Cloud using one of these methods:
-  • Interactive browser-based OAuth (default)
-  • API key (--api-key)
-  • Email/password (--email, --password)`,
+  • Browser approval with a one-time code (default)
+  • API key (--api-key), created under Settings → API Keys
+
+Credentials are stored in the OS keychain (macOS Keychain, Secret Service
+on Linux, Credential Locker on Windows), or in ~/.cm/config.json when no
+keychain is available.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		apiKey, _ := cmd.Flags().GetString("api-key")
+		baseURL := cloudBaseURL()
+		if cloudLoginURL != "" {
+			baseURL = strings.TrimSuffix(cloudLoginURL, "/")
+		}
 
+		apiKey, _ := cmd.Flags().GetString("api-key")
 		if apiKey != "" {
-			// API key auth
-			return cloudLoginWithAPIKey(apiKey)
+			return cloudLoginWithAPIKey(apiKey, baseURL)
 		}
-
-		// Interactive OAuth
-		return cloudLoginInteractive()
+		return cloudLoginDevice(baseURL)
 	},
 }
 
-func cloudLoginWithAPIKey(apiKey string) error {
+func cloudLoginWithAPIKey(apiKey, baseURL string) error {
 	// Validate API key
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", cloudAPIURL+"/api/v1/user", nil)
+	client := httpclient.New(httpclient.Options{Timeout: 10 * time.Second})
+	req, _ := http.NewRequest("GET", baseURL+"/api/v1/user", nil)
 	req.Header.Set("X-API-Key", apiKey)
 
 	resp, err := client.Do(req)
@@ -73,117 +92,133 @@ func cloudLoginWithAPIKey(apiKey string) error {
 		return fmt.Errorf("invalid API key")
 	}
 
-	// Save API key
-	cfg, _ := userconfig.Load()
-	if cfg == nil {
-		cfg = &userconfig.UserConfig{}
-	}
-	cfg.CloudAPIKey = apiKey
-	cfg.CloudAPIURL = cloudAPIURL
-
-	if err := userconfig.Save(cfg); err != nil {
+	where, err := saveCloudCredentials(&cloudCredentials{APIKey: apiKey}, baseURL)
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("✅ Logged in successfully!")
+	fmt.Printf("✅ Logged in successfully! (credentials stored in %s)\n", where)
 	return nil
 }
 
-func cloudLoginInteractive() error {
-	fmt.Println("🔐 Opening browser for authentication...")
-	fmt.Println()
+var cloudLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out from Container-Maker Cloud",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := clearCloudCredentials(); err != nil {
+			return fmt.Errorf("failed to remove credentials: %w", err)
+		}
+		fmt.Println("✅ Logged out successfully")
+		return nil
+	},
+}
 
-	// Generate state for OAuth
-	authURL := cloudAPIURL + "/auth/github?cli=true"
+// cloudInstance is an instance as returned by the control plane
+type cloudInstance struct {
+	ID           string     `json:"id" yaml:"id"`
+	Name         string     `json:"name" yaml:"name"`
+	Provider     string     `json:"provider" yaml:"provider"`
+	Region       string     `json:"region" yaml:"region"`
+	InstanceType string     `json:"instance_type" yaml:"instance_type"`
+	Status       string     `json:"status" yaml:"status"`
+	StatusReason string     `json:"status_reason,omitempty" yaml:"status_reason,omitempty"`
+	PublicIP     string     `json:"public_ip,omitempty" yaml:"public_ip,omitempty"`
+	HourlyRate   float64    `json:"hourly_rate" yaml:"hourly_rate"`
+	CreateAt     *time.Time `json:"create_at,omitempty" yaml:"create_at,omitempty"`
+	StopAt       *time.Time `json:"stop_at,omitempty" yaml:"stop_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" yaml:"created_at"`
+}
 
-	// Open browser based on OS
-	var browserCmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		browserCmd = exec.Command("cmd", "/c", "start", authURL)
-	case "darwin":
-		browserCmd = exec.Command("open", authURL)
-	default:
-		browserCmd = exec.Command("xdg-open", authURL)
+// getCloudInstance fetches an instance
+func getCloudInstance(client *http.Client, id string) (*cloudInstance, error) {
+	resp, err := client.Get(cloudBaseURL() + "/api/v1/instances/" + url.PathEscape(id))
+	if err != nil {
+		return nil, err
 	}
-
-	if err := browserCmd.Start(); err != nil {
-		fmt.Printf("Please open this URL in your browser:\n%s\n", authURL)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get instance %s: %s", id, cloudErrorMessage(resp))
 	}
+	var inst cloudInstance
+	if err := json.NewDecoder(resp.Body).Decode(&inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
 
-	fmt.Println("Waiting for authentication...")
-	fmt.Println("(Press Ctrl+C to cancel)")
-
-	// TODO: Implement device code flow or local callback server
-	fmt.Println()
-	fmt.Print("Enter API key or token: ")
-	var token string
-	_, _ = fmt.Scanln(&token)
-
-	if token == "" {
-		return fmt.Errorf("authentication cancelled")
+// listCloudInstances fetches the user's instances
+func listCloudInstances(client *http.Client) ([]cloudInstance, error) {
+	resp, err := client.Get(cloudBaseURL() + "/api/v1/instances")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list instances: %s", cloudErrorMessage(resp))
 	}
 
-	return cloudLoginWithAPIKey(token)
+	instances := []cloudInstance{}
+	if err := json.NewDecoder(resp.Body).Decode(&instances); err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	return instances, nil
 }
 
-var cloudLogoutCmd = &cobra.Command{
-	Use:   "logout",
-	Short: "Log out from Container-Maker Cloud",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, _ := userconfig.Load()
-		if cfg != nil {
-			cfg.CloudAPIKey = ""
-			cfg.CloudToken = ""
-			_ = userconfig.Save(cfg)
-		}
-		fmt.Println("✅ Logged out successfully")
-		return nil
-	},
+// cloudInstanceAction starts or stops an instance
+func cloudInstanceAction(client *http.Client, id, action string) error {
+	resp, err := client.Post(fmt.Sprintf("%s/api/v1/instances/%s/%s", cloudBaseURL(), url.PathEscape(id), action), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s instance %s: %s", action, id, cloudErrorMessage(resp))
+	}
+	return nil
 }
 
-var cloudInstancesCmd = &cobra.Command{
-	Use:   "instances",
-	Short: "List running cloud instances",
+var cloudListFormat string
+
+var cloudListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls", "instances"},
+	Short:   "List cloud instances",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudListFormat); err != nil {
+			return err
+		}
 		client, err := getCloudClient()
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.Get(cloudAPIURL + "/api/v1/instances")
+		instances, err := listCloudInstances(client)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
 
-		var instances []map[string]interface{}
-		_ = json.NewDecoder(resp.Body).Decode(&instances)
+		return output.Print(os.Stdout, cloudListFormat, instances, func() error {
+			if len(instances) == 0 {
+				fmt.Println("No instances.")
+				fmt.Println()
+				fmt.Println("Create one with: cm cloud create --type cpu-small")
+				return nil
+			}
 
-		if len(instances) == 0 {
-			fmt.Println("No running instances.")
+			fmt.Println("☁️  Cloud Instances")
 			fmt.Println()
-			fmt.Println("Create one with: cm cloud create --type cpu-small")
-			return nil
-		}
-
-		fmt.Println("☁️  Cloud Instances")
-		fmt.Println()
-		fmt.Printf("  %-12s %-15s %-10s %-8s %-15s %s\n", "ID", "Name", "Type", "Status", "Provider", "IP")
-		fmt.Printf("  %-12s %-15s %-10s %-8s %-15s %s\n", "────────────", "───────────────", "──────────", "────────", "───────────────", "───────────────")
-
-		for _, inst := range instances {
-			fmt.Printf("  %-12s %-15s %-10s %-8s %-15s %s\n",
-				inst["id"],
-				inst["name"],
-				inst["instance_type"],
-				inst["status"],
-				inst["provider"],
-				inst["public_ip"],
-			)
-		}
-
-		return nil
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tTYPE\tSTATUS\tPROVIDER\tREGION\tIP\tAGE")
+			for _, inst := range instances {
+				ip := inst.PublicIP
+				if ip == "" {
+					ip = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					inst.ID, inst.Name, inst.InstanceType, inst.Status, inst.Provider, inst.Region, ip, formatAge(inst.CreatedAt))
+			}
+			return w.Flush()
+		})
 	},
 }
 
@@ -191,11 +226,16 @@ var cloudCreateType string
 var cloudCreateProvider string
 var cloudCreateRegion string
 var cloudCreateName string
+var cloudCreateDetach bool
+var cloudCreateTimeout time.Duration
+var cloudCreateAt string
+var cloudCreateStopAt string
+var cloudCreateStopAfter time.Duration
 
 var cloudCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new cloud instance",
-	Long: `Create a new cloud development environment.
+	Long: `Create a new cloud development environment and wait until it is running.
 
 Instance Types:
   cpu-small   2 vCPU, 4GB RAM       ~$0.02/hr
@@ -205,9 +245,16 @@ Instance Types:
   gpu-a10     8 vCPU, 32GB + A10    ~$1.50/hr
   gpu-a100    8 vCPU, 80GB + A100   ~$3.00/hr
 
+Run 'cm cloud pricing <provider>' for live prices by region.
+
+--stop-after or --stop-at stops the instance at that time, so a forgotten
+one doesn't bill overnight; postpone it with 'cm cloud schedule'.
+--create-at schedules the instance to be created later instead of now.
+
 Providers:
   aws, gcp, azure, digitalocean, linode, vultr, hetzner,
-  oci, alibaba, tencent, lambdalabs, runpod, vast`,
+  oci, alibaba, tencent, lambdalabs, runpod, vast,
+  hosts (your own machines; see 'cm cloud host')`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		client, err := getCloudClient()
 		if err != nil {
@@ -220,49 +267,199 @@ Providers:
 			name = filepath.Base(cwd)
 		}
 
-		body := map[string]interface{}{
-			"name":          name,
-			"instance_type": cloudCreateType,
-			"provider":      cloudCreateProvider,
-			"region":        cloudCreateRegion,
+		schedule, err := cloudCreateSchedule(cmd)
+		if err != nil {
+			return err
 		}
 
-		// Check for devcontainer.json
-		if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
-			data, _ := os.ReadFile(".devcontainer/devcontainer.json")
-			body["devcontainer"] = string(data)
+		inst, err := createCloudInstance(client, name, cloudCreateType, cloudCreateProvider, cloudCreateRegion, schedule)
+		if err != nil {
+			return err
+		}
+		if inst.Status == "scheduled" {
+			fmt.Printf("🕒 Instance %s will be created at %s\n", inst.ID, formatScheduleTime(*inst.CreateAt))
+			if inst.StopAt != nil {
+				fmt.Printf("   and stopped at %s\n", formatScheduleTime(*inst.StopAt))
+			}
+			return nil
+		}
+		if !cloudCreateDetach {
+			if err := waitForCloudInstance(client, inst, cloudCreateTimeout); err != nil {
+				return err
+			}
+		}
+		if inst.StopAt != nil {
+			fmt.Printf("🕒 Stops at %s; postpone with: cm cloud schedule %s --postpone 1h\n", formatScheduleTime(*inst.StopAt), inst.ID)
 		}
 
-		jsonBody, _ := json.Marshal(body)
+		fmt.Println()
+		fmt.Printf("Connect with: cm cloud ssh %s\n", inst.ID)
+		return nil
+	},
+}
 
-		fmt.Printf("🚀 Creating %s instance on %s...\n", cloudCreateType, cloudCreateProvider)
+// cloudCreateSchedule returns the schedule the create flags ask for, or nil
+func cloudCreateSchedule(cmd *cobra.Command) (*cloudSchedule, error) {
+	flags := cmd.Flags()
+	if !flags.Changed("create-at") && !flags.Changed("stop-at") && !flags.Changed("stop-after") {
+		return nil, nil
+	}
+	if flags.Changed("stop-at") && flags.Changed("stop-after") {
+		return nil, fmt.Errorf("give either --stop-at or --stop-after")
+	}
+	schedule := &cloudSchedule{}
+	if cloudCreateAt != "" {
+		at, err := parseScheduleTime(cloudCreateAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --create-at: %w", err)
+		}
+		schedule.CreateAt = &at
+	}
+	if cloudCreateStopAt != "" {
+		at, err := parseScheduleTime(cloudCreateStopAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --stop-at: %w", err)
+		}
+		schedule.StopAt = &at
+	}
+	if flags.Changed("stop-after") {
+		if cloudCreateStopAfter <= 0 {
+			return nil, fmt.Errorf("--stop-after must be positive")
+		}
+		schedule.StopAfter = cloudCreateStopAfter.String()
+	}
+	return schedule, nil
+}
+
+// createCloudInstance asks the control plane for an instance, authorizing
+// the user's SSH public key on it
+func createCloudInstance(client *http.Client, name, instanceType, provider, region string, schedule *cloudSchedule) (*cloudInstance, error) {
+	body := map[string]interface{}{
+		"name":          name,
+		"instance_type": instanceType,
+		"provider":      provider,
+		"region":        region,
+	}
+	if schedule != nil {
+		if schedule.CreateAt != nil {
+			body["create_at"] = schedule.CreateAt
+		}
+		if schedule.StopAt != nil {
+			body["stop_at"] = schedule.StopAt
+		}
+		if schedule.StopAfter != "" {
+			body["stop_after"] = schedule.StopAfter
+		}
+	}
+	if keys, err := runner.HostPublicKeys(); err == nil {
+		body["ssh_public_key"] = keys[0]
+	} else {
+		fmt.Printf("⚠️  %v; SSH into the instance won't work\n", err)
+	}
+
+	// Check for devcontainer.json
+	if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+		data, _ := os.ReadFile(".devcontainer/devcontainer.json")
+		body["devcontainer"] = string(data)
+	}
+
+	jsonBody, _ := json.Marshal(body)
+
+	fmt.Printf("🚀 Creating %s instance on %s...\n", instanceType, provider)
+
+	resp, err := client.Post(cloudBaseURL()+"/api/v1/instances", "application/json", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create instance: %s", cloudErrorMessage(resp))
+	}
+
+	var inst cloudInstance
+	if err := json.NewDecoder(resp.Body).Decode(&inst); err != nil {
+		return nil, fmt.Errorf("failed to create instance: %w", err)
+	}
+	fmt.Printf("📦 Instance %s accepted (%s)\n", inst.ID, inst.Status)
+	return &inst, nil
+}
+
+// waitForCloudInstance polls an instance, printing each status it goes
+// through, until it is running or has failed
+func waitForCloudInstance(client *http.Client, inst *cloudInstance, timeout time.Duration) error {
+	start := time.Now()
+	status := inst.Status
+	for {
+		switch inst.Status {
+		case "running":
+			fmt.Printf("✅ Instance %s is running (%s)\n", inst.ID, time.Since(start).Round(time.Second))
+			if inst.PublicIP != "" {
+				fmt.Printf("   IP: %s\n", inst.PublicIP)
+			}
+			return nil
+		case "error", "terminated":
+			if inst.StatusReason != "" {
+				return fmt.Errorf("instance %s failed: %s", inst.ID, inst.StatusReason)
+			}
+			return fmt.Errorf("instance %s is %s", inst.ID, inst.Status)
+		}
+		if time.Since(start) > timeout {
+			return fmt.Errorf("instance %s still %s after %s; check with: cm cloud list", inst.ID, inst.Status, timeout)
+		}
 
-		resp, err := client.Post(cloudAPIURL+"/api/v1/instances", "application/json", bytes.NewReader(jsonBody))
+		time.Sleep(2 * time.Second)
+		latest, err := getCloudInstance(client, inst.ID)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to create instance: %s", string(body))
+		*inst = *latest
+		if inst.Status != status {
+			status = inst.Status
+			fmt.Printf("⏳ %s (%s)\n", status, time.Since(start).Round(time.Second))
 		}
+	}
+}
 
-		var result map[string]interface{}
-		_ = json.NewDecoder(resp.Body).Decode(&result)
+// cloudSSHEndpoint is where to SSH into an instance
+type cloudSSHEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	User string `json:"user"`
+}
 
-		fmt.Printf("✅ Instance created: %s\n", result["id"])
-		fmt.Println()
-		fmt.Printf("Connect with: cm cloud connect %s\n", result["id"])
+// getCloudSSHEndpoint fetches an instance's SSH endpoint
+func getCloudSSHEndpoint(client *http.Client, instanceID string) (*cloudSSHEndpoint, error) {
+	resp, err := client.Get(fmt.Sprintf("%s/api/v1/instances/%s/ssh", cloudBaseURL(), url.PathEscape(instanceID)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get SSH endpoint: %s", cloudErrorMessage(resp))
+	}
 
-		return nil
-	},
+	var endpoint cloudSSHEndpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, fmt.Errorf("failed to get SSH endpoint: %w", err)
+	}
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("instance %s has no public address yet", instanceID)
+	}
+	if endpoint.User == "" {
+		endpoint.User = "root"
+	}
+	if endpoint.Port == 0 {
+		endpoint.Port = 22
+	}
+	return &endpoint, nil
 }
 
-var cloudConnectCmd = &cobra.Command{
-	Use:   "connect <instance-id>",
-	Short: "SSH into a cloud instance",
-	Args:  cobra.ExactArgs(1),
+var cloudSSHCmd = &cobra.Command{
+	Use:     "ssh <instance-id> [-- ssh-args...]",
+	Aliases: []string{"connect"},
+	Short:   "SSH into a cloud instance",
+	Args:    cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		instanceID := args[0]
 
@@ -271,26 +468,15 @@ var cloudConnectCmd = &cobra.Command{
 			return err
 		}
 
-		// Get SSH config
-		resp, err := client.Get(fmt.Sprintf("%s/api/v1/instances/%s/ssh", cloudAPIURL, instanceID))
+		sshConfig, err := getCloudSSHEndpoint(client, instanceID)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		var sshConfig map[string]interface{}
-		_ = json.NewDecoder(resp.Body).Decode(&sshConfig)
-
-		host := sshConfig["host"].(string)
-		port := int(sshConfig["port"].(float64))
-		user := "root"
-		if u, ok := sshConfig["user"].(string); ok {
-			user = u
-		}
 
-		fmt.Printf("🔌 Connecting to %s@%s:%d...\n", user, host, port)
+		fmt.Printf("🔌 Connecting to %s@%s:%d...\n", sshConfig.User, sshConfig.Host, sshConfig.Port)
 
-		sshCmd := exec.Command("ssh", "-p", fmt.Sprintf("%d", port), fmt.Sprintf("%s@%s", user, host))
+		sshArgs := append([]string{"-p", fmt.Sprintf("%d", sshConfig.Port), fmt.Sprintf("%s@%s", sshConfig.User, sshConfig.Host)}, args[1:]...)
+		sshCmd := exec.Command("ssh", sshArgs...)
 		sshCmd.Stdin = os.Stdin
 		sshCmd.Stdout = os.Stdout
 		sshCmd.Stderr = os.Stderr
@@ -310,40 +496,135 @@ var cloudStopCmd = &cobra.Command{
 			return err
 		}
 
-		resp, err := client.Post(fmt.Sprintf("%s/api/v1/instances/%s/stop", cloudAPIURL, instanceID), "", nil)
-		if err != nil {
+		if err := cloudInstanceAction(client, instanceID, "stop"); err != nil {
 			return err
 		}
-		defer resp.Body.Close()
 
 		fmt.Printf("✅ Instance %s stopped\n", instanceID)
 		return nil
 	},
 }
 
-var cloudDeleteCmd = &cobra.Command{
-	Use:   "delete <instance-id>",
-	Short: "Delete a cloud instance",
+var cloudRmCmd = &cobra.Command{
+	Use:     "rm <instance-id>...",
+	Aliases: []string{"delete"},
+	Short:   "Delete cloud instances",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+
+		for _, instanceID := range args {
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/instances/%s", cloudBaseURL(), url.PathEscape(instanceID)), nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+				msg := cloudErrorMessage(resp)
+				resp.Body.Close()
+				return fmt.Errorf("failed to delete instance %s: %s", instanceID, msg)
+			}
+			resp.Body.Close()
+			fmt.Printf("✅ Instance %s deleted\n", instanceID)
+		}
+		return nil
+	},
+}
+
+var cloudLogsTail int
+var cloudLogsFollow bool
+
+var cloudLogsCmd = &cobra.Command{
+	Use:   "logs <instance-id>",
+	Short: "Show a cloud instance's logs",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		instanceID := args[0]
+		if cloudLogsFollow {
+			return followCloudLogs(instanceID)
+		}
+
 		client, err := getCloudClient()
 		if err != nil {
 			return err
 		}
-
-		req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/instances/%s", cloudAPIURL, instanceID), nil)
-		resp, err := client.Do(req)
+		resp, err := client.Get(fmt.Sprintf("%s/api/v1/instances/%s/logs?tail=%d", cloudBaseURL(), url.PathEscape(instanceID), cloudLogsTail))
 		if err != nil {
 			return err
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get logs: %s", cloudErrorMessage(resp))
+		}
 
-		fmt.Printf("✅ Instance %s deleted\n", instanceID)
+		var logs struct {
+			Logs string `json:"logs"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&logs); err != nil {
+			return fmt.Errorf("failed to get logs: %w", err)
+		}
+		fmt.Print(logs.Logs)
+		if logs.Logs != "" && !strings.HasSuffix(logs.Logs, "\n") {
+			fmt.Println()
+		}
 		return nil
 	},
 }
 
+// followCloudLogs streams an instance's logs over the control plane's
+// WebSocket until it closes or the user interrupts
+func followCloudLogs(instanceID string) error {
+	creds, err := loadCloudCredentials()
+	if err != nil {
+		return err
+	}
+	token := creds.APIKey
+	if token == "" {
+		// Renew the access token first; the WebSocket can't refresh it mid-stream
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		if _, err := getCloudInstance(client, instanceID); err != nil {
+			return err
+		}
+		if creds, err = loadCloudCredentials(); err != nil {
+			return err
+		}
+		token = creds.Token
+	}
+
+	wsURL := strings.Replace(cloudBaseURL(), "http", "ws", 1) +
+		"/api/v1/instances/" + url.PathEscape(instanceID) + "/logs/stream?token=" + url.QueryEscape(token)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to stream logs: %w", err)
+	}
+	defer conn.Close()
+
+	for {
+		var line struct {
+			Timestamp string `json:"timestamp"`
+			Level     string `json:"level"`
+			Message   string `json:"message"`
+		}
+		if err := conn.ReadJSON(&line); err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return fmt.Errorf("log stream closed: %w", err)
+		}
+		if line.Level == "error" {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", line.Message)
+			continue
+		}
+		fmt.Printf("%s %s\n", line.Timestamp, line.Message)
+	}
+}
+
 var cloudProvidersCmd = &cobra.Command{
 	Use:   "providers",
 	Short: "List available cloud providers",
@@ -353,7 +634,7 @@ var cloudProvidersCmd = &cobra.Command{
 			return err
 		}
 
-		resp, err := client.Get(cloudAPIURL + "/api/v1/providers")
+		resp, err := client.Get(cloudBaseURL() + "/api/v1/providers")
 		if err != nil {
 			return err
 		}
@@ -375,6 +656,159 @@ var cloudProvidersCmd = &cobra.Command{
 	},
 }
 
+var (
+	cloudPricingRegion  string
+	cloudPricingGPU     bool
+	cloudPricingAll     bool
+	cloudPricingRefresh bool
+	cloudPricingFormat  string
+)
+
+// cloudOffer is an instance type's price and availability in a region
+type cloudOffer struct {
+	Provider   string  `json:"provider"`
+	Region     string  `json:"region"`
+	Type       string  `json:"type"`
+	HourlyRate float64 `json:"hourly_rate"`
+	Currency   string  `json:"currency"`
+	Available  bool    `json:"available"`
+	GPUType    string  `json:"gpu_type,omitempty"`
+}
+
+var cloudPricingCmd = &cobra.Command{
+	Use:   "pricing [provider]",
+	Short: "Show instance prices and availability by region",
+	Long: `Show what each instance type costs in each region and where it is available.
+
+Prices and availability come from the provider's own APIs where supported
+and are cached for an hour; otherwise the built-in price table is shown.
+
+EXAMPLES
+  cm cloud pricing aws
+  cm cloud pricing aws --gpu
+  cm cloud pricing hetzner --region fsn1 --refresh
+  cm cloud pricing --gpu --format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudPricingFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+
+		names := args
+		if len(names) == 0 {
+			resp, err := client.Get(cloudBaseURL() + "/api/v1/providers")
+			if err != nil {
+				return err
+			}
+			var list []struct {
+				Name string `json:"name"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&list)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("failed to list providers: %w", err)
+			}
+			for _, p := range list {
+				names = append(names, p.Name)
+			}
+			sort.Strings(names)
+		}
+
+		var offers []cloudOffer
+		var notes []string
+		for _, name := range names {
+			reqURL := cloudBaseURL() + "/api/v1/providers/" + name + "/capabilities"
+			if cloudPricingRefresh {
+				reqURL += "?refresh=true"
+			}
+			resp, err := client.Get(reqURL)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return fmt.Errorf("failed to get pricing for %s: %s", name, resp.Status)
+			}
+			var caps struct {
+				Types []struct {
+					Type    string `json:"type"`
+					GPUType string `json:"gpu_type"`
+				} `json:"types"`
+				Offers    []cloudOffer `json:"offers"`
+				Live      bool         `json:"live"`
+				FetchedAt time.Time    `json:"fetched_at"`
+				Error     string       `json:"error"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&caps)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("failed to get pricing for %s: %w", name, err)
+			}
+
+			gpus := make(map[string]string)
+			for _, t := range caps.Types {
+				gpus[t.Type] = t.GPUType
+			}
+			for _, o := range caps.Offers {
+				o.Provider, o.GPUType = name, gpus[o.Type]
+				if cloudPricingRegion != "" && o.Region != cloudPricingRegion {
+					continue
+				}
+				if (cloudPricingGPU && o.GPUType == "") || (!cloudPricingAll && !o.Available) {
+					continue
+				}
+				offers = append(offers, o)
+			}
+
+			switch {
+			case caps.Live:
+				age := formatAge(caps.FetchedAt)
+				if age != "just now" {
+					age += " ago"
+				}
+				notes = append(notes, fmt.Sprintf("%s: live, fetched %s", name, age))
+			case caps.Error != "":
+				notes = append(notes, fmt.Sprintf("%s: built-in prices (%s)", name, caps.Error))
+			}
+		}
+
+		return output.Print(os.Stdout, cloudPricingFormat, offers, func() error {
+			if len(offers) == 0 {
+				fmt.Println("No matching offers.")
+				return nil
+			}
+			fmt.Println("💰 Cloud Pricing")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROVIDER\tREGION\tTYPE\tGPU\tPRICE/HR\tAVAILABLE")
+			for _, o := range offers {
+				gpu, available := o.GPUType, "✅"
+				if gpu == "" {
+					gpu = "-"
+				}
+				if !o.Available {
+					available = "❌"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.4f %s\t%s\n", o.Provider, o.Region, o.Type, gpu, o.HourlyRate, o.Currency, available)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(notes) > 0 {
+				fmt.Println()
+				for _, note := range notes {
+					fmt.Printf("  %s\n", note)
+				}
+			}
+			return nil
+		})
+	},
+}
+
 var cloudBillingCmd = &cobra.Command{
 	Use:   "billing",
 	Short: "View billing and usage",
@@ -384,76 +818,89 @@ var cloudBillingCmd = &cobra.Command{
 			return err
 		}
 
-		resp, err := client.Get(cloudAPIURL + "/api/v1/billing/usage")
+		resp, err := client.Get(cloudBaseURL() + "/api/v1/billing/usage")
 		if err != nil {
 			return err
 		}
 		defer resp.Body.Close()
 
-		var usage map[string]interface{}
-		_ = json.NewDecoder(resp.Body).Decode(&usage)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get usage: %s", cloudErrorMessage(resp))
+		}
 
-		currentMonth := usage["current_month"].(map[string]interface{})
+		var usage struct {
+			CurrentMonth struct {
+				CPUHours  float64 `json:"cpu_hours"`
+				GPUHours  float64 `json:"gpu_hours"`
+				TotalCost float64 `json:"total_cost"`
+				Instances int     `json:"instances"`
+				Forecast  float64 `json:"forecast"`
+			} `json:"current_month"`
+			Budget *struct {
+				MonthlyLimit float64 `json:"monthly_limit"`
+				HardStop     bool    `json:"hard_stop"`
+			} `json:"budget"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+			return fmt.Errorf("failed to get usage: %w", err)
+		}
+		currentMonth := usage.CurrentMonth
 
 		fmt.Println("💰 Billing & Usage")
 		fmt.Println()
 		fmt.Println("  Current Month:")
-		fmt.Printf("    CPU Hours:    %.1f\n", currentMonth["cpu_hours"])
-		fmt.Printf("    GPU Hours:    %.1f\n", currentMonth["gpu_hours"])
-		fmt.Printf("    Total Cost:   $%.2f\n", currentMonth["total_cost"])
-		fmt.Printf("    Instances:    %.0f\n", currentMonth["instances"])
+		fmt.Printf("    CPU Hours:    %.1f\n", currentMonth.CPUHours)
+		fmt.Printf("    GPU Hours:    %.1f\n", currentMonth.GPUHours)
+		fmt.Printf("    Total Cost:   $%.2f\n", currentMonth.TotalCost)
+		fmt.Printf("    Forecast:     $%.2f\n", currentMonth.Forecast)
+		fmt.Printf("    Running:      %d instances\n", currentMonth.Instances)
+		if budget := usage.Budget; budget != nil {
+			fmt.Printf("    Budget:       $%.2f (%.0f%% used)", budget.MonthlyLimit, currentMonth.TotalCost/budget.MonthlyLimit*100)
+			if budget.HardStop {
+				fmt.Print(", hard stop")
+			}
+			fmt.Println()
+		}
 
 		return nil
 	},
 }
 
-func getCloudClient() (*http.Client, error) {
-	cfg, err := userconfig.Load()
-	if err != nil || (cfg.CloudAPIKey == "" && cfg.CloudToken == "") {
-		return nil, fmt.Errorf("not logged in. Run: cm cloud login")
-	}
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &authTransport{
-			apiKey: cfg.CloudAPIKey,
-			token:  cfg.CloudToken,
-		},
-	}
-
-	return client, nil
-}
-
-type authTransport struct {
-	apiKey string
-	token  string
-}
-
-func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t.apiKey != "" {
-		req.Header.Set("X-API-Key", t.apiKey)
-	} else if t.token != "" {
-		req.Header.Set("Authorization", "Bearer "+t.token)
-	}
-	return http.DefaultTransport.RoundTrip(req)
-}
-
 func init() {
 	cloudLoginCmd.Flags().String("api-key", "", "API key for authentication")
+	cloudLoginCmd.Flags().StringVar(&cloudLoginURL, "url", "", "Control plane URL, for self-hosted deployments")
+
+	cloudListCmd.Flags().StringVar(&cloudListFormat, "format", "", output.FlagUsage)
 
 	cloudCreateCmd.Flags().StringVar(&cloudCreateType, "type", "cpu-small", "Instance type")
 	cloudCreateCmd.Flags().StringVar(&cloudCreateProvider, "provider", "aws", "Cloud provider")
 	cloudCreateCmd.Flags().StringVar(&cloudCreateRegion, "region", "", "Cloud region")
 	cloudCreateCmd.Flags().StringVar(&cloudCreateName, "name", "", "Instance name")
+	cloudCreateCmd.Flags().BoolVarP(&cloudCreateDetach, "detach", "d", false, "Return once the instance is accepted instead of waiting for it to run")
+	cloudCreateCmd.Flags().DurationVar(&cloudCreateTimeout, "timeout", 15*time.Minute, "How long to wait for the instance to run")
+	cloudCreateCmd.Flags().StringVar(&cloudCreateAt, "create-at", "", "Create the instance at this time instead of now, e.g. 08:30 or 2026-05-04T08:30:00Z")
+	cloudCreateCmd.Flags().StringVar(&cloudCreateStopAt, "stop-at", "", "Stop the instance at this time, e.g. 19:00")
+	cloudCreateCmd.Flags().DurationVar(&cloudCreateStopAfter, "stop-after", 0, "Stop the instance this long after it's created, e.g. 4h")
+
+	cloudLogsCmd.Flags().IntVarP(&cloudLogsTail, "tail", "n", 100, "Number of lines to show")
+	cloudLogsCmd.Flags().BoolVarP(&cloudLogsFollow, "follow", "f", false, "Stream new log lines")
+
+	cloudPricingCmd.Flags().StringVar(&cloudPricingRegion, "region", "", "Only show this region")
+	cloudPricingCmd.Flags().BoolVar(&cloudPricingGPU, "gpu", false, "Only show GPU instance types")
+	cloudPricingCmd.Flags().BoolVarP(&cloudPricingAll, "all", "a", false, "Include unavailable offers")
+	cloudPricingCmd.Flags().BoolVar(&cloudPricingRefresh, "refresh", false, "Bypass the server's pricing cache")
+	cloudPricingCmd.Flags().StringVar(&cloudPricingFormat, "format", "", output.FlagUsage)
 
 	cloudCmd.AddCommand(cloudLoginCmd)
 	cloudCmd.AddCommand(cloudLogoutCmd)
-	cloudCmd.AddCommand(cloudInstancesCmd)
+	cloudCmd.AddCommand(cloudListCmd)
 	cloudCmd.AddCommand(cloudCreateCmd)
-	cloudCmd.AddCommand(cloudConnectCmd)
+	cloudCmd.AddCommand(cloudSSHCmd)
 	cloudCmd.AddCommand(cloudStopCmd)
-	cloudCmd.AddCommand(cloudDeleteCmd)
+	cloudCmd.AddCommand(cloudRmCmd)
+	cloudCmd.AddCommand(cloudLogsCmd)
 	cloudCmd.AddCommand(cloudProvidersCmd)
 	cloudCmd.AddCommand(cloudBillingCmd)
+	cloudCmd.AddCommand(cloudPricingCmd)
 	rootCmd.AddCommand(cloudCmd)
 }
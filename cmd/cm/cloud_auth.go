@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/keychain"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// cloudKeychainService names cm's cloud credentials in the OS keychain
+const cloudKeychainService = "container-maker-cloud"
+
+// cloudCredentials authenticate against the control plane: an API key, or an
+// access token with the refresh token that renews it
+type cloudCredentials struct {
+	APIKey       string
+	Token        string
+	RefreshToken string
+}
+
+// accounts maps each keychain account to its credential
+func (c *cloudCredentials) accounts() map[string]*string {
+	return map[string]*string{
+		"api-key":       &c.APIKey,
+		"access-token":  &c.Token,
+		"refresh-token": &c.RefreshToken,
+	}
+}
+
+// cloudBaseURL returns the control plane's URL: CM_CLOUD_URL, the URL logged
+// in to, or the hosted service
+func cloudBaseURL() string {
+	if cfg, err := userconfig.Load(); err == nil && cfg.CloudAPIURL != "" {
+		return strings.TrimSuffix(cfg.CloudAPIURL, "/")
+	}
+	return cloudAPIURL
+}
+
+// loadCloudCredentials returns the stored credentials, preferring the config
+// file, where they only are when the keychain was unavailable at login
+func loadCloudCredentials() (*cloudCredentials, error) {
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return nil, err
+	}
+	creds := &cloudCredentials{APIKey: cfg.CloudAPIKey, Token: cfg.CloudToken, RefreshToken: cfg.CloudRefreshToken}
+	if creds.APIKey != "" || creds.Token != "" {
+		return creds, nil
+	}
+
+	for account, value := range creds.accounts() {
+		secret, err := keychain.Get(cloudKeychainService, account)
+		if err != nil && !errors.Is(err, keychain.ErrNotFound) && !errors.Is(err, keychain.ErrUnavailable) {
+			return nil, fmt.Errorf("failed to read %s from keychain: %w", account, err)
+		}
+		*value = secret
+	}
+	return creds, nil
+}
+
+// saveCloudCredentials stores credentials for the control plane at baseURL in
+// the OS keychain, or the config file if there is none, and returns where
+func saveCloudCredentials(creds *cloudCredentials, baseURL string) (string, error) {
+	cfg, err := userconfig.Load()
+	if err != nil || cfg == nil {
+		cfg = &userconfig.UserConfig{}
+	}
+	cfg.CloudAPIURL = baseURL
+
+	where := "OS keychain"
+	for account, value := range creds.accounts() {
+		if *value == "" {
+			err = keychain.Delete(cloudKeychainService, account)
+		} else {
+			err = keychain.Set(cloudKeychainService, account, *value)
+		}
+		if err != nil {
+			where = "~/.cm/config.json"
+			break
+		}
+	}
+
+	if where == "OS keychain" {
+		cfg.CloudAPIKey, cfg.CloudToken, cfg.CloudRefreshToken = "", "", ""
+	} else {
+		cfg.CloudAPIKey, cfg.CloudToken, cfg.CloudRefreshToken = creds.APIKey, creds.Token, creds.RefreshToken
+	}
+	return where, userconfig.Save(cfg)
+}
+
+// clearCloudCredentials removes the credentials from the keychain and config file
+func clearCloudCredentials() error {
+	for account := range (&cloudCredentials{}).accounts() {
+		if err := keychain.Delete(cloudKeychainService, account); err != nil && !errors.Is(err, keychain.ErrUnavailable) {
+			return err
+		}
+	}
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return err
+	}
+	cfg.CloudAPIKey, cfg.CloudToken, cfg.CloudRefreshToken = "", "", ""
+	return userconfig.Save(cfg)
+}
+
+// cloudTokenResponse is the control plane's response to a login or refresh
+type cloudTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	User         struct {
+		Email string `json:"email"`
+	} `json:"user"`
+}
+
+// cloudLoginDevice logs in with the OAuth device flow: the user approves a
+// code in the browser while the CLI polls for the resulting tokens
+func cloudLoginDevice(baseURL string) error {
+	client := httpclient.New(httpclient.Options{Timeout: 30 * time.Second})
+
+	resp, err := client.Post(baseURL+"/api/v1/auth/device/code", "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to cloud: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to start login: %s", cloudErrorMessage(resp))
+	}
+	var code struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&code); err != nil {
+		return fmt.Errorf("invalid response from cloud: %v", err)
+	}
+
+	fmt.Printf("🔐 Your one-time code: %s\n", code.UserCode)
+	fmt.Println()
+	if err := openBrowser(code.VerificationURIComplete); err != nil {
+		fmt.Printf("Open %s in your browser and enter the code.\n", code.VerificationURI)
+	} else {
+		fmt.Printf("Opened %s in your browser; confirm the code there.\n", code.VerificationURI)
+	}
+	fmt.Println("Waiting for approval... (Press Ctrl+C to cancel)")
+
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		body, _ := json.Marshal(map[string]string{"device_code": code.DeviceCode})
+		resp, err := client.Post(baseURL+"/api/v1/auth/device/token", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to connect to cloud: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			var tokens cloudTokenResponse
+			if err := json.Unmarshal(data, &tokens); err != nil {
+				return fmt.Errorf("invalid response from cloud: %v", err)
+			}
+			where, err := saveCloudCredentials(&cloudCredentials{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, baseURL)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✅ Logged in as %s (credentials stored in %s)\n", tokens.User.Email, where)
+			return nil
+		}
+
+		var pollErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(data, &pollErr)
+		switch pollErr.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return fmt.Errorf("login was denied in the browser")
+		case "expired_token":
+			return fmt.Errorf("login code expired, run cm cloud login again")
+		default:
+			return fmt.Errorf("login failed: %s", strings.TrimSpace(string(data)))
+		}
+	}
+	return fmt.Errorf("login code expired, run cm cloud login again")
+}
+
+// openBrowser opens a URL in the default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// cloudErrorMessage returns the message of a control plane error response
+func cloudErrorMessage(resp *http.Response) string {
+	data, _ := io.ReadAll(resp.Body)
+	var errResp struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &errResp) == nil && errResp.Message != "" {
+		return errResp.Message
+	}
+	if msg := strings.TrimSpace(string(data)); msg != "" {
+		return msg
+	}
+	return resp.Status
+}
+
+// getCloudClient returns an HTTP client authenticating with the stored
+// credentials, renewing an expired access token with the refresh token
+func getCloudClient() (*http.Client, error) {
+	creds, err := loadCloudCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if creds.APIKey == "" && creds.Token == "" {
+		return nil, fmt.Errorf("not logged in. Run: cm cloud login")
+	}
+
+	client := httpclient.New(httpclient.Options{Timeout: 30 * time.Second})
+	client.Transport = &authTransport{
+		base:    client.Transport,
+		creds:   creds,
+		baseURL: cloudBaseURL(),
+	}
+
+	return client, nil
+}
+
+type authTransport struct {
+	base    http.RoundTripper
+	creds   *cloudCredentials
+	baseURL string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(t.authorize(req))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || t.creds.APIKey != "" || t.creds.RefreshToken == "" {
+		return resp, err
+	}
+
+	// The access token expired: renew it and retry once
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	if err := t.refresh(); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(t.authorize(retry))
+}
+
+// authorize returns a copy of req carrying the credentials
+func (t *authTransport) authorize(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+	if t.creds.APIKey != "" {
+		req.Header.Set("X-API-Key", t.creds.APIKey)
+	} else if t.creds.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.creds.Token)
+	}
+	return req
+}
+
+// refresh exchanges the refresh token for new tokens and stores them
+func (t *authTransport) refresh() error {
+	body, _ := json.Marshal(map[string]string{"refresh_token": t.creds.RefreshToken})
+	req, err := http.NewRequest(http.MethodPost, t.baseURL+"/api/v1/auth/refresh", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("session expired, run cm cloud login")
+	}
+
+	var tokens cloudTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return err
+	}
+	t.creds.Token, t.creds.RefreshToken = tokens.AccessToken, tokens.RefreshToken
+	_, err = saveCloudCredentials(t.creds, t.baseURL)
+	return err
+}
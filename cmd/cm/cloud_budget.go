@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloudBudgetTeam       string
+	cloudBudgetLimit      float64
+	cloudBudgetHardStop   bool
+	cloudBudgetThresholds string
+	cloudBudgetEmail      string
+	cloudBudgetWebhook    string
+)
+
+// cloudBudget is a budget as returned by the control plane
+type cloudBudget struct {
+	MonthlyLimit    float64 `json:"monthly_limit"`
+	HardStop        bool    `json:"hard_stop"`
+	AlertThresholds string  `json:"alert_thresholds"`
+	AlertEmail      string  `json:"alert_email"`
+	WebhookURL      string  `json:"webhook_url"`
+	WebhookSecret   string  `json:"webhook_secret,omitempty"` // Only when the webhook is set
+	Spent           float64 `json:"spent,omitempty"`
+	Period          string  `json:"period,omitempty"`
+}
+
+var cloudBudgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Show or set the monthly spend limit and alerts",
+	Long: `Show or set the monthly budget of the instances you own, or with --team
+a team's instances.
+
+Spend is metered from instance runtime at the instance's hourly rate and
+counted per calendar month (UTC). You're alerted in the dashboard, by email
+and, with --webhook, by a JSON POST (Slack-compatible) as it crosses each
+--alert percentage. The POST is signed like webhook events, with a secret
+shown once when the webhook is set. With --hard-stop, running instances are
+stopped and new ones refused once the limit is reached. Flags not given keep
+their current values.
+
+EXAMPLES
+  cm cloud budget                                  # Show the budget and spend
+  cm cloud budget --limit 200                      # Alert at 50/80/100% of $200
+  cm cloud budget --limit 200 --hard-stop          # Stop instances at $200
+  cm cloud budget --alert 75,90,100 --webhook https://hooks.slack.com/services/...
+  cm cloud budget --team <team-id> --limit 1000    # Set a team's budget
+  cm cloud budget --limit 0                        # Turn it off`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/billing/budget"
+		if cloudBudgetTeam != "" {
+			endpoint = fmt.Sprintf("%s/api/v1/teams/%s/budget", cloudBaseURL(), url.PathEscape(cloudBudgetTeam))
+		}
+
+		budget, err := fetchCloudBudget(client, endpoint)
+		if err != nil {
+			return err
+		}
+
+		flags := cmd.Flags()
+		changed := false
+		if flags.Changed("limit") {
+			budget.MonthlyLimit, changed = cloudBudgetLimit, true
+		}
+		if flags.Changed("hard-stop") {
+			budget.HardStop, changed = cloudBudgetHardStop, true
+		}
+		if flags.Changed("alert") {
+			budget.AlertThresholds, changed = cloudBudgetThresholds, true
+		}
+		if flags.Changed("email") {
+			budget.AlertEmail, changed = cloudBudgetEmail, true
+		}
+		if flags.Changed("webhook") {
+			budget.WebhookURL, changed = cloudBudgetWebhook, true
+		}
+
+		if changed {
+			body, _ := json.Marshal(budget)
+			req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("failed to update budget: %s", cloudErrorMessage(resp))
+			}
+			if err := json.NewDecoder(resp.Body).Decode(budget); err != nil {
+				return err
+			}
+			fmt.Println("✅ Budget updated")
+			fmt.Println()
+			if budget.WebhookSecret != "" {
+				fmt.Printf("   Webhook signing secret: %s\n", budget.WebhookSecret)
+				fmt.Println("   Save it now; it isn't shown again. Verify X-CM-Signature with it.")
+				fmt.Println()
+			}
+		}
+
+		if cloudBudgetTeam != "" {
+			fmt.Printf("💰 Budget of team %s\n", cloudBudgetTeam)
+		} else {
+			fmt.Println("💰 Budget of your instances")
+		}
+		if budget.MonthlyLimit <= 0 {
+			fmt.Println("   Limit:     none")
+			fmt.Printf("   Spent:     $%.2f in %s\n", budget.Spent, budget.Period)
+			return nil
+		}
+		fmt.Printf("   Limit:     $%.2f per month\n", budget.MonthlyLimit)
+		fmt.Printf("   Spent:     $%.2f in %s (%.0f%%)\n", budget.Spent, budget.Period, budget.Spent/budget.MonthlyLimit*100)
+		fmt.Printf("   Alerts:    at %s%%\n", budget.AlertThresholds)
+		if budget.AlertEmail != "" {
+			fmt.Printf("   Email:     %s\n", budget.AlertEmail)
+		}
+		if budget.WebhookURL != "" {
+			fmt.Printf("   Webhook:   %s\n", budget.WebhookURL)
+		}
+		if budget.HardStop {
+			fmt.Println("   Hard stop: instances are stopped at the limit")
+		} else {
+			fmt.Println("   Hard stop: off")
+		}
+		return nil
+	},
+}
+
+// fetchCloudBudget fetches the user's or a team's budget
+func fetchCloudBudget(client *http.Client, endpoint string) (*cloudBudget, error) {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get budget: %s", cloudErrorMessage(resp))
+	}
+	var budget cloudBudget
+	if err := json.NewDecoder(resp.Body).Decode(&budget); err != nil {
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+	return &budget, nil
+}
+
+func init() {
+	cloudBudgetCmd.Flags().StringVar(&cloudBudgetTeam, "team", "", "Show or set the budget of this team's instances")
+	cloudBudgetCmd.Flags().Float64Var(&cloudBudgetLimit, "limit", 0, "Monthly limit in USD (0 turns the budget off)")
+	cloudBudgetCmd.Flags().BoolVar(&cloudBudgetHardStop, "hard-stop", false, "Stop running instances and refuse new ones at the limit")
+	cloudBudgetCmd.Flags().StringVar(&cloudBudgetThresholds, "alert", "", "Percentages of the limit to alert at, e.g. 50,80,100")
+	cloudBudgetCmd.Flags().StringVar(&cloudBudgetEmail, "email", "", "Email alerts to this address instead of the owner's")
+	cloudBudgetCmd.Flags().StringVar(&cloudBudgetWebhook, "webhook", "", "POST alerts as JSON to this URL (\"\" turns it off)")
+	cloudCmd.AddCommand(cloudBudgetCmd)
+}
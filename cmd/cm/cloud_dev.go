@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	cmsync "github.com/UPwith-me/Container-Maker/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloudDevType     string
+	cloudDevProvider string
+	cloudDevRegion   string
+	cloudDevSync     string
+	cloudDevWatch    bool
+	cloudDevDetach   bool
+	cloudDevTimeout  time.Duration
+)
+
+var cloudDevCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Run this project's dev container on a cloud instance",
+	Long: `Run the current project's dev container on a cloud instance, as if it
+were local.
+
+cm provisions an instance for the project (or reuses the one it created
+before), installs Docker and cm on it, copies the workspace over, starts
+the dev container with 'cm up' (cm-workspace.yaml) or the persistent
+container (devcontainer.json), then forwards the forwarded ports to
+localhost until you press Ctrl+C.
+
+Open a shell in the remote dev container with 'cm shell --cloud'.
+
+The workspace is copied with rsync, or with --sync git by pushing the
+current branch; git only carries committed changes.
+
+EXAMPLES
+  cm cloud dev                              # Provision, sync, start, forward ports
+  cm cloud dev --type gpu-t4 --provider aws # On a GPU instance
+  cm cloud dev --watch                      # Keep syncing changes while running
+  cm cloud dev --sync git -d                # Push commits and return at once`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudDevSync != "rsync" && cloudDevSync != "git" {
+			return fmt.Errorf("--sync must be rsync or git")
+		}
+		projectDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		ports, err := cloudDevPorts(projectDir)
+		if err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+
+		state, err := ensureCloudDevInstance(client, projectDir)
+		if err != nil {
+			return err
+		}
+		if err := saveCloudDevState(projectDir, state); err != nil {
+			return err
+		}
+
+		fmt.Printf("🔌 Waiting for SSH on %s...\n", state.destination())
+		if err := state.waitForSSH(5 * time.Minute); err != nil {
+			return err
+		}
+
+		fmt.Println("🐳 Installing Docker and cm...")
+		if err := state.run(cloudDevBootstrapScript(state.Dir)); err != nil {
+			return fmt.Errorf("failed to prepare instance: %w", err)
+		}
+
+		if err := state.syncWorkspace(projectDir, cloudDevSync); err != nil {
+			return err
+		}
+
+		fmt.Println("🚀 Starting the dev container...")
+		start := fmt.Sprintf("cd %s && if [ -f cm-workspace.yaml ]; then cm up; else cm exec true; fi", state.Dir)
+		if err := state.runInteractive(start); err != nil {
+			return fmt.Errorf("failed to start the dev container: %w", err)
+		}
+
+		fmt.Println()
+		fmt.Printf("✅ Dev environment running on %s\n", state.InstanceID)
+		fmt.Println("   Shell:  cm shell --cloud")
+		fmt.Printf("   Stop:   cm cloud stop %s\n", state.InstanceID)
+		if cloudDevDetach {
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if cloudDevWatch && cloudDevSync == "rsync" {
+			syncer, err := state.syncer(projectDir)
+			if err != nil {
+				return err
+			}
+			go func() {
+				// The initial sync already ran, so this mostly watches
+				if err := syncer.Start(ctx); err != nil {
+					fmt.Printf("⚠️  Sync stopped: %v\n", err)
+				}
+			}()
+			defer syncer.Stop()
+		}
+
+		return state.tunnel(ctx, ports)
+	},
+}
+
+// remoteWorkspace is a project's copy on an SSH host: the cloud instance
+// 'cm cloud dev' runs it on, remembered so later runs and 'cm shell --cloud'
+// reuse it, or the host of 'cm run --remote'
+type remoteWorkspace struct {
+	InstanceID string `json:"instance_id"` // Empty for hosts added with 'cm remote add'
+	Host       string `json:"host"`
+	Port       int    `json:"port"` // 0 for the SSH default
+	User       string `json:"user"` // Empty when Host is "user@host" or an ssh_config alias
+	Dir        string `json:"dir"`  // Workspace on the host, relative to the user's home
+}
+
+// cloudDevStatePath is where the instances of all projects are remembered
+func cloudDevStatePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "cloud-dev.json"), nil
+}
+
+// loadCloudDevStates returns the remembered instances by project directory
+func loadCloudDevStates() (map[string]*remoteWorkspace, error) {
+	path, err := cloudDevStatePath()
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]*remoteWorkspace{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return states, nil
+}
+
+// loadCloudDevState returns a project's remembered instance, or nil
+func loadCloudDevState(projectDir string) (*remoteWorkspace, error) {
+	states, err := loadCloudDevStates()
+	if err != nil {
+		return nil, err
+	}
+	return states[projectDir], nil
+}
+
+func saveCloudDevState(projectDir string, state *remoteWorkspace) error {
+	states, err := loadCloudDevStates()
+	if err != nil {
+		return err
+	}
+	states[projectDir] = state
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := cloudDevStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// cloudDevPorts returns the TCP ports to forward, from devcontainer.json;
+// the project needs one, or a cm-workspace.yaml, to run remotely
+func cloudDevPorts(projectDir string) ([]int, error) {
+	var cfg *config.DevContainerConfig
+	for _, path := range []string{".devcontainer/devcontainer.json", "devcontainer.json"} {
+		if _, err := os.Stat(filepath.Join(projectDir, path)); err == nil {
+			parsed, err := config.ParseConfig(filepath.Join(projectDir, path))
+			if err != nil {
+				return nil, err
+			}
+			cfg = parsed
+			break
+		}
+	}
+	if cfg == nil {
+		if _, err := os.Stat(filepath.Join(projectDir, "cm-workspace.yaml")); err == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no devcontainer.json or cm-workspace.yaml in %s; create one with: cm init", projectDir)
+	}
+
+	var ports []int
+	for _, spec := range cfg.PortSpecs() {
+		if strings.HasSuffix(spec, "/udp") {
+			continue
+		}
+		// The host side of "3000:80" is what the remote host publishes
+		hostPort := strings.SplitN(strings.TrimSuffix(spec, "/tcp"), ":", 2)[0]
+		if port, err := strconv.Atoi(hostPort); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// remoteNamePattern matches characters not allowed in instance and directory names
+var remoteNamePattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// remoteProjectName names a project's instance and remote workspace
+func remoteProjectName(projectDir string) string {
+	name := strings.Trim(remoteNamePattern.ReplaceAllString(strings.ToLower(filepath.Base(projectDir)), "-"), "-")
+	if name == "" {
+		return "project"
+	}
+	return name
+}
+
+// ensureCloudDevInstance returns a running instance for the project: the
+// remembered one, started if stopped, or a new one
+func ensureCloudDevInstance(client *http.Client, projectDir string) (*remoteWorkspace, error) {
+	name := remoteProjectName(projectDir)
+
+	var inst *cloudInstance
+	if state, err := loadCloudDevState(projectDir); err != nil {
+		return nil, err
+	} else if state != nil {
+		if existing, err := getCloudInstance(client, state.InstanceID); err == nil {
+			switch existing.Status {
+			case "running", "pending", "provisioning", "starting":
+				fmt.Printf("♻️  Reusing instance %s (%s)\n", existing.ID, existing.Status)
+				inst = existing
+			case "stopped":
+				fmt.Printf("▶️  Starting instance %s...\n", existing.ID)
+				if err := cloudInstanceAction(client, existing.ID, "start"); err != nil {
+					return nil, err
+				}
+				inst = existing
+				inst.Status = "starting"
+			}
+		}
+	}
+
+	if inst == nil {
+		var err error
+		inst, err = createCloudInstance(client, "cm-dev-"+name, cloudDevType, cloudDevProvider, cloudDevRegion, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := waitForCloudInstance(client, inst, cloudDevTimeout); err != nil {
+		return nil, err
+	}
+
+	return cloudWorkspace(client, inst.ID, "workspace/"+name)
+}
+
+// cloudWorkspace returns a workspace directory on an instance
+func cloudWorkspace(client *http.Client, instanceID, dir string) (*remoteWorkspace, error) {
+	endpoint, err := getCloudSSHEndpoint(client, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteWorkspace{
+		InstanceID: instanceID,
+		Host:       endpoint.Host,
+		Port:       endpoint.Port,
+		User:       endpoint.User,
+		Dir:        dir,
+	}, nil
+}
+
+// cloudDevBootstrapScript installs Docker and cm unless the instance's own
+// bootstrap already did, and creates the workspace directory
+func cloudDevBootstrapScript(dir string) string {
+	return `set -e
+command -v cloud-init >/dev/null && cloud-init status --wait >/dev/null 2>&1 || true
+SUDO=; [ "$(id -u)" = 0 ] || SUDO=sudo
+if ! command -v docker >/dev/null; then
+  curl -fsSL https://get.docker.com | $SUDO sh
+  $SUDO usermod -aG docker "$(id -un)" || true
+fi
+if ! command -v cm >/dev/null; then
+  case "$(uname -m)" in aarch64) arch=arm64 ;; *) arch=amd64 ;; esac
+  $SUDO curl -fsSLo /usr/local/bin/cm "https://github.com/UPwith-me/Container-Maker/releases/latest/download/cm-linux-$arch"
+  $SUDO chmod +x /usr/local/bin/cm
+fi
+mkdir -p ` + dir
+}
+
+// destination is the host to pass to ssh
+func (s *remoteWorkspace) destination() string {
+	if s.User == "" {
+		return s.Host
+	}
+	return s.User + "@" + s.Host
+}
+
+// sshOptions are the options of every SSH connection to the host; cloud
+// addresses are recycled, so instance host keys aren't remembered
+func (s *remoteWorkspace) sshOptions() []string {
+	var opts []string
+	if s.Port != 0 {
+		opts = append(opts, "-p", strconv.Itoa(s.Port))
+	}
+	if s.InstanceID != "" {
+		opts = append(opts,
+			"-o", "StrictHostKeyChecking=no",
+			"-o", "UserKnownHostsFile="+sshNullFile(),
+			"-o", "LogLevel=ERROR")
+	}
+	return opts
+}
+
+// sshCommand returns an ssh command running a remote command, with extra
+// options before the destination
+func (s *remoteWorkspace) sshCommand(remote string, extra ...string) *exec.Cmd {
+	args := append(s.sshOptions(), extra...)
+	args = append(args, s.destination())
+	if remote != "" {
+		args = append(args, remote)
+	}
+	return exec.Command("ssh", args...)
+}
+
+// waitForSSH waits until the instance accepts SSH connections
+func (s *remoteWorkspace) waitForSSH(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd := s.sshCommand("true", "-o", "ConnectTimeout=10", "-o", "BatchMode=yes")
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("SSH to %s failed: %s", s.destination(), strings.TrimSpace(string(out)))
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// run runs a shell script on the instance
+func (s *remoteWorkspace) run(script string) error {
+	cmd := s.sshCommand("sh -s")
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runInteractive runs a command on the instance with a terminal, so
+// progress output renders as it would locally
+func (s *remoteWorkspace) runInteractive(command string) error {
+	cmd := s.sshCommand(command, "-t")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// syncer returns an rsync syncer from the project to its remote workspace
+func (s *remoteWorkspace) syncer(projectDir string) (*cmsync.Syncer, error) {
+	return cmsync.New(cmsync.SyncConfig{
+		LocalPath:  projectDir,
+		RemoteHost: s.destination(),
+		SSHPort:    s.Port,
+		RemotePath: s.Dir,
+	})
+}
+
+// syncWorkspace copies the project to the instance with rsync, or by
+// pushing the current branch with git
+func (s *remoteWorkspace) syncWorkspace(projectDir, mode string) error {
+	if mode == "rsync" {
+		fmt.Println("📤 Syncing workspace...")
+		syncer, err := s.syncer(projectDir)
+		if err != nil {
+			return err
+		}
+		return syncer.SyncToRemote()
+	}
+
+	branch, err := exec.Command("git", "-C", projectDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("--sync git needs a git repository with a commit: %w", err)
+	}
+	if out, _ := exec.Command("git", "-C", projectDir, "status", "--porcelain").Output(); len(out) > 0 {
+		fmt.Println("⚠️  Uncommitted changes are not pushed with --sync git")
+	}
+
+	fmt.Printf("📤 Pushing %s...\n", strings.TrimSpace(string(branch)))
+	init := fmt.Sprintf("[ -d %[1]s/.git ] || git init -q %[1]s; git -C %[1]s config receive.denyCurrentBranch updateInstead", s.Dir)
+	if err := s.run(init); err != nil {
+		return fmt.Errorf("failed to prepare remote repository: %w", err)
+	}
+
+	ref := strings.TrimSpace(string(branch))
+	push := exec.Command("git", "-C", projectDir, "push", "--force",
+		fmt.Sprintf("ssh://%s@%s:%d/~/%s", s.User, s.Host, s.Port, s.Dir), "HEAD:refs/heads/"+ref)
+	push.Env = append(os.Environ(), "GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile="+sshNullFile()+" -o LogLevel=ERROR")
+	push.Stdout = os.Stdout
+	push.Stderr = os.Stderr
+	if err := push.Run(); err != nil {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	return s.run(fmt.Sprintf("git -C %s checkout -q %s", s.Dir, ref))
+}
+
+// tunnel forwards the ports from localhost to the instance until ctx ends
+func (s *remoteWorkspace) tunnel(ctx context.Context, ports []int) error {
+	if len(ports) == 0 {
+		fmt.Println("   No forwardPorts to tunnel; press Ctrl+C to exit")
+		<-ctx.Done()
+		return nil
+	}
+
+	var forwards []string
+	for _, port := range ports {
+		forwards = append(forwards, "-L", fmt.Sprintf("%d:localhost:%d", port, port))
+		fmt.Printf("📡 Forwarding localhost:%d → %s:%d\n", port, s.InstanceID, port)
+	}
+	fmt.Println("   Press Ctrl+C to stop forwarding")
+
+	args := append(s.sshOptions(), "-N", "-o", "ServerAliveInterval=30")
+	args = append(args, forwards...)
+	args = append(args, s.destination())
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("port forwarding stopped: %w", err)
+	}
+	return nil
+}
+
+// cloudDevShell opens a shell in the project's remote dev container,
+// forwarding its ports while the shell is open
+func cloudDevShell() error {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	state, err := loadCloudDevState(projectDir)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("this project has no cloud instance yet; run: cm cloud dev")
+	}
+	ports, _ := cloudDevPorts(projectDir)
+
+	var forwards []string
+	for _, port := range ports {
+		forwards = append(forwards, "-L", fmt.Sprintf("%d:localhost:%d", port, port))
+	}
+	cmd := state.sshCommand(fmt.Sprintf("cd %s && cm shell", state.Dir), append([]string{"-t"}, forwards...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+func init() {
+	cloudDevCmd.Flags().StringVar(&cloudDevType, "type", "cpu-medium", "Instance type of a new instance")
+	cloudDevCmd.Flags().StringVar(&cloudDevProvider, "provider", "aws", "Cloud provider of a new instance")
+	cloudDevCmd.Flags().StringVar(&cloudDevRegion, "region", "", "Cloud region of a new instance")
+	cloudDevCmd.Flags().StringVar(&cloudDevSync, "sync", "rsync", "How to copy the workspace: rsync or git")
+	cloudDevCmd.Flags().BoolVarP(&cloudDevWatch, "watch", "w", false, "Keep syncing local changes while running (rsync)")
+	cloudDevCmd.Flags().BoolVarP(&cloudDevDetach, "detach", "d", false, "Return once the dev container runs, without forwarding ports")
+	cloudDevCmd.Flags().DurationVar(&cloudDevTimeout, "timeout", 15*time.Minute, "How long to wait for the instance to run")
+	cloudCmd.AddCommand(cloudDevCmd)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+)
+
+var (
+	cloudEstimateHours        float64
+	cloudEstimateRegion       string
+	cloudEstimateMinVCPU      int
+	cloudEstimateMinMemory    int
+	cloudEstimateMinGPUMemory int
+	cloudEstimateGPU          string
+	cloudEstimateFormat       string
+)
+
+// cloudCostEstimate is an offer's cost as estimated by the control plane
+type cloudCostEstimate struct {
+	Provider    string  `json:"provider"`
+	Region      string  `json:"region"`
+	Type        string  `json:"type"`
+	VCPU        int     `json:"vcpu"`
+	MemoryGB    int     `json:"memory_gb"`
+	GPUType     string  `json:"gpu_type,omitempty"`
+	GPUMemoryGB int     `json:"gpu_memory_gb,omitempty"`
+	HourlyRate  float64 `json:"hourly_rate"`
+	Currency    string  `json:"currency"`
+	Total       float64 `json:"total"`
+	Live        bool    `json:"live"`
+}
+
+// cloudEstimate is the control plane's answer to an estimate
+type cloudEstimate struct {
+	Hours       float64             `json:"hours"`
+	Estimates   []cloudCostEstimate `json:"estimates"`
+	CheapestGPU *cloudCostEstimate  `json:"cheapest_gpu,omitempty"`
+}
+
+var cloudEstimateCmd = &cobra.Command{
+	Use:   "estimate [instance-type]",
+	Short: "Estimate what an instance costs with each provider",
+	Long: `Estimate what an instance type costs over --hours with each provider
+that offers it, cheapest first, and find the cheapest GPU offer meeting the
+--min-* and --gpu requirements.
+
+Each provider is priced in --region where it has it, and in its cheapest
+region otherwise. Prices are the providers' live ones where they can be
+discovered ('cm cloud pricing'). Only USD prices compete for the cheapest
+GPU, as rates aren't converted; Docker and your own machines are free and
+left out.
+
+EXAMPLES
+  cm cloud estimate gpu-t4 --hours 40
+  cm cloud estimate cpu-medium --hours 160 --region us-east-1
+  cm cloud estimate --hours 100 --min-gpu-memory 40
+  cm cloud estimate --hours 8 --gpu A100 --format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudEstimateFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]interface{}{
+			"region":            cloudEstimateRegion,
+			"hours":             cloudEstimateHours,
+			"min_vcpu":          cloudEstimateMinVCPU,
+			"min_memory_gb":     cloudEstimateMinMemory,
+			"min_gpu_memory_gb": cloudEstimateMinGPUMemory,
+			"gpu_type":          cloudEstimateGPU,
+		}
+		if len(args) == 1 {
+			req["instance_type"] = args[0]
+		}
+		var estimate cloudEstimate
+		if err := cloudSendJSON(client, http.MethodPost, cloudBaseURL()+"/api/v1/estimates", req, http.StatusOK, "estimate cost", &estimate); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudEstimateFormat, estimate, func() error {
+			if len(args) == 1 {
+				if len(estimate.Estimates) == 0 {
+					fmt.Printf("No provider offers %s.\n", args[0])
+				} else {
+					fmt.Printf("💰 %s for %g hours\n", args[0], estimate.Hours)
+					fmt.Println()
+					w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+					fmt.Fprintln(w, "PROVIDER\tREGION\tSPEC\tRATE\tTOTAL")
+					for _, e := range estimate.Estimates {
+						rate := fmt.Sprintf("%.4f %s/hr", e.HourlyRate, e.Currency)
+						if !e.Live {
+							rate += " (est.)"
+						}
+						fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f %s\n", e.Provider, e.Region, estimateSpec(e), rate, e.Total, e.Currency)
+					}
+					if err := w.Flush(); err != nil {
+						return err
+					}
+				}
+				fmt.Println()
+			}
+			if gpu := estimate.CheapestGPU; gpu != nil {
+				fmt.Printf("⭐ Cheapest GPU: %s %s in %s, %s, $%.2f for %g hours ($%.4f/hr)\n",
+					gpu.Provider, gpu.Type, gpu.Region, estimateSpec(*gpu), gpu.Total, estimate.Hours, gpu.HourlyRate)
+				fmt.Printf("   Create it with: cm cloud create --provider %s --type %s --region %s\n", gpu.Provider, gpu.Type, gpu.Region)
+			} else {
+				fmt.Println("No GPU offer meets the requirements.")
+			}
+			return nil
+		})
+	},
+}
+
+// estimateSpec describes the hardware of an estimated offer
+func estimateSpec(e cloudCostEstimate) string {
+	spec := fmt.Sprintf("%d vCPU, %d GB", e.VCPU, e.MemoryGB)
+	if e.GPUType != "" {
+		spec += ", " + e.GPUType
+		if e.GPUMemoryGB > 0 {
+			spec += fmt.Sprintf(" %d GB", e.GPUMemoryGB)
+		}
+	}
+	return spec
+}
+
+func init() {
+	cloudEstimateCmd.Flags().Float64Var(&cloudEstimateHours, "hours", 1, "Hours the instance runs")
+	cloudEstimateCmd.Flags().StringVar(&cloudEstimateRegion, "region", "", "Preferred region")
+	cloudEstimateCmd.Flags().IntVar(&cloudEstimateMinVCPU, "min-vcpu", 0, "vCPUs the GPU offer needs at least")
+	cloudEstimateCmd.Flags().IntVar(&cloudEstimateMinMemory, "min-memory", 0, "Memory in GB the GPU offer needs at least")
+	cloudEstimateCmd.Flags().IntVar(&cloudEstimateMinGPUMemory, "min-gpu-memory", 0, "GPU memory in GB the GPU offer needs at least")
+	cloudEstimateCmd.Flags().StringVar(&cloudEstimateGPU, "gpu", "", "GPU model the GPU offer needs, e.g. A100")
+	cloudEstimateCmd.Flags().StringVar(&cloudEstimateFormat, "format", "", output.FlagUsage)
+	cloudCmd.AddCommand(cloudEstimateCmd)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var cloudExecContainer string
+
+var cloudExecCmd = &cobra.Command{
+	Use:   "exec <instance-id> -- <command> [args...]",
+	Short: "Run a command on a cloud instance through its agent",
+	Long: `Run a command on a cloud instance, or with --container in one of its
+containers, and print its output.
+
+The command goes through the cm agent's connection to the control plane
+rather than SSH, so it works for instances behind NAT or a firewall that
+blocks inbound connections. It runs for at most 10 minutes, and cm exits
+with its exit code.
+
+EXAMPLES
+  cm cloud exec <id> -- nvidia-smi
+  cm cloud exec <id> --container web -- sh -c 'tail -n 50 /var/log/app.log'`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		client.Timeout = 11 * time.Minute
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"command":   args[1:],
+			"container": cloudExecContainer,
+		})
+		resp, err := client.Post(fmt.Sprintf("%s/api/v1/instances/%s/exec", cloudBaseURL(), url.PathEscape(args[0])),
+			"application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to run command: %s", cloudErrorMessage(resp))
+		}
+
+		var result struct {
+			Stdout   string `json:"stdout"`
+			Stderr   string `json:"stderr"`
+			ExitCode int    `json:"exit_code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("failed to run command: %w", err)
+		}
+		fmt.Print(result.Stdout)
+		fmt.Fprint(os.Stderr, result.Stderr)
+		if result.ExitCode != 0 {
+			os.Exit(result.ExitCode)
+		}
+		return nil
+	},
+}
+
+var cloudHealthCmd = &cobra.Command{
+	Use:   "health <instance-id>",
+	Short: "Show a cloud instance's health as reported by its agent",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Get(fmt.Sprintf("%s/api/v1/instances/%s/health", cloudBaseURL(), url.PathEscape(args[0])))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get health: %s", cloudErrorMessage(resp))
+		}
+
+		var result struct {
+			AgentConnectedAt time.Time `json:"agent_connected_at"`
+			Health           struct {
+				Load              float64 `json:"load"`
+				SSHSessions       int     `json:"ssh_sessions"`
+				UptimeSeconds     int64   `json:"uptime_seconds"`
+				MemoryTotalMB     int64   `json:"memory_total_mb"`
+				MemoryAvailableMB int64   `json:"memory_available_mb"`
+				Containers        int     `json:"containers"`
+				DockerVersion     string  `json:"docker_version"`
+			} `json:"health"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("failed to get health: %w", err)
+		}
+		h := result.Health
+		fmt.Printf("🩺 Instance %s\n", args[0])
+		fmt.Printf("   Agent:      connected %s ago\n", time.Since(result.AgentConnectedAt).Round(time.Second))
+		fmt.Printf("   Uptime:     %s\n", (time.Duration(h.UptimeSeconds) * time.Second).String())
+		fmt.Printf("   Load:       %.2f per CPU\n", h.Load)
+		if h.MemoryTotalMB > 0 {
+			fmt.Printf("   Memory:     %d MB free of %d MB\n", h.MemoryAvailableMB, h.MemoryTotalMB)
+		}
+		fmt.Printf("   SSH:        %d sessions\n", h.SSHSessions)
+		fmt.Printf("   Docker:     %s, %d containers running\n", h.DockerVersion, h.Containers)
+		return nil
+	},
+}
+
+func init() {
+	cloudExecCmd.Flags().StringVar(&cloudExecContainer, "container", "", "Run the command in this container instead of on the instance")
+	cloudCmd.AddCommand(cloudExecCmd)
+	cloudCmd.AddCommand(cloudHealthCmd)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+)
+
+var (
+	cloudGitHubTeam     string
+	cloudGitHubProvider string
+	cloudGitHubType     string
+	cloudGitHubRegion   string
+	cloudGitHubFormat   string
+)
+
+// cloudRepoLink is a linked repository as returned by the control plane
+type cloudRepoLink struct {
+	ID           string    `json:"id"`
+	Repo         string    `json:"repo"`
+	Provider     string    `json:"provider"`
+	InstanceType string    `json:"instance_type"`
+	Region       string    `json:"region,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// cloudPREnvironment is a pull request environment as returned by the
+// control plane
+type cloudPREnvironment struct {
+	ID           string    `json:"id"`
+	Repo         string    `json:"repo"`
+	Number       int       `json:"number"`
+	Branch       string    `json:"branch"`
+	Author       string    `json:"author"`
+	InstanceID   *string   `json:"instance_id,omitempty"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+var cloudGitHubCmd = &cobra.Command{
+	Use:   "github",
+	Short: "Create dev environments for pull requests",
+	Long: `Link GitHub repositories to a team, so each pull request opened in them
+gets a dev environment: an instance with the branch cloned and its dev
+container started. The control plane comments how to connect on the pull
+request, and deletes the instance when the pull request is merged or closed.
+
+Environments are created with the GitHub App of the control plane, which
+its operator sets up with these environment variables:
+
+  GITHUB_APP_ID                  The app's ID
+  GITHUB_APP_PRIVATE_KEY(_FILE)  Its private key, in PEM
+  GITHUB_WEBHOOK_SECRET          Its webhook secret
+  GITHUB_API_URL                 For GitHub Enterprise Server
+
+The app's webhook URL is <control plane>/api/v1/github/webhook, subscribed
+to pull request events, and it needs read access to contents and pull
+requests and write access to issues. Install it on the repositories before
+linking them.
+
+Instances are owned by the admin who links a repository and bill to the
+team, and need a provider that installs cm, such as aws or hetzner. The
+pull request author's GitHub SSH keys are authorized on them. Pull requests
+from forks don't get environments.
+
+EXAMPLES
+  cm cloud github link acme/api --team <team-id>
+  cm cloud github link acme/web --team <team-id> --provider hetzner --type cpu-large
+  cm cloud github list --team <team-id>
+  cm cloud github unlink <link-id> --team <team-id>`,
+}
+
+var cloudGitHubLinkCmd = &cobra.Command{
+	Use:   "link <owner/repo>",
+	Short: "Create environments for a repository's pull requests",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudGitHubTeam == "" {
+			return fmt.Errorf("--team is required")
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]string{
+			"repo":          args[0],
+			"provider":      cloudGitHubProvider,
+			"instance_type": cloudGitHubType,
+			"region":        cloudGitHubRegion,
+		}
+		var link cloudRepoLink
+		endpoint := cloudBaseURL() + "/api/v1/teams/" + url.PathEscape(cloudGitHubTeam) + "/github-repos"
+		if err := cloudSendJSON(client, http.MethodPost, endpoint, req, http.StatusCreated, "link repository", &link); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Pull requests in %s get %s %s environments (%s)\n", link.Repo, link.Provider, link.InstanceType, link.ID)
+		return nil
+	},
+}
+
+var cloudGitHubListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List a team's linked repositories and pull request environments",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudGitHubTeam == "" {
+			return fmt.Errorf("--team is required")
+		}
+		if err := output.Validate(cloudGitHubFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		base := cloudBaseURL() + "/api/v1/teams/" + url.PathEscape(cloudGitHubTeam)
+		var links []cloudRepoLink
+		if err := cloudGetJSON(client, base+"/github-repos", "list repositories", &links); err != nil {
+			return err
+		}
+		var envs []cloudPREnvironment
+		if err := cloudGetJSON(client, base+"/pr-environments", "list environments", &envs); err != nil {
+			return err
+		}
+
+		result := struct {
+			Repos        []cloudRepoLink      `json:"repos"`
+			Environments []cloudPREnvironment `json:"environments"`
+		}{links, envs}
+		return output.Print(os.Stdout, cloudGitHubFormat, result, func() error {
+			if len(links) == 0 {
+				fmt.Println("No linked repositories.")
+				fmt.Println()
+				fmt.Println("Link one with: cm cloud github link <owner/repo> --team <team-id>")
+				return nil
+			}
+			fmt.Println("🔗 Linked repositories")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tREPOSITORY\tPROVIDER\tTYPE\tLINKED")
+			for _, l := range links {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.ID, l.Repo, l.Provider, l.InstanceType, formatAge(l.CreatedAt))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(envs) == 0 {
+				return nil
+			}
+
+			fmt.Println()
+			fmt.Println("🚀 Pull request environments")
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PULL REQUEST\tBRANCH\tAUTHOR\tSTATUS\tINSTANCE\tCREATED")
+			for _, e := range envs {
+				instance := "-"
+				if e.InstanceID != nil {
+					instance = *e.InstanceID
+				}
+				if e.Status == "failed" && e.StatusReason != "" {
+					instance = e.StatusReason
+					if i := strings.IndexByte(instance, '\n'); i >= 0 {
+						instance = instance[:i]
+					}
+				}
+				fmt.Fprintf(w, "%s#%d\t%s\t%s\t%s\t%s\t%s\n", e.Repo, e.Number, e.Branch, e.Author, e.Status, instance, formatAge(e.CreatedAt))
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudGitHubUnlinkCmd = &cobra.Command{
+	Use:   "unlink <link-id>",
+	Short: "Stop creating environments for a repository's pull requests",
+	Long: `Stop creating environments for a repository's pull requests. The
+environments of its open pull requests stay until they're closed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudGitHubTeam == "" {
+			return fmt.Errorf("--team is required")
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/teams/" + url.PathEscape(cloudGitHubTeam) + "/github-repos/" + url.PathEscape(args[0])
+		if err := cloudSendJSON(client, http.MethodDelete, endpoint, nil, http.StatusNoContent, "unlink repository", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Repository %s unlinked\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	cloudGitHubCmd.PersistentFlags().StringVar(&cloudGitHubTeam, "team", "", "Team the repositories are linked to")
+	cloudGitHubLinkCmd.Flags().StringVar(&cloudGitHubProvider, "provider", "aws", "Provider of the environments' instances")
+	cloudGitHubLinkCmd.Flags().StringVar(&cloudGitHubType, "type", "cpu-medium", "Instance type of the environments")
+	cloudGitHubLinkCmd.Flags().StringVar(&cloudGitHubRegion, "region", "", "Region of the environments")
+	cloudGitHubListCmd.Flags().StringVar(&cloudGitHubFormat, "format", "", output.FlagUsage)
+	cloudGitHubCmd.AddCommand(cloudGitHubLinkCmd, cloudGitHubListCmd, cloudGitHubUnlinkCmd)
+	cloudCmd.AddCommand(cloudGitHubCmd)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+)
+
+var (
+	cloudHostAddress string
+	cloudHostTeam    string
+	cloudHostFormat  string
+)
+
+// cloudHost is a registered machine as returned by the control plane
+type cloudHost struct {
+	ID            string     `json:"id"`
+	TeamID        *string    `json:"team_id,omitempty"`
+	Name          string     `json:"name"`
+	Address       string     `json:"address"`
+	VCPU          int        `json:"vcpu"`
+	MemoryMB      int64      `json:"memory_mb"`
+	GPUs          string     `json:"gpus"`
+	DockerVersion string     `json:"docker_version"`
+	Online        bool       `json:"online"`
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+var cloudHostCmd = &cobra.Command{
+	Use:     "host",
+	Aliases: []string{"hosts"},
+	Short:   "Manage your own machines that instances run on",
+	Long: `Manage hosts: machines you or your team register, such as a lab's idle
+GPU workstations, that instances run on as Docker containers.
+
+Registering a host prints a command to run on it, which starts the cm
+agent with the host's token. The agent dials out to the control plane, so
+the machine needs no open ports besides those of its instances, and
+reports its CPUs, memory and GPUs. Instances created with --provider hosts
+are placed on the connected host with the least room left that fits them;
+GPU types take one whole GPU, whatever its model. GPUs need the NVIDIA
+Container Toolkit on the host.
+
+A team's hosts run only the team's instances, and personal hosts only
+their owner's.
+
+EXAMPLES
+  cm cloud host add lab-3090
+  cm cloud host add lab-a100 --team <team-id> --address lab.example.com
+  cm cloud host list
+  cm cloud create --provider hosts --type gpu-t4
+  cm cloud host rm <host-id>`,
+}
+
+var cloudHostListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List hosts",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudHostFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var hosts []cloudHost
+		if err := cloudGetJSON(client, cloudBaseURL()+"/api/v1/hosts", "list hosts", &hosts); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudHostFormat, hosts, func() error {
+			if len(hosts) == 0 {
+				fmt.Println("No hosts.")
+				fmt.Println()
+				fmt.Println("Register one with: cm cloud host add <name>")
+				return nil
+			}
+			fmt.Println("🖥️  Hosts")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tADDRESS\tSTATUS\tCPUS\tMEMORY\tGPUS\tCREATED")
+			for _, h := range hosts {
+				status := "offline"
+				if h.Online {
+					status = "online"
+				} else if h.LastSeenAt == nil {
+					status = "never connected"
+				}
+				gpus := "-"
+				if h.GPUs != "" {
+					gpus = strings.ReplaceAll(h.GPUs, ",", ", ")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d GB\t%s\t%s\n",
+					h.ID, h.Name, h.Address, status, h.VCPU, h.MemoryMB/1024, gpus, formatAge(h.CreatedAt))
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudHostAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Register a machine and print the command that connects it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]interface{}{"name": args[0], "address": cloudHostAddress}
+		if cloudHostTeam != "" {
+			req["team_id"] = cloudHostTeam
+		}
+		var created struct {
+			Host    cloudHost `json:"host"`
+			Token   string    `json:"token"`
+			Command string    `json:"command"`
+		}
+		if err := cloudSendJSON(client, http.MethodPost, cloudBaseURL()+"/api/v1/hosts", req, http.StatusCreated, "register host", &created); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Host %s registered (%s)\n", created.Host.Name, created.Host.ID)
+		fmt.Println()
+		fmt.Println("Run this on the machine, which needs Docker and cm installed:")
+		fmt.Println()
+		fmt.Printf("  %s\n", created.Command)
+		fmt.Println()
+		fmt.Println("⚠️  The token isn't shown again.")
+		return nil
+	},
+}
+
+var cloudHostRmCmd = &cobra.Command{
+	Use:     "rm <host-id>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Unregister a host that runs no instances",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/hosts/" + url.PathEscape(args[0])
+		if err := cloudSendJSON(client, http.MethodDelete, endpoint, nil, http.StatusNoContent, "delete host", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Host %s unregistered\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	cloudHostAddCmd.Flags().StringVar(&cloudHostAddress, "address", "", "Address instances' SSH ports are reached at (default: the agent's IP)")
+	cloudHostAddCmd.Flags().StringVar(&cloudHostTeam, "team", "", "Register the host for this team's instances")
+	cloudHostListCmd.Flags().StringVar(&cloudHostFormat, "format", "", output.FlagUsage)
+	cloudHostCmd.AddCommand(cloudHostListCmd, cloudHostAddCmd, cloudHostRmCmd)
+	cloudCmd.AddCommand(cloudHostCmd)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloudPolicyTeam       string
+	cloudPolicyIdle       time.Duration
+	cloudPolicyWarn       time.Duration
+	cloudPolicyShutdownAt string
+	cloudPolicyTimezone   string
+	cloudPolicyWeekdays   string
+)
+
+// cloudIdlePolicy is an idle policy as returned by the control plane
+type cloudIdlePolicy struct {
+	IdleMinutes int    `json:"idle_minutes"`
+	WarnMinutes int    `json:"warn_minutes"`
+	ShutdownAt  string `json:"shutdown_at"`
+	Timezone    string `json:"timezone"`
+	Weekdays    string `json:"weekdays"`
+}
+
+var cloudPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show or set when idle instances are stopped",
+	Long: `Show or set the idle policy that stops your running instances, or with
+--team a team's instances, so they don't bill while nobody uses them.
+
+An instance is idle while the cm agent on it reports no SSH sessions,
+container execs or CPU load. --idle stops it after that long idle;
+--shutdown-at stops every instance still running at that time of day,
+on --weekdays only if given. The owner is warned in the dashboard
+--warn ahead of each stop. Flags not given keep their current values.
+
+EXAMPLES
+  cm cloud policy                                  # Show the policy
+  cm cloud policy --idle 30m                       # Stop after 30 idle minutes
+  cm cloud policy --shutdown-at 19:00 --timezone Europe/Berlin --weekdays mon,tue,wed,thu,fri
+  cm cloud policy --team <team-id> --idle 1h       # Set a team's policy
+  cm cloud policy --idle 0 --shutdown-at ""        # Turn both off`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/idle-policy"
+		if cloudPolicyTeam != "" {
+			endpoint = fmt.Sprintf("%s/api/v1/teams/%s/idle-policy", cloudBaseURL(), url.PathEscape(cloudPolicyTeam))
+		}
+
+		policy, err := fetchCloudIdlePolicy(client, endpoint)
+		if err != nil {
+			return err
+		}
+
+		flags := cmd.Flags()
+		changed := false
+		if flags.Changed("idle") {
+			policy.IdleMinutes, changed = int(cloudPolicyIdle.Minutes()), true
+		}
+		if flags.Changed("warn") {
+			policy.WarnMinutes, changed = int(cloudPolicyWarn.Minutes()), true
+		}
+		if flags.Changed("shutdown-at") {
+			policy.ShutdownAt, changed = cloudPolicyShutdownAt, true
+		}
+		if flags.Changed("timezone") {
+			policy.Timezone, changed = cloudPolicyTimezone, true
+		}
+		if flags.Changed("weekdays") {
+			policy.Weekdays, changed = cloudPolicyWeekdays, true
+		}
+
+		if changed {
+			body, _ := json.Marshal(policy)
+			req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("failed to update idle policy: %s", cloudErrorMessage(resp))
+			}
+			if err := json.NewDecoder(resp.Body).Decode(policy); err != nil {
+				return err
+			}
+			fmt.Println("✅ Idle policy updated")
+			fmt.Println()
+		}
+
+		if cloudPolicyTeam != "" {
+			fmt.Printf("💤 Idle policy of team %s\n", cloudPolicyTeam)
+		} else {
+			fmt.Println("💤 Idle policy of your instances")
+		}
+		if policy.IdleMinutes > 0 {
+			fmt.Printf("   Idle:      stop after %d minutes without activity\n", policy.IdleMinutes)
+		} else {
+			fmt.Println("   Idle:      off")
+		}
+		if policy.ShutdownAt != "" {
+			tz, days := policy.Timezone, policy.Weekdays
+			if tz == "" {
+				tz = "UTC"
+			}
+			if days == "" {
+				days = "every day"
+			}
+			fmt.Printf("   Off-hours: stop at %s %s (%s)\n", policy.ShutdownAt, tz, days)
+		} else {
+			fmt.Println("   Off-hours: off")
+		}
+		if policy.IdleMinutes > 0 || policy.ShutdownAt != "" {
+			fmt.Printf("   Warning:   %d minutes before stopping\n", policy.WarnMinutes)
+		}
+		return nil
+	},
+}
+
+// fetchCloudIdlePolicy fetches the user's or a team's idle policy
+func fetchCloudIdlePolicy(client *http.Client, endpoint string) (*cloudIdlePolicy, error) {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get idle policy: %s", cloudErrorMessage(resp))
+	}
+	var policy cloudIdlePolicy
+	if err := json.NewDecoder(resp.Body).Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to get idle policy: %w", err)
+	}
+	return &policy, nil
+}
+
+func init() {
+	cloudPolicyCmd.Flags().StringVar(&cloudPolicyTeam, "team", "", "Show or set the policy of this team's instances")
+	cloudPolicyCmd.Flags().DurationVar(&cloudPolicyIdle, "idle", 0, "Stop instances idle this long (0 turns it off)")
+	cloudPolicyCmd.Flags().DurationVar(&cloudPolicyWarn, "warn", 10*time.Minute, "Warn this long before stopping")
+	cloudPolicyCmd.Flags().StringVar(&cloudPolicyShutdownAt, "shutdown-at", "", "Stop running instances at this time of day, e.g. 19:00 (\"\" turns it off)")
+	cloudPolicyCmd.Flags().StringVar(&cloudPolicyTimezone, "timezone", "", "Time zone of --shutdown-at, e.g. Europe/Berlin (default UTC)")
+	cloudPolicyCmd.Flags().StringVar(&cloudPolicyWeekdays, "weekdays", "", "Days --shutdown-at applies on, e.g. mon,tue,wed,thu,fri (default every day)")
+	cloudCmd.AddCommand(cloudPolicyCmd)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+)
+
+var (
+	cloudPrebuildTeam          string
+	cloudPrebuildRef           string
+	cloudPrebuildConfig        string
+	cloudPrebuildProvider      string
+	cloudPrebuildType          string
+	cloudPrebuildRegion        string
+	cloudPrebuildGitTokenStdin bool
+	cloudPrebuildFormat        string
+
+	cloudRegistryURL           string
+	cloudRegistryUsername      string
+	cloudRegistryPasswordStdin bool
+)
+
+// cloudPrebuild is a prebuild as returned by the control plane
+type cloudPrebuild struct {
+	ID           string     `json:"id"`
+	TeamID       string     `json:"team_id"`
+	RepoURL      string     `json:"repo_url"`
+	Ref          string     `json:"ref,omitempty"`
+	ConfigPath   string     `json:"config_path,omitempty"`
+	Provider     string     `json:"provider"`
+	InstanceType string     `json:"instance_type"`
+	InstanceID   *string    `json:"instance_id,omitempty"`
+	Status       string     `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	ConfigHash   string     `json:"config_hash,omitempty"`
+	Image        string     `json:"image,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+var cloudPrebuildCmd = &cobra.Command{
+	Use:     "prebuild",
+	Aliases: []string{"prebuilds"},
+	Short:   "Prebuild devcontainer images on cloud builders",
+	Long: `Prebuild a repository's devcontainer image, with its features installed,
+on a builder instance and push it to your team's registry.
+
+A builder is created in the team for the build and deleted after it, and
+bills like any instance; it needs a provider that installs cm, such as aws
+or hetzner. Its image is pushed as <registry>/<repository name>:<hash>,
+where the hash is that of the configuration ('cm prebuild hash').
+
+When you're signed in, cm looks up the hash of a project's configuration
+before building its image, and pulls the newest prebuilt image of it from
+your teams instead. Set CM_NO_PREBUILD=1 to always build locally.
+
+The registry's password and the git token are encrypted at rest, but the
+builder holds them in the clear while it runs.
+
+EXAMPLES
+  echo "$GHCR_TOKEN" | cm cloud prebuild registry --team <team-id> --url ghcr.io/acme/devcontainers --username acme-bot --password-stdin
+  cm cloud prebuild create https://github.com/acme/api --team <team-id>
+  echo "$GITHUB_TOKEN" | cm cloud prebuild create https://github.com/acme/private --team <team-id> --ref main --git-token-stdin
+  cm cloud prebuild list
+  cm cloud prebuild rm <prebuild-id>`,
+}
+
+var cloudPrebuildCreateCmd = &cobra.Command{
+	Use:   "create <git-url>",
+	Short: "Build and push a repository's devcontainer image on a builder",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudPrebuildTeam == "" {
+			return fmt.Errorf("--team is required; images are pushed to the team's registry")
+		}
+		req := map[string]interface{}{
+			"team_id":       cloudPrebuildTeam,
+			"repo_url":      args[0],
+			"ref":           cloudPrebuildRef,
+			"config_path":   cloudPrebuildConfig,
+			"provider":      cloudPrebuildProvider,
+			"instance_type": cloudPrebuildType,
+			"region":        cloudPrebuildRegion,
+		}
+		if cloudPrebuildGitTokenStdin {
+			token, err := readStdinSecret()
+			if err != nil {
+				return err
+			}
+			req["git_token"] = token
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var prebuild cloudPrebuild
+		if err := cloudSendJSON(client, http.MethodPost, cloudBaseURL()+"/api/v1/prebuilds", req, http.StatusAccepted, "create prebuild", &prebuild); err != nil {
+			return err
+		}
+		fmt.Printf("🏗️  Prebuild %s started on a %s %s builder\n", prebuild.ID, prebuild.Provider, prebuild.InstanceType)
+		fmt.Println()
+		fmt.Println("Follow it with: cm cloud prebuild list")
+		return nil
+	},
+}
+
+var cloudPrebuildListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List your teams' prebuilds",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudPrebuildFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var prebuilds []cloudPrebuild
+		if err := cloudGetJSON(client, cloudBaseURL()+"/api/v1/prebuilds", "list prebuilds", &prebuilds); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudPrebuildFormat, prebuilds, func() error {
+			if len(prebuilds) == 0 {
+				fmt.Println("No prebuilds.")
+				fmt.Println()
+				fmt.Println("Start one with: cm cloud prebuild create <git-url> --team <team-id>")
+				return nil
+			}
+			fmt.Println("🏗️  Prebuilds")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tREPOSITORY\tREF\tSTATUS\tIMAGE\tCREATED")
+			for _, p := range prebuilds {
+				ref, image := p.Ref, p.Image
+				if ref == "" {
+					ref = "-"
+				}
+				if image == "" {
+					image = "-"
+				}
+				if p.Status == "failed" && p.StatusReason != "" {
+					image = p.StatusReason
+					if i := strings.IndexByte(image, '\n'); i >= 0 {
+						image = image[:i]
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.RepoURL, ref, p.Status, image, formatAge(p.CreatedAt))
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudPrebuildRmCmd = &cobra.Command{
+	Use:     "rm <prebuild-id>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Forget a finished prebuild; its image stays in the registry",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/prebuilds/" + url.PathEscape(args[0])
+		if err := cloudSendJSON(client, http.MethodDelete, endpoint, nil, http.StatusNoContent, "delete prebuild", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Prebuild %s deleted\n", args[0])
+		return nil
+	},
+}
+
+var cloudPrebuildRegistryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Show or set the registry a team's prebuilt images are pushed to",
+	Long: `Show the registry a team's prebuilt images are pushed to, or set it with
+--url, --username and --password-stdin; an empty --url removes it. Setting
+it takes the admin role in the team.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudPrebuildTeam == "" {
+			return fmt.Errorf("--team is required")
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/teams/" + url.PathEscape(cloudPrebuildTeam) + "/registry"
+		var registry struct {
+			URL      string `json:"url"`
+			Username string `json:"username"`
+		}
+		if cmd.Flags().Changed("url") {
+			req := map[string]string{"url": cloudRegistryURL, "username": cloudRegistryUsername}
+			if cloudRegistryPasswordStdin {
+				if req["password"], err = readStdinSecret(); err != nil {
+					return err
+				}
+			}
+			if err := cloudSendJSON(client, http.MethodPut, endpoint, req, http.StatusOK, "set registry", &registry); err != nil {
+				return err
+			}
+			if registry.URL == "" {
+				fmt.Println("✅ Registry removed")
+				return nil
+			}
+			fmt.Printf("✅ Prebuilt images are pushed to %s as %s\n", registry.URL, registry.Username)
+			return nil
+		}
+
+		if err := cloudGetJSON(client, endpoint, "get registry", &registry); err != nil {
+			return err
+		}
+		if registry.URL == "" {
+			fmt.Println("No registry set.")
+			return nil
+		}
+		fmt.Printf("Registry: %s\nUsername: %s\n", registry.URL, registry.Username)
+		return nil
+	},
+}
+
+// readStdinSecret reads a password or token piped to cm
+func readStdinSecret() (string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("nothing was read from stdin")
+	}
+	return secret, nil
+}
+
+// lookupPrebuild asks the control plane for a prebuilt image of a prebuild
+// hash in the signed-in user's teams. Not being signed in or having none
+// means building locally.
+func lookupPrebuild(ctx context.Context, hash string) (string, error) {
+	if os.Getenv("CM_NO_PREBUILD") != "" {
+		return "", nil
+	}
+	client, err := getCloudClient()
+	if err != nil {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cloudBaseURL()+"/api/v1/prebuilds/lookup?hash="+url.QueryEscape(hash), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(cloudErrorMessage(resp))
+	}
+	var found struct {
+		Image string `json:"image"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return "", err
+	}
+	return found.Image, nil
+}
+
+func init() {
+	runner.PrebuildLookup = lookupPrebuild
+
+	cloudPrebuildCreateCmd.Flags().StringVar(&cloudPrebuildTeam, "team", "", "Team whose registry the image is pushed to")
+	cloudPrebuildCreateCmd.Flags().StringVar(&cloudPrebuildRef, "ref", "", "Branch or tag to build (default: the default branch)")
+	cloudPrebuildCreateCmd.Flags().StringVarP(&cloudPrebuildConfig, "config", "c", "", "Path to devcontainer.json in the repository")
+	cloudPrebuildCreateCmd.Flags().StringVar(&cloudPrebuildProvider, "provider", "aws", "Provider of the builder")
+	cloudPrebuildCreateCmd.Flags().StringVar(&cloudPrebuildType, "type", "cpu-large", "Instance type of the builder")
+	cloudPrebuildCreateCmd.Flags().StringVar(&cloudPrebuildRegion, "region", "", "Region of the builder")
+	cloudPrebuildCreateCmd.Flags().BoolVar(&cloudPrebuildGitTokenStdin, "git-token-stdin", false, "Read a token for a private repository from stdin")
+	cloudPrebuildListCmd.Flags().StringVar(&cloudPrebuildFormat, "format", "", output.FlagUsage)
+	cloudPrebuildRegistryCmd.Flags().StringVar(&cloudPrebuildTeam, "team", "", "Team whose registry to show or set")
+	cloudPrebuildRegistryCmd.Flags().StringVar(&cloudRegistryURL, "url", "", "Repository prefix, e.g. ghcr.io/acme/devcontainers")
+	cloudPrebuildRegistryCmd.Flags().StringVar(&cloudRegistryUsername, "username", "", "Registry username")
+	cloudPrebuildRegistryCmd.Flags().BoolVar(&cloudRegistryPasswordStdin, "password-stdin", false, "Read the registry password or token from stdin")
+	cloudPrebuildCmd.AddCommand(cloudPrebuildCreateCmd, cloudPrebuildListCmd, cloudPrebuildRmCmd, cloudPrebuildRegistryCmd)
+	cloudCmd.AddCommand(cloudPrebuildCmd)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloudScheduleCreateAt  string
+	cloudScheduleStopAt    string
+	cloudScheduleStopAfter time.Duration
+	cloudSchedulePostpone  time.Duration
+	cloudScheduleNoStop    bool
+)
+
+// cloudSchedule is when an instance is created and stopped
+type cloudSchedule struct {
+	CreateAt  *time.Time `json:"create_at,omitempty"`
+	StopAt    *time.Time `json:"stop_at,omitempty"`
+	StopAfter string     `json:"stop_after,omitempty"`
+	Postpone  string     `json:"postpone,omitempty"`
+	NoStop    bool       `json:"no_stop,omitempty"`
+}
+
+var cloudScheduleCmd = &cobra.Command{
+	Use:   "schedule <id>",
+	Short: "Show or change when an instance is created and stopped",
+	Long: `Show or change an instance's schedule.
+
+A scheduled stop stops the instance at that time; the owner is warned in
+the dashboard 10 minutes ahead. --postpone moves it later, --no-stop
+clears it. --create-at moves the creation of an instance that is still
+scheduled; 'cm cloud rm' cancels one.
+
+Times are RFC 3339 (2026-05-04T19:00:00Z), a local date and time
+(2026-05-04 19:00), or a local time of day (19:00), meaning its next
+occurrence.
+
+EXAMPLES
+  cm cloud schedule <id>                    # Show the schedule
+  cm cloud schedule <id> --stop-after 4h    # Stop 4 hours from now
+  cm cloud schedule <id> --stop-at 19:00    # Stop at 7 pm
+  cm cloud schedule <id> --postpone 1h      # Stop an hour later
+  cm cloud schedule <id> --no-stop          # Keep it running`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+
+		flags := cmd.Flags()
+		stopOptions := 0
+		for _, name := range []string{"stop-at", "stop-after", "postpone", "no-stop"} {
+			if flags.Changed(name) {
+				stopOptions++
+			}
+		}
+		if stopOptions > 1 {
+			return fmt.Errorf("give only one of --stop-at, --stop-after, --postpone and --no-stop")
+		}
+
+		var inst *cloudInstance
+		if stopOptions == 0 && !flags.Changed("create-at") {
+			if inst, err = getCloudInstance(client, args[0]); err != nil {
+				return err
+			}
+		} else {
+			var req cloudSchedule
+			if cloudScheduleCreateAt != "" {
+				at, err := parseScheduleTime(cloudScheduleCreateAt)
+				if err != nil {
+					return fmt.Errorf("invalid --create-at: %w", err)
+				}
+				req.CreateAt = &at
+			}
+			if cloudScheduleStopAt != "" {
+				at, err := parseScheduleTime(cloudScheduleStopAt)
+				if err != nil {
+					return fmt.Errorf("invalid --stop-at: %w", err)
+				}
+				req.StopAt = &at
+			}
+			if flags.Changed("stop-after") {
+				req.StopAfter = cloudScheduleStopAfter.String()
+			}
+			if flags.Changed("postpone") {
+				req.Postpone = cloudSchedulePostpone.String()
+			}
+			req.NoStop = cloudScheduleNoStop
+
+			inst = &cloudInstance{}
+			endpoint := cloudBaseURL() + "/api/v1/instances/" + url.PathEscape(args[0]) + "/schedule"
+			if err := cloudSendJSON(client, http.MethodPut, endpoint, req, http.StatusOK, "update schedule", inst); err != nil {
+				return err
+			}
+			fmt.Println("✅ Schedule updated")
+			fmt.Println()
+		}
+
+		fmt.Printf("🕒 Schedule of %s (%s)\n", inst.ID, inst.Status)
+		if inst.Status == "scheduled" && inst.CreateAt != nil {
+			fmt.Printf("   Create: %s\n", formatScheduleTime(*inst.CreateAt))
+		}
+		if inst.StopAt != nil {
+			fmt.Printf("   Stop:   %s\n", formatScheduleTime(*inst.StopAt))
+		} else {
+			fmt.Println("   Stop:   none")
+		}
+		return nil
+	},
+}
+
+// parseScheduleTime parses an RFC 3339 time, a local "2006-01-02 15:04",
+// or a local "15:04" as its next occurrence
+func parseScheduleTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	clock, err := time.ParseInLocation("15:04", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time such as 19:00, 2026-05-04 19:00 or 2026-05-04T19:00:00Z", s)
+	}
+	now := time.Now()
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// formatScheduleTime shows a scheduled time locally with how far off it is
+func formatScheduleTime(t time.Time) string {
+	local := t.Local()
+	d := time.Until(t).Round(time.Minute)
+	if d <= 0 {
+		return local.Format("2006-01-02 15:04") + " (due)"
+	}
+	return fmt.Sprintf("%s (in %s)", local.Format("2006-01-02 15:04"), d)
+}
+
+func init() {
+	cloudScheduleCmd.Flags().StringVar(&cloudScheduleCreateAt, "create-at", "", "Create a scheduled instance at this time instead")
+	cloudScheduleCmd.Flags().StringVar(&cloudScheduleStopAt, "stop-at", "", "Stop the instance at this time")
+	cloudScheduleCmd.Flags().DurationVar(&cloudScheduleStopAfter, "stop-after", 0, "Stop the instance this long from now, or after it's created")
+	cloudScheduleCmd.Flags().DurationVar(&cloudSchedulePostpone, "postpone", 0, "Move the stop time this much later")
+	cloudScheduleCmd.Flags().BoolVar(&cloudScheduleNoStop, "no-stop", false, "Clear the stop time")
+	cloudCmd.AddCommand(cloudScheduleCmd)
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+)
+
+var (
+	cloudVolumeSize         int
+	cloudVolumeProvider     string
+	cloudVolumeRegion       string
+	cloudVolumeTeam         string
+	cloudVolumeAttach       string
+	cloudVolumeMount        string
+	cloudVolumeFromSnapshot string
+	cloudVolumeDescription  string
+	cloudVolumeFormat       string
+)
+
+// cloudVolume is a volume as returned by the control plane
+type cloudVolume struct {
+	ID           string    `json:"id"`
+	TeamID       *string   `json:"team_id,omitempty"`
+	Name         string    `json:"name"`
+	Provider     string    `json:"provider"`
+	Region       string    `json:"region"`
+	Zone         string    `json:"zone,omitempty"`
+	SizeGB       int       `json:"size_gb"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	InstanceID   *string   `json:"instance_id,omitempty"`
+	MountPath    string    `json:"mount_path,omitempty"`
+	Mounted      bool      `json:"mounted"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// cloudVolumeSnapshot is a volume snapshot as returned by the control plane
+type cloudVolumeSnapshot struct {
+	ID           string    `json:"id"`
+	VolumeID     string    `json:"volume_id"`
+	Method       string    `json:"method"`
+	Provider     string    `json:"provider"`
+	Region       string    `json:"region"`
+	SizeGB       int       `json:"size_gb"`
+	Description  string    `json:"description,omitempty"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+var cloudVolumeCmd = &cobra.Command{
+	Use:     "volume",
+	Aliases: []string{"volumes", "vol"},
+	Short:   "Manage persistent volumes and their snapshots",
+	Long: `Manage volumes: block storage that outlives instances and moves between
+them, within a provider's region.
+
+An attached volume is mounted on the instance, at /mnt/volumes/<name>
+unless --mount says otherwise, and again whenever its agent reconnects.
+Deleting an instance detaches its volumes.
+
+Snapshots are taken by the provider where it can (AWS EBS snapshots; make
+a volume from one with --from-snapshot). Otherwise restic backs the volume
+up from its instance into the control plane's snapshot repository, and
+'cm cloud volume restore' restores it in place.
+
+EXAMPLES
+  cm cloud volume create data --provider aws --region us-east-1 --size 50
+  cm cloud volume create data --attach <instance-id>
+  cm cloud volume attach <volume-id> <instance-id> --mount /workspace
+  cm cloud volume snapshot <volume-id> -m "before upgrade"
+  cm cloud volume detach <volume-id>
+  cm cloud volume snapshots <volume-id>
+  cm cloud volume restore <volume-id> <snapshot-id>`,
+}
+
+var cloudVolumeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List volumes",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudVolumeFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var volumes []cloudVolume
+		if err := cloudGetJSON(client, cloudBaseURL()+"/api/v1/volumes", "list volumes", &volumes); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudVolumeFormat, volumes, func() error {
+			if len(volumes) == 0 {
+				fmt.Println("No volumes.")
+				fmt.Println()
+				fmt.Println("Create one with: cm cloud volume create <name> --attach <instance-id>")
+				return nil
+			}
+			fmt.Println("💾 Volumes")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPROVIDER\tREGION\tSIZE\tSTATUS\tINSTANCE\tMOUNT")
+			for _, v := range volumes {
+				instance, mount := "-", "-"
+				if v.InstanceID != nil {
+					instance = *v.InstanceID
+					if v.Mounted {
+						mount = v.MountPath
+					} else {
+						mount = "(not mounted)"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d GB\t%s\t%s\t%s\n",
+					v.ID, v.Name, v.Provider, v.Region, v.SizeGB, v.Status, instance, mount)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudVolumeCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a volume, optionally attached to an instance",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cloudVolumeAttach == "" && cloudVolumeFromSnapshot == "" && cloudVolumeProvider == "" {
+			return fmt.Errorf("give --provider, or --attach to create the volume next to an instance")
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]interface{}{
+			"name":        args[0],
+			"provider":    cloudVolumeProvider,
+			"region":      cloudVolumeRegion,
+			"size_gb":     cloudVolumeSize,
+			"instance_id": cloudVolumeAttach,
+			"mount_path":  cloudVolumeMount,
+			"snapshot_id": cloudVolumeFromSnapshot,
+		}
+		if cloudVolumeTeam != "" {
+			req["team_id"] = cloudVolumeTeam
+		}
+		fmt.Printf("💾 Creating volume %s...\n", args[0])
+		var volume cloudVolume
+		if err := cloudSendJSON(client, http.MethodPost, cloudBaseURL()+"/api/v1/volumes", req, http.StatusCreated, "create volume", &volume); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Volume %s created (%d GB, %s %s)\n", volume.ID, volume.SizeGB, volume.Provider, volume.Region)
+		printVolumeAttachment(&volume)
+		return nil
+	},
+}
+
+var cloudVolumeAttachCmd = &cobra.Command{
+	Use:   "attach <volume-id> <instance-id>",
+	Short: "Attach a volume to an instance and mount it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]string{"instance_id": args[1], "mount_path": cloudVolumeMount}
+		var volume cloudVolume
+		if err := cloudSendJSON(client, http.MethodPost, cloudVolumeURL(args[0], "/attach"), req, http.StatusOK, "attach volume", &volume); err != nil {
+			return err
+		}
+		printVolumeAttachment(&volume)
+		return nil
+	},
+}
+
+// printVolumeAttachment tells where an attached volume is mounted
+func printVolumeAttachment(volume *cloudVolume) {
+	switch {
+	case volume.InstanceID == nil:
+	case volume.Mounted:
+		fmt.Printf("✅ Attached to %s and mounted at %s\n", *volume.InstanceID, volume.MountPath)
+	default:
+		fmt.Printf("✅ Attached to %s; %s\n", *volume.InstanceID, volume.StatusReason)
+	}
+}
+
+var cloudVolumeDetachCmd = &cobra.Command{
+	Use:   "detach <volume-id>",
+	Short: "Unmount a volume and detach it from its instance",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		if err := cloudSendJSON(client, http.MethodPost, cloudVolumeURL(args[0], "/detach"), nil, http.StatusOK, "detach volume", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Volume %s detached\n", args[0])
+		return nil
+	},
+}
+
+var cloudVolumeRmCmd = &cobra.Command{
+	Use:     "rm <volume-id>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Delete a detached volume; its snapshots are kept",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		if err := cloudSendJSON(client, http.MethodDelete, cloudVolumeURL(args[0], ""), nil, http.StatusNoContent, "delete volume", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Volume %s deleted\n", args[0])
+		return nil
+	},
+}
+
+var cloudVolumeSnapshotCmd = &cobra.Command{
+	Use:   "snapshot <volume-id>",
+	Short: "Snapshot a volume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(map[string]string{"description": cloudVolumeDescription})
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(cloudVolumeURL(args[0], "/snapshots"), "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		// Provider snapshots are taken at once, restic ones in the background
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+			return fmt.Errorf("failed to snapshot volume: %s", cloudErrorMessage(resp))
+		}
+		var snapshot cloudVolumeSnapshot
+		if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+			return fmt.Errorf("failed to snapshot volume: %w", err)
+		}
+		if snapshot.Status == "completed" {
+			fmt.Printf("✅ Snapshot %s taken\n", snapshot.ID)
+			return nil
+		}
+		fmt.Printf("📸 Snapshot %s is being taken from the volume's instance\n", snapshot.ID)
+		fmt.Printf("Follow it with: cm cloud volume snapshots %s\n", args[0])
+		return nil
+	},
+}
+
+var cloudVolumeSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots [volume-id]",
+	Short: "List snapshots, of all volumes or one",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudVolumeFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/snapshots"
+		if len(args) == 1 {
+			endpoint += "?volume_id=" + url.QueryEscape(args[0])
+		}
+		var snapshots []cloudVolumeSnapshot
+		if err := cloudGetJSON(client, endpoint, "list snapshots", &snapshots); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudVolumeFormat, snapshots, func() error {
+			if len(snapshots) == 0 {
+				fmt.Println("No snapshots.")
+				return nil
+			}
+			fmt.Println("📸 Snapshots")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tVOLUME\tMETHOD\tSTATUS\tCREATED\tDESCRIPTION")
+			for _, s := range snapshots {
+				status := s.Status
+				if s.StatusReason != "" {
+					status += ": " + s.StatusReason
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.VolumeID, s.Method, status, formatAge(s.CreatedAt), s.Description)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudVolumeRestoreCmd = &cobra.Command{
+	Use:   "restore <volume-id> <snapshot-id>",
+	Short: "Restore a restic snapshot into its volume",
+	Long: `Restore a restic snapshot into the volume it was taken of, over its
+current files. Files created since the snapshot are kept.
+
+Provider snapshots are restored by creating a volume from them:
+  cm cloud volume create <name> --from-snapshot <snapshot-id>`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]string{"snapshot_id": args[1]}
+		if err := cloudSendJSON(client, http.MethodPost, cloudVolumeURL(args[0], "/restore"), req, http.StatusAccepted, "restore snapshot", nil); err != nil {
+			return err
+		}
+		fmt.Printf("♻️  Restoring %s into %s\n", args[1], args[0])
+		fmt.Println("The volume's status returns to in-use when it's done: cm cloud volume list")
+		return nil
+	},
+}
+
+var cloudVolumeRmSnapshotCmd = &cobra.Command{
+	Use:   "rm-snapshot <snapshot-id>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/snapshots/" + url.PathEscape(args[0])
+		if err := cloudSendJSON(client, http.MethodDelete, endpoint, nil, http.StatusNoContent, "delete snapshot", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Snapshot %s deleted\n", args[0])
+		return nil
+	},
+}
+
+func cloudVolumeURL(id, action string) string {
+	return cloudBaseURL() + "/api/v1/volumes/" + url.PathEscape(id) + action
+}
+
+func init() {
+	cloudVolumeCreateCmd.Flags().IntVar(&cloudVolumeSize, "size", 0, "Size in GB (default 10, or the snapshot's)")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeProvider, "provider", "", "Cloud provider (default: the --attach instance's)")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeRegion, "region", "", "Region (default: the --attach instance's)")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeTeam, "team", "", "Create the volume in this team")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeAttach, "attach", "", "Attach the volume to this instance")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeFromSnapshot, "from-snapshot", "", "Create the volume from this provider snapshot")
+	cloudVolumeCreateCmd.Flags().StringVar(&cloudVolumeMount, "mount", "", "Mount path on the instance (default /mnt/volumes/<name>)")
+	cloudVolumeAttachCmd.Flags().StringVar(&cloudVolumeMount, "mount", "", "Mount path on the instance (default /mnt/volumes/<name>)")
+	cloudVolumeSnapshotCmd.Flags().StringVarP(&cloudVolumeDescription, "message", "m", "", "Describe the snapshot")
+	cloudVolumeListCmd.Flags().StringVar(&cloudVolumeFormat, "format", "", output.FlagUsage)
+	cloudVolumeSnapshotsCmd.Flags().StringVar(&cloudVolumeFormat, "format", "", output.FlagUsage)
+	cloudVolumeCmd.AddCommand(cloudVolumeListCmd, cloudVolumeCreateCmd, cloudVolumeAttachCmd, cloudVolumeDetachCmd, cloudVolumeRmCmd,
+		cloudVolumeSnapshotCmd, cloudVolumeSnapshotsCmd, cloudVolumeRestoreCmd, cloudVolumeRmSnapshotCmd)
+	cloudCmd.AddCommand(cloudVolumeCmd)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+)
+
+// cloudEventsPollInterval is how often cm cloud events -f asks for new events
+const cloudEventsPollInterval = 5 * time.Second
+
+var (
+	cloudWebhookTeam        string
+	cloudWebhookEvents      []string
+	cloudWebhookDescription string
+	cloudWebhookFormat      string
+
+	cloudEventsTeam   string
+	cloudEventsTypes  []string
+	cloudEventsCursor string
+	cloudEventsFollow bool
+	cloudEventsFormat string
+)
+
+// cloudWebhook is a webhook as returned by the control plane
+type cloudWebhook struct {
+	ID          string    `json:"id"`
+	TeamID      *string   `json:"team_id,omitempty"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// cloudWebhookDelivery is a webhook delivery as returned by the control plane
+type cloudWebhookDelivery struct {
+	ID            string     `json:"id"`
+	EventID       int64      `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	ResponseCode  int        `json:"response_code,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// cloudEvent is an event as returned by the control plane
+type cloudEvent struct {
+	ID         int64                  `json:"id"`
+	Type       string                 `json:"type"`
+	TeamID     *string                `json:"team_id,omitempty"`
+	InstanceID string                 `json:"instance_id,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+	Text       string                 `json:"text"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+var cloudWebhookCmd = &cobra.Command{
+	Use:     "webhook",
+	Aliases: []string{"webhooks"},
+	Short:   "Manage webhooks for instance and budget events",
+	Long: `Manage webhooks that receive a signed JSON POST for each event about your
+instances and budget, or with --team a team's.
+
+Events: instance.created, instance.ready, instance.stopped, budget.exceeded.
+The payload's "text" field makes it a valid Slack or Mattermost message.
+Each request carries X-CM-Event, X-CM-Delivery and
+X-CM-Signature: t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>"> keyed
+with the secret shown once when the webhook is added. Failed deliveries are
+retried for about 9 hours.
+
+EXAMPLES
+  cm cloud webhook add https://hooks.slack.com/services/...
+  cm cloud webhook add https://ci.example.com/hook --event instance.ready --team <team-id>
+  cm cloud webhook list
+  cm cloud webhook ping <id>
+  cm cloud webhook deliveries <id>
+  cm cloud webhook rm <id>`,
+}
+
+var cloudWebhookListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List webhooks",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudWebhookFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		endpoint := cloudBaseURL() + "/api/v1/webhooks"
+		if cloudWebhookTeam != "" {
+			endpoint += "?team_id=" + url.QueryEscape(cloudWebhookTeam)
+		}
+		var webhooks []cloudWebhook
+		if err := cloudGetJSON(client, endpoint, "list webhooks", &webhooks); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudWebhookFormat, webhooks, func() error {
+			if len(webhooks) == 0 {
+				fmt.Println("No webhooks.")
+				fmt.Println()
+				fmt.Println("Add one with: cm cloud webhook add <url>")
+				return nil
+			}
+			fmt.Println("🪝 Webhooks")
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tURL\tEVENTS\tACTIVE")
+			for _, wh := range webhooks {
+				events := strings.Join(wh.Events, ",")
+				if events == "" {
+					events = "all"
+				}
+				active := "✅"
+				if !wh.Active {
+					active = "❌"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", wh.ID, wh.URL, events, active)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudWebhookAddCmd = &cobra.Command{
+	Use:   "add <url>",
+	Short: "Add a webhook",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		req := map[string]interface{}{
+			"url":         args[0],
+			"description": cloudWebhookDescription,
+			"events":      cloudWebhookEvents,
+		}
+		if cloudWebhookTeam != "" {
+			req["team_id"] = cloudWebhookTeam
+		}
+		var webhook cloudWebhook
+		if err := cloudSendJSON(client, http.MethodPost, cloudBaseURL()+"/api/v1/webhooks", req, http.StatusCreated, "add webhook", &webhook); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Webhook %s added\n", webhook.ID)
+		fmt.Println()
+		fmt.Printf("   Signing secret: %s\n", webhook.Secret)
+		fmt.Println("   Save it now; it isn't shown again. Verify X-CM-Signature with it.")
+		fmt.Println()
+		fmt.Printf("Test it with: cm cloud webhook ping %s\n", webhook.ID)
+		return nil
+	},
+}
+
+var cloudWebhookRmCmd = &cobra.Command{
+	Use:     "rm <id>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Remove a webhook",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		if err := cloudSendJSON(client, http.MethodDelete, cloudWebhookURL(args[0], ""), nil, http.StatusNoContent, "remove webhook", nil); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Webhook %s removed\n", args[0])
+		return nil
+	},
+}
+
+var cloudWebhookPingCmd = &cobra.Command{
+	Use:   "ping <id>",
+	Short: "Send a webhook a test event",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Delivered bool   `json:"delivered"`
+			Error     string `json:"error"`
+		}
+		if err := cloudSendJSON(client, http.MethodPost, cloudWebhookURL(args[0], "/ping"), nil, http.StatusOK, "ping webhook", &result); err != nil {
+			return err
+		}
+		if !result.Delivered {
+			return fmt.Errorf("webhook test failed: %s", result.Error)
+		}
+		fmt.Println("✅ Endpoint accepted the test event")
+		return nil
+	},
+}
+
+var cloudWebhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries <id>",
+	Short: "Show a webhook's recent deliveries",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudWebhookFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+		var deliveries []cloudWebhookDelivery
+		if err := cloudGetJSON(client, cloudWebhookURL(args[0], "/deliveries"), "list deliveries", &deliveries); err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, cloudWebhookFormat, deliveries, func() error {
+			if len(deliveries) == 0 {
+				fmt.Println("No deliveries yet.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "EVENT\tTYPE\tSTATUS\tATTEMPTS\tCODE\tAGE\tERROR")
+			for _, d := range deliveries {
+				status := d.Status
+				if d.Status == "pending" && d.NextAttemptAt != nil {
+					status += ", retry at " + d.NextAttemptAt.Local().Format("15:04")
+				}
+				code := "-"
+				if d.ResponseCode != 0 {
+					code = fmt.Sprint(d.ResponseCode)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+					d.EventID, d.EventType, status, d.Attempts, code, formatAge(d.CreatedAt), valueOrDash(d.LastError))
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var cloudEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show instance and budget events",
+	Long: `Show events about your instances and budget and your teams', oldest first.
+
+Each event has an increasing ID; --after continues after one, and -f keeps
+polling for new events. For scripts, --format '{{json .}}' prints one JSON
+object per line.
+
+EXAMPLES
+  cm cloud events                                  # Recent events
+  cm cloud events -f                               # Follow new events
+  cm cloud events --type instance.stopped --team <team-id>
+  cm cloud events -f --format '{{json .}}' | my-bot`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(cloudEventsFormat); err != nil {
+			return err
+		}
+		client, err := getCloudClient()
+		if err != nil {
+			return err
+		}
+
+		cursor := cloudEventsCursor
+		header := true
+		for {
+			query := url.Values{}
+			if cursor != "" {
+				query.Set("cursor", cursor)
+			}
+			if cloudEventsTeam != "" {
+				query.Set("team_id", cloudEventsTeam)
+			}
+			if len(cloudEventsTypes) > 0 {
+				query.Set("type", strings.Join(cloudEventsTypes, ","))
+			}
+			var page struct {
+				Events     []cloudEvent `json:"events"`
+				NextCursor string       `json:"next_cursor"`
+				HasMore    bool         `json:"has_more"`
+			}
+			if err := cloudGetJSON(client, cloudBaseURL()+"/api/v1/events?"+query.Encode(), "list events", &page); err != nil {
+				return err
+			}
+			cursor = page.NextCursor
+
+			if len(page.Events) > 0 || (!cloudEventsFollow && header) {
+				err := output.Print(os.Stdout, cloudEventsFormat, page.Events, func() error {
+					if len(page.Events) == 0 {
+						fmt.Println("No events.")
+						return nil
+					}
+					w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+					if header {
+						fmt.Fprintln(w, "ID\tTIME\tTYPE\tEVENT")
+					}
+					for _, e := range page.Events {
+						fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.ID, e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.Type, e.Text)
+					}
+					header = false
+					return w.Flush()
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if page.HasMore {
+				continue
+			}
+			if !cloudEventsFollow {
+				return nil
+			}
+			time.Sleep(cloudEventsPollInterval)
+		}
+	},
+}
+
+// cloudWebhookURL returns the URL of a webhook, or of one of its actions
+func cloudWebhookURL(id, action string) string {
+	return cloudBaseURL() + "/api/v1/webhooks/" + url.PathEscape(id) + action
+}
+
+// cloudGetJSON GETs a control plane endpoint into out
+func cloudGetJSON(client *http.Client, endpoint, what string, out interface{}) error {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s: %s", what, cloudErrorMessage(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to %s: %w", what, err)
+	}
+	return nil
+}
+
+// cloudSendJSON sends a request with an optional JSON body to a control
+// plane endpoint and decodes the response into out, if given
+func cloudSendJSON(client *http.Client, method, endpoint string, body interface{}, want int, what string, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		return fmt.Errorf("failed to %s: %s", what, cloudErrorMessage(resp))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to %s: %w", what, err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	cloudWebhookCmd.PersistentFlags().StringVar(&cloudWebhookTeam, "team", "", "A team's webhooks (team owners and admins)")
+	cloudWebhookAddCmd.Flags().StringSliceVar(&cloudWebhookEvents, "event", nil, "Event type to send (repeatable; every type if omitted)")
+	cloudWebhookAddCmd.Flags().StringVar(&cloudWebhookDescription, "description", "", "What the webhook is for")
+	cloudWebhookListCmd.Flags().StringVar(&cloudWebhookFormat, "format", "", output.FlagUsage)
+	cloudWebhookDeliveriesCmd.Flags().StringVar(&cloudWebhookFormat, "format", "", output.FlagUsage)
+	cloudWebhookCmd.AddCommand(cloudWebhookListCmd, cloudWebhookAddCmd, cloudWebhookRmCmd, cloudWebhookPingCmd, cloudWebhookDeliveriesCmd)
+	cloudCmd.AddCommand(cloudWebhookCmd)
+
+	cloudEventsCmd.Flags().StringVar(&cloudEventsTeam, "team", "", "Only this team's events")
+	cloudEventsCmd.Flags().StringSliceVar(&cloudEventsTypes, "type", nil, "Only events of this type (repeatable)")
+	cloudEventsCmd.Flags().StringVar(&cloudEventsCursor, "after", "", "Only events after this event ID")
+	cloudEventsCmd.Flags().BoolVarP(&cloudEventsFollow, "follow", "f", false, "Keep polling for new events")
+	cloudEventsCmd.Flags().StringVar(&cloudEventsFormat, "format", "", output.FlagUsage)
+	cloudCmd.AddCommand(cloudEventsCmd)
+}
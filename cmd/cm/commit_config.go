@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/drift"
+	"github.com/spf13/cobra"
+)
+
+var (
+	commitConfigDryRun bool
+	commitConfigYes    bool
+)
+
+var commitConfigCmd = &cobra.Command{
+	Use:   "commit-config",
+	Short: "Add packages installed in the dev container to its Dockerfile",
+	Long: `Bake packages installed by hand in the persistent container back into
+the project's configuration, so the next rebuild has them too.
+
+The packages installed or removed with apt, apk, pip and npm since the
+container was created (see 'cm diff') become RUN lines appended to the
+Dockerfile, leaving out those installed only as dependencies. A config
+using an image gets a Dockerfile built FROM that image instead, and its
+devcontainer.json builds it. Docker Compose configs aren't supported.
+
+The changes are shown as a patch to review before they are applied;
+with --dry-run, only the patch is printed, ready for git apply.
+
+EXAMPLES
+  cm commit-config
+  cm commit-config --dry-run > drift.patch
+  cm commit-config --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := configFile
+		if configPath == "" {
+			for _, path := range []string{".devcontainer/devcontainer.json", "devcontainer.json"} {
+				if _, err := os.Stat(path); err == nil {
+					configPath = path
+					break
+				}
+			}
+			if configPath == "" {
+				return fmt.Errorf("no devcontainer.json found; create one with 'cm init'")
+			}
+			configFile = configPath
+		}
+		cfg, projectDir, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		report, err := collectDrift(cfg, projectDir)
+		if err != nil {
+			return err
+		}
+		edits, err := drift.CommitEdits(report, cfg, configPath)
+		if err != nil {
+			return err
+		}
+		if len(edits) == 0 {
+			fmt.Fprintln(os.Stderr, "✅ No packages were installed or removed in the container; nothing to commit")
+			if len(report.Changes) > 0 {
+				fmt.Fprintln(os.Stderr, "   Other files changed; 'cm diff' lists them")
+			}
+			return nil
+		}
+
+		patch := drift.UnifiedDiff(edits, projectDir)
+		if commitConfigDryRun {
+			fmt.Print(patch)
+			return nil
+		}
+		fmt.Print(patch)
+		fmt.Println()
+		if !commitConfigYes {
+			fmt.Print("Apply this patch? [y/N] ")
+			var response string
+			_, _ = fmt.Scanln(&response)
+			if strings.ToLower(response) != "y" {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+		if err := drift.Apply(edits); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Updated %d file(s); rebuild with 'cm shell --rebuild' to check the image\n", len(edits))
+		return nil
+	},
+}
+
+func init() {
+	commitConfigCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	commitConfigCmd.Flags().BoolVar(&commitConfigDryRun, "dry-run", false, "Print the patch without applying it")
+	commitConfigCmd.Flags().BoolVarP(&commitConfigYes, "yes", "y", false, "Apply without asking")
+	rootCmd.AddCommand(commitConfigCmd)
+}
@@ -1,15 +1,32 @@
 package main
 
 import (
+	"context"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/environment"
+	"github.com/UPwith-me/Container-Maker/pkg/images"
+	mkpkg "github.com/UPwith-me/Container-Maker/pkg/make"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
 var completionCmd = &cobra.Command{
 	Use:   "completion [bash|zsh|fish|powershell]",
 	Short: "Generate completion script",
-	Long: `To load completions:
+	Long: `Generate a shell completion script.
+
+Besides commands and flags, completions know your resources: environment
+names, templates, image presets, backends, Makefile targets and workspace
+or Docker Compose services.
+
+To load completions:
 
 Bash:
   $ source <(cm completion bash)
@@ -64,3 +81,183 @@ PowerShell:
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
+
+// Dynamic completions for the arguments and flags that name the user's
+// resources. Errors just leave the list empty; completion must never fail
+// loudly.
+
+// completeEnvNames completes environment names, skipping ones already given
+func completeEnvNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	mgr, err := environment.NewManager()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	envs, err := mgr.List(ctx, environment.EnvironmentListOptions{All: true})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, env := range envs {
+		names = append(names, env.Name+"\t"+string(env.Status))
+	}
+	return withoutArgs(names, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeEnvName completes a single environment name
+func completeEnvName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeEnvNames(cmd, args, toComplete)
+}
+
+// completeEnvPair completes the two environments of 'cm env link/unlink'
+func completeEnvPair(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeEnvNames(cmd, args, toComplete)
+}
+
+// completeTemplateNames completes built-in and custom template names
+func completeTemplateNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name, t := range template.GetAllTemplates() {
+		names = append(names, name+"\t"+t.Description)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeImagePresets completes preset and custom image names
+func completeImagePresets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg, err := images.LoadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, presets := range []map[string]*images.PresetImage{cfg.Org, cfg.Presets, cfg.Custom} {
+		for name, p := range presets {
+			names = append(names, name+"\t"+p.Image)
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBackends completes the names of detected container backends
+func completeBackends(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, b := range runtime.NewDetector().Detect().Backends {
+		desc := b.Type
+		if b.Version != "" {
+			desc += " " + b.Version
+		}
+		names = append(names, b.Name+"\t"+desc)
+	}
+	distros, _ := runtime.ListWSLDistros()
+	for _, d := range distros {
+		names = append(names, runtime.WSLPrefix+d.Name+"\tWSL distribution")
+	}
+	return withoutDuplicates(names), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeMakeTargets completes targets of the Makefile in the current
+// directory, with their ## descriptions
+func completeMakeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cwd, _ := os.Getwd()
+	path, err := mkpkg.FindMakefile(cwd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	info, err := mkpkg.ParseMakefile(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var targets []string
+	for _, t := range info.Targets {
+		if t.Description != "" {
+			targets = append(targets, t.Name+"\t"+t.Description)
+		} else {
+			targets = append(targets, t.Name)
+		}
+	}
+	return withoutArgs(targets, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeServices completes the services of cm-workspace.yaml or, without
+// one, of the devcontainer's Docker Compose file
+func completeServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var services []string
+	if ws, err := workspace.Load(""); err == nil {
+		for name := range ws.Services {
+			services = append(services, name)
+		}
+		sort.Strings(services)
+	} else if cfg, projectDir, err := findDevConfig(); err == nil && cfg.DockerComposeFile != nil {
+		if cr, err := runner.NewComposeRunner(cfg, projectDir); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			services, _ = cr.ListServices(ctx)
+		}
+	}
+	return withoutArgs(services, args), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeContainers completes the containers that may belong to the
+// current project, for --container
+func completeContainers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	if cfg, projectDir, err := findDevConfig(); err == nil {
+		if pr, err := runner.NewPersistentRunner(cfg, projectDir); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			candidates, _ := pr.Candidates(ctx)
+			for _, c := range candidates {
+				names = append(names, c.Name+"\t"+c.Status+" "+c.Image)
+			}
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// withoutDuplicates keeps the first candidate of each name
+func withoutDuplicates(candidates []string) []string {
+	seen := make(map[string]bool, len(candidates))
+	var out []string
+	for _, c := range candidates {
+		name, _, _ := strings.Cut(c, "\t")
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// withoutArgs drops candidates already on the command line
+func withoutArgs(candidates, args []string) []string {
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+	var out []string
+	for _, c := range candidates {
+		name, _, _ := strings.Cut(c, "\t")
+		if !given[name] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
+	"strings"
 
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/i18n"
 	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
 	"github.com/spf13/cobra"
 )
@@ -23,12 +27,35 @@ var configListCmd = &cobra.Command{
 		keys := []string{
 			"skip_welcome",
 			"default_backend",
+			"locale",
 			"ai.enabled",
+			"ai.provider",
 			"ai.api_base",
 			"ai.model",
+			"ai.embed_model",
+			"ai.api_version",
 			"ai.api_key", // We will mask this
 			"analytics.enabled",
+			"analytics.endpoint",
 			"team.org_name",
+			"marketplace.index_url",
+			"marketplace.public_key",
+			"proxy.http",
+			"proxy.https",
+			"proxy.no_proxy",
+			"policy.source",
+			"verify.strict",
+			"ports.remap",
+			"ports.https",
+			"host.no_timezone",
+			"host.no_locale",
+			"host.ca_certs",
+			"host.no_tool_versions",
+			"env.hosts",
+			"share.relay",
+			"share.token",
+			"stats.idle_pause_minutes",
+			"update.channel",
 		}
 		sort.Strings(keys)
 
@@ -71,11 +98,15 @@ var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
 	Example: `  cm config set ai.model gpt-4
-  cm config set ai.enabled true`,
+  cm config set ai.enabled true
+  cm config set locale zh`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		key := args[0]
 		val := args[1]
+		if key == "locale" && val != "auto" && i18n.Normalize(val) == "" {
+			return fmt.Errorf("unsupported locale %q: use %s or auto", val, strings.Join(i18n.Supported(), ", "))
+		}
 		if err := userconfig.Set(key, val); err != nil {
 			return err
 		}
@@ -84,7 +115,112 @@ var configSetCmd = &cobra.Command{
 	},
 }
 
+var (
+	configProxyHTTPS   string
+	configProxyNoProxy string
+)
+
+var configProxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Show or change the proxy used for downloads and API calls",
+	Long: `Show the proxy cm uses for registries, the marketplace, AI providers and
+update checks.
+
+The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables (upper or
+lower case) take precedence; the settings saved here apply when they are
+not set. Docker pulls use the Docker daemon's own proxy configuration.`,
+	Example: `  cm config proxy
+  cm config proxy set http://proxy.corp:3128 --no-proxy localhost,.corp,10.0.0.0/8
+  cm config proxy unset`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := userconfig.Load()
+		if err != nil {
+			return err
+		}
+		effective := httpclient.ProxySettings()
+
+		fmt.Println("Proxy Configuration:")
+		fmt.Println("--------------------")
+		rows := []struct {
+			name, env, saved, value string
+		}{
+			{"http", "HTTP_PROXY", cfg.Proxy.HTTPProxy, effective.HTTPProxy},
+			{"https", "HTTPS_PROXY", cfg.Proxy.HTTPSProxy, effective.HTTPSProxy},
+			{"no_proxy", "NO_PROXY", cfg.Proxy.NoProxy, effective.NoProxy},
+		}
+		for _, r := range rows {
+			source := ""
+			switch {
+			case r.value == "":
+				r.value = "(unset)"
+			case r.value != r.saved:
+				source = " (from " + r.env + ")"
+			}
+			fmt.Printf("%-10s : %s%s\n", r.name, r.value, source)
+		}
+		return nil
+	},
+}
+
+var configProxySetCmd = &cobra.Command{
+	Use:   "set <url>",
+	Short: "Save a proxy for HTTP and HTTPS requests",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		httpsProxy := configProxyHTTPS
+		if httpsProxy == "" {
+			httpsProxy = args[0]
+		}
+		for _, p := range []string{args[0], httpsProxy} {
+			if u, err := url.Parse(p); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid proxy URL %q, expected e.g. http://proxy.corp:3128", p)
+			}
+		}
+
+		settings := map[string]string{
+			"proxy.http":     args[0],
+			"proxy.https":    httpsProxy,
+			"proxy.no_proxy": configProxyNoProxy,
+		}
+		for _, key := range []string{"proxy.http", "proxy.https", "proxy.no_proxy"} {
+			if err := userconfig.Set(key, settings[key]); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("✅ Proxy set to %s\n", args[0])
+		if httpsProxy != args[0] {
+			fmt.Printf("   HTTPS requests use %s\n", httpsProxy)
+		}
+		if configProxyNoProxy != "" {
+			fmt.Printf("   Bypassed for %s\n", configProxyNoProxy)
+		}
+		return nil
+	},
+}
+
+var configProxyUnsetCmd = &cobra.Command{
+	Use:   "unset",
+	Short: "Remove the saved proxy",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, key := range []string{"proxy.http", "proxy.https", "proxy.no_proxy"} {
+			if err := userconfig.Set(key, ""); err != nil {
+				return err
+			}
+		}
+		fmt.Println("✅ Saved proxy removed")
+		return nil
+	},
+}
+
 func init() {
+	configProxySetCmd.Flags().StringVar(&configProxyHTTPS, "https", "", "Different proxy for HTTPS requests")
+	configProxySetCmd.Flags().StringVar(&configProxyNoProxy, "no-proxy", "", "Comma-separated hosts, domains and CIDRs to reach directly")
+	configProxyCmd.AddCommand(configProxySetCmd)
+	configProxyCmd.AddCommand(configProxyUnsetCmd)
+
+	configCmd.AddCommand(configProxyCmd)
 	configCmd.AddCommand(configListCmd)
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/UPwith-me/Container-Maker/pkg/debugger"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugPort   int
+	debugModule string
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug [lang] [program] [-- args...]",
+	Short: "Run the project under its debugger with the debug port forwarded",
+	Long: `Run the project's program under its language's debugger in the persistent
+container, forward the debugger's port to the host and print editor
+configurations that attach to it.
+
+Each debugger listens on its conventional port in the container:
+
+  go       delve, headless          2345   program: a package (default ".")
+  python   debugpy, waits to attach 5678   program: a script or -m module (default main.py)
+  node     node --inspect-brk       9229   program: a script (default index.js)
+
+Without a language, it's told from go.mod, package.json or the Python
+project files. delve and debugpy are installed in the container when
+missing. A port in forwardPorts is used as published; otherwise a socat
+sidecar (CM_FORWARD_IMAGE, default alpine/socat) publishes the first free
+host port from the same number on 127.0.0.1, until the program exits.
+
+EXAMPLES
+  cm debug                             # Detect the language
+  cm debug go ./cmd/api -- --verbose
+  cm debug python -m flask -- run
+  cm debug node server.js --port 9230`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runDebug,
+}
+
+func init() {
+	debugCmd.Flags().IntVar(&debugPort, "port", 0, "Debugger port in the container (default: the language's)")
+	debugCmd.Flags().StringVarP(&debugModule, "module", "m", "", "Python module to run instead of a script")
+	rootCmd.AddCommand(debugCmd)
+}
+
+func runDebug(cmd *cobra.Command, args []string) error {
+	var programArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args, programArgs = args[:dash], args[dash:]
+	}
+
+	cfg, projectDir, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var d *debugger.Debugger
+	if len(args) > 0 {
+		d, err = debugger.Get(args[0])
+		args = args[1:]
+	} else {
+		d, err = debugger.Detect(projectDir)
+	}
+	if err != nil {
+		return err
+	}
+	program := ""
+	switch {
+	case len(args) > 1 || len(args) == 1 && debugModule != "":
+		return fmt.Errorf("give one program to debug, and its arguments after --")
+	case debugModule != "":
+		program = "-m " + debugModule
+	case len(args) == 1:
+		program = args[0]
+	}
+	port := d.Port
+	if debugPort != 0 {
+		port = debugPort
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+	containerID, err := pr.EnsureContainer(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if d.Install != "" && !inContainer(ctx, pr.BackendCommand(), containerID, d.Check, io.Discard) {
+		fmt.Printf("📦 Installing %s...\n", d.Name)
+		if !inContainer(ctx, pr.BackendCommand(), containerID, d.Install, os.Stderr) {
+			return fmt.Errorf("failed to install %s: %s", d.Name, d.Install)
+		}
+	}
+
+	hostPort, stop, err := pr.ForwardPort(ctx, containerID, port)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	fmt.Printf("🐞 %s on 127.0.0.1:%d (port %d in the container)\n\n", d.Name, hostPort, port)
+	fmt.Println("VS Code, in .vscode/launch.json \"configurations\":")
+	fmt.Println(d.VSCodeLaunch(hostPort, pr.WorkspaceDir()))
+	fmt.Println()
+	fmt.Println(d.Hint(hostPort))
+	fmt.Println()
+
+	return pr.Exec(ctx, d.Command(program, port, programArgs))
+}
+
+// inContainer runs a shell command in the container and reports whether it
+// succeeded
+func inContainer(ctx context.Context, backend, containerID, script string, output io.Writer) bool {
+	c := exec.CommandContext(ctx, backend, "exec", containerID, "sh", "-c", script)
+	c.Stdout, c.Stderr = output, output
+	return c.Run() == nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/drift"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffJSON bool
+	diffAll  bool
+)
+
+// diffMaxChanges is how many other file changes are listed without --all
+const diffMaxChanges = 30
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how the dev container has drifted from its image",
+	Long: `Show what changed in the project's persistent container since it was
+created from its image, and how to make those changes part of the image.
+
+Packages installed or removed with apt, apk, pip and npm (global) are
+listed by name and version, with those installed only as dependencies
+marked. The other files added, changed or deleted follow, as docker diff
+reports them but without the files of those packages, the parent
+directories of changes, and caches, logs, shell histories and temporary
+files.
+
+The suggested Dockerfile lines reinstall the packages, leaving out
+dependencies. For a config using an image rather than a Dockerfile, they
+come with the Dockerfile and devcontainer.json changes to build it.
+Nothing is changed by this command.
+
+EXAMPLES
+  cm diff
+  cm diff --all
+  cm diff --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, projectDir, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		report, err := collectDrift(cfg, projectDir)
+		if err != nil {
+			return err
+		}
+
+		if diffJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				*drift.Report
+				Dockerfile []string `json:"dockerfile"`
+			}{report, report.DockerfileLines()})
+		}
+		printDrift(report, cfg, projectDir)
+		return nil
+	},
+}
+
+// collectDrift compares the project's persistent container with its image
+func collectDrift(cfg *config.DevContainerConfig, projectDir string) (*drift.Report, error) {
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	_, containerID, err := pr.IsContainerRunning(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if containerID == "" {
+		return nil, fmt.Errorf("the project has no dev container; start it with 'cm shell'")
+	}
+
+	report, err := drift.Collect(ctx, pr.BackendCommand(), containerID)
+	if err != nil {
+		return nil, err
+	}
+	report.Container = pr.GetContainerName()
+	return report, nil
+}
+
+func printDrift(report *drift.Report, cfg *config.DevContainerConfig, projectDir string) {
+	if report.Empty() {
+		fmt.Printf("✅ %s matches its image %s\n", report.Container, report.Image)
+		if report.Ignored > 0 {
+			fmt.Printf("   (%d changes to caches, logs and temporary files not shown)\n", report.Ignored)
+		}
+		return
+	}
+	fmt.Printf("🔍 %s has drifted from its image %s\n", report.Container, report.Image)
+
+	printPackages := func(title string, packages []drift.Package) {
+		if len(packages) == 0 {
+			return
+		}
+		fmt.Println()
+		fmt.Printf("📦 %s:\n", title)
+		for _, p := range packages {
+			line := fmt.Sprintf("   %-4s %s", p.Manager, p.Name)
+			switch {
+			case p.From != "":
+				line += fmt.Sprintf(" %s → %s (upgraded)", p.From, p.Version)
+			case p.Version != "":
+				line += " " + p.Version
+			}
+			if p.Dependency {
+				line += " (dependency)"
+			}
+			fmt.Println(line)
+		}
+	}
+	printPackages("Packages installed", report.Installed)
+	printPackages("Packages removed", report.Removed)
+
+	if len(report.Changes) > 0 {
+		fmt.Println()
+		fmt.Println("📄 Other changes:")
+		shown := report.Changes
+		if !diffAll && len(shown) > diffMaxChanges {
+			shown = shown[:diffMaxChanges]
+		}
+		for _, c := range shown {
+			fmt.Printf("   %s %s\n", c.Kind, c.Path)
+		}
+		if more := len(report.Changes) - len(shown); more > 0 {
+			fmt.Printf("   ... and %d more (--all lists them)\n", more)
+		}
+	}
+	if report.Ignored > 0 {
+		fmt.Printf("   (%d changes to caches, logs and temporary files not shown)\n", report.Ignored)
+	}
+
+	if lines := report.DockerfileLines(); len(lines) > 0 {
+		fmt.Println()
+		printDriftSuggestion(lines, cfg, projectDir)
+	}
+	if len(report.Changes) > 0 {
+		fmt.Println()
+		fmt.Println("💡 Other files can't be reproduced automatically: COPY the ones you need")
+		fmt.Println("   from the build context, or create them in postCreateCommand.")
+	}
+}
+
+// printDriftSuggestion prints where the Dockerfile lines making the drift
+// reproducible go
+func printDriftSuggestion(lines []string, cfg *config.DevContainerConfig, projectDir string) {
+	configDir := cfg.ConfigDir
+	if configDir == "" {
+		configDir = filepath.Join(projectDir, ".devcontainer")
+	}
+	relative := func(path string) string {
+		if rel, err := filepath.Rel(projectDir, path); err == nil {
+			return rel
+		}
+		return path
+	}
+	printLines := func() {
+		for _, line := range lines {
+			fmt.Println("   " + strings.ReplaceAll(line, "\n", "\n   "))
+		}
+	}
+
+	switch {
+	case cfg.Build != nil:
+		dockerfile := cfg.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		fmt.Printf("💡 To make it reproducible, add to %s:\n", relative(filepath.Join(configDir, dockerfile)))
+		printLines()
+	case runner.IsComposeConfig(cfg):
+		fmt.Printf("💡 To make it reproducible, add to the Dockerfile of the %s service:\n", cfg.Service)
+		printLines()
+	default:
+		fmt.Printf("💡 To make it reproducible, create %s:\n", relative(filepath.Join(configDir, "Dockerfile")))
+		fmt.Println("   FROM " + cfg.Image)
+		printLines()
+		fmt.Println("   and build it instead of the image in devcontainer.json:")
+		fmt.Println(`   "build": { "dockerfile": "Dockerfile" }`)
+	}
+	if !runner.IsComposeConfig(cfg) {
+		fmt.Println("   ('cm commit-config' makes these changes for you)")
+	}
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output as JSON")
+	diffCmd.Flags().BoolVar(&diffAll, "all", false, "List every other change")
+	rootCmd.AddCommand(diffCmd)
+}
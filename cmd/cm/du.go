@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/diskusage"
+	"github.com/spf13/cobra"
+)
+
+var duJSON bool
+
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show disk space used by each project's images, snapshots and volumes",
+	Long: `Show how much disk space cm uses per project.
+
+Images, pause snapshots, containers and volumes are attributed to projects
+by the cm.project label, or by cm's naming scheme for objects created
+before cm labelled them. Image sizes count only layers not shared with
+other images. Projects are sorted by size, followed by package caches
+shared by all projects, untagged images and the build cache.
+
+Suggestions list what can be removed safely, such as objects of projects
+whose directory is gone and snapshots of containers that are no longer
+paused. Nothing is removed by this command.
+
+EXAMPLES
+  cm du
+  cm du --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := diskusage.Collect(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if duJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				*diskusage.Report
+				Suggestions []diskusage.Suggestion `json:"suggestions"`
+			}{report, report.Suggestions()})
+		}
+
+		printDiskUsage(report)
+		return nil
+	},
+}
+
+func printDiskUsage(report *diskusage.Report) {
+	fmt.Println("💾 Disk usage by project")
+	fmt.Println()
+
+	size := func(n int64) string {
+		if n == 0 {
+			return "-"
+		}
+		return diskusage.FormatSize(n)
+	}
+
+	fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", "PROJECT", "IMAGES", "SNAPSHOTS", "CONTAINERS", "VOLUMES", "TOTAL")
+	fmt.Println(strings.Repeat("-", 88))
+	for _, p := range report.Projects {
+		name := p.Name
+		if p.Missing {
+			name += " (gone)"
+		}
+		fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", truncateName(name, 32),
+			size(p.Images), size(p.Snapshots), size(p.Containers), size(p.Volumes), size(p.Total()))
+	}
+	if n := report.SharedCachesSize(); n > 0 {
+		fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", "(shared package caches)", "", "", "", size(n), size(n))
+	}
+	if report.Dangling > 0 {
+		fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", "(untagged images)", size(report.Dangling), "", "", "", size(report.Dangling))
+	}
+	if report.BuildCache > 0 {
+		fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", "(build cache)", "", "", "", "", size(report.BuildCache))
+	}
+	fmt.Println(strings.Repeat("-", 88))
+	fmt.Printf("%-32s %10s %10s %11s %10s %10s\n", "Total", "", "", "", "", size(report.Total()))
+
+	suggestions := report.Suggestions()
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("💡 Reclaimable space:")
+	for _, s := range suggestions {
+		fmt.Printf("   • %s (%s)\n", s.Message, diskusage.FormatSize(s.Bytes))
+		fmt.Printf("     %s\n", s.Command)
+	}
+}
+
+func truncateName(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
+
+func init() {
+	duCmd.Flags().BoolVar(&duJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(duCmd)
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/environment"
+	"github.com/UPwith-me/Container-Maker/pkg/gpu"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +22,8 @@ var (
 	envCreateNoStart  bool
 	envCreateForce    bool
 	envCreateGPU      []int
+	envCreateMIG      []string
+	envCreateShared   bool
 	envCreateMemory   string
 	envCreateCPU      float64
 	envCreateLink     []string
@@ -27,12 +31,18 @@ var (
 	// Flags for env list
 	envListAll    bool
 	envListStatus string
+	envListFormat string
+	envListGPUs   bool
 
 	// Flags for env delete
 	envDeleteForce bool
 
 	// Flags for env stop
 	envStopTimeout int
+
+	// Flags for env link
+	envLinkPorts     []int
+	envLinkDirection string
 )
 
 var envCmd = &cobra.Command{
@@ -84,6 +94,10 @@ EXAMPLES
   # Create with GPU support
   cm env create ml-training --template pytorch --gpu 0,1
 
+  # Create on a MIG slice, or share a GPU with other --shared environments
+  cm env create inference --template pytorch --mig 0:1
+  cm env create notebook --template pytorch --gpu 0 --shared
+
   # Create and link to existing environment
   cm env create backend --template python --link frontend`,
 	Args: cobra.ExactArgs(1),
@@ -106,6 +120,8 @@ EXAMPLES
 			NoStart:    envCreateNoStart,
 			Force:      envCreateForce,
 			GPUs:       envCreateGPU,
+			MIGDevices: envCreateMIG,
+			GPUShared:  envCreateShared,
 			Memory:     envCreateMemory,
 			CPU:        envCreateCPU,
 			LinkTo:     envCreateLink,
@@ -119,6 +135,8 @@ EXAMPLES
 			return nil
 		}
 
+		syncEnvHosts(ctx, mgr)
+
 		fmt.Println()
 		fmt.Printf("✅ Environment '%s' created successfully!\n", env.Name)
 		fmt.Printf("   ID:      %s\n", env.ID)
@@ -139,13 +157,22 @@ var envListCmd = &cobra.Command{
 	Long: `List all development environments.
 
 By default, shows all environments with their status, network, and age.
+With --gpus, shows the host's GPUs instead: their utilization and memory,
+and the running environments holding them or their MIG slices.
 
 EXAMPLES
   cm env list
   cm env list --all
-  cm env list --status running`,
+  cm env list --gpus
+  cm env list --status running
+  cm env list --format json
+  cm env list --format '{{.Name}} {{.Status}}'`,
 	Aliases: []string{"ls"},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(envListFormat); err != nil {
+			return err
+		}
+
 		mgr, err := environment.NewManager()
 		if err != nil {
 			fmt.Println(environment.FormatUserError(err))
@@ -154,6 +181,17 @@ EXAMPLES
 
 		ctx := context.Background()
 
+		if envListGPUs {
+			usages, err := mgr.GPUUsage(ctx)
+			if err != nil {
+				fmt.Println(environment.FormatUserError(err))
+				return nil
+			}
+			return output.Print(os.Stdout, envListFormat, usages, func() error {
+				return printGPUUsage(usages)
+			})
+		}
+
 		opts := environment.EnvironmentListOptions{
 			All: envListAll,
 		}
@@ -167,58 +205,60 @@ EXAMPLES
 			return nil
 		}
 
-		if len(envs) == 0 {
-			fmt.Println("No environments found.")
-			fmt.Println()
-			fmt.Println("Create one with:")
-			fmt.Println("  cm env create myproject --template python")
-			return nil
-		}
-
-		// Get active environment
-		active, _ := mgr.GetActive(ctx)
-		activeID := ""
-		if active != nil {
-			activeID = active.ID
-		}
-
-		// Print table
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "  \tNAME\tSTATUS\tNETWORK\tTEMPLATE\tAGE")
-		fmt.Fprintln(w, "  \t----\t------\t-------\t--------\t---")
-
-		for _, env := range envs {
-			marker := " "
-			if env.ID == activeID {
-				marker = "*"
+		return output.Print(os.Stdout, envListFormat, envs, func() error {
+			if len(envs) == 0 {
+				fmt.Println("No environments found.")
+				fmt.Println()
+				fmt.Println("Create one with:")
+				fmt.Println("  cm env create myproject --template python")
+				return nil
 			}
 
-			status := statusIcon(env.Status) + " " + string(env.Status)
-			template := env.Template
-			if template == "" {
-				template = "-"
+			// Get active environment
+			active, _ := mgr.GetActive(ctx)
+			activeID := ""
+			if active != nil {
+				activeID = active.ID
 			}
-			network := env.NetworkName
-			if network == "" {
-				network = "-"
-			}
-			age := formatAge(env.CreatedAt)
-
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-				marker, env.Name, status, network, template, age)
-		}
-		w.Flush()
 
-		fmt.Println()
-		fmt.Printf("Total: %d environments (* = active)\n", len(envs))
+			// Print table
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  \tNAME\tSTATUS\tNETWORK\tTEMPLATE\tAGE")
+			fmt.Fprintln(w, "  \t----\t------\t-------\t--------\t---")
+
+			for _, env := range envs {
+				marker := " "
+				if env.ID == activeID {
+					marker = "*"
+				}
+
+				status := statusIcon(env.Status) + " " + string(env.Status)
+				template := env.Template
+				if template == "" {
+					template = "-"
+				}
+				network := env.NetworkName
+				if network == "" {
+					network = "-"
+				}
+				age := formatAge(env.CreatedAt)
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					marker, env.Name, status, network, template, age)
+			}
+			w.Flush()
 
-		return nil
+			fmt.Println()
+			fmt.Printf("Total: %d environments (* = active)\n", len(envs))
+			return nil
+		})
 	},
 }
 
 var envSwitchCmd = &cobra.Command{
-	Use:   "switch <name>",
-	Short: "Set the active environment",
+	Use:               "switch <name>",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Set the active environment",
 	Long: `Set the active environment.
 
 The active environment is used by default for commands like 'cm shell'.
@@ -252,9 +292,10 @@ EXAMPLES
 }
 
 var envStartCmd = &cobra.Command{
-	Use:   "start <name>",
-	Short: "Start an environment",
-	Args:  cobra.ExactArgs(1),
+	Use:               "start <name>",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Start an environment",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -272,15 +313,17 @@ var envStartCmd = &cobra.Command{
 			return nil
 		}
 
+		syncEnvHosts(ctx, mgr)
 		fmt.Printf("✅ Environment '%s' started\n", name)
 		return nil
 	},
 }
 
 var envStopCmd = &cobra.Command{
-	Use:   "stop <name>",
-	Short: "Stop an environment",
-	Args:  cobra.ExactArgs(1),
+	Use:               "stop <name>",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Stop an environment",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -298,15 +341,17 @@ var envStopCmd = &cobra.Command{
 			return nil
 		}
 
+		syncEnvHosts(ctx, mgr)
 		fmt.Printf("✅ Environment '%s' stopped\n", name)
 		return nil
 	},
 }
 
 var envRestartCmd = &cobra.Command{
-	Use:   "restart <name>",
-	Short: "Restart an environment",
-	Args:  cobra.ExactArgs(1),
+	Use:               "restart <name>",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Restart an environment",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -324,16 +369,18 @@ var envRestartCmd = &cobra.Command{
 			return nil
 		}
 
+		syncEnvHosts(ctx, mgr)
 		fmt.Printf("✅ Environment '%s' restarted\n", name)
 		return nil
 	},
 }
 
 var envDeleteCmd = &cobra.Command{
-	Use:     "delete <name>",
-	Short:   "Delete an environment",
-	Aliases: []string{"rm", "remove"},
-	Args:    cobra.ExactArgs(1),
+	Use:               "delete <name>",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Delete an environment",
+	Aliases:           []string{"rm", "remove"},
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -362,21 +409,31 @@ var envDeleteCmd = &cobra.Command{
 			return nil
 		}
 
+		syncEnvHosts(ctx, mgr)
 		fmt.Printf("✅ Environment '%s' deleted\n", name)
 		return nil
 	},
 }
 
 var envLinkCmd = &cobra.Command{
-	Use:   "link <env1> <env2>",
-	Short: "Link two environments",
+	Use:               "link <env1> <env2>",
+	ValidArgsFunction: completeEnvPair,
+	Short:             "Link two environments",
 	Long: `Link two environments for network communication.
 
 Linked environments can communicate with each other using their 
 environment names as hostnames.
 
+By default the link allows all traffic both ways. --port limits the
+ports of <env2> that <env1> can reach, and --direction forward stops
+<env2> from opening connections to <env1>. The rules are iptables rules
+set from a short-lived container in each environment's network namespace
+(an iptables-only image built on first use, or CM_FIREWALL_IMAGE), and
+are set again when either environment starts.
+
 EXAMPLE
   cm env link frontend backend
+  cm env link frontend backend --port 8080 --direction forward
   
 Then from frontend, you can access backend at http://backend:PORT`,
 	Args: cobra.ExactArgs(2),
@@ -405,25 +462,40 @@ Then from frontend, you can access backend at http://backend:PORT`,
 			return nil
 		}
 
+		var policy *environment.LinkPolicy
+		if len(envLinkPorts) > 0 || envLinkDirection != "" {
+			policy = &environment.LinkPolicy{Ports: envLinkPorts, Direction: envLinkDirection}
+		}
+
 		if err := mgr.Link(ctx, e1.ID, e2.ID, environment.EnvironmentLinkOptions{
 			Bidirectional: true,
+			Policy:        policy,
 		}); err != nil {
 			fmt.Println(environment.FormatUserError(err))
 			return nil
 		}
 
 		fmt.Printf("✅ Environments linked!\n")
-		fmt.Printf("   From %s: access %s at http://%s:<port>\n", env1, env2, env2)
-		fmt.Printf("   From %s: access %s at http://%s:<port>\n", env2, env1, env1)
+		if len(envLinkPorts) > 0 {
+			fmt.Printf("   From %s: access %s at %s:%v only\n", env1, env2, env2, envLinkPorts)
+		} else {
+			fmt.Printf("   From %s: access %s at http://%s:<port>\n", env1, env2, env2)
+		}
+		if envLinkDirection == environment.LinkForward {
+			fmt.Printf("   From %s: no new connections to %s\n", env2, env1)
+		} else {
+			fmt.Printf("   From %s: access %s at http://%s:<port>\n", env2, env1, env1)
+		}
 
 		return nil
 	},
 }
 
 var envUnlinkCmd = &cobra.Command{
-	Use:   "unlink <env1> <env2>",
-	Short: "Unlink two environments",
-	Args:  cobra.ExactArgs(2),
+	Use:               "unlink <env1> <env2>",
+	ValidArgsFunction: completeEnvPair,
+	Short:             "Unlink two environments",
+	Args:              cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		env1, env2 := args[0], args[1]
 
@@ -458,8 +530,9 @@ var envUnlinkCmd = &cobra.Command{
 }
 
 var envStatusCmd = &cobra.Command{
-	Use:   "status [name]",
-	Short: "Show environment status",
+	Use:               "status [name]",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Show environment status",
 	Long: `Show detailed status of an environment.
 
 If no name is given, shows the active environment.`,
@@ -498,17 +571,27 @@ If no name is given, shows the active environment.`,
 		if len(env.LinkedEnvs) > 0 {
 			fmt.Printf("Linked to:   %v\n", env.LinkedEnvs)
 		}
+		for peerID, policy := range env.LinkPolicies {
+			fmt.Printf("Link policy: %s: %s\n", peerID, policy)
+		}
 		if len(env.GPUs) > 0 {
 			fmt.Printf("GPUs:        %v\n", env.GPUs)
 		}
+		if len(env.MIGDevices) > 0 {
+			fmt.Printf("MIG slices:  %s\n", strings.Join(env.MIGDevices, ", "))
+		}
+		if env.GPUShared {
+			fmt.Println("GPU sharing: shared with other --shared environments")
+		}
 
 		return nil
 	},
 }
 
 var envShellCmd = &cobra.Command{
-	Use:   "shell [name]",
-	Short: "Open shell in environment",
+	Use:               "shell [name]",
+	ValidArgsFunction: completeEnvName,
+	Short:             "Open shell in environment",
 	Long: `Open an interactive shell in an environment.
 
 If no name is given, uses the active environment.`,
@@ -615,13 +698,23 @@ func init() {
 	envCreateCmd.Flags().BoolVar(&envCreateNoStart, "no-start", false, "Create but don't start")
 	envCreateCmd.Flags().BoolVarP(&envCreateForce, "force", "f", false, "Force recreate if exists")
 	envCreateCmd.Flags().IntSliceVar(&envCreateGPU, "gpu", nil, "GPU IDs to allocate")
+	envCreateCmd.Flags().StringSliceVar(&envCreateMIG, "mig", nil, "MIG slices to allocate, as <gpu>:<slice>")
+	envCreateCmd.Flags().BoolVar(&envCreateShared, "shared", false, "Share the GPUs with other --shared environments")
 	envCreateCmd.Flags().StringVar(&envCreateMemory, "memory", "", "Memory limit (e.g., 8g)")
 	envCreateCmd.Flags().Float64Var(&envCreateCPU, "cpu", 0, "CPU limit")
 	envCreateCmd.Flags().StringSliceVar(&envCreateLink, "link", nil, "Environments to link to")
+	_ = envCreateCmd.RegisterFlagCompletionFunc("template", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeTemplateNames(cmd, nil, toComplete)
+	})
+	_ = envCreateCmd.RegisterFlagCompletionFunc("link", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeEnvNames(cmd, nil, toComplete)
+	})
 
 	// env list flags
 	envListCmd.Flags().BoolVarP(&envListAll, "all", "a", false, "Show all environments")
 	envListCmd.Flags().StringVar(&envListStatus, "status", "", "Filter by status")
+	envListCmd.Flags().StringVar(&envListFormat, "format", "", output.FlagUsage)
+	envListCmd.Flags().BoolVar(&envListGPUs, "gpus", false, "Show GPU utilization and which environments hold each GPU")
 
 	// env delete flags
 	envDeleteCmd.Flags().BoolVarP(&envDeleteForce, "force", "f", false, "Force delete")
@@ -629,6 +722,10 @@ func init() {
 	// env stop flags
 	envStopCmd.Flags().IntVar(&envStopTimeout, "timeout", 10, "Stop timeout in seconds")
 
+	// env link flags
+	envLinkCmd.Flags().IntSliceVar(&envLinkPorts, "port", nil, "Ports of env2 that env1 can reach (default all)")
+	envLinkCmd.Flags().StringVar(&envLinkDirection, "direction", "", "Who can open connections: both (default) or forward (env1 to env2 only)")
+
 	// Add subcommands
 	envCmd.AddCommand(envCreateCmd)
 	envCmd.AddCommand(envListCmd)
@@ -644,3 +741,46 @@ func init() {
 
 	rootCmd.AddCommand(envCmd)
 }
+
+// printGPUUsage prints the host's GPUs and the environments holding them
+func printGPUUsage(usages []environment.GPUUsage) error {
+	if len(usages) == 0 {
+		fmt.Println("No GPUs detected and no environment holds one.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GPU\tNAME\tUTIL\tMEMORY\tHELD BY")
+	fmt.Fprintln(w, "---\t----\t----\t------\t-------")
+	for _, usage := range usages {
+		name, util, memory := "-", "-", "-"
+		if g := usage.GPU; g != nil {
+			name = g.Name
+			util = fmt.Sprintf("%d%%", g.Utilization)
+			memory = fmt.Sprintf("%s / %s", gpu.FormatVRAM(g.VRAMUsed), gpu.FormatVRAM(g.VRAM))
+		}
+
+		var holders []string
+		for _, h := range usage.Holders {
+			holder := h.Environment
+			if h.Device != usage.Index {
+				slice := "MIG " + h.Device
+				if h.Profile != "" {
+					slice += " " + h.Profile
+				}
+				holder += " (" + slice + ")"
+			}
+			if h.Shared {
+				holder += " [shared]"
+			}
+			holders = append(holders, holder)
+		}
+		heldBy := "free"
+		if len(holders) > 0 {
+			heldBy = strings.Join(holders, ", ")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", usage.Index, name, util, memory, heldBy)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/UPwith-me/Container-Maker/pkg/environment"
+	"github.com/UPwith-me/Container-Maker/pkg/hostsfile"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envHostsWrite bool
+	envHostsClean bool
+)
+
+var envHostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Reach environments from the host as <name>.cm.local",
+	Long: `Map each running environment to its container IP as <name>.cm.local,
+so host browsers and tools can open http://backend.cm.local:8080 without
+a forwarded port.
+
+Without flags the entries are printed. --write puts them in a marked
+block of the hosts file (/etc/hosts, or the Windows hosts file), which
+usually needs sudo or an administrator prompt; --clean removes the block.
+With 'cm config set env.hosts true', cm env create, start, stop, restart
+and delete update the block when they can write the file.
+
+Container IPs are only reachable from the host where the engine runs
+natively (Linux); Docker Desktop keeps them inside its VM.
+
+EXAMPLES
+  cm env hosts
+  sudo cm env hosts --write
+  sudo cm env hosts --clean`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if envHostsClean {
+			changed, err := hostsfile.Sync(nil)
+			if err != nil {
+				return err
+			}
+			if changed {
+				fmt.Printf("✅ Removed environment entries from %s\n", hostsfile.Path())
+			}
+			return nil
+		}
+
+		mgr, err := environment.NewManager()
+		if err != nil {
+			fmt.Println(environment.FormatUserError(err))
+			return nil
+		}
+
+		entries, err := mgr.HostEntries(context.Background())
+		if err != nil {
+			fmt.Println(environment.FormatUserError(err))
+			return nil
+		}
+
+		if !envHostsWrite {
+			if len(entries) == 0 {
+				fmt.Println("No running environments.")
+				return nil
+			}
+			fmt.Print(hostsfile.Render(entries))
+			return nil
+		}
+
+		changed, err := hostsfile.Sync(entries)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("✅ Updated %s with %d environment(s)\n", hostsfile.Path(), len(entries))
+		} else {
+			fmt.Printf("%s is up to date\n", hostsfile.Path())
+		}
+		return nil
+	},
+}
+
+// syncEnvHosts updates the hosts file after an environment changed state,
+// when env.hosts is enabled. It only warns, as the file often isn't
+// writable without sudo.
+func syncEnvHosts(ctx context.Context, mgr *environment.Manager) {
+	cfg, err := userconfig.Load()
+	if err != nil || !cfg.Env.Hosts {
+		return
+	}
+
+	entries, err := mgr.HostEntries(ctx)
+	if err != nil {
+		return
+	}
+	if _, err := hostsfile.Sync(entries); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+func init() {
+	envHostsCmd.Flags().BoolVar(&envHostsWrite, "write", false, "Write the entries to the hosts file")
+	envHostsCmd.Flags().BoolVar(&envHostsClean, "clean", false, "Remove the entries from the hosts file")
+	envHostsCmd.MarkFlagsMutuallyExclusive("write", "clean")
+	envCmd.AddCommand(envHostsCmd)
+}
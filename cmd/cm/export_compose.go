@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportComposeOutput    string
+	exportComposeWorkspace string
+	exportComposeForce     bool
+)
+
+var exportComposeCmd = &cobra.Command{
+	Use:   "compose",
+	Short: "Generate a docker-compose.yml from the workspace",
+	Long: `Serialize cm-workspace.yaml into a docker-compose file for tools that
+do not understand CM workspaces.
+
+Services, networks, volumes, healthchecks, resource limits and GPU
+reservations are converted. Each service keeps the runtime layout CM gives
+it: its directory is mounted at /workspaces/<dir> and used as the working
+directory. Templates are resolved to their images.
+
+EXAMPLES
+  cm export compose
+  cm export compose -o compose.ci.yml
+  cm export compose -o - | docker compose -f - config`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := workspace.Load(exportComposeWorkspace)
+		if err != nil {
+			return err
+		}
+		if err := workspace.Validate(ws); err != nil {
+			return err
+		}
+
+		result := imports.ExportCompose(ws)
+		data, err := result.YAML()
+		if err != nil {
+			return fmt.Errorf("failed to marshal compose file: %w", err)
+		}
+		header := []byte("# Generated by 'cm export compose' from " + filepath.Base(ws.ConfigFile) + "\n")
+		data = append(header, data...)
+
+		if exportComposeOutput == "-" {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+
+		output := exportComposeOutput
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(filepath.Dir(ws.ConfigFile), output)
+		}
+		if _, err := os.Stat(output); err == nil && !exportComposeForce {
+			return fmt.Errorf("%s already exists, use --force to overwrite", output)
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Wrote %s (%d services)\n", output, len(result.Compose.Services))
+		for _, w := range result.Warnings {
+			fmt.Printf("  ⚠️  [%s] %s: %s\n", w.Code, w.Service, w.Message)
+			if w.Suggestion != "" {
+				fmt.Printf("      Suggestion: %s\n", w.Suggestion)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportComposeCmd.Flags().StringVarP(&exportComposeOutput, "output", "o", "docker-compose.yml", "Output file, relative to the workspace (\"-\" for stdout)")
+	exportComposeCmd.Flags().StringVarP(&exportComposeWorkspace, "file", "f", "", "Workspace file (default: search for cm-workspace.yaml)")
+	exportComposeCmd.Flags().BoolVar(&exportComposeForce, "force", false, "Overwrite an existing output file")
+
+	exportCmd.AddCommand(exportComposeCmd)
+}
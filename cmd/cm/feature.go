@@ -2,29 +2,47 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/features"
 	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/spf13/cobra"
 )
 
 var featureCmd = &cobra.Command{
-	Use:   "feature",
-	Short: "Manage DevContainer features",
-	Long: `List, search, download, and get information about DevContainer features.
+	Use:     "feature",
+	Aliases: []string{"features"},
+	Short:   "Manage DevContainer features",
+	Long: `List, search, download, and get information about DevContainer features,
+and author your own.
 
 Features are modular additions to dev containers that provide
 additional tools, runtimes, or configurations.
 
-Supports downloading from OCI registries (ghcr.io, etc.)
+Supports downloading from OCI registries (ghcr.io, etc.). devcontainer.json
+can also point at a local feature directory relative to itself
+("./local-features/mytool") or at a tarball URL
+("https://example.com/devcontainer-feature-mytool.tgz"). Changes to local
+features are picked up on the next rebuild.
 
 Examples:
   cm feature list                    # List available features
   cm feature info go                 # Show feature details and options
   cm feature download node           # Download feature to cache
   cm feature cache                   # Show cached features
-  cm feature cache clear             # Clear feature cache`,
+  cm feature cache clear             # Clear feature cache
+  cm features plan                   # Show the resolved install order
+
+Authoring:
+  cm feature init hello              # Scaffold src/hello and test/hello
+  cm feature test src/hello          # Build onto base images and run tests
+  cm feature publish --namespace ghcr.io/my-org/features`,
 }
 
 var featureListCmd = &cobra.Command{
@@ -65,7 +83,37 @@ var featureCacheClearCmd = &cobra.Command{
 	RunE:  runFeatureCacheClear,
 }
 
+var (
+	featurePlanConfig     string
+	featurePlanNoMetadata bool
+	featurePlanJSON       bool
+)
+
+var featurePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the resolved feature install order",
+	Long: `Resolve the features of devcontainer.json the way they will be installed.
+
+Features listed under another feature's dependsOn are added, a feature
+requested more than once is installed once with merged options, and the
+order follows dependsOn, installsAfter and overrideFeatureInstallOrder.
+Features that are ready at the same time install alphabetically, so the
+order is the same on every machine.
+
+EXAMPLES
+  cm features plan
+  cm features plan -c .devcontainer/gpu/devcontainer.json
+  cm features plan --no-metadata # Skip fetching metadata (no dependsOn)`,
+	Args: cobra.NoArgs,
+	RunE: runFeaturePlan,
+}
+
 func init() {
+	featurePlanCmd.Flags().StringVarP(&featurePlanConfig, "config", "c", "", "Path to devcontainer.json")
+	featurePlanCmd.Flags().BoolVar(&featurePlanNoMetadata, "no-metadata", false, "Don't read feature metadata (dependsOn is not followed)")
+	featurePlanCmd.Flags().BoolVar(&featurePlanJSON, "json", false, "Output as JSON")
+	featureCmd.AddCommand(featurePlanCmd)
+
 	featureCacheCmd.AddCommand(featureCacheClearCmd)
 	featureCmd.AddCommand(featureListCmd)
 	featureCmd.AddCommand(featureInfoCmd)
@@ -247,3 +295,85 @@ func normalizeFeatureID(name string) string {
 	}
 	return fmt.Sprintf("ghcr.io/devcontainers/features/%s:1", name)
 }
+
+func runFeaturePlan(cmd *cobra.Command, args []string) error {
+	configPath := featurePlanConfig
+	if configPath == "" {
+		if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+			configPath = ".devcontainer/devcontainer.json"
+		} else if _, err := os.Stat("devcontainer.json"); err == nil {
+			configPath = "devcontainer.json"
+		} else {
+			return fmt.Errorf("no devcontainer.json found")
+		}
+	}
+
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	var plan *features.InstallPlan
+	if featurePlanNoMetadata {
+		plan, err = features.ResolveInstallOrder(cfg.Features, cfg.OverrideFeatureInstallOrder, nil)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		plan, err = runner.ResolveFeatures(ctx, cfg.Features, cfg.OverrideFeatureInstallOrder, cfg.ConfigDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if featurePlanJSON {
+		type step struct {
+			Ref        string                 `json:"ref"`
+			Options    map[string]interface{} `json:"options,omitempty"`
+			RequiredBy []string               `json:"requiredBy"`
+		}
+		out := struct {
+			Features []step   `json:"features"`
+			Warnings []string `json:"warnings,omitempty"`
+		}{Warnings: plan.Warnings}
+		for _, f := range plan.Features {
+			out.Features = append(out.Features, step{Ref: f.Ref, Options: f.Options, RequiredBy: f.RequiredBy})
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("📋 Feature install plan for %s\n\n", configPath)
+	if len(plan.Features) == 0 {
+		fmt.Println("   No features configured")
+		return nil
+	}
+	for i, f := range plan.Features {
+		fmt.Printf("%3d. %s\n", i+1, f.Ref)
+		if len(f.Options) > 0 {
+			var opts []string
+			for name, value := range f.Options {
+				opts = append(opts, fmt.Sprintf("%s=%v", name, value))
+			}
+			sort.Strings(opts)
+			fmt.Printf("     options: %s\n", strings.Join(opts, ", "))
+		}
+		if len(f.RequiredBy) > 1 || f.RequiredBy[0] != features.ConfigSource {
+			fmt.Printf("     required by: %s\n", strings.Join(f.RequiredBy, ", "))
+		}
+		if f.Metadata == nil && !featurePlanNoMetadata {
+			fmt.Println("     ⚠️  metadata unavailable, dependencies unknown")
+		}
+	}
+
+	if len(plan.Warnings) > 0 {
+		fmt.Println()
+		for _, w := range plan.Warnings {
+			fmt.Printf("⚠️  %s\n", w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/spf13/cobra"
+)
+
+var (
+	featureInitName        string
+	featureInitDescription string
+
+	featureTestImages        []string
+	featureTestSkipScenarios bool
+	featureTestFilter        string
+	featureTestKeepImages    bool
+	featureTestBackend       string
+
+	featurePublishNamespace    string
+	featurePublishUsername     string
+	featurePublishPasswordFile string
+	featurePublishPlainHTTP    bool
+)
+
+var featureInitCmd = &cobra.Command{
+	Use:   "init <id> [dir]",
+	Short: "Scaffold a new feature",
+	Long: `Create a feature in the devcontainers/feature-starter layout:
+
+  src/<id>/devcontainer-feature.json   metadata and options
+  src/<id>/install.sh                  runs as root while the image builds
+  test/<id>/test.sh                    checks run on each base image
+  test/<id>/scenarios.json             extra option combinations to test
+
+EXAMPLES
+  cm feature init hello
+  cm feature init my-tool ./features --name "My Tool"`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := "."
+		if len(args) > 1 {
+			root = args[1]
+		}
+
+		written, err := features.Scaffold(root, features.ScaffoldOptions{
+			ID:          args[0],
+			Name:        featureInitName,
+			Description: featureInitDescription,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Created feature '%s'\n\n", args[0])
+		for _, f := range written {
+			fmt.Printf("   %s\n", filepath.Join(root, f))
+		}
+		fmt.Println()
+		fmt.Printf("💡 Edit src/%s/install.sh, then run 'cm feature test %s'\n", args[0], filepath.Join(root, "src", args[0]))
+		return nil
+	},
+}
+
+var featureTestCmd = &cobra.Command{
+	Use:   "test [feature-dir]",
+	Short: "Build a feature onto base images and run its tests",
+	Long: `Install a local feature onto one or more base images and run its tests.
+
+test/<id>/test.sh runs on every --base-image with the default options. Each
+entry of test/<id>/scenarios.json is then built with its own image and
+options and checked by test/<id>/<scenario>.sh. Test scripts can source
+dev-container-features-test-lib for the check and reportResults helpers.
+
+EXAMPLES
+  cm feature test src/hello
+  cm feature test src/hello --base-image debian:12 --base-image ubuntu:22.04
+  cm feature test src/hello --skip-scenarios`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		feature, err := features.LoadLocalFeature(dir)
+		if err != nil {
+			return err
+		}
+		if errs := feature.Validate(); len(errs) > 0 {
+			printFeatureErrors(feature, errs)
+			return fmt.Errorf("feature %s is invalid", feature.Metadata.ID)
+		}
+
+		fmt.Printf("🧪 Testing feature '%s' v%s\n", feature.Metadata.ID, feature.Metadata.Version)
+
+		results, err := features.RunTests(context.Background(), feature, features.TestOptions{
+			Backend:       featureTestBackend,
+			BaseImages:    featureTestImages,
+			SkipScenarios: featureTestSkipScenarios,
+			Filter:        featureTestFilter,
+			KeepImages:    featureTestKeepImages,
+			Output:        os.Stdout,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		fmt.Println("📋 Results")
+		failed := 0
+		for _, r := range results {
+			if r.Passed() {
+				fmt.Printf("   ✅ %s (%s)\n", r.Case.Name, r.Duration.Round(time.Second))
+				continue
+			}
+			failed++
+			fmt.Printf("   ❌ %s: %v\n", r.Case.Name, r.Err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d test runs failed", failed, len(results))
+		}
+		fmt.Printf("\n✅ All %d test runs passed\n", len(results))
+		return nil
+	},
+}
+
+var featurePublishCmd = &cobra.Command{
+	Use:   "publish [dir] --namespace <registry>/<namespace>",
+	Short: "Publish features to an OCI registry",
+	Long: `Publish local features as OCI artifacts that dev container tools can
+install, e.g. ghcr.io/my-org/features/hello:1.
+
+Point it at a single feature (src/<id>) or at a repository root to publish
+every feature under src/ together with the namespace's collection index.
+Each feature is tagged with its full version plus its major, minor and
+latest tags unless a newer release already holds them. Versions that are
+already in the registry are skipped.
+
+Credentials come from --username and --password-file, or from the
+CM_REGISTRY_USERNAME and CM_REGISTRY_PASSWORD environment variables. For
+ghcr.io, GITHUB_ACTOR and GITHUB_TOKEN are used as a fallback.
+
+EXAMPLES
+  cm feature publish --namespace ghcr.io/my-org/features
+  cm feature publish src/hello --namespace ghcr.io/my-org/features
+  cm feature publish --namespace localhost:5000/features --plain-http`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		registry, namespace, ok := strings.Cut(featurePublishNamespace, "/")
+		if !ok || namespace == "" {
+			return fmt.Errorf("--namespace must be <registry>/<namespace>, e.g. ghcr.io/my-org/features")
+		}
+
+		toPublish, collection, err := loadFeaturesToPublish(dir)
+		if err != nil {
+			return err
+		}
+
+		username, password, err := featureRegistryCredentials(registry)
+		if err != nil {
+			return err
+		}
+		publisher := features.NewPublisher(features.PublishOptions{
+			Registry:  registry,
+			Namespace: namespace,
+			Username:  username,
+			Password:  password,
+			PlainHTTP: featurePublishPlainHTTP,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		defer cancel()
+
+		for _, f := range toPublish {
+			if errs := f.Validate(); len(errs) > 0 {
+				printFeatureErrors(f, errs)
+				return fmt.Errorf("feature %s is invalid", f.Metadata.ID)
+			}
+		}
+		for _, f := range toPublish {
+			fmt.Printf("📤 Publishing %s v%s...\n", f.Metadata.ID, f.Metadata.Version)
+			result, err := publisher.Publish(ctx, f)
+			if err != nil {
+				return err
+			}
+			if result.Skipped {
+				fmt.Printf("   ⏭️  %s is already published\n", result.Ref)
+				continue
+			}
+			fmt.Printf("   ✅ %s (tags: %s)\n", result.Ref, strings.Join(result.Tags, ", "))
+			fmt.Printf("      %s\n", result.Digest)
+		}
+
+		if collection {
+			fmt.Println("📤 Publishing collection metadata...")
+			if err := publisher.PublishCollection(ctx, toPublish); err != nil {
+				return err
+			}
+			fmt.Printf("   ✅ %s:latest\n", featurePublishNamespace)
+		}
+		return nil
+	},
+}
+
+func init() {
+	featureInitCmd.Flags().StringVar(&featureInitName, "name", "", "Display name (default: the id)")
+	featureInitCmd.Flags().StringVar(&featureInitDescription, "description", "", "Short description")
+
+	featureTestCmd.Flags().StringArrayVar(&featureTestImages, "base-image", nil, "Base image to test on (repeatable, default: "+features.DefaultTestImage+")")
+	featureTestCmd.Flags().BoolVar(&featureTestSkipScenarios, "skip-scenarios", false, "Only run test.sh, not scenarios.json")
+	featureTestCmd.Flags().StringVar(&featureTestFilter, "filter", "", "Only run test cases whose name contains this")
+	featureTestCmd.Flags().BoolVar(&featureTestKeepImages, "keep-images", false, "Keep the built test images")
+	featureTestCmd.Flags().StringVar(&featureTestBackend, "backend", "docker", "Container CLI to build with (docker or podman)")
+
+	featurePublishCmd.Flags().StringVar(&featurePublishNamespace, "namespace", "", "Target <registry>/<namespace>, e.g. ghcr.io/my-org/features")
+	featurePublishCmd.Flags().StringVar(&featurePublishUsername, "username", "", "Registry username")
+	featurePublishCmd.Flags().StringVar(&featurePublishPasswordFile, "password-file", "", "File containing the registry password or token")
+	featurePublishCmd.Flags().BoolVar(&featurePublishPlainHTTP, "plain-http", false, "Use HTTP instead of HTTPS (local registries)")
+	_ = featurePublishCmd.MarkFlagRequired("namespace")
+
+	featureCmd.AddCommand(featureInitCmd)
+	featureCmd.AddCommand(featureTestCmd)
+	featureCmd.AddCommand(featurePublishCmd)
+}
+
+// loadFeaturesToPublish loads a single feature directory, or every feature of
+// a repository root; the collection index is only published for the latter
+func loadFeaturesToPublish(dir string) ([]*features.LocalFeature, bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, features.FeatureMetadataFile)); err == nil {
+		f, err := features.LoadLocalFeature(dir)
+		if err != nil {
+			return nil, false, err
+		}
+		return []*features.LocalFeature{f}, false, nil
+	}
+
+	ids, err := features.ListLocalFeatures(dir)
+	if err != nil || len(ids) == 0 {
+		return nil, false, fmt.Errorf("no features found in %s (expected src/<id>/%s)", dir, features.FeatureMetadataFile)
+	}
+	var list []*features.LocalFeature
+	for _, id := range ids {
+		f, err := features.LoadLocalFeature(filepath.Join(dir, "src", id))
+		if err != nil {
+			return nil, false, err
+		}
+		list = append(list, f)
+	}
+	return list, true, nil
+}
+
+// featureRegistryCredentials resolves credentials from flags, then the environment
+func featureRegistryCredentials(registry string) (string, string, error) {
+	username := featurePublishUsername
+	password := ""
+	if featurePublishPasswordFile != "" {
+		data, err := os.ReadFile(featurePublishPasswordFile)
+		if err != nil {
+			return "", "", err
+		}
+		password = strings.TrimSpace(string(data))
+	}
+
+	if username == "" {
+		username = os.Getenv("CM_REGISTRY_USERNAME")
+	}
+	if password == "" {
+		password = os.Getenv("CM_REGISTRY_PASSWORD")
+	}
+	if registry == "ghcr.io" {
+		if username == "" {
+			username = os.Getenv("GITHUB_ACTOR")
+		}
+		if password == "" {
+			password = os.Getenv("GITHUB_TOKEN")
+		}
+	}
+	return username, password, nil
+}
+
+func printFeatureErrors(f *features.LocalFeature, errs []error) {
+	fmt.Printf("❌ %s has %d problem(s):\n", filepath.Join(f.Dir, features.FeatureMetadataFile), len(errs))
+	for _, err := range errs {
+		fmt.Printf("   • %v\n", err)
+	}
+}
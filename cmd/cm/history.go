@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/history"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyAll    bool
+	historyKind   string
+	historyGrep   string
+	historyFailed bool
+	historySince  string
+	historyLimit  int
+	historyFormat string
+	historyClear  bool
+
+	rerunStrict bool
+	rerunLatest bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the commands run with cm run, exec and make",
+	Long: `List the commands run in this project's dev container with 'cm run',
+'cm exec' and 'cm make', newest first, with their exit code, duration and
+the image they ran in. Repeat one with 'cm rerun <id>'.
+
+History is kept in ~/.cm/history.jsonl (the last 1000 commands).
+
+Examples:
+  cm history
+  cm history --failed
+  cm history --kind make --since 24h
+  cm history --grep test -n 5
+  cm history --all --format json
+  cm history --clear`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(historyFormat); err != nil {
+			return err
+		}
+
+		filter := history.Filter{
+			Kind:   historyKind,
+			Grep:   historyGrep,
+			Failed: historyFailed,
+		}
+		if !historyAll {
+			filter.Project = currentProject()
+		}
+		if historySince != "" {
+			d, err := parseSince(historySince)
+			if err != nil {
+				return err
+			}
+			filter.Since = time.Now().Add(-d)
+		}
+
+		if historyClear {
+			if err := history.Clear(filter.Project); err != nil {
+				return err
+			}
+			if filter.Project == "" {
+				fmt.Println("🧹 Cleared the command history")
+			} else {
+				fmt.Printf("🧹 Cleared the command history of %s\n", filter.Project)
+			}
+			return nil
+		}
+
+		entries, err := history.Query(filter, historyLimit)
+		if err != nil {
+			return err
+		}
+
+		return output.Print(os.Stdout, historyFormat, entries, func() error {
+			if len(entries) == 0 {
+				fmt.Println("No commands recorded yet.")
+				fmt.Println("Commands run with 'cm run', 'cm exec' and 'cm make' show up here.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			if historyAll {
+				fmt.Fprintln(w, "ID\tAGE\tPROJECT\tKIND\tEXIT\tDURATION\tCOMMAND")
+			} else {
+				fmt.Fprintln(w, "ID\tAGE\tKIND\tEXIT\tDURATION\tCOMMAND")
+			}
+			for _, e := range entries {
+				exit := "✅ 0"
+				if e.ExitCode != 0 {
+					exit = fmt.Sprintf("❌ %d", e.ExitCode)
+				}
+				duration := (time.Duration(e.DurationMs) * time.Millisecond).Round(100 * time.Millisecond)
+				command := truncate(strings.Join(e.Command, " "), 60)
+				if historyAll {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, formatAge(e.Time), filepath.Base(e.Project), e.Kind, exit, duration, command)
+				} else {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", e.ID, formatAge(e.Time), e.Kind, exit, duration, command)
+				}
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var rerunCmd = &cobra.Command{
+	Use:   "rerun [id]",
+	Short: "Repeat a command from cm history",
+	Long: `Repeat a command recorded by 'cm history', from the directory it ran in
+and with the same cm arguments. Without an ID the project's last command
+is repeated.
+
+A 'cm run' command runs in the exact image it used before while that image
+is still present, so a rebuilt image does not change the result; --latest
+uses the current image instead. When the image changed since the command
+was recorded, cm warns, or refuses with --strict.
+
+Examples:
+  cm rerun
+  cm rerun 42
+  cm rerun 42 --strict
+  cm rerun 42 --latest`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entry, err := rerunEntry(args)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+
+		cmArgs := append([]string(nil), entry.Args...)
+		current := currentImageID(ctx, entry)
+		changed := entry.ImageDigest != "" && current != "" && current != entry.ImageDigest
+
+		pinned := false
+		if entry.Kind == "run" && entry.ImageDigest != "" && !rerunLatest {
+			if _, err := runner.ImageID(ctx, "docker", entry.ImageDigest); err == nil {
+				cmArgs = pinImage(cmArgs, entry.ImageDigest)
+				pinned = true
+			}
+		}
+
+		if changed && !pinned {
+			if rerunStrict {
+				return fmt.Errorf("the image of #%d changed since it ran (%s, now %s); rerun without --strict to use the current one",
+					entry.ID, shortImageID(entry.ImageDigest), shortImageID(current))
+			}
+			fmt.Printf("⚠️  The image changed since #%d ran (%s, now %s); results may differ\n",
+				entry.ID, shortImageID(entry.ImageDigest), shortImageID(current))
+		}
+
+		age := formatAge(entry.Time)
+		if age != "just now" {
+			age += " ago"
+		}
+		fmt.Printf("🔁 Rerunning #%d (%s): %s\n", entry.ID, age, strings.Join(entry.Command, " "))
+		if pinned {
+			fmt.Printf("📌 Using image %s\n", shortImageID(entry.ImageDigest))
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		c := exec.Command(exe, cmArgs...)
+		c.Dir = entry.Dir
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("#%d failed: %w", entry.ID, err)
+		}
+		return nil
+	},
+}
+
+// rerunEntry returns the entry named on the command line, or the current
+// project's last one
+func rerunEntry(args []string) (*history.Entry, error) {
+	if len(args) == 1 {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid history ID %q", args[0])
+		}
+		return history.Get(id)
+	}
+	entries, err := history.Query(history.Filter{Project: currentProject()}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no commands recorded for this project; see 'cm history --all'")
+	}
+	return &entries[0], nil
+}
+
+// currentImageID returns the ID of the image an entry's command would run
+// in now
+func currentImageID(ctx context.Context, e *history.Entry) string {
+	if e.Kind == "run" {
+		id, _ := runner.ImageID(ctx, "docker", e.Image)
+		return id
+	}
+	_, id := runner.ContainerImage(ctx, e.Project)
+	return id
+}
+
+// pinImage makes 'cm run' arguments use an image, replacing an --image a
+// previous rerun added
+func pinImage(args []string, image string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+			out = append(out, args[i:]...)
+			i = len(args)
+		case args[i] == "--image" && i+1 < len(args):
+			i++
+		case strings.HasPrefix(args[i], "--image="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+	for i, arg := range out {
+		if arg == "run" {
+			return append(out[:i+1], append([]string{"--image", image}, out[i+1:]...)...)
+		}
+	}
+	return out
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// recordHistory runs a cm run, exec or make command and records it in the
+// history; fn may fill in the exit code and image when it knows them
+func recordHistory(kind string, command []string, fn func(e *history.Entry) error) error {
+	e := history.Entry{
+		Kind:    kind,
+		Command: command,
+		Args:    os.Args[1:],
+		Project: currentProject(),
+	}
+	e.Dir, _ = os.Getwd()
+
+	start := time.Now()
+	err := fn(&e)
+	e.DurationMs = time.Since(start).Milliseconds()
+	if e.ExitCode == 0 {
+		e.ExitCode = history.ExitCode(err)
+	}
+	if e.ImageDigest == "" && kind != "run" {
+		e.Image, e.ImageDigest = runner.ContainerImage(context.Background(), e.Project)
+	}
+
+	if _, herr := history.Record(e); herr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record command history: %v\n", herr)
+	}
+	return err
+}
+
+// currentProject returns the project directory of --config, or the current
+// directory
+func currentProject() string {
+	dir, _ := os.Getwd()
+	if configFile != "" {
+		if abs, err := filepath.Abs(configFile); err == nil {
+			dir = filepath.Dir(abs)
+			if filepath.Base(dir) == ".devcontainer" {
+				dir = filepath.Dir(dir)
+			}
+		}
+	}
+	return dir
+}
+
+// parseSince parses a duration like 90m, 24h or 7d
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --since %q: use e.g. 90m, 24h or 7d", s)
+	}
+	return d, nil
+}
+
+func init() {
+	historyCmd.Flags().BoolVarP(&historyAll, "all", "a", false, "Show the commands of all projects")
+	historyCmd.Flags().StringVar(&historyKind, "kind", "", "Only show run, exec or make commands")
+	historyCmd.Flags().StringVar(&historyGrep, "grep", "", "Only show commands containing this text")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "Only show commands that failed")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only show commands newer than this (e.g. 24h, 7d)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many commands (0 for all)")
+	historyCmd.Flags().StringVar(&historyFormat, "format", "", output.FlagUsage)
+	historyCmd.Flags().BoolVar(&historyClear, "clear", false, "Delete the history of this project (or all, with --all)")
+
+	rerunCmd.Flags().BoolVar(&rerunStrict, "strict", false, "Refuse to rerun when the image changed since the command ran")
+	rerunCmd.Flags().BoolVar(&rerunLatest, "latest", false, "Run in the current image instead of the one the command used")
+
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(rerunCmd)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/autoenter"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook <bash|zsh|fish>",
+	Short: "Print the shell hook that runs commands in the dev container after cd",
+	Long: `Print the opt-in auto-enter hook for a shell.
+
+When you cd into a directory with a devcontainer.json, the hook asks once
+whether commands there should run in the project's dev container. In an
+allowed project each command line is passed to the container, as if typed
+after 'cm exec'. cm itself, cd, exit, shell builtins, functions and aliases
+keep running on the host, as do the commands listed in CM_AUTO_ENTER_SKIP.
+
+Like direnv, the permission is stored per project in ~/.cm/allowed.json and
+asked again when devcontainer.json changes. Use 'cm allow' and 'cm deny' to
+change it.
+
+SETUP
+  bash:  eval "$(cm hook bash)"     # in ~/.bashrc
+  zsh:   eval "$(cm hook zsh)"      # in ~/.zshrc
+  fish:  cm hook fish | source      # in ~/.config/fish/config.fish
+
+'cm init --shell <shell> --auto-enter --apply' adds the line for you.`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.ExactValidArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, err := autoenter.Script(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	},
+}
+
+var hookCheckCmd = &cobra.Command{
+	Use:    "check",
+	Short:  "Print the hook decision for the current directory",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil
+		}
+		projectDir, configPath, ok := runner.FindProject(cwd)
+		if !ok {
+			return nil
+		}
+		store, err := loadAllowlist()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%s\n", store.Check(projectDir, configPath), projectDir)
+		return nil
+	},
+}
+
+var hookRunCmd = &cobra.Command{
+	Use:                "run <command line>",
+	Short:              "Run a command line in the dev container, in the matching directory",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Args:               cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		projectDir, _, ok := runner.FindProject(cwd)
+		if !ok {
+			return fmt.Errorf("no devcontainer.json found above %s", cwd)
+		}
+
+		// The project is mounted at /workspaces/<name>
+		rel, err := filepath.Rel(projectDir, cwd)
+		if err != nil {
+			rel = "."
+		}
+		workdir := filepath.ToSlash(filepath.Join("/workspaces", filepath.Base(projectDir), rel))
+		line := strings.Join(args, " ")
+
+		if err := os.Chdir(projectDir); err != nil {
+			return err
+		}
+		return execInProject([]string{"sh", "-c", "cd " + shellQuote(workdir) + " 2>/dev/null; " + line})
+	},
+}
+
+var allowCmd = &cobra.Command{
+	Use:   "allow [dir]",
+	Short: "Allow the auto-enter hook to run commands in a project's dev container",
+	Long: `Allow the auto-enter hook (see 'cm hook') to run commands in the dev
+container of the project containing dir, by default the current directory.
+The permission covers the current devcontainer.json; the hook asks again
+after it changes.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, configPath, err := hookProject(args)
+		if err != nil {
+			return err
+		}
+		store, err := loadAllowlist()
+		if err != nil {
+			return err
+		}
+		store.Allow(projectDir, configPath)
+		if err := store.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Commands in %s now run in its dev container\n", projectDir)
+		return nil
+	},
+}
+
+var denyCmd = &cobra.Command{
+	Use:   "deny [dir]",
+	Short: "Stop the auto-enter hook for a project",
+	Long: `Stop the auto-enter hook (see 'cm hook') from running commands in the dev
+container of the project containing dir, by default the current directory,
+and from asking about it again. Open a new shell or cd back in to apply.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _, err := hookProject(args)
+		if err != nil {
+			return err
+		}
+		store, err := loadAllowlist()
+		if err != nil {
+			return err
+		}
+		store.Deny(projectDir)
+		if err := store.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("🚫 Commands in %s run on the host\n", projectDir)
+		return nil
+	},
+}
+
+// hookProject finds the project containing the directory argument or the
+// current directory
+func hookProject(args []string) (string, string, error) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	projectDir, configPath, ok := runner.FindProject(dir)
+	if !ok {
+		return "", "", fmt.Errorf("no devcontainer.json found in %s or its parents", dir)
+	}
+	return projectDir, configPath, nil
+}
+
+func loadAllowlist() (*autoenter.Store, error) {
+	path, err := autoenter.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return autoenter.Load(path)
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	hookCmd.AddCommand(hookCheckCmd)
+	hookCmd.AddCommand(hookRunCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(allowCmd)
+	rootCmd.AddCommand(denyCmd)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/localca"
+	"github.com/spf13/cobra"
+)
+
+var httpsCmd = &cobra.Command{
+	Use:   "https",
+	Short: "Serve forwarded ports over HTTPS with locally trusted certificates",
+	Long: `Serve a project's forwarded ports at https://<port>.<project>.localhost.
+
+With 'cm config set ports.https true', cm shell starts a Caddy sidecar
+(CM_HTTPS_PROXY_IMAGE, default caddy:2-alpine) next to the persistent
+container. It listens on host port 443, or the first free port from 8443
+when 443 is taken or the engine is rootless, and proxies each forwarded
+TCP port with a certificate issued by cm's local CA. The sidecar is
+removed with the container.
+
+Secure cookies, service workers and other APIs that need a secure
+context then work as in production. Browsers resolve *.localhost to the
+host by themselves.
+
+EXAMPLES
+  cm config set ports.https true
+  cm https trust                 # Once, so browsers accept the certificates
+  cm shell --rebuild`,
+}
+
+var httpsTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Add cm's local CA to the host's trust store",
+	Long: `Create cm's local CA in ~/.cm/ca if needed and add its root certificate to
+the host's trust store: the system keychain on macOS, the root store on
+Windows, or the distribution's CA bundle on Linux. This needs sudo or an
+administrator prompt. Firefox keeps its own store; import the printed
+certificate there.
+
+The CA's key never leaves ~/.cm/ca. Only certificates for the proxy are
+issued with it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := localca.Dir()
+		if err != nil {
+			return err
+		}
+		ca, err := localca.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("🔑 Local CA: %s\n", ca.CertPath())
+		if ca.Trusted() {
+			fmt.Println("✅ The host already trusts it")
+			return nil
+		}
+
+		fmt.Printf("Running: %s\n", strings.Join(ca.TrustCommand(), " "))
+		if err := ca.Trust(); err != nil {
+			return fmt.Errorf("failed to trust the CA (run the command above with sudo or as administrator): %w", err)
+		}
+		fmt.Println("✅ The local CA is trusted; restart browsers to pick it up")
+		return nil
+	},
+}
+
+func init() {
+	httpsCmd.AddCommand(httpsTrustCmd)
+	rootCmd.AddCommand(httpsCmd)
+}
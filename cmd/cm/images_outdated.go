@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/images"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/registry"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/spf13/cobra"
+)
+
+var imagesOutdatedAll bool
+var imagesOutdatedTemplates bool
+var imagesOutdatedFormat string
+
+var imagesOutdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List images with a newer digest or tag in their registry",
+	Long: `Compare the digest each image was last pulled at with the one its tag points
+at in the registry, and look for newer tags of the same shape ("1.21-alpine"
+leads to "1.23-alpine"). Checks the images 'cm images' lists and the current
+project's devcontainer.json image; --templates adds the templates' images.
+
+An image is "outdated" when its tag moved since the pull, and "not-pulled"
+when it isn't available locally. Registries are queried anonymously.
+
+EXAMPLES
+  cm images outdated
+  cm images outdated --all --templates
+  cm images outdated --format json`,
+	RunE: runImagesOutdated,
+}
+
+var imagesPinLatest bool
+
+var imagesPinCmd = &cobra.Command{
+	Use:   "pin [devcontainer.json]",
+	Short: "Pin a devcontainer.json image to its digest",
+	Long: `Rewrite the "image" of devcontainer.json to the digest it was pulled at, as
+"golang:1.22@sha256:...", so every rebuild uses the same image. With --latest
+the digest the tag points at in the registry is used instead. Comments and
+formatting are kept. Run 'cm images pin' again to move a pinned image forward.
+
+EXAMPLES
+  cm images pin
+  cm images pin --latest
+  cm images pin path/to/devcontainer.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImagesPin,
+}
+
+func init() {
+	imagesOutdatedCmd.Flags().BoolVar(&imagesOutdatedAll, "all", false, "Also list images that are up to date")
+	imagesOutdatedCmd.Flags().BoolVar(&imagesOutdatedTemplates, "templates", false, "Also check the templates' images, at their default options")
+	imagesOutdatedCmd.Flags().StringVar(&imagesOutdatedFormat, "format", "", output.FlagUsage)
+	imagesPinCmd.Flags().BoolVar(&imagesPinLatest, "latest", false, "Pin to the registry's current digest instead of the pulled one")
+	imagesCmd.AddCommand(imagesOutdatedCmd)
+	imagesCmd.AddCommand(imagesPinCmd)
+}
+
+func runImagesOutdated(cmd *cobra.Command, args []string) error {
+	if err := output.Validate(imagesOutdatedFormat); err != nil {
+		return err
+	}
+	if offline.Enabled() {
+		return fmt.Errorf("offline mode: 'cm images outdated' needs the network")
+	}
+
+	cfg, err := images.LoadConfig()
+	if err != nil {
+		return err
+	}
+	targets := images.Targets(cfg)
+	if path := projectConfigPath(); path != "" {
+		if devCfg, err := config.ParseConfig(path); err == nil && devCfg.Image != "" {
+			targets = append([]images.Target{{Name: path, Kind: "project", Image: devCfg.Image}}, targets...)
+		}
+	}
+	if imagesOutdatedTemplates {
+		targets = append(targets, templateTargets()...)
+	}
+	targets = uniqueTargets(targets)
+
+	if imagesOutdatedFormat == "" {
+		fmt.Printf("🔍 Checking %d image(s)...\n\n", len(targets))
+	}
+	statuses := images.CheckOutdated(cmd.Context(), cfg, targets)
+	images.SortStatuses(statuses)
+
+	shown := statuses
+	if !imagesOutdatedAll {
+		shown = nil
+		for _, s := range statuses {
+			if s.NeedsUpdate() || s.Status == images.StatusUnknown {
+				shown = append(shown, s)
+			}
+		}
+	}
+
+	return output.Print(os.Stdout, imagesOutdatedFormat, shown, func() error {
+		if len(shown) == 0 {
+			fmt.Println("✅ All images are up to date")
+			return nil
+		}
+		fmt.Printf("%-22s %-9s %-40s %-12s %-12s %-14s %s\n", "NAME", "KIND", "IMAGE", "PULLED", "LATEST", "NEWER TAG", "STATUS")
+		outdated := 0
+		for _, s := range shown {
+			status := s.Status
+			if s.Error != "" {
+				status += " (" + s.Error + ")"
+			}
+			if s.NeedsUpdate() {
+				outdated++
+			}
+			fmt.Printf("%-22s %-9s %-40s %-12s %-12s %-14s %s\n",
+				truncate(s.Name, 22), s.Kind, truncate(s.Image, 40),
+				valueOrDash(images.ShortDigest(s.Pulled)), valueOrDash(images.ShortDigest(s.Latest)),
+				valueOrDash(s.NewerTag), status)
+		}
+		if outdated > 0 {
+			fmt.Println("\n💡 Run 'cm images pull <name>' to update a preset, or 'cm images pin --latest' to pin the project to the newest digest")
+		}
+		return nil
+	})
+}
+
+func runImagesPin(cmd *cobra.Command, args []string) error {
+	path := projectConfigPath()
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if path == "" {
+		return fmt.Errorf("no devcontainer.json found; pass its path")
+	}
+
+	cfg, err := images.LoadConfig()
+	if err != nil {
+		return err
+	}
+	digest := func(ref string) (string, error) {
+		if !imagesPinLatest {
+			if d := images.PulledDigest(cfg, ref); d != "" {
+				return d, nil
+			}
+			fmt.Printf("⚠️  %s hasn't been pulled; pinning to the registry's current digest\n", ref)
+		}
+		if offline.Enabled() {
+			return "", offline.Missing("image digest", ref)
+		}
+		r, err := registry.ParseReference(ref)
+		if err != nil {
+			return "", err
+		}
+		if r.Tag == "" {
+			return r.Digest, nil
+		}
+		return registry.NewClient().Digest(cmd.Context(), r)
+	}
+
+	before, after, err := images.PinDevcontainer(path, digest)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		fmt.Printf("✅ %s is already pinned to %s\n", path, after)
+		return nil
+	}
+	fmt.Printf("📌 Pinned %s\n   %s\n → %s\n", path, before, after)
+	return nil
+}
+
+// projectConfigPath returns the devcontainer.json of the current project
+func projectConfigPath() string {
+	if configFile != "" {
+		return configFile
+	}
+	for _, path := range []string{".devcontainer/devcontainer.json", "devcontainer.json"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// templateTargets returns the images of all templates, rendered with their
+// default options
+func templateTargets() []images.Target {
+	all := template.GetAllTemplates()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []images.Target
+	for _, name := range names {
+		t, err := all[name].Render(nil)
+		if err != nil || t.Image == "" || strings.Contains(t.Image, "${") {
+			continue
+		}
+		targets = append(targets, images.Target{Name: name, Kind: "template", Image: t.Image})
+	}
+	return targets
+}
+
+// uniqueTargets drops later targets for an image already listed
+func uniqueTargets(targets []images.Target) []images.Target {
+	seen := make(map[string]bool)
+	var unique []images.Target
+	for _, t := range targets {
+		if seen[t.Image] {
+			continue
+		}
+		seen[t.Image] = true
+		unique = append(unique, t)
+	}
+	return unique
+}
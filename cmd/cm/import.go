@@ -16,19 +16,32 @@ var (
 	importOutput  string
 	importName    string
 	importAnalyze bool
+	importFiles   []string
 )
 
 var importCmd = &cobra.Command{
-	Use:   "import <source-file>",
+	Use:   "import [source-file]",
 	Short: "Import from existing configurations",
-	Long: `Import services from docker-compose.yml or Helm charts.
+	Long: `Import services from docker-compose.yml or Helm charts, or a
+dev environment from Gitpod, Codespaces, Vagrant or a Dockerfile.
 
 This command converts existing container orchestration configurations
-to Container-Maker workspace format.
+to Container-Maker workspace format, and single-environment configurations
+to .devcontainer/devcontainer.json.
+
+Like docker compose, --file merges several compose files in order, with
+later files overriding earlier ones, and extends is resolved. Without
+--file, an override file next to the compose file, such as
+docker-compose.override.yml, is merged too.
 
 SUPPORTED SOURCES
   - docker-compose.yml / docker-compose.yaml
   - compose.yml / compose.yaml
+  - .gitpod.yml (tasks, ports, image, extensions)
+  - .devcontainer/devcontainer.json written for Codespaces
+    (secrets, updateContentCommand, codespaces customizations)
+  - Vagrantfile (box, synced folders, forwarded ports, shell provisioners)
+  - Dockerfile
   - Helm charts (coming soon)
 
 EXAMPLES
@@ -36,26 +49,46 @@ EXAMPLES
   cm import docker-compose.yml --output cm-workspace.yaml
   cm import docker-compose.yml --analyze
   cm import docker-compose.yml --dry-run
+  cm import -f docker-compose.yml -f docker-compose.prod.yml
+  cm import .gitpod.yml
+  cm import .devcontainer/devcontainer.json --analyze
+  cm import Vagrantfile
 
 The importer will:
   1. Parse the source configuration
   2. Analyze compatibility with Container-Maker
   3. Convert services, networks, and volumes
   4. Generate warnings for unsupported features`,
-	Args: cobra.ExactArgs(1),
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		sourcePath := args[0]
+		files := append(args, importFiles...)
+		if len(files) == 0 {
+			return fmt.Errorf("no source file given")
+		}
+		sourcePath := files[0]
 
 		// Check file exists
 		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
 			return fmt.Errorf("file not found: %s", sourcePath)
 		}
 
+		if dcImporter := selectDevcontainerImporter(sourcePath); dcImporter != nil {
+			if len(files) > 1 {
+				return fmt.Errorf("only Docker Compose files can be merged")
+			}
+			return runDevcontainerImport(dcImporter, sourcePath)
+		}
+
 		// Determine importer
 		importer := selectImporter(sourcePath)
 		if importer == nil {
 			return fmt.Errorf("unsupported file format: %s", sourcePath)
 		}
+		if composeImporter, ok := importer.(*imports.ComposeImporter); ok {
+			composeImporter.Overrides = files[1:]
+		} else if len(files) > 1 {
+			return fmt.Errorf("only Docker Compose files can be merged")
+		}
 
 		// Analyze only mode
 		if importAnalyze {
@@ -93,6 +126,91 @@ func selectImporter(path string) imports.Importer {
 	return nil
 }
 
+func selectDevcontainerImporter(path string) imports.DevcontainerImporter {
+	for _, importer := range imports.DevcontainerImporters() {
+		if importer.CanHandle(path) {
+			return importer
+		}
+	}
+	return nil
+}
+
+// runDevcontainerImport converts a single-environment config to devcontainer.json
+func runDevcontainerImport(importer imports.DevcontainerImporter, sourcePath string) error {
+	absSource, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return err
+	}
+	projectDir := filepath.Dir(absSource)
+	if filepath.Base(projectDir) == ".devcontainer" {
+		projectDir = filepath.Dir(projectDir)
+	}
+
+	result, err := importer.Convert(sourcePath, projectDir)
+	if err != nil {
+		return err
+	}
+
+	if importAnalyze {
+		printDevcontainerCompatibility(result)
+		fmt.Println()
+		fmt.Println("Run 'cm import " + sourcePath + "' to perform the import.")
+		return nil
+	}
+
+	if importDryRun {
+		data, _ := result.JSON()
+		fmt.Println(string(data))
+		printDevcontainerCompatibility(result)
+		return nil
+	}
+
+	outputPath := importOutput
+	if outputPath == "" {
+		outputPath = filepath.Join(projectDir, ".devcontainer", "devcontainer.json")
+	}
+	if _, err := os.Stat(outputPath); err == nil && result.Source != imports.SourceCodespaces {
+		return fmt.Errorf("%s already exists, use --output to write elsewhere", outputPath)
+	}
+
+	path, err := result.WriteTo(outputPath)
+	if err != nil {
+		return err
+	}
+	printDevcontainerResult(result, path)
+	return nil
+}
+
+// printDevcontainerCompatibility shows how much of a source config CM can honour
+func printDevcontainerCompatibility(result *imports.DevcontainerResult) {
+	fmt.Println()
+	fmt.Println("COMPATIBILITY REPORT")
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("Source: %s (%s)\n", result.SourceFile, result.Source)
+	fmt.Printf("Score: %d/100\n", result.Compatibility.Score)
+	fmt.Printf("Fully Supported: %s\n", listOrDash(result.Compatibility.FullySupported))
+	fmt.Printf("Partial Support: %s\n", listOrDash(result.Compatibility.PartialSupport))
+	fmt.Printf("Not Supported: %s\n", listOrDash(result.Compatibility.NotSupported))
+
+	if len(result.Warnings) > 0 {
+		fmt.Println()
+		fmt.Println("WARNINGS")
+		for _, w := range result.Warnings {
+			fmt.Printf("  [%s] %s\n", w.Code, w.Message)
+			if w.Suggestion != "" {
+				fmt.Printf("      Suggestion: %s\n", w.Suggestion)
+			}
+		}
+	}
+}
+
+func listOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ", ")
+}
+
 func runAnalysis(importer imports.Importer, path string) error {
 	fmt.Printf("Analyzing %s...\n\n", filepath.Base(path))
 
@@ -207,9 +325,13 @@ func printImportResult(result *imports.ImportResult, dryRun bool) {
 var importAnalyzeCmd = &cobra.Command{
 	Use:   "analyze <source-file>",
 	Short: "Analyze a configuration file",
-	Long:  "Analyze a docker-compose.yml or Helm chart for CM compatibility.",
+	Long:  "Analyze a docker-compose.yml, .gitpod.yml, Codespaces devcontainer.json, Vagrantfile or Helm chart for CM compatibility.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dcImporter := selectDevcontainerImporter(args[0]); dcImporter != nil {
+			importAnalyze = true
+			return runDevcontainerImport(dcImporter, args[0])
+		}
 		importer := selectImporter(args[0])
 		if importer == nil {
 			return fmt.Errorf("unsupported file format")
@@ -224,7 +346,62 @@ func init() {
 	importCmd.Flags().StringVarP(&importOutput, "output", "o", "", "Output file path")
 	importCmd.Flags().StringVar(&importName, "name", "", "Project name")
 	importCmd.Flags().BoolVar(&importAnalyze, "analyze", false, "Analyze only, don't import")
+	importCmd.Flags().StringArrayVarP(&importFiles, "file", "f", nil, "Compose file to merge, in order, like docker compose -f (repeatable)")
 
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(importAnalyzeCmd)
 }
+
+// runInitFromDockerfile converts an existing Dockerfile into .devcontainer/devcontainer.json
+func runInitFromDockerfile(dockerfile string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(".devcontainer", "devcontainer.json")
+	if _, err := os.Stat(configPath); err == nil {
+		fmt.Printf("⚠️  %s already exists. Overwrite? [y/N] ", configPath)
+		var response string
+		_, _ = fmt.Scanln(&response)
+		if strings.ToLower(response) != "y" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	fmt.Printf("🔍 Analyzing %s...\n", dockerfile)
+	result, err := imports.ConvertDockerfile(dockerfile, cwd)
+	if err != nil {
+		return err
+	}
+
+	path, err := result.Write(cwd)
+	if err != nil {
+		return err
+	}
+	printDevcontainerResult(result, path)
+	return nil
+}
+
+// printDevcontainerResult shows the generated config and anything that needs review
+func printDevcontainerResult(result *imports.DevcontainerResult, path string) {
+	data, _ := result.JSON()
+	fmt.Println()
+	fmt.Println(string(data))
+
+	if len(result.Warnings) > 0 {
+		fmt.Println()
+		fmt.Println("REVIEW")
+		for _, w := range result.Warnings {
+			fmt.Printf("  [%s] %s\n", w.Code, w.Message)
+			if w.Suggestion != "" {
+				fmt.Printf("      Suggestion: %s\n", w.Suggestion)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Created %s\n", path)
+	fmt.Println("🚀 Run 'cm shell' to start your dev container")
+}
@@ -12,16 +12,23 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/cudacheck"
 	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/UPwith-me/Container-Maker/pkg/history"
+	"github.com/UPwith-me/Container-Maker/pkg/hostreq"
 	"github.com/UPwith-me/Container-Maker/pkg/images"
 	mkpkg "github.com/UPwith-me/Container-Maker/pkg/make"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
 	"github.com/UPwith-me/Container-Maker/pkg/plugin"
 	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/UPwith-me/Container-Maker/pkg/runtime"
 	"github.com/UPwith-me/Container-Maker/pkg/template"
 	"github.com/UPwith-me/Container-Maker/pkg/tui"
 	"github.com/UPwith-me/Container-Maker/pkg/update"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
 	"github.com/UPwith-me/Container-Maker/pkg/watch"
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
@@ -33,6 +40,11 @@ var (
 )
 
 var configFile string
+var offlineMode bool
+var ignoreHostRequirements bool
+var containerRef string
+var publishAllPorts bool
+var remapPorts bool
 
 var rootCmd = &cobra.Command{
 	Use:   "cm",
@@ -67,10 +79,29 @@ EXAMPLES
   $ cm code
 
   # Deploy to cloud
-  $ cm cloud deploy --provider aws`,
+  $ cm cloud create --provider aws`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		// Only show welcome on init command
-		if cmd.Name() == "init" {
+		if offlineMode {
+			offline.Enable()
+		}
+		if verifyStrict {
+			verify.EnableStrict()
+		}
+		if ignoreHostRequirements {
+			hostreq.Ignore()
+			cudacheck.Ignore()
+		}
+		if containerRef != "" {
+			runner.UseContainer(containerRef)
+		}
+		if publishAllPorts {
+			runner.PublishAllPorts()
+		}
+		if remapPorts {
+			runner.RemapBusyPorts()
+		}
+		// Only show welcome on init command, not when printing shell snippets
+		if cmd.Name() == "init" && !cmd.Flags().Changed("shell") {
 			tui.RenderWelcome()
 		}
 		// Check PATH setup on first run (only for root command)
@@ -79,8 +110,10 @@ EXAMPLES
 		}
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		// Run smart update check (non-blocking)
-		update.CheckForUpdates(Version)
+		// Run smart update check (non-blocking), but not on every shell prompt
+		if !offline.Enabled() && cmd.Name() != "prompt" {
+			update.CheckForUpdates(Version)
+		}
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Show smart home screen when cm is run without arguments
@@ -88,12 +121,57 @@ EXAMPLES
 	},
 }
 
-var runCmd = &cobra.Command{
+var (
+	runRemove bool
+	runName   string
+	runDetach bool
+	runImage  string
+
+	runProfiles     []string
+	runComposeFiles []string
+)
 
+var runCmd = &cobra.Command{
 	Use:   "run [command]",
 	Short: "Run a command inside the dev container",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Run a command in a new dev container, removed when the command exits.
+
+Like docker run, --rm=false keeps the container for postmortem debugging,
+--name names it, and --detach starts it in the background and prints its ID.
+
+--remote runs the command on another machine instead: a host added with
+'cm remote add', a cloud instance by ID or name, or an instance type such
+as gpu-t4. The workspace is synced there, the command runs in the project's
+dev container with its output streamed back, and the paths given with
+--artifact are copied back afterwards. An instance provisioned for an
+instance type is stopped when the command ends (unless --keep-remote) and
+reused by the next run.
+
+For Docker Compose dev containers, --profile also starts the services of
+a compose profile and --file merges more compose files over the config's,
+like docker compose --profile and -f.
+
+Examples:
+  cm run -- make test
+  cm run --rm=false -- ./flaky-test.sh
+  cm run --name api-dev --detach -- npm start
+  cm run --remote gpu-t4 --artifact checkpoints -- python train.py
+  cm run --remote myserver -- make bench
+  cm run --profile debug -- ./debug.sh
+  cm run -f docker-compose.ci.yml -- make integration`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if runRemote != "" {
+			return recordHistory("run", args, func(e *history.Entry) error {
+				code, err := runOnRemote(runRemote, cmd.Flags(), args)
+				e.ExitCode = code
+				return err
+			})
+		}
+		if len(runArtifacts) > 0 || runKeepRemote {
+			return fmt.Errorf("--artifact and --keep-remote need --remote")
+		}
+
 		// Default config paths
 		if configFile == "" {
 			if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
@@ -105,7 +183,7 @@ var runCmd = &cobra.Command{
 			}
 		}
 
-		cfg, err := config.ParseConfig(configFile)
+		cfg, err := parseDevConfig(configFile)
 		if err != nil {
 			return err
 		}
@@ -117,7 +195,15 @@ var runCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			return cr.Run(context.Background(), args)
+			cr.Profiles = runProfiles
+			cr.Files = runComposeFiles
+			return recordHistory("run", args, func(*history.Entry) error {
+				return cr.Run(context.Background(), args)
+			})
+		}
+
+		if len(runProfiles) > 0 || len(runComposeFiles) > 0 {
+			return fmt.Errorf("--profile and --file need a Docker Compose dev container")
 		}
 
 		// Standard container mode
@@ -125,8 +211,18 @@ var runCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		r.KeepContainer = !runRemove
+		r.Name = runName
+		r.Detach = runDetach
+		r.Image = runImage
 
-		return r.Run(context.Background(), args)
+		return recordHistory("run", args, func(e *history.Entry) error {
+			err := r.Run(context.Background(), args)
+			e.ExitCode = r.ExitCode
+			e.Image = r.Config.Image
+			e.ImageDigest, _ = runner.ImageID(context.Background(), "docker", r.Config.Image)
+			return err
+		})
 	},
 }
 
@@ -145,7 +241,7 @@ var prepareCmd = &cobra.Command{
 			}
 		}
 
-		cfg, err := config.ParseConfig(configFile)
+		cfg, err := parseDevConfig(configFile)
 		if err != nil {
 			return err
 		}
@@ -157,6 +253,8 @@ var prepareCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			cr.Profiles = runProfiles
+			cr.Files = runComposeFiles
 			return cr.Prepare(context.Background())
 		}
 
@@ -178,7 +276,9 @@ var prepareCmd = &cobra.Command{
 }
 
 var applyShell bool
+var autoEnter bool
 var shellType string
+var initFromDockerfile string
 
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -186,18 +286,22 @@ var initCmd = &cobra.Command{
 	Long:  `Initialize a new DevContainer project or generate shell integration scripts.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If --apply or --shell is used, run shell integration logic
-		if applyShell || cmd.Flags().Changed("shell") {
+		if applyShell || autoEnter || cmd.Flags().Changed("shell") {
 			return runShellIntegration(cmd, args)
 		}
 
+		if cmd.Flags().Changed("from-dockerfile") {
+			return runInitFromDockerfile(initFromDockerfile)
+		}
+
 		// Otherwise, run the interactive wizard
 		fmt.Println("🚀 Initializing new DevContainer project...")
-		template, err := tui.RunInitWizard()
+		templateID, err := tui.RunInitWizard()
 		if err != nil {
 			return err
 		}
 
-		if template == "" {
+		if templateID == "" {
 			return nil // Cancelled
 		}
 
@@ -213,8 +317,23 @@ var initCmd = &cobra.Command{
 			}
 		}
 
+		// Ask for template options (versions, GPU, ...)
+		var options map[string]string
+		if t, ok := template.BuiltInTemplates()[templateID]; ok && len(t.Options) > 0 {
+			options, err = tui.RunOptionsPrompt("Template options for "+templateID+":", t.Options, nil)
+			if err != nil {
+				return err
+			}
+			if options == nil {
+				return nil // Cancelled
+			}
+		}
+
 		// Generate config content
-		content := tui.GenerateConfig(template)
+		content, err := tui.GenerateConfigWithOptions(templateID, options)
+		if err != nil {
+			return err
+		}
 
 		// Create directory
 		if err := os.MkdirAll(".devcontainer", 0755); err != nil {
@@ -226,11 +345,33 @@ var initCmd = &cobra.Command{
 			return fmt.Errorf("failed to write config file: %w", err)
 		}
 
-		tui.RenderBox("Success!", fmt.Sprintf("Created %s\nSelected Template: %s", configPath, template))
+		// Remember the generated config so 'cm template update' can merge later changes
+		if _, ok := template.BuiltInTemplates()[templateID]; ok {
+			_ = template.SaveTemplateBase(".", []byte(content))
+		}
+
+		tui.RenderBox("Success!", fmt.Sprintf("Created %s\nSelected Template: %s", configPath, templateID))
 		return nil
 	},
 }
 
+// starshipSnippet is a starship custom module showing 'cm prompt'
+const starshipSnippet = `[custom.cm]
+command = "cm prompt"
+detect_files = ["devcontainer.json"]
+detect_folders = [".devcontainer"]
+format = "[$output]($style) "
+style = "bold cyan"
+`
+
+// p10kSnippet is a powerlevel10k segment showing 'cm prompt'
+const p10kSnippet = `function prompt_cm() {
+  local out
+  out=$(cm prompt 2>/dev/null) || return
+  [[ -n $out ]] && p10k segment -f 39 -t "$out"
+}
+`
+
 func runShellIntegration(_ *cobra.Command, _ []string) error {
 	// Shell integration script content
 	shellScript := `
@@ -250,12 +391,32 @@ end
 # End Container-Maker Integration
 `
 
+	// Prompt frameworks get a 'cm prompt' segment instead
+	switch shellType {
+	case "starship":
+		fmt.Println("# Add this to ~/.config/starship.toml")
+		fmt.Print(starshipSnippet)
+		return nil
+	case "p10k", "powerlevel10k":
+		fmt.Println("# Add this to ~/.p10k.zsh, then add 'cm' to POWERLEVEL9K_LEFT_PROMPT_ELEMENTS")
+		fmt.Print(p10kSnippet)
+		return nil
+	}
+
+	// Opt-in hook that runs commands in the dev container after cd
+	withAutoEnter := func(script, hook string) string {
+		if !autoEnter {
+			return script
+		}
+		return strings.Replace(script, "# End Container-Maker Integration", hook+"\n# End Container-Maker Integration", 1)
+	}
+
 	if !applyShell {
 		// Just print the script
 		fmt.Println("# Add this to your shell configuration (.bashrc, .zshrc, etc.)")
-		fmt.Println(shellScript)
+		fmt.Println(withAutoEnter(shellScript, `eval "$(cm hook bash)"  # zsh: eval "$(cm hook zsh)"`))
 		fmt.Println("# For Fish shell, use:")
-		fmt.Println(fishScript)
+		fmt.Println(withAutoEnter(fishScript, "cm hook fish | source"))
 		return nil
 	}
 
@@ -283,13 +444,13 @@ end
 	switch detectedShell {
 	case "zsh":
 		configPath = filepath.Join(homeDir, ".zshrc")
-		script = shellScript
+		script = withAutoEnter(shellScript, `eval "$(cm hook zsh)"`)
 	case "fish":
 		configPath = filepath.Join(homeDir, ".config", "fish", "config.fish")
-		script = fishScript
+		script = withAutoEnter(fishScript, "cm hook fish | source")
 	default: // bash
 		configPath = filepath.Join(homeDir, ".bashrc")
-		script = shellScript
+		script = withAutoEnter(shellScript, `eval "$(cm hook bash)"`)
 	}
 
 	// Check if already integrated
@@ -365,19 +526,110 @@ func Execute() {
 	}
 }
 
+var statusShort bool
+var statusFormat string
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show running container status dashboard",
-	Long:  `Launch an interactive dashboard to view running containers, their stats, ports, and access logs or shell.`,
+	Long: `Launch an interactive dashboard of the containers of the active backend
+(Docker or Podman): live CPU and memory sparklines, ports, and a scrollable
+log pane for the selected container.
+
+Keys: s shell, x stop, R restart, p pause/resume, l follow logs,
+tab scroll the log pane, r refresh, q quit.
+
+With --short, print a plain summary of the current project's persistent
+container instead: state, backend, image, forwarded ports (and those moved
+off busy host ports by --remap-ports), the result of
+the last lifecycle command and whether devcontainer.json changed since the
+container was created. --format prints the same summary as JSON, YAML or
+through a Go template, e.g. for a shell prompt:
+
+  cm status --format '{{.State}}'`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return tui.RunStatusDashboard()
+		if !statusShort && statusFormat == "" {
+			return tui.RunStatusDashboard()
+		}
+		if err := output.Validate(statusFormat); err != nil {
+			return err
+		}
+
+		cfg, projectDir, err := findDevConfig()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if projectDir == "" {
+			projectDir, _ = os.Getwd()
+		}
+		pr, err := runner.NewPersistentRunner(cfg, projectDir)
+		if err != nil {
+			return err
+		}
+
+		summary := pr.Summary(context.Background())
+		return output.Print(os.Stdout, statusFormat, summary, func() error {
+			printStatusSummary(summary)
+			return nil
+		})
 	},
 }
 
+// printStatusSummary prints the --short view of a project's container
+func printStatusSummary(s *runner.StatusSummary) {
+	icon := map[string]string{"running": "🟢", "stopped": "🔴", "paused": "⏸️ "}[s.State]
+	if icon == "" {
+		icon = "⚪"
+	}
+	fmt.Printf("%s %s: %s (%s)\n", icon, s.Project, s.State, s.Backend)
+	if s.State == "none" {
+		fmt.Println("   No persistent container. Start one with 'cm shell'.")
+	}
+
+	if s.ImageTag != "" {
+		image := s.ImageTag
+		if id := strings.TrimPrefix(s.ImageID, "sha256:"); len(id) >= 12 {
+			image += " @ " + id[:12]
+		}
+		fmt.Printf("   Image:  %s\n", image)
+	}
+	if s.ImageStale {
+		fmt.Println("           the tag now points to a newer image; 'cm shell --rebuild' picks it up")
+	}
+	if len(s.ConfigHash) >= 8 {
+		fmt.Printf("   Config: %s", s.ConfigHash[:8])
+		if s.ConfigDrift {
+			fmt.Printf(" (devcontainer.json changed since; 'cm shell --rebuild' applies it)")
+		}
+		fmt.Println()
+	}
+	if len(s.Ports) > 0 {
+		fmt.Printf("   Ports:  %s\n", strings.Join(s.Ports, ", "))
+	}
+	if len(s.Remapped) > 0 {
+		var remaps []string
+		for requested, used := range s.Remapped {
+			port, _, _ := strings.Cut(requested, "/")
+			remaps = append(remaps, fmt.Sprintf("%s -> %s (CM_PORT_%s)", requested, used, port))
+		}
+		sort.Strings(remaps)
+		fmt.Printf("   Moved:  %s\n", strings.Join(remaps, ", "))
+	}
+	if h := s.LastHook; h != nil {
+		result := "✅ succeeded"
+		if !h.Succeeded {
+			result = "❌ failed: " + h.Error
+		}
+		fmt.Printf("   Hook:   %s %s (%s ago)\n", h.Name, result, time.Since(h.FinishedAt).Round(time.Second))
+	}
+}
+
 var shellStop bool
 var shellRebuild bool
 var shellPause bool
 var shellResume bool
+var shellCloud bool
+var execService string
 
 var shellCmd = &cobra.Command{
 	Use:   "shell",
@@ -388,8 +640,13 @@ Flags:
   --stop     Stop and remove the container
   --pause    Save container state and stop (frees memory, preserves environment)
   --resume   Restore from saved snapshot
-  --rebuild  Rebuild the container from scratch`,
+  --rebuild  Rebuild the container from scratch
+  --cloud    Enter the container started by 'cm cloud dev' on its cloud instance`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if shellCloud {
+			return cloudDevShell()
+		}
+
 		cfg, projectDir, err := loadConfig()
 		if err != nil {
 			return err
@@ -424,23 +681,66 @@ Flags:
 var execCmd = &cobra.Command{
 	Use:   "exec [command]",
 	Short: "Execute a command in the persistent container",
-	Long:  `Execute a command in the persistent dev container. If no container is running, one will be started automatically.`,
-	Args:  cobra.MinimumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, projectDir, err := loadConfig()
-		if err != nil {
-			return err
-		}
+	Long: `Execute a command in the persistent dev container. If no container is running, one will be started automatically.
 
-		pr, err := runner.NewPersistentRunner(cfg, projectDir)
-		if err != nil {
-			return err
-		}
+Repeated execs take a fast path straight to the Docker API while the
+container is known to be ready: for CM_READY_TTL (default 60s) after the
+last full check, or indefinitely while 'cm agent' is running.
 
-		return pr.Exec(context.Background(), args)
+In a workspace (cm-workspace.yaml, or one 'cm mono up' generated),
+--service runs the command in that service's container instead:
+
+  cm exec --service api -- go test ./...`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if execService != "" {
+			return recordHistory("exec", args, func(*history.Entry) error {
+				return execInService(execService, args)
+			})
+		}
+		return recordHistory("exec", args, func(*history.Entry) error {
+			return execInProject(args)
+		})
 	},
 }
 
+// execInService runs a command in a running workspace service
+func execInService(service string, command []string) error {
+	ws, err := workspace.Load("")
+	if err != nil {
+		return err
+	}
+	if _, err := ws.GetService(service); err != nil {
+		return err
+	}
+	orch, err := workspace.NewOrchestrator(ws)
+	if err != nil {
+		return err
+	}
+	defer orch.Close()
+	return orch.Exec(context.Background(), service, command)
+}
+
+// execInProject runs a command in the persistent container of the project
+// in the current directory, starting it if needed
+func execInProject(command []string) error {
+	cfg, projectDir, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if handled, err := runner.FastExec(context.Background(), cfg, projectDir, command); handled {
+		return err
+	}
+
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+
+	return pr.Exec(context.Background(), command)
+}
+
 // loadConfig loads the devcontainer.json and returns config and project directory
 // loadConfig loads the devcontainer.json and returns config and project directory
 // If no config exists, it triggers auto-detection
@@ -459,7 +759,7 @@ func loadConfig() (*config.DevContainerConfig, string, error) {
 
 	// If config exists, use it
 	if configPath != "" {
-		cfg, err := config.ParseConfig(configPath)
+		cfg, err := parseDevConfig(configPath)
 		if err != nil {
 			return nil, "", err
 		}
@@ -478,6 +778,20 @@ func loadConfig() (*config.DevContainerConfig, string, error) {
 	return loadConfigWithAutoDetect(projectDir)
 }
 
+// findDevConfig parses the project's devcontainer.json without loadConfig's
+// auto-detection and policy output, for commands that must not prompt or
+// print, such as completions and 'cm status --short'
+func findDevConfig() (*config.DevContainerConfig, string, error) {
+	projectDir, _ := os.Getwd()
+	for _, path := range []string{".devcontainer/devcontainer.json", "devcontainer.json"} {
+		if _, err := os.Stat(path); err == nil {
+			cfg, err := config.ParseConfig(path)
+			return cfg, projectDir, err
+		}
+	}
+	return nil, "", os.ErrNotExist
+}
+
 // loadConfigWithAutoDetect uses project type detection to create a temporary config
 func loadConfigWithAutoDetect(projectDir string) (*config.DevContainerConfig, string, error) {
 	result := detect.DetectProjectType(projectDir)
@@ -490,7 +804,7 @@ func loadConfigWithAutoDetect(projectDir string) (*config.DevContainerConfig, st
 
 		// Check if config was created
 		if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
-			cfg, err := config.ParseConfig(".devcontainer/devcontainer.json")
+			cfg, err := parseDevConfig(".devcontainer/devcontainer.json")
 			if err != nil {
 				return nil, "", err
 			}
@@ -506,9 +820,27 @@ func loadConfigWithAutoDetect(projectDir string) (*config.DevContainerConfig, st
 		return nil, "", err
 	}
 
-	// Save config if requested
+	// Save config if requested: review the full proposal, falling back to
+	// an image-only config when nothing more could be proposed
 	if saveConfig {
-		if err := detect.CreateDevcontainerConfig(projectDir, image, result.Primary.Language); err != nil {
+		if proposal, err := detect.Propose(projectDir); err == nil {
+			fmt.Println()
+			fmt.Println("📋 Proposed .devcontainer/devcontainer.json:")
+			fmt.Println()
+			fmt.Print(proposal.Format(nil))
+			fmt.Println()
+			fmt.Print("Write this devcontainer.json? [Y/n] ")
+			var response string
+			_, _ = fmt.Scanln(&response)
+			if response != "" && strings.ToLower(response) != "y" {
+				fmt.Println("Not saved; using the image for this run only.")
+			} else if err := proposal.Write(projectDir, nil); err != nil {
+				fmt.Printf("⚠️  Failed to create devcontainer.json: %v\n", err)
+			} else {
+				fmt.Println("✅ Created .devcontainer/devcontainer.json")
+				return loadConfig()
+			}
+		} else if err := detect.CreateDevcontainerConfig(projectDir, image, result.Primary.Language); err != nil {
 			fmt.Printf("⚠️  Failed to create devcontainer.json: %v\n", err)
 		} else {
 			fmt.Println("✅ Created .devcontainer/devcontainer.json")
@@ -527,23 +859,61 @@ func main() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(prepareCmd)
 	rootCmd.AddCommand(initCmd)
+	statusCmd.Flags().BoolVar(&statusShort, "short", false, "Print a plain summary of the current project instead of the dashboard")
+	statusCmd.Flags().StringVar(&statusFormat, "format", "", output.FlagUsage)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(shellCmd)
 	rootCmd.AddCommand(execCmd)
 
+	// Host requirements are checked where the image is built or the container created
+	for _, cmd := range []*cobra.Command{runCmd, prepareCmd, shellCmd, execCmd} {
+		cmd.Flags().BoolVar(&ignoreHostRequirements, "ignore-host-requirements", false, "Start even when the host has fewer CPUs, memory or storage than hostRequirements asks for, or a driver too old for the image's CUDA")
+	}
+	// Ports are published by the commands that create the project's container
+	for _, cmd := range []*cobra.Command{runCmd, shellCmd, execCmd} {
+		cmd.Flags().BoolVar(&publishAllPorts, "publish-all", false, "Publish every port the image exposes on a random host port, like publishAllPorts in devcontainer.json")
+		cmd.Flags().BoolVar(&remapPorts, "remap-ports", false, "Forward ports whose host port is busy to a free port at a stable per-project offset instead of skipping them (or 'cm config set ports.remap true')")
+	}
+	// The commands that use the project's persistent container
+	for _, cmd := range []*cobra.Command{shellCmd, execCmd, makeCmd, statusCmd, debugCmd, diffCmd, notebookCmd, profileCmd, snapshotCmd, sshCmd} {
+		cmd.PersistentFlags().StringVar(&containerRef, "container", "", "Use this container, by name or ID, as the project's persistent container and remember the choice")
+		_ = cmd.RegisterFlagCompletionFunc("container", completeContainers)
+	}
+	// The commands that download images, features or templates
+	for _, cmd := range []*cobra.Command{runCmd, prepareCmd, shellCmd, execCmd, makeCmd, debugCmd, notebookCmd, sshCmd, ciCmd, initCmd, templateCmd, featureCmd, marketplaceCmd, imagesCmd, teamCmd, upgradeCmd} {
+		cmd.PersistentFlags().BoolVar(&offlineMode, "offline", false, "Never use the network; images, features and templates must be available locally (see 'cm bundle')")
+	}
 	runCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	runCmd.Flags().BoolVar(&runRemove, "rm", true, "Remove the container when the command exits (--rm=false keeps it)")
+	runCmd.Flags().StringVar(&runName, "name", "", "Name the container")
+	runCmd.Flags().BoolVarP(&runDetach, "detach", "d", false, "Run in the background and print the container ID")
+	runCmd.Flags().StringVar(&runImage, "image", "", "Run this image instead of building or pulling the config's (used by 'cm rerun')")
+	runCmd.Flags().StringVar(&runRemote, "remote", "", "Run on a remote host, cloud instance, or a new instance of this type (e.g. gpu-t4)")
+	runCmd.Flags().StringVar(&runRemoteProvider, "remote-provider", "aws", "Cloud provider of instances provisioned by --remote")
+	runCmd.Flags().StringArrayVar(&runArtifacts, "artifact", nil, "Copy this workspace path back from the remote host afterwards (repeatable)")
+	runCmd.Flags().BoolVar(&runKeepRemote, "keep-remote", false, "Leave an instance provisioned by --remote running")
+	runCmd.Flags().StringArrayVar(&runProfiles, "profile", nil, "Also start the services of this Docker Compose profile (repeatable)")
+	runCmd.Flags().StringArrayVarP(&runComposeFiles, "file", "f", nil, "Merge this Docker Compose file over the config's, in order (repeatable)")
 	prepareCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	prepareCmd.Flags().StringArrayVar(&runProfiles, "profile", nil, "Also build the services of this Docker Compose profile (repeatable)")
+	prepareCmd.Flags().StringArrayVarP(&runComposeFiles, "file", "f", nil, "Merge this Docker Compose file over the config's, in order (repeatable)")
 	initCmd.Flags().BoolVarP(&applyShell, "apply", "a", false, "Automatically apply shell integration to config file")
-	initCmd.Flags().StringVarP(&shellType, "shell", "s", "", "Shell type (bash, zsh, fish). Auto-detected if not specified")
+	initCmd.Flags().BoolVar(&autoEnter, "auto-enter", false, "Include the hook that runs commands in the dev container after cd into an allowed project (see 'cm allow')")
+	initCmd.Flags().StringVarP(&shellType, "shell", "s", "", "Shell type (bash, zsh, fish), or a prompt framework (starship, p10k) to print a 'cm prompt' segment for. Auto-detected if not specified")
+	initCmd.Flags().StringVar(&initFromDockerfile, "from-dockerfile", "", "Generate devcontainer.json from an existing Dockerfile")
+	initCmd.Flags().Lookup("from-dockerfile").NoOptDefVal = "Dockerfile"
 
 	shellCmd.Flags().BoolVar(&shellStop, "stop", false, "Stop the persistent container")
 	shellCmd.Flags().BoolVar(&shellRebuild, "rebuild", false, "Rebuild the container")
 	shellCmd.Flags().BoolVar(&shellPause, "pause", false, "Save container state and stop (frees memory)")
 	shellCmd.Flags().BoolVar(&shellResume, "resume", false, "Restore from saved snapshot")
 	shellCmd.Flags().Bool("status", false, "Show persistent container status")
+	shellCmd.Flags().BoolVar(&shellCloud, "cloud", false, "Enter the project's dev container on its cloud instance")
 	shellCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
 
 	execCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	execCmd.Flags().StringVar(&execService, "service", "", "Run in this workspace service's container")
+	_ = execCmd.RegisterFlagCompletionFunc("service", completeServices)
 
 	makeCmd.Flags().BoolVar(&makeList, "list", false, "List available Makefile targets")
 	makeCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
@@ -554,8 +924,9 @@ func main() {
 var makeList bool
 
 var makeCmd = &cobra.Command{
-	Use:   "make [target...]",
-	Short: "Run Makefile targets in the dev container",
+	Use:               "make [target...]",
+	ValidArgsFunction: completeMakeTargets,
+	Short:             "Run Makefile targets in the dev container",
 	Long: `Run make targets inside the dev container.
 
 Examples:
@@ -582,48 +953,57 @@ Examples:
 			return nil
 		}
 
-		// Load config
-		cfg, projectDir, err := loadConfig()
-		if err != nil {
-			return err
-		}
-
-		// Show current image info
-		if cfg.Image != "" {
-			fmt.Printf("📋 Using image: %s\n", cfg.Image)
-		}
-
-		// Create persistent runner
-		pr, err := runner.NewPersistentRunner(cfg, projectDir)
-		if err != nil {
-			return err
-		}
-
 		// Build make command
 		makeArgs := []string{"make"}
 		makeArgs = append(makeArgs, args...)
 
-		// Execute in container
-		err = pr.Exec(context.Background(), makeArgs)
-
-		// Check for 'make not found' error and provide helpful hints
-		if err != nil && strings.Contains(err.Error(), "127") {
-			fmt.Println("\n⚠️  'make' is not installed in this container image.")
-			fmt.Println("\n💡 Suggested solutions:")
-			fmt.Println("   1. Use an image with make pre-installed:")
-			fmt.Println("      • gcc:latest (C/C++ projects)")
-			fmt.Println("      • golang:latest (Go projects)")
-			fmt.Println("      • node:latest (Node.js projects)")
-			fmt.Println("      • mcr.microsoft.com/devcontainers/base:debian")
-			fmt.Println("\n   2. Install make in your current container:")
-			fmt.Println("      cm exec apt-get update && apt-get install -y make")
-			fmt.Println("      cm shell --pause  # Save the changes")
-			fmt.Printf("\n   Current image: %s\n", cfg.Image)
-			return nil // Don't show the raw error
-		}
+		return recordHistory("make", makeArgs, func(e *history.Entry) error {
+			return runMake(makeArgs, e)
+		})
+	},
+}
 
+// runMake runs make in the persistent container, explaining how to get make
+// when the image lacks it
+func runMake(makeArgs []string, e *history.Entry) error {
+	// Load config
+	cfg, projectDir, err := loadConfig()
+	if err != nil {
 		return err
-	},
+	}
+
+	// Show current image info
+	if cfg.Image != "" {
+		fmt.Printf("📋 Using image: %s\n", cfg.Image)
+	}
+
+	// Create persistent runner
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+
+	// Execute in container
+	err = pr.Exec(context.Background(), makeArgs)
+
+	// Check for 'make not found' error and provide helpful hints
+	if err != nil && strings.Contains(err.Error(), "127") {
+		fmt.Println("\n⚠️  'make' is not installed in this container image.")
+		fmt.Println("\n💡 Suggested solutions:")
+		fmt.Println("   1. Use an image with make pre-installed:")
+		fmt.Println("      • gcc:latest (C/C++ projects)")
+		fmt.Println("      • golang:latest (Go projects)")
+		fmt.Println("      • node:latest (Node.js projects)")
+		fmt.Println("      • mcr.microsoft.com/devcontainers/base:debian")
+		fmt.Println("\n   2. Install make in your current container:")
+		fmt.Println("      cm exec apt-get update && apt-get install -y make")
+		fmt.Println("      cm shell --pause  # Save the changes")
+		fmt.Printf("\n   Current image: %s\n", cfg.Image)
+		e.ExitCode = 127
+		return nil // Don't show the raw error
+	}
+
+	return err
 }
 
 func init() {
@@ -637,13 +1017,7 @@ var imagesCmd = &cobra.Command{
 	Long:  `Manage preset development images for quick switching between environments.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Default: list images
-		cfg, err := images.LoadConfig()
-		if err != nil {
-			return err
-		}
-		images.UpdateDownloadedStatus(cfg)
-		fmt.Println(images.ListImages(cfg))
-		return nil
+		return listImages()
 	},
 }
 
@@ -651,14 +1025,25 @@ var imagesListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available images",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := images.LoadConfig()
-		if err != nil {
-			return err
-		}
-		images.UpdateDownloadedStatus(cfg)
+		return listImages()
+	},
+}
+
+var imagesFormat string
+
+func listImages() error {
+	if err := output.Validate(imagesFormat); err != nil {
+		return err
+	}
+	cfg, err := images.LoadConfig()
+	if err != nil {
+		return err
+	}
+	images.UpdateDownloadedStatus(cfg)
+	return output.Print(os.Stdout, imagesFormat, images.Entries(cfg), func() error {
 		fmt.Println(images.ListImages(cfg))
 		return nil
-	},
+	})
 }
 
 var imagesSetupCmd = &cobra.Command{
@@ -678,9 +1063,10 @@ var imagesSetupCmd = &cobra.Command{
 }
 
 var imagesUseCmd = &cobra.Command{
-	Use:   "use <name>",
-	Short: "Switch current project to use specified image",
-	Args:  cobra.ExactArgs(1),
+	Use:               "use <name>",
+	ValidArgsFunction: completeImagePresets,
+	Short:             "Switch current project to use specified image",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -744,9 +1130,10 @@ var imagesAddCmd = &cobra.Command{
 }
 
 var imagesPullCmd = &cobra.Command{
-	Use:   "pull <name>",
-	Short: "Download an image",
-	Args:  cobra.ExactArgs(1),
+	Use:               "pull <name>",
+	ValidArgsFunction: completeImagePresets,
+	Short:             "Download an image",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -765,6 +1152,7 @@ var imagesPullCmd = &cobra.Command{
 		}
 
 		preset.Downloaded = true
+		images.RecordDigest(cfg, preset.Image)
 		_ = images.SaveConfig(cfg)
 
 		return nil
@@ -772,9 +1160,10 @@ var imagesPullCmd = &cobra.Command{
 }
 
 var imagesRemoveCmd = &cobra.Command{
-	Use:   "remove <name>",
-	Short: "Remove a custom image from the list",
-	Args:  cobra.ExactArgs(1),
+	Use:               "remove <name>",
+	ValidArgsFunction: completeImagePresets,
+	Short:             "Remove a custom image from the list",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -792,13 +1181,142 @@ var imagesRemoveCmd = &cobra.Command{
 	},
 }
 
+var imagesSyncFile string
+var imagesSyncRef string
+
+var imagesSyncCmd = &cobra.Command{
+	Use:   "sync [url|git-url|file]",
+	Short: "Use the team's approved preset list",
+	Long: `Pull an organization-approved preset list and show its presets in 'cm images'.
+
+The list has the same shape as ~/.cm/images.json: a "presets" object mapping
+names to images, and an optional "default". It is read from an http(s) URL, a
+local file, or a git repository (git@..., ssh://..., git+https://... or a URL
+ending in .git), where --file names the list (default images.json). Team
+presets take precedence over built-in presets of the same name. Without an
+argument the list is synced again from the last source.
+
+EXAMPLES
+  cm images sync https://intranet.example.com/cm/images.json
+  cm images sync git@github.com:org/cm-config.git --file presets/images.json
+  cm images sync`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := images.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		var src images.OrgSource
+		switch {
+		case len(args) == 1:
+			src = images.OrgSource{URL: args[0]}
+			if images.IsGitSource(src.URL) {
+				src.File, src.Ref = imagesSyncFile, imagesSyncRef
+			}
+		case cfg.Source != nil:
+			src = *cfg.Source
+		default:
+			return fmt.Errorf("no preset list synced yet; run 'cm images sync <url>'")
+		}
+
+		fmt.Printf("📥 Fetching presets from %s...\n", src.URL)
+		list, err := images.SyncPresets(cmd.Context(), cfg, src)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Synced %d team preset(s)\n", len(list.Presets))
+		for _, preset := range images.Entries(cfg) {
+			if preset.Team {
+				fmt.Printf("   • %-12s %s\n", preset.Name, preset.Image)
+			}
+		}
+		fmt.Println("\n💡 Run 'cm images prefetch --all' to download them")
+		return nil
+	},
+}
+
+var imagesPrefetchAll bool
+var imagesPrefetchJobs int
+
+var imagesPrefetchCmd = &cobra.Command{
+	Use:               "prefetch [name...]",
+	ValidArgsFunction: completeImagePresets,
+	Short:             "Download images in parallel ahead of time",
+	Long: `Pull preset images in parallel so they are available without a network,
+for example before a flight or a workshop. --all pulls every image 'cm images'
+lists: team presets, built-in presets and custom images.
+
+EXAMPLES
+  cm images prefetch --all
+  cm images prefetch --all --jobs 6
+  cm images prefetch python node`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !imagesPrefetchAll && len(args) == 0 {
+			return fmt.Errorf("name the images to prefetch or pass --all")
+		}
+		if offline.Enabled() {
+			return fmt.Errorf("offline mode: 'cm images prefetch' needs the network")
+		}
+
+		cfg, err := images.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		var presets []*images.PresetImage
+		if imagesPrefetchAll {
+			presets = images.AllImages(cfg)
+		} else {
+			for _, name := range args {
+				preset, found := images.GetImage(cfg, name)
+				if !found {
+					return fmt.Errorf("image '%s' not found. Use 'cm images' to see available images", name)
+				}
+				presets = append(presets, preset)
+			}
+		}
+
+		fmt.Printf("📥 Prefetching %d image(s), %d at a time...\n\n", len(presets), imagesPrefetchJobs)
+		results := images.Prefetch(cmd.Context(), presets, imagesPrefetchJobs, os.Stdout)
+
+		failed := 0
+		for i, r := range results {
+			if r.Err != nil {
+				failed++
+				continue
+			}
+			presets[i].Downloaded = true
+			images.RecordDigest(cfg, r.Image)
+		}
+		_ = images.SaveConfig(cfg)
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d image(s) failed to download", failed, len(results))
+		}
+		fmt.Printf("\n🎉 All %d image(s) are available offline\n", len(results))
+		return nil
+	},
+}
+
 func init() {
+	imagesCmd.Flags().StringVar(&imagesFormat, "format", "", output.FlagUsage)
+	imagesListCmd.Flags().StringVar(&imagesFormat, "format", "", output.FlagUsage)
 	imagesCmd.AddCommand(imagesListCmd)
 	imagesCmd.AddCommand(imagesSetupCmd)
 	imagesCmd.AddCommand(imagesUseCmd)
 	imagesCmd.AddCommand(imagesAddCmd)
 	imagesCmd.AddCommand(imagesPullCmd)
 	imagesCmd.AddCommand(imagesRemoveCmd)
+
+	imagesSyncCmd.Flags().StringVar(&imagesSyncFile, "file", images.DefaultPresetFile, "Preset list file in a git repository")
+	imagesSyncCmd.Flags().StringVar(&imagesSyncRef, "ref", "", "Tag or branch of a git repository")
+	imagesCmd.AddCommand(imagesSyncCmd)
+
+	imagesPrefetchCmd.Flags().BoolVar(&imagesPrefetchAll, "all", false, "Download every listed image")
+	imagesPrefetchCmd.Flags().IntVarP(&imagesPrefetchJobs, "jobs", "j", images.DefaultPrefetchJobs, "Images to pull at once")
+	imagesCmd.AddCommand(imagesPrefetchCmd)
 	rootCmd.AddCommand(imagesCmd)
 }
 
@@ -808,9 +1326,14 @@ var watchIgnore string
 var watchDelay int
 var watchClear bool
 var watchNoInitial bool
+var watchRestart bool
+var watchSignal string
+var watchNoIgnoreFiles bool
+var watchContainerEvents bool
+var watchRules []string
 
 var watchCmd = &cobra.Command{
-	Use:   "watch [flags] -- <command>",
+	Use:   "watch [flags] [-- <command>]",
 	Short: "Watch for file changes and auto-run commands",
 	Long: `Watch for file changes and automatically re-run commands in the container.
 
@@ -819,8 +1342,25 @@ Examples:
   cm watch -- npm run build        # Watch and build
   cm watch --ext go,mod -- go test # Only watch .go and .mod files
   cm watch --delay 500 -- make     # 500ms debounce delay
-  cm watch --clear -- go build     # Clear screen before each run`,
-	Args: cobra.MinimumNArgs(1),
+  cm watch --clear -- go build     # Clear screen before each run
+  cm watch --restart -- go run .   # Restart a long-running server on change
+  cm watch --restart --signal SIGHUP -- nginx -g 'daemon off;'  # Graceful reload
+  cm watch --container-events -- make  # Also react to files written inside the container
+  cm watch --rule '*.go=go test ./...' --rule '*.proto=make proto'
+
+Rules can also be declared in .cm.yaml and run with a bare 'cm watch':
+
+  watch:
+    rules:
+      - name: test
+        patterns: ["*.go"]
+        run: go test ./...
+      - name: proto
+        patterns: ["api/**/*.proto"]
+        run: make proto
+        delay: 1000
+
+Paths matched by .gitignore or .cmignore in the project root are skipped.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Load config
 		cfg, projectDir, err := loadConfig()
@@ -828,12 +1368,40 @@ Examples:
 			return err
 		}
 
+		// Rules from --rule take precedence over .cm.yaml
+		var rules []watch.Rule
+		for i, spec := range watchRules {
+			rule, err := watch.ParseRule(spec, i)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		if len(rules) == 0 && len(args) == 0 {
+			rules, err = watch.LoadRules(projectDir)
+			if err != nil {
+				return err
+			}
+		}
+		if len(rules) == 0 && len(args) == 0 {
+			return fmt.Errorf("no command given and no watch rules found in %s", watch.ProjectConfigFile)
+		}
+		if len(rules) > 0 && len(args) > 0 {
+			return fmt.Errorf("a command cannot be combined with watch rules")
+		}
+		if len(rules) > 0 && (watchRestart || watchSignal != "") {
+			return fmt.Errorf("--restart and --signal are not supported with watch rules")
+		}
+
 		// Parse options
 		opts := watch.DefaultOptions()
+		opts.Rules = rules
 		opts.ProjectDir = projectDir
 		opts.Config = cfg
 		opts.Clear = watchClear
 		opts.InitialRun = !watchNoInitial
+		opts.UseIgnore = !watchNoIgnoreFiles
+		opts.ContainerEvents = watchContainerEvents
 
 		if watchExtensions != "" {
 			opts.Extensions = strings.Split(watchExtensions, ",")
@@ -847,6 +1415,18 @@ Examples:
 			opts.Delay = time.Duration(watchDelay) * time.Millisecond
 		}
 
+		if watchSignal != "" {
+			sig, err := watch.ParseSignal(watchSignal)
+			if err != nil {
+				return err
+			}
+			opts.Signal = sig
+			opts.Restart = true
+		}
+		if watchRestart {
+			opts.Restart = true
+		}
+
 		// Create watcher
 		w, err := watch.New(opts, args)
 		if err != nil {
@@ -875,6 +1455,11 @@ func init() {
 	watchCmd.Flags().IntVar(&watchDelay, "delay", 300, "Debounce delay in milliseconds")
 	watchCmd.Flags().BoolVar(&watchClear, "clear", false, "Clear screen before each run")
 	watchCmd.Flags().BoolVar(&watchNoInitial, "no-initial", false, "Don't run command on startup")
+	watchCmd.Flags().BoolVar(&watchRestart, "restart", false, "Treat the command as a long-running process and restart it on change")
+	watchCmd.Flags().BoolVar(&watchNoIgnoreFiles, "no-ignore-files", false, "Don't honor .gitignore/.cmignore")
+	watchCmd.Flags().BoolVar(&watchContainerEvents, "container-events", false, "Merge file events from inside the container (requires inotifywait)")
+	watchCmd.Flags().StringArrayVar(&watchRules, "rule", nil, "Per-glob command as GLOB[,GLOB]=COMMAND (repeatable)")
+	watchCmd.Flags().StringVar(&watchSignal, "signal", "", "Send this signal (e.g. SIGHUP) on change instead of restarting (implies --restart)")
 	watchCmd.Flags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
 	rootCmd.AddCommand(watchCmd)
 }
@@ -885,8 +1470,7 @@ var templateCmd = &cobra.Command{
 	Short: "Manage devcontainer templates",
 	Long:  `Browse and use pre-configured devcontainer templates for various project types.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println(template.ListTemplates())
-		return nil
+		return listTemplates()
 	},
 }
 
@@ -894,19 +1478,54 @@ var templateListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available templates",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		return listTemplates()
+	},
+}
+
+var templateFormat string
+
+func listTemplates() error {
+	if err := output.Validate(templateFormat); err != nil {
+		return err
+	}
+	all := template.GetAllTemplates()
+	templates := make([]*template.Template, 0, len(all))
+	for _, t := range all {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return output.Print(os.Stdout, templateFormat, templates, func() error {
 		fmt.Println(template.ListTemplates())
 		return nil
-	},
+	})
 }
 
 var templateUseCmd = &cobra.Command{
-	Use:   "use <name>",
-	Short: "Apply a template to current project",
-	Args:  cobra.ExactArgs(1),
+	Use:               "use <name|oci-ref>",
+	ValidArgsFunction: completeTemplateNames,
+	Short:             "Apply a template to current project",
+	Long: `Apply a built-in or custom template, or a devcontainer Template published
+to an OCI registry (per the containers.dev Templates spec).
+
+Examples:
+  cm template use go-basic
+  cm template use go-basic --option version=1.22
+  cm template use ghcr.io/devcontainers/templates/go:1
+  cm template use ghcr.io/devcontainers/templates/python:3 --option imageVariant=3.12-bookworm`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		cwd, _ := os.Getwd()
 
+		options, err := parseOptionFlags(templateOptions)
+		if err != nil {
+			return err
+		}
+
+		if template.IsOCIRef(name) {
+			return applyOCITemplate(cmd.Context(), name, cwd, options)
+		}
+
 		// Get template info first
 		info, err := template.TemplateInfo(name)
 		if err != nil {
@@ -914,9 +1533,15 @@ var templateUseCmd = &cobra.Command{
 		}
 		fmt.Println(info)
 
+		tmpl, _ := template.GetTemplate(name)
+		options, err = promptTemplateOptions("Template options for "+name+":", tmpl.Options, options)
+		if err != nil {
+			return err
+		}
+
 		// Apply template
 		fmt.Println("Creating .devcontainer/devcontainer.json...")
-		if err := template.ApplyTemplate(name, cwd); err != nil {
+		if err := template.ApplyTemplateWithOptions(name, cwd, options); err != nil {
 			return err
 		}
 
@@ -929,9 +1554,10 @@ var templateUseCmd = &cobra.Command{
 }
 
 var templateInfoCmd = &cobra.Command{
-	Use:   "info <name>",
-	Short: "Show template details",
-	Args:  cobra.ExactArgs(1),
+	Use:               "info <name>",
+	ValidArgsFunction: completeTemplateNames,
+	Short:             "Show template details",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		info, err := template.TemplateInfo(args[0])
 		if err != nil {
@@ -965,9 +1591,10 @@ var templateSaveCmd = &cobra.Command{
 }
 
 var templateRemoveCmd = &cobra.Command{
-	Use:   "remove <name>",
-	Short: "Remove a custom template",
-	Args:  cobra.ExactArgs(1),
+	Use:               "remove <name>",
+	ValidArgsFunction: completeTemplateNames,
+	Short:             "Remove a custom template",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
@@ -1011,7 +1638,11 @@ Examples:
 func init() {
 	templateSearchCmd.Flags().BoolVar(&templateSearchGPU, "gpu", false, "Show only GPU-required templates")
 	templateSearchCmd.Flags().StringVar(&templateSearchCategory, "category", "", "Filter by category")
+	templateUseCmd.Flags().StringArrayVar(&templateOptions, "option", nil, "Template option as key=value (repeatable)")
+	templateUseCmd.Flags().BoolVarP(&templateAcceptDefaults, "yes", "y", false, "Use defaults for options without prompting")
 
+	templateCmd.Flags().StringVar(&templateFormat, "format", "", output.FlagUsage)
+	templateListCmd.Flags().StringVar(&templateFormat, "format", "", output.FlagUsage)
 	templateCmd.AddCommand(templateListCmd)
 	templateCmd.AddCommand(templateUseCmd)
 	templateCmd.AddCommand(templateInfoCmd)
@@ -1039,22 +1670,15 @@ var backendListCmd = &cobra.Command{
 	},
 }
 
+var backendFormat string
+
 func listBackends() error {
+	if err := output.Validate(backendFormat); err != nil {
+		return err
+	}
 	detector := runtime.NewDetector()
 	result := detector.Detect()
 
-	fmt.Println("📦 Container Backends")
-	fmt.Println()
-
-	if len(result.Backends) == 0 {
-		fmt.Println("  No container runtimes found.")
-		fmt.Println()
-		fmt.Println("Install Docker or Podman:")
-		fmt.Println("  Docker:  https://docker.com/get-started")
-		fmt.Println("  Podman:  https://podman.io/getting-started")
-		return nil
-	}
-
 	// Sort backends: active first, then by name
 	backends := result.Backends
 	sort.Slice(backends, func(i, j int) bool {
@@ -1064,47 +1688,86 @@ func listBackends() error {
 		return backends[i].Name < backends[j].Name
 	})
 
-	fmt.Printf("  %-8s %-12s %-10s %s\n", "Status", "Name", "Version", "Path")
-	fmt.Printf("  %-8s %-12s %-10s %s\n", "──────", "────────────", "──────────", "────────────────────")
+	return output.Print(os.Stdout, backendFormat, backends, func() error {
+		fmt.Println("📦 Container Backends")
+		fmt.Println()
 
-	for _, b := range backends {
-		status := "○ Ready"
-		if b.IsActive {
-			status = "● Active"
-		} else if !b.Running {
-			status = "✗ Stopped"
+		if len(result.Backends) == 0 {
+			fmt.Println("  No container runtimes found.")
+			fmt.Println()
+			fmt.Println("Install Docker or Podman:")
+			fmt.Println("  Docker:  https://docker.com/get-started")
+			fmt.Println("  Podman:  https://podman.io/getting-started")
+			return nil
 		}
 
-		name := b.Name
-		if b.IsCustom {
-			name += " [custom]"
-		}
+		fmt.Printf("  %-8s %-12s %-10s %s\n", "Status", "Name", "Version", "Path")
+		fmt.Printf("  %-8s %-12s %-10s %s\n", "──────", "────────────", "──────────", "────────────────────")
 
-		version := b.Version
-		if version == "" {
-			version = "-"
-		}
+		for _, b := range backends {
+			status := "○ Ready"
+			if b.IsActive {
+				status = "● Active"
+			} else if !b.Running {
+				status = "✗ Stopped"
+			}
 
-		fmt.Printf("  %-8s %-12s %-10s %s\n", status, name, version, b.Path)
-	}
+			name := b.Name
+			if b.IsCustom {
+				name += " [custom]"
+			}
 
-	fmt.Println()
-	if result.Active != nil {
-		fmt.Printf("Current: %s\n", result.Active.Name)
-	}
-	fmt.Println("Switch with: cm backend use <name>")
+			version := b.Version
+			if version == "" {
+				version = "-"
+			}
 
-	return nil
+			fmt.Printf("  %-8s %-12s %-10s %s\n", status, name, version, b.Path)
+		}
+
+		fmt.Println()
+		if result.Active != nil {
+			fmt.Printf("Current: %s\n", result.Active.Name)
+		}
+		fmt.Println("Switch with: cm backend use <name>")
+		if distros, _ := runtime.ListWSLDistros(); len(distros) > 0 {
+			var names []string
+			for _, d := range distros {
+				names = append(names, d.Name)
+			}
+			fmt.Printf("WSL distributions: %s (use one with: cm backend use wsl:<distro>)\n", strings.Join(names, ", "))
+		}
+		return nil
+	})
 }
 
 var backendUseCmd = &cobra.Command{
-	Use:   "use <name>",
-	Short: "Switch to a specific backend",
-	Args:  cobra.ExactArgs(1),
+	Use:               "use <name>",
+	ValidArgsFunction: completeBackends,
+	Short:             "Switch to a specific backend",
+	Long: `Switch to a specific backend.
+
+On Windows, wsl:<distro> runs containers with the Podman or Docker engine
+installed inside a WSL distribution, without Docker Desktop. Workspace
+paths are translated to /mnt/<drive>, and ports published in the
+distribution reach Windows through WSL's localhost forwarding.
+
+Examples:
+  cm backend use podman
+  cm backend use wsl:Ubuntu`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		detector := runtime.NewDetector()
 
+		// wsl:<distro> backends are set up on first use
+		distro, isWSL := runtime.WSLDistroOf(name)
+		if isWSL {
+			if _, err := detector.AddWSLBackend(distro); err != nil {
+				return err
+			}
+		}
+
 		// Verify backend exists and is running
 		result := detector.Detect()
 		var found *runtime.BackendInfo
@@ -1134,6 +1797,13 @@ var backendUseCmd = &cobra.Command{
 		}
 
 		fmt.Printf("✅ Switched to %s\n", name)
+		if isWSL {
+			fmt.Printf("   Containers run in the WSL distribution %s; Windows paths are mounted from /mnt/<drive>\n", distro)
+			if !runtime.WSLLocalhostForwarding() {
+				fmt.Println("⚠️  localhostForwarding is off in .wslconfig, so forwarded ports are not reachable on")
+				fmt.Println("   localhost from Windows. Set localhostForwarding=true under [wsl2] and run 'wsl --shutdown'.")
+			}
+		}
 		return nil
 	},
 }
@@ -1179,9 +1849,10 @@ Examples:
 }
 
 var backendRemoveCmd = &cobra.Command{
-	Use:   "remove <name>",
-	Short: "Remove a custom backend",
-	Args:  cobra.ExactArgs(1),
+	Use:               "remove <name>",
+	ValidArgsFunction: completeBackends,
+	Short:             "Remove a custom backend",
+	Args:              cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		detector := runtime.NewDetector()
@@ -1206,6 +1877,8 @@ var backendDetectCmd = &cobra.Command{
 }
 
 func init() {
+	backendCmd.Flags().StringVar(&backendFormat, "format", "", output.FlagUsage)
+	backendListCmd.Flags().StringVar(&backendFormat, "format", "", output.FlagUsage)
 	backendCmd.AddCommand(backendListCmd)
 	backendCmd.AddCommand(backendUseCmd)
 	backendCmd.AddCommand(backendAddCmd)
@@ -1223,6 +1896,7 @@ var doctorCmd = &cobra.Command{
 Checks include:
   • Container runtime (Docker/Podman)
   • GPU support (NVIDIA/AMD)
+  • CUDA version of the project's image vs the NVIDIA driver
   • Network connectivity
   • Disk space
   • Docker Compose`,
@@ -1232,6 +1906,9 @@ Checks include:
 		fmt.Println()
 
 		results := runtime.RunDiagnostics()
+		if r, ok := cudaDiagnostic(context.Background()); ok {
+			results = append(results, r)
+		}
 
 		for _, r := range results {
 			var icon string
@@ -1287,3 +1964,39 @@ Checks include:
 func init() {
 	rootCmd.AddCommand(doctorCmd)
 }
+
+// cudaDiagnostic checks the CUDA version of the project's image, when it
+// names one, against the host's NVIDIA driver; ok is false without a driver
+func cudaDiagnostic(ctx context.Context) (runtime.DiagnosticResult, bool) {
+	host, ok := cudacheck.DetectHost(ctx)
+	if !ok {
+		return runtime.DiagnosticResult{}, false
+	}
+	result := runtime.DiagnosticResult{
+		Name:    "CUDA Compatibility",
+		Status:  "ok",
+		Message: fmt.Sprintf("Driver %s runs CUDA %s and older", host.Driver, host.CUDA),
+	}
+
+	for _, path := range []string{configFile, ".devcontainer/devcontainer.json", "devcontainer.json"} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		cfg, err := parseDevConfig(path)
+		if err != nil || cfg.Image == "" {
+			break
+		}
+		img, ok := cudacheck.DetectImage(ctx, "docker", cfg.Image)
+		if !ok {
+			result.Details = cfg.Image + " doesn't use CUDA"
+			break
+		}
+		c := cudacheck.Compare(img, host)
+		result.Status, result.Message, result.Fix = c.Status, c.Message, c.Fix
+		break
+	}
+	return result, true
+}
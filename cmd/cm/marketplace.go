@@ -5,19 +5,36 @@ import (
 	"os"
 
 	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
 	"github.com/spf13/cobra"
 )
 
+var marketplaceRefresh bool
+var marketplaceApply bool
+var marketplacePublishIndex string
+var marketplacePublishBaseURL string
+var marketplacePublishAuthor string
+var marketplacePublishVersion string
+var marketplacePublishSignKey string
+
 var marketplaceCmd = &cobra.Command{
 	Use:     "marketplace",
 	Aliases: []string{"market", "store"},
 	Short:   "Browse and install community templates",
 	Long: `Discover, search, and install DevContainer templates from the community.
 
+Templates are listed in a JSON index (configure with
+'cm config set marketplace.index_url <url>'). The index must carry a valid
+ed25519 signature: the community index is checked against a key built into
+cm, other indexes against 'marketplace.public_key'. Installed templates must
+match the index's checksum and are saved to ~/.cm/templates for use with
+'cm template use'.
+
 Examples:
   cm marketplace search python    # Search for Python templates
   cm marketplace list             # List all templates
   cm marketplace install go       # Install the Go template
+  cm marketplace update           # Update installed templates
   cm marketplace info python      # Show template details`,
 }
 
@@ -43,6 +60,12 @@ var marketplaceInstallCmd = &cobra.Command{
 	RunE:  runMarketplaceInstall,
 }
 
+var marketplaceUpdateCmd = &cobra.Command{
+	Use:   "update [template-id...]",
+	Short: "Update installed templates to the latest index version",
+	RunE:  runMarketplaceUpdate,
+}
+
 var marketplaceInfoCmd = &cobra.Command{
 	Use:   "info <template-id>",
 	Short: "Show template details",
@@ -50,19 +73,57 @@ var marketplaceInfoCmd = &cobra.Command{
 	RunE:  runMarketplaceInfo,
 }
 
+var marketplacePublishCmd = &cobra.Command{
+	Use:   "publish <template-name>",
+	Short: "Add a local template to a marketplace index",
+	Long: `Add or update a local template's entry in a marketplace index file.
+
+The template JSON is written to templates/<name>.json next to the index and
+its SHA-256 checksum is recorded. Commit both files to the marketplace
+repository (or open a pull request) to share the template.
+
+Examples:
+  cm marketplace publish my-stack --index ./cm-marketplace/index.json \
+    --base-url https://raw.githubusercontent.com/org/cm-marketplace/main
+  cm marketplace publish my-stack --index index.json --sign-key "$CM_MARKETPLACE_KEY"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMarketplacePublish,
+}
+
 func init() {
+	marketplaceCmd.PersistentFlags().BoolVar(&marketplaceRefresh, "refresh", false, "Ignore the cached index and fetch it again")
+	marketplaceInstallCmd.Flags().BoolVar(&marketplaceApply, "apply", false, "Also apply the template to the current project")
+	marketplacePublishCmd.Flags().StringVar(&marketplacePublishIndex, "index", "index.json", "Path to the index file to update")
+	marketplacePublishCmd.Flags().StringVar(&marketplacePublishBaseURL, "base-url", "", "URL the index directory is served from")
+	marketplacePublishCmd.Flags().StringVar(&marketplacePublishAuthor, "author", "", "Template author")
+	marketplacePublishCmd.Flags().StringVar(&marketplacePublishVersion, "version", "1.0.0", "Template version")
+	marketplacePublishCmd.Flags().StringVar(&marketplacePublishSignKey, "sign-key", "", "Base64 ed25519 private key to sign the index with")
+	_ = marketplacePublishCmd.MarkFlagRequired("base-url")
+
 	marketplaceCmd.AddCommand(marketplaceSearchCmd)
 	marketplaceCmd.AddCommand(marketplaceListCmd)
 	marketplaceCmd.AddCommand(marketplaceInstallCmd)
+	marketplaceCmd.AddCommand(marketplaceUpdateCmd)
 	marketplaceCmd.AddCommand(marketplaceInfoCmd)
+	marketplaceCmd.AddCommand(marketplacePublishCmd)
 	rootCmd.AddCommand(marketplaceCmd)
 }
 
+// newMarketplace builds a client from user configuration
+func newMarketplace() *template.Marketplace {
+	opts := template.MarketplaceOptions{Refresh: marketplaceRefresh}
+	if cfg, err := userconfig.Load(); err == nil {
+		opts.IndexURL = cfg.Marketplace.IndexURL
+		opts.PublicKey = cfg.Marketplace.PublicKey
+	}
+	return template.NewMarketplaceWithOptions(opts)
+}
+
 func runMarketplaceSearch(cmd *cobra.Command, args []string) error {
 	fmt.Println("🏪 Template Marketplace")
 	fmt.Println()
 
-	market := template.NewMarketplace()
+	market := newMarketplace()
 
 	query := ""
 	if len(args) > 0 {
@@ -92,28 +153,85 @@ func runMarketplaceInstall(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("📦 Installing template: %s\n", templateID)
 
-	cwd, err := os.Getwd()
-	if err != nil {
+	market := newMarketplace()
+	if _, err := market.Install(templateID); err != nil {
 		return err
 	}
 
-	market := template.NewMarketplace()
-	if err := market.Install(templateID, cwd); err != nil {
-		return err
+	fmt.Println("✅ Template installed successfully!")
+	fmt.Printf("📁 Saved to %s\n", template.GetTemplatesDir())
+
+	if marketplaceApply {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if err := template.ApplyTemplate(templateID, cwd); err != nil {
+			return err
+		}
+		fmt.Println("📁 Created .devcontainer/devcontainer.json")
+		fmt.Println("🚀 Run 'cm shell' to start your dev container")
+		return nil
 	}
 
-	fmt.Println("✅ Template installed successfully!")
 	fmt.Println()
-	fmt.Println("📁 Created .devcontainer/devcontainer.json")
-	fmt.Println("🚀 Run 'cm shell' to start your dev container")
+	fmt.Printf("💡 Use 'cm template use %s' to apply it to a project\n", templateID)
+	return nil
+}
+
+func runMarketplaceUpdate(cmd *cobra.Command, args []string) error {
+	market := newMarketplace()
 
+	updates, err := market.Updates()
+	if err != nil {
+		return err
+	}
+
+	// Restrict to requested IDs
+	if len(args) > 0 {
+		wanted := make(map[string]bool)
+		for _, id := range args {
+			wanted[id] = true
+		}
+		var filtered []template.MarketplaceTemplate
+		for _, u := range updates {
+			if wanted[u.ID] {
+				filtered = append(filtered, u)
+			}
+		}
+		updates = filtered
+	}
+
+	if len(updates) == 0 {
+		fmt.Println("✅ All installed templates are up to date.")
+		return nil
+	}
+
+	installed, _ := market.LoadInstalled()
+	failed := 0
+	for _, u := range updates {
+		from := installed[u.ID].Version
+		if from == "" {
+			from = "?"
+		}
+		fmt.Printf("⬆️  Updating %s (%s → %s)...\n", u.ID, from, u.Version)
+		if _, err := market.Install(u.ID); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d template(s) failed to update", failed)
+	}
+	fmt.Printf("✅ Updated %d template(s)\n", len(updates))
 	return nil
 }
 
 func runMarketplaceInfo(cmd *cobra.Command, args []string) error {
 	templateID := args[0]
 
-	market := template.NewMarketplace()
+	market := newMarketplace()
 	tmpl, err := market.GetTemplate(templateID)
 	if err != nil {
 		return err
@@ -126,6 +244,12 @@ func runMarketplaceInfo(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Author:      %s\n", tmpl.Author)
 	fmt.Printf("  Category:    %s\n", tmpl.Category)
 	fmt.Printf("  Description: %s\n", tmpl.Description)
+	if tmpl.Version != "" {
+		fmt.Printf("  Version:     %s\n", tmpl.Version)
+	}
+	if tmpl.SHA256 != "" {
+		fmt.Printf("  SHA-256:     %s\n", tmpl.SHA256)
+	}
 	fmt.Printf("  Stars:       ⭐ %d\n", tmpl.Stars)
 	fmt.Printf("  Downloads:   📥 %d\n", tmpl.Downloads)
 	fmt.Println()
@@ -133,3 +257,36 @@ func runMarketplaceInfo(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runMarketplacePublish(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	entry, err := template.PublishTemplate(name, marketplacePublishIndex, marketplacePublishBaseURL,
+		marketplacePublishAuthor, marketplacePublishVersion)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Published '%s' v%s to %s\n", entry.ID, entry.Version, marketplacePublishIndex)
+	fmt.Printf("   URL:     %s\n", entry.URL)
+	fmt.Printf("   SHA-256: %s\n", entry.SHA256)
+
+	if marketplacePublishSignKey != "" {
+		data, err := os.ReadFile(marketplacePublishIndex)
+		if err != nil {
+			return err
+		}
+		sig, err := template.SignIndex(data, marketplacePublishSignKey)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(marketplacePublishIndex+".sig", []byte(sig+"\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("🔏 Signed index: %s.sig\n", marketplacePublishIndex)
+	}
+
+	fmt.Println()
+	fmt.Println("💡 Commit the index and templates/ directory to publish the change")
+	return nil
+}
@@ -29,8 +29,8 @@ KEYBOARD SHORTCUTS
 
 EXAMPLES
   cm monitor                  # Open dashboard for all containers
-  cm status                   # Alias for cm monitor`,
-	Aliases: []string{"status", "dashboard"},
+  cm dashboard                # Alias for cm monitor`,
+	Aliases: []string{"dashboard"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("📊 Starting Container-Maker Monitor...")
 		fmt.Println("   Press 'q' to quit, '?' for help")
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	monoDevcontainers bool
+	monoForce         bool
+)
+
+var monoCmd = &cobra.Command{
+	Use:   "mono",
+	Short: "Run the services of a monorepo in their own containers",
+	Long: `Give every service of a monorepo or multi-root project its own dev container.
+
+cm looks for services under apps/, packages/, services/, libs/ and modules/,
+detects each one's language and suggested template, and writes a
+cm-workspace.yaml with one service per directory. The services start on the
+workspace's shared network, where they reach each other by service name.
+
+COMMANDS
+  cm mono init    Generate cm-workspace.yaml from the detected services
+  cm mono up      Generate it if missing, then start the services
+
+Afterwards the usual workspace commands apply (cm ps, cm logs, cm down), and
+'cm exec --service <name>' runs a command in a service's container.`,
+}
+
+var monoInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate cm-workspace.yaml from the detected services",
+	Long: `Generate cm-workspace.yaml from the services detected in the current directory.
+
+A service uses the image of its own .devcontainer/devcontainer.json when it
+has one, otherwise the image of the suggested template. --devcontainers also
+writes that template's devcontainer.json into each service that lacks one,
+for editors that open a single service.
+
+EXAMPLES
+  cm mono init
+  cm mono init --devcontainers
+  cm mono init --force          # Regenerate an existing cm-workspace.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _ := os.Getwd()
+		path := filepath.Join(projectDir, workspace.DefaultConfigFile)
+		if _, err := os.Stat(path); err == nil && !monoForce {
+			return fmt.Errorf("%s already exists (use --force to regenerate it)", workspace.DefaultConfigFile)
+		}
+
+		ws, err := generateMonoWorkspace(projectDir)
+		if err != nil {
+			return err
+		}
+		names := ws.ServiceNames()
+		sort.Strings(names)
+		fmt.Printf("✅ Created %s\n", workspace.DefaultConfigFile)
+		fmt.Println()
+		fmt.Println("Next steps:")
+		fmt.Println("  cm mono up                       # Start the services")
+		fmt.Printf("  cm exec --service %s -- <cmd>   # Run a command in one\n", names[0])
+		return nil
+	},
+}
+
+var monoUpCmd = &cobra.Command{
+	Use:               "up [services...]",
+	ValidArgsFunction: completeServices,
+	Short:             "Start the monorepo services",
+	Long: `Start the services of the monorepo on a shared network, generating
+cm-workspace.yaml from the detected services first when it does not exist.
+Takes the same flags as 'cm up'.
+
+EXAMPLES
+  cm mono up
+  cm mono up api web
+  cm mono up --devcontainers`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, _ := os.Getwd()
+		if _, err := workspace.FindWorkspaceConfig(projectDir); err != nil {
+			if _, err := generateMonoWorkspace(projectDir); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Created %s\n\n", workspace.DefaultConfigFile)
+		}
+		return upCmd.RunE(cmd, args)
+	},
+}
+
+// generateMonoWorkspace writes a cm-workspace.yaml with one service per
+// detected monorepo service and prints what it found
+func generateMonoWorkspace(projectDir string) (*workspace.Workspace, error) {
+	services, err := detect.DetectServices(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services found under apps/, packages/, services/, libs/ or modules/")
+	}
+
+	ws := workspace.CreateDefaultWorkspace(filepath.Base(projectDir))
+	ws.ConfigFile = filepath.Join(projectDir, workspace.DefaultConfigFile)
+
+	fmt.Printf("🔍 Found %d services\n\n", len(services))
+	fmt.Printf("%-20s %-25s %-15s %s\n", "SERVICE", "PATH", "TEMPLATE", "IMAGE")
+	for _, s := range services {
+		name := s.Name
+		if _, taken := ws.Services[name]; taken {
+			// apps/web and packages/web
+			name = strings.ReplaceAll(filepath.ToSlash(s.Path), "/", "-")
+		}
+		serviceDir := filepath.Join(projectDir, s.Path)
+
+		if monoDevcontainers && !hasDevcontainer(serviceDir) {
+			if err := template.ApplyTemplate(s.Template, serviceDir); err != nil {
+				fmt.Printf("⚠️  %s: could not write devcontainer.json: %v\n", name, err)
+			}
+		}
+
+		image := monoServiceImage(serviceDir, s.Template)
+		_ = ws.AddService(name, &workspace.Service{
+			Image:   image,
+			Path:    "./" + filepath.ToSlash(s.Path),
+			Command: []string{"sleep", "infinity"},
+		})
+		fmt.Printf("%-20s %-25s %-15s %s\n", name, s.Path, s.Template, image)
+	}
+	fmt.Println()
+
+	if err := workspace.Save(ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// hasDevcontainer reports whether a directory has its own devcontainer.json
+func hasDevcontainer(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	return err == nil
+}
+
+// monoServiceImage picks a service's image: that of its devcontainer.json,
+// else that of its suggested template
+func monoServiceImage(serviceDir, templateName string) string {
+	if hasDevcontainer(serviceDir) {
+		if cfg, err := config.ParseConfig(filepath.Join(serviceDir, ".devcontainer", "devcontainer.json")); err == nil && cfg.Image != "" {
+			return cfg.Image
+		}
+	}
+	if tmpl, ok := template.GetTemplate(templateName); ok {
+		if rendered, err := tmpl.Render(nil); err == nil && rendered.Image != "" {
+			return rendered.Image
+		}
+	}
+	return workspace.ResolveTemplateImage(strings.SplitN(templateName, "-", 2)[0])
+}
+
+func init() {
+	monoInitCmd.Flags().BoolVar(&monoDevcontainers, "devcontainers", false, "Also write a devcontainer.json into each service that lacks one")
+	monoInitCmd.Flags().BoolVarP(&monoForce, "force", "f", false, "Overwrite an existing cm-workspace.yaml")
+
+	monoUpCmd.Flags().BoolVar(&monoDevcontainers, "devcontainers", false, "When generating cm-workspace.yaml, also write a devcontainer.json into each service that lacks one")
+
+	monoCmd.AddCommand(monoInitCmd)
+	monoCmd.AddCommand(monoUpCmd)
+	rootCmd.AddCommand(monoCmd)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notebookPort      int
+	notebookToken     string
+	notebookNoBrowser bool
+)
+
+var notebookCmd = &cobra.Command{
+	Use:   "notebook",
+	Short: "Run JupyterLab in the dev container and open it",
+	Long: `Start JupyterLab in the project's persistent container, installing it
+with pip when the image lacks it, and open it in the browser.
+
+JupyterLab listens on port 8888 in the container (--port) with a random
+token (--token). The port is forwarded to 127.0.0.1 even when it isn't in
+forwardPorts, and the URL with the token is printed once the server
+answers. Ctrl+C stops it.
+
+Jupyter's settings, kernels and IPython history go to the cm-jupyter
+volume at ` + template.JupyterStatePath + `, one directory per project, which the
+python-ml and deep learning templates mount so they survive rebuilds.
+Without the volume they go to .jupyter-state in the project.
+
+EXAMPLES
+  cm notebook
+  cm notebook --port 8890 --no-browser`,
+	Args: cobra.NoArgs,
+	RunE: runNotebook,
+}
+
+func init() {
+	notebookCmd.Flags().IntVar(&notebookPort, "port", 8888, "JupyterLab port in the container")
+	notebookCmd.Flags().StringVar(&notebookToken, "token", "", "Access token (default: random)")
+	notebookCmd.Flags().BoolVar(&notebookNoBrowser, "no-browser", false, "Only print the URL")
+	rootCmd.AddCommand(notebookCmd)
+}
+
+func runNotebook(cmd *cobra.Command, args []string) error {
+	cfg, projectDir, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	token := notebookToken
+	if token == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+	containerID, err := pr.EnsureContainer(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// Kernel state in the volume when it's mounted and writable
+	stateDir := path.Join(template.JupyterStatePath, filepath.Base(projectDir))
+	if !inContainer(ctx, pr.BackendCommand(), containerID, "mkdir -p "+shellQuote(stateDir), io.Discard) {
+		stateDir = pr.WorkspaceDir() + "/.jupyter-state"
+		fmt.Printf("💡 No writable %s volume; keeping Jupyter state in .jupyter-state\n", template.JupyterStatePath)
+	}
+
+	hostPort, stop, err := pr.ForwardPort(ctx, containerID, notebookPort)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/lab?token=%s", hostPort, token)
+	go func() {
+		if !waitForJupyter(ctx, hostPort, 5*time.Minute) {
+			return
+		}
+		fmt.Printf("\n📓 JupyterLab: %s\n\n", url)
+		if !notebookNoBrowser {
+			_ = openBrowser(url)
+		}
+	}()
+
+	return pr.Exec(ctx, []string{"sh", "-c", notebookScript(stateDir, pr.WorkspaceDir(), notebookPort, token)})
+}
+
+// notebookScript installs JupyterLab when missing, keeps its state in
+// stateDir and runs it in the foreground
+func notebookScript(stateDir, workspace string, port int, token string) string {
+	return `set -e
+PY=$(command -v python3 || command -v python)
+if ! "$PY" -c 'import jupyterlab' 2>/dev/null; then
+  echo "📦 Installing JupyterLab..."
+  "$PY" -m pip install --quiet jupyterlab
+fi
+STATE=` + shellQuote(stateDir) + `
+mkdir -p "$STATE"
+export JUPYTER_CONFIG_DIR="$STATE/config" JUPYTER_DATA_DIR="$STATE/data" IPYTHONDIR="$STATE/ipython"
+export JUPYTERLAB_SETTINGS_DIR="$STATE/lab/user-settings" JUPYTERLAB_WORKSPACES_DIR="$STATE/lab/workspaces"
+export JUPYTER_TOKEN=` + shellQuote(token) + `
+exec "$PY" -m jupyter lab --ip=0.0.0.0 --port=` + strconv.Itoa(port) + ` --no-browser --allow-root --notebook-dir=` + shellQuote(workspace)
+}
+
+// waitForJupyter waits until JupyterLab answers on a host port; the
+// forwarded port accepts connections before it does
+func waitForJupyter(ctx context.Context, port int, timeout time.Duration) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/api", port))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+	return false
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/output"
 	"github.com/UPwith-me/Container-Maker/pkg/plugin"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +24,10 @@ var pluginListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List installed plugins",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(pluginListFormat); err != nil {
+			return err
+		}
+
 		mgr := plugin.GetManager()
 		// Re-scan to be sure
 		if err := mgr.DiscoverPlugins(context.Background()); err != nil {
@@ -29,21 +35,29 @@ var pluginListCmd = &cobra.Command{
 		}
 
 		plugins := mgr.GetPlugins()
-		if len(plugins) == 0 {
-			fmt.Println("No plugins installed.")
-			return nil
-		}
-
-		fmt.Println("NAME                 VERSION      DESCRIPTION")
-		fmt.Println("────────────────────────────────────────────────────────")
+		metas := make([]plugin.PluginMetadata, 0, len(plugins))
 		for _, p := range plugins {
-			meta := p.Metadata()
-			fmt.Printf("%-20s %-12s %s\n", meta.Name, meta.Version, meta.Description)
+			metas = append(metas, p.Metadata())
 		}
-		return nil
+
+		return output.Print(os.Stdout, pluginListFormat, metas, func() error {
+			if len(metas) == 0 {
+				fmt.Println("No plugins installed.")
+				return nil
+			}
+
+			fmt.Println("NAME                 VERSION      DESCRIPTION")
+			fmt.Println("────────────────────────────────────────────────────────")
+			for _, meta := range metas {
+				fmt.Printf("%-20s %-12s %s\n", meta.Name, meta.Version, meta.Description)
+			}
+			return nil
+		})
 	},
 }
 
+var pluginListFormat string
+
 var pluginInstallCmd = &cobra.Command{
 	Use:   "install <url>",
 	Short: "Install a plugin from a URL",
@@ -53,7 +67,8 @@ var pluginInstallCmd = &cobra.Command{
 		fmt.Printf("⬇️  Downloading plugin from %s...\n", url)
 
 		// 1. Download
-		resp, err := http.Get(url)
+		client := httpclient.New(httpclient.Options{Timeout: 10 * time.Minute})
+		resp, err := client.Get(url)
 		if err != nil {
 			return err
 		}
@@ -105,6 +120,7 @@ var pluginInstallCmd = &cobra.Command{
 }
 
 func init() {
+	pluginListCmd.Flags().StringVar(&pluginListFormat, "format", "", output.FlagUsage)
 	pluginCmd.AddCommand(pluginListCmd)
 	pluginCmd.AddCommand(pluginInstallCmd)
 	rootCmd.AddCommand(pluginCmd)
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/UPwith-me/Container-Maker/pkg/config"
 	"github.com/UPwith-me/Container-Maker/pkg/policy"
 	"github.com/UPwith-me/Container-Maker/pkg/workspace"
 	"github.com/spf13/cobra"
@@ -15,61 +18,136 @@ var policyCmd = &cobra.Command{
 	Short: "Manage security and compliance policies",
 	Long: `Enforce security, best practices, and resource limits using Policy as Code.
 
-Check your workspace configuration against built-in or custom policies to identify
-potential security risks and misconfigurations.
+Check your dev container and workspace configuration against built-in or custom
+policies to identify potential security risks and misconfigurations.
+
+Policies come from three layers, later ones overriding earlier ones:
+  1. Built-in policies (see 'cm policy list')
+  2. The organization policy: a file or URL set with CM_POLICY or
+     'cm config set policy.source <url>'. Projects cannot change the
+     policies it sets.
+  3. The project's .cm-policy.yaml
+
+Each policy has a mode. In "warn" mode (the default) violations are
+reported; in "deny" mode cm refuses to start a dev container whose
+devcontainer.json violates it; "off" disables the policy.
+
+EXAMPLE .cm-policy.yaml
+  version: "1"
+  policies:
+    - id: DC-001        # no --privileged
+      mode: deny
+    - id: DC-003        # no docker.sock mounts
+      mode: deny
+    - id: DC-004        # pinned images
+      mode: deny
+      parameters:
+        require_digest: true
+    - id: DC-005        # allowed registries
+      mode: deny
+      parameters:
+        registries: [ghcr.io/acme, mcr.microsoft.com]
 
 COMMANDS
-  cm policy check    Check workspace against policies
+  cm policy check    Check devcontainer.json and workspace against policies
   cm policy list     List active policies`,
 }
 
 var (
 	policyFailOnWarn bool
 	policyQuiet      bool
+	policyFile       string
 )
 
 var policyCheckCmd = &cobra.Command{
-	Use:   "check [workspace-file]",
-	Short: "Check workspace against policies",
-	Args:  cobra.MaximumNArgs(1),
+	Use:   "check [devcontainer.json | workspace-file]",
+	Short: "Check devcontainer.json and workspace against policies",
+	Long: `Check configuration against the active policies, for use in CI.
+
+Without an argument, .devcontainer/devcontainer.json (or devcontainer.json)
+and the workspace file are checked, whichever exist. The command exits 1 on
+violations of deny-mode policies or of critical and error severity, and on
+any violation with --strict.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Load workspace
-		path := ""
+		devConfigPath, workspacePath := "", ""
 		if len(args) > 0 {
-			path = args[0]
+			if strings.HasSuffix(args[0], ".json") {
+				devConfigPath = args[0]
+			} else {
+				workspacePath = args[0]
+			}
+		} else {
+			if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+				devConfigPath = ".devcontainer/devcontainer.json"
+			} else if _, err := os.Stat("devcontainer.json"); err == nil {
+				devConfigPath = "devcontainer.json"
+			}
+			if path, err := workspace.FindWorkspaceConfig("."); err == nil {
+				workspacePath = path
+			}
 		}
-
-		ws, err := workspace.Load(path)
-		if err != nil {
-			return fmt.Errorf("failed to load workspace: %w", err)
+		if devConfigPath == "" && workspacePath == "" {
+			return fmt.Errorf("no devcontainer.json or workspace file found")
 		}
 
 		// Create engine
-		engine := policy.NewEngine()
-
-		// Evaluate
-		result, err := engine.EvaluateWorkspace(cmd.Context(), ws)
+		var dirs []string
+		if devConfigPath != "" {
+			dirs = policyDirs(devConfigPath)
+		}
+		if workspacePath != "" {
+			dirs = append(dirs, filepath.Dir(workspacePath))
+		}
+		engine, err := policy.Load(dirs...)
 		if err != nil {
-			return fmt.Errorf("evaluation failed: %w", err)
+			return err
+		}
+		if policyFile != "" {
+			if err := engine.LoadPolicies(policyFile); err != nil {
+				return err
+			}
 		}
-
-		// Print output
 		if !policyQuiet {
-			printPolicyResult(result)
+			for _, source := range engine.Sources() {
+				fmt.Printf("📋 Loaded policies from %s\n", source)
+			}
 		}
 
-		// Determine exit code
-		if !result.Passed {
-			// If strict mode or critical errors, exit non-zero
-			hasCritical := false
-			for _, v := range result.Violations {
-				if v.Severity == policy.SeverityCritical || v.Severity == policy.SeverityError {
-					hasCritical = true
-					break
-				}
+		var violations []policy.Violation
+		if devConfigPath != "" {
+			cfg, err := config.ParseConfig(devConfigPath)
+			if err != nil {
+				return err
 			}
+			result, err := engine.EvaluateConfig(cmd.Context(), cfg)
+			if err != nil {
+				return fmt.Errorf("evaluation failed: %w", err)
+			}
+			if !policyQuiet {
+				printPolicyResult(result, "Config")
+			}
+			violations = append(violations, result.Violations...)
+		}
+		if workspacePath != "" {
+			ws, err := workspace.Load(workspacePath)
+			if err != nil {
+				return fmt.Errorf("failed to load workspace: %w", err)
+			}
+			result, err := engine.EvaluateWorkspace(cmd.Context(), ws)
+			if err != nil {
+				return fmt.Errorf("evaluation failed: %w", err)
+			}
+			if !policyQuiet {
+				printPolicyResult(result, "Service")
+			}
+			violations = append(violations, result.Violations...)
+		}
 
-			if hasCritical || policyFailOnWarn {
+		// Determine exit code
+		for _, v := range violations {
+			if policyFailOnWarn || v.Mode == policy.ModeDeny ||
+				v.Severity == policy.SeverityCritical || v.Severity == policy.SeverityError {
 				os.Exit(1)
 			}
 		}
@@ -81,25 +159,93 @@ var policyCheckCmd = &cobra.Command{
 var policyListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List active policies",
-	Run: func(cmd *cobra.Command, args []string) {
-		engine := policy.NewEngine()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, err := policy.Load(".", ".devcontainer")
+		if err != nil {
+			return err
+		}
 		policies := engine.GetPolicies()
 
+		for _, source := range engine.Sources() {
+			fmt.Printf("📋 Loaded policies from %s\n", source)
+		}
 		fmt.Printf("Active Policies: %d\n\n", len(policies))
-		fmt.Printf("%-10s %-30s %-10s %-10s\n", "ID", "NAME", "TYPE", "SEVERITY")
-		fmt.Println(strings.Repeat("-", 70))
+		fmt.Printf("%-10s %-30s %-14s %-10s %-6s\n", "ID", "NAME", "TYPE", "SEVERITY", "MODE")
+		fmt.Println(strings.Repeat("-", 74))
 
 		for _, p := range policies {
-			fmt.Printf("%-10s %-30s %-10s %-10s\n",
+			mode := p.EffectiveMode()
+			if !p.Enabled {
+				mode = policy.ModeOff
+			}
+			fmt.Printf("%-10s %-30s %-14s %-10s %-6s\n",
 				p.ID,
 				truncate(p.Name, 28),
 				p.Type,
-				p.Severity)
+				p.Severity,
+				mode)
 		}
+		return nil
 	},
 }
 
-func printPolicyResult(res *policy.EvaluationResult) {
+// enforceConfigPolicy evaluates a devcontainer.json when it is loaded. Warn
+// mode violations are printed; deny mode violations stop the command.
+func enforceConfigPolicy(cfg *config.DevContainerConfig, configPath string) error {
+	engine, err := policy.Load(policyDirs(configPath)...)
+	if err != nil {
+		return err
+	}
+	result, err := engine.EvaluateConfig(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range result.Violations {
+		if v.Mode == policy.ModeWarn {
+			fmt.Printf("⚠️  Policy %s: %s\n", v.PolicyID, v.Message)
+		}
+	}
+	denied := result.Denied()
+	if len(denied) == 0 {
+		return nil
+	}
+	for _, v := range denied {
+		fmt.Printf("❌ Policy %s (%s): %s\n", v.PolicyID, v.PolicyName, v.Message)
+		if v.Suggestion != "" {
+			fmt.Printf("   💡 %s\n", v.Suggestion)
+		}
+	}
+	return fmt.Errorf("%s denied by policy", configPath)
+}
+
+// parseDevConfig parses a devcontainer.json and enforces the policies on it
+func parseDevConfig(configPath string) (*config.DevContainerConfig, error) {
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := enforceConfigPolicy(cfg, configPath); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// policyDirs returns where a project's .cm-policy.yaml may live: the
+// directory of devcontainer.json and, for .devcontainer/, the project root
+func policyDirs(configPath string) []string {
+	dir := filepath.Dir(configPath)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	dirs := []string{dir}
+	if filepath.Base(dir) == ".devcontainer" {
+		dirs = append(dirs, filepath.Dir(dir))
+	}
+	return dirs
+}
+
+func printPolicyResult(res *policy.EvaluationResult, label string) {
 	fmt.Println()
 	fmt.Printf("Policy Check Results\n")
 	fmt.Println(strings.Repeat("=", 60))
@@ -119,7 +265,7 @@ func printPolicyResult(res *policy.EvaluationResult) {
 	}
 
 	for resource, violations := range byResource {
-		fmt.Printf("Service: %s\n", resource)
+		fmt.Printf("%s: %s\n", label, resource)
 		for _, v := range violations {
 			icon := "ℹ️"
 			switch v.Severity {
@@ -131,7 +277,11 @@ func printPolicyResult(res *policy.EvaluationResult) {
 				icon = "⚠️"
 			}
 
-			fmt.Printf("  %s [%s] %s: %s\n", icon, v.PolicyID, v.Severity, v.Message)
+			mode := ""
+			if v.Mode == policy.ModeDeny {
+				mode = " (deny)"
+			}
+			fmt.Printf("  %s [%s] %s%s: %s\n", icon, v.PolicyID, v.Severity, mode, v.Message)
 			if v.Suggestion != "" {
 				fmt.Printf("     Suggestion: %s\n", v.Suggestion)
 			}
@@ -162,6 +312,7 @@ func truncate(s string, l int) string {
 func init() {
 	policyCheckCmd.Flags().BoolVar(&policyFailOnWarn, "strict", false, "Fail on warnings")
 	policyCheckCmd.Flags().BoolVarP(&policyQuiet, "quiet", "q", false, "Suppress output")
+	policyCheckCmd.Flags().StringVar(&policyFile, "policy", "", "Additional policy file to apply")
 
 	policyCmd.AddCommand(policyCheckCmd)
 	policyCmd.AddCommand(policyListCmd)
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+)
+
+var prebuildPush string
+
+var prebuildCmd = &cobra.Command{
+	Use:   "prebuild",
+	Short: "Build the project's image with its features installed",
+	Long: `Build the project's image, from its image or Dockerfile, with its
+features installed, and tag it with the prebuild hash of its configuration.
+With --push it is pushed as <repository>:<hash>.
+
+'cm run' and friends look up the prebuild hash with the control plane when
+signed in, and pull a prebuilt image of it from a team's registry instead of
+building; 'cm cloud prebuild create' builds one on a cloud builder. The
+hash covers the image or the Dockerfile with its build args and target, and
+the features with their options, but not the files a Dockerfile copies.
+
+EXAMPLES
+  cm prebuild
+  cm prebuild --push ghcr.io/acme/devcontainers/api
+  cm prebuild hash`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pr, err := prebuildRunner()
+		if err != nil {
+			return err
+		}
+		_, err = pr.Prebuild(context.Background(), prebuildPush)
+		return err
+	},
+}
+
+var prebuildHashCmd = &cobra.Command{
+	Use:   "hash",
+	Short: "Print the prebuild hash of the project's configuration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pr, err := prebuildRunner()
+		if err != nil {
+			return err
+		}
+		hash, err := pr.PrebuildHash()
+		if err != nil {
+			return err
+		}
+		fmt.Println(hash)
+		return nil
+	},
+}
+
+// prebuildRunner returns the runner of the project's devcontainer.json,
+// which must exist: a detected configuration isn't worth prebuilding
+func prebuildRunner() (*runner.PersistentRunner, error) {
+	if configFile == "" {
+		if _, err := os.Stat(".devcontainer/devcontainer.json"); err != nil {
+			if _, err := os.Stat("devcontainer.json"); err != nil {
+				return nil, fmt.Errorf("no devcontainer.json found; pass one with --config")
+			}
+		}
+	}
+	cfg, projectDir, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return runner.NewPersistentRunner(cfg, projectDir)
+}
+
+func init() {
+	prebuildCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Path to devcontainer.json")
+	prebuildCmd.Flags().StringVar(&prebuildPush, "push", "", "Push the image to this repository, e.g. ghcr.io/acme/devcontainers/api")
+	prebuildCmd.AddCommand(prebuildHashCmd)
+	rootCmd.AddCommand(prebuildCmd)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var promptFormat string
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a compact dev container indicator for shell prompts",
+	Long: `Print a compact indicator of the current project's dev container, for
+use in shell prompts. Nothing is printed outside a project.
+
+  ⬢ my-app     container running
+  ⬡ my-app     container stopped
+  ⏸ my-app     container paused (snapshot saved)
+  ◌ my-app     no container yet
+  ... ⇡        devcontainer.json changed since the container was created
+
+The command only reads local files and makes at most one short container
+inspection, so it is cheap enough to run on every prompt. Generate
+snippets for prompt frameworks with:
+
+  cm init --shell starship
+  cm init --shell p10k
+
+EXAMPLES
+  cm prompt
+  cm prompt --format '{{.State}}'
+  PS1='$(cm prompt) '"$PS1"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(promptFormat); err != nil {
+			return err
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil
+		}
+		info := runner.Prompt(context.Background(), cwd)
+		if info == nil {
+			return nil
+		}
+		return output.Print(os.Stdout, promptFormat, info, func() error {
+			fmt.Println(info.Segment())
+			return nil
+		})
+	},
+}
+
+func init() {
+	promptCmd.Flags().StringVar(&promptFormat, "format", "", output.FlagUsage)
+	rootCmd.AddCommand(promptCmd)
+}
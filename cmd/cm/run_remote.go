@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var (
+	runRemote         string
+	runRemoteProvider string
+	runArtifacts      []string
+	runKeepRemote     bool
+)
+
+// runRemoteTarget is where 'cm run --remote' runs a command
+type runRemoteTarget struct {
+	workspace *remoteWorkspace
+	client    *http.Client // Set for cloud instances
+	stopAfter bool         // The instance was provisioned for the command
+}
+
+// resolveRunRemote finds the host named by --remote: a host added with
+// 'cm remote add', a cloud instance by ID or name, or an instance type
+// such as gpu-t4 to provision an instance of
+func resolveRunRemote(target, projectDir string) (*runRemoteTarget, error) {
+	name := remoteProjectName(projectDir)
+	dir := "workspace/" + name
+
+	if cfg, err := userconfig.Load(); err == nil {
+		if host, ok := cfg.RemoteHosts[target]; ok {
+			return &runRemoteTarget{workspace: &remoteWorkspace{Host: host, Dir: dir}}, nil
+		}
+	}
+
+	client, err := getCloudClient()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a remote host (cm remote list), and cloud instances need a login: %w", target, err)
+	}
+	instances, err := listCloudInstances(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instances provisioned by earlier runs are reused, so one per project
+	// and type exists at most
+	isType := strings.HasPrefix(target, "cpu-") || strings.HasPrefix(target, "gpu-")
+	ownName := "cm-run-" + name + "-" + target
+
+	var inst *cloudInstance
+	for i := range instances {
+		candidate := &instances[i]
+		if candidate.Status == "terminated" || candidate.Status == "error" {
+			continue
+		}
+		if candidate.ID == target || candidate.Name == target || (isType && candidate.Name == ownName) {
+			inst = candidate
+			break
+		}
+	}
+
+	stopAfter := inst == nil && isType || inst != nil && inst.Name == ownName
+	if inst == nil {
+		if !isType {
+			return nil, fmt.Errorf("no remote host, cloud instance or instance type named %s", target)
+		}
+		if inst, err = createCloudInstance(client, ownName, target, runRemoteProvider, "", nil); err != nil {
+			return nil, err
+		}
+	} else if inst.Status == "stopped" {
+		fmt.Printf("▶️  Starting instance %s...\n", inst.ID)
+		if err := cloudInstanceAction(client, inst.ID, "start"); err != nil {
+			return nil, err
+		}
+		inst.Status = "starting"
+	}
+
+	if err := waitForCloudInstance(client, inst, 15*time.Minute); err != nil {
+		return nil, err
+	}
+	workspace, err := cloudWorkspace(client, inst.ID, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &runRemoteTarget{workspace: workspace, client: client, stopAfter: stopAfter && !runKeepRemote}, nil
+}
+
+// remoteRunArgs returns the flags set on this 'cm run' for the one on the
+// remote host. Paths are made relative to the workspace, the flags of
+// --remote itself stay here, and a flag it can't pass on is an error
+// rather than silently dropped.
+func remoteRunArgs(flags *pflag.FlagSet, projectDir string) ([]string, error) {
+	var args []string
+	var err error
+	flags.Visit(func(f *pflag.Flag) {
+		if err != nil {
+			return
+		}
+		values := []string{f.Value.String()}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		switch f.Name {
+		case "remote", "remote-provider", "artifact", "keep-remote":
+		case "config", "file":
+			// The workspace is the current directory, so they are inside it
+			for _, value := range values {
+				abs, absErr := filepath.Abs(value)
+				if absErr != nil {
+					err = absErr
+					return
+				}
+				rel, relErr := filepath.Rel(projectDir, abs)
+				if relErr != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					err = fmt.Errorf("--%s must be inside the current directory with --remote", f.Name)
+					return
+				}
+				args = append(args, "--"+f.Name+"="+filepath.ToSlash(rel))
+			}
+		case "rm", "name", "detach", "profile", "strict", "ignore-host-requirements", "publish-all", "remap-ports", "offline":
+			for _, value := range values {
+				args = append(args, "--"+f.Name+"="+value)
+			}
+		default:
+			err = fmt.Errorf("--%s can't be used with --remote", f.Name)
+		}
+	})
+	return args, err
+}
+
+// runOnRemote runs a command in the project's dev container on a remote
+// host: it syncs the workspace, runs 'cm run' there with the output
+// streamed back, fetches the artifacts and stops an instance provisioned
+// for it. It returns the command's exit code.
+func runOnRemote(target string, flags *pflag.FlagSet, args []string) (int, error) {
+	if runDetach && len(runArtifacts) > 0 {
+		return 0, fmt.Errorf("--artifact can't be used with --detach; the command is still running when 'cm run' returns")
+	}
+	// As locally, a detached run prints only the container ID on stdout
+	stdout := os.Stdout
+	if runDetach {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	flagArgs, err := remoteRunArgs(flags, projectDir)
+	if err != nil {
+		return 0, err
+	}
+	remote, err := resolveRunRemote(target, projectDir)
+	if err != nil {
+		return 0, err
+	}
+	ws := remote.workspace
+	if remote.stopAfter {
+		defer func() {
+			fmt.Printf("⏹️  Stopping instance %s...\n", ws.InstanceID)
+			if err := cloudInstanceAction(remote.client, ws.InstanceID, "stop"); err != nil {
+				fmt.Printf("⚠️  %v; stop it with: cm cloud stop %s\n", err, ws.InstanceID)
+			}
+		}()
+		if runDetach {
+			return 0, fmt.Errorf("--detach on an instance provisioned by --remote needs --keep-remote, or it is stopped under the command")
+		}
+	}
+
+	fmt.Printf("🔌 Connecting to %s...\n", ws.destination())
+	if err := ws.waitForSSH(5 * time.Minute); err != nil {
+		return 0, err
+	}
+	if err := ws.run(cloudDevBootstrapScript(ws.Dir)); err != nil {
+		return 0, fmt.Errorf("failed to prepare %s: %w", ws.destination(), err)
+	}
+	if err := ws.syncWorkspace(projectDir, "rsync"); err != nil {
+		return 0, err
+	}
+
+	var quoted []string
+	for _, arg := range append(append(flagArgs, "--"), args...) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	command := fmt.Sprintf("cd %s && cm run %s", ws.Dir, strings.Join(quoted, " "))
+
+	fmt.Printf("🚀 Running on %s: %s\n", ws.destination(), strings.Join(args, " "))
+	// A terminal would merge the remote stderr into stdout
+	var extra []string
+	if term.IsTerminal(int(os.Stdin.Fd())) && !runDetach {
+		extra = append(extra, "-t")
+	}
+	cmd := ws.sshCommand(command, extra...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return 0, err
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	// Artifacts are fetched even when the command failed, for its logs
+	if len(runArtifacts) > 0 {
+		syncer, err := ws.syncer(projectDir)
+		if err != nil {
+			return exitCode, err
+		}
+		for _, artifact := range runArtifacts {
+			fmt.Printf("📥 Fetching %s...\n", artifact)
+			if err := syncer.FetchFromRemote(artifact); err != nil {
+				fmt.Printf("⚠️  Failed to fetch %s: %v\n", artifact, err)
+			}
+		}
+	}
+
+	if exitCode != 0 {
+		return exitCode, fmt.Errorf("remote command exited with status %d", exitCode)
+	}
+	return 0, nil
+}
@@ -2,81 +2,219 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/UPwith-me/Container-Maker/pkg/scan"
 	"github.com/spf13/cobra"
 )
 
+var (
+	scanConfig        string
+	scanScanner       string
+	scanFailOn        string
+	scanIgnoreUnfixed bool
+	scanJSON          bool
+	scanOutput        string
+	scanBackend       string
+)
+
 var scanCmd = &cobra.Command{
 	Use:   "scan [image]",
 	Short: "Scan an image for vulnerabilities securely",
-	Long:  `Scan a container image for vulnerabilities using Trivy.`,
+	Long: `Scan a container image for known vulnerabilities (CVEs).
+
+Without an image, the dev container image of the current project is
+resolved first: the image from devcontainer.json, or the image built from
+its Dockerfile, with all features applied. That is the image developers and
+CI actually run.
+
+The scanner is Trivy or Grype when installed; otherwise Trivy runs in a
+container (` + scan.TrivyImage + `). Use --fail-on in CI to exit non-zero
+when vulnerabilities of a severity or worse are found.
+
+EXAMPLES
+  cm scan
+  cm scan python:3.12-slim
+  cm scan --fail-on high --ignore-unfixed
+  cm scan --scanner grype --json --output scan-report.json`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		failOn := ""
+		if scanFailOn != "" {
+			var err error
+			if failOn, err = scan.ParseSeverity(scanFailOn); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
 		var image string
 		if len(args) > 0 {
 			image = args[0]
 		} else {
-			// Try to resolve current project image from config
-			// "Industrial Grade": Use PersistentRunner to get image
-			cfg, _, err := loadConfig()
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w (provide image manually or run in project root)", err)
-			}
-			image = cfg.Image
-			if image == "" {
-				// Try to construct from build?
-				// For simple MVP+, prompt user
-				return fmt.Errorf("no image specified and could not detect from config")
+			var err error
+			if image, err = resolveScanImage(ctx); err != nil {
+				return err
 			}
-			fmt.Printf("🔍 Auto-detected image from config: %s\n", image)
 		}
 
-		scanner := scan.NewTrivyScanner()
+		scanner, err := scan.New(scanScanner, scanBackend)
+		if err != nil {
+			return err
+		}
 		if !scanner.IsAvailable() {
-			fmt.Println("❌ Security Scanner (Trivy) not found.")
-			fmt.Println("   Please install it: https://aquasecurity.github.io/trivy/v0.18.3/installation/")
-			// Attempt auto-download could happen here in strict Industrial Enterprise version
-			return fmt.Errorf("trivy binary not found in PATH")
+			fmt.Printf("❌ Security scanner (%s) not found.\n", scanner.Name())
+			fmt.Println("   Install Trivy: https://trivy.dev/latest/getting-started/installation/")
+			fmt.Println("   or Grype:      https://github.com/anchore/grype#installation")
+			return fmt.Errorf("%s is not available", scanner.Name())
 		}
 
-		fmt.Printf("🛡️  Scanning image %s...\n", image)
-		report, err := scanner.Scan(context.Background(), image)
+		if !scanJSON {
+			fmt.Printf("🛡️  Scanning image %s with %s...\n", image, scanner.Name())
+		}
+		report, err := scanner.Scan(ctx, image)
 		if err != nil {
 			return err
 		}
+		if scanIgnoreUnfixed {
+			report.DropUnfixed()
+		}
 
-		// Print Report
-		fmt.Println("\nScanning Result:")
-		fmt.Printf("Image: %s\n", report.Image)
-		fmt.Printf("Time:  %s\n", report.ScannedAt)
-		fmt.Println("Summary:")
-		fmt.Printf("  CRITICAL: %d\n", report.Summary[scan.SeverityCritical])
-		fmt.Printf("  HIGH:     %d\n", report.Summary[scan.SeverityHigh])
-		fmt.Printf("  MEDIUM:   %d\n", report.Summary[scan.SeverityMedium])
-		fmt.Printf("  LOW:      %d\n", report.Summary[scan.SeverityLow])
-
-		if len(report.Vulns) > 0 {
-			fmt.Println("\nTop Vulnerabilities (High/Critical):")
-			count := 0
-			for _, v := range report.Vulns {
-				if v.Severity == scan.SeverityCritical || v.Severity == scan.SeverityHigh {
-					fmt.Printf("- [%s] %s (%s) - Fixed in: %s\n", v.Severity, v.PkgName, v.VulnerabilityID, v.FixedVersion)
-					count++
-					if count >= 10 {
-						fmt.Println("  ... and more")
-						break
-					}
+		if scanOutput != "" || scanJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			if scanOutput != "" {
+				if err := os.WriteFile(scanOutput, append(data, '\n'), 0644); err != nil {
+					return err
 				}
 			}
-		} else {
-			fmt.Println("\n✅ No vulnerabilities found!")
+			if scanJSON {
+				fmt.Println(string(data))
+			}
+		}
+		if !scanJSON {
+			printScanReport(report)
+			if scanOutput != "" {
+				fmt.Printf("\n📄 Report written to %s\n", scanOutput)
+			}
 		}
 
+		if failOn != "" {
+			if n := report.CountAtOrAbove(failOn); n > 0 {
+				fmt.Fprintf(os.Stderr, "❌ %s at or above %s\n", vulnCount(n), failOn)
+				os.Exit(1)
+			}
+		}
 		return nil
 	},
 }
 
+// resolveScanImage builds or pulls the project's dev container image,
+// including features, and returns its tag
+func resolveScanImage(ctx context.Context) (string, error) {
+	configPath := scanConfig
+	if configPath == "" {
+		if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+			configPath = ".devcontainer/devcontainer.json"
+		} else if _, err := os.Stat("devcontainer.json"); err == nil {
+			configPath = "devcontainer.json"
+		} else {
+			return "", fmt.Errorf("no image given and no devcontainer.json found")
+		}
+	}
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	if runner.IsComposeConfig(cfg) {
+		return "", fmt.Errorf("%s uses Docker Compose; pass the image to scan", configPath)
+	}
+
+	r, err := runner.NewRunner(cfg)
+	if err != nil {
+		return "", err
+	}
+	if !scanJSON {
+		fmt.Printf("🔍 Resolving the dev container image from %s...\n", configPath)
+	}
+	return r.ResolveImage(ctx)
+}
+
+func printScanReport(report *scan.Report) {
+	fmt.Println("\nScanning Result:")
+	fmt.Printf("Image: %s\n", report.Image)
+	fmt.Printf("Time:  %s\n", report.ScannedAt)
+	fmt.Println("Summary:")
+	for _, severity := range scan.Severities {
+		if severity == scan.SeverityUnknown && report.Summary[severity] == 0 {
+			continue
+		}
+		fmt.Printf("  %-9s %d\n", severity+":", report.Summary[severity])
+	}
+
+	if len(report.Vulns) == 0 {
+		fmt.Println("\n✅ No vulnerabilities found!")
+		return
+	}
+
+	// Vulns are sorted most severe first
+	const limit = 15
+	shown := 0
+	for _, v := range report.Vulns {
+		if scan.SeverityRank(v.Severity) < scan.SeverityRank(scan.SeverityHigh) {
+			break
+		}
+		if shown == 0 {
+			fmt.Println("\nTop Vulnerabilities (High/Critical):")
+		}
+		if shown == limit {
+			fmt.Printf("  ... and %d more\n", report.CountAtOrAbove(scan.SeverityHigh)-limit)
+			break
+		}
+		fixed := v.FixedVersion
+		if fixed == "" {
+			fixed = "no fix yet"
+		}
+		fmt.Printf("- [%s] %s %s (%s) - Fixed in: %s\n", v.Severity, v.PkgName, v.InstalledVersion, v.VulnerabilityID, fixed)
+		shown++
+	}
+	if critical := report.Summary[scan.SeverityCritical]; critical > 0 {
+		var pkgs []string
+		seen := make(map[string]bool)
+		for _, v := range report.Vulns {
+			if v.Severity == scan.SeverityCritical && !seen[v.PkgName] {
+				seen[v.PkgName] = true
+				pkgs = append(pkgs, v.PkgName)
+			}
+		}
+		fmt.Printf("\n⚠️  %s rated critical, in: %s\n", vulnCount(critical), strings.Join(pkgs, ", "))
+	}
+}
+
+func vulnCount(n int) string {
+	if n == 1 {
+		return "1 vulnerability"
+	}
+	return fmt.Sprintf("%d vulnerabilities", n)
+}
+
 func init() {
+	scanCmd.Flags().StringVarP(&scanConfig, "config", "c", "", "Path to devcontainer.json")
+	scanCmd.Flags().StringVar(&scanScanner, "scanner", scan.ScannerAuto, "Scanner to use: auto, trivy, grype or trivy-container")
+	scanCmd.Flags().StringVar(&scanFailOn, "fail-on", "", "Exit 1 when vulnerabilities of this severity or worse are found (critical, high, medium, low)")
+	scanCmd.Flags().BoolVar(&scanIgnoreUnfixed, "ignore-unfixed", false, "Ignore vulnerabilities without a fixed version")
+	scanCmd.Flags().BoolVar(&scanJSON, "json", false, "Print the report as JSON")
+	scanCmd.Flags().StringVarP(&scanOutput, "output", "o", "", "Also write the JSON report to a file")
+	scanCmd.Flags().StringVar(&scanBackend, "backend", "docker", "Container CLI for the containerized scanner")
 	rootCmd.AddCommand(scanCmd)
 }
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/UPwith-me/Container-Maker/cloud/api"
+	"github.com/UPwith-me/Container-Maker/cloud/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverDataDir  string
+	serverAddr     string
+	serverTLSAuto  bool
+	serverDomains  []string
+	serverEmail    string
+	serverTLSCert  string
+	serverTLSKey   string
+	serverHTTPAddr string
+	serverUser     string
+	serverInstall  bool
+)
+
+// serverUnitPath is where 'cm server systemd --install' writes the unit
+const serverUnitPath = "/etc/systemd/system/cm-server.service"
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Run a self-hosted control plane",
+	Long: `Run the cloud control plane (API and dashboard) from the cm binary.
+
+Everything it keeps goes in one data directory (default ~/.cm/server): the
+SQLite database, a generated secret that signs sessions and encrypts cloud
+credentials, TLS certificates, and provider plugins (cm-provider-*
+executables in plugins/, or PROVIDER_PLUGIN_DIR). Point it at Postgres with
+DB_DRIVER and DATABASE_URL; the other environment variables cm-server reads
+(JWT_SECRET, SMTP_*, STRIPE_SECRET_KEY, GITHUB_APP_*, ...) work the same way.
+
+With --tls-auto it serves HTTPS: with a certificate from Let's Encrypt for
+each --domain, which must resolve to this host and reach it on ports 443
+and 80, or otherwise with a generated self-signed certificate.
+
+EXAMPLES
+  cm server start
+  cm server start --tls-auto --domain cm.example.com --email ops@example.com
+  cm server migrate status
+  sudo cm server systemd --install --tls-auto --domain cm.example.com`,
+}
+
+var serverStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the control plane in the foreground",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := selfHostedConfig(true)
+		if err != nil {
+			return err
+		}
+		server, err := api.NewServer(config)
+		if err != nil {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+
+		scheme, host, defaultPort := "http", "localhost", "80"
+		if config.TLSCertFile != "" || len(config.TLSDomains) > 0 || config.TLSSelfSigned {
+			scheme, defaultPort = "https", "443"
+		}
+		if len(config.TLSDomains) > 0 {
+			host = config.TLSDomains[0]
+		}
+		if _, port, err := net.SplitHostPort(config.Addr); err == nil && port != defaultPort {
+			host += ":" + port
+		}
+		log.Printf("🚀 Control plane running on %s", config.Addr)
+		log.Printf("📂 Data: %s", serverDataDir)
+		log.Printf("📦 Database: %s", config.DatabaseDriver)
+		if config.TLSSelfSigned {
+			log.Printf("🔒 TLS: self-signed certificate in %s", config.TLSDir)
+		} else if len(config.TLSDomains) > 0 {
+			log.Printf("🔒 TLS: Let's Encrypt for %s", strings.Join(config.TLSDomains, ", "))
+		}
+		if config.DevMode {
+			log.Printf("⚠️  Dev mode: unauthenticated requests act as a demo user")
+		}
+		log.Printf("🔗 Dashboard: %s://%s", scheme, host)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := server.Run(ctx); err != nil {
+			return err
+		}
+		log.Printf("👋 Control plane stopped")
+		return nil
+	},
+}
+
+var serverMigrateCmd = &cobra.Command{
+	Use:   "migrate [up|status]",
+	Short: "Apply or list database migrations",
+	Long: `Apply the database's pending migrations, or list them with status.
+
+The control plane applies pending migrations when it starts unless
+DB_AUTO_MIGRATE=false; then run this before starting a new release.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"up", "status"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := selfHostedConfig(false)
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 && args[0] == "status" {
+			return db.PrintMigrationStatus(config.DatabaseConfig(), os.Stdout)
+		}
+		if len(args) == 1 && args[0] != "up" {
+			return fmt.Errorf("unknown migrate command %q; use up or status", args[0])
+		}
+		return db.Migrate(config.DatabaseConfig(), log.Printf)
+	},
+}
+
+var serverSystemdCmd = &cobra.Command{
+	Use:   "systemd",
+	Short: "Generate a systemd unit that runs the control plane",
+	Long: `Print a systemd unit that runs 'cm server start' with the given flags, or
+with --install write it to ` + serverUnitPath + ` and enable it.
+
+The unit reads further settings from /etc/cm/server.env if it exists, e.g.
+DATABASE_URL or SMTP_ADDR, and may bind ports 80 and 443 as a normal user.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := selfHostedConfig(false); err != nil {
+			return err
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		unitUser := serverUser
+		if unitUser == "" {
+			current, err := user.Current()
+			if err != nil {
+				return err
+			}
+			unitUser = current.Username
+		}
+
+		unit := serverUnit(unitUser, append([]string{exe}, serverStartArgs()...))
+		if !serverInstall {
+			fmt.Print(unit)
+			return nil
+		}
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("--install needs Linux with systemd")
+		}
+		if err := os.WriteFile(serverUnitPath, []byte(unit), 0644); err != nil {
+			return fmt.Errorf("failed to write %s (run with sudo?): %w", serverUnitPath, err)
+		}
+		if serverUser != "" {
+			if err := chownToUser(serverDataDir, serverUser); err != nil {
+				return fmt.Errorf("failed to give %s the data directory: %w", serverUser, err)
+			}
+		}
+		for _, args := range [][]string{{"daemon-reload"}, {"enable", "--now", "cm-server"}} {
+			systemctl := exec.Command("systemctl", args...)
+			systemctl.Stdout, systemctl.Stderr = os.Stdout, os.Stderr
+			if err := systemctl.Run(); err != nil {
+				return fmt.Errorf("systemctl %s failed: %w", strings.Join(args, " "), err)
+			}
+		}
+		fmt.Printf("✅ Installed %s and started cm-server\n", serverUnitPath)
+		fmt.Println("   Logs: journalctl -u cm-server -f")
+		return nil
+	},
+}
+
+// selfHostedConfig returns the server configuration from the environment
+// and the flags, with the data directory holding the SQLite database,
+// TLS certificates and, created if withSecret and JWT_SECRET isn't set,
+// the secret
+func selfHostedConfig(withSecret bool) (api.Config, error) {
+	if serverDataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return api.Config{}, err
+		}
+		serverDataDir = filepath.Join(home, ".cm", "server")
+	}
+	dataDir, err := filepath.Abs(serverDataDir)
+	if err != nil {
+		return api.Config{}, err
+	}
+	serverDataDir = dataDir
+	if len(serverDomains) > 0 && !serverTLSAuto {
+		return api.Config{}, fmt.Errorf("--domain needs --tls-auto")
+	}
+	if (serverTLSCert == "") != (serverTLSKey == "") {
+		return api.Config{}, fmt.Errorf("--tls-cert and --tls-key go together")
+	}
+	if serverTLSAuto && serverTLSCert != "" {
+		return api.Config{}, fmt.Errorf("--tls-auto and --tls-cert can't be used together")
+	}
+
+	config, err := api.ConfigFromEnv()
+	if err != nil {
+		return api.Config{}, err
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return api.Config{}, err
+	}
+	if config.DatabaseDriver == "sqlite" && os.Getenv("DATABASE_URL") == "" {
+		config.DatabaseURL = filepath.Join(dataDir, "cloud.db")
+	}
+	if withSecret && os.Getenv("JWT_SECRET") == "" {
+		if config.JWTSecret, err = serverSecret(dataDir); err != nil {
+			return api.Config{}, err
+		}
+	}
+
+	config.TLSDir = filepath.Join(dataDir, "tls")
+	if config.ProviderPluginDir == "" {
+		config.ProviderPluginDir = filepath.Join(dataDir, "plugins")
+	}
+	config.TLSCertFile, config.TLSKeyFile = serverTLSCert, serverTLSKey
+	if serverTLSAuto {
+		if len(serverDomains) > 0 {
+			config.TLSDomains = serverDomains
+			config.TLSEmail = serverEmail
+			config.HTTPRedirectAddr = serverHTTPAddr
+		} else {
+			config.TLSSelfSigned = true
+		}
+	}
+	config.Addr = serverAddr
+	if config.Addr == "" {
+		config.Addr = fmt.Sprintf(":%d", config.Port)
+		if len(config.TLSDomains) > 0 {
+			config.Addr = ":443"
+		}
+	}
+	return config, nil
+}
+
+// serverSecret returns the secret kept in the data directory, generating
+// it on first start; losing it signs everyone out and makes saved cloud
+// credentials unreadable
+func serverSecret(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, "secret")
+	if data, err := os.ReadFile(path); err == nil {
+		if secret := strings.TrimSpace(string(data)); secret != "" {
+			return secret, nil
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save secret: %w", err)
+	}
+	return secret, nil
+}
+
+// serverStartArgs returns the 'cm server start' arguments that reproduce
+// this command's flags
+func serverStartArgs() []string {
+	args := []string{"server", "start", "--data-dir", serverDataDir}
+	if serverAddr != "" {
+		args = append(args, "--addr", serverAddr)
+	}
+	if serverTLSAuto {
+		args = append(args, "--tls-auto")
+	}
+	for _, domain := range serverDomains {
+		args = append(args, "--domain", domain)
+	}
+	if serverEmail != "" {
+		args = append(args, "--email", serverEmail)
+	}
+	if serverTLSCert != "" {
+		certPath, _ := filepath.Abs(serverTLSCert)
+		keyPath, _ := filepath.Abs(serverTLSKey)
+		args = append(args, "--tls-cert", certPath, "--tls-key", keyPath)
+	}
+	if serverHTTPAddr != ":80" {
+		args = append(args, "--http-addr", serverHTTPAddr)
+	}
+	return args
+}
+
+// chownToUser makes a user the owner of a directory and what's in it
+func chownToUser(dir, name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// serverUnit returns a systemd unit running a command as a user
+func serverUnit(unitUser string, command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Container-Maker control plane
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+User=%s
+EnvironmentFile=-/etc/cm/server.env
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+TimeoutStopSec=40
+AmbientCapabilities=CAP_NET_BIND_SERVICE
+NoNewPrivileges=true
+
+[Install]
+WantedBy=multi-user.target
+`, unitUser, strings.Join(quoted, " "))
+}
+
+// systemdQuote quotes an ExecStart argument, escaping the specifiers and
+// variables systemd would otherwise expand
+func systemdQuote(s string) string {
+	s = strings.NewReplacer("%", "%%", "$", "$$").Replace(s)
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{serverStartCmd, serverMigrateCmd, serverSystemdCmd} {
+		cmd.Flags().StringVar(&serverDataDir, "data-dir", "", "Directory for the database, secret and certificates (default ~/.cm/server)")
+	}
+	for _, cmd := range []*cobra.Command{serverStartCmd, serverSystemdCmd} {
+		cmd.Flags().StringVar(&serverAddr, "addr", "", "Listen address (default :8080, or :443 with --domain)")
+		cmd.Flags().BoolVar(&serverTLSAuto, "tls-auto", false, "Serve HTTPS with Let's Encrypt certificates for --domain, or a self-signed one")
+		cmd.Flags().StringSliceVar(&serverDomains, "domain", nil, "Domain to get a Let's Encrypt certificate for (repeatable)")
+		cmd.Flags().StringVar(&serverEmail, "email", "", "Contact email for the Let's Encrypt account")
+		cmd.Flags().StringVar(&serverTLSCert, "tls-cert", "", "PEM certificate to serve instead, with --tls-key")
+		cmd.Flags().StringVar(&serverTLSKey, "tls-key", "", "PEM private key for --tls-cert")
+		cmd.Flags().StringVar(&serverHTTPAddr, "http-addr", ":80", "With --domain, address answering Let's Encrypt challenges and redirecting to HTTPS; empty disables")
+	}
+	serverSystemdCmd.Flags().StringVar(&serverUser, "user", "", "User the service runs as (default the current user)")
+	serverSystemdCmd.Flags().BoolVar(&serverInstall, "install", false, "Write the unit to "+serverUnitPath+" and enable it")
+
+	serverCmd.AddCommand(serverStartCmd)
+	serverCmd.AddCommand(serverMigrateCmd)
+	serverCmd.AddCommand(serverSystemdCmd)
+	rootCmd.AddCommand(serverCmd)
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/UPwith-me/Container-Maker/pkg/i18n"
 	"github.com/UPwith-me/Container-Maker/pkg/runtime"
 	"github.com/spf13/cobra"
 )
@@ -43,36 +44,36 @@ func init() {
 }
 
 func runSetup(cmd *cobra.Command, args []string) error {
-	fmt.Println("🚀 Container-Maker Setup Wizard")
+	fmt.Println(i18n.T("setup.title"))
 	fmt.Println()
 
 	// Detect host
-	fmt.Println("🔍 Detecting system environment...")
+	fmt.Println(i18n.T("setup.detecting"))
 	host := runtime.DetectHost()
 	fmt.Println()
 	fmt.Println(host.FormatHostInfo())
 
 	// Check if already installed
 	if host.HasDocker || host.HasPodman {
-		fmt.Println("✅ Container runtime detected, no installation needed!")
+		fmt.Println(i18n.T("setup.runtime_found"))
 		fmt.Println()
 
 		// Run doctor to verify
 		if host.HasDocker {
-			fmt.Println("💡 Run 'cm doctor' to check Docker status")
+			fmt.Println(i18n.T("setup.doctor_hint"))
 		}
 		return nil
 	}
 
 	if setupDetectOnly {
-		fmt.Println("💡 Use 'cm setup' to install container runtime")
+		fmt.Println(i18n.T("setup.install_hint"))
 		return nil
 	}
 
 	// Get installation options
 	options := host.GetInstallOptions()
 	if len(options) == 0 {
-		fmt.Println("❌ Cannot provide installation recommendations for your system")
+		fmt.Println(i18n.T("setup.no_options"))
 		return nil
 	}
 
@@ -83,12 +84,12 @@ func runSetup(cmd *cobra.Command, args []string) error {
 
 	// Auto mode: install the first (highest priority) option
 	if setupAuto {
-		fmt.Printf("🔧 Auto-installing: %s\n", options[0].Name)
+		fmt.Println(i18n.T("setup.auto_install", options[0].Name))
 		return executeInstall(options[0])
 	}
 
 	// Interactive mode
-	fmt.Println("📋 Recommended installation options:")
+	fmt.Println(i18n.T("setup.recommended"))
 	fmt.Println()
 
 	for i, opt := range options {
@@ -101,20 +102,20 @@ func runSetup(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
-	fmt.Print("Select option (1-", len(options), ") or 'q' to quit: ")
+	fmt.Print(i18n.T("setup.select", len(options)))
 
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
 
 	if input == "q" || input == "Q" {
-		fmt.Println("Cancelled")
+		fmt.Println(i18n.T("setup.cancelled"))
 		return nil
 	}
 
 	choice, err := strconv.Atoi(input)
 	if err != nil || choice < 1 || choice > len(options) {
-		fmt.Println("❌ Invalid selection")
+		fmt.Println(i18n.T("setup.invalid"))
 		return nil
 	}
 
@@ -123,20 +124,20 @@ func runSetup(cmd *cobra.Command, args []string) error {
 
 func executeInstall(opt runtime.InstallOption) error {
 	fmt.Println()
-	fmt.Printf("🔧 Installing %s...\n", opt.Name)
+	fmt.Println(i18n.T("setup.installing", opt.Name))
 	fmt.Println()
-	fmt.Println("📝 Executing command:")
+	fmt.Println(i18n.T("setup.executing"))
 	fmt.Printf("   %s\n", opt.Command)
 	fmt.Println()
 
 	// Confirm
-	fmt.Print("Confirm execution? [Y/n] ")
+	fmt.Print(i18n.T("setup.confirm"))
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(strings.ToLower(input))
 
 	if input != "" && input != "y" && input != "yes" {
-		fmt.Println("Cancelled")
+		fmt.Println(i18n.T("setup.cancelled"))
 		return nil
 	}
 
@@ -158,23 +159,23 @@ func executeInstall(opt runtime.InstallOption) error {
 
 	err := cmd.Run()
 	if err != nil {
-		fmt.Printf("\n❌ Installation failed: %v\n", err)
+		fmt.Println("\n" + i18n.T("setup.failed", err))
 		fmt.Println()
-		fmt.Println("💡 Please try running the command manually or check the error")
+		fmt.Println(i18n.T("setup.failed_hint"))
 		return nil
 	}
 
 	fmt.Println()
-	fmt.Println("✅ Installation complete!")
+	fmt.Println(i18n.T("setup.complete"))
 	fmt.Println()
-	fmt.Println("📋 Next steps:")
-	fmt.Println("   1. If Docker Desktop was installed, start the application")
-	fmt.Println("   2. Run 'cm doctor' to verify installation")
-	fmt.Println("   3. Run 'cm shell' to start using container dev environment")
+	fmt.Println(i18n.T("setup.next_steps"))
+	fmt.Println(i18n.T("setup.next_desktop"))
+	fmt.Println(i18n.T("setup.next_doctor"))
+	fmt.Println(i18n.T("setup.next_shell"))
 
 	if !isWindows() {
 		fmt.Println()
-		fmt.Println("⚠️  Note: If you added docker user group, re-login or run:")
+		fmt.Println(i18n.T("setup.relogin"))
 		fmt.Println("   newgrp docker")
 	}
 
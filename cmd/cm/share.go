@@ -19,9 +19,14 @@ set up the same development environment.
 The link encodes the repository URL and configuration, allowing anyone
 with Container-Maker installed to clone and run with a single command.
 
+To share the running environment itself, use 'cm share start': it exposes
+forwarded ports, or an SSH endpoint into the container, through a relay.
+
 Examples:
   cm share                    # Generate link for current project
-  cm share --format markdown  # Output as markdown link`,
+  cm share --format markdown  # Output as markdown link
+  cm share start 3000 --ssh   # Share a web app and SSH access
+  cm share stop               # Stop sharing`,
 	RunE: runShare,
 }
 
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/output"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/UPwith-me/Container-Maker/pkg/share"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// defaultShareRelay is the CM cloud relay, used unless share.relay is set
+const defaultShareRelay = "https://relay.container-maker.dev"
+
+// sharePasswordEnv supplies the password of a protected share to
+// 'cm share connect' when it cannot prompt
+const sharePasswordEnv = "CM_SHARE_PASSWORD"
+
+var (
+	shareSSH        bool
+	shareExpires    time.Duration
+	sharePassword   bool
+	shareRelay      string
+	shareForeground bool
+	shareStopAll    bool
+	shareListFormat string
+	shareListen     string
+
+	relayListen    string
+	relayPublicURL string
+	relayToken     string
+	relayCert      string
+	relayKey       string
+	relayMaxTTL    time.Duration
+)
+
+var shareStartCmd = &cobra.Command{
+	Use:   "start [port[/tcp]...]",
+	Short: "Share the running dev container through a relay",
+	Long: `Share the running persistent container with other people through a relay.
+
+Each port becomes a link: HTTP ports open in a browser, PORT/tcp ports are
+reached with 'cm share connect'. Without ports, the forwarded ports of
+devcontainer.json are shared. --ssh adds an SSH endpoint served by sshd
+inside the container (add the sshd feature); nothing is published on the
+host for it.
+
+Links are unguessable and expire (--expires, default 2h). --password asks
+for a password that visitors must give. The share runs in the background
+until it expires or 'cm share stop'.
+
+By default the CM cloud relay is used, which needs 'cm cloud login'. Run
+your own with 'cm share relay' and point cm at it with
+'cm config set share.relay https://share.example.com' or --relay.
+
+Examples:
+  cm share start                       # forwarded ports
+  cm share start 3000 5432/tcp --ssh   # a web app, a database and SSH
+  cm share start 8080 --expires 30m --password`,
+	RunE: runShareStart,
+}
+
+var shareServeCmd = &cobra.Command{
+	Use:    "serve",
+	Short:  "Run a share read from stdin in the foreground",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts share.Options
+		if err := json.NewDecoder(os.Stdin).Decode(&opts); err != nil {
+			return fmt.Errorf("invalid share options: %w", err)
+		}
+		// Outlive the terminal that started it
+		signal.Ignore(syscall.SIGHUP)
+		return serveShare(opts, func(s *share.Session) {
+			// Hand the session to 'cm share start', which waits for it
+			_ = json.NewEncoder(os.Stdout).Encode(s)
+			os.Stdout.Close()
+		})
+	},
+}
+
+var shareListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List running shares",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.Validate(shareListFormat); err != nil {
+			return err
+		}
+		sessions, err := share.List()
+		if err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, shareListFormat, sessions, func() error {
+			if len(sessions) == 0 {
+				fmt.Println("No running shares. Start one with 'cm share start'.")
+				return nil
+			}
+			for _, s := range sessions {
+				printShareSession(s)
+			}
+			return nil
+		})
+	},
+}
+
+var shareStopCmd = &cobra.Command{
+	Use:   "stop [id]",
+	Short: "Stop sharing",
+	Long: `Stop a share by ID (or a prefix of it), every share of the current
+project without an ID, or every share with --all. Its links stop working
+immediately.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeShares,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var sessions []*share.Session
+		switch {
+		case len(args) == 1:
+			s, err := share.Find(args[0])
+			if err != nil {
+				return err
+			}
+			sessions = append(sessions, s)
+		default:
+			all, err := share.List()
+			if err != nil {
+				return err
+			}
+			project, _ := os.Getwd()
+			for _, s := range all {
+				if shareStopAll || s.Project == project {
+					sessions = append(sessions, s)
+				}
+			}
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No running shares to stop")
+			return nil
+		}
+		for _, s := range sessions {
+			if err := s.Stop(); err != nil {
+				return err
+			}
+			fmt.Printf("🛑 Stopped share %s (%s)\n", shortShareID(s.ID), filepath.Base(s.Project))
+		}
+		return nil
+	},
+}
+
+var shareConnectCmd = &cobra.Command{
+	Use:   "connect <link>",
+	Short: "Connect to a shared TCP port or SSH endpoint",
+	Long: `Connect to a TCP or SSH share. By default the connection is relayed over
+stdin and stdout, which suits ssh's ProxyCommand; --listen accepts local
+connections instead.
+
+Protected shares need the password in CM_SHARE_PASSWORD, or typed at the
+prompt with --listen.
+
+Examples:
+  ssh -o ProxyCommand="cm share connect https://relay.container-maker.dev/s/<id>/ssh" vscode@share
+  cm share connect https://relay.container-maker.dev/s/<id>/5432 --listen 127.0.0.1:5432`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		link := args[0]
+		password := os.Getenv(sharePasswordEnv)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if shareListen == "" {
+			conn, err := share.Connect(ctx, link, password)
+			if err != nil {
+				return err
+			}
+			share.Pipe(conn, stdio{})
+			return nil
+		}
+
+		// Check the link, and the password, before accepting connections
+		conn, err := share.Connect(ctx, link, password)
+		if err == share.ErrPassword && password == "" && term.IsTerminal(int(os.Stdin.Fd())) {
+			if password, err = readSharePassword("Share password: "); err == nil {
+				conn, err = share.Connect(ctx, link, password)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		conn.Close()
+
+		l, err := net.Listen("tcp", shareListen)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			l.Close()
+		}()
+		fmt.Printf("📡 Forwarding %s → %s (Ctrl+C to stop)\n", l.Addr(), link)
+		for {
+			local, err := l.Accept()
+			if err != nil {
+				return nil
+			}
+			go func() {
+				remote, err := share.Connect(ctx, link, password)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+					local.Close()
+					return
+				}
+				share.Pipe(local, remote)
+			}()
+		}
+	},
+}
+
+var shareRelayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Run a self-hosted share relay",
+	Long: `Run a relay that 'cm share start' can use instead of CM cloud.
+
+Serve it over TLS (--tls-cert and --tls-key, or behind a TLS-terminating
+proxy with --public-url https://...) so that shared traffic is encrypted.
+With --token, only clients configured with
+'cm config set share.token <token>' may share through it.
+
+Example:
+  cm share relay --listen :8443 --tls-cert cert.pem --tls-key key.pem --token s3cret`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (relayCert == "") != (relayKey == "") {
+			return fmt.Errorf("--tls-cert and --tls-key go together")
+		}
+		relay := share.NewRelay(share.RelayOptions{PublicURL: relayPublicURL, Token: relayToken, MaxTTL: relayMaxTTL})
+		server := &http.Server{Addr: relayListen, Handler: relay, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			_ = server.Close()
+		}()
+
+		fmt.Printf("🛰️  Share relay listening on %s\n", relayListen)
+		var err error
+		if relayCert != "" {
+			err = server.ListenAndServeTLS(relayCert, relayKey)
+		} else {
+			if relayPublicURL == "" || share.Insecure(relayPublicURL) {
+				fmt.Println("⚠️  Serving without TLS: shared traffic is not encrypted. Use --tls-cert/--tls-key or a TLS proxy.")
+			}
+			err = server.ListenAndServe()
+		}
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	},
+}
+
+func runShareStart(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cfg, projectDir, err := findDevConfig()
+	if err != nil {
+		return fmt.Errorf("no devcontainer.json found in the current directory")
+	}
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+	summary := pr.Summary(ctx)
+	if summary.State != "running" {
+		return fmt.Errorf("the dev container is not running; start it with 'cm shell'")
+	}
+
+	// Share the forwarded ports unless told otherwise
+	if len(args) == 0 && !shareSSH {
+		for _, spec := range cfg.PortSpecs() {
+			port := spec[strings.LastIndex(spec, ":")+1:]
+			if strings.HasSuffix(port, "/udp") {
+				continue
+			}
+			args = append(args, strings.TrimSuffix(port, "/tcp"))
+		}
+	}
+	var targets []share.Target
+	for _, arg := range args {
+		target, err := share.ParseTarget(arg)
+		if err != nil {
+			return err
+		}
+		if target.Kind != share.KindSSH {
+			hostPort, err := pr.PublishedPort(ctx, target.Name)
+			if err != nil {
+				return err
+			}
+			target.Port, _ = strconv.Atoi(hostPort)
+		}
+		targets = append(targets, target)
+	}
+	if shareSSH {
+		targets = append(targets, share.Target{Name: share.KindSSH, Kind: share.KindSSH})
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("nothing to share: name ports, forward some in devcontainer.json, or pass --ssh")
+	}
+
+	relay, token, err := shareRelaySettings()
+	if err != nil {
+		return err
+	}
+	if share.Insecure(relay) {
+		fmt.Printf("⚠️  %s is not https: shared traffic is not encrypted\n", relay)
+	}
+
+	opts := share.Options{
+		Relay:     relay,
+		Token:     token,
+		Project:   projectDir,
+		Container: summary.Container,
+		Backend:   pr.BackendCommand(),
+		Targets:   targets,
+		TTL:       shareExpires,
+	}
+	if sharePassword {
+		if opts.Password, err = readSharePassword("Password for visitors: "); err != nil {
+			return err
+		}
+		if opts.Password == "" {
+			return fmt.Errorf("empty password")
+		}
+	}
+
+	if shareForeground {
+		return serveShare(opts, func(s *share.Session) {
+			printShareSession(s)
+			fmt.Println("   Sharing until it expires or Ctrl+C")
+		})
+	}
+	session, err := startShareInBackground(opts)
+	if err != nil {
+		return err
+	}
+	printShareSession(session)
+	fmt.Printf("   Stop with: cm share stop %s\n", shortShareID(session.ID))
+	return nil
+}
+
+// serveShare registers a share, records it and answers visitors until it
+// ends; started is called once the links are known
+func serveShare(opts share.Options, started func(*share.Session)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tunnel, err := share.Open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+	if err := tunnel.Session.Save(); err != nil {
+		return err
+	}
+	defer tunnel.Session.Remove()
+
+	started(tunnel.Session)
+	return tunnel.Serve(ctx)
+}
+
+// startShareInBackground runs 'cm share serve' detached and waits for it to
+// report the share's links. The options, including the password, go over
+// its stdin rather than the command line.
+func startShareInBackground(opts share.Options) (*share.Session, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := share.Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	logPath := filepath.Join(dir, "share.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	input, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	proc := exec.Command(exe, "share", "serve")
+	proc.Stdin = strings.NewReader(string(input))
+	proc.Stderr = logFile
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := proc.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start sharing: %w", err)
+	}
+
+	result := make(chan *share.Session, 1)
+	go func() {
+		var s share.Session
+		if line, err := bufio.NewReader(stdout).ReadBytes('\n'); err == nil && json.Unmarshal(line, &s) == nil {
+			result <- &s
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case s := <-result:
+		if s == nil {
+			_ = proc.Wait()
+			return nil, fmt.Errorf("sharing failed: %s", lastLogLine(logPath))
+		}
+		_ = proc.Process.Release()
+		return s, nil
+	case <-time.After(30 * time.Second):
+		_ = proc.Process.Kill()
+		return nil, fmt.Errorf("the relay did not answer; see %s", logPath)
+	}
+}
+
+// shareRelaySettings returns the relay to use and its credentials: the
+// --relay flag or share.relay with share.token, else CM cloud with the
+// 'cm cloud login' credentials
+func shareRelaySettings() (relay, token string, err error) {
+	cfg, err := userconfig.Load()
+	if err != nil {
+		cfg = &userconfig.UserConfig{}
+	}
+	relay = shareRelay
+	if relay == "" {
+		relay = cfg.Share.Relay
+	}
+	if relay != "" {
+		return strings.TrimRight(relay, "/"), cfg.Share.Token, nil
+	}
+
+	creds, err := loadCloudCredentials()
+	if err != nil {
+		return "", "", err
+	}
+	token = creds.Token
+	if token == "" {
+		token = creds.APIKey
+	}
+	if token == "" {
+		return "", "", fmt.Errorf("sharing through CM cloud needs 'cm cloud login'; or run your own relay ('cm share relay') and pass --relay")
+	}
+	return defaultShareRelay, token, nil
+}
+
+// printShareSession prints a share and its links
+func printShareSession(s *share.Session) {
+	lock := ""
+	if s.Protected {
+		lock = " 🔒"
+	}
+	fmt.Printf("🔗 Share %s of %s, expires in %s%s\n",
+		shortShareID(s.ID), filepath.Base(s.Project), time.Until(s.ExpiresAt).Round(time.Minute), lock)
+
+	names := make([]string, 0, len(s.Links))
+	kinds := map[string]string{}
+	for _, t := range s.Targets {
+		names = append(names, t.Name)
+		kinds[t.Name] = t.Kind
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		link := s.Links[name]
+		switch kinds[name] {
+		case share.KindSSH:
+			fmt.Printf("   ssh:   ssh -o ProxyCommand=\"cm share connect %s\" <user>@share\n", link)
+		case share.KindTCP:
+			fmt.Printf("   %-5s  cm share connect %s --listen 127.0.0.1:%s\n", name, link, name)
+		default:
+			fmt.Printf("   %-5s  %s\n", name, link)
+		}
+	}
+}
+
+// shortShareID abbreviates a share ID for display
+func shortShareID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// readSharePassword prompts for a password without echoing it
+func readSharePassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(password), err
+}
+
+// lastLogLine returns the last line of a log file, to explain a failure
+func lastLogLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "see " + path
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return strings.TrimPrefix(lines[len(lines)-1], "Error: ")
+}
+
+// stdio is the process's stdin and stdout as one stream
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdio) Close() error                { return os.Stdout.Close() }
+
+// completeShares completes the IDs of running shares
+func completeShares(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	sessions, _ := share.List()
+	var ids []string
+	for _, s := range sessions {
+		ids = append(ids, shortShareID(s.ID)+"\t"+filepath.Base(s.Project))
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	shareStartCmd.Flags().BoolVar(&shareSSH, "ssh", false, "Share an SSH endpoint into the container (needs sshd in the container)")
+	shareStartCmd.Flags().DurationVar(&shareExpires, "expires", share.DefaultTTL, "How long the links work")
+	shareStartCmd.Flags().BoolVar(&sharePassword, "password", false, "Ask for a password visitors must give")
+	shareStartCmd.Flags().StringVar(&shareRelay, "relay", "", "Relay URL (default: share.relay, else CM cloud)")
+	shareStartCmd.Flags().BoolVar(&shareForeground, "foreground", false, "Share until Ctrl+C instead of in the background")
+	shareStopCmd.Flags().BoolVar(&shareStopAll, "all", false, "Stop the shares of every project")
+	shareListCmd.Flags().StringVar(&shareListFormat, "format", "", output.FlagUsage)
+	shareConnectCmd.Flags().StringVar(&shareListen, "listen", "", "Accept connections on this local address instead of using stdin/stdout")
+	shareRelayCmd.Flags().StringVar(&relayListen, "listen", ":8443", "Address to listen on")
+	shareRelayCmd.Flags().StringVar(&relayPublicURL, "public-url", "", "Base URL of the links, e.g. https://share.example.com (default: from requests)")
+	shareRelayCmd.Flags().StringVar(&relayToken, "token", "", "Token clients must present to share")
+	shareRelayCmd.Flags().StringVar(&relayCert, "tls-cert", "", "TLS certificate file")
+	shareRelayCmd.Flags().StringVar(&relayKey, "tls-key", "", "TLS key file")
+	shareRelayCmd.Flags().DurationVar(&relayMaxTTL, "max-ttl", 24*time.Hour, "Longest lifetime a share may ask for (0 = unlimited)")
+
+	shareCmd.AddCommand(shareStartCmd)
+	shareCmd.AddCommand(shareServeCmd)
+	shareCmd.AddCommand(shareListCmd)
+	shareCmd.AddCommand(shareStopCmd)
+	shareCmd.AddCommand(shareConnectCmd)
+	shareCmd.AddCommand(shareRelayCmd)
+}
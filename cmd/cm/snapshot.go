@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/UPwith-me/Container-Maker/pkg/output"
 	"github.com/UPwith-me/Container-Maker/pkg/runner"
 	"github.com/UPwith-me/Container-Maker/pkg/snapshot"
 	"github.com/spf13/cobra"
@@ -60,6 +62,11 @@ var snapshotListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available snapshots",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if err := output.Validate(format); err != nil {
+			return err
+		}
+
 		// Mock runner to get runtime? No, we need runtime.
 		// We can use a lightweight way to get runtime if possible, but loadConfig is fine.
 		cfg, projectDir, err := loadConfig()
@@ -83,16 +90,18 @@ var snapshotListCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Println("ID             Name                 Created              Description")
-		fmt.Println("────────────────────────────────────────────────────────────────────────────────")
-		for _, s := range snaps {
-			id := s.ImageID
-			if len(id) > 12 {
-				id = id[:12]
+		return output.Print(os.Stdout, format, snaps, func() error {
+			fmt.Println("ID             Name                 Created              Description")
+			fmt.Println("────────────────────────────────────────────────────────────────────────────────")
+			for _, s := range snaps {
+				id := s.ImageID
+				if len(id) > 12 {
+					id = id[:12]
+				}
+				fmt.Printf("%-14s %-20s %-20s %s\n", id, s.Name, s.CreatedAt.Format("2006-01-02 15:04"), s.Description)
 			}
-			fmt.Printf("%-14s %-20s %-20s %s\n", id, s.Name, s.CreatedAt.Format("2006-01-02 15:04"), s.Description)
-		}
-		return nil
+			return nil
+		})
 	},
 }
 
@@ -142,6 +151,7 @@ var snapshotRestoreCmd = &cobra.Command{
 func init() {
 	snapshotCreateCmd.Flags().StringP("description", "d", "", "Snapshot description")
 	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotListCmd.Flags().String("format", "", output.FlagUsage)
 	snapshotCmd.AddCommand(snapshotListCmd)
 	snapshotCmd.AddCommand(snapshotRestoreCmd)
 	rootCmd.AddCommand(snapshotCmd)
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sshStdio       bool
+	sshProject     string
+	sshConfigPrint bool
+)
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh [-- command]",
+	Short: "Connect to the dev container over SSH",
+	Long: `Connect to the persistent dev container with your SSH client.
+
+cm runs sshd inside the container (installing openssh-server on first use
+when the image lacks it) and authorizes the keys of your SSH agent, or your
+~/.ssh/*.pub keys. The connection is carried over the backend's exec
+stream, so no port is published on the host.
+
+Use 'cm ssh-config' to add a host for the project to ~/.ssh/config, so
+JetBrains Gateway, VS Code Remote-SSH, vim/scp/rsync and any other SSH
+tooling can connect with 'ssh cm-<project>'.
+
+Examples:
+  cm ssh
+  cm ssh -- make test`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sshStdio {
+			return serveSSHStdio()
+		}
+
+		cfg, projectDir, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		pr, err := runner.NewPersistentRunner(cfg, projectDir)
+		if err != nil {
+			return err
+		}
+		if _, err := exec.LookPath("ssh"); err != nil {
+			return fmt.Errorf("ssh client not found in PATH")
+		}
+
+		// Start the container and install sshd here rather than inside the
+		// ProxyCommand, where progress output would be hidden
+		containerID, err := pr.EnsureContainer(context.Background(), false)
+		if err != nil {
+			return err
+		}
+		if err := pr.SetupSSH(context.Background(), containerID); err != nil {
+			return err
+		}
+
+		proxy, err := sshProxyCommand(projectDir)
+		if err != nil {
+			return err
+		}
+		sshArgs := []string{
+			"-o", "ProxyCommand=" + proxy,
+			"-o", "StrictHostKeyChecking=no",
+			"-o", "UserKnownHostsFile=" + sshNullFile(),
+			"-o", "LogLevel=ERROR",
+			"-l", pr.SSHUser(),
+		}
+		if len(args) == 0 {
+			sshArgs = append(sshArgs, "-t")
+		}
+		sshArgs = append(sshArgs, sshHostAlias(pr))
+		sshArgs = append(sshArgs, args...)
+
+		ssh := exec.Command("ssh", sshArgs...)
+		ssh.Stdin = os.Stdin
+		ssh.Stdout = os.Stdout
+		ssh.Stderr = os.Stderr
+		if err := ssh.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				os.Exit(exitErr.ExitCode())
+			}
+			return err
+		}
+		return nil
+	},
+}
+
+var sshConfigCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Add an SSH host for the dev container to ~/.ssh/config",
+	Long: `Add a "Host cm-<project>" entry for the project's persistent container
+to ~/.ssh/config. The entry connects through 'cm ssh --stdio', which starts
+the container when needed, so editors using SSH remoting (JetBrains Gateway,
+VS Code Remote-SSH, vim with scp://) can open it like any other host.
+
+Running it again replaces the entry. --print shows it instead.
+
+Examples:
+  cm ssh-config && ssh cm-myapp
+  cm ssh-config --print >> ~/.ssh/config`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, projectDir, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		pr, err := runner.NewPersistentRunner(cfg, projectDir)
+		if err != nil {
+			return err
+		}
+		proxy, err := sshProxyCommand(projectDir)
+		if err != nil {
+			return err
+		}
+
+		alias := sshHostAlias(pr)
+		begin := "# Added by cm for " + projectDir
+		block := strings.Join([]string{
+			begin,
+			"Host " + alias,
+			"  User " + pr.SSHUser(),
+			"  ProxyCommand " + proxy,
+			"  StrictHostKeyChecking no",
+			"  UserKnownHostsFile " + sshNullFile(),
+			"  LogLevel ERROR",
+			sshConfigEnd,
+		}, "\n") + "\n"
+
+		if sshConfigPrint {
+			fmt.Print(block)
+			return nil
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(home, ".ssh", "config")
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(replaceSSHBlock(string(existing), begin, block)), 0600); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Added host %s to %s\n", alias, path)
+		fmt.Printf("   Connect with: ssh %s\n", alias)
+		return nil
+	},
+}
+
+// sshConfigEnd closes the blocks 'cm ssh-config' writes
+const sshConfigEnd = "# End cm"
+
+// serveSSHStdio is the ProxyCommand side of 'cm ssh': it speaks SSH over
+// stdin and stdout with sshd inside the project's container
+func serveSSHStdio() error {
+	if sshProject != "" {
+		if err := os.Chdir(sshProject); err != nil {
+			return err
+		}
+	}
+	cfg, projectDir, err := findDevConfig()
+	if err != nil {
+		return fmt.Errorf("no devcontainer.json found in %s", sshProject)
+	}
+	pr, err := runner.NewPersistentRunner(cfg, projectDir)
+	if err != nil {
+		return err
+	}
+	return pr.ServeSSH(context.Background())
+}
+
+// sshHostAlias names the SSH host of a project: its container name without
+// the -dev suffix
+func sshHostAlias(pr *runner.PersistentRunner) string {
+	return strings.TrimSuffix(pr.GetContainerName(), "-dev")
+}
+
+// sshProxyCommand returns the ProxyCommand that reaches the project's
+// container through this cm binary
+func sshProxyCommand(projectDir string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s ssh --stdio --project %s", sshQuote(exe), sshQuote(projectDir)), nil
+}
+
+// sshNullFile is the known hosts file cm hosts use: the container's host
+// keys are generated inside it and change on every rebuild
+func sshNullFile() string {
+	if runtime.GOOS == "windows" {
+		return "NUL"
+	}
+	return "/dev/null"
+}
+
+// sshQuote quotes a path containing spaces for an ssh_config value
+func sshQuote(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// replaceSSHBlock replaces the block starting with the begin line in an
+// ssh_config, or appends it
+func replaceSSHBlock(existing, begin, block string) string {
+	if start := strings.Index(existing, begin+"\n"); start >= 0 {
+		if end := strings.Index(existing[start:], sshConfigEnd+"\n"); end >= 0 {
+			end += start + len(sshConfigEnd) + 1
+			return existing[:start] + block + existing[end:]
+		}
+	}
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		existing += "\n"
+	}
+	if existing != "" {
+		existing += "\n"
+	}
+	return existing + block
+}
+
+func init() {
+	rootCmd.AddCommand(sshCmd)
+	rootCmd.AddCommand(sshConfigCmd)
+
+	sshCmd.Flags().BoolVar(&sshStdio, "stdio", false, "Speak SSH over stdin/stdout (used as ProxyCommand)")
+	sshCmd.Flags().StringVar(&sshProject, "project", "", "Project directory (with --stdio)")
+	_ = sshCmd.Flags().MarkHidden("stdio")
+	_ = sshCmd.Flags().MarkHidden("project")
+
+	sshConfigCmd.Flags().BoolVar(&sshConfigPrint, "print", false, "Print the host entry instead of writing ~/.ssh/config")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/monitor"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsNoStream  bool
+	statsHeadless  bool
+	statsIdlePause int
+	statsInterval  time.Duration
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show resource usage of cm containers and pause idle ones",
+	Long: `Show live CPU, memory, network and disk usage of the containers cm manages.
+
+With --idle-pause N (or 'cm config set stats.idle_pause_minutes N'),
+persistent containers that stay idle for N minutes are paused to free
+memory: their state is saved to a snapshot image and the container is
+removed, as with 'cm shell --pause'. The next 'cm exec' or 'cm shell' in
+the project resumes the container transparently.
+
+A container is idle while it uses under 2% CPU and 1 KB/s of network and
+has no exec session (such as an open shell) attached.
+
+EXAMPLES
+  cm stats                          # Live table
+  cm stats --no-stream              # Print once
+  cm stats --idle-pause 30          # Live table, pause after 30 idle minutes
+  cm stats --headless --idle-pause 30 &   # Auto-pause in the background`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsNoStream {
+			return monitor.PrintStats(context.Background(), os.Stdout)
+		}
+
+		idlePause := statsIdlePause
+		if !cmd.Flags().Changed("idle-pause") {
+			if cfg, err := userconfig.Load(); err == nil {
+				idlePause = cfg.Stats.IdlePauseMinutes
+			}
+		}
+		opts := monitor.StatsOptions{
+			Interval:  statsInterval,
+			IdlePause: time.Duration(idlePause) * time.Minute,
+			Pause:     pauseIdleContainer,
+		}
+
+		if statsHeadless {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			fmt.Printf("📈 Watching cm containers; pausing persistent ones idle for %s\n", opts.IdlePause)
+			return monitor.WatchIdle(ctx, opts, os.Stdout)
+		}
+		return monitor.RunStats(opts)
+	},
+}
+
+// pauseIdleContainer pauses a persistent container by running
+// 'cm shell --pause' in its project, so the snapshot and state file are
+// handled exactly as for a manual pause
+func pauseIdleContainer(ctx context.Context, s *monitor.StatsSample) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	pause := exec.CommandContext(ctx, self, "shell", "--pause")
+	pause.Dir = s.Project
+	out, err := pause.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if i := strings.LastIndex(msg, "\n"); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+func init() {
+	statsCmd.Flags().BoolVar(&statsNoStream, "no-stream", false, "Print one sample and exit")
+	statsCmd.Flags().BoolVar(&statsHeadless, "headless", false, "Only auto-pause idle containers, without the table")
+	statsCmd.Flags().IntVar(&statsIdlePause, "idle-pause", 0, "Pause persistent containers idle for this many minutes (0 = never)")
+	statsCmd.Flags().DurationVar(&statsInterval, "interval", 2*time.Second, "Time between samples")
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/UPwith-me/Container-Maker/pkg/tui"
+	"golang.org/x/term"
+)
+
+var templateOptions []string
+var templateAcceptDefaults bool
+
+// parseOptionFlags turns repeated --option key=value flags into a map
+func parseOptionFlags(flags []string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid option %q (expected key=value)", f)
+		}
+		options[strings.TrimSpace(key)] = value
+	}
+	return options, nil
+}
+
+// promptTemplateOptions asks for every option not already given on the command line.
+// Non-interactive sessions and --yes fall back to the template defaults.
+func promptTemplateOptions(title string, defs map[string]template.TemplateOption, given map[string]string) (map[string]string, error) {
+	if len(defs) == 0 || templateAcceptDefaults || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return given, nil
+	}
+
+	values, err := tui.RunOptionsPrompt(title, defs, given)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, fmt.Errorf("cancelled")
+	}
+	return values, nil
+}
+
+// applyOCITemplate pulls a Template artifact and writes its files into the project
+func applyOCITemplate(ctx context.Context, ref, targetDir string, options map[string]string) error {
+	fmt.Printf("📥 Pulling template %s...\n", ref)
+	tmpl, err := template.PullOCITemplate(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	meta := tmpl.Metadata
+	fmt.Printf("📋 Template: %s (%s v%s)\n", meta.Name, meta.ID, meta.Version)
+	if meta.Description != "" {
+		fmt.Printf("   %s\n", meta.Description)
+	}
+
+	options, err = promptTemplateOptions("Template options for "+meta.Name+":", meta.Options, options)
+	if err != nil {
+		return err
+	}
+	resolved, err := tmpl.ResolveOptions(options)
+	if err != nil {
+		return err
+	}
+
+	written, err := tmpl.Apply(targetDir, resolved)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	for _, f := range written {
+		fmt.Printf("   ✓ %s\n", f)
+	}
+	fmt.Println("✅ Template applied!")
+	fmt.Println()
+	fmt.Println("Run 'cm shell' to start developing.")
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/spf13/cobra"
+)
+
+var templateProposeDryRun bool
+var templateProposeYes bool
+
+var templateProposeCmd = &cobra.Command{
+	Use:   "propose",
+	Short: "Propose a devcontainer.json from the detected project setup",
+	Long: `Detect the project's languages, frameworks, package managers and GPU
+needs and propose a devcontainer.json for them:
+
+  image              the primary language's image, at the version the
+                     project pins (.nvmrc, go.mod, .python-version, ...)
+  features           the other detected languages, and Docker when the
+                     project has a Dockerfile or compose file
+  forwardPorts       the default ports of detected frameworks
+  postCreateCommand  dependency installation with the detected package
+                     manager (npm ci, pnpm, poetry, go mod download, ...)
+  runArgs            --gpus all for GPU dependencies
+
+The proposal is shown as a diff, with the reason for each setting, and
+written only after you approve it. An existing devcontainer.json keeps the
+settings the proposal does not cover, and its features; its comments are
+not preserved.
+
+Examples:
+  cm template propose            # Preview and write
+  cm template propose --dry-run  # Preview only
+  cm template propose -y         # Write without confirmation`,
+	Args: cobra.NoArgs,
+	RunE: runTemplatePropose,
+}
+
+func init() {
+	templateProposeCmd.Flags().BoolVar(&templateProposeDryRun, "dry-run", false, "Show the proposal without writing it")
+	templateProposeCmd.Flags().BoolVarP(&templateProposeYes, "yes", "y", false, "Write without asking for confirmation")
+	templateCmd.AddCommand(templateProposeCmd)
+}
+
+func runTemplatePropose(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	existing, err := detect.ReadDevcontainer(cwd)
+	if err != nil {
+		return err
+	}
+	proposal, err := detect.Propose(cwd)
+	if err != nil {
+		return err
+	}
+
+	if !proposal.Changes(existing) {
+		fmt.Println("✅ devcontainer.json already matches the proposal.")
+		return nil
+	}
+
+	if existing == nil {
+		fmt.Println("📋 Proposed .devcontainer/devcontainer.json:")
+	} else {
+		fmt.Println("📋 Proposed changes to .devcontainer/devcontainer.json:")
+	}
+	fmt.Println()
+	fmt.Print(proposal.Format(existing))
+	fmt.Println()
+
+	if templateProposeDryRun {
+		return nil
+	}
+	if !templateProposeYes {
+		fmt.Print("Write this devcontainer.json? [y/N] ")
+		var response string
+		_, _ = fmt.Scanln(&response)
+		if strings.ToLower(response) != "y" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if err := proposal.Write(cwd, existing); err != nil {
+		return err
+	}
+	fmt.Println("✅ Wrote .devcontainer/devcontainer.json")
+	return nil
+}
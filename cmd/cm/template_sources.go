@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/team"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/spf13/cobra"
+)
+
+var templateSourceName string
+var templateSourceRef string
+
+var templateSourceCmd = &cobra.Command{
+	Use:     "source",
+	Aliases: []string{"sources"},
+	Short:   "Manage git repositories that provide templates",
+	Long: `Share private templates across an organization from git repositories.
+
+Each top-level directory of a source repository containing a devcontainer.json
+becomes a template named <source>/<directory>, usable with 'cm template use'.
+Sources are cloned when added and refreshed with 'cm template source update';
+template lookups only read the local clones. Pin a source to a tag or branch
+with --ref to freeze its templates. Sources are the same repositories managed
+by 'cm team'.
+
+Examples:
+  cm template source add git@github.com:org/cm-templates.git
+  cm template source add https://github.com/org/cm-templates --ref v2.1.0
+  cm template source update
+  cm template use org/python-service`,
+}
+
+var templateSourceAddCmd = &cobra.Command{
+	Use:   "add <git-url>",
+	Short: "Add a template source",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTemplateSourceAdd,
+}
+
+var templateSourceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List template sources",
+	RunE:  runTemplateSourceList,
+}
+
+var templateSourceRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a template source and its cache",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return teamRemoveCmd.RunE(cmd, args)
+	},
+}
+
+var templateSourceUpdateCmd = &cobra.Command{
+	Use:   "update [name]",
+	Short: "Fetch the latest templates from sources",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return teamSyncCmd.RunE(cmd, args)
+	},
+}
+
+var templateSourcePinCmd = &cobra.Command{
+	Use:   "pin <name> <ref>",
+	Short: "Pin a source to a tag or branch",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, ref := args[0], args[1]
+		if err := team.PinVersion(name, ref); err != nil {
+			return err
+		}
+		// Shallow clones only contain the old ref, so fetch again
+		_ = team.ClearCache(name)
+		if err := syncTemplateSource(cmd.Context(), name); err != nil {
+			return err
+		}
+		fmt.Printf("📌 Pinned '%s' to %s\n", name, ref)
+		return nil
+	},
+}
+
+func init() {
+	templateSourceAddCmd.Flags().StringVar(&templateSourceName, "name", "", "Source name used as template prefix (default: org from the URL)")
+	templateSourceAddCmd.Flags().StringVar(&templateSourceRef, "ref", "", "Tag or branch to pin the source to")
+
+	templateSourceCmd.AddCommand(templateSourceAddCmd)
+	templateSourceCmd.AddCommand(templateSourceListCmd)
+	templateSourceCmd.AddCommand(templateSourceRemoveCmd)
+	templateSourceCmd.AddCommand(templateSourceUpdateCmd)
+	templateSourceCmd.AddCommand(templateSourcePinCmd)
+	templateCmd.AddCommand(templateSourceCmd)
+}
+
+func runTemplateSourceAdd(cmd *cobra.Command, args []string) error {
+	url := args[0]
+
+	cfg, err := userconfig.Load()
+	if err != nil {
+		cfg = &userconfig.UserConfig{}
+	}
+
+	name := templateSourceName
+	if name == "" {
+		name = team.RepoNameFromURL(url)
+	}
+	if strings.ContainsAny(name, "/\\ ") {
+		return fmt.Errorf("invalid source name %q", name)
+	}
+	for _, r := range cfg.Team.Repositories {
+		if r.Name == name {
+			return fmt.Errorf("source '%s' already exists, use --name to choose another", name)
+		}
+	}
+
+	repo := userconfig.TeamRepository{
+		Name:       name,
+		URL:        url,
+		Tag:        templateSourceRef,
+		AuthType:   team.DetectAuthType(url),
+		AutoUpdate: templateSourceRef == "",
+		CacheTTL:   24,
+	}
+	cfg.Team.Repositories = append(cfg.Team.Repositories, repo)
+	if err := userconfig.Save(cfg); err != nil {
+		return err
+	}
+
+	fmt.Printf("📥 Fetching %s...\n", url)
+	if err := syncTemplateSource(cmd.Context(), name); err != nil {
+		// Don't keep a source we could not fetch
+		cfg.Team.Repositories = cfg.Team.Repositories[:len(cfg.Team.Repositories)-1]
+		_ = userconfig.Save(cfg)
+		_ = team.ClearCache(name)
+		return err
+	}
+
+	var names []string
+	for key, t := range template.LoadSourceTemplates() {
+		if t.Source == name {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Printf("✅ Added source '%s' with %d template(s)\n", name, len(names))
+	for _, n := range names {
+		fmt.Printf("   • %s\n", n)
+	}
+	return nil
+}
+
+// syncTemplateSource fetches one source and records the sync in the config
+func syncTemplateSource(ctx context.Context, name string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Team.Repositories {
+		repo := &cfg.Team.Repositories[i]
+		if repo.Name != name {
+			continue
+		}
+		result := team.SyncRepository(ctx, repo)
+		if !result.Success {
+			return fmt.Errorf("failed to fetch source '%s': %s", name, result.Message)
+		}
+		repo.LastSyncTime = time.Now().Unix()
+		repo.LastCommit = result.NewCommit
+		return userconfig.Save(cfg)
+	}
+	return fmt.Errorf("source '%s' not found", name)
+}
+
+func runTemplateSourceList(cmd *cobra.Command, args []string) error {
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Team.Repositories) == 0 {
+		fmt.Println("No template sources configured.")
+		fmt.Println("💡 Add one with 'cm template source add <git-url>'")
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, t := range template.LoadSourceTemplates() {
+		counts[t.Source]++
+	}
+
+	fmt.Printf("%-12s %-10s %-10s %-9s %s\n", "NAME", "REF", "COMMIT", "TEMPLATES", "URL")
+	for _, r := range cfg.Team.Repositories {
+		ref := r.Tag
+		if ref == "" {
+			ref = r.Branch
+		}
+		if ref == "" {
+			ref = "(default)"
+		}
+		commit := r.LastCommit
+		if commit == "" {
+			commit = "-"
+		}
+		fmt.Printf("%-12s %-10s %-10s %-9d %s\n", r.Name, ref, commit, counts[r.Name], r.URL)
+	}
+	return nil
+}
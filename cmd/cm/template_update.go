@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/spf13/cobra"
+)
+
+var templateUpdateDryRun bool
+var templateUpdateYes bool
+
+var templateUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update devcontainer.json to the latest version of its template",
+	Long: `Compare the project's devcontainer.json with the latest version of the
+template it was created from and merge upstream changes.
+
+The merge is three-way: settings changed only by the template are updated,
+settings you edited locally are kept, and settings changed on both sides are
+reported as conflicts and left untouched. Only the updated settings are
+edited in the file; its comments and formatting are kept.
+
+Examples:
+  cm template update            # Preview and apply
+  cm template update --dry-run  # Preview only
+  cm template update -y         # Apply without confirmation`,
+	Args: cobra.NoArgs,
+	RunE: runTemplateUpdate,
+}
+
+func init() {
+	templateUpdateCmd.Flags().BoolVar(&templateUpdateDryRun, "dry-run", false, "Show the diff without changing files")
+	templateUpdateCmd.Flags().BoolVarP(&templateUpdateYes, "yes", "y", false, "Apply without asking for confirmation")
+	templateCmd.AddCommand(templateUpdateCmd)
+}
+
+func runTemplateUpdate(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	update, err := template.PlanTemplateUpdate(cwd)
+	if err != nil {
+		return err
+	}
+
+	from := update.Applied.Version
+	if from == "" {
+		from = "?"
+	}
+	fmt.Printf("📋 Template: %s (%s → %s)\n", update.Applied.Name, from, update.LatestVersion)
+
+	if update.UpToDate() {
+		fmt.Println("✅ devcontainer.json is up to date with its template.")
+		return nil
+	}
+
+	if !update.HasBase {
+		fmt.Printf("⚠️  No %s found; differing settings are treated as conflicts.\n", template.TemplateBaseFile)
+	}
+	fmt.Println()
+	fmt.Print(template.FormatTemplateUpdate(update))
+	fmt.Println()
+
+	conflicts := update.Conflicts()
+	applicable := len(update.Changes) - len(conflicts)
+	if templateUpdateDryRun {
+		fmt.Printf("%d change(s) can be applied, %d conflict(s)\n", applicable, len(conflicts))
+		return nil
+	}
+	if applicable == 0 {
+		fmt.Printf("Nothing to apply automatically; resolve %d conflict(s) by hand.\n", len(conflicts))
+		return nil
+	}
+
+	if !templateUpdateYes {
+		fmt.Printf("Apply %d change(s)? [y/N] ", applicable)
+		var response string
+		_, _ = fmt.Scanln(&response)
+		if strings.ToLower(response) != "y" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if err := update.Apply(cwd); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Applied %d change(s)\n", applicable)
+	if len(conflicts) > 0 {
+		fmt.Printf("⚠️  Kept local values for %d conflict(s); review them above.\n", len(conflicts))
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateVerifyAll   bool
+	templateVerifyGPU   bool
+	templateVerifyKeep  bool
+	templateVerifyJUnit string
+)
+
+var templateVerifyCmd = &cobra.Command{
+	Use:   "verify [template...]",
+	Short: "Build and smoke-test templates",
+	Long: `Check that templates still work: for each one, create a temporary project
+with the template applied, prepare its dev container (build or pull the
+image and install features), then run its postCreateCommand in a fresh
+container.
+
+Templates whose postCreateCommand needs project files get a minimal
+project: go.mod for "go mod download", package.json for "npm install",
+Cargo.toml for "cargo fetch", a .csproj for "dotnet restore" and
+pyproject.toml for "poetry install".
+
+Templates that need a GPU are skipped unless --gpu is given. A summary is
+printed at the end, --junit writes it as a JUnit XML report, and the exit
+status is 1 when a template fails, so the command can run as a scheduled
+CI job.
+
+EXAMPLES
+  cm template verify go-basic
+  cm template verify --all
+  cm template verify --all --gpu --junit templates.xml`,
+	RunE: runTemplateVerify,
+}
+
+func init() {
+	templateVerifyCmd.Flags().BoolVar(&templateVerifyAll, "all", false, "Verify all templates")
+	templateVerifyCmd.Flags().BoolVar(&templateVerifyGPU, "gpu", false, "Also verify templates that need a GPU")
+	templateVerifyCmd.Flags().BoolVar(&templateVerifyKeep, "keep", false, "Keep the temporary projects")
+	templateVerifyCmd.Flags().StringVar(&templateVerifyJUnit, "junit", "", "Write a JUnit XML report to this file")
+	templateCmd.AddCommand(templateVerifyCmd)
+}
+
+func runTemplateVerify(cmd *cobra.Command, args []string) error {
+	names := args
+	if templateVerifyAll {
+		if len(args) > 0 {
+			return fmt.Errorf("give template names or --all, not both")
+		}
+		for name := range template.GetAllTemplates() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no templates to verify: give their names or --all")
+	}
+
+	ctx := context.Background()
+	started := time.Now()
+	var steps []*ciStep
+	failed := 0
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\n── %s ──\n", name)
+		prepare, postCreate := verifyTemplate(ctx, name)
+		steps = append(steps, prepare, postCreate)
+		if (prepare.Err != nil && !prepare.Skipped) || (postCreate.Err != nil && !postCreate.Skipped) {
+			failed++
+		}
+	}
+
+	printTemplateVerifySummary(steps)
+	if templateVerifyJUnit != "" {
+		if err := writeCIJUnit(templateVerifyJUnit, "cm template verify", steps, started); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write JUnit report: %v\n", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d templates failed", failed, len(names))
+	}
+	return nil
+}
+
+// verifyTemplate applies a template to a temporary project and returns its
+// prepare and postCreate steps
+func verifyTemplate(ctx context.Context, name string) (prepare, postCreate *ciStep) {
+	prepare = &ciStep{Name: name + ": prepare"}
+	postCreate = &ciStep{Name: name + ": postCreate"}
+	skip := func(err error) (*ciStep, *ciStep) {
+		prepare.Skipped, prepare.Err = true, err
+		postCreate.Skipped, postCreate.Err = true, err
+		return prepare, postCreate
+	}
+	fail := func(err error) (*ciStep, *ciStep) {
+		prepare.Err = err
+		postCreate.Skipped = true
+		return prepare, postCreate
+	}
+
+	tmpl, ok := template.GetTemplate(name)
+	if !ok {
+		return fail(fmt.Errorf("template '%s' not found", name))
+	}
+	if tmpl.RequiresGPU() && !templateVerifyGPU {
+		return skip(fmt.Errorf("needs a GPU, verify it with --gpu"))
+	}
+
+	dir, err := os.MkdirTemp("", "cm-verify-"+strings.NewReplacer("/", "-", ":", "-").Replace(name)+"-")
+	if err != nil {
+		return fail(err)
+	}
+	if templateVerifyKeep {
+		fmt.Fprintf(os.Stderr, "📁 Project: %s\n", dir)
+	} else {
+		// Files created as root in the container may not be removable
+		defer func() { _ = os.RemoveAll(dir) }()
+	}
+	if err := template.ApplyTemplateWithOptions(name, dir, nil); err != nil {
+		return fail(err)
+	}
+	if _, err := tmpl.WriteSmokeProject(dir); err != nil {
+		return fail(err)
+	}
+
+	// The runner mounts the working directory as the workspace
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fail(err)
+	}
+	defer func() { _ = os.Chdir(cwd) }()
+
+	cfg, err := parseDevConfig(filepath.Join(".devcontainer", "devcontainer.json"))
+	if err != nil {
+		return fail(err)
+	}
+	if runner.IsComposeConfig(cfg) {
+		return skip(fmt.Errorf("docker compose templates aren't verified yet"))
+	}
+
+	// postCreateCommand runs as the container's command, so that its
+	// failure fails the template rather than only warning
+	command := []string{"true"}
+	switch c := cfg.PostCreateCommand.(type) {
+	case string:
+		if c != "" {
+			command = []string{"sh", "-c", c}
+		}
+	case []interface{}:
+		command = command[:0]
+		for _, arg := range c {
+			command = append(command, fmt.Sprint(arg))
+		}
+	}
+	cfg.PostCreateCommand = nil
+
+	r, err := runner.NewRunner(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	stdout, stderr, tail := ciCapture()
+	r.NoTTY, r.Stdout, r.Stderr = true, stdout, stderr
+	t := time.Now()
+	r.Image, err = r.ResolveImage(ctx)
+	prepare.Duration = time.Since(t)
+	if err != nil {
+		return fail(err)
+	}
+
+	t = time.Now()
+	err = r.Run(ctx, command)
+	postCreate.Duration, postCreate.Output = time.Since(t), tail.String()
+	switch {
+	case err != nil:
+		postCreate.Err = err
+	case r.ExitCode != 0:
+		postCreate.Err, postCreate.ExitCode = fmt.Errorf("exit status %d", r.ExitCode), r.ExitCode
+	}
+	return prepare, postCreate
+}
+
+// printTemplateVerifySummary prints the steps of each template to stderr
+func printTemplateVerifySummary(steps []*ciStep) {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "── cm template verify ──")
+	passed, failed, skipped := 0, 0, 0
+	for _, s := range steps {
+		switch {
+		case s.Skipped && s.Err != nil:
+			fmt.Fprintf(os.Stderr, "⏭️  %s: skipped, %v\n", s.Name, s.Err)
+			skipped++
+		case s.Skipped:
+			fmt.Fprintf(os.Stderr, "⏭️  %s: skipped\n", s.Name)
+			skipped++
+		case s.Err != nil:
+			fmt.Fprintf(os.Stderr, "❌ %s: %v (%s)\n", s.Name, s.Err, s.Duration.Round(100*time.Millisecond))
+			failed++
+		default:
+			fmt.Fprintf(os.Stderr, "✅ %s (%s)\n", s.Name, s.Duration.Round(100*time.Millisecond))
+			passed++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/workspace"
@@ -15,22 +18,33 @@ var (
 	upForce   bool
 	upProfile string
 	upDetach  bool
+	upTimeout int
 )
 
 var upCmd = &cobra.Command{
-	Use:   "up [services...]",
-	Short: "Start workspace services",
+	Use:               "up [services...]",
+	ValidArgsFunction: completeServices,
+	Short:             "Start workspace services",
 	Long: `Start all or specified services in the workspace.
 
 This command reads cm-workspace.yaml and starts services in dependency order.
 Dependencies are automatically started before their dependents.
 
+A service starts once its dependencies meet their depends_on condition:
+service_started, service_healthy or service_completed_successfully. Without
+a condition, dependencies with a healthcheck must become healthy first.
+
+As in compose, services with profiles only start when one of their profiles
+is activated with --profile, or when they are named. Variables of env_file
+files, relative to the workspace file, come before a service's environment.
+
 EXAMPLES
   cm up                     # Start all services
   cm up frontend backend    # Start specific services (+ dependencies)
   cm up --no-deps frontend  # Start without dependencies
-  cm up --profile dev       # Start services with 'dev' profile
+  cm up --profile dev       # Also start services with the 'dev' profile
   cm up --build             # Build images before starting
+  cm up --timeout 300       # Wait up to 5 minutes for each dependency
 
 WORKSPACE FILE
   Create a cm-workspace.yaml to define your services:
@@ -44,9 +58,13 @@ WORKSPACE FILE
     backend:
       template: python
       depends_on:
-        - database
+        database:
+          condition: service_healthy
+          timeout: 60s
     database:
-      image: postgres:15`,
+      image: postgres:15
+      healthcheck:
+        test: ["CMD", "pg_isready"]`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Find and load workspace config
 		ws, err := workspace.Load("")
@@ -80,7 +98,7 @@ WORKSPACE FILE
 			Force:    upForce,
 			Profile:  upProfile,
 			Detach:   upDetach,
-			Timeout:  120,
+			Timeout:  upTimeout,
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
@@ -97,8 +115,9 @@ var (
 )
 
 var downCmd = &cobra.Command{
-	Use:   "down [services...]",
-	Short: "Stop workspace services",
+	Use:               "down [services...]",
+	ValidArgsFunction: completeServices,
+	Short:             "Stop workspace services",
 	Long: `Stop all or specified services in the workspace.
 
 Services are stopped in reverse dependency order - dependents are stopped
@@ -138,9 +157,17 @@ EXAMPLES
 }
 
 var restartCmd = &cobra.Command{
-	Use:   "restart [services...]",
-	Short: "Restart workspace services",
-	Long:  `Restart all or specified services in the workspace.`,
+	Use:               "restart [services...]",
+	ValidArgsFunction: completeServices,
+	Short:             "Restart workspace services",
+	Long: `Restart all or specified services in the workspace.
+
+Services that depend on a restarted service are restarted after it, once
+its healthcheck passes. Its own dependencies keep running.
+
+EXAMPLES
+  cm restart                # Restart everything
+  cm restart backend        # Restart backend and its dependents`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ws, err := workspace.Load("")
 		if err != nil {
@@ -168,15 +195,17 @@ var (
 )
 
 var logsCmd = &cobra.Command{
-	Use:   "logs <service>",
-	Short: "View service logs",
-	Long: `View logs from a running service.
+	Use:               "logs [services...]",
+	ValidArgsFunction: completeServices,
+	Short:             "View service logs",
+	Long: `View logs from workspace services. With several services (or none,
+meaning all) each line is prefixed with its service name.
 
 EXAMPLES
+  cm logs                   # Recent logs of every service
   cm logs backend           # View recent logs
   cm logs backend -f        # Follow logs
   cm logs backend -n 200    # Last 200 lines`,
-	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ws, err := workspace.Load("")
 		if err != nil {
@@ -198,7 +227,7 @@ EXAMPLES
 			defer cancel()
 		}
 
-		return orch.Logs(ctx, args[0], logsFollow, logsTail)
+		return orch.StreamLogs(ctx, args, logsFollow, logsTail, os.Stdout)
 	},
 }
 
@@ -220,23 +249,44 @@ var psCmd = &cobra.Command{
 		}
 		defer orch.Close()
 
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := orch.Refresh(ctx); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return nil
+		}
 		state := orch.Status()
 
+		names := ws.ServiceNames()
+		sort.Strings(names)
+
 		fmt.Printf("Workspace: %s\n\n", ws.Name)
-		fmt.Printf("%-20s %-12s %-20s\n", "SERVICE", "STATUS", "CONTAINER")
-		fmt.Printf("%-20s %-12s %-20s\n", "-------", "------", "---------")
+		fmt.Printf("%-20s %-12s %-10s %-14s %-20s\n", "SERVICE", "STATUS", "HEALTH", "CONTAINER", "PORTS")
+		fmt.Printf("%-20s %-12s %-10s %-14s %-20s\n", "-------", "------", "------", "---------", "-----")
 
-		for name := range ws.Services {
+		for _, name := range names {
 			svcState := state.Services[name]
-			status := "stopped"
+			status := "not created"
+			health := "-"
 			containerID := "-"
+			ports := "-"
 			if svcState != nil {
 				status = string(svcState.Status)
-				if svcState.ContainerID != "" {
+				if svcState.Health != "" {
+					health = svcState.Health
+				}
+				if len(svcState.ContainerID) >= 12 {
 					containerID = svcState.ContainerID[:12]
 				}
+				var mapped []string
+				for _, p := range svcState.Ports {
+					mapped = append(mapped, fmt.Sprintf("%d->%d/%s", p.Host, p.Container, p.Protocol))
+				}
+				if len(mapped) > 0 {
+					ports = strings.Join(mapped, ", ")
+				}
 			}
-			fmt.Printf("%-20s %-12s %-20s\n", name, status, containerID)
+			fmt.Printf("%-20s %-12s %-10s %-14s %-20s\n", name, status, health, containerID, ports)
 		}
 
 		return nil
@@ -248,8 +298,9 @@ func init() {
 	upCmd.Flags().BoolVar(&upBuild, "build", false, "Build images before starting")
 	upCmd.Flags().BoolVar(&upNoDeps, "no-deps", false, "Don't start dependencies")
 	upCmd.Flags().BoolVarP(&upForce, "force", "f", false, "Force recreate containers")
-	upCmd.Flags().StringVar(&upProfile, "profile", "", "Activate specific profile")
+	upCmd.Flags().StringVar(&upProfile, "profile", "", "Also start the services of this profile")
 	upCmd.Flags().BoolVarP(&upDetach, "detach", "d", true, "Run in background")
+	upCmd.Flags().IntVar(&upTimeout, "timeout", workspace.DefaultStartTimeout, "Seconds to wait for each dependency")
 
 	// down flags
 	downCmd.Flags().IntVar(&downTimeout, "timeout", 10, "Stop timeout in seconds")
@@ -265,4 +316,26 @@ func init() {
 	rootCmd.AddCommand(restartCmd)
 	rootCmd.AddCommand(logsCmd)
 	rootCmd.AddCommand(psCmd)
+
+	// The same commands under 'cm workspace'
+	for _, c := range []*cobra.Command{upCmd, downCmd, restartCmd, logsCmd, psCmd} {
+		workspaceCmd.AddCommand(workspaceAlias(c))
+	}
+
+	// 'cm mono up' takes the flags of 'cm up'
+	monoUpCmd.Flags().AddFlagSet(upCmd.Flags())
+}
+
+// workspaceAlias copies a top-level workspace command, sharing its flags
+func workspaceAlias(c *cobra.Command) *cobra.Command {
+	alias := &cobra.Command{
+		Use:               c.Use,
+		Short:             c.Short,
+		Long:              strings.ReplaceAll(c.Long, "  cm ", "  cm workspace "),
+		Args:              c.Args,
+		RunE:              c.RunE,
+		ValidArgsFunction: c.ValidArgsFunction,
+	}
+	alias.Flags().AddFlagSet(c.Flags())
+	return alias
 }
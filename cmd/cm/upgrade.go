@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeCheck   bool
+	upgradeChannel string
+	upgradeVersion string
+	upgradeForce   bool
+
+	upgradeInsecureSkipSignature bool
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:     "upgrade",
+	Aliases: []string{"self-update"},
+	Short:   "Upgrade cm to the latest release",
+	Long: `Download the latest release of cm from GitHub and replace the running binary.
+
+The stable channel takes full releases; the beta channel also takes
+prereleases. Choose one per run with --channel or for good with
+'cm config set update.channel beta'. The download is checked against the
+release's checksums.txt, whose signature by the release workflow is
+verified with cosign. Without cosign, or for a release without a
+signature, the upgrade is refused; --insecure-skip-signature installs it
+on the checksum alone. The new binary is written next to the old one,
+checked to run, and renamed over it.
+
+A cm installed by a package manager (Homebrew, Scoop, winget, apt, go
+install, ...) is left to that manager; --force replaces it anyway.
+
+--check only reports, exiting with status 1 when an upgrade is available,
+for scripts.
+
+EXAMPLES
+  cm upgrade
+  cm upgrade --check
+  cm upgrade --channel beta
+  cm upgrade --version v2.0.0   # Install a specific release, also to downgrade`,
+	Args: cobra.NoArgs,
+	RunE: runUpgrade,
+}
+
+func init() {
+	upgradeCmd.Flags().BoolVar(&upgradeCheck, "check", false, "Only check for an upgrade; exit 1 when one is available")
+	upgradeCmd.Flags().StringVar(&upgradeChannel, "channel", "", "Release channel: stable or beta (default: update.channel, else stable)")
+	upgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "Install this release instead of the latest")
+	upgradeCmd.Flags().BoolVar(&upgradeForce, "force", false, "Reinstall the same version, or replace a package manager's binary")
+	upgradeCmd.Flags().BoolVar(&upgradeInsecureSkipSignature, "insecure-skip-signature", false, "Install even if the release's signature can't be verified")
+	rootCmd.AddCommand(upgradeCmd)
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	if offline.Enabled() {
+		return fmt.Errorf("offline mode: 'cm upgrade' needs the network")
+	}
+	channel := upgradeChannel
+	if channel == "" {
+		channel = update.Channel()
+	}
+	if err := update.ValidChannel(channel); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	client := httpclient.New(httpclient.Options{Timeout: 10 * time.Minute})
+
+	var rel *update.Release
+	var err error
+	if upgradeVersion != "" {
+		rel, err = update.ReleaseByTag(ctx, client, upgradeVersion)
+	} else {
+		rel, err = update.LatestRelease(ctx, client, channel)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up releases: %w", err)
+	}
+
+	newer := update.CompareVersions(rel.TagName, Version) > 0
+	if upgradeCheck {
+		if !newer {
+			fmt.Printf("✅ cm %s is up to date (%s channel)\n", Version, channel)
+			return nil
+		}
+		fmt.Printf("📦 Upgrade available: %s -> %s (%s channel)\n", Version, rel.TagName, channel)
+		fmt.Printf("   %s\n", rel.HTMLURL)
+		os.Exit(1)
+	}
+	if !newer && upgradeVersion == "" && !upgradeForce {
+		fmt.Printf("✅ cm %s is up to date (%s channel)\n", Version, channel)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	install := update.DetectInstall(exe)
+	if install.Managed() && !upgradeForce {
+		return fmt.Errorf("cm was installed with %s; upgrade it with:\n   %s\n(or run 'cm upgrade --force' to replace %s anyway)", install.Manager, install.Command, install.Path)
+	}
+
+	name := update.CurrentAssetName()
+	asset, ok := rel.Asset(name)
+	if !ok {
+		return fmt.Errorf("release %s has no binary for this platform (%s)", rel.TagName, name)
+	}
+	sums, ok := rel.Asset(update.ChecksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s to verify the download; install it manually from %s", rel.TagName, update.ChecksumsAsset, rel.HTMLURL)
+	}
+
+	bundle, signed := rel.Asset(update.SignatureAsset)
+	if !signed && !upgradeInsecureSkipSignature {
+		return fmt.Errorf("release %s isn't signed, so it can't be verified; rerun with --insecure-skip-signature to install it on its checksum alone", rel.TagName)
+	}
+
+	fmt.Printf("📥 Downloading cm %s (%s)...\n", rel.TagName, name)
+	binary, err := update.Download(ctx, client, asset.URL)
+	if err != nil {
+		return err
+	}
+	checksums, err := update.Download(ctx, client, sums.URL)
+	if err != nil {
+		return err
+	}
+	if err := update.VerifyChecksum(binary, checksums, name); err != nil {
+		return err
+	}
+	fmt.Println("✅ Checksum verified")
+
+	if !signed {
+		fmt.Printf("⚠️  Release %s isn't signed; only its checksum was verified\n", rel.TagName)
+	} else {
+		data, err := update.Download(ctx, client, bundle.URL)
+		if err != nil {
+			return err
+		}
+		switch err := update.VerifySignature(ctx, checksums, data); {
+		case errors.Is(err, update.ErrNoCosign) && upgradeInsecureSkipSignature:
+			fmt.Printf("⚠️  Signature not verified: %v\n", err)
+		case errors.Is(err, update.ErrNoCosign):
+			return fmt.Errorf("%w\n(or rerun with --insecure-skip-signature to install on the checksum alone)", err)
+		case err != nil:
+			return err
+		default:
+			fmt.Println("✅ Signature verified")
+		}
+	}
+
+	if err := update.Replace(ctx, install.Path, binary); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w\n💡 Run 'sudo cm upgrade', or reinstall cm in a directory you own", err)
+		}
+		return err
+	}
+	fmt.Printf("🎉 Upgraded cm %s -> %s (%s)\n", Version, rel.TagName, install.Path)
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyStrict   bool
+	verifyConfig   string
+	verifyKey      string
+	verifyIdentity string
+	verifyIssuer   string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [image...]",
+	Short: "Verify image signatures with cosign",
+	Long: `Verify that images are signed by a trusted key or identity.
+
+Trust rules are configured per registry prefix with 'cm verify trust add'.
+Once a rule exists, cm verifies matching images with cosign before it pulls,
+builds from or runs them, and warns when verification fails. With --strict
+on run, prepare, shell and exec (or CM_VERIFY_STRICT=1, or
+'cm config set verify.strict true') unverified images are refused, including
+images no rule covers.
+
+Without arguments, the image of devcontainer.json, or the base images of its
+Dockerfile, are verified. The command exits 1 when any image fails.
+
+EXAMPLES
+  cm verify trust add ghcr.io/acme --key cosign.pub
+  cm verify trust add mcr.microsoft.com/devcontainers \
+      --identity '^https://github.com/devcontainers/' \
+      --issuer https://token.actions.githubusercontent.com
+  cm verify ghcr.io/acme/dev:1.4
+  cm shell --strict`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		images := args
+		if len(images) == 0 {
+			var err error
+			if images, err = projectBaseImages(); err != nil {
+				return err
+			}
+		}
+
+		v, err := verify.New()
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, image := range images {
+			res := v.Verify(context.Background(), image)
+			if res.Verified {
+				fmt.Printf("✅ %s", image)
+				if res.Digest != "" {
+					fmt.Printf(" (%s)", res.Digest)
+				}
+				fmt.Printf(" signed by %s\n", describeTrustRule(*res.Rule))
+				continue
+			}
+			failed++
+			fmt.Printf("❌ %s: %v\n", image, res.Err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+// projectBaseImages returns the images the current project runs or builds from
+func projectBaseImages() ([]string, error) {
+	configPath := verifyConfig
+	if configPath == "" {
+		if _, err := os.Stat(".devcontainer/devcontainer.json"); err == nil {
+			configPath = ".devcontainer/devcontainer.json"
+		} else if _, err := os.Stat("devcontainer.json"); err == nil {
+			configPath = "devcontainer.json"
+		} else {
+			return nil, fmt.Errorf("no image given and no devcontainer.json found")
+		}
+	}
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Image != "" {
+		return []string{cfg.Image}, nil
+	}
+	if cfg.Build != nil {
+		dockerfile := cfg.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.ConfigDir, dockerfile))
+		if err != nil {
+			return nil, err
+		}
+		if images := imports.BaseImages(data, cfg.Build.Args); len(images) > 0 {
+			return images, nil
+		}
+	}
+	return nil, fmt.Errorf("%s names no image to verify", configPath)
+}
+
+var verifyTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Manage the signers trusted per registry",
+}
+
+var verifyTrustListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List trust rules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := userconfig.Load()
+		if err != nil {
+			return err
+		}
+		if len(cfg.Verify.Trust) == 0 {
+			fmt.Println("No trust rules configured. Images are not verified.")
+			fmt.Println("💡 Add one with: cm verify trust add <registry> --key cosign.pub")
+			return nil
+		}
+		fmt.Printf("%-40s %s\n", "REGISTRY", "SIGNER")
+		fmt.Println(strings.Repeat("-", 80))
+		for _, rule := range cfg.Verify.Trust {
+			fmt.Printf("%-40s %s\n", rule.Registry, describeTrustRule(rule))
+		}
+		if verify.StrictEnabled() {
+			fmt.Println("\n🔒 Strict mode is on: images no rule covers are refused")
+		}
+		return nil
+	},
+}
+
+var verifyTrustAddCmd = &cobra.Command{
+	Use:   "add <registry-prefix>",
+	Short: "Trust a key or keyless identity for images under a registry prefix",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rule := userconfig.TrustRule{
+			Registry: strings.TrimSuffix(args[0], "/"),
+			Key:      verifyKey,
+			Identity: verifyIdentity,
+			Issuer:   verifyIssuer,
+		}
+		if rule.Key != "" && (rule.Identity != "" || rule.Issuer != "") {
+			return fmt.Errorf("use either --key or --identity with --issuer, not both")
+		}
+		if _, err := verify.CosignArgs(rule, ""); err != nil {
+			return fmt.Errorf("give --key, or --identity and --issuer for keyless signatures")
+		}
+
+		cfg, err := userconfig.Load()
+		if err != nil {
+			return err
+		}
+		replaced := false
+		for i, existing := range cfg.Verify.Trust {
+			if existing.Registry == rule.Registry {
+				cfg.Verify.Trust[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			cfg.Verify.Trust = append(cfg.Verify.Trust, rule)
+		}
+		if err := userconfig.Save(cfg); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Images under %s must be signed by %s\n", rule.Registry, describeTrustRule(rule))
+		return nil
+	},
+}
+
+var verifyTrustRemoveCmd = &cobra.Command{
+	Use:   "remove <registry-prefix>",
+	Short: "Remove a trust rule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := userconfig.Load()
+		if err != nil {
+			return err
+		}
+		registry := strings.TrimSuffix(args[0], "/")
+		rules := cfg.Verify.Trust[:0]
+		for _, rule := range cfg.Verify.Trust {
+			if rule.Registry != registry {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) == len(cfg.Verify.Trust) {
+			return fmt.Errorf("no trust rule for %s", registry)
+		}
+		cfg.Verify.Trust = rules
+		if err := userconfig.Save(cfg); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed the trust rule for %s\n", registry)
+		return nil
+	},
+}
+
+func describeTrustRule(rule userconfig.TrustRule) string {
+	if rule.Key != "" {
+		return "key " + rule.Key
+	}
+	return fmt.Sprintf("identity %s (issuer %s)", rule.Identity, rule.Issuer)
+}
+
+func init() {
+	verifyCmd.Flags().StringVarP(&verifyConfig, "config", "c", "", "Path to devcontainer.json")
+	verifyTrustAddCmd.Flags().StringVar(&verifyKey, "key", "", "cosign public key (file, URL or KMS URI)")
+	verifyTrustAddCmd.Flags().StringVar(&verifyIdentity, "identity", "", "Keyless: certificate identity regexp")
+	verifyTrustAddCmd.Flags().StringVar(&verifyIssuer, "issuer", "", "Keyless: OIDC issuer URL")
+
+	verifyTrustCmd.AddCommand(verifyTrustListCmd)
+	verifyTrustCmd.AddCommand(verifyTrustAddCmd)
+	verifyTrustCmd.AddCommand(verifyTrustRemoveCmd)
+	verifyCmd.AddCommand(verifyTrustCmd)
+	rootCmd.AddCommand(verifyCmd)
+
+	for _, cmd := range []*cobra.Command{runCmd, prepareCmd, shellCmd, execCmd} {
+		cmd.Flags().BoolVar(&verifyStrict, "strict", false, "Refuse images whose signatures cannot be verified")
+	}
+}
@@ -21,7 +21,12 @@ COMMANDS
   cm workspace init         Create a new cm-workspace.yaml
   cm workspace validate     Validate workspace configuration
   cm workspace graph        Show dependency graph
-  cm workspace services     List defined services`,
+  cm workspace services     List defined services
+  cm workspace up           Start services in dependency order
+  cm workspace down         Stop services
+  cm workspace restart      Restart services and their dependents
+  cm workspace ps           Show service status and health
+  cm workspace logs         Show service logs`,
 	Aliases: []string{"ws"},
 }
 
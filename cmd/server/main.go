@@ -1,29 +1,38 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/UPwith-me/Container-Maker/cloud/api"
+	"github.com/UPwith-me/Container-Maker/cloud/db"
 )
 
 func main() {
-	config := api.Config{
-		Port:      8080,
-		JWTSecret: getEnv("JWT_SECRET", "dev-secret-key-change-in-production"),
-
-		// OAuth (optional - will work without)
-		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", ""),
-		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-
-		// Database
-		DatabaseDriver: getEnv("DB_DRIVER", "sqlite"),
-		DatabaseURL:    getEnv("DATABASE_URL", ""),
+	config, err := api.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		// Stripe
-		StripeSecretKey: getEnv("STRIPE_SECRET_KEY", ""),
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" || len(os.Args) > 3 {
+			log.Fatalf("Unknown command %q; usage: %s [migrate [up|status]]", os.Args[1], os.Args[0])
+		}
+		switch {
+		case len(os.Args) == 3 && os.Args[2] == "status":
+			err = db.PrintMigrationStatus(config.DatabaseConfig(), os.Stdout)
+		case len(os.Args) == 2 || os.Args[2] == "up":
+			err = db.Migrate(config.DatabaseConfig(), log.Printf)
+		default:
+			log.Fatalf("usage: %s migrate [up|status]", os.Args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
 	server, err := api.NewServer(config)
@@ -33,16 +42,14 @@ func main() {
 
 	log.Printf("🚀 Cloud Control Plane API running on port %d", config.Port)
 	log.Printf("📦 Database: %s", config.DatabaseDriver)
+	if config.DevMode {
+		log.Printf("⚠️  Dev mode: unauthenticated requests act as a demo user")
+	}
 	log.Printf("🔗 Dashboard: http://localhost:%d", config.Port)
 
-	if err := server.Start(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Run(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
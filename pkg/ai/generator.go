@@ -4,63 +4,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"github.com/UPwith-me/Container-Maker/pkg/detect"
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
 )
 
+// aiHTTPClient allows for slow completions from hosted models
+var aiHTTPClient = httpclient.New(httpclient.Options{Timeout: 3 * time.Minute})
+
 // Generator generates devcontainer.json using AI
 type Generator struct {
-	apiKey  string
-	apiBase string
-	model   string
+	provider Provider
 }
 
-// NewGenerator creates a new AI generator
+// NewGenerator creates a new AI generator for the configured provider
 func NewGenerator() (*Generator, error) {
-	cfg, err := userconfig.Load()
+	p, err := NewProvider()
 	if err != nil {
 		return nil, err
 	}
+	return &Generator{provider: p}, nil
+}
 
-	if !cfg.AI.Enabled {
-		return nil, fmt.Errorf("AI is not enabled. Run 'cm config set ai.enabled true' first")
-	}
-
-	if cfg.AI.APIKey == "" {
-		return nil, fmt.Errorf("AI API key not set. Run 'cm config set ai.api_key <key>'")
-	}
-
-	apiBase := cfg.AI.APIBase
-	if apiBase == "" {
-		apiBase = "https://api.openai.com/v1"
-	}
-
-	model := cfg.AI.Model
-	if model == "" {
-		model = "gpt-4o-mini" // Default to cheaper model
-	}
+// NewGeneratorWithProvider creates a generator for a given provider
+func NewGeneratorWithProvider(p Provider) *Generator {
+	return &Generator{provider: Guard(p)}
+}
 
-	return &Generator{
-		apiKey:  cfg.AI.APIKey,
-		apiBase: apiBase,
-		model:   model,
-	}, nil
+// Provider describes the provider and model in use
+func (g *Generator) Provider() string {
+	return g.provider.Name()
 }
 
 // AnalyzeProject analyzes a project and generates devcontainer.json
 func (g *Generator) AnalyzeProject(ctx context.Context, projectDir string) (string, error) {
-	// Collect project info
-	projectInfo := g.collectProjectInfo(projectDir)
-
-	// Generate prompt
-	prompt := g.buildPrompt(projectInfo)
+	// Ground the request in what detection found
+	prompt, err := buildPrompt(projectDir)
+	if err != nil {
+		return "", err
+	}
 
 	// Call AI API
 	response, err := g.callAPI(ctx, prompt)
@@ -76,9 +64,7 @@ func (g *Generator) AnalyzeProject(ctx context.Context, projectDir string) (stri
 		// Try to fix common issues
 		response, err = g.attemptAutoFix(ctx, response, result)
 		if err != nil {
-			// Return the original with validation errors appended
-			return response + "\n\n// Validation errors: " +
-				FormatValidationResult(result), nil
+			return "", fmt.Errorf("generated config is invalid (%v)\n%s", err, FormatValidationResult(NewValidator(false).Validate(response)))
 		}
 	}
 
@@ -280,154 +266,104 @@ func (g *Generator) collectProjectInfo(projectDir string) *ProjectInfo {
 	return info
 }
 
-// buildPrompt creates the AI prompt
-func (g *Generator) buildPrompt(info *ProjectInfo) string {
-	var sb strings.Builder
-
-	sb.WriteString("Generate a devcontainer.json for the following project:\n\n")
-	sb.WriteString(fmt.Sprintf("Project Name: %s\n", info.Name))
-	sb.WriteString(fmt.Sprintf("Languages: %s\n", strings.Join(info.Languages, ", ")))
-
-	if len(info.Dependencies) > 0 {
-		sb.WriteString(fmt.Sprintf("Dependencies: %s\n", strings.Join(info.Dependencies[:min(15, len(info.Dependencies))], ", ")))
-	}
-
-	// Add environment-specific context
-	sb.WriteString("\nEnvironment Details:\n")
-	if info.HasCondaEnv {
-		sb.WriteString("- Has Conda environment.yml (use miniconda image)\n")
-	}
-	if info.HasPoetry {
-		sb.WriteString("- Has Poetry project (install poetry in postCreateCommand)\n")
-	}
-	if info.HasPipenv {
-		sb.WriteString("- Has Pipenv project (install pipenv in postCreateCommand)\n")
-	}
-	if info.HasCMake {
-		sb.WriteString("- Has CMake project (use C++ devcontainer with cmake)\n")
-	}
-	if info.HasConan {
-		sb.WriteString("- Has Conan package manager (install conan)\n")
-	}
-	if info.HasVcpkg {
-		sb.WriteString("- Has Vcpkg manifest (add vcpkg feature)\n")
-	}
-	if info.HasMaven {
-		sb.WriteString("- Has Maven project (use Java devcontainer with Maven)\n")
-	}
-	if info.HasGradle {
-		sb.WriteString("- Has Gradle project (use Java devcontainer with Gradle)\n")
-	}
-	if info.HasDotnet {
-		sb.WriteString("- Has .NET project (use dotnet devcontainer)\n")
+// buildPrompt creates the AI prompt from the detector's report on the
+// project, its template recommendations and the configuration detection
+// alone proposes
+func buildPrompt(projectDir string) (string, error) {
+	detector := detect.NewDetector(projectDir)
+	info, err := detector.Detect()
+	if err != nil {
+		return "", err
 	}
-	if info.HasCargo {
-		sb.WriteString("- Has Rust/Cargo project\n")
+	report := *info
+	report.RootDir = "" // Keep local paths out of the request
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
 	}
-	if info.HasDockerfile {
-		sb.WriteString("- Has Dockerfile (consider using build context)\n")
+
+	var sb strings.Builder
+	sb.WriteString("Generate a devcontainer.json for the project described by this detection report:\n\n")
+	sb.WriteString("```json\n")
+	sb.Write(reportJSON)
+	sb.WriteString("\n```\n")
+
+	var templates []string
+	for _, rec := range detector.RecommendTemplates() {
+		templates = append(templates, fmt.Sprintf("%s (%s confidence)", rec.Template, rec.Confidence))
 	}
-	if info.HasMakefile {
-		sb.WriteString("- Has Makefile\n")
+	if len(templates) > 0 {
+		sb.WriteString(fmt.Sprintf("\nRecommended templates: %s\n", strings.Join(templates, ", ")))
 	}
 
-	sb.WriteString(fmt.Sprintf("\nFiles: %s\n", strings.Join(info.Files[:min(20, len(info.Files))], ", ")))
+	if proposal, err := detect.Propose(projectDir); err == nil {
+		if baseline, err := json.MarshalIndent(proposal.Config, "", "  "); err == nil {
+			sb.WriteString("\nConfiguration derived from detection alone, to start from and improve:\n\n```json\n")
+			sb.Write(baseline)
+			sb.WriteString("\n```\n")
+		}
+	}
 
 	sb.WriteString(`
 Generate a complete devcontainer.json with:
-1. Appropriate base image for the detected environment
-2. Necessary devcontainer features for the detected languages/tools
-3. postCreateCommand to install all dependencies
-4. Useful VS Code extensions for the languages
+1. A base image for the primary language at the detected version
+2. Devcontainer features, as full OCI references, for the other languages and tools
+3. forwardPorts for the detected frameworks
+4. postCreateCommand installing dependencies with the detected package managers
+5. Useful VS Code extensions under customizations.vscode.extensions
 
+Only use properties from the devcontainer.json specification.
 Return ONLY the JSON, no explanation.`)
 
-	return sb.String()
+	return sb.String(), nil
 }
 
-// callAPI calls the OpenAI-compatible API with retry logic
-func (g *Generator) callAPI(ctx context.Context, prompt string) (string, error) {
-	reqBody := map[string]interface{}{
-		"model": g.model,
-		"messages": []map[string]string{
-			{"role": "system", "content": "You are an expert DevOps engineer. Generate valid devcontainer.json configurations."},
-			{"role": "user", "content": prompt},
-		},
-		"temperature": 0.3,
-	}
+// systemPrompt frames every generation request
+const systemPrompt = "You are an expert DevOps engineer. Generate valid devcontainer.json configurations."
 
-	jsonBody, _ := json.Marshal(reqBody)
+// callAPI asks the configured provider, with retry logic
+func (g *Generator) callAPI(ctx context.Context, prompt string) (string, error) {
 	var lastErr error
 
 	for i := 0; i < 3; i++ {
 		if i > 0 {
 			time.Sleep(time.Duration(i) * time.Second)
-			fmt.Printf("⚠️  API request failed, retrying... (%d/3)\n", i+1)
+			fmt.Printf("⚠️  Unusable AI response, retrying... (%d/3)\n", i+1)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", g.apiBase+"/chat/completions", bytes.NewBuffer(jsonBody))
-		if err != nil {
-			return "", err
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+g.apiKey)
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("API request failed: %w", err)
-			continue
-		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
-
-		if resp.StatusCode != 200 {
-			lastErr = fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
-			// Don't retry on 4xx errors (client error), except 429 (Too Many Requests)
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
-				return "", lastErr
-			}
+		// Rate limits and server errors are retried by the HTTP client; this
+		// loop only asks again when the answer is unusable
+		completion, err := g.provider.Chat(ctx, []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		})
+		if errors.Is(err, errUnusableResponse) {
+			lastErr = err
 			continue
 		}
-
-		var result struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
+		if err != nil {
+			return "", err
 		}
 
-		if err := json.Unmarshal(body, &result); err != nil {
-			lastErr = fmt.Errorf("failed to parse response: %w", err)
-			continue
-		}
+		return stripCodeFence(completion.Content), nil
+	}
 
-		if len(result.Choices) == 0 {
-			lastErr = fmt.Errorf("no response from AI")
-			continue
-		}
+	return "", fmt.Errorf("max retries exceeded: %w", lastErr)
+}
 
-		// Extract JSON from response
-		content := result.Choices[0].Message.Content
+// stripCodeFence removes a markdown code block around the answer
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```json") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimSuffix(content, "```")
+		content = strings.TrimSpace(content)
+	} else if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(content, "```")
 		content = strings.TrimSpace(content)
-
-		// Remove markdown code blocks if present
-		if strings.HasPrefix(content, "```json") {
-			content = strings.TrimPrefix(content, "```json")
-			content = strings.TrimSuffix(content, "```")
-			content = strings.TrimSpace(content)
-		} else if strings.HasPrefix(content, "```") {
-			content = strings.TrimPrefix(content, "```")
-			content = strings.TrimSuffix(content, "```")
-			content = strings.TrimSpace(content)
-		}
-
-		return content, nil
 	}
-
-	return "", fmt.Errorf("max retries exceeded: %w", lastErr)
+	return content
 }
 
 // SaveConfig saves the generated config to disk
@@ -0,0 +1,510 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mb = 1024 * 1024
+
+// ImageLayer is one layer of an image's history
+type ImageLayer struct {
+	Size      int64
+	CreatedBy string
+}
+
+// ImageInput is what the image optimizer inspects: the resolved
+// devcontainer.json, its Dockerfile and the history of the built image
+type ImageInput struct {
+	Config     map[string]interface{}
+	Dockerfile string       // Path, empty for image-based configs
+	Layers     []ImageLayer // Empty when the image was not built yet
+}
+
+// FilePatch is a set of text replacements in one file
+type FilePatch struct {
+	File  string
+	Edits []TextEdit
+}
+
+// TextEdit replaces Before with After; an empty Before creates the file
+type TextEdit struct {
+	Before string
+	After  string
+}
+
+// Apply writes the patch, failing if the file changed since it was made
+func (p *FilePatch) Apply() error {
+	data, err := os.ReadFile(p.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(data)
+	for _, e := range p.Edits {
+		switch {
+		case e.Before == "" && content == "":
+			content = e.After
+		case strings.Contains(content, e.Before):
+			content = strings.Replace(content, e.Before, e.After, 1)
+		default:
+			return fmt.Errorf("%s changed since the patch was proposed", p.File)
+		}
+	}
+	return os.WriteFile(p.File, []byte(content), 0644)
+}
+
+// ImageHistory returns the layers of an image, newest first
+func ImageHistory(ctx context.Context, backend, image string) ([]ImageLayer, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, backend, "history", "--no-trunc", "--human=false",
+		"--format", "{{.Size}}\t{{.CreatedBy}}", image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", image, err)
+	}
+
+	var layers []ImageLayer
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		size, createdBy, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		layers = append(layers, ImageLayer{Size: n, CreatedBy: createdBy})
+	}
+	return layers, nil
+}
+
+// instruction is a Dockerfile instruction, with continued lines joined
+type instruction struct {
+	Raw     string // As written, for patches
+	Command string // Upper-cased keyword, e.g. "RUN"
+	Args    string // Rest of the instruction on one line
+}
+
+// parseDockerfile splits a Dockerfile into instructions
+func parseDockerfile(content string) []instruction {
+	var instructions []instruction
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		start := i
+		for i < len(lines)-1 && strings.HasSuffix(strings.TrimSpace(lines[i]), "\\") {
+			i++
+		}
+		raw := strings.Join(lines[start:i+1], "\n")
+		joined := strings.Join(strings.Fields(strings.ReplaceAll(raw, "\\\n", " ")), " ")
+		keyword, args, _ := strings.Cut(joined, " ")
+		instructions = append(instructions, instruction{Raw: raw, Command: strings.ToUpper(keyword), Args: args})
+	}
+	return instructions
+}
+
+// toolchainSizes estimates what a language toolchain adds to an image
+var toolchainSizes = map[string]int64{
+	"python": 150 * mb,
+	"node":   180 * mb,
+	"go":     500 * mb,
+	"java":   350 * mb,
+	"rust":   700 * mb,
+	"ruby":   120 * mb,
+	"php":    100 * mb,
+	"dotnet": 500 * mb,
+}
+
+// slimVariants are official images with a much smaller -slim variant, and
+// roughly how much smaller it is
+var slimVariants = map[string]int64{
+	"python": 850 * mb,
+	"node":   800 * mb,
+	"ruby":   650 * mb,
+}
+
+// imageLanguage returns the toolchain an image ships with
+func imageLanguage(image string) string {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name, _, _ = strings.Cut(name, ":")
+	name, _, _ = strings.Cut(name, "@")
+	switch name {
+	case "golang", "go":
+		return "go"
+	case "openjdk", "eclipse-temurin", "amazoncorretto", "java", "maven", "gradle":
+		return "java"
+	case "typescript-node", "javascript-node":
+		return "node"
+	case "aspnet", "sdk":
+		if strings.Contains(image, "dotnet") {
+			return "dotnet"
+		}
+	}
+	if _, ok := toolchainSizes[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// featureLanguage returns the toolchain a feature installs
+func featureLanguage(ref string) string {
+	name := ref
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name, _, _ = strings.Cut(name, ":")
+	if _, ok := toolchainSizes[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// cacheTargets are BuildKit cache mounts for package managers' downloads
+var cacheTargets = []struct {
+	pattern *regexp.Regexp
+	target  string
+}{
+	{regexp.MustCompile(`\bpip3? install\b`), "/root/.cache/pip"},
+	{regexp.MustCompile(`\bnpm (?:ci|install)\b`), "/root/.npm"},
+	{regexp.MustCompile(`\byarn(?: install)?\b`), "/usr/local/share/.cache/yarn"},
+	{regexp.MustCompile(`\bgo (?:mod download|build|install)\b`), "/root/go/pkg/mod"},
+	{regexp.MustCompile(`\bcargo (?:build|install|fetch)\b`), "/usr/local/cargo/registry"},
+	{regexp.MustCompile(`\bmvn\b`), "/root/.m2"},
+	{regexp.MustCompile(`\bgradle\b`), "/root/.gradle"},
+}
+
+// fromSource matches RUN commands that compile tools, whose toolchains and
+// sources a multi-stage build would leave behind
+var fromSource = regexp.MustCompile(`\./configure\b|\bmake install\b|\bcmake\b|\bcargo install\b|\bgo install\b|\bgit clone\b`)
+
+// AnalyzeImage returns suggestions to make the image smaller and faster to
+// build, ranked by impact and estimated savings
+func AnalyzeImage(in ImageInput) []OptimizationSuggestion {
+	var suggestions []OptimizationSuggestion
+
+	var instructions []instruction
+	if in.Dockerfile != "" {
+		if data, err := os.ReadFile(in.Dockerfile); err == nil {
+			instructions = parseDockerfile(string(data))
+		}
+	}
+
+	// Languages the image already has, and where they come from
+	languages := make(map[string]string)
+	baseImage, _ := in.Config["image"].(string)
+	stages := 0
+	var fromLine string
+	for _, inst := range instructions {
+		if inst.Command == "FROM" {
+			stages++
+			fromLine = inst.Raw
+			baseImage = fromImage(inst.Args)
+		}
+	}
+	if lang := imageLanguage(baseImage); lang != "" {
+		languages[lang] = "the base image " + baseImage
+	}
+
+	// apt caches left in layers
+	var aptEdits []TextEdit
+	for _, inst := range instructions {
+		if inst.Command != "RUN" || !strings.Contains(inst.Args, "apt-get install") || strings.Contains(inst.Args, "/var/lib/apt/lists") {
+			continue
+		}
+		after := inst.Raw
+		if !strings.Contains(after, "--no-install-recommends") {
+			after = strings.Replace(after, "apt-get install", "apt-get install --no-install-recommends", 1)
+		}
+		after += " \\\n    && rm -rf /var/lib/apt/lists/*"
+		aptEdits = append(aptEdits, TextEdit{Before: inst.Raw, After: after})
+	}
+	if len(aptEdits) > 0 {
+		savings := int64(len(aptEdits)) * 40 * mb
+		if size := layerSize(in.Layers, "apt-get install"); size > 0 {
+			// Recommended packages are often a third of what is installed
+			savings = size / 3
+		}
+		suggestions = append(suggestions, OptimizationSuggestion{
+			Title:       "Clean up apt caches",
+			Description: "Package lists stay in the layer unless removed in the same RUN; --no-install-recommends skips optional packages",
+			Impact:      "high",
+			Category:    "size",
+			Savings:     savings,
+			Patch:       &FilePatch{File: in.Dockerfile, Edits: aptEdits},
+		})
+	}
+
+	// Package manager downloads repeated on every build
+	var cacheEdits []TextEdit
+	for _, inst := range instructions {
+		if inst.Command != "RUN" || strings.Contains(inst.Args, "--mount=") || strings.Contains(inst.Args, "--no-cache-dir") {
+			continue
+		}
+		// Patches must not overlap; this one is offered again once the apt
+		// cleanup is applied
+		if patched(aptEdits, inst.Raw) {
+			continue
+		}
+		var mounts []string
+		for _, c := range cacheTargets {
+			if c.pattern.MatchString(inst.Args) {
+				mounts = append(mounts, "--mount=type=cache,target="+c.target)
+			}
+		}
+		if len(mounts) == 0 {
+			continue
+		}
+		// Right after the keyword, however it is indented or cased
+		i := len(inst.Raw) - len(strings.TrimLeft(inst.Raw, " \t")) + len("RUN")
+		after := inst.Raw[:i] + " " + strings.Join(mounts, " ") + inst.Raw[i:]
+		cacheEdits = append(cacheEdits, TextEdit{Before: inst.Raw, After: after})
+	}
+	if len(cacheEdits) > 0 {
+		suggestions = append(suggestions, OptimizationSuggestion{
+			Title:       "Add build cache mounts",
+			Description: "BuildKit cache mounts keep package downloads between builds, so changing a dependency does not download everything again",
+			Impact:      "high",
+			Category:    "build time",
+			Patch:       &FilePatch{File: in.Dockerfile, Edits: cacheEdits},
+		})
+	}
+
+	// A slimmer variant of the same official image
+	name, tag, _ := strings.Cut(strings.TrimPrefix(baseImage, "library/"), ":")
+	if savings := slimVariants[name]; savings > 0 && !strings.Contains(tag, "slim") && !strings.Contains(tag, "alpine") {
+		slim := name + ":" + tag + "-slim"
+		if tag == "" || tag == "latest" {
+			slim = name + ":slim"
+		}
+		s := OptimizationSuggestion{
+			Title:       "Use a slimmer base image",
+			Description: fmt.Sprintf("%s is the full image with compilers and many libraries; %s has the same runtime", baseImage, slim),
+			Impact:      "high",
+			Category:    "size",
+			Savings:     savings,
+		}
+		if fromLine != "" {
+			s.Patch = &FilePatch{File: in.Dockerfile, Edits: []TextEdit{{Before: fromLine, After: strings.Replace(fromLine, baseImage, slim, 1)}}}
+		} else {
+			s.Apply = func(c map[string]interface{}) { c["image"] = slim }
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	// Toolchains installed twice
+	if features, ok := in.Config["features"].(map[string]interface{}); ok {
+		refs := make([]string, 0, len(features))
+		for ref := range features {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		for _, ref := range refs {
+			lang := featureLanguage(ref)
+			if lang == "" {
+				continue
+			}
+			if source, ok := languages[lang]; ok {
+				ref := ref
+				suggestions = append(suggestions, OptimizationSuggestion{
+					Title:       "Remove duplicate " + lang + " toolchain",
+					Description: fmt.Sprintf("The feature %s installs %s, which %s already has", ref, lang, source),
+					Impact:      "medium",
+					Category:    "size",
+					Savings:     toolchainSizes[lang],
+					Apply: func(c map[string]interface{}) {
+						if f, ok := c["features"].(map[string]interface{}); ok {
+							delete(f, ref)
+						}
+					},
+				})
+				continue
+			}
+			languages[lang] = "the feature " + ref
+		}
+	}
+
+	// Tools compiled in the final image
+	if stages == 1 {
+		for _, inst := range instructions {
+			if inst.Command == "RUN" && fromSource.MatchString(inst.Args) {
+				suggestions = append(suggestions, OptimizationSuggestion{
+					Title:       "Use multi-stage build",
+					Description: "Tools are built from source in the final image; build them in a separate stage and COPY --from it, leaving sources and build dependencies behind",
+					Impact:      "medium",
+					Category:    "size",
+					Savings:     layerSize(in.Layers, strings.Fields(fromSource.FindString(inst.Args))[0]) / 2,
+				})
+				break
+			}
+		}
+	}
+
+	// The whole project sent as build context
+	if in.Dockerfile != "" {
+		contextDir := filepath.Dir(in.Dockerfile)
+		if build, ok := in.Config["build"].(map[string]interface{}); ok {
+			if dir, ok := build["context"].(string); ok && dir != "" {
+				contextDir = filepath.Join(filepath.Dir(in.Dockerfile), dir)
+			}
+		}
+		ignore := filepath.Join(contextDir, ".dockerignore")
+		copiesAll := false
+		for _, inst := range instructions {
+			if (inst.Command == "COPY" || inst.Command == "ADD") && strings.Contains(" "+inst.Args+" ", " . ") {
+				copiesAll = true
+			}
+		}
+		if _, err := os.Stat(ignore); os.IsNotExist(err) && copiesAll {
+			suggestions = append(suggestions, OptimizationSuggestion{
+				Title:       "Add a .dockerignore",
+				Description: "The build copies the whole context, including .git and dependency directories; ignoring them speeds up builds and avoids cache misses",
+				Impact:      "medium",
+				Category:    "build time",
+				Patch: &FilePatch{File: ignore, Edits: []TextEdit{{
+					After: ".git\nnode_modules\n__pycache__\n.venv\ntarget\ndist\nbuild\n.cm\n",
+				}}},
+			})
+		}
+	}
+
+	RankSuggestions(suggestions)
+	return suggestions
+}
+
+// patched reports whether an edit replaces text
+func patched(edits []TextEdit, text string) bool {
+	for _, e := range edits {
+		if e.Before == text {
+			return true
+		}
+	}
+	return false
+}
+
+// fromImage returns the image of a FROM instruction's arguments
+func fromImage(args string) string {
+	for _, field := range strings.Fields(args) {
+		if !strings.HasPrefix(field, "--") {
+			return field
+		}
+	}
+	return ""
+}
+
+// layerSize returns the size of the layers created by commands containing s
+func layerSize(layers []ImageLayer, s string) int64 {
+	var size int64
+	for _, l := range layers {
+		if strings.Contains(l.CreatedBy, s) {
+			size += l.Size
+		}
+	}
+	return size
+}
+
+// RankSuggestions orders suggestions by impact, then estimated savings
+func RankSuggestions(suggestions []OptimizationSuggestion) {
+	weight := map[string]int{"high": 3, "medium": 2, "low": 1}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if weight[a.Impact] != weight[b.Impact] {
+			return weight[a.Impact] > weight[b.Impact]
+		}
+		return a.Savings > b.Savings
+	})
+}
+
+// SuggestImageOptimizations asks an AI provider for further ways to shrink
+// the image or speed up its build, beyond those found
+func SuggestImageOptimizations(ctx context.Context, p Provider, in ImageInput, found []OptimizationSuggestion) ([]OptimizationSuggestion, error) {
+	var sb strings.Builder
+	if in.Dockerfile != "" {
+		if data, err := os.ReadFile(in.Dockerfile); err == nil {
+			sb.WriteString("Dockerfile:\n```\n" + string(data) + "\n```\n\n")
+		}
+	}
+	if config, err := json.MarshalIndent(in.Config, "", "  "); err == nil {
+		sb.WriteString("devcontainer.json:\n```json\n" + string(config) + "\n```\n\n")
+	}
+	if len(in.Layers) > 0 {
+		sb.WriteString("Largest image layers:\n")
+		layers := append([]ImageLayer(nil), in.Layers...)
+		sort.Slice(layers, func(i, j int) bool { return layers[i].Size > layers[j].Size })
+		for i, l := range layers {
+			if i == 10 || l.Size == 0 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- %d MB: %s\n", l.Size/mb, truncateCommand(l.CreatedBy, 200)))
+		}
+		sb.WriteString("\n")
+	}
+	if len(found) > 0 {
+		sb.WriteString("Already suggested:\n")
+		for _, s := range found {
+			sb.WriteString("- " + s.Title + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(`Suggest up to 3 other ways to make this dev container image smaller or faster to build.
+Respond with only this JSON:
+[{"title": "...", "description": "...", "impact": "high|medium|low", "category": "size|build time", "savingsMB": 0}]`)
+
+	completion, err := p.Chat(ctx, []ChatMessage{
+		{Role: "system", Content: "You optimize container images for development environments. Only suggest changes that keep the tools developers need."},
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := stripCodeFence(completion.Content)
+	var answers []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Impact      string `json:"impact"`
+		Category    string `json:"category"`
+		SavingsMB   int64  `json:"savingsMB"`
+	}
+	if err := json.Unmarshal([]byte(content), &answers); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnusableResponse, err)
+	}
+
+	var suggestions []OptimizationSuggestion
+	for _, a := range answers {
+		if a.Title == "" {
+			continue
+		}
+		switch a.Impact {
+		case "high", "medium", "low":
+		default:
+			a.Impact = "low"
+		}
+		suggestions = append(suggestions, OptimizationSuggestion{
+			Title:       a.Title,
+			Description: a.Description,
+			Impact:      a.Impact,
+			Category:    a.Category + " (" + p.Name() + ")",
+			Savings:     a.SavingsMB * mb,
+		})
+	}
+	return suggestions, nil
+}
+
+func truncateCommand(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
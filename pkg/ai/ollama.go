@@ -10,8 +10,15 @@ import (
 	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
 )
 
+// ollamaHTTPClient talks to the local Ollama server: model pulls and
+// generation can take minutes, and a server that is down should be reported
+// at once rather than retried
+var ollamaHTTPClient = httpclient.New(httpclient.Options{Timeout: -1, Retries: -1})
+
 // LocalProvider represents a local AI model provider
 type LocalProvider interface {
 	IsAvailable() bool
@@ -21,23 +28,26 @@ type LocalProvider interface {
 
 // OllamaProvider provides access to Ollama local models
 type OllamaProvider struct {
-	endpoint string
-	model    string
+	endpoint   string
+	model      string
+	embedModel string
 }
 
 // NewOllamaProvider creates a new Ollama provider
 func NewOllamaProvider() *OllamaProvider {
 	return &OllamaProvider{
-		endpoint: "http://localhost:11434",
-		model:    "", // Will be auto-detected
+		endpoint:   "http://localhost:11434",
+		model:      "", // Will be auto-detected
+		embedModel: "nomic-embed-text",
 	}
 }
 
 // NewOllamaProviderWithModel creates a new Ollama provider with a specific model
 func NewOllamaProviderWithModel(model string) *OllamaProvider {
 	return &OllamaProvider{
-		endpoint: "http://localhost:11434",
-		model:    model,
+		endpoint:   "http://localhost:11434",
+		model:      model,
+		embedModel: "nomic-embed-text",
 	}
 }
 
@@ -51,7 +61,7 @@ func (o *OllamaProvider) IsAvailable() bool {
 		return false
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ollamaHTTPClient.Do(req)
 	if err != nil {
 		return false
 	}
@@ -60,6 +70,11 @@ func (o *OllamaProvider) IsAvailable() bool {
 	return resp.StatusCode == 200
 }
 
+// Name identifies the provider and model
+func (o *OllamaProvider) Name() string {
+	return fmt.Sprintf("ollama (%s)", o.GetModel())
+}
+
 // GetModel returns the current model name
 func (o *OllamaProvider) GetModel() string {
 	if o.model != "" {
@@ -115,7 +130,7 @@ func (o *OllamaProvider) ListModels() ([]string, error) {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ollamaHTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +179,7 @@ func (o *OllamaProvider) Generate(ctx context.Context, prompt string) (string, e
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ollamaHTTPClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("ollama request failed: %w", err)
 	}
@@ -191,7 +206,7 @@ func (o *OllamaProvider) Generate(ctx context.Context, prompt string) (string, e
 }
 
 // Chat sends a chat-style message to Ollama
-func (o *OllamaProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+func (o *OllamaProvider) Chat(ctx context.Context, messages []ChatMessage) (*Completion, error) {
 	model := o.GetModel()
 
 	var ollamaMessages []map[string]string
@@ -213,41 +228,95 @@ func (o *OllamaProvider) Chat(ctx context.Context, messages []ChatMessage) (stri
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", o.endpoint+"/api/chat", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ollamaHTTPClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("ollama chat failed: %w", err)
+		return nil, fmt.Errorf("ollama chat failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("ollama error %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("ollama error %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, fmt.Errorf("%w: %v", errUnusableResponse, err)
+	}
+	if strings.TrimSpace(result.Message.Content) == "" {
+		return nil, fmt.Errorf("%w: empty response", errUnusableResponse)
 	}
 
-	return result.Message.Content, nil
+	return &Completion{
+		Content: result.Message.Content,
+		Usage:   Usage{PromptTokens: result.PromptEvalCount, CompletionTokens: result.EvalCount},
+	}, nil
+}
+
+// Embed returns embeddings from Ollama's embedding model
+func (o *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	reqBody := map[string]interface{}{
+		"model": o.embedModel,
+		"input": texts,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.endpoint+"/api/embed", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ollamaHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama embed failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("ollama error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%w: %d embeddings for %d texts", errUnusableResponse, len(result.Embeddings), len(texts))
+	}
+
+	return result.Embeddings, nil
 }
 
 // ChatMessage represents a chat message
@@ -273,7 +342,7 @@ func (o *OllamaProvider) PullModel(ctx context.Context, modelName string) error
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ollamaHTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to pull model: %w", err)
 	}
@@ -327,7 +396,7 @@ const (
 // SmartGenerator uses the best available AI backend
 type SmartGenerator struct {
 	backend AIBackend
-	openai  *Generator
+	openai  *Generator // The configured provider, whichever it is
 	ollama  *OllamaProvider
 }
 
@@ -375,44 +444,7 @@ func (sg *SmartGenerator) GenerateConfig(ctx context.Context, projectDir string)
 
 // generateWithOllama uses Ollama for config generation
 func (sg *SmartGenerator) generateWithOllama(ctx context.Context, projectDir string) (string, error) {
-	// Collect project info (reusing generator logic)
-	gen := &Generator{}
-	info := gen.collectProjectInfo(projectDir)
-	prompt := gen.buildPrompt(info)
-
-	// Add system context
-	systemPrompt := `You are an expert DevOps engineer. Generate valid devcontainer.json configurations.
-Return ONLY valid JSON, no explanation or markdown code blocks.`
-
-	messages := []ChatMessage{
-		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: prompt},
-	}
-
-	response, err := sg.ollama.Chat(ctx, messages)
-	if err != nil {
-		return "", err
-	}
-
-	// Clean up response
-	response = strings.TrimSpace(response)
-	if strings.HasPrefix(response, "```json") {
-		response = strings.TrimPrefix(response, "```json")
-		response = strings.TrimSuffix(response, "```")
-		response = strings.TrimSpace(response)
-	} else if strings.HasPrefix(response, "```") {
-		response = strings.TrimPrefix(response, "```")
-		response = strings.TrimSuffix(response, "```")
-		response = strings.TrimSpace(response)
-	}
-
-	// Validate JSON
-	var js json.RawMessage
-	if err := json.Unmarshal([]byte(response), &js); err != nil {
-		return "", fmt.Errorf("invalid JSON from Ollama: %w", err)
-	}
-
-	return response, nil
+	return NewGeneratorWithProvider(sg.ollama).AnalyzeProject(ctx, projectDir)
 }
 
 // generateWithRules uses the rule engine without AI
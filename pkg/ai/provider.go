@@ -0,0 +1,362 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// Provider is an AI backend: a hosted API or a local model server
+type Provider interface {
+	// Name identifies the provider and model, e.g. "openai (gpt-4o-mini)"
+	Name() string
+	Chat(ctx context.Context, messages []ChatMessage) (*Completion, error)
+	// Embed returns one embedding vector per text
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// Completion is a chat answer and the tokens it took
+type Completion struct {
+	Content string
+	Usage   Usage
+}
+
+// Usage counts the tokens of a request, as reported by the provider
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// ErrEmbeddingsUnsupported is returned by providers without an embeddings API
+var ErrEmbeddingsUnsupported = errors.New("provider does not support embeddings")
+
+// errUnusableResponse marks answers worth asking for again
+var errUnusableResponse = errors.New("unusable AI response")
+
+// providerDefaults are the endpoint and models used when none is configured
+var providerDefaults = map[string]struct{ apiBase, model, embedModel string }{
+	"openai":    {"https://api.openai.com/v1", "gpt-4o-mini", "text-embedding-3-small"}, // Default to cheaper model
+	"anthropic": {"https://api.anthropic.com/v1", "claude-3-5-haiku-latest", ""},
+	"azure":     {"", "", ""},                                       // Resource endpoint and deployment names are required
+	"ollama":    {"http://localhost:11434", "", "nomic-embed-text"}, // Best local model is picked
+	"llamacpp":  {"http://localhost:8080/v1", "", ""},               // Serves the model it was started with
+}
+
+// Providers lists the values 'cm config set ai.provider' accepts
+func Providers() []string {
+	return []string{"openai", "anthropic", "azure", "ollama", "llamacpp"}
+}
+
+// NewProvider creates the provider selected with 'cm config set ai.provider'.
+// Secrets are redacted from everything it sends, and its token usage is
+// recorded.
+func NewProvider() (Provider, error) {
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.AI.Enabled {
+		return nil, fmt.Errorf("AI is not enabled. Run 'cm config set ai.enabled true' first")
+	}
+
+	p, err := providerFor(cfg.AI)
+	if err != nil {
+		return nil, err
+	}
+	return Guard(p), nil
+}
+
+// providerFor builds a provider from the AI settings
+func providerFor(cfg userconfig.AIConfig) (Provider, error) {
+	name := cfg.Provider
+	if name == "" {
+		name = "openai"
+	}
+	defaults, ok := providerDefaults[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider %q. Run 'cm config set ai.provider %s'", name, strings.Join(Providers(), "|"))
+	}
+
+	apiBase := strings.TrimSuffix(cfg.APIBase, "/")
+	if apiBase == "" {
+		apiBase = defaults.apiBase
+	}
+	model := cfg.Model
+	if model == "" {
+		model = defaults.model
+	}
+	embedModel := cfg.EmbedModel
+	if embedModel == "" {
+		embedModel = defaults.embedModel
+	}
+
+	switch name {
+	case "openai", "anthropic", "azure":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("AI API key not set. Run 'cm config set ai.api_key <key>'")
+		}
+	}
+
+	switch name {
+	case "anthropic":
+		return &anthropicProvider{apiBase: apiBase, apiKey: cfg.APIKey, model: model}, nil
+	case "azure":
+		if apiBase == "" || model == "" {
+			return nil, fmt.Errorf("azure needs the resource endpoint and deployment: 'cm config set ai.api_base https://<resource>.openai.azure.com' and 'cm config set ai.model <deployment>'")
+		}
+		version := cfg.APIVersion
+		if version == "" {
+			version = "2024-06-01"
+		}
+		return &openAIProvider{name: name, apiBase: apiBase, apiKey: cfg.APIKey, model: model, embedModel: embedModel, azureVersion: version}, nil
+	case "ollama":
+		return &OllamaProvider{endpoint: apiBase, model: model, embedModel: embedModel}, nil
+	default:
+		return &openAIProvider{name: name, apiBase: apiBase, apiKey: cfg.APIKey, model: model, embedModel: embedModel}, nil
+	}
+}
+
+// Guard wraps a provider so secrets are redacted from what it is sent and
+// its token usage is recorded
+func Guard(p Provider) Provider {
+	if _, ok := p.(guardedProvider); ok {
+		return p
+	}
+	return guardedProvider{p}
+}
+
+type guardedProvider struct {
+	Provider
+}
+
+func (g guardedProvider) Chat(ctx context.Context, messages []ChatMessage) (*Completion, error) {
+	redacted := make([]ChatMessage, len(messages))
+	for i, m := range messages {
+		m.Content = RedactSecrets(m.Content)
+		redacted[i] = m
+	}
+
+	completion, err := g.Provider.Chat(ctx, redacted)
+	if err != nil {
+		return nil, err
+	}
+	_ = RecordUsage(g.Name(), completion.Usage) // Accounting must not fail requests
+	return completion, nil
+}
+
+func (g guardedProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	redacted := make([]string, len(texts))
+	for i, t := range texts {
+		redacted[i] = RedactSecrets(t)
+	}
+	return g.Provider.Embed(ctx, redacted)
+}
+
+// openAIProvider talks to the OpenAI API and compatible servers: Azure
+// OpenAI, llama.cpp and most hosted gateways
+type openAIProvider struct {
+	name         string
+	apiBase      string
+	apiKey       string
+	model        string // Deployment name on Azure
+	embedModel   string
+	azureVersion string // Set for Azure OpenAI
+}
+
+func (p *openAIProvider) Name() string {
+	if p.model == "" {
+		return p.name
+	}
+	return fmt.Sprintf("%s (%s)", p.name, p.model)
+}
+
+// endpoint returns the URL of an operation for a model
+func (p *openAIProvider) endpoint(operation, model string) string {
+	if p.azureVersion != "" {
+		return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s",
+			p.apiBase, url.PathEscape(model), operation, url.QueryEscape(p.azureVersion))
+	}
+	return p.apiBase + "/" + operation
+}
+
+func (p *openAIProvider) headers() map[string]string {
+	switch {
+	case p.apiKey == "":
+		return nil
+	case p.azureVersion != "":
+		return map[string]string{"api-key": p.apiKey}
+	default:
+		return map[string]string{"Authorization": "Bearer " + p.apiKey}
+	}
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, messages []ChatMessage) (*Completion, error) {
+	reqBody := map[string]interface{}{
+		"messages":    messages,
+		"temperature": 0.3,
+	}
+	if p.model != "" && p.azureVersion == "" {
+		reqBody["model"] = p.model
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage Usage `json:"usage"`
+	}
+	if err := postJSON(ctx, p.endpoint("chat/completions", p.model), p.headers(), reqBody, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("%w: no choices", errUnusableResponse)
+	}
+	return &Completion{Content: result.Choices[0].Message.Content, Usage: result.Usage}, nil
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if p.embedModel == "" {
+		return nil, fmt.Errorf("%w: set an embeddings model with 'cm config set ai.embed_model <model>'", ErrEmbeddingsUnsupported)
+	}
+	reqBody := map[string]interface{}{"input": texts}
+	if p.azureVersion == "" {
+		reqBody["model"] = p.embedModel
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postJSON(ctx, p.endpoint("embeddings", p.embedModel), p.headers(), reqBody, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("%w: %d embeddings for %d texts", errUnusableResponse, len(result.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("%w: embedding index %d out of range", errUnusableResponse, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	apiBase string
+	apiKey  string
+	model   string
+}
+
+func (p *anthropicProvider) Name() string {
+	return fmt.Sprintf("anthropic (%s)", p.model)
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, messages []ChatMessage) (*Completion, error) {
+	// System prompts are a separate field rather than a message role
+	var system []string
+	var turns []ChatMessage
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+		} else {
+			turns = append(turns, m)
+		}
+	}
+
+	reqBody := map[string]interface{}{
+		"model":       p.model,
+		"max_tokens":  4096,
+		"messages":    turns,
+		"temperature": 0.3,
+	}
+	if len(system) > 0 {
+		reqBody["system"] = strings.Join(system, "\n\n")
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	if err := postJSON(ctx, p.apiBase+"/messages", headers, reqBody, &result); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("%w: no text content", errUnusableResponse)
+	}
+	return &Completion{
+		Content: text.String(),
+		Usage:   Usage{PromptTokens: result.Usage.InputTokens, CompletionTokens: result.Usage.OutputTokens},
+	}, nil
+}
+
+func (p *anthropicProvider) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	return nil, fmt.Errorf("%w: anthropic has no embeddings API", ErrEmbeddingsUnsupported)
+}
+
+// postJSON sends body to url and decodes the JSON response into result
+func postJSON(ctx context.Context, url string, headers map[string]string, body, result interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := aiHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(data))
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("%w: failed to parse response: %v", errUnusableResponse, err)
+	}
+	return nil
+}
@@ -322,8 +322,10 @@ type OptimizationSuggestion struct {
 	Title       string
 	Description string
 	Impact      string // "high", "medium", "low"
-	Category    string // "performance", "security", "productivity"
+	Category    string // "performance", "security", "productivity", "size", "build time"
 	Apply       func(config map[string]interface{})
+	Savings     int64      // Estimated bytes saved, 0 when unknown
+	Patch       *FilePatch // Change to a file other than devcontainer.json
 }
 
 // Optimizer analyzes and suggests improvements for configs
@@ -461,7 +463,24 @@ func FormatSuggestions(suggestions []OptimizationSuggestion) string {
 
 		sb.WriteString(fmt.Sprintf("%d. [%s %s] %s\n", i+1, impact, s.Impact, s.Title))
 		sb.WriteString(fmt.Sprintf("   %s\n", s.Description))
-		sb.WriteString(fmt.Sprintf("   Category: %s\n\n", s.Category))
+		if s.Savings > 0 {
+			sb.WriteString(fmt.Sprintf("   Saves: ~%d MB\n", s.Savings/mb))
+		}
+		sb.WriteString(fmt.Sprintf("   Category: %s\n", s.Category))
+		if s.Patch != nil {
+			sb.WriteString(fmt.Sprintf("   Patch: %s\n", s.Patch.File))
+			for _, e := range s.Patch.Edits {
+				if e.Before != "" {
+					for _, line := range strings.Split(e.Before, "\n") {
+						sb.WriteString("     - " + line + "\n")
+					}
+				}
+				for _, line := range strings.Split(strings.TrimSuffix(e.After, "\n"), "\n") {
+					sb.WriteString("     + " + line + "\n")
+				}
+			}
+		}
+		sb.WriteString("\n")
 	}
 
 	return sb.String()
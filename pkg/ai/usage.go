@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UsageRecord is the token usage of one provider and model
+type UsageRecord struct {
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	LastUsed         time.Time `json:"last_used"`
+}
+
+// usageMu serializes updates from concurrent requests in this process
+var usageMu sync.Mutex
+
+// usagePath returns the file token usage is accounted in
+func usagePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "ai_usage.json"), nil
+}
+
+// LoadUsage returns the recorded token usage, by provider and model
+func LoadUsage() (map[string]*UsageRecord, error) {
+	path, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*UsageRecord)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// RecordUsage adds a request's token usage to the provider's total
+func RecordUsage(provider string, u Usage) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	usage, err := LoadUsage()
+	if err != nil {
+		usage = make(map[string]*UsageRecord) // Start over from a corrupt file
+	}
+	record, ok := usage[provider]
+	if !ok {
+		record = &UsageRecord{}
+		usage[provider] = record
+	}
+	record.Requests++
+	record.PromptTokens += u.PromptTokens
+	record.CompletionTokens += u.CompletionTokens
+	record.LastUsed = time.Now()
+
+	return saveUsage(usage)
+}
+
+// ResetUsage clears the recorded token usage
+func ResetUsage() error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func saveUsage(usage map[string]*UsageRecord) error {
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
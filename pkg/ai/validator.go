@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -192,6 +193,33 @@ func (v *Validator) validateSchema(config map[string]interface{}) []ValidationEr
 		}
 	}
 
+	// Check properties against the devcontainer.json specification
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kinds, known := propertyKinds[key]
+		if !known {
+			errors = append(errors, ValidationError{
+				Type:     "schema",
+				Severity: "warning",
+				Field:    key,
+				Message:  fmt.Sprintf("Unknown property '%s'", key),
+			})
+			continue
+		}
+		if !matchesKind(config[key], kinds) {
+			errors = append(errors, ValidationError{
+				Type:     "schema",
+				Severity: "error",
+				Field:    key,
+				Message:  fmt.Sprintf("Property '%s' must be %s", key, strings.ReplaceAll(kinds, "|", " or ")),
+			})
+		}
+	}
+
 	// Validate features format
 	if features, ok := config["features"].(map[string]interface{}); ok {
 		for featureName := range features {
@@ -209,6 +237,75 @@ func (v *Validator) validateSchema(config map[string]interface{}) []ValidationEr
 	return errors
 }
 
+// propertyKinds are the JSON types of the devcontainer.json properties
+var propertyKinds = map[string]string{
+	"$schema":                     "string",
+	"name":                        "string",
+	"image":                       "string",
+	"build":                       "object",
+	"dockerFile":                  "string",
+	"context":                     "string",
+	"dockerComposeFile":           "string|array",
+	"service":                     "string",
+	"runServices":                 "array",
+	"workspaceFolder":             "string",
+	"workspaceMount":              "string",
+	"features":                    "object",
+	"overrideFeatureInstallOrder": "array",
+	"forwardPorts":                "array",
+	"portsAttributes":             "object",
+	"otherPortsAttributes":        "object",
+	"appPort":                     "number|string|array",
+	"runArgs":                     "array",
+	"mounts":                      "array",
+	"containerEnv":                "object",
+	"remoteEnv":                   "object",
+	"containerUser":               "string",
+	"remoteUser":                  "string",
+	"updateRemoteUserUID":         "boolean",
+	"userEnvProbe":                "string",
+	"overrideCommand":             "boolean",
+	"shutdownAction":              "string",
+	"init":                        "boolean",
+	"privileged":                  "boolean",
+	"capAdd":                      "array",
+	"securityOpt":                 "array",
+	"initializeCommand":           "string|array|object",
+	"onCreateCommand":             "string|array|object",
+	"updateContentCommand":        "string|array|object",
+	"postCreateCommand":           "string|array|object",
+	"postStartCommand":            "string|array|object",
+	"postAttachCommand":           "string|array|object",
+	"waitFor":                     "string",
+	"customizations":              "object",
+	"hostRequirements":            "object",
+	"extensions":                  "array",  // Deprecated, now customizations.vscode
+	"settings":                    "object", // Deprecated, now customizations.vscode
+}
+
+// matchesKind reports whether a decoded JSON value has one of kinds
+func matchesKind(value interface{}, kinds string) bool {
+	kind := ""
+	switch value.(type) {
+	case string:
+		kind = "string"
+	case float64:
+		kind = "number"
+	case bool:
+		kind = "boolean"
+	case []interface{}:
+		kind = "array"
+	case map[string]interface{}:
+		kind = "object"
+	}
+	for _, k := range strings.Split(kinds, "|") {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // checkSecurity checks for security issues
 func (v *Validator) checkSecurity(config map[string]interface{}) []ValidationError {
 	var errors []ValidationError
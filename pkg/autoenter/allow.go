@@ -0,0 +1,112 @@
+// Package autoenter implements the opt-in shell hook that runs commands in a
+// project's dev container after 'cd'-ing into it. Like direnv, a project is
+// only entered once it is allowed, and the permission is tied to the
+// contents of its devcontainer.json.
+package autoenter
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Decision is what the hook does in a project
+type Decision string
+
+const (
+	Allowed Decision = "allowed" // Wrap commands with cm exec
+	Denied  Decision = "denied"  // Never ask again
+	Unknown Decision = "new"     // Ask once
+)
+
+// Entry is the decision for one project
+type Entry struct {
+	Allowed    bool      `json:"allowed"`
+	ConfigHash string    `json:"configHash,omitempty"` // devcontainer.json the permission was given for
+	At         time.Time `json:"at"`
+}
+
+// Store is the allowlist, kept in ~/.cm/allowed.json
+type Store struct {
+	path     string
+	Projects map[string]*Entry `json:"projects"`
+}
+
+// DefaultPath returns the allowlist file
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "allowed.json"), nil
+}
+
+// Load reads the allowlist at path; a missing file is an empty list
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, Projects: make(map[string]*Entry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid allowlist %s: %w", path, err)
+	}
+	if s.Projects == nil {
+		s.Projects = make(map[string]*Entry)
+	}
+	return s, nil
+}
+
+// Save writes the allowlist
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// Check returns the decision for a project. A project allowed for another
+// version of its devcontainer.json must be allowed again.
+func (s *Store) Check(projectDir, configPath string) Decision {
+	e := s.Projects[projectDir]
+	if e == nil {
+		return Unknown
+	}
+	if !e.Allowed {
+		return Denied
+	}
+	if e.ConfigHash != fileHash(configPath) {
+		return Unknown
+	}
+	return Allowed
+}
+
+// Allow permits wrapping commands in a project for its current
+// devcontainer.json
+func (s *Store) Allow(projectDir, configPath string) {
+	s.Projects[projectDir] = &Entry{Allowed: true, ConfigHash: fileHash(configPath), At: time.Now()}
+}
+
+// Deny stops the hook from wrapping commands or asking in a project
+func (s *Store) Deny(projectDir string) {
+	s.Projects[projectDir] = &Entry{Allowed: false, At: time.Now()}
+}
+
+func fileHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,65 @@
+package autoenter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"image":"alpine"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	storePath := filepath.Join(dir, ".cm", "allowed.json")
+
+	s, err := Load(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Check(dir, configPath); got != Unknown {
+		t.Errorf("new project: got %s", got)
+	}
+
+	s.Allow(dir, configPath)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Load(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Check(dir, configPath); got != Allowed {
+		t.Errorf("allowed project: got %s", got)
+	}
+
+	// A changed devcontainer.json must be allowed again
+	if err := os.WriteFile(configPath, []byte(`{"image":"ubuntu"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Check(dir, configPath); got != Unknown {
+		t.Errorf("changed config: got %s", got)
+	}
+
+	s.Deny(dir)
+	if got := s.Check(dir, configPath); got != Denied {
+		t.Errorf("denied project: got %s", got)
+	}
+}
+
+func TestScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := Script(shell)
+		if err != nil {
+			t.Fatalf("Script(%s): %v", shell, err)
+		}
+		if !strings.Contains(script, "cm hook check") || !strings.Contains(script, "cm hook run ") {
+			t.Errorf("Script(%s) does not check and wrap", shell)
+		}
+	}
+	if _, err := Script("tcsh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,136 @@
+package autoenter
+
+import "fmt"
+
+// SkipEnvVar lists extra commands, separated by spaces, that the hook
+// always runs on the host
+const SkipEnvVar = "CM_AUTO_ENTER_SKIP"
+
+// Script returns the hook for a shell. On every directory change it asks
+// 'cm hook check' about the project, prompts once for new projects, and
+// while inside an allowed one rewrites each command line to
+// 'cm hook run <line>'. cm itself, cd, exit, shell builtins, functions and
+// aliases stay on the host.
+func Script(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return commonScript + bashScript, nil
+	case "zsh":
+		return commonScript + zshScript, nil
+	case "fish":
+		return fishScript, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q: use bash, zsh or fish", shell)
+}
+
+// commonScript is shared by bash and zsh
+const commonScript = `# Container-Maker auto-enter hook
+_cm_hook() {
+  local state dir reply
+  IFS=$'\t' read -r state dir < <(cm hook check 2>/dev/null)
+  if [[ "$state" == new ]]; then
+    printf 'cm: run commands in the dev container of %s? [y/N] ' "$dir"
+    read -r reply
+    if [[ "$reply" == [yY]* ]]; then
+      cm allow "$dir" >/dev/null && state=allowed
+    else
+      cm deny "$dir" >/dev/null
+    fi
+  fi
+  if [[ "$state" == allowed ]]; then
+    [[ "$CM_AUTO_PROJECT" != "$dir" ]] && echo "cm: commands run in the dev container of $dir ('cm deny' to stop)"
+    export CM_AUTO_PROJECT="$dir"
+  else
+    unset CM_AUTO_PROJECT
+  fi
+}
+
+_cm_host_command() {
+  case " cm cd exit $CM_AUTO_ENTER_SKIP " in
+    *" $1 "*) return 0 ;;
+  esac
+  return 1
+}
+`
+
+const bashScript = `
+_cm_hook_pwd=""
+_cm_prompt_hook() {
+  [[ "$PWD" == "$_cm_hook_pwd" ]] && return
+  _cm_hook_pwd="$PWD"
+  _cm_hook
+}
+
+_cm_rewrite_line() {
+  [[ -n "$CM_AUTO_PROJECT" && -n "${READLINE_LINE// /}" ]] || return
+  local word=${READLINE_LINE#"${READLINE_LINE%%[! ]*}"}
+  word=${word%% *}
+  _cm_host_command "$word" && return
+  case "$(type -t -- "$word")" in
+    builtin|keyword|function|alias) return ;;
+  esac
+  local quoted
+  printf -v quoted '%q' "$READLINE_LINE"
+  READLINE_LINE="cm hook run $quoted"
+  READLINE_POINT=${#READLINE_LINE}
+}
+
+bind -x '"\C-x\C-y": _cm_rewrite_line'
+bind '"\C-m": "\C-x\C-y\C-j"'
+PROMPT_COMMAND="_cm_prompt_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`
+
+const zshScript = `
+_cm_accept_line() {
+  if [[ -n "$CM_AUTO_PROJECT" && -n "${BUFFER// /}" ]]; then
+    local word=${${(z)BUFFER}[1]}
+    if ! _cm_host_command "$word" && [[ "$(whence -w -- "$word")" != *": "(builtin|reserved|function|alias) ]]; then
+      BUFFER="cm hook run ${(q)BUFFER}"
+    fi
+  fi
+  zle .accept-line
+}
+
+zle -N accept-line _cm_accept_line
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _cm_hook
+_cm_hook
+`
+
+const fishScript = `# Container-Maker auto-enter hook
+function __cm_hook --on-variable PWD
+  set -l out (cm hook check 2>/dev/null | string split -m1 \t)
+  set -l state $out[1]
+  set -l dir $out[2]
+  if test "$state" = new
+    read -l -P "cm: run commands in the dev container of $dir? [y/N] " reply
+    if string match -qi 'y*' -- $reply
+      cm allow $dir >/dev/null; and set state allowed
+    else
+      cm deny $dir >/dev/null
+    end
+  end
+  if test "$state" = allowed
+    test "$CM_AUTO_PROJECT" != "$dir"; and echo "cm: commands run in the dev container of $dir ('cm deny' to stop)"
+    set -gx CM_AUTO_PROJECT $dir
+  else
+    set -e CM_AUTO_PROJECT
+  end
+end
+
+function __cm_accept_line
+  set -l line (commandline)
+  if set -q CM_AUTO_PROJECT; and test -n (string trim -- "$line")
+    set -l word (string split -m1 ' ' -- (string trim -- "$line"))[1]
+    set -l skip cm cd exit (string split ' ' -- "$CM_AUTO_ENTER_SKIP")
+    if not contains -- $word $skip; and not builtin -q -- $word; and not functions -q -- $word
+      commandline -r "cm hook run "(string escape -- "$line")
+    end
+  end
+  commandline -f execute
+end
+
+bind \r __cm_accept_line
+bind \n __cm_accept_line
+__cm_hook
+`
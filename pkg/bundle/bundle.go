@@ -0,0 +1,453 @@
+// Package bundle packages the images, features and templates a project needs
+// into one archive, so cm can run offline on machines that cannot reach the
+// registries.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/runner"
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+)
+
+// Archive layout:
+//
+//	manifest.json
+//	images.tar             output of 'docker save'
+//	features/<cache-key>/  same layout as ~/.cm/features
+//	templates/<cache-key>/ same layout as ~/.cm/oci-templates
+const (
+	ManifestFile = "manifest.json"
+	ImagesFile   = "images.tar"
+	featuresDir  = "features"
+	templatesDir = "templates"
+
+	// FormatVersion is bumped when the archive layout changes
+	FormatVersion = 1
+)
+
+// Manifest describes the contents of a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Images    []string  `json:"images,omitempty"`
+	Features  []Entry   `json:"features,omitempty"`
+	Templates []Entry   `json:"templates,omitempty"`
+}
+
+// Entry maps a feature or template reference to its directory in the archive
+type Entry struct {
+	Ref  string `json:"ref"`
+	Path string `json:"path"`
+}
+
+// CreateOptions configures Create
+type CreateOptions struct {
+	ConfigFile string   // devcontainer.json to collect assets from, optional
+	Images     []string // extra images
+	Features   []string // extra features
+	Templates  []string // OCI templates
+	Backend    string   // docker or podman
+	Output     string   // archive path
+	Log        io.Writer
+}
+
+// Create collects the assets a project needs, downloading what is missing,
+// and writes them into a gzipped tar archive
+func Create(ctx context.Context, opts CreateOptions) (*Manifest, error) {
+	if opts.Backend == "" {
+		opts.Backend = "docker"
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+
+	images := append([]string{}, opts.Images...)
+	requested := make(map[string]interface{})
+	var configDir string
+	var installOrder []string
+	if opts.ConfigFile != "" {
+		cfg, err := config.ParseConfig(opts.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		configDir = cfg.ConfigDir
+		installOrder = cfg.OverrideFeatureInstallOrder
+		images = append(images, configImages(cfg)...)
+		for ref, options := range cfg.Features {
+			requested[ref] = options
+		}
+	}
+	for _, ref := range opts.Features {
+		if _, ok := requested[ref]; !ok {
+			requested[ref] = map[string]interface{}{}
+		}
+	}
+
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+	dirs := make(map[string]string) // archive path -> local directory
+
+	if len(requested) > 0 {
+		fmt.Fprintln(opts.Log, "🧩 Resolving features...")
+		plan, err := runner.ResolveFeatures(ctx, requested, installOrder, configDir)
+		if err != nil {
+			return nil, err
+		}
+		downloader := runner.NewOCIFeatureDownloader(opts.Backend)
+		for _, planned := range plan.Features {
+			switch {
+			case features.IsLocalFeature(planned.Ref):
+				continue // travels with the project
+			case features.IsTarballFeature(planned.Ref):
+				fmt.Fprintf(opts.Log, "⚠️  %s is a URL and cannot be bundled; vendor it as a local feature\n", planned.Ref)
+				continue
+			}
+			dir, err := downloader.DownloadFeature(ctx, planned.Ref)
+			if err != nil {
+				return nil, err
+			}
+			path := featuresDir + "/" + filepath.Base(dir)
+			dirs[path] = dir
+			manifest.Features = append(manifest.Features, Entry{Ref: planned.Ref, Path: path})
+			fmt.Fprintf(opts.Log, "   ✓ feature %s\n", planned.Ref)
+		}
+	}
+
+	for _, ref := range opts.Templates {
+		if !template.IsOCIRef(ref) {
+			return nil, fmt.Errorf("%s is not an OCI template reference (e.g. ghcr.io/devcontainers/templates/go)", ref)
+		}
+		tmpl, err := template.PullOCITemplate(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", ref, err)
+		}
+		path := templatesDir + "/" + filepath.Base(tmpl.Dir)
+		dirs[path] = tmpl.Dir
+		manifest.Templates = append(manifest.Templates, Entry{Ref: ref, Path: path})
+		fmt.Fprintf(opts.Log, "   ✓ template %s\n", ref)
+	}
+
+	manifest.Images = dedupe(images)
+	var imagesTar string
+	if len(manifest.Images) > 0 {
+		tmpDir, err := os.MkdirTemp("", "cm-bundle-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+		imagesTar = filepath.Join(tmpDir, ImagesFile)
+		if err := saveImages(ctx, opts.Backend, manifest.Images, imagesTar, opts.Log); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeArchive(opts.Output, manifest, dirs, imagesTar); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// configImages returns the image a devcontainer.json runs, or the base images
+// of the Dockerfile it builds
+func configImages(cfg *config.DevContainerConfig) []string {
+	if cfg.Image != "" {
+		return []string{cfg.Image}
+	}
+	if cfg.Build == nil {
+		return nil
+	}
+	dockerfile := cfg.Build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(cfg.ConfigDir, dockerfile)
+	}
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return nil
+	}
+	return imports.BaseImages(data, cfg.Build.Args)
+}
+
+// saveImages pulls the images that are not available locally and saves them all into one tar
+func saveImages(ctx context.Context, backend string, images []string, dest string, log io.Writer) error {
+	for _, img := range images {
+		if exec.CommandContext(ctx, backend, "image", "inspect", img).Run() == nil {
+			continue
+		}
+		fmt.Fprintf(log, "📥 Pulling %s...\n", img)
+		pull := exec.CommandContext(ctx, backend, "pull", img)
+		pull.Stdout = log
+		pull.Stderr = log
+		if err := pull.Run(); err != nil {
+			return fmt.Errorf("failed to pull %s: %w", img, err)
+		}
+	}
+
+	fmt.Fprintf(log, "💾 Saving %d image(s)...\n", len(images))
+	args := append([]string{"save", "-o", dest}, images...)
+	if out, err := exec.CommandContext(ctx, backend, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s save failed: %s", backend, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// writeArchive writes the manifest first, so Inspect does not have to read
+// the whole archive, followed by the asset directories and the image tar
+func writeArchive(dest string, manifest *Manifest, dirs map[string]string, imagesTar string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: ManifestFile, Mode: 0644, Size: int64(len(data)), ModTime: manifest.CreatedAt}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for p := range dirs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := addDir(tw, dirs[p], p); err != nil {
+			return err
+		}
+	}
+	if imagesTar != "" {
+		if err := addFile(tw, imagesTar, ImagesFile); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func addDir(tw *tar.Writer, dir, prefix string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, path, prefix+"/"+filepath.ToSlash(rel))
+	})
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Load unpacks a bundle into the local feature and template caches and
+// loads its images into the container backend
+func Load(ctx context.Context, archive, backend string, log io.Writer) (*Manifest, error) {
+	if backend == "" {
+		backend = "docker"
+	}
+	if log == nil {
+		log = io.Discard
+	}
+	roots := map[string]string{
+		featuresDir:  runner.NewOCIFeatureDownloader(backend).CacheDir(),
+		templatesDir: template.OCITemplateCacheDir(),
+	}
+
+	tmpDir, err := os.MkdirTemp("", "cm-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var manifest *Manifest
+	var imagesTar string
+	err = readArchive(archive, func(header *tar.Header, r io.Reader) error {
+		name := filepath.ToSlash(filepath.Clean(header.Name))
+		switch {
+		case name == ManifestFile:
+			m, err := decodeManifest(r)
+			manifest = m
+			return err
+		case name == ImagesFile:
+			imagesTar = filepath.Join(tmpDir, ImagesFile)
+			return writeFile(imagesTar, r, 0644)
+		}
+
+		kind, rel, _ := strings.Cut(name, "/")
+		root, ok := roots[kind]
+		if !ok || rel == "" {
+			return nil // unknown entries are ignored
+		}
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, filepath.Clean(root)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in bundle: %s", header.Name)
+		}
+		return writeFile(target, r, os.FileMode(header.Mode).Perm())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%s is not a cm bundle (no %s)", archive, ManifestFile)
+	}
+
+	if imagesTar != "" {
+		fmt.Fprintf(log, "📦 Loading %d image(s) into %s...\n", len(manifest.Images), backend)
+		if out, err := exec.CommandContext(ctx, backend, "load", "-i", imagesTar).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("%s load failed: %s", backend, strings.TrimSpace(string(out)))
+		}
+	}
+	return manifest, nil
+}
+
+// errStop ends readArchive early
+var errStop = errors.New("stop")
+
+// Inspect reads the manifest of a bundle
+func Inspect(archive string) (*Manifest, error) {
+	var manifest *Manifest
+	err := readArchive(archive, func(header *tar.Header, r io.Reader) error {
+		if filepath.Clean(header.Name) != ManifestFile {
+			return nil
+		}
+		m, err := decodeManifest(r)
+		if err != nil {
+			return err
+		}
+		manifest = m
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%s is not a cm bundle (no %s)", archive, ManifestFile)
+	}
+	return manifest, nil
+}
+
+// readArchive calls fn for every regular file of a gzipped tar
+func readArchive(archive string, fn func(*tar.Header, io.Reader) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s is not a cm bundle: %w", archive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if m.Version > FormatVersion {
+		return nil, fmt.Errorf("bundle format %d is newer than this cm supports (%d); upgrade cm", m.Version, FormatVersion)
+	}
+	return &m, nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAndLoad(t *testing.T) {
+	src := t.TempDir()
+	featureDir := filepath.Join(src, "ghcr.io-devcontainers-features-go-1")
+	templateDir := filepath.Join(src, "ghcr.io_devcontainers_templates_go_latest")
+	for path, data := range map[string]string{
+		filepath.Join(featureDir, "install.sh"):                          "#!/bin/sh\n",
+		filepath.Join(featureDir, "devcontainer-feature.json"):           `{"id":"go"}`,
+		filepath.Join(templateDir, "devcontainer-template.json"):         `{"id":"go"}`,
+		filepath.Join(templateDir, ".devcontainer", "devcontainer.json"): `{}`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := &Manifest{
+		Version:   FormatVersion,
+		Features:  []Entry{{Ref: "ghcr.io/devcontainers/features/go:1", Path: "features/" + filepath.Base(featureDir)}},
+		Templates: []Entry{{Ref: "ghcr.io/devcontainers/templates/go", Path: "templates/" + filepath.Base(templateDir)}},
+	}
+	archive := filepath.Join(src, "bundle.tar.gz")
+	err := writeArchive(archive, manifest, map[string]string{
+		manifest.Features[0].Path:  featureDir,
+		manifest.Templates[0].Path: templateDir,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inspected, err := Inspect(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inspected.Features) != 1 || inspected.Features[0].Ref != "ghcr.io/devcontainers/features/go:1" {
+		t.Errorf("Inspect features = %+v", inspected.Features)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if _, err := Load(context.Background(), archive, "docker", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(home, ".cm", "features", filepath.Base(featureDir), "install.sh"),
+		filepath.Join(home, ".cm", "oci-templates", filepath.Base(templateDir), ".devcontainer", "devcontainer.json"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s after load: %v", path, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(home, ".cm", "features", filepath.Base(featureDir), "install.sh"))
+	if err == nil && info.Mode().Perm()&0111 == 0 {
+		t.Errorf("install.sh lost its executable bit")
+	}
+}
+
+func TestLoadRejectsForeignArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "other.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	data := []byte("x")
+	_ = tw.WriteHeader(&tar.Header{Name: "features/../../escape", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	_, _ = tw.Write(data)
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	if _, err := Load(context.Background(), archive, "docker", nil); err == nil {
+		t.Error("expected an error for an archive without a manifest")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "home", "escape")); err == nil {
+		t.Error("archive entry escaped the cache directory")
+	}
+}
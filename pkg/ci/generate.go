@@ -0,0 +1,251 @@
+// Package ci generates CI configurations that run a project's checks in its
+// dev container with 'cm ci run'
+package ci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	mkpkg "github.com/UPwith-me/Container-Maker/pkg/make"
+)
+
+// Platforms CI configurations are generated for
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+// installURL is where CI jobs download cm from
+const installURL = "https://github.com/UPwith-me/Container-Maker/releases/latest/download/cm-linux-amd64"
+
+// makeTargets are the Makefile targets CI runs when a project has them, in
+// the order it runs them
+var makeTargets = []string{"lint", "vet", "check", "test", "build"}
+
+// Project is what a CI configuration is generated from
+type Project struct {
+	// ConfigPath is the devcontainer.json, relative to the repository root;
+	// empty for the default location
+	ConfigPath string
+
+	// Dockerfile is whether the image is built from a Dockerfile, whose
+	// build is cached in the registry
+	Dockerfile bool
+
+	// Compose is whether the dev container is a Docker Compose service,
+	// which can't be prebuilt
+	Compose bool
+
+	// Commands are the checks to run, each in a fresh dev container
+	Commands []string
+
+	// Branch is the default branch: pushes to it export the build cache
+	// and push prebuilds
+	Branch string
+}
+
+// DetectProject derives a project's CI configuration from its
+// devcontainer.json, given relative to dir or found in the default
+// locations, and from the lint, test and build targets of its Makefile
+func DetectProject(dir, configPath string) (*Project, error) {
+	p := &Project{ConfigPath: filepath.ToSlash(configPath), Branch: "main"}
+	path := configPath
+	if path == "" {
+		for _, candidate := range []string{".devcontainer/devcontainer.json", "devcontainer.json"} {
+			if _, err := os.Stat(filepath.Join(dir, candidate)); err == nil {
+				path = candidate
+				break
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("no devcontainer.json found")
+		}
+		if path != ".devcontainer/devcontainer.json" {
+			// 'cm ci run' looks there first
+			p.ConfigPath = path
+		}
+	}
+	cfg, err := config.ParseConfig(filepath.Join(dir, path))
+	if err != nil {
+		return nil, err
+	}
+	p.Dockerfile = cfg.Build != nil
+	p.Compose = cfg.DockerComposeFile != nil
+
+	if makefile, err := mkpkg.FindMakefile(dir); err == nil {
+		info, err := mkpkg.ParseMakefile(makefile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Makefile: %w", err)
+		}
+		found := make(map[string]bool)
+		for _, t := range info.Targets {
+			found[t.Name] = true
+		}
+		for _, target := range makeTargets {
+			if found[target] {
+				p.Commands = append(p.Commands, "make "+target)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Generate returns the CI configuration of a project for a platform. With
+// prebuild, pushes to the default branch also push the prebuilt image to
+// the platform's registry.
+func Generate(platform string, p *Project, prebuild bool) (string, error) {
+	if len(p.Commands) == 0 {
+		return "", fmt.Errorf("nothing to run: no Makefile with %s targets was found", strings.Join(makeTargets, ", "))
+	}
+	if prebuild && p.Compose {
+		return "", fmt.Errorf("Docker Compose dev containers can't be prebuilt")
+	}
+	switch platform {
+	case GitHub:
+		return generateGitHub(p, prebuild), nil
+	case GitLab:
+		return generateGitLab(p, prebuild), nil
+	}
+	return "", fmt.Errorf("unknown CI platform %q: want %s or %s", platform, GitHub, GitLab)
+}
+
+// DefaultPath returns where a platform's CI configuration goes
+func DefaultPath(platform string) string {
+	if platform == GitLab {
+		return ".gitlab-ci.yml"
+	}
+	return ".github/workflows/devcontainer.yml"
+}
+
+func generateGitHub(p *Project, prebuild bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by 'cm ci generate github' from %s\n", p.configName())
+	sb.WriteString("name: Dev container\n\n")
+	sb.WriteString("on:\n  push:\n    branches: [" + yamlString(p.Branch) + "]\n  pull_request:\n\n")
+	sb.WriteString("permissions:\n  contents: read\n")
+	if p.Dockerfile || prebuild {
+		sb.WriteString("  packages: write\n")
+	}
+	sb.WriteString("\njobs:\n  ci:\n    runs-on: ubuntu-latest\n    steps:\n")
+	sb.WriteString("      - uses: actions/checkout@v4\n")
+	sb.WriteString("      - name: Install cm\n        run: |\n")
+	sb.WriteString("          mkdir -p \"$HOME/.local/bin\"\n")
+	sb.WriteString("          curl -fsSLo \"$HOME/.local/bin/cm\" " + installURL + "\n")
+	sb.WriteString("          chmod +x \"$HOME/.local/bin/cm\"\n")
+	sb.WriteString("          echo \"$HOME/.local/bin\" >> \"$GITHUB_PATH\"\n")
+	if p.Dockerfile || prebuild {
+		sb.WriteString("      - name: Log in to GitHub Container Registry\n")
+		sb.WriteString("        run: echo \"${{ secrets.GITHUB_TOKEN }}\" | docker login ghcr.io -u \"${{ github.actor }}\" --password-stdin\n")
+	}
+	if p.Dockerfile {
+		// Pull requests import the cache; pushes to the default branch,
+		// whose token can write packages, export it too
+		sb.WriteString("      - name: Set up the build cache\n        run: |\n")
+		sb.WriteString("          cache=\"ghcr.io/${GITHUB_REPOSITORY,,}/devcontainer-cache\"\n")
+		sb.WriteString("          echo \"CM_CACHE_FROM=type=registry,ref=$cache\" >> \"$GITHUB_ENV\"\n")
+		sb.WriteString("          if [ \"$GITHUB_EVENT_NAME\" = push ]; then\n")
+		sb.WriteString("            echo \"CM_CACHE_TO=type=registry,ref=$cache,mode=max\" >> \"$GITHUB_ENV\"\n")
+		sb.WriteString("          fi\n")
+	}
+	for _, command := range p.Commands {
+		fmt.Fprintf(&sb, "      - name: %s\n", yamlString(command))
+		fmt.Fprintf(&sb, "        run: %s\n", yamlString(p.ciRun(command)))
+	}
+	if prebuild {
+		sb.WriteString("      - name: Push the prebuilt image\n")
+		sb.WriteString("        if: github.event_name == 'push'\n")
+		sb.WriteString("        run: " + yamlString(p.prebuildCommand(`"ghcr.io/${GITHUB_REPOSITORY,,}/devcontainer"`)) + "\n")
+	}
+	sb.WriteString("      - name: Upload the reports\n        if: always()\n        uses: actions/upload-artifact@v4\n")
+	sb.WriteString("        with:\n          name: cm-ci\n          path: cm-ci/\n")
+	return sb.String()
+}
+
+func generateGitLab(p *Project, prebuild bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by 'cm ci generate gitlab' from %s\n", p.configName())
+	sb.WriteString("# The jobs use Docker-in-Docker, which needs privileged runners.\n\n")
+	sb.WriteString(".cm:\n  image: docker:27\n  services:\n    - docker:27-dind\n")
+	sb.WriteString("  variables:\n    DOCKER_TLS_CERTDIR: /certs\n")
+	sb.WriteString("  before_script:\n")
+	sb.WriteString("    - wget -qO /usr/local/bin/cm " + installURL + " && chmod +x /usr/local/bin/cm\n")
+	if p.Dockerfile || prebuild {
+		sb.WriteString("    - echo \"$CI_REGISTRY_PASSWORD\" | docker login \"$CI_REGISTRY\" -u \"$CI_REGISTRY_USER\" --password-stdin\n")
+	}
+	if p.Dockerfile {
+		sb.WriteString("    - export CM_CACHE_FROM=\"type=registry,ref=$CI_REGISTRY_IMAGE/devcontainer-cache\"\n")
+		sb.WriteString("    - if [ \"$CI_COMMIT_BRANCH\" = \"$CI_DEFAULT_BRANCH\" ]; then export CM_CACHE_TO=\"$CM_CACHE_FROM,mode=max\"; fi\n")
+	}
+
+	sb.WriteString("\ndevcontainer:\n  extends: .cm\n  script:\n")
+	for _, command := range p.Commands {
+		fmt.Fprintf(&sb, "    - %s\n", yamlString(p.ciRun(command)))
+	}
+	sb.WriteString("  artifacts:\n    when: always\n    reports:\n      junit: cm-ci/*.xml\n")
+
+	if prebuild {
+		sb.WriteString("\ndevcontainer-prebuild:\n  extends: .cm\n  needs: [devcontainer]\n")
+		sb.WriteString("  rules:\n    - if: $CI_COMMIT_BRANCH == $CI_DEFAULT_BRANCH\n")
+		sb.WriteString("  script:\n")
+		fmt.Fprintf(&sb, "    - %s\n", yamlString(p.prebuildCommand(`"$CI_REGISTRY_IMAGE/devcontainer"`)))
+	}
+	return sb.String()
+}
+
+// ciRun returns the cm ci run command line of a check; checks using shell
+// syntax run in sh
+func (p *Project) ciRun(command string) string {
+	name := strings.Trim(reportNameInvalid.ReplaceAllString(strings.TrimPrefix(command, "make "), "-"), "-")
+	if name == "" {
+		name = "check"
+	}
+	if strings.ContainsAny(command, "&|;<>$`()*?'\"\\") {
+		command = "sh -c " + shellQuote(command)
+	}
+	return "cm ci run" + p.configFlag() + " --junit cm-ci/" + name + ".xml -- " + command
+}
+
+// reportNameInvalid matches what report file names can't have
+var reportNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// prebuildCommand returns the cm prebuild command line pushing to repo
+func (p *Project) prebuildCommand(repo string) string {
+	return "cm prebuild" + p.configFlag() + " --push " + repo
+}
+
+func (p *Project) configFlag() string {
+	if p.ConfigPath == "" {
+		return ""
+	}
+	return " -c " + shellQuote(p.ConfigPath)
+}
+
+func (p *Project) configName() string {
+	if p.ConfigPath == "" {
+		return ".devcontainer/devcontainer.json"
+	}
+	return p.ConfigPath
+}
+
+// yamlString returns s as a YAML scalar, quoted if it needs to be
+func yamlString(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// shellQuote quotes s for sh when it isn't a plain word
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
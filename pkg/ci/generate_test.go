@@ -0,0 +1,95 @@
+package ci
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectProject(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		".devcontainer/devcontainer.json": `{"build": {"dockerfile": "Dockerfile"}}`,
+		"Makefile":                        "VERSION := 1.0\n.PHONY: test lint\nbuild:\n\tgo build\ntest:\n\tgo test ./...\nlint:\n\tgolangci-lint run\nrelease:\n\tgoreleaser\n",
+	})
+	p, err := DetectProject(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ConfigPath != "" || !p.Dockerfile || p.Compose {
+		t.Errorf("project = %+v, want the default Dockerfile config", p)
+	}
+	want := []string{"make lint", "make test", "make build"}
+	if strings.Join(p.Commands, ",") != strings.Join(want, ",") {
+		t.Errorf("commands = %q, want %q", p.Commands, want)
+	}
+
+	dir = writeProject(t, map[string]string{"devcontainer.json": `{"image": "golang:1.24"}`})
+	p, err = DetectProject(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ConfigPath != "devcontainer.json" || p.Dockerfile || len(p.Commands) != 0 {
+		t.Errorf("project = %+v, want devcontainer.json with an image and no commands", p)
+	}
+
+	if _, err := DetectProject(t.TempDir(), ""); err == nil {
+		t.Error("DetectProject should fail without a devcontainer.json")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	p := &Project{
+		ConfigPath: "ci/devcontainer.json",
+		Dockerfile: true,
+		Commands:   []string{"make test", "go vet ./... && go test -race ./..."},
+		Branch:     "main",
+	}
+	for _, platform := range []string{GitHub, GitLab} {
+		out, err := Generate(platform, p, true)
+		if err != nil {
+			t.Fatalf("%s: %v", platform, err)
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+			t.Fatalf("%s: invalid YAML: %v\n%s", platform, err, out)
+		}
+		for _, want := range []string{
+			"cm ci run -c ci/devcontainer.json --junit cm-ci/test.xml -- make test",
+			"--junit cm-ci/go-vet-go-test-race.xml -- sh -c 'go vet ./... && go test -race ./...'",
+			"CM_CACHE_FROM",
+			"cm prebuild -c ci/devcontainer.json --push",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: missing %q in\n%s", platform, want, out)
+			}
+		}
+	}
+
+	if _, err := Generate(GitHub, &Project{Compose: true, Commands: []string{"make test"}}, true); err == nil {
+		t.Error("Generate should refuse to prebuild a Docker Compose dev container")
+	}
+	if _, err := Generate(GitHub, &Project{}, false); err == nil {
+		t.Error("Generate should fail without commands")
+	}
+	if _, err := Generate("jenkins", &Project{Commands: []string{"make test"}}, false); err == nil {
+		t.Error("Generate should fail for an unknown platform")
+	}
+}
@@ -3,7 +3,11 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/tailscale/hujson"
 )
@@ -29,6 +33,13 @@ type DevContainerConfig struct {
 	ContainerEnv map[string]string `json:"containerEnv,omitempty"`
 	RemoteEnv    map[string]string `json:"remoteEnv,omitempty"`
 
+	// Host devices: classes such as "usb:serial" or "audio", or paths
+	Devices []string `json:"devices,omitempty"`
+
+	// Name resolution: /etc/hosts entries and resolv.conf settings
+	HostAliases []HostAlias `json:"hostAliases,omitempty"`
+	DNS         *DNSConfig  `json:"dns,omitempty"`
+
 	// Lifecycle commands
 	OnCreateCommand   interface{} `json:"onCreateCommand,omitempty"`   // string or []string
 	PostCreateCommand interface{} `json:"postCreateCommand,omitempty"` // string or []string
@@ -36,10 +47,15 @@ type DevContainerConfig struct {
 	PostAttachCommand interface{} `json:"postAttachCommand,omitempty"` // string or []string
 
 	// DevContainer Features
-	Features map[string]interface{} `json:"features,omitempty"`
+	Features                    map[string]interface{} `json:"features,omitempty"`
+	OverrideFeatureInstallOrder []string               `json:"overrideFeatureInstallOrder,omitempty"`
 
 	// Port forwarding
 	ForwardPorts []interface{} `json:"forwardPorts,omitempty"` // number or string
+	AppPort      interface{}   `json:"appPort,omitempty"`      // number, string or an array of them (legacy)
+
+	// Publish every port the image exposes on a random host port
+	PublishAllPorts bool `json:"publishAllPorts,omitempty"`
 
 	// User configuration
 	User string `json:"user,omitempty"`
@@ -47,12 +63,122 @@ type DevContainerConfig struct {
 	// Workspace configuration
 	WorkspaceMount  string `json:"workspaceMount,omitempty"`
 	WorkspaceFolder string `json:"workspaceFolder,omitempty"`
+
+	// Docker access from inside the container: "proxy", "dind" or "none"
+	DockerInDocker string             `json:"dockerInDocker,omitempty"`
+	DockerProxy    *DockerProxyConfig `json:"dockerProxy,omitempty"`
+
+	// Minimum resources the container needs, checked before building
+	HostRequirements *HostRequirements `json:"hostRequirements,omitempty"`
+
+	// ConfigDir is the directory containing devcontainer.json; local
+	// features ("./my-feature") are resolved relative to it
+	ConfigDir string `json:"-"`
 }
 
 type BuildConfig struct {
 	Dockerfile string            `json:"dockerfile,omitempty"`
 	Context    string            `json:"context,omitempty"`
 	Args       map[string]string `json:"args,omitempty"`
+	Target     string            `json:"target,omitempty"`
+}
+
+// DockerProxyConfig selects the Docker API endpoints the socket proxy lets
+// through when dockerInDocker is "proxy"
+type DockerProxyConfig struct {
+	Allow      []string `json:"allow,omitempty"`      // e.g. ["containers", "images", "build"]
+	AllowWrite bool     `json:"allowWrite,omitempty"` // Allow POST/DELETE requests on the allowed endpoints
+}
+
+// HostAlias makes hostnames resolve to an IP in the container, like
+// docker run --add-host. The IP "host-gateway" is the host.
+type HostAlias struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// DNSConfig replaces the DNS settings the container gets from the host
+type DNSConfig struct {
+	Servers []string `json:"servers,omitempty"` // e.g. ["10.0.0.2", "10.0.0.3"]
+	Search  []string `json:"search,omitempty"`  // Search domains, e.g. ["corp.example.com"]
+	Options []string `json:"options,omitempty"` // e.g. ["ndots:2", "timeout:1"]
+}
+
+// HostRequirements are the minimum host resources for the container. Sizes
+// are strings such as "8gb" or "512mb".
+type HostRequirements struct {
+	CPUs    int         `json:"cpus,omitempty"`
+	Memory  string      `json:"memory,omitempty"`
+	Storage string      `json:"storage,omitempty"`
+	GPU     interface{} `json:"gpu,omitempty"` // true, "optional" or {"cores": n, "memory": "8gb", "vendor": "amd"}
+}
+
+// PortSpecs returns the ports to publish, from forwardPorts followed by the
+// legacy appPort, as specs such as "8080", "3000:80" or "53/udp". Duplicates
+// and entries that are neither numbers nor strings are dropped.
+func (c *DevContainerConfig) PortSpecs() []string {
+	ports := append([]interface{}{}, c.ForwardPorts...)
+	switch v := c.AppPort.(type) {
+	case nil:
+	case []interface{}:
+		ports = append(ports, v...)
+	default:
+		ports = append(ports, v)
+	}
+
+	seen := map[string]bool{}
+	var specs []string
+	for _, p := range ports {
+		var spec string
+		switch v := p.(type) {
+		case float64: // JSON numbers are floats
+			spec = strconv.Itoa(int(v))
+		case int:
+			spec = strconv.Itoa(v)
+		case string:
+			spec = strings.TrimSpace(v)
+		}
+		if spec == "" || seen[spec] {
+			continue
+		}
+		seen[spec] = true
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// ExtraHosts returns the hostAliases as "hostname:ip" entries, the form of
+// docker run --add-host
+func (c *DevContainerConfig) ExtraHosts() ([]string, error) {
+	var hosts []string
+	for _, alias := range c.HostAliases {
+		if alias.IP != "host-gateway" && net.ParseIP(alias.IP) == nil {
+			return nil, fmt.Errorf("hostAliases: %q is not an IP address", alias.IP)
+		}
+		if len(alias.Hostnames) == 0 {
+			return nil, fmt.Errorf("hostAliases: no hostnames for %s", alias.IP)
+		}
+		for _, name := range alias.Hostnames {
+			if name == "" || strings.ContainsAny(name, ": \t") {
+				return nil, fmt.Errorf("hostAliases: invalid hostname %q", name)
+			}
+			hosts = append(hosts, name+":"+alias.IP)
+		}
+	}
+	return hosts, nil
+}
+
+// Validate checks the DNS servers are IP addresses
+func (d *DNSConfig) Validate() error {
+	if d == nil {
+		return nil
+	}
+	for _, server := range d.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("dns.servers: %q is not an IP address", server)
+		}
+	}
+	return nil
 }
 
 // ParseConfig reads and parses a devcontainer.json file
@@ -73,5 +199,9 @@ func ParseConfig(path string) (*DevContainerConfig, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if abs, err := filepath.Abs(path); err == nil {
+		config.ConfigDir = filepath.Dir(abs)
+	}
+
 	return &config, nil
 }
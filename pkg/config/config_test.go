@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -110,3 +111,84 @@ func TestParseConfig_NotFound(t *testing.T) {
 		t.Error("Expected error for non-existent file")
 	}
 }
+
+func TestParseConfig_AppPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"number", `{"image": "node", "appPort": 3000}`, []string{"3000"}},
+		{"string", `{"image": "node", "appPort": "8080:80"}`, []string{"8080:80"}},
+		{"array", `{"image": "node", "appPort": [3000, "9229:9229", "53/udp"]}`, []string{"3000", "9229:9229", "53/udp"}},
+		{"with forwardPorts", `{"image": "node", "forwardPorts": [3000, 5432], "appPort": [3000, 8080]}`, []string{"3000", "5432", "8080"}},
+		{"none", `{"image": "node"}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := ParseConfig(configPath)
+			if err != nil {
+				t.Fatalf("ParseConfig failed: %v", err)
+			}
+
+			got := cfg.PortSpecs()
+			if len(got) != len(tt.want) {
+				t.Fatalf("PortSpecs() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("PortSpecs() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfig_HostAliasesAndDNS(t *testing.T) {
+	content := `{
+		"image": "node",
+		"hostAliases": [
+			{"ip": "10.1.2.3", "hostnames": ["git.corp", "registry.corp"]},
+			{"ip": "host-gateway", "hostnames": ["host.docker.internal"]}
+		],
+		"dns": {"servers": ["10.0.0.2"], "search": ["corp.example.com"], "options": ["ndots:2"]}
+	}`
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := ParseConfig(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	hosts, err := cfg.ExtraHosts()
+	if err != nil {
+		t.Fatalf("ExtraHosts failed: %v", err)
+	}
+	want := []string{"git.corp:10.1.2.3", "registry.corp:10.1.2.3", "host.docker.internal:host-gateway"}
+	if strings.Join(hosts, " ") != strings.Join(want, " ") {
+		t.Errorf("ExtraHosts() = %v, want %v", hosts, want)
+	}
+	if err := cfg.DNS.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+	if cfg.DNS.Search[0] != "corp.example.com" || cfg.DNS.Options[0] != "ndots:2" {
+		t.Errorf("DNS = %+v", cfg.DNS)
+	}
+
+	bad := &DevContainerConfig{HostAliases: []HostAlias{{IP: "git.corp", Hostnames: []string{"10.1.2.3"}}}}
+	if _, err := bad.ExtraHosts(); err == nil {
+		t.Error("Expected error for a hostname given as the IP")
+	}
+	if err := (&DNSConfig{Servers: []string{"dns.corp"}}).Validate(); err == nil {
+		t.Error("Expected error for a DNS server that is not an IP")
+	}
+}
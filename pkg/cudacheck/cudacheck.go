@@ -0,0 +1,347 @@
+// Package cudacheck compares the CUDA version a GPU image is built for with
+// the newest one the host's NVIDIA driver supports, so a container that
+// would fail with "CUDA driver version is insufficient" is caught before it
+// starts, with an image tag that fits the driver. The check is skipped with
+// --ignore-host-requirements or CM_IGNORE_CUDA_CHECK=1.
+package cudacheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IgnoreEnvVar turns a blocking mismatch into a warning when set to 1 or true
+const IgnoreEnvVar = "CM_IGNORE_CUDA_CHECK"
+
+var ignore bool
+
+// Ignore turns a blocking mismatch into a warning for the rest of the process
+func Ignore() {
+	ignore = true
+}
+
+// Ignored reports whether mismatches are only warned about, via
+// --ignore-host-requirements or CM_IGNORE_CUDA_CHECK
+func Ignored() bool {
+	if ignore {
+		return true
+	}
+	switch strings.ToLower(os.Getenv(IgnoreEnvVar)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// Version is a CUDA major.minor version
+type Version struct {
+	Major, Minor int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Less reports whether v is older than o
+func (v Version) Less(o Version) bool {
+	return v.Major < o.Major || v.Major == o.Major && v.Minor < o.Minor
+}
+
+// ParseVersion reads "12.1" or "12.1.0"
+func ParseVersion(s string) (Version, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 {
+		return Version{}, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return Version{}, false
+	}
+	return Version{major, minor}, true
+}
+
+// releases are the CUDA releases, newest first, with the oldest Linux
+// driver that runs them and their last nvidia/cuda image version
+var releases = []struct {
+	cuda   Version
+	driver string
+	image  string
+}{
+	{Version{13, 0}, "580.65.06", "13.0.0"},
+	{Version{12, 9}, "575.51.03", "12.9.1"},
+	{Version{12, 8}, "570.26", "12.8.1"},
+	{Version{12, 6}, "560.28.03", "12.6.3"},
+	{Version{12, 5}, "555.42.02", "12.5.1"},
+	{Version{12, 4}, "550.54.14", "12.4.1"},
+	{Version{12, 3}, "545.23.06", "12.3.2"},
+	{Version{12, 2}, "535.54.03", "12.2.2"},
+	{Version{12, 1}, "530.30.02", "12.1.1"},
+	{Version{12, 0}, "525.60.13", "12.0.1"},
+	{Version{11, 8}, "520.61.05", "11.8.0"},
+	{Version{11, 7}, "515.43.04", "11.7.1"},
+	{Version{11, 6}, "510.39.01", "11.6.2"},
+	{Version{11, 5}, "495.29.05", "11.5.2"},
+	{Version{11, 4}, "470.42.01", "11.4.3"},
+	{Version{11, 3}, "465.19.01", "11.3.1"},
+	{Version{11, 2}, "460.27.03", "11.2.2"},
+	{Version{11, 1}, "455.23", "11.1.1"},
+	{Version{11, 0}, "450.36.06", "11.0.3"},
+}
+
+// MinDriver returns the oldest Linux driver that runs a CUDA version, or ""
+// when the version is unknown
+func MinDriver(v Version) string {
+	for _, r := range releases {
+		if r.cuda == v {
+			return r.driver
+		}
+	}
+	return ""
+}
+
+// MaxCUDA returns the newest CUDA version a driver runs
+func MaxCUDA(driver string) (Version, bool) {
+	for _, r := range releases {
+		if compareDriver(driver, r.driver) >= 0 {
+			return r.cuda, true
+		}
+	}
+	return Version{}, false
+}
+
+// compareDriver compares dotted driver versions like "535.104.05"
+func compareDriver(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Host is the host's NVIDIA driver and the newest CUDA version it runs
+type Host struct {
+	Driver string
+	CUDA   Version
+}
+
+var (
+	driverPattern   = regexp.MustCompile(`Driver Version:\s*([\d.]+)`)
+	cudaPattern     = regexp.MustCompile(`CUDA Version:\s*(\d+\.\d+)`)
+	imageTagPattern = regexp.MustCompile(`cuda[-_]?(\d+\.\d+)`)
+	requirePattern  = regexp.MustCompile(`cuda>=(\d+\.\d+)`)
+)
+
+// DetectHost reads the driver from nvidia-smi; ok is false without one
+func DetectHost(ctx context.Context) (Host, bool) {
+	out, err := exec.CommandContext(ctx, "nvidia-smi").Output()
+	if err != nil {
+		return Host{}, false
+	}
+	return parseNvidiaSMI(string(out))
+}
+
+// parseNvidiaSMI reads the driver and CUDA version from nvidia-smi's
+// header, telling the CUDA version from the driver when it isn't shown
+func parseNvidiaSMI(out string) (Host, bool) {
+	m := driverPattern.FindStringSubmatch(out)
+	if m == nil {
+		return Host{}, false
+	}
+	host := Host{Driver: m[1]}
+	if m := cudaPattern.FindStringSubmatch(out); m != nil {
+		host.CUDA, _ = ParseVersion(m[1])
+	}
+	if host.CUDA == (Version{}) {
+		var ok bool
+		if host.CUDA, ok = MaxCUDA(host.Driver); !ok {
+			return Host{}, false
+		}
+	}
+	return host, true
+}
+
+// Image is the CUDA version an image is built for
+type Image struct {
+	Ref  string
+	CUDA Version
+	// Enforced is set when the image has NVIDIA_REQUIRE_CUDA, which makes
+	// the NVIDIA container runtime refuse to start it on an older driver
+	Enforced bool
+}
+
+// DetectImage reads the CUDA version from a local image's CUDA_VERSION or
+// NVIDIA_REQUIRE_CUDA, or else from its tag, as in
+// "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime"; ok is false for images
+// without CUDA
+func DetectImage(ctx context.Context, backend, ref string) (Image, bool) {
+	var env []string
+	if out, err := exec.CommandContext(ctx, backend, "image", "inspect", "--format", "{{json .Config.Env}}", ref).Output(); err == nil {
+		_ = json.Unmarshal(out, &env)
+	}
+	return imageCUDA(ref, env)
+}
+
+func imageCUDA(ref string, env []string) (Image, bool) {
+	img := Image{Ref: ref}
+	vars := map[string]string{}
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			vars[k] = v
+		}
+	}
+	if req, ok := vars["NVIDIA_REQUIRE_CUDA"]; ok && vars["NVIDIA_DISABLE_REQUIRE"] == "" {
+		if m := requirePattern.FindStringSubmatch(req); m != nil {
+			img.CUDA, _ = ParseVersion(m[1])
+			img.Enforced = true
+			return img, true
+		}
+	}
+	if v, ok := ParseVersion(vars["CUDA_VERSION"]); ok {
+		img.CUDA = v
+		return img, true
+	}
+
+	repo, tag := splitRef(ref)
+	if repo == "nvidia/cuda" || strings.HasSuffix(repo, "/nvidia/cuda") {
+		if v, ok := ParseVersion(strings.SplitN(tag, "-", 2)[0]); ok {
+			img.CUDA = v
+			return img, true
+		}
+	}
+	if m := imageTagPattern.FindStringSubmatch(tag); m != nil {
+		img.CUDA, _ = ParseVersion(m[1])
+		return img, true
+	}
+	return Image{}, false
+}
+
+// splitRef splits an image reference into its repository and tag
+func splitRef(ref string) (repo, tag string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// Result is how an image's CUDA version fits the host's driver
+type Result struct {
+	Status  string // "ok", "warning", "error"
+	Message string
+	Fix     string
+}
+
+// Compare checks an image's CUDA version against the host's. A newer major
+// version, or a newer minor one the image enforces, can't run; a newer
+// minor one otherwise may, under CUDA's minor version compatibility.
+func Compare(img Image, host Host) Result {
+	if !host.CUDA.Less(img.CUDA) {
+		return Result{Status: "ok", Message: fmt.Sprintf("%s uses CUDA %s; the driver %s runs up to CUDA %s", img.Ref, img.CUDA, host.Driver, host.CUDA)}
+	}
+
+	result := Result{
+		Status:  "error",
+		Message: fmt.Sprintf("%s needs CUDA %s, but the driver %s runs up to CUDA %s", img.Ref, img.CUDA, host.Driver, host.CUDA),
+	}
+	if img.CUDA.Major == host.CUDA.Major && !img.Enforced {
+		result.Status = "warning"
+		result.Message += "; it may start under minor version compatibility, but kernels using newer features will fail"
+	}
+
+	var fixes []string
+	if tag := SuggestImage(img.Ref, host.CUDA); tag != "" {
+		fixes = append(fixes, "Use "+tag+" instead")
+	} else {
+		fixes = append(fixes, fmt.Sprintf("Use an image built for CUDA %s or older", host.CUDA))
+	}
+	if driver := MinDriver(img.CUDA); driver != "" {
+		fixes = append(fixes, fmt.Sprintf("or upgrade the NVIDIA driver to %s or newer", driver))
+	}
+	result.Fix = strings.Join(fixes, ", ")
+	return result
+}
+
+// SuggestImage returns ref retagged for the newest CUDA version up to max:
+// the last release of it for nvidia/cuda images, or the nearest CUDA build
+// PyTorch publishes for "cudaX.Y" tags. It returns "" when it can't.
+func SuggestImage(ref string, max Version) string {
+	repo, tag := splitRef(ref)
+
+	if repo == "nvidia/cuda" || strings.HasSuffix(repo, "/nvidia/cuda") {
+		_, rest, _ := strings.Cut(tag, "-")
+		for _, r := range releases {
+			if !max.Less(r.cuda) {
+				if rest == "" {
+					return repo + ":" + r.image
+				}
+				return repo + ":" + r.image + "-" + rest
+			}
+		}
+		return ""
+	}
+
+	loc := imageTagPattern.FindStringSubmatchIndex(tag)
+	if loc == nil {
+		return ""
+	}
+	for _, v := range pytorchCUDA {
+		if !max.Less(v) {
+			return repo + ":" + tag[:loc[2]] + v.String() + tag[loc[3]:]
+		}
+	}
+	return ""
+}
+
+// pytorchCUDA are the CUDA versions PyTorch images are built for, newest
+// first
+var pytorchCUDA = []Version{{12, 8}, {12, 6}, {12, 4}, {12, 1}, {11, 8}, {11, 7}, {11, 3}}
+
+// Check compares a GPU image with the host's driver before it runs. A
+// mismatch that can't run is printed to log and returned as an error unless
+// the check is ignored; one that may run is only warned about. Hosts
+// without an NVIDIA driver and images without CUDA aren't checked.
+func Check(ctx context.Context, backend, ref string, log io.Writer) error {
+	if log == nil {
+		log = io.Discard
+	}
+	host, ok := DetectHost(ctx)
+	if !ok {
+		return nil
+	}
+	img, ok := DetectImage(ctx, backend, ref)
+	if !ok {
+		return nil
+	}
+
+	result := Compare(img, host)
+	switch {
+	case result.Status == "ok":
+		return nil
+	case result.Status == "warning" || Ignored():
+		fmt.Fprintf(log, "⚠️  %s\n", result.Message)
+		fmt.Fprintf(log, "💡 %s\n", result.Fix)
+		return nil
+	}
+	fmt.Fprintf(log, "❌ %s\n", result.Message)
+	fmt.Fprintf(log, "💡 %s\n", result.Fix)
+	return fmt.Errorf("CUDA %s image on a CUDA %s driver; change the image, upgrade the driver or run with --ignore-host-requirements", img.CUDA, host.CUDA)
+}
@@ -0,0 +1,93 @@
+package cudacheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNvidiaSMI(t *testing.T) {
+	out := `+---------------------------------------------------------------------------------------+
+| NVIDIA-SMI 535.104.05             Driver Version: 535.104.05   CUDA Version: 12.2     |
+|-----------------------------------------+----------------------+----------------------+`
+	host, ok := parseNvidiaSMI(out)
+	if !ok || host.Driver != "535.104.05" || host.CUDA != (Version{12, 2}) {
+		t.Errorf("parseNvidiaSMI = %+v, %v", host, ok)
+	}
+
+	// Old drivers don't show the CUDA version
+	host, ok = parseNvidiaSMI("| NVIDIA-SMI 470.82.01    Driver Version: 470.82.01 |")
+	if !ok || host.CUDA != (Version{11, 4}) {
+		t.Errorf("CUDA from the driver = %+v, %v", host, ok)
+	}
+
+	if _, ok := parseNvidiaSMI("command not found"); ok {
+		t.Error("Output without a driver should not parse")
+	}
+}
+
+func TestImageCUDA(t *testing.T) {
+	tests := []struct {
+		ref      string
+		env      []string
+		want     Version
+		enforced bool
+		ok       bool
+	}{
+		{"nvidia/cuda:12.1.0-cudnn8-devel-ubuntu22.04", []string{"CUDA_VERSION=12.1.0", "NVIDIA_REQUIRE_CUDA=cuda>=12.1 brand=tesla,driver>=470,driver<471"}, Version{12, 1}, true, true},
+		{"nvidia/cuda:12.1.0-cudnn8-devel-ubuntu22.04", []string{"NVIDIA_REQUIRE_CUDA=cuda>=12.1", "NVIDIA_DISABLE_REQUIRE=1", "CUDA_VERSION=12.1.0"}, Version{12, 1}, false, true},
+		{"nvidia/cuda:11.8.0-runtime-ubuntu22.04", nil, Version{11, 8}, false, true},
+		{"pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime", nil, Version{12, 1}, false, true},
+		{"localhost:5000/ml:cuda11.7", nil, Version{11, 7}, false, true},
+		{"python:3.11", []string{"PATH=/usr/bin"}, Version{}, false, false},
+	}
+	for _, tt := range tests {
+		img, ok := imageCUDA(tt.ref, tt.env)
+		if ok != tt.ok || img.CUDA != tt.want || img.Enforced != tt.enforced {
+			t.Errorf("imageCUDA(%q) = %+v, %v", tt.ref, img, ok)
+		}
+	}
+}
+
+func TestMaxCUDA(t *testing.T) {
+	tests := map[string]Version{
+		"535.104.05": {12, 2},
+		"550.54.14":  {12, 4},
+		"525.60.13":  {12, 0},
+		"470.82.01":  {11, 4},
+	}
+	for driver, want := range tests {
+		if got, ok := MaxCUDA(driver); !ok || got != want {
+			t.Errorf("MaxCUDA(%s) = %v, want %v", driver, got, want)
+		}
+	}
+	if _, ok := MaxCUDA("390.48"); ok {
+		t.Error("A driver older than CUDA 11 should have no known CUDA version")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	host := Host{Driver: "535.104.05", CUDA: Version{12, 2}}
+
+	if r := Compare(Image{Ref: "nvidia/cuda:11.8.0-base-ubuntu22.04", CUDA: Version{11, 8}}, host); r.Status != "ok" {
+		t.Errorf("Older CUDA should be ok: %+v", r)
+	}
+
+	r := Compare(Image{Ref: "nvidia/cuda:12.4.1-cudnn-runtime-ubuntu22.04", CUDA: Version{12, 4}, Enforced: true}, host)
+	if r.Status != "error" || !strings.Contains(r.Fix, "nvidia/cuda:12.2.2-cudnn-runtime-ubuntu22.04") || !strings.Contains(r.Fix, "550.54.14") {
+		t.Errorf("Enforced newer minor should be an error with a retagged image: %+v", r)
+	}
+
+	r = Compare(Image{Ref: "pytorch/pytorch:2.5.1-cuda12.4-cudnn9-runtime", CUDA: Version{12, 4}}, host)
+	if r.Status != "warning" || !strings.Contains(r.Fix, "pytorch/pytorch:2.5.1-cuda12.1-cudnn9-runtime") {
+		t.Errorf("Newer minor should be a warning: %+v", r)
+	}
+
+	r = Compare(Image{Ref: "nvidia/cuda:12.1.0-base-ubuntu22.04", CUDA: Version{12, 1}}, Host{Driver: "470.82.01", CUDA: Version{11, 4}})
+	if r.Status != "error" || !strings.Contains(r.Fix, "nvidia/cuda:11.4.3-base-ubuntu22.04") {
+		t.Errorf("Newer major should be an error: %+v", r)
+	}
+
+	if tag := SuggestImage("myorg/model:latest", Version{12, 2}); tag != "" {
+		t.Errorf("SuggestImage without a CUDA tag = %q", tag)
+	}
+}
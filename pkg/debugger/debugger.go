@@ -0,0 +1,194 @@
+// Package debugger runs a project's program under its language's debugger
+// in the dev container, listening on the conventional port for that
+// debugger, and renders editor configurations that attach to it.
+package debugger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Debugger is a language's debug adapter and how to run a program under it
+type Debugger struct {
+	Lang    string
+	Name    string // The debugger, e.g. "delve"
+	Port    int    // Its conventional port
+	Install string // Installs the debugger when Check fails
+	Check   string // Succeeds when the debugger is installed
+
+	// program is the default program to debug
+	program string
+	command func(program string, port int, args []string) []string
+	launch  func(port int, workspace string) map[string]interface{}
+}
+
+var debuggers = map[string]*Debugger{
+	"go": {
+		Lang:    "go",
+		Name:    "delve",
+		Port:    2345,
+		Install: "go install github.com/go-delve/delve/cmd/dlv@latest",
+		Check:   "command -v dlv || test -x \"$(go env GOPATH)/bin/dlv\"",
+		program: ".",
+		command: func(program string, port int, args []string) []string {
+			cmd := []string{"sh", "-c", `PATH="$PATH:$(go env GOPATH)/bin" exec dlv "$@"`, "dlv",
+				"debug", program, "--headless", "--listen=:" + strconv.Itoa(port), "--api-version=2", "--accept-multiclient"}
+			if len(args) > 0 {
+				cmd = append(append(cmd, "--"), args...)
+			}
+			return cmd
+		},
+		launch: func(port int, workspace string) map[string]interface{} {
+			return map[string]interface{}{
+				"name":           "Attach to cm debug (Go)",
+				"type":           "go",
+				"request":        "attach",
+				"mode":           "remote",
+				"host":           "127.0.0.1",
+				"port":           port,
+				"substitutePath": []map[string]string{{"from": "${workspaceFolder}", "to": workspace}},
+			}
+		},
+	},
+	"python": {
+		Lang:    "python",
+		Name:    "debugpy",
+		Port:    5678,
+		Install: "python3 -m pip install debugpy",
+		Check:   "python3 -c 'import debugpy'",
+		program: "main.py",
+		command: func(program string, port int, args []string) []string {
+			cmd := []string{"python3", "-m", "debugpy", "--listen", "0.0.0.0:" + strconv.Itoa(port), "--wait-for-client"}
+			if strings.HasPrefix(program, "-m") {
+				cmd = append(cmd, "-m", strings.TrimSpace(strings.TrimPrefix(program, "-m")))
+			} else {
+				cmd = append(cmd, program)
+			}
+			return append(cmd, args...)
+		},
+		launch: func(port int, workspace string) map[string]interface{} {
+			return map[string]interface{}{
+				"name":         "Attach to cm debug (Python)",
+				"type":         "debugpy",
+				"request":      "attach",
+				"connect":      map[string]interface{}{"host": "127.0.0.1", "port": port},
+				"pathMappings": []map[string]string{{"localRoot": "${workspaceFolder}", "remoteRoot": workspace}},
+				"justMyCode":   false,
+			}
+		},
+	},
+	"node": {
+		Lang:    "node",
+		Name:    "node --inspect",
+		Port:    9229,
+		Check:   "command -v node",
+		program: "index.js",
+		command: func(program string, port int, args []string) []string {
+			return append([]string{"node", "--inspect-brk=0.0.0.0:" + strconv.Itoa(port), program}, args...)
+		},
+		launch: func(port int, workspace string) map[string]interface{} {
+			return map[string]interface{}{
+				"name":       "Attach to cm debug (Node.js)",
+				"type":       "node",
+				"request":    "attach",
+				"address":    "127.0.0.1",
+				"port":       port,
+				"localRoot":  "${workspaceFolder}",
+				"remoteRoot": workspace,
+			}
+		},
+	},
+}
+
+// aliases are other names of the languages
+var aliases = map[string]string{
+	"golang":     "go",
+	"py":         "python",
+	"nodejs":     "node",
+	"javascript": "node",
+	"js":         "node",
+	"typescript": "node",
+	"ts":         "node",
+}
+
+// Get returns the debugger of a language
+func Get(lang string) (*Debugger, error) {
+	lang = strings.ToLower(lang)
+	if alias, ok := aliases[lang]; ok {
+		lang = alias
+	}
+	d, ok := debuggers[lang]
+	if !ok {
+		return nil, fmt.Errorf("no debugger for %q: want one of %s", lang, strings.Join(Languages(), ", "))
+	}
+	return d, nil
+}
+
+// Languages returns the languages with a debugger
+func Languages() []string {
+	langs := make([]string, 0, len(debuggers))
+	for lang := range debuggers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// projectFiles tell a project's language, in order
+var projectFiles = []struct{ file, lang string }{
+	{"go.mod", "go"},
+	{"package.json", "node"},
+	{"pyproject.toml", "python"},
+	{"requirements.txt", "python"},
+	{"setup.py", "python"},
+}
+
+// Detect returns the debugger of the project in dir, from its go.mod,
+// package.json or Python project files
+func Detect(dir string) (*Debugger, error) {
+	for _, f := range projectFiles {
+		if _, err := os.Stat(filepath.Join(dir, f.file)); err == nil {
+			return debuggers[f.lang], nil
+		}
+	}
+	return nil, fmt.Errorf("couldn't tell the project's language: give one of %s", strings.Join(Languages(), ", "))
+}
+
+// Command returns the command that runs program under the debugger,
+// listening on port; an empty program is the language's default: the
+// main package, main.py or index.js. For Python, "-m module" runs a
+// module.
+func (d *Debugger) Command(program string, port int, args []string) []string {
+	if program == "" {
+		program = d.program
+	}
+	return d.command(program, port, args)
+}
+
+// VSCodeLaunch returns a launch.json configuration attaching to the
+// debugger forwarded to hostPort, with the sources mapped from the
+// workspace folder in the container
+func (d *Debugger) VSCodeLaunch(hostPort int, workspace string) string {
+	data, _ := json.MarshalIndent(d.launch(hostPort, workspace), "", "  ")
+	return string(data)
+}
+
+// Hint returns how other editors attach to the debugger on hostPort
+func (d *Debugger) Hint(hostPort int) string {
+	switch d.Lang {
+	case "go":
+		return fmt.Sprintf("GoLand: Run > Edit Configurations > Go Remote, host 127.0.0.1, port %d\n"+
+			"Neovim (nvim-dap): { type = 'go', request = 'attach', mode = 'remote', port = %d }", hostPort, hostPort)
+	case "python":
+		return fmt.Sprintf("Neovim (nvim-dap): { type = 'python', request = 'attach', connect = { host = '127.0.0.1', port = %d } }", hostPort)
+	case "node":
+		return fmt.Sprintf("Chrome: open chrome://inspect and add 127.0.0.1:%d\n"+
+			"WebStorm: Run > Edit Configurations > Attach to Node.js/Chrome, port %d", hostPort, hostPort)
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package debugger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		lang    string
+		program string
+		args    []string
+		want    []string
+	}{
+		{"go", "", nil, []string{"sh", "-c", `PATH="$PATH:$(go env GOPATH)/bin" exec dlv "$@"`, "dlv",
+			"debug", ".", "--headless", "--listen=:2345", "--api-version=2", "--accept-multiclient"}},
+		{"golang", "./cmd/api", []string{"-v"}, []string{"sh", "-c", `PATH="$PATH:$(go env GOPATH)/bin" exec dlv "$@"`, "dlv",
+			"debug", "./cmd/api", "--headless", "--listen=:2345", "--api-version=2", "--accept-multiclient", "--", "-v"}},
+		{"python", "app.py", []string{"--debug"}, []string{"python3", "-m", "debugpy", "--listen", "0.0.0.0:5678", "--wait-for-client", "app.py", "--debug"}},
+		{"py", "-m flask", []string{"run"}, []string{"python3", "-m", "debugpy", "--listen", "0.0.0.0:5678", "--wait-for-client", "-m", "flask", "run"}},
+		{"ts", "", nil, []string{"node", "--inspect-brk=0.0.0.0:9229", "index.js"}},
+	}
+	for _, tt := range tests {
+		d, err := Get(tt.lang)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", tt.lang, err)
+		}
+		if got := d.Command(tt.program, d.Port, tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Command() = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+
+	if _, err := Get("cobol"); err == nil {
+		t.Error("Get should fail for a language without a debugger")
+	}
+}
+
+func TestVSCodeLaunch(t *testing.T) {
+	for _, lang := range Languages() {
+		d, _ := Get(lang)
+		var launch map[string]interface{}
+		if err := json.Unmarshal([]byte(d.VSCodeLaunch(12345, "/workspaces/api")), &launch); err != nil {
+			t.Fatalf("%s: invalid launch configuration: %v", lang, err)
+		}
+		if launch["request"] != "attach" {
+			t.Errorf("%s: request = %v, want attach", lang, launch["request"])
+		}
+		data, _ := json.Marshal(launch)
+		for _, want := range []string{"12345", "/workspaces/api"} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: launch configuration lacks %s: %s", lang, want, data)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Detect(dir); err == nil {
+		t.Error("Detect should fail for an empty project")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "requirements.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := Detect(dir); err != nil || d.Lang != "python" {
+		t.Errorf("Detect() = %v, %v, want python", d, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := Detect(dir); err != nil || d.Lang != "go" {
+		t.Errorf("Detect() = %v, %v, want go", d, err)
+	}
+}
@@ -16,6 +16,7 @@ type ProjectType struct {
 	Priority    int
 	Description string
 	Template    string // Suggested template name
+	Version     string // Language version Image is pinned to, e.g. "go 1.22"
 }
 
 // DetectedProject contains detection results
@@ -35,34 +36,39 @@ var detectionRules = []struct {
 	Description string
 	Template    string // Suggested template name
 }{
+	// === Environment Managers and Build Systems (they provide the toolchain) ===
+	{[]string{"devbox.json"}, "Devbox", "jetpackio/devbox:latest", 1, "Devbox project", "devbox"},
+	{[]string{"flake.nix", "shell.nix"}, "Nix", "nixos/nix:latest", 2, "Nix project", "nix-flakes"},
+	{[]string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel"}, "Bazel", "gcr.io/bazel-public/bazel:latest", 3, "Bazel workspace", "bazel"},
+
 	// === Python Complex Environments (highest priority for specific tools) ===
-	{[]string{"environment.yml", "environment.yaml"}, "Python (Conda)", "mcr.microsoft.com/devcontainers/miniconda:3", 1, "Conda environment", "miniconda"},
-	{[]string{"poetry.lock"}, "Python (Poetry)", "mcr.microsoft.com/devcontainers/python:3.11", 2, "Poetry project", "python-poetry"},
-	{[]string{"Pipfile.lock"}, "Python (Pipenv)", "mcr.microsoft.com/devcontainers/python:3.11", 3, "Pipenv project", "python-pipenv"},
+	{[]string{"environment.yml", "environment.yaml"}, "Python (Conda)", "mcr.microsoft.com/devcontainers/miniconda:3", 4, "Conda environment", "miniconda"},
+	{[]string{"poetry.lock"}, "Python (Poetry)", "mcr.microsoft.com/devcontainers/python:3.11", 5, "Poetry project", "python-poetry"},
+	{[]string{"Pipfile.lock"}, "Python (Pipenv)", "mcr.microsoft.com/devcontainers/python:3.11", 6, "Pipenv project", "python-pipenv"},
 
 	// === C/C++ Complex Build Systems ===
-	{[]string{"conanfile.txt", "conanfile.py"}, "C++ (Conan)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 4, "C++ Conan project", "cpp-conan"},
-	{[]string{"vcpkg.json"}, "C++ (Vcpkg)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 5, "C++ Vcpkg project", "cpp-vcpkg"},
-	{[]string{"CMakeLists.txt"}, "C++ (CMake)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 6, "CMake project", "cpp-cmake"},
+	{[]string{"conanfile.txt", "conanfile.py"}, "C++ (Conan)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 7, "C++ Conan project", "cpp-conan"},
+	{[]string{"vcpkg.json"}, "C++ (Vcpkg)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 8, "C++ Vcpkg project", "cpp-vcpkg"},
+	{[]string{"CMakeLists.txt"}, "C++ (CMake)", "mcr.microsoft.com/devcontainers/cpp:ubuntu", 9, "CMake project", "cpp-cmake"},
 
 	// === Java Build Systems ===
-	{[]string{"pom.xml"}, "Java (Maven)", "mcr.microsoft.com/devcontainers/java:17", 7, "Maven project", "java-maven"},
-	{[]string{"build.gradle", "build.gradle.kts"}, "Java (Gradle)", "mcr.microsoft.com/devcontainers/java:17", 8, "Gradle project", "java-gradle"},
+	{[]string{"pom.xml"}, "Java (Maven)", "mcr.microsoft.com/devcontainers/java:17", 10, "Maven project", "java-maven"},
+	{[]string{"build.gradle", "build.gradle.kts"}, "Java (Gradle)", "mcr.microsoft.com/devcontainers/java:17", 11, "Gradle project", "java-gradle"},
 
 	// === Standard Language Detection ===
-	{[]string{"go.mod", "go.sum"}, "Go", "golang:1.21-alpine", 10, "Go project", "go-basic"},
-	{[]string{"package.json"}, "Node.js", "node:20-alpine", 11, "Node.js project", "node-basic"},
-	{[]string{"requirements.txt", "pyproject.toml", "setup.py", "Pipfile"}, "Python", "python:3.11-slim", 12, "Python project", "python-basic"},
-	{[]string{"Cargo.toml"}, "Rust", "rust:alpine", 13, "Rust project", "rust-basic"},
-	{[]string{"*.csproj", "*.sln"}, ".NET", "mcr.microsoft.com/dotnet/sdk:8.0", 14, ".NET project", "dotnet"},
-	{[]string{"composer.json"}, "PHP", "php:8.2-cli", 15, "PHP project", "php-composer"},
-	{[]string{"Gemfile"}, "Ruby", "ruby:3.2-slim", 16, "Ruby project", "ruby-basic"},
+	{[]string{"go.mod", "go.sum"}, "Go", "golang:1.21-alpine", 13, "Go project", "go-basic"},
+	{[]string{"package.json"}, "Node.js", "node:20-alpine", 14, "Node.js project", "node-basic"},
+	{[]string{"requirements.txt", "pyproject.toml", "setup.py", "Pipfile"}, "Python", "python:3.11-slim", 15, "Python project", "python-basic"},
+	{[]string{"Cargo.toml"}, "Rust", "rust:alpine", 16, "Rust project", "rust-basic"},
+	{[]string{"*.csproj", "*.sln"}, ".NET", "mcr.microsoft.com/dotnet/sdk:8.0", 17, ".NET project", "dotnet"},
+	{[]string{"composer.json"}, "PHP", "php:8.2-cli", 18, "PHP project", "php-composer"},
+	{[]string{"Gemfile"}, "Ruby", "ruby:3.2-slim", 19, "Ruby project", "ruby-basic"},
 }
 
 // DetectProjectType scans the current directory for project indicators
 func DetectProjectType(dir string) *DetectedProject {
 	result := &DetectedProject{
-		Types: []ProjectType{},
+		Types: ruleProjectTypes(dir),
 	}
 
 	// Check each detection rule
@@ -98,6 +104,13 @@ func DetectProjectType(dir string) *DetectedProject {
 		})
 	}
 
+	// Pin language images to the versions the project asks for
+	d := NewDetector(dir)
+	d.detectVersions()
+	for i := range result.Types {
+		result.Types[i].Image, result.Types[i].Version = pinImage(result.Types[i].Image, d.info.Versions)
+	}
+
 	// Sort by priority and set primary
 	if len(result.Types) > 0 {
 		result.HasMultiple = len(result.Types) > 1
@@ -47,6 +47,10 @@ type ProjectInfo struct {
 
 	// Files in root
 	RootFiles []string `json:"rootFiles,omitempty"`
+
+	// User detection rules and plugins
+	Template     string   `json:"template,omitempty"`     // Template a rule or plugin asks for
+	MatchedRules []string `json:"matchedRules,omitempty"` // Rules and plugins that matched
 }
 
 // LanguageInfo holds information about a detected language
@@ -111,6 +115,12 @@ func (d *Detector) Detect() (*ProjectInfo, error) {
 	// Layer 6: Existing config detection
 	d.detectExistingConfigs()
 
+	// Layer 7: User rules and plugins
+	d.applyRules()
+	if err := d.runPlugins(); err != nil {
+		return nil, err
+	}
+
 	// Set primary language
 	d.setPrimaryLanguage()
 
@@ -453,15 +463,6 @@ func (d *Detector) analyzeGoMod() {
 	}
 
 	content := string(data)
-	lines := strings.Split(content, "\n")
-
-	// Extract Go version
-	for _, line := range lines {
-		if strings.HasPrefix(line, "go ") {
-			d.info.Versions["go"] = strings.TrimSpace(strings.TrimPrefix(line, "go "))
-			break
-		}
-	}
 
 	// Framework detection
 	frameworks := map[string]string{
@@ -522,6 +523,9 @@ func (d *Detector) analyzeCargoToml() {
 
 // detectVersions detects specific language versions
 func (d *Detector) detectVersions() {
+	// Manifests first; version manager files below take precedence
+	d.detectManifestVersions()
+
 	// Node.js version
 	if data, err := os.ReadFile(filepath.Join(d.projectDir, ".nvmrc")); err == nil {
 		d.info.Versions["node"] = strings.TrimSpace(string(data))
@@ -660,12 +664,47 @@ func (d *Detector) detectServices() {
 	}
 }
 
+// DetectServices lists the services of a monorepo or multi-root project:
+// each directory under apps/, packages/, services/, libs/ or modules/ with
+// a recognizable language, with its suggested template. Unlike Detect it
+// does not require monorepo tooling such as turbo.json to be present.
+func DetectServices(projectDir string) ([]ServiceInfo, error) {
+	info, err := NewDetector(projectDir).Detect()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsMonorepo {
+		return info.Services, nil
+	}
+	d := NewDetector(projectDir)
+	d.detectServices()
+	return d.info.Services, nil
+}
+
 // detectExistingConfigs checks for existing configuration files
 func (d *Detector) detectExistingConfigs() {
 	d.info.HasDockerfile = d.fileExists("Dockerfile")
 	d.info.HasDockerCompose = d.fileExists("docker-compose.yml") || d.fileExists("docker-compose.yaml")
 	d.info.HasDevcontainer = d.fileExists(".devcontainer/devcontainer.json") || d.fileExists("devcontainer.json")
 	d.info.HasMakefile = d.fileExists("Makefile")
+
+	// Build systems and environment managers that provide the toolchain
+	tools := []struct {
+		name  string
+		files []string
+	}{
+		{"devbox", []string{"devbox.json"}},
+		{"nix", []string{"flake.nix", "shell.nix"}},
+		{"bazel", []string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel"}},
+	}
+	for _, tool := range tools {
+		for _, file := range tool.files {
+			if d.fileExists(file) {
+				d.info.BuildTools = append(d.info.BuildTools, tool.name)
+				break
+			}
+		}
+	}
 }
 
 // setPrimaryLanguage determines the primary language
@@ -711,6 +750,20 @@ func (d *Detector) fileExists(pattern string) bool {
 
 // suggestTemplate suggests a template based on project info
 func suggestTemplate(info *ProjectInfo) string {
+	if info.Template != "" {
+		return info.Template
+	}
+
+	// Environment managers and Bazel provide the whole toolchain
+	switch {
+	case containsAny(info.BuildTools, "devbox"):
+		return "devbox"
+	case containsAny(info.BuildTools, "nix"):
+		return "nix-flakes"
+	case containsAny(info.BuildTools, "bazel"):
+		return "bazel"
+	}
+
 	// GPU templates
 	if info.NeedsGPU {
 		for _, fw := range info.GPUFrameworks {
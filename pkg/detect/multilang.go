@@ -263,29 +263,52 @@ func normalizeLangName(name string) string {
 	}
 }
 
+// frameworkPorts are the ports frameworks serve on by default
+var frameworkPorts = map[string][]int{
+	"Next.js":   {3000},
+	"React":     {3000},
+	"Vue":       {5173, 8080},
+	"Nuxt":      {3000},
+	"Angular":   {4200},
+	"Svelte":    {5173},
+	"SvelteKit": {5173},
+	"Astro":     {4321},
+	"Remix":     {3000},
+	"Gatsby":    {8000},
+	"Django":    {8000},
+	"FastAPI":   {8000},
+	"Flask":     {5000},
+	"Streamlit": {8501},
+	"Gradio":    {7860},
+	"Express":   {3000},
+	"NestJS":    {3000},
+	"Fastify":   {3000},
+	"Koa":       {3000},
+	"Gin":       {8080},
+	"Echo":      {8080},
+	"Fiber":     {3000},
+	"Chi":       {8080},
+	"Spring":    {8080},
+	"Actix":     {8080},
+	"Axum":      {3000},
+	"Rocket":    {8000},
+}
+
+// portsOf returns a framework's default ports, from detection rules or
+// frameworkPorts
+func portsOf(framework string) []int {
+	if ports := rulePorts(framework); len(ports) > 0 {
+		return ports
+	}
+	return frameworkPorts[framework]
+}
+
 func detectPorts(info *ProjectInfo) []int {
 	var ports []int
 	portSet := make(map[int]bool)
 
-	// Framework-specific ports
-	frameworkPorts := map[string][]int{
-		"Next.js": {3000},
-		"React":   {3000},
-		"Vue":     {5173, 8080},
-		"Angular": {4200},
-		"Django":  {8000},
-		"FastAPI": {8000},
-		"Flask":   {5000},
-		"Express": {3000},
-		"NestJS":  {3000},
-		"Gin":     {8080},
-		"Echo":    {8080},
-		"Fiber":   {3000},
-		"Spring":  {8080},
-	}
-
 	for _, fw := range info.Frameworks {
-		if fwPorts, ok := frameworkPorts[fw]; ok {
+		if fwPorts := portsOf(fw); len(fwPorts) > 0 {
 			for _, p := range fwPorts {
 				if !portSet[p] {
 					ports = append(ports, p)
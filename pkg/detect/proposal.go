@@ -0,0 +1,355 @@
+package detect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// Proposal is a devcontainer.json generated from detection results, with the
+// reason for each setting, meant to be reviewed before it is written
+type Proposal struct {
+	Config  map[string]interface{}
+	Reasons map[string]string // Setting -> why it was proposed
+}
+
+// Propose builds a devcontainer.json for a project: the image of its primary
+// language (at the detected version), features for the other languages and
+// for Docker, forwardPorts from framework defaults, a postCreateCommand that
+// installs dependencies with the detected package manager, and GPU runArgs
+func Propose(dir string) (*Proposal, error) {
+	result := DetectProjectType(dir)
+	info, err := NewDetector(dir).Detect()
+	if err != nil {
+		return nil, err
+	}
+	if result.Primary == nil && !info.NeedsGPU {
+		return nil, fmt.Errorf("no project type detected in %s", dir)
+	}
+
+	p := &Proposal{
+		Config:  map[string]interface{}{"name": info.Name},
+		Reasons: map[string]string{"name": "project directory"},
+	}
+
+	// The image provides the primary language; the rest come as features
+	covered := ""
+	if result.Primary != nil {
+		reason := result.Primary.DetectedBy + " found"
+		if result.Primary.Version != "" {
+			reason += ", " + result.Primary.Version
+		}
+		p.set("image", result.Primary.Image, reason)
+		covered = normalizeLangName(imageLanguage(result.Primary.Language))
+	}
+
+	if info.NeedsGPU {
+		runArgs := []string{"--gpus", "all"}
+		reason := "GPU dependencies found"
+		if len(info.GPUFrameworks) > 0 {
+			reason = "GPU dependencies: " + strings.Join(info.GPUFrameworks, ", ")
+		}
+		for _, fw := range info.GPUFrameworks {
+			if gpu, ok := GPUFeatures[fw]; ok {
+				p.set("image", gpu.Image, fw+" image with CUDA")
+				runArgs = gpu.RunArgs
+				covered = "Python"
+				break
+			}
+		}
+		if _, ok := p.Config["image"]; !ok {
+			p.set("image", "nvidia/cuda:12.1.0-cudnn8-devel-ubuntu22.04", "CUDA base image")
+		}
+		p.set("runArgs", runArgs, reason)
+	}
+
+	features := make(map[string]interface{})
+	var featureReasons []string
+	languages := append([]LanguageInfo(nil), info.Languages...)
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Name < languages[j].Name })
+	nixManaged := containsAny(info.BuildTools, "nix", "devbox") // Nix provides the toolchains
+	for _, lang := range languages {
+		name := normalizeLangName(lang.Name)
+		if nixManaged || versionKey(name) == versionKey(covered) || name == "C++" {
+			continue
+		}
+		feat, ok := LanguageFeatures[name]
+		if !ok {
+			continue
+		}
+		if _, seen := features[feat.Feature]; seen {
+			continue
+		}
+		options := make(map[string]interface{})
+		for k, v := range feat.Config {
+			options[k] = v
+		}
+		if version := strings.TrimPrefix(info.Versions[versionKey(name)], "v"); version != "" {
+			options["version"] = version
+		}
+		features[feat.Feature] = options
+		featureReasons = append(featureReasons, fmt.Sprintf("%s (%s)", lang.Name, strings.Join(lang.Indicators, ", ")))
+	}
+	if info.HasDockerfile || info.HasDockerCompose {
+		features["ghcr.io/devcontainers/features/docker-in-docker:2"] = map[string]interface{}{}
+		featureReasons = append(featureReasons, "Docker (Dockerfile or docker-compose.yml)")
+	}
+	if len(features) > 0 {
+		p.set("features", features, strings.Join(featureReasons, ", "))
+	}
+
+	if containsAny(info.BuildTools, "nix") && !containsAny(info.BuildTools, "devbox") {
+		p.set("containerEnv", map[string]string{"NIX_CONFIG": "experimental-features = nix-command flakes"}, "flake.nix or shell.nix found")
+	}
+
+	if ports := detectPorts(info); len(ports) > 0 {
+		sort.Ints(ports)
+		var portReasons []string
+		for _, port := range ports {
+			var by []string
+			for _, fw := range info.Frameworks {
+				for _, fp := range portsOf(fw) {
+					if fp == port && !contains(by, fw) {
+						by = append(by, fw)
+					}
+				}
+			}
+			portReasons = append(portReasons, fmt.Sprintf("%d (%s)", port, strings.Join(by, ", ")))
+		}
+		p.set("forwardPorts", ports, "framework defaults: "+strings.Join(portReasons, ", "))
+	}
+
+	if commands, sources := installCommands(dir, info); len(commands) > 0 {
+		p.set("postCreateCommand", strings.Join(commands, " && "), strings.Join(sources, ", ")+" found")
+	}
+
+	// Normalize to what decoding the written file yields, so proposals compare
+	// cleanly with existing configs
+	data, err := json.Marshal(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	p.Config = nil
+	if err := json.Unmarshal(data, &p.Config); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Proposal) set(key string, value interface{}, reason string) {
+	p.Config[key] = value
+	p.Reasons[key] = reason
+}
+
+// imageLanguage maps a detection rule's language to a LanguageFeatures key
+func imageLanguage(language string) string {
+	switch {
+	case strings.HasPrefix(language, "Node.js"):
+		return "JavaScript"
+	case strings.HasPrefix(language, "Python"):
+		return "Python"
+	case strings.HasPrefix(language, "Java"):
+		return "Java"
+	case strings.HasPrefix(language, "C++"), strings.HasPrefix(language, "C/C++"):
+		return "C++"
+	default:
+		return language
+	}
+}
+
+// versionKey maps a LanguageFeatures key to its ProjectInfo.Versions key
+func versionKey(language string) string {
+	switch language {
+	case "JavaScript", "TypeScript":
+		return "node"
+	default:
+		return strings.ToLower(language)
+	}
+}
+
+// installCommands returns the commands that install a project's dependencies
+// with the package managers its lock and manifest files point to, and the
+// files that led to them
+func installCommands(dir string, info *ProjectInfo) ([]string, []string) {
+	exists := func(name string) bool {
+		matches, _ := filepath.Glob(filepath.Join(dir, name))
+		return len(matches) > 0
+	}
+	var commands, sources []string
+	add := func(source, command string) {
+		sources = append(sources, source)
+		commands = append(commands, command)
+	}
+
+	// Language tools live in the Nix environment, which installs the rest
+	switch {
+	case exists("devbox.json"):
+		add("devbox.json", "devbox install")
+		return commands, sources
+	case exists("flake.nix"):
+		add("flake.nix", "nix develop --command true")
+		return commands, sources
+	case exists("shell.nix"):
+		add("shell.nix", "nix-shell --run true")
+		return commands, sources
+	}
+
+	switch {
+	case exists("pnpm-lock.yaml"):
+		add("pnpm-lock.yaml", "corepack enable && pnpm install")
+	case exists("yarn.lock"):
+		add("yarn.lock", "yarn install")
+	case exists("package-lock.json"):
+		add("package-lock.json", "npm ci")
+	case exists("package.json"):
+		add("package.json", "npm install")
+	}
+
+	switch {
+	case exists("poetry.lock") || containsAny(info.PackageManagers, "poetry"):
+		add("Poetry project", "pip install poetry && poetry install")
+	case containsAny(info.PackageManagers, "pdm"):
+		add("PDM project", "pip install pdm && pdm install")
+	case exists("Pipfile"):
+		add("Pipfile", "pip install pipenv && pipenv install --dev")
+	case exists("requirements.txt"):
+		add("requirements.txt", "pip install -r requirements.txt")
+	case exists("pyproject.toml"):
+		add("pyproject.toml", "pip install -e .")
+	}
+
+	if exists("go.mod") {
+		add("go.mod", "go mod download")
+	}
+	if exists("Cargo.toml") {
+		add("Cargo.toml", "cargo fetch")
+	}
+	if exists("Gemfile") {
+		add("Gemfile", "bundle install")
+	}
+	if exists("composer.json") {
+		add("composer.json", "composer install")
+	}
+	if exists("*.sln") || exists("*.csproj") {
+		add(".NET project", "dotnet restore")
+	}
+	return commands, sources
+}
+
+// Merge returns existing with the proposal applied: proposed settings
+// replace existing ones, except features, which are added to those present
+func (p *Proposal) Merge(existing map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(existing)+len(p.Config))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range p.Config {
+		if k == "features" {
+			if current, ok := existing[k].(map[string]interface{}); ok {
+				features := make(map[string]interface{})
+				for ref, opts := range current {
+					features[ref] = opts
+				}
+				for ref, opts := range v.(map[string]interface{}) {
+					if _, ok := features[ref]; !ok {
+						features[ref] = opts
+					}
+				}
+				v = features
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// Format renders the proposal as a diff preview against existing (nil when
+// there is no devcontainer.json yet), one setting per line with its reason
+func (p *Proposal) Format(existing map[string]interface{}) string {
+	merged := p.Merge(existing)
+	keys := make([]string, 0, len(p.Config))
+	for k := range p.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		old, had := existing[k]
+		next := formatSetting(merged[k])
+		switch {
+		case !had:
+			sb.WriteString(fmt.Sprintf("  + %s: %s\n", k, next))
+		case formatSetting(old) == next:
+			continue
+		default:
+			sb.WriteString(fmt.Sprintf("  ~ %s: %s → %s\n", k, formatSetting(old), next))
+		}
+		sb.WriteString(fmt.Sprintf("      # %s\n", p.Reasons[k]))
+	}
+	return sb.String()
+}
+
+// Changes reports whether applying the proposal would change existing
+func (p *Proposal) Changes(existing map[string]interface{}) bool {
+	return p.Format(existing) != ""
+}
+
+// Write saves the proposal, merged into existing, as .devcontainer/devcontainer.json
+func (p *Proposal) Write(dir string, existing map[string]interface{}) error {
+	devcontainerDir := filepath.Join(dir, ".devcontainer")
+	if err := os.MkdirAll(devcontainerDir, 0755); err != nil {
+		return err
+	}
+	data, err := marshalJSON(p.Merge(existing), "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.json"), data, 0644)
+}
+
+// ReadDevcontainer returns a project's .devcontainer/devcontainer.json as a
+// map, or nil when it has none
+func ReadDevcontainer(dir string) (map[string]interface{}, error) {
+	path := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	std, err := hujson.Standardize(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(std, &config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func formatSetting(v interface{}) string {
+	data, _ := marshalJSON(v, "")
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// marshalJSON encodes without escaping &, < and >, which are common in
+// shell commands
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,306 @@
+package detect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a user-defined detection rule, for in-house frameworks and
+// toolchains the built-in detection does not know. Rules are read from
+// ~/.cm/detect.d/*.yaml or registered from Go with RegisterRule.
+//
+//	rules:
+//	  - name: acme-web
+//	    files: [acme.toml]
+//	    contains:
+//	      package.json: '"@acme/web"'
+//	    language: TypeScript
+//	    framework: Acme Web
+//	    ports: [4000]
+//	    template: acme-node
+//	    image: registry.acme.dev/devcontainers/node:20
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// A rule matches when one of Files (globs) exists, if any are given, and
+	// the content of every file in Contains matches its regular expression
+	Files    []string          `yaml:"files,omitempty"`
+	Contains map[string]string `yaml:"contains,omitempty"`
+
+	// What a match adds to the detection results
+	Language  string  `yaml:"language,omitempty"`
+	Weight    float64 `yaml:"weight,omitempty"` // Language confidence added, default 0.9
+	Framework string  `yaml:"framework,omitempty"`
+	Ports     []int   `yaml:"ports,omitempty"`    // Default ports of Framework
+	Template  string  `yaml:"template,omitempty"` // Recommended ahead of built-in templates
+	Image     string  `yaml:"image,omitempty"`    // Suggested ahead of built-in images
+
+	patterns map[string]*regexp.Regexp
+}
+
+// Plugin extends detection from Go, for programs that embed the detector.
+// Detect runs after the built-in layers and the rules, and may add
+// languages, frameworks, versions or a template to info.
+type Plugin interface {
+	Name() string
+	Detect(dir string, info *ProjectInfo) error
+}
+
+var (
+	registryMu sync.RWMutex
+	extraRules []Rule
+	plugins    []Plugin
+
+	userRulesOnce sync.Once
+	userRules     []Rule
+)
+
+// RegisterRule adds a detection rule
+func RegisterRule(r Rule) error {
+	if err := r.compile(); err != nil {
+		return err
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	extraRules = append(extraRules, r)
+	return nil
+}
+
+// RegisterPlugin adds a detection plugin
+func RegisterPlugin(p Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	plugins = append(plugins, p)
+}
+
+// RulesDir returns the directory user rules are read from
+func RulesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "detect.d"), nil
+}
+
+// LoadRules reads the *.yaml and *.yml rule files of dir, in name order
+func LoadRules(dir string) ([]Rule, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var rules []Rule
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Rules []Rule `yaml:"rules"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, r := range file.Rules {
+			if err := r.compile(); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// activeRules returns the user rules, loaded once, then the registered ones.
+// A broken rules directory is reported and ignored rather than failing
+// detection.
+func activeRules() []Rule {
+	userRulesOnce.Do(func() {
+		dir, err := RulesDir()
+		if err != nil {
+			return
+		}
+		rules, err := LoadRules(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Ignoring detection rules: %v\n", err)
+			return
+		}
+		userRules = rules
+	})
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append(append([]Rule(nil), userRules...), extraRules...)
+}
+
+func registeredPlugins() []Plugin {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Plugin(nil), plugins...)
+}
+
+// compile validates the rule and prepares its patterns
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("detection rule without a name")
+	}
+	if len(r.Files) == 0 && len(r.Contains) == 0 {
+		return fmt.Errorf("rule %s: needs files or contains", r.Name)
+	}
+	if r.Language == "" && r.Framework == "" && r.Template == "" && r.Image == "" {
+		return fmt.Errorf("rule %s: needs a language, framework, template or image", r.Name)
+	}
+	if len(r.Ports) > 0 && r.Framework == "" {
+		return fmt.Errorf("rule %s: ports need a framework", r.Name)
+	}
+	r.patterns = make(map[string]*regexp.Regexp, len(r.Contains))
+	for file, expr := range r.Contains {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("rule %s: invalid pattern for %s: %w", r.Name, file, err)
+		}
+		r.patterns[file] = re
+	}
+	return nil
+}
+
+// match reports whether the rule matches the project in dir, and the file
+// that made it match
+func (r *Rule) match(dir string) (string, bool) {
+	indicator := ""
+	if len(r.Files) > 0 {
+		for _, pattern := range r.Files {
+			if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+				indicator = filepath.Base(matches[0])
+				break
+			}
+		}
+		if indicator == "" {
+			return "", false
+		}
+	}
+
+	files := make([]string, 0, len(r.patterns))
+	for file := range r.patterns {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || !r.patterns[file].Match(data) {
+			return "", false
+		}
+		if indicator == "" {
+			indicator = file
+		}
+	}
+	return indicator, true
+}
+
+// applyRules adds what matching rules contribute to the detection results
+func (d *Detector) applyRules() {
+	for _, r := range activeRules() {
+		indicator, ok := r.match(d.projectDir)
+		if !ok {
+			continue
+		}
+		d.info.MatchedRules = append(d.info.MatchedRules, r.Name)
+
+		if r.Language != "" {
+			weight := r.Weight
+			if weight == 0 {
+				weight = 0.9
+			}
+			d.addLanguage(r.Language, weight, indicator)
+		}
+		if r.Framework != "" && !contains(d.info.Frameworks, r.Framework) {
+			d.info.Frameworks = append(d.info.Frameworks, r.Framework)
+		}
+		if r.Template != "" && d.info.Template == "" {
+			d.info.Template = r.Template
+		}
+	}
+}
+
+// addLanguage raises the confidence of a language, adding it if new
+func (d *Detector) addLanguage(name string, weight float64, indicator string) {
+	for i := range d.info.Languages {
+		lang := &d.info.Languages[i]
+		if lang.Name == name {
+			lang.Confidence = minFloat(lang.Confidence+weight, 1)
+			lang.Indicators = append(lang.Indicators, indicator)
+			return
+		}
+	}
+	d.info.Languages = append(d.info.Languages, LanguageInfo{
+		Name:       name,
+		Confidence: minFloat(weight, 1),
+		Indicators: []string{indicator},
+	})
+}
+
+// runPlugins lets registered plugins add to the detection results
+func (d *Detector) runPlugins() error {
+	for _, p := range registeredPlugins() {
+		if err := p.Detect(d.projectDir, d.info); err != nil {
+			return fmt.Errorf("detection plugin %s: %w", p.Name(), err)
+		}
+		d.info.MatchedRules = append(d.info.MatchedRules, p.Name())
+	}
+	return nil
+}
+
+// ruleProjectTypes returns the project types of rules with an image that
+// match dir. They rank ahead of the built-in rules.
+func ruleProjectTypes(dir string) []ProjectType {
+	var types []ProjectType
+	for _, r := range activeRules() {
+		if r.Image == "" {
+			continue
+		}
+		indicator, ok := r.match(dir)
+		if !ok {
+			continue
+		}
+		language := r.Language
+		if language == "" {
+			language = r.Name
+		}
+		types = append(types, ProjectType{
+			Name:        r.Name,
+			Language:    language,
+			Image:       r.Image,
+			DetectedBy:  indicator,
+			Priority:    0,
+			Description: "Detection rule " + r.Name,
+			Template:    r.Template,
+		})
+	}
+	return types
+}
+
+// rulePorts returns the ports rules give a framework
+func rulePorts(framework string) []int {
+	for _, r := range activeRules() {
+		if strings.EqualFold(r.Framework, framework) && len(r.Ports) > 0 {
+			return r.Ports
+		}
+	}
+	return nil
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
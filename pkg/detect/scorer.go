@@ -16,6 +16,7 @@ type TemplateDefinition struct {
 	Languages   []string
 	Frameworks  []string
 	Keywords    []string
+	BuildTools  []string
 	RequiresGPU bool
 	Weight      float64
 }
@@ -206,6 +207,27 @@ func (ts *TemplateScorer) registerTemplates() {
 		Weight:    1.5,
 	}
 
+	// Environment managers and build systems, which provide the toolchain
+	ts.templates["devbox"] = TemplateDefinition{
+		Name:       "devbox",
+		BuildTools: []string{"devbox"},
+		Weight:     2.5,
+	}
+
+	ts.templates["nix-flakes"] = TemplateDefinition{
+		Name:       "nix-flakes",
+		BuildTools: []string{"nix"},
+		Keywords:   []string{"flake", "nix"},
+		Weight:     2.0,
+	}
+
+	ts.templates["bazel"] = TemplateDefinition{
+		Name:       "bazel",
+		BuildTools: []string{"bazel"},
+		Keywords:   []string{"bazel", "rules_"},
+		Weight:     2.0,
+	}
+
 	// Default templates
 	ts.templates["python-basic"] = TemplateDefinition{
 		Name:      "python-basic",
@@ -238,6 +260,18 @@ func (ts *TemplateScorer) ScoreTemplates(info *ProjectInfo) []ScoredTemplate {
 		}
 	}
 
+	// A template named by a detection rule or plugin outranks scored ones
+	if info.Template != "" {
+		st, ok := scores[info.Template]
+		if !ok {
+			st = &ScoredTemplate{Name: info.Template}
+			scores[info.Template] = st
+		}
+		st.Score += 5.0
+		st.Reasons = append([]string{"Detection rule: " + strings.Join(info.MatchedRules, ", ")}, st.Reasons...)
+		st.MatchedBy = append(st.MatchedBy, "rule")
+	}
+
 	// Convert to slice and sort
 	var result []ScoredTemplate
 	for _, st := range scores {
@@ -289,6 +323,15 @@ func (ts *TemplateScorer) scoreTemplate(tmpl TemplateDefinition, info *ProjectIn
 		}
 	}
 
+	// Build tool matching (high value)
+	for _, bt := range tmpl.BuildTools {
+		if containsCI(info.BuildTools, bt) {
+			score += 1.0 * tmpl.Weight
+			reasons = append(reasons, "Build tool match: "+bt)
+			matchedBy = append(matchedBy, "buildtool:"+bt)
+		}
+	}
+
 	// Keyword matching in dependencies
 	for _, kw := range tmpl.Keywords {
 		for _, dep := range info.Dependencies {
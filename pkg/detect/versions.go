@@ -0,0 +1,149 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultVersions are the language versions of the default images. An
+// open-ended constraint such as ">=18" they satisfy keeps the default.
+var defaultVersions = map[string]string{
+	"node":   "20",
+	"python": "3.11",
+}
+
+// pinnedImages maps default images to the tag format of the same image at
+// another version, and the Versions key that selects it
+var pinnedImages = map[string]struct {
+	key, format string
+	parts       int // Version components the tags use, 0 for all
+}{
+	"golang:1.21-alpine": {"go", "golang:%s-alpine", 0},
+	"node:20-alpine":     {"node", "node:%s-alpine", 0},
+	"python:3.11-slim":   {"python", "python:%s-slim", 0},
+	"ruby:3.2-slim":      {"ruby", "ruby:%s-slim", 0},
+	"mcr.microsoft.com/devcontainers/python:3.11": {"python", "mcr.microsoft.com/devcontainers/python:%s", 2},
+	"mcr.microsoft.com/devcontainers/java:17":     {"java", "mcr.microsoft.com/devcontainers/java:%s", 1},
+}
+
+// detectManifestVersions reads language versions from project manifests:
+// the go.mod go and toolchain directives, package.json engines.node,
+// pyproject.toml requires-python and the pom.xml compiler release
+func (d *Detector) detectManifestVersions() {
+	if data, err := os.ReadFile(filepath.Join(d.projectDir, "go.mod")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			switch fields[0] {
+			case "go":
+				if _, ok := d.info.Versions["go"]; !ok {
+					d.info.Versions["go"] = fields[1]
+				}
+			case "toolchain":
+				// The toolchain directive names the Go release to build with
+				if version := strings.TrimPrefix(fields[1], "go"); version != "default" {
+					d.info.Versions["go"] = version
+				}
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(d.projectDir, "package.json")); err == nil {
+		var pkg struct {
+			Engines map[string]string `json:"engines"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			if version := constraintVersion(pkg.Engines["node"], 1, defaultVersions["node"]); version != "" {
+				d.info.Versions["node"] = version
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(d.projectDir, "pyproject.toml")); err == nil {
+		// PEP 621 requires-python, or Poetry's python dependency
+		re := regexp.MustCompile(`(?m)^\s*(?:requires-python|python)\s*=\s*["']([^"']+)["']`)
+		if m := re.FindStringSubmatch(string(data)); m != nil {
+			if version := constraintVersion(m[1], 2, defaultVersions["python"]); version != "" {
+				d.info.Versions["python"] = version
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(d.projectDir, "pom.xml")); err == nil {
+		content := string(data)
+		for _, tag := range []string{"maven.compiler.release", "release", "java.version", "maven.compiler.source"} {
+			re := regexp.MustCompile(`<` + regexp.QuoteMeta(tag) + `>\s*([\d.]+)\s*</`)
+			if m := re.FindStringSubmatch(content); m != nil {
+				// Java 8 and earlier are spelled 1.x
+				d.info.Versions["java"] = strings.TrimPrefix(m[1], "1.")
+				break
+			}
+		}
+	}
+}
+
+// constraintVersion resolves a version constraint (">=18", "^3.11",
+// ">=3.9,<4", "18.x") to a version with the given number of components: the
+// lower bound, or def when the constraint has no upper bound and def meets it
+func constraintVersion(constraint string, parts int, def string) string {
+	constraint = strings.TrimSpace(constraint)
+	re := regexp.MustCompile(`\d+(?:\.\d+)*`)
+	lower := re.FindString(constraint)
+	if lower == "" {
+		return ""
+	}
+	if components := strings.Split(lower, "."); len(components) > parts {
+		lower = strings.Join(components[:parts], ".")
+	}
+
+	openEnded := strings.HasPrefix(constraint, ">") && !strings.Contains(constraint, "<")
+	if openEnded && def != "" && compareVersions(def, lower) >= 0 {
+		return def
+	}
+	return lower
+}
+
+// compareVersions compares dotted numeric versions
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// pinImage returns image at the version the project asks for, when image is
+// an official language image, and that version
+func pinImage(image string, versions map[string]string) (string, string) {
+	pin, ok := pinnedImages[image]
+	if !ok {
+		return image, ""
+	}
+	version := strings.TrimPrefix(versions[pin.key], "v")
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return image, ""
+	}
+	if components := strings.Split(version, "."); pin.parts > 0 && len(components) > pin.parts {
+		version = strings.Join(components[:pin.parts], ".")
+	}
+	return fmt.Sprintf(pin.format, version), pin.key + " " + version
+}
@@ -0,0 +1,242 @@
+// Package devices resolves the host devices a dev container asks for with
+// its "devices" setting, such as "usb:serial" or "audio", into the device
+// mappings and cgroup rules of the backend, and re-attaches devices plugged
+// in while the container runs (see Monitor).
+package devices
+
+import (
+	"fmt"
+	"os/user"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+)
+
+// Class is a kind of device requested by name
+type Class struct {
+	// Paths are the host nodes or directories mapped when the container is
+	// created, as glob patterns
+	Paths []string
+	// Hotplug are the nodes re-attached when they appear later
+	Hotplug []string
+	// CgroupRules let the container use nodes of the class created after it
+	// started, as "c major:minor perms"
+	CgroupRules []string
+	// Groups are the host groups owning the nodes, which the container's
+	// processes join by ID
+	Groups []string
+}
+
+// Classes are the device classes known to the devices setting
+var Classes = map[string]Class{
+	"usb:serial": {
+		Paths:       []string{"/dev/ttyUSB*", "/dev/ttyACM*"},
+		Hotplug:     []string{"/dev/ttyUSB*", "/dev/ttyACM*"},
+		CgroupRules: []string{"c 188:* rmw", "c 166:* rmw"},
+	},
+	"usb": {
+		Paths:       []string{"/dev/bus/usb"},
+		Hotplug:     []string{"/dev/bus/usb/*/*"},
+		CgroupRules: []string{"c 189:* rmw"},
+	},
+	"audio": {
+		Paths:       []string{"/dev/snd"},
+		Hotplug:     []string{"/dev/snd/*"},
+		CgroupRules: []string{"c 116:* rmw"},
+	},
+	"video": {
+		Paths:       []string{"/dev/video*"},
+		Hotplug:     []string{"/dev/video*"},
+		CgroupRules: []string{"c 81:* rmw"},
+	},
+	// AMD GPUs through ROCm: the compute interface and the render nodes
+	"gpu:amd": {
+		Paths:       []string{"/dev/kfd", "/dev/dri"},
+		CgroupRules: []string{"c 226:* rmw"},
+		Groups:      []string{"video", "render"},
+	},
+	// Intel GPUs through oneAPI Level Zero: the render nodes
+	"gpu:intel": {
+		Paths:       []string{"/dev/dri"},
+		CgroupRules: []string{"c 226:* rmw"},
+		Groups:      []string{"video", "render"},
+	},
+}
+
+// aliases are other names of the classes
+var aliases = map[string]string{
+	"snd":    "audio",
+	"sound":  "audio",
+	"serial": "usb:serial",
+	"rocm":   "gpu:amd",
+	"oneapi": "gpu:intel",
+}
+
+// lookupGroup returns the ID of a host group
+var lookupGroup = func(name string) (string, bool) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", false
+	}
+	return g.Gid, true
+}
+
+// udevData is the udev database; tools enumerating devices through libudev
+// find their properties there
+const udevData = "/run/udev"
+
+// Mapping maps a host device into the container
+type Mapping struct {
+	Host      string
+	Container string
+}
+
+// Plan is how the backend gives the container its devices
+type Plan struct {
+	Devices     []Mapping
+	CgroupRules []string
+	Binds       []string
+	// Groups are the IDs of the host groups the container's processes join
+	Groups []string
+	// Hotplug are the patterns of the nodes the Monitor re-attaches
+	Hotplug []string
+	// Warnings are requested devices the container won't get
+	Warnings []string
+}
+
+// Empty reports whether the plan gives the container nothing
+func (p *Plan) Empty() bool {
+	return len(p.Devices) == 0 && len(p.CgroupRules) == 0 && len(p.Binds) == 0
+}
+
+// Resolve returns the plan for the devices setting of a container created
+// by backend ("docker", "podman", "nerdctl" or "wsl"). Entries are class
+// names or host paths, optionally followed by ":" and the path in the
+// container; paths may be glob patterns. Devices only exist when the engine
+// runs on this Linux host.
+func Resolve(specs []string, backend string) (*Plan, error) {
+	return resolve(specs, backend, goruntime.GOOS, filepath.Glob)
+}
+
+func resolve(specs []string, backend, goos string, glob func(string) ([]string, error)) (*Plan, error) {
+	plan := &Plan{}
+	if len(specs) == 0 {
+		return plan, nil
+	}
+
+	var classes []string
+	var paths []Mapping
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if name, ok := aliases[spec]; ok {
+			spec = name
+		}
+		if _, ok := Classes[spec]; ok {
+			classes = append(classes, spec)
+			continue
+		}
+		if !strings.HasPrefix(spec, "/") {
+			return nil, fmt.Errorf("unknown device %q (use a path or one of %s)", spec, strings.Join(Names(), ", "))
+		}
+		host, target, _ := strings.Cut(spec, ":")
+		paths = append(paths, Mapping{Host: host, Container: target})
+	}
+
+	switch {
+	case goos != "linux":
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("devices can't be passed through on %s: the engine runs in a VM that doesn't see them", goos))
+		return plan, nil
+	case backend == "wsl":
+		plan.Warnings = append(plan.Warnings, "devices can't be passed through to the WSL engine; attach USB devices to WSL with usbipd and run cm there")
+		return plan, nil
+	}
+
+	seen := map[string]bool{}
+	add := func(m Mapping) {
+		if m.Container == "" {
+			m.Container = m.Host
+		}
+		if !seen[m.Host] {
+			seen[m.Host] = true
+			plan.Devices = append(plan.Devices, m)
+		}
+	}
+
+	for _, name := range classes {
+		class := Classes[name]
+		found := false
+		for _, pattern := range class.Paths {
+			matches, _ := glob(pattern)
+			for _, match := range matches {
+				add(Mapping{Host: match})
+				found = true
+			}
+		}
+		// nerdctl has no device cgroup rules: only the devices present
+		// when the container is created are usable
+		if backend == "nerdctl" {
+			if !found {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("no %s device is plugged in; plug it in and recreate the container", name))
+			}
+			continue
+		}
+		// GPUs aren't hot-plugged: without their nodes the driver isn't loaded
+		if !found && strings.HasPrefix(name, "gpu:") {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("no %s device on the host; is its driver loaded?", name))
+			continue
+		}
+		plan.CgroupRules = append(plan.CgroupRules, class.CgroupRules...)
+		plan.Hotplug = append(plan.Hotplug, class.Hotplug...)
+	}
+
+	for _, name := range classes {
+		for _, group := range Classes[name].Groups {
+			if gid, ok := lookupGroup(group); ok && !containsString(plan.Groups, gid) {
+				plan.Groups = append(plan.Groups, gid)
+			}
+		}
+	}
+
+	for _, p := range paths {
+		matches, _ := glob(p.Host)
+		if len(matches) == 0 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s doesn't exist on the host", p.Host))
+			continue
+		}
+		for _, match := range matches {
+			if len(matches) > 1 || p.Container == "" {
+				// A pattern maps each device to the same path
+				add(Mapping{Host: match})
+			} else {
+				add(Mapping{Host: match, Container: p.Container})
+			}
+		}
+	}
+
+	if len(classes) > 0 {
+		if found, _ := glob(udevData); len(found) > 0 {
+			plan.Binds = append(plan.Binds, udevData+":"+udevData+":ro")
+		}
+	}
+	return plan, nil
+}
+
+// Names returns the names of the device classes
+func Names() []string {
+	names := make([]string, 0, len(Classes))
+	for name := range Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package devices
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hostGlob matches patterns against a fixed set of host paths
+func hostGlob(paths ...string) func(string) ([]string, error) {
+	return func(pattern string) ([]string, error) {
+		var matches []string
+		for _, p := range paths {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				matches = append(matches, p)
+			}
+		}
+		return matches, nil
+	}
+}
+
+func TestResolve(t *testing.T) {
+	glob := hostGlob("/dev/ttyUSB0", "/dev/ttyACM1", "/dev/snd", "/dev/video0", "/run/udev")
+
+	plan, err := resolve([]string{"usb:serial", "snd", "/dev/video0:/dev/camera", "/dev/ttyS9"}, "docker", "linux", glob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDevices := []Mapping{
+		{"/dev/ttyUSB0", "/dev/ttyUSB0"},
+		{"/dev/ttyACM1", "/dev/ttyACM1"},
+		{"/dev/snd", "/dev/snd"},
+		{"/dev/video0", "/dev/camera"},
+	}
+	if !reflect.DeepEqual(plan.Devices, wantDevices) {
+		t.Errorf("devices = %v, want %v", plan.Devices, wantDevices)
+	}
+	if got := strings.Join(plan.CgroupRules, ", "); got != "c 188:* rmw, c 166:* rmw, c 116:* rmw" {
+		t.Errorf("cgroup rules = %s", got)
+	}
+	if got := strings.Join(plan.Hotplug, " "); got != "/dev/ttyUSB* /dev/ttyACM* /dev/snd/*" {
+		t.Errorf("hotplug = %s", got)
+	}
+	if len(plan.Binds) != 1 || plan.Binds[0] != "/run/udev:/run/udev:ro" {
+		t.Errorf("binds = %v, want the udev database", plan.Binds)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "/dev/ttyS9") {
+		t.Errorf("warnings = %v, want the missing /dev/ttyS9", plan.Warnings)
+	}
+
+	// nerdctl only gets the devices plugged in
+	plan, err = resolve([]string{"usb:serial", "usb"}, "nerdctl", "linux", glob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Devices) != 2 || len(plan.CgroupRules) != 0 || len(plan.Hotplug) != 0 {
+		t.Errorf("nerdctl plan = %+v", plan)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "no usb device") {
+		t.Errorf("warnings = %v, want the missing usb devices", plan.Warnings)
+	}
+
+	for _, goos := range []string{"darwin", "windows"} {
+		plan, err = resolve([]string{"audio"}, "docker", goos, glob)
+		if err != nil || !plan.Empty() || len(plan.Warnings) != 1 {
+			t.Errorf("%s plan = %+v, %v; want only a warning", goos, plan, err)
+		}
+	}
+
+	if _, err := resolve([]string{"usb:jtag"}, "docker", "linux", glob); err == nil {
+		t.Error("expected an error for an unknown class")
+	}
+}
+
+func TestMonitorSync(t *testing.T) {
+	sysfs := t.TempDir()
+	plug := func(entry, devname string) {
+		dir := filepath.Join(sysfs, entry)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		uevent := "MAJOR=" + strings.Split(entry, ":")[0] + "\nDEVNAME=" + devname + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "uevent"), []byte(uevent), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var scripts, logs []string
+	m := NewMonitor("docker", "dev", []string{"/dev/ttyUSB*", "/dev/bus/usb/*/*"})
+	m.sysfs = sysfs
+	m.exec = func(_ context.Context, script string) error {
+		scripts = append(scripts, script)
+		return nil
+	}
+	m.Logf = func(format string, args ...interface{}) {
+		logs = append(logs, format)
+	}
+	sync := func() {
+		t.Helper()
+		if err := m.sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plug("188:0", "ttyUSB0")
+	plug("4:1", "tty1")
+	sync()
+	if len(scripts) != 1 || scripts[0] != "[ -e /dev/ttyUSB0 ] || { mkdir -p /dev && mknod -m 666 /dev/ttyUSB0 c 188 0; }" {
+		t.Fatalf("scripts = %q, want /dev/ttyUSB0 created", scripts)
+	}
+	if len(logs) != 0 {
+		t.Errorf("logs = %q, want none for the devices present at the start", logs)
+	}
+
+	sync()
+	if len(scripts) != 1 {
+		t.Errorf("scripts = %q, want nothing run without changes", scripts)
+	}
+
+	plug("189:5", "bus/usb/001/006")
+	if err := os.RemoveAll(filepath.Join(sysfs, "188:0")); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	want := "[ -e /dev/bus/usb/001/006 ] || { mkdir -p /dev/bus/usb/001 && mknod -m 666 /dev/bus/usb/001/006 c 189 5; }\n" +
+		"[ ! -c /dev/ttyUSB0 ] || rm -f /dev/ttyUSB0"
+	if len(scripts) != 2 || scripts[1] != want {
+		t.Errorf("scripts = %q, want the USB device attached and the serial one detached", scripts)
+	}
+	if len(logs) != 2 {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestResolveGPU(t *testing.T) {
+	defer func(orig func(string) (string, bool)) { lookupGroup = orig }(lookupGroup)
+	lookupGroup = func(name string) (string, bool) {
+		gids := map[string]string{"video": "44", "render": "109"}
+		gid, ok := gids[name]
+		return gid, ok
+	}
+
+	plan, err := resolve([]string{"rocm"}, "docker", "linux", hostGlob("/dev/kfd", "/dev/dri"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mapping{{"/dev/kfd", "/dev/kfd"}, {"/dev/dri", "/dev/dri"}}
+	if !reflect.DeepEqual(plan.Devices, want) {
+		t.Errorf("devices = %v, want %v", plan.Devices, want)
+	}
+	if got := strings.Join(plan.Groups, ","); got != "44,109" {
+		t.Errorf("groups = %s, want the video and render groups' IDs", got)
+	}
+
+	plan, err = resolve([]string{"gpu:intel"}, "docker", "linux", hostGlob())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Devices) != 0 || len(plan.CgroupRules) != 0 || len(plan.Warnings) != 1 {
+		t.Errorf("plan without a GPU = %+v, want only a warning", plan)
+	}
+}
@@ -0,0 +1,198 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sysDevChar lists the character devices of a Linux host, one entry per
+// "major:minor" whose uevent names the node under /dev
+const sysDevChar = "/sys/dev/char"
+
+// Node is a character device node
+type Node struct {
+	Path         string
+	Major, Minor string
+}
+
+// Monitor keeps the device nodes of a running container in step with the
+// host: a container only gets the nodes present when it starts, so nodes
+// matching the hotplug patterns are created in it when a device is plugged
+// in, and removed when it's unplugged. The plan's cgroup rules let the
+// container use them. It needs a Linux host.
+type Monitor struct {
+	Backend   string // CLI of the backend, e.g. "docker"
+	Container string
+	Patterns  []string
+	Interval  time.Duration
+	// Logf reports the nodes attached and detached, if set
+	Logf func(format string, args ...interface{})
+
+	sysfs string
+	exec  func(ctx context.Context, script string) error
+	known map[string]Node // by sysfs entry; Path is empty for other devices
+}
+
+// NewMonitor returns a monitor of the devices matching patterns for a
+// container
+func NewMonitor(backend, container string, patterns []string) *Monitor {
+	m := &Monitor{
+		Backend:   backend,
+		Container: container,
+		Patterns:  patterns,
+		Interval:  time.Second,
+		sysfs:     sysDevChar,
+	}
+	m.exec = m.execInContainer
+	return m
+}
+
+// Run attaches the matching devices already plugged in, then follows
+// hotplug events until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	if _, err := os.Stat(m.sysfs); err != nil {
+		return
+	}
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		err := m.sync(ctx)
+		switch {
+		case err == nil:
+			lastErr = ""
+		case ctx.Err() == nil && err.Error() != lastErr:
+			// A failure is retried every interval but reported once
+			lastErr = err.Error()
+			m.logf("failed to update devices: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync creates the nodes of new devices and removes those of devices gone
+// since the last call; the first call creates the nodes missing from the
+// container
+func (m *Monitor) sync(ctx context.Context) error {
+	entries, err := os.ReadDir(m.sysfs)
+	if err != nil {
+		return err
+	}
+	first := m.known == nil
+	if first {
+		m.known = map[string]Node{}
+	}
+
+	current := map[string]bool{}
+	var added, removed []Node
+	var addedEntries []string
+	for _, e := range entries {
+		current[e.Name()] = true
+		if _, ok := m.known[e.Name()]; ok {
+			continue
+		}
+		node := m.node(e.Name())
+		m.known[e.Name()] = node
+		if node.Path != "" {
+			added = append(added, node)
+			addedEntries = append(addedEntries, e.Name())
+		}
+	}
+	for name, node := range m.known {
+		if !current[name] {
+			delete(m.known, name)
+			if node.Path != "" {
+				removed = append(removed, node)
+			}
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Path < added[j].Path })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+
+	if err := m.exec(ctx, SyncScript(added, removed)); err != nil {
+		// The new devices are attached on the next try
+		for _, name := range addedEntries {
+			delete(m.known, name)
+		}
+		return err
+	}
+	// Devices present at the start are usually mapped already
+	if !first {
+		for _, n := range added {
+			m.logf("%s attached", n.Path)
+		}
+	}
+	for _, n := range removed {
+		m.logf("%s detached", n.Path)
+	}
+	return nil
+}
+
+// node returns the node of a sysfs entry if it matches the patterns
+func (m *Monitor) node(entry string) Node {
+	major, minor, ok := strings.Cut(entry, ":")
+	if !ok {
+		return Node{}
+	}
+	data, err := os.ReadFile(filepath.Join(m.sysfs, entry, "uevent"))
+	if err != nil {
+		return Node{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, ok := strings.CutPrefix(line, "DEVNAME=")
+		if !ok {
+			continue
+		}
+		p := "/dev/" + name
+		for _, pattern := range m.Patterns {
+			if matched, _ := path.Match(pattern, p); matched {
+				return Node{Path: p, Major: major, Minor: minor}
+			}
+		}
+	}
+	return Node{}
+}
+
+// SyncScript is the shell script creating the added nodes missing from a
+// container and removing the removed ones. Nodes are readable and writable
+// by everyone: the container user is rarely in the host's dialout or audio
+// group.
+func SyncScript(added, removed []Node) string {
+	var lines []string
+	for _, n := range added {
+		lines = append(lines, fmt.Sprintf("[ -e %[1]s ] || { mkdir -p %[2]s && mknod -m 666 %[1]s c %[3]s %[4]s; }",
+			n.Path, path.Dir(n.Path), n.Major, n.Minor))
+	}
+	for _, n := range removed {
+		lines = append(lines, fmt.Sprintf("[ ! -c %[1]s ] || rm -f %[1]s", n.Path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *Monitor) execInContainer(ctx context.Context, script string) error {
+	out, err := exec.CommandContext(ctx, m.Backend, "exec", "-u", "root", m.Container, "sh", "-c", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (m *Monitor) logf(format string, args ...interface{}) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
@@ -0,0 +1,358 @@
+// Package diskusage attributes the disk space used by cm's images, snapshots,
+// containers and volumes to the projects they belong to. Objects are matched
+// by the cm.project label, or by cm's naming scheme (cm-<project>-dev...) for
+// objects created before cm labelled them.
+package diskusage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// Kinds of objects
+const (
+	KindImage     = "image"
+	KindSnapshot  = "snapshot"
+	KindContainer = "container"
+	KindVolume    = "volume"
+)
+
+// projectLabel is set by pkg/runner on the containers and images it creates
+const projectLabel = "cm.project"
+
+// cmName matches the names cm gives a project's objects and captures the
+// project: cm-<project>-dev, cm-cm-<project>-dev (built image), and the
+// snapshot, feature image, Docker access sidecar and volume suffixes
+var cmName = regexp.MustCompile(`^(?:cm-)?cm-(.+)-dev(?:-snapshot|-latest-with-features|-dind-cache|-dind-certs|-dind|-docker-proxy|-net)?$`)
+
+// Item is one image, snapshot, container or volume
+type Item struct {
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Size   int64             `json:"size"` // Bytes not shared with other images
+	Labels map[string]string `json:"-"`
+}
+
+// ProjectUsage is the space attributed to one project
+type ProjectUsage struct {
+	Name       string `json:"name"`
+	Dir        string `json:"dir,omitempty"` // Empty when the project is only known by name
+	Missing    bool   `json:"missing"`       // Dir no longer exists
+	Images     int64  `json:"images"`
+	Snapshots  int64  `json:"snapshots"`
+	Containers int64  `json:"containers"`
+	Volumes    int64  `json:"volumes"`
+	Items      []Item `json:"items"`
+}
+
+// Total returns the project's total size
+func (p *ProjectUsage) Total() int64 {
+	return p.Images + p.Snapshots + p.Containers + p.Volumes
+}
+
+// Report is the disk usage of all projects
+type Report struct {
+	Projects              []*ProjectUsage `json:"projects"` // Largest first
+	SharedCaches          []Item          `json:"shared_caches"`
+	Dangling              int64           `json:"dangling"` // Untagged images, often replaced cm builds
+	DanglingCount         int             `json:"dangling_count"`
+	BuildCache            int64           `json:"build_cache"`
+	BuildCacheReclaimable int64           `json:"build_cache_reclaimable"`
+}
+
+// SharedCachesSize returns the size of the package cache volumes shared by
+// all projects
+func (r *Report) SharedCachesSize() int64 {
+	var total int64
+	for _, item := range r.SharedCaches {
+		total += item.Size
+	}
+	return total
+}
+
+// Total returns the size of everything in the report
+func (r *Report) Total() int64 {
+	total := r.SharedCachesSize() + r.Dangling + r.BuildCache
+	for _, p := range r.Projects {
+		total += p.Total()
+	}
+	return total
+}
+
+// Collect asks the container engine for its disk usage and attributes it
+func Collect(ctx context.Context) (*Report, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	du, err := cli.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage: %w", err)
+	}
+	return FromDiskUsage(du), nil
+}
+
+// FromDiskUsage builds a report from the engine's disk usage
+func FromDiskUsage(du types.DiskUsage) *Report {
+	var items []Item
+	report := &Report{}
+
+	for _, img := range du.Images {
+		size := img.Size
+		if img.SharedSize > 0 {
+			size -= img.SharedSize
+		}
+		name := ""
+		for _, tag := range img.RepoTags {
+			if tag != "<none>:<none>" {
+				name = tag
+				break
+			}
+		}
+		if name == "" {
+			report.Dangling += size
+			report.DanglingCount++
+			continue
+		}
+		kind := KindImage
+		if strings.HasSuffix(repository(name), "-snapshot") {
+			kind = KindSnapshot
+		}
+		items = append(items, Item{Kind: kind, Name: name, Size: size, Labels: img.Labels})
+	}
+
+	for _, ctr := range du.Containers {
+		if len(ctr.Names) == 0 {
+			continue
+		}
+		items = append(items, Item{
+			Kind:   KindContainer,
+			Name:   strings.TrimPrefix(ctr.Names[0], "/"),
+			Size:   ctr.SizeRw,
+			Labels: ctr.Labels,
+		})
+	}
+
+	for _, vol := range du.Volumes {
+		var size int64
+		if vol.UsageData != nil && vol.UsageData.Size > 0 {
+			size = vol.UsageData.Size
+		}
+		items = append(items, Item{Kind: KindVolume, Name: vol.Name, Size: size, Labels: vol.Labels})
+	}
+
+	for _, rec := range du.BuildCache {
+		if rec.Shared {
+			continue // Counted in the images
+		}
+		report.BuildCache += rec.Size
+		if !rec.InUse {
+			report.BuildCacheReclaimable += rec.Size
+		}
+	}
+
+	attribute(report, items, dirExists)
+	return report
+}
+
+// attribute sorts items into projects, or into the shared caches for cm's
+// package cache volumes
+func attribute(report *Report, items []Item, exists func(string) bool) {
+	projects := make(map[string]*ProjectUsage)
+	for _, item := range items {
+		name, dir := projectOf(item)
+		if name == "" {
+			if item.Kind == KindVolume && strings.HasPrefix(item.Name, "cm-") {
+				report.SharedCaches = append(report.SharedCaches, item)
+			}
+			continue
+		}
+
+		p := projects[name]
+		if p == nil {
+			p = &ProjectUsage{Name: name}
+			projects[name] = p
+		}
+		if p.Dir == "" && dir != "" {
+			p.Dir = dir
+			p.Missing = !exists(dir)
+		}
+		p.Items = append(p.Items, item)
+		switch item.Kind {
+		case KindImage:
+			p.Images += item.Size
+		case KindSnapshot:
+			p.Snapshots += item.Size
+		case KindContainer:
+			p.Containers += item.Size
+		case KindVolume:
+			p.Volumes += item.Size
+		}
+	}
+
+	for _, p := range projects {
+		report.Projects = append(report.Projects, p)
+	}
+	sort.Slice(report.Projects, func(i, j int) bool {
+		if report.Projects[i].Total() != report.Projects[j].Total() {
+			return report.Projects[i].Total() > report.Projects[j].Total()
+		}
+		return report.Projects[i].Name < report.Projects[j].Name
+	})
+}
+
+// projectOf returns the project an item belongs to, keyed like cm's
+// container names, and its directory when labelled
+func projectOf(item Item) (name, dir string) {
+	if dir = item.Labels[projectLabel]; dir != "" {
+		return projectKey(filepath.Base(dir)), dir
+	}
+	if m := cmName.FindStringSubmatch(repository(item.Name)); m != nil {
+		return m[1], ""
+	}
+	return "", ""
+}
+
+// projectKey normalizes a directory name the way cm names containers
+func projectKey(base string) string {
+	return strings.ReplaceAll(strings.ToLower(base), " ", "-")
+}
+
+// repository strips the tag from an image reference
+func repository(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i]
+	}
+	return ref
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// Suggestion is a way to reclaim space
+type Suggestion struct {
+	Message string `json:"message"`
+	Command string `json:"command"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Suggestions returns ways to reclaim space, largest first
+func (r *Report) Suggestions() []Suggestion {
+	var out []Suggestion
+
+	for _, p := range r.Projects {
+		if p.Missing {
+			out = append(out, Suggestion{
+				Message: fmt.Sprintf("%s: project directory %s no longer exists", p.Name, p.Dir),
+				Command: removeCommand(p.Items),
+				Bytes:   p.Total(),
+			})
+			continue
+		}
+		if p.Snapshots > 0 && p.Dir != "" && !pausedState(p.Dir) {
+			var snapshots []Item
+			for _, item := range p.Items {
+				if item.Kind == KindSnapshot {
+					snapshots = append(snapshots, item)
+				}
+			}
+			out = append(out, Suggestion{
+				Message: fmt.Sprintf("%s: the pause snapshot is no longer needed (the container is not paused)", p.Name),
+				Command: removeCommand(snapshots),
+				Bytes:   p.Snapshots,
+			})
+		}
+	}
+
+	if r.Dangling > 0 {
+		out = append(out, Suggestion{
+			Message: fmt.Sprintf("%d untagged image(s), mostly replaced builds", r.DanglingCount),
+			Command: "docker image prune",
+			Bytes:   r.Dangling,
+		})
+	}
+	if r.BuildCacheReclaimable > 0 {
+		out = append(out, Suggestion{
+			Message: "Build cache not used by any build in progress",
+			Command: "docker builder prune",
+			Bytes:   r.BuildCacheReclaimable,
+		})
+	}
+	if size := r.SharedCachesSize(); size > 0 {
+		out = append(out, Suggestion{
+			Message: "Package caches shared by all projects (rebuilt on demand)",
+			Command: "cm cache clean",
+			Bytes:   size,
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Bytes > out[j].Bytes })
+	return out
+}
+
+// removeCommand returns the docker commands that remove items: containers
+// first, since they hold on to images and volumes
+func removeCommand(items []Item) string {
+	var containers, images, volumes []string
+	for _, item := range items {
+		switch item.Kind {
+		case KindContainer:
+			containers = append(containers, item.Name)
+		case KindImage, KindSnapshot:
+			images = append(images, item.Name)
+		case KindVolume:
+			volumes = append(volumes, item.Name)
+		}
+	}
+	var cmds []string
+	if len(containers) > 0 {
+		cmds = append(cmds, "docker rm -f "+strings.Join(containers, " "))
+	}
+	if len(images) > 0 {
+		cmds = append(cmds, "docker rmi "+strings.Join(images, " "))
+	}
+	if len(volumes) > 0 {
+		cmds = append(cmds, "docker volume rm "+strings.Join(volumes, " "))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// pausedState reports whether the project's persistent container is paused,
+// from the state file pkg/runner keeps in the project
+func pausedState(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, ".devcontainer", ".cm-state.json"))
+	if err != nil {
+		return false
+	}
+	var state struct {
+		IsPaused bool `json:"isPaused"`
+	}
+	return json.Unmarshal(data, &state) == nil && state.IsPaused
+}
+
+// FormatSize formats bytes for display, e.g. "1.2GB"
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,73 @@
+package diskusage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProjectOf(t *testing.T) {
+	tests := []struct {
+		item    Item
+		project string
+	}{
+		{Item{Name: "cm-cm-my-app-dev:latest"}, "my-app"},
+		{Item{Name: "cm-my-app-dev-snapshot:latest"}, "my-app"},
+		{Item{Name: "cm-my-app-dev"}, "my-app"},
+		{Item{Name: "cm-my-app-dev-dind-cache"}, "my-app"},
+		{Item{Name: "cm-cm-api-dev-latest-with-features:0a1b2c3d4e5f"}, "api"},
+		{Item{Name: "cm-go-pkg"}, ""},
+		{Item{Name: "golang:1.22"}, ""},
+		{Item{Name: "whatever", Labels: map[string]string{"cm.project": "/src/My App"}}, "my-app"},
+	}
+	for _, tt := range tests {
+		if got, _ := projectOf(tt.item); got != tt.project {
+			t.Errorf("projectOf(%q) = %q, want %q", tt.item.Name, got, tt.project)
+		}
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	items := []Item{
+		{Kind: KindImage, Name: "cm-cm-small-dev:latest", Size: 100},
+		{Kind: KindImage, Name: "cm-cm-big-dev:latest", Size: 1000, Labels: map[string]string{"cm.project": "/gone/big"}},
+		{Kind: KindSnapshot, Name: "cm-big-dev-snapshot:latest", Size: 500},
+		{Kind: KindContainer, Name: "cm-big-dev", Size: 10},
+		{Kind: KindVolume, Name: "cm-big-dev-dind-cache", Size: 2000},
+		{Kind: KindVolume, Name: "cm-npm-cache", Size: 300},
+		{Kind: KindVolume, Name: "unrelated", Size: 9999},
+	}
+	report := &Report{}
+	attribute(report, items, func(string) bool { return false })
+
+	if len(report.Projects) != 2 {
+		t.Fatalf("expected 2 projects, got %d", len(report.Projects))
+	}
+	big := report.Projects[0]
+	if big.Name != "big" || big.Total() != 3510 || !big.Missing || big.Dir != "/gone/big" {
+		t.Errorf("unexpected largest project: %+v", big)
+	}
+	if big.Images != 1000 || big.Snapshots != 500 || big.Containers != 10 || big.Volumes != 2000 {
+		t.Errorf("unexpected breakdown: %+v", big)
+	}
+	if report.SharedCachesSize() != 300 {
+		t.Errorf("expected 300 bytes of shared caches, got %d", report.SharedCachesSize())
+	}
+
+	suggestions := report.Suggestions()
+	if len(suggestions) == 0 || !strings.Contains(suggestions[0].Message, "no longer exists") {
+		t.Fatalf("expected the missing project first, got %+v", suggestions)
+	}
+	cmd := suggestions[0].Command
+	if !strings.HasPrefix(cmd, "docker rm -f cm-big-dev && docker rmi ") || !strings.Contains(cmd, "docker volume rm cm-big-dev-dind-cache") {
+		t.Errorf("unexpected remove command: %s", cmd)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	if got := repository("localhost:5000/app:1.0"); got != "localhost:5000/app" {
+		t.Errorf("repository = %q", got)
+	}
+	if got := repository("localhost:5000/app"); got != "localhost:5000/app" {
+		t.Errorf("repository = %q", got)
+	}
+}
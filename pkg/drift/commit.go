@@ -0,0 +1,280 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tailscale/hujson"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// FileEdit is a change to one file of a project
+type FileEdit struct {
+	Path   string
+	Before []byte // nil for a new file
+	After  []byte
+}
+
+// commitComment introduces the lines appended to a Dockerfile
+const commitComment = "# Installed in the dev container, added by 'cm commit-config'"
+
+var (
+	// userInstruction matches a Dockerfile USER instruction
+	userInstruction = regexp.MustCompile(`(?im)^\s*USER\s+(\S+)`)
+
+	// imageSetting matches the image setting of a devcontainer.json
+	imageSetting = regexp.MustCompile(`"image"\s*:\s*"(?:[^"\\]|\\.)*"`)
+)
+
+// CommitEdits returns the edits that make the packages of a report part of
+// the image of the dev container configured in configPath: its Dockerfile
+// gets the RUN lines; a config using an image gets a Dockerfile built from
+// that image instead. Docker Compose configs aren't supported, as the
+// Dockerfile of their service isn't known.
+func CommitEdits(r *Report, cfg *config.DevContainerConfig, configPath string) ([]FileEdit, error) {
+	lines := r.DockerfileLines()
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if cfg.DockerComposeFile != nil {
+		return nil, fmt.Errorf("the Dockerfile of the %s service isn't known; add the lines to it by hand", cfg.Service)
+	}
+	configDir := filepath.Dir(configPath)
+	block := commitComment + "\n" + strings.Join(lines, "\n") + "\n"
+
+	if cfg.Build != nil {
+		dockerfile := cfg.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		path := filepath.Join(configDir, dockerfile)
+		before, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Dockerfile: %w", err)
+		}
+		after := string(before)
+		if after != "" && !strings.HasSuffix(after, "\n") {
+			after += "\n"
+		}
+		after += "\n"
+		// Packages are installed as root; the user the image runs as is
+		// restored afterwards
+		if users := userInstruction.FindAllStringSubmatch(after, -1); len(users) > 0 {
+			if user := users[len(users)-1][1]; user != "root" && user != "0" {
+				block = "USER root\n" + block + "USER " + user + "\n"
+			}
+		}
+		return []FileEdit{{Path: path, Before: before, After: []byte(after + block)}}, nil
+	}
+
+	if cfg.Image == "" {
+		return nil, fmt.Errorf("%s has neither an image nor a build", configPath)
+	}
+	var name string
+	candidates := []string{"devcontainer.Dockerfile"}
+	if filepath.Base(configDir) == ".devcontainer" {
+		candidates = []string{"Dockerfile", "devcontainer.Dockerfile"}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(configDir, candidate)); os.IsNotExist(err) {
+			name = candidate
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s already exists", filepath.Join(configDir, candidates[len(candidates)-1]))
+	}
+
+	before, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	matches := imageSetting.FindAllIndex(before, -1)
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("can't find the image setting in %s", configPath)
+	}
+	build, _ := json.Marshal(name)
+	after := make([]byte, 0, len(before))
+	after = append(after, before[:matches[0][0]]...)
+	after = append(after, `"build": { "dockerfile": `+string(build)+` }`...)
+	after = append(after, before[matches[0][1]:]...)
+	if err := checkBuildSetting(after); err != nil {
+		return nil, fmt.Errorf("can't replace the image setting in %s: %w", configPath, err)
+	}
+
+	dockerfile := "FROM " + cfg.Image + "\n\n" + block
+	return []FileEdit{
+		{Path: filepath.Join(configDir, name), After: []byte(dockerfile)},
+		{Path: configPath, Before: before, After: after},
+	}, nil
+}
+
+// checkBuildSetting verifies an edited devcontainer.json builds a Dockerfile
+// instead of using an image
+func checkBuildSetting(data []byte) error {
+	// Standardize blanks out comments in place
+	std, err := hujson.Standardize(append([]byte(nil), data...))
+	if err != nil {
+		return err
+	}
+	var cfg config.DevContainerConfig
+	if err := json.Unmarshal(std, &cfg); err != nil {
+		return err
+	}
+	if cfg.Image != "" || cfg.Build == nil {
+		return fmt.Errorf("the image setting isn't at the top level")
+	}
+	return nil
+}
+
+// Apply writes the edits
+func Apply(edits []FileEdit) error {
+	for _, e := range edits {
+		if err := os.WriteFile(e.Path, e.After, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnifiedDiff renders edits as a patch git apply accepts, with paths
+// relative to dir
+func UnifiedDiff(edits []FileEdit, dir string) string {
+	var sb strings.Builder
+	for _, e := range edits {
+		name := e.Path
+		if rel, err := filepath.Rel(dir, e.Path); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		from := "a/" + name
+		if e.Before == nil {
+			from = "/dev/null"
+		}
+		fmt.Fprintf(&sb, "--- %s\n+++ b/%s\n", from, name)
+		sb.WriteString(diffLines(splitLines(string(e.Before)), splitLines(string(e.After))))
+	}
+	return sb.String()
+}
+
+// noNewline marks a last line without a newline, which differs from the
+// same line with one
+const noNewline = "\x00"
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += noNewline
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffContext is how many unchanged lines surround each change
+const diffContext = 3
+
+// diffLines returns the hunks turning a into b, from their longest common
+// subsequence
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		line string
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := max(first-diffContext, start)
+		end := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end+1 > 2*diffContext {
+				// Changes further apart get their own hunks
+				break
+			}
+		}
+		to := min(end+diffContext, len(ops))
+
+		// Line numbers of the hunk in a and b
+		aStart, bStart := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				aStart++
+			}
+			if o.kind != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				aLen++
+			}
+			if o.kind != '-' {
+				bLen++
+			}
+		}
+		if aLen == 0 {
+			aStart--
+		}
+		if bLen == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, o := range ops[from:to] {
+			sb.WriteByte(o.kind)
+			if line, ok := strings.CutSuffix(o.line, noNewline); ok {
+				sb.WriteString(line + "\n\\ No newline at end of file\n")
+				continue
+			}
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
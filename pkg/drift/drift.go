@@ -0,0 +1,578 @@
+// Package drift finds how a dev container has drifted from its image: the
+// packages installed or removed with apt, apk, pip and npm since it was
+// created, and the other files changed. It suggests the Dockerfile lines
+// that would make those packages part of the image.
+package drift
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of file changes, as docker diff prints them
+const (
+	Added   = "A"
+	Changed = "C"
+	Deleted = "D"
+)
+
+// Package managers
+const (
+	Apt = "apt"
+	Apk = "apk"
+	Pip = "pip"
+	Npm = "npm"
+)
+
+// Package databases read from the container and its image
+const (
+	dpkgStatus    = "/var/lib/dpkg/status"
+	aptStates     = "/var/lib/apt/extended_states"
+	dpkgInfo      = "/var/lib/dpkg/info"
+	apkWorld      = "/etc/apk/world"
+	npmGlobalRoot = "/usr/local/lib/node_modules"
+)
+
+// Change is a file added, changed or deleted in the container
+type Change struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+// Package is a package installed, upgraded or removed in the container
+type Package struct {
+	Manager string `json:"manager"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	From    string `json:"from,omitempty"` // Version in the image, for upgrades
+
+	// Dependency is whether the package was installed only as a dependency
+	// of another, so installing that one brings it back
+	Dependency bool `json:"dependency,omitempty"`
+}
+
+// Report is how a container differs from its image
+type Report struct {
+	Container string    `json:"container"`
+	Image     string    `json:"image"`
+	Installed []Package `json:"installed"`
+	Removed   []Package `json:"removed"`
+
+	// Changes are the files changed other than by the packages, with
+	// directories only listed themselves
+	Changes []Change `json:"changes"`
+
+	// Ignored counts changes to caches, logs, histories and temporary
+	// files, left out of Changes
+	Ignored int `json:"ignored"`
+}
+
+// Empty returns whether the container hasn't drifted
+func (r *Report) Empty() bool {
+	return len(r.Installed) == 0 && len(r.Removed) == 0 && len(r.Changes) == 0
+}
+
+// Collect compares a container with the image it was created from, using
+// the container CLI backend (docker, podman or nerdctl)
+func Collect(ctx context.Context, backend, container string) (*Report, error) {
+	out, err := exec.CommandContext(ctx, backend, "inspect", "--type", "container", "--format", "{{.Image}} {{.Config.Image}}", container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("container %s not found", container)
+	}
+	imageID, imageName, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if imageName == "" {
+		imageName = imageID
+	}
+
+	out, err = exec.CommandContext(ctx, backend, "diff", container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff the container: %w", commandError(err))
+	}
+	changes := ParseDiff(string(out))
+
+	fs := &filesystems{ctx: ctx, backend: backend, container: container, image: imageID}
+	defer fs.close()
+	report, err := analyze(changes, fs)
+	if err != nil {
+		return nil, err
+	}
+	report.Container, report.Image = container, imageName
+	return report, nil
+}
+
+// ParseDiff parses the output of docker diff
+func ParseDiff(out string) []Change {
+	var changes []Change
+	for _, line := range strings.Split(out, "\n") {
+		kind, p, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || (kind != Added && kind != Changed && kind != Deleted) {
+			continue
+		}
+		changes = append(changes, Change{Kind: kind, Path: strings.TrimSpace(p)})
+	}
+	return changes
+}
+
+// fileReader reads files of the container ("after") and of its image
+// ("before"); missing files read as nil
+type fileReader interface {
+	after(p string) ([]byte, error)
+	before(p string) ([]byte, error)
+	// afterDir returns the files of a directory of the container accepted
+	// by keep, by name
+	afterDir(p string, keep func(name string) bool) (map[string][]byte, error)
+}
+
+// pipDistInfo matches the metadata directory of a pip package
+var pipDistInfo = regexp.MustCompile(`/(?:site|dist)-packages/([^/]+)-([^/-]+)\.dist-info(/.*)?$`)
+
+// analyze builds the report of a container's changes
+func analyze(changes []Change, fs fileReader) (*Report, error) {
+	report := &Report{}
+	byPath := make(map[string]string, len(changes))
+	// parents are the directories with changes inside them
+	parents := make(map[string]bool)
+	for _, c := range changes {
+		byPath[c.Path] = c.Kind
+		parents[path.Dir(c.Path)] = true
+	}
+
+	// owned are the paths accounted for by package managers
+	owned := make(map[string]bool)
+
+	if _, ok := byPath[dpkgStatus]; ok {
+		installed, removed, err := aptChanges(fs)
+		if err != nil {
+			return nil, err
+		}
+		report.Installed = append(report.Installed, installed...)
+		report.Removed = append(report.Removed, removed...)
+		if len(installed) > 0 {
+			names := make(map[string]bool, len(installed))
+			for _, p := range installed {
+				names[p.Name] = true
+			}
+			lists, err := fs.afterDir(dpkgInfo, func(name string) bool {
+				pkg, ok := strings.CutSuffix(name, ".list")
+				pkg, _, _ = strings.Cut(pkg, ":") // Drop the architecture
+				return ok && names[pkg]
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, data := range lists {
+				for _, p := range strings.Split(string(data), "\n") {
+					if p != "" && p != "/." {
+						owned[p] = true
+					}
+				}
+			}
+		}
+	}
+
+	if _, ok := byPath[apkWorld]; ok {
+		installed, removed, err := apkChanges(fs)
+		if err != nil {
+			return nil, err
+		}
+		report.Installed = append(report.Installed, installed...)
+		report.Removed = append(report.Removed, removed...)
+	}
+
+	// pip packages are found by their metadata directories; REQUESTED marks
+	// the ones installed by name rather than as dependencies
+	for _, c := range changes {
+		m := pipDistInfo.FindStringSubmatch(c.Path)
+		if m == nil || m[3] != "" {
+			continue
+		}
+		pkg := Package{Manager: Pip, Name: m[1], Version: m[2]}
+		switch c.Kind {
+		case Added:
+			_, requested := byPath[c.Path+"/REQUESTED"]
+			pkg.Dependency = !requested
+			report.Installed = append(report.Installed, pkg)
+		case Deleted:
+			report.Removed = append(report.Removed, pkg)
+		}
+	}
+
+	// Global npm packages are directories of its root, or of a scope there
+	for _, c := range changes {
+		rel, ok := strings.CutPrefix(c.Path, npmGlobalRoot+"/")
+		if !ok || rel == "npm" || strings.HasPrefix(rel, ".") {
+			continue
+		}
+		if parts := strings.Split(rel, "/"); len(parts) == 1 && !strings.HasPrefix(rel, "@") || len(parts) == 2 && strings.HasPrefix(rel, "@") {
+			switch c.Kind {
+			case Added:
+				report.Installed = append(report.Installed, Package{Manager: Npm, Name: rel})
+			case Deleted:
+				report.Removed = append(report.Removed, Package{Manager: Npm, Name: rel})
+			}
+		}
+	}
+
+	// What's left: files not owned by a package or package manager, without
+	// the parents docker diff lists as changed and the contents of added or
+	// deleted directories
+	for _, c := range changes {
+		switch {
+		case owned[c.Path] || managedPath(c.Path, report):
+		case c.Kind == Changed && parents[c.Path]:
+		case ignoredPath(c.Path):
+			report.Ignored++
+		case coveredByParent(c.Path, byPath):
+		default:
+			report.Changes = append(report.Changes, c)
+		}
+	}
+
+	sortPackages(report.Installed)
+	sortPackages(report.Removed)
+	sort.Slice(report.Changes, func(i, j int) bool { return report.Changes[i].Path < report.Changes[j].Path })
+	return report, nil
+}
+
+// aptChanges compares the dpkg databases of the container and its image
+func aptChanges(fs fileReader) (installed, removed []Package, err error) {
+	afterStatus, err := fs.after(dpkgStatus)
+	if err != nil {
+		return nil, nil, err
+	}
+	beforeStatus, err := fs.before(dpkgStatus)
+	if err != nil {
+		return nil, nil, err
+	}
+	states, err := fs.after(aptStates)
+	if err != nil {
+		return nil, nil, err
+	}
+	auto := parseAutoInstalled(states)
+	after, before := ParseDpkgStatus(afterStatus), ParseDpkgStatus(beforeStatus)
+
+	for name, version := range after {
+		if from, ok := before[name]; !ok || from != version {
+			pkg := Package{Manager: Apt, Name: name, Version: version, From: from, Dependency: auto[name]}
+			installed = append(installed, pkg)
+		}
+	}
+	for name, version := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, Package{Manager: Apt, Name: name, Version: version})
+		}
+	}
+	return installed, removed, nil
+}
+
+// ParseDpkgStatus returns the installed packages of a dpkg status file and
+// their versions
+func ParseDpkgStatus(data []byte) map[string]string {
+	packages := make(map[string]string)
+	for _, stanza := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		var name, version, status string
+		for _, line := range strings.Split(stanza, "\n") {
+			key, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Package":
+				name = value
+			case "Version":
+				version = value
+			case "Status":
+				status = value
+			}
+		}
+		if name != "" && strings.HasSuffix(status, " installed") {
+			packages[name] = version
+		}
+	}
+	return packages
+}
+
+// parseAutoInstalled returns the packages apt installed as dependencies,
+// from its extended_states file
+func parseAutoInstalled(data []byte) map[string]bool {
+	auto := make(map[string]bool)
+	var name string
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ": ")
+		switch key {
+		case "Package":
+			name = value
+		case "Auto-Installed":
+			if value == "1" {
+				auto[name] = true
+			}
+		}
+	}
+	return auto
+}
+
+// apkChanges compares the packages explicitly installed in the container
+// and in its image
+func apkChanges(fs fileReader) (installed, removed []Package, err error) {
+	afterWorld, err := fs.after(apkWorld)
+	if err != nil {
+		return nil, nil, err
+	}
+	beforeWorld, err := fs.before(apkWorld)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, before := strings.Fields(string(afterWorld)), strings.Fields(string(beforeWorld))
+	inBefore := make(map[string]bool, len(before))
+	for _, name := range before {
+		inBefore[name] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, name := range after {
+		inAfter[name] = true
+		if !inBefore[name] {
+			installed = append(installed, Package{Manager: Apk, Name: name})
+		}
+	}
+	for _, name := range before {
+		if !inAfter[name] {
+			removed = append(removed, Package{Manager: Apk, Name: name})
+		}
+	}
+	return installed, removed, nil
+}
+
+// managedPath returns whether a path belongs to a package manager's
+// databases or to the packages it found
+func managedPath(p string, report *Report) bool {
+	for _, prefix := range []string{"/var/lib/dpkg/", "/var/lib/apt/", "/lib/apk/db/"} {
+		if strings.HasPrefix(p, prefix) || p+"/" == prefix {
+			return true
+		}
+	}
+	if p == apkWorld || p == "/etc/ld.so.cache" {
+		return true
+	}
+	if strings.Contains(p, "/site-packages/") || strings.Contains(p, "/dist-packages/") {
+		return hasManager(report, Pip)
+	}
+	if strings.HasPrefix(p, npmGlobalRoot+"/") {
+		return hasManager(report, Npm)
+	}
+	return false
+}
+
+func hasManager(report *Report, manager string) bool {
+	for _, list := range [][]Package{report.Installed, report.Removed} {
+		for _, p := range list {
+			if p.Manager == manager {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ignoredPrefixes hold caches, logs and runtime state
+var ignoredPrefixes = []string{
+	"/tmp", "/var/tmp", "/run", "/var/run", "/var/log", "/var/cache",
+	"/proc", "/sys", "/dev", "/root/.cache", "/root/.npm",
+}
+
+// ignoredNames are shell and editor state
+var ignoredNames = map[string]bool{
+	".bash_history": true, ".zsh_history": true, ".python_history": true,
+	".node_repl_history": true, ".lesshst": true, ".viminfo": true,
+	".wget-hsts": true, "__pycache__": true,
+}
+
+// ignoredPath returns whether a change is to a cache, log, history or
+// temporary file, which isn't worth reproducing
+func ignoredPath(p string) bool {
+	for _, prefix := range ignoredPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	if strings.HasPrefix(p, "/home/") {
+		// Per-user caches: /home/<user>/.cache, .npm
+		parts := strings.SplitN(p, "/", 5)
+		if len(parts) >= 4 && (parts[3] == ".cache" || parts[3] == ".npm") {
+			return true
+		}
+	}
+	for _, part := range strings.Split(p, "/") {
+		if ignoredNames[part] {
+			return true
+		}
+	}
+	return strings.HasSuffix(p, ".pyc")
+}
+
+// coveredByParent returns whether p is inside a directory added or deleted
+// as a whole
+func coveredByParent(p string, byPath map[string]string) bool {
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if kind := byPath[dir]; kind == Added || kind == Deleted {
+			return true
+		}
+	}
+	return false
+}
+
+func sortPackages(packages []Package) {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Manager != packages[j].Manager {
+			return packages[i].Manager < packages[j].Manager
+		}
+		return packages[i].Name < packages[j].Name
+	})
+}
+
+// DockerfileLines returns the RUN instructions that install and remove the
+// packages of the report, leaving out the ones installed as dependencies
+func (r *Report) DockerfileLines() []string {
+	names := func(list []Package, manager string, pinned bool) []string {
+		var result []string
+		for _, p := range list {
+			if p.Manager != manager || p.Dependency {
+				continue
+			}
+			name := p.Name
+			if pinned && p.Version != "" {
+				name += "==" + p.Version
+			}
+			result = append(result, name)
+		}
+		return result
+	}
+
+	var lines []string
+	if apt := names(r.Installed, Apt, false); len(apt) > 0 {
+		lines = append(lines, "RUN apt-get update && apt-get install -y --no-install-recommends "+strings.Join(apt, " ")+" \\\n    && rm -rf /var/lib/apt/lists/*")
+	}
+	if apt := names(r.Removed, Apt, false); len(apt) > 0 {
+		lines = append(lines, "RUN apt-get purge -y "+strings.Join(apt, " "))
+	}
+	if apk := names(r.Installed, Apk, false); len(apk) > 0 {
+		lines = append(lines, "RUN apk add --no-cache "+strings.Join(apk, " "))
+	}
+	if apk := names(r.Removed, Apk, false); len(apk) > 0 {
+		lines = append(lines, "RUN apk del "+strings.Join(apk, " "))
+	}
+	if pip := names(r.Installed, Pip, true); len(pip) > 0 {
+		lines = append(lines, "RUN pip install --no-cache-dir "+strings.Join(pip, " "))
+	}
+	if pip := names(r.Removed, Pip, false); len(pip) > 0 {
+		lines = append(lines, "RUN pip uninstall -y "+strings.Join(pip, " "))
+	}
+	if npm := names(r.Installed, Npm, false); len(npm) > 0 {
+		lines = append(lines, "RUN npm install -g "+strings.Join(npm, " "))
+	}
+	if npm := names(r.Removed, Npm, false); len(npm) > 0 {
+		lines = append(lines, "RUN npm uninstall -g "+strings.Join(npm, " "))
+	}
+	return lines
+}
+
+// filesystems reads files from a container, and from its image through a
+// container created from it but never started
+type filesystems struct {
+	ctx       context.Context
+	backend   string
+	container string
+	image     string
+	created   string
+}
+
+func (f *filesystems) after(p string) ([]byte, error) {
+	files, err := f.copy(f.container, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	return files[path.Base(p)], nil
+}
+
+func (f *filesystems) before(p string) ([]byte, error) {
+	if f.created == "" {
+		// The command is never run; images without one can't be created
+		out, err := exec.CommandContext(f.ctx, f.backend, "create", "--entrypoint", "", f.image, "true").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the image: %w", commandError(err))
+		}
+		f.created = strings.TrimSpace(string(out))
+	}
+	files, err := f.copy(f.created, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	return files[path.Base(p)], nil
+}
+
+func (f *filesystems) afterDir(p string, keep func(name string) bool) (map[string][]byte, error) {
+	return f.copy(f.container, p, keep)
+}
+
+// copy reads a file, or the files of a directory accepted by keep, from a
+// container by their base names
+func (f *filesystems) copy(container, p string, keep func(name string) bool) (map[string][]byte, error) {
+	cmd := exec.CommandContext(f.ctx, f.backend, "cp", container+":"+p, "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(bufio.NewReader(stdout))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || (keep != nil && !keep(name)) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			break
+		}
+		files[name] = data
+	}
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		if strings.Contains(strings.ToLower(stderr.String()), "no such file") || strings.Contains(stderr.String(), "Could not find") {
+			return files, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %s", p, strings.TrimSpace(stderr.String()))
+	}
+	return files, nil
+}
+
+// close removes the container created to read the image
+func (f *filesystems) close() {
+	if f.created != "" {
+		_ = exec.Command(f.backend, "rm", "-f", f.created).Run()
+	}
+}
+
+// commandError adds the stderr of a failed command to its error
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
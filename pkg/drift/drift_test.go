@@ -0,0 +1,259 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// fakeFS serves the files of a container and its image from maps
+type fakeFS struct {
+	afterFiles, beforeFiles map[string]string
+	dirs                    map[string]map[string]string
+}
+
+func (f *fakeFS) after(p string) ([]byte, error) {
+	if data, ok := f.afterFiles[p]; ok {
+		return []byte(data), nil
+	}
+	return nil, nil
+}
+
+func (f *fakeFS) before(p string) ([]byte, error) {
+	if data, ok := f.beforeFiles[p]; ok {
+		return []byte(data), nil
+	}
+	return nil, nil
+}
+
+func (f *fakeFS) afterDir(p string, keep func(string) bool) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for name, data := range f.dirs[p] {
+		if keep(name) {
+			files[name] = []byte(data)
+		}
+	}
+	return files, nil
+}
+
+const imageStatus = `Package: bash
+Status: install ok installed
+Version: 5.2-2
+
+Package: nano
+Status: install ok installed
+Version: 7.2-1
+`
+
+const containerStatus = `Package: bash
+Status: install ok installed
+Version: 5.2-2
+
+Package: htop
+Status: install ok installed
+Version: 3.2.2-2
+
+Package: libnl-3-200
+Status: install ok installed
+Version: 3.7.0-0.2
+
+Package: nano
+Status: deinstall ok config-files
+Version: 7.2-1
+`
+
+const diff = `C /etc
+A /etc/motd.d
+A /etc/motd.d/welcome
+C /root
+A /root/.bash_history
+C /tmp
+A /tmp/build.log
+C /usr
+C /usr/bin
+A /usr/bin/htop
+D /usr/bin/nano
+C /usr/local
+C /usr/local/lib
+C /usr/local/lib/node_modules
+A /usr/local/lib/node_modules/typescript
+A /usr/local/lib/node_modules/typescript/package.json
+A /usr/local/lib/node_modules/@angular
+A /usr/local/lib/node_modules/@angular/cli
+C /usr/local/lib/python3.12
+C /usr/local/lib/python3.12/site-packages
+A /usr/local/lib/python3.12/site-packages/requests
+A /usr/local/lib/python3.12/site-packages/requests/__init__.py
+A /usr/local/lib/python3.12/site-packages/requests-2.31.0.dist-info
+A /usr/local/lib/python3.12/site-packages/requests-2.31.0.dist-info/REQUESTED
+A /usr/local/lib/python3.12/site-packages/idna-3.6.dist-info
+C /var
+C /var/lib
+C /var/lib/dpkg
+C /var/lib/dpkg/status
+C /opt
+A /opt/tool
+A /opt/tool/bin
+A /opt/tool/bin/tool
+`
+
+func TestAnalyze(t *testing.T) {
+	fs := &fakeFS{
+		afterFiles: map[string]string{
+			dpkgStatus: containerStatus,
+			aptStates:  "Package: libnl-3-200\nArchitecture: amd64\nAuto-Installed: 1\n",
+		},
+		beforeFiles: map[string]string{dpkgStatus: imageStatus},
+		dirs: map[string]map[string]string{
+			dpkgInfo: {
+				"htop.list":                 "/.\n/usr\n/usr/bin\n/usr/bin/htop\n",
+				"libnl-3-200:amd64.list":    "/.\n/usr\n",
+				"bash.list":                 "/etc/motd.d/welcome\n",
+				"libnl-3-200:amd64.md5sums": "",
+			},
+		},
+	}
+	report, err := analyze(ParseDiff(diff), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var installed []string
+	for _, p := range report.Installed {
+		name := p.Manager + ":" + p.Name
+		if p.Dependency {
+			name += "(dep)"
+		}
+		installed = append(installed, name)
+	}
+	want := "apt:htop apt:libnl-3-200(dep) npm:@angular/cli npm:typescript pip:idna(dep) pip:requests"
+	if got := strings.Join(installed, " "); got != want {
+		t.Errorf("installed = %s, want %s", got, want)
+	}
+	if len(report.Removed) != 1 || report.Removed[0].Name != "nano" {
+		t.Errorf("removed = %+v, want nano", report.Removed)
+	}
+
+	var changes []string
+	for _, c := range report.Changes {
+		changes = append(changes, c.Kind+" "+c.Path)
+	}
+	// /usr/bin/nano was nano's, but removed packages' files aren't known
+	wantChanges := "A /etc/motd.d, A /opt/tool, D /usr/bin/nano"
+	if got := strings.Join(changes, ", "); got != wantChanges {
+		t.Errorf("changes = %s, want %s", got, wantChanges)
+	}
+	if report.Ignored != 2 {
+		t.Errorf("ignored = %d, want the history and the log", report.Ignored)
+	}
+
+	lines := report.DockerfileLines()
+	if len(lines) != 4 {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "RUN apt-get update && apt-get install -y --no-install-recommends htop \\") {
+		t.Errorf("apt line = %q, want only htop", lines[0])
+	}
+	if lines[1] != "RUN apt-get purge -y nano" || lines[2] != "RUN pip install --no-cache-dir requests==2.31.0" || lines[3] != "RUN npm install -g @angular/cli typescript" {
+		t.Errorf("lines = %q", lines[1:])
+	}
+}
+
+func TestApkChanges(t *testing.T) {
+	fs := &fakeFS{
+		afterFiles:  map[string]string{apkWorld: "alpine-base\nbash\ncurl\n"},
+		beforeFiles: map[string]string{apkWorld: "alpine-base\nwget\n"},
+	}
+	report, err := analyze([]Change{{Changed, "/etc/apk"}, {Changed, apkWorld}}, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Installed) != 2 || len(report.Removed) != 1 || len(report.Changes) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	lines := report.DockerfileLines()
+	if len(lines) != 2 || lines[0] != "RUN apk add --no-cache bash curl" || lines[1] != "RUN apk del wget" {
+		t.Errorf("lines = %q", lines)
+	}
+}
+
+func TestCommitEdits(t *testing.T) {
+	report := &Report{Installed: []Package{{Manager: Apt, Name: "htop", Version: "3.2.2-2"}}}
+
+	t.Run("dockerfile", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), ".devcontainer")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		dockerfile := "FROM debian:12\nRUN useradd dev\nUSER dev"
+		if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config.DevContainerConfig{Build: &config.BuildConfig{Dockerfile: "Dockerfile"}}
+		edits, err := CommitEdits(report, cfg, filepath.Join(dir, "devcontainer.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edits) != 1 {
+			t.Fatalf("edits = %d, want the Dockerfile's", len(edits))
+		}
+		want := `--- a/.devcontainer/Dockerfile
++++ b/.devcontainer/Dockerfile
+@@ -1,3 +1,9 @@
+ FROM debian:12
+ RUN useradd dev
+-USER dev
+\ No newline at end of file
++USER dev
++
++USER root
++# Installed in the dev container, added by 'cm commit-config'
++RUN apt-get update && apt-get install -y --no-install-recommends htop \
++    && rm -rf /var/lib/apt/lists/*
++USER dev
+`
+		if got := UnifiedDiff(edits, filepath.Dir(dir)); got != want {
+			t.Errorf("patch =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("image", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), ".devcontainer")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		configPath := filepath.Join(dir, "devcontainer.json")
+		devcontainer := "{\n  // Go\n  \"image\": \"golang:1.23\",\n  \"remoteUser\": \"root\"\n}\n"
+		if err := os.WriteFile(configPath, []byte(devcontainer), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config.DevContainerConfig{Image: "golang:1.23"}
+		edits, err := CommitEdits(report, cfg, configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edits) != 2 || edits[0].Path != filepath.Join(dir, "Dockerfile") || edits[0].Before != nil {
+			t.Fatalf("edits = %+v", edits)
+		}
+		if !strings.HasPrefix(string(edits[0].After), "FROM golang:1.23\n\n# Installed") {
+			t.Errorf("Dockerfile = %q", edits[0].After)
+		}
+		want := "{\n  // Go\n  \"build\": { \"dockerfile\": \"Dockerfile\" },\n  \"remoteUser\": \"root\"\n}\n"
+		if string(edits[1].After) != want {
+			t.Errorf("devcontainer.json = %q, want %q", edits[1].After, want)
+		}
+		if got := UnifiedDiff(edits, filepath.Dir(dir)); !strings.HasPrefix(got, "--- /dev/null\n+++ b/.devcontainer/Dockerfile\n@@ -0,0 +1,5 @@\n") {
+			t.Errorf("patch = %s", got)
+		}
+	})
+
+	t.Run("compose", func(t *testing.T) {
+		cfg := &config.DevContainerConfig{DockerComposeFile: "compose.yaml", Service: "app"}
+		if _, err := CommitEdits(report, cfg, "devcontainer.json"); err == nil {
+			t.Error("expected an error for a Docker Compose config")
+		}
+	})
+}
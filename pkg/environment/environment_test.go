@@ -3,6 +3,7 @@ package environment
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -215,6 +216,60 @@ func TestNetworkInfo(t *testing.T) {
 	}
 }
 
+func TestLinkPolicy(t *testing.T) {
+	var none *LinkPolicy
+	if !none.IsOpen() || none.Validate() != nil {
+		t.Error("A nil policy should be open and valid")
+	}
+	if !(&LinkPolicy{Direction: LinkBoth}).IsOpen() {
+		t.Error("A policy without ports both ways should be open")
+	}
+
+	policy := &LinkPolicy{Ports: []int{8080}, Direction: LinkForward}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+	if policy.IsOpen() {
+		t.Error("A policy with ports should not be open")
+	}
+	if got := policy.String(); got != "ports 8080, forward only" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if (&LinkPolicy{Ports: []int{70000}}).Validate() == nil {
+		t.Error("Expected error for an invalid port")
+	}
+	if (&LinkPolicy{Direction: "backward"}).Validate() == nil {
+		t.Error("Expected error for an unknown direction")
+	}
+}
+
+func TestFirewallScript(t *testing.T) {
+	chain := linkChain("env-0123456789abcdef")
+	if chain != "CM-LINK-0123456789AB" {
+		t.Errorf("linkChain() = %q", chain)
+	}
+
+	script := firewallScript(chain, []string{"172.18.0.2"}, []int{8080})
+	for _, want := range []string{
+		"iptables -I INPUT -j " + chain,
+		"--ctstate ESTABLISHED,RELATED -j RETURN",
+		"-s 172.18.0.2 -p tcp --dport 8080 -j RETURN",
+		"-s 172.18.0.2 -j REJECT",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script should contain %q:\n%s", want, script)
+		}
+	}
+	if strings.Index(script, "--dport 8080") > strings.Index(script, "-j REJECT") {
+		t.Error("Allowed ports must come before the reject rule")
+	}
+
+	if !strings.Contains(removeFirewallScript(chain), "iptables -X "+chain) {
+		t.Error("Remove script should delete the chain")
+	}
+}
+
 func TestStateFilePath(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "cm-test-*")
 	defer os.RemoveAll(tmpDir)
@@ -229,3 +284,49 @@ func TestStateFilePath(t *testing.T) {
 		t.Errorf("State path should be absolute: %s", statePath)
 	}
 }
+
+func TestGPUConflict(t *testing.T) {
+	whole := &Environment{ID: "a", Name: "train", GPUs: []int{0}}
+	slice := &Environment{ID: "b", Name: "infer", MIGDevices: []string{"1:0"}}
+
+	tests := []struct {
+		name   string
+		env    *Environment
+		holder string
+		device string
+	}{
+		{"same GPU", &Environment{ID: "c", GPUs: []int{0}}, "train", "0"},
+		{"slice of a held GPU", &Environment{ID: "c", MIGDevices: []string{"0:2"}}, "train", "0:2"},
+		{"GPU with a held slice", &Environment{ID: "c", GPUs: []int{1}}, "infer", "1"},
+		{"same slice", &Environment{ID: "c", MIGDevices: []string{"1:0"}}, "infer", "1:0"},
+		{"other slice", &Environment{ID: "c", MIGDevices: []string{"1:1"}}, "", ""},
+		{"other GPU", &Environment{ID: "c", GPUs: []int{2}}, "", ""},
+		{"itself", &Environment{ID: "a", GPUs: []int{0}}, "", ""},
+		{"shared with one side", &Environment{ID: "c", GPUs: []int{0}, GPUShared: true}, "train", "0"},
+	}
+	for _, tt := range tests {
+		holder, device := gpuConflict(tt.env, []*Environment{whole, slice})
+		name := ""
+		if holder != nil {
+			name = holder.Name
+		}
+		if name != tt.holder || device != tt.device {
+			t.Errorf("%s: got %q on %q, want %q on %q", tt.name, name, device, tt.holder, tt.device)
+		}
+	}
+
+	sharedA := &Environment{ID: "a", GPUs: []int{0}, GPUShared: true}
+	sharedB := &Environment{ID: "b", GPUs: []int{0}, GPUShared: true}
+	if holder, _ := gpuConflict(sharedB, []*Environment{sharedA}); holder != nil {
+		t.Error("Shared environments should share a GPU")
+	}
+
+	if devices := (&Environment{GPUs: []int{1}, MIGDevices: []string{"0:1"}}).GPUDevices(); strings.Join(devices, ",") != "1,0:1" {
+		t.Errorf("GPUDevices = %v", devices)
+	}
+	for device, valid := range map[string]bool{"0:1": true, "0": false, "a:1": false, "0:-1": false, "0:": false} {
+		if err := ParseMIGDevice(device); (err == nil) != valid {
+			t.Errorf("ParseMIGDevice(%q) = %v", device, err)
+		}
+	}
+}
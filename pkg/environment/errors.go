@@ -17,10 +17,12 @@ var (
 	ErrInvalidConfig         = &EnvironmentError{Code: "INVALID_CONFIG", Message: "invalid configuration"}
 	ErrDockerNotAvailable    = &EnvironmentError{Code: "DOCKER_UNAVAILABLE", Message: "Docker is not available"}
 	ErrGPUNotAvailable       = &EnvironmentError{Code: "GPU_UNAVAILABLE", Message: "requested GPU is not available"}
+	ErrGPUInUse              = &EnvironmentError{Code: "GPU_IN_USE", Message: "requested GPU is held by another environment"}
 	ErrInsufficientResources = &EnvironmentError{Code: "INSUFFICIENT_RESOURCES", Message: "insufficient resources"}
 	ErrLinkExists            = &EnvironmentError{Code: "LINK_EXISTS", Message: "environments are already linked"}
 	ErrLinkNotFound          = &EnvironmentError{Code: "LINK_NOT_FOUND", Message: "environments are not linked"}
 	ErrSelfLink              = &EnvironmentError{Code: "SELF_LINK", Message: "cannot link environment to itself"}
+	ErrFirewallFailed        = &EnvironmentError{Code: "FIREWALL_FAILED", Message: "failed to apply link policy"}
 	ErrStateCorrupted        = &EnvironmentError{Code: "STATE_CORRUPTED", Message: "environment state is corrupted"}
 	ErrOperationTimeout      = &EnvironmentError{Code: "OPERATION_TIMEOUT", Message: "operation timed out"}
 )
@@ -136,6 +138,8 @@ func FormatUserError(err error) string {
 				result += "\nSuggestion: Run 'cm doctor' to diagnose Docker issues\n"
 			case "GPU_UNAVAILABLE":
 				result += "\nSuggestion: Run 'cm gpu list' to see available GPUs\n"
+			case "GPU_IN_USE":
+				result += "\nSuggestion: Run 'cm env list --gpus' to see which environments hold GPUs\n"
 			case "INSUFFICIENT_RESOURCES":
 				result += "\nSuggestion: Stop other environments with 'cm env stop' or reduce resource requests\n"
 			}
@@ -0,0 +1,204 @@
+package environment
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/gpu"
+)
+
+// GPU allocation: a running environment holds its whole GPUs and MIG
+// slices, and another environment can't start on them unless both were
+// created with --shared.
+
+// GPUDevices returns the devices the environment holds, as the NVIDIA
+// runtime takes them: "0" for a whole GPU, "0:1" for a MIG slice
+func (e *Environment) GPUDevices() []string {
+	var devices []string
+	for _, id := range e.GPUs {
+		devices = append(devices, strconv.Itoa(id))
+	}
+	return append(devices, e.MIGDevices...)
+}
+
+// ParseMIGDevice checks a MIG slice given as "<gpu>:<slice>", e.g. "0:1"
+func ParseMIGDevice(device string) error {
+	gpuIndex, slice, ok := strings.Cut(device, ":")
+	if !ok {
+		return fmt.Errorf("invalid MIG device %q: want <gpu>:<slice>, e.g. 0:1", device)
+	}
+	for _, n := range []string{gpuIndex, slice} {
+		if i, err := strconv.Atoi(n); err != nil || i < 0 {
+			return fmt.Errorf("invalid MIG device %q: want <gpu>:<slice>, e.g. 0:1", device)
+		}
+	}
+	return nil
+}
+
+// parentGPU returns the GPU a device is on
+func parentGPU(device string) string {
+	gpuIndex, _, _ := strings.Cut(device, ":")
+	return gpuIndex
+}
+
+// devicesOverlap reports whether two devices share hardware: they are the
+// same, or one is a whole GPU and the other a slice of it
+func devicesOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	whole := !strings.Contains(a, ":") || !strings.Contains(b, ":")
+	return whole && parentGPU(a) == parentGPU(b)
+}
+
+// gpuConflict returns the first of the running environments holding a
+// device env asks for, unless both share it, and that device
+func gpuConflict(env *Environment, running []*Environment) (*Environment, string) {
+	for _, device := range env.GPUDevices() {
+		for _, other := range running {
+			if other.ID == env.ID || (env.GPUShared && other.GPUShared) {
+				continue
+			}
+			for _, held := range other.GPUDevices() {
+				if devicesOverlap(device, held) {
+					return other, device
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// checkGPUs makes sure the devices env asks for exist, when nvidia-smi can
+// tell, and that no other running environment holds them
+func (m *Manager) checkGPUs(ctx context.Context, env *Environment) error {
+	devices := env.GPUDevices()
+	if len(devices) == 0 {
+		return nil
+	}
+
+	detector := gpu.NewNVIDIADetector()
+	if detector.IsAvailable() {
+		known := map[string]bool{}
+		if gpus, err := detector.Detect(); err == nil {
+			for _, g := range gpus {
+				known[strconv.Itoa(g.Index)] = true
+			}
+		}
+		if migs, err := detector.DetectMIG(); err == nil {
+			for _, mig := range migs {
+				known[mig.ID()] = true
+			}
+		}
+		for _, device := range devices {
+			if !known[device] {
+				return ErrGPUNotAvailable.WithEnv(env.ID, env.Name).WithSuggestion(fmt.Sprintf("there is no GPU device %s; run 'cm env list --gpus' to see them", device))
+			}
+		}
+	}
+
+	running, err := m.runningEnvironments(ctx)
+	if err != nil {
+		return err
+	}
+	if holder, device := gpuConflict(env, running); holder != nil {
+		return ErrGPUInUse.WithEnv(env.ID, env.Name).WithSuggestion(fmt.Sprintf(
+			"GPU %s is held by '%s'; stop it, pick another device, or create both environments with --shared", device, holder.Name))
+	}
+	return nil
+}
+
+// runningEnvironments returns the environments whose containers run
+func (m *Manager) runningEnvironments(ctx context.Context) ([]*Environment, error) {
+	envs, err := m.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var running []*Environment
+	for _, env := range envs {
+		if env, _ := m.syncStatus(ctx, env); env != nil && env.Status == StatusRunning {
+			running = append(running, env)
+		}
+	}
+	return running, nil
+}
+
+// GPUHolder is an environment holding a GPU or one of its MIG slices
+type GPUHolder struct {
+	Device      string // "0", or "0:1" for a MIG slice
+	Profile     string // MIG profile, e.g. "3g.20gb"
+	Environment string
+	Shared      bool
+}
+
+// GPUUsage is a GPU's load and the running environments holding it
+type GPUUsage struct {
+	Index   string
+	GPU     *gpu.GPU // nil when nvidia-smi isn't available
+	Holders []GPUHolder
+}
+
+// GPUUsage returns the host's GPUs, their utilization and the running
+// environments holding them or their MIG slices
+func (m *Manager) GPUUsage(ctx context.Context) ([]GPUUsage, error) {
+	byIndex := map[string]*GPUUsage{}
+	profiles := map[string]string{}
+
+	detector := gpu.NewNVIDIADetector()
+	if detector.IsAvailable() {
+		gpus, err := detector.Detect()
+		if err != nil {
+			return nil, err
+		}
+		for i := range gpus {
+			index := strconv.Itoa(gpus[i].Index)
+			byIndex[index] = &GPUUsage{Index: index, GPU: &gpus[i]}
+		}
+		if migs, err := detector.DetectMIG(); err == nil {
+			for _, mig := range migs {
+				profiles[mig.ID()] = mig.Profile
+			}
+		}
+	}
+
+	running, err := m.runningEnvironments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range running {
+		for _, device := range env.GPUDevices() {
+			index := parentGPU(device)
+			usage, ok := byIndex[index]
+			if !ok {
+				usage = &GPUUsage{Index: index}
+				byIndex[index] = usage
+			}
+			usage.Holders = append(usage.Holders, GPUHolder{
+				Device:      device,
+				Profile:     profiles[device],
+				Environment: env.Name,
+				Shared:      env.GPUShared,
+			})
+		}
+	}
+
+	usages := make([]GPUUsage, 0, len(byIndex))
+	for _, usage := range byIndex {
+		sort.Slice(usage.Holders, func(i, j int) bool {
+			if usage.Holders[i].Device != usage.Holders[j].Device {
+				return usage.Holders[i].Device < usage.Holders[j].Device
+			}
+			return usage.Holders[i].Environment < usage.Holders[j].Environment
+		})
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		a, _ := strconv.Atoi(usages[i].Index)
+		b, _ := strconv.Atoi(usages[j].Index)
+		return a < b
+	})
+	return usages, nil
+}
@@ -0,0 +1,33 @@
+package environment
+
+import (
+	"context"
+
+	"github.com/UPwith-me/Container-Maker/pkg/hostsfile"
+)
+
+// HostEntries returns a <name>.cm.local entry for each running environment,
+// pointing at its container's IP on the environment's own network
+func (m *Manager) HostEntries(ctx context.Context) ([]hostsfile.Entry, error) {
+	envs, err := m.List(ctx, EnvironmentListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []hostsfile.Entry
+	for _, env := range envs {
+		if env.Status != StatusRunning || env.ContainerID == "" {
+			continue
+		}
+		inspect, err := m.dockerClient.ContainerInspect(ctx, env.ContainerID)
+		if err != nil || inspect.NetworkSettings == nil {
+			continue
+		}
+		endpoint := inspect.NetworkSettings.Networks[env.NetworkName]
+		if endpoint == nil || endpoint.IPAddress == "" {
+			continue
+		}
+		entries = append(entries, hostsfile.Entry{IP: endpoint.IPAddress, Hostname: hostsfile.Hostname(env.Name)})
+	}
+	return entries, nil
+}
@@ -14,6 +14,10 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/devices"
+	"github.com/UPwith-me/Container-Maker/pkg/hostpath"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
@@ -108,6 +112,12 @@ func (m *Manager) Create(ctx context.Context, opts EnvironmentCreateOptions) (*E
 	}
 	projectDir, _ = filepath.Abs(projectDir)
 
+	for _, device := range opts.MIGDevices {
+		if err := ParseMIGDevice(device); err != nil {
+			return nil, WrapError(err, "INVALID_MIG_DEVICE", "invalid --mig device")
+		}
+	}
+
 	// Create environment
 	env := &Environment{
 		ID:          generateID(),
@@ -123,6 +133,8 @@ func (m *Manager) Create(ctx context.Context, opts EnvironmentCreateOptions) (*E
 		Ports:       make(map[string]int),
 		LinkedEnvs:  []string{},
 		GPUs:        opts.GPUs,
+		MIGDevices:  opts.MIGDevices,
+		GPUShared:   opts.GPUShared,
 		MemoryLimit: opts.Memory,
 		CPULimit:    opts.CPU,
 	}
@@ -178,6 +190,10 @@ func (m *Manager) Create(ctx context.Context, opts EnvironmentCreateOptions) (*E
 
 // startEnvironment starts the container for an environment
 func (m *Manager) startEnvironment(ctx context.Context, env *Environment, opts EnvironmentCreateOptions) error {
+	if err := m.checkGPUs(ctx, env); err != nil {
+		return err
+	}
+
 	// Load devcontainer.json or template
 	cfg, err := m.loadConfig(env)
 	if err != nil {
@@ -225,22 +241,63 @@ func (m *Manager) startEnvironment(ctx context.Context, env *Environment, opts E
 		containerConfig.Env = append(containerConfig.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	workspaceBind, err := hostpath.New("docker").Bind(env.ProjectDir, workspaceDir)
+	if err != nil {
+		return err
+	}
+
 	hostConfig := &container.HostConfig{
-		Binds:       []string{fmt.Sprintf("%s:%s", env.ProjectDir, workspaceDir)},
+		Binds:       []string{workspaceBind},
 		NetworkMode: container.NetworkMode(env.NetworkName),
 	}
 
 	// Add mounts from config
 	hostConfig.Binds = append(hostConfig.Binds, cfg.Mounts...)
 
-	// Add GPU support
-	if len(env.GPUs) > 0 || len(opts.GPUs) > 0 {
-		hostConfig.Resources.DeviceRequests = []container.DeviceRequest{
-			{
-				Driver:       "nvidia",
-				Count:        -1, // All GPUs or specific ones
-				Capabilities: [][]string{{"gpu"}},
-			},
+	// Add /etc/hosts entries and DNS settings
+	extraHosts, err := cfg.ExtraHosts()
+	if err != nil {
+		return ErrInvalidConfig.WithSuggestion(err.Error())
+	}
+	if err := cfg.DNS.Validate(); err != nil {
+		return ErrInvalidConfig.WithSuggestion(err.Error())
+	}
+	hostConfig.ExtraHosts = extraHosts
+	if cfg.DNS != nil {
+		hostConfig.DNS = cfg.DNS.Servers
+		hostConfig.DNSSearch = cfg.DNS.Search
+		hostConfig.DNSOptions = cfg.DNS.Options
+	}
+
+	// Add GPU support: only the allocated GPUs and MIG slices, or every
+	// device node of an AMD or Intel GPU, which can't be picked by ID
+	if ids := env.GPUDevices(); len(ids) > 0 {
+		switch vendor := runtime.DetectGPU().Type; vendor {
+		case "amd", "intel":
+			plan, err := devices.Resolve([]string{"gpu:" + vendor}, "docker")
+			if err != nil {
+				return err
+			}
+			for _, w := range plan.Warnings {
+				fmt.Printf("⚠️  %s\n", w)
+			}
+			for _, d := range plan.Devices {
+				hostConfig.Resources.Devices = append(hostConfig.Resources.Devices, container.DeviceMapping{
+					PathOnHost:        d.Host,
+					PathInContainer:   d.Container,
+					CgroupPermissions: "rwm",
+				})
+			}
+			hostConfig.Resources.DeviceCgroupRules = append(hostConfig.Resources.DeviceCgroupRules, plan.CgroupRules...)
+			hostConfig.GroupAdd = append(hostConfig.GroupAdd, plan.Groups...)
+		default:
+			hostConfig.Resources.DeviceRequests = []container.DeviceRequest{
+				{
+					Driver:       "nvidia",
+					DeviceIDs:    ids,
+					Capabilities: [][]string{{"gpu"}},
+				},
+			}
 		}
 	}
 
@@ -342,6 +399,9 @@ func (m *Manager) ensureImage(ctx context.Context, imageName string) error {
 	if err == nil {
 		return nil // Image exists
 	}
+	if offline.Enabled() {
+		return offline.Missing("image", imageName)
+	}
 
 	// Pull image
 	fmt.Printf("📥 Pulling image %s...\n", imageName)
@@ -436,13 +496,22 @@ func (m *Manager) Start(ctx context.Context, nameOrID string) error {
 		return ErrContainerNotFound.WithEnv(env.ID, env.Name)
 	}
 
+	if err := m.checkGPUs(ctx, env); err != nil {
+		return err
+	}
+
 	if err := m.dockerClient.ContainerStart(ctx, env.ContainerID, container.StartOptions{}); err != nil {
 		return WrapError(err, "CONTAINER_START_ERROR", "failed to start container")
 	}
 
 	env.Status = StatusRunning
 	env.UpdatedAt = time.Now()
-	return m.store.Save(env)
+	if err := m.store.Save(env); err != nil {
+		return err
+	}
+
+	m.reapplyLinkPolicies(ctx, env)
+	return nil
 }
 
 // Stop stops a running environment
@@ -647,6 +716,10 @@ func (m *Manager) Link(ctx context.Context, env1ID, env2ID string, opts Environm
 		return ErrSelfLink
 	}
 
+	if err := opts.Policy.Validate(); err != nil {
+		return err
+	}
+
 	env1, err := m.Get(ctx, env1ID)
 	if err != nil {
 		return err
@@ -669,6 +742,19 @@ func (m *Manager) Link(ctx context.Context, env1ID, env2ID string, opts Environm
 		return err
 	}
 
+	// Restrict the traffic over the link
+	if !opts.Policy.IsOpen() {
+		if err := m.applyLinkPolicy(ctx, env1, env2, opts.Policy); err != nil {
+			m.removeLinkPolicy(ctx, env1, env2)
+			_ = m.networkManager.UnlinkEnvironments(ctx, env1, env2)
+			return err
+		}
+		if env1.LinkPolicies == nil {
+			env1.LinkPolicies = make(map[string]*LinkPolicy)
+		}
+		env1.LinkPolicies[env2ID] = opts.Policy
+	}
+
 	// Update state
 	env1.LinkedEnvs = append(env1.LinkedEnvs, env2ID)
 	_ = m.store.Save(env1)
@@ -693,14 +779,19 @@ func (m *Manager) Unlink(ctx context.Context, env1ID, env2ID string) error {
 		return err
 	}
 
-	// Disconnect networks
+	// Disconnect networks and remove the link policy rules
 	_ = m.networkManager.UnlinkEnvironments(ctx, env1, env2)
+	if env1.LinkPolicies[env2ID] != nil || env2.LinkPolicies[env1ID] != nil {
+		m.removeLinkPolicy(ctx, env1, env2)
+	}
 
 	// Update state
 	env1.LinkedEnvs = removeFromSlice(env1.LinkedEnvs, env2ID)
+	delete(env1.LinkPolicies, env2ID)
 	_ = m.store.Save(env1)
 
 	env2.LinkedEnvs = removeFromSlice(env2.LinkedEnvs, env1ID)
+	delete(env2.LinkPolicies, env1ID)
 	_ = m.store.Save(env2)
 
 	return nil
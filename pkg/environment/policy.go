@@ -0,0 +1,310 @@
+package environment
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// DefaultFirewallImage runs iptables in a linked environment's network
+// namespace. It holds only iptables and is built from firewallDockerfile
+// the first time a link needs it; CM_FIREWALL_IMAGE names an image to pull
+// instead.
+const DefaultFirewallImage = "container-maker/firewall:1"
+
+// firewallDockerfile builds DefaultFirewallImage. Changing it needs a new
+// image tag, so hosts that built the old one build it again.
+const firewallDockerfile = `FROM alpine:3.22.1
+RUN apk add --no-cache iptables
+`
+
+// Directions of a link policy
+const (
+	LinkBoth    = "both"    // Either environment can open connections
+	LinkForward = "forward" // Only the first environment can open connections
+)
+
+// LinkPolicy limits the traffic over a link. The zero value allows all
+// traffic both ways, as a link without a policy does.
+type LinkPolicy struct {
+	Ports     []int  `json:"ports,omitempty"`     // Ports of the target the source may reach; empty = all
+	Direction string `json:"direction,omitempty"` // LinkBoth (default) or LinkForward
+}
+
+// Validate checks the ports and direction of a policy
+func (p *LinkPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, port := range p.Ports {
+		if port < 1 || port > 65535 {
+			return ErrInvalidConfig.WithSuggestion(fmt.Sprintf("invalid port %d", port))
+		}
+	}
+	switch p.Direction {
+	case "", LinkBoth, LinkForward:
+		return nil
+	default:
+		return ErrInvalidConfig.WithSuggestion(
+			fmt.Sprintf("unknown direction %q (use %s or %s)", p.Direction, LinkBoth, LinkForward),
+		)
+	}
+}
+
+// IsOpen reports whether the policy allows everything
+func (p *LinkPolicy) IsOpen() bool {
+	return p == nil || (len(p.Ports) == 0 && p.Direction != LinkForward)
+}
+
+// String describes the policy, e.g. "ports 8080,9090, forward only"
+func (p *LinkPolicy) String() string {
+	if p.IsOpen() {
+		return "open"
+	}
+	var parts []string
+	if len(p.Ports) > 0 {
+		ports := make([]string, len(p.Ports))
+		for i, port := range p.Ports {
+			ports[i] = fmt.Sprint(port)
+		}
+		parts = append(parts, "ports "+strings.Join(ports, ","))
+	}
+	if p.Direction == LinkForward {
+		parts = append(parts, "forward only")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// linkChain names the iptables chain that filters traffic from a peer
+// environment. Chain names are limited to 28 characters.
+func linkChain(peerID string) string {
+	id := strings.ToUpper(strings.TrimPrefix(peerID, "env-"))
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return "CM-LINK-" + id
+}
+
+// firewallScript returns the shell script that (re)creates a chain letting
+// the peer IPs open connections only to the given ports. With no ports the
+// peer cannot open connections at all; replies are always allowed.
+func firewallScript(chain string, peerIPs []string, ports []int) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "iptables -N %s 2>/dev/null || iptables -F %s\n", chain, chain)
+	fmt.Fprintf(&b, "iptables -C INPUT -j %s 2>/dev/null || iptables -I INPUT -j %s\n", chain, chain)
+	fmt.Fprintf(&b, "iptables -A %s -m conntrack --ctstate ESTABLISHED,RELATED -j RETURN\n", chain)
+	for _, ip := range peerIPs {
+		for _, port := range ports {
+			for _, proto := range []string{"tcp", "udp"} {
+				fmt.Fprintf(&b, "iptables -A %s -s %s -p %s --dport %d -j RETURN\n", chain, ip, proto, port)
+			}
+		}
+		fmt.Fprintf(&b, "iptables -A %s -s %s -j REJECT\n", chain, ip)
+	}
+	return b.String()
+}
+
+// removeFirewallScript returns the shell script that removes a chain
+func removeFirewallScript(chain string) string {
+	return fmt.Sprintf("while iptables -D INPUT -j %s 2>/dev/null; do :; done\n"+
+		"iptables -F %s 2>/dev/null || true\n"+
+		"iptables -X %s 2>/dev/null || true\n", chain, chain, chain)
+}
+
+// applyLinkPolicy enforces the policy of the link from src to dst: the
+// target only accepts the allowed ports from the source and, for a forward
+// link, the source accepts no new connections from the target. Both must be
+// running; the rules live in their network namespaces and are applied
+// again when either starts.
+func (m *Manager) applyLinkPolicy(ctx context.Context, src, dst *Environment, policy *LinkPolicy) error {
+	if src.Status != StatusRunning || dst.Status != StatusRunning {
+		return nil
+	}
+
+	srcIPs, err := m.containerIPs(ctx, src.ContainerID)
+	if err != nil {
+		return err
+	}
+	dstIPs, err := m.containerIPs(ctx, dst.ContainerID)
+	if err != nil {
+		return err
+	}
+
+	dstScript := removeFirewallScript(linkChain(src.ID))
+	if len(policy.Ports) > 0 {
+		dstScript = firewallScript(linkChain(src.ID), srcIPs, policy.Ports)
+	}
+	if err := m.runFirewall(ctx, dst.ContainerID, dstScript); err != nil {
+		return err
+	}
+
+	srcScript := removeFirewallScript(linkChain(dst.ID))
+	if policy.Direction == LinkForward {
+		srcScript = firewallScript(linkChain(dst.ID), dstIPs, nil)
+	}
+	return m.runFirewall(ctx, src.ContainerID, srcScript)
+}
+
+// removeLinkPolicy removes the rules of the link between two environments
+func (m *Manager) removeLinkPolicy(ctx context.Context, env1, env2 *Environment) {
+	if env1.Status == StatusRunning {
+		_ = m.runFirewall(ctx, env1.ContainerID, removeFirewallScript(linkChain(env2.ID)))
+	}
+	if env2.Status == StatusRunning {
+		_ = m.runFirewall(ctx, env2.ContainerID, removeFirewallScript(linkChain(env1.ID)))
+	}
+}
+
+// reapplyLinkPolicies applies the policies of all links of an environment
+// again, as a started container has a new network namespace and may have
+// new IP addresses
+func (m *Manager) reapplyLinkPolicies(ctx context.Context, env *Environment) {
+	for peerID, policy := range env.LinkPolicies {
+		peer, err := m.Get(ctx, peerID)
+		if err != nil {
+			continue
+		}
+		if err := m.applyLinkPolicy(ctx, env, peer, policy); err != nil {
+			fmt.Printf("Warning: failed to apply the policy of the link to %s: %v\n", peer.Name, err)
+		}
+	}
+
+	others, err := m.store.List()
+	if err != nil {
+		return
+	}
+	for _, peer := range others {
+		policy, ok := peer.LinkPolicies[env.ID]
+		if !ok || peer.ID == env.ID {
+			continue
+		}
+		if err := m.applyLinkPolicy(ctx, peer, env, policy); err != nil {
+			fmt.Printf("Warning: failed to apply the policy of the link from %s: %v\n", peer.Name, err)
+		}
+	}
+}
+
+// containerIPs returns the IP addresses of a container on all its networks
+func (m *Manager) containerIPs(ctx context.Context, containerID string) ([]string, error) {
+	inspect, err := m.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, WrapError(err, "CONTAINER_INSPECT_ERROR", "failed to inspect container")
+	}
+
+	var ips []string
+	if inspect.NetworkSettings != nil {
+		for _, endpoint := range inspect.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				ips = append(ips, endpoint.IPAddress)
+			}
+		}
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+// runFirewall runs a script in a short-lived container that shares the
+// network namespace of a container, so it can change its iptables rules
+// without the dev container needing NET_ADMIN or iptables
+func (m *Manager) runFirewall(ctx context.Context, containerID, script string) error {
+	img := os.Getenv("CM_FIREWALL_IMAGE")
+	ensure := m.ensureImage
+	if img == "" {
+		img = DefaultFirewallImage
+		ensure = m.ensureFirewallImage
+	}
+	if err := ensure(ctx, img); err != nil {
+		return err
+	}
+
+	resp, err := m.dockerClient.ContainerCreate(ctx,
+		&container.Config{
+			Image:      img,
+			Entrypoint: []string{"sh", "-c", script},
+			Labels:     map[string]string{LabelManagedBy: "container-maker"},
+		},
+		&container.HostConfig{
+			NetworkMode: container.NetworkMode("container:" + containerID),
+			CapAdd:      []string{"NET_ADMIN"},
+		}, nil, nil, "")
+	if err != nil {
+		return WrapError(err, "FIREWALL_ERROR", "failed to create the firewall container")
+	}
+	defer func() {
+		_ = m.dockerClient.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := m.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return WrapError(err, "FIREWALL_ERROR", "failed to start the firewall container")
+	}
+
+	statusCh, errCh := m.dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return WrapError(err, "FIREWALL_ERROR", "failed to wait for the firewall container")
+	case status := <-statusCh:
+		if status.StatusCode == 0 {
+			return nil
+		}
+	}
+
+	var out bytes.Buffer
+	if logs, err := m.dockerClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true}); err == nil {
+		_, _ = stdcopy.StdCopy(&out, &out, logs)
+		logs.Close()
+	}
+	return ErrFirewallFailed.WithSuggestion(strings.TrimSpace(out.String()))
+}
+
+// ensureFirewallImage builds DefaultFirewallImage unless it exists
+func (m *Manager) ensureFirewallImage(ctx context.Context, img string) error {
+	if _, _, err := m.dockerClient.ImageInspectWithRaw(ctx, img); err == nil {
+		return nil
+	}
+	if offline.Enabled() {
+		return offline.Missing("image", img)
+	}
+
+	var buildContext bytes.Buffer
+	tw := tar.NewWriter(&buildContext)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(firewallDockerfile))}); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(firewallDockerfile)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("🔨 Building image %s...\n", img)
+	resp, err := m.dockerClient.ImageBuild(ctx, &buildContext, build.ImageBuildOptions{
+		Tags:        []string{img},
+		Remove:      true,
+		ForceRemove: true,
+		Labels:      map[string]string{LabelManagedBy: "container-maker"},
+	})
+	if err != nil {
+		return WrapError(err, "IMAGE_BUILD_ERROR", "failed to build the firewall image")
+	}
+	defer resp.Body.Close()
+
+	// A failed step is reported in the build's output, not as an error
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil); err != nil {
+		return WrapError(err, "IMAGE_BUILD_ERROR", "failed to build the firewall image")
+	}
+	fmt.Printf("✅ Image %s ready\n", img)
+	return nil
+}
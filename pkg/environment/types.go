@@ -42,12 +42,15 @@ type Environment struct {
 	Ports       map[string]int `json:"ports,omitempty"`        // Service -> Host port
 
 	// Environment linking
-	LinkedEnvs []string `json:"linked_envs,omitempty"` // IDs of linked environments
+	LinkedEnvs   []string               `json:"linked_envs,omitempty"`   // IDs of linked environments
+	LinkPolicies map[string]*LinkPolicy `json:"link_policies,omitempty"` // Linked environment ID -> policy of the link to it
 
 	// Resources
-	GPUs        []int   `json:"gpus,omitempty"`         // Allocated GPU IDs
-	MemoryLimit string  `json:"memory_limit,omitempty"` // e.g., "8g"
-	CPULimit    float64 `json:"cpu_limit,omitempty"`    // e.g., 4.0
+	GPUs        []int    `json:"gpus,omitempty"`         // Allocated GPU IDs
+	MIGDevices  []string `json:"mig_devices,omitempty"`  // Allocated MIG slices, "<gpu>:<slice>"
+	GPUShared   bool     `json:"gpu_shared,omitempty"`   // Other shared environments may hold the same GPUs
+	MemoryLimit string   `json:"memory_limit,omitempty"` // e.g., "8g"
+	CPULimit    float64  `json:"cpu_limit,omitempty"`    // e.g., 4.0
 
 	// Status
 	Status    EnvironmentStatus `json:"status"`
@@ -79,10 +82,12 @@ type EnvironmentCreateOptions struct {
 	LinkTo      []string // Environment names to link to
 
 	// Resources
-	GPUs     []int   // Specific GPU IDs (empty = auto)
-	GPUCount int     // Number of GPUs needed
-	Memory   string  // Memory limit
-	CPU      float64 // CPU limit
+	GPUs       []int    // Specific GPU IDs (empty = auto)
+	MIGDevices []string // MIG slices, "<gpu>:<slice>"
+	GPUShared  bool     // Share the GPUs with other shared environments
+	GPUCount   int      // Number of GPUs needed
+	Memory     string   // Memory limit
+	CPU        float64  // CPU limit
 
 	// Options
 	NoStart bool              // Create but don't start
@@ -112,9 +117,10 @@ type EnvironmentFilter struct {
 
 // EnvironmentLinkOptions contains options for linking environments
 type EnvironmentLinkOptions struct {
-	Bidirectional bool   // Link both ways
-	ShareVolumes  bool   // Share named volumes
-	DNSAlias      string // Custom DNS alias
+	Bidirectional bool        // Link both ways
+	ShareVolumes  bool        // Share named volumes
+	DNSAlias      string      // Custom DNS alias
+	Policy        *LinkPolicy // Allowed ports and direction; nil = all traffic
 }
 
 // EnvironmentMetrics contains real-time metrics for an environment
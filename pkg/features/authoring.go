@@ -0,0 +1,312 @@
+package features
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Layout of a feature repository, as used by devcontainers/feature-starter:
+//
+//	src/<id>/devcontainer-feature.json
+//	src/<id>/install.sh
+//	test/<id>/test.sh
+//	test/<id>/scenarios.json
+//	test/<id>/<scenario>.sh
+const (
+	FeatureMetadataFile = "devcontainer-feature.json"
+	FeatureInstallFile  = "install.sh"
+	FeatureTestFile     = "test.sh"
+	FeatureScenarioFile = "scenarios.json"
+)
+
+var (
+	featureIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	semverPattern    = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`)
+)
+
+// LocalFeature is a feature being authored in a local source directory
+type LocalFeature struct {
+	Dir      string // src/<id>
+	TestDir  string // test/<id>, empty when the feature has no tests
+	Metadata *Feature
+	Raw      []byte // devcontainer-feature.json as written by the author
+}
+
+// ScaffoldOptions configures a new feature
+type ScaffoldOptions struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// Scaffold writes a new feature with metadata, install script and tests into
+// root. Existing files are never overwritten.
+func Scaffold(root string, opts ScaffoldOptions) ([]string, error) {
+	if !featureIDPattern.MatchString(opts.ID) {
+		return nil, fmt.Errorf("invalid feature id %q: use lowercase letters, digits and dashes", opts.ID)
+	}
+	if opts.Name == "" {
+		opts.Name = opts.ID
+	}
+	if opts.Description == "" {
+		opts.Description = fmt.Sprintf("Installs %s", opts.Name)
+	}
+
+	meta := map[string]interface{}{
+		"id":          opts.ID,
+		"version":     "1.0.0",
+		"name":        opts.Name,
+		"description": opts.Description,
+		"options": map[string]interface{}{
+			"version": map[string]interface{}{
+				"type":        "string",
+				"proposals":   []string{"latest"},
+				"default":     "latest",
+				"description": "Version to install",
+			},
+		},
+		"installsAfter": []string{"ghcr.io/devcontainers/features/common-utils"},
+	}
+	metaJSON, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios := map[string]interface{}{
+		"specific_version": map[string]interface{}{
+			"image": DefaultTestImage,
+			"features": map[string]interface{}{
+				opts.ID: map[string]interface{}{"version": "latest"},
+			},
+		},
+	}
+	scenarioJSON, err := json.MarshalIndent(scenarios, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	envName := optionEnvName("version")
+	files := []struct {
+		path string
+		data string
+		mode os.FileMode
+	}{
+		{filepath.Join("src", opts.ID, FeatureMetadataFile), string(metaJSON) + "\n", 0644},
+		{filepath.Join("src", opts.ID, FeatureInstallFile), fmt.Sprintf(installTemplate, opts.Name, envName), 0755},
+		{filepath.Join("test", opts.ID, FeatureTestFile), fmt.Sprintf(testTemplate, opts.ID), 0755},
+		{filepath.Join("test", opts.ID, FeatureScenarioFile), string(scenarioJSON) + "\n", 0644},
+		{filepath.Join("test", opts.ID, "specific_version.sh"), fmt.Sprintf(testTemplate, opts.ID), 0755},
+	}
+
+	var written []string
+	for _, f := range files {
+		path := filepath.Join(root, f.path)
+		if _, err := os.Stat(path); err == nil {
+			return written, fmt.Errorf("%s already exists", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, []byte(f.data), f.mode); err != nil {
+			return written, err
+		}
+		written = append(written, f.path)
+	}
+	return written, nil
+}
+
+const installTemplate = `#!/bin/sh
+set -e
+
+# Options from devcontainer-feature.json arrive as upper-case environment variables
+VERSION="${%[2]s:-latest}"
+
+echo "Activating feature '%[1]s' (version: ${VERSION})"
+
+# The user the dev container runs as, provided by the tooling
+echo "Remote user: ${_REMOTE_USER:-root}"
+
+# TODO: install %[1]s here
+`
+
+const testTemplate = `#!/bin/bash
+set -e
+
+# Provided by 'cm feature test'
+source dev-container-features-test-lib
+
+# check <LABEL> <cmd> [args...]
+check "feature %s activated" bash -c "true"
+
+reportResults
+`
+
+// LoadLocalFeature reads a feature from its source directory. dir may point at
+// src/<id> directly or at a repository root containing a single feature.
+func LoadLocalFeature(dir string) (*LocalFeature, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	metaPath := filepath.Join(dir, FeatureMetadataFile)
+	if _, err := os.Stat(metaPath); err != nil {
+		ids, listErr := ListLocalFeatures(dir)
+		if listErr != nil || len(ids) != 1 {
+			return nil, fmt.Errorf("%s not found in %s", FeatureMetadataFile, dir)
+		}
+		dir = filepath.Join(dir, "src", ids[0])
+		metaPath = filepath.Join(dir, FeatureMetadataFile)
+	}
+
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	feature := &LocalFeature{Dir: dir, Raw: raw, Metadata: &Feature{}}
+	if err := json.Unmarshal(raw, feature.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", metaPath, err)
+	}
+
+	// test/<id> sits next to src/ in the repository layout
+	testDir := filepath.Join(filepath.Dir(filepath.Dir(dir)), "test", filepath.Base(dir))
+	if info, err := os.Stat(testDir); err == nil && info.IsDir() {
+		feature.TestDir = testDir
+	}
+	return feature, nil
+}
+
+// ListLocalFeatures returns the ids of all features under root/src
+func ListLocalFeatures(root string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, "src"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, "src", e.Name(), FeatureMetadataFile)); err == nil {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Validate checks the feature against the rules registries and tooling rely on
+func (f *LocalFeature) Validate() []error {
+	var errs []error
+	meta := f.Metadata
+
+	if meta.ID == "" {
+		errs = append(errs, fmt.Errorf("id is required"))
+	} else {
+		if !featureIDPattern.MatchString(meta.ID) {
+			errs = append(errs, fmt.Errorf("id %q must be lowercase letters, digits and dashes", meta.ID))
+		}
+		if meta.ID != filepath.Base(f.Dir) {
+			errs = append(errs, fmt.Errorf("id %q does not match directory name %q", meta.ID, filepath.Base(f.Dir)))
+		}
+	}
+	if !semverPattern.MatchString(meta.Version) {
+		errs = append(errs, fmt.Errorf("version %q must be semantic (MAJOR.MINOR.PATCH)", meta.Version))
+	}
+	if meta.Name == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+
+	install := filepath.Join(f.Dir, FeatureInstallFile)
+	if info, err := os.Stat(install); err != nil {
+		errs = append(errs, fmt.Errorf("%s is missing", FeatureInstallFile))
+	} else if info.Mode().Perm()&0111 == 0 {
+		errs = append(errs, fmt.Errorf("%s is not executable", FeatureInstallFile))
+	}
+
+	for name, raw := range meta.Options {
+		opt, ok := raw.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Errorf("option %q must be an object", name))
+			continue
+		}
+		switch opt["type"] {
+		case "string", "boolean":
+		default:
+			errs = append(errs, fmt.Errorf("option %q has unsupported type %v", name, opt["type"]))
+		}
+	}
+	return errs
+}
+
+// Package returns the feature directory as an uncompressed tar archive, the
+// layer format of published features. Entries are sorted so the digest is
+// reproducible.
+func (f *LocalFeature) Package() ([]byte, error) {
+	var files []string
+	err := filepath.Walk(f.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(f.Dir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		mode := int64(0644)
+		if info.Mode().Perm()&0111 != 0 || strings.HasSuffix(rel, ".sh") {
+			mode = 0755
+		}
+		header := &tar.Header{
+			Name:     "./" + filepath.ToSlash(rel),
+			Mode:     mode,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// optionEnvName converts an option name into the environment variable
+// install.sh receives: upper case, with anything but letters, digits and
+// underscores replaced by underscores
+func optionEnvName(name string) string {
+	name = regexp.MustCompile(`[^\w]`).ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return strings.ToUpper(name)
+}
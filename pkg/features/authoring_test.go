@@ -0,0 +1,202 @@
+package features
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScaffoldAndLoad(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Scaffold(root, ScaffoldOptions{ID: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Scaffold(root, ScaffoldOptions{ID: "hello"}); err == nil {
+		t.Error("scaffolding over an existing feature should fail")
+	}
+	if _, err := Scaffold(root, ScaffoldOptions{ID: "Bad_ID"}); err == nil {
+		t.Error("invalid id should be rejected")
+	}
+
+	// A repository root with one feature resolves to it
+	f, err := LoadLocalFeature(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Metadata.ID != "hello" || f.Metadata.Version != "1.0.0" || f.TestDir == "" {
+		t.Fatalf("loaded feature = %+v", f)
+	}
+	if errs := f.Validate(); len(errs) > 0 {
+		t.Fatalf("scaffolded feature is invalid: %v", errs)
+	}
+
+	cases, err := f.TestCases([]string{"debian:12", "ubuntu:22.04"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 3 || cases[1].Image != "ubuntu:22.04" || cases[2].Name != "specific_version" {
+		t.Fatalf("test cases = %+v", cases)
+	}
+	if env := f.OptionEnv(cases[2].Options); env["VERSION"] != "latest" {
+		t.Errorf("option env = %v", env)
+	}
+
+	data, err := f.Package()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(bytes.NewReader(data))
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+		if h.Name == "./install.sh" && h.Mode != 0755 {
+			t.Errorf("install.sh mode = %o", h.Mode)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"./devcontainer-feature.json", "./install.sh"}) {
+		t.Errorf("archive entries = %v", names)
+	}
+
+	os.Chmod(filepath.Join(f.Dir, "install.sh"), 0644)
+	f.Metadata.Version = "1.0"
+	if errs := f.Validate(); len(errs) != 2 {
+		t.Errorf("expected version and mode errors, got %v", errs)
+	}
+}
+
+func TestTagsToPublish(t *testing.T) {
+	tests := []struct {
+		version   string
+		published []string
+		want      []string
+	}{
+		{"1.2.3", nil, []string{"1.2.3", "1.2", "1", "latest"}},
+		{"1.2.4", []string{"1.2.3", "1.2", "1", "latest"}, []string{"1.2.4", "1.2", "1", "latest"}},
+		{"1.2.4", []string{"1.3.0", "2.0.0"}, []string{"1.2.4", "1.2"}},
+		{"1.2.4", []string{"1.2.5"}, []string{"1.2.4"}},
+	}
+	for _, tt := range tests {
+		if got := TagsToPublish(tt.version, tt.published); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TagsToPublish(%s, %v) = %v, want %v", tt.version, tt.published, got, tt.want)
+		}
+	}
+}
+
+// fakeRegistry implements the subset of the OCI distribution API used for pushes
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte // repo:tag
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		if req.URL.Path == "/token" {
+			if user, pass, _ := req.BasicAuth(); user == "me" && pass == "pw" {
+				json.NewEncoder(w).Encode(map[string]string{"token": "secret"})
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+req.Host+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(path, "/tags/list"):
+		w.WriteHeader(http.StatusNotFound)
+	case strings.HasSuffix(path, "/blobs/uploads/") && req.Method == http.MethodPost:
+		w.Header().Set("Location", "/upload/1")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/"):
+		digest := path[strings.LastIndex(path, "/")+1:]
+		if _, ok := r.blobs[digest]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case strings.Contains(path, "/manifests/"):
+		i := strings.Index(path, "/manifests/")
+		data, _ := io.ReadAll(req.Body)
+		r.manifests[path[:i]+":"+path[i+len("/manifests/"):]] = data
+		w.WriteHeader(http.StatusCreated)
+	case req.URL.Path == "/upload/1" && req.Method == http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if digest != digestOf(data) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	registry := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	root := t.TempDir()
+	if _, err := Scaffold(root, ScaffoldOptions{ID: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadLocalFeature(filepath.Join(root, "src", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := NewPublisher(PublishOptions{
+		Registry:  strings.TrimPrefix(server.URL, "http://"),
+		Namespace: "org/features",
+		Username:  "me",
+		Password:  "pw",
+		PlainHTTP: true,
+	})
+	result, err := publisher.Publish(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Tags, []string{"1.0.0", "1.0", "1", "latest"}) {
+		t.Errorf("tags = %v", result.Tags)
+	}
+
+	var manifest ociImageManifest
+	if err := json.Unmarshal(registry.manifests["org/features/hello:1"], &manifest); err != nil {
+		t.Fatalf("manifest for tag 1 missing: %v", err)
+	}
+	if manifest.Layers[0].MediaType != MediaTypeFeatureLayer || registry.blobs[manifest.Layers[0].Digest] == nil {
+		t.Errorf("layer not uploaded: %+v", manifest.Layers)
+	}
+	if !strings.Contains(manifest.Annotations["dev.containers.metadata"], `"id":"hello"`) {
+		t.Errorf("metadata annotation = %q", manifest.Annotations["dev.containers.metadata"])
+	}
+
+	if err := publisher.PublishCollection(context.Background(), []*LocalFeature{f}); err != nil {
+		t.Fatal(err)
+	}
+	if registry.manifests["org/features:latest"] == nil {
+		t.Error("collection manifest not pushed")
+	}
+}
@@ -2,14 +2,21 @@ package features
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 )
 
 // Feature represents a DevContainer Feature
@@ -21,37 +28,79 @@ type Feature struct {
 	Options       map[string]interface{} `json:"options"`
 	InstallSh     string                 // Content of install.sh
 	InstallsAfter []string               `json:"installsAfter,omitempty"`
+	DependsOn     map[string]interface{} `json:"dependsOn,omitempty"`
 }
 
+// Feature source kinds
+const (
+	SourceOCI     = "oci"
+	SourceLocal   = "local"
+	SourceTarball = "tarball"
+)
+
 // FeatureRef represents a reference to a feature in devcontainer.json
 type FeatureRef struct {
 	Source  string                 // Full feature reference (e.g., "ghcr.io/devcontainers/features/go:1")
+	Kind    string                 // SourceOCI, SourceLocal or SourceTarball
 	ID      string                 // Feature ID (e.g., "go")
 	Version string                 // Version (e.g., "1")
 	Options map[string]interface{} // Feature options from config
+	BaseDir string                 // Directory of devcontainer.json, for local features
+}
+
+// IsLocalFeature reports whether source is a local feature directory ("./my-feature")
+func IsLocalFeature(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// IsTarballFeature reports whether source is a feature tarball URL
+func IsTarballFeature(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
 }
 
 // ParseFeatureRef parses a feature reference string
 // Examples:
 //   - "ghcr.io/devcontainers/features/go:1"
 //   - "ghcr.io/devcontainers/features/docker-in-docker:2"
+//   - "./local-features/mytool"
+//   - "https://example.com/devcontainer-feature-mytool.tgz"
 func ParseFeatureRef(source string, options interface{}) (*FeatureRef, error) {
 	ref := &FeatureRef{
 		Source: source,
+		Kind:   SourceOCI,
 	}
 
-	// Parse version from source
-	if idx := strings.LastIndex(source, ":"); idx != -1 {
-		ref.Version = source[idx+1:]
-		source = source[:idx]
-	} else {
+	switch {
+	case IsLocalFeature(source):
+		ref.Kind = SourceLocal
+		ref.Version = "local"
+		ref.ID = filepath.Base(filepath.Clean(source))
+	case IsTarballFeature(source):
+		// By convention the file is named devcontainer-feature-<id>.tgz
+		ref.Kind = SourceTarball
 		ref.Version = "latest"
-	}
+		name := path.Base(strings.SplitN(source, "?", 2)[0])
+		for _, ext := range []string{".tgz", ".tar.gz", ".tar"} {
+			name = strings.TrimSuffix(name, ext)
+		}
+		ref.ID = strings.TrimPrefix(name, "devcontainer-feature-")
+		if ref.ID == "" {
+			return nil, fmt.Errorf("cannot derive a feature id from %s", source)
+		}
+	default:
+		// Parse version from source
+		if idx := strings.LastIndex(source, ":"); idx != -1 {
+			ref.Version = source[idx+1:]
+			source = source[:idx]
+		} else {
+			ref.Version = "latest"
+		}
 
-	// Extract feature ID (last path component)
-	parts := strings.Split(source, "/")
-	if len(parts) > 0 {
-		ref.ID = parts[len(parts)-1]
+		// Extract feature ID (last path component)
+		parts := strings.Split(source, "/")
+		if len(parts) > 0 {
+			ref.ID = parts[len(parts)-1]
+		}
 	}
 
 	// Parse options
@@ -97,8 +146,21 @@ func DownloadFeature(ref *FeatureRef, destDir string) (*Feature, error) {
 		return nil, fmt.Errorf("failed to create feature directory: %w", err)
 	}
 
+	switch ref.Kind {
+	case SourceLocal:
+		return copyLocalFeature(ref, featureDir)
+	case SourceTarball:
+		if offline.Enabled() {
+			return nil, fmt.Errorf("offline mode: feature %s is a URL; vendor it as a local feature (\"./%s\") to use it offline", ref.Source, ref.ID)
+		}
+		return downloadAndExtractTarball(ref.Source, featureDir, ref)
+	}
+
 	// For ghcr.io features, we need to use OCI API
 	// This is a simplified version - production would need proper authentication
+	if offline.Enabled() {
+		return nil, offline.Missing("feature", ref.Source)
+	}
 	if strings.HasPrefix(ref.Source, "ghcr.io/devcontainers/features/") {
 		return downloadGHCRFeature(ref, featureDir)
 	}
@@ -106,6 +168,95 @@ func DownloadFeature(ref *FeatureRef, destDir string) (*Feature, error) {
 	return nil, fmt.Errorf("unsupported feature source: %s", ref.Source)
 }
 
+// LocalFeatureDir returns the absolute directory of a local feature
+func LocalFeatureDir(ref *FeatureRef) string {
+	if filepath.IsAbs(ref.Source) {
+		return ref.Source
+	}
+	return filepath.Join(ref.BaseDir, filepath.FromSlash(ref.Source))
+}
+
+// copyLocalFeature copies a local feature directory into destDir
+func copyLocalFeature(ref *FeatureRef, destDir string) (*Feature, error) {
+	srcDir := LocalFeatureDir(ref)
+	if _, err := os.Stat(filepath.Join(srcDir, "install.sh")); err != nil {
+		return nil, fmt.Errorf("local feature %s has no install.sh", ref.Source)
+	}
+
+	feature := &Feature{ID: ref.ID, Version: ref.Version, Options: ref.Options}
+	err := filepath.Walk(srcDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+		switch filepath.ToSlash(rel) {
+		case "install.sh":
+			_ = os.Chmod(target, 0755)
+			feature.InstallSh = string(data)
+		case "devcontainer-feature.json":
+			if err := json.Unmarshal(data, feature); err != nil {
+				return fmt.Errorf("invalid devcontainer-feature.json in %s: %w", ref.Source, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return feature, nil
+}
+
+// LocalFeaturesDigest hashes the files of all local features in a features
+// map, so callers can tell when a local feature changed and a rebuild is due.
+// It returns an empty string when there are no local features.
+func LocalFeaturesDigest(features map[string]interface{}, baseDir string) string {
+	var sources []string
+	for source := range features {
+		if IsLocalFeature(source) {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return ""
+	}
+	sort.Strings(sources)
+
+	h := sha256.New()
+	for _, source := range sources {
+		dir := LocalFeatureDir(&FeatureRef{Source: source, BaseDir: baseDir})
+		fmt.Fprintf(h, "feature %s\n", source)
+		_ = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, p)
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(h, "%s %o %d\n", filepath.ToSlash(rel), info.Mode().Perm(), len(data))
+			h.Write(data)
+			return nil
+		})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 // downloadGHCRFeature downloads a feature from GitHub Container Registry
 func downloadGHCRFeature(ref *FeatureRef, destDir string) (*Feature, error) {
 	// Construct the OCI blob URL
@@ -121,8 +272,7 @@ func downloadGHCRFeature(ref *FeatureRef, destDir string) (*Feature, error) {
 	}
 	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get manifest: %w", err)
 	}
@@ -157,21 +307,30 @@ func downloadGHCRFeature(ref *FeatureRef, destDir string) (*Feature, error) {
 
 // downloadAndExtractTarball downloads and extracts a feature tarball
 func downloadAndExtractTarball(url string, destDir string, ref *FeatureRef) (*Feature, error) {
-	resp, err := http.Get(url)
+	resp, err := httpclient.Default().Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Decompress gzip
-	gzReader, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+	}
+
+	// Feature tarballs may or may not be gzipped
+	body := bufio.NewReader(resp.Body)
+	var archive io.Reader = body
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		defer gzReader.Close()
+		archive = gzReader
 	}
-	defer gzReader.Close()
 
 	// Extract tar
-	tarReader := tar.NewReader(gzReader)
+	tarReader := tar.NewReader(archive)
 
 	feature := &Feature{
 		ID:      ref.ID,
@@ -189,6 +348,9 @@ func downloadAndExtractTarball(url string, destDir string, ref *FeatureRef) (*Fe
 		}
 
 		targetPath := filepath.Join(destDir, header.Name)
+		if !strings.HasPrefix(targetPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			continue
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -286,11 +448,11 @@ func (fi *FeatureInstaller) GenerateDockerfileSnippet() string {
 			sb.WriteString(fmt.Sprintf("ENV %s\n", e))
 		}
 
-		// Copy and run install script
-		sb.WriteString(fmt.Sprintf("COPY --chown=root:root %s/install.sh /tmp/%s-install.sh\n",
+		// Copy the whole feature, install.sh may use other files in it
+		sb.WriteString(fmt.Sprintf("COPY --chown=root:root %s/ /tmp/dev-container-features/%s/\n",
+			feature.ID, feature.ID))
+		sb.WriteString(fmt.Sprintf("RUN cd /tmp/dev-container-features/%s && chmod +x install.sh && ./install.sh && rm -rf /tmp/dev-container-features/%s\n",
 			feature.ID, feature.ID))
-		sb.WriteString(fmt.Sprintf("RUN chmod +x /tmp/%s-install.sh && /tmp/%s-install.sh && rm /tmp/%s-install.sh\n",
-			feature.ID, feature.ID, feature.ID))
 	}
 
 	return sb.String()
@@ -1,6 +1,13 @@
 package features
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -47,6 +54,23 @@ func TestParseFeatureRef(t *testing.T) {
 			wantVersion: "latest",
 			wantErr:     false,
 		},
+		{
+			name:        "local feature directory",
+			source:      "./local-features/mytool",
+			wantID:      "mytool",
+			wantVersion: "local",
+		},
+		{
+			name:        "tarball URL",
+			source:      "https://example.com/releases/devcontainer-feature-mytool.tgz",
+			wantID:      "mytool",
+			wantVersion: "latest",
+		},
+		{
+			name:    "tarball URL without a name",
+			source:  "https://example.com/.tgz",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -190,6 +214,79 @@ func TestFeatureInstaller_SortByDependencies(t *testing.T) {
 	}
 }
 
+func TestDownloadLocalAndTarballFeatures(t *testing.T) {
+	configDir := t.TempDir()
+	featureDir := filepath.Join(configDir, "local-features", "mytool")
+	if err := os.MkdirAll(filepath.Join(featureDir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"devcontainer-feature.json": `{"id": "mytool", "version": "0.1.0", "name": "My Tool"}`,
+		"install.sh":                "#!/bin/sh\n. ./lib/helpers.sh\n",
+		"lib/helpers.sh":            "echo helper\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(featureDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requested := map[string]interface{}{"./local-features/mytool": map[string]interface{}{}}
+	digest := LocalFeaturesDigest(requested, configDir)
+	if digest == "" || LocalFeaturesDigest(map[string]interface{}{"ghcr.io/devcontainers/features/go:1": true}, configDir) != "" {
+		t.Fatal("digest should only cover local features")
+	}
+
+	ref, _ := ParseFeatureRef("./local-features/mytool", nil)
+	ref.BaseDir = configDir
+	dest := t.TempDir()
+	feature, err := DownloadFeature(ref, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feature.Name != "My Tool" || feature.InstallSh == "" {
+		t.Errorf("local feature = %+v", feature)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "mytool", "lib", "helpers.sh")); err != nil {
+		t.Error("support files of a local feature should be copied")
+	}
+
+	// Editing a local feature changes the digest
+	if err := os.WriteFile(filepath.Join(featureDir, "lib", "helpers.sh"), []byte("echo changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if LocalFeaturesDigest(requested, configDir) == digest {
+		t.Error("digest should change when a local feature file changes")
+	}
+
+	// Serve the same feature as a gzipped tarball
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	ref, err = ParseFeatureRef(server.URL+"/devcontainer-feature-mytool.tgz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feature, err = DownloadFeature(ref, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feature.ID != "mytool" || feature.Version != "0.1.0" || feature.InstallSh == "" {
+		t.Errorf("tarball feature = %+v", feature)
+	}
+}
+
 func containsString(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 && (s == substr || len(s) >= len(substr) && (s[:len(substr)] == substr || containsString(s[1:], substr)))
 }
@@ -0,0 +1,289 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tailscale/hujson"
+)
+
+// DefaultTestImage is the base image features are tested on when none is given
+const DefaultTestImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+// TestCase is one build-and-test run of a feature
+type TestCase struct {
+	Name    string
+	Image   string
+	Options map[string]interface{}
+	Script  string // test script, relative to the feature's test directory
+}
+
+// TestOptions configures RunTests
+type TestOptions struct {
+	Backend       string   // docker or podman
+	BaseImages    []string // images for the default test.sh run
+	SkipScenarios bool
+	Filter        string // only run cases whose name contains this
+	KeepImages    bool
+	Output        io.Writer
+}
+
+// TestResult is the outcome of a TestCase
+type TestResult struct {
+	Case     TestCase
+	Err      error
+	Duration time.Duration
+}
+
+// Passed reports whether the case built and its tests passed
+func (r TestResult) Passed() bool {
+	return r.Err == nil
+}
+
+// scenario is an entry of test/<id>/scenarios.json
+type scenario struct {
+	Image    string                 `json:"image"`
+	Features map[string]interface{} `json:"features"`
+}
+
+// TestCases lists the runs for a feature: test.sh with default options on
+// every base image, then each scenario from scenarios.json
+func (f *LocalFeature) TestCases(baseImages []string, skipScenarios bool) ([]TestCase, error) {
+	if f.TestDir == "" {
+		return nil, fmt.Errorf("no tests found for feature %s (expected test/%s/%s)", f.Metadata.ID, f.Metadata.ID, FeatureTestFile)
+	}
+	if len(baseImages) == 0 {
+		baseImages = []string{DefaultTestImage}
+	}
+
+	var cases []TestCase
+	if _, err := os.Stat(filepath.Join(f.TestDir, FeatureTestFile)); err == nil {
+		for _, image := range baseImages {
+			cases = append(cases, TestCase{Name: "default (" + image + ")", Image: image, Script: FeatureTestFile})
+		}
+	}
+
+	if skipScenarios {
+		return cases, nil
+	}
+	data, err := os.ReadFile(filepath.Join(f.TestDir, FeatureScenarioFile))
+	if os.IsNotExist(err) {
+		return cases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = hujson.Standardize(data); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FeatureScenarioFile, err)
+	}
+	var scenarios map[string]scenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FeatureScenarioFile, err)
+	}
+
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sc := scenarios[name]
+		tc := TestCase{Name: name, Image: sc.Image, Script: name + ".sh"}
+		if tc.Image == "" {
+			tc.Image = baseImages[0]
+		}
+		for ref, opts := range sc.Features {
+			if ref != f.Metadata.ID && ref != "./"+f.Metadata.ID {
+				return nil, fmt.Errorf("scenario %s: only the feature under test can be used, found %s", name, ref)
+			}
+			if m, ok := opts.(map[string]interface{}); ok {
+				tc.Options = m
+			}
+		}
+		if _, err := os.Stat(filepath.Join(f.TestDir, tc.Script)); err != nil {
+			return nil, fmt.Errorf("scenario %s has no test script %s", name, tc.Script)
+		}
+		cases = append(cases, tc)
+	}
+	return cases, nil
+}
+
+// OptionEnv returns the environment install.sh runs with: option defaults
+// from devcontainer-feature.json overridden by the given values
+func (f *LocalFeature) OptionEnv(given map[string]interface{}) map[string]string {
+	env := make(map[string]string)
+	for name, raw := range f.Metadata.Options {
+		if opt, ok := raw.(map[string]interface{}); ok && opt["default"] != nil {
+			env[optionEnvName(name)] = fmt.Sprintf("%v", opt["default"])
+		}
+	}
+	for name, value := range given {
+		env[optionEnvName(name)] = fmt.Sprintf("%v", value)
+	}
+	return env
+}
+
+// RunTests builds the feature onto each test image and runs its test scripts
+func RunTests(ctx context.Context, f *LocalFeature, opts TestOptions) ([]TestResult, error) {
+	if opts.Backend == "" {
+		opts.Backend = "docker"
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
+	cases, err := f.TestCases(opts.BaseImages, opts.SkipScenarios)
+	if err != nil {
+		return nil, err
+	}
+
+	libDir, err := os.MkdirTemp("", "cm-feature-lib-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(libDir)
+	libPath := filepath.Join(libDir, "dev-container-features-test-lib")
+	if err := os.WriteFile(libPath, []byte(testLib), 0755); err != nil {
+		return nil, err
+	}
+
+	var results []TestResult
+	for i, tc := range cases {
+		if opts.Filter != "" && !strings.Contains(tc.Name, opts.Filter) {
+			continue
+		}
+		fmt.Fprintf(opts.Output, "\n▶ %s\n", tc.Name)
+		start := time.Now()
+		tag := fmt.Sprintf("cm-feature-test-%s:%d", f.Metadata.ID, i)
+		err := runTestCase(ctx, f, tc, tag, libPath, opts)
+		if !opts.KeepImages {
+			_ = exec.Command(opts.Backend, "rmi", "-f", tag).Run()
+		}
+		results = append(results, TestResult{Case: tc, Err: err, Duration: time.Since(start)})
+	}
+	return results, nil
+}
+
+func runTestCase(ctx context.Context, f *LocalFeature, tc TestCase, tag, libPath string, opts TestOptions) error {
+	buildDir, err := os.MkdirTemp("", "cm-feature-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(buildDir)
+
+	archive, err := f.Package()
+	if err != nil {
+		return err
+	}
+	if err := extractTar(archive, filepath.Join(buildDir, "feature")); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(buildDir, "feature", "devcontainer-features.env"), []byte(envFile(f.OptionEnv(tc.Options))), 0644); err != nil {
+		return err
+	}
+	dockerfile := fmt.Sprintf(`FROM %s
+USER root
+COPY feature /tmp/dev-container-features/%[2]s
+RUN cd /tmp/dev-container-features/%[2]s \
+ && chmod +x install.sh \
+ && set -a && . ./devcontainer-features.env && set +a \
+ && ./install.sh
+`, tc.Image, f.Metadata.ID)
+	if err := os.WriteFile(filepath.Join(buildDir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		return err
+	}
+
+	build := exec.CommandContext(ctx, opts.Backend, "build", "-t", tag, buildDir)
+	build.Stdout = opts.Output
+	build.Stderr = opts.Output
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("install failed on %s: %w", tc.Image, err)
+	}
+
+	run := exec.CommandContext(ctx, opts.Backend, "run", "--rm",
+		"-v", f.TestDir+":/tmp/feature-tests:ro",
+		"-v", libPath+":/usr/local/bin/dev-container-features-test-lib:ro",
+		"-w", "/tmp/feature-tests",
+		"--entrypoint", "/bin/bash",
+		tag, tc.Script)
+	run.Stdout = opts.Output
+	run.Stderr = opts.Output
+	if err := run.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", tc.Script, err)
+	}
+	return nil
+}
+
+// envFile renders KEY="value" lines for sourcing from sh
+func envFile(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s=\"%s\"\n", k, escaper.Replace(env[k])))
+	}
+	return sb.String()
+}
+
+// testLib is a compatible subset of the devcontainers CLI test library
+const testLib = `#!/bin/bash
+FAILED=()
+
+check() {
+    LABEL=$1
+    shift
+    echo -e "\n🔄 Testing '$LABEL'"
+    if "$@"; then
+        echo "✅  Passed '$LABEL'"
+        return 0
+    else
+        echo "❌ $LABEL check failed."
+        FAILED+=("$LABEL")
+        return 1
+    fi
+}
+
+checkMultiple() {
+    PASSED=0
+    LABEL="$1"
+    shift; MINIMUMPASSED=$1
+    shift; EXPRESSION="$1"
+    while [ "$EXPRESSION" != "" ]; do
+        if $EXPRESSION; then ((PASSED++)); fi
+        shift; EXPRESSION=$1
+    done
+    if [ $PASSED -ge $MINIMUMPASSED ]; then
+        echo "✅ Passed '$LABEL'"
+        return 0
+    else
+        echo "❌ $LABEL check failed."
+        FAILED+=("$LABEL")
+        return 1
+    fi
+}
+
+reportResults() {
+    if [ ${#FAILED[@]} -ne 0 ]; then
+        echo -e "\n💥  Failed tests: ${FAILED[@]}"
+        exit 1
+    else
+        echo -e "\n💯  All passed!"
+        exit 0
+    fi
+}
+
+set +e
+`
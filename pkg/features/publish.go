@@ -0,0 +1,461 @@
+package features
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+)
+
+// Media types of devcontainer artifacts in OCI registries
+const (
+	MediaTypeManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig          = "application/vnd.devcontainers"
+	MediaTypeFeatureLayer    = "application/vnd.devcontainers.layer.v1+tar"
+	MediaTypeCollectionLayer = "application/vnd.devcontainers.collection.layer.v1+json"
+)
+
+// PublishOptions configures where and how features are pushed
+type PublishOptions struct {
+	Registry  string // e.g. ghcr.io
+	Namespace string // e.g. my-org/features
+	Username  string
+	Password  string
+	PlainHTTP bool // talk to the registry without TLS (local registries)
+	Client    *http.Client
+}
+
+// PublishResult describes a pushed feature
+type PublishResult struct {
+	Ref     string
+	Digest  string
+	Tags    []string
+	Skipped bool // the version was already published
+}
+
+// Publisher pushes features to an OCI registry
+type Publisher struct {
+	opts   PublishOptions
+	client *http.Client
+	tokens map[string]string // repository -> bearer token
+}
+
+// NewPublisher creates a publisher for the given registry namespace
+func NewPublisher(opts PublishOptions) *Publisher {
+	client := opts.Client
+	if client == nil {
+		client = httpclient.New(httpclient.Options{Timeout: 5 * time.Minute})
+	}
+	opts.Namespace = strings.Trim(opts.Namespace, "/")
+	return &Publisher{opts: opts, client: client, tokens: make(map[string]string)}
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int               `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociImageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Publish pushes a feature as <registry>/<namespace>/<id> tagged with its
+// version and, unless a newer release already owns them, its major, minor
+// and latest tags. A version that is already published is skipped.
+func (p *Publisher) Publish(ctx context.Context, f *LocalFeature) (*PublishResult, error) {
+	if errs := f.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("feature %s is invalid: %v", f.Metadata.ID, errs[0])
+	}
+
+	repo := p.opts.Namespace + "/" + f.Metadata.ID
+	result := &PublishResult{Ref: p.opts.Registry + "/" + repo + ":" + f.Metadata.Version}
+
+	published, err := p.listTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range published {
+		if tag == f.Metadata.Version {
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	layer, err := f.Package()
+	if err != nil {
+		return nil, err
+	}
+	var meta bytes.Buffer
+	if err := json.Compact(&meta, f.Raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FeatureMetadataFile, err)
+	}
+
+	annotations := map[string]string{
+		"dev.containers.metadata": meta.String(),
+		"com.github.package.type": "devcontainer_feature",
+	}
+	result.Tags = TagsToPublish(f.Metadata.Version, published)
+	result.Digest, err = p.pushArtifact(ctx, repo, layer, MediaTypeFeatureLayer,
+		"devcontainer-feature-"+f.Metadata.ID+".tgz", annotations, result.Tags)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PublishCollection pushes devcontainer-collection.json, the index of all
+// features in the namespace, as <registry>/<namespace>:latest
+func (p *Publisher) PublishCollection(ctx context.Context, features []*LocalFeature) error {
+	var list []json.RawMessage
+	for _, f := range features {
+		var meta bytes.Buffer
+		if err := json.Compact(&meta, f.Raw); err != nil {
+			return err
+		}
+		list = append(list, meta.Bytes())
+	}
+	collection, err := json.Marshal(map[string]interface{}{
+		"sourceInformation": map[string]string{"source": "container-maker"},
+		"features":          list,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.pushArtifact(ctx, p.opts.Namespace, collection, MediaTypeCollectionLayer,
+		"devcontainer-collection.json", nil, []string{"latest"})
+	return err
+}
+
+// pushArtifact uploads an empty config and a single layer, then tags a manifest
+func (p *Publisher) pushArtifact(ctx context.Context, repo string, layer []byte, layerType, title string, annotations map[string]string, tags []string) (string, error) {
+	config := []byte("{}")
+	configDigest, err := p.pushBlob(ctx, repo, config)
+	if err != nil {
+		return "", fmt.Errorf("failed to push config: %w", err)
+	}
+	layerDigest, err := p.pushBlob(ctx, repo, layer)
+	if err != nil {
+		return "", fmt.Errorf("failed to push layer: %w", err)
+	}
+
+	manifest, err := json.Marshal(ociImageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Config:        ociDescriptor{MediaType: MediaTypeConfig, Digest: configDigest, Size: len(config)},
+		Layers: []ociDescriptor{{
+			MediaType:   layerType,
+			Digest:      layerDigest,
+			Size:        len(layer),
+			Annotations: map[string]string{"org.opencontainers.image.title": title},
+		}},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, tag := range tags {
+		resp, err := p.do(ctx, repo, http.MethodPut, p.url(repo, "manifests/"+tag), bytes.NewReader(manifest), MediaTypeManifest)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to push manifest %s:%s: HTTP %d", repo, tag, resp.StatusCode)
+		}
+	}
+	return digestOf(manifest), nil
+}
+
+// pushBlob uploads data unless the registry already has it
+func (p *Publisher) pushBlob(ctx context.Context, repo string, data []byte) (string, error) {
+	digest := digestOf(data)
+
+	resp, err := p.do(ctx, repo, http.MethodHead, p.url(repo, "blobs/"+digest), nil, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return digest, nil
+	}
+
+	resp, err = p.do(ctx, repo, http.MethodPost, p.url(repo, "blobs/uploads/"), nil, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("upload rejected: HTTP %d", resp.StatusCode)
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", fmt.Errorf("invalid upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = p.do(ctx, repo, http.MethodPut, location.String(), bytes.NewReader(data), "application/octet-stream")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("upload failed: HTTP %d", resp.StatusCode)
+	}
+	return digest, nil
+}
+
+// listTags returns the tags of a repository; a missing repository has none
+func (p *Publisher) listTags(ctx context.Context, repo string) ([]string, error) {
+	resp, err := p.do(ctx, repo, http.MethodGet, p.url(repo, "tags/list"), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to list tags of %s: HTTP %d", repo, resp.StatusCode)
+	}
+
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Tags, nil
+}
+
+func (p *Publisher) url(repo, path string) string {
+	scheme := "https"
+	if p.opts.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, p.opts.Registry, repo, path)
+}
+
+// do sends a request, answering a registry auth challenge once if needed
+func (p *Publisher) do(ctx context.Context, repo, method, target string, body *bytes.Reader, contentType string) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		var reader io.Reader
+		if body != nil {
+			if _, err := body.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			reader = body
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.ContentLength = int64(body.Len())
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token := p.tokens[repo]; token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		return p.client.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || p.tokens[repo] != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	auth, err := p.authenticate(ctx, repo, challenge)
+	if err != nil {
+		return nil, err
+	}
+	p.tokens[repo] = auth
+	return send()
+}
+
+// authenticate turns a WWW-Authenticate challenge into an Authorization header
+func (p *Publisher) authenticate(ctx context.Context, repo, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	if strings.EqualFold(scheme, "basic") {
+		if p.opts.Username == "" {
+			return "", fmt.Errorf("registry %s requires credentials", p.opts.Registry)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(p.opts.Username, p.opts.Password)
+		return req.Header.Get("Authorization"), nil
+	}
+	if !strings.EqualFold(scheme, "bearer") || params["realm"] == "" {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	query := tokenURL.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", "repository:"+repo+":pull,push")
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if p.opts.Username != "" || p.opts.Password != "" {
+		req.SetBasicAuth(p.opts.Username, p.opts.Password)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token request failed: HTTP %d (check your credentials)", resp.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry issued no token")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge splits `Bearer realm="...",service="..."` into scheme and params
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return scheme, params
+}
+
+// TagsToPublish returns the tags a version should be pushed under given the
+// tags already in the registry. The major, minor and latest tags only move
+// forward, so publishing a fix for an older line leaves them alone.
+func TagsToPublish(version string, published []string) []string {
+	m := semverPattern.FindStringSubmatch(version)
+	if m == nil {
+		return []string{version}
+	}
+	v := parseSemver(m)
+
+	newerMinor, newerMajor, newerAny := false, false, false
+	for _, tag := range published {
+		pm := semverPattern.FindStringSubmatch(tag)
+		if pm == nil {
+			continue
+		}
+		pv := parseSemver(pm)
+		if !semverLess(v, pv) {
+			continue
+		}
+		newerAny = true
+		if pv[0] == v[0] {
+			newerMajor = true
+			if pv[1] == v[1] {
+				newerMinor = true
+			}
+		}
+	}
+
+	tags := []string{version}
+	if !newerMinor {
+		tags = append(tags, m[1]+"."+m[2])
+	}
+	if !newerMajor {
+		tags = append(tags, m[1])
+	}
+	if !newerAny {
+		tags = append(tags, "latest")
+	}
+	return tags
+}
+
+func parseSemver(m []string) [3]int {
+	var v [3]int
+	for i := 0; i < 3; i++ {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v
+}
+
+func semverLess(a, b [3]int) bool {
+	for i := 0; i < 3; i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// extractTar unpacks a tar archive produced by Package into dir
+func extractTar(data []byte, dir string) error {
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, os.FileMode(header.Mode)&0777); err != nil {
+			return err
+		}
+	}
+}
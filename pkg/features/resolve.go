@@ -0,0 +1,239 @@
+package features
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MetadataFetcher returns the devcontainer-feature.json of a feature
+// reference. It may return nil metadata for features without one.
+type MetadataFetcher func(ref string) (*Feature, error)
+
+// PlannedFeature is one feature in a resolved install plan
+type PlannedFeature struct {
+	ID         string                 // canonical id, e.g. ghcr.io/devcontainers/features/go
+	Ref        string                 // reference to install, including the version
+	Options    map[string]interface{} // merged options
+	RequiredBy []string               // "devcontainer.json" and/or the ids of dependents
+	Metadata   *Feature
+}
+
+// InstallPlan is the deterministic install order of a set of features
+type InstallPlan struct {
+	Features []*PlannedFeature
+	Warnings []string
+}
+
+// ConfigSource marks features requested directly in devcontainer.json
+const ConfigSource = "devcontainer.json"
+
+// CanonicalFeatureID strips the tag or digest from a reference and lowercases
+// it, so the same feature referenced twice resolves to one entry
+func CanonicalFeatureID(ref string) string {
+	id := strings.ToLower(strings.TrimSpace(ref))
+	if at := strings.Index(id, "@"); at != -1 {
+		return id[:at]
+	}
+	if colon := strings.LastIndex(id, ":"); colon > strings.LastIndex(id, "/") {
+		id = id[:colon]
+	}
+	return id
+}
+
+// ResolveInstallOrder expands dependsOn, merges repeated features and orders
+// the result following the dev container features spec: hard dependencies
+// (dependsOn) and soft ones (installsAfter, when present) install first; the
+// features that are ready at the same time install by overrideFeatureInstallOrder
+// priority, then alphabetically by id.
+//
+// When a feature is requested more than once its options are merged. Options
+// from devcontainer.json win over those asked for by dependents, and
+// otherwise the first request wins; conflicts are reported as warnings.
+func ResolveInstallOrder(requested map[string]interface{}, override []string, fetch MetadataFetcher) (*InstallPlan, error) {
+	plan := &InstallPlan{}
+	nodes := make(map[string]*PlannedFeature)
+
+	type request struct {
+		ref     string
+		options interface{}
+		from    string
+	}
+	var queue []request
+	for _, ref := range sortedKeys(requested) {
+		queue = append(queue, request{ref, requested[ref], ConfigSource})
+	}
+
+	for len(queue) > 0 {
+		req := queue[0]
+		queue = queue[1:]
+		id := CanonicalFeatureID(req.ref)
+		options := normalizeFeatureOptions(req.options)
+
+		if node, ok := nodes[id]; ok {
+			plan.mergeInto(node, req.ref, options, req.from)
+			continue
+		}
+
+		node := &PlannedFeature{
+			ID:         id,
+			Ref:        req.ref,
+			Options:    options,
+			RequiredBy: []string{req.from},
+		}
+		if fetch != nil {
+			meta, err := fetch(req.ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve feature %s: %w", req.ref, err)
+			}
+			node.Metadata = meta
+		}
+		nodes[id] = node
+
+		if node.Metadata != nil {
+			for _, dep := range sortedKeys(node.Metadata.DependsOn) {
+				queue = append(queue, request{dep, node.Metadata.DependsOn[dep], id})
+			}
+		}
+	}
+
+	// Edges point from a feature to the features that must install before it
+	before := make(map[string][]string)
+	for id, node := range nodes {
+		if node.Metadata == nil {
+			continue
+		}
+		for dep := range node.Metadata.DependsOn {
+			before[id] = append(before[id], CanonicalFeatureID(dep))
+		}
+		for _, after := range node.Metadata.InstallsAfter {
+			if afterID := CanonicalFeatureID(after); nodes[afterID] != nil && afterID != id {
+				before[id] = append(before[id], afterID)
+			}
+		}
+	}
+
+	priority := make(map[string]int)
+	for i, ref := range override {
+		priority[CanonicalFeatureID(ref)] = len(override) - i
+	}
+
+	installed := make(map[string]bool)
+	for len(installed) < len(nodes) {
+		var ready []string
+		for id := range nodes {
+			if installed[id] {
+				continue
+			}
+			ok := true
+			for _, dep := range before[id] {
+				if !installed[dep] {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				ready = append(ready, id)
+			}
+		}
+		if len(ready) == 0 {
+			var pending []string
+			for id := range nodes {
+				if !installed[id] {
+					pending = append(pending, id)
+				}
+			}
+			sort.Strings(pending)
+			return nil, fmt.Errorf("circular feature dependency between: %s", strings.Join(pending, ", "))
+		}
+
+		// Only the highest priority features of a round are committed, so an
+		// override can pull a feature ahead of others that are also ready
+		sort.Slice(ready, func(i, j int) bool {
+			if priority[ready[i]] != priority[ready[j]] {
+				return priority[ready[i]] > priority[ready[j]]
+			}
+			return ready[i] < ready[j]
+		})
+		top := priority[ready[0]]
+		for _, id := range ready {
+			if priority[id] != top {
+				break
+			}
+			installed[id] = true
+			plan.Features = append(plan.Features, nodes[id])
+		}
+	}
+
+	for _, ref := range override {
+		if nodes[CanonicalFeatureID(ref)] == nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("overrideFeatureInstallOrder lists %s, which is not installed", ref))
+		}
+	}
+	return plan, nil
+}
+
+// mergeInto folds a repeated request for a feature into its plan entry
+func (p *InstallPlan) mergeInto(node *PlannedFeature, ref string, options map[string]interface{}, from string) {
+	node.RequiredBy = append(node.RequiredBy, from)
+
+	if ref != node.Ref {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s is requested as both %s and %s, using %s", node.ID, node.Ref, ref, node.Ref))
+	}
+	for _, key := range sortedKeys(options) {
+		value := options[key]
+		existing, ok := node.Options[key]
+		if !ok {
+			node.Options[key] = value
+			continue
+		}
+		if !reflect.DeepEqual(existing, value) {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s: option %s=%v requested by %s conflicts with %v, keeping %v",
+				node.ID, key, value, from, existing, existing))
+		}
+	}
+}
+
+// normalizeFeatureOptions accepts the forms a feature value can take in
+// devcontainer.json: an options object, true, or a version string
+func normalizeFeatureOptions(value interface{}) map[string]interface{} {
+	options := make(map[string]interface{})
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			options[k] = val
+		}
+	case string:
+		if v != "" {
+			options["version"] = v
+		}
+	}
+	return options
+}
+
+// OptionValues returns the values a feature installs with: defaults from its
+// option definitions overridden by the given options
+func (f *Feature) OptionValues(given map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	for name, raw := range f.Options {
+		if def, ok := raw.(map[string]interface{}); ok {
+			if d, ok := def["default"]; ok {
+				values[name] = d
+			}
+		}
+	}
+	for name, v := range given {
+		values[name] = v
+	}
+	return values
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package features
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveInstallOrder(t *testing.T) {
+	const (
+		utils  = "ghcr.io/devcontainers/features/common-utils"
+		node   = "ghcr.io/devcontainers/features/node"
+		python = "ghcr.io/devcontainers/features/python"
+		tool   = "ghcr.io/acme/features/tool"
+	)
+	registry := map[string]*Feature{
+		utils:  {ID: "common-utils"},
+		python: {ID: "python", InstallsAfter: []string{utils}},
+		node:   {ID: "node", InstallsAfter: []string{utils}},
+		tool: {ID: "tool", DependsOn: map[string]interface{}{
+			node + ":1":  map[string]interface{}{"version": "20", "nodeGypDependencies": true},
+			utils + ":2": map[string]interface{}{},
+		}},
+	}
+	fetch := func(ref string) (*Feature, error) {
+		return registry[CanonicalFeatureID(ref)], nil
+	}
+
+	requested := map[string]interface{}{
+		tool + ":1":   map[string]interface{}{},
+		python + ":1": true,
+		node + ":1":   map[string]interface{}{"version": "18"},
+	}
+	plan, err := ResolveInstallOrder(requested, nil, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for _, f := range plan.Features {
+		order = append(order, f.ID)
+	}
+	// common-utils is pulled in by tool and lets node and python go after it
+	want := []string{utils, node, python, tool}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+
+	nodePlan := plan.Features[1]
+	if nodePlan.Options["version"] != "18" || nodePlan.Options["nodeGypDependencies"] != true {
+		t.Errorf("node options should merge with devcontainer.json winning, got %v", nodePlan.Options)
+	}
+	if !reflect.DeepEqual(nodePlan.RequiredBy, []string{ConfigSource, tool}) {
+		t.Errorf("node required by = %v", nodePlan.RequiredBy)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "version=20") {
+		t.Errorf("expected one option conflict warning, got %v", plan.Warnings)
+	}
+
+	// overrideFeatureInstallOrder moves python ahead of node
+	plan, err = ResolveInstallOrder(requested, []string{python}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Features[1].ID != python {
+		t.Errorf("override not applied: %v", plan.Features[1].ID)
+	}
+}
+
+func TestResolveInstallOrderCycle(t *testing.T) {
+	fetch := func(ref string) (*Feature, error) {
+		other := "b"
+		if CanonicalFeatureID(ref) == "b" {
+			other = "a"
+		}
+		return &Feature{DependsOn: map[string]interface{}{other: map[string]interface{}{}}}, nil
+	}
+	if _, err := ResolveInstallOrder(map[string]interface{}{"a": true}, nil, fetch); err == nil {
+		t.Error("expected a circular dependency error")
+	}
+}
+
+func TestCanonicalFeatureID(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/devcontainers/features/go:1":     "ghcr.io/devcontainers/features/go",
+		"GHCR.io/devcontainers/features/Go":       "ghcr.io/devcontainers/features/go",
+		"localhost:5000/features/tool@sha256:abc": "localhost:5000/features/tool",
+		"localhost:5000/features/tool:2.1":        "localhost:5000/features/tool",
+		"./local-feature":                         "./local-feature",
+	}
+	for ref, want := range tests {
+		if got := CanonicalFeatureID(ref); got != want {
+			t.Errorf("CanonicalFeatureID(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
@@ -88,6 +88,64 @@ func (d *NVIDIADetector) parseNvidiaSmiOutput(output []byte) ([]GPU, error) {
 	return gpus, nil
 }
 
+// MIGDevice is a Multi-Instance GPU slice of an A100/H100-class GPU
+type MIGDevice struct {
+	GPU     int    `json:"gpu"`     // Index of the GPU it's on
+	Index   int    `json:"index"`   // Index on that GPU
+	Profile string `json:"profile"` // e.g., "3g.20gb"
+	UUID    string `json:"uuid"`
+}
+
+// ID returns the device ID the NVIDIA runtime takes for the slice, "0:1"
+func (m MIGDevice) ID() string {
+	return fmt.Sprintf("%d:%d", m.GPU, m.Index)
+}
+
+// DetectMIG returns the MIG slices of all GPUs, none when MIG is off
+func (d *NVIDIADetector) DetectMIG() ([]MIGDevice, error) {
+	output, err := exec.Command(d.smiPath, "-L").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run nvidia-smi: %w", err)
+	}
+	return parseMIGList(output), nil
+}
+
+// parseMIGList parses the MIG lines of 'nvidia-smi -L':
+//
+//	GPU 0: NVIDIA A100-SXM4-40GB (UUID: GPU-...)
+//	  MIG 3g.20gb     Device  0: (UUID: MIG-...)
+func parseMIGList(output []byte) []MIGDevice {
+	var devices []MIGDevice
+	gpuIndex := -1
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "GPU "):
+			index, _, _ := strings.Cut(strings.TrimPrefix(line, "GPU "), ":")
+			if n, err := strconv.Atoi(index); err == nil {
+				gpuIndex = n
+			}
+		case strings.HasPrefix(line, "MIG ") && gpuIndex >= 0:
+			fields := strings.Fields(line)
+			// MIG <profile> Device <index>: (UUID: <uuid>)
+			if len(fields) < 6 || fields[2] != "Device" {
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimSuffix(fields[3], ":"))
+			if err != nil {
+				continue
+			}
+			devices = append(devices, MIGDevice{
+				GPU:     gpuIndex,
+				Index:   index,
+				Profile: fields[1],
+				UUID:    strings.TrimSuffix(fields[len(fields)-1], ")"),
+			})
+		}
+	}
+	return devices
+}
+
 // DetectVendor for NVIDIA always returns NVIDIA GPUs
 func (d *NVIDIADetector) DetectVendor(vendor GPUVendor) ([]GPU, error) {
 	if vendor != VendorNVIDIA {
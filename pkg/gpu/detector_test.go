@@ -0,0 +1,25 @@
+package gpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMIGList(t *testing.T) {
+	output := []byte(`GPU 0: NVIDIA A100-SXM4-40GB (UUID: GPU-5d5b1c2e-0000-0000-0000-000000000000)
+  MIG 3g.20gb     Device  0: (UUID: MIG-a1b2c3d4-0000-0000-0000-000000000000)
+  MIG 1g.5gb      Device  1: (UUID: MIG-e5f6a7b8-0000-0000-0000-000000000000)
+GPU 1: NVIDIA GeForce RTX 4090 (UUID: GPU-9f8e7d6c-0000-0000-0000-000000000000)
+`)
+	want := []MIGDevice{
+		{GPU: 0, Index: 0, Profile: "3g.20gb", UUID: "MIG-a1b2c3d4-0000-0000-0000-000000000000"},
+		{GPU: 0, Index: 1, Profile: "1g.5gb", UUID: "MIG-e5f6a7b8-0000-0000-0000-000000000000"},
+	}
+	got := parseMIGList(output)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseMIGList() = %+v, want %+v", got, want)
+	}
+	if got[1].ID() != "0:1" {
+		t.Errorf("ID() = %q, want 0:1", got[1].ID())
+	}
+}
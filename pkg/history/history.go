@@ -0,0 +1,242 @@
+// Package history records the commands run in dev containers with 'cm run',
+// 'cm exec' and 'cm make', so 'cm history' can list them and 'cm rerun' can
+// repeat them.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEntries is how many entries are kept; older ones are dropped
+const maxEntries = 1000
+
+// Entry is one recorded command
+type Entry struct {
+	ID          int       `json:"id"`
+	Time        time.Time `json:"time"`
+	Project     string    `json:"project"`         // Project directory
+	Dir         string    `json:"dir"`             // Working directory cm ran in
+	Kind        string    `json:"kind"`            // "run", "exec" or "make"
+	Command     []string  `json:"command"`         // Command run in the container
+	Args        []string  `json:"args"`            // cm arguments, to repeat it
+	DurationMs  int64     `json:"durationMs"`      // Wall time, including container startup
+	ExitCode    int       `json:"exitCode"`        // Non-zero when the command, or cm, failed
+	Image       string    `json:"image,omitempty"` // Image of the container
+	ImageDigest string    `json:"imageDigest,omitempty"`
+}
+
+// Filter selects entries; zero values match everything
+type Filter struct {
+	Project string
+	Kind    string
+	Grep    string // Substring of the command
+	Failed  bool   // Only non-zero exit codes
+	Since   time.Time
+}
+
+// Match reports whether an entry passes the filter
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Project != "" && e.Project != f.Project:
+		return false
+	case f.Kind != "" && e.Kind != f.Kind:
+		return false
+	case f.Grep != "" && !strings.Contains(strings.Join(e.Command, " "), f.Grep):
+		return false
+	case f.Failed && e.ExitCode == 0:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	}
+	return true
+}
+
+// Path returns the history file, ~/.cm/history.jsonl
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "history.jsonl"), nil
+}
+
+// Load returns all recorded entries, oldest first
+func Load() ([]Entry, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return load(path)
+}
+
+func load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Query returns the entries matching a filter, newest first, at most limit
+// of them when limit is positive
+func Query(f Filter, limit int) ([]Entry, error) {
+	entries, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	var matched []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if f.Match(entries[i]) {
+			matched = append(matched, entries[i])
+			if limit > 0 && len(matched) == limit {
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// Get returns the entry with an ID
+func Get(id int) (*Entry, error) {
+	entries, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no command #%d in history", id)
+}
+
+// Record appends an entry, assigning its ID, and returns it
+func Record(e Entry) (Entry, error) {
+	path, err := Path()
+	if err != nil {
+		return e, err
+	}
+	entries, err := load(path)
+	if err != nil {
+		return e, err
+	}
+
+	e.ID = 1
+	if len(entries) > 0 {
+		e.ID = entries[len(entries)-1].ID + 1
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return e, err
+	}
+
+	// Rewrite the file now and then rather than on every command
+	if len(entries) >= maxEntries+maxEntries/5 {
+		return e, save(path, append(entries[len(entries)-maxEntries+1:], e))
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return e, err
+}
+
+// Clear removes the entries of a project, or all entries when project is
+// empty
+func Clear(project string) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if project == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	entries, err := load(path)
+	if err != nil {
+		return err
+	}
+	var kept []Entry
+	for _, e := range entries {
+		if e.Project != project {
+			kept = append(kept, e)
+		}
+	}
+	return save(path, kept)
+}
+
+func save(path string, entries []Entry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// exitStatus matches the exit codes reported in the errors of container
+// runners, e.g. "command exited with code 2"
+var exitStatus = regexp.MustCompile(`(?:exit(?:ed with)? code|exit status) (\d+)`)
+
+// ExitCode returns the exit code a command's error reports: 0 for nil, the
+// process's code when known, and 1 otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if m := exitStatus.FindStringSubmatch(err.Error()); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return n
+		}
+	}
+	return 1
+}
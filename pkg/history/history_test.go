@@ -0,0 +1,99 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRecordAndQuery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, e := range []Entry{
+		{Project: "/a", Kind: "run", Command: []string{"go", "test", "./..."}},
+		{Project: "/b", Kind: "exec", Command: []string{"ls"}},
+		{Project: "/a", Kind: "make", Command: []string{"make", "build"}, ExitCode: 2},
+	} {
+		if _, err := Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		limit  int
+		want   []int
+	}{
+		{"all, newest first", Filter{}, 0, []int{3, 2, 1}},
+		{"limit", Filter{}, 1, []int{3}},
+		{"project", Filter{Project: "/a"}, 0, []int{3, 1}},
+		{"kind", Filter{Kind: "exec"}, 0, []int{2}},
+		{"grep", Filter{Grep: "test"}, 0, []int{1}},
+		{"failed", Filter{Failed: true}, 0, []int{3}},
+		{"since", Filter{Since: time.Now().Add(time.Hour)}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Query(tt.filter, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, e := range entries {
+				ids = append(ids, e.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("Query() IDs = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	if e, err := Get(2); err != nil || e.Project != "/b" {
+		t.Errorf("Get(2) = %+v, %v", e, err)
+	}
+	if _, err := Get(9); err == nil {
+		t.Error("Get(9) should fail")
+	}
+
+	if err := Clear("/a"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := Load()
+	if len(entries) != 1 || entries[0].ID != 2 {
+		t.Errorf("after Clear(/a) = %+v, want only #2", entries)
+	}
+
+	// IDs continue from the last entry kept
+	if e, _ := Record(Entry{Project: "/b", Kind: "exec"}); e.ID != 3 {
+		t.Errorf("next ID = %d, want 3", e.ID)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"process", exitErr, 3},
+		{"wrapped process", fmt.Errorf("exec: %w", exitErr), 3},
+		{"persistent exec", errors.New("command exited with code 2"), 2},
+		{"exit status", errors.New("compose run: exit status 4"), 4},
+		{"other", errors.New("no devcontainer.json found"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
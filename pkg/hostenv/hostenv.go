@@ -0,0 +1,196 @@
+// Package hostenv carries host settings into dev containers, which
+// otherwise run in UTC with the C locale: the time zone and locale as
+// environment variables and, when enabled, the host's CA certificates, so
+// TLS works behind proxies that inspect it with a corporate CA.
+package hostenv
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+)
+
+// CAPath is where the host's CA certificates are mounted in a container;
+// update-ca-certificates picks up .crt files from its directory
+const CAPath = "/usr/local/share/ca-certificates/cm-host.crt"
+
+// caEnv are the variables pointing TLS clients at the host's certificates:
+// OpenSSL and Go, Python requests, curl, git and Node.js
+var caEnv = []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE", "GIT_SSL_CAINFO", "NODE_EXTRA_CA_CERTS"}
+
+// InstallScript adds the mounted certificates to the system store of the
+// container, for the tools that ignore the variables (Java, apt). It needs
+// root and does nothing when the certificates aren't mounted.
+const InstallScript = `if [ -f ` + CAPath + ` ]; then
+  if command -v update-ca-certificates >/dev/null 2>&1; then
+    update-ca-certificates >/dev/null 2>&1
+  elif command -v update-ca-trust >/dev/null 2>&1; then
+    mkdir -p /etc/pki/ca-trust/source/anchors && cp ` + CAPath + ` /etc/pki/ca-trust/source/anchors/ && update-ca-trust
+  fi
+fi`
+
+// Options select the host settings passed on
+type Options struct {
+	Timezone bool
+	Locale   bool
+	CACerts  bool
+}
+
+// Setup is what a container gets from the host
+type Setup struct {
+	Env   []string // KEY=value
+	Binds []string
+}
+
+// Collect returns the host settings selected by opts. The CA certificates
+// are exported to a file in dir, as the host's bundle may be in a keychain
+// or a directory the engine can't mount.
+func Collect(opts Options, dir string) (*Setup, error) {
+	setup := &Setup{}
+	if opts.Timezone {
+		if tz := Timezone(); tz != "" {
+			setup.Env = append(setup.Env, "TZ="+tz)
+		}
+	}
+	if opts.Locale {
+		setup.Env = append(setup.Env, Locale()...)
+	}
+	if opts.CACerts {
+		bundle, err := CABundle()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the host's CA certificates: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, "ca-certificates.crt")
+		if err := os.WriteFile(path, bundle, 0644); err != nil {
+			return nil, err
+		}
+		setup.Binds = append(setup.Binds, path+":"+CAPath+":ro")
+		for _, key := range caEnv {
+			setup.Env = append(setup.Env, key+"="+CAPath)
+		}
+	}
+	return setup, nil
+}
+
+// Timezone returns the IANA name of the host's time zone, such as
+// "Europe/Paris", or "" when it isn't known (Windows names its zones
+// differently)
+func Timezone() string {
+	if tz := os.Getenv("TZ"); tz != "" {
+		return strings.TrimPrefix(tz, ":")
+	}
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		if tz := strings.TrimSpace(string(data)); tz != "" {
+			return tz
+		}
+	}
+	// /etc/localtime links to the zone's file, e.g.
+	// /usr/share/zoneinfo/Europe/Paris or, on macOS,
+	// /var/db/timezone/zoneinfo/Europe/Paris
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		return zoneName(target)
+	}
+	return ""
+}
+
+// zoneName returns the zone of a zoneinfo file path
+func zoneName(path string) string {
+	_, name, ok := strings.Cut(filepath.ToSlash(path), "zoneinfo/")
+	if !ok {
+		return ""
+	}
+	// posix/ and right/ hold variants of the same zones
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "posix/"), "right/")
+	return name
+}
+
+// localeVars are the locale variables passed on
+var localeVars = []string{"LANG", "LANGUAGE", "LC_ALL", "LC_CTYPE", "LC_MESSAGES", "LC_COLLATE", "LC_NUMERIC", "LC_TIME", "LC_MONETARY"}
+
+// Locale returns the host's locale variables. The C and POSIX locales are
+// left out, as they are already the container's. A macOS host without
+// LANG gets its system locale in UTF-8.
+func Locale() []string {
+	var env []string
+	for _, key := range localeVars {
+		switch v := os.Getenv(key); v {
+		case "", "C", "POSIX":
+		default:
+			env = append(env, key+"="+v)
+		}
+	}
+	if len(env) == 0 && goruntime.GOOS == "darwin" {
+		out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output()
+		// e.g. "en_US" or "en_GB@currency=EUR"
+		if locale, _, _ := strings.Cut(strings.TrimSpace(string(out)), "@"); err == nil && locale != "" {
+			env = append(env, "LANG="+locale+".UTF-8")
+		}
+	}
+	return env
+}
+
+// caBundles are where Linux distributions keep their CA bundle
+var caBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Arch, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",                  // Alpine, macOS (without the keychain's)
+}
+
+// CABundle returns the host's trusted CA certificates in PEM: the
+// distribution's bundle on Linux, the system keychains on macOS and the
+// root stores on Windows
+func CABundle() ([]byte, error) {
+	var bundle []byte
+	var err error
+	switch goruntime.GOOS {
+	case "darwin":
+		bundle, err = exec.Command("security", "find-certificate", "-a", "-p",
+			"/System/Library/Keychains/SystemRootCertificates.keychain",
+			"/Library/Keychains/System.keychain").Output()
+	case "windows":
+		bundle, err = exec.Command("powershell", "-NoProfile", "-Command",
+			`Get-ChildItem Cert:\LocalMachine\Root, Cert:\CurrentUser\Root | ForEach-Object { '-----BEGIN CERTIFICATE-----'; [Convert]::ToBase64String($_.RawData, 'InsertLineBreaks'); '-----END CERTIFICATE-----' }`).Output()
+	default:
+		err = fmt.Errorf("no CA bundle found in %s", strings.Join(caBundles, ", "))
+		for _, path := range caBundles {
+			if data, readErr := os.ReadFile(path); readErr == nil {
+				bundle, err = data, nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return certificates(bundle)
+}
+
+// certificates returns the certificates of a PEM bundle, with Windows line
+// endings and other blocks removed
+func certificates(bundle []byte) ([]byte, error) {
+	bundle = bytes.ReplaceAll(bundle, []byte("\r\n"), []byte("\n"))
+	var out bytes.Buffer
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			_ = pem.Encode(&out, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+		}
+	}
+	if out.Len() == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+// Package hostpath translates host paths into the bind mount sources that
+// container backends expect. On Linux and macOS paths pass through
+// unchanged. On Windows, Docker Desktop takes drive paths with forward
+// slashes and \\wsl$ shares, while a podman machine and an engine running
+// inside a WSL distribution (backend "wsl:<distro>") see the Windows drives
+// under /mnt/<drive>.
+package hostpath
+
+import (
+	"fmt"
+	goruntime "runtime"
+	"strings"
+)
+
+// Translator translates the paths of one host for one backend
+type Translator struct {
+	GOOS    string // Host operating system, as runtime.GOOS
+	Backend string // Backend type: docker, podman or nerdctl, or wsl:<distro>
+}
+
+// New returns a translator for the current host and a backend
+func New(backend string) *Translator {
+	return &Translator{GOOS: goruntime.GOOS, Backend: backend}
+}
+
+// Source returns the bind mount source for a host path
+func (t *Translator) Source(path string) (string, error) {
+	if t.GOOS != "windows" {
+		return path, nil
+	}
+
+	p := parseWindows(path)
+	distro, inWSL := strings.CutPrefix(t.Backend, "wsl:")
+	switch {
+	case p.drive != "":
+		if t.Backend == "podman" || inWSL {
+			// podman machine mounts the drives like WSL does
+			return "/mnt/" + strings.ToLower(p.drive) + "/" + p.rest, nil
+		}
+		return strings.ToUpper(p.drive) + ":/" + p.rest, nil
+
+	case p.wslDistro != "":
+		if inWSL && strings.EqualFold(p.wslDistro, distro) {
+			return "/" + p.rest, nil
+		}
+		if t.Backend == "podman" || inWSL {
+			return "", fmt.Errorf("%s is inside the WSL distribution %q, which %s cannot mount; run cm from inside that distribution", path, p.wslDistro, t.Backend)
+		}
+		// Older Docker Desktop releases only know the wsl$ host name
+		return `\\wsl$\` + p.wslDistro + `\` + strings.ReplaceAll(p.rest, "/", `\`), nil
+
+	case p.share != "":
+		if t.Backend == "podman" || inWSL {
+			return "", fmt.Errorf("%s is a network share, which %s cannot mount; copy the project to a local drive", path, t.Backend)
+		}
+		return `\\` + p.share + `\` + strings.ReplaceAll(p.rest, "/", `\`), nil
+	}
+	return path, nil
+}
+
+// Bind returns a "source:target" bind mount of a host path
+func (t *Translator) Bind(hostPath, containerPath string) (string, error) {
+	source, err := t.Source(hostPath)
+	if err != nil {
+		return "", err
+	}
+	return source + ":" + containerPath, nil
+}
+
+// Equal reports whether two host paths name the same directory, ignoring
+// case and separators where the host's file system does
+func (t *Translator) Equal(a, b string) bool {
+	if t.GOOS != "windows" && t.GOOS != "darwin" {
+		return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+	}
+	if t.GOOS == "windows" {
+		a, b = strings.ReplaceAll(a, `\`, "/"), strings.ReplaceAll(b, `\`, "/")
+	}
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
+
+// windowsPath is a Windows path split into its parts. At most one of drive,
+// wslDistro and share is set; rest uses forward slashes without a leading or
+// trailing one.
+type windowsPath struct {
+	drive     string // e.g. "C"
+	wslDistro string // e.g. "Ubuntu" for \\wsl$\Ubuntu\...
+	share     string // e.g. "server\share" for other UNC paths
+	rest      string
+}
+
+func parseWindows(path string) windowsPath {
+	p := strings.ReplaceAll(path, `\`, "/")
+	// Extended-length paths: //?/C:/... and //?/UNC/server/share/...
+	if strings.HasPrefix(p, "//?/") {
+		p = strings.TrimPrefix(p, "//?/")
+		if strings.HasPrefix(strings.ToUpper(p), "UNC/") {
+			p = "//" + p[4:]
+		}
+	}
+
+	if len(p) >= 2 && p[1] == ':' && isLetter(p[0]) {
+		return windowsPath{drive: p[:1], rest: cleanRest(p[2:])}
+	}
+
+	if strings.HasPrefix(p, "//") {
+		parts := strings.SplitN(strings.TrimPrefix(p, "//"), "/", 3)
+		if len(parts) < 2 {
+			return windowsPath{}
+		}
+		rest := ""
+		if len(parts) == 3 {
+			rest = cleanRest(parts[2])
+		}
+		host := strings.ToLower(parts[0])
+		if host == "wsl$" || host == "wsl.localhost" {
+			return windowsPath{wslDistro: parts[1], rest: rest}
+		}
+		return windowsPath{share: parts[0] + `\` + parts[1], rest: rest}
+	}
+	return windowsPath{}
+}
+
+// cleanRest drops empty and "." segments and surrounding slashes
+func cleanRest(rest string) string {
+	var segments []string
+	for _, s := range strings.Split(rest, "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,101 @@
+package hostpath
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	cases := []struct {
+		goos, backend, path, want string
+	}{
+		// Linux and macOS paths pass through
+		{"linux", "docker", "/home/me/src/foo", "/home/me/src/foo"},
+		{"linux", "podman", "/mnt/c/src/foo", "/mnt/c/src/foo"},
+		{"darwin", "podman", "/Users/me/My Project", "/Users/me/My Project"},
+
+		// Drive letters
+		{"windows", "docker", `C:\src\foo`, "C:/src/foo"},
+		{"windows", "docker", `c:\src\foo\`, "C:/src/foo"},
+		{"windows", "docker", `C:/src//foo/./`, "C:/src/foo"},
+		{"windows", "docker", `\\?\D:\very\long\path`, "D:/very/long/path"},
+		{"windows", "nerdctl", `C:\src\foo`, "C:/src/foo"},
+		{"windows", "podman", `C:\src\foo`, "/mnt/c/src/foo"},
+		{"windows", "podman", `E:\Work\Foo`, "/mnt/e/Work/Foo"},
+		{"windows", "podman", `C:\`, "/mnt/c/"},
+
+		// WSL shares
+		{"windows", "docker", `\\wsl$\Ubuntu\home\me\foo`, `\\wsl$\Ubuntu\home\me\foo`},
+		{"windows", "docker", `\\wsl.localhost\Ubuntu\home\me\foo`, `\\wsl$\Ubuntu\home\me\foo`},
+		{"windows", "docker", `//wsl.localhost/Ubuntu/home/me/foo/`, `\\wsl$\Ubuntu\home\me\foo`},
+
+		// Engines inside a WSL distribution
+		{"windows", "wsl:Ubuntu", `C:\src\foo`, "/mnt/c/src/foo"},
+		{"windows", "wsl:Ubuntu", `\\wsl$\Ubuntu\home\me\foo`, "/home/me/foo"},
+		{"windows", "wsl:Ubuntu", `\\wsl.localhost\ubuntu\home\me\foo`, "/home/me/foo"},
+
+		// Network shares
+		{"windows", "docker", `\\fileserver\projects\foo`, `\\fileserver\projects\foo`},
+		{"windows", "docker", `\\?\UNC\fileserver\projects\foo`, `\\fileserver\projects\foo`},
+	}
+	for _, c := range cases {
+		tr := &Translator{GOOS: c.goos, Backend: c.backend}
+		got, err := tr.Source(c.path)
+		if err != nil {
+			t.Errorf("%s/%s Source(%q): %v", c.goos, c.backend, c.path, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s/%s Source(%q) = %q, want %q", c.goos, c.backend, c.path, got, c.want)
+		}
+	}
+}
+
+func TestSourceUnreachableForPodman(t *testing.T) {
+	tr := &Translator{GOOS: "windows", Backend: "podman"}
+	for _, path := range []string{`\\wsl$\Ubuntu\home\me\foo`, `\\fileserver\projects\foo`} {
+		if _, err := tr.Source(path); err == nil {
+			t.Errorf("Source(%q) should fail for podman", path)
+		}
+	}
+
+	// Another distribution's files are out of reach
+	tr = &Translator{GOOS: "windows", Backend: "wsl:Debian"}
+	if _, err := tr.Source(`\\wsl$\Ubuntu\home\me\foo`); err == nil {
+		t.Error("Source should fail for a path in another distribution")
+	}
+}
+
+func TestBind(t *testing.T) {
+	tr := &Translator{GOOS: "windows", Backend: "podman"}
+	got, err := tr.Bind(`C:\src\foo`, "/workspaces/foo")
+	if err != nil || got != "/mnt/c/src/foo:/workspaces/foo" {
+		t.Errorf("Bind = %q, %v", got, err)
+	}
+
+	tr = &Translator{GOOS: "windows", Backend: "docker"}
+	got, _ = tr.Bind(`C:\src\foo`, "/workspaces/foo")
+	// The target must be the last colon-separated field
+	if !strings.HasSuffix(got, ":/workspaces/foo") || strings.Contains(got, `\`) {
+		t.Errorf("Bind = %q", got)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		goos, a, b string
+		want       bool
+	}{
+		{"linux", "/src/Foo", "/src/foo", false},
+		{"linux", "/src/foo/", "/src/foo", true},
+		{"darwin", "/Users/me/Foo", "/users/me/foo", true},
+		{"windows", `C:\Src\Foo`, `c:/src/foo`, true},
+		{"windows", `C:\src\foo`, `C:\src\bar`, false},
+	}
+	for _, c := range cases {
+		tr := &Translator{GOOS: c.goos}
+		if got := tr.Equal(c.a, c.b); got != c.want {
+			t.Errorf("%s Equal(%q, %q) = %v, want %v", c.goos, c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package hostreq checks a devcontainer's hostRequirements (cpus, memory,
+// storage, gpu) against what the container backend can give it, so a build
+// that would run out of memory fails early with a clear message. On Docker
+// Desktop and Podman machines the limits are those of the VM, not the host.
+// The check is skipped with --ignore-host-requirements or
+// CM_IGNORE_HOST_REQUIREMENTS=1.
+package hostreq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+)
+
+// IgnoreEnvVar skips the check when set to 1 or true
+const IgnoreEnvVar = "CM_IGNORE_HOST_REQUIREMENTS"
+
+var ignore bool
+
+// Ignore turns the check into a warning for the rest of the process
+func Ignore() {
+	ignore = true
+}
+
+// Ignored reports whether unmet requirements are only warned about, via
+// --ignore-host-requirements or CM_IGNORE_HOST_REQUIREMENTS
+func Ignored() bool {
+	if ignore {
+		return true
+	}
+	switch strings.ToLower(os.Getenv(IgnoreEnvVar)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// Resources are what the backend can give a container. Zero means unknown.
+type Resources struct {
+	CPUs      int
+	Memory    int64 // bytes
+	Storage   int64 // free bytes where images are stored
+	GPU       bool
+	GPUVendor string // "nvidia", "amd" or "intel" when there is a GPU
+	VM        string // "Docker Desktop" or "Podman machine" when limits come from a VM
+}
+
+// Shortfall is one requirement the resources do not meet
+type Shortfall struct {
+	Resource string
+	Need     string
+	Have     string
+	Optional bool // Only warned about
+}
+
+func (s Shortfall) String() string {
+	return fmt.Sprintf("%s: needs %s, has %s", s.Resource, s.Need, s.Have)
+}
+
+// Check compares the requirements with the backend's resources. Unmet
+// requirements are printed to log and returned as an error unless the check
+// is ignored.
+func Check(ctx context.Context, req *config.HostRequirements, backend string, log io.Writer) error {
+	if req == nil {
+		return nil
+	}
+	if log == nil {
+		log = io.Discard
+	}
+
+	res := Detect(ctx, backend, req.GPU != nil)
+	shortfalls, err := Evaluate(req, res)
+	if err != nil {
+		return err
+	}
+
+	var required []Shortfall
+	for _, s := range shortfalls {
+		if s.Optional {
+			fmt.Fprintf(log, "⚠️  Optional %s\n", s)
+			continue
+		}
+		required = append(required, s)
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	if Ignored() {
+		fmt.Fprintln(log, "⚠️  Host requirements not met (ignored):")
+	} else {
+		fmt.Fprintln(log, "❌ This dev container needs more than is available:")
+	}
+	for _, s := range required {
+		fmt.Fprintf(log, "   • %s\n", s)
+	}
+	if res.VM != "" {
+		fmt.Fprintf(log, "💡 These are the %s VM's limits; raise them in its resource settings\n", res.VM)
+	}
+	if Ignored() {
+		return nil
+	}
+
+	names := make([]string, len(required))
+	for i, s := range required {
+		names[i] = s.Resource
+	}
+	return fmt.Errorf("host requirements not met (%s); free up resources or run with --ignore-host-requirements", strings.Join(names, ", "))
+}
+
+// Evaluate returns the requirements the resources do not meet. Resources
+// that could not be detected are not reported.
+func Evaluate(req *config.HostRequirements, res Resources) ([]Shortfall, error) {
+	if req == nil {
+		return nil, nil
+	}
+	var shortfalls []Shortfall
+
+	if req.CPUs > 0 && res.CPUs > 0 && res.CPUs < req.CPUs {
+		shortfalls = append(shortfalls, Shortfall{
+			Resource: "cpus",
+			Need:     strconv.Itoa(req.CPUs),
+			Have:     strconv.Itoa(res.CPUs),
+		})
+	}
+
+	if req.Memory != "" {
+		need, err := ParseSize(req.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("hostRequirements.memory: %w", err)
+		}
+		if res.Memory > 0 && res.Memory < need {
+			shortfalls = append(shortfalls, Shortfall{Resource: "memory", Need: FormatSize(need), Have: FormatSize(res.Memory)})
+		}
+	}
+
+	if req.Storage != "" {
+		need, err := ParseSize(req.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("hostRequirements.storage: %w", err)
+		}
+		if res.Storage > 0 && res.Storage < need {
+			shortfalls = append(shortfalls, Shortfall{Resource: "storage", Need: FormatSize(need), Have: FormatSize(res.Storage) + " free"})
+		}
+	}
+
+	required, optional, vendor, err := gpuRequirement(req.GPU)
+	if err != nil {
+		return nil, err
+	}
+	if required || optional {
+		need := "a GPU"
+		if vendor != "" {
+			need = vendorGPU(vendor)
+		}
+		switch {
+		case !res.GPU:
+			shortfalls = append(shortfalls, Shortfall{Resource: "gpu", Need: need, Have: "none", Optional: optional})
+		case vendor != "" && res.GPUVendor != "" && res.GPUVendor != vendor:
+			shortfalls = append(shortfalls, Shortfall{Resource: "gpu", Need: need, Have: vendorGPU(res.GPUVendor), Optional: optional})
+		}
+	}
+
+	return shortfalls, nil
+}
+
+// gpuRequirement reads the gpu field: true, "optional", or an object with
+// cores and memory, which cm treats as required, and optionally the GPU's
+// vendor: "nvidia", "amd" or "intel"
+func gpuRequirement(v interface{}) (required, optional bool, vendor string, err error) {
+	switch g := v.(type) {
+	case nil:
+		return false, false, "", nil
+	case bool:
+		return g, false, "", nil
+	case string:
+		switch g {
+		case "optional":
+			return false, true, "", nil
+		case "true":
+			return true, false, "", nil
+		case "false":
+			return false, false, "", nil
+		}
+		return false, false, "", fmt.Errorf("hostRequirements.gpu: unknown value %q (use true, false or \"optional\")", g)
+	case map[string]interface{}:
+		if v, ok := g["vendor"]; ok {
+			vendor, _ = v.(string)
+			vendor = strings.ToLower(vendor)
+			if _, known := vendorGPUs[vendor]; !known {
+				return false, false, "", fmt.Errorf("hostRequirements.gpu.vendor: unknown vendor %v (use nvidia, amd or intel)", v)
+			}
+		}
+		return true, false, vendor, nil
+	}
+	return false, false, "", fmt.Errorf("hostRequirements.gpu: unsupported value %v", v)
+}
+
+// vendorGPUs name the GPUs of the vendors hostRequirements.gpu.vendor takes
+var vendorGPUs = map[string]string{
+	"nvidia": "an NVIDIA GPU",
+	"amd":    "an AMD GPU",
+	"intel":  "an Intel GPU",
+}
+
+// vendorGPU names a vendor's GPU, as in "an AMD GPU"
+func vendorGPU(vendor string) string {
+	if name, ok := vendorGPUs[vendor]; ok {
+		return name
+	}
+	return "a " + vendor + " GPU"
+}
+
+// Detect returns the resources of the backend's daemon, falling back to the
+// host's when the daemon cannot be queried
+func Detect(ctx context.Context, backend string, gpu bool) Resources {
+	var res Resources
+	var root string
+	if out, err := exec.CommandContext(ctx, backend, "info", "--format", "{{json .}}").Output(); err == nil {
+		res, root = parseInfo(out)
+	}
+
+	if res.CPUs == 0 {
+		res.CPUs = goruntime.NumCPU()
+	}
+	if res.Memory == 0 {
+		res.Memory = hostMemory()
+	}
+
+	// Docker Desktop keeps images in a disk image under the home directory
+	if _, err := os.Stat(root); root == "" || err != nil {
+		root, _ = os.UserHomeDir()
+	}
+	res.Storage = freeSpace(ctx, root)
+
+	if gpu {
+		info := runtime.DetectGPU()
+		res.GPU = info.Available
+		if info.Available {
+			res.GPUVendor = info.Type
+		}
+	}
+	return res
+}
+
+// parseInfo reads 'docker info' or 'podman info' JSON and returns the
+// resources and the directory where images are stored
+func parseInfo(data []byte) (Resources, string) {
+	var info struct {
+		// docker, nerdctl
+		NCPU            int
+		MemTotal        int64
+		DockerRootDir   string
+		OperatingSystem string
+
+		// podman
+		Host struct {
+			CPUs     int   `json:"cpus"`
+			MemTotal int64 `json:"memTotal"`
+		} `json:"host"`
+		Store struct {
+			GraphRoot string `json:"graphRoot"`
+		} `json:"store"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return Resources{}, ""
+	}
+
+	if info.Host.CPUs > 0 {
+		res := Resources{CPUs: info.Host.CPUs, Memory: info.Host.MemTotal}
+		if goruntime.GOOS != "linux" {
+			res.VM = "Podman machine"
+		}
+		return res, info.Store.GraphRoot
+	}
+
+	res := Resources{CPUs: info.NCPU, Memory: info.MemTotal}
+	if strings.Contains(info.OperatingSystem, "Docker Desktop") {
+		res.VM = "Docker Desktop"
+	}
+	return res, info.DockerRootDir
+}
+
+// hostMemory returns the host's total memory on Linux, or 0
+func hostMemory() int64 {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// freeSpace returns the free bytes on the filesystem holding path, or 0 when
+// df is unavailable
+func freeSpace(ctx context.Context, path string) int64 {
+	if path == "" {
+		return 0
+	}
+	out, err := exec.CommandContext(ctx, "df", "-Pk", path).Output()
+	if err != nil {
+		return 0
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0
+	}
+	kb, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
+
+// ParseSize parses a size such as "8gb", "512mb", "1.5tb" or a plain number
+// of bytes. Units are binary (1gb = 1024mb).
+func ParseSize(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}, {"b", 1},
+	}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 8gb or 512mb)", s)
+	}
+	return int64(n * mult), nil
+}
+
+// FormatSize formats bytes as GB or MB with one decimal
+func FormatSize(n int64) string {
+	if n >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
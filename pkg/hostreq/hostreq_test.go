@@ -0,0 +1,117 @@
+package hostreq
+
+import (
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"8gb", 8 << 30},
+		{"512mb", 512 << 20},
+		{"1.5tb", 3 << 39},
+		{"4G", 4 << 30},
+		{"1024", 1024},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("ParseSize(\"lots\") should fail")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	req := &config.HostRequirements{CPUs: 4, Memory: "8gb", Storage: "32gb", GPU: "optional"}
+	res := Resources{CPUs: 2, Memory: 4 << 30, Storage: 100 << 30}
+
+	shortfalls, err := Evaluate(req, res)
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	got := make(map[string]Shortfall)
+	for _, s := range shortfalls {
+		got[s.Resource] = s
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected cpus, memory and gpu shortfalls, got %v", shortfalls)
+	}
+	if got["memory"].Need != "8.0 GB" || got["memory"].Have != "4.0 GB" {
+		t.Errorf("unexpected memory shortfall: %v", got["memory"])
+	}
+	if !got["gpu"].Optional {
+		t.Error("an optional GPU should only be warned about")
+	}
+	if got["cpus"].Optional {
+		t.Error("cpus should be required")
+	}
+}
+
+func TestEvaluateUnknownResources(t *testing.T) {
+	req := &config.HostRequirements{CPUs: 64, Memory: "1tb"}
+	shortfalls, err := Evaluate(req, Resources{})
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if len(shortfalls) != 0 {
+		t.Errorf("undetected resources should not be reported, got %v", shortfalls)
+	}
+}
+
+func TestEvaluateInvalid(t *testing.T) {
+	if _, err := Evaluate(&config.HostRequirements{Memory: "much"}, Resources{}); err == nil {
+		t.Error("expected an error for an invalid memory size")
+	}
+	if _, err := Evaluate(&config.HostRequirements{GPU: "maybe"}, Resources{}); err == nil {
+		t.Error("expected an error for an unknown gpu value")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	docker := []byte(`{"NCPU": 4, "MemTotal": 8233017344, "DockerRootDir": "/var/lib/docker", "OperatingSystem": "Docker Desktop"}`)
+	res, root := parseInfo(docker)
+	if res.CPUs != 4 || res.Memory != 8233017344 || root != "/var/lib/docker" || res.VM != "Docker Desktop" {
+		t.Errorf("unexpected docker info: %+v %s", res, root)
+	}
+
+	podman := []byte(`{"host": {"cpus": 2, "memTotal": 2147483648}, "store": {"graphRoot": "/var/lib/containers/storage"}}`)
+	res, root = parseInfo(podman)
+	if res.CPUs != 2 || res.Memory != 2<<30 || root != "/var/lib/containers/storage" {
+		t.Errorf("unexpected podman info: %+v %s", res, root)
+	}
+}
+
+func TestEvaluateGPUVendor(t *testing.T) {
+	req := &config.HostRequirements{GPU: map[string]interface{}{"vendor": "amd"}}
+
+	shortfalls, err := Evaluate(req, Resources{GPU: true, GPUVendor: "nvidia"})
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if len(shortfalls) != 1 || shortfalls[0].Need != "an AMD GPU" || shortfalls[0].Have != "an NVIDIA GPU" {
+		t.Errorf("expected an AMD GPU shortfall, got %v", shortfalls)
+	}
+
+	if shortfalls, _ := Evaluate(req, Resources{GPU: true, GPUVendor: "amd"}); len(shortfalls) != 0 {
+		t.Errorf("an AMD GPU should meet the requirement, got %v", shortfalls)
+	}
+	if shortfalls, _ := Evaluate(req, Resources{}); len(shortfalls) != 1 || shortfalls[0].Need != "an AMD GPU" {
+		t.Errorf("expected a missing AMD GPU, got %v", shortfalls)
+	}
+
+	if _, err := Evaluate(&config.HostRequirements{GPU: map[string]interface{}{"vendor": "3dfx"}}, Resources{}); err == nil {
+		t.Error("expected an error for an unknown vendor")
+	}
+}
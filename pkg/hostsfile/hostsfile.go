@@ -0,0 +1,131 @@
+// Package hostsfile keeps a block of entries in the host's hosts file, so
+// environments can be reached by name (backend.cm.local) from the host's
+// browsers and tools. Lines outside the block are never changed.
+package hostsfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+)
+
+// Domain is appended to environment names
+const Domain = "cm.local"
+
+// Markers around the block cm owns
+const (
+	beginMarker = "# BEGIN Container-Maker environments"
+	endMarker   = "# END Container-Maker environments"
+)
+
+// Entry maps a hostname to an IP address
+type Entry struct {
+	IP       string
+	Hostname string
+}
+
+// Hostname returns the name of an environment, e.g. "backend.cm.local"
+func Hostname(envName string) string {
+	return strings.ToLower(envName) + "." + Domain
+}
+
+// Path returns the hosts file of the host
+func Path() string {
+	if goruntime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// Render returns the block for the entries, sorted by hostname; no entries
+// give an empty string
+func Render(entries []Entry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	sorted := append([]Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Hostname < sorted[j].Hostname })
+
+	var b strings.Builder
+	b.WriteString(beginMarker + "\n")
+	for _, e := range sorted {
+		fmt.Fprintf(&b, "%s\t%s\n", e.IP, e.Hostname)
+	}
+	b.WriteString(endMarker + "\n")
+	return b.String()
+}
+
+// Update returns content with its block replaced by one for the entries.
+// The block is appended when missing and removed when there are no entries.
+func Update(content string, entries []Entry) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var kept []string
+	inBlock := false
+	for _, line := range lines {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			inBlock = true
+			continue
+		case endMarker:
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			kept = append(kept, line)
+		}
+	}
+	for len(kept) > 0 && kept[len(kept)-1] == "" {
+		kept = kept[:len(kept)-1]
+	}
+
+	out := strings.Join(kept, "\n")
+	if out != "" {
+		out += "\n"
+	}
+	if block := Render(entries); block != "" {
+		if out != "" {
+			out += "\n"
+		}
+		out += block
+	}
+	return strings.ReplaceAll(out, "\n", newline)
+}
+
+// Sync writes the entries to the hosts file, leaving it untouched when they
+// haven't changed. Writing usually needs root or an elevated prompt.
+func Sync(entries []Entry) (changed bool, err error) {
+	path := Path()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	updated := Update(string(data), entries)
+	if updated == string(data) {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(updated), info.Mode().Perm()); err != nil {
+		if os.IsPermission(err) {
+			return false, fmt.Errorf("no permission to write %s; run again with sudo (or as administrator on Windows)", path)
+		}
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
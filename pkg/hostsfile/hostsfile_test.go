@@ -0,0 +1,48 @@
+package hostsfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	original := "127.0.0.1\tlocalhost\n::1\tlocalhost\n"
+	entries := []Entry{
+		{IP: "172.18.0.3", Hostname: Hostname("frontend")},
+		{IP: "172.19.0.2", Hostname: Hostname("Backend")},
+	}
+
+	updated := Update(original, entries)
+	want := original + "\n" + beginMarker + "\n" +
+		"172.19.0.2\tbackend.cm.local\n" +
+		"172.18.0.3\tfrontend.cm.local\n" +
+		endMarker + "\n"
+	if updated != want {
+		t.Errorf("Update() =\n%s\nwant\n%s", updated, want)
+	}
+
+	// Updating again replaces the block instead of adding one
+	again := Update(updated+"10.0.0.1\tgit.corp\n", entries[:1])
+	if strings.Count(again, beginMarker) != 1 || strings.Contains(again, "backend.cm.local") {
+		t.Errorf("Block not replaced:\n%s", again)
+	}
+	if !strings.Contains(again, "10.0.0.1\tgit.corp") {
+		t.Errorf("Lines after the block should be kept:\n%s", again)
+	}
+
+	// No entries remove the block
+	if cleaned := Update(updated, nil); cleaned != original {
+		t.Errorf("Update(nil) = %q, want %q", cleaned, original)
+	}
+}
+
+func TestUpdateKeepsCRLF(t *testing.T) {
+	original := "127.0.0.1 localhost\r\n"
+	updated := Update(original, []Entry{{IP: "172.18.0.2", Hostname: "api.cm.local"}})
+	if strings.Contains(strings.ReplaceAll(updated, "\r\n", ""), "\n") {
+		t.Errorf("Expected CRLF line endings: %q", updated)
+	}
+	if Update(updated, nil) != original {
+		t.Errorf("Removing the block should restore the file: %q", Update(updated, nil))
+	}
+}
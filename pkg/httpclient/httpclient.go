@@ -0,0 +1,231 @@
+// Package httpclient is the HTTP layer shared by everything cm downloads or
+// calls: feature and template registries, the marketplace, AI providers and
+// update checks. Requests go through the configured proxy and are retried
+// with exponential backoff when the failure looks transient.
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	// DefaultTimeout bounds a whole request, including reading the body
+	DefaultTimeout = 60 * time.Second
+
+	// DefaultRetries is how many times a failed request is retried
+	DefaultRetries = 3
+
+	maxBackoff = 30 * time.Second
+)
+
+// baseBackoff is the wait before the first retry; it doubles on each attempt
+var baseBackoff = 500 * time.Millisecond
+
+// Options configures a client
+type Options struct {
+	// Timeout bounds each request; 0 uses DefaultTimeout and a negative
+	// value disables it for long streaming responses
+	Timeout time.Duration
+	// Retries is the number of retries after the first attempt; 0 uses
+	// DefaultRetries and a negative value disables retrying
+	Retries int
+}
+
+// New returns a client using the shared proxy-aware transport
+func New(opts Options) *http.Client {
+	timeout := opts.Timeout
+	switch {
+	case timeout == 0:
+		timeout = DefaultTimeout
+	case timeout < 0:
+		timeout = 0
+	}
+	retries := opts.Retries
+	switch {
+	case retries == 0:
+		retries = DefaultRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &retryTransport{base: sharedTransport(), retries: retries},
+	}
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *http.Client
+)
+
+// Default returns the shared client with the default timeout and retries
+func Default() *http.Client {
+	defaultOnce.Do(func() {
+		defaultClient = New(Options{})
+	})
+	return defaultClient
+}
+
+// Get fetches a URL with the default client
+func Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Default().Do(req)
+}
+
+var (
+	transportOnce sync.Once
+	transport     *http.Transport
+)
+
+// sharedTransport is reused by every client so connections are pooled
+func sharedTransport() *http.Transport {
+	transportOnce.Do(func() {
+		transport = &http.Transport{
+			Proxy: ProxyFunc(ProxySettings()),
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	})
+	return transport
+}
+
+// ProxySettings returns the effective proxy configuration. The standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables (upper or lower case) win
+// over 'cm config proxy', like other environment overrides.
+func ProxySettings() *httpproxy.Config {
+	settings := httpproxy.FromEnvironment()
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return settings
+	}
+	if settings.HTTPProxy == "" {
+		settings.HTTPProxy = cfg.Proxy.HTTPProxy
+	}
+	if settings.HTTPSProxy == "" {
+		settings.HTTPSProxy = cfg.Proxy.HTTPSProxy
+	}
+	if settings.NoProxy == "" {
+		settings.NoProxy = cfg.Proxy.NoProxy
+	}
+	return settings
+}
+
+// ProxyFunc adapts proxy settings to http.Transport.Proxy
+func ProxyFunc(settings *httpproxy.Config) func(*http.Request) (*url.URL, error) {
+	proxy := settings.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// retryTransport retries connection errors, 429 and 5xx gateway errors.
+// Requests with a body are only retried when the body can be replayed.
+// POST and PATCH may already have taken effect when a connection drops or a
+// gateway times out, so they are only retried on 429 unless the caller opts
+// in with an Idempotency-Key header.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := t.retries
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && req.GetBody == nil {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && hasBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= retries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := backoff(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		// Rejected before it was handled
+		return true
+	}
+	if !replayable(req) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// replayable reports whether sending a request twice has the same effect
+// as sending it once
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff doubles the wait on each attempt with some jitter, honoring a
+// Retry-After header given in seconds
+func backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, maxBackoff)
+		}
+	}
+	wait := baseBackoff << attempt
+	wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	return min(wait, maxBackoff)
+}
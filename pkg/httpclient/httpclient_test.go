@@ -0,0 +1,154 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+func TestRetries(t *testing.T) {
+	baseBackoff = time.Millisecond
+
+	var calls int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case calls < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	client := New(Options{Timeout: 5 * time.Second})
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Errorf("status %d after %d calls, want 200 after 3", resp.StatusCode, calls)
+	}
+	for _, b := range bodies {
+		if b != "payload" {
+			t.Errorf("retried request body = %q", b)
+		}
+	}
+
+	calls = 0
+	resp, err = client.Get(server.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls != 1 {
+		t.Errorf("404 was requested %d times, want 1", calls)
+	}
+
+	calls = 0
+	resp, err = New(Options{Retries: -1}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != 1 {
+		t.Errorf("with retries disabled got %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+func TestPostNotReplayed(t *testing.T) {
+	baseBackoff = time.Millisecond
+
+	var calls int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := New(Options{Timeout: 5 * time.Second})
+
+	// The server may have created the resource before the gateway gave up
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"name": "gpu-box"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != 1 {
+		t.Errorf("POST got %d after %d calls, want 503 after 1", resp.StatusCode, calls)
+	}
+
+	// Opting in with an idempotency key allows the replay
+	calls = 0
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"name": "gpu-box"}`))
+	req.Header.Set("Idempotency-Key", "create-gpu-box")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("POST with Idempotency-Key got %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+	}
+
+	// 429 means the request was refused, so it is safe to send again
+	calls, status = 0, http.StatusTooManyRequests
+	if resp, err = client.Post(server.URL, "application/json", strings.NewReader(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("POST after 429 got %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestProxySettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, key := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy", "REQUEST_METHOD"} {
+		t.Setenv(key, "")
+	}
+
+	if err := userconfig.Save(&userconfig.UserConfig{Proxy: userconfig.ProxyConfig{
+		HTTPProxy:  "http://saved:3128",
+		HTTPSProxy: "http://saved:3128",
+		NoProxy:    ".corp",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := ProxySettings()
+	if settings.HTTPSProxy != "http://saved:3128" || settings.NoProxy != ".corp" {
+		t.Errorf("saved settings not used: %+v", settings)
+	}
+
+	t.Setenv("HTTPS_PROXY", "http://env:8080")
+	settings = ProxySettings()
+	if settings.HTTPSProxy != "http://env:8080" {
+		t.Errorf("HTTPS_PROXY should win over the saved proxy, got %s", settings.HTTPSProxy)
+	}
+
+	proxy := ProxyFunc(settings)
+	req, _ := http.NewRequest(http.MethodGet, "https://ghcr.io/v2/", nil)
+	if u, err := proxy(req); err != nil || u == nil || u.Host != "env:8080" {
+		t.Errorf("ghcr.io proxy = %v, %v", u, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://git.corp/repo", nil)
+	if u, _ := proxy(req); u != nil {
+		t.Errorf("no_proxy host was proxied via %v", u)
+	}
+}
@@ -0,0 +1,71 @@
+package i18n
+
+// en is the English catalog and the fallback of the others, so every key
+// must exist here
+var en = map[string]string{
+	// cm setup: runtime installation options
+	"setup.option.docker_desktop_windows": "Official Docker Desktop for Windows (recommended)",
+	"setup.option.rancher_desktop":        "Open source alternative with containerd/dockerd",
+	"setup.option.podman_desktop":         "Red Hat's daemonless Docker alternative",
+	"setup.option.docker_desktop_mac":     "Official Docker Desktop for Mac (recommended)",
+	"setup.option.orbstack":               "Faster, lighter Docker alternative (macOS only)",
+	"setup.option.orbstack_arm64":         "Faster, lighter Docker alternative (recommended on Apple Silicon)",
+	"setup.option.colima":                 "Open source lightweight container runtime",
+	"setup.option.podman":                 "Daemonless container engine",
+	"setup.option.podman_linux":           "Daemonless container engine, compatible with the Docker CLI",
+	"setup.option.docker_desktop_wsl":     "Use Docker Desktop on the Windows host (recommended)",
+	"setup.option.docker_desktop_wsl_cmd": "Install Docker Desktop on Windows and enable WSL integration",
+	"setup.option.docker_engine_wsl_name": "Docker Engine (inside WSL)",
+	"setup.option.docker_engine_wsl":      "Install Docker Engine directly inside WSL",
+	"setup.option.docker_engine":          "Official Docker Engine (recommended)",
+
+	// cm setup: host information
+	"setup.host.title":         "🖥️  Host",
+	"setup.host.os":            "OS:",
+	"setup.host.distro":        "Distro:",
+	"setup.host.environment":   "Env:",
+	"setup.host.runtimes":      "📦 Container runtimes",
+	"setup.host.installed":     "✅ installed",
+	"setup.host.not_installed": "❌ not installed",
+
+	// cm setup: wizard
+	"setup.title":         "🚀 Container-Maker Setup Wizard",
+	"setup.detecting":     "🔍 Detecting system environment...",
+	"setup.runtime_found": "✅ Container runtime detected, no installation needed!",
+	"setup.doctor_hint":   "💡 Run 'cm doctor' to check Docker status",
+	"setup.install_hint":  "💡 Use 'cm setup' to install container runtime",
+	"setup.no_options":    "❌ Cannot provide installation recommendations for your system",
+	"setup.auto_install":  "🔧 Auto-installing: %s",
+	"setup.recommended":   "📋 Recommended installation options:",
+	"setup.select":        "Select option (1-%d) or 'q' to quit: ",
+	"setup.cancelled":     "Cancelled",
+	"setup.invalid":       "❌ Invalid selection",
+	"setup.installing":    "🔧 Installing %s...",
+	"setup.executing":     "📝 Executing command:",
+	"setup.confirm":       "Confirm execution? [Y/n] ",
+	"setup.failed":        "❌ Installation failed: %v",
+	"setup.failed_hint":   "💡 Please try running the command manually or check the error",
+	"setup.complete":      "✅ Installation complete!",
+	"setup.next_steps":    "📋 Next steps:",
+	"setup.next_desktop":  "   1. If Docker Desktop was installed, start the application",
+	"setup.next_doctor":   "   2. Run 'cm doctor' to verify installation",
+	"setup.next_shell":    "   3. Run 'cm shell' to start using container dev environment",
+	"setup.relogin":       "⚠️  Note: If you added docker user group, re-login or run:",
+
+	// cm status dashboard
+	"status.title":       "📦 Container-Make Status Dashboard (%s)",
+	"status.loading":     "Loading containers...",
+	"status.error":       "Error: %v",
+	"status.empty":       "No containers found.",
+	"status.empty_hint":  "Run 'cm run -- <command>' to start a container.",
+	"status.logs":        "Logs",
+	"status.logs_scroll": " (scrolled up %d)",
+	"status.no_output":   "No output yet",
+	"status.stopping":    "Stopping...",
+	"status.restarting":  "Restarting...",
+	"status.stopped":     "Stopped",
+	"status.restarted":   "Restarted",
+	"status.paused":      "Paused",
+	"status.resumed":     "Resumed",
+	"status.help":        "↑/↓: Navigate  tab: Scroll logs  s: Shell  x: Stop  R: Restart  p: Pause/Resume  l: Follow logs  r: Refresh  q: Quit",
+}
@@ -0,0 +1,111 @@
+// Package i18n translates the text cm shows to users. Messages are looked up
+// by key in per-locale catalogs; a message missing from the active catalog
+// falls back to English, and a missing key to the key itself.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// Supported locales
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// catalogs maps each supported locale to its messages
+var catalogs = map[string]map[string]string{
+	English: en,
+	Chinese: zh,
+}
+
+var (
+	mu      sync.RWMutex
+	current string
+)
+
+// Supported returns the supported locales
+func Supported() []string {
+	return []string{English, Chinese}
+}
+
+// Normalize maps a locale such as "zh_CN.UTF-8" or "en-US" to a supported
+// locale, or "" when none matches
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "_-.@"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// Detect picks the locale: the 'locale' setting (or CM_LOCALE), then the
+// LC_ALL, LC_MESSAGES and LANG environment variables, then English
+func Detect() string {
+	if cfg, err := userconfig.Load(); err == nil && cfg.Locale != "" && cfg.Locale != "auto" {
+		if l := Normalize(cfg.Locale); l != "" {
+			return l
+		}
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			// The first variable set decides, as for gettext
+			if l := Normalize(v); l != "" {
+				return l
+			}
+			break
+		}
+	}
+	return English
+}
+
+// Locale returns the active locale, detecting it on first use
+func Locale() string {
+	mu.RLock()
+	l := current
+	mu.RUnlock()
+	if l != "" {
+		return l
+	}
+
+	l = Detect()
+	mu.Lock()
+	current = l
+	mu.Unlock()
+	return l
+}
+
+// SetLocale overrides the detected locale; unsupported locales select
+// English
+func SetLocale(locale string) {
+	l := Normalize(locale)
+	if l == "" {
+		l = English
+	}
+	mu.Lock()
+	current = l
+	mu.Unlock()
+}
+
+// T returns the message for key in the active locale, formatted with args
+// like fmt.Sprintf when any are given
+func T(key string, args ...interface{}) string {
+	msg, ok := catalogs[Locale()][key]
+	if !ok {
+		if msg, ok = en[key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
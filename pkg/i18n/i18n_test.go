@@ -0,0 +1,79 @@
+package i18n
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"zh_CN.UTF-8": "zh",
+		"zh-TW":       "zh",
+		"en_US.UTF-8": "en",
+		"EN":          "en",
+		"C":           "",
+		"POSIX":       "",
+		"fr_FR":       "",
+		"":            "",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDetectFromEnvironment(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CM_LOCALE", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+
+	t.Setenv("LANG", "zh_CN.UTF-8")
+	if got := Detect(); got != Chinese {
+		t.Errorf("LANG=zh_CN: got %q", got)
+	}
+
+	// LC_ALL takes precedence, even when it names an unsupported locale
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+	if got := Detect(); got != English {
+		t.Errorf("LC_ALL=fr_FR: got %q", got)
+	}
+
+	t.Setenv("CM_LOCALE", "zh")
+	if got := Detect(); got != Chinese {
+		t.Errorf("CM_LOCALE=zh: got %q", got)
+	}
+}
+
+func TestCatalogsMatchEnglish(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for key := range catalog {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s catalog has key %q missing from English", locale, key)
+			}
+		}
+		for key := range en {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s catalog lacks %q", locale, key)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer SetLocale(English)
+
+	SetLocale("zh_CN")
+	if got := T("setup.cancelled"); got != "已取消" {
+		t.Errorf("zh: got %q", got)
+	}
+	if got := T("setup.auto_install", "Podman"); got != "🔧 自动安装: Podman" {
+		t.Errorf("zh with args: got %q", got)
+	}
+
+	SetLocale("de")
+	if got := T("setup.cancelled"); got != "Cancelled" {
+		t.Errorf("fallback to English: got %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key: got %q", got)
+	}
+}
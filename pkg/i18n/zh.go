@@ -0,0 +1,70 @@
+package i18n
+
+// zh is the Simplified Chinese catalog
+var zh = map[string]string{
+	// cm setup: runtime installation options
+	"setup.option.docker_desktop_windows": "官方 Docker Desktop for Windows (推荐)",
+	"setup.option.rancher_desktop":        "开源替代品，支持 containerd/dockerd",
+	"setup.option.podman_desktop":         "Red Hat 的 Docker 替代品，无需守护进程",
+	"setup.option.docker_desktop_mac":     "官方 Docker Desktop for Mac (推荐)",
+	"setup.option.orbstack":               "更快更轻量的 Docker 替代品 (macOS 专属)",
+	"setup.option.orbstack_arm64":         "更快更轻量的 Docker 替代品 (Apple Silicon 推荐)",
+	"setup.option.colima":                 "开源轻量级容器运行时",
+	"setup.option.podman":                 "无守护进程的容器引擎",
+	"setup.option.podman_linux":           "无守护进程的容器引擎，兼容 Docker CLI",
+	"setup.option.docker_desktop_wsl":     "使用 Windows 宿主的 Docker Desktop (推荐)",
+	"setup.option.docker_desktop_wsl_cmd": "请在 Windows 中安装 Docker Desktop 并启用 WSL 集成",
+	"setup.option.docker_engine_wsl_name": "Docker Engine (WSL 内)",
+	"setup.option.docker_engine_wsl":      "在 WSL 内直接安装 Docker Engine",
+	"setup.option.docker_engine":          "官方 Docker Engine (推荐)",
+
+	// cm setup: host information
+	"setup.host.title":         "🖥️  主机信息",
+	"setup.host.os":            "操作系统:",
+	"setup.host.distro":        "发行版:",
+	"setup.host.environment":   "环境:",
+	"setup.host.runtimes":      "📦 容器运行时",
+	"setup.host.installed":     "✅ 已安装",
+	"setup.host.not_installed": "❌ 未安装",
+
+	// cm setup: wizard
+	"setup.title":         "🚀 Container-Maker 安装向导",
+	"setup.detecting":     "🔍 正在检测系统环境...",
+	"setup.runtime_found": "✅ 已检测到容器运行时，无需安装！",
+	"setup.doctor_hint":   "💡 运行 'cm doctor' 检查 Docker 状态",
+	"setup.install_hint":  "💡 使用 'cm setup' 安装容器运行时",
+	"setup.no_options":    "❌ 无法为您的系统提供安装建议",
+	"setup.auto_install":  "🔧 自动安装: %s",
+	"setup.recommended":   "📋 推荐的安装选项:",
+	"setup.select":        "请选择 (1-%d)，或输入 'q' 退出: ",
+	"setup.cancelled":     "已取消",
+	"setup.invalid":       "❌ 无效的选择",
+	"setup.installing":    "🔧 正在安装 %s...",
+	"setup.executing":     "📝 将执行命令:",
+	"setup.confirm":       "确认执行? [Y/n] ",
+	"setup.failed":        "❌ 安装失败: %v",
+	"setup.failed_hint":   "💡 请尝试手动运行该命令，或检查错误信息",
+	"setup.complete":      "✅ 安装完成！",
+	"setup.next_steps":    "📋 后续步骤:",
+	"setup.next_desktop":  "   1. 如果安装了 Docker Desktop，请启动该应用",
+	"setup.next_doctor":   "   2. 运行 'cm doctor' 验证安装",
+	"setup.next_shell":    "   3. 运行 'cm shell' 开始使用容器开发环境",
+	"setup.relogin":       "⚠️  注意: 如果添加了 docker 用户组，请重新登录或运行:",
+
+	// cm status dashboard
+	"status.title":       "📦 Container-Make 状态面板 (%s)",
+	"status.loading":     "正在加载容器...",
+	"status.error":       "错误: %v",
+	"status.empty":       "没有找到容器。",
+	"status.empty_hint":  "运行 'cm run -- <command>' 启动一个容器。",
+	"status.logs":        "日志",
+	"status.logs_scroll": " (已向上滚动 %d 行)",
+	"status.no_output":   "暂无输出",
+	"status.stopping":    "正在停止...",
+	"status.restarting":  "正在重启...",
+	"status.stopped":     "已停止",
+	"status.restarted":   "已重启",
+	"status.paused":      "已暂停",
+	"status.resumed":     "已恢复",
+	"status.help":        "↑/↓: 选择  tab: 滚动日志  s: Shell  x: 停止  R: 重启  p: 暂停/恢复  l: 跟踪日志  r: 刷新  q: 退出",
+}
@@ -0,0 +1,269 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/registry"
+	"github.com/docker/docker/client"
+	"github.com/tailscale/hujson"
+)
+
+// DigestRecord is the digest an image was last pulled at
+type DigestRecord struct {
+	Digest   string `json:"digest"`
+	PulledAt int64  `json:"pulled_at"`
+}
+
+// LocalDigest returns the registry digest of a local image, as recorded by
+// the last pull, or "" when the image isn't there or was built locally
+func LocalDigest(ref string) string {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return ""
+	}
+	defer cli.Close()
+
+	inspect, _, err := cli.ImageInspectWithRaw(context.Background(), ref)
+	if err != nil {
+		return ""
+	}
+	return matchRepoDigest(ref, inspect.RepoDigests)
+}
+
+// matchRepoDigest picks the digest of ref's repository from an image's
+// RepoDigests, e.g. "golang@sha256:..."
+func matchRepoDigest(ref string, repoDigests []string) string {
+	want, err := registry.ParseReference(ref)
+	if err != nil {
+		return ""
+	}
+	for _, rd := range repoDigests {
+		got, err := registry.ParseReference(rd)
+		if err == nil && got.Registry == want.Registry && got.Repository == want.Repository {
+			return got.Digest
+		}
+	}
+	return ""
+}
+
+// RecordDigest remembers the digest a freshly pulled image is at
+func RecordDigest(config *ImagesConfig, ref string) {
+	digest := LocalDigest(ref)
+	if digest == "" {
+		return
+	}
+	if config.Digests == nil {
+		config.Digests = make(map[string]*DigestRecord)
+	}
+	config.Digests[ref] = &DigestRecord{Digest: digest, PulledAt: time.Now().Unix()}
+}
+
+// PulledDigest returns the digest an image was last pulled at: the pinned
+// one, the recorded one, or the local image's
+func PulledDigest(config *ImagesConfig, ref string) string {
+	if r, err := registry.ParseReference(ref); err == nil && r.Digest != "" {
+		return r.Digest
+	}
+	if record, ok := config.Digests[ref]; ok {
+		return record.Digest
+	}
+	return LocalDigest(ref)
+}
+
+// Target is an image to check for updates
+type Target struct {
+	Name  string // Preset or template name, or the config path
+	Kind  string // "preset", "team", "custom", "template" or "project"
+	Image string
+}
+
+// Update statuses
+const (
+	StatusUpToDate  = "up-to-date"
+	StatusOutdated  = "outdated"
+	StatusNotPulled = "not-pulled"
+	StatusUnknown   = "unknown"
+)
+
+// ImageStatus is how an image compares with its registry
+type ImageStatus struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Image    string `json:"image"`
+	Pulled   string `json:"pulled_digest,omitempty"`
+	Latest   string `json:"latest_digest,omitempty"`
+	NewerTag string `json:"newer_tag,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NeedsUpdate reports whether the image has a newer digest or tag
+func (s ImageStatus) NeedsUpdate() bool {
+	return s.Status == StatusOutdated || s.NewerTag != ""
+}
+
+// Targets returns the images 'cm images' lists
+func Targets(config *ImagesConfig) []Target {
+	var targets []Target
+	for _, e := range Entries(config) {
+		kind := "preset"
+		switch {
+		case e.Team:
+			kind = "team"
+		case e.Custom:
+			kind = "custom"
+		}
+		targets = append(targets, Target{Name: e.Name, Kind: kind, Image: e.Image})
+	}
+	return targets
+}
+
+// outdatedJobs is how many images CheckOutdated looks up at once
+const outdatedJobs = 4
+
+// CheckOutdated compares each target's pulled digest with the one its tag
+// points at in the registry, and looks for newer tags of the same shape
+func CheckOutdated(ctx context.Context, config *ImagesConfig, targets []Target) []ImageStatus {
+	reg := registry.NewClient()
+	statuses := make([]ImageStatus, len(targets))
+
+	sem := make(chan struct{}, outdatedJobs)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		pulled := PulledDigest(config, target.Image)
+		wg.Add(1)
+		go func(i int, target Target, pulled string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			statuses[i] = checkImage(ctx, reg, target, pulled)
+		}(i, target, pulled)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func checkImage(ctx context.Context, reg *registry.Client, target Target, pulled string) ImageStatus {
+	status := ImageStatus{Name: target.Name, Kind: target.Kind, Image: target.Image, Pulled: pulled, Status: StatusUnknown}
+
+	ref, err := registry.ParseReference(target.Image)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if ref.Tag == "" {
+		// Pinned to a digest alone: nothing to compare with
+		status.Status = StatusUpToDate
+		return status
+	}
+
+	if status.Latest, err = reg.Digest(ctx, ref); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if tags, err := reg.Tags(ctx, ref); err == nil {
+		status.NewerTag = registry.NewerTag(ref.Tag, tags)
+	}
+
+	switch {
+	case pulled == "":
+		status.Status = StatusNotPulled
+	case pulled != status.Latest:
+		status.Status = StatusOutdated
+	default:
+		status.Status = StatusUpToDate
+	}
+	return status
+}
+
+// SortStatuses orders statuses with the ones needing an update first
+func SortStatuses(statuses []ImageStatus) {
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].NeedsUpdate() && !statuses[j].NeedsUpdate()
+	})
+}
+
+// ShortDigest shortens "sha256:0123456789abcdef..." to "0123456789ab"
+func ShortDigest(digest string) string {
+	_, hex, _ := strings.Cut(digest, ":")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
+}
+
+var imageSetting = regexp.MustCompile(`"image"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+// PinnedRef returns ref pinned to digest, keeping its tag for readers:
+// "golang:1.22" becomes "golang:1.22@sha256:..."
+func PinnedRef(ref, digest string) string {
+	if at := strings.Index(ref, "@"); at != -1 {
+		ref = ref[:at]
+	}
+	return ref + "@" + digest
+}
+
+// PinDevcontainer rewrites the top-level "image" of a devcontainer.json to
+// its pinned form, keeping comments and formatting. It returns the image
+// before and after.
+func PinDevcontainer(path string, digest func(ref string) (string, error)) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	image, err := topLevelImage(data)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", path, err)
+	}
+	if image == "" {
+		return "", "", fmt.Errorf("%s has no image to pin; images built from a Dockerfile are pinned in its FROM line", path)
+	}
+
+	d, err := digest(image)
+	if err != nil {
+		return "", "", err
+	}
+	pinned := PinnedRef(image, d)
+	if pinned == image {
+		return image, pinned, nil
+	}
+
+	matches := imageSetting.FindAllIndex(data, -1)
+	if len(matches) != 1 {
+		return "", "", fmt.Errorf("can't find the image setting in %s", path)
+	}
+	value, _ := json.Marshal(pinned)
+	after := make([]byte, 0, len(data)+len(d)+1)
+	after = append(after, data[:matches[0][0]]...)
+	after = append(after, `"image": `+string(value)...)
+	after = append(after, data[matches[0][1]:]...)
+
+	if got, err := topLevelImage(after); err != nil || got != pinned {
+		return "", "", fmt.Errorf("can't replace the image setting in %s", path)
+	}
+	return image, pinned, os.WriteFile(path, after, 0644)
+}
+
+// topLevelImage reads the "image" of a devcontainer.json
+func topLevelImage(data []byte) (string, error) {
+	// Standardize blanks out comments in place
+	std, err := hujson.Standardize(append([]byte(nil), data...))
+	if err != nil {
+		return "", err
+	}
+	var cfg struct {
+		Image string `json:"image"`
+	}
+	if err := json.Unmarshal(std, &cfg); err != nil {
+		return "", err
+	}
+	return cfg.Image, nil
+}
@@ -0,0 +1,61 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchRepoDigest(t *testing.T) {
+	repoDigests := []string{"ghcr.io/org/golang@sha256:aaa", "golang@sha256:bbb"}
+	for ref, want := range map[string]string{
+		"golang:1.22":                "sha256:bbb",
+		"docker.io/library/golang:1": "sha256:bbb",
+		"ghcr.io/org/golang:1.22":    "sha256:aaa",
+		"python:3.12":                "",
+	} {
+		if got := matchRepoDigest(ref, repoDigests); got != want {
+			t.Errorf("matchRepoDigest(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestPinDevcontainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	data := `{
+	// Keep this comment
+	"name": "app",
+	"image": "golang:1.22-alpine",
+	"features": {"ghcr.io/devcontainers/features/git:1": {}},
+}
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	digest := func(ref string) (string, error) { return "sha256:abc", nil }
+	before, after, err := PinDevcontainer(path, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != "golang:1.22-alpine" || after != "golang:1.22-alpine@sha256:abc" {
+		t.Errorf("pinned %q to %q", before, after)
+	}
+	got, _ := os.ReadFile(path)
+	if !strings.Contains(string(got), "// Keep this comment") || !strings.Contains(string(got), `"image": "golang:1.22-alpine@sha256:abc"`) {
+		t.Errorf("unexpected devcontainer.json:\n%s", got)
+	}
+
+	// Pinning again moves the digest forward
+	digest = func(ref string) (string, error) { return "sha256:def", nil }
+	if _, after, err = PinDevcontainer(path, digest); err != nil || after != "golang:1.22-alpine@sha256:def" {
+		t.Errorf("repin = %q, %v", after, err)
+	}
+
+	build := filepath.Join(t.TempDir(), "devcontainer.json")
+	_ = os.WriteFile(build, []byte(`{"build": {"dockerfile": "Dockerfile"}}`), 0644)
+	if _, _, err := PinDevcontainer(build, digest); err == nil {
+		t.Error("a devcontainer.json without an image should fail")
+	}
+}
@@ -0,0 +1,169 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// DefaultPrefetchJobs is how many images Prefetch pulls at once
+const DefaultPrefetchJobs = 3
+
+// PrefetchResult is the outcome of pulling one image
+type PrefetchResult struct {
+	Name  string
+	Image string
+	Err   error
+}
+
+// AllImages returns every image 'cm images' lists, team presets first and
+// each image once, sorted by name within its group
+func AllImages(config *ImagesConfig) []*PresetImage {
+	var all []*PresetImage
+	seen := make(map[string]bool)
+	for _, group := range []map[string]*PresetImage{config.Org, config.Presets, config.Custom} {
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			preset := group[name]
+			if seen[name] || seen[preset.Image] {
+				continue
+			}
+			seen[name], seen[preset.Image] = true, true
+			all = append(all, preset)
+		}
+	}
+	return all
+}
+
+// Prefetch pulls images in parallel, at most jobs at a time, printing each
+// image's download progress to out as it passes a quarter
+func Prefetch(ctx context.Context, presets []*PresetImage, jobs int, out io.Writer) []PrefetchResult {
+	if jobs < 1 {
+		jobs = DefaultPrefetchJobs
+	}
+	results := make([]PrefetchResult, len(presets))
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		for i, p := range presets {
+			results[i] = PrefetchResult{Name: p.Name, Image: p.Image, Err: err}
+		}
+		return results
+	}
+	defer cli.Close()
+
+	var mu sync.Mutex
+	done := 0
+	printf := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(out, format, args...)
+	}
+
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, preset := range presets {
+		wg.Add(1)
+		go func(i int, preset *PresetImage) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			printf("  📥 %-12s pulling %s\n", preset.Name, preset.Image)
+			err := pullWithProgress(ctx, cli, preset.Image, func(percent int) {
+				printf("     %-12s %3d%%\n", preset.Name, percent)
+			})
+			results[i] = PrefetchResult{Name: preset.Name, Image: preset.Image, Err: err}
+
+			mu.Lock()
+			done++
+			if err != nil {
+				fmt.Fprintf(out, "  ❌ %-12s [%d/%d] %v\n", preset.Name, done, len(presets), err)
+			} else {
+				fmt.Fprintf(out, "  ✅ %-12s [%d/%d] ready\n", preset.Name, done, len(presets))
+			}
+			mu.Unlock()
+		}(i, preset)
+	}
+	wg.Wait()
+	return results
+}
+
+// pullWithProgress pulls an image, calling report at 25, 50 and 75 percent
+// of its download
+func pullWithProgress(ctx context.Context, cli *client.Client, ref string, report func(percent int)) error {
+	reader, err := cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	progress := newPullProgress()
+	next := 25
+	dec := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		for percent := progress.update(msg); next < 100 && percent >= next; next += 25 {
+			report(next)
+		}
+	}
+}
+
+// pullProgress sums the download progress of an image's layers
+type pullProgress struct {
+	current map[string]int64
+	total   map[string]int64
+}
+
+func newPullProgress() *pullProgress {
+	return &pullProgress{current: make(map[string]int64), total: make(map[string]int64)}
+}
+
+// update records a pull message and returns the percentage downloaded so
+// far, across the layers seen
+func (p *pullProgress) update(msg jsonmessage.JSONMessage) int {
+	if msg.ID != "" {
+		switch msg.Status {
+		case "Downloading":
+			if msg.Progress != nil && msg.Progress.Total > 0 {
+				p.current[msg.ID] = msg.Progress.Current
+				p.total[msg.ID] = msg.Progress.Total
+			}
+		case "Download complete", "Pull complete":
+			if total, ok := p.total[msg.ID]; ok {
+				p.current[msg.ID] = total
+			}
+		}
+	}
+
+	var current, total int64
+	for id, t := range p.total {
+		current += p.current[id]
+		total += t
+	}
+	if total == 0 {
+		return 0
+	}
+	return int(current * 100 / total)
+}
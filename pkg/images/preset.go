@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/client"
@@ -26,6 +27,14 @@ type ImagesConfig struct {
 	Presets map[string]*PresetImage `json:"presets"`
 	Custom  map[string]*PresetImage `json:"custom"`
 	Default string                  `json:"default"`
+
+	// Org holds the team presets synced with 'cm images sync'; they take
+	// precedence over built-in presets of the same name
+	Org    map[string]*PresetImage `json:"org,omitempty"`
+	Source *OrgSource              `json:"source,omitempty"`
+
+	// Digests are the digests images were last pulled at, by reference
+	Digests map[string]*DigestRecord `json:"digests,omitempty"`
 }
 
 // DefaultPresets returns the built-in preset images
@@ -162,6 +171,9 @@ func UpdateDownloadedStatus(config *ImagesConfig) {
 	for _, custom := range config.Custom {
 		custom.Downloaded = CheckImageExists(custom.Image)
 	}
+	for _, org := range config.Org {
+		org.Downloaded = CheckImageExists(org.Image)
+	}
 }
 
 // ListImages returns a formatted list of all images
@@ -172,8 +184,27 @@ func ListImages(config *ImagesConfig) string {
 	sb.WriteString("  NAME           IMAGE                                    STATUS\n")
 	sb.WriteString("  ──────────────────────────────────────────────────────────────\n")
 
+	// Team presets
+	if len(config.Org) > 0 {
+		for name, org := range config.Org {
+			marker := "  "
+			if name == config.Default {
+				marker = "* "
+			}
+			status := "⬇️  Not downloaded"
+			if org.Downloaded {
+				status = "✅ Ready"
+			}
+			sb.WriteString(fmt.Sprintf("  %s%-12s %-38s %s\n", marker, name, org.Image, status))
+		}
+		sb.WriteString("\n  Built-in:\n")
+	}
+
 	// Presets
 	for name, preset := range config.Presets {
+		if _, overridden := config.Org[name]; overridden {
+			continue
+		}
 		marker := "  "
 		if name == config.Default {
 			marker = "* "
@@ -210,12 +241,18 @@ func ListImages(config *ImagesConfig) string {
 	sb.WriteString("  cm images pull <name>   Download an image\n")
 	sb.WriteString("  cm images add <image>   Add custom image\n")
 	sb.WriteString("  cm images setup         Run setup wizard\n")
+	sb.WriteString("  cm images sync <url>    Use the team's preset list\n")
+	sb.WriteString("  cm images prefetch      Download images in parallel\n")
+	sb.WriteString("  cm images outdated      Check for newer images\n")
 
 	return sb.String()
 }
 
 // GetImage returns an image by name (preset or custom)
 func GetImage(config *ImagesConfig, name string) (*PresetImage, bool) {
+	if org, ok := config.Org[name]; ok {
+		return org, true
+	}
 	if preset, ok := config.Presets[name]; ok {
 		return preset, true
 	}
@@ -230,6 +267,9 @@ func AddCustomImage(config *ImagesConfig, name, image string) error {
 	if _, exists := config.Presets[name]; exists {
 		return fmt.Errorf("'%s' is a preset name, choose a different name", name)
 	}
+	if _, exists := config.Org[name]; exists {
+		return fmt.Errorf("'%s' is a team preset name, choose a different name", name)
+	}
 
 	config.Custom[name] = &PresetImage{
 		Name:        name,
@@ -246,6 +286,9 @@ func RemoveCustomImage(config *ImagesConfig, name string) error {
 	if _, exists := config.Presets[name]; exists {
 		return fmt.Errorf("'%s' is a preset and cannot be removed", name)
 	}
+	if _, exists := config.Org[name]; exists {
+		return fmt.Errorf("'%s' is a team preset and cannot be removed; it comes from 'cm images sync'", name)
+	}
 
 	if _, exists := config.Custom[name]; !exists {
 		return fmt.Errorf("custom image '%s' not found", name)
@@ -254,3 +297,40 @@ func RemoveCustomImage(config *ImagesConfig, name string) error {
 	delete(config.Custom, name)
 	return SaveConfig(config)
 }
+
+// ImageEntry is one image of a machine-readable listing
+type ImageEntry struct {
+	*PresetImage
+	Custom  bool `json:"custom"`
+	Team    bool `json:"team"`    // From the team preset list
+	Default bool `json:"default"` // Default for new projects
+}
+
+// Entries returns the team presets, then the built-in ones they don't
+// override, then the custom images, each sorted by name
+func Entries(config *ImagesConfig) []ImageEntry {
+	var entries []ImageEntry
+	for _, group := range []struct {
+		images map[string]*PresetImage
+		custom bool
+		team   bool
+	}{{config.Org, false, true}, {config.Presets, false, false}, {config.Custom, true, false}} {
+		names := make([]string, 0, len(group.images))
+		for name := range group.images {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, overridden := config.Org[name]; overridden && !group.team {
+				continue
+			}
+			entries = append(entries, ImageEntry{
+				PresetImage: group.images[name],
+				Custom:      group.custom,
+				Team:        group.team,
+				Default:     name == config.Default,
+			})
+		}
+	}
+	return entries
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/docker/docker/api/types/image"
@@ -158,6 +159,13 @@ func PullImage(imageName string) error {
 	}
 	defer cli.Close()
 
+	if offline.Enabled() {
+		if _, _, err := cli.ImageInspectWithRaw(context.Background(), imageName); err != nil {
+			return offline.Missing("image", imageName)
+		}
+		return nil
+	}
+
 	fmt.Printf("  📥 Pulling %s...\n", imageName)
 
 	reader, err := cli.ImagePull(context.Background(), imageName, image.PullOptions{})
@@ -194,6 +202,7 @@ func PullSelectedImages(names []string) error {
 			fmt.Printf("  ❌ Failed to pull %s: %v\n", name, err)
 		} else {
 			config.Presets[name].Downloaded = true
+			RecordDigest(config, preset.Image)
 		}
 	}
 
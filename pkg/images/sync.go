@@ -0,0 +1,158 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+)
+
+// DefaultPresetFile is the file read from a git repository preset list
+const DefaultPresetFile = "images.json"
+
+// OrgSource is where the team preset list was synced from
+type OrgSource struct {
+	URL      string `json:"url"`
+	File     string `json:"file,omitempty"` // File in a git repository
+	Ref      string `json:"ref,omitempty"`  // Tag or branch of a git repository
+	SyncedAt int64  `json:"synced_at"`
+}
+
+// PresetList is a team preset list, in the same shape as images.json
+type PresetList struct {
+	Presets map[string]*PresetImage `json:"presets"`
+	Default string                  `json:"default"`
+}
+
+// ParsePresetList reads a preset list, naming each preset after its key
+func ParsePresetList(data []byte) (*PresetList, error) {
+	var list PresetList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid preset list: %w", err)
+	}
+	if len(list.Presets) == 0 {
+		return nil, fmt.Errorf("preset list has no presets")
+	}
+	for name, preset := range list.Presets {
+		if name == "" || strings.ContainsAny(name, " \t/\\") {
+			return nil, fmt.Errorf("invalid preset name %q", name)
+		}
+		if preset == nil || preset.Image == "" {
+			return nil, fmt.Errorf("preset '%s' has no image", name)
+		}
+		preset.Name = name
+		preset.Downloaded = false
+	}
+	if list.Default != "" && list.Presets[list.Default] == nil {
+		return nil, fmt.Errorf("default preset '%s' is not in the list", list.Default)
+	}
+	return &list, nil
+}
+
+// IsGitSource reports whether a preset list source is a git repository:
+// "git@host:org/repo", "ssh://...", "git+https://..." or a URL ending in .git
+func IsGitSource(source string) bool {
+	return strings.HasPrefix(source, "git@") ||
+		strings.HasPrefix(source, "ssh://") ||
+		strings.HasPrefix(source, "git+") ||
+		strings.HasSuffix(source, ".git")
+}
+
+// FetchPresetList reads a preset list from an http(s) URL, a git repository
+// or a local file
+func FetchPresetList(ctx context.Context, src OrgSource) ([]byte, error) {
+	switch {
+	case IsGitSource(src.URL):
+		if offline.Enabled() {
+			return nil, offline.Missing("preset list", src.URL)
+		}
+		return fetchGitPresetList(ctx, src)
+	case strings.HasPrefix(src.URL, "https://") || strings.HasPrefix(src.URL, "http://"):
+		if offline.Enabled() {
+			return nil, offline.Missing("preset list", src.URL)
+		}
+		return fetchHTTPPresetList(ctx, src.URL)
+	}
+	return os.ReadFile(src.URL)
+}
+
+func fetchHTTPPresetList(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := httpclient.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// fetchGitPresetList shallow-clones the repository into a temporary
+// directory and reads the list file from it
+func fetchGitPresetList(ctx context.Context, src OrgSource) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "cm-images-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--depth", "1"}
+	if src.Ref != "" {
+		args = append(args, "--branch", src.Ref)
+	}
+	args = append(args, strings.TrimPrefix(src.URL, "git+"), dir)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to clone %s: %s", src.URL, strings.TrimSpace(string(out)))
+	}
+
+	file := src.File
+	if file == "" {
+		file = DefaultPresetFile
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no %s", src.URL, file)
+	}
+	return data, nil
+}
+
+// ApplyPresetList replaces the team presets with a synced list. The list's
+// default becomes the user's when they have none.
+func ApplyPresetList(config *ImagesConfig, list *PresetList, src OrgSource) {
+	config.Org = list.Presets
+	src.SyncedAt = time.Now().Unix()
+	config.Source = &src
+	if config.Default == "" {
+		config.Default = list.Default
+	}
+}
+
+// SyncPresets fetches the team preset list and saves it to the config
+func SyncPresets(ctx context.Context, config *ImagesConfig, src OrgSource) (*PresetList, error) {
+	data, err := FetchPresetList(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	list, err := ParsePresetList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src.URL, err)
+	}
+	ApplyPresetList(config, list, src)
+	return list, SaveConfig(config)
+}
@@ -0,0 +1,127 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+func TestParsePresetList(t *testing.T) {
+	list, err := ParsePresetList([]byte(`{
+		"presets": {
+			"python": {"image": "registry.example.com/python:3.12", "downloaded": true},
+			"ml": {"image": "registry.example.com/ml:2024.10", "description": "ML stack"}
+		},
+		"default": "python"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := list.Presets["ml"].Name; got != "ml" {
+		t.Errorf("Name = %q, want ml", got)
+	}
+	if list.Presets["python"].Downloaded {
+		t.Error("Downloaded should not come from the list")
+	}
+
+	for _, data := range []string{
+		`{"presets": {}}`,
+		`{"presets": {"python": {}}}`,
+		`{"presets": {"a b": {"image": "x"}}}`,
+		`{"presets": {"python": {"image": "x"}}, "default": "go"}`,
+		`not json`,
+	} {
+		if _, err := ParsePresetList([]byte(data)); err == nil {
+			t.Errorf("ParsePresetList(%s) should fail", data)
+		}
+	}
+}
+
+func TestIsGitSource(t *testing.T) {
+	for source, want := range map[string]bool{
+		"git@github.com:org/cm-config.git":    true,
+		"ssh://git@example.com/org/cm-config": true,
+		"git+https://example.com/org/config":  true,
+		"https://github.com/org/config.git":   true,
+		"https://example.com/cm/images.json":  false,
+		"./images.json":                       false,
+	} {
+		if got := IsGitSource(source); got != want {
+			t.Errorf("IsGitSource(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestSyncPresetsFromFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path := filepath.Join(home, "team.json")
+	data := `{"presets": {"python": {"image": "registry.example.com/python:3.12"}}, "default": "python"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncPresets(t.Context(), cfg, OrgSource{URL: path}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Source == nil || cfg.Source.URL != path {
+		t.Fatalf("Source = %+v, want %s", cfg.Source, path)
+	}
+	if cfg.Default != "python" {
+		t.Errorf("Default = %q, want python", cfg.Default)
+	}
+	preset, _ := GetImage(cfg, "python")
+	if preset.Image != "registry.example.com/python:3.12" {
+		t.Errorf("team preset should override the built-in one, got %s", preset.Image)
+	}
+	if err := AddCustomImage(cfg, "python", "python:3"); err == nil {
+		t.Error("custom image should not shadow a team preset")
+	}
+
+	var pythons int
+	for _, e := range Entries(cfg) {
+		if e.Name == "python" {
+			pythons++
+			if !e.Team {
+				t.Error("python should be listed as a team preset")
+			}
+		}
+	}
+	if pythons != 1 {
+		t.Errorf("python listed %d times, want 1", pythons)
+	}
+	if all := AllImages(cfg); all[0].Name != "python" || len(all) != len(DefaultPresets()) {
+		t.Errorf("AllImages should list the team preset first and each name once, got %d images", len(all))
+	}
+}
+
+func TestPullProgress(t *testing.T) {
+	p := newPullProgress()
+	steps := []struct {
+		msg  jsonmessage.JSONMessage
+		want int
+	}{
+		{jsonmessage.JSONMessage{ID: "a", Status: "Pulling fs layer"}, 0},
+		{jsonmessage.JSONMessage{ID: "a", Status: "Downloading", Progress: &jsonmessage.JSONProgress{Current: 50, Total: 100}}, 50},
+		{jsonmessage.JSONMessage{ID: "b", Status: "Downloading", Progress: &jsonmessage.JSONProgress{Current: 0, Total: 300}}, 12},
+		{jsonmessage.JSONMessage{ID: "a", Status: "Download complete"}, 25},
+		{jsonmessage.JSONMessage{ID: "b", Status: "Pull complete"}, 100},
+	}
+	for i, step := range steps {
+		if got := p.update(step.msg); got != step.want {
+			t.Errorf("step %d: progress = %d%%, want %d%%", i, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tailscale/hujson"
+)
+
+// SourceCodespaces identifies a devcontainer.json written for GitHub Codespaces
+const SourceCodespaces ImportSource = "codespaces"
+
+// CodespacesImporter strips and converts Codespaces-only extensions of devcontainer.json
+type CodespacesImporter struct{}
+
+// NewCodespacesImporter creates a new Codespaces importer
+func NewCodespacesImporter() *CodespacesImporter {
+	return &CodespacesImporter{}
+}
+
+// CanHandle checks if this importer can handle the file
+func (i *CodespacesImporter) CanHandle(path string) bool {
+	base := filepath.Base(path)
+	return base == "devcontainer.json" || base == ".devcontainer.json"
+}
+
+// Convert rewrites a Codespaces devcontainer.json into one CM runs as intended
+func (i *CodespacesImporter) Convert(path, projectDir string) (*DevcontainerResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	std, err := hujson.Standardize(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSONC: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(std, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	result := &DevcontainerResult{
+		Source:     SourceCodespaces,
+		SourceFile: path,
+		Config:     config,
+		CreatedAt:  time.Now(),
+	}
+
+	// Everything else in devcontainer.json is understood as-is
+	for key := range config {
+		result.track(key, supportFull)
+	}
+
+	if custom, ok := config["customizations"].(map[string]interface{}); ok {
+		if cs, ok := custom["codespaces"].(map[string]interface{}); ok {
+			if _, ok := cs["repositories"]; ok {
+				result.track("customizations.codespaces.repositories", supportNone)
+				result.warn("REPO_PERMISSIONS", "Codespaces repository permissions are not applied",
+					"Provide a token for other repositories through remoteEnv")
+			}
+			if _, ok := cs["openFiles"]; ok {
+				result.track("customizations.codespaces.openFiles", supportNone)
+			}
+			delete(custom, "codespaces")
+			if len(custom) == 0 {
+				delete(config, "customizations")
+			}
+		}
+	}
+
+	// Recommended secrets become environment passthrough from the host
+	if secrets, ok := config["secrets"].(map[string]interface{}); ok {
+		result.track("secrets", supportPartial)
+		remoteEnv, _ := config["remoteEnv"].(map[string]interface{})
+		if remoteEnv == nil {
+			remoteEnv = make(map[string]interface{})
+		}
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, exists := remoteEnv[name]; !exists {
+				remoteEnv[name] = fmt.Sprintf("${localEnv:%s}", name)
+			}
+		}
+		config["remoteEnv"] = remoteEnv
+		delete(config, "secrets")
+		result.warn("SECRETS", fmt.Sprintf("Codespaces secrets %s are read from your local environment", strings.Join(names, ", ")),
+			"Export them in your shell before running 'cm shell'")
+	}
+
+	// CM runs onCreate, postCreate, postStart and postAttach; fold updateContent into onCreate
+	if update, ok := config["updateContentCommand"]; ok {
+		if cmd := lifecycleString(update); cmd != "" {
+			if onCreate := lifecycleString(config["onCreateCommand"]); onCreate != "" {
+				config["onCreateCommand"] = onCreate + " && " + cmd
+			} else {
+				config["onCreateCommand"] = cmd
+			}
+			result.track("updateContentCommand", supportFull)
+		} else {
+			result.track("updateContentCommand", supportPartial)
+			result.warn("LIFECYCLE_OBJECT", "updateContentCommand uses the parallel object form, which is not converted",
+				"Rewrite it as a single shell command")
+		}
+		delete(config, "updateContentCommand")
+	}
+
+	for _, hook := range []string{"onCreateCommand", "postCreateCommand", "postStartCommand", "postAttachCommand"} {
+		if _, isObject := config[hook].(map[string]interface{}); isObject {
+			result.track(hook, supportPartial)
+			result.warn("LIFECYCLE_OBJECT", fmt.Sprintf("%s uses the parallel object form, which CM does not run", hook),
+				"Rewrite it as a single shell command")
+		}
+	}
+
+	if _, ok := config["waitFor"]; ok {
+		result.track("waitFor", supportNone)
+		delete(config, "waitFor")
+		result.warn("WAIT_FOR", "waitFor is ignored; CM waits for every lifecycle command", "")
+	}
+
+	if _, ok := config["hostRequirements"]; ok {
+		result.track("hostRequirements", supportPartial)
+		result.warn("HOST_REQUIREMENTS", "hostRequirements choose a Codespaces machine type; locally they are informational", "")
+	}
+
+	result.finishCompatibility()
+	return result, nil
+}
+
+// lifecycleString flattens a string or array lifecycle command; object forms return ""
+func lifecycleString(v interface{}) string {
+	switch c := v.(type) {
+	case string:
+		return c
+	case []interface{}:
+		parts := make([]string, len(c))
+		for i, p := range c {
+			parts[i] = fmt.Sprintf("%v", p)
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
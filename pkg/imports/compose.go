@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,7 +14,12 @@ import (
 )
 
 // ComposeImporter imports docker-compose.yml files
-type ComposeImporter struct{}
+type ComposeImporter struct {
+	// Overrides are compose files merged over the imported one in order,
+	// like docker compose -f. Without them, an override file next to it,
+	// such as docker-compose.override.yml, is merged.
+	Overrides []string
+}
 
 // NewComposeImporter creates a new compose importer
 func NewComposeImporter() *ComposeImporter {
@@ -30,11 +36,22 @@ func (i *ComposeImporter) CanHandle(path string) bool {
 		strings.HasPrefix(base, "docker-compose.")
 }
 
+// load reads a compose file merged with its overrides
+func (i *ComposeImporter) load(path string) ([]byte, error) {
+	paths := append([]string{path}, i.Overrides...)
+	if len(i.Overrides) == 0 {
+		if override := ComposeOverrideFile(path); override != "" {
+			paths = append(paths, override)
+		}
+	}
+	return LoadComposeFiles(paths)
+}
+
 // Validate checks if the source file is valid
 func (i *ComposeImporter) Validate(path string) error {
-	data, err := os.ReadFile(path)
+	data, err := i.load(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
 	}
 
 	var compose ComposeFile
@@ -51,9 +68,9 @@ func (i *ComposeImporter) Validate(path string) error {
 
 // Analyze analyzes a compose file without importing
 func (i *ComposeImporter) Analyze(path string) (*AnalysisResult, error) {
-	data, err := os.ReadFile(path)
+	data, err := i.load(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
 	var compose ComposeFile
@@ -190,9 +207,9 @@ func (i *ComposeImporter) analyzeService(name string, svc *ComposeService) Servi
 
 // Import imports a docker-compose file
 func (i *ComposeImporter) Import(opts ImportOptions) (*ImportResult, error) {
-	data, err := os.ReadFile(opts.SourcePath)
+	data, err := i.load(opts.SourcePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
 	var compose ComposeFile
@@ -223,6 +240,7 @@ func (i *ComposeImporter) Import(opts ImportOptions) (*ImportResult, error) {
 		result.Warnings = append(result.Warnings, warnings...)
 		result.Statistics.ServicesImported++
 	}
+	result.Warnings = append(result.Warnings, unsupportedComposeKeys(data)...)
 
 	// Convert networks
 	for name, net := range compose.Networks {
@@ -280,6 +298,10 @@ func (i *ComposeImporter) convertService(name string, svc *ComposeService, opts
 		Image:         svc.Image,
 		RestartPolicy: svc.Restart,
 		WorkingDir:    svc.WorkingDir,
+		User:          svc.User,
+		CapAdd:        svc.CapAdd,
+		CapDrop:       svc.CapDrop,
+		Profiles:      svc.Profiles,
 	}
 
 	// Convert build
@@ -309,6 +331,17 @@ func (i *ComposeImporter) convertService(name string, svc *ComposeService, opts
 
 	// Convert environment
 	cmSvc.Environment = i.convertEnvironment(svc.Environment)
+	envFiles, envWarnings := i.convertEnvFile(name, svc.EnvFile, opts)
+	cmSvc.EnvFile = envFiles
+	warnings = append(warnings, envWarnings...)
+
+	// Convert expose
+	for _, e := range svc.Expose {
+		port, err := strconv.Atoi(strings.SplitN(fmt.Sprintf("%v", e), "/", 2)[0])
+		if err == nil {
+			cmSvc.Expose = append(cmSvc.Expose, port)
+		}
+	}
 
 	// Convert ports
 	for _, p := range svc.Ports {
@@ -330,9 +363,18 @@ func (i *ComposeImporter) convertService(name string, svc *ComposeService, opts
 			cmSvc.DependsOn = append(cmSvc.DependsOn, fmt.Sprintf("%v", d))
 		}
 	case map[string]interface{}:
-		for d := range deps {
+		for d, spec := range deps {
 			cmSvc.DependsOn = append(cmSvc.DependsOn, d)
+			if m, ok := spec.(map[string]interface{}); ok {
+				if cond, ok := m["condition"].(string); ok && cond != "" {
+					if cmSvc.DependencyConditions == nil {
+						cmSvc.DependencyConditions = make(map[string]*workspace.DependencyCondition)
+					}
+					cmSvc.DependencyConditions[d] = &workspace.DependencyCondition{Condition: cond}
+				}
+			}
 		}
+		sort.Strings(cmSvc.DependsOn)
 	}
 
 	// Convert networks
@@ -429,6 +471,132 @@ func (i *ComposeImporter) convertEnvironment(env interface{}) map[string]string
 	return result
 }
 
+// convertEnvFile converts env_file: a path or a list of paths or of
+// {path, required} entries, relative to the compose file. They're kept as
+// references, relative to the workspace file, rather than copying their
+// variables into it; optional files that don't exist are left out.
+func (i *ComposeImporter) convertEnvFile(service string, envFile interface{}, opts ImportOptions) ([]string, []ImportWarning) {
+	type entry struct {
+		path     string
+		required bool
+	}
+	var entries []entry
+	switch e := envFile.(type) {
+	case string:
+		entries = append(entries, entry{e, true})
+	case []interface{}:
+		for _, item := range e {
+			switch v := item.(type) {
+			case string:
+				entries = append(entries, entry{v, true})
+			case map[string]interface{}:
+				path, _ := v["path"].(string)
+				required, ok := v["required"].(bool)
+				entries = append(entries, entry{path, required || !ok})
+			}
+		}
+	}
+
+	sourceDir := filepath.Dir(opts.SourcePath)
+	outputDir := sourceDir
+	if opts.OutputPath != "" {
+		outputDir = filepath.Dir(opts.OutputPath)
+	}
+	var files []string
+	var warnings []ImportWarning
+	for _, e := range entries {
+		if e.path == "" {
+			continue
+		}
+		path := e.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(sourceDir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			if !e.required {
+				continue
+			}
+			warnings = append(warnings, ImportWarning{
+				Code:       "ENV_FILE_MISSING",
+				Message:    fmt.Sprintf("env_file %s does not exist", e.path),
+				Service:    service,
+				Field:      "env_file",
+				Suggestion: "Create it before starting the service",
+			})
+		}
+		if rel, err := filepath.Rel(outputDir, path); err == nil && !filepath.IsAbs(e.path) {
+			path = rel
+		}
+		files = append(files, filepath.ToSlash(path))
+	}
+	return files, warnings
+}
+
+// composeKeysImported are the service keys the importer converts. CM
+// services always get a TTY with stdin open, so stdin_open and tty need
+// nothing.
+var composeKeysImported = map[string]bool{
+	"image": true, "build": true, "command": true, "entrypoint": true,
+	"environment": true, "env_file": true, "ports": true, "expose": true,
+	"volumes": true, "depends_on": true, "networks": true, "restart": true,
+	"healthcheck": true, "deploy": true, "labels": true, "working_dir": true,
+	"user": true, "privileged": true, "cap_add": true, "cap_drop": true,
+	"profiles": true, "stdin_open": true, "tty": true,
+}
+
+// unsupportedComposeKeys warns about the keys of a compose file the
+// importer doesn't convert, rather than dropping them silently. Extensions
+// (x-*) are ignored, and secrets are reported on their own.
+func unsupportedComposeKeys(data []byte) []ImportWarning {
+	var raw struct {
+		Top      map[string]interface{}            `yaml:",inline"`
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+	var warnings []ImportWarning
+	warn := func(service, key string) {
+		warnings = append(warnings, ImportWarning{
+			Code:       "UNSUPPORTED_KEY",
+			Message:    fmt.Sprintf("%s is not imported", key),
+			Service:    service,
+			Field:      key,
+			Suggestion: "Configure it in cm-workspace.yaml by hand if needed",
+		})
+	}
+
+	for _, key := range sortedKeys(raw.Top) {
+		switch {
+		case strings.HasPrefix(key, "x-"), key == "name", key == "version", key == "networks", key == "volumes", key == "secrets":
+		default:
+			warn("", key)
+		}
+	}
+	names := make([]string, 0, len(raw.Services))
+	for name := range raw.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, key := range sortedKeys(raw.Services[name]) {
+			if !composeKeysImported[key] && !strings.HasPrefix(key, "x-") {
+				warn(name, key)
+			}
+		}
+	}
+	return warnings
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // convertPort converts port configuration
 func (i *ComposeImporter) convertPort(port interface{}) *workspace.PortConfig {
 	switch p := port.(type) {
@@ -0,0 +1,292 @@
+package imports
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
+	"gopkg.in/yaml.v3"
+)
+
+// ExportResult contains a compose file generated from a CM workspace
+type ExportResult struct {
+	Compose  *ComposeFile    `json:"compose"`
+	Warnings []ImportWarning `json:"warnings,omitempty"`
+}
+
+// YAML returns the compose file content
+func (r *ExportResult) YAML() ([]byte, error) {
+	return yaml.Marshal(r.Compose)
+}
+
+// ExportCompose converts a workspace into a docker-compose file. Services keep
+// the runtime behaviour CM gives them: the service directory is mounted at
+// /workspaces/<dir>, which is also the working directory, and a TTY is kept
+// open. Paths are written relative to the workspace file.
+func ExportCompose(ws *workspace.Workspace) *ExportResult {
+	result := &ExportResult{
+		Compose: &ComposeFile{
+			Name:     ws.Name,
+			Services: make(map[string]*ComposeService),
+		},
+	}
+
+	baseDir := "."
+	if ws.ConfigFile != "" {
+		baseDir = filepath.Dir(ws.ConfigFile)
+	}
+
+	for _, name := range ws.ServiceNames() {
+		svc := ws.Services[name]
+		result.Compose.Services[name] = exportService(name, svc, baseDir, result)
+	}
+
+	if len(ws.Networks) > 0 {
+		result.Compose.Networks = make(map[string]*ComposeNetwork)
+		for name, net := range ws.Networks {
+			result.Compose.Networks[name] = exportNetwork(net)
+		}
+	}
+
+	if len(ws.Volumes) > 0 {
+		result.Compose.Volumes = make(map[string]*ComposeVolume)
+		for name, vol := range ws.Volumes {
+			cv := &ComposeVolume{Driver: vol.Driver, Labels: vol.Labels}
+			if vol.External {
+				cv.External = true
+			}
+			result.Compose.Volumes[name] = cv
+		}
+	}
+
+	return result
+}
+
+func exportService(name string, svc *workspace.Service, baseDir string, result *ExportResult) *ComposeService {
+	out := &ComposeService{
+		Image:      svc.Image,
+		User:       svc.User,
+		Privileged: svc.Privileged,
+		CapAdd:     svc.CapAdd,
+		CapDrop:    svc.CapDrop,
+		Restart:    svc.RestartPolicy,
+		Profiles:   svc.Profiles,
+		StdinOpen:  true,
+		Tty:        true,
+	}
+
+	if out.Image == "" && svc.Template != "" {
+		out.Image = workspace.ResolveTemplateImage(svc.Template)
+	}
+
+	servicePath := relativePath(baseDir, svc.Path, name)
+	if svc.Build != nil {
+		build := map[string]interface{}{}
+		context := svc.Build.Context
+		if context == "" {
+			context = servicePath
+		}
+		build["context"] = context
+		if svc.Build.Dockerfile != "" {
+			build["dockerfile"] = svc.Build.Dockerfile
+		}
+		if len(svc.Build.Args) > 0 {
+			build["args"] = svc.Build.Args
+		}
+		if svc.Build.Target != "" {
+			build["target"] = svc.Build.Target
+		}
+		if len(svc.Build.CacheFrom) > 0 {
+			build["cache_from"] = svc.Build.CacheFrom
+		}
+		out.Build = build
+	}
+	if out.Image == "" && out.Build == nil {
+		result.Warnings = append(result.Warnings, ImportWarning{
+			Code:       "NO_IMAGE",
+			Message:    "service has no image, template or build",
+			Service:    name,
+			Suggestion: "Add an image before using the compose file",
+		})
+	}
+
+	if len(svc.Command) > 0 {
+		out.Command = svc.Command
+	}
+	if len(svc.Entrypoint) > 0 {
+		out.Entrypoint = svc.Entrypoint
+	}
+	if len(svc.Environment) > 0 {
+		out.Environment = svc.Environment
+	}
+	if len(svc.EnvFile) > 0 {
+		out.EnvFile = svc.EnvFile
+	}
+	if len(svc.Labels) > 0 {
+		out.Labels = svc.Labels
+	}
+	if len(svc.DependencyConditions) > 0 {
+		deps := make(map[string]interface{}, len(svc.DependsOn))
+		for _, dep := range svc.DependsOn {
+			cond := svc.DependencyCondition(dep)
+			if cond.Condition == "" {
+				cond.Condition = workspace.ConditionServiceStarted
+			}
+			deps[dep] = map[string]string{"condition": cond.Condition}
+			if cond.Timeout > 0 {
+				result.Warnings = append(result.Warnings, ImportWarning{
+					Code:    "DEPENDENCY_TIMEOUT",
+					Message: fmt.Sprintf("timeout for dependency %s has no compose equivalent and was dropped", dep),
+					Service: name,
+				})
+			}
+		}
+		out.DependsOn = deps
+	} else if len(svc.DependsOn) > 0 {
+		out.DependsOn = svc.DependsOn
+	}
+	if len(svc.Networks) > 0 {
+		// CM attaches every service to the workspace network as well
+		out.Networks = append([]string{"default"}, svc.Networks...)
+	}
+
+	workspaceDir := "/workspaces/" + name
+	if svc.Path != "" {
+		workspaceDir = "/workspaces/" + filepath.Base(svc.Path)
+	}
+	out.WorkingDir = workspaceDir
+	if svc.WorkingDir != "" {
+		out.WorkingDir = svc.WorkingDir
+	}
+	out.Volumes = append(out.Volumes, servicePath+":"+workspaceDir)
+	for _, v := range svc.Volumes {
+		out.Volumes = append(out.Volumes, v)
+	}
+
+	for _, p := range svc.Ports {
+		out.Ports = append(out.Ports, formatComposePort(p))
+	}
+	for _, e := range svc.Expose {
+		out.Expose = append(out.Expose, strconv.Itoa(e))
+	}
+
+	if svc.HealthCheck != nil && len(svc.HealthCheck.Test) > 0 {
+		out.HealthCheck = &ComposeHealthCheck{
+			Test:        svc.HealthCheck.Test,
+			Interval:    formatComposeDuration(svc.HealthCheck.Interval),
+			Timeout:     formatComposeDuration(svc.HealthCheck.Timeout),
+			Retries:     svc.HealthCheck.Retries,
+			StartPeriod: formatComposeDuration(svc.HealthCheck.StartPeriod),
+		}
+	}
+
+	resources := &ComposeResources{}
+	if r := svc.Resources; r != nil {
+		out.ShmSize = r.ShmSize
+		if r.Memory != "" || r.CPUs > 0 || r.Pids > 0 {
+			resources.Limits = &ComposeResourceSpec{Memory: r.Memory, Pids: r.Pids}
+			if r.CPUs > 0 {
+				resources.Limits.CPUs = strconv.FormatFloat(r.CPUs, 'f', -1, 64)
+			}
+		}
+	}
+	if gpu := svc.GPU; gpu != nil {
+		device := ComposeDeviceRequest{
+			Driver:       gpu.Driver,
+			DeviceIDs:    gpu.DeviceIDs,
+			Capabilities: gpu.Capabilities,
+		}
+		if device.Driver == "" {
+			device.Driver = "nvidia"
+		}
+		if len(device.Capabilities) == 0 {
+			device.Capabilities = []string{"gpu"}
+		}
+		// count and device_ids are mutually exclusive in compose
+		if len(gpu.DeviceIDs) == 0 {
+			if gpu.Count > 0 {
+				device.Count = gpu.Count
+			} else {
+				device.Count = "all"
+			}
+		}
+		resources.Reservations = &ComposeResourceSpec{Devices: []ComposeDeviceRequest{device}}
+	}
+	if resources.Limits != nil || resources.Reservations != nil {
+		out.Deploy = &ComposeDeploy{Resources: resources}
+	}
+
+	if svc.ConfigFile != "" {
+		result.Warnings = append(result.Warnings, ImportWarning{
+			Code:    "DEVCONTAINER_CONFIG",
+			Message: fmt.Sprintf("devcontainer config %s is not part of the compose file", svc.ConfigFile),
+			Service: name,
+		})
+	}
+	if len(svc.Tags) > 0 {
+		result.Warnings = append(result.Warnings, ImportWarning{
+			Code:    "TAGS",
+			Message: "tags have no compose equivalent and were dropped",
+			Service: name,
+		})
+	}
+
+	return out
+}
+
+func exportNetwork(net *workspace.NetworkConfig) *ComposeNetwork {
+	out := &ComposeNetwork{Driver: net.Driver, Labels: net.Labels}
+	if net.External {
+		out.External = true
+	}
+	if net.IPAM != nil {
+		out.IPAM = &ComposeIPAM{Driver: net.IPAM.Driver}
+		for _, pool := range net.IPAM.Config {
+			out.IPAM.Config = append(out.IPAM.Config, ComposeIPAMPool{Subnet: pool.Subnet, Gateway: pool.Gateway})
+		}
+	}
+	return out
+}
+
+// relativePath returns path relative to baseDir in "./dir" form, defaulting to the service name
+func relativePath(baseDir, path, name string) string {
+	if path == "" {
+		path = name
+	}
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(baseDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	path = filepath.ToSlash(path)
+	if path == "." || filepath.IsAbs(path) || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		return path
+	}
+	return "./" + path
+}
+
+// formatComposePort renders the short port syntax, e.g. "127.0.0.1:8080:80/udp"
+func formatComposePort(p workspace.PortConfig) string {
+	published := p.Published
+	if published == 0 {
+		published = p.Target // CM publishes on the same port when unset
+	}
+	s := fmt.Sprintf("%d:%d", published, p.Target)
+	if p.HostIP != "" {
+		s = p.HostIP + ":" + s
+	}
+	if p.Protocol != "" && p.Protocol != "tcp" {
+		s += "/" + p.Protocol
+	}
+	return s
+}
+
+func formatComposeDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
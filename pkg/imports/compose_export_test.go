@@ -0,0 +1,105 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/workspace"
+	"gopkg.in/yaml.v3"
+)
+
+const testWorkspace = `name: shop
+services:
+  api:
+    template: go
+    path: ./backend
+    depends_on:
+      db:
+        condition: service_healthy
+    ports:
+      - target: 8080
+        published: 18080
+    environment:
+      DB_HOST: db
+    gpu:
+      count: 1
+    resources:
+      memory: 4g
+      cpus: 2
+  db:
+    image: postgres:16
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+    healthcheck:
+      test: ["CMD", "pg_isready"]
+      interval: 10s
+      retries: 5
+volumes:
+  pgdata: {}
+`
+
+func TestExportCompose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cm-workspace.yaml")
+	if err := os.WriteFile(path, []byte(testWorkspace), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ExportCompose(ws).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var compose ComposeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		t.Fatalf("generated compose is not valid YAML: %v\n%s", err, data)
+	}
+
+	api := compose.Services["api"]
+	if api == nil || api.Image != "mcr.microsoft.com/devcontainers/go:1.21" {
+		t.Fatalf("api image not resolved: %+v", api)
+	}
+	if len(api.Ports) != 1 || api.Ports[0] != "18080:8080" {
+		t.Errorf("ports = %v", api.Ports)
+	}
+	if len(api.Volumes) != 1 || api.Volumes[0] != "./backend:/workspaces/backend" {
+		t.Errorf("volumes = %v", api.Volumes)
+	}
+	if api.Deploy == nil || api.Deploy.Resources.Limits.Memory != "4g" || api.Deploy.Resources.Limits.CPUs != "2" {
+		t.Errorf("resource limits not exported: %+v", api.Deploy)
+	}
+	devices := api.Deploy.Resources.Reservations.Devices
+	if len(devices) != 1 || devices[0].Driver != "nvidia" || devices[0].Count != 1 {
+		t.Errorf("GPU reservation = %+v", devices)
+	}
+
+	db := compose.Services["db"]
+	if db.HealthCheck == nil || db.HealthCheck.Interval != "10s" || db.HealthCheck.Retries != 5 {
+		t.Errorf("healthcheck = %+v", db.HealthCheck)
+	}
+	if _, ok := compose.Volumes["pgdata"]; !ok {
+		t.Error("named volume missing")
+	}
+
+	// The result must import back into an equivalent workspace
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	back, err := NewComposeImporter().Import(ImportOptions{SourcePath: composePath, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := back.Workspace.Services["api"]; got.GPU == nil || got.GPU.Count != 1 || !strings.HasPrefix(got.Image, "mcr.") {
+		t.Errorf("round trip lost settings: %+v", got)
+	}
+	if cond := back.Workspace.Services["api"].DependencyCondition("db"); cond.Condition != workspace.ConditionServiceHealthy {
+		t.Errorf("depends_on condition lost on round trip: %+v", cond)
+	}
+}
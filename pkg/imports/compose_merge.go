@@ -0,0 +1,457 @@
+package imports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sentinels the !reset and !override tags are decoded to
+const (
+	composeResetValue  = "\x00cm-reset"
+	composeOverrideKey = "\x00cm-override"
+)
+
+// ComposeOverrideFile returns the override file docker compose merges over
+// a compose file when no other files are given, such as
+// docker-compose.override.yml next to docker-compose.yml, or "" if there is
+// none
+func ComposeOverrideFile(path string) string {
+	ext := filepath.Ext(path)
+	override := strings.TrimSuffix(path, ext) + ".override" + ext
+	if _, err := os.Stat(override); err == nil {
+		return override
+	}
+	return ""
+}
+
+// LoadComposeFiles reads compose files and merges them in order, like
+// docker compose -f a.yml -f b.yml, then resolves extends. Relative paths
+// in every file are relative to the first file's directory, as in docker
+// compose. It returns the merged compose file as YAML.
+func LoadComposeFiles(paths []string) ([]byte, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no compose file given")
+	}
+	var merged map[string]interface{}
+	for _, path := range paths {
+		data, err := readComposeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = data
+		} else {
+			merged = mergeComposeMaps(merged, data, "")
+		}
+	}
+	if services, ok := merged["services"].(map[string]interface{}); ok {
+		if err := resolveExtends(services, filepath.Dir(paths[0]), filepath.Dir(paths[0])); err != nil {
+			return nil, err
+		}
+	}
+	stripComposeSentinels(merged)
+	return yaml.Marshal(merged)
+}
+
+// readComposeFile parses a compose file, keeping anchors and merge keys
+// working while recording !reset and !override
+func readComposeFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	markComposeTags(&doc)
+	result := make(map[string]interface{})
+	if err := doc.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// markComposeTags replaces values tagged !reset with a sentinel and wraps
+// values tagged !override in a sentinel mapping
+func markComposeTags(n *yaml.Node) {
+	for _, child := range n.Content {
+		markComposeTags(child)
+	}
+	switch n.Tag {
+	case "!reset":
+		*n = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: composeResetValue}
+	case "!override":
+		value := *n
+		value.Tag = ""
+		*n = yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: composeOverrideKey},
+			&value,
+		}}
+	}
+}
+
+// stripComposeSentinels removes what's left of !reset and !override where
+// nothing was overridden
+func stripComposeSentinels(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if inner, ok := val[composeOverrideKey]; ok && len(val) == 1 {
+			return stripComposeSentinels(inner)
+		}
+		for k, item := range val {
+			if item == composeResetValue {
+				delete(val, k)
+				continue
+			}
+			val[k] = stripComposeSentinels(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = stripComposeSentinels(item)
+		}
+	}
+	return v
+}
+
+// mergeComposeMaps merges override over base, both mappings at path (such
+// as "services.api"), following the compose merge rules
+func mergeComposeMaps(base, override map[string]interface{}, path string) map[string]interface{} {
+	result := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		if v == composeResetValue {
+			delete(result, k)
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			if inner, ok := m[composeOverrideKey]; ok && len(m) == 1 {
+				result[k] = inner
+				continue
+			}
+		}
+		old, exists := result[k]
+		if !exists {
+			result[k] = v
+			continue
+		}
+		result[k] = mergeComposeValue(old, v, joinComposePath(path, k))
+	}
+	return result
+}
+
+func joinComposePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mergeComposeValue merges the value of one key of a mapping
+func mergeComposeValue(base, override interface{}, path string) interface{} {
+	// Service keys are handled by name; path is services.<name>.<key>
+	key := path
+	if parts := strings.SplitN(path, ".", 3); len(parts) == 3 && parts[0] == "services" {
+		key = parts[2]
+	}
+	switch key {
+	case "command", "entrypoint", "healthcheck.test":
+		return override
+	case "environment", "labels", "annotations", "sysctls", "build.args", "build.labels":
+		return mergeComposeMaps(composeMapping(base, "="), composeMapping(override, "="), path)
+	case "depends_on", "networks":
+		return mergeComposeMaps(composeNameMapping(base), composeNameMapping(override), path)
+	case "volumes", "devices":
+		if strings.HasPrefix(path, "services.") {
+			return mergeComposeMounts(base, override)
+		}
+	case "build":
+		return mergeComposeMaps(composeBuild(base), composeBuild(override), path)
+	}
+
+	baseMap, baseIsMap := base.(map[string]interface{})
+	overrideMap, overrideIsMap := override.(map[string]interface{})
+	if baseIsMap && overrideIsMap {
+		return mergeComposeMaps(baseMap, overrideMap, path)
+	}
+	baseList, baseIsList := base.([]interface{})
+	overrideList, overrideIsList := override.([]interface{})
+	if baseIsList && overrideIsList {
+		return appendUnique(baseList, overrideList)
+	}
+	return override
+}
+
+// composeMapping returns a mapping given either as a mapping or as a list
+// of KEY<sep>VALUE entries
+func composeMapping(v interface{}, sep string) map[string]interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return val
+	case []interface{}:
+		result := make(map[string]interface{}, len(val))
+		for _, item := range val {
+			key, value, found := strings.Cut(fmt.Sprintf("%v", item), sep)
+			if found {
+				result[key] = value
+			} else {
+				result[key] = nil
+			}
+		}
+		return result
+	}
+	return map[string]interface{}{}
+}
+
+// composeNameMapping returns a mapping of names, such as depends_on or
+// networks, given either as a mapping or as a list of names
+func composeNameMapping(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return val
+	case []interface{}:
+		result := make(map[string]interface{}, len(val))
+		for _, item := range val {
+			result[fmt.Sprintf("%v", item)] = map[string]interface{}{}
+		}
+		return result
+	}
+	return map[string]interface{}{}
+}
+
+// composeBuild returns build in its long form
+func composeBuild(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return val
+	case string:
+		return map[string]interface{}{"context": val}
+	}
+	return map[string]interface{}{}
+}
+
+// mergeComposeMounts merges volumes or devices by their target in the
+// container: an override replaces the base entry with the same target
+func mergeComposeMounts(base, override interface{}) interface{} {
+	baseList, _ := base.([]interface{})
+	overrideList, _ := override.([]interface{})
+	index := make(map[string]int)
+	result := make([]interface{}, 0, len(baseList)+len(overrideList))
+	for _, list := range [][]interface{}{baseList, overrideList} {
+		for _, item := range list {
+			target := mountTarget(item)
+			if i, ok := index[target]; ok && target != "" {
+				result[i] = item
+				continue
+			}
+			index[target] = len(result)
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// mountTarget returns the container path of a volume or device
+func mountTarget(v interface{}) string {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		target, _ := val["target"].(string)
+		return target
+	case string:
+		// SOURCE:TARGET[:MODE], or just TARGET for anonymous volumes
+		parts := strings.Split(val, ":")
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		return parts[1]
+	}
+	return ""
+}
+
+// appendUnique appends the items of override not already in base
+func appendUnique(base, override []interface{}) []interface{} {
+	seen := make(map[string]bool, len(base))
+	result := make([]interface{}, 0, len(base)+len(override))
+	for _, list := range [][]interface{}{base, override} {
+		for _, item := range list {
+			key := fmt.Sprintf("%v", item)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// resolveExtends replaces the extends of services, declared in a file in
+// dir, with the services they extend merged under them. Relative paths are
+// then rebased onto projectDir.
+func resolveExtends(services map[string]interface{}, dir, projectDir string) error {
+	resolving := make(map[string]bool)
+	var resolve func(name string) (map[string]interface{}, error)
+	resolve = func(name string) (map[string]interface{}, error) {
+		svc, ok := services[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("extends: service %q not found", name)
+		}
+		ext, ok := svc["extends"]
+		if !ok {
+			return svc, nil
+		}
+		if resolving[name] {
+			return nil, fmt.Errorf("extends: service %q extends itself", name)
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+
+		var baseName, file string
+		switch e := ext.(type) {
+		case string:
+			baseName = e
+		case map[string]interface{}:
+			baseName, _ = e["service"].(string)
+			file, _ = e["file"].(string)
+		}
+		if baseName == "" {
+			return nil, fmt.Errorf("extends of service %q has no service", name)
+		}
+
+		var base map[string]interface{}
+		if file == "" {
+			b, err := resolve(baseName)
+			if err != nil {
+				return nil, err
+			}
+			base = b
+		} else {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			data, err := readComposeFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("extends of service %q: %w", name, err)
+			}
+			other, _ := data["services"].(map[string]interface{})
+			if _, ok := other[baseName].(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("extends: service %q not found in %s", baseName, file)
+			}
+			if err := resolveExtends(other, filepath.Dir(file), dir); err != nil {
+				return nil, err
+			}
+			base = other[baseName].(map[string]interface{})
+		}
+		// The base may be extended again, and its paths rebased
+		base = copyComposeValue(base).(map[string]interface{})
+
+		own := make(map[string]interface{}, len(svc))
+		for k, v := range svc {
+			if k != "extends" {
+				own[k] = v
+			}
+		}
+		merged := mergeComposeMaps(base, own, "services."+name)
+		services[name] = merged
+		return merged, nil
+	}
+
+	for name := range services {
+		if _, err := resolve(name); err != nil {
+			return err
+		}
+	}
+	if dir != projectDir {
+		for _, svc := range services {
+			if m, ok := svc.(map[string]interface{}); ok {
+				rebaseComposePaths(m, dir, projectDir)
+			}
+		}
+	}
+	return nil
+}
+
+// rebaseComposePaths makes the relative paths of a service declared in a
+// file in dir relative to projectDir
+func rebaseComposePaths(svc map[string]interface{}, dir, projectDir string) {
+	rebase := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "~") || strings.Contains(p, "://") {
+			return p
+		}
+		rel, err := filepath.Rel(projectDir, filepath.Join(dir, p))
+		if err != nil {
+			return p
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	switch build := svc["build"].(type) {
+	case string:
+		svc["build"] = rebase(build)
+	case map[string]interface{}:
+		if context, ok := build["context"].(string); ok {
+			build["context"] = rebase(context)
+		}
+	}
+
+	switch envFile := svc["env_file"].(type) {
+	case string:
+		svc["env_file"] = rebase(envFile)
+	case []interface{}:
+		for i, item := range envFile {
+			switch v := item.(type) {
+			case string:
+				envFile[i] = rebase(v)
+			case map[string]interface{}:
+				if p, ok := v["path"].(string); ok {
+					v["path"] = rebase(p)
+				}
+			}
+		}
+	}
+
+	volumes, _ := svc["volumes"].([]interface{})
+	for i, item := range volumes {
+		switch v := item.(type) {
+		case string:
+			// Only bind mounts have a path as their source
+			if source, rest, found := strings.Cut(v, ":"); found && strings.HasPrefix(source, ".") {
+				source = rebase(source)
+				if !strings.HasPrefix(source, ".") {
+					// Without it, the source would name a volume
+					source = "./" + source
+				}
+				volumes[i] = source + ":" + rest
+			}
+		case map[string]interface{}:
+			if source, ok := v["source"].(string); ok && v["type"] == "bind" {
+				v["source"] = rebase(source)
+			}
+		}
+	}
+}
+
+// copyComposeValue returns a deep copy of a parsed compose value
+func copyComposeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			result[k] = copyComposeValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = copyComposeValue(item)
+		}
+		return result
+	}
+	return v
+}
@@ -0,0 +1,184 @@
+package imports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testCompose = `name: shop
+services:
+  api:
+    image: shop/api
+    env_file:
+      - .env
+      - path: .env.local
+        required: false
+    environment:
+      MODE: dev
+    shm_size: 1g
+  debugger:
+    image: shop/debugger
+    profiles: [debug]
+    env_file: missing.env
+configs:
+  app: {}
+`
+
+func TestComposeImporterProfilesAndEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "compose.yaml")
+	if err := os.WriteFile(path, []byte(testCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("TOKEN=x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewComposeImporter().Import(ImportOptions{
+		Source:     SourceDockerCompose,
+		SourcePath: path,
+		OutputPath: filepath.Join(dir, "out", "cm-workspace.yaml"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := result.Workspace
+
+	api := ws.Services["api"]
+	if len(api.EnvFile) != 1 || api.EnvFile[0] != "../.env" {
+		t.Errorf("api env_file = %v, want the optional missing file left out", api.EnvFile)
+	}
+	if api.Environment["MODE"] != "dev" {
+		t.Errorf("api environment = %v", api.Environment)
+	}
+	if debugger := ws.Services["debugger"]; len(debugger.Profiles) != 1 || debugger.Profiles[0] != "debug" {
+		t.Errorf("debugger profiles = %v", debugger.Profiles)
+	}
+
+	if !hasWarning(result.Warnings, "ENV_FILE_MISSING") {
+		t.Error("expected a warning about the missing required env_file")
+	}
+	unsupported := make(map[string]bool)
+	for _, w := range result.Warnings {
+		if w.Code == "UNSUPPORTED_KEY" {
+			unsupported[w.Service+"/"+w.Field] = true
+		}
+	}
+	if len(unsupported) != 2 || !unsupported["api/shm_size"] || !unsupported["/configs"] {
+		t.Errorf("unsupported keys = %v", unsupported)
+	}
+}
+
+func TestLoadComposeFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"docker-compose.yml": `services:
+  api:
+    extends:
+      file: common/base.yml
+      service: go
+    command: ["serve"]
+    environment:
+      - MODE=dev
+      - DEBUG
+    ports: ["8080:8080"]
+    volumes:
+      - ./src:/app
+      - cache:/cache
+    depends_on: [db]
+  db:
+    image: postgres
+    ports: ["5432:5432"]
+`,
+		"docker-compose.override.yml": `services:
+  api:
+    command: ["serve", "--reload"]
+    environment:
+      MODE: prod
+    ports: ["9090:9090"]
+    volumes:
+      - ./other:/app
+    depends_on:
+      cache:
+        condition: service_healthy
+  db:
+    ports: !reset []
+`,
+		"common/base.yml": `services:
+  go:
+    build: ./go
+    env_file: go.env
+    environment:
+      GOFLAGS: -mod=mod
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	main := filepath.Join(dir, "docker-compose.yml")
+	override := ComposeOverrideFile(main)
+	if override != filepath.Join(dir, "docker-compose.override.yml") {
+		t.Fatalf("override file = %q", override)
+	}
+	data, err := LoadComposeFiles([]string{main, override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compose struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		t.Fatal(err)
+	}
+
+	api := compose.Services["api"]
+	if _, ok := api["extends"]; ok {
+		t.Error("extends should be resolved")
+	}
+	if got := fmt.Sprint(api["command"]); got != "[serve --reload]" {
+		t.Errorf("command = %s, want the override's", got)
+	}
+	if got := fmt.Sprint(api["environment"]); got != "map[DEBUG:<nil> GOFLAGS:-mod=mod MODE:prod]" {
+		t.Errorf("environment = %s", got)
+	}
+	if got := fmt.Sprint(api["ports"]); got != "[8080:8080 9090:9090]" {
+		t.Errorf("ports = %s, want both", got)
+	}
+	if got := fmt.Sprint(api["volumes"]); got != "[./other:/app cache:/cache]" {
+		t.Errorf("volumes = %s, want /app replaced", got)
+	}
+	if got := fmt.Sprint(api["depends_on"]); got != "map[cache:map[condition:service_healthy] db:map[]]" {
+		t.Errorf("depends_on = %s", got)
+	}
+	if got := fmt.Sprint(api["build"], " ", api["env_file"]); got != "common/go common/go.env" {
+		t.Errorf("build and env_file = %s, want them relative to the project", got)
+	}
+	if _, ok := compose.Services["db"]["ports"]; ok {
+		t.Error("!reset should remove db's ports")
+	}
+}
+
+func TestLoadComposeFilesExtendsCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose.yaml")
+	content := "services:\n  a:\n    extends: b\n  b:\n    extends: a\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadComposeFiles([]string{path}); err == nil {
+		t.Error("expected an error for an extends cycle")
+	}
+}
@@ -0,0 +1,158 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DevcontainerImporter converts a single-environment configuration (Dockerfile,
+// Gitpod, Codespaces, Vagrant, ...) into a devcontainer.json
+type DevcontainerImporter interface {
+	// CanHandle checks if this importer can handle the given file
+	CanHandle(path string) bool
+
+	// Convert generates the devcontainer.json; projectDir is the repository root
+	Convert(path, projectDir string) (*DevcontainerResult, error)
+}
+
+// DevcontainerImporters returns all devcontainer importers in match order
+func DevcontainerImporters() []DevcontainerImporter {
+	return []DevcontainerImporter{
+		NewDockerfileImporter(),
+		NewGitpodImporter(),
+		NewCodespacesImporter(),
+		NewVagrantImporter(),
+	}
+}
+
+// DevcontainerResult is a devcontainer.json generated from another project configuration
+type DevcontainerResult struct {
+	Source        ImportSource           `json:"source"`
+	SourceFile    string                 `json:"source_file"`
+	Config        map[string]interface{} `json:"config"`
+	Warnings      []ImportWarning        `json:"warnings,omitempty"`
+	Compatibility CompatibilityReport    `json:"compatibility"`
+	CreatedAt     time.Time              `json:"created_at"`
+
+	// Per-setting support levels used to build the compatibility report
+	support map[string]supportLevel
+}
+
+type supportLevel int
+
+const (
+	supportFull supportLevel = iota
+	supportPartial
+	supportNone
+)
+
+// JSON returns the generated devcontainer.json content
+func (r *DevcontainerResult) JSON() ([]byte, error) {
+	// Shell commands contain &, < and >, which must stay readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r.Config); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Write saves the config to .devcontainer/devcontainer.json under projectDir
+func (r *DevcontainerResult) Write(projectDir string) (string, error) {
+	return r.WriteTo(filepath.Join(projectDir, ".devcontainer", "devcontainer.json"))
+}
+
+// WriteTo saves the config to an explicit path
+func (r *DevcontainerResult) WriteTo(path string) (string, error) {
+	data, err := r.JSON()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0644)
+}
+
+func (r *DevcontainerResult) warn(code, message, suggestion string) {
+	r.Warnings = append(r.Warnings, ImportWarning{Code: code, Message: message, Suggestion: suggestion})
+}
+
+// track records how well a source setting maps to CM; the worst level wins
+func (r *DevcontainerResult) track(setting string, level supportLevel) {
+	if r.support == nil {
+		r.support = make(map[string]supportLevel)
+	}
+	if current, ok := r.support[setting]; !ok || level > current {
+		r.support[setting] = level
+	}
+}
+
+// finishCompatibility builds the compatibility report from tracked settings
+func (r *DevcontainerResult) finishCompatibility() {
+	report := CompatibilityReport{
+		FullySupported:  make([]string, 0),
+		PartialSupport:  make([]string, 0),
+		NotSupported:    make([]string, 0),
+		Recommendations: make([]string, 0),
+	}
+
+	settings := make([]string, 0, len(r.support))
+	for s := range r.support {
+		settings = append(settings, s)
+	}
+	sort.Strings(settings)
+
+	full, partial := 0, 0
+	for _, s := range settings {
+		switch r.support[s] {
+		case supportFull:
+			report.FullySupported = append(report.FullySupported, s)
+			full++
+		case supportPartial:
+			report.PartialSupport = append(report.PartialSupport, s)
+			partial++
+		default:
+			report.NotSupported = append(report.NotSupported, s)
+		}
+	}
+
+	total := len(settings)
+	if total == 0 {
+		total = 1
+	}
+	report.Score = (full*100 + partial*70) / total
+
+	for _, w := range r.Warnings {
+		if w.Suggestion != "" {
+			report.Recommendations = append(report.Recommendations, w.Suggestion)
+		}
+	}
+	r.Compatibility = report
+}
+
+// DockerfileImporter wraps ConvertDockerfile as a DevcontainerImporter
+type DockerfileImporter struct{}
+
+// NewDockerfileImporter creates a new Dockerfile importer
+func NewDockerfileImporter() *DockerfileImporter {
+	return &DockerfileImporter{}
+}
+
+// CanHandle checks if this importer can handle the file
+func (i *DockerfileImporter) CanHandle(path string) bool {
+	base := filepath.Base(path)
+	return base == "Dockerfile" || strings.HasPrefix(base, "Dockerfile.") || strings.HasSuffix(base, ".Dockerfile")
+}
+
+// Convert generates a devcontainer.json that builds the Dockerfile
+func (i *DockerfileImporter) Convert(path, projectDir string) (*DevcontainerResult, error) {
+	return ConvertDockerfile(path, projectDir)
+}
@@ -0,0 +1,208 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGitpod = `image: gitpod/workspace-node
+tasks:
+  - name: Dev Server
+    init: npm install
+    command: npm run dev
+    env:
+      PORT: "3000"
+ports:
+  - port: 3000
+    onOpen: open-browser
+    name: web
+    visibility: public
+  - port: 9000-9002
+vscode:
+  extensions:
+    - dbaeumer.vscode-eslint@2.4.0
+github:
+  prebuilds:
+    master: true
+`
+
+func TestGitpodImporter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitpod.yml")
+	if err := os.WriteFile(path, []byte(testGitpod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	importer := NewGitpodImporter()
+	if !importer.CanHandle(path) {
+		t.Fatal("expected importer to handle .gitpod.yml")
+	}
+	result, err := importer.Convert(path, dir)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	cfg := result.Config
+
+	if cfg["image"] != "gitpod/workspace-node" || cfg["remoteUser"] != "gitpod" {
+		t.Errorf("unexpected image/user: %v %v", cfg["image"], cfg["remoteUser"])
+	}
+	if cfg["postCreateCommand"] != "npm install" {
+		t.Errorf("postCreateCommand = %v", cfg["postCreateCommand"])
+	}
+	start, _ := cfg["postStartCommand"].(string)
+	if !strings.Contains(start, "npm run dev") || !strings.HasSuffix(start, "&") {
+		t.Errorf("postStartCommand should background the task, got %q", start)
+	}
+
+	ports, _ := cfg["forwardPorts"].([]interface{})
+	if len(ports) != 4 || ports[0] != 3000 || ports[3] != 9002 {
+		t.Errorf("forwardPorts = %v", cfg["forwardPorts"])
+	}
+
+	ext := cfg["customizations"].(map[string]interface{})["vscode"].(map[string]interface{})["extensions"].([]string)
+	if len(ext) != 1 || ext[0] != "dbaeumer.vscode-eslint" {
+		t.Errorf("extensions = %v", ext)
+	}
+
+	if !hasWarning(result.Warnings, "PREBUILDS") {
+		t.Error("expected PREBUILDS warning")
+	}
+	if len(result.Compatibility.NotSupported) == 0 || result.Compatibility.Score >= 100 {
+		t.Errorf("unexpected compatibility: %+v", result.Compatibility)
+	}
+}
+
+const testCodespaces = `{
+  // Codespaces config
+  "image": "mcr.microsoft.com/devcontainers/go:1",
+  "updateContentCommand": "go mod download",
+  "postCreateCommand": "make tools",
+  "waitFor": "updateContentCommand",
+  "hostRequirements": { "cpus": 4 },
+  "secrets": { "API_KEY": { "description": "API key" } },
+  "customizations": {
+    "codespaces": { "openFiles": ["README.md"] },
+    "vscode": { "extensions": ["golang.go"] }
+  }
+}`
+
+func TestCodespacesImporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".devcontainer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "devcontainer.json")
+	if err := os.WriteFile(path, []byte(testCodespaces), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewCodespacesImporter().Convert(path, filepath.Dir(dir))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	cfg := result.Config
+
+	if cfg["onCreateCommand"] != "go mod download" {
+		t.Errorf("onCreateCommand = %v", cfg["onCreateCommand"])
+	}
+	for _, key := range []string{"updateContentCommand", "waitFor", "secrets"} {
+		if _, ok := cfg[key]; ok {
+			t.Errorf("%s should be removed", key)
+		}
+	}
+	env := cfg["remoteEnv"].(map[string]interface{})
+	if env["API_KEY"] != "${localEnv:API_KEY}" {
+		t.Errorf("remoteEnv = %v", env)
+	}
+	custom := cfg["customizations"].(map[string]interface{})
+	if _, ok := custom["codespaces"]; ok {
+		t.Error("codespaces customizations should be removed")
+	}
+	if _, ok := custom["vscode"]; !ok {
+		t.Error("vscode customizations should be kept")
+	}
+	if _, ok := cfg["hostRequirements"]; !ok {
+		t.Error("hostRequirements should be kept")
+	}
+}
+
+func hasWarning(warnings []ImportWarning, code string) bool {
+	for _, w := range warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+const testVagrantfile = `# -*- mode: ruby -*-
+Vagrant.configure("2") do |config|
+  config.vm.box = "ubuntu/jammy64"
+  config.vm.hostname = "devbox"
+  config.vm.network "forwarded_port", guest: 3000, host: 3000
+  config.vm.network "forwarded_port", guest: 80, host: 8080
+  config.vm.network "private_network", ip: "192.168.56.10"
+  config.vm.synced_folder ".", "/vagrant"
+  config.vm.synced_folder "./data", "/srv/data"
+
+  config.vm.provider "virtualbox" do |vb|
+    vb.memory = "2048"
+    vb.cpus = 2
+    vb.gui = false
+  end
+
+  config.vm.provision "shell", inline: <<-SHELL
+    # install deps
+    apt-get update
+    apt-get install -y nodejs
+  SHELL
+  config.vm.provision "shell", path: "scripts/start.sh", run: "always"
+  config.vm.provision "ansible", playbook: "site.yml"
+end
+`
+
+func TestVagrantImporter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Vagrantfile")
+	if err := os.WriteFile(path, []byte(testVagrantfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewVagrantImporter().Convert(path, dir)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	cfg := result.Config
+
+	if cfg["image"] != "ubuntu:22.04" {
+		t.Errorf("image = %v", cfg["image"])
+	}
+	ports, _ := cfg["forwardPorts"].([]interface{})
+	if len(ports) != 2 || ports[0] != 3000 || ports[1] != "8080:80" {
+		t.Errorf("forwardPorts = %v", cfg["forwardPorts"])
+	}
+	if cfg["workspaceFolder"] != "/vagrant" {
+		t.Errorf("workspaceFolder = %v", cfg["workspaceFolder"])
+	}
+	mounts, _ := cfg["mounts"].([]string)
+	if len(mounts) != 1 || mounts[0] != "source=${localWorkspaceFolder}/data,target=/srv/data,type=bind" {
+		t.Errorf("mounts = %v", mounts)
+	}
+	runArgs, _ := cfg["runArgs"].([]string)
+	if strings.Join(runArgs, " ") != "--hostname=devbox --memory=2048m --cpus=2" {
+		t.Errorf("runArgs = %v", runArgs)
+	}
+	if cfg["postCreateCommand"] != "apt-get update && apt-get install -y nodejs" {
+		t.Errorf("postCreateCommand = %v", cfg["postCreateCommand"])
+	}
+	if cfg["postStartCommand"] != "bash ${containerWorkspaceFolder}/scripts/start.sh" {
+		t.Errorf("postStartCommand = %v", cfg["postStartCommand"])
+	}
+	for _, code := range []string{"NETWORK", "PROVIDER", "PROVISIONER"} {
+		if !hasWarning(result.Warnings, code) {
+			t.Errorf("expected %s warning", code)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceDockerfile identifies a plain Dockerfile import
+const SourceDockerfile ImportSource = "dockerfile"
+
+// DockerInstruction is one parsed Dockerfile instruction
+type DockerInstruction struct {
+	Command string // upper-cased, e.g. "FROM"
+	Args    string
+	Line    int
+}
+
+// dockerStage is one FROM ... section of a Dockerfile
+type dockerStage struct {
+	Name         string
+	Image        string
+	Instructions []DockerInstruction
+}
+
+// ParseDockerfile splits a Dockerfile into instructions, joining line continuations
+func ParseDockerfile(data []byte) []DockerInstruction {
+	var instructions []DockerInstruction
+	var current strings.Builder
+	startLine := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current.Len() == 0 {
+			startLine = lineNo
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+
+		full := strings.TrimSpace(current.String())
+		current.Reset()
+		cmd, args, _ := strings.Cut(full, " ")
+		instructions = append(instructions, DockerInstruction{
+			Command: strings.ToUpper(cmd),
+			Args:    strings.TrimSpace(args),
+			Line:    startLine,
+		})
+	}
+	return instructions
+}
+
+// splitStages groups instructions by FROM; instructions before the first FROM (global ARGs) go to the returned globals
+func splitStages(instructions []DockerInstruction) (globals []DockerInstruction, stages []*dockerStage) {
+	for _, inst := range instructions {
+		if inst.Command == "FROM" {
+			fields := strings.Fields(inst.Args)
+			var image, name string
+			for i := 0; i < len(fields); i++ {
+				f := fields[i]
+				switch {
+				case strings.HasPrefix(f, "--"):
+					continue // --platform=...
+				case strings.EqualFold(f, "AS") && i+1 < len(fields):
+					name = fields[i+1]
+					i++
+				case image == "":
+					image = f
+				}
+			}
+			stages = append(stages, &dockerStage{Name: name, Image: image})
+			continue
+		}
+		if len(stages) == 0 {
+			globals = append(globals, inst)
+			continue
+		}
+		stage := stages[len(stages)-1]
+		stage.Instructions = append(stage.Instructions, inst)
+	}
+	return globals, stages
+}
+
+// BaseImages returns the external images a Dockerfile builds FROM, with
+// global ARGs expanded from their defaults and the given build args. Stage
+// references and scratch are skipped.
+func BaseImages(data []byte, buildArgs map[string]string) []string {
+	globals, stages := splitStages(ParseDockerfile(data))
+
+	args := make(map[string]string)
+	for _, inst := range globals {
+		if inst.Command != "ARG" {
+			continue
+		}
+		name, value, _ := strings.Cut(inst.Args, "=")
+		args[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	for k, v := range buildArgs {
+		args[k] = v
+	}
+
+	stageNames := make(map[string]bool)
+	seen := make(map[string]bool)
+	var images []string
+	for _, s := range stages {
+		image := os.Expand(s.Image, func(name string) string { return args[name] })
+		if image != "" && image != "scratch" && !stageNames[strings.ToLower(image)] && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+		if s.Name != "" {
+			stageNames[strings.ToLower(s.Name)] = true
+		}
+	}
+	return images
+}
+
+// Tools whose packages map to a devcontainer Feature that adds value beyond the package itself
+var dockerPackageFeatures = map[string]string{
+	"docker.io":     "ghcr.io/devcontainers/features/docker-outside-of-docker:1",
+	"docker-ce":     "ghcr.io/devcontainers/features/docker-outside-of-docker:1",
+	"docker-ce-cli": "ghcr.io/devcontainers/features/docker-outside-of-docker:1",
+	"docker-cli":    "ghcr.io/devcontainers/features/docker-outside-of-docker:1",
+}
+
+// Script installers that have an equivalent Feature
+var dockerInstallerFeatures = []struct {
+	Pattern *regexp.Regexp
+	Tool    string
+	Feature string
+}{
+	{regexp.MustCompile(`sh\.rustup\.rs|rustup-init`), "Rust (rustup)", "ghcr.io/devcontainers/features/rust:1"},
+	{regexp.MustCompile(`nvm-sh/nvm|install\.sh.*nvm`), "Node.js (nvm)", "ghcr.io/devcontainers/features/node:1"},
+	{regexp.MustCompile(`get\.docker\.com`), "Docker (get.docker.com)", "ghcr.io/devcontainers/features/docker-outside-of-docker:1"},
+	{regexp.MustCompile(`get\.sdkman\.io`), "Java (SDKMAN)", "ghcr.io/devcontainers/features/java:1"},
+	{regexp.MustCompile(`pyenv\.run|pyenv-installer`), "Python (pyenv)", "ghcr.io/devcontainers/features/python:1"},
+	{regexp.MustCompile(`raw\.githubusercontent\.com/ohmyzsh`), "Oh My Zsh", "ghcr.io/devcontainers/features/common-utils:2"},
+}
+
+var (
+	packageInstallPattern = regexp.MustCompile(`(?:apt-get|apt|apk|yum|dnf|microdnf|zypper)\s+(?:-\S+\s+)*(?:install|add)\s+([^&|;]+)`)
+	secretEnvPattern      = regexp.MustCompile(`(?i)(password|secret|token|api_?key|private_?key)`)
+	productionEnvKeys     = map[string]bool{"NODE_ENV": true, "RAILS_ENV": true, "RACK_ENV": true, "FLASK_ENV": true, "APP_ENV": true, "GO_ENV": true, "DJANGO_ENV": true, "ASPNETCORE_ENVIRONMENT": true}
+	devStageNames         = map[string]bool{"dev": true, "development": true, "devcontainer": true, "develop": true}
+)
+
+// ConvertDockerfile generates a devcontainer.json that builds the given Dockerfile.
+// projectDir is the repository root; paths in the result are relative to .devcontainer/.
+func ConvertDockerfile(dockerfilePath, projectDir string) (*DevcontainerResult, error) {
+	data, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	globals, stages := splitStages(ParseDockerfile(data))
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("%s has no FROM instruction", dockerfilePath)
+	}
+
+	result := &DevcontainerResult{
+		Source:     SourceDockerfile,
+		SourceFile: dockerfilePath,
+		CreatedAt:  time.Now(),
+	}
+
+	absDockerfile, _ := filepath.Abs(dockerfilePath)
+	absProject, _ := filepath.Abs(projectDir)
+	relDockerfile, err := filepath.Rel(filepath.Join(absProject, ".devcontainer"), absDockerfile)
+	if err != nil {
+		relDockerfile = dockerfilePath
+	}
+	build := map[string]interface{}{
+		"dockerfile": filepath.ToSlash(relDockerfile),
+		"context":    "..",
+	}
+	config := map[string]interface{}{
+		"name":  filepath.Base(absProject),
+		"build": build,
+	}
+	result.Config = config
+
+	// Pick the stage to build: a stage named like "dev" wins, otherwise the final one
+	target := stages[len(stages)-1]
+	for _, s := range stages {
+		if devStageNames[strings.ToLower(s.Name)] {
+			target = s
+			build["target"] = s.Name
+		}
+	}
+	result.track("FROM", supportFull)
+	if len(stages) > 1 {
+		result.track("multi-stage build", supportPartial)
+		if _, ok := build["target"]; ok {
+			result.warn("MULTI_STAGE", fmt.Sprintf("Dockerfile has %d stages; building the '%s' stage", len(stages), target.Name), "")
+		} else {
+			result.warn("MULTI_STAGE",
+				fmt.Sprintf("Dockerfile has %d stages; the final stage is built and may lack build tools", len(stages)),
+				"Set build.target to the stage that has your compilers and dev dependencies")
+		}
+	}
+
+	// Resolve the chain of stages the target builds on
+	byName := make(map[string]*dockerStage)
+	for _, s := range stages {
+		if s.Name != "" {
+			byName[s.Name] = s
+		}
+	}
+	var chain []*dockerStage
+	for s := target; s != nil; s = byName[s.Image] {
+		chain = append([]*dockerStage{s}, chain...)
+		if len(chain) > len(stages) {
+			break // FROM cycle, invalid Dockerfile
+		}
+	}
+	baseImage := chain[0].Image
+
+	// Build args without defaults must be supplied
+	buildArgs := make(map[string]string)
+	for _, inst := range append(append([]DockerInstruction{}, globals...), collectInstructions(chain)...) {
+		if inst.Command != "ARG" {
+			continue
+		}
+		if name, _, hasDefault := strings.Cut(inst.Args, "="); !hasDefault {
+			buildArgs[strings.TrimSpace(name)] = ""
+			result.track("ARG", supportPartial)
+			result.warn("ARG_NO_DEFAULT", fmt.Sprintf("ARG %s has no default (line %d)", name, inst.Line),
+				"Fill in build.args in devcontainer.json")
+		}
+	}
+	if len(buildArgs) > 0 {
+		build["args"] = buildArgs
+	}
+
+	var ports []interface{}
+	seenPorts := make(map[int]bool)
+	remoteEnv := make(map[string]string)
+	features := make(map[string]interface{})
+	var user, workdir string
+	installsGit := false
+	copiesSources := false
+
+	for _, inst := range collectInstructions(chain) {
+		result.track(inst.Command, supportFull)
+		switch inst.Command {
+		case "EXPOSE":
+			for _, p := range strings.Fields(inst.Args) {
+				portStr, proto, _ := strings.Cut(p, "/")
+				port, err := strconv.Atoi(portStr)
+				if err != nil {
+					result.track("EXPOSE", supportPartial)
+					result.warn("EXPOSE_VARIABLE", fmt.Sprintf("EXPOSE %s cannot be forwarded statically (line %d)", p, inst.Line),
+						"Add the port to forwardPorts manually")
+					continue
+				}
+				if strings.EqualFold(proto, "udp") {
+					result.track("EXPOSE", supportPartial)
+					result.warn("EXPOSE_UDP", fmt.Sprintf("UDP port %d is not forwarded (line %d)", port, inst.Line),
+						"Publish it with runArgs: [\"-p\", \"<port>:<port>/udp\"]")
+					continue
+				}
+				if !seenPorts[port] {
+					seenPorts[port] = true
+					ports = append(ports, port)
+				}
+			}
+
+		case "ENV":
+			for key, value := range parseEnvArgs(inst.Args) {
+				if secretEnvPattern.MatchString(key) && value != "" {
+					result.warn("ENV_SECRET", fmt.Sprintf("ENV %s looks like a secret baked into the image (line %d)", key, inst.Line),
+						"Pass it at runtime with remoteEnv and ${localEnv:"+key+"} instead")
+				}
+				if productionEnvKeys[key] && strings.EqualFold(value, "production") {
+					remoteEnv[key] = "development"
+					result.warn("ENV_PRODUCTION", fmt.Sprintf("ENV %s=production (line %d); overridden to development in the dev container", key, inst.Line), "")
+				}
+			}
+
+		case "USER":
+			user = strings.TrimSpace(inst.Args)
+
+		case "WORKDIR":
+			workdir = strings.TrimSpace(inst.Args)
+
+		case "RUN":
+			for _, m := range packageInstallPattern.FindAllStringSubmatch(inst.Args, -1) {
+				for _, pkg := range strings.Fields(m[1]) {
+					if strings.HasPrefix(pkg, "-") {
+						continue
+					}
+					name, _, _ := strings.Cut(pkg, "=")
+					if name == "git" {
+						installsGit = true
+					}
+					if feature, ok := dockerPackageFeatures[name]; ok {
+						features[feature] = map[string]interface{}{}
+						result.warn("DOCKER_CLI", fmt.Sprintf("Installs %s (line %d); added docker-outside-of-docker so the CLI can reach the host daemon", name, inst.Line), "")
+					}
+				}
+			}
+			for _, installer := range dockerInstallerFeatures {
+				if installer.Pattern.MatchString(inst.Args) {
+					features[installer.Feature] = map[string]interface{}{}
+					result.warn("SCRIPT_INSTALL", fmt.Sprintf("%s is installed with a download script (line %d)", installer.Tool, inst.Line),
+						"Consider removing it from the Dockerfile and relying on the "+installer.Feature+" feature")
+				}
+			}
+
+		case "COPY", "ADD":
+			fields := strings.Fields(inst.Args)
+			if len(fields) >= 2 && !strings.HasPrefix(fields[0], "--from") && (fields[0] == "." || fields[0] == "./") {
+				copiesSources = true
+			}
+
+		case "VOLUME":
+			result.track("VOLUME", supportNone)
+			result.warn("VOLUME", fmt.Sprintf("VOLUME %s is not mapped (line %d)", inst.Args, inst.Line),
+				"Add a named volume to mounts if the data should persist")
+
+		case "HEALTHCHECK", "STOPSIGNAL", "ONBUILD", "SHELL":
+			result.track(inst.Command, supportNone)
+			result.warn("UNSUPPORTED", fmt.Sprintf("%s has no devcontainer.json equivalent and only applies to the image (line %d)", inst.Command, inst.Line), "")
+		}
+	}
+
+	// CMD/ENTRYPOINT only matter for the target stage itself
+	for _, inst := range target.Instructions {
+		switch inst.Command {
+		case "CMD":
+			result.track("CMD", supportPartial)
+			result.warn("CMD_OVERRIDDEN", fmt.Sprintf("CMD %s is replaced by a keep-alive command in dev containers (line %d)", inst.Args, inst.Line),
+				"Start the app from postStartCommand or your shell")
+		case "ENTRYPOINT":
+			result.track("ENTRYPOINT", supportPartial)
+			result.warn("ENTRYPOINT", fmt.Sprintf("ENTRYPOINT %s still runs when the container starts (line %d)", inst.Args, inst.Line),
+				"Make sure it ends with exec \"$@\" so the dev container command can run")
+		}
+	}
+
+	if len(ports) > 0 {
+		config["forwardPorts"] = ports
+	}
+	if len(remoteEnv) > 0 {
+		config["remoteEnv"] = remoteEnv
+	}
+	if user != "" {
+		config["remoteUser"] = user
+	}
+	if workdir != "" && !strings.Contains(workdir, "$") {
+		// Mount the sources where the image expects them
+		config["workspaceFolder"] = workdir
+		config["workspaceMount"] = fmt.Sprintf("source=${localWorkspaceFolder},target=%s,type=bind", workdir)
+		if copiesSources {
+			result.warn("COPY_SOURCES", fmt.Sprintf("Sources copied into %s are replaced by the live workspace mount", workdir), "")
+		}
+	}
+	if !installsGit && !strings.Contains(baseImage, "devcontainers") {
+		features["ghcr.io/devcontainers/features/git:1"] = map[string]interface{}{}
+	}
+	if len(features) > 0 {
+		config["features"] = features
+	}
+
+	result.finishCompatibility()
+	return result, nil
+}
+
+// collectInstructions flattens the instructions of a stage chain
+func collectInstructions(chain []*dockerStage) []DockerInstruction {
+	var all []DockerInstruction
+	for _, s := range chain {
+		all = append(all, s.Instructions...)
+	}
+	return all
+}
+
+// parseEnvArgs handles both ENV key=value ... and the legacy ENV key value form
+func parseEnvArgs(args string) map[string]string {
+	env := make(map[string]string)
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return env
+	}
+	if !strings.Contains(fields[0], "=") {
+		env[fields[0]] = strings.Trim(strings.Join(fields[1:], " "), `"'`)
+		return env
+	}
+	for _, f := range fields {
+		if key, value, ok := strings.Cut(f, "="); ok {
+			env[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return env
+}
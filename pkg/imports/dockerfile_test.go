@@ -0,0 +1,104 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDockerfile = `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.22
+ARG BUILD_ID
+
+FROM golang:${GO_VERSION} AS dev
+RUN apt-get update && apt-get install -y \
+    git \
+    docker.io \
+ && rm -rf /var/lib/apt/lists/*
+ENV NODE_ENV=production API_TOKEN=abc
+WORKDIR /app
+COPY . .
+EXPOSE 8080 9090/tcp 5353/udp
+USER gopher
+
+FROM gcr.io/distroless/base AS runtime
+COPY --from=dev /app/bin /bin/app
+CMD ["/bin/app"]
+`
+
+func TestConvertDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(path, []byte(testDockerfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ConvertDockerfile(path, dir)
+	if err != nil {
+		t.Fatalf("ConvertDockerfile failed: %v", err)
+	}
+	cfg := result.Config
+
+	build := cfg["build"].(map[string]interface{})
+	if build["dockerfile"] != "../Dockerfile" || build["context"] != ".." || build["target"] != "dev" {
+		t.Errorf("unexpected build config: %v", build)
+	}
+	if args := build["args"].(map[string]string); len(args) != 1 || args["BUILD_ID"] != "" {
+		t.Errorf("unexpected build args: %v", args)
+	}
+
+	ports := cfg["forwardPorts"].([]interface{})
+	if len(ports) != 2 || ports[0] != 8080 || ports[1] != 9090 {
+		t.Errorf("forwardPorts = %v, want [8080 9090]", ports)
+	}
+	if cfg["remoteUser"] != "gopher" || cfg["workspaceFolder"] != "/app" {
+		t.Errorf("unexpected user/workspace: %v %v", cfg["remoteUser"], cfg["workspaceFolder"])
+	}
+	if env := cfg["remoteEnv"].(map[string]string); env["NODE_ENV"] != "development" {
+		t.Errorf("remoteEnv = %v", env)
+	}
+
+	features := cfg["features"].(map[string]interface{})
+	if _, ok := features["ghcr.io/devcontainers/features/docker-outside-of-docker:1"]; !ok {
+		t.Errorf("expected docker-outside-of-docker feature, got %v", features)
+	}
+	if _, ok := features["ghcr.io/devcontainers/features/git:1"]; ok {
+		t.Error("git feature should not be suggested when git is installed")
+	}
+
+	codes := make(map[string]bool)
+	for _, w := range result.Warnings {
+		codes[w.Code] = true
+	}
+	for _, code := range []string{"MULTI_STAGE", "ARG_NO_DEFAULT", "EXPOSE_UDP", "ENV_SECRET", "ENV_PRODUCTION", "COPY_SOURCES"} {
+		if !codes[code] {
+			t.Errorf("missing warning %s (got %v)", code, codes)
+		}
+	}
+	if codes["CMD_OVERRIDDEN"] {
+		t.Error("CMD from a non-target stage should not be flagged")
+	}
+}
+
+func TestBaseImages(t *testing.T) {
+	data := []byte(`ARG GO_VERSION=1.22
+FROM golang:${GO_VERSION} AS build
+RUN go build ./...
+
+FROM build AS test
+FROM scratch AS empty
+FROM --platform=linux/amd64 gcr.io/distroless/base
+COPY --from=build /out /app
+`)
+	got := BaseImages(data, nil)
+	want := []string{"golang:1.22", "gcr.io/distroless/base"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("BaseImages = %v, want %v", got, want)
+	}
+
+	got = BaseImages(data, map[string]string{"GO_VERSION": "1.23"})
+	if got[0] != "golang:1.23" {
+		t.Errorf("build arg not applied: %v", got)
+	}
+}
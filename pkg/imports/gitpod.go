@@ -0,0 +1,342 @@
+package imports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceGitpod identifies a .gitpod.yml import
+const SourceGitpod ImportSource = "gitpod"
+
+// GitpodConfig represents the subset of .gitpod.yml we understand
+type GitpodConfig struct {
+	Image                  interface{}            `yaml:"image,omitempty"` // string or {file, context}
+	Tasks                  []GitpodTask           `yaml:"tasks,omitempty"`
+	Ports                  []GitpodPort           `yaml:"ports,omitempty"`
+	VSCode                 *GitpodVSCode          `yaml:"vscode,omitempty"`
+	GitHub                 map[string]interface{} `yaml:"github,omitempty"`
+	GitConfig              map[string]string      `yaml:"gitConfig,omitempty"`
+	WorkspaceLocation      string                 `yaml:"workspaceLocation,omitempty"`
+	CheckoutLocation       string                 `yaml:"checkoutLocation,omitempty"`
+	AdditionalRepositories []interface{}          `yaml:"additionalRepositories,omitempty"`
+	JetBrains              map[string]interface{} `yaml:"jetbrains,omitempty"`
+}
+
+// GitpodTask is one entry of tasks:
+type GitpodTask struct {
+	Name     string            `yaml:"name,omitempty"`
+	Before   string            `yaml:"before,omitempty"`
+	Init     string            `yaml:"init,omitempty"`
+	Command  string            `yaml:"command,omitempty"`
+	Env      map[string]string `yaml:"env,omitempty"`
+	OpenMode string            `yaml:"openMode,omitempty"`
+	OpenIn   string            `yaml:"openIn,omitempty"`
+}
+
+// GitpodPort is one entry of ports:
+type GitpodPort struct {
+	Port        interface{} `yaml:"port"` // number or "3000-3010"
+	OnOpen      string      `yaml:"onOpen,omitempty"`
+	Visibility  string      `yaml:"visibility,omitempty"`
+	Name        string      `yaml:"name,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+	Protocol    string      `yaml:"protocol,omitempty"`
+}
+
+// GitpodVSCode holds editor extensions
+type GitpodVSCode struct {
+	Extensions []string `yaml:"extensions,omitempty"`
+}
+
+// gitpodOnOpen maps ports[].onOpen to portsAttributes.onAutoForward
+var gitpodOnOpen = map[string]string{
+	"open-browser": "openBrowser",
+	"open-preview": "openPreview",
+	"notify":       "notify",
+	"ignore":       "ignore",
+}
+
+// maxExpandedPortRange limits how many ports a range like 3000-3010 expands to
+const maxExpandedPortRange = 20
+
+// GitpodImporter imports .gitpod.yml files
+type GitpodImporter struct{}
+
+// NewGitpodImporter creates a new Gitpod importer
+func NewGitpodImporter() *GitpodImporter {
+	return &GitpodImporter{}
+}
+
+// CanHandle checks if this importer can handle the file
+func (i *GitpodImporter) CanHandle(path string) bool {
+	base := filepath.Base(path)
+	return base == ".gitpod.yml" || base == ".gitpod.yaml"
+}
+
+// Convert generates a devcontainer.json from .gitpod.yml
+func (i *GitpodImporter) Convert(path, projectDir string) (*DevcontainerResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var gp GitpodConfig
+	if err := yaml.Unmarshal(data, &gp); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	absProject, _ := filepath.Abs(projectDir)
+	result := &DevcontainerResult{
+		Source:     SourceGitpod,
+		SourceFile: path,
+		CreatedAt:  time.Now(),
+	}
+	config := map[string]interface{}{
+		"name": filepath.Base(absProject),
+	}
+	result.Config = config
+
+	i.convertImage(gp.Image, config, result)
+	i.convertTasks(gp.Tasks, config, result)
+	i.convertPorts(gp.Ports, config, result)
+
+	if gp.VSCode != nil && len(gp.VSCode.Extensions) > 0 {
+		var extensions []string
+		for _, ext := range gp.VSCode.Extensions {
+			if strings.Contains(ext, "://") {
+				result.track("vscode.extensions", supportPartial)
+				result.warn("EXTENSION_URL", fmt.Sprintf("Extension %s is installed from a URL", ext),
+					"Publish it to the marketplace or install the .vsix manually")
+				continue
+			}
+			id, _, _ := strings.Cut(ext, "@") // Gitpod allows id@version
+			extensions = append(extensions, id)
+		}
+		result.track("vscode.extensions", supportFull)
+		config["customizations"] = map[string]interface{}{
+			"vscode": map[string]interface{}{"extensions": extensions},
+		}
+	}
+
+	if len(gp.GitConfig) > 0 {
+		result.track("gitConfig", supportFull)
+		keys := make([]string, 0, len(gp.GitConfig))
+		for k := range gp.GitConfig {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var cmds []string
+		for _, k := range keys {
+			cmds = append(cmds, fmt.Sprintf("git config --global %s %s", k, shellQuote(gp.GitConfig[k])))
+		}
+		appendCommand(config, "onCreateCommand", strings.Join(cmds, " && "))
+	}
+
+	if prebuilds, ok := gp.GitHub["prebuilds"]; ok && prebuilds != nil {
+		result.track("github.prebuilds", supportNone)
+		result.warn("PREBUILDS", "Gitpod prebuilds are not converted",
+			"Build and push the dev container image in CI and reference it with \"image\"")
+	}
+	if gp.WorkspaceLocation != "" || gp.CheckoutLocation != "" {
+		result.track("workspaceLocation", supportNone)
+		result.warn("LOCATION", "workspaceLocation/checkoutLocation are ignored; the project is mounted at /workspaces/<name>", "")
+	}
+	if len(gp.AdditionalRepositories) > 0 {
+		result.track("additionalRepositories", supportNone)
+		result.warn("MULTI_REPO", fmt.Sprintf("%d additional repositories are not cloned", len(gp.AdditionalRepositories)),
+			"Clone them in onCreateCommand or use a CM workspace")
+	}
+	if len(gp.JetBrains) > 0 {
+		result.track("jetbrains", supportNone)
+		result.warn("JETBRAINS", "JetBrains IDE settings are not converted", "")
+	}
+
+	result.finishCompatibility()
+	return result, nil
+}
+
+// convertImage maps image: to image or build
+func (i *GitpodImporter) convertImage(image interface{}, config map[string]interface{}, result *DevcontainerResult) {
+	switch img := image.(type) {
+	case nil:
+		result.track("image", supportPartial)
+		config["image"] = "gitpod/workspace-full"
+		result.warn("DEFAULT_IMAGE", "No image set; using Gitpod's default gitpod/workspace-full (several GB)",
+			"Pick a smaller base such as mcr.microsoft.com/devcontainers/base:ubuntu")
+	case string:
+		result.track("image", supportFull)
+		config["image"] = img
+		if strings.HasPrefix(img, "gitpod/workspace-") {
+			result.warn("GITPOD_IMAGE", fmt.Sprintf("%s is a large Gitpod image that expects the gitpod user", img),
+				"Consider an mcr.microsoft.com/devcontainers image plus features")
+			config["remoteUser"] = "gitpod"
+		}
+	case map[string]interface{}:
+		result.track("image", supportFull)
+		file, _ := img["file"].(string)
+		context, _ := img["context"].(string)
+		if context == "" {
+			context = "."
+		}
+		config["build"] = map[string]interface{}{
+			"dockerfile": filepath.ToSlash(filepath.Join("..", file)),
+			"context":    filepath.ToSlash(filepath.Join("..", context)),
+		}
+	}
+}
+
+// convertTasks maps Gitpod task phases onto lifecycle commands.
+// init runs once after creation; before and command run on every start, with
+// long-running commands moved to the background so startup is not blocked.
+func (i *GitpodImporter) convertTasks(tasks []GitpodTask, config map[string]interface{}, result *DevcontainerResult) {
+	remoteEnv := make(map[string]string)
+
+	for idx, task := range tasks {
+		name := task.Name
+		if name == "" {
+			name = fmt.Sprintf("task-%d", idx+1)
+		}
+		slug := strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}), "-"))
+
+		if task.Init != "" {
+			result.track("tasks.init", supportFull)
+			appendCommand(config, "postCreateCommand", joinTaskCommand(task.Before, task.Init))
+		}
+		if task.Command != "" {
+			result.track("tasks.command", supportPartial)
+			logFile := fmt.Sprintf("/tmp/cm-task-%s.log", slug)
+			script := joinTaskCommand(task.Before, task.Command)
+			appendCommand(config, "postStartCommand",
+				fmt.Sprintf("nohup sh -c %s > %s 2>&1 &", shellQuote(script), logFile))
+			result.warn("TASK_BACKGROUND", fmt.Sprintf("Task '%s' runs in the background on start (log: %s)", name, logFile), "")
+		} else if task.Before != "" && task.Init == "" {
+			result.track("tasks.before", supportFull)
+			appendCommand(config, "postStartCommand", task.Before)
+		}
+
+		for k, v := range task.Env {
+			if existing, ok := remoteEnv[k]; ok && existing != v {
+				result.warn("TASK_ENV_CONFLICT", fmt.Sprintf("Tasks set %s to different values; using %q", k, v), "")
+			}
+			remoteEnv[k] = v
+			result.track("tasks.env", supportFull)
+		}
+		if task.OpenMode != "" || task.OpenIn != "" {
+			result.track("tasks.openMode", supportNone)
+		}
+	}
+
+	if len(remoteEnv) > 0 {
+		config["remoteEnv"] = remoteEnv
+	}
+}
+
+// convertPorts maps ports: to forwardPorts and portsAttributes
+func (i *GitpodImporter) convertPorts(ports []GitpodPort, config map[string]interface{}, result *DevcontainerResult) {
+	var forward []interface{}
+	attributes := make(map[string]interface{})
+
+	for _, p := range ports {
+		var numbers []int
+		key := fmt.Sprintf("%v", p.Port)
+		switch v := p.Port.(type) {
+		case int:
+			numbers = []int{v}
+		case string:
+			lo, hi, isRange := strings.Cut(v, "-")
+			start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+			end := start
+			var err2 error
+			if isRange {
+				end, err2 = strconv.Atoi(strings.TrimSpace(hi))
+			}
+			if err1 != nil || err2 != nil || end < start {
+				result.track("ports", supportPartial)
+				result.warn("PORT_INVALID", fmt.Sprintf("Cannot parse port %q", v), "")
+				continue
+			}
+			if end-start+1 > maxExpandedPortRange {
+				result.track("ports", supportPartial)
+				result.warn("PORT_RANGE", fmt.Sprintf("Port range %s is too large to forward; only attributes are kept", v),
+					"List the ports you actually use in forwardPorts")
+			} else {
+				for n := start; n <= end; n++ {
+					numbers = append(numbers, n)
+				}
+			}
+		}
+		result.track("ports", supportFull)
+		for _, n := range numbers {
+			forward = append(forward, n)
+		}
+
+		attr := make(map[string]interface{})
+		if p.Name != "" {
+			attr["label"] = p.Name
+		}
+		if p.OnOpen != "" {
+			if mapped, ok := gitpodOnOpen[p.OnOpen]; ok {
+				attr["onAutoForward"] = mapped
+			}
+		}
+		if p.Protocol == "https" {
+			attr["protocol"] = "https"
+		}
+		if len(attr) > 0 {
+			attributes[key] = attr
+		}
+
+		if p.Visibility == "public" {
+			result.track("ports.visibility", supportNone)
+			result.warn("PORT_PUBLIC", fmt.Sprintf("Port %s is public in Gitpod; CM only forwards to localhost", key),
+				"Expose it through a tunnel if others need access")
+		}
+	}
+
+	if len(forward) > 0 {
+		config["forwardPorts"] = forward
+	}
+	if len(attributes) > 0 {
+		config["portsAttributes"] = attributes
+	}
+}
+
+// joinTaskCommand chains a task's before script with its main command
+func joinTaskCommand(before, cmd string) string {
+	before, cmd = strings.TrimSpace(before), strings.TrimSpace(cmd)
+	if before == "" {
+		return cmd
+	}
+	return before + " && " + cmd
+}
+
+// appendCommand adds a shell command to a string lifecycle hook
+func appendCommand(config map[string]interface{}, hook, cmd string) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+	if existing, ok := config[hook].(string); ok && existing != "" {
+		if strings.HasSuffix(existing, " &") {
+			config[hook] = existing + " " + cmd // previous command was backgrounded
+		} else {
+			config[hook] = existing + " && " + cmd
+		}
+		return
+	}
+	config[hook] = cmd
+}
+
+// shellQuote wraps s in single quotes for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -130,6 +130,7 @@ type CompatibilityReport struct {
 
 // ComposeFile represents a docker-compose.yml structure
 type ComposeFile struct {
+	Name     string                     `yaml:"name,omitempty"`
 	Version  string                     `yaml:"version,omitempty"`
 	Services map[string]*ComposeService `yaml:"services"`
 	Networks map[string]*ComposeNetwork `yaml:"networks,omitempty"`
@@ -172,6 +173,7 @@ type ComposeService struct {
 	StopSignal      string                 `yaml:"stop_signal,omitempty"`
 	StopGracePeriod string                 `yaml:"stop_grace_period,omitempty"`
 	Runtime         string                 `yaml:"runtime,omitempty"`
+	Profiles        []string               `yaml:"profiles,omitempty"`
 }
 
 // ComposeHealthCheck represents healthcheck configuration
@@ -203,6 +205,7 @@ type ComposeResources struct {
 type ComposeResourceSpec struct {
 	CPUs    string                 `yaml:"cpus,omitempty"`
 	Memory  string                 `yaml:"memory,omitempty"`
+	Pids    int                    `yaml:"pids,omitempty"`
 	Devices []ComposeDeviceRequest `yaml:"devices,omitempty"`
 }
 
@@ -237,6 +240,19 @@ type ComposeNetwork struct {
 	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
 	Attachable bool              `yaml:"attachable,omitempty"`
 	Internal   bool              `yaml:"internal,omitempty"`
+	IPAM       *ComposeIPAM      `yaml:"ipam,omitempty"`
+}
+
+// ComposeIPAM represents a network's IP address management
+type ComposeIPAM struct {
+	Driver string            `yaml:"driver,omitempty"`
+	Config []ComposeIPAMPool `yaml:"config,omitempty"`
+}
+
+// ComposeIPAMPool represents one IPAM subnet
+type ComposeIPAMPool struct {
+	Subnet  string `yaml:"subnet,omitempty"`
+	Gateway string `yaml:"gateway,omitempty"`
 }
 
 // ComposeVolume represents a volume definition
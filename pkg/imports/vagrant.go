@@ -0,0 +1,540 @@
+package imports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceVagrant identifies a Vagrantfile import
+const SourceVagrant ImportSource = "vagrant"
+
+// vagrantBoxImages maps well-known boxes to equivalent container images
+var vagrantBoxImages = map[string]string{
+	"ubuntu/noble64":       "ubuntu:24.04",
+	"ubuntu/jammy64":       "ubuntu:22.04",
+	"ubuntu/focal64":       "ubuntu:20.04",
+	"ubuntu/bionic64":      "ubuntu:18.04",
+	"ubuntu/xenial64":      "ubuntu:16.04",
+	"hashicorp/bionic64":   "ubuntu:18.04",
+	"bento/ubuntu-24.04":   "ubuntu:24.04",
+	"bento/ubuntu-22.04":   "ubuntu:22.04",
+	"bento/ubuntu-20.04":   "ubuntu:20.04",
+	"generic/ubuntu2204":   "ubuntu:22.04",
+	"generic/ubuntu2004":   "ubuntu:20.04",
+	"debian/bookworm64":    "debian:12",
+	"debian/bullseye64":    "debian:11",
+	"debian/buster64":      "debian:10",
+	"bento/debian-12":      "debian:12",
+	"bento/debian-11":      "debian:11",
+	"generic/debian12":     "debian:12",
+	"generic/debian11":     "debian:11",
+	"centos/7":             "centos:7",
+	"centos/stream9":       "quay.io/centos/centos:stream9",
+	"generic/centos7":      "centos:7",
+	"rockylinux/8":         "rockylinux:8",
+	"rockylinux/9":         "rockylinux:9",
+	"bento/rockylinux-9":   "rockylinux:9",
+	"almalinux/8":          "almalinux:8",
+	"almalinux/9":          "almalinux:9",
+	"generic/alpine318":    "alpine:3.18",
+	"generic/alpine319":    "alpine:3.19",
+	"fedora/39-cloud-base": "fedora:39",
+	"generic/fedora39":     "fedora:39",
+	"archlinux/archlinux":  "archlinux:latest",
+}
+
+const vagrantFallbackImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+var (
+	vagrantConfigurePattern = regexp.MustCompile(`Vagrant\.configure\([^)]*\)\s+do\s+\|(\w+)\|`)
+	vagrantProviderPattern  = regexp.MustCompile(`\.vm\.provider\s+[:"']?(\w+)["']?(?:.*do\s+\|(\w+)\|)?`)
+	vagrantDefinePattern    = regexp.MustCompile(`\.vm\.define\s+[:"']?([\w-]+)`)
+	vagrantHeredocPattern   = regexp.MustCompile(`<<[-~]?\s*["']?(\w+)["']?`)
+	vagrantStringPattern    = regexp.MustCompile(`^\s*["']((?:[^"'\\]|\\.)*)["']`)
+	vagrantOptionPattern    = regexp.MustCompile(`(\w+):\s*("(?:[^"\\]|\\.)*"|'[^']*'|[\w.]+)`)
+	vagrantOldOptionPattern = regexp.MustCompile(`:(\w+)\s*=>\s*("(?:[^"\\]|\\.)*"|'[^']*'|[\w.]+)`)
+	vagrantCompoundPattern  = regexp.MustCompile(`\b(then|do|done|fi|esac|case)\b|<<|[{}]\s*$`)
+)
+
+// VagrantImporter imports Vagrantfiles
+type VagrantImporter struct{}
+
+// NewVagrantImporter creates a new Vagrant importer
+func NewVagrantImporter() *VagrantImporter {
+	return &VagrantImporter{}
+}
+
+// CanHandle checks if this importer can handle the file
+func (i *VagrantImporter) CanHandle(path string) bool {
+	return filepath.Base(path) == "Vagrantfile"
+}
+
+// vagrantStatement is one logical setting from a Vagrantfile, e.g.
+// config.vm.network "forwarded_port", guest: 80, host: 8080
+type vagrantStatement struct {
+	Line     int
+	Target   string // receiver variable, e.g. "config" or "vb"
+	Setting  string // e.g. "vm.network"
+	Args     string // raw text after the setting
+	Heredoc  string // body of a heredoc argument, if any
+	Provider string // provider block the statement belongs to
+}
+
+// parseVagrantfile extracts settings from a Vagrantfile. Ruby is not evaluated;
+// only the declarative subset that nearly every Vagrantfile uses is recognised.
+func parseVagrantfile(data []byte) (configVar string, stmts []vagrantStatement) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	configVar = "config"
+	providers := make(map[string]string) // block variable -> provider name
+
+	for n := 0; n < len(lines); n++ {
+		line := strings.TrimSpace(lines[n])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := vagrantConfigurePattern.FindStringSubmatch(line); m != nil {
+			configVar = m[1]
+			continue
+		}
+
+		// Setting statements look like <var>.<setting> ...
+		dot := strings.Index(line, ".")
+		if dot <= 0 {
+			continue
+		}
+		target := line[:dot]
+		if !isRubyIdent(target) {
+			continue
+		}
+		rest := line[dot+1:]
+		setting := rest
+		args := ""
+		if idx := strings.IndexAny(rest, " =("); idx >= 0 {
+			setting = rest[:idx]
+			args = strings.TrimSpace(rest[idx:])
+			args = strings.TrimSpace(strings.TrimPrefix(args, "="))
+		}
+
+		stmt := vagrantStatement{Line: n + 1, Target: target, Setting: setting, Args: args}
+		if provider, ok := providers[target]; ok {
+			stmt.Provider = provider
+		}
+		if setting == "vm.provider" {
+			if m := vagrantProviderPattern.FindStringSubmatch(line); m != nil && m[2] != "" {
+				providers[m[2]] = m[1]
+			}
+		}
+
+		// Continuation lines: trailing comma or backslash
+		for (strings.HasSuffix(stmt.Args, ",") || strings.HasSuffix(stmt.Args, "\\")) && n+1 < len(lines) {
+			n++
+			stmt.Args = strings.TrimSuffix(stmt.Args, "\\") + " " + strings.TrimSpace(lines[n])
+		}
+
+		if m := vagrantHeredocPattern.FindStringSubmatch(stmt.Args); m != nil {
+			var body []string
+			for n+1 < len(lines) {
+				n++
+				if strings.TrimSpace(lines[n]) == m[1] {
+					break
+				}
+				body = append(body, lines[n])
+			}
+			stmt.Heredoc = strings.Join(body, "\n")
+		}
+
+		stmts = append(stmts, stmt)
+	}
+	return configVar, stmts
+}
+
+func isRubyIdent(s string) bool {
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return s != ""
+}
+
+// vagrantOptions parses Ruby keyword arguments (guest: 80, host: "x") into strings
+func vagrantOptions(args string) map[string]string {
+	opts := make(map[string]string)
+	for _, pattern := range []*regexp.Regexp{vagrantOptionPattern, vagrantOldOptionPattern} {
+		for _, m := range pattern.FindAllStringSubmatch(args, -1) {
+			opts[m[1]] = unquoteRuby(m[2])
+		}
+	}
+	return opts
+}
+
+// vagrantKind returns the first argument of network/provision, quoted or as a symbol
+func vagrantKind(args string) string {
+	if pos := vagrantPositional(args); len(pos) > 0 {
+		return pos[0]
+	}
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(args), "("))
+	if len(fields) > 0 && strings.HasPrefix(fields[0], ":") {
+		return strings.TrimSuffix(fields[0][1:], ",")
+	}
+	return ""
+}
+
+// vagrantPositional returns the leading quoted string arguments
+func vagrantPositional(args string) []string {
+	var values []string
+	rest := strings.TrimPrefix(strings.TrimSpace(args), "(")
+	for {
+		m := vagrantStringPattern.FindStringSubmatchIndex(rest)
+		if m == nil {
+			return values
+		}
+		values = append(values, unquoteRuby(rest[m[0]:m[1]]))
+		rest = strings.TrimSpace(rest[m[1]:])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+}
+
+func unquoteRuby(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+		if s != "" && !strings.Contains(s, "#{") {
+			s = strings.ReplaceAll(s, `\"`, `"`)
+		}
+	}
+	return s
+}
+
+// Convert generates a devcontainer.json from a Vagrantfile
+func (i *VagrantImporter) Convert(path, projectDir string) (*DevcontainerResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	configVar, stmts := parseVagrantfile(data)
+
+	absProject, _ := filepath.Abs(projectDir)
+	result := &DevcontainerResult{
+		Source:     SourceVagrant,
+		SourceFile: path,
+		CreatedAt:  time.Now(),
+	}
+	config := map[string]interface{}{
+		"name": filepath.Base(absProject),
+	}
+	result.Config = config
+
+	var (
+		forwardPorts []interface{}
+		mounts       []string
+		runArgs      []string
+		machines     []string
+		providerSeen = make(map[string]bool)
+
+		provisionsAsRoot bool
+	)
+
+	for _, stmt := range stmts {
+		if stmt.Provider != "" {
+			if arg, ok := i.convertProviderSetting(stmt, config, result); ok {
+				runArgs = append(runArgs, arg)
+			}
+			continue
+		}
+		if stmt.Target != configVar && !strings.HasPrefix(stmt.Setting, "vm.") {
+			continue
+		}
+
+		switch stmt.Setting {
+		case "vm.box":
+			box := unquoteRuby(stmt.Args)
+			if image, ok := vagrantBoxImages[box]; ok {
+				result.track("vm.box", supportFull)
+				config["image"] = image
+			} else {
+				result.track("vm.box", supportPartial)
+				config["image"] = vagrantFallbackImage
+				result.warn("BOX_UNKNOWN", fmt.Sprintf("No container image is known for box %q (line %d); using %s", box, stmt.Line, vagrantFallbackImage),
+					"Set \"image\" to the closest container image for your box")
+			}
+
+		case "vm.box_version", "vm.box_check_update", "vm.box_url":
+			result.track(stmt.Setting, supportNone)
+
+		case "vm.hostname":
+			result.track("vm.hostname", supportFull)
+			runArgs = append(runArgs, "--hostname="+unquoteRuby(stmt.Args))
+
+		case "vm.network":
+			i.convertNetwork(stmt, &forwardPorts, result)
+
+		case "vm.synced_folder":
+			i.convertSyncedFolder(stmt, config, &mounts, result)
+
+		case "vm.provision":
+			if i.convertProvision(stmt, config, result) {
+				provisionsAsRoot = true
+			}
+
+		case "vm.provider":
+			provider := ""
+			if m := vagrantProviderPattern.FindStringSubmatch("." + stmt.Setting + " " + stmt.Args); m != nil {
+				provider = m[1]
+			}
+			if provider != "" && !providerSeen[provider] {
+				providerSeen[provider] = true
+				result.track("vm.provider "+provider, supportFull)
+			}
+
+		case "vm.define":
+			if m := vagrantDefinePattern.FindStringSubmatch("." + stmt.Setting + " " + stmt.Args); m != nil {
+				machines = append(machines, m[1])
+			}
+
+		default:
+			if strings.HasPrefix(stmt.Setting, "vm.") || strings.HasPrefix(stmt.Setting, "ssh.") ||
+				strings.HasPrefix(stmt.Setting, "vagrant.") || strings.HasPrefix(stmt.Setting, "winrm.") {
+				result.track(stmt.Setting, supportNone)
+				result.warn("UNSUPPORTED", fmt.Sprintf("%s.%s (line %d) has no container equivalent", stmt.Target, stmt.Setting, stmt.Line), "")
+			}
+		}
+	}
+
+	if _, ok := config["image"]; !ok {
+		result.track("vm.box", supportPartial)
+		config["image"] = vagrantFallbackImage
+		result.warn("BOX_MISSING", "No config.vm.box found; using "+vagrantFallbackImage, "")
+	}
+	if provisionsAsRoot {
+		result.warn("PROVISION_ROOT", "Vagrant ran shell provisioners as root",
+			"Prefix commands with sudo if the image uses a non-root user")
+	}
+	if len(machines) > 1 {
+		result.track("vm.define", supportPartial)
+		result.warn("MULTI_MACHINE", fmt.Sprintf("%d machines are defined (%s); their settings were merged into one container",
+			len(machines), strings.Join(machines, ", ")),
+			"Model each machine as a service in a CM workspace (cm-workspace.yaml)")
+	}
+
+	if len(forwardPorts) > 0 {
+		config["forwardPorts"] = forwardPorts
+	}
+	if len(mounts) > 0 {
+		config["mounts"] = mounts
+	}
+	if len(runArgs) > 0 {
+		config["runArgs"] = runArgs
+	}
+
+	result.finishCompatibility()
+	return result, nil
+}
+
+// convertNetwork maps forwarded ports; private and public networks have no equivalent
+func (i *VagrantImporter) convertNetwork(stmt vagrantStatement, forwardPorts *[]interface{}, result *DevcontainerResult) {
+	kind := vagrantKind(stmt.Args)
+	opts := vagrantOptions(stmt.Args)
+
+	switch kind {
+	case "forwarded_port":
+		guest, err := strconv.Atoi(opts["guest"])
+		if err != nil {
+			result.track("vm.network", supportPartial)
+			result.warn("PORT_INVALID", fmt.Sprintf("Cannot read the guest port on line %d", stmt.Line), "")
+			return
+		}
+		result.track("vm.network forwarded_port", supportFull)
+		host := opts["host"]
+		protocol := opts["protocol"]
+		switch {
+		case host != "" && host != opts["guest"] && protocol == "udp":
+			*forwardPorts = append(*forwardPorts, fmt.Sprintf("%s:%d/udp", host, guest))
+		case host != "" && host != opts["guest"]:
+			*forwardPorts = append(*forwardPorts, fmt.Sprintf("%s:%d", host, guest))
+		case protocol == "udp":
+			*forwardPorts = append(*forwardPorts, fmt.Sprintf("%d/udp", guest))
+		default:
+			*forwardPorts = append(*forwardPorts, guest)
+		}
+		if opts["host_ip"] != "" && opts["host_ip"] != "127.0.0.1" {
+			result.warn("PORT_HOST_IP", fmt.Sprintf("host_ip %s on line %d is ignored", opts["host_ip"], stmt.Line), "")
+		}
+	case "private_network", "public_network":
+		result.track("vm.network "+kind, supportNone)
+		result.warn("NETWORK", fmt.Sprintf("%s on line %d is not converted; containers use Docker networking", kind, stmt.Line),
+			"Use forwarded ports, or a CM workspace network for multi-container setups")
+	default:
+		result.track("vm.network", supportNone)
+		result.warn("NETWORK", fmt.Sprintf("Unrecognised network on line %d", stmt.Line), "")
+	}
+}
+
+// convertSyncedFolder maps synced folders to bind mounts; the project root becomes the workspace
+func (i *VagrantImporter) convertSyncedFolder(stmt vagrantStatement, config map[string]interface{}, mounts *[]string, result *DevcontainerResult) {
+	pos := vagrantPositional(stmt.Args)
+	if len(pos) < 2 {
+		result.track("vm.synced_folder", supportPartial)
+		result.warn("SYNCED_FOLDER", fmt.Sprintf("Cannot read synced folder on line %d", stmt.Line), "")
+		return
+	}
+	opts := vagrantOptions(stmt.Args)
+	if opts["disabled"] == "true" {
+		return
+	}
+
+	hostPath, guestPath := filepath.ToSlash(pos[0]), pos[1]
+	switch opts["type"] {
+	case "", "virtualbox", "docker":
+		result.track("vm.synced_folder", supportFull)
+	default:
+		result.track("vm.synced_folder", supportPartial)
+		result.warn("SYNCED_FOLDER_TYPE", fmt.Sprintf("%s synced folder on line %d becomes a bind mount", opts["type"], stmt.Line), "")
+	}
+
+	if hostPath == "." || hostPath == "./" {
+		config["workspaceFolder"] = guestPath
+		config["workspaceMount"] = fmt.Sprintf("source=${localWorkspaceFolder},target=%s,type=bind", guestPath)
+		return
+	}
+
+	source := hostPath
+	if !filepath.IsAbs(hostPath) && !strings.HasPrefix(hostPath, "~") {
+		source = "${localWorkspaceFolder}/" + strings.TrimPrefix(hostPath, "./")
+	} else if strings.HasPrefix(hostPath, "~") {
+		source = "${localEnv:HOME}" + strings.TrimPrefix(hostPath, "~")
+	}
+	*mounts = append(*mounts, fmt.Sprintf("source=%s,target=%s,type=bind", source, guestPath))
+}
+
+// convertProvision maps shell provisioners to lifecycle commands and reports
+// whether the script ran as root in Vagrant
+func (i *VagrantImporter) convertProvision(stmt vagrantStatement, config map[string]interface{}, result *DevcontainerResult) (asRoot bool) {
+	kind := vagrantKind(stmt.Args)
+	opts := vagrantOptions(stmt.Args)
+
+	if kind != "shell" {
+		result.track("vm.provision "+kind, supportNone)
+		result.warn("PROVISIONER", fmt.Sprintf("The %s provisioner on line %d is not converted", kind, stmt.Line),
+			"Translate it to a Dockerfile, a feature, or postCreateCommand")
+		return false
+	}
+
+	var cmd string
+	switch {
+	case stmt.Heredoc != "":
+		cmd = joinScriptLines(stmt.Heredoc)
+	case opts["inline"] != "":
+		cmd = strings.ReplaceAll(opts["inline"], `\n`, "\n")
+		cmd = joinScriptLines(cmd)
+	case opts["path"] != "":
+		script := strings.TrimPrefix(filepath.ToSlash(opts["path"]), "./")
+		cmd = "bash ${containerWorkspaceFolder}/" + script
+		if opts["args"] != "" {
+			cmd += " " + opts["args"]
+		}
+	}
+	if cmd == "" {
+		result.track("vm.provision shell", supportPartial)
+		result.warn("PROVISION_UNREADABLE", fmt.Sprintf("Cannot read the shell provisioner on line %d", stmt.Line),
+			"Copy its commands into postCreateCommand")
+		return false
+	}
+
+	if strings.Contains(cmd, "#{") {
+		result.track("vm.provision shell", supportPartial)
+		result.warn("RUBY_INTERPOLATION", fmt.Sprintf("The provisioner on line %d uses Ruby interpolation", stmt.Line),
+			"Replace #{...} with literal values")
+	} else {
+		result.track("vm.provision shell", supportFull)
+	}
+
+	hook := "postCreateCommand"
+	if opts["run"] == "always" {
+		hook = "postStartCommand"
+	}
+	appendCommand(config, hook, cmd)
+
+	// Vagrant provisions as root unless privileged: false
+	return opts["privileged"] != "false"
+}
+
+// joinScriptLines joins a multi-line shell script into one && chain, dropping
+// comments. Scripts with control flow or heredocs are kept line by line.
+func joinScriptLines(script string) string {
+	if vagrantCompoundPattern.MatchString(script) {
+		var lines []string
+		for _, line := range strings.Split(script, "\n") {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				lines = append(lines, trimmed)
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	var parts []string
+	var current string
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		parts = append(parts, current+line)
+		current = ""
+	}
+	if current != "" {
+		parts = append(parts, strings.TrimSpace(current))
+	}
+	return strings.Join(parts, " && ")
+}
+
+// convertProviderSetting maps VM sizing to docker run limits; everything else is provider-specific
+func (i *VagrantImporter) convertProviderSetting(stmt vagrantStatement, config map[string]interface{}, result *DevcontainerResult) (string, bool) {
+	value := unquoteRuby(stmt.Args)
+
+	// The docker provider already describes a container
+	if stmt.Provider == "docker" {
+		switch stmt.Setting {
+		case "image":
+			result.track("docker.image", supportFull)
+			config["image"] = value
+			return "", false
+		case "build_dir":
+			result.track("docker.build_dir", supportFull)
+			delete(config, "image")
+			config["build"] = map[string]interface{}{
+				"dockerfile": filepath.ToSlash(filepath.Join("..", value, "Dockerfile")),
+				"context":    filepath.ToSlash(filepath.Join("..", value)),
+			}
+			return "", false
+		}
+	}
+
+	switch stmt.Setting {
+	case "memory":
+		if _, err := strconv.Atoi(value); err == nil {
+			result.track(stmt.Provider+".memory", supportFull)
+			return "--memory=" + value + "m", true
+		}
+	case "cpus":
+		if _, err := strconv.Atoi(value); err == nil {
+			result.track(stmt.Provider+".cpus", supportFull)
+			return "--cpus=" + value, true
+		}
+	}
+	result.track(stmt.Provider+"."+stmt.Setting, supportNone)
+	result.warn("PROVIDER", fmt.Sprintf("%s setting %s (line %d) is provider-specific and was dropped", stmt.Provider, stmt.Setting, stmt.Line), "")
+	return "", false
+}
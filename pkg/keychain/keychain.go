@@ -0,0 +1,146 @@
+// Package keychain stores secrets such as cloud tokens in the operating
+// system's credential store: the login keychain on macOS, the Secret Service
+// (GNOME Keyring, KWallet) on Linux and the Credential Locker on Windows.
+// It drives each platform's own command-line tool, so it needs no cgo.
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no secret is stored for a service and account
+	ErrNotFound = errors.New("secret not found in keychain")
+
+	// ErrUnavailable is returned when the platform's credential store can't be used
+	ErrUnavailable = errors.New("no OS keychain available")
+)
+
+// notFoundExitCode is the exit status of security(1) for a missing item; the
+// Windows script uses it too
+const notFoundExitCode = 44
+
+// goos is the platform whose credential store is used
+var goos = runtime.GOOS
+
+// Set stores a secret, replacing any stored for the same service and account
+func Set(service, account, secret string) error {
+	switch goos {
+	case "darwin":
+		// Commands read by `security -i` keep the secret out of the process list
+		return run("security", fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(service), securityQuote(account), securityQuote(secret)), "-i")
+	case "windows":
+		return run("powershell", secret, "-NoProfile", "-NonInteractive", "-Command", vaultScript(service, account,
+			`$v.Add((New-Object Windows.Security.Credentials.PasswordCredential($s, $a, [Console]::In.ReadToEnd())))`))
+	default:
+		return run("secret-tool", secret, "store", "--label", service+" ("+account+")", "service", service, "account", account)
+	}
+}
+
+// Get returns a stored secret, or ErrNotFound
+func Get(service, account string) (string, error) {
+	var out string
+	var err error
+	switch goos {
+	case "darwin":
+		out, err = output("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		out, err = output("powershell", "-NoProfile", "-NonInteractive", "-Command", vaultScript(service, account,
+			`$c = $v.Retrieve($s, $a); $c.RetrievePassword(); [Console]::Out.Write($c.Password)`))
+	default:
+		out, err = output("secret-tool", "lookup", "service", service, "account", account)
+		// secret-tool exits 1 without output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && out == "" {
+			return "", ErrNotFound
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// Delete removes a stored secret; deleting one that doesn't exist is not an error
+func Delete(service, account string) error {
+	var err error
+	switch goos {
+	case "darwin":
+		err = run("security", "", "delete-generic-password", "-s", service, "-a", account)
+	case "windows":
+		err = run("powershell", "", "-NoProfile", "-NonInteractive", "-Command", vaultScript(service, account,
+			`$v.Remove($v.Retrieve($s, $a))`))
+	default:
+		err = run("secret-tool", "", "clear", "service", service, "account", account)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// run runs a credential tool with stdin, discarding its output
+func run(name, stdin string, args ...string) error {
+	_, err := command(name, stdin, args...)
+	return err
+}
+
+// output runs a credential tool and returns its standard output
+func output(name string, args ...string) (string, error) {
+	return command(name, "", args...)
+}
+
+func command(name, stdin string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", ErrUnavailable
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == notFoundExitCode {
+			return "", ErrNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("%s: %s: %w", name, msg, err)
+		}
+		return stdout.String(), fmt.Errorf("%s: %w", name, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// vaultScript wraps a PowerShell statement using the Credential Locker in $v,
+// the service in $s and the account in $a
+func vaultScript(service, account, statement string) string {
+	return fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+[void][Windows.Security.Credentials.PasswordVault, Windows.Security.Credentials, ContentType = WindowsRuntime]
+$v = New-Object Windows.Security.Credentials.PasswordVault
+$s = %s; $a = %s
+try { %s } catch { if ($_.Exception.HResult -eq -2147023728) { exit %d }; throw }`,
+		powershellQuote(service), powershellQuote(account), statement, notFoundExitCode)
+}
+
+// securityQuote quotes an argument for a command read by `security -i`
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// powershellQuote quotes a PowerShell string literal
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,81 @@
+package keychain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeSecretTool puts a secret-tool on PATH that keeps secrets in files
+func fakeSecretTool(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake secret-tool is a shell script")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+store="` + dir + `/$(echo "$@" | sed 's/.*service \([^ ]*\) account \([^ ]*\).*/\1-\2/')"
+case "$1" in
+store) cat > "$store" ;;
+lookup) [ -f "$store" ] || exit 1; cat "$store" ;;
+clear) rm -f "$store" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	goos = "linux"
+	t.Cleanup(func() { goos = runtime.GOOS })
+}
+
+func TestSecretService(t *testing.T) {
+	fakeSecretTool(t)
+
+	if _, err := Get("cm-test", "token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of a missing secret error = %v, want ErrNotFound", err)
+	}
+	if err := Set("cm-test", "token", "s3cret value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Set("cm-test", "token", "rotated"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, err := Get("cm-test", "token")
+	if err != nil || got != "rotated" {
+		t.Errorf("Get() = %q, %v, want rotated", got, err)
+	}
+	if err := Delete("cm-test", "token"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := Delete("cm-test", "token"); err != nil {
+		t.Errorf("Delete() of a missing secret error = %v", err)
+	}
+	if _, err := Get("cm-test", "token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	goos = "linux"
+	defer func() { goos = runtime.GOOS }()
+
+	if err := Set("cm-test", "token", "x"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Set() error = %v, want ErrUnavailable", err)
+	}
+	if _, err := Get("cm-test", "token"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get() error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestQuoting(t *testing.T) {
+	if got := securityQuote(`a"b\c`); got != `"a\"b\\c"` {
+		t.Errorf("securityQuote() = %s", got)
+	}
+	if got := powershellQuote("it's"); got != "'it''s'" {
+		t.Errorf("powershellQuote() = %s", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package localca manages a certificate authority private to this machine,
+// in the manner of mkcert: its root is trusted once on the host, then it
+// issues certificates for local development names such as
+// 3000.myapp.localhost, so browsers accept HTTPS without warnings.
+package localca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"time"
+)
+
+const (
+	certFile = "rootCA.pem"
+	keyFile  = "rootCA-key.pem"
+
+	// caValidity is the root's lifetime; leafValidity stays under the 825
+	// days macOS and iOS accept for TLS certificates
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 820 * 24 * time.Hour
+)
+
+// CA is the local certificate authority
+type CA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Dir returns where the CA is kept, ~/.cm/ca
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "ca"), nil
+}
+
+// Load returns the CA in dir, creating it the first time. The private key
+// is only readable by the user.
+func Load(dir string) (*CA, error) {
+	ca := &CA{dir: dir}
+	certPEM, certErr := os.ReadFile(filepath.Join(dir, certFile))
+	keyPEM, keyErr := os.ReadFile(filepath.Join(dir, keyFile))
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		return create(dir)
+	}
+	if certErr != nil {
+		return nil, certErr
+	}
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM certificate", filepath.Join(dir, certFile))
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM key", filepath.Join(dir, keyFile))
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ca.cert, ca.key = cert, key
+	return ca, nil
+}
+
+// create generates a new root
+func create(dir string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{Organization: []string{"Container-Maker local CA"}, CommonName: "cm local CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, keyFile), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, certFile), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, err
+	}
+	return &CA{dir: dir, cert: cert, key: key}, nil
+}
+
+// CertPath returns the root certificate's file, the one to trust
+func (ca *CA) CertPath() string {
+	return filepath.Join(ca.dir, certFile)
+}
+
+// Issue returns a PEM certificate and key for the names, which may be
+// hostnames or IP addresses
+func (ca *CA) Issue(names []string) (certPEM, keyPEM []byte, err error) {
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no names to issue a certificate for")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{Organization: []string{"Container-Maker development certificate"}, CommonName: names[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Trusted reports whether the host already trusts the root
+func (ca *CA) Trusted() bool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return false
+	}
+	_, err = ca.cert.Verify(x509.VerifyOptions{Roots: pool})
+	return err == nil
+}
+
+// TrustCommand returns the command that adds the root to the host's trust
+// store; it needs sudo or an administrator prompt. Firefox keeps its own
+// store and needs the root imported there.
+func (ca *CA) TrustCommand() []string {
+	switch goruntime.GOOS {
+	case "darwin":
+		return []string{"security", "add-trusted-cert", "-d", "-r", "trustRoot", "-k", "/Library/Keychains/System.keychain", ca.CertPath()}
+	case "windows":
+		return []string{"certutil", "-addstore", "-f", "ROOT", ca.CertPath()}
+	}
+	if _, err := exec.LookPath("update-ca-trust"); err == nil {
+		return []string{"sh", "-c", "cp " + ca.CertPath() + " /etc/pki/ca-trust/source/anchors/cm-local-ca.pem && update-ca-trust"}
+	}
+	return []string{"sh", "-c", "cp " + ca.CertPath() + " /usr/local/share/ca-certificates/cm-local-ca.crt && update-ca-certificates"}
+}
+
+// Trust runs TrustCommand
+func (ca *CA) Trust() error {
+	args := ca.TrustCommand()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+func serialNumber() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
@@ -0,0 +1,54 @@
+package localca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIssue(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, keyFile)); err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Errorf("The CA key should only be readable by the user: %v", info.Mode())
+	}
+
+	// Loading again returns the same root
+	again, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !again.cert.Equal(ca.cert) {
+		t.Error("Load should reuse the existing root")
+	}
+
+	certPEM, keyPEM, err := again.Issue([]string{"3000.myapp.localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatalf("Certificate and key don't match: %v", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, name := range []string{"3000.myapp.localhost", "127.0.0.1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("Verify(%s) failed: %v", name, err)
+		}
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "8080.myapp.localhost", Roots: roots}); err == nil {
+		t.Error("The certificate should not be valid for other names")
+	}
+}
@@ -56,7 +56,8 @@ func ParseMakefile(path string) (*MakefileInfo, error) {
 	}
 
 	// Regex patterns
-	targetPattern := regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_\-]*)\s*:`)
+	// The colon of a target isn't that of a := or ::= assignment
+	targetPattern := regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_\-]*)\s*:([^=:]|:[^=]|$)`)
 	phonyPattern := regexp.MustCompile(`^\.PHONY\s*:\s*(.+)`)
 	commentPattern := regexp.MustCompile(`^##\s*(.+)`)
 
@@ -0,0 +1,461 @@
+// Package metrics records how long dev environments take to come up: cold
+// starts, image pulls, builds (with their cache hits) and lifecycle hooks.
+// Recording is opt-in ('cm analytics enable'); samples stay in
+// ~/.cm/metrics.jsonl unless exported to a configured endpoint.
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// Kinds of samples
+const (
+	KindColdStart = "cold_start" // Creating a persistent container until it is ready
+	KindPull      = "image_pull"
+	KindBuild     = "build"
+	KindHook      = "hook"
+)
+
+// Kinds lists the kinds in report order
+var Kinds = []string{KindColdStart, KindPull, KindBuild, KindHook}
+
+// maxSamples is how many samples are kept; older ones are dropped
+const maxSamples = 5000
+
+// Sample is one timed operation
+type Sample struct {
+	Kind        string    `json:"kind"`
+	Project     string    `json:"project,omitempty"` // Project directory; empty for pulls
+	Name        string    `json:"name,omitempty"`    // Image or hook name
+	DurationMs  int64     `json:"durationMs"`
+	Failed      bool      `json:"failed,omitempty"`
+	Steps       int       `json:"steps,omitempty"`       // Build steps
+	CachedSteps int       `json:"cachedSteps,omitempty"` // Build steps served from the cache
+	At          time.Time `json:"at"`
+}
+
+// Duration returns the sample's duration
+func (s Sample) Duration() time.Duration {
+	return time.Duration(s.DurationMs) * time.Millisecond
+}
+
+// Enabled reports whether metrics are recorded ('cm analytics enable')
+func Enabled() bool {
+	cfg, err := userconfig.Load()
+	return err == nil && cfg.Analytics.Enabled
+}
+
+// Path returns the metrics file, ~/.cm/metrics.jsonl
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "metrics.jsonl"), nil
+}
+
+var mu sync.Mutex
+
+// Record saves a sample when metrics are enabled
+func Record(s Sample) error {
+	if !Enabled() {
+		return nil
+	}
+	if s.At.IsZero() {
+		s.At = time.Now()
+	}
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return trim(path)
+}
+
+// trim drops the oldest samples once the file holds a fifth more than
+// maxSamples, so it is rewritten only now and then
+func trim(path string) error {
+	// Samples take well over 100 bytes; skip reading small files
+	if info, err := os.Stat(path); err != nil || info.Size() < maxSamples*100 {
+		return nil
+	}
+	samples, err := load(path)
+	if err != nil || len(samples) < maxSamples+maxSamples/5 {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, s := range samples[len(samples)-maxSamples:] {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Start times an operation; calling the returned function records it
+func Start(kind, project, name string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		_ = Record(Sample{
+			Kind:       kind,
+			Project:    project,
+			Name:       name,
+			DurationMs: time.Since(start).Milliseconds(),
+			Failed:     err != nil,
+		})
+	}
+}
+
+// Load returns all samples, oldest first
+func Load() ([]Sample, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return load(path)
+}
+
+func load(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s Sample
+		if json.Unmarshal(scanner.Bytes(), &s) == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// Clear deletes all samples
+func Clear() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Build step lines of BuildKit's plain progress ("#5 [2/4] RUN ...",
+// "#5 CACHED") and of the classic builder ("Step 2/4 : RUN ...",
+// " ---> Using cache")
+var (
+	buildkitStep   = regexp.MustCompile(`^#(\d+) \[[^\]]*\d+/\d+\] `)
+	buildkitCached = regexp.MustCompile(`^#(\d+) CACHED`)
+	classicStep    = regexp.MustCompile(`^Step \d+/\d+ : `)
+	classicCached  = regexp.MustCompile(`^ ---> Using cache`)
+)
+
+// BuildCounter counts the steps of a build, and those served from the
+// cache, from its output
+type BuildCounter struct {
+	mu     sync.Mutex
+	line   []byte
+	steps  map[string]bool
+	cached map[string]bool
+	n      int // Classic builder steps
+	hits   int // Classic builder cache hits
+}
+
+func (c *BuildCounter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range p {
+		if b != '\n' && b != '\r' {
+			if len(c.line) < 512 {
+				c.line = append(c.line, b)
+			}
+			continue
+		}
+		c.scan(string(c.line))
+		c.line = c.line[:0]
+	}
+	return len(p), nil
+}
+
+func (c *BuildCounter) scan(line string) {
+	if c.steps == nil {
+		c.steps, c.cached = map[string]bool{}, map[string]bool{}
+	}
+	switch {
+	case buildkitStep.MatchString(line):
+		c.steps[buildkitStep.FindStringSubmatch(line)[1]] = true
+	case buildkitCached.MatchString(line):
+		c.cached[buildkitCached.FindStringSubmatch(line)[1]] = true
+	case classicStep.MatchString(line):
+		c.n++
+	case classicCached.MatchString(line):
+		c.hits++
+	}
+}
+
+// Counts returns the number of build steps and cached steps seen
+func (c *BuildCounter) Counts() (steps, cached int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.line) > 0 {
+		c.scan(string(c.line))
+		c.line = c.line[:0]
+	}
+	for id := range c.cached {
+		if c.steps[id] {
+			cached++
+		}
+	}
+	return len(c.steps) + c.n, cached + c.hits
+}
+
+// StartBuild times a build; the output written to the returned counter
+// gives its cache hits
+func StartBuild(project, name string) (*BuildCounter, func(err error)) {
+	counter := &BuildCounter{}
+	start := time.Now()
+	return counter, func(err error) {
+		steps, cached := counter.Counts()
+		_ = Record(Sample{
+			Kind:        KindBuild,
+			Project:     project,
+			Name:        name,
+			DurationMs:  time.Since(start).Milliseconds(),
+			Failed:      err != nil,
+			Steps:       steps,
+			CachedSteps: cached,
+		})
+	}
+}
+
+// Summary describes one kind of sample, or one hook, over a period
+type Summary struct {
+	Kind   string
+	Name   string // Hook name, for hooks
+	Count  int
+	Failed int
+	Median time.Duration
+	P90    time.Duration
+	Change float64 // Change of the last week's median against the weeks before
+	Trend  bool    // Whether Change could be computed
+	Daily  []time.Duration
+	Steps  int // Build steps
+	Cached int // Cached build steps
+
+	samples []Sample
+}
+
+// CacheHitRate returns the share of build steps served from the cache
+func (s Summary) CacheHitRate() (float64, bool) {
+	if s.Steps == 0 {
+		return 0, false
+	}
+	return float64(s.Cached) / float64(s.Steps), true
+}
+
+// Summarize summarizes the successful samples of the last days days per
+// kind, with hooks per name. Daily holds the median of each day, oldest
+// first.
+func Summarize(samples []Sample, days int, now time.Time) []Summary {
+	since := now.AddDate(0, 0, -days)
+	recent := now.AddDate(0, 0, -7)
+
+	groups := map[string]*Summary{}
+	var order []string
+	for _, s := range samples {
+		if s.At.Before(since) || s.At.After(now) {
+			continue
+		}
+		key := s.Kind
+		if s.Kind == KindHook {
+			key += "/" + s.Name
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &Summary{Kind: s.Kind}
+			if s.Kind == KindHook {
+				g.Name = s.Name
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		if s.Failed {
+			g.Failed++
+			continue
+		}
+		g.Count++
+		g.Steps += s.Steps
+		g.Cached += s.CachedSteps
+		g.samples = append(g.samples, s)
+	}
+
+	var out []Summary
+	for _, key := range order {
+		g := groups[key]
+		var all, before, after []time.Duration
+		daily := make([][]time.Duration, days)
+		for _, s := range g.samples {
+			d := s.Duration()
+			all = append(all, d)
+			if s.At.Before(recent) {
+				before = append(before, d)
+			} else {
+				after = append(after, d)
+			}
+			day := int(now.Sub(s.At) / (24 * time.Hour))
+			if day < days {
+				daily[days-1-day] = append(daily[days-1-day], d)
+			}
+		}
+		g.Median, g.P90 = percentile(all, 50), percentile(all, 90)
+		if len(before) > 0 && len(after) > 0 {
+			b := percentile(before, 50)
+			if b > 0 {
+				g.Change = float64(percentile(after, 50)-b) / float64(b)
+				g.Trend = true
+			}
+		}
+		g.Daily = make([]time.Duration, days)
+		for i, d := range daily {
+			g.Daily[i] = percentile(d, 50)
+		}
+		out = append(out, *g)
+	}
+
+	rank := map[string]int{}
+	for i, k := range Kinds {
+		rank[k] = i
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return rank[out[i].Kind] < rank[out[j].Kind]
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// percentile returns the p-th percentile of durations, 0 for none
+func percentile(durations []time.Duration, p int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return sorted[i]
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws durations as a line of bars; days without samples are
+// blank
+func Sparkline(values []time.Duration) string {
+	var max time.Duration
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		switch {
+		case v == 0:
+			sb.WriteRune(' ')
+		case max == 0:
+			sb.WriteRune(sparks[0])
+		default:
+			sb.WriteRune(sparks[int(int64(v)*int64(len(sparks)-1)/int64(max))])
+		}
+	}
+	return sb.String()
+}
+
+// Export sends samples to an endpoint as a JSON POST. Project directories
+// are reduced to their names so no local paths leave the machine.
+func Export(ctx context.Context, endpoint, session string, samples []Sample) error {
+	anonymized := make([]Sample, len(samples))
+	for i, s := range samples {
+		if s.Project != "" {
+			s.Project = filepath.Base(s.Project)
+		}
+		anonymized[i] = s
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"session": session,
+		"samples": anonymized,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpclient.Default().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("export to %s failed: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
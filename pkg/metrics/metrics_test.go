@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+func TestRecordOptIn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	Start(KindPull, "", "alpine")(nil)
+	if samples, _ := Load(); len(samples) != 0 {
+		t.Fatalf("recorded %d samples while disabled", len(samples))
+	}
+
+	if err := userconfig.Set("analytics.enabled", "true"); err != nil {
+		t.Fatal(err)
+	}
+	Start(KindPull, "", "alpine")(nil)
+	Start(KindHook, "/p", "postCreateCommand")(errors.New("exit 1"))
+	samples, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Name != "alpine" || !samples[1].Failed {
+		t.Errorf("samples = %+v", samples)
+	}
+}
+
+func TestBuildCounter(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		steps, cache int
+	}{
+		{"buildkit", "#1 [internal] load build definition\n#5 [1/3] FROM docker.io/library/alpine\n#5 CACHED\n\n#6 [2/3] RUN apk add git\n#6 CACHED\n#7 [3/3] COPY . .\n#7 0.210 done\n", 3, 2},
+		{"multi-stage", "#4 [build 1/2] FROM golang\n#5 [build 2/2] RUN go build\n#5 CACHED\n", 2, 1},
+		{"classic", "Step 1/3 : FROM alpine\nStep 2/3 : RUN apk add git\n ---> Using cache\nStep 3/3 : COPY . .\n", 3, 1},
+		{"no trailing newline", "#5 [1/1] FROM alpine\r#5 CACHED", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c BuildCounter
+			// Write in small chunks, as pipes deliver it
+			for i := 0; i < len(tt.output); i += 7 {
+				end := i + 7
+				if end > len(tt.output) {
+					end = len(tt.output)
+				}
+				_, _ = io.WriteString(&c, tt.output[i:end])
+			}
+			if steps, cached := c.Counts(); steps != tt.steps || cached != tt.cache {
+				t.Errorf("Counts() = %d, %d, want %d, %d", steps, cached, tt.steps, tt.cache)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2026, 5, 30, 12, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	samples := []Sample{
+		{Kind: KindColdStart, DurationMs: 10000, At: day(20)},
+		{Kind: KindColdStart, DurationMs: 10000, At: day(10)},
+		{Kind: KindColdStart, DurationMs: 15000, At: day(2)},
+		{Kind: KindColdStart, DurationMs: 99000, At: day(1), Failed: true},
+		{Kind: KindColdStart, DurationMs: 50000, At: day(40)}, // Outside the window
+		{Kind: KindHook, Name: "postStartCommand", DurationMs: 1000, At: day(1)},
+		{Kind: KindHook, Name: "postCreateCommand", DurationMs: 3000, At: day(1)},
+		{Kind: KindBuild, DurationMs: 60000, At: day(3), Steps: 10, CachedSteps: 4},
+		{Kind: KindBuild, DurationMs: 30000, At: day(1), Steps: 10, CachedSteps: 8},
+	}
+
+	summaries := Summarize(samples, 30, now)
+	var names []string
+	for _, s := range summaries {
+		names = append(names, s.Kind+" "+s.Name)
+	}
+	want := "[cold_start  build  hook postCreateCommand hook postStartCommand]"
+	if fmt.Sprint(names) != want {
+		t.Fatalf("order = %v, want %v", names, want)
+	}
+
+	cold := summaries[0]
+	if cold.Count != 3 || cold.Failed != 1 {
+		t.Errorf("cold start count = %d, failed = %d", cold.Count, cold.Failed)
+	}
+	if cold.Median != 10*time.Second || cold.P90 != 15*time.Second {
+		t.Errorf("cold start median = %v, p90 = %v", cold.Median, cold.P90)
+	}
+	if !cold.Trend || cold.Change != 0.5 {
+		t.Errorf("cold start change = %v (%v), want +50%%", cold.Change, cold.Trend)
+	}
+	if len(cold.Daily) != 30 || cold.Daily[27] != 15*time.Second || cold.Daily[0] != 0 {
+		t.Errorf("daily = %v", cold.Daily)
+	}
+
+	if rate, ok := summaries[1].CacheHitRate(); !ok || rate != 0.6 {
+		t.Errorf("cache hit rate = %v, %v, want 0.6", rate, ok)
+	}
+	if summaries[2].Trend {
+		t.Error("a hook with only recent samples should have no trend")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	got := Sparkline([]time.Duration{0, time.Second, 4 * time.Second, 8 * time.Second})
+	if got != " ▁▄█" {
+		t.Errorf("Sparkline() = %q", got)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var got struct {
+		Session string   `json:"session"`
+		Samples []Sample `json:"samples"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	err := Export(t.Context(), srv.URL, "abc", []Sample{{Kind: KindBuild, Project: "/home/me/api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Session != "abc" || len(got.Samples) != 1 || got.Samples[0].Project != "api" {
+		t.Errorf("exported %+v", got)
+	}
+}
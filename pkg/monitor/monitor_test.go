@@ -245,3 +245,43 @@ func TestDashboardState(t *testing.T) {
 		t.Error("Metrics should be accessible")
 	}
 }
+
+func TestIdleTracker(t *testing.T) {
+	tracker := NewIdleTracker(DefaultIdlePolicy)
+	start := time.Now()
+	idle := &StatsSample{ContainerMetrics: &ContainerMetrics{ContainerID: "a", CPUPercent: 0.5}}
+
+	if got := tracker.Observe(idle, start); got != 0 {
+		t.Errorf("first sample should not count as idle, got %s", got)
+	}
+	if got := tracker.Observe(idle, start.Add(10*time.Minute)); got != 10*time.Minute {
+		t.Errorf("expected 10m idle, got %s", got)
+	}
+
+	busy := &StatsSample{ContainerMetrics: &ContainerMetrics{ContainerID: "a", CPUPercent: 40}}
+	if got := tracker.Observe(busy, start.Add(11*time.Minute)); got != 0 {
+		t.Errorf("busy container should reset idle time, got %s", got)
+	}
+
+	shell := &StatsSample{ContainerMetrics: &ContainerMetrics{ContainerID: "a"}, Execs: 1}
+	if got := tracker.Observe(shell, start.Add(30*time.Minute)); got != 0 {
+		t.Errorf("container with an open session should not be idle, got %s", got)
+	}
+}
+
+func TestDuePause(t *testing.T) {
+	samples := []*StatsSample{
+		{ContainerMetrics: &ContainerMetrics{ContainerID: "persistent"}, Project: "/src/app", IdleFor: 31 * time.Minute},
+		{ContainerMetrics: &ContainerMetrics{ContainerID: "ephemeral"}, IdleFor: time.Hour},
+		{ContainerMetrics: &ContainerMetrics{ContainerID: "recent"}, Project: "/src/api", IdleFor: 5 * time.Minute},
+		{ContainerMetrics: &ContainerMetrics{ContainerID: "failed"}, Project: "/src/web", IdleFor: time.Hour},
+	}
+
+	due := DuePause(samples, 30*time.Minute, map[string]bool{"failed": true})
+	if len(due) != 1 || due[0].ContainerID != "persistent" {
+		t.Errorf("expected only the idle persistent container, got %v", due)
+	}
+	if DuePause(samples, 0, nil) != nil {
+		t.Error("auto-pause should be off when the idle time is 0")
+	}
+}
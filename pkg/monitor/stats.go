@@ -0,0 +1,289 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Labels pkg/runner puts on the containers it creates
+const (
+	managedLabel = "cm.managed"
+	projectLabel = "cm.project"
+)
+
+// StatsSample is one sample of a container cm manages
+type StatsSample struct {
+	*ContainerMetrics
+	Project string        // Project directory; set for persistent containers only
+	Execs   int           // Running exec sessions, such as an open cm shell
+	IdleFor time.Duration // How long the container has been idle
+}
+
+// IdlePolicy decides whether a container is idle: below the CPU and network
+// thresholds, with no exec session attached
+type IdlePolicy struct {
+	CPUPercent  float64
+	NetworkRate float64 // bytes/sec, received plus transmitted
+}
+
+// DefaultIdlePolicy treats a container using under 2% CPU and 1 KB/s of
+// network as idle
+var DefaultIdlePolicy = IdlePolicy{CPUPercent: 2, NetworkRate: 1024}
+
+// Active reports whether the sample shows activity
+func (p IdlePolicy) Active(s *StatsSample) bool {
+	return s.Execs > 0 ||
+		s.CPUPercent >= p.CPUPercent ||
+		s.NetworkRxRate+s.NetworkTxRate >= p.NetworkRate
+}
+
+// IdleTracker remembers when each container was last active
+type IdleTracker struct {
+	Policy     IdlePolicy
+	lastActive map[string]time.Time
+}
+
+// NewIdleTracker creates an idle tracker
+func NewIdleTracker(policy IdlePolicy) *IdleTracker {
+	return &IdleTracker{Policy: policy, lastActive: make(map[string]time.Time)}
+}
+
+// Observe records a sample and returns how long its container has been
+// idle. A container seen for the first time counts as just active.
+func (t *IdleTracker) Observe(s *StatsSample, now time.Time) time.Duration {
+	last, seen := t.lastActive[s.ContainerID]
+	if !seen || t.Policy.Active(s) {
+		t.lastActive[s.ContainerID] = now
+		return 0
+	}
+	return now.Sub(last)
+}
+
+// Forget drops containers that are no longer sampled
+func (t *IdleTracker) Forget(keep map[string]bool) {
+	for id := range t.lastActive {
+		if !keep[id] {
+			delete(t.lastActive, id)
+		}
+	}
+}
+
+// StatsSampler samples the CPU, memory, network and disk usage of the
+// containers cm manages
+type StatsSampler struct {
+	collector *DockerCollector
+	idle      *IdleTracker
+}
+
+// NewStatsSampler connects to Docker and creates a sampler
+func NewStatsSampler() (*StatsSampler, error) {
+	collector, err := NewDockerCollector()
+	if err != nil {
+		return nil, err
+	}
+	return &StatsSampler{collector: collector, idle: NewIdleTracker(DefaultIdlePolicy)}, nil
+}
+
+// Sample returns one sample per running cm container, sorted by name. Rates
+// and CPU usage need a previous sample, so the first call reports them as 0.
+func (s *StatsSampler) Sample(ctx context.Context) ([]*StatsSample, error) {
+	containers, err := s.collector.client.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	var samples []*StatsSample
+	for _, ctr := range containers {
+		name := ""
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		// Containers created before cm labelled them are recognized by name
+		if ctr.Labels[managedLabel] != "true" && !strings.HasPrefix(name, "cm-") {
+			continue
+		}
+
+		sample, err := s.sampleOne(ctx, ctr.ID)
+		if err != nil {
+			continue // The container may have stopped since it was listed
+		}
+		sample.IdleFor = s.idle.Observe(sample, now)
+		seen[ctr.ID] = true
+		samples = append(samples, sample)
+	}
+	s.idle.Forget(seen)
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].ContainerName < samples[j].ContainerName
+	})
+	return samples, nil
+}
+
+func (s *StatsSampler) sampleOne(ctx context.Context, id string) (*StatsSample, error) {
+	inspect, err := s.collector.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.collector.client.ContainerStatsOneShot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+
+	sample := &StatsSample{
+		ContainerMetrics: s.collector.parseStats(id, inspect.Name, &stats),
+		Execs:            len(inspect.ExecIDs),
+	}
+	if inspect.Config != nil {
+		sample.Project = inspect.Config.Labels[projectLabel]
+	}
+	return sample, nil
+}
+
+// Close releases the Docker connection
+func (s *StatsSampler) Close() error {
+	return s.collector.Close()
+}
+
+// DuePause returns the persistent containers that have been idle for at
+// least after, skipping the ones in skip
+func DuePause(samples []*StatsSample, after time.Duration, skip map[string]bool) []*StatsSample {
+	if after <= 0 {
+		return nil
+	}
+	var due []*StatsSample
+	for _, s := range samples {
+		if s.Project != "" && s.IdleFor >= after && !skip[s.ContainerID] {
+			due = append(due, s)
+		}
+	}
+	return due
+}
+
+// FormatStatsTable renders samples as a plain text table
+func FormatStatsTable(samples []*StatsSample) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-28s %7s %-20s %6s %-19s %-19s %5s %s\n",
+		"NAME", "CPU %", "MEM USAGE / LIMIT", "MEM %", "NET I/O", "BLOCK I/O", "PIDS", "IDLE")
+	for _, s := range samples {
+		fmt.Fprintf(&b, "%-28s %6.1f%% %-20s %5.1f%% %-19s %-19s %5d %s\n",
+			truncate(s.ContainerName, 28),
+			s.CPUPercent,
+			formatBytes(s.MemoryUsed)+" / "+formatBytes(s.MemoryLimit),
+			s.MemoryPercent,
+			formatBytes(s.NetworkRx)+" / "+formatBytes(s.NetworkTx),
+			formatBytes(s.BlockRead)+" / "+formatBytes(s.BlockWrite),
+			s.PIDs,
+			formatIdle(s))
+	}
+	return b.String()
+}
+
+// formatIdle describes a container's idle time; containers with an open
+// session are never idle
+func formatIdle(s *StatsSample) string {
+	switch {
+	case s.Execs > 0:
+		return fmt.Sprintf("active (%d session(s))", s.Execs)
+	case s.IdleFor < time.Minute:
+		return "-"
+	default:
+		return s.IdleFor.Truncate(time.Minute).String()
+	}
+}
+
+// PrintStats prints one sample of every cm container. Two samples are taken
+// a second apart so CPU and rates are meaningful.
+func PrintStats(ctx context.Context, w io.Writer) error {
+	sampler, err := NewStatsSampler()
+	if err != nil {
+		return err
+	}
+	defer sampler.Close()
+
+	if _, err := sampler.Sample(ctx); err != nil {
+		return err
+	}
+	time.Sleep(time.Second)
+	samples, err := sampler.Sample(ctx)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(w, "No running cm containers.")
+		return nil
+	}
+	fmt.Fprint(w, FormatStatsTable(samples))
+	return nil
+}
+
+// StatsOptions configure live stats and idle auto-pause
+type StatsOptions struct {
+	Interval  time.Duration // Between samples, default 2s
+	IdlePause time.Duration // Pause persistent containers idle this long; 0 disables
+	Pause     func(ctx context.Context, s *StatsSample) error
+}
+
+func (o StatsOptions) interval() time.Duration {
+	if o.Interval <= 0 {
+		return 2 * time.Second
+	}
+	return o.Interval
+}
+
+// WatchIdle samples until ctx is done and pauses persistent containers idle
+// for opts.IdlePause, logging each pause to log. It is the headless form of
+// the stats view.
+func WatchIdle(ctx context.Context, opts StatsOptions, log io.Writer) error {
+	if opts.IdlePause <= 0 || opts.Pause == nil {
+		return fmt.Errorf("idle auto-pause is not configured")
+	}
+	sampler, err := NewStatsSampler()
+	if err != nil {
+		return err
+	}
+	defer sampler.Close()
+
+	failed := make(map[string]bool)
+	ticker := time.NewTicker(opts.interval())
+	defer ticker.Stop()
+	for {
+		samples, err := sampler.Sample(ctx)
+		if err != nil {
+			fmt.Fprintf(log, "⚠️  %v\n", err)
+		}
+		for _, s := range DuePause(samples, opts.IdlePause, failed) {
+			fmt.Fprintf(log, "⏸️  %s has been idle for %s; pausing...\n", s.ContainerName, s.IdleFor.Truncate(time.Minute))
+			if err := opts.Pause(ctx, s); err != nil {
+				failed[s.ContainerID] = true
+				fmt.Fprintf(log, "❌ Failed to pause %s: %v\n", s.ContainerName, err)
+				continue
+			}
+			fmt.Fprintf(log, "✅ Paused %s; the next 'cm exec' or 'cm shell' resumes it\n", s.ContainerName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
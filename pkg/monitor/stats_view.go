@@ -0,0 +1,161 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StatsModel is the Bubble Tea model of 'cm stats': a live table of the cm
+// containers that optionally pauses idle persistent containers
+type StatsModel struct {
+	sampler *StatsSampler
+	opts    StatsOptions
+	samples []*StatsSample
+	events  []string
+	pausing map[string]bool // Pause started or failed; not retried
+	err     error
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+type statsTickMsg time.Time
+type statsSampleMsg []*StatsSample
+type statsPausedMsg struct {
+	name string
+	err  error
+}
+
+// NewStatsModel creates the stats view
+func NewStatsModel(opts StatsOptions) (*StatsModel, error) {
+	sampler, err := NewStatsSampler()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StatsModel{
+		sampler: sampler,
+		opts:    opts,
+		pausing: make(map[string]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Init takes the first sample
+func (m StatsModel) Init() tea.Cmd {
+	return tea.Batch(m.sample, m.tick())
+}
+
+// Update handles messages
+func (m StatsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			m.cancel()
+			_ = m.sampler.Close()
+			return m, tea.Quit
+		}
+
+	case statsTickMsg:
+		return m, tea.Batch(m.sample, m.tick())
+
+	case statsSampleMsg:
+		m.samples = msg
+		m.err = nil
+		var cmds []tea.Cmd
+		if m.opts.Pause != nil {
+			for _, s := range DuePause(m.samples, m.opts.IdlePause, m.pausing) {
+				m.pausing[s.ContainerID] = true
+				m.addEvent(fmt.Sprintf("⏸️  %s idle for %s; pausing...", s.ContainerName, s.IdleFor.Truncate(time.Minute)))
+				cmds = append(cmds, m.pause(s))
+			}
+		}
+		return m, tea.Batch(cmds...)
+
+	case statsPausedMsg:
+		if msg.err != nil {
+			m.addEvent(fmt.Sprintf("❌ Failed to pause %s: %v", msg.name, msg.err))
+		} else {
+			m.addEvent(fmt.Sprintf("✅ Paused %s", msg.name))
+		}
+
+	case errMsg:
+		m.err = msg.err
+	}
+	return m, nil
+}
+
+func (m *StatsModel) addEvent(event string) {
+	m.events = append(m.events, time.Now().Format("15:04")+"  "+event)
+	if len(m.events) > 5 {
+		m.events = m.events[1:]
+	}
+}
+
+// View renders the table
+func (m StatsModel) View() string {
+	var sections []string
+	sections = append(sections, titleStyle.Render("📈 Container-Maker Stats"))
+
+	switch {
+	case m.err != nil:
+		sections = append(sections, stoppedStyle.Render("Error: "+m.err.Error()))
+	case m.samples == nil:
+		sections = append(sections, mutedStyle.Render("Sampling..."))
+	case len(m.samples) == 0:
+		sections = append(sections, mutedStyle.Render("No running cm containers."))
+	default:
+		lines := strings.Split(strings.TrimRight(FormatStatsTable(m.samples), "\n"), "\n")
+		lines[0] = headerStyle.Render(lines[0])
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	if m.opts.IdlePause > 0 {
+		sections = append(sections, "", mutedStyle.Render(fmt.Sprintf("Auto-pause: persistent containers idle for %s", m.opts.IdlePause)))
+	}
+	if len(m.events) > 0 {
+		sections = append(sections, strings.Join(m.events, "\n"))
+	}
+	sections = append(sections, "", helpStyle.Render("q Quit"))
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+func (m StatsModel) tick() tea.Cmd {
+	return tea.Tick(m.opts.interval(), func(t time.Time) tea.Msg {
+		return statsTickMsg(t)
+	})
+}
+
+func (m StatsModel) sample() tea.Msg {
+	samples, err := m.sampler.Sample(m.ctx)
+	if err != nil {
+		return errMsg{err}
+	}
+	if samples == nil {
+		samples = []*StatsSample{}
+	}
+	return statsSampleMsg(samples)
+}
+
+func (m StatsModel) pause(s *StatsSample) tea.Cmd {
+	return func() tea.Msg {
+		return statsPausedMsg{name: s.ContainerName, err: m.opts.Pause(m.ctx, s)}
+	}
+}
+
+// RunStats shows live stats until the user quits
+func RunStats(opts StatsOptions) error {
+	model, err := NewStatsModel(opts)
+	if err != nil {
+		return err
+	}
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	_, err = p.Run()
+	return err
+}
@@ -0,0 +1,38 @@
+// Package offline tracks whether cm may reach the network. In offline mode
+// images, features and templates must already be available locally, usually
+// loaded from an archive made by 'cm bundle create'.
+package offline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvVar enables offline mode when set to 1 or true
+const EnvVar = "CM_OFFLINE"
+
+var enabled bool
+
+// Enable turns on offline mode for the rest of the process
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether network access is disabled, via --offline or CM_OFFLINE
+func Enabled() bool {
+	if enabled {
+		return true
+	}
+	switch strings.ToLower(os.Getenv(EnvVar)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// Missing returns the error reported when an asset would have to be
+// downloaded while offline
+func Missing(kind, name string) error {
+	return fmt.Errorf("offline mode: %s %s is not available locally; load a bundle with 'cm bundle load <archive>' or run without --offline", kind, name)
+}
@@ -0,0 +1,128 @@
+// Package output prints the results of list commands as a decorated table,
+// JSON, YAML or through a Go template, so scripts can consume them.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by Print besides Go templates
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// FlagUsage is the help text of --format flags
+const FlagUsage = "Output format: table, json, yaml, or a Go template such as '{{.Name}}'"
+
+// Print writes items, usually a slice, in format. The table format, also
+// used when format is empty, calls table to print the human-readable view.
+// A Go template is executed once per element of a slice, each result on
+// its own line, as 'docker ps --format' does.
+func Print(w io.Writer, format string, items interface{}, table func() error) error {
+	items = nonNil(items)
+	switch format {
+	case "", FormatTable:
+		return table()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case FormatYAML:
+		// Round-trip through JSON so keys match the JSON output
+		data, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(generic); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	if !strings.Contains(format, "{{") {
+		return fmt.Errorf("unknown format %q: use table, json, yaml or a Go template", format)
+	}
+	tmpl, err := parseTemplate(format)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return execute(w, tmpl, items)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := execute(w, tmpl, v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate reports whether format can be printed, so commands can fail
+// before doing any work
+func Validate(format string) error {
+	switch format {
+	case "", FormatTable, FormatJSON, FormatYAML:
+		return nil
+	}
+	if !strings.Contains(format, "{{") {
+		return fmt.Errorf("unknown format %q: use table, json, yaml or a Go template", format)
+	}
+	_, err := parseTemplate(format)
+	return err
+}
+
+// parseTemplate parses a format template. Escaped tabs and newlines are
+// expanded, since shells pass '\t' through literally.
+func parseTemplate(format string) (*template.Template, error) {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("format").Funcs(funcs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func execute(w io.Writer, tmpl *template.Template, item interface{}) error {
+	if err := tmpl.Execute(w, item); err != nil {
+		return fmt.Errorf("format template: %w", err)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// funcs are available in format templates
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// nonNil turns a nil slice into an empty one, so JSON prints [] not null
+func nonNil(items interface{}) interface{} {
+	v := reflect.ValueOf(items)
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	}
+	return items
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type item struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags,omitempty"`
+	Ready bool     `json:"ready"`
+}
+
+func TestPrint(t *testing.T) {
+	items := []item{{Name: "a", Tags: []string{"x", "z"}, Ready: true}, {Name: "b"}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "[\n  {\n    \"name\": \"a\",\n    \"tags\": [\n      \"x\",\n      \"z\"\n    ],\n    \"ready\": true\n  },\n  {\n    \"name\": \"b\",\n    \"ready\": false\n  }\n]\n"},
+		{"yaml", "- name: a\n  ready: true\n  tags:\n    - x\n    - z\n- name: b\n  ready: false\n"},
+		{"{{.Name}}", "a\nb\n"},
+		{`{{.Name}}\t{{.Ready}}`, "a\ttrue\nb\tfalse\n"},
+		{"{{.Name}} {{join .Tags \",\"}} {{upper .Name}}", "a x,z A\nb  B\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Print(&buf, tt.format, items, nil); err != nil {
+			t.Fatalf("Print(%q): %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Print(%q) = %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	called := false
+	for _, format := range []string{"", "table"} {
+		called = false
+		if err := Print(&bytes.Buffer{}, format, []item{}, func() error { called = true; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if !called {
+			t.Errorf("format %q did not print the table", format)
+		}
+	}
+}
+
+func TestPrintNilSlice(t *testing.T) {
+	var buf bytes.Buffer
+	var items []item
+	if err := Print(&buf, "json", items, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("got %q, want []", buf.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, format := range []string{"", "table", "json", "yaml", "{{.Name}}"} {
+		if err := Validate(format); err != nil {
+			t.Errorf("Validate(%q): %v", format, err)
+		}
+	}
+	for _, format := range []string{"xml", "{{.Name"} {
+		if err := Validate(format); err == nil {
+			t.Errorf("Validate(%q) should fail", format)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+)
+
+// hostNamespaceFlags are the docker run flags that can join a host namespace
+var hostNamespaceFlags = map[string]bool{
+	"--network": true, "--net": true, "--pid": true, "--ipc": true, "--uts": true, "--userns": true,
+}
+
+// EvaluateConfig evaluates a devcontainer.json against the DC-* policies
+func (e *SimpleEngine) EvaluateConfig(ctx context.Context, cfg *config.DevContainerConfig) (*EvaluationResult, error) {
+	start := time.Now()
+	result := &EvaluationResult{
+		EvaluatedAt: start,
+		Violations:  make([]Violation, 0),
+		Passed:      true,
+		PolicyCount: len(e.policies),
+	}
+
+	resource := cfg.Name
+	if resource == "" {
+		resource = "devcontainer.json"
+	}
+	images := configImages(cfg)
+
+	for _, p := range e.policies {
+		if !p.Enabled || p.Mode == ModeOff {
+			continue
+		}
+		for _, v := range checkConfigPolicy(p, cfg, images) {
+			v.PolicyID = p.ID
+			v.PolicyName = p.Name
+			v.Severity = p.Severity
+			v.Mode = p.EffectiveMode()
+			v.Resource = resource
+			v.Timestamp = time.Now()
+			result.Violations = append(result.Violations, v)
+		}
+	}
+
+	result.Passed = len(result.Violations) == 0
+	result.Score = calculateScore(1, result.Violations)
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// checkConfigPolicy returns the violations of one policy; the caller fills
+// in the policy fields
+func checkConfigPolicy(p Policy, cfg *config.DevContainerConfig, images []configImage) []Violation {
+	var violations []Violation
+
+	switch p.ID {
+	case "DC-001": // No Privileged
+		for _, arg := range cfg.RunArgs {
+			if arg == "--privileged" || arg == "--privileged=true" {
+				violations = append(violations, Violation{
+					Message:    "runArgs include --privileged",
+					Location:   "runArgs",
+					Suggestion: "Grant the specific capabilities needed with --cap-add instead",
+				})
+			}
+		}
+
+	case "DC-002": // Host Namespaces
+		for _, flag := range runArgFlags(cfg.RunArgs) {
+			if hostNamespaceFlags[flag.name] && flag.value == "host" {
+				violations = append(violations, Violation{
+					Message:    fmt.Sprintf("runArgs share the host namespace (%s=host)", flag.name),
+					Location:   "runArgs",
+					Suggestion: "Publish ports with forwardPorts instead of using the host network",
+				})
+			}
+		}
+
+	case "DC-003": // Container Socket
+		mounts := append([]string{}, cfg.Mounts...)
+		for _, flag := range runArgFlags(cfg.RunArgs) {
+			if flag.name == "-v" || flag.name == "--volume" || flag.name == "--mount" {
+				mounts = append(mounts, flag.value)
+			}
+		}
+		for _, m := range mounts {
+			if strings.Contains(m, "docker.sock") || strings.Contains(m, "podman.sock") {
+				violations = append(violations, Violation{
+					Message:    fmt.Sprintf("The container socket is mounted (%s)", m),
+					Location:   "mounts",
+					Suggestion: `Set "dockerInDocker": "proxy" to give the container a filtered Docker API instead`,
+				})
+			}
+		}
+
+	case "DC-004": // Pinned Images
+		requireDigest := boolParameter(p.Parameters, "require_digest")
+		for _, img := range images {
+			switch {
+			case requireDigest && !strings.Contains(img.ref, "@sha256:"):
+				violations = append(violations, Violation{
+					Message:    fmt.Sprintf("Image '%s' is not pinned to a digest", img.ref),
+					Location:   img.location,
+					Suggestion: "Pin the image with @sha256:<digest>",
+				})
+			case !requireDigest && !isPinned(img.ref):
+				violations = append(violations, Violation{
+					Message:    fmt.Sprintf("Image '%s' uses 'latest' tag or no tag", img.ref),
+					Location:   img.location,
+					Suggestion: "Use a specific version tag (e.g., :1.22-bookworm) or a digest",
+				})
+			}
+		}
+
+	case "DC-005": // Allowed Registries
+		allowed := stringsParameter(p.Parameters, "registries")
+		if len(allowed) == 0 {
+			break
+		}
+		for _, img := range images {
+			if !fromAllowedRegistry(img.ref, allowed) {
+				violations = append(violations, Violation{
+					Message:    fmt.Sprintf("Image '%s' is not from an allowed registry", img.ref),
+					Location:   img.location,
+					Suggestion: "Use an image from " + strings.Join(allowed, ", "),
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+type configImage struct {
+	ref      string
+	location string
+}
+
+// configImages returns the image and the Dockerfile's base images
+func configImages(cfg *config.DevContainerConfig) []configImage {
+	var images []configImage
+	if cfg.Image != "" {
+		images = append(images, configImage{ref: cfg.Image, location: "image"})
+	}
+	if cfg.Build == nil {
+		return images
+	}
+
+	dockerfile := cfg.Build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	candidates := []string{filepath.Join(cfg.ConfigDir, dockerfile), filepath.Join(filepath.Dir(cfg.ConfigDir), dockerfile)}
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, img := range imports.BaseImages(data, cfg.Build.Args) {
+			images = append(images, configImage{ref: img, location: "build.dockerfile (FROM)"})
+		}
+		break
+	}
+	return images
+}
+
+type runArgFlag struct {
+	name  string
+	value string
+}
+
+// runArgFlags splits runArgs into flags, accepting both "--flag=value" and
+// "--flag value"
+func runArgFlags(args []string) []runArgFlag {
+	var flags []runArgFlag
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			flags = append(flags, runArgFlag{name: name, value: value})
+			continue
+		}
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags = append(flags, runArgFlag{name: arg, value: args[i+1]})
+			i++
+			continue
+		}
+		flags = append(flags, runArgFlag{name: arg})
+	}
+	return flags
+}
+
+// isPinned reports whether an image reference has a digest or a tag other
+// than latest. A registry port ("localhost:5000/app") is not a tag.
+func isPinned(ref string) bool {
+	if strings.Contains(ref, "@sha256:") {
+		return true
+	}
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	_, tag, ok := strings.Cut(name, ":")
+	return ok && tag != "" && tag != "latest"
+}
+
+// fromAllowedRegistry reports whether ref starts with one of the allowed
+// prefixes. Docker Hub images are matched as docker.io/library/<name>.
+func fromAllowedRegistry(ref string, allowed []string) bool {
+	full := normalizeImageRef(ref)
+	for _, prefix := range allowed {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if full == prefix || strings.HasPrefix(full, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeImageRef(ref string) string {
+	first, _, hasSlash := strings.Cut(ref, "/")
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return ref
+	}
+	if !hasSlash {
+		return "docker.io/library/" + ref
+	}
+	return "docker.io/" + ref
+}
+
+func boolParameter(params map[string]interface{}, key string) bool {
+	switch v := params[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	}
+	return false
+}
+
+func stringsParameter(params map[string]interface{}, key string) []string {
+	switch v := params[key].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+func violationIDs(t *testing.T, e *SimpleEngine, cfg *config.DevContainerConfig) map[string]Mode {
+	t.Helper()
+	result, err := e.EvaluateConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]Mode)
+	for _, v := range result.Violations {
+		ids[v.PolicyID] = v.Mode
+	}
+	return ids
+}
+
+func TestEvaluateConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM ubuntu\nRUN true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.DevContainerConfig{
+		Image:     "mcr.microsoft.com/devcontainers/go:1.22",
+		RunArgs:   []string{"--privileged", "--network", "host", "-v", "/var/run/docker.sock:/var/run/docker.sock"},
+		Build:     &config.BuildConfig{Dockerfile: "Dockerfile"},
+		ConfigDir: dir,
+	}
+	ids := violationIDs(t, NewEngine(), cfg)
+	for _, id := range []string{"DC-001", "DC-002", "DC-003", "DC-004"} {
+		if ids[id] != ModeWarn {
+			t.Errorf("%s: got mode %q, want a warn violation", id, ids[id])
+		}
+	}
+
+	clean := &config.DevContainerConfig{Image: "localhost:5000/app:1.0", RunArgs: []string{"--cap-add=SYS_PTRACE", "--network=bridge"}}
+	if ids := violationIDs(t, NewEngine(), clean); len(ids) != 0 {
+		t.Errorf("unexpected violations %v", ids)
+	}
+}
+
+func TestPolicyFileModes(t *testing.T) {
+	dir := t.TempDir()
+	org := filepath.Join(dir, "org.yaml")
+	project := filepath.Join(dir, ".cm-policy.yaml")
+	writeFile := func(path, data string) {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(org, `
+policies:
+  - id: DC-005
+    mode: deny
+    parameters:
+      registries: [ghcr.io/acme]
+`)
+	writeFile(project, `
+policies:
+  - id: DC-005
+    mode: off
+  - id: DC-004
+    mode: deny
+    parameters:
+      require_digest: true
+`)
+
+	e := NewEngine()
+	if err := e.LoadOrgPolicies(org); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadPolicies(project); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := violationIDs(t, e, &config.DevContainerConfig{Image: "python:3.12"})
+	if ids["DC-005"] != ModeDeny {
+		t.Errorf("the project must not turn off an organization policy, got %v", ids)
+	}
+	if ids["DC-004"] != ModeDeny {
+		t.Errorf("require_digest should reject a tag-only image, got %v", ids)
+	}
+
+	ids = violationIDs(t, e, &config.DevContainerConfig{Image: "ghcr.io/acme/dev@sha256:abc"})
+	if len(ids) != 0 {
+		t.Errorf("unexpected violations %v", ids)
+	}
+
+	writeFile(project, "policies:\n  - id: DC-004\n    mode: block\n")
+	if err := NewEngine().LoadPolicies(project); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestIsPinned(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"python":                       false,
+		"python:latest":                false,
+		"python:3.12":                  true,
+		"localhost:5000/app":           false,
+		"localhost:5000/app:1":         true,
+		"ghcr.io/acme/app@sha256:0123": true,
+	} {
+		if got := isPinned(ref); got != want {
+			t.Errorf("isPinned(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
@@ -14,50 +14,117 @@ import (
 // SimpleEngine implements a basic policy engine
 type SimpleEngine struct {
 	policies []Policy
+	sources  []string
+	locked   map[string]bool // IDs set by the organization policy
 }
 
 // NewEngine creates a new policy engine
 func NewEngine() *SimpleEngine {
 	return &SimpleEngine{
 		policies: DefaultPolicies(),
+		locked:   make(map[string]bool),
 	}
 }
 
+// policyFile is the format of .cm-policy.yaml and organization policies
+type policyFile struct {
+	Version  string   `yaml:"version"`
+	Policies []Policy `yaml:"policies"`
+}
+
 // LoadPolicies loads policies from a YAML file
 func (e *SimpleEngine) LoadPolicies(path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read policy file: %w", err)
 	}
+	return e.loadData(data, path, false)
+}
 
-	var policyFile struct {
-		Version  string   `yaml:"version"`
-		Policies []Policy `yaml:"policies"`
+// loadData merges a policy file into the loaded policies. Fields set in the
+// file override the built-in or earlier definition of the same ID. Policies
+// set by the organization cannot be changed by later files.
+func (e *SimpleEngine) loadData(data []byte, source string, org bool) error {
+	var file policyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse policy file %s: %w", source, err)
 	}
 
-	if err := yaml.Unmarshal(data, &policyFile); err != nil {
-		return fmt.Errorf("failed to parse policy file: %w", err)
-	}
+	for _, p := range file.Policies {
+		if p.ID == "" {
+			return fmt.Errorf("policy file %s: every policy needs an id", source)
+		}
+		switch p.Mode {
+		case "", ModeWarn, ModeDeny, ModeOff:
+		default:
+			return fmt.Errorf("policy file %s: %s has unknown mode %q (use warn, deny or off)", source, p.ID, p.Mode)
+		}
+		if e.locked[p.ID] {
+			continue
+		}
+		if org {
+			e.locked[p.ID] = true
+		}
 
-	// Merge with default policies, custom ones override
-	policyMap := make(map[string]Policy)
-	for _, p := range e.policies {
-		policyMap[p.ID] = p
-	}
-	for _, p := range policyFile.Policies {
-		p.Enabled = true // Loaded policies are enabled by default
-		policyMap[p.ID] = p
-	}
+		i := e.indexOf(p.ID)
+		if i < 0 {
+			p.Enabled = p.Mode != ModeOff // Loaded policies are enabled by default
+			p.Source = source
+			e.policies = append(e.policies, p)
+			continue
+		}
 
-	e.policies = make([]Policy, 0, len(policyMap))
-	for _, p := range policyMap {
-		e.policies = append(e.policies, p)
+		existing := &e.policies[i]
+		if p.Name != "" {
+			existing.Name = p.Name
+		}
+		if p.Description != "" {
+			existing.Description = p.Description
+		}
+		if p.Type != "" {
+			existing.Type = p.Type
+		}
+		if p.Severity != "" {
+			existing.Severity = p.Severity
+		}
+		if p.Rule != "" {
+			existing.Rule = p.Rule
+		}
+		if p.Mode != "" {
+			existing.Mode = p.Mode
+		}
+		if len(p.Parameters) > 0 {
+			params := make(map[string]interface{}, len(existing.Parameters)+len(p.Parameters))
+			for k, v := range existing.Parameters {
+				params[k] = v
+			}
+			for k, v := range p.Parameters {
+				params[k] = v
+			}
+			existing.Parameters = params
+		}
+		existing.Enabled = existing.Mode != ModeOff
+		existing.Source = source
 	}
 
-	fmt.Printf("📋 Loaded %d policies from %s\n", len(policyFile.Policies), path)
+	e.sources = append(e.sources, source)
 	return nil
 }
 
+func (e *SimpleEngine) indexOf(id string) int {
+	for i, p := range e.policies {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sources returns the policy files loaded so far, in load order
+func (e *SimpleEngine) Sources() []string {
+	return e.sources
+}
+
 // GetPolicies returns loaded policies
 func (e *SimpleEngine) GetPolicies() []Policy {
 	return e.policies
@@ -101,12 +168,13 @@ func (e *SimpleEngine) EvaluateService(ctx context.Context, svc *workspace.Servi
 	}
 
 	for _, p := range e.policies {
-		if !p.Enabled {
+		if !p.Enabled || p.Mode == ModeOff {
 			continue
 		}
 
 		violation := e.checkPolicy(p, svc)
 		if violation != nil {
+			violation.Mode = p.EffectiveMode()
 			result.Violations = append(result.Violations, *violation)
 		}
 	}
@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// ProjectFileNames are the policy files looked up in a project
+var ProjectFileNames = []string{".cm-policy.yaml", ".cm-policy.yml"}
+
+// Load returns an engine with the built-in policies, then the organization
+// policy (CM_POLICY or 'cm config set policy.source'), then the project's
+// .cm-policy.yaml from the given directories applied on top
+func Load(dirs ...string) (*SimpleEngine, error) {
+	e := NewEngine()
+
+	if cfg, err := userconfig.Load(); err == nil && cfg.Policy.Source != "" {
+		if err := e.LoadOrgPolicies(cfg.Policy.Source); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if path := FindProjectFile(dir); path != "" {
+			if err := e.LoadPolicies(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// FindProjectFile returns the project policy file in dir, or ""
+func FindProjectFile(dir string) string {
+	for _, name := range ProjectFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadOrgPolicies loads the organization policy from a file or an http(s)
+// URL. Policies it sets are locked: project files cannot relax them.
+// Downloaded policies are cached so they still apply when offline or when
+// the server is unreachable.
+func (e *SimpleEngine) LoadOrgPolicies(source string) error {
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to read organization policy: %w", err)
+		}
+		return e.loadData(data, source, true)
+	}
+
+	cachePath := orgCachePath()
+	data, err := fetchOrgPolicy(source)
+	if err != nil {
+		cached, cacheErr := os.ReadFile(cachePath)
+		if cacheErr != nil {
+			return fmt.Errorf("organization policy %s is unavailable and not cached: %w", source, err)
+		}
+		data = cached
+	} else if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			_ = os.WriteFile(cachePath, data, 0644)
+		}
+	}
+	return e.loadData(data, source, true)
+}
+
+func fetchOrgPolicy(url string) ([]byte, error) {
+	if offline.Enabled() {
+		return nil, fmt.Errorf("offline mode")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	resp, err := httpclient.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func orgCachePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cm", "policy", "org.yaml")
+}
@@ -19,6 +19,16 @@ const (
 	SeverityCritical SeverityLevel = "critical"
 )
 
+// Mode decides what happens when a policy is violated while a dev container
+// config is loaded
+type Mode string
+
+const (
+	ModeWarn Mode = "warn" // report the violation and continue
+	ModeDeny Mode = "deny" // refuse to use the config
+	ModeOff  Mode = "off"  // do not evaluate the policy
+)
+
 // PolicyType defines the type of policy
 type PolicyType string
 
@@ -38,10 +48,22 @@ type Policy struct {
 	Type        PolicyType    `json:"type" yaml:"type"`
 	Severity    SeverityLevel `json:"severity" yaml:"severity"`
 	Enabled     bool          `json:"enabled" yaml:"enabled"`
+	Mode        Mode          `json:"mode,omitempty" yaml:"mode,omitempty"` // Empty means warn
 
 	// Rule logic (rego or internal)
 	Rule       string                 `json:"rule,omitempty" yaml:"rule,omitempty"`
 	Parameters map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+
+	// Source is the policy file that last configured the policy
+	Source string `json:"source,omitempty" yaml:"-"`
+}
+
+// EffectiveMode returns the policy's mode, defaulting to warn
+func (p Policy) EffectiveMode() Mode {
+	if p.Mode == "" {
+		return ModeWarn
+	}
+	return p.Mode
 }
 
 // Violation represents a policy violation
@@ -49,6 +71,7 @@ type Violation struct {
 	PolicyID   string        `json:"policy_id"`
 	PolicyName string        `json:"policy_name"`
 	Severity   SeverityLevel `json:"severity"`
+	Mode       Mode          `json:"mode"`
 	Message    string        `json:"message"`
 	Resource   string        `json:"resource"`           // e.g., service name
 	Location   string        `json:"location,omitempty"` // file:line or field path
@@ -81,6 +104,17 @@ type Engine interface {
 	GetPolicies() []Policy
 }
 
+// Denied returns the violations of policies in deny mode
+func (r *EvaluationResult) Denied() []Violation {
+	var denied []Violation
+	for _, v := range r.Violations {
+		if v.Mode == ModeDeny {
+			denied = append(denied, v)
+		}
+	}
+	return denied
+}
+
 // DefaultPolicies returns a set of built-in default policies
 func DefaultPolicies() []Policy {
 	return []Policy{
@@ -124,5 +158,45 @@ func DefaultPolicies() []Policy {
 			Severity:    SeverityWarning,
 			Enabled:     true,
 		},
+		{
+			ID:          "DC-001",
+			Name:        "No Privileged Dev Containers",
+			Description: "runArgs must not include --privileged",
+			Type:        PolicyTypeSecurity,
+			Severity:    SeverityCritical,
+			Enabled:     true,
+		},
+		{
+			ID:          "DC-002",
+			Name:        "No Host Namespaces",
+			Description: "runArgs must not share the host network, PID, IPC or UTS namespace",
+			Type:        PolicyTypeSecurity,
+			Severity:    SeverityError,
+			Enabled:     true,
+		},
+		{
+			ID:          "DC-003",
+			Name:        "No Container Socket Mounts",
+			Description: "The Docker or Podman socket must not be mounted into the dev container",
+			Type:        PolicyTypeSecurity,
+			Severity:    SeverityError,
+			Enabled:     true,
+		},
+		{
+			ID:          "DC-004",
+			Name:        "Pinned Images",
+			Description: "The image and Dockerfile base images must use a version tag, or a digest when require_digest is set",
+			Type:        PolicyTypeBestPractice,
+			Severity:    SeverityWarning,
+			Enabled:     true,
+		},
+		{
+			ID:          "DC-005",
+			Name:        "Allowed Registries",
+			Description: "Images must come from one of the registries listed in the registries parameter",
+			Type:        PolicyTypeSecurity,
+			Severity:    SeverityError,
+			Enabled:     false, // Enabled by a policy file listing the registries
+		},
 	}
 }
@@ -0,0 +1,330 @@
+// Package registry asks container registries about images without pulling
+// them: the digest a tag currently points at and the tags a repository has.
+// It speaks the OCI distribution API with anonymous bearer tokens, which is
+// enough for public images on Docker Hub, GHCR, Quay and MCR.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+)
+
+// DockerHub is the registry of images without a registry host
+const DockerHub = "docker.io"
+
+// manifestTypes are the manifest media types a digest lookup accepts. The
+// index types come first so a multi-arch tag resolves to the digest Docker
+// records in RepoDigests when pulling it.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Reference is a parsed image reference
+type Reference struct {
+	Registry   string // "docker.io", "ghcr.io", ...
+	Repository string // "library/golang", "org/image", ...
+	Tag        string
+	Digest     string // "sha256:...", when pinned
+}
+
+// ParseReference parses "golang:1.22", "ghcr.io/org/image:tag" or
+// "image:tag@sha256:..."; the tag defaults to latest
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+	name := ref
+	if at := strings.Index(name, "@"); at != -1 {
+		name, r.Digest = name[:at], name[at+1:]
+		if !strings.Contains(r.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid digest in image %q", ref)
+		}
+	}
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:colon], name[colon+1:]
+	}
+	if name == "" || strings.ContainsAny(name, " \t$") {
+		return Reference{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	first, rest, hasSlash := strings.Cut(name, "/")
+	switch {
+	case hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost"):
+		r.Registry, r.Repository = first, rest
+	case hasSlash:
+		r.Registry, r.Repository = DockerHub, name
+	default:
+		r.Registry, r.Repository = DockerHub, "library/"+name
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	return r, nil
+}
+
+// Name returns the reference without its tag and digest, in the short form
+// Docker uses: "golang" rather than "docker.io/library/golang"
+func (r Reference) Name() string {
+	if r.Registry != DockerHub {
+		return r.Registry + "/" + r.Repository
+	}
+	return strings.TrimPrefix(r.Repository, "library/")
+}
+
+// String returns the reference with its tag and digest
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// host returns the registry's API host
+func (r Reference) host() string {
+	if r.Registry == DockerHub {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
+
+// Client queries registries, caching a token per repository
+type Client struct {
+	HTTP *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a client using the shared proxy-aware HTTP client
+func NewClient() *Client {
+	return &Client{HTTP: httpclient.New(httpclient.Options{Timeout: 30 * time.Second})}
+}
+
+// Digest returns the digest the reference's tag currently points at
+func (c *Client) Digest(ctx context.Context, r Reference) (string, error) {
+	ref := r.Tag
+	if ref == "" {
+		ref = r.Digest
+	}
+	resp, err := c.do(ctx, http.MethodHead, r, "/manifests/"+ref, strings.Join(manifestTypes, ", "))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("%s did not return a digest for %s", r.Registry, r)
+	}
+	return digest, nil
+}
+
+// maxTagPages bounds how many pages of a repository's tags are read
+const maxTagPages = 20
+
+// Tags returns the repository's tags
+func (c *Client) Tags(ctx context.Context, r Reference) ([]string, error) {
+	var tags []string
+	path := "/tags/list?n=1000"
+	for page := 0; page < maxTagPages && path != ""; page++ {
+		resp, err := c.do(ctx, http.MethodGet, r, path, "")
+		if err != nil {
+			return nil, err
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag list from %s: %w", r.Registry, err)
+		}
+		tags = append(tags, list.Tags...)
+		path = nextPage(resp.Header.Get("Link"), r.Repository)
+	}
+	return tags, nil
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPage returns the path after /v2/<repository> of the next page of a
+// paginated list, from its Link header
+func nextPage(link, repository string) string {
+	m := linkPattern.FindStringSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	u, err := url.Parse(m[1])
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimPrefix(u.Path, "/v2/"+repository)
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
+
+// do sends a request to /v2/<repository><path>, fetching an anonymous
+// token when the registry asks for one
+func (c *Client) do(ctx context.Context, method string, r Reference, path, accept string) (*http.Response, error) {
+	endpoint := "https://" + r.host() + "/v2/" + r.Repository + path
+	send := func(token string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return c.HTTP.Do(req)
+	}
+
+	key := r.Registry + "/" + r.Repository
+	c.mu.Lock()
+	token := c.tokens[key]
+	c.mu.Unlock()
+
+	resp, err := send(token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if token, err = c.token(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Registry, err)
+		}
+		c.mu.Lock()
+		if c.tokens == nil {
+			c.tokens = make(map[string]string)
+		}
+		c.tokens[key] = token
+		c.mu.Unlock()
+		if resp, err = send(token); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d from %s for %s", resp.StatusCode, r.Registry, r.Name())
+	}
+	return resp, nil
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// token requests an anonymous token for a "Bearer realm=..." challenge
+func (c *Client) token(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("authentication required")
+	}
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid authentication challenge %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("authentication required (HTTP %d from the token service)", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("the token service returned no token")
+}
+
+var versionTag = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(.*)$`)
+
+// NewerTag returns the newest tag with the same shape as current but a
+// higher version: "1.21-alpine" leads to "1.23-alpine", not to "1.23" or
+// "1.23.4-alpine". It returns "" for tags without a version, like latest.
+func NewerTag(current string, tags []string) string {
+	m := versionTag.FindStringSubmatch(current)
+	if m == nil {
+		return ""
+	}
+	version, suffix := parseVersion(m[1]), m[2]
+	prefix := current[:len(current)-len(m[1])-len(suffix)]
+
+	best, bestVersion := "", version
+	for _, tag := range tags {
+		t := versionTag.FindStringSubmatch(tag)
+		if t == nil || t[2] != suffix || tag[:len(tag)-len(t[1])-len(t[2])] != prefix {
+			continue
+		}
+		v := parseVersion(t[1])
+		if len(v) == len(version) && compareVersions(v, bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best
+}
+
+func parseVersion(s string) []int {
+	parts := strings.Split(s, ".")
+	v := make([]int, len(parts))
+	for i, p := range parts {
+		v[i], _ = strconv.Atoi(p)
+	}
+	return v
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
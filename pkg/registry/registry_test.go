@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	for ref, want := range map[string]Reference{
+		"golang":                           {Registry: DockerHub, Repository: "library/golang", Tag: "latest"},
+		"golang:1.22-alpine":               {Registry: DockerHub, Repository: "library/golang", Tag: "1.22-alpine"},
+		"pytorch/pytorch:2.3.0":            {Registry: DockerHub, Repository: "pytorch/pytorch", Tag: "2.3.0"},
+		"ghcr.io/org/image:v1":             {Registry: "ghcr.io", Repository: "org/image", Tag: "v1"},
+		"localhost:5000/image":             {Registry: "localhost:5000", Repository: "image", Tag: "latest"},
+		"golang:1.22@sha256:abc":           {Registry: DockerHub, Repository: "library/golang", Tag: "1.22", Digest: "sha256:abc"},
+		"mcr.microsoft.com/a/b@sha256:def": {Registry: "mcr.microsoft.com", Repository: "a/b", Digest: "sha256:def"},
+	} {
+		got, err := ParseReference(ref)
+		if err != nil {
+			t.Errorf("ParseReference(%q): %v", ref, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", ref, got, want)
+		}
+	}
+
+	if r, _ := ParseReference("golang:1.22@sha256:abc"); r.String() != "golang:1.22@sha256:abc" {
+		t.Errorf("String() = %q", r.String())
+	}
+	for _, ref := range []string{"", "python:${templateOption:version}", "golang@abc"} {
+		if _, err := ParseReference(ref); err == nil {
+			t.Errorf("ParseReference(%q) should fail", ref)
+		}
+	}
+}
+
+func TestNewerTag(t *testing.T) {
+	tags := []string{"latest", "1.21-alpine", "1.22-alpine", "1.23-alpine", "1.23", "1.23.4-alpine", "1.24rc1-alpine", "v2.0", "v2.1", "2.2"}
+	for current, want := range map[string]string{
+		"1.21-alpine": "1.23-alpine",
+		"1.23-alpine": "",
+		"1.21":        "2.2",
+		"v2.0":        "v2.1",
+		"latest":      "",
+	} {
+		if got := NewerTag(current, tags); got != want {
+			t.Errorf("NewerTag(%q) = %q, want %q", current, got, want)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:org/app:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token": "secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test",scope="repository:org/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/org/app/manifests/1.0":
+			if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+				http.Error(w, "missing accept", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:feed")
+		case r.URL.Path == "/v2/org/app/tags/list" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/org/app/tags/list?n=1000&last=1.1>; rel="next"`)
+			w.Write([]byte(`{"tags": ["1.0", "1.1"]}`))
+		case r.URL.Path == "/v2/org/app/tags/list":
+			w.Write([]byte(`{"tags": ["1.2"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{HTTP: server.Client()}
+	ref, err := ParseReference(strings.TrimPrefix(server.URL, "https://") + "/org/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := c.Digest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:feed" {
+		t.Errorf("Digest = %q, want sha256:feed", digest)
+	}
+
+	tags, err := c.Tags(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.0", "1.1", "1.2"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags = %v, want %v", tags, want)
+	}
+
+	ref.Repository = "org/missing"
+	if _, err := c.Digest(context.Background(), ref); err == nil {
+		t.Error("Digest of a missing repository should fail")
+	}
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// AgentInfo is what a running agent records in its pid file
+type AgentInfo struct {
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// agentFile returns the path of the agent's pid file
+func agentFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "agent.json"), nil
+}
+
+// RunningAgent returns the background agent if one is alive
+func RunningAgent() (*AgentInfo, bool) {
+	path, err := agentFile()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var info AgentInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.PID <= 0 {
+		return nil, false
+	}
+	proc, err := os.FindProcess(info.PID)
+	if err != nil || proc.Signal(syscall.Signal(0)) != nil {
+		return nil, false
+	}
+	return &info, true
+}
+
+// RunAgent watches container events until ctx is cancelled and removes the
+// ready marker of a project as soon as its persistent container stops, so
+// 'cm exec' can trust markers without a TTL while the agent runs
+func RunAgent(ctx context.Context) error {
+	if info, ok := RunningAgent(); ok {
+		return fmt.Errorf("agent already running (pid %d)", info.PID)
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Ping(ctx); err != nil {
+		return fmt.Errorf("cannot reach Docker: %w", err)
+	}
+
+	path, err := agentFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(AgentInfo{PID: os.Getpid(), StartedAt: time.Now()})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	// On a cloud instance, also report activity for idle shutdown
+	reporter := newActivityReporter()
+	if reporter != nil {
+		go reporter.run(ctx)
+	}
+	// and serve the control plane's requests
+	if channel := newControlChannel(cli, reporter); channel != nil {
+		go channel.run(ctx)
+	}
+
+	eventsCh, errCh := cli.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("label", LabelManaged+"=true"),
+		),
+	})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("event stream failed: %w", err)
+			}
+			return nil
+		case ev := <-eventsCh:
+			switch ev.Action {
+			case events.ActionDie, events.ActionStop, events.ActionKill, events.ActionPause, events.ActionDestroy:
+				if dir := ev.Actor.Attributes[LabelProject]; dir != "" {
+					ClearReadyMarker(dir)
+				}
+			default:
+				if reporter != nil && strings.HasPrefix(string(ev.Action), string(events.ActionExecStart)) {
+					reporter.execStarted()
+				}
+			}
+		}
+	}
+}
+
+// StopAgent stops the running agent
+func StopAgent() error {
+	info, ok := RunningAgent()
+	if !ok {
+		return fmt.Errorf("agent is not running")
+	}
+	proc, err := os.FindProcess(info.PID)
+	if err != nil {
+		return err
+	}
+	if err := proc.Signal(os.Interrupt); err != nil {
+		return proc.Kill()
+	}
+	return nil
+}
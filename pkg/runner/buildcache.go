@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// cacheBuilder is the buildx builder created to export build caches when
+// the current one can't
+const cacheBuilder = "cm-cache"
+
+// CacheSpecs turns a build cache backend into the --cache-from and
+// --cache-to specs of an image build. A backend is a registry repository
+// (ghcr.io/acme/api-cache), an S3 location (s3://bucket/name, with
+// ?region= or AWS_REGION, and ?endpoint= for S3-compatible storage), or a
+// BuildKit spec (type=...) that is used as is.
+func CacheSpecs(backend string) (from, to string, err error) {
+	if strings.HasPrefix(backend, "type=") {
+		return backend, backend, nil
+	}
+
+	if rest, ok := strings.CutPrefix(backend, "s3://"); ok {
+		u, err := url.Parse("s3://" + rest)
+		if err != nil || u.Host == "" {
+			return "", "", fmt.Errorf("invalid S3 cache %q: want s3://bucket/name", backend)
+		}
+		name := strings.Trim(u.Path, "/")
+		if name == "" {
+			name = "cm"
+		}
+		region := u.Query().Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return "", "", fmt.Errorf("S3 cache %q needs a region: add ?region= or set AWS_REGION", backend)
+		}
+		spec := fmt.Sprintf("type=s3,region=%s,bucket=%s,name=%s", region, u.Host, name)
+		if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+			spec += ",endpoint_url=" + endpoint + ",use_path_style=true"
+		}
+		if strings.Contains(spec, `"`) {
+			return "", "", fmt.Errorf("invalid S3 cache %q", backend)
+		}
+		return spec, spec + ",mode=max", nil
+	}
+
+	if strings.Contains(backend, "://") || strings.ContainsAny(backend, ", \"") || backend == "" {
+		return "", "", fmt.Errorf("invalid cache %q: want a registry repository, s3://bucket/name or type=...", backend)
+	}
+	spec := "type=registry,ref=" + backend
+	return spec, spec + ",mode=max", nil
+}
+
+// cacheBuildArgs returns the build arguments that import and export a
+// build's cache, and whether they need 'docker buildx build'. The default
+// docker driver only exports inline caches, so other exports go through a
+// docker-container builder, created once, whose image is loaded back into
+// Docker.
+func cacheBuildArgs(ctx context.Context, from []string, to string) (args []string, buildx bool) {
+	for _, spec := range from {
+		args = append(args, "--cache-from", spec)
+	}
+	if to == "" {
+		return args, false
+	}
+	args = append(args, "--cache-to", to)
+	if strings.HasPrefix(to, "type=inline") || !usesDockerDriver(ctx) {
+		return args, false
+	}
+	if exec.CommandContext(ctx, "docker", "buildx", "inspect", cacheBuilder).Run() != nil {
+		fmt.Printf("Creating buildx builder %s to export the build cache...\n", cacheBuilder)
+		create := exec.CommandContext(ctx, "docker", "buildx", "create", "--name", cacheBuilder, "--driver", "docker-container")
+		if out, err := create.CombinedOutput(); err != nil {
+			// Let the build report that the cache can't be exported
+			fmt.Printf("Warning: failed to create buildx builder: %s\n", strings.TrimSpace(string(out)))
+			return args, false
+		}
+	}
+	return append(args, "--builder", cacheBuilder, "--load"), true
+}
+
+// usesDockerDriver reports whether the current buildx builder uses the
+// docker driver
+func usesDockerDriver(ctx context.Context) bool {
+	out, err := exec.CommandContext(ctx, "docker", "buildx", "inspect").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if driver, ok := strings.CutPrefix(strings.TrimSpace(line), "Driver:"); ok {
+			return strings.TrimSpace(driver) == "docker"
+		}
+	}
+	return false
+}
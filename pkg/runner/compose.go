@@ -3,12 +3,14 @@ package runner
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
 )
 
 // ComposeRunner handles Docker Compose-based dev containers
@@ -16,6 +18,20 @@ type ComposeRunner struct {
 	Config      *config.DevContainerConfig
 	ComposeFile string
 	ProjectDir  string
+
+	// Files are compose files merged over the config's, in order, like
+	// docker compose -f; relative paths are relative to the current
+	// directory
+	Files []string
+
+	// Profiles are the compose profiles to enable, whose services start
+	// along with the ones without a profile
+	Profiles []string
+
+	// NoTTY runs commands without a terminal, and Stdout and Stderr receive
+	// their output instead of os.Stdout and os.Stderr (cm ci)
+	NoTTY          bool
+	Stdout, Stderr io.Writer
 }
 
 // NewComposeRunner creates a new Docker Compose runner
@@ -95,6 +111,9 @@ func (r *ComposeRunner) Exec(ctx context.Context, command []string) error {
 
 	args := r.buildBaseArgs()
 	args = append(args, "exec")
+	if r.NoTTY {
+		args = append(args, "-T")
+	}
 
 	// Add user if specified
 	if r.Config.User != "" {
@@ -148,7 +167,18 @@ func (r *ComposeRunner) Prepare(ctx context.Context) error {
 	args = append(args, "build")
 
 	fmt.Println("Building Docker Compose services...")
-	if err := r.runCompose(ctx, args); err != nil {
+	done := metrics.Start(metrics.KindBuild, r.ProjectDir, "docker compose build")
+	output, err := r.runComposeCaptured(ctx, args)
+	done(err)
+	if err != nil {
+		recordFailure(r.ProjectDir, Failure{
+			Kind:      "build",
+			Name:      "docker compose build",
+			Command:   "docker compose " + strings.Join(args, " "),
+			Error:     err.Error(),
+			Output:    output,
+			ConfigDir: r.Config.ConfigDir,
+		})
 		return err
 	}
 
@@ -171,6 +201,26 @@ func (r *ComposeRunner) buildBaseArgs() []string {
 				args = append(args, "-f", filepath.Join(r.ProjectDir, f))
 			}
 		}
+	} else if len(r.Files) == 0 {
+		// docker compose merges docker-compose.override.yml only when no
+		// -f is given
+		main := filepath.Join(r.ProjectDir, r.ComposeFile)
+		ext := filepath.Ext(main)
+		override := strings.TrimSuffix(main, ext) + ".override" + ext
+		if _, err := os.Stat(override); err == nil {
+			args = append(args, "-f", override)
+		}
+	}
+	for _, f := range r.Files {
+		// docker compose runs in the project directory
+		if abs, err := filepath.Abs(f); err == nil {
+			f = abs
+		}
+		args = append(args, "-f", f)
+	}
+
+	for _, profile := range r.Profiles {
+		args = append(args, "--profile", profile)
 	}
 
 	return args
@@ -185,13 +235,29 @@ func (r *ComposeRunner) runCompose(ctx context.Context, args []string) error {
 	return cmd.Run()
 }
 
+// runComposeCaptured executes docker compose, returning the tail of its
+// output for a failure record
+func (r *ComposeRunner) runComposeCaptured(ctx context.Context, args []string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose"}, args...)...)
+	cmd.Dir = r.ProjectDir
+	var tail *tailBuffer
+	cmd.Stdout, cmd.Stderr, tail = captureOutput()
+	err := cmd.Run()
+	return tail.String(), err
+}
+
 // runComposeInteractive executes docker compose with interactive stdin
 func (r *ComposeRunner) runComposeInteractive(ctx context.Context, args []string) error {
 	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose"}, args...)...)
 	cmd.Dir = r.ProjectDir
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if r.Stdout != nil {
+		cmd.Stdout = r.Stdout
+	}
+	if r.Stderr != nil {
+		cmd.Stderr = r.Stderr
+	}
 	return cmd.Run()
 }
 
@@ -239,7 +305,18 @@ func (r *ComposeRunner) executeLifecycleHooks(ctx context.Context) error {
 			fmt.Printf("Executing %s: %s\n", hook.name, cmd)
 			args := r.buildBaseArgs()
 			args = append(args, "exec", "-T", service, "/bin/sh", "-c", cmd)
-			if err := r.runCompose(ctx, args); err != nil {
+			done := metrics.Start(metrics.KindHook, r.ProjectDir, hook.name)
+			output, err := r.runComposeCaptured(ctx, args)
+			done(err)
+			if err != nil {
+				recordFailure(r.ProjectDir, Failure{
+					Kind:      "hook",
+					Name:      hook.name,
+					Command:   cmd,
+					Error:     err.Error(),
+					Output:    output,
+					ConfigDir: r.Config.ConfigDir,
+				})
 				return fmt.Errorf("%s failed: %w", hook.name, err)
 			}
 		}
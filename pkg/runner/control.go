@@ -0,0 +1,466 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// controlReadTimeout is how long the control channel waits for the
+	// control plane's pings before it reconnects
+	controlReadTimeout = 90 * time.Second
+
+	// controlWriteTimeout bounds each message sent to the control plane
+	controlWriteTimeout = 10 * time.Second
+
+	// controlMaxBackoff caps the wait between reconnection attempts
+	controlMaxBackoff = time.Minute
+
+	// controlExecTimeout bounds a command run for the control plane
+	controlExecTimeout = 10 * time.Minute
+
+	// maxExecOutput is how much of each output stream of a command is
+	// returned; longer output keeps its tail
+	maxExecOutput = 1 << 20
+)
+
+// controlMessage is a request from the control plane or a reply to one;
+// replies carry the request's ID. Requests are exec, logs, health, docker
+// and cancel; every request ends with a result, and logs also stream log
+// lines before it.
+type controlMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+
+	// Requests
+	Command   []string `json:"command,omitempty"`   // exec: the command and its arguments
+	Container string   `json:"container,omitempty"` // exec, logs, docker: a container, or the host if empty
+	Action    string   `json:"action,omitempty"`    // docker: list, start, stop, restart or remove
+	Tail      int      `json:"tail,omitempty"`      // logs: lines of history, 0 for all
+	Follow    bool     `json:"follow,omitempty"`    // logs: keep streaming new lines
+
+	// Replies
+	Stdout     string             `json:"stdout,omitempty"`
+	Stderr     string             `json:"stderr,omitempty"`
+	ExitCode   int                `json:"exit_code,omitempty"`
+	Line       string             `json:"line,omitempty"`
+	Health     *instanceHealth    `json:"health,omitempty"`
+	Containers []containerSummary `json:"containers,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// instanceHealth is what the agent reports about the instance it runs on
+type instanceHealth struct {
+	Load              float64  `json:"load"`                // One-minute load average per CPU
+	SSHSessions       int      `json:"ssh_sessions"`        // Open SSH connections
+	UptimeSeconds     int64    `json:"uptime_seconds"`      // Since boot
+	MemoryTotalMB     int64    `json:"memory_total_mb"`     // 0 where /proc isn't available
+	MemoryAvailableMB int64    `json:"memory_available_mb"` // 0 where /proc isn't available
+	Containers        int      `json:"containers"`          // Running containers
+	DockerVersion     string   `json:"docker_version"`
+	CPUs              int      `json:"cpus"`
+	GPUs              []string `json:"gpus,omitempty"` // NVIDIA GPU models, by device index
+}
+
+// containerSummary is a container on the instance
+type containerSummary struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Image  string `json:"image"`
+	State  string `json:"state"`
+	Status string `json:"status"`
+}
+
+// controlChannel keeps an outbound WebSocket open to the control plane and
+// serves its requests over it, so instances behind NAT or firewalls that
+// block inbound connections can still be managed. Like activityReporter it
+// is configured by CM_CLOUD_URL, CM_INSTANCE_ID and CM_AGENT_TOKEN, or on a
+// machine registered to run instances by CM_HOST_ID instead of
+// CM_INSTANCE_ID.
+type controlChannel struct {
+	url      string
+	token    string
+	docker   *client.Client
+	reporter *activityReporter // Counts execs as activity, if set
+
+	writeMu sync.Mutex // Serializes writes to the connection
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // Of requests in progress, by ID
+}
+
+// newControlChannel returns nil unless the agent runs on a cloud instance
+// or a registered host
+func newControlChannel(docker *client.Client, reporter *activityReporter) *controlChannel {
+	baseURL, id, token := os.Getenv("CM_CLOUD_URL"), os.Getenv("CM_INSTANCE_ID"), os.Getenv("CM_AGENT_TOKEN")
+	path := "instances"
+	if host := os.Getenv("CM_HOST_ID"); host != "" {
+		id, path = host, "hosts"
+	}
+	if baseURL == "" || id == "" || token == "" {
+		return nil
+	}
+	wsURL := strings.TrimSuffix(baseURL, "/")
+	if strings.HasPrefix(wsURL, "https://") {
+		wsURL = "wss://" + strings.TrimPrefix(wsURL, "https://")
+	} else {
+		wsURL = "ws://" + strings.TrimPrefix(wsURL, "http://")
+	}
+	return &controlChannel{
+		url:      fmt.Sprintf("%s/api/v1/%s/%s/agent", wsURL, path, id),
+		token:    token,
+		docker:   docker,
+		reporter: reporter,
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+// run keeps the channel connected until ctx is cancelled, backing off
+// between failed attempts
+func (ch *controlChannel) run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected := time.Now()
+		err := ch.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(connected) > controlMaxBackoff {
+			backoff = time.Second
+		}
+		fmt.Fprintf(os.Stderr, "control channel: %v; reconnecting in %s\n", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > controlMaxBackoff {
+			backoff = controlMaxBackoff
+		}
+	}
+}
+
+// connect dials the control plane and serves requests until the
+// connection drops; requests in progress are cancelled with it
+func (ch *controlChannel) connect(ctx context.Context) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+ch.token)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, ch.url, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to connect: %w (%s)", err, resp.Status)
+		}
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(controlWriteTimeout))
+	})
+
+	for {
+		var msg controlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("connection lost: %w", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+		if msg.Type == "cancel" {
+			ch.cancel(msg.ID)
+			continue
+		}
+		go ch.handle(ctx, conn, msg)
+	}
+}
+
+// send writes a message to the control plane
+func (ch *controlChannel) send(conn *websocket.Conn, msg controlMessage) error {
+	ch.writeMu.Lock()
+	defer ch.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(controlWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+// cancel stops a request in progress
+func (ch *controlChannel) cancel(id string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if cancel, ok := ch.cancels[id]; ok {
+		cancel()
+		delete(ch.cancels, id)
+	}
+}
+
+// handle serves a request and sends its result
+func (ch *controlChannel) handle(ctx context.Context, conn *websocket.Conn, req controlMessage) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch.mu.Lock()
+	ch.cancels[req.ID] = cancel
+	ch.mu.Unlock()
+	defer ch.cancel(req.ID)
+
+	result := controlMessage{ID: req.ID, Type: "result"}
+	var err error
+	switch req.Type {
+	case "exec":
+		err = ch.exec(ctx, req, &result)
+	case "logs":
+		err = ch.logs(ctx, req, func(line string) error {
+			return ch.send(conn, controlMessage{ID: req.ID, Type: "log", Line: line})
+		})
+	case "health":
+		result.Health, err = ch.health(ctx)
+	case "docker":
+		result.Containers, err = ch.dockerAction(ctx, req)
+	default:
+		err = fmt.Errorf("unknown request %q", req.Type)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err := ch.send(conn, result); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "control channel: failed to reply: %v\n", err)
+	}
+}
+
+// exec runs a command on the host or in a container and records its output
+// and exit code in result
+func (ch *controlChannel) exec(ctx context.Context, req controlMessage, result *controlMessage) error {
+	if len(req.Command) == 0 {
+		return fmt.Errorf("no command given")
+	}
+	ctx, cancel := context.WithTimeout(ctx, controlExecTimeout)
+	defer cancel()
+	if ch.reporter != nil {
+		ch.reporter.execStarted()
+	}
+
+	stdout := &tailBuffer{max: maxExecOutput}
+	stderr := &tailBuffer{max: maxExecOutput}
+	defer func() {
+		result.Stdout, result.Stderr = stdout.String(), stderr.String()
+	}()
+
+	if req.Container == "" {
+		cmd := exec.CommandContext(ctx, req.Command[0], req.Command[1:]...)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		err := cmd.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+			return nil
+		}
+		return err
+	}
+
+	execID, err := createExec(ctx, ch.docker, req.Container, req.Command, false)
+	if err != nil {
+		return err
+	}
+	attach, err := ch.docker.ContainerExecAttach(ctx, execID, container.ExecStartOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer attach.Close()
+	if _, err := stdcopy.StdCopy(stdout, stderr, attach.Reader); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	inspect, err := ch.docker.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	result.ExitCode = inspect.ExitCode
+	return nil
+}
+
+// logs sends the last lines of a container's logs, or of the system journal
+// without one, and with req.Follow new lines until ctx is cancelled
+func (ch *controlChannel) logs(ctx context.Context, req controlMessage, send func(line string) error) error {
+	var reader io.Reader
+	if req.Container == "" {
+		args := []string{"--no-pager", "--output", "short-iso"}
+		if req.Tail > 0 {
+			args = append(args, "--lines", strconv.Itoa(req.Tail))
+		}
+		if req.Follow {
+			args = append(args, "--follow")
+		}
+		cmd := exec.CommandContext(ctx, "journalctl", args...)
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to read the system journal: %w", err)
+		}
+		defer func() { _ = cmd.Wait() }()
+		reader = out
+	} else {
+		info, err := ch.docker.ContainerInspect(ctx, req.Container)
+		if err != nil {
+			return err
+		}
+		tail := "all"
+		if req.Tail > 0 {
+			tail = strconv.Itoa(req.Tail)
+		}
+		rc, err := ch.docker.ContainerLogs(ctx, req.Container, container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     req.Follow,
+			Tail:       tail,
+		})
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		reader = rc
+		if info.Config == nil || !info.Config.Tty {
+			// Without a TTY stdout and stderr are multiplexed
+			pr, pw := io.Pipe()
+			go func() {
+				_, err := stdcopy.StdCopy(pw, pw, rc)
+				pw.CloseWithError(err)
+			}()
+			reader = pr
+		}
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := send(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// health reports the instance's load, CPUs, GPUs, memory, uptime and
+// containers
+func (ch *controlChannel) health(ctx context.Context) (*instanceHealth, error) {
+	h := &instanceHealth{
+		Load:        loadPerCPU(),
+		SSHSessions: sshSessions(),
+		CPUs:        runtime.NumCPU(),
+		GPUs:        nvidiaGPUs(ctx),
+	}
+	if data, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			uptime, _ := strconv.ParseFloat(fields[0], 64)
+			h.UptimeSeconds = int64(uptime)
+		}
+	}
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			switch fields[0] {
+			case "MemTotal:":
+				h.MemoryTotalMB = kb / 1024
+			case "MemAvailable:":
+				h.MemoryAvailableMB = kb / 1024
+			}
+		}
+	}
+
+	version, err := ch.docker.ServerVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach Docker: %w", err)
+	}
+	h.DockerVersion = version.Version
+	running, err := ch.docker.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	h.Containers = len(running)
+	return h, nil
+}
+
+// nvidiaGPUs returns the models of the NVIDIA GPUs, by device index; none
+// without nvidia-smi
+func nvidiaGPUs(ctx context.Context) []string {
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=name", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil
+	}
+	var gpus []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			gpus = append(gpus, line)
+		}
+	}
+	return gpus
+}
+
+// dockerAction lists the instance's containers or starts, stops, restarts
+// or removes one; it returns the containers after the action
+func (ch *controlChannel) dockerAction(ctx context.Context, req controlMessage) ([]containerSummary, error) {
+	if req.Action != "list" && req.Container == "" {
+		return nil, fmt.Errorf("no container given")
+	}
+	var err error
+	switch req.Action {
+	case "list":
+	case "start":
+		err = ch.docker.ContainerStart(ctx, req.Container, container.StartOptions{})
+	case "stop":
+		err = ch.docker.ContainerStop(ctx, req.Container, container.StopOptions{})
+	case "restart":
+		err = ch.docker.ContainerRestart(ctx, req.Container, container.StopOptions{})
+	case "remove":
+		err = ch.docker.ContainerRemove(ctx, req.Container, container.RemoveOptions{Force: true})
+	default:
+		return nil, fmt.Errorf("unknown action %q; use list, start, stop, restart or remove", req.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ch.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]containerSummary, 0, len(list))
+	for _, c := range list {
+		name := c.ID[:12]
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		containers = append(containers, containerSummary{
+			ID:     c.ID[:12],
+			Name:   name,
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+		})
+	}
+	return containers, nil
+}
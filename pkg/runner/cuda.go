@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// wantsGPU reports whether the container asks for GPUs, with --gpus in
+// runArgs or hostRequirements.gpu
+func wantsGPU(cfg *config.DevContainerConfig) bool {
+	for _, arg := range cfg.RunArgs {
+		if arg == "--gpus" || strings.HasPrefix(arg, "--gpus=") {
+			return true
+		}
+	}
+	if req := cfg.HostRequirements; req != nil {
+		switch g := req.GPU.(type) {
+		case nil:
+			return false
+		case bool:
+			return g
+		case string:
+			return g != "false"
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/devices"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+	"github.com/docker/docker/api/types/container"
+)
+
+// resolveDevices returns how a backend ("docker", "podman", "nerdctl" or
+// "wsl") gives the container the devices of its config, and the host's AMD
+// or Intel GPUs for --gpus, warning about those it won't get
+func resolveDevices(cfg *config.DevContainerConfig, backend string) (*devices.Plan, error) {
+	plan, err := devices.Resolve(append(gpuDevices(cfg), cfg.Devices...), backend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid devices setting: %w", err)
+	}
+	for _, w := range plan.Warnings {
+		fmt.Printf("⚠️  %s\n", w)
+	}
+	return plan, nil
+}
+
+// applyDevices adds the devices of a plan to a Docker host config
+func applyDevices(plan *devices.Plan, hostConfig *container.HostConfig) {
+	for _, d := range plan.Devices {
+		hostConfig.Devices = append(hostConfig.Devices, container.DeviceMapping{
+			PathOnHost:        d.Host,
+			PathInContainer:   d.Container,
+			CgroupPermissions: "rwm",
+		})
+	}
+	hostConfig.DeviceCgroupRules = append(hostConfig.DeviceCgroupRules, plan.CgroupRules...)
+	hostConfig.Binds = append(hostConfig.Binds, plan.Binds...)
+	hostConfig.GroupAdd = append(hostConfig.GroupAdd, plan.Groups...)
+}
+
+// applyRuntimeDevices adds the devices of a plan to a runtime config
+func applyRuntimeDevices(plan *devices.Plan, cfg *runtime.ContainerConfig) {
+	for _, d := range plan.Devices {
+		cfg.Devices = append(cfg.Devices, runtime.DeviceMapping{
+			PathOnHost:        d.Host,
+			PathInContainer:   d.Container,
+			CgroupPermissions: "rwm",
+		})
+	}
+	cfg.DeviceCgroupRules = append(cfg.DeviceCgroupRules, plan.CgroupRules...)
+	cfg.Binds = append(cfg.Binds, plan.Binds...)
+	cfg.GroupAdd = append(cfg.GroupAdd, plan.Groups...)
+}
+
+// watchDevices re-attaches the devices of the config plugged in or out of
+// the host until the returned function is called; backendCmd is the CLI of
+// the backend
+func watchDevices(ctx context.Context, cfg *config.DevContainerConfig, backend, backendCmd, containerID string) func() {
+	if len(cfg.Devices) == 0 {
+		return func() {}
+	}
+	plan, err := devices.Resolve(cfg.Devices, backend)
+	if err != nil || len(plan.Hotplug) == 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	m := devices.NewMonitor(backendCmd, containerID, plan.Hotplug)
+	m.Logf = func(format string, args ...interface{}) {
+		// The terminal may be in raw mode
+		fmt.Fprintf(os.Stderr, "\r🔌 "+format+"\r\n", args...)
+	}
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"net"
@@ -17,9 +18,15 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/cudacheck"
 	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/UPwith-me/Container-Maker/pkg/hostpath"
+	"github.com/UPwith-me/Container-Maker/pkg/hostreq"
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
@@ -29,6 +36,35 @@ import (
 type Runner struct {
 	Client *client.Client
 	Config *config.DevContainerConfig
+
+	// Run options, like docker run's; the zero value is an anonymous
+	// container removed when the command exits
+	KeepContainer bool   // Keep the container after it exits (--rm=false)
+	Name          string // Container name (--name)
+	Detach        bool   // Start in the background and print the ID (--detach)
+	Image         string // Run this image instead of resolving the config's (--image)
+
+	// Build cache imports and export (--cache-from, --cache-to), as
+	// BuildKit specs; they default to CM_CACHE_FROM and CM_CACHE_TO
+	CacheFrom []string
+	CacheTo   string
+
+	// NoTTY runs the command without a terminal even when stdin is one, and
+	// Stdout and Stderr receive its output instead of os.Stdout and
+	// os.Stderr (cm ci)
+	NoTTY          bool
+	Stdout, Stderr io.Writer
+
+	// ExitCode is the exit status of the command once Run returns
+	ExitCode int
+}
+
+// stdoutToStderr points os.Stdout at stderr until restore is called, and
+// returns the stdout it replaced
+func stdoutToStderr() (stdout *os.File, restore func()) {
+	stdout = os.Stdout
+	os.Stdout = os.Stderr
+	return stdout, func() { os.Stdout = stdout }
 }
 
 func NewRunner(cfg *config.DevContainerConfig) (*Runner, error) {
@@ -40,21 +76,53 @@ func NewRunner(cfg *config.DevContainerConfig) (*Runner, error) {
 }
 
 func (r *Runner) Run(ctx context.Context, command []string) error {
-	var imageTag string
+	// A detached run prints only the container ID on stdout, for
+	// id=$(cm run --detach ...); its progress goes to stderr
+	idOut := os.Stdout
+	if r.Detach {
+		var restore func()
+		idOut, restore = stdoutToStderr()
+		defer restore()
+	}
+
+	imageTag := r.Image
 	var err error
 
-	// 1. Resolve Image (Build/Pull + Features)
-	imageTag, err = r.ResolveImage(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to resolve image: %w", err)
+	// 1. Resolve Image (Build/Pull + Features), unless one is pinned
+	if imageTag == "" {
+		imageTag, err = r.ResolveImage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to resolve image: %w", err)
+		}
 	}
 	r.Config.Image = imageTag
 
+	// 1.1 Docker access sidecar (dockerInDocker), removed with the container
+	cwd, _ := os.Getwd()
+	accessPrefix := r.Name
+	if accessPrefix == "" {
+		accessPrefix = fmt.Sprintf("cm-run-%d", os.Getpid())
+	}
+	access, err := newDockerAccess(r.Config, "docker", accessPrefix, projectContainerName(cwd))
+	if err != nil {
+		return err
+	}
+	var accessEnv, accessBinds []string
+	if access != nil {
+		if accessEnv, accessBinds, err = access.Start(ctx); err != nil {
+			return err
+		}
+		// A detached container keeps using its sidecars
+		if !r.Detach {
+			defer access.Cleanup(context.Background())
+		}
+	}
+
 	// 2. Create Container
 	fmt.Println("Creating container...")
 
-	// Check if we are in a terminal
-	isTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+	// Check if we are in a terminal; a detached container gets none
+	isTerminal := term.IsTerminal(int(os.Stdin.Fd())) && !r.Detach && !r.NoTTY
 
 	// 2.1 Setup workspace mount
 	workspaceBind, workspaceDir, err := r.setupWorkspaceMount()
@@ -64,11 +132,13 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 
 	// Basic HostConfig
 	hostConfig := &container.HostConfig{
-		AutoRemove: true,             // --rm
+		AutoRemove: !r.KeepContainer, // --rm
 		Init:       &[]bool{true}[0], // --init
 		Binds:      r.Config.Mounts,
 	}
 
+	hostConfig.Binds = append(hostConfig.Binds, accessBinds...)
+
 	// Add workspace bind mount if available
 	if workspaceBind != "" {
 		hostConfig.Binds = append(hostConfig.Binds, workspaceBind)
@@ -84,53 +154,55 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		}
 	}
 
+	// 2.3 Host devices (devices)
+	devicePlan, err := resolveDevices(r.Config, "docker")
+	if err != nil {
+		return err
+	}
+	applyDevices(devicePlan, hostConfig)
+
+	// 2.4 /etc/hosts entries and DNS (hostAliases, dns)
+	if err := applyNameResolution(r.Config, hostConfig); err != nil {
+		return err
+	}
+
 	// Port Forwarding
 	exposedPorts := nat.PortSet{}
 	portBindings := nat.PortMap{}
 
-	for _, p := range r.Config.ForwardPorts {
-		var portSpec string
-		switch v := p.(type) {
-		case float64: // JSON numbers are floats
-			portSpec = fmt.Sprintf("%d", int(v))
-		case int:
-			portSpec = fmt.Sprintf("%d", v)
-		case string:
-			portSpec = v
-		default:
-			fmt.Printf("Warning: invalid port format: %v\n", p)
-			continue
-		}
-
-		// Parse port specification
-		// Formats: "8080", "8080/tcp", "8080/udp", "8080:80", "8080:80/tcp"
-		hostPort, containerPort, protocol := parsePortSpec(portSpec)
-
-		// Check for port conflict
-		if isPortInUse(hostPort, protocol) {
-			fmt.Printf("Warning: port %s/%s is already in use on host, skipping\n", hostPort, protocol)
-			continue
-		}
-
-		port := nat.Port(containerPort + "/" + protocol)
+	// Busy host ports are skipped or remapped (see resolvePorts)
+	ports, _ := resolvePorts(r.Config.PortSpecs(), projectDirOf(r.Config))
+	for _, p := range ports {
+		port := nat.Port(p.ContainerPort + "/" + p.Protocol)
 		exposedPorts[port] = struct{}{}
 		portBindings[port] = []nat.PortBinding{
 			{
 				HostIP:   "127.0.0.1", // Bind to localhost
-				HostPort: hostPort,
+				HostPort: p.HostPort,
 			},
 		}
-		fmt.Printf("Forwarding port %s -> %s/%s\n", hostPort, containerPort, protocol)
+		fmt.Printf("Forwarding port %s -> %s/%s\n", p.HostPort, p.ContainerPort, p.Protocol)
 	}
 
 	hostConfig.PortBindings = portBindings
+	if publishesAllPorts(r.Config) {
+		hostConfig.PublishAllPorts = true
+		fmt.Println("Publishing all exposed ports on random host ports")
+	}
 
 	// Entrypoint setup
 	// We inject a script to handle UID mapping
 	entrypointPath := "/tmp/cm-entrypoint.sh"
 
 	// Merge environment variables
-	envVars := mergeEnvMaps(r.Config.ContainerEnv, r.Config.RemoteEnv)
+	envVars := append(mergeEnvMaps(r.Config.ContainerEnv, r.Config.RemoteEnv), accessEnv...)
+	envVars = append(envVars, portEnv(ports)...)
+
+	// Host time zone, locale and CA certificates, which the entrypoint
+	// installs
+	host := hostSetup(r.Config)
+	envVars = append(envVars, host.Env...)
+	hostConfig.Binds = append(hostConfig.Binds, host.Binds...)
 
 	// Pass target user to entrypoint if specified in config
 	if r.Config.User != "" {
@@ -157,6 +229,7 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		OpenStdin:    true,
 		Entrypoint:   []string{"/bin/sh", entrypointPath},
 		ExposedPorts: exposedPorts,
+		Labels:       map[string]string{LabelManaged: "true"},
 	}
 
 	// Set working directory if workspace is configured
@@ -164,12 +237,19 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		containerConfig.WorkingDir = workspaceDir
 	}
 
-	resp, err := r.Client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	resp, err := r.Client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, r.Name)
 	if err != nil {
 		return fmt.Errorf("failed to create container: %w", err)
 	}
 	fmt.Printf("Container created: %s\n", resp.ID)
 
+	if access != nil {
+		if err := access.Connect(ctx, resp.ID); err != nil {
+			_ = r.Client.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+			return err
+		}
+	}
+
 	// 2.5 Inject Entrypoint Script
 	if err := r.copyEntrypointToContainer(ctx, resp.ID, entrypointPath); err != nil {
 		// Clean up
@@ -197,6 +277,20 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		fmt.Println("Warning: 'features' are detected in devcontainer.json but are not yet supported by Container-Make. They will be ignored.")
 	}
 
+	if r.Detach {
+		fmt.Fprintln(idOut, resp.ID)
+		if access != nil {
+			fmt.Fprintf(os.Stderr, "Docker access sidecars keep running; remove them with: docker rm -f %s\n", strings.Join(access.Sidecars(), " "))
+		}
+		return nil
+	}
+	if r.KeepContainer {
+		defer fmt.Printf("Container kept: %s (inspect with 'docker logs %.12s', remove with 'docker rm %.12s')\n", resp.ID, resp.ID, resp.ID)
+	}
+
+	// Devices plugged in while the command runs are attached to it
+	defer watchDevices(ctx, r.Config, "docker", "docker", resp.ID)()
+
 	// 4. Handle Signals & TTY
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -246,6 +340,14 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		fmt.Printf("Warning: postAttachCommand failed: %v\n", err)
 	}
 
+	stdout, stderr := r.Stdout, r.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
 	// Use a channel to signal when output streaming is done
 	outputDone := make(chan error, 1)
 
@@ -254,11 +356,11 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		if isTerminal {
 			// In TTY mode, stdout and stderr are merged, and we copy stdin
 			go func() { _, _ = io.Copy(attachResp.Conn, os.Stdin) }()
-			_, err := io.Copy(os.Stdout, attachResp.Reader)
+			_, err := io.Copy(stdout, attachResp.Reader)
 			outputDone <- err
 		} else {
 			// In non-TTY mode, use StdCopy to demultiplex
-			_, err := stdcopy.StdCopy(os.Stdout, os.Stderr, attachResp.Reader)
+			_, err := stdcopy.StdCopy(stdout, stderr, attachResp.Reader)
 			outputDone <- err
 		}
 	}()
@@ -270,7 +372,8 @@ func (r *Runner) Run(ctx context.Context, command []string) error {
 		if err != nil {
 			return fmt.Errorf("error waiting for container: %w", err)
 		}
-	case <-statusCh:
+	case status := <-statusCh:
+		r.ExitCode = int(status.StatusCode)
 	}
 
 	// Wait for output to finish (with timeout)
@@ -331,6 +434,34 @@ func parseRunArgs(runArgs []string, hostConfig *container.HostConfig, _ *contain
 				CgroupPermissions: "rwm",
 			})
 
+		case "--add-host":
+			val, err := getValue()
+			if err != nil {
+				return err
+			}
+			hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, val)
+
+		case "--dns":
+			val, err := getValue()
+			if err != nil {
+				return err
+			}
+			hostConfig.DNS = append(hostConfig.DNS, val)
+
+		case "--dns-search":
+			val, err := getValue()
+			if err != nil {
+				return err
+			}
+			hostConfig.DNSSearch = append(hostConfig.DNSSearch, val)
+
+		case "--dns-option", "--dns-opt":
+			val, err := getValue()
+			if err != nil {
+				return err
+			}
+			hostConfig.DNSOptions = append(hostConfig.DNSOptions, val)
+
 		case "--network", "--net":
 			val, err := getValue()
 			if err != nil {
@@ -367,6 +498,9 @@ func parseRunArgs(runArgs []string, hostConfig *container.HostConfig, _ *contain
 			if err != nil {
 				return err
 			}
+			if gpusAsDevices() {
+				continue // Given as devices, see gpuDevices
+			}
 			// Handle GPU access via DeviceRequests
 			// Common values: "all", "device=0", "device=0,1"
 			if val == "all" {
@@ -459,6 +593,14 @@ func (r *Runner) Build(ctx context.Context) (string, error) {
 	// For simplicity, let's use "cm-dev-env" for now, or maybe hash the path
 	tag := "cm-dev-env:latest"
 
+	if err := checkOfflineBuild(ctx, "docker", dockerfile, r.Config.Build.Args); err != nil {
+		return "", err
+	}
+	if err := verifyBuildImages(ctx, dockerfile, r.Config.Build.Args); err != nil {
+		return "", err
+	}
+	prePullBaseImages(ctx, NewPullManager(r.Client), dockerfile, r.Config.Build.Args)
+
 	fmt.Printf("Building image %s from %s...\n", tag, dockerfile)
 
 	// Construct docker build command
@@ -468,31 +610,75 @@ func (r *Runner) Build(ctx context.Context) (string, error) {
 	for k, v := range r.Config.Build.Args {
 		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, v))
 	}
+	if r.Config.Build.Target != "" {
+		args = append(args, "--target", r.Config.Build.Target)
+	}
 
-	// Add cache support from environment variables
-	if cacheFrom := os.Getenv("CM_CACHE_FROM"); cacheFrom != "" {
-		args = append(args, "--cache-from", cacheFrom)
-		fmt.Printf("Using cache from: %s\n", cacheFrom)
+	// Add cache support, from the environment unless set
+	cacheFrom, cacheTo := r.CacheFrom, r.CacheTo
+	if len(cacheFrom) == 0 && os.Getenv("CM_CACHE_FROM") != "" {
+		cacheFrom = []string{os.Getenv("CM_CACHE_FROM")}
 	}
-	if cacheTo := os.Getenv("CM_CACHE_TO"); cacheTo != "" {
-		args = append(args, "--cache-to", cacheTo)
+	if cacheTo == "" {
+		cacheTo = os.Getenv("CM_CACHE_TO")
+	}
+	for _, spec := range cacheFrom {
+		fmt.Printf("Using cache from: %s\n", spec)
+	}
+	if cacheTo != "" {
 		fmt.Printf("Caching to: %s\n", cacheTo)
 	}
+	cacheArgs, buildx := cacheBuildArgs(ctx, cacheFrom, cacheTo)
+	args = append(args, cacheArgs...)
+	if buildx {
+		args = append([]string{"buildx"}, args...)
+	}
 
 	args = append(args, buildContext)
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	var tail *tailBuffer
+	var done func(error)
+	cmd.Stdout, cmd.Stderr, tail, done = captureBuildOutput(projectDirOf(r.Config), tag)
 
-	if err := cmd.Run(); err != nil {
+	err := cmd.Run()
+	done(err)
+	if err != nil {
+		dockerfilePath, _ := filepath.Abs(dockerfile)
+		recordFailure(projectDirOf(r.Config), Failure{
+			Kind:       "build",
+			Name:       tag,
+			Command:    "docker " + strings.Join(args, " "),
+			Error:      err.Error(),
+			Output:     tail.String(),
+			Dockerfile: dockerfilePath,
+			ConfigDir:  r.Config.ConfigDir,
+		})
 		return "", err
 	}
 
 	return tag, nil
 }
 
+// checkOfflineBuild fails fast when an offline build needs a base image that
+// is not available locally, rather than letting the build try to pull it
+func checkOfflineBuild(ctx context.Context, backend, dockerfile string, buildArgs map[string]string) error {
+	if !offline.Enabled() {
+		return nil
+	}
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return nil // let the build report it
+	}
+	for _, img := range imports.BaseImages(data, buildArgs) {
+		if err := exec.CommandContext(ctx, backend, "image", "inspect", img).Run(); err != nil {
+			return offline.Missing("image", img)
+		}
+	}
+	return nil
+}
+
 // Pull pulls a Docker image with progress display
 func (r *Runner) Pull(ctx context.Context) error {
 	if r.Config.Image == "" {
@@ -507,21 +693,15 @@ func (r *Runner) Pull(ctx context.Context) error {
 		fmt.Printf("Image %s already exists locally.\n", r.Config.Image)
 		return nil
 	}
-
-	// Pull the image
-	reader, err := r.Client.ImagePull(ctx, r.Config.Image, image.PullOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to pull image: %w", err)
+	if offline.Enabled() {
+		return offline.Missing("image", r.Config.Image)
 	}
-	defer reader.Close()
 
-	// Stream pull progress to stdout
-	_, err = io.Copy(os.Stdout, reader)
-	if err != nil {
-		return fmt.Errorf("failed to read pull output: %w", err)
+	if err := NewPullManager(r.Client).PullAll(ctx, []string{r.Config.Image}); err != nil {
+		return err
 	}
 
-	fmt.Printf("\nSuccessfully pulled %s\n", r.Config.Image)
+	fmt.Printf("Successfully pulled %s\n", r.Config.Image)
 	return nil
 }
 
@@ -530,6 +710,10 @@ func (r *Runner) ResolveImage(ctx context.Context) (string, error) {
 	var baseImage string
 	var err error
 
+	if err := hostreq.Check(ctx, r.Config.HostRequirements, "docker", os.Stdout); err != nil {
+		return "", err
+	}
+
 	// 1. Resolve Base Image
 	if r.Config.Build != nil {
 		baseImage, err = r.Build(ctx)
@@ -537,6 +721,9 @@ func (r *Runner) ResolveImage(ctx context.Context) (string, error) {
 			return "", fmt.Errorf("failed to build base image: %w", err)
 		}
 	} else if r.Config.Image != "" {
+		if err := verify.Images(ctx, []string{r.Config.Image}, os.Stdout); err != nil {
+			return "", err
+		}
 		if err := r.Pull(ctx); err != nil {
 			return "", fmt.Errorf("failed to pull base image: %w", err)
 		}
@@ -544,6 +731,11 @@ func (r *Runner) ResolveImage(ctx context.Context) (string, error) {
 	} else {
 		return "", fmt.Errorf("no image or build configuration found")
 	}
+	if wantsGPU(r.Config) {
+		if err := cudacheck.Check(ctx, "docker", baseImage, os.Stdout); err != nil {
+			return "", err
+		}
+	}
 
 	// 2. Apply Features (if any)
 	if len(r.Config.Features) == 0 {
@@ -562,34 +754,61 @@ func (r *Runner) ResolveImage(ctx context.Context) (string, error) {
 func (r *Runner) applyFeatures(ctx context.Context, baseImage string) (string, error) {
 	fmt.Println("🔍 Resolving DevContainer Features...")
 
-	// parse feature refs
-	refs, err := features.ParseFeaturesFromConfig(r.Config.Features)
+	// Create temp build context
+	tmpDir, err := os.MkdirTemp("", "cm-features-build-*")
 	if err != nil {
 		return "", err
 	}
+	defer os.RemoveAll(tmpDir)
 
-	if len(refs) == 0 {
-		return baseImage, nil
+	// Download features while resolving, so dependsOn can be followed
+	downloaded := make(map[string]*features.Feature)
+	fetch := func(source string) (*features.Feature, error) {
+		ref, err := features.ParseFeatureRef(source, nil)
+		if err != nil {
+			return nil, err
+		}
+		ref.BaseDir = r.Config.ConfigDir
+		if offline.Enabled() && ref.Kind == features.SourceOCI {
+			// Use the feature cache, which 'cm bundle load' fills
+			cached, err := NewOCIFeatureDownloader("docker").DownloadFeature(ctx, source)
+			if err != nil {
+				return nil, err
+			}
+			ref.Kind, ref.Source = features.SourceLocal, cached
+		}
+		feature, err := features.DownloadFeature(ref, tmpDir)
+		if err != nil {
+			if offline.Enabled() {
+				return nil, err
+			}
+			fmt.Printf("Warning: Failed to download feature %s: %v\n", source, err)
+			return nil, nil
+		}
+		downloaded[features.CanonicalFeatureID(source)] = feature
+		return feature, nil
 	}
 
-	// Create temp build context
-	tmpDir, err := os.MkdirTemp("", "cm-features-build-*")
+	plan, err := features.ResolveInstallOrder(r.Config.Features, r.Config.OverrideFeatureInstallOrder, fetch)
 	if err != nil {
 		return "", err
 	}
-	defer os.RemoveAll(tmpDir)
+	for _, w := range plan.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
 
 	installer := features.NewFeatureInstaller(tmpDir)
-
-	// Download features
-	for _, ref := range refs {
-		feature, err := features.DownloadFeature(ref, tmpDir)
-		if err != nil {
-			fmt.Printf("Warning: Failed to download feature %s: %v\n", ref.Source, err)
+	for _, planned := range plan.Features {
+		feature := downloaded[planned.ID]
+		if feature == nil {
 			continue
 		}
+		feature.Options = feature.OptionValues(planned.Options)
 		installer.AddFeature(feature)
 	}
+	if len(installer.Features) == 0 {
+		return baseImage, nil
+	}
 
 	// Generate Dockerfile
 	dockerfileContent := fmt.Sprintf("FROM %s\n", baseImage)
@@ -600,11 +819,12 @@ func (r *Runner) applyFeatures(ctx context.Context, baseImage string) (string, e
 		return "", err
 	}
 
-	// Build feature layer
-	// We tag it based on a hash of features, or just a generic dev tag for now
+	// Build feature layer, tagged by the generated Dockerfile and the content
+	// of local features so that editing a local feature yields a new image
+	digest := sha256.Sum256([]byte(dockerfileContent + features.LocalFeaturesDigest(r.Config.Features, r.Config.ConfigDir)))
 	featureTag := fmt.Sprintf("%s-with-features", baseImage)
 	// Sanitize tag
-	featureTag = strings.ReplaceAll(featureTag, ":", "-") + ":latest"
+	featureTag = strings.ReplaceAll(featureTag, ":", "-") + fmt.Sprintf(":%x", digest[:6])
 
 	fmt.Printf("🛠️  Building image with features -> %s\n", featureTag)
 
@@ -612,10 +832,21 @@ func (r *Runner) applyFeatures(ctx context.Context, baseImage string) (string, e
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	var tail *tailBuffer
+	var done func(error)
+	cmd.Stdout, cmd.Stderr, tail, done = captureBuildOutput(projectDirOf(r.Config), featureTag)
 
-	if err := cmd.Run(); err != nil {
+	err = cmd.Run()
+	done(err)
+	if err != nil {
+		recordFailure(projectDirOf(r.Config), Failure{
+			Kind:      "build",
+			Name:      featureTag,
+			Command:   "docker " + strings.Join(args, " "),
+			Error:     err.Error(),
+			Output:    tail.String(),
+			ConfigDir: r.Config.ConfigDir,
+		})
 		return "", fmt.Errorf("docker build failed: %w", err)
 	}
 
@@ -646,6 +877,7 @@ func (r *Runner) executeLifecycleHook(ctx context.Context, containerID, name str
 	fmt.Printf("Executing %s (%d command(s))...\n", name, len(commands))
 	for i, c := range commands {
 		startTime := time.Now()
+		done := metrics.Start(metrics.KindHook, projectDirOf(r.Config), name)
 		fmt.Printf("  [%d/%d] Running: %s\n", i+1, len(commands), truncateString(c, 60))
 
 		// Create Exec
@@ -666,7 +898,8 @@ func (r *Runner) executeLifecycleHook(ctx context.Context, containerID, name str
 		}
 
 		// Stream output
-		_, _ = stdcopy.StdCopy(os.Stdout, os.Stderr, resp.Reader)
+		stdout, stderr, tail := captureOutput()
+		_, _ = stdcopy.StdCopy(stdout, stderr, resp.Reader)
 		resp.Close()
 
 		// Check exit code
@@ -675,10 +908,21 @@ func (r *Runner) executeLifecycleHook(ctx context.Context, containerID, name str
 			fmt.Printf("  Warning: could not inspect exec status: %v\n", err)
 		} else if inspectResp.ExitCode != 0 {
 			duration := time.Since(startTime)
-			return fmt.Errorf("%s command failed with exit code %d (took %v): %s",
+			err := fmt.Errorf("%s command failed with exit code %d (took %v): %s",
 				name, inspectResp.ExitCode, duration.Round(time.Millisecond), c)
+			done(err)
+			recordFailure(projectDirOf(r.Config), Failure{
+				Kind:      "hook",
+				Name:      name,
+				Command:   c,
+				Error:     err.Error(),
+				Output:    tail.String(),
+				ConfigDir: r.Config.ConfigDir,
+			})
+			return err
 		}
 
+		done(nil)
 		fmt.Printf("  ✓ Completed in %v\n", time.Since(startTime).Round(time.Millisecond))
 	}
 
@@ -752,9 +996,11 @@ func (r *Runner) setupWorkspaceMount() (string, string, error) {
 	// Default workspace folder
 	workdir = "/workspaces/" + projectName
 
-	// Create bind mount string
-	// Use Docker's standard bind mount format
-	bind = fmt.Sprintf("%s:%s", cwd, workdir)
+	// Create bind mount string, translating Windows paths for the engine
+	bind, err = hostpath.New("docker").Bind(cwd, workdir)
+	if err != nil {
+		return "", "", err
+	}
 
 	return bind, workdir, nil
 }
@@ -829,6 +1075,20 @@ func parsePortSpec(spec string) (string, string, string) {
 	return spec, spec, protocol
 }
 
+// publishAll is set by --publish-all
+var publishAll bool
+
+// PublishAllPorts publishes every port the image exposes on a random host
+// port, as publishAllPorts does in devcontainer.json
+func PublishAllPorts() {
+	publishAll = true
+}
+
+// publishesAllPorts reports whether containers for cfg publish all ports
+func publishesAllPorts(cfg *config.DevContainerConfig) bool {
+	return publishAll || cfg.PublishAllPorts
+}
+
 // isPortInUse checks if a port is already in use on the host
 func isPortInUse(port string, protocol string) bool {
 	network := protocol
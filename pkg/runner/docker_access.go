@@ -0,0 +1,320 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// Modes of the dockerInDocker setting
+const (
+	DockerAccessNone  = "none"
+	DockerAccessProxy = "proxy"
+	DockerAccessDind  = "dind"
+)
+
+// DefaultDockerProxyImage filters Docker API requests by endpoint; override
+// it with CM_DOCKER_PROXY_IMAGE. It is pinned to a release, so an upstream
+// push can't change what guards the host's Docker socket.
+const DefaultDockerProxyImage = "tecnativa/docker-socket-proxy:0.3.0"
+
+// DefaultDindImage runs the nested Docker daemon; override it with
+// CM_DIND_IMAGE
+const DefaultDindImage = "docker:dind"
+
+const (
+	dockerProxyPort = 2375 // Where the socket proxy listens
+	dindTLSPort     = 2376 // Where the nested daemon listens with TLS
+
+	// dindCertsPath is where the dev container sees the nested daemon's
+	// certificates; the client certificate is in its client/ directory
+	dindCertsPath = "/cm-dind-certs"
+
+	// dindReadyTimeout bounds the wait for the nested daemon to start
+	dindReadyTimeout = 60 * time.Second
+)
+
+// DefaultDockerProxyAllow is the endpoint allowlist used when dockerProxy.allow
+// is not set: enough for docker ps, images, inspect and logs
+var DefaultDockerProxyAllow = []string{"containers", "images", "info", "networks", "version", "volumes"}
+
+// dockerProxyEndpoints are the API sections the proxy can allow
+var dockerProxyEndpoints = map[string]bool{
+	"auth": true, "build": true, "commit": true, "configs": true, "containers": true,
+	"distribution": true, "events": true, "exec": true, "grpc": true, "images": true,
+	"info": true, "networks": true, "nodes": true, "ping": true, "plugins": true,
+	"secrets": true, "services": true, "session": true, "swarm": true, "system": true,
+	"tasks": true, "version": true, "volumes": true,
+}
+
+// dockerAccess runs the sidecar that gives a dev container a Docker API
+// without mounting the host socket into it
+type dockerAccess struct {
+	backend   string
+	mode      string
+	network   string
+	sidecar   string
+	proxyConf *config.DockerProxyConfig
+
+	// dind only
+	certsVolume string // Recreated with the sidecar
+	cacheVolume string // Image and build cache, kept across containers
+}
+
+// newDockerAccess returns the Docker access setup for a dev container, or nil
+// when the config does not ask for one. projectName names the dind cache
+// volume, so containers of the same project share it.
+func newDockerAccess(cfg *config.DevContainerConfig, backend, containerName, projectName string) (*dockerAccess, error) {
+	switch cfg.DockerInDocker {
+	case "", DockerAccessNone:
+		return nil, nil
+	case DockerAccessProxy:
+		if err := validateDockerProxy(cfg.DockerProxy); err != nil {
+			return nil, err
+		}
+		return &dockerAccess{
+			backend:   backend,
+			mode:      DockerAccessProxy,
+			network:   containerName + "-net",
+			sidecar:   containerName + "-docker-proxy",
+			proxyConf: cfg.DockerProxy,
+		}, nil
+	case DockerAccessDind:
+		return &dockerAccess{
+			backend:     backend,
+			mode:        DockerAccessDind,
+			network:     containerName + "-net",
+			sidecar:     containerName + "-dind",
+			certsVolume: containerName + "-dind-certs",
+			cacheVolume: projectName + "-dind-cache",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown dockerInDocker mode %q (use proxy, dind or none)", cfg.DockerInDocker)
+	}
+}
+
+func validateDockerProxy(pc *config.DockerProxyConfig) error {
+	if pc == nil {
+		return nil
+	}
+	for _, endpoint := range pc.Allow {
+		if !dockerProxyEndpoints[strings.ToLower(endpoint)] {
+			return fmt.Errorf("dockerProxy.allow: unknown endpoint %q", endpoint)
+		}
+	}
+	return nil
+}
+
+// proxyEnv returns the socket proxy's environment: one variable per allowed
+// API section, and POST=1 when writes are allowed
+func (d *dockerAccess) proxyEnv() []string {
+	allow := DefaultDockerProxyAllow
+	write := false
+	if d.proxyConf != nil {
+		if len(d.proxyConf.Allow) > 0 {
+			allow = d.proxyConf.Allow
+		}
+		write = d.proxyConf.AllowWrite
+	}
+
+	seen := make(map[string]bool)
+	var env []string
+	for _, endpoint := range allow {
+		name := strings.ToUpper(endpoint)
+		if !seen[name] {
+			seen[name] = true
+			env = append(env, name+"=1")
+		}
+	}
+	sort.Strings(env)
+	if write {
+		env = append(env, "POST=1", "ALLOW_START=1", "ALLOW_STOP=1", "ALLOW_RESTARTS=1")
+	} else {
+		env = append(env, "POST=0")
+	}
+	return env
+}
+
+// Start creates the network and starts the sidecar. It returns the
+// environment and mounts the dev container needs to reach it.
+func (d *dockerAccess) Start(ctx context.Context) (env, binds []string, err error) {
+	d.Cleanup(ctx) // Leftovers from a container that was not stopped with cm
+
+	if out, err := exec.CommandContext(ctx, d.backend, "network", "create", "--label", "cm.managed=true", d.network).CombinedOutput(); err != nil {
+		return nil, nil, fmt.Errorf("failed to create network %s: %s", d.network, strings.TrimSpace(string(out)))
+	}
+
+	if d.mode == DockerAccessDind {
+		return d.startDind(ctx)
+	}
+
+	image := os.Getenv("CM_DOCKER_PROXY_IMAGE")
+	if image == "" {
+		image = DefaultDockerProxyImage
+	}
+	args := []string{"run", "-d",
+		"--name", d.sidecar,
+		"--network", d.network,
+		"--restart", "unless-stopped",
+		"--label", "cm.managed=true",
+		"-v", hostDockerSocket(d.backend) + ":/var/run/docker.sock:ro",
+	}
+	for _, e := range d.proxyEnv() {
+		args = append(args, "-e", e)
+	}
+	args = append(args, image)
+
+	fmt.Printf("🐳 Starting Docker socket proxy %s...\n", d.sidecar)
+	if out, err := exec.CommandContext(ctx, d.backend, args...).CombinedOutput(); err != nil {
+		d.Cleanup(ctx)
+		return nil, nil, fmt.Errorf("failed to start the Docker socket proxy: %s", strings.TrimSpace(string(out)))
+	}
+
+	return []string{fmt.Sprintf("DOCKER_HOST=tcp://%s:%d", d.sidecar, dockerProxyPort)}, nil, nil
+}
+
+// startDind starts a privileged Docker daemon in the sidecar. Its data
+// directory is the project's cache volume, so pulled images and build cache
+// survive container rebuilds. The daemon generates TLS certificates into a
+// volume the dev container mounts read-only.
+func (d *dockerAccess) startDind(ctx context.Context) ([]string, []string, error) {
+	image := os.Getenv("CM_DIND_IMAGE")
+	if image == "" {
+		image = DefaultDindImage
+	}
+
+	// Two daemons must not share a data directory
+	cache := d.cacheVolume
+	if out, _ := exec.CommandContext(ctx, d.backend, "ps", "-q", "--filter", "volume="+cache).Output(); len(strings.TrimSpace(string(out))) > 0 {
+		fmt.Printf("⚠️  Docker cache volume %s is in use by another container; starting without cache\n", cache)
+		cache = ""
+	}
+
+	args := []string{"run", "-d",
+		"--name", d.sidecar,
+		"--network", d.network,
+		"--privileged",
+		"--restart", "unless-stopped",
+		"--label", "cm.managed=true",
+		"-e", "DOCKER_TLS_CERTDIR=/certs",
+		"-v", d.certsVolume + ":/certs",
+	}
+	if cache != "" {
+		args = append(args, "-v", cache+":/var/lib/docker")
+	}
+	args = append(args, image)
+
+	fmt.Printf("🐳 Starting Docker-in-Docker daemon %s...\n", d.sidecar)
+	if cache != "" {
+		fmt.Printf("   Cache volume: %s\n", cache)
+	}
+	if out, err := exec.CommandContext(ctx, d.backend, args...).CombinedOutput(); err != nil {
+		d.Cleanup(ctx)
+		return nil, nil, fmt.Errorf("failed to start the Docker-in-Docker daemon: %s", strings.TrimSpace(string(out)))
+	}
+
+	if err := d.waitForDind(ctx); err != nil {
+		d.Cleanup(ctx)
+		return nil, nil, err
+	}
+
+	env := []string{
+		fmt.Sprintf("DOCKER_HOST=tcp://%s:%d", d.sidecar, dindTLSPort),
+		"DOCKER_TLS_VERIFY=1",
+		"DOCKER_CERT_PATH=" + dindCertsPath + "/client",
+	}
+	binds := []string{d.certsVolume + ":" + dindCertsPath + ":ro"}
+	return env, binds, nil
+}
+
+// waitForDind waits until the nested daemon answers and its client
+// certificate exists
+func (d *dockerAccess) waitForDind(ctx context.Context) error {
+	deadline := time.Now().Add(dindReadyTimeout)
+	for {
+		check := exec.CommandContext(ctx, d.backend, "exec", d.sidecar, "sh", "-c", "test -f /certs/client/cert.pem && docker info >/dev/null 2>&1")
+		if check.Run() == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			logs, _ := exec.CommandContext(ctx, d.backend, "logs", "--tail", "5", d.sidecar).CombinedOutput()
+			return fmt.Errorf("the Docker-in-Docker daemon did not start within %s: %s", dindReadyTimeout, strings.TrimSpace(string(logs)))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Connect attaches the dev container to the sidecar's network, keeping its
+// default network so forwarded ports still work
+func (d *dockerAccess) Connect(ctx context.Context, containerID string) error {
+	if out, err := exec.CommandContext(ctx, d.backend, "network", "connect", d.network, containerID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to connect to %s: %s", d.network, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Cleanup removes the sidecar, its network and its certificates. The dind
+// cache volume is kept.
+func (d *dockerAccess) Cleanup(ctx context.Context) {
+	removeDockerAccess(ctx, d.backend, d.Sidecars(), d.network, d.Volumes())
+}
+
+// Sidecars returns the containers Cleanup removes
+func (d *dockerAccess) Sidecars() []string {
+	return []string{d.sidecar}
+}
+
+// Volumes returns the volumes Cleanup removes
+func (d *dockerAccess) Volumes() []string {
+	if d.certsVolume == "" {
+		return nil
+	}
+	return []string{d.certsVolume}
+}
+
+// checkDockerCLI hints at installing the docker CLI when the image has none
+func (d *dockerAccess) checkDockerCLI(ctx context.Context, containerID string) {
+	if err := exec.CommandContext(ctx, d.backend, "exec", containerID, "sh", "-c", "command -v docker").Run(); err != nil {
+		fmt.Println("💡 DOCKER_HOST is set, but the image has no docker CLI. Add the")
+		fmt.Println("   ghcr.io/devcontainers/features/docker-outside-of-docker feature to install it.")
+	}
+}
+
+// removeDockerAccess removes sidecars, then their network and volumes,
+// ignoring ones that do not exist
+func removeDockerAccess(ctx context.Context, backend string, sidecars []string, network string, volumes []string) {
+	for _, name := range sidecars {
+		_ = exec.CommandContext(ctx, backend, "rm", "-f", name).Run()
+	}
+	if network != "" {
+		_ = exec.CommandContext(ctx, backend, "network", "rm", network).Run()
+	}
+	for _, name := range volumes {
+		_ = exec.CommandContext(ctx, backend, "volume", "rm", name).Run()
+	}
+}
+
+// hostDockerSocket returns the host's container API socket: DOCKER_HOST when
+// it is a unix socket, the rootless Podman socket, or the Docker default
+func hostDockerSocket(backend string) string {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	if backend == "podman" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return dir + "/podman/podman.sock"
+		}
+		return "/run/podman/podman.sock"
+	}
+	return "/var/run/docker.sock"
+}
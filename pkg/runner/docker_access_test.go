@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+func TestDockerProxyEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *config.DockerProxyConfig
+		want []string
+	}{
+		{"defaults are read-only", nil, []string{"CONTAINERS=1", "IMAGES=1", "INFO=1", "NETWORKS=1", "VERSION=1", "VOLUMES=1", "POST=0"}},
+		{"empty allow keeps the defaults", &config.DockerProxyConfig{}, []string{"CONTAINERS=1", "IMAGES=1", "INFO=1", "NETWORKS=1", "VERSION=1", "VOLUMES=1", "POST=0"}},
+		{"allow replaces the defaults", &config.DockerProxyConfig{Allow: []string{"events", "containers"}}, []string{"CONTAINERS=1", "EVENTS=1", "POST=0"}},
+		{"duplicates and case", &config.DockerProxyConfig{Allow: []string{"exec", "EXEC", "Exec"}}, []string{"EXEC=1", "POST=0"}},
+		{"writes", &config.DockerProxyConfig{Allow: []string{"containers"}, AllowWrite: true}, []string{"CONTAINERS=1", "POST=1", "ALLOW_START=1", "ALLOW_STOP=1", "ALLOW_RESTARTS=1"}},
+	}
+	for _, tt := range tests {
+		d := &dockerAccess{mode: DockerAccessProxy, proxyConf: tt.conf}
+		if got := d.proxyEnv(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: proxyEnv() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDockerProxy(t *testing.T) {
+	tests := []struct {
+		allow   []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"containers", "Images", "EXEC"}, false},
+		{[]string{"containers", "everything"}, true},
+		{[]string{""}, true},
+		{[]string{"containers/create"}, true},
+	}
+	for _, tt := range tests {
+		err := validateDockerProxy(&config.DockerProxyConfig{Allow: tt.allow})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateDockerProxy(%v) = %v, wantErr %v", tt.allow, err, tt.wantErr)
+		}
+	}
+	if err := validateDockerProxy(nil); err != nil {
+		t.Errorf("validateDockerProxy(nil) = %v", err)
+	}
+}
+
+func TestNewDockerAccess(t *testing.T) {
+	tests := []struct {
+		mode    string
+		proxy   *config.DockerProxyConfig
+		wantNil bool
+		wantErr bool
+		sidecar string
+		volumes []string
+	}{
+		{"", nil, true, false, "", nil},
+		{DockerAccessNone, nil, true, false, "", nil},
+		{DockerAccessProxy, nil, false, false, "cm-app-docker-proxy", nil},
+		{DockerAccessProxy, &config.DockerProxyConfig{Allow: []string{"root"}}, true, true, "", nil},
+		{DockerAccessDind, nil, false, false, "cm-app-dind", []string{"cm-app-dind-certs"}},
+		{"host", nil, true, true, "", nil},
+	}
+	for _, tt := range tests {
+		cfg := &config.DevContainerConfig{DockerInDocker: tt.mode, DockerProxy: tt.proxy}
+		d, err := newDockerAccess(cfg, "docker", "cm-app", "cm-app")
+		if (err != nil) != tt.wantErr || (d == nil) != tt.wantNil {
+			t.Errorf("newDockerAccess(%q) = %v, %v", tt.mode, d, err)
+			continue
+		}
+		if d == nil {
+			continue
+		}
+		if d.sidecar != tt.sidecar || d.network != "cm-app-net" || !reflect.DeepEqual(d.Volumes(), tt.volumes) {
+			t.Errorf("newDockerAccess(%q) = sidecar %s, network %s, volumes %v", tt.mode, d.sidecar, d.network, d.Volumes())
+		}
+	}
+}
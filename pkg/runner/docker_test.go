@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestStdoutToStderr checks that a detached run's progress, printed or
+// from the commands it runs, stays off the stdout its ID goes to
+func TestStdoutToStderr(t *testing.T) {
+	read := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := *f
+		*f = w
+		return func() string {
+			*f = orig
+			w.Close()
+			out, _ := io.ReadAll(r)
+			return string(out)
+		}
+	}
+	readStdout, readStderr := read(&os.Stdout), read(&os.Stderr)
+
+	stdout, restore := stdoutToStderr()
+	fmt.Println("Creating container...")
+	cmd := exec.Command("echo", "Step 1/3")
+	cmd.Stdout = os.Stdout
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(stdout, "0123456789ab")
+	restore()
+	fmt.Println("after")
+
+	if got, want := readStdout(), "0123456789ab\nafter\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := readStderr(), "Creating container...\nStep 1/3\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
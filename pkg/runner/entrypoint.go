@@ -1,5 +1,7 @@
 package runner
 
+import "github.com/UPwith-me/Container-Maker/pkg/hostenv"
+
 // EntrypointScript is a shell script that handles UID/GID mapping.
 // It checks the ownership of the current directory (workspace) and creates a user
 // with the same UID/GID if it doesn't exist. Respects CM_TARGET_USER if set.
@@ -11,6 +13,9 @@ if [ "$(id -u)" != "0" ]; then
     exec "$@"
 fi
 
+# Trust the host's CA certificates mounted by cm
+` + hostenv.InstallScript + ` || true
+
 # Check if a specific user is requested via CM_TARGET_USER
 if [ -n "$CM_TARGET_USER" ]; then
     # Check if user exists
@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// maxFailures is how many failures a project keeps; older ones are dropped
+const maxFailures = 10
+
+// maxFailureOutput bounds the output kept of a failed build or hook
+const maxFailureOutput = 64 * 1024
+
+// Failure is the captured output of a failed image build or lifecycle hook,
+// kept for 'cm ai debug'
+type Failure struct {
+	Kind       string    `json:"kind"`    // "build" or "hook"
+	Name       string    `json:"name"`    // Hook name or image tag
+	Command    string    `json:"command"` // What was run
+	Error      string    `json:"error"`
+	Output     string    `json:"output"` // Tail of stdout and stderr
+	Dockerfile string    `json:"dockerfile,omitempty"`
+	ConfigDir  string    `json:"configDir,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// FailuresDir returns the directory a project's failures are kept in
+func FailuresDir(projectDir string) string {
+	return filepath.Join(projectDir, ".cm", "failures")
+}
+
+// RecordFailure saves a failure, dropping the oldest beyond maxFailures
+func RecordFailure(projectDir string, f Failure) error {
+	dir := FailuresDir(projectDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if f.At.IsZero() {
+		f.At = time.Now()
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.json", f.At.UTC().Format("20060102T150405.000000000"), f.Kind)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+
+	files, err := failureFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > maxFailures {
+		_ = os.Remove(files[len(files)-1])
+		files = files[:len(files)-1]
+	}
+	return nil
+}
+
+// Failures returns a project's recorded failures, newest first
+func Failures(projectDir string) ([]Failure, error) {
+	files, err := failureFiles(FailuresDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
+	var failures []Failure
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var f Failure
+		if json.Unmarshal(data, &f) == nil {
+			failures = append(failures, f)
+		}
+	}
+	return failures, nil
+}
+
+// LastFailure returns a project's most recent failure
+func LastFailure(projectDir string) (*Failure, error) {
+	failures, err := Failures(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) == 0 {
+		return nil, fmt.Errorf("no failed build or lifecycle hook recorded in %s", FailuresDir(projectDir))
+	}
+	return &failures[0], nil
+}
+
+// failureFiles lists failure files, newest first; the names sort by time
+func failureFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// captureOutput returns writers that pass output through to the terminal
+// while keeping its tail for a failure record
+func captureOutput() (stdout, stderr io.Writer, tail *tailBuffer) {
+	tail = &tailBuffer{max: maxFailureOutput}
+	return io.MultiWriter(os.Stdout, tail), io.MultiWriter(os.Stderr, tail), tail
+}
+
+// recordFailure saves a failure of a project, warning rather than failing
+// when it cannot be written
+func recordFailure(projectDir string, f Failure) {
+	if err := RecordFailure(projectDir, f); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record failure: %v\n", err)
+	}
+}
+
+// projectDirOf returns the project a configuration belongs to: the parent
+// of its .devcontainer directory, or the directory of the file itself
+func projectDirOf(cfg *config.DevContainerConfig) string {
+	if cfg == nil || cfg.ConfigDir == "" {
+		return "."
+	}
+	dir := cfg.ConfigDir
+	for d := dir; ; d = filepath.Dir(d) {
+		if filepath.Base(d) == ".devcontainer" {
+			return filepath.Dir(d)
+		}
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+	return dir
+}
@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
 )
 
 // FeatureMetadata represents devcontainer-feature.json
@@ -87,7 +88,7 @@ func FetchFeatureMetadata(featureID string) (*FeatureMetadata, error) {
 		featureName := strings.TrimPrefix(path, "devcontainers/features/")
 		url := fmt.Sprintf("https://raw.githubusercontent.com/devcontainers/features/main/src/%s/devcontainer-feature.json", featureName)
 
-		resp, err := http.Get(url)
+		resp, err := httpclient.Default().Get(url)
 		if err != nil {
 			return nil, err
 		}
@@ -197,7 +198,7 @@ func ListOfficialFeatures() ([]string, error) {
 	// Fetch the list from devcontainers/features repo
 	url := "https://api.github.com/repos/devcontainers/features/contents/src"
 
-	resp, err := http.Get(url)
+	resp, err := httpclient.Default().Get(url)
 	if err != nil {
 		return nil, err
 	}
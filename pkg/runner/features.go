@@ -2,12 +2,17 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 )
 
 // Feature represents a DevContainer Feature
@@ -21,38 +26,122 @@ type Feature struct {
 type FeatureInstaller struct {
 	containerID string
 	backend     string
+	configDir   string
 }
 
-// NewFeatureInstaller creates a new feature installer
-func NewFeatureInstaller(containerID, backend string) *FeatureInstaller {
+// NewFeatureInstaller creates a new feature installer. configDir is the
+// directory of devcontainer.json, which local features are relative to.
+func NewFeatureInstaller(containerID, backend, configDir string) *FeatureInstaller {
 	return &FeatureInstaller{
 		containerID: containerID,
 		backend:     backend,
+		configDir:   configDir,
 	}
 }
 
-// InstallFeatures installs features into a container
-func (f *FeatureInstaller) InstallFeatures(ctx context.Context, features map[string]interface{}) error {
-	if len(features) == 0 {
+// InstallFeatures installs features into a container in dependency order
+func (f *FeatureInstaller) InstallFeatures(ctx context.Context, requested map[string]interface{}, installOrder []string) error {
+	if len(requested) == 0 {
 		return nil
 	}
 
-	fmt.Printf("🔧 Installing %d DevContainer feature(s)...\n", len(features))
+	plan, err := ResolveFeatures(ctx, requested, installOrder, f.configDir)
+	if err != nil {
+		return err
+	}
+	for _, w := range plan.Warnings {
+		fmt.Printf("⚠️  %s\n", w)
+	}
+
+	fmt.Printf("🔧 Installing %d DevContainer feature(s)...\n", len(plan.Features))
 
-	for featureID, options := range features {
-		if err := f.installFeature(ctx, featureID, options); err != nil {
-			fmt.Printf("⚠️  Feature '%s' failed: %v\n", featureID, err)
+	for _, planned := range plan.Features {
+		options := interface{}(planned.Options)
+		if planned.Metadata != nil {
+			options = planned.Metadata.OptionValues(planned.Options)
+		}
+		if err := f.installFeature(ctx, planned.Ref, options); err != nil {
+			fmt.Printf("⚠️  Feature '%s' failed: %v\n", planned.Ref, err)
 			continue
 		}
-		fmt.Printf("  ✓ Installed: %s\n", featureID)
+		fmt.Printf("  ✓ Installed: %s\n", planned.Ref)
 	}
 
 	fmt.Println("✅ Features installation complete")
 	return nil
 }
 
+// ResolveFeatures orders features for installation, pulling in their
+// dependsOn features. Metadata comes from the feature cache, downloading as
+// needed; local features ("./name") are read relative to configDir and
+// tarballs are fetched each time. Features
+// whose metadata cannot be fetched are installed without dependencies, except
+// in offline mode where a missing feature is an error.
+func ResolveFeatures(ctx context.Context, requested map[string]interface{}, installOrder []string, configDir string) (*features.InstallPlan, error) {
+	downloader := NewOCIFeatureDownloader("docker")
+	return features.ResolveInstallOrder(requested, installOrder, func(ref string) (*features.Feature, error) {
+		var dir string
+		switch {
+		case features.IsLocalFeature(ref):
+			dir = features.LocalFeatureDir(&features.FeatureRef{Source: ref, BaseDir: configDir})
+		case features.IsTarballFeature(ref):
+			tmpDir, err := os.MkdirTemp("", "cm-feature-*")
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(tmpDir)
+			parsed, err := features.ParseFeatureRef(ref, nil)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := features.DownloadFeature(parsed, tmpDir); err != nil {
+				if offline.Enabled() {
+					return nil, err
+				}
+				return nil, nil
+			}
+			dir = filepath.Join(tmpDir, parsed.ID)
+		default:
+			var err error
+			if dir, err = downloader.DownloadFeature(ctx, ref); err != nil {
+				if offline.Enabled() {
+					return nil, err
+				}
+				return nil, nil
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "devcontainer-feature.json"))
+		if err != nil {
+			return nil, nil
+		}
+		var meta features.Feature
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("invalid devcontainer-feature.json: %w", err)
+		}
+		return &meta, nil
+	})
+}
+
 // installFeature installs a single feature
 func (f *FeatureInstaller) installFeature(ctx context.Context, featureID string, options interface{}) error {
+	// Local directories and tarballs are copied in and run as-is
+	if features.IsLocalFeature(featureID) || features.IsTarballFeature(featureID) {
+		return f.installFromSource(ctx, featureID, options)
+	}
+
+	// Offline, the built-in commands and the CDN are out of reach; install the
+	// cached feature instead
+	if offline.Enabled() {
+		downloader := NewOCIFeatureDownloader(f.backend)
+		dir, err := downloader.DownloadFeature(ctx, featureID)
+		if err != nil {
+			return err
+		}
+		opts, _ := options.(map[string]interface{})
+		return downloader.InstallFeatureInContainer(ctx, f.containerID, dir, opts)
+	}
+
 	// Try built-in command first (faster)
 	if installCmd := f.getFeatureInstallCommand(featureID, options); installCmd != "" {
 		cmd := exec.CommandContext(ctx, f.backend, "exec", f.containerID, "sh", "-c", installCmd)
@@ -71,6 +160,27 @@ func (f *FeatureInstaller) installFeature(ctx context.Context, featureID string,
 	return fmt.Errorf("unsupported feature: %s", featureID)
 }
 
+// installFromSource installs a local or tarball feature
+func (f *FeatureInstaller) installFromSource(ctx context.Context, source string, options interface{}) error {
+	tmpDir, err := os.MkdirTemp("", "cm-feature-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ref, err := features.ParseFeatureRef(source, nil)
+	if err != nil {
+		return err
+	}
+	ref.BaseDir = f.configDir
+	if _, err := features.DownloadFeature(ref, tmpDir); err != nil {
+		return err
+	}
+
+	opts, _ := options.(map[string]interface{})
+	return NewOCIFeatureDownloader(f.backend).InstallFeatureInContainer(ctx, f.containerID, filepath.Join(tmpDir, ref.ID), opts)
+}
+
 // installFromOCI downloads and installs a feature from OCI registry
 func (f *FeatureInstaller) installFromOCI(ctx context.Context, featureID string, options interface{}) error {
 	// Parse ghcr.io/owner/repo/feature:version
@@ -90,7 +200,7 @@ func (f *FeatureInstaller) installFromOCI(ctx context.Context, featureID string,
 	// Try to get install script from devcontainers CDN
 	cdnURL := fmt.Sprintf("https://github.com/devcontainers/features/raw/main/src/%s/install.sh", featureName)
 
-	resp, err := http.Get(cdnURL)
+	resp, err := httpclient.Default().Get(cdnURL)
 	if err != nil || resp.StatusCode != 200 {
 		return fmt.Errorf("feature not found in CDN")
 	}
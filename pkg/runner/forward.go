@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultForwardImage relays a port forwarded after the container was
+// created; override it with CM_FORWARD_IMAGE
+const DefaultForwardImage = "alpine/socat:latest"
+
+// ForwardPort makes a port of the running container reachable on the host,
+// as for a debugger started after the container was created. It returns
+// the published host port when the port is already forwarded, or else
+// starts a socat sidecar on the container's network that publishes the
+// first free host port from the same number; stop removes it.
+func (r *PersistentRunner) ForwardPort(ctx context.Context, containerID string, port int) (hostPort int, stop func(), err error) {
+	backend := r.getBackendCommand()
+	info, err := inspectContainer(ctx, backend, containerID)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, binding := range info.NetworkSettings.Ports[fmt.Sprintf("%d/tcp", port)] {
+		if n, err := strconv.Atoi(binding.HostPort); err == nil {
+			return n, func() {}, nil
+		}
+	}
+
+	// The first network with an address, by name, so reruns pick the same
+	var networks []string
+	for name, network := range info.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			networks = append(networks, name)
+		}
+	}
+	if len(networks) == 0 {
+		return 0, nil, fmt.Errorf("the container has no network address to forward port %d to", port)
+	}
+	sort.Strings(networks)
+	network := networks[0]
+	ip := info.NetworkSettings.Networks[network].IPAddress
+
+	free, ok := freeHostPort(strconv.Itoa(port), "tcp", 0, map[string]bool{})
+	if !ok {
+		return 0, nil, fmt.Errorf("no free host port to forward port %d to", port)
+	}
+	hostPort, _ = strconv.Atoi(free)
+
+	image := os.Getenv("CM_FORWARD_IMAGE")
+	if image == "" {
+		image = DefaultForwardImage
+	}
+	sidecar := fmt.Sprintf("%s-forward-%d", r.GetContainerName(), port)
+	_ = exec.CommandContext(ctx, backend, "rm", "-f", sidecar).Run()
+	args := []string{"run", "-d", "--rm",
+		"--name", sidecar,
+		"--label", "cm.managed=true",
+		"--network", network,
+		"-p", fmt.Sprintf("127.0.0.1:%d:%d", hostPort, port),
+		image,
+		fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", port),
+		fmt.Sprintf("TCP:%s:%d", ip, port),
+	}
+	if out, err := exec.CommandContext(ctx, backend, args...).CombinedOutput(); err != nil {
+		return 0, nil, fmt.Errorf("failed to forward port %d: %s", port, strings.TrimSpace(string(out)))
+	}
+
+	stop = func() {
+		_ = exec.Command(backend, "rm", "-f", sidecar).Run()
+	}
+	return hostPort, stop, nil
+}
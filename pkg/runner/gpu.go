@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+)
+
+// hostGPUVendor is the vendor of the host's GPU: "nvidia", "amd", "intel"
+// or "none"
+var hostGPUVendor = sync.OnceValue(func() string {
+	return runtime.DetectGPU().Type
+})
+
+// gpusAsDevices reports whether --gpus is given to the container as device
+// nodes rather than to the NVIDIA runtime: the host's GPU is AMD or Intel
+func gpusAsDevices() bool {
+	switch hostGPUVendor() {
+	case "amd", "intel":
+		return true
+	}
+	return false
+}
+
+// gpuDevices returns the device class of the host's AMD or Intel GPU when
+// runArgs ask for --gpus, which only the NVIDIA runtime understands
+func gpuDevices(cfg *config.DevContainerConfig) []string {
+	asked := false
+	for _, arg := range cfg.RunArgs {
+		if arg == "--gpus" || strings.HasPrefix(arg, "--gpus=") {
+			asked = true
+		}
+	}
+	if !asked || !gpusAsDevices() {
+		return nil
+	}
+	return []string{"gpu:" + hostGPUVendor()}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+)
+
+const (
+	// heartbeatInterval is how often the agent on a cloud instance reports
+	// activity to the control plane
+	heartbeatInterval = time.Minute
+
+	// busyLoad is the one-minute load average per CPU above which the
+	// instance counts as active, e.g. while a training job runs
+	busyLoad = 0.25
+)
+
+// activityReporter sends the control plane heartbeats saying whether the
+// cloud instance the agent runs on is in use, so idle instances can be
+// stopped. Provisioning configures it with CM_CLOUD_URL, CM_INSTANCE_ID and
+// CM_AGENT_TOKEN.
+type activityReporter struct {
+	url    string
+	token  string
+	client *http.Client
+	execs  atomic.Int64 // Exec sessions started since the last heartbeat
+}
+
+// newActivityReporter returns nil unless the agent runs on a cloud instance
+func newActivityReporter() *activityReporter {
+	baseURL, id, token := os.Getenv("CM_CLOUD_URL"), os.Getenv("CM_INSTANCE_ID"), os.Getenv("CM_AGENT_TOKEN")
+	if baseURL == "" || id == "" || token == "" {
+		return nil
+	}
+	return &activityReporter{
+		url:    fmt.Sprintf("%s/api/v1/instances/%s/heartbeat", strings.TrimSuffix(baseURL, "/"), id),
+		token:  token,
+		client: httpclient.New(httpclient.Options{Timeout: 30 * time.Second}),
+	}
+}
+
+// execStarted records a 'cm exec' or 'docker exec' into a container
+func (r *activityReporter) execStarted() {
+	r.execs.Add(1)
+}
+
+// run sends a heartbeat every interval until ctx is cancelled
+func (r *activityReporter) run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.beat(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "heartbeat failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// beat reports whether anyone is connected over SSH, exec'd into a
+// container or keeps the CPUs busy
+func (r *activityReporter) beat(ctx context.Context) error {
+	sessions := sshSessions()
+	load := loadPerCPU()
+	execs := r.execs.Swap(0)
+	body, _ := json.Marshal(map[string]interface{}{
+		"active":       sessions > 0 || execs > 0 || load >= busyLoad,
+		"ssh_sessions": sessions,
+		"load":         load,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("control plane returned %s", resp.Status)
+	}
+	return nil
+}
+
+// sshSessions counts established connections to local port 22; it is 0
+// where /proc isn't available
+func sshSessions() int {
+	count := 0
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local_address rem_address st ...; addresses are hex IP:port
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[3] != "01" {
+				continue
+			}
+			if i := strings.LastIndexByte(fields[1], ':'); i >= 0 && fields[1][i+1:] == "0016" {
+				count++
+			}
+		}
+		f.Close()
+	}
+	return count
+}
+
+// loadPerCPU returns the one-minute load average divided by the number of
+// CPUs, or 0 where /proc isn't available
+func loadPerCPU() float64 {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return load / float64(runtime.NumCPU())
+}
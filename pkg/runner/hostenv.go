@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/hostenv"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// hostSetup returns the host time zone, locale and CA certificates passed
+// on to a container, as chosen with 'cm config set host.*'. Variables the
+// config sets in containerEnv or remoteEnv are left to it.
+func hostSetup(cfg *config.DevContainerConfig) *hostenv.Setup {
+	opts := hostenv.Options{Timezone: true, Locale: true}
+	if uc, err := userconfig.Load(); err == nil {
+		opts = hostenv.Options{
+			Timezone: !uc.Host.NoTimezone,
+			Locale:   !uc.Host.NoLocale,
+			CACerts:  uc.Host.CACerts,
+		}
+	}
+
+	dir := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".cm")
+	}
+	setup, err := hostenv.Collect(opts, dir)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		opts.CACerts = false
+		setup, _ = hostenv.Collect(opts, dir)
+	}
+
+	env := setup.Env[:0]
+	for _, kv := range setup.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := cfg.ContainerEnv[key]; ok {
+			continue
+		}
+		if _, ok := cfg.RemoteEnv[key]; ok {
+			continue
+		}
+		env = append(env, kv)
+	}
+	setup.Env = env
+	return setup
+}
+
+// installHostCAs adds the host's CA certificates mounted in a container to
+// its system store; a failure only leaves them to the variables pointing at
+// them
+func installHostCAs(ctx context.Context, setup *hostenv.Setup, backend, containerID string) {
+	if len(setup.Binds) == 0 {
+		return
+	}
+	cmd := exec.CommandContext(ctx, backend, "exec", "-u", "root", containerID, "sh", "-c", hostenv.InstallScript)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Printf("⚠️  Failed to install the host's CA certificates: %v %s\n", err, strings.TrimSpace(string(out)))
+	}
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+	"github.com/docker/docker/api/types/container"
+)
+
+// nameResolution returns the /etc/hosts entries (hostAliases) and DNS
+// settings (dns) of a config, so names only corporate DNS knows resolve in
+// the container
+func nameResolution(cfg *config.DevContainerConfig) ([]string, *config.DNSConfig, error) {
+	hosts, err := cfg.ExtraHosts()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.DNS.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid config: %w", err)
+	}
+	dns := cfg.DNS
+	if dns == nil {
+		dns = &config.DNSConfig{}
+	}
+	return hosts, dns, nil
+}
+
+// applyNameResolution adds the hostAliases and dns settings of a config to
+// a Docker host config
+func applyNameResolution(cfg *config.DevContainerConfig, hostConfig *container.HostConfig) error {
+	hosts, dns, err := nameResolution(cfg)
+	if err != nil {
+		return err
+	}
+	hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, hosts...)
+	hostConfig.DNS = append(hostConfig.DNS, dns.Servers...)
+	hostConfig.DNSSearch = append(hostConfig.DNSSearch, dns.Search...)
+	hostConfig.DNSOptions = append(hostConfig.DNSOptions, dns.Options...)
+	return nil
+}
+
+// applyRuntimeNameResolution adds the hostAliases and dns settings of a
+// config to a runtime config
+func applyRuntimeNameResolution(cfg *config.DevContainerConfig, rc *runtime.ContainerConfig) error {
+	hosts, dns, err := nameResolution(cfg)
+	if err != nil {
+		return err
+	}
+	rc.ExtraHosts = append(rc.ExtraHosts, hosts...)
+	rc.DNS = append(rc.DNS, dns.Servers...)
+	rc.DNSSearch = append(rc.DNSSearch, dns.Search...)
+	rc.DNSOptions = append(rc.DNSOptions, dns.Options...)
+	return nil
+}
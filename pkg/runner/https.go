@@ -0,0 +1,209 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/hostpath"
+	"github.com/UPwith-me/Container-Maker/pkg/localca"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// DefaultHTTPSProxyImage terminates TLS for forwarded ports; override it
+// with CM_HTTPS_PROXY_IMAGE
+const DefaultHTTPSProxyImage = "caddy:2-alpine"
+
+// httpsConfigPath is where the proxy sees its Caddyfile and certificate
+const httpsConfigPath = "/etc/cm-https"
+
+// httpsRoute sends https://<port>.<project>.localhost to a forwarded port
+type httpsRoute struct {
+	Hostname string
+	HostPort string
+}
+
+// httpsEnabled reports whether forwarded ports get an HTTPS proxy, via
+// 'cm config set ports.https true'
+func httpsEnabled() bool {
+	cfg, err := userconfig.Load()
+	return err == nil && cfg.Ports.HTTPS
+}
+
+var nonHostnameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// projectHostname returns the DNS label of a project, e.g. "my-app" for
+// "My_App"
+func projectHostname(projectDir string) string {
+	name := nonHostnameChars.ReplaceAllString(strings.ToLower(filepath.Base(projectDir)), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if name == "" {
+		return "project"
+	}
+	return name
+}
+
+// httpsRoutes returns a route per forwarded TCP port; published maps
+// "3000/tcp" style container ports to their host port
+func httpsRoutes(projectDir string, published map[string]string) []httpsRoute {
+	project := projectHostname(projectDir)
+	var routes []httpsRoute
+	for port, hostPort := range published {
+		containerPort, proto, _ := strings.Cut(port, "/")
+		if proto != "tcp" || hostPort == "" {
+			continue
+		}
+		routes = append(routes, httpsRoute{Hostname: containerPort + "." + project + ".localhost", HostPort: hostPort})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Hostname < routes[j].Hostname })
+	return routes
+}
+
+// caddyfile returns the proxy's configuration: one site per route, served
+// with the certificate cm issued and proxied to the host port on upstream
+func caddyfile(routes []httpsRoute, upstream string) string {
+	var b strings.Builder
+	b.WriteString("{\n\tauto_https off\n\tadmin off\n}\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "\nhttps://%s {\n", route.Hostname)
+		fmt.Fprintf(&b, "\ttls %s/cert.pem %s/key.pem\n", httpsConfigPath, httpsConfigPath)
+		fmt.Fprintf(&b, "\treverse_proxy %s:%s\n", upstream, route.HostPort)
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// startHTTPSProxy runs a Caddy sidecar that serves the container's forwarded
+// TCP ports at https://<port>.<project>.localhost with a certificate from
+// cm's local CA. It returns the sidecar to remove with the container, or ""
+// when there is nothing to proxy.
+func startHTTPSProxy(ctx context.Context, backend, containerName, projectDir, containerID string) (string, error) {
+	info, err := inspectContainer(ctx, backend, containerID)
+	if err != nil {
+		return "", err
+	}
+	published := map[string]string{}
+	for port, bindings := range info.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			if binding.HostPort != "" {
+				published[port] = binding.HostPort
+				break
+			}
+		}
+	}
+	routes := httpsRoutes(projectDir, published)
+	if len(routes) == 0 {
+		return "", nil
+	}
+
+	caDir, err := localca.Dir()
+	if err != nil {
+		return "", err
+	}
+	ca, err := localca.Load(caDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the local CA: %w", err)
+	}
+	names := make([]string, len(routes))
+	for i, route := range routes {
+		names[i] = route.Hostname
+	}
+	certPEM, keyPEM, err := ca.Issue(names)
+	if err != nil {
+		return "", err
+	}
+
+	// Docker and nerdctl need host.docker.internal mapped; Podman provides
+	// host.containers.internal
+	upstream, addHost := "host.docker.internal", []string{"--add-host", "host.docker.internal:host-gateway"}
+	if backend == "podman" {
+		upstream, addHost = "host.containers.internal", nil
+	}
+
+	dir := filepath.Join(filepath.Dir(caDir), "https", containerName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	files := map[string][]byte{
+		"cert.pem":  certPEM,
+		"key.pem":   keyPEM,
+		"Caddyfile": []byte(caddyfile(routes, upstream)),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return "", err
+		}
+	}
+	bind, err := hostpath.New(backend).Bind(dir, httpsConfigPath)
+	if err != nil {
+		return "", err
+	}
+
+	// 443 keeps the URLs free of a port; rootless engines can't bind it
+	hostPort := "443"
+	if backend == "podman" || isPortInUse(hostPort, "tcp") {
+		free, ok := freeHostPort("8443", "tcp", 0, map[string]bool{})
+		if !ok {
+			return "", fmt.Errorf("no free port for the HTTPS proxy")
+		}
+		hostPort = free
+	}
+
+	image := os.Getenv("CM_HTTPS_PROXY_IMAGE")
+	if image == "" {
+		image = DefaultHTTPSProxyImage
+	}
+	sidecar := containerName + "-https"
+	_ = exec.CommandContext(ctx, backend, "rm", "-f", sidecar).Run()
+	args := []string{"run", "-d",
+		"--name", sidecar,
+		"--restart", "unless-stopped",
+		"--label", "cm.managed=true",
+		"-p", hostPort + ":443",
+		"-v", bind + ":ro",
+	}
+	args = append(args, addHost...)
+	args = append(args, image, "caddy", "run", "--config", httpsConfigPath+"/Caddyfile", "--adapter", "caddyfile")
+
+	if out, err := exec.CommandContext(ctx, backend, args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to start the HTTPS proxy: %s", strings.TrimSpace(string(out)))
+	}
+
+	suffix := ""
+	if hostPort != "443" {
+		suffix = ":" + hostPort
+	}
+	fmt.Println("🔒 HTTPS proxy:")
+	for _, route := range routes {
+		fmt.Printf("   https://%s%s -> %s\n", route.Hostname, suffix, route.HostPort)
+	}
+	if !ca.Trusted() {
+		fmt.Println("💡 Trust cm's local CA once with 'cm https trust' so browsers accept these certificates.")
+	}
+	return sidecar, nil
+}
+
+// startHTTPSProxyIfEnabled starts the HTTPS proxy when ports.https is set;
+// a failure only warns, as the ports are still forwarded over HTTP
+func startHTTPSProxyIfEnabled(ctx context.Context, backend, containerName, projectDir, containerID string) []string {
+	if !httpsEnabled() {
+		return nil
+	}
+	sidecar, err := startHTTPSProxy(ctx, backend, containerName, projectDir, containerID)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		return nil
+	}
+	if sidecar == "" {
+		return nil
+	}
+	return []string{sidecar}
+}
@@ -0,0 +1,15 @@
+package runner
+
+import (
+	"io"
+
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
+)
+
+// captureBuildOutput is captureOutput for an image build, also counting the
+// steps served from the cache; done records the build's metrics
+func captureBuildOutput(projectDir, image string) (stdout, stderr io.Writer, tail *tailBuffer, done func(error)) {
+	counter, done := metrics.StartBuild(projectDir, image)
+	stdout, stderr, tail = captureOutput()
+	return io.MultiWriter(stdout, counter), io.MultiWriter(stderr, counter), tail, done
+}
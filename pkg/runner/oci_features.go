@@ -12,6 +12,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 )
 
 // OCIFeatureDownloader handles downloading DevContainer Features from OCI registries
@@ -52,11 +55,13 @@ func (d *OCIFeatureDownloader) DownloadFeature(ctx context.Context, featureRef s
 	registry, namespace, name, tag := parseFeatureRef(featureRef)
 
 	// Check cache first
-	cacheKey := fmt.Sprintf("%s-%s-%s-%s", registry, strings.ReplaceAll(namespace, "/", "-"), name, tag)
-	cachePath := filepath.Join(d.cacheDir, cacheKey)
+	cachePath := d.CachePath(featureRef)
 	if _, err := os.Stat(filepath.Join(cachePath, "install.sh")); err == nil {
 		return cachePath, nil // Already cached
 	}
+	if offline.Enabled() {
+		return "", offline.Missing("feature", featureRef)
+	}
 
 	fmt.Printf("📥 Downloading feature: %s\n", featureRef)
 
@@ -86,6 +91,19 @@ func (d *OCIFeatureDownloader) DownloadFeature(ctx context.Context, featureRef s
 	return "", fmt.Errorf("failed to download feature %s: all methods failed", featureRef)
 }
 
+// CacheDir returns the directory downloaded features are cached in
+func (d *OCIFeatureDownloader) CacheDir() string {
+	return d.cacheDir
+}
+
+// CachePath returns where a feature reference is cached, whether or not it
+// has been downloaded yet
+func (d *OCIFeatureDownloader) CachePath(featureRef string) string {
+	registry, namespace, name, tag := parseFeatureRef(featureRef)
+	cacheKey := fmt.Sprintf("%s-%s-%s-%s", registry, strings.ReplaceAll(namespace, "/", "-"), name, tag)
+	return filepath.Join(d.cacheDir, cacheKey)
+}
+
 // parseFeatureRef parses a feature reference into components
 func parseFeatureRef(ref string) (registry, namespace, name, tag string) {
 	tag = "latest"
@@ -132,7 +150,7 @@ func (d *OCIFeatureDownloader) downloadFromGitHub(_ context.Context, name, _ str
 
 	for _, file := range files {
 		url := fmt.Sprintf("%s/%s", baseURL, file)
-		resp, err := http.Get(url)
+		resp, err := httpclient.Default().Get(url)
 		if err != nil {
 			continue
 		}
@@ -182,7 +200,7 @@ func (d *OCIFeatureDownloader) downloadFromOCI(ctx context.Context, registry, na
 	// Accept OCI manifest media types
 	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return err
 	}
@@ -215,7 +233,7 @@ func (d *OCIFeatureDownloader) downloadFromOCIWithToken(ctx context.Context, reg
 	// Get anonymous token from ghcr.io
 	tokenURL := fmt.Sprintf("https://%s/token?scope=repository:%s/%s:pull", registry, namespace, name)
 
-	tokenResp, err := http.Get(tokenURL)
+	tokenResp, err := httpclient.Default().Get(tokenURL)
 	if err != nil {
 		return err
 	}
@@ -238,7 +256,7 @@ func (d *OCIFeatureDownloader) downloadFromOCIWithToken(ctx context.Context, reg
 	req.Header.Set("Authorization", "Bearer "+tokenData.Token)
 	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return err
 	}
@@ -264,7 +282,7 @@ func (d *OCIFeatureDownloader) downloadFromOCIWithToken(ctx context.Context, reg
 	blobReq, _ := http.NewRequestWithContext(ctx, "GET", blobURL, nil)
 	blobReq.Header.Set("Authorization", "Bearer "+tokenData.Token)
 
-	blobResp, err := http.DefaultClient.Do(blobReq)
+	blobResp, err := httpclient.Default().Do(blobReq)
 	if err != nil {
 		return err
 	}
@@ -281,7 +299,7 @@ func (d *OCIFeatureDownloader) downloadFromOCIWithToken(ctx context.Context, reg
 func (d *OCIFeatureDownloader) downloadAndExtractLayer(_ context.Context, registry, namespace, name, digest string, destPath string) error {
 	blobURL := fmt.Sprintf("https://%s/v2/%s/%s/blobs/%s", registry, namespace, name, digest)
 
-	resp, err := http.Get(blobURL)
+	resp, err := httpclient.Default().Get(blobURL)
 	if err != nil {
 		return err
 	}
@@ -370,25 +388,31 @@ func (d *OCIFeatureDownloader) downloadWithOras(ctx context.Context, featureRef,
 	return cmd.Run()
 }
 
-// InstallFeatureInContainer installs a downloaded feature into a container
+// InstallFeatureInContainer installs a downloaded feature into a container.
+// The whole feature directory is copied, since install.sh may use other files.
 func (d *OCIFeatureDownloader) InstallFeatureInContainer(ctx context.Context, containerID, featurePath string, options map[string]interface{}) error {
 	installScript := filepath.Join(featurePath, "install.sh")
 	if _, err := os.Stat(installScript); err != nil {
 		return fmt.Errorf("install.sh not found in feature")
 	}
 
-	// Copy install.sh to container
-	cmd := exec.CommandContext(ctx, d.backend, "cp", installScript, containerID+":/tmp/feature-install.sh")
+	// Copy the feature to the container
+	target := "/tmp/dev-container-features/" + filepath.Base(featurePath)
+	mkdir := exec.CommandContext(ctx, d.backend, "exec", containerID, "mkdir", "-p", target)
+	if err := mkdir.Run(); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, d.backend, "cp", featurePath+"/.", containerID+":"+target)
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
 	// Build environment variables from options
-	envArgs := []string{"exec"}
+	envArgs := []string{"exec", "-u", "root"}
 	for k, v := range options {
 		envArgs = append(envArgs, "-e", fmt.Sprintf("%s=%v", strings.ToUpper(k), v))
 	}
-	envArgs = append(envArgs, containerID, "sh", "-c", "chmod +x /tmp/feature-install.sh && /tmp/feature-install.sh")
+	envArgs = append(envArgs, containerID, "sh", "-c", fmt.Sprintf("cd %s && chmod +x install.sh && ./install.sh", target))
 
 	// Execute install script
 	execCmd := exec.CommandContext(ctx, d.backend, envArgs...)
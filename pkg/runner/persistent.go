@@ -5,7 +5,6 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,9 +12,15 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/UPwith-me/Container-Maker/pkg/cudacheck"
+	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/UPwith-me/Container-Maker/pkg/hostpath"
+	"github.com/UPwith-me/Container-Maker/pkg/hostreq"
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 	"github.com/UPwith-me/Container-Maker/pkg/runtime"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"golang.org/x/term"
@@ -29,20 +34,36 @@ type PersistentRunner struct {
 	StateFile  string
 	ProjectDir string
 	Backend    string // "docker", "podman", etc.
+
+	prebuilt bool // The image was prebuilt with the features installed
 }
 
 // ContainerState stores the state of a persistent container
 type ContainerState struct {
-	ContainerID   string    `json:"containerId"`
-	ContainerName string    `json:"containerName"`
-	CreatedAt     time.Time `json:"createdAt"`
-	ConfigHash    string    `json:"configHash"`
-	ImageTag      string    `json:"imageTag"`
-	SnapshotImage string    `json:"snapshotImage,omitempty"` // Saved snapshot image
-	IsPaused      bool      `json:"isPaused,omitempty"`      // Container was paused (snapshot saved)
-	Backend       string    `json:"backend,omitempty"`       // Which backend was used
+	ContainerID   string      `json:"containerId"`
+	ContainerName string      `json:"containerName"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	ConfigHash    string      `json:"configHash"`
+	ImageTag      string      `json:"imageTag"`
+	SnapshotImage string      `json:"snapshotImage,omitempty"` // Saved snapshot image
+	IsPaused      bool        `json:"isPaused,omitempty"`      // Container was paused (snapshot saved)
+	Backend       string      `json:"backend,omitempty"`       // Which backend was used
+	Sidecars      []string    `json:"sidecars,omitempty"`      // Docker access (dockerInDocker) and HTTPS proxy sidecars
+	Network       string      `json:"network,omitempty"`       // Network shared with the sidecars
+	Volumes       []string    `json:"volumes,omitempty"`       // Sidecar volumes removed with the container
+	LastHook      *HookResult `json:"lastHook,omitempty"`      // Result of the last lifecycle command
+
+	// Forwarded ports moved off busy host ports, requested -> used
+	RemappedPorts map[string]string `json:"remappedPorts,omitempty"`
 }
 
+// Labels set on persistent containers, so 'cm stats' can find them and the
+// project they belong to
+const (
+	LabelManaged = "cm.managed"
+	LabelProject = "cm.project"
+)
+
 // NewPersistentRunner creates a new persistent runner
 func NewPersistentRunner(cfg *config.DevContainerConfig, projectDir string) (*PersistentRunner, error) {
 	stateFile := filepath.Join(projectDir, ".devcontainer", ".cm-state.json")
@@ -77,13 +98,23 @@ func NewPersistentRunner(cfg *config.DevContainerConfig, projectDir string) (*Pe
 
 // GetContainerName returns the container name for this project
 func (r *PersistentRunner) GetContainerName() string {
-	projectName := filepath.Base(r.ProjectDir)
+	return projectContainerName(r.ProjectDir)
+}
+
+// projectContainerName returns the persistent container name of a project
+func projectContainerName(projectDir string) string {
+	projectName := filepath.Base(projectDir)
 	// Sanitize name for Docker
 	projectName = strings.ToLower(projectName)
 	projectName = strings.ReplaceAll(projectName, " ", "-")
 	return fmt.Sprintf("cm-%s-dev", projectName)
 }
 
+// WorkspaceDir returns where the project is mounted in the container
+func (r *PersistentRunner) WorkspaceDir() string {
+	return fmt.Sprintf("/workspaces/%s", filepath.Base(r.ProjectDir))
+}
+
 // GetSnapshotImageName returns the snapshot image name for this project
 func (r *PersistentRunner) GetSnapshotImageName() string {
 	return fmt.Sprintf("%s-snapshot:latest", r.GetContainerName())
@@ -92,6 +123,8 @@ func (r *PersistentRunner) GetSnapshotImageName() string {
 // CalculateConfigHash calculates a hash of the current configuration
 func (r *PersistentRunner) CalculateConfigHash() string {
 	data, _ := json.Marshal(r.Config)
+	// Edits to local features count as configuration changes
+	data = append(data, features.LocalFeaturesDigest(r.Config.Features, r.Config.ConfigDir)...)
 	hash := sha256.Sum256(data)
 	return fmt.Sprintf("%x", hash[:8])
 }
@@ -132,6 +165,7 @@ func (r *PersistentRunner) SaveState(state *ContainerState) error {
 
 // ClearState removes the state file
 func (r *PersistentRunner) ClearState() error {
+	ClearReadyMarker(r.ProjectDir)
 	return os.Remove(r.StateFile)
 }
 
@@ -157,6 +191,10 @@ func (r *PersistentRunner) getClient(_ context.Context) (*client.Client, error)
 
 // IsContainerRunning checks if the persistent container is running
 func (r *PersistentRunner) IsContainerRunning(ctx context.Context) (bool, string, error) {
+	if err := r.resolveContainer(ctx); err != nil {
+		return false, "", err
+	}
+
 	state, err := r.LoadState()
 	if err != nil {
 		return false, "", nil // No state file = no container
@@ -188,11 +226,35 @@ func (r *PersistentRunner) IsContainerRunning(ctx context.Context) (bool, string
 	return inspect.State.Running, state.ContainerID, nil
 }
 
-// EnsureContainer ensures a persistent container is running
+// EnsureContainer ensures a persistent container is running and marks it
+// ready for the 'cm exec' fast path
 func (r *PersistentRunner) EnsureContainer(ctx context.Context, rebuild bool) (string, error) {
+	containerID, err := r.ensureContainer(ctx, rebuild)
+	if err != nil {
+		ClearReadyMarker(r.ProjectDir)
+		return "", err
+	}
+	r.markReady(containerID)
+	return containerID, nil
+}
+
+func (r *PersistentRunner) ensureContainer(ctx context.Context, rebuild bool) (string, error) {
 	containerName := r.GetContainerName()
 	currentHash := r.CalculateConfigHash()
 
+	// A container paused to free memory is restored transparently
+	if !rebuild {
+		if state, err := r.LoadState(); err == nil && state.IsPaused && state.SnapshotImage != "" {
+			fmt.Printf("⏯️  Container '%s' was paused; resuming...\n", containerName)
+			containerID, err := r.restoreSnapshot(ctx, state)
+			if err != nil {
+				return "", err
+			}
+			fmt.Println("✅ Container restored from snapshot")
+			return containerID, nil
+		}
+	}
+
 	// Check if we have an existing container
 	running, containerID, err := r.IsContainerRunning(ctx)
 	if err != nil {
@@ -218,7 +280,9 @@ func (r *PersistentRunner) EnsureContainer(ctx context.Context, rebuild bool) (s
 		}
 	}
 
-	// Need to create or rebuild
+	// Need to create or rebuild; a cold start is timed until the container
+	// is ready, hooks included
+	coldStart := metrics.Start(metrics.KindColdStart, r.ProjectDir, containerName)
 	if containerID != "" {
 		fmt.Printf("🔄 Stopping existing container '%s'...\n", containerName)
 		if r.Runtime != nil {
@@ -230,22 +294,54 @@ func (r *PersistentRunner) EnsureContainer(ctx context.Context, rebuild bool) (s
 			_ = cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout})
 			_ = cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
 		}
+		if state, err := r.LoadState(); err == nil {
+			removeDockerAccess(ctx, r.getBackendCommand(), state.Sidecars, state.Network, state.Volumes)
+		}
 		_ = r.ClearState()
 	}
 
+	access, err := newDockerAccess(r.Config, r.getBackendCommand(), containerName, containerName)
+	if err != nil {
+		return "", err
+	}
+
 	// Resolve image
 	imageTag, err := r.resolveImage(ctx)
 	if err != nil {
 		return "", err
 	}
+	if wantsGPU(r.Config) {
+		if err := cudacheck.Check(ctx, r.getBackendCommand(), imageTag, os.Stdout); err != nil {
+			return "", err
+		}
+	}
+
+	var accessEnv, accessBinds []string
+	if access != nil {
+		if accessEnv, accessBinds, err = access.Start(ctx); err != nil {
+			return "", err
+		}
+	}
 
 	fmt.Printf("📦 Creating persistent container '%s' (backend: %s)...\n", containerName, r.Backend)
 
+	// Host time zone, locale and CA certificates
+	host := hostSetup(r.Config)
+
 	// Create container
-	containerID, err = r.createContainer(ctx, containerName, imageTag)
+	containerID, remapped, err := r.createContainer(ctx, containerName, imageTag, append(accessEnv, host.Env...), append(accessBinds, host.Binds...))
 	if err != nil {
+		if access != nil {
+			access.Cleanup(ctx)
+		}
 		return "", err
 	}
+	if access != nil {
+		if err := access.Connect(ctx, containerID); err != nil {
+			access.Cleanup(ctx)
+			return "", err
+		}
+	}
 
 	// Start container
 	if r.Runtime != nil {
@@ -266,21 +362,34 @@ func (r *PersistentRunner) EnsureContainer(ctx context.Context, rebuild bool) (s
 		ConfigHash:    currentHash,
 		ImageTag:      imageTag,
 		Backend:       r.Backend,
+		RemappedPorts: remapped,
+	}
+	if access != nil {
+		state.Sidecars = access.Sidecars()
+		state.Network = access.network
+		state.Volumes = access.Volumes()
 	}
+	state.Sidecars = append(state.Sidecars, startHTTPSProxyIfEnabled(ctx, r.getBackendCommand(), containerName, r.ProjectDir, containerID)...)
 	if err := r.SaveState(state); err != nil {
 		fmt.Printf("Warning: failed to save state: %v\n", err)
 	}
 
 	fmt.Printf("✅ Container '%s' started\n", containerName)
+	if access != nil {
+		access.checkDockerCLI(ctx, containerID)
+	}
+	installHostCAs(ctx, host, r.getBackendCommand(), containerID)
 
-	// Install DevContainer Features
-	if len(r.Config.Features) > 0 {
-		installer := NewFeatureInstaller(containerID, r.getBackendCommand())
-		if err := installer.InstallFeatures(ctx, r.Config.Features); err != nil {
+	// Install DevContainer Features, which prebuilt images have already
+	if len(r.Config.Features) > 0 && !r.prebuilt {
+		installer := NewFeatureInstaller(containerID, r.getBackendCommand(), r.Config.ConfigDir)
+		if err := installer.InstallFeatures(ctx, r.Config.Features, r.Config.OverrideFeatureInstallOrder); err != nil {
 			fmt.Printf("⚠️  Features installation failed: %v\n", err)
 		}
 	}
 
+	r.installToolVersions(ctx, containerID)
+
 	// Execute lifecycle commands
 	if err := r.runLifecycleCommand(ctx, containerID, "postCreateCommand", r.Config.PostCreateCommand); err != nil {
 		fmt.Printf("⚠️  postCreateCommand failed: %v\n", err)
@@ -289,11 +398,25 @@ func (r *PersistentRunner) EnsureContainer(ctx context.Context, rebuild bool) (s
 		fmt.Printf("⚠️  postStartCommand failed: %v\n", err)
 	}
 
+	coldStart(nil)
 	return containerID, nil
 }
 
-// resolveImage ensures the image is available (either by pulling or building)
+// resolveImage ensures the image is available: a prebuilt one if there is
+// one, or else by pulling or building
 func (r *PersistentRunner) resolveImage(ctx context.Context) (string, error) {
+	if err := hostreq.Check(ctx, r.Config.HostRequirements, r.getBackendCommand(), os.Stdout); err != nil {
+		return "", err
+	}
+	if image, ok := r.pullPrebuild(ctx); ok {
+		r.prebuilt = true
+		return image, nil
+	}
+	return r.resolveBaseImage(ctx)
+}
+
+// resolveBaseImage pulls the configured image or builds its Dockerfile
+func (r *PersistentRunner) resolveBaseImage(ctx context.Context) (string, error) {
 	// Check if we need to build from Dockerfile
 	if r.Config.Build != nil && r.Config.Build.Dockerfile != "" {
 		return r.buildImage(ctx)
@@ -305,10 +428,24 @@ func (r *PersistentRunner) resolveImage(ctx context.Context) (string, error) {
 	}
 
 	fmt.Printf("🔍 Checking image %s...\n", r.Config.Image)
+	if err := verify.Images(ctx, []string{r.Config.Image}, os.Stdout); err != nil {
+		return "", err
+	}
 
-	// Use runtime if available
+	// Docker API: pull with layer progress
+	if pm := r.pullManager(ctx); pm != nil {
+		if err := pm.PullAll(ctx, []string{r.Config.Image}); err != nil {
+			return "", err
+		}
+		return r.Config.Image, nil
+	}
+
+	// Other runtimes pull with their own CLI
 	if r.Runtime != nil {
 		if !r.Runtime.ImageExists(ctx, r.Config.Image) {
+			if offline.Enabled() {
+				return "", offline.Missing("image", r.Config.Image)
+			}
 			fmt.Printf("📥 Pulling image %s...\n", r.Config.Image)
 			if err := r.Runtime.PullImage(ctx, r.Config.Image); err != nil {
 				return "", fmt.Errorf("failed to pull image: %w", err)
@@ -318,50 +455,34 @@ func (r *PersistentRunner) resolveImage(ctx context.Context) (string, error) {
 		return r.Config.Image, nil
 	}
 
-	// Fallback to Docker client
-	cli, err := r.getClient(ctx)
-	if err != nil {
-		return "", err
-	}
-
-	_, _, err = cli.ImageInspectWithRaw(ctx, r.Config.Image)
-	if err != nil {
-		fmt.Printf("📥 Pulling image %s...\n", r.Config.Image)
-		reader, err := cli.ImagePull(ctx, r.Config.Image, image.PullOptions{})
-		if err != nil {
-			return "", fmt.Errorf("failed to pull image: %w", err)
-		}
-		defer reader.Close()
-
-		// Use beautiful progress display
-		progress := NewPullProgressDisplay()
-		_ = progress.ProcessPullOutput(reader)
-
-		fmt.Printf("✅ Successfully pulled %s\n", r.Config.Image)
-	}
-
 	return r.Config.Image, nil
 }
 
-// buildImage builds an image from Dockerfile
-func (r *PersistentRunner) buildImage(ctx context.Context) (string, error) {
-	dockerfile := r.Config.Build.Dockerfile
+// buildPaths returns the paths of the Dockerfile and the build context,
+// relative to .devcontainer or else to the project directory
+func (r *PersistentRunner) buildPaths() (dockerfilePath, contextPath string) {
 	buildContext := r.Config.Build.Context
 	if buildContext == "" {
 		buildContext = "."
 	}
 
-	// Resolve paths relative to project directory
-	dockerfilePath := filepath.Join(r.ProjectDir, ".devcontainer", dockerfile)
+	dockerfilePath = filepath.Join(r.ProjectDir, ".devcontainer", r.Config.Build.Dockerfile)
 	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
 		// Try relative to project root
-		dockerfilePath = filepath.Join(r.ProjectDir, dockerfile)
+		dockerfilePath = filepath.Join(r.ProjectDir, r.Config.Build.Dockerfile)
 	}
 
-	contextPath := filepath.Join(r.ProjectDir, ".devcontainer", buildContext)
+	contextPath = filepath.Join(r.ProjectDir, ".devcontainer", buildContext)
 	if _, err := os.Stat(contextPath); os.IsNotExist(err) {
 		contextPath = filepath.Join(r.ProjectDir, buildContext)
 	}
+	return dockerfilePath, contextPath
+}
+
+// buildImage builds an image from Dockerfile
+func (r *PersistentRunner) buildImage(ctx context.Context) (string, error) {
+	dockerfile := r.Config.Build.Dockerfile
+	dockerfilePath, contextPath := r.buildPaths()
 
 	// Generate image tag
 	imageTag := fmt.Sprintf("cm-%s:latest", r.GetContainerName())
@@ -370,22 +491,48 @@ func (r *PersistentRunner) buildImage(ctx context.Context) (string, error) {
 	fmt.Printf("   Context: %s\n", contextPath)
 	fmt.Printf("   Tag: %s\n", imageTag)
 
+	if err := checkOfflineBuild(ctx, r.getBackendCommand(), dockerfilePath, r.Config.Build.Args); err != nil {
+		return "", err
+	}
+	if err := verifyBuildImages(ctx, dockerfilePath, r.Config.Build.Args); err != nil {
+		return "", err
+	}
+	prePullBaseImages(ctx, r.pullManager(ctx), dockerfilePath, r.Config.Build.Args)
+
 	// Build using docker CLI for better output
 	args := []string{"build", "-t", imageTag, "-f", dockerfilePath}
+	for k, v := range r.containerLabels() {
+		args = append(args, "--label", k+"="+v)
+	}
 
 	// Add build args
 	for k, v := range r.Config.Build.Args {
 		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, v))
 	}
+	if r.Config.Build.Target != "" {
+		args = append(args, "--target", r.Config.Build.Target)
+	}
 
 	args = append(args, contextPath)
 
 	cmd := exec.CommandContext(ctx, r.getBackendCommand(), args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	var tail *tailBuffer
+	var done func(error)
+	cmd.Stdout, cmd.Stderr, tail, done = captureBuildOutput(r.ProjectDir, imageTag)
 	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
 
-	if err := cmd.Run(); err != nil {
+	err := cmd.Run()
+	done(err)
+	if err != nil {
+		recordFailure(r.ProjectDir, Failure{
+			Kind:       "build",
+			Name:       imageTag,
+			Command:    r.getBackendCommand() + " " + strings.Join(args, " "),
+			Error:      err.Error(),
+			Output:     tail.String(),
+			Dockerfile: dockerfilePath,
+			ConfigDir:  r.Config.ConfigDir,
+		})
 		return "", fmt.Errorf("failed to build image: %w", err)
 	}
 
@@ -422,10 +569,22 @@ func (r *PersistentRunner) runLifecycleCommand(ctx context.Context, containerID,
 	// Execute command in container
 	backendCmd := r.getBackendCommand()
 	execCmd := exec.CommandContext(ctx, backendCmd, "exec", containerID, "sh", "-c", cmdStr)
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
+	var tail *tailBuffer
+	execCmd.Stdout, execCmd.Stderr, tail = captureOutput()
 
-	if err := execCmd.Run(); err != nil {
+	done := metrics.Start(metrics.KindHook, r.ProjectDir, cmdName)
+	err := execCmd.Run()
+	done(err)
+	r.recordHook(cmdName, err)
+	if err != nil {
+		recordFailure(r.ProjectDir, Failure{
+			Kind:      "hook",
+			Name:      cmdName,
+			Command:   cmdStr,
+			Error:     err.Error(),
+			Output:    tail.String(),
+			ConfigDir: r.Config.ConfigDir,
+		})
 		return fmt.Errorf("%s failed: %w", cmdName, err)
 	}
 
@@ -433,13 +592,25 @@ func (r *PersistentRunner) runLifecycleCommand(ctx context.Context, containerID,
 	return nil
 }
 
-// createContainer creates a new persistent container
-func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag string) (string, error) {
+// createContainer creates a new persistent container, returning its ID and
+// the forwarded ports moved off busy host ports
+func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag string, extraEnv, extraBinds []string) (string, map[string]string, error) {
 	// Setup workspace mount
 	cwd, _ := os.Getwd()
-	projectName := filepath.Base(r.ProjectDir)
-	workspaceDir := fmt.Sprintf("/workspaces/%s", projectName)
-	workspaceBind := fmt.Sprintf("%s:%s", cwd, workspaceDir)
+	workspaceDir := r.WorkspaceDir()
+	workspaceBind, err := r.hostPaths().Bind(cwd, workspaceDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Busy host ports are skipped or remapped (see resolvePorts)
+	ports, remapped := resolvePorts(r.Config.PortSpecs(), r.ProjectDir)
+	extraEnv = append(extraEnv, portEnv(ports)...)
+
+	devicePlan, err := resolveDevices(r.Config, r.backendType())
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Use runtime if available
 	if r.Runtime != nil {
@@ -449,7 +620,8 @@ func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag s
 			WorkingDir: workspaceDir,
 			Tty:        true,
 			OpenStdin:  true,
-			Binds:      append([]string{workspaceBind}, r.Config.Mounts...),
+			Binds:      append(append([]string{workspaceBind}, r.Config.Mounts...), extraBinds...),
+			Labels:     r.containerLabels(),
 		}
 
 		// Add environment variables
@@ -459,34 +631,34 @@ func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag s
 		for k, v := range r.Config.RemoteEnv {
 			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", k, v))
 		}
+		cfg.Env = append(cfg.Env, extraEnv...)
 
 		// Parse runArgs for GPU and other settings
 		if len(r.Config.RunArgs) > 0 {
 			applyRunArgsToRuntimeConfig(r.Config.RunArgs, cfg)
 		}
+		applyRuntimeDevices(devicePlan, cfg)
+		if err := applyRuntimeNameResolution(r.Config, cfg); err != nil {
+			return "", nil, err
+		}
 
-		// Add port bindings from forwardPorts
+		// Add port bindings from forwardPorts and appPort
 		cfg.PortBindings = make(map[string][]runtime.PortBinding)
-		for _, p := range r.Config.ForwardPorts {
-			var port string
-			switch v := p.(type) {
-			case float64:
-				port = fmt.Sprintf("%d", int(v))
-			case string:
-				port = v
-			default:
-				continue
-			}
-			portProto := port + "/tcp"
-			cfg.PortBindings[portProto] = []runtime.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: port},
+		for _, p := range ports {
+			cfg.PortBindings[p.ContainerPort+"/"+p.Protocol] = []runtime.PortBinding{
+				{HostIP: "0.0.0.0", HostPort: p.HostPort},
 			}
 		}
 		if len(cfg.PortBindings) > 0 {
-			fmt.Printf("🔌 Forwarding ports: %v\n", r.Config.ForwardPorts)
+			fmt.Printf("🔌 Forwarding ports: %s\n", portList(ports))
+		}
+		if publishesAllPorts(r.Config) {
+			cfg.PublishAllPorts = true
+			fmt.Printf("🔌 Publishing all exposed ports (see '%s port %s')\n", r.getBackendCommand(), r.GetContainerName())
 		}
 
-		return r.Runtime.CreateContainer(ctx, cfg)
+		id, err := r.Runtime.CreateContainer(ctx, cfg)
+		return id, remapped, err
 	}
 
 	// Fallback to Docker client
@@ -496,36 +668,36 @@ func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag s
 
 	// Add mounts from config
 	hostConfig.Binds = append(hostConfig.Binds, r.Config.Mounts...)
+	hostConfig.Binds = append(hostConfig.Binds, extraBinds...)
 
 	// Apply runArgs to hostConfig (for GPU, shm-size, etc.)
 	if len(r.Config.RunArgs) > 0 {
 		if err := parseRunArgs(r.Config.RunArgs, hostConfig, &container.Config{}); err != nil {
-			return "", fmt.Errorf("failed to parse runArgs: %w", err)
+			return "", nil, fmt.Errorf("failed to parse runArgs: %w", err)
 		}
 	}
+	applyDevices(devicePlan, hostConfig)
+	if err := applyNameResolution(r.Config, hostConfig); err != nil {
+		return "", nil, err
+	}
 
-	// Add port bindings from forwardPorts
+	// Add port bindings from forwardPorts and appPort
 	portBindings := nat.PortMap{}
 	exposedPorts := nat.PortSet{}
-	for _, p := range r.Config.ForwardPorts {
-		var port string
-		switch v := p.(type) {
-		case float64:
-			port = fmt.Sprintf("%d", int(v))
-		case string:
-			port = v
-		default:
-			continue
-		}
-		containerPort := nat.Port(port + "/tcp")
-		exposedPorts[containerPort] = struct{}{}
-		portBindings[containerPort] = []nat.PortBinding{
-			{HostIP: "0.0.0.0", HostPort: port},
+	for _, p := range ports {
+		port := nat.Port(p.ContainerPort + "/" + p.Protocol)
+		exposedPorts[port] = struct{}{}
+		portBindings[port] = []nat.PortBinding{
+			{HostIP: "0.0.0.0", HostPort: p.HostPort},
 		}
 	}
 	if len(portBindings) > 0 {
 		hostConfig.PortBindings = portBindings
-		fmt.Printf("🔌 Forwarding ports: %v\n", r.Config.ForwardPorts)
+		fmt.Printf("🔌 Forwarding ports: %s\n", portList(ports))
+	}
+	if publishesAllPorts(r.Config) {
+		hostConfig.PublishAllPorts = true
+		fmt.Printf("🔌 Publishing all exposed ports (see 'docker port %s')\n", r.GetContainerName())
 	}
 
 	containerConfig := &container.Config{
@@ -535,6 +707,7 @@ func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag s
 		Tty:          true,
 		OpenStdin:    true,
 		ExposedPorts: exposedPorts,
+		Labels:       r.containerLabels(),
 	}
 
 	// Add environment variables
@@ -544,18 +717,43 @@ func (r *PersistentRunner) createContainer(ctx context.Context, name, imageTag s
 	for k, v := range r.Config.RemoteEnv {
 		containerConfig.Env = append(containerConfig.Env, fmt.Sprintf("%s=%s", k, v))
 	}
+	containerConfig.Env = append(containerConfig.Env, extraEnv...)
 
 	cli, err := r.getClient(ctx)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	resp, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
+		return "", nil, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	return resp.ID, remapped, nil
+}
+
+// containerLabels returns the labels of the persistent container
+func (r *PersistentRunner) containerLabels() map[string]string {
+	projectDir, err := filepath.Abs(r.ProjectDir)
+	if err != nil {
+		projectDir = r.ProjectDir
 	}
+	return map[string]string{LabelManaged: "true", LabelProject: projectDir}
+}
 
-	return resp.ID, nil
+// pullManager returns a pull manager when the backend speaks the Docker
+// API, or nil
+func (r *PersistentRunner) pullManager(ctx context.Context) *PullManager {
+	if r.Runtime != nil {
+		if _, ok := r.Runtime.(*runtime.DockerRuntime); !ok {
+			return nil
+		}
+	}
+	cli, err := r.getClient(ctx)
+	if err != nil {
+		return nil
+	}
+	return NewPullManager(cli)
 }
 
 // getBackendCommand returns the CLI command for the current backend
@@ -566,11 +764,35 @@ func (r *PersistentRunner) getBackendCommand() string {
 			return "podman"
 		case "nerdctl":
 			return "nerdctl"
+		case "wsl":
+			return r.Runtime.Path() // Shim running the engine inside the distribution
 		}
 	}
 	return "docker"
 }
 
+// backendType returns the type of the backend: "docker", "podman",
+// "nerdctl" or "wsl"
+func (r *PersistentRunner) backendType() string {
+	if r.Runtime != nil {
+		return r.Runtime.Type()
+	}
+	return "docker"
+}
+
+// hostPaths translates host paths into bind mount sources for the backend
+func (r *PersistentRunner) hostPaths() *hostpath.Translator {
+	if r.Runtime != nil && r.Runtime.Type() == "wsl" {
+		return hostpath.New(r.Runtime.Name())
+	}
+	return hostpath.New(r.getBackendCommand())
+}
+
+// BackendCommand returns the CLI binary used to talk to the container backend
+func (r *PersistentRunner) BackendCommand() string {
+	return r.getBackendCommand()
+}
+
 // Shell enters an interactive shell in the persistent container
 func (r *PersistentRunner) Shell(ctx context.Context) error {
 	containerID, err := r.EnsureContainer(ctx, false)
@@ -578,6 +800,8 @@ func (r *PersistentRunner) Shell(ctx context.Context) error {
 		return err
 	}
 
+	defer watchDevices(ctx, r.Config, r.backendType(), r.getBackendCommand(), containerID)()
+
 	fmt.Println("🚀 Entering shell...")
 
 	// Use the appropriate backend command for interactive shell
@@ -598,6 +822,7 @@ func (r *PersistentRunner) Exec(ctx context.Context, command []string) error {
 	}
 
 	isTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+	defer watchDevices(ctx, r.Config, r.backendType(), r.getBackendCommand(), containerID)()
 
 	// Use runtime if available
 	if r.Runtime != nil {
@@ -615,44 +840,11 @@ func (r *PersistentRunner) Exec(ctx context.Context, command []string) error {
 		return err
 	}
 
-	execConfig := container.ExecOptions{
-		Cmd:          command,
-		AttachStdout: true,
-		AttachStderr: true,
-		AttachStdin:  isTerminal,
-		Tty:          isTerminal,
-	}
-
-	execResp, err := cli.ContainerExecCreate(ctx, containerID, execConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create exec: %w", err)
-	}
-
-	attachResp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{
-		Tty: isTerminal,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to attach exec: %w", err)
-	}
-	defer attachResp.Close()
-
-	// Stream output
-	if isTerminal {
-		go func() { _, _ = io.Copy(attachResp.Conn, os.Stdin) }()
-	}
-	_, _ = io.Copy(os.Stdout, attachResp.Reader)
-
-	// Get exit code
-	inspectResp, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	execID, err := createExec(ctx, cli, containerID, command, isTerminal)
 	if err != nil {
-		return nil // Ignore inspect errors
-	}
-
-	if inspectResp.ExitCode != 0 {
-		return fmt.Errorf("command exited with code %d", inspectResp.ExitCode)
+		return err
 	}
-
-	return nil
+	return runExec(ctx, cli, execID, isTerminal)
 }
 
 // Stop stops and removes the persistent container
@@ -687,6 +879,7 @@ func (r *PersistentRunner) Stop(ctx context.Context) error {
 		}
 	}
 
+	removeDockerAccess(ctx, r.getBackendCommand(), state.Sidecars, state.Network, state.Volumes)
 	_ = r.ClearState()
 	fmt.Printf("✅ Container '%s' stopped and removed\n", containerName)
 	return nil
@@ -766,14 +959,18 @@ func (r *PersistentRunner) Pause(ctx context.Context) error {
 		_ = cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
 	}
 
+	removeDockerAccess(ctx, r.getBackendCommand(), state.Sidecars, state.Network, state.Volumes)
+
 	// Update state
+	ClearReadyMarker(r.ProjectDir)
 	state.SnapshotImage = snapshotImage
 	state.IsPaused = true
 	state.ContainerID = ""
+	state.Sidecars, state.Network, state.Volumes = nil, "", nil
 	_ = r.SaveState(state)
 
 	fmt.Println("✅ Container paused. Memory freed.")
-	fmt.Println("   Use 'cm shell --resume' to restore your environment.")
+	fmt.Println("   The next 'cm shell' or 'cm exec' restores your environment.")
 	return nil
 }
 
@@ -789,29 +986,76 @@ func (r *PersistentRunner) Resume(ctx context.Context) error {
 		return r.Shell(ctx)
 	}
 
+	containerID, err := r.restoreSnapshot(ctx, state)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✅ Container restored from snapshot!")
+	defer watchDevices(ctx, r.Config, r.backendType(), r.getBackendCommand(), containerID)()
+
+	fmt.Println("🚀 Entering shell...")
+
+	// Enter shell
+	backendCmd := r.getBackendCommand()
+	cmd := exec.CommandContext(ctx, backendCmd, "exec", "-it", containerID, "/bin/sh")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// restoreSnapshot recreates a paused container from its snapshot image and
+// starts it
+func (r *PersistentRunner) restoreSnapshot(ctx context.Context, state *ContainerState) (string, error) {
 	// Check if snapshot image exists
 	if r.Runtime != nil {
 		if !r.Runtime.ImageExists(ctx, state.SnapshotImage) {
-			return fmt.Errorf("snapshot image not found: %s", state.SnapshotImage)
+			return "", fmt.Errorf("snapshot image not found: %s", state.SnapshotImage)
 		}
 	} else {
 		cli, err := r.getClient(ctx)
 		if err != nil {
-			return err
+			return "", err
 		}
 		_, _, err = cli.ImageInspectWithRaw(ctx, state.SnapshotImage)
 		if err != nil {
-			return fmt.Errorf("snapshot image not found: %s", state.SnapshotImage)
+			return "", fmt.Errorf("snapshot image not found: %s", state.SnapshotImage)
 		}
 	}
 
 	containerName := r.GetContainerName()
 	fmt.Printf("📦 Restoring container from snapshot '%s'...\n", state.SnapshotImage)
 
-	// Create container from snapshot image
-	containerID, err := r.createContainer(ctx, containerName, state.SnapshotImage)
+	access, err := newDockerAccess(r.Config, r.getBackendCommand(), containerName, containerName)
+	if err != nil {
+		return "", err
+	}
+	var accessEnv, accessBinds []string
+	if access != nil {
+		if accessEnv, accessBinds, err = access.Start(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	// Create container from snapshot image; it has the CA certificates
+	// installed already
+	host := hostSetup(r.Config)
+	containerID, remapped, err := r.createContainer(ctx, containerName, state.SnapshotImage, append(accessEnv, host.Env...), append(accessBinds, host.Binds...))
 	if err != nil {
-		return fmt.Errorf("failed to create container from snapshot: %w", err)
+		if access != nil {
+			access.Cleanup(ctx)
+		}
+		return "", fmt.Errorf("failed to create container from snapshot: %w", err)
+	}
+	if access != nil {
+		if err := access.Connect(ctx, containerID); err != nil {
+			access.Cleanup(ctx)
+			return "", err
+		}
+		state.Sidecars = access.Sidecars()
+		state.Network = access.network
+		state.Volumes = access.Volumes()
 	}
 
 	// Start container
@@ -822,24 +1066,18 @@ func (r *PersistentRunner) Resume(ctx context.Context) error {
 		err = cli.ContainerStart(ctx, containerID, container.StartOptions{})
 	}
 	if err != nil {
-		return fmt.Errorf("failed to start container: %w", err)
+		return "", fmt.Errorf("failed to start container: %w", err)
 	}
 
+	state.Sidecars = append(state.Sidecars, startHTTPSProxyIfEnabled(ctx, r.getBackendCommand(), containerName, r.ProjectDir, containerID)...)
+
 	// Update state
 	state.ContainerID = containerID
 	state.IsPaused = false
+	state.RemappedPorts = remapped
 	_ = r.SaveState(state)
 
-	fmt.Println("✅ Container restored from snapshot!")
-	fmt.Println("🚀 Entering shell...")
-
-	// Enter shell
-	backendCmd := r.getBackendCommand()
-	cmd := exec.CommandContext(ctx, backendCmd, "exec", "-it", containerID, "/bin/sh")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return containerID, nil
 }
 
 // applyRunArgsToRuntimeConfig parses runArgs and applies GPU/shm settings to runtime.ContainerConfig
@@ -858,8 +1096,8 @@ func applyRunArgsToRuntimeConfig(runArgs []string, cfg *runtime.ContainerConfig)
 		switch arg {
 		case "--gpus":
 			val := getValue()
-			if val == "" {
-				continue
+			if val == "" || gpusAsDevices() {
+				continue // AMD and Intel GPUs are given as devices, see gpuDevices
 			}
 			// Handle GPU access
 			if val == "all" {
@@ -910,6 +1148,26 @@ func applyRunArgsToRuntimeConfig(runArgs []string, cfg *runtime.ContainerConfig)
 			if val != "" {
 				cfg.SecurityOpt = append(cfg.SecurityOpt, val)
 			}
+
+		case "--add-host":
+			if val := getValue(); val != "" {
+				cfg.ExtraHosts = append(cfg.ExtraHosts, val)
+			}
+
+		case "--dns":
+			if val := getValue(); val != "" {
+				cfg.DNS = append(cfg.DNS, val)
+			}
+
+		case "--dns-search":
+			if val := getValue(); val != "" {
+				cfg.DNSSearch = append(cfg.DNSSearch, val)
+			}
+
+		case "--dns-option", "--dns-opt":
+			if val := getValue(); val != "" {
+				cfg.DNSOptions = append(cfg.DNSOptions, val)
+			}
 		}
 	}
 }
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// remapBusyPorts is set by --remap-ports
+var remapBusyPorts bool
+
+// RemapBusyPorts forwards ports whose host port is taken to a free host port
+// instead of skipping them
+func RemapBusyPorts() {
+	remapBusyPorts = true
+}
+
+// remapEnabled reports whether busy ports are remapped, via --remap-ports or
+// 'cm config set ports.remap true'
+func remapEnabled() bool {
+	if remapBusyPorts {
+		return true
+	}
+	cfg, err := userconfig.Load()
+	return err == nil && cfg.Ports.Remap
+}
+
+// maxRemapAttempts bounds the search for a free host port
+const maxRemapAttempts = 50
+
+// forwardedPort is a port published on the host
+type forwardedPort struct {
+	Requested     string // Host port asked for in devcontainer.json
+	HostPort      string // Host port actually used
+	ContainerPort string
+	Protocol      string
+}
+
+// portEnvVar names the variable holding the host port used for a requested
+// port, e.g. CM_PORT_8080
+func portEnvVar(requested string) string {
+	return "CM_PORT_" + requested
+}
+
+// portOffset is the project's offset for remapped ports. It depends only on
+// the project directory, so a busy port maps to the same replacement every
+// time while different projects spread over different ports.
+func portOffset(projectDir string) int {
+	if abs, err := filepath.Abs(projectDir); err == nil {
+		projectDir = abs
+	}
+	h := fnv.New32a()
+	h.Write([]byte(projectDir))
+	return int(h.Sum32()%100) + 1
+}
+
+// resolvePorts decides the host port of each port spec. A busy host port is
+// skipped with a warning or, when remapping is enabled, moved to the first
+// free port from the requested port plus the project's offset. remapped maps
+// requested ports, as port/protocol, to the host ports used.
+func resolvePorts(specs []string, projectDir string) (ports []forwardedPort, remapped map[string]string) {
+	remapped = map[string]string{}
+	taken := map[string]bool{}
+	offset := portOffset(projectDir)
+	remap := remapEnabled()
+
+	for _, spec := range specs {
+		hostPort, containerPort, protocol := parsePortSpec(spec)
+		p := forwardedPort{Requested: hostPort, HostPort: hostPort, ContainerPort: containerPort, Protocol: protocol}
+
+		if taken[hostPort+"/"+protocol] || isPortInUse(hostPort, protocol) {
+			free, ok := "", false
+			if remap {
+				free, ok = freeHostPort(hostPort, protocol, offset, taken)
+			}
+			if !ok {
+				fmt.Printf("⚠️  Port %s/%s is already in use on the host, skipping\n", hostPort, protocol)
+				continue
+			}
+			fmt.Printf("🔀 Port %s/%s is in use, forwarding %s -> %s/%s instead (%s=%s)\n",
+				hostPort, protocol, free, containerPort, protocol, portEnvVar(hostPort), free)
+			p.HostPort = free
+			remapped[hostPort+"/"+protocol] = free
+		}
+
+		taken[p.HostPort+"/"+protocol] = true
+		ports = append(ports, p)
+	}
+	return ports, remapped
+}
+
+// freeHostPort finds a free host port at port+offset or after it
+func freeHostPort(port, protocol string, offset int, taken map[string]bool) (string, bool) {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return "", false // Ranges and named ports are not remapped
+	}
+	for i := 0; i < maxRemapAttempts; i++ {
+		candidate := n + offset + i
+		if candidate > 65535 {
+			break
+		}
+		s := strconv.Itoa(candidate)
+		if !taken[s+"/"+protocol] && !isPortInUse(s, protocol) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// portList formats forwarded ports for display, e.g. "3000, 8081->8080"
+func portList(ports []forwardedPort) string {
+	s := ""
+	for i, p := range ports {
+		if i > 0 {
+			s += ", "
+		}
+		if p.HostPort == p.ContainerPort {
+			s += p.HostPort
+		} else {
+			s += p.HostPort + "->" + p.ContainerPort
+		}
+		if p.Protocol != "tcp" {
+			s += "/" + p.Protocol
+		}
+	}
+	return s
+}
+
+// portEnv exports the host port of every forwarded port as CM_PORT_<port>
+func portEnv(ports []forwardedPort) []string {
+	var env []string
+	seen := map[string]bool{}
+	for _, p := range ports {
+		if seen[p.Requested] {
+			continue
+		}
+		seen[p.Requested] = true
+		env = append(env, portEnvVar(p.Requested)+"="+p.HostPort)
+	}
+	return env
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// busyPort listens on a free TCP port until the test ends and returns it
+func busyPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// freePort returns a TCP port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestPortOffset(t *testing.T) {
+	for _, dir := range []string{"/home/me/api", "/home/me/web", "/", ""} {
+		offset := portOffset(dir)
+		if offset < 1 || offset > 100 {
+			t.Errorf("portOffset(%q) = %d, want 1-100", dir, offset)
+		}
+		if portOffset(dir) != offset {
+			t.Errorf("portOffset(%q) isn't stable", dir)
+		}
+	}
+
+	// The same project gets the same offset however its path is written
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portOffset(".") != portOffset(cwd) || portOffset(cwd+"/") != portOffset(cwd) {
+		t.Error("relative and absolute paths of a project get different offsets")
+	}
+}
+
+func TestFreeHostPort(t *testing.T) {
+	busy := busyPort(t)
+	tests := []struct {
+		name   string
+		port   string
+		offset int
+		taken  map[string]bool
+		want   string // "" when no port is found
+	}{
+		{"named port", "http", 10, nil, ""},
+		{"range", "8000-8010", 10, nil, ""},
+		{"past the last port", "65530", 10, nil, ""},
+		{"offset skips a busy port", strconv.Itoa(busy - 1), 1, nil, strconv.Itoa(busy + 1)},
+		{"offset skips a taken port", strconv.Itoa(busy - 1), 2, map[string]bool{strconv.Itoa(busy+1) + "/tcp": true}, strconv.Itoa(busy + 2)},
+	}
+	for _, tt := range tests {
+		taken := tt.taken
+		if taken == nil {
+			taken = map[string]bool{}
+		}
+		got, ok := freeHostPort(tt.port, "tcp", tt.offset, taken)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: freeHostPort(%s, +%d) = %q, %v, want %q", tt.name, tt.port, tt.offset, got, ok, tt.want)
+		}
+	}
+}
+
+func TestResolvePorts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func() { remapBusyPorts = false }()
+	busy := strconv.Itoa(busyPort(t))
+	free := strconv.Itoa(freePort(t))
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		remap    bool
+		specs    []string
+		kept     []string // Requested ports forwarded, in order
+		remapped []string // Keys of the remapped ports
+	}{
+		{"free ports are kept", false, []string{free, free + "/udp"}, []string{free, free}, nil},
+		{"busy ports are skipped", false, []string{busy, free}, []string{free}, nil},
+		{"busy ports are remapped", true, []string{busy, free}, []string{busy, free}, []string{busy + "/tcp"}},
+		{"a port used twice is remapped", true, []string{free, free + ":9000"}, []string{free, free}, []string{free + "/tcp"}},
+		{"protocols are remapped apart", true, []string{busy, busy + "/udp"}, []string{busy, busy}, []string{busy + "/tcp"}},
+	}
+	for _, tt := range tests {
+		remapBusyPorts = tt.remap
+		ports, remapped := resolvePorts(tt.specs, dir)
+
+		kept := []string{}
+		moved := map[string]string{}
+		for _, p := range ports {
+			kept = append(kept, p.Requested)
+			if p.HostPort != p.Requested {
+				moved[p.Requested+"/"+p.Protocol] = p.HostPort
+			}
+		}
+		if !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("%s: forwarded %v, want %v", tt.name, kept, tt.kept)
+		}
+		if !reflect.DeepEqual(remapped, moved) {
+			t.Errorf("%s: remapped %v, but moved %v", tt.name, remapped, moved)
+		}
+		var keys []string
+		for key := range remapped {
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, tt.remapped) {
+			t.Errorf("%s: remapped %v, want %v", tt.name, keys, tt.remapped)
+		}
+	}
+}
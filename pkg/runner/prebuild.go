@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/features"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+)
+
+// LabelPrebuildHash is set on prebuilt images to the prebuild hash of the
+// configuration they were built from
+const LabelPrebuildHash = "cm.prebuild-hash"
+
+// PrebuildLookup returns the prebuilt image of a prebuild hash, or "" if
+// there is none. The CLI sets it when signed in to a control plane; without
+// it images are always pulled or built here.
+var PrebuildLookup func(ctx context.Context, hash string) (string, error)
+
+// PrebuildHash hashes what goes into a project's image: the image or the
+// Dockerfile's contents with its build args and target, and the features
+// with their options, local ones by their contents. Files the Dockerfile
+// copies from its build context aren't hashed.
+func (r *PersistentRunner) PrebuildHash() (string, error) {
+	inputs := struct {
+		Image        string                 `json:"image,omitempty"`
+		Dockerfile   string                 `json:"dockerfile,omitempty"`
+		BuildArgs    map[string]string      `json:"buildArgs,omitempty"`
+		Target       string                 `json:"target,omitempty"`
+		Features     map[string]interface{} `json:"features,omitempty"`
+		InstallOrder []string               `json:"installOrder,omitempty"`
+		Local        string                 `json:"local,omitempty"`
+	}{
+		Image:        r.Config.Image,
+		Features:     r.Config.Features,
+		InstallOrder: r.Config.OverrideFeatureInstallOrder,
+		Local:        features.LocalFeaturesDigest(r.Config.Features, r.Config.ConfigDir),
+	}
+	if r.Config.Build != nil && r.Config.Build.Dockerfile != "" {
+		dockerfilePath, _ := r.buildPaths()
+		data, err := os.ReadFile(dockerfilePath)
+		if err != nil {
+			return "", err
+		}
+		inputs.Image = ""
+		inputs.Dockerfile = string(data)
+		inputs.BuildArgs = r.Config.Build.Args
+		inputs.Target = r.Config.Build.Target
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash[:16]), nil
+}
+
+// wantsPrebuild reports whether building the project's image takes more
+// than pulling one, so a prebuilt image saves time
+func (r *PersistentRunner) wantsPrebuild() bool {
+	return len(r.Config.Features) > 0 || (r.Config.Build != nil && r.Config.Build.Dockerfile != "")
+}
+
+// pullPrebuild pulls the prebuilt image of the project's configuration, if
+// there is one. Failing to find or pull it isn't an error: the image is
+// then built here as usual.
+func (r *PersistentRunner) pullPrebuild(ctx context.Context) (string, bool) {
+	if PrebuildLookup == nil || offline.Enabled() || !r.wantsPrebuild() {
+		return "", false
+	}
+	hash, err := r.PrebuildHash()
+	if err != nil {
+		return "", false
+	}
+	image, err := PrebuildLookup(ctx, hash)
+	if err != nil {
+		fmt.Printf("⚠️  Couldn't look up a prebuilt image: %v\n", err)
+		return "", false
+	}
+	if image == "" {
+		return "", false
+	}
+
+	fmt.Printf("⚡ Using prebuilt image %s\n", image)
+	if pm := r.pullManager(ctx); pm != nil {
+		err = pm.PullAll(ctx, []string{image})
+	} else {
+		err = exec.CommandContext(ctx, r.getBackendCommand(), "pull", image).Run()
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to pull the prebuilt image, building instead: %v\n", err)
+		return "", false
+	}
+	return image, true
+}
+
+// Prebuild builds the project's image with its features installed, tagged
+// with its prebuild hash, and pushes it to repository if given. It returns
+// the image's tag.
+func (r *PersistentRunner) Prebuild(ctx context.Context, repository string) (string, error) {
+	hash, err := r.PrebuildHash()
+	if err != nil {
+		return "", err
+	}
+	tag := fmt.Sprintf("cm-%s-prebuild:%s", r.GetContainerName(), hash)
+	if repository != "" {
+		tag = repository + ":" + hash
+	}
+
+	base, err := r.resolveBaseImage(ctx)
+	if err != nil {
+		return "", err
+	}
+	backend := r.getBackendCommand()
+	image := base
+	if len(r.Config.Features) > 0 {
+		// Features are installed into a container of the image, which is
+		// then committed with the image's own command
+		out, err := exec.CommandContext(ctx, backend, "inspect", "--format", "{{json .Config.Cmd}}", base).Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect %s: %w", base, err)
+		}
+		cmd := strings.TrimSpace(string(out))
+		if cmd == "null" {
+			cmd = "[]"
+		}
+
+		name := fmt.Sprintf("cm-prebuild-%s", hash[:12])
+		_ = exec.CommandContext(ctx, backend, "rm", "-f", name).Run()
+		if out, err := exec.CommandContext(ctx, backend, "run", "-d", "--name", name, base, "sleep", "infinity").CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to start a container of %s: %s", base, bytes.TrimSpace(out))
+		}
+		defer func() { _ = exec.Command(backend, "rm", "-f", name).Run() }()
+
+		installer := NewFeatureInstaller(name, backend, r.Config.ConfigDir)
+		if err := installer.InstallFeatures(ctx, r.Config.Features, r.Config.OverrideFeatureInstallOrder); err != nil {
+			return "", fmt.Errorf("failed to install features: %w", err)
+		}
+		if out, err := exec.CommandContext(ctx, backend, "commit", "--change", "CMD "+cmd, name, tag).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to commit the image: %s", bytes.TrimSpace(out))
+		}
+		image = tag
+	}
+
+	// The label lets the image be told apart from others of the project
+	labeled := exec.CommandContext(ctx, backend, "build", "-t", tag, "--label", LabelPrebuildHash+"="+hash, "-")
+	labeled.Stdin = strings.NewReader("FROM " + image + "\n")
+	if out, err := labeled.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to label the image: %s", bytes.TrimSpace(out))
+	}
+	fmt.Printf("✅ Prebuilt %s\n", tag)
+
+	if repository != "" {
+		fmt.Printf("📤 Pushing %s...\n", tag)
+		push := exec.CommandContext(ctx, backend, "push", tag)
+		push.Stdout, push.Stderr = os.Stdout, os.Stderr
+		if err := push.Run(); err != nil {
+			return "", fmt.Errorf("failed to push %s: %w", tag, err)
+		}
+	}
+	return tag, nil
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/docker/docker/client"
+)
+
+// promptInspectTimeout bounds the container check of 'cm prompt', which
+// runs on every shell prompt
+const promptInspectTimeout = 300 * time.Millisecond
+
+// PromptInfo is what 'cm prompt' shows about the current directory
+type PromptInfo struct {
+	Project  string `json:"project"`
+	Dir      string `json:"dir"`
+	State    string `json:"state"`    // running, stopped, paused, none or unknown
+	OutDated bool   `json:"outdated"` // devcontainer.json changed since the container was created
+}
+
+// Segment renders the compact prompt indicator, e.g. "⬢ my-app" or
+// "⏸ my-app ⇡"
+func (p *PromptInfo) Segment() string {
+	icon := map[string]string{
+		"running": "⬢",
+		"stopped": "⬡",
+		"paused":  "⏸",
+		"none":    "◌",
+	}[p.State]
+	if icon == "" {
+		icon = "?"
+	}
+	s := icon + " " + p.Project
+	if p.OutDated {
+		s += " ⇡"
+	}
+	return s
+}
+
+// FindProject walks up from dir to the nearest directory with a
+// devcontainer.json, returning the project directory and config path
+func FindProject(dir string) (projectDir, configPath string, ok bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", false
+	}
+	for {
+		for _, rel := range []string{filepath.Join(".devcontainer", "devcontainer.json"), "devcontainer.json"} {
+			path := filepath.Join(dir, rel)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if filepath.Base(dir) == ".devcontainer" {
+				// Inside .devcontainer itself
+				return filepath.Dir(dir), path, true
+			}
+			return dir, path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// Prompt returns the prompt information for the project containing dir, or
+// nil outside a project. It only reads local files, plus one container
+// inspection bounded by promptInspectTimeout, and never prompts or prints.
+func Prompt(ctx context.Context, dir string) *PromptInfo {
+	projectDir, configPath, ok := FindProject(dir)
+	if !ok {
+		return nil
+	}
+	name := projectContainerName(projectDir)
+	info := &PromptInfo{
+		Project: strings.TrimSuffix(strings.TrimPrefix(name, "cm-"), "-dev"),
+		Dir:     projectDir,
+		State:   "none",
+	}
+
+	r := &PersistentRunner{ProjectDir: projectDir, StateFile: filepath.Join(projectDir, ".devcontainer", ".cm-state.json")}
+	state, err := r.LoadState()
+	if err != nil {
+		return info
+	}
+	if cfg, err := config.ParseConfig(configPath); err == nil {
+		r.Config = cfg
+		info.OutDated = state.ConfigHash != r.CalculateConfigHash()
+	}
+	if state.IsPaused {
+		info.State = "paused"
+		return info
+	}
+
+	// A trusted ready marker answers without asking the engine
+	if r.Config != nil {
+		if m, ok := loadReadyMarker(r.Config, projectDir, time.Now()); ok && m.ContainerID == state.ContainerID {
+			info.State = "running"
+			return info
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, promptInspectTimeout)
+	defer cancel()
+	running, err := containerRunning(ctx, state.Backend, state.ContainerID)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		info.State = "unknown"
+	case err != nil:
+		info.State = "none"
+	case running:
+		info.State = "running"
+	default:
+		info.State = "stopped"
+	}
+	return info
+}
+
+// containerRunning inspects a container through the Docker API, or the
+// backend's CLI for other backends
+func containerRunning(ctx context.Context, backend, id string) (bool, error) {
+	if backend == "" || backend == "docker" {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return false, err
+		}
+		defer cli.Close()
+		inspect, err := cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return inspect.State != nil && inspect.State.Running, nil
+	}
+
+	out, err := exec.CommandContext(ctx, backend, "inspect", "--type", "container", id).Output()
+	if err != nil {
+		return false, err
+	}
+	var infos []containerInspect
+	if err := json.Unmarshal(out, &infos); err != nil {
+		return false, err
+	}
+	if len(infos) == 0 {
+		return false, os.ErrNotExist
+	}
+	return infos[0].State.Running, nil
+}
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/metrics"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"golang.org/x/term"
+)
+
+// maxParallelPulls bounds the images pulled at once
+const maxParallelPulls = 3
+
+// PullManager pulls several images concurrently, showing per-layer progress
+// bars on a terminal and one line per image otherwise
+type PullManager struct {
+	pull   func(ctx context.Context, ref string) (io.ReadCloser, error)
+	exists func(ctx context.Context, ref string) bool
+	out    io.Writer
+}
+
+// NewPullManager creates a pull manager for a Docker API client
+func NewPullManager(cli *client.Client) *PullManager {
+	return &PullManager{
+		pull: func(ctx context.Context, ref string) (io.ReadCloser, error) {
+			return cli.ImagePull(ctx, ref, image.PullOptions{})
+		},
+		exists: func(ctx context.Context, ref string) bool {
+			_, err := cli.ImageInspect(ctx, ref)
+			return err == nil
+		},
+		out: os.Stdout,
+	}
+}
+
+// Missing returns the images that are not available locally
+func (m *PullManager) Missing(ctx context.Context, refs []string) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if !m.exists(ctx, ref) {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+// PullAll pulls the images that are missing locally, up to maxParallelPulls
+// at a time. It returns an error naming every image that failed.
+func (m *PullManager) PullAll(ctx context.Context, refs []string) error {
+	missing := m.Missing(ctx, refs)
+	if len(missing) == 0 {
+		return nil
+	}
+	if offline.Enabled() {
+		return offline.Missing("image", strings.Join(missing, ", "))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var program *tea.Program
+	if f, ok := m.out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		program = tea.NewProgram(newPullModel(missing, cancel), tea.WithOutput(m.out), tea.WithoutSignalHandler())
+	} else if len(missing) == 1 {
+		fmt.Fprintf(m.out, "📥 Pulling %s...\n", missing[0])
+	} else {
+		fmt.Fprintf(m.out, "📥 Pulling %d images: %s\n", len(missing), strings.Join(missing, ", "))
+	}
+
+	var mu sync.Mutex
+	failed := make(map[string]error)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelPulls)
+	for _, ref := range missing {
+		wg.Add(1)
+		go func(ref string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			done := metrics.Start(metrics.KindPull, "", ref)
+			err := m.pullOne(ctx, ref, func(ev PullProgress) {
+				if program != nil {
+					program.Send(pullEventMsg{image: ref, event: ev})
+				}
+			})
+			done(err)
+			if program != nil {
+				program.Send(pullDoneMsg{image: ref, err: err})
+			} else if err == nil {
+				fmt.Fprintf(m.out, "✅ Pulled %s\n", ref)
+			}
+			if err != nil {
+				mu.Lock()
+				failed[ref] = err
+				mu.Unlock()
+			}
+		}(ref)
+	}
+
+	if program != nil {
+		go func() {
+			wg.Wait()
+			program.Send(pullFinishedMsg{})
+		}()
+		if _, err := program.Run(); err != nil {
+			cancel()
+		}
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil && len(failed) > 0 {
+		return fmt.Errorf("pull cancelled")
+	}
+	if len(failed) > 0 {
+		var msgs []string
+		for ref, err := range failed {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", ref, err))
+		}
+		sort.Strings(msgs)
+		return fmt.Errorf("failed to pull %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// pullOne pulls one image, passing each progress event to onEvent
+func (m *PullManager) pullOne(ctx context.Context, ref string, onEvent func(PullProgress)) error {
+	reader, err := m.pull(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return readPullEvents(reader, onEvent)
+}
+
+// readPullEvents decodes the JSON progress stream of a pull. An error
+// event fails the pull.
+func readPullEvents(reader io.Reader, onEvent func(PullProgress)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		if ev.Error != "" {
+			return fmt.Errorf("%s", ev.Error)
+		}
+		onEvent(ev)
+	}
+	return scanner.Err()
+}
+
+// prePullBaseImages pulls a Dockerfile's base images concurrently before the
+// build, which would otherwise fetch them one stage at a time. Failures are
+// only warnings: the build retries with the CLI's registry credentials.
+func prePullBaseImages(ctx context.Context, m *PullManager, dockerfile string, buildArgs map[string]string) {
+	if m == nil || offline.Enabled() {
+		return
+	}
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return // let the build report it
+	}
+	if err := m.PullAll(ctx, imports.BaseImages(data, buildArgs)); err != nil {
+		fmt.Printf("⚠️  %v; the build will pull it instead\n", err)
+	}
+}
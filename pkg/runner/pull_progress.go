@@ -17,6 +17,7 @@ type PullProgress struct {
 		Current int64 `json:"current"`
 		Total   int64 `json:"total"`
 	} `json:"progressDetail"`
+	Error string `json:"error,omitempty"`
 }
 
 // PullProgressDisplay handles parsing and displaying Docker pull progress
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	pullDoneStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#10B981"))
+	pullErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444"))
+	pullMutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#6B7280"))
+)
+
+type pullEventMsg struct {
+	image string
+	event PullProgress
+}
+
+type pullDoneMsg struct {
+	image string
+	err   error
+}
+
+type pullFinishedMsg struct{}
+
+// imagePull is the progress of one image in the pull view
+type imagePull struct {
+	ref    string
+	order  []string // Layer IDs in the order they appeared
+	layers map[string]*layerState
+	done   bool
+	err    error
+}
+
+// pullModel shows one block of layer progress bars per image; finished
+// images collapse to a single line
+type pullModel struct {
+	images []*imagePull
+	byRef  map[string]*imagePull
+	cancel context.CancelFunc
+	width  int
+}
+
+func newPullModel(refs []string, cancel context.CancelFunc) pullModel {
+	m := pullModel{byRef: make(map[string]*imagePull), cancel: cancel}
+	for _, ref := range refs {
+		p := &imagePull{ref: ref, layers: make(map[string]*layerState)}
+		m.images = append(m.images, p)
+		m.byRef[ref] = p
+	}
+	return m
+}
+
+func (m pullModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pullModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.cancel()
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+
+	case pullEventMsg:
+		p := m.byRef[msg.image]
+		ev := msg.event
+		if p == nil || ev.ID == "" || strings.HasPrefix(ev.Status, "Pulling from") {
+			break
+		}
+		layer, ok := p.layers[ev.ID]
+		if !ok {
+			layer = &layerState{}
+			p.layers[ev.ID] = layer
+			p.order = append(p.order, ev.ID)
+		}
+		layer.status = ev.Status
+		if ev.ProgressDetail.Total > 0 {
+			layer.current = ev.ProgressDetail.Current
+			layer.total = ev.ProgressDetail.Total
+		}
+		switch ev.Status {
+		case "Pull complete", "Already exists":
+			layer.complete = true
+		}
+
+	case pullDoneMsg:
+		if p := m.byRef[msg.image]; p != nil {
+			p.done = true
+			p.err = msg.err
+		}
+
+	case pullFinishedMsg:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m pullModel) View() string {
+	var b strings.Builder
+	for _, p := range m.images {
+		switch {
+		case p.err != nil:
+			b.WriteString(pullErrorStyle.Render(fmt.Sprintf("❌ %s: %v", p.ref, p.err)) + "\n")
+			continue
+		case p.done:
+			b.WriteString(pullDoneStyle.Render(fmt.Sprintf("✅ Pulled %s", p.ref)) + "\n")
+			continue
+		}
+
+		complete := 0
+		for _, layer := range p.layers {
+			if layer.complete {
+				complete++
+			}
+		}
+		fmt.Fprintf(&b, "📥 %s %s\n", p.ref, pullMutedStyle.Render(fmt.Sprintf("(%d/%d layers)", complete, len(p.layers))))
+		for _, id := range p.order {
+			layer := p.layers[id]
+			if layer.complete {
+				continue
+			}
+			b.WriteString("   " + formatLayer(id, layer) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// formatLayer renders one layer line: ID, status and, while downloading or
+// extracting, a progress bar
+func formatLayer(id string, layer *layerState) string {
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	line := fmt.Sprintf("%s  %-18s", id, layer.status)
+	if layer.total > 0 && (layer.status == "Downloading" || layer.status == "Extracting") {
+		percent := float64(layer.current) / float64(layer.total) * 100
+		filled := int(percent / 100 * 25)
+		if filled > 25 {
+			filled = 25
+		}
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", 25-filled)
+		line += fmt.Sprintf(" %s %3.0f%% %s/%s", bar, percent, formatBytes(layer.current), formatBytes(layer.total))
+	}
+	return pullMutedStyle.Render(line)
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"golang.org/x/term"
+)
+
+// ReadyTTLEnvVar overrides how long a ready marker is trusted when no agent
+// is running, e.g. CM_READY_TTL=5m. Zero disables the fast path.
+const ReadyTTLEnvVar = "CM_READY_TTL"
+
+// defaultReadyTTL bounds how long a ready marker is trusted without an agent
+// watching the container
+const defaultReadyTTL = 60 * time.Second
+
+// readyMarker records that a project's persistent container was running with
+// the current configuration, so 'cm exec' can skip runtime detection, state
+// checks and inspection and go straight to the Docker API
+type readyMarker struct {
+	ContainerID string    `json:"containerId"`
+	ConfigHash  string    `json:"configHash"`
+	Backend     string    `json:"backend"`
+	WrittenAt   time.Time `json:"writtenAt"`
+}
+
+// readyMarkerPath returns the marker file of a project
+func readyMarkerPath(projectDir string) string {
+	return filepath.Join(projectDir, ".devcontainer", ".cm-ready.json")
+}
+
+// ClearReadyMarker removes a project's ready marker, forcing the next exec
+// through the full container checks
+func ClearReadyMarker(projectDir string) {
+	_ = os.Remove(readyMarkerPath(projectDir))
+}
+
+// markReady writes the ready marker after the container was ensured. Only
+// the Docker backend has a fast path.
+func (r *PersistentRunner) markReady(containerID string) {
+	if r.Backend != "docker" {
+		return
+	}
+	data, err := json.Marshal(readyMarker{
+		ContainerID: containerID,
+		ConfigHash:  r.CalculateConfigHash(),
+		Backend:     r.Backend,
+		WrittenAt:   time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(readyMarkerPath(r.ProjectDir), data, 0644)
+}
+
+// readyTTL returns how long a marker is trusted without an agent
+func readyTTL() time.Duration {
+	if v := os.Getenv(ReadyTTLEnvVar); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultReadyTTL
+}
+
+// loadReadyMarker returns the project's marker if it can be trusted: it
+// matches the current configuration and is either younger than the TTL or
+// was written while the running agent was watching for container events
+func loadReadyMarker(cfg *config.DevContainerConfig, projectDir string, now time.Time) (*readyMarker, bool) {
+	data, err := os.ReadFile(readyMarkerPath(projectDir))
+	if err != nil {
+		return nil, false
+	}
+	var m readyMarker
+	if err := json.Unmarshal(data, &m); err != nil || m.ContainerID == "" || m.Backend != "docker" {
+		return nil, false
+	}
+	if m.ConfigHash != (&PersistentRunner{Config: cfg, ProjectDir: projectDir}).CalculateConfigHash() {
+		return nil, false
+	}
+	if now.Sub(m.WrittenAt) < readyTTL() {
+		return &m, true
+	}
+	if agent, ok := RunningAgent(); ok && m.WrittenAt.After(agent.StartedAt) {
+		return &m, true
+	}
+	return nil, false
+}
+
+// FastExec runs a command in the project's persistent container when a
+// trusted ready marker exists, skipping the checks 'cm exec' otherwise does
+// on every call. handled is false when the caller must take the slow path:
+// there is no trusted marker or the container turned out not to be running.
+func FastExec(ctx context.Context, cfg *config.DevContainerConfig, projectDir string, command []string) (handled bool, err error) {
+	if selectedContainer != "" {
+		return false, nil // --container goes through the full check
+	}
+	m, ok := loadReadyMarker(cfg, projectDir, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return false, nil
+	}
+	defer cli.Close()
+
+	isTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+	execID, err := createExec(ctx, cli, m.ContainerID, command, isTerminal)
+	if err != nil {
+		// Gone, stopped or paused behind our back
+		ClearReadyMarker(projectDir)
+		return false, nil
+	}
+	defer watchDevices(ctx, cfg, "docker", "docker", m.ContainerID)()
+	return true, runExec(ctx, cli, execID, isTerminal)
+}
+
+// createExec creates an exec instance in a container
+func createExec(ctx context.Context, cli *client.Client, containerID string, command []string, tty bool) (string, error) {
+	resp, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+		AttachStdin:  tty,
+		Tty:          tty,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create exec: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// runExec attaches to an exec instance, streams its output and returns an
+// error for a non-zero exit code
+func runExec(ctx context.Context, cli *client.Client, execID string, tty bool) error {
+	attachResp, err := cli.ContainerExecAttach(ctx, execID, container.ExecStartOptions{
+		Tty: tty,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer attachResp.Close()
+
+	// Stream output
+	if tty {
+		go func() { _, _ = io.Copy(attachResp.Conn, os.Stdin) }()
+	}
+	_, _ = io.Copy(os.Stdout, attachResp.Reader)
+
+	// Get exit code
+	inspectResp, err := cli.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return nil // Ignore inspect errors
+	}
+
+	if inspectResp.ExitCode != 0 {
+		return fmt.Errorf("command exited with code %d", inspectResp.ExitCode)
+	}
+
+	return nil
+}
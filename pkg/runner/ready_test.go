@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/config"
+)
+
+// newReadyProject returns a project directory with a ready marker written
+// for cfg, and no agent running
+func newReadyProject(t testing.TB, cfg *config.DevContainerConfig) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	(&PersistentRunner{Config: cfg, ProjectDir: dir, Backend: "docker"}).markReady("abc123")
+	return dir
+}
+
+func TestLoadReadyMarker(t *testing.T) {
+	cfg := &config.DevContainerConfig{Image: "alpine:3.20"}
+	tests := []struct {
+		name    string
+		ttl     string // CM_READY_TTL
+		age     time.Duration
+		cfg     *config.DevContainerConfig
+		trusted bool
+	}{
+		{"fresh", "", 10 * time.Second, cfg, true},
+		{"expired", "", 2 * time.Minute, cfg, false},
+		{"longer TTL", "5m", 2 * time.Minute, cfg, true},
+		{"fast path off", "0", 0, cfg, false},
+		{"bad TTL keeps the default", "soon", 2 * time.Minute, cfg, false},
+		{"config changed", "", 0, &config.DevContainerConfig{Image: "alpine:3.21"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newReadyProject(t, cfg)
+			t.Setenv(ReadyTTLEnvVar, tt.ttl)
+			m, ok := loadReadyMarker(tt.cfg, dir, time.Now().Add(tt.age))
+			if ok != tt.trusted {
+				t.Fatalf("loadReadyMarker trusted = %v, want %v", ok, tt.trusted)
+			}
+			if ok && m.ContainerID != "abc123" {
+				t.Errorf("marker container = %q, want abc123", m.ContainerID)
+			}
+		})
+	}
+}
+
+func TestLoadReadyMarkerRejectsBadMarkers(t *testing.T) {
+	cfg := &config.DevContainerConfig{Image: "alpine:3.20"}
+	hash := (&PersistentRunner{Config: cfg}).CalculateConfigHash()
+	for name, marker := range map[string]string{
+		"not JSON":        "{",
+		"no container":    `{"configHash":"` + hash + `","backend":"docker","writtenAt":"` + time.Now().Format(time.RFC3339) + `"}`,
+		"another backend": `{"containerId":"abc","configHash":"` + hash + `","backend":"podman","writtenAt":"` + time.Now().Format(time.RFC3339) + `"}`,
+	} {
+		dir := newReadyProject(t, cfg)
+		if err := os.WriteFile(readyMarkerPath(dir), []byte(marker), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := loadReadyMarker(cfg, dir, time.Now()); ok {
+			t.Errorf("%s: marker trusted", name)
+		}
+	}
+
+	dir := newReadyProject(t, cfg)
+	ClearReadyMarker(dir)
+	if _, ok := loadReadyMarker(cfg, dir, time.Now()); ok {
+		t.Error("cleared marker trusted")
+	}
+}
+
+func TestLoadReadyMarkerWithAgent(t *testing.T) {
+	cfg := &config.DevContainerConfig{Image: "alpine:3.20"}
+	dir := newReadyProject(t, cfg)
+	writeAgent := func(startedAt time.Time) {
+		t.Helper()
+		path, err := agentFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(AgentInfo{PID: os.Getpid(), StartedAt: startedAt})
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Hour)
+
+	// The agent clears markers when containers stop, but only after it started
+	writeAgent(time.Now().Add(-time.Minute))
+	if _, ok := loadReadyMarker(cfg, dir, later); !ok {
+		t.Error("marker written while the agent watched not trusted past the TTL")
+	}
+	writeAgent(time.Now().Add(time.Minute))
+	if _, ok := loadReadyMarker(cfg, dir, later); ok {
+		t.Error("marker written before the agent started trusted past the TTL")
+	}
+}
+
+func TestFastExecFallsBack(t *testing.T) {
+	cfg := &config.DevContainerConfig{Image: "alpine:3.20"}
+	ctx := context.Background()
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "docker.sock"))
+
+	// No marker
+	dir := t.TempDir()
+	if handled, err := FastExec(ctx, cfg, dir, []string{"true"}); handled || err != nil {
+		t.Errorf("without a marker: handled = %v, %v, want the slow path", handled, err)
+	}
+
+	// --container always takes the full check
+	dir = newReadyProject(t, cfg)
+	UseContainer("other")
+	handled, err := FastExec(ctx, cfg, dir, []string{"true"})
+	UseContainer("")
+	if handled || err != nil {
+		t.Errorf("with --container: handled = %v, %v, want the slow path", handled, err)
+	}
+
+	// A container that can't be reached takes the slow path and loses its marker
+	if handled, err := FastExec(ctx, cfg, dir, []string{"true"}); handled || err != nil {
+		t.Errorf("unreachable container: handled = %v, %v, want the slow path", handled, err)
+	}
+	if _, err := os.Stat(readyMarkerPath(dir)); !os.IsNotExist(err) {
+		t.Error("marker of an unreachable container kept")
+	}
+}
+
+// BenchmarkExec compares 'cm exec' through the full container checks with
+// the ready-marker fast path. It needs Docker and pulls alpine.
+func BenchmarkExec(b *testing.B) {
+	if err := exec.Command("docker", "version", "--format", "{{.Server.Version}}").Run(); err != nil {
+		b.Skip("Docker not available")
+	}
+	ctx := context.Background()
+	cfg := &config.DevContainerConfig{Image: "alpine:latest"}
+	dir := newReadyProject(b, cfg)
+	pr, err := NewPersistentRunner(cfg, dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := pr.EnsureContainer(ctx, false); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = pr.Stop(context.Background()) })
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ClearReadyMarker(dir)
+			if err := pr.Exec(ctx, []string{"true"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ready", func(b *testing.B) {
+		if _, err := pr.EnsureContainer(ctx, false); err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < b.N; i++ {
+			if handled, err := FastExec(ctx, cfg, dir, []string{"true"}); !handled || err != nil {
+				b.Fatalf("fast path not taken: %v %v", handled, err)
+			}
+		}
+	})
+}
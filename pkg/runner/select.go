@@ -0,0 +1,181 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// selectedContainer is the container chosen with --container, if any
+var selectedContainer string
+
+// UseContainer makes persistent runners use the given container, by name or
+// ID, instead of the one in the state file
+func UseContainer(ref string) {
+	selectedContainer = ref
+}
+
+// ContainerCandidate is an existing container that may belong to a project
+type ContainerCandidate struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Image   string    `json:"image"`
+	Status  string    `json:"status"`            // running, exited, paused, ...
+	Project string    `json:"project,omitempty"` // Directory of its cm.project label
+	Created time.Time `json:"created"`
+}
+
+// Candidates lists the containers that may be this project's: those labeled
+// with its directory and the one holding its container name
+func (r *PersistentRunner) Candidates(ctx context.Context) ([]ContainerCandidate, error) {
+	backend := r.getBackendCommand()
+	filters := [][]string{
+		{"--filter", "label=" + LabelProject + "=" + r.ProjectDir},
+		{"--filter", "name=^/?" + r.GetContainerName() + "$"},
+	}
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, filter := range filters {
+		args := append([]string{"ps", "-a", "-q", "--no-trunc"}, filter...)
+		out, err := exec.CommandContext(ctx, backend, args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, id := range strings.Fields(string(out)) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	var candidates []ContainerCandidate
+	for _, id := range ids {
+		info, err := inspectContainer(ctx, backend, id)
+		if err != nil {
+			continue // Removed in the meantime
+		}
+		candidates = append(candidates, info.candidate())
+	}
+	return candidates, nil
+}
+
+// resolveContainer makes the state file point at the right container. An
+// explicit --container wins; otherwise, when the recorded container is gone,
+// a single container labeled with this project is adopted and several are
+// offered for selection. The choice is remembered in the state file.
+func (r *PersistentRunner) resolveContainer(ctx context.Context) error {
+	backend := r.getBackendCommand()
+
+	if selectedContainer != "" {
+		info, err := inspectContainer(ctx, backend, selectedContainer)
+		if err != nil {
+			return fmt.Errorf("container %q not found", selectedContainer)
+		}
+		return r.adoptContainer(info.candidate())
+	}
+
+	if state, err := r.LoadState(); err == nil {
+		if _, err := inspectContainer(ctx, backend, state.ContainerID); err == nil || state.IsPaused {
+			return nil
+		}
+	}
+
+	candidates, err := r.Candidates(ctx)
+	if err != nil || len(candidates) == 0 {
+		return nil // Nothing to choose from; a new container is created
+	}
+	if len(candidates) == 1 && r.hostPaths().Equal(candidates[0].Project, r.ProjectDir) {
+		fmt.Printf("🔗 Using existing container '%s'\n", candidates[0].Name)
+		return r.adoptContainer(candidates[0])
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		var names []string
+		for _, c := range candidates {
+			names = append(names, c.Name)
+		}
+		return fmt.Errorf("several containers match this project (%s); choose one with --container", strings.Join(names, ", "))
+	}
+
+	choice, err := promptCandidate(candidates)
+	if err != nil {
+		return err
+	}
+	return r.adoptContainer(choice)
+}
+
+// adoptContainer records an existing container as the project's container
+func (r *PersistentRunner) adoptContainer(c ContainerCandidate) error {
+	if state, err := r.LoadState(); err == nil && state.ContainerID == c.ID {
+		return nil
+	}
+	ClearReadyMarker(r.ProjectDir)
+	state := &ContainerState{
+		ContainerID:   c.ID,
+		ContainerName: c.Name,
+		CreatedAt:     c.Created,
+		ImageTag:      c.Image,
+	}
+	if r.Config != nil {
+		state.ConfigHash = r.CalculateConfigHash()
+	}
+	return r.SaveState(state)
+}
+
+// promptCandidate asks which container to use
+func promptCandidate(candidates []ContainerCandidate) (ContainerCandidate, error) {
+	fmt.Println("⚠️  Several containers match this project:")
+	for i, c := range candidates {
+		fmt.Printf("   %d) %-24s %-30s %-8s created %s\n", i+1, c.Name, c.Image, c.Status, since(c.Created))
+		if c.Project != "" {
+			fmt.Printf("      project: %s\n", c.Project)
+		}
+	}
+	fmt.Printf("   Use which container? [1-%d] ", len(candidates))
+
+	var response string
+	_, _ = fmt.Scanln(&response)
+	n, err := strconv.Atoi(strings.TrimSpace(response))
+	if err != nil || n < 1 || n > len(candidates) {
+		return ContainerCandidate{}, fmt.Errorf("no container selected")
+	}
+	return candidates[n-1], nil
+}
+
+// candidate converts inspect output to a ContainerCandidate
+func (c *containerInspect) candidate() ContainerCandidate {
+	created, _ := time.Parse(time.RFC3339Nano, c.Created)
+	return ContainerCandidate{
+		ID:      c.ID,
+		Name:    strings.TrimPrefix(c.Name, "/"),
+		Image:   c.Config.Image,
+		Status:  c.State.Status,
+		Project: c.Config.Labels[LabelProject],
+		Created: created,
+	}
+}
+
+// since formats the time elapsed since t, e.g. "3h ago"
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "at an unknown time"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
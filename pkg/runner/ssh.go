@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sshKeysDir holds the public keys cm authorizes, one file per user, so the
+// user's own authorized_keys is left alone
+const sshKeysDir = "/etc/ssh/cm_authorized_keys"
+
+// sshSetupScript makes sure sshd and host keys exist and authorizes the keys
+// read from stdin for the user in $1. Package manager output goes to stderr,
+// since stdout may already carry the SSH session.
+const sshSetupScript = `set -e
+if [ ! -x /usr/sbin/sshd ]; then
+  echo "Installing openssh-server in the container..." >&2
+  if command -v apk >/dev/null 2>&1; then
+    apk add --no-cache openssh-server >&2
+  elif command -v apt-get >/dev/null 2>&1; then
+    (apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends openssh-server) >&2
+  elif command -v dnf >/dev/null 2>&1; then
+    dnf install -y openssh-server >&2
+  elif command -v yum >/dev/null 2>&1; then
+    yum install -y openssh-server >&2
+  else
+    echo "cannot install sshd: no supported package manager; add the sshd feature" >&2
+    exit 1
+  fi
+fi
+ssh-keygen -A >/dev/null
+mkdir -p /run/sshd ` + sshKeysDir + `
+if ! grep -q "^$1:" /etc/passwd; then
+  echo "user $1 does not exist in the container" >&2
+  exit 1
+fi
+cat > ` + sshKeysDir + `/"$1"
+chmod 755 ` + sshKeysDir + `
+chmod 644 ` + sshKeysDir + `/"$1"
+`
+
+// sshdOptions run sshd with key authentication only, trusting both the
+// user's authorized_keys and the keys cm injected
+var sshdOptions = []string{
+	"-o", "AuthorizedKeysFile=.ssh/authorized_keys " + sshKeysDir + "/%u",
+	"-o", "PasswordAuthentication=no",
+	"-o", "KbdInteractiveAuthentication=no",
+	"-o", "PermitRootLogin=prohibit-password",
+	"-o", "LogLevel=ERROR",
+}
+
+// SSHUser is the user SSH sessions log in as: the configured container user,
+// or root
+func (r *PersistentRunner) SSHUser() string {
+	if r.Config != nil && r.Config.User != "" {
+		return r.Config.User
+	}
+	return "root"
+}
+
+// SetupSSH prepares the persistent container for SSH: it installs sshd if
+// missing and authorizes the host's public keys for SSHUser
+func (r *PersistentRunner) SetupSSH(ctx context.Context, containerID string) error {
+	keys, err := HostPublicKeys()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, r.getBackendCommand(), "exec", "-i", "-u", "root", containerID,
+		"sh", "-c", sshSetupScript, "sh", r.SSHUser())
+	cmd.Stdin = strings.NewReader(strings.Join(keys, "\n") + "\n")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to set up sshd in the container: %w", err)
+	}
+	return nil
+}
+
+// ServeSSH runs sshd in inetd mode inside the persistent container, speaking
+// SSH over stdin and stdout. It is the ProxyCommand of 'cm ssh' and the
+// hosts 'cm ssh-config' writes, so no port is published and every backend
+// works. Progress messages go to stderr to keep stdout clean.
+func (r *PersistentRunner) ServeSSH(ctx context.Context) error {
+	stdout, restore := stdoutToStderr()
+	containerID, err := r.EnsureContainer(ctx, false)
+	restore()
+	if err != nil {
+		return err
+	}
+	if err := r.SetupSSH(ctx, containerID); err != nil {
+		return err
+	}
+
+	args := append([]string{"exec", "-i", "-u", "root", containerID, "/usr/sbin/sshd", "-i", "-e"}, sshdOptions...)
+	cmd := exec.CommandContext(ctx, r.getBackendCommand(), args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// HostPublicKeys returns the public keys to authorize: those of the SSH
+// agent, or else the ~/.ssh/*.pub files
+func HostPublicKeys() ([]string, error) {
+	if out, err := exec.Command("ssh-add", "-L").Output(); err == nil {
+		if keys := publicKeyLines(out); len(keys) > 0 {
+			return keys, nil
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(home, ".ssh", "*.pub"))
+	var keys []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, publicKeyLines(data)...)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no SSH public key found: add one to your SSH agent (ssh-add) or create one with ssh-keygen")
+	}
+	return keys, nil
+}
+
+// publicKeyLines keeps the lines that look like public keys
+func publicKeyLines(data []byte) []string {
+	var keys []string
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("ssh-")) || bytes.HasPrefix(line, []byte("ecdsa-")) || bytes.HasPrefix(line, []byte("sk-")) {
+			keys = append(keys, string(line))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HookResult records how the last lifecycle command went
+type HookResult struct {
+	Name       string    `json:"name"`
+	Succeeded  bool      `json:"succeeded"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// StatusSummary is a non-interactive view of the project's persistent
+// container, for 'cm status --short' and scripts
+type StatusSummary struct {
+	Project     string            `json:"project"`
+	Container   string            `json:"container"`
+	State       string            `json:"state"` // running, stopped, paused or none
+	Backend     string            `json:"backend"`
+	ImageTag    string            `json:"imageTag,omitempty"`
+	ImageID     string            `json:"imageId,omitempty"`    // Image the container runs
+	ImageStale  bool              `json:"imageStale"`           // ImageTag now points to another image
+	ConfigHash  string            `json:"configHash,omitempty"` // Configuration the container was created with
+	CurrentHash string            `json:"currentHash,omitempty"`
+	ConfigDrift bool              `json:"configDrift"`
+	Ports       []string          `json:"ports"`                   // e.g. 0.0.0.0:3000->3000/tcp
+	Remapped    map[string]string `json:"remappedPorts,omitempty"` // Requested port/protocol -> used host port
+	LastHook    *HookResult       `json:"lastHook,omitempty"`
+}
+
+// Summary inspects the persistent container without prompting or changing
+// anything
+func (r *PersistentRunner) Summary(ctx context.Context) *StatusSummary {
+	s := &StatusSummary{
+		Container: r.GetContainerName(),
+		State:     "none",
+		Backend:   r.Backend,
+		Ports:     []string{},
+	}
+	s.Project = strings.TrimSuffix(strings.TrimPrefix(s.Container, "cm-"), "-dev")
+	if r.Config != nil {
+		s.CurrentHash = r.CalculateConfigHash()
+	}
+
+	state, err := r.LoadState()
+	if err != nil {
+		return s
+	}
+	s.ImageTag = state.ImageTag
+	s.ConfigHash = state.ConfigHash
+	s.ConfigDrift = s.CurrentHash != "" && state.ConfigHash != s.CurrentHash
+	s.LastHook = state.LastHook
+	s.Remapped = state.RemappedPorts
+	if state.IsPaused {
+		s.State = "paused"
+		return s
+	}
+
+	info, err := inspectContainer(ctx, r.getBackendCommand(), state.ContainerID)
+	if err != nil {
+		s.State = "none"
+		return s
+	}
+	s.State = "stopped"
+	if info.State.Running {
+		s.State = "running"
+	}
+	s.ImageID = info.Image
+	s.Ports = info.ports()
+	if current, err := ImageID(ctx, r.getBackendCommand(), state.ImageTag); err == nil && current != "" {
+		s.ImageStale = current != info.Image
+	}
+	return s
+}
+
+// containerInspect is the part of '<backend> inspect' cm reads;
+// Docker and Podman agree on it
+type containerInspect struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Created string `json:"Created"`
+	Image   string `json:"Image"`
+	Config  struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status  string `json:"Status"`
+		Running bool   `json:"Running"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func inspectContainer(ctx context.Context, backend, id string) (*containerInspect, error) {
+	if id == "" {
+		return nil, fmt.Errorf("no container")
+	}
+	out, err := exec.CommandContext(ctx, backend, "inspect", "--type", "container", id).Output()
+	if err != nil {
+		return nil, err
+	}
+	var infos []containerInspect
+	if err := json.Unmarshal(out, &infos); err != nil || len(infos) == 0 {
+		return nil, fmt.Errorf("unexpected inspect output")
+	}
+	return &infos[0], nil
+}
+
+// ports lists the published ports as host->container/proto
+func (c *containerInspect) ports() []string {
+	ports := []string{}
+	for port, bindings := range c.NetworkSettings.Ports {
+		for _, b := range bindings {
+			host := b.HostPort
+			if b.HostIP != "" {
+				host = b.HostIP + ":" + host
+			}
+			ports = append(ports, host+"->"+port)
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// PublishedPort returns the host port a port of the persistent container is
+// published on; port is "3000" or "3000/udp"
+func (r *PersistentRunner) PublishedPort(ctx context.Context, port string) (string, error) {
+	if !strings.Contains(port, "/") {
+		port += "/tcp"
+	}
+	state, err := r.LoadState()
+	if err != nil {
+		return "", fmt.Errorf("no persistent container; start one with 'cm shell'")
+	}
+	info, err := inspectContainer(ctx, r.getBackendCommand(), state.ContainerID)
+	if err != nil {
+		return "", fmt.Errorf("container %s not found", state.ContainerName)
+	}
+	for _, b := range info.NetworkSettings.Ports[port] {
+		if b.HostPort != "" {
+			return b.HostPort, nil
+		}
+	}
+	return "", fmt.Errorf("port %s is not published; add it to forwardPorts", port)
+}
+
+// ContainerImage returns the image tag of a project's persistent container
+// and the ID of the image it runs, empty when it has none
+func ContainerImage(ctx context.Context, projectDir string) (tag, id string) {
+	state, err := (&PersistentRunner{StateFile: filepath.Join(projectDir, ".devcontainer", ".cm-state.json")}).LoadState()
+	if err != nil || state == nil {
+		return "", ""
+	}
+	backend := state.Backend
+	if backend == "" {
+		backend = "docker"
+	}
+	if info, err := inspectContainer(ctx, backend, state.ContainerID); err == nil {
+		return state.ImageTag, info.Image
+	}
+	id, _ = ImageID(ctx, backend, state.ImageTag)
+	return state.ImageTag, id
+}
+
+// ImageID returns the ID an image reference currently points to
+func ImageID(ctx context.Context, backend, ref string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	out, err := exec.CommandContext(ctx, backend, "image", "inspect", "--format", "{{.Id}}", ref).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// recordHook saves the result of a lifecycle command in the state file
+func (r *PersistentRunner) recordHook(name string, err error) {
+	state, loadErr := r.LoadState()
+	if loadErr != nil {
+		return
+	}
+	state.LastHook = &HookResult{Name: name, Succeeded: err == nil, FinishedAt: time.Now()}
+	if err != nil {
+		state.LastHook.Error = err.Error()
+	}
+	_ = r.SaveState(state)
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/UPwith-me/Container-Maker/pkg/toolversions"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// installToolVersions installs the runtimes the project pins in
+// .tool-versions or .mise.toml with asdf or mise, unless
+// 'cm config set host.no_tool_versions true'. It runs before
+// postCreateCommand, which may need them; a failure only warns.
+func (r *PersistentRunner) installToolVersions(ctx context.Context, containerID string) {
+	if cfg, err := userconfig.Load(); err == nil && cfg.Host.NoToolVersions {
+		return
+	}
+	project, err := toolversions.Detect(r.ProjectDir)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		return
+	}
+	if project == nil || len(project.Tools) == 0 {
+		return
+	}
+
+	fmt.Printf("🧰 Installing tools from %s: %s\n", project.File, project)
+	cmd := exec.CommandContext(ctx, r.getBackendCommand(), "exec", containerID, "sh", "-c", toolversions.InstallScript)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("⚠️  Failed to install the tools from %s: %v\n", project.File, err)
+		return
+	}
+	fmt.Println("✅ Tools installed")
+}
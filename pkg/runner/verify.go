@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"context"
+	"os"
+
+	"github.com/UPwith-me/Container-Maker/pkg/imports"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
+)
+
+// verifyBuildImages checks the signatures of the base images a Dockerfile
+// builds FROM before the build can pull them
+func verifyBuildImages(ctx context.Context, dockerfile string, buildArgs map[string]string) error {
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return nil // let the build report it
+	}
+	return verify.Images(ctx, imports.BaseImages(data, buildArgs), os.Stdout)
+}
@@ -12,6 +12,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
@@ -169,18 +170,25 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, config *ContainerCo
 	}
 
 	hostConfig := &container.HostConfig{
-		Binds:        config.Binds,
-		PortBindings: portBindings,
-		AutoRemove:   config.AutoRemove,
-		Init:         &config.Init,
-		Privileged:   config.Privileged,
-		NetworkMode:  container.NetworkMode(config.NetworkMode),
-		CapAdd:       config.CapAdd,
-		CapDrop:      config.CapDrop,
-		SecurityOpt:  config.SecurityOpt,
+		Binds:           config.Binds,
+		PortBindings:    portBindings,
+		PublishAllPorts: config.PublishAllPorts,
+		AutoRemove:      config.AutoRemove,
+		Init:            &config.Init,
+		Privileged:      config.Privileged,
+		NetworkMode:     container.NetworkMode(config.NetworkMode),
+		CapAdd:          config.CapAdd,
+		CapDrop:         config.CapDrop,
+		SecurityOpt:     config.SecurityOpt,
+		ExtraHosts:      config.ExtraHosts,
+		DNS:             config.DNS,
+		DNSSearch:       config.DNSSearch,
+		DNSOptions:      config.DNSOptions,
+		GroupAdd:        config.GroupAdd,
 		Resources: container.Resources{
-			Devices:        devices,
-			DeviceRequests: deviceRequests,
+			Devices:           devices,
+			DeviceCgroupRules: config.DeviceCgroupRules,
+			DeviceRequests:    deviceRequests,
 		},
 	}
 
@@ -197,6 +205,7 @@ func (r *DockerRuntime) CreateContainer(ctx context.Context, config *ContainerCo
 		Hostname:     config.Hostname,
 		Entrypoint:   config.Entrypoint,
 		ExposedPorts: exposedPorts,
+		Labels:       config.Labels,
 		Tty:          config.Tty,
 		OpenStdin:    config.OpenStdin,
 	}
@@ -307,6 +316,9 @@ func (r *DockerRuntime) PullImage(ctx context.Context, imageName string) error {
 	if err == nil {
 		return nil // Image already exists
 	}
+	if offline.Enabled() {
+		return offline.Missing("image", imageName)
+	}
 
 	reader, err := r.client.ImagePull(ctx, imageName, image.PullOptions{})
 	if err != nil {
@@ -425,3 +437,116 @@ func (r *DockerRuntime) RemoveImage(ctx context.Context, imageStr string, force
 	_, err := r.client.ImageRemove(ctx, imageStr, opts)
 	return err
 }
+
+// PauseContainer freezes the processes of a container
+func (r *DockerRuntime) PauseContainer(ctx context.Context, id string) error {
+	return r.client.ContainerPause(ctx, id)
+}
+
+// UnpauseContainer resumes a paused container
+func (r *DockerRuntime) UnpauseContainer(ctx context.Context, id string) error {
+	return r.client.ContainerUnpause(ctx, id)
+}
+
+// ListContainers lists running containers, or all of them
+func (r *DockerRuntime) ListContainers(ctx context.Context, all bool) ([]ContainerSummary, error) {
+	list, err := r.client.ContainerList(ctx, container.ListOptions{All: all})
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []ContainerSummary
+	for _, c := range list {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		var ports []string
+		for _, p := range c.Ports {
+			if p.PublicPort == 0 {
+				ports = append(ports, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+				continue
+			}
+			ports = append(ports, fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+		}
+		containers = append(containers, ContainerSummary{
+			ID:     c.ID,
+			Name:   name,
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+			Ports:  strings.Join(ports, ", "),
+		})
+	}
+	return containers, nil
+}
+
+// ContainerStats samples the resource usage of a running container. Docker
+// measures CPU usage over about a second, so the call takes that long.
+func (r *DockerRuntime) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	resp, err := r.client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+
+	result := &ContainerStats{}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpus := float64(stats.CPUStats.OnlineCPUs)
+		if cpus == 0 {
+			cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+		}
+		result.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// Page cache is reclaimable; docker stats leaves it out too
+	used := stats.MemoryStats.Usage
+	if cache := stats.MemoryStats.Stats["inactive_file"]; cache < used {
+		used -= cache
+	}
+	if stats.MemoryStats.Limit > 0 {
+		result.MemoryPercent = float64(used) / float64(stats.MemoryStats.Limit) * 100
+	}
+	result.MemoryUsage = fmt.Sprintf("%s / %s", formatMiB(used), formatMiB(stats.MemoryStats.Limit))
+	return result, nil
+}
+
+// formatMiB formats a byte count like docker stats does
+func formatMiB(bytes uint64) string {
+	if bytes >= 1<<30 {
+		return fmt.Sprintf("%.2fGiB", float64(bytes)/(1<<30))
+	}
+	return fmt.Sprintf("%.1fMiB", float64(bytes)/(1<<20))
+}
+
+// ContainerLogs returns the last lines of a container's output
+func (r *DockerRuntime) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	info, err := r.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	reader, err := r.client.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       fmt.Sprintf("%d", tail),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(&buf, reader)
+	} else {
+		_, err = stdcopy.StdCopy(&buf, &buf, reader)
+	}
+	return buf.String(), err
+}
@@ -3,12 +3,13 @@ package runtime
 import (
 	"fmt"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
 )
 
 // DiagnosticResult holds the result of a diagnostic check
@@ -115,18 +116,33 @@ func checkGPU() DiagnosticResult {
 	if gpu.CUDAVersion != "" {
 		details = append(details, fmt.Sprintf("CUDA: %s", gpu.CUDAVersion))
 	}
+	if gpu.ROCmVersion != "" {
+		details = append(details, fmt.Sprintf("ROCm: %s", gpu.ROCmVersion))
+	}
 	if gpu.Count > 1 {
 		details = append(details, fmt.Sprintf("%d GPUs available", gpu.Count))
 	}
 
 	result.Details = strings.Join(details, ", ")
 
-	// Check NVIDIA Container Toolkit
-	if gpu.Type == "nvidia" {
+	// Check that containers can use the GPU
+	switch gpu.Type {
+	case "nvidia":
 		if _, err := exec.LookPath("nvidia-container-toolkit"); err != nil {
 			result.Status = "warning"
 			result.Fix = "Install NVIDIA Container Toolkit for GPU in containers:\nhttps://docs.nvidia.com/datacenter/cloud-native/container-toolkit/install-guide.html"
 		}
+	case "amd", "intel":
+		// Given to containers as /dev/kfd and /dev/dri with the host's
+		// video and render groups; the user needs them too for tools on
+		// the host
+		if out, err := exec.Command("id", "-Gn").Output(); err == nil {
+			groups := " " + strings.TrimSpace(string(out)) + " "
+			if !strings.Contains(groups, " render ") && !strings.Contains(groups, " video ") {
+				result.Status = "warning"
+				result.Fix = "Add yourself to the render and video groups to use the GPU outside containers:\nsudo usermod -aG render,video $USER"
+			}
+		}
 	}
 
 	return result
@@ -138,9 +154,8 @@ func checkNetwork() DiagnosticResult {
 	}
 
 	// Check Docker Hub connectivity
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+	// Goes through the configured proxy, like real downloads
+	client := httpclient.New(httpclient.Options{Timeout: 5 * time.Second, Retries: -1})
 
 	targets := []struct {
 		name string
@@ -2,8 +2,11 @@ package runtime
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +18,7 @@ type GPUInfo struct {
 	Memory      string
 	DriverVer   string
 	CUDAVersion string
+	ROCmVersion string
 	Count       int
 }
 
@@ -37,6 +41,8 @@ func CreateRuntime(name, path, typ string) (ContainerRuntime, error) {
 		return NewDockerRuntime(name, path)
 	case "podman":
 		return NewPodmanRuntime(name, path)
+	case "wsl":
+		return NewWSLRuntime(name, path)
 	default:
 		// Default to docker-compatible
 		return NewDockerRuntime(name, path)
@@ -108,14 +114,10 @@ func detectNVIDIA() *GPUInfo {
 }
 
 func detectAMD() *GPUInfo {
-	// Try rocm-smi for AMD GPUs
-	cmd := exec.Command("rocm-smi", "--showproductname")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil
-	}
-
-	if !strings.Contains(string(output), "GPU") {
+	// The ROCm compute interface exists once amdgpu is loaded, with or
+	// without rocm-smi
+	cards := drmCards(drmSysfs, "0x1002")
+	if _, err := os.Stat("/dev/kfd"); err != nil || len(cards) == 0 {
 		return nil
 	}
 
@@ -123,41 +125,95 @@ func detectAMD() *GPUInfo {
 		Available: true,
 		Type:      "amd",
 		Name:      "AMD GPU (ROCm)",
-		Count:     1,
+		Count:     len(cards),
 	}
-
-	// Try to get more details
-	if memCmd := exec.Command("rocm-smi", "--showmeminfo", "vram"); memCmd != nil {
-		if memOutput, err := memCmd.Output(); err == nil {
-			// Parse memory info
-			info.Memory = strings.TrimSpace(string(memOutput))
+	if out, err := exec.Command("rocm-smi", "--showproductname").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if _, series, ok := strings.Cut(line, "Card Series:"); ok && strings.TrimSpace(series) != "" {
+				info.Name = strings.TrimSpace(series)
+				break
+			}
 		}
 	}
-
+	if vram := readSysfsInt(filepath.Join(cards[0], "device", "mem_info_vram_total")); vram > 0 {
+		info.Memory = fmt.Sprintf("%d MiB", vram>>20)
+	}
+	if data, err := os.ReadFile("/sys/module/amdgpu/version"); err == nil {
+		info.DriverVer = strings.TrimSpace(string(data))
+	}
+	if data, err := os.ReadFile("/opt/rocm/.info/version"); err == nil {
+		info.ROCmVersion = strings.TrimSpace(string(data))
+	}
 	return info
 }
 
 func detectIntel() *GPUInfo {
-	// Basic Intel GPU detection
-	if runtime.GOOS == "linux" {
-		// Check for Intel GPU via lspci
-		cmd := exec.Command("lspci")
-		output, err := cmd.Output()
-		if err != nil {
-			return nil
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	// Intel GPUs with a render node, which oneAPI's Level Zero drives
+	var cards []string
+	for _, card := range drmCards(drmSysfs, "0x8086") {
+		if nodes, _ := filepath.Glob(filepath.Join(card, "device", "drm", "renderD*")); len(nodes) > 0 {
+			cards = append(cards, card)
 		}
+	}
+	if len(cards) == 0 {
+		return nil
+	}
 
-		if strings.Contains(strings.ToLower(string(output)), "intel") &&
-			strings.Contains(strings.ToLower(string(output)), "vga") {
-			return &GPUInfo{
-				Available: true,
-				Type:      "intel",
-				Name:      "Intel Integrated Graphics",
-				Count:     1,
+	info := &GPUInfo{
+		Available: true,
+		Type:      "intel",
+		Name:      "Intel Graphics",
+		Count:     len(cards),
+	}
+	if out, err := exec.Command("lspci").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			lower := strings.ToLower(line)
+			if !strings.Contains(lower, "intel") || !(strings.Contains(lower, "vga") || strings.Contains(lower, "display")) {
+				continue
 			}
+			if i := strings.Index(line, "Intel Corporation "); i >= 0 {
+				info.Name = "Intel " + strings.TrimSpace(line[i+len("Intel Corporation "):])
+			}
+			break
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(cards[0], "device", "driver", "module", "version")); err == nil {
+		info.DriverVer = strings.TrimSpace(string(data))
+	}
+	return info
+}
+
+// drmSysfs lists the host's DRM devices
+const drmSysfs = "/sys/class/drm"
+
+// drmCards returns the DRM cards of a PCI vendor ID, such as "0x1002" for
+// AMD, skipping their connectors
+func drmCards(sysfs, vendor string) []string {
+	matches, _ := filepath.Glob(filepath.Join(sysfs, "card*"))
+	var cards []string
+	for _, card := range matches {
+		if strings.Contains(filepath.Base(card), "-") {
+			continue
 		}
+		data, err := os.ReadFile(filepath.Join(card, "device", "vendor"))
+		if err == nil && strings.TrimSpace(string(data)) == vendor {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// readSysfsInt reads a number from a sysfs file, or 0
+func readSysfsInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
 	}
-	return nil
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
 }
 
 // GPUDockerArgs returns Docker/Podman args for GPU support
@@ -171,7 +227,9 @@ func GPUDockerArgs(gpu *GPUInfo) []string {
 		return []string{"--gpus", "all"}
 	case "amd":
 		// ROCm uses device mapping
-		return []string{"--device=/dev/kfd", "--device=/dev/dri"}
+		return []string{"--device=/dev/kfd", "--device=/dev/dri", "--group-add", "video"}
+	case "intel":
+		return []string{"--device=/dev/dri", "--group-add", "video"}
 	default:
 		return nil
 	}
@@ -194,6 +252,9 @@ func FormatGPUInfo(gpu *GPUInfo) string {
 	if gpu.CUDAVersion != "" {
 		sb.WriteString(fmt.Sprintf("CUDA: %s\n", gpu.CUDAVersion))
 	}
+	if gpu.ROCmVersion != "" {
+		sb.WriteString(fmt.Sprintf("ROCm: %s\n", gpu.ROCmVersion))
+	}
 	if gpu.Count > 1 {
 		sb.WriteString(fmt.Sprintf("Count: %d GPUs\n", gpu.Count))
 	}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 )
 
 // PodmanRuntime implements ContainerRuntime for Podman
@@ -96,6 +98,11 @@ func (r *PodmanRuntime) CreateContainer(ctx context.Context, config *ContainerCo
 		args = append(args, "-e", env)
 	}
 
+	// Labels
+	for k, v := range config.Labels {
+		args = append(args, "--label", k+"="+v)
+	}
+
 	// Working directory
 	if config.WorkingDir != "" {
 		args = append(args, "-w", config.WorkingDir)
@@ -130,6 +137,10 @@ func (r *PodmanRuntime) CreateContainer(ctx context.Context, config *ContainerCo
 		}
 	}
 
+	if config.PublishAllPorts {
+		args = append(args, "-P")
+	}
+
 	// Auto remove
 	if config.AutoRemove {
 		args = append(args, "--rm")
@@ -162,6 +173,26 @@ func (r *PodmanRuntime) CreateContainer(ctx context.Context, config *ContainerCo
 	for _, d := range config.Devices {
 		args = append(args, "--device", fmt.Sprintf("%s:%s", d.PathOnHost, d.PathInContainer))
 	}
+	for _, rule := range config.DeviceCgroupRules {
+		args = append(args, "--device-cgroup-rule", rule)
+	}
+	for _, group := range config.GroupAdd {
+		args = append(args, "--group-add", group)
+	}
+
+	// Name resolution
+	for _, host := range config.ExtraHosts {
+		args = append(args, "--add-host", host)
+	}
+	for _, server := range config.DNS {
+		args = append(args, "--dns", server)
+	}
+	for _, domain := range config.DNSSearch {
+		args = append(args, "--dns-search", domain)
+	}
+	for _, opt := range config.DNSOptions {
+		args = append(args, "--dns-option", opt)
+	}
 
 	// Security options
 	for _, opt := range config.SecurityOpt {
@@ -335,6 +366,12 @@ func (r *PodmanRuntime) InspectContainer(ctx context.Context, id string) (*Conta
 }
 
 func (r *PodmanRuntime) PullImage(ctx context.Context, imageName string) error {
+	if offline.Enabled() {
+		if r.ImageExists(ctx, imageName) {
+			return nil
+		}
+		return offline.Missing("image", imageName)
+	}
 	cmd := exec.CommandContext(ctx, r.path, "pull", imageName)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -497,3 +534,65 @@ func (r *PodmanRuntime) RemoveImage(ctx context.Context, imageStr string, force
 	cmd := exec.CommandContext(ctx, r.path, args...)
 	return cmd.Run()
 }
+
+// PauseContainer freezes the processes of a container
+func (r *PodmanRuntime) PauseContainer(ctx context.Context, id string) error {
+	return exec.CommandContext(ctx, r.path, "pause", id).Run()
+}
+
+// UnpauseContainer resumes a paused container
+func (r *PodmanRuntime) UnpauseContainer(ctx context.Context, id string) error {
+	return exec.CommandContext(ctx, r.path, "unpause", id).Run()
+}
+
+// ListContainers lists running containers, or all of them
+func (r *PodmanRuntime) ListContainers(ctx context.Context, all bool) ([]ContainerSummary, error) {
+	args := []string{"ps", "--format", "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.State}}\t{{.Status}}\t{{.Ports}}"}
+	if all {
+		args = append(args, "-a")
+	}
+	output, err := exec.CommandContext(ctx, r.path, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []ContainerSummary
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) < 6 {
+			continue
+		}
+		containers = append(containers, ContainerSummary{
+			ID:     parts[0],
+			Name:   parts[1],
+			Image:  parts[2],
+			State:  parts[3],
+			Status: parts[4],
+			Ports:  parts[5],
+		})
+	}
+	return containers, nil
+}
+
+// ContainerStats samples the resource usage of a running container
+func (r *PodmanRuntime) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	output, err := exec.CommandContext(ctx, r.path, "stats", "--no-stream", "--format", "{{.CPUPerc}}\t{{.MemPerc}}\t{{.MemUsage}}", id).Output()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimSpace(string(output)), "\t")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("unexpected stats output: %q", output)
+	}
+
+	stats := &ContainerStats{MemoryUsage: parts[2]}
+	_, _ = fmt.Sscanf(strings.TrimSuffix(parts[0], "%"), "%f", &stats.CPUPercent)
+	_, _ = fmt.Sscanf(strings.TrimSuffix(parts[1], "%"), "%f", &stats.MemoryPercent)
+	return stats, nil
+}
+
+// ContainerLogs returns the last lines of a container's output
+func (r *PodmanRuntime) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	output, err := exec.CommandContext(ctx, r.path, "logs", "--tail", fmt.Sprintf("%d", tail), id).CombinedOutput()
+	return string(output), err
+}
@@ -26,6 +26,13 @@ type ContainerRuntime interface {
 	AttachContainer(ctx context.Context, id string, opts AttachOptions) (*AttachResponse, error)
 	WaitContainer(ctx context.Context, id string) (<-chan int64, <-chan error)
 	InspectContainer(ctx context.Context, id string) (*ContainerInfo, error)
+	PauseContainer(ctx context.Context, id string) error
+	UnpauseContainer(ctx context.Context, id string) error
+
+	// Monitoring
+	ListContainers(ctx context.Context, all bool) ([]ContainerSummary, error)
+	ContainerStats(ctx context.Context, id string) (*ContainerStats, error)
+	ContainerLogs(ctx context.Context, id string, tail int) (string, error)
 
 	// Image operations
 	PullImage(ctx context.Context, image string) error
@@ -52,20 +59,28 @@ type ContainerConfig struct {
 	Hostname     string
 	Entrypoint   []string
 	ExposedPorts map[string]struct{}
+	Labels       map[string]string
 
 	// Host config
-	Binds          []string
-	PortBindings   map[string][]PortBinding
-	AutoRemove     bool
-	Init           bool
-	Privileged     bool
-	NetworkMode    string
-	CapAdd         []string
-	CapDrop        []string
-	Devices        []DeviceMapping
-	DeviceRequests []DeviceRequest // GPU access
-	SecurityOpt    []string
-	ShmSize        int64
+	Binds             []string
+	PortBindings      map[string][]PortBinding
+	PublishAllPorts   bool // Publish every exposed port on a random host port
+	AutoRemove        bool
+	Init              bool
+	Privileged        bool
+	NetworkMode       string
+	CapAdd            []string
+	CapDrop           []string
+	Devices           []DeviceMapping
+	DeviceCgroupRules []string        // e.g. "c 188:* rmw"
+	DeviceRequests    []DeviceRequest // GPU access
+	GroupAdd          []string        // Additional group IDs
+	SecurityOpt       []string
+	ExtraHosts        []string // "hostname:ip"
+	DNS               []string
+	DNSSearch         []string
+	DNSOptions        []string
+	ShmSize           int64
 
 	// TTY
 	Tty       bool
@@ -137,6 +152,23 @@ type ContainerInfo struct {
 	Running bool
 }
 
+// ContainerSummary is a container as listed by ps
+type ContainerSummary struct {
+	ID     string
+	Name   string
+	Image  string
+	State  string // running, exited, paused, ...
+	Status string // Human readable, e.g. "Up 3 hours"
+	Ports  string
+}
+
+// ContainerStats is one resource usage sample of a container
+type ContainerStats struct {
+	CPUPercent    float64
+	MemoryPercent float64
+	MemoryUsage   string // e.g. "120MiB / 7.6GiB"
+}
+
 // BackendInfo holds backend metadata for display
 type BackendInfo struct {
 	Name      string `json:"name"`
@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/i18n"
 )
 
 // HostInfo contains detected host information
@@ -125,19 +127,19 @@ func (h *HostInfo) getWindowsOptions() []InstallOption {
 	return []InstallOption{
 		{
 			Name:        "Docker Desktop",
-			Description: "官方 Docker Desktop for Windows (推荐)",
+			Description: i18n.T("setup.option.docker_desktop_windows"),
 			Command:     `winget install Docker.DockerDesktop`,
 			Priority:    100,
 		},
 		{
 			Name:        "Rancher Desktop",
-			Description: "开源替代品，支持 containerd/dockerd",
+			Description: i18n.T("setup.option.rancher_desktop"),
 			Command:     `winget install suse.RancherDesktop`,
 			Priority:    80,
 		},
 		{
 			Name:        "Podman Desktop",
-			Description: "Red Hat 的 Docker 替代品，无需守护进程",
+			Description: i18n.T("setup.option.podman_desktop"),
 			Command:     `winget install RedHat.Podman-Desktop`,
 			Priority:    70,
 		},
@@ -149,25 +151,25 @@ func (h *HostInfo) getMacOSOptions() []InstallOption {
 	options := []InstallOption{
 		{
 			Name:        "Docker Desktop",
-			Description: "官方 Docker Desktop for Mac (推荐)",
+			Description: i18n.T("setup.option.docker_desktop_mac"),
 			Command:     `brew install --cask docker`,
 			Priority:    100,
 		},
 		{
 			Name:        "OrbStack",
-			Description: "更快更轻量的 Docker 替代品 (macOS 专属)",
+			Description: i18n.T("setup.option.orbstack"),
 			Command:     `brew install --cask orbstack`,
 			Priority:    95,
 		},
 		{
 			Name:        "Colima",
-			Description: "开源轻量级容器运行时",
+			Description: i18n.T("setup.option.colima"),
 			Command:     `brew install colima docker && colima start`,
 			Priority:    80,
 		},
 		{
 			Name:        "Podman",
-			Description: "无守护进程的容器引擎",
+			Description: i18n.T("setup.option.podman"),
 			Command:     `brew install podman && podman machine init && podman machine start`,
 			Priority:    70,
 		},
@@ -176,7 +178,7 @@ func (h *HostInfo) getMacOSOptions() []InstallOption {
 	// Recommend OrbStack for Apple Silicon
 	if h.Arch == "arm64" {
 		options[1].Priority = 100
-		options[1].Description = "更快更轻量的 Docker 替代品 (Apple Silicon 推荐)"
+		options[1].Description = i18n.T("setup.option.orbstack_arm64")
 		options[0].Priority = 90
 	}
 
@@ -188,19 +190,19 @@ func (h *HostInfo) getWSLOptions() []InstallOption {
 	return []InstallOption{
 		{
 			Name:        "Docker Desktop (Windows)",
-			Description: "使用 Windows 宿主的 Docker Desktop (推荐)",
-			Command:     `echo "请在 Windows 中安装 Docker Desktop 并启用 WSL 集成"`,
+			Description: i18n.T("setup.option.docker_desktop_wsl"),
+			Command:     "echo " + shellQuote(i18n.T("setup.option.docker_desktop_wsl_cmd")),
 			Priority:    100,
 		},
 		{
-			Name:        "Docker Engine (WSL 内)",
-			Description: "在 WSL 内直接安装 Docker Engine",
+			Name:        i18n.T("setup.option.docker_engine_wsl_name"),
+			Description: i18n.T("setup.option.docker_engine_wsl"),
 			Command:     h.getDockerInstallCmd(),
 			Priority:    80,
 		},
 		{
 			Name:        "Podman",
-			Description: "无守护进程的容器引擎",
+			Description: i18n.T("setup.option.podman"),
 			Command:     h.getPodmanInstallCmd(),
 			Priority:    70,
 		},
@@ -212,13 +214,13 @@ func (h *HostInfo) getLinuxOptions() []InstallOption {
 	return []InstallOption{
 		{
 			Name:        "Docker Engine",
-			Description: "官方 Docker Engine (推荐)",
+			Description: i18n.T("setup.option.docker_engine"),
 			Command:     h.getDockerInstallCmd(),
 			Priority:    100,
 		},
 		{
 			Name:        "Podman",
-			Description: "无守护进程的容器引擎，兼容 Docker CLI",
+			Description: i18n.T("setup.option.podman_linux"),
 			Command:     h.getPodmanInstallCmd(),
 			Priority:    80,
 		},
@@ -269,33 +271,39 @@ func (h *HostInfo) getPodmanInstallCmd() string {
 func (h *HostInfo) FormatHostInfo() string {
 	var sb strings.Builder
 
-	sb.WriteString("🖥️  主机信息\n")
-	sb.WriteString(fmt.Sprintf("   操作系统: %s/%s\n", h.OS, h.Arch))
+	row := func(label, value string) {
+		sb.WriteString(fmt.Sprintf("   %-10s%s\n", label, value))
+	}
+	installed := func(ok bool) string {
+		if ok {
+			return i18n.T("setup.host.installed")
+		}
+		return i18n.T("setup.host.not_installed")
+	}
+
+	sb.WriteString(i18n.T("setup.host.title") + "\n")
+	row(i18n.T("setup.host.os"), h.OS+"/"+h.Arch)
 
 	if h.Distro != "" {
 		distroInfo := h.Distro
 		if h.DistroVer != "" {
 			distroInfo += " " + h.DistroVer
 		}
-		sb.WriteString(fmt.Sprintf("   发行版:   %s\n", distroInfo))
+		row(i18n.T("setup.host.distro"), distroInfo)
 	}
 
 	if h.IsWSL {
-		sb.WriteString("   环境:     WSL (Windows Subsystem for Linux)\n")
+		row(i18n.T("setup.host.environment"), "WSL (Windows Subsystem for Linux)")
 	}
 
-	sb.WriteString("\n📦 容器运行时\n")
-	if h.HasDocker {
-		sb.WriteString("   Docker:   ✅ 已安装\n")
-	} else {
-		sb.WriteString("   Docker:   ❌ 未安装\n")
-	}
-
-	if h.HasPodman {
-		sb.WriteString("   Podman:   ✅ 已安装\n")
-	} else {
-		sb.WriteString("   Podman:   ❌ 未安装\n")
-	}
+	sb.WriteString("\n" + i18n.T("setup.host.runtimes") + "\n")
+	row("Docker:", installed(h.HasDocker))
+	row("Podman:", installed(h.HasPodman))
 
 	return sb.String()
 }
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// WSLPrefix starts the name of backends that run inside a WSL distribution,
+// e.g. "wsl:Ubuntu"
+const WSLPrefix = "wsl:"
+
+// WSLDistro is a WSL distribution installed on a Windows host
+type WSLDistro struct {
+	Name    string `json:"name"`
+	State   string `json:"state"` // Running or Stopped
+	Version int    `json:"version"`
+	Default bool   `json:"default"`
+}
+
+// WSLDistroOf returns the distribution of a "wsl:<distro>" backend name
+func WSLDistroOf(backend string) (string, bool) {
+	if !strings.HasPrefix(backend, WSLPrefix) || len(backend) == len(WSLPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(backend, WSLPrefix), true
+}
+
+// ListWSLDistros lists the WSL distributions, or none when wsl.exe is not
+// available
+func ListWSLDistros() ([]WSLDistro, error) {
+	wsl, err := exec.LookPath("wsl.exe")
+	if err != nil {
+		return nil, nil
+	}
+	out, err := exec.Command(wsl, "--list", "--verbose").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WSL distributions: %w", err)
+	}
+	return parseWSLList(decodeWSLOutput(out)), nil
+}
+
+// decodeWSLOutput decodes the output of wsl.exe, which is UTF-16LE
+func decodeWSLOutput(out []byte) string {
+	if len(out) < 2 || !strings.ContainsRune(string(out), 0) {
+		return string(out)
+	}
+	u := make([]uint16, 0, len(out)/2)
+	for i := 0; i+1 < len(out); i += 2 {
+		u = append(u, uint16(out[i])|uint16(out[i+1])<<8)
+	}
+	return strings.TrimPrefix(string(utf16.Decode(u)), "\uFEFF")
+}
+
+// parseWSLList parses the table printed by 'wsl.exe --list --verbose':
+//
+//	  NAME            STATE           VERSION
+//	* Ubuntu          Running         2
+//	  docker-desktop  Stopped         2
+func parseWSLList(out string) []WSLDistro {
+	var distros []WSLDistro
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	header := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if header {
+			header = false // NAME STATE VERSION, translated on localized Windows
+			continue
+		}
+		isDefault := strings.HasPrefix(strings.TrimSpace(line), "*")
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if len(fields) < 3 {
+			continue
+		}
+		d := WSLDistro{
+			Name:    strings.Join(fields[:len(fields)-2], " "),
+			State:   fields[len(fields)-2],
+			Default: isDefault,
+		}
+		_, _ = fmt.Sscanf(fields[len(fields)-1], "%d", &d.Version)
+		// Docker Desktop's own distributions cannot run user containers
+		if strings.HasPrefix(d.Name, "docker-desktop") {
+			continue
+		}
+		distros = append(distros, d)
+	}
+	return distros
+}
+
+// WSLEngine returns the container engine installed inside a distribution,
+// preferring Podman
+func WSLEngine(distro string) (string, error) {
+	out, err := exec.Command("wsl.exe", "-d", distro, "--exec", "sh", "-c", "command -v podman || command -v docker").Output()
+	if err != nil || strings.TrimSpace(string(out)) == "" {
+		return "", fmt.Errorf("neither podman nor docker is installed in the WSL distribution %q", distro)
+	}
+	line := strings.Fields(string(out))[0]
+	return filepath.Base(strings.ReplaceAll(line, "\\", "/")), nil
+}
+
+// AddWSLBackend registers the engine of a WSL distribution as the backend
+// "wsl:<distro>". The backend is a small .cmd shim running the engine
+// through wsl.exe, so everything that runs the backend's CLI works
+// unchanged.
+func (d *Detector) AddWSLBackend(distro string) (*CustomBackend, error) {
+	engine, err := WSLEngine(distro)
+	if err != nil {
+		return nil, err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(home, ".cm", "wsl", distro)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	shim := filepath.Join(dir, engine+".cmd")
+	script := fmt.Sprintf("@echo off\r\nwsl.exe -d \"%s\" --exec %s %%*\r\n", distro, engine)
+	if err := os.WriteFile(shim, []byte(script), 0755); err != nil {
+		return nil, err
+	}
+
+	backend := &CustomBackend{Name: WSLPrefix + distro, Path: shim, Type: "wsl"}
+	if err := d.AddCustomBackend(backend.Name, backend.Path, backend.Type); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// WSLRuntime runs Podman or Docker inside a WSL distribution through the
+// shim created by AddWSLBackend. Their CLIs agree on everything cm uses.
+type WSLRuntime struct {
+	*PodmanRuntime
+	distro string
+}
+
+// NewWSLRuntime creates a runtime for a "wsl:<distro>" backend
+func NewWSLRuntime(name, path string) (*WSLRuntime, error) {
+	distro, ok := WSLDistroOf(name)
+	if !ok {
+		return nil, fmt.Errorf("invalid WSL backend name %q (expected wsl:<distro>)", name)
+	}
+	return &WSLRuntime{
+		PodmanRuntime: &PodmanRuntime{name: name, path: path},
+		distro:        distro,
+	}, nil
+}
+
+func (r *WSLRuntime) Type() string { return "wsl" }
+
+// Distro returns the WSL distribution the containers run in
+func (r *WSLRuntime) Distro() string { return r.distro }
+
+// WSLLocalhostForwarding reports whether WSL forwards ports listening in
+// the distributions to localhost on Windows. It is on unless .wslconfig
+// turns localhostForwarding off outside mirrored networking.
+func WSLLocalhostForwarding() bool {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return true
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".wslconfig"))
+	if err != nil {
+		return true
+	}
+	return wslLocalhostForwarding(string(data))
+}
+
+func wslLocalhostForwarding(wslconfig string) bool {
+	forwarding, mirrored := true, false
+	section := ""
+	for _, line := range strings.Split(wslconfig, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "wsl2" {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+		switch key {
+		case "localhostforwarding":
+			forwarding = value != "false"
+		case "networkingmode":
+			mirrored = value == "mirrored"
+		}
+	}
+	return forwarding || mirrored
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GrypeScanner scans images with Anchore Grype
+type GrypeScanner struct{}
+
+// NewGrypeScanner creates a new Grype scanner
+func NewGrypeScanner() *GrypeScanner {
+	return &GrypeScanner{}
+}
+
+func (s *GrypeScanner) Name() string {
+	return "grype"
+}
+
+func (s *GrypeScanner) IsAvailable() bool {
+	_, err := exec.LookPath("grype")
+	return err == nil
+}
+
+// Internal Grype JSON structure
+type grypeOutput struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID          string `json:"id"`
+			Severity    string `json:"severity"`
+			Description string `json:"description"`
+			Fix         struct {
+				Versions []string `json:"versions"`
+				State    string   `json:"state"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+func (s *GrypeScanner) Scan(ctx context.Context, image string) (*Report, error) {
+	if !s.IsAvailable() {
+		return nil, fmt.Errorf("grype not found in PATH")
+	}
+
+	cmd := exec.CommandContext(ctx, "grype", image, "-q", "-o", "json")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("grype failed: %s (stderr: %s)", err, string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("grype failed: %w", err)
+	}
+	return parseGrypeOutput(image, output)
+}
+
+func parseGrypeOutput(image string, output []byte) (*Report, error) {
+	var raw grypeOutput
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse grype output: %w", err)
+	}
+
+	var vulns []Vulnerability
+	for _, m := range raw.Matches {
+		severity := strings.ToUpper(m.Vulnerability.Severity)
+		if severity == "NEGLIGIBLE" {
+			severity = SeverityLow
+		}
+		vulns = append(vulns, Vulnerability{
+			VulnerabilityID:  m.Vulnerability.ID,
+			PkgName:          m.Artifact.Name,
+			InstalledVersion: m.Artifact.Version,
+			FixedVersion:     strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:         severity,
+			Description:      m.Vulnerability.Description,
+		})
+	}
+	return newReport(image, "grype", vulns), nil
+}
@@ -0,0 +1,63 @@
+package scan
+
+import "testing"
+
+func TestParseTrivyOutput(t *testing.T) {
+	output := []byte(`{"Results":[
+	  {"Target":"debian","Vulnerabilities":[
+	    {"VulnerabilityID":"CVE-1","PkgName":"openssl","InstalledVersion":"3.0.1","FixedVersion":"3.0.2","Severity":"HIGH"},
+	    {"VulnerabilityID":"CVE-2","PkgName":"zlib","InstalledVersion":"1.2","Severity":"CRITICAL"}
+	  ]},
+	  {"Target":"usr/lib","Vulnerabilities":[
+	    {"VulnerabilityID":"CVE-1","PkgName":"openssl","InstalledVersion":"3.0.1","FixedVersion":"3.0.2","Severity":"HIGH"},
+	    {"VulnerabilityID":"CVE-3","PkgName":"bash","InstalledVersion":"5.1","Severity":"LOW"}
+	  ]},
+	  {"Target":"app","Vulnerabilities":null}
+	]}`)
+
+	report, err := parseTrivyOutput("debian:12", "trivy", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Vulns) != 3 {
+		t.Fatalf("got %d vulnerabilities, want 3 after deduplication", len(report.Vulns))
+	}
+	if report.Vulns[0].VulnerabilityID != "CVE-2" {
+		t.Errorf("most severe should come first, got %s", report.Vulns[0].VulnerabilityID)
+	}
+	if got := report.CountAtOrAbove(SeverityHigh); got != 2 {
+		t.Errorf("CountAtOrAbove(HIGH) = %d, want 2", got)
+	}
+
+	report.DropUnfixed()
+	if len(report.Vulns) != 1 || report.Summary[SeverityCritical] != 0 || report.Summary[SeverityHigh] != 1 {
+		t.Errorf("after DropUnfixed: %+v", report.Summary)
+	}
+}
+
+func TestParseGrypeOutput(t *testing.T) {
+	output := []byte(`{"matches":[
+	  {"vulnerability":{"id":"GHSA-1","severity":"Critical","fix":{"versions":["1.2.3"],"state":"fixed"}},"artifact":{"name":"lodash","version":"1.0.0"}},
+	  {"vulnerability":{"id":"CVE-9","severity":"Negligible","fix":{"versions":[],"state":"not-fixed"}},"artifact":{"name":"tar","version":"1.34"}}
+	]}`)
+
+	report, err := parseGrypeOutput("node:20", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary[SeverityCritical] != 1 || report.Summary[SeverityLow] != 1 {
+		t.Errorf("summary = %v", report.Summary)
+	}
+	if report.Vulns[0].FixedVersion != "1.2.3" || report.Vulns[0].PkgName != "lodash" {
+		t.Errorf("first vulnerability = %+v", report.Vulns[0])
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("high"); err != nil || s != SeverityHigh {
+		t.Errorf("ParseSeverity(high) = %q, %v", s, err)
+	}
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"fmt"
+)
+
+// Scanner names accepted by New
+const (
+	ScannerAuto           = "auto"
+	ScannerTrivy          = "trivy"
+	ScannerGrype          = "grype"
+	ScannerTrivyContainer = "trivy-container"
+)
+
+// New returns the named scanner. "auto" prefers a local trivy, then grype,
+// and finally runs Trivy in a container with the given backend.
+func New(name, backend string) (Scanner, error) {
+	switch name {
+	case "", ScannerAuto:
+		for _, s := range []Scanner{NewTrivyScanner(), NewGrypeScanner(), NewTrivyContainerScanner(backend)} {
+			if s.IsAvailable() {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("no vulnerability scanner found: install trivy or grype, or start %s to run %s", backendOrDocker(backend), TrivyImage)
+	case ScannerTrivy:
+		return NewTrivyScanner(), nil
+	case ScannerGrype:
+		return NewGrypeScanner(), nil
+	case ScannerTrivyContainer:
+		return NewTrivyContainerScanner(backend), nil
+	}
+	return nil, fmt.Errorf("unknown scanner %q (use auto, trivy, grype or trivy-container)", name)
+}
+
+func backendOrDocker(backend string) string {
+	if backend == "" {
+		return "docker"
+	}
+	return backend
+}
@@ -4,18 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
-	"time"
+	"path/filepath"
 )
 
-type TrivyScanner struct{}
+// TrivyImage is the image used when Trivy runs in a container
+const TrivyImage = "aquasec/trivy:latest"
+
+type TrivyScanner struct {
+	// backend runs Trivy from TrivyImage instead of a local binary when set
+	backend string
+}
 
 // NewTrivyScanner creates a new Trivy scanner
 func NewTrivyScanner() *TrivyScanner {
 	return &TrivyScanner{}
 }
 
+// NewTrivyContainerScanner runs Trivy in a container, for machines without
+// the trivy binary. The Docker socket is mounted so it can read local images.
+func NewTrivyContainerScanner(backend string) *TrivyScanner {
+	if backend == "" {
+		backend = "docker"
+	}
+	return &TrivyScanner{backend: backend}
+}
+
+func (s *TrivyScanner) Name() string {
+	if s.backend != "" {
+		return "trivy (" + TrivyImage + ")"
+	}
+	return "trivy"
+}
+
 func (s *TrivyScanner) IsAvailable() bool {
+	if s.backend != "" {
+		return exec.Command(s.backend, "info").Run() == nil
+	}
 	_, err := exec.LookPath("trivy")
 	return err == nil
 }
@@ -30,12 +56,31 @@ type trivyOutput struct {
 
 func (s *TrivyScanner) Scan(ctx context.Context, image string) (*Report, error) {
 	if !s.IsAvailable() {
+		if s.backend != "" {
+			return nil, fmt.Errorf("%s is not running, cannot start %s", s.backend, TrivyImage)
+		}
 		return nil, fmt.Errorf("trivy not found in PATH")
 	}
 
-	// trivy image --format json --output - <image>
+	// trivy image --format json <image>
 	// -q to suppress progress bar
-	cmd := exec.CommandContext(ctx, "trivy", "image", "-q", "--format", "json", image)
+	args := []string{"image", "-q", "--format", "json", image}
+	var cmd *exec.Cmd
+	if s.backend != "" {
+		// Keep the vulnerability database between runs
+		cacheDir := filepath.Join(os.TempDir(), "cm-trivy-cache")
+		if home, err := os.UserHomeDir(); err == nil {
+			cacheDir = filepath.Join(home, ".cm", "cache", "trivy")
+		}
+		_ = os.MkdirAll(cacheDir, 0755)
+		runArgs := []string{"run", "--rm",
+			"-v", "/var/run/docker.sock:/var/run/docker.sock",
+			"-v", cacheDir + ":/root/.cache/",
+			TrivyImage}
+		cmd = exec.CommandContext(ctx, s.backend, append(runArgs, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, "trivy", args...)
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -43,28 +88,19 @@ func (s *TrivyScanner) Scan(ctx context.Context, image string) (*Report, error)
 		}
 		return nil, fmt.Errorf("trivy failed: %w", err)
 	}
+	return parseTrivyOutput(image, s.Name(), output)
+}
 
+func parseTrivyOutput(image, scanner string, output []byte) (*Report, error) {
 	var raw trivyOutput
 	if err := json.Unmarshal(output, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse trivy output: %w", err)
 	}
 
-	report := &Report{
-		Image:     image,
-		ScannedAt: time.Now().Format(time.RFC3339),
-		Summary:   make(map[string]int),
-		Vulns:     []Vulnerability{},
-	}
-
 	// Flatten results
+	var vulns []Vulnerability
 	for _, res := range raw.Results {
-		report.Vulns = append(report.Vulns, res.Vulnerabilities...)
-	}
-
-	// Calculate summary
-	for _, v := range report.Vulns {
-		report.Summary[v.Severity]++
+		vulns = append(vulns, res.Vulnerabilities...)
 	}
-
-	return report, nil
+	return newReport(image, scanner, vulns), nil
 }
@@ -2,6 +2,10 @@ package scan
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Severity levels
@@ -13,6 +17,35 @@ const (
 	SeverityUnknown  = "UNKNOWN"
 )
 
+// Severities lists the levels from most to least severe
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityUnknown}
+
+// SeverityRank orders severities; higher is more severe
+func SeverityRank(severity string) int {
+	switch strings.ToUpper(severity) {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// ParseSeverity validates a severity given on the command line
+func ParseSeverity(s string) (string, error) {
+	severity := strings.ToUpper(strings.TrimSpace(s))
+	for _, known := range Severities {
+		if severity == known {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity %q (use critical, high, medium, low)", s)
+}
+
 // Vulnerability represents a single security issue
 type Vulnerability struct {
 	VulnerabilityID  string `json:"VulnerabilityID"`
@@ -27,13 +60,84 @@ type Vulnerability struct {
 // Report represents a scan result
 type Report struct {
 	Image     string          `json:"image"`
+	Scanner   string          `json:"scanner,omitempty"`
 	Vulns     []Vulnerability `json:"vulnerabilities"`
 	Summary   map[string]int  `json:"summary"` // Severity -> Count
 	ScannedAt string          `json:"scanned_at"`
 }
 
+// newReport deduplicates vulnerabilities reported for several targets,
+// sorts them most severe first and counts them by severity
+func newReport(image, scanner string, vulns []Vulnerability) *Report {
+	report := &Report{
+		Image:     image,
+		Scanner:   scanner,
+		ScannedAt: time.Now().Format(time.RFC3339),
+		Vulns:     []Vulnerability{},
+	}
+	seen := make(map[string]bool)
+	for _, v := range vulns {
+		v.Severity = strings.ToUpper(v.Severity)
+		if SeverityRank(v.Severity) == 0 {
+			v.Severity = SeverityUnknown
+		}
+		key := v.VulnerabilityID + "|" + v.PkgName + "|" + v.InstalledVersion
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		report.Vulns = append(report.Vulns, v)
+	}
+	sort.SliceStable(report.Vulns, func(i, j int) bool {
+		a, b := report.Vulns[i], report.Vulns[j]
+		if SeverityRank(a.Severity) != SeverityRank(b.Severity) {
+			return SeverityRank(a.Severity) > SeverityRank(b.Severity)
+		}
+		if a.PkgName != b.PkgName {
+			return a.PkgName < b.PkgName
+		}
+		return a.VulnerabilityID < b.VulnerabilityID
+	})
+	report.summarize()
+	return report
+}
+
+func (r *Report) summarize() {
+	r.Summary = make(map[string]int)
+	for _, v := range r.Vulns {
+		r.Summary[v.Severity]++
+	}
+}
+
+// DropUnfixed removes vulnerabilities that have no fixed version yet
+func (r *Report) DropUnfixed() {
+	kept := r.Vulns[:0]
+	for _, v := range r.Vulns {
+		if v.FixedVersion != "" {
+			kept = append(kept, v)
+		}
+	}
+	r.Vulns = kept
+	r.summarize()
+}
+
+// CountAtOrAbove counts vulnerabilities of the given severity or worse
+func (r *Report) CountAtOrAbove(severity string) int {
+	min := SeverityRank(severity)
+	count := 0
+	for _, v := range r.Vulns {
+		if SeverityRank(v.Severity) >= min {
+			count++
+		}
+	}
+	return count
+}
+
 // Scanner defines the interface for security scanners
 type Scanner interface {
+	// Name identifies the scanner in reports
+	Name() string
+
 	// Scan scans an image and returns a report
 	Scan(ctx context.Context, image string) (*Report, error)
 
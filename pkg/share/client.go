@@ -0,0 +1,239 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPassword is returned by Connect when the password is missing or wrong
+var ErrPassword = errors.New("the share needs a password (wrong or missing)")
+
+// Options describe a share to start
+type Options struct {
+	Relay     string        `json:"relay"`           // e.g. https://relay.container-maker.dev
+	Token     string        `json:"token,omitempty"` // Relay credentials
+	Project   string        `json:"project"`
+	Container string        `json:"container"`
+	Backend   string        `json:"backend"` // CLI used to reach sshd in the container
+	Targets   []Target      `json:"targets"`
+	TTL       time.Duration `json:"ttl"`
+	Password  string        `json:"password,omitempty"`
+}
+
+// Tunnel is a share registered with a relay
+type Tunnel struct {
+	Session *Session
+	opts    Options
+	control *websocket.Conn
+}
+
+// Open registers a share with the relay. Serve must be called to answer
+// visitors.
+func Open(ctx context.Context, opts Options) (*Tunnel, error) {
+	if len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("nothing to share")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	base, err := wsURL(opts.Relay)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, base+tunnelPath, opts.header())
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("relay %s refused the credentials", opts.Relay)
+		}
+		return nil, fmt.Errorf("failed to reach relay %s: %w", opts.Relay, err)
+	}
+
+	reg := registerRequest{Targets: opts.Targets, ExpiresAt: time.Now().Add(opts.TTL)}
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			ws.Close()
+			return nil, err
+		}
+		reg.PasswordHash = string(hash)
+	}
+	var reply registerResponse
+	if err := ws.WriteJSON(reg); err == nil {
+		err = ws.ReadJSON(&reply)
+	}
+	if err != nil || reply.Error != "" {
+		ws.Close()
+		if reply.Error != "" {
+			return nil, fmt.Errorf("relay refused the share: %s", reply.Error)
+		}
+		return nil, fmt.Errorf("failed to register with relay: %w", err)
+	}
+
+	return &Tunnel{
+		Session: &Session{
+			ID:        reply.ID,
+			Relay:     opts.Relay,
+			Project:   opts.Project,
+			Container: opts.Container,
+			Backend:   opts.Backend,
+			Targets:   opts.Targets,
+			Links:     reply.Links,
+			Protected: opts.Password != "",
+			ExpiresAt: reg.ExpiresAt,
+			StartedAt: time.Now(),
+			PID:       os.Getpid(),
+		},
+		opts:    opts,
+		control: ws,
+	}, nil
+}
+
+func (o *Options) header() http.Header {
+	header := http.Header{}
+	if o.Token != "" {
+		header.Set("Authorization", "Bearer "+o.Token)
+	}
+	return header
+}
+
+// Serve answers visitors until ctx is cancelled, the share expires or the
+// relay goes away
+func (t *Tunnel) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithDeadline(ctx, t.Session.ExpiresAt)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		t.control.Close()
+	}()
+
+	for {
+		var open openRequest
+		if err := t.control.ReadJSON(&open); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("lost the relay: %w", err)
+		}
+		go t.answer(ctx, open)
+	}
+}
+
+// Close ends the share
+func (t *Tunnel) Close() error {
+	return t.control.Close()
+}
+
+// answer connects a visitor's stream to its target
+func (t *Tunnel) answer(ctx context.Context, open openRequest) {
+	var target *Target
+	for i := range t.Session.Targets {
+		if t.Session.Targets[i].Name == open.Target {
+			target = &t.Session.Targets[i]
+		}
+	}
+	if target == nil {
+		return
+	}
+
+	base, err := wsURL(t.opts.Relay)
+	if err != nil {
+		return
+	}
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, base+streamPath+t.Session.ID+"/"+open.Stream, t.opts.header())
+	if err != nil {
+		return
+	}
+	stream := newWSConn(ws)
+
+	local, err := t.dial(ctx, *target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "share %s: %v\n", target.Name, err)
+		stream.Close()
+		return
+	}
+	Pipe(stream, local)
+}
+
+// dial opens the local end of a target
+func (t *Tunnel) dial(ctx context.Context, target Target) (io.ReadWriteCloser, error) {
+	if target.Kind == KindSSH {
+		return t.sshd(ctx)
+	}
+	return net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(target.Port)), 5*time.Second)
+}
+
+// sshd runs sshd in inetd mode inside the container, speaking SSH over its
+// stdin and stdout, so the container needs sshd but no published port
+func (t *Tunnel) sshd(ctx context.Context) (io.ReadWriteCloser, error) {
+	cmd := exec.CommandContext(ctx, t.opts.Backend, "exec", "-i", "-u", "root", t.opts.Container, "/usr/sbin/sshd", "-i", "-e")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start sshd in %s: %w", t.opts.Container, err)
+	}
+	return &cmdConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// cmdConn is a stream over a process's stdin and stdout
+type cmdConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *cmdConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *cmdConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *cmdConn) Close() error {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+// Connect opens a raw stream to a shared target from its link, for TCP and
+// SSH shares; password is needed for protected shares
+func Connect(ctx context.Context, link, password string) (net.Conn, error) {
+	u, err := wsURL(link)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set(streamHeader, "1")
+	if password != "" {
+		header.Set(PasswordHeader, password)
+	}
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil {
+			switch resp.StatusCode {
+			case http.StatusUnauthorized:
+				return nil, ErrPassword
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("share not found or expired")
+			}
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", link, err)
+	}
+	return newWSConn(ws), nil
+}
@@ -0,0 +1,400 @@
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Relay endpoints. Sharing clients connect to tunnelPath and open a stream
+// under streamPath for each visitor connection; visitors use the links,
+// linkPath/<id>/<target>/.
+const (
+	tunnelPath = "/v1/tunnels"
+	streamPath = "/v1/streams/"
+	linkPath   = "/s/"
+)
+
+// PasswordHeader carries a share password for 'cm share connect'; browsers
+// use basic authentication instead
+const PasswordHeader = "X-CM-Share-Password"
+
+// streamHeader asks for a raw stream to a target, even an HTTP one
+const streamHeader = "X-CM-Share-Stream"
+
+// streamTimeout bounds how long the relay waits for the sharing side to
+// answer a visitor
+const streamTimeout = 10 * time.Second
+
+var errUnsupportedScheme = errors.New("unsupported scheme, use https:// or http://")
+
+// registerRequest is the first message of a sharing client
+type registerRequest struct {
+	Targets      []Target  `json:"targets"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	PasswordHash string    `json:"passwordHash,omitempty"` // bcrypt
+}
+
+// registerResponse is the relay's answer
+type registerResponse struct {
+	ID    string            `json:"id,omitempty"`
+	Links map[string]string `json:"links,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+// openRequest asks the sharing client to open a stream for a visitor
+type openRequest struct {
+	Stream string `json:"stream"`
+	Target string `json:"target"`
+}
+
+// RelayOptions configure a relay
+type RelayOptions struct {
+	PublicURL string        // Base of the links, e.g. https://share.example.com; derived from requests if empty
+	Token     string        // Required from sharing clients as a bearer token when set
+	MaxTTL    time.Duration // Upper bound for share lifetimes; 0 means none
+}
+
+// Relay is a self-hostable share relay
+type Relay struct {
+	opts     RelayOptions
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	tunnels map[string]*relayTunnel
+}
+
+// relayTunnel is one registered share
+type relayTunnel struct {
+	id           string
+	targets      map[string]Target
+	expiresAt    time.Time
+	passwordHash []byte
+	transports   map[string]*http.Transport // HTTP targets
+
+	control *websocket.Conn
+	wmu     sync.Mutex // Serializes writes to control
+
+	mu      sync.Mutex
+	pending map[string]chan *websocket.Conn
+}
+
+// NewRelay creates a relay
+func NewRelay(opts RelayOptions) *Relay {
+	return &Relay{
+		opts:     opts,
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		tunnels:  make(map[string]*relayTunnel),
+	}
+}
+
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == tunnelPath:
+		r.handleTunnel(w, req)
+	case strings.HasPrefix(req.URL.Path, streamPath):
+		r.handleStream(w, req)
+	case strings.HasPrefix(req.URL.Path, linkPath):
+		r.handleVisitor(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// handleTunnel registers a share and relays open requests to it until the
+// client disconnects or the share expires
+func (r *Relay) handleTunnel(w http.ResponseWriter, req *http.Request) {
+	if r.opts.Token != "" && subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+r.opts.Token)) != 1 {
+		http.Error(w, "invalid relay token", http.StatusUnauthorized)
+		return
+	}
+	ws, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	var reg registerRequest
+	if err := ws.ReadJSON(&reg); err != nil {
+		return
+	}
+	t, err := r.register(reg, ws)
+	if err != nil {
+		_ = ws.WriteJSON(registerResponse{Error: err.Error()})
+		return
+	}
+	defer r.unregister(t)
+
+	base := r.publicURL(req)
+	links := make(map[string]string, len(reg.Targets))
+	for _, target := range reg.Targets {
+		link := base + linkPath + t.id + "/" + target.Name
+		if target.Kind == KindHTTP {
+			link += "/"
+		}
+		links[target.Name] = link
+	}
+	t.wmu.Lock()
+	err = ws.WriteJSON(registerResponse{ID: t.id, Links: links})
+	t.wmu.Unlock()
+	if err != nil {
+		return
+	}
+
+	// Close the tunnel at expiry and keep idle proxies from dropping it
+	expired := time.AfterFunc(time.Until(t.expiresAt), func() { ws.Close() })
+	defer expired.Stop()
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			t.wmu.Lock()
+			err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+			t.wmu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		if _, _, err := ws.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// register validates a share and assigns it an ID
+func (r *Relay) register(reg registerRequest, ws *websocket.Conn) (*relayTunnel, error) {
+	if len(reg.Targets) == 0 {
+		return nil, fmt.Errorf("nothing to share")
+	}
+	if !reg.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("share already expired")
+	}
+	if r.opts.MaxTTL > 0 && time.Until(reg.ExpiresAt) > r.opts.MaxTTL {
+		return nil, fmt.Errorf("shares on this relay last at most %s", r.opts.MaxTTL)
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	t := &relayTunnel{
+		id:         id,
+		targets:    make(map[string]Target),
+		expiresAt:  reg.ExpiresAt,
+		transports: make(map[string]*http.Transport),
+		control:    ws,
+		pending:    make(map[string]chan *websocket.Conn),
+	}
+	if reg.PasswordHash != "" {
+		t.passwordHash = []byte(reg.PasswordHash)
+	}
+	for _, target := range reg.Targets {
+		if target.Name == "" || strings.Contains(target.Name, "/") {
+			return nil, fmt.Errorf("invalid target name %q", target.Name)
+		}
+		t.targets[target.Name] = target
+		if target.Kind == KindHTTP {
+			name := target.Name
+			t.transports[name] = &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return t.open(ctx, name)
+				},
+				IdleConnTimeout: time.Minute,
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.tunnels[id] = t
+	r.mu.Unlock()
+	return t, nil
+}
+
+func (r *Relay) unregister(t *relayTunnel) {
+	r.mu.Lock()
+	delete(r.tunnels, t.id)
+	r.mu.Unlock()
+	for _, tr := range t.transports {
+		tr.CloseIdleConnections()
+	}
+}
+
+// lookup returns a live share
+func (r *Relay) lookup(id string) *relayTunnel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tunnels[id]
+	if t == nil || time.Now().After(t.expiresAt) {
+		return nil
+	}
+	return t
+}
+
+// publicURL is the base of the links handed out
+func (r *Relay) publicURL(req *http.Request) string {
+	if r.opts.PublicURL != "" {
+		return strings.TrimRight(r.opts.PublicURL, "/")
+	}
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// open asks the sharing client for a stream to a target and waits for it
+func (t *relayTunnel) open(ctx context.Context, target string) (net.Conn, error) {
+	stream, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *websocket.Conn, 1)
+	t.mu.Lock()
+	t.pending[stream] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, stream)
+		t.mu.Unlock()
+	}()
+
+	t.wmu.Lock()
+	err = t.control.WriteJSON(openRequest{Stream: stream, Target: target})
+	t.wmu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("share is gone: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, streamTimeout)
+	defer cancel()
+	select {
+	case ws := <-ch:
+		return newWSConn(ws), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("share did not answer: %w", ctx.Err())
+	}
+}
+
+// handleStream accepts a stream the sharing client opened for a visitor
+func (r *Relay) handleStream(w http.ResponseWriter, req *http.Request) {
+	id, stream, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, streamPath), "/")
+	t := r.lookup(id)
+	if t == nil {
+		http.NotFound(w, req)
+		return
+	}
+	t.mu.Lock()
+	ch := t.pending[stream]
+	delete(t.pending, stream)
+	t.mu.Unlock()
+	if ch == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	ws, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	ch <- ws
+}
+
+// handleVisitor serves a link: HTTP targets through a reverse proxy, and a
+// raw stream for WebSocket upgrades (see Connect)
+func (r *Relay) handleVisitor(w http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, linkPath), "/", 3)
+	if len(parts) < 2 {
+		http.NotFound(w, req)
+		return
+	}
+	t := r.lookup(parts[0])
+	if t == nil {
+		http.Error(w, "share not found or expired", http.StatusNotFound)
+		return
+	}
+	target, ok := t.targets[parts[1]]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if !t.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="cm share"`)
+		http.Error(w, "password required", http.StatusUnauthorized)
+		return
+	}
+
+	if req.Header.Get(streamHeader) != "" || target.Kind != KindHTTP {
+		if !websocket.IsWebSocketUpgrade(req) {
+			http.Error(w, fmt.Sprintf("this is a %s share; connect with 'cm share connect %s'", target.Kind, req.URL.String()), http.StatusBadRequest)
+			return
+		}
+		visitor, err := r.upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		stream, err := t.open(req.Context(), target.Name)
+		if err != nil {
+			visitor.Close()
+			return
+		}
+		Pipe(newWSConn(visitor), stream)
+		return
+	}
+
+	if len(parts) == 2 {
+		http.Redirect(w, req, req.URL.Path+"/", http.StatusFound)
+		return
+	}
+	proxy := &httputil.ReverseProxy{
+		Transport: t.transports[target.Name],
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = "localhost:" + target.Name
+			pr.Out.URL.Path = "/" + parts[2]
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = pr.Out.URL.Host
+			pr.Out.Header.Del(PasswordHeader)
+			if t.passwordHash != nil {
+				pr.Out.Header.Del("Authorization")
+			}
+			pr.SetXForwarded()
+		},
+	}
+	proxy.ServeHTTP(w, req)
+}
+
+// authorized checks the share password, if there is one
+func (t *relayTunnel) authorized(req *http.Request) bool {
+	if t.passwordHash == nil {
+		return true
+	}
+	password := req.Header.Get(PasswordHeader)
+	if password == "" {
+		_, password, _ = req.BasicAuth()
+	}
+	return password != "" && bcrypt.CompareHashAndPassword(t.passwordHash, []byte(password)) == nil
+}
+
+// randomID returns an unguessable identifier
+func randomID() (string, error) {
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.EncodeToString(b)), nil
+}
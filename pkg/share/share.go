@@ -0,0 +1,182 @@
+// Package share exposes a running dev container to other people through a
+// relay: selected forwarded ports as HTTP links or raw TCP, and an SSH
+// endpoint served by sshd inside the container. The sharing side keeps one
+// WebSocket open to the relay and opens another for every visitor
+// connection, so nothing listens on the host. Links are unguessable, expire,
+// and may require a password; with an https relay every hop is TLS.
+package share
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Target kinds
+const (
+	KindHTTP = "http" // Proxied as a web link
+	KindTCP  = "tcp"  // Raw stream, reached with 'cm share connect'
+	KindSSH  = "ssh"  // sshd -i inside the container
+)
+
+// DefaultTTL is how long a share lives unless asked otherwise
+const DefaultTTL = 2 * time.Hour
+
+// Target is one thing a share exposes
+type Target struct {
+	Name string `json:"name"`           // "3000" or "ssh"; the last element of its link
+	Kind string `json:"kind"`           // http, tcp or ssh
+	Port int    `json:"port,omitempty"` // Host port it is published on (http and tcp)
+}
+
+// ParseTarget parses a port argument: "3000" (HTTP), "5432/tcp" or "ssh"
+func ParseTarget(arg string) (Target, error) {
+	if arg == KindSSH {
+		return Target{Name: KindSSH, Kind: KindSSH}, nil
+	}
+	port, kind := arg, KindHTTP
+	if i := strings.Index(arg, "/"); i >= 0 {
+		port, kind = arg[:i], arg[i+1:]
+	}
+	if kind != KindHTTP && kind != KindTCP {
+		return Target{}, fmt.Errorf("invalid target %q: use PORT, PORT/http, PORT/tcp or ssh", arg)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return Target{}, fmt.Errorf("invalid port in %q", arg)
+	}
+	return Target{Name: port, Kind: kind}, nil
+}
+
+// Session is a running share, recorded in ~/.cm/shares/<id>.json
+type Session struct {
+	ID        string            `json:"id"`
+	Relay     string            `json:"relay"`
+	Project   string            `json:"project"`
+	Container string            `json:"container"`
+	Backend   string            `json:"backend"`
+	Targets   []Target          `json:"targets"`
+	Links     map[string]string `json:"links"` // Target name -> link
+	Protected bool              `json:"protected"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	StartedAt time.Time         `json:"startedAt"`
+	PID       int               `json:"pid"`
+}
+
+// Dir returns the directory holding the session files
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cm", "shares"), nil
+}
+
+func sessionPath(id string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// Save records the session
+func (s *Session) Save() error {
+	path, err := sessionPath(s.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Remove deletes the session file
+func (s *Session) Remove() error {
+	path, err := sessionPath(s.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// alive reports whether the process serving the session still runs
+func (s *Session) alive() bool {
+	if s.PID <= 0 || time.Now().After(s.ExpiresAt) {
+		return false
+	}
+	proc, err := os.FindProcess(s.PID)
+	return err == nil && proc.Signal(syscall.Signal(0)) == nil
+}
+
+// Stop ends the share and forgets it
+func (s *Session) Stop() error {
+	if s.alive() {
+		if proc, err := os.FindProcess(s.PID); err == nil {
+			if err := proc.Signal(os.Interrupt); err != nil {
+				_ = proc.Kill()
+			}
+		}
+	}
+	return s.Remove()
+}
+
+// List returns the running shares, oldest first, and removes the records of
+// those that ended
+func List() ([]*Session, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	var sessions []*Session
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil || !s.alive() {
+			_ = os.Remove(path)
+			continue
+		}
+		sessions = append(sessions, &s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.Before(sessions[j].StartedAt) })
+	return sessions, nil
+}
+
+// Find returns the running share whose ID starts with prefix
+func Find(prefix string) (*Session, error) {
+	sessions, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var found *Session
+	for _, s := range sessions {
+		if strings.HasPrefix(s.ID, prefix) {
+			if found != nil {
+				return nil, fmt.Errorf("share ID %q is ambiguous", prefix)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no running share %q", prefix)
+	}
+	return found, nil
+}
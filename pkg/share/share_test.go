@@ -0,0 +1,175 @@
+package share
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    Target
+		wantErr bool
+	}{
+		{"3000", Target{Name: "3000", Kind: KindHTTP}, false},
+		{"5432/tcp", Target{Name: "5432", Kind: KindTCP}, false},
+		{"8080/http", Target{Name: "8080", Kind: KindHTTP}, false},
+		{"ssh", Target{Name: "ssh", Kind: KindSSH}, false},
+		{"70000", Target{}, true},
+		{"53/udp", Target{}, true},
+		{"web", Target{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTarget(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTarget(%q) = %+v, want %+v", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestInsecure(t *testing.T) {
+	tests := map[string]bool{
+		"https://relay.example.com": false,
+		"http://localhost:8080":     false,
+		"http://127.0.0.1:8080":     false,
+		"http://relay.example.com":  true,
+	}
+	for relay, want := range tests {
+		if got := Insecure(relay); got != want {
+			t.Errorf("Insecure(%q) = %v, want %v", relay, got, want)
+		}
+	}
+}
+
+// startShare runs a relay and shares the given targets through it
+func startShare(t *testing.T, password string, targets ...Target) *Tunnel {
+	t.Helper()
+	relay := httptest.NewServer(NewRelay(RelayOptions{Token: "secret"}))
+	t.Cleanup(relay.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tunnel, err := Open(ctx, Options{Relay: relay.URL, Token: "secret", Targets: targets, TTL: time.Minute, Password: password})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	go func() { _ = tunnel.Serve(ctx) }()
+	return tunnel
+}
+
+func listen(t *testing.T, serve func(net.Listener)) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go serve(l)
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestShareTCP(t *testing.T) {
+	port := listen(t, func(l net.Listener) {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	})
+	tunnel := startShare(t, "hunter2", Target{Name: strconv.Itoa(port), Kind: KindTCP, Port: port})
+	link := tunnel.Session.Links[strconv.Itoa(port)]
+
+	if _, err := Connect(context.Background(), link, ""); err != ErrPassword {
+		t.Fatalf("Connect without password: got %v, want ErrPassword", err)
+	}
+
+	conn, err := Connect(context.Background(), link, "hunter2")
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintln(conn, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+}
+
+func TestShareHTTP(t *testing.T) {
+	port := listen(t, func(l net.Listener) {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "path=%s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}))
+	})
+	tunnel := startShare(t, "hunter2", Target{Name: strconv.Itoa(port), Kind: KindHTTP, Port: port})
+	link := tunnel.Session.Links[strconv.Itoa(port)]
+	if !strings.HasSuffix(link, "/") {
+		t.Fatalf("HTTP link %q should end with a slash", link)
+	}
+
+	resp, err := http.Get(link + "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without password: status %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", link+"api?x=1", nil)
+	req.SetBasicAuth("", "hunter2")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `path=/api auth=""` {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestShareExpired(t *testing.T) {
+	relay := httptest.NewServer(NewRelay(RelayOptions{MaxTTL: time.Hour}))
+	defer relay.Close()
+
+	_, err := Open(context.Background(), Options{Relay: relay.URL, Targets: []Target{{Name: "ssh", Kind: KindSSH}}, TTL: 2 * time.Hour})
+	if err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Fatalf("Open with a TTL above MaxTTL: got %v", err)
+	}
+
+	_, err = Open(context.Background(), Options{Relay: relay.URL, Token: "wrong", Targets: []Target{{Name: "ssh", Kind: KindSSH}}, TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("a relay without a token accepts any client: %v", err)
+	}
+
+	resp, err := http.Get(relay.URL + linkPath + "unknown/3000/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown share: status %d, want 404", resp.StatusCode)
+	}
+}
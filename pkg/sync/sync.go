@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +20,7 @@ import (
 type SyncConfig struct {
 	LocalPath       string        // Local directory to sync
 	RemoteHost      string        // SSH host (user@host)
+	SSHPort         int           // SSH port, if not 22
 	RemotePath      string        // Remote directory path
 	ExcludePatterns []string      // Patterns to exclude (e.g., .git, node_modules)
 	SyncInterval    time.Duration // Interval for periodic sync (0 = watch mode only)
@@ -152,26 +154,50 @@ func (s *Syncer) Stop() {
 
 // SyncToRemote performs a one-way sync from local to remote
 func (s *Syncer) SyncToRemote() error {
-	return s.rsync(s.config.LocalPath, fmt.Sprintf("%s:%s", s.config.RemoteHost, s.config.RemotePath))
+	return s.rsync(s.config.LocalPath, fmt.Sprintf("%s:%s", s.config.RemoteHost, s.config.RemotePath), true)
 }
 
 // SyncFromRemote performs a one-way sync from remote to local
 func (s *Syncer) SyncFromRemote() error {
-	return s.rsync(fmt.Sprintf("%s:%s", s.config.RemoteHost, s.config.RemotePath), s.config.LocalPath)
+	return s.rsync(fmt.Sprintf("%s:%s", s.config.RemoteHost, s.config.RemotePath), s.config.LocalPath, true)
 }
 
-// rsync executes rsync with appropriate flags
-func (s *Syncer) rsync(src, dst string) error {
+// FetchFromRemote copies a file or directory at a path relative to
+// RemotePath to the same path under LocalPath, leaving other local files
+// alone. Exclude patterns don't apply, so build outputs such as bin can be
+// fetched.
+func (s *Syncer) FetchFromRemote(path string) error {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." || filepath.IsAbs(path) || strings.HasPrefix(path, "../") || path == ".." {
+		return fmt.Errorf("%s is not a path inside the workspace", path)
+	}
+	localDir := filepath.Join(s.config.LocalPath, filepath.Dir(path))
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return err
+	}
+	src := fmt.Sprintf("%s:%s/%s", s.config.RemoteHost, strings.TrimSuffix(s.config.RemotePath, "/"), path)
+	return s.rsync(src, localDir+string(filepath.Separator), false)
+}
+
+// rsync executes rsync with appropriate flags; mirror deletes files missing
+// from src and applies the exclude patterns
+func (s *Syncer) rsync(src, dst string, mirror bool) error {
+	sshCmd := "ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
+	if s.config.SSHPort != 0 {
+		sshCmd += fmt.Sprintf(" -p %d", s.config.SSHPort)
+	}
 	args := []string{
 		"-avz",       // Archive, verbose, compress
-		"--delete",   // Delete extraneous files from dest
 		"--progress", // Show progress
-		"-e", "ssh -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
+		"-e", sshCmd,
 	}
 
-	// Add exclude patterns
-	for _, pattern := range s.config.ExcludePatterns {
-		args = append(args, "--exclude", pattern)
+	if mirror {
+		// Delete extraneous files from dest
+		args = append(args, "--delete")
+		for _, pattern := range s.config.ExcludePatterns {
+			args = append(args, "--exclude", pattern)
+		}
 	}
 
 	// Ensure trailing slash for directory sync
@@ -220,8 +246,11 @@ func (s *Syncer) rsync(src, dst string) error {
 			"-r",
 			"-o", "StrictHostKeyChecking=no",
 			"-o", "UserKnownHostsFile=/dev/null",
-			src, dst,
 		}
+		if s.config.SSHPort != 0 {
+			scpArgs = append(scpArgs, "-P", strconv.Itoa(s.config.SSHPort))
+		}
+		scpArgs = append(scpArgs, src, dst)
 		cmd = exec.Command("scp", scpArgs...)
 	} else {
 		cmd = exec.Command(rsyncPath, args...)
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
 )
 
@@ -47,6 +48,12 @@ func SyncRepository(ctx context.Context, repo *userconfig.TeamRepository) SyncRe
 		UpdatedAt: time.Now(),
 	}
 
+	if offline.Enabled() {
+		result.Success = false
+		result.Message = offline.Missing("template source", repo.Name).Error()
+		return result
+	}
+
 	// Get cache directory
 	repoDir, err := GetRepoCacheDir(repo.Name)
 	if err != nil {
@@ -240,3 +247,15 @@ func ClearAllCaches() error {
 	}
 	return os.RemoveAll(cacheDir)
 }
+
+// RepoNameFromURL derives a short source name (the org/owner) from a git URL,
+// e.g. git@github.com:org/cm-templates.git -> org
+func RepoNameFromURL(url string) string {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	trimmed = strings.ReplaceAll(trimmed, ":", "/")
+	parts := strings.Split(trimmed, "/")
+	if len(parts) >= 2 && parts[len(parts)-2] != "" {
+		return strings.ToLower(parts[len(parts)-2])
+	}
+	return strings.ToLower(parts[len(parts)-1])
+}
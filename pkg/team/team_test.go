@@ -167,3 +167,17 @@ func TestGetAuditLog_NoFile(t *testing.T) {
 		t.Errorf("GetAuditLog() returned %d entries, want 0", len(entries))
 	}
 }
+
+func TestRepoNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:org/cm-templates.git":        "org",
+		"https://github.com/MyCompany/templates":     "mycompany",
+		"https://gitlab.com/group/sub/templates.git": "sub",
+		"templates": "templates",
+	}
+	for url, want := range tests {
+		if got := RepoNameFromURL(url); got != want {
+			t.Errorf("RepoNameFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -1,16 +1,39 @@
 package template
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
 )
 
+// DefaultMarketplaceIndexURL is the community template index used when none is configured
+const DefaultMarketplaceIndexURL = "https://raw.githubusercontent.com/UPwith-me/cm-marketplace/main/index.json"
+
+// DefaultMarketplacePublicKey is the base64 ed25519 key the community index
+// is signed with. Other indexes need marketplace.public_key.
+const DefaultMarketplacePublicKey = "6697iTfJLAseFjv4dvgXCl+hZBwJzowI7heAFx6fhRs="
+
+// ErrBadSignature is returned when an index's signature doesn't verify;
+// unverified data is never used in its place
+var ErrBadSignature = errors.New("marketplace index signature verification failed")
+
+// marketplaceCacheTTL controls how long a fetched index is reused
+const marketplaceCacheTTL = 24 * time.Hour
+
 // MarketplaceTemplate represents a template in the marketplace
 type MarketplaceTemplate struct {
 	ID          string    `json:"id"`
@@ -18,29 +41,80 @@ type MarketplaceTemplate struct {
 	Author      string    `json:"author"`
 	Description string    `json:"description"`
 	Category    string    `json:"category"`
+	Version     string    `json:"version,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
 	Stars       int       `json:"stars"`
 	Downloads   int       `json:"downloads"`
 	URL         string    `json:"url"`
+	SHA256      string    `json:"sha256,omitempty"` // Checksum of the file at URL
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// MarketplaceIndex is the JSON document served at the index URL.
+// A detached ed25519 signature of the raw bytes is served at URL + ".sig".
+type MarketplaceIndex struct {
+	Version   int                   `json:"version"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	Templates []MarketplaceTemplate `json:"templates"`
+}
+
+// InstalledTemplate records a marketplace install for later updates
+type InstalledTemplate struct {
+	ID          string    `json:"id"`
+	Version     string    `json:"version,omitempty"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// MarketplaceOptions configures the marketplace client
+type MarketplaceOptions struct {
+	IndexURL  string // Empty uses DefaultMarketplaceIndexURL
+	PublicKey string // Base64 ed25519 key the index must be signed with; empty uses DefaultMarketplacePublicKey for the default index
+	Refresh   bool   // Ignore the cached index
+}
+
 // Marketplace provides access to community templates
 type Marketplace struct {
-	baseURL   string
+	indexURL  string
+	publicKey string
+	refresh   bool
 	cacheDir  string
+	client    *http.Client
 	templates []MarketplaceTemplate
+	source    string // Where the loaded index came from (for display)
 }
 
-// NewMarketplace creates a new marketplace client
+// NewMarketplace creates a new marketplace client with default options
 func NewMarketplace() *Marketplace {
+	return NewMarketplaceWithOptions(MarketplaceOptions{})
+}
+
+// NewMarketplaceWithOptions creates a marketplace client
+func NewMarketplaceWithOptions(opts MarketplaceOptions) *Marketplace {
 	home, _ := os.UserHomeDir()
+	indexURL := opts.IndexURL
+	if indexURL == "" {
+		indexURL = DefaultMarketplaceIndexURL
+	}
+	publicKey := opts.PublicKey
+	if publicKey == "" && indexURL == DefaultMarketplaceIndexURL {
+		publicKey = DefaultMarketplacePublicKey
+	}
 	return &Marketplace{
-		baseURL:  "https://raw.githubusercontent.com/devcontainers/templates/main",
-		cacheDir: filepath.Join(home, ".cm", "marketplace"),
+		indexURL:  indexURL,
+		publicKey: publicKey,
+		refresh:   opts.Refresh,
+		cacheDir:  filepath.Join(home, ".cm", "marketplace"),
+		client:    httpclient.New(httpclient.Options{Timeout: 30 * time.Second}),
 	}
 }
 
+// Source describes where templates were loaded from
+func (m *Marketplace) Source() string {
+	return m.source
+}
+
 // Search searches for templates in the marketplace
 func (m *Marketplace) Search(query string) ([]MarketplaceTemplate, error) {
 	// Load cached templates or fetch from remote
@@ -57,7 +131,8 @@ func (m *Marketplace) Search(query string) ([]MarketplaceTemplate, error) {
 	for _, t := range m.templates {
 		if strings.Contains(strings.ToLower(t.Name), query) ||
 			strings.Contains(strings.ToLower(t.Description), query) ||
-			strings.Contains(strings.ToLower(t.Category), query) {
+			strings.Contains(strings.ToLower(t.Category), query) ||
+			strings.Contains(strings.ToLower(strings.Join(t.Tags, " ")), query) {
 			results = append(results, t)
 		}
 	}
@@ -80,187 +155,402 @@ func (m *Marketplace) GetTemplate(id string) (*MarketplaceTemplate, error) {
 	return nil, fmt.Errorf("template not found: %s", id)
 }
 
-// Install downloads and installs a marketplace template
-func (m *Marketplace) Install(id, targetDir string) error {
+// Install downloads a marketplace template, verifies its checksum and saves it
+// to ~/.cm/templates so it can be used with 'cm template use <id>'.
+func (m *Marketplace) Install(id string) (*Template, error) {
+	if !validTemplateID(id) {
+		return nil, fmt.Errorf("invalid template ID %q", id)
+	}
 	tmpl, err := m.GetTemplate(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if tmpl.SHA256 == "" {
+		return nil, fmt.Errorf("template %s has no checksum in the index; refusing to install it", id)
 	}
 
-	// Download template with retries
-	var resp *http.Response
-	for i := 0; i < 3; i++ {
-		resp, err = http.Get(tmpl.URL)
-		if err == nil && resp.StatusCode == 200 {
-			break
+	content, err := m.download(tmpl.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(tmpl.SHA256, checksum) {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", id, tmpl.SHA256, checksum)
+	}
+
+	t, err := templateFromMarketplaceContent(tmpl, content)
+	if err != nil {
+		return nil, err
+	}
+
+	templatesDir := GetTemplatesDir()
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(templatesDir, id+".json"), data, 0644); err != nil {
+		return nil, err
+	}
+
+	installed, _ := m.LoadInstalled()
+	installed[id] = InstalledTemplate{
+		ID:          id,
+		Version:     tmpl.Version,
+		SHA256:      checksum,
+		InstalledAt: time.Now(),
+	}
+	if err := m.saveInstalled(installed); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// validTemplateID reports whether an ID is a single path element, safe to
+// name a file in ~/.cm/templates
+func validTemplateID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\:`) && filepath.Clean(id) == id
+}
+
+// Updates returns installed templates whose index entry has changed
+func (m *Marketplace) Updates() ([]MarketplaceTemplate, error) {
+	if err := m.loadTemplates(); err != nil {
+		return nil, err
+	}
+	installed, err := m.LoadInstalled()
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []MarketplaceTemplate
+	for _, t := range m.templates {
+		inst, ok := installed[t.ID]
+		if !ok {
+			continue
 		}
-		if resp != nil {
-			resp.Body.Close()
+		if (t.Version != "" && t.Version != inst.Version) ||
+			(t.SHA256 != "" && !strings.EqualFold(t.SHA256, inst.SHA256)) {
+			updates = append(updates, t)
 		}
-		time.Sleep(time.Duration(i+1) * time.Second)
 	}
+	return updates, nil
+}
 
+// LoadInstalled returns the install records keyed by template ID
+func (m *Marketplace) LoadInstalled() (map[string]InstalledTemplate, error) {
+	installed := make(map[string]InstalledTemplate)
+	data, err := os.ReadFile(filepath.Join(m.cacheDir, "installed.json"))
 	if err != nil {
-		return fmt.Errorf("failed to download template after 3 attempts: %w", err)
+		if os.IsNotExist(err) {
+			return installed, nil
+		}
+		return installed, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("template not found (HTTP %d)", resp.StatusCode)
+	if err := json.Unmarshal(data, &installed); err != nil {
+		return make(map[string]InstalledTemplate), nil
 	}
+	return installed, nil
+}
 
-	content, err := io.ReadAll(resp.Body)
+func (m *Marketplace) saveInstalled(installed map[string]InstalledTemplate) error {
+	if err := os.MkdirAll(m.cacheDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(installed, "", "  ")
 	if err != nil {
 		return err
 	}
+	return os.WriteFile(filepath.Join(m.cacheDir, "installed.json"), data, 0644)
+}
 
-	// Create .devcontainer directory
-	devcontainerDir := filepath.Join(targetDir, ".devcontainer")
-	if err := os.MkdirAll(devcontainerDir, 0755); err != nil {
-		return err
+// templateFromMarketplaceContent accepts either a CM template or a plain devcontainer.json
+func templateFromMarketplaceContent(entry *MarketplaceTemplate, content []byte) (*Template, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", entry.ID, err)
+	}
+
+	var t Template
+	if _, isCM := raw["category"]; isCM {
+		if err := json.Unmarshal(content, &t); err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", entry.ID, err)
+		}
+	} else {
+		// devcontainer.json: keep the fields CM templates understand
+		if img, ok := raw["image"].(string); ok {
+			t.Image = img
+		}
+		if features, ok := raw["features"].(map[string]interface{}); ok {
+			t.Features = features
+		}
+		if postCreate, ok := raw["postCreateCommand"].(string); ok {
+			t.PostCreate = postCreate
+		}
+		if runArgs, ok := raw["runArgs"].([]interface{}); ok {
+			for _, a := range runArgs {
+				t.RunArgs = append(t.RunArgs, fmt.Sprintf("%v", a))
+			}
+		}
 	}
 
-	// Write devcontainer.json
-	configPath := filepath.Join(devcontainerDir, "devcontainer.json")
-	return os.WriteFile(configPath, content, 0644)
+	if t.Image == "" {
+		return nil, fmt.Errorf("template %s has no image (build-based templates are not supported)", entry.ID)
+	}
+
+	t.Name = entry.ID
+	if t.Category == "" {
+		t.Category = entry.Category
+	}
+	if t.Description == "" {
+		t.Description = entry.Description
+	}
+	if entry.Version != "" {
+		t.Version = entry.Version
+	}
+	t.IsCustom = true
+	return &t, nil
 }
 
-// loadTemplates loads templates from cache or fetches from remote
+// loadTemplates loads the index from cache or fetches it from remote. The
+// index must be signed with the marketplace key, also when it's cached.
 func (m *Marketplace) loadTemplates() error {
 	if len(m.templates) > 0 {
 		return nil
 	}
+	if m.publicKey == "" {
+		return fmt.Errorf("the marketplace index at %s can't be verified: set its signing key with 'cm config set marketplace.public_key <base64-ed25519-key>'", m.indexURL)
+	}
 
-	// Try to load from cache
-	cachePath := filepath.Join(m.cacheDir, "templates.json")
-	if data, err := os.ReadFile(cachePath); err == nil {
-		if json.Unmarshal(data, &m.templates) == nil && len(m.templates) > 0 {
-			return nil
+	cachePath := m.indexCachePath()
+
+	// Fresh cache wins unless a refresh was requested
+	if !m.refresh {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < marketplaceCacheTTL {
+			if idx, err := m.readCachedIndex(cachePath); err == nil && len(idx.Templates) > 0 {
+				m.templates = idx.Templates
+				m.source = m.indexURL + " (cached)"
+				return nil
+			}
 		}
 	}
 
-	// Fetch from remote (using devcontainers/templates repo)
-	m.templates = m.getDefaultTemplates()
-
-	// Cache the templates
-	_ = os.MkdirAll(m.cacheDir, 0755)
-	if data, err := json.Marshal(m.templates); err == nil {
+	idx, data, sig, err := m.fetchIndex()
+	if err == nil {
+		m.templates = idx.Templates
+		m.source = m.indexURL
+		_ = os.MkdirAll(m.cacheDir, 0755)
 		_ = os.WriteFile(cachePath, data, 0644)
+		_ = os.WriteFile(cachePath+".sig", sig, 0644)
+		return nil
+	}
+
+	// A bad signature must never fall back to other data
+	if errors.Is(err, ErrBadSignature) {
+		return err
+	}
+
+	// A stale, verified cache is better than nothing when offline
+	if idx, cacheErr := m.readCachedIndex(cachePath); cacheErr == nil && len(idx.Templates) > 0 {
+		m.templates = idx.Templates
+		m.source = m.indexURL + " (stale cache)"
+		return nil
+	}
+	return fmt.Errorf("failed to load the marketplace index: %w", err)
+}
+
+// indexCachePath returns where the index is cached, per index URL
+func (m *Marketplace) indexCachePath() string {
+	sum := sha256.Sum256([]byte(m.indexURL))
+	return filepath.Join(m.cacheDir, "index-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// fetchIndex downloads the index and its signature and verifies it
+func (m *Marketplace) fetchIndex() (*MarketplaceIndex, []byte, []byte, error) {
+	data, err := m.download(m.indexURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sig, err := m.download(m.indexURL + ".sig")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to fetch index signature: %w", err)
+	}
+	if err := VerifyIndexSignature(data, sig, m.publicKey); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var idx MarketplaceIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid marketplace index: %w", err)
+	}
+	return &idx, data, sig, nil
+}
+
+// readCachedIndex reads a cached index, verifying it again with the
+// current key
+func (m *Marketplace) readCachedIndex(path string) (*MarketplaceIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyIndexSignature(data, sig, m.publicKey); err != nil {
+		return nil, err
+	}
+	var idx MarketplaceIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// download fetches a URL with a few retries
+func (m *Marketplace) download(url string) ([]byte, error) {
+	if offline.Enabled() {
+		return nil, offline.Missing("download", url)
+	}
+
+	// Transient failures are retried by the shared HTTP client
+	resp, err := m.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d fetching %s", resp.StatusCode, url)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func readIndexFile(path string) (*MarketplaceIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var idx MarketplaceIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
 	}
+	return &idx, nil
+}
 
+// VerifyIndexSignature checks a base64 ed25519 signature over the raw index
+// bytes; every failure is an ErrBadSignature
+func VerifyIndexSignature(data, sig []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid marketplace public key", ErrBadSignature)
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature encoding: %v", ErrBadSignature, err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, rawSig) {
+		return ErrBadSignature
+	}
 	return nil
 }
 
-// getDefaultTemplates returns official devcontainer templates
-func (m *Marketplace) getDefaultTemplates() []MarketplaceTemplate {
-	return []MarketplaceTemplate{
-		{
-			ID:          "python",
-			Name:        "Python",
-			Author:      "devcontainers",
-			Description: "Develop Python applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/python/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "javascript-node",
-			Name:        "Node.js & JavaScript",
-			Author:      "devcontainers",
-			Description: "Develop Node.js based applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/javascript-node/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "typescript-node",
-			Name:        "Node.js & TypeScript",
-			Author:      "devcontainers",
-			Description: "Develop TypeScript applications with Node.js",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/typescript-node/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "go",
-			Name:        "Go",
-			Author:      "devcontainers",
-			Description: "Develop Go applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/go/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "rust",
-			Name:        "Rust",
-			Author:      "devcontainers",
-			Description: "Develop Rust applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/rust/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "java",
-			Name:        "Java",
-			Author:      "devcontainers",
-			Description: "Develop Java applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/java/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "cpp",
-			Name:        "C++",
-			Author:      "devcontainers",
-			Description: "Develop C++ applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/cpp/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "dotnet",
-			Name:        ".NET",
-			Author:      "devcontainers",
-			Description: "Develop .NET applications",
-			Category:    "Languages",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/dotnet/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "docker-from-docker",
-			Name:        "Docker from Docker",
-			Author:      "devcontainers",
-			Description: "Access your host's Docker install from inside a container",
-			Category:    "DevOps",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/docker-from-docker/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "kubernetes-helm",
-			Name:        "Kubernetes - Local Configuration",
-			Author:      "devcontainers",
-			Description: "Access a local Kubernetes cluster",
-			Category:    "DevOps",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/kubernetes-helm/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "ubuntu",
-			Name:        "Ubuntu",
-			Author:      "devcontainers",
-			Description: "A plain Ubuntu container with minimal tooling",
-			Category:    "Base",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/ubuntu/.devcontainer/devcontainer.json",
-		},
-		{
-			ID:          "alpine",
-			Name:        "Alpine",
-			Author:      "devcontainers",
-			Description: "A minimal Alpine Linux container",
-			Category:    "Base",
-			URL:         "https://raw.githubusercontent.com/devcontainers/templates/main/src/alpine/.devcontainer/devcontainer.json",
-		},
+// SignIndex returns the base64 ed25519 signature for index bytes
+func SignIndex(data []byte, privateKey string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("invalid ed25519 private key")
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(key), data)), nil
+}
+
+// PublishTemplate adds or replaces a local template's entry in an index file.
+// The template JSON is written next to the index so both can be committed to
+// the marketplace repository; baseURL is where that repository is served from.
+func PublishTemplate(name, indexPath, baseURL, author, version string) (*MarketplaceTemplate, error) {
+	t, ok := GetTemplate(name)
+	if !ok {
+		return nil, fmt.Errorf("template '%s' not found", name)
+	}
+
+	content, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	indexDir := filepath.Dir(indexPath)
+	templatesDir := filepath.Join(indexDir, "templates")
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(templatesDir, name+".json"), content, 0644); err != nil {
+		return nil, err
+	}
+
+	idx := &MarketplaceIndex{Version: 1}
+	if existing, err := readIndexFile(indexPath); err == nil {
+		idx = existing
+	}
+
+	sum := sha256.Sum256(content)
+	now := time.Now().UTC()
+	entry := MarketplaceTemplate{
+		ID:          name,
+		Name:        name,
+		Author:      author,
+		Description: t.Description,
+		Category:    t.Category,
+		Version:     version,
+		URL:         strings.TrimSuffix(baseURL, "/") + "/templates/" + name + ".json",
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	replaced := false
+	for i, existing := range idx.Templates {
+		if existing.ID == name {
+			entry.CreatedAt = existing.CreatedAt
+			entry.Stars = existing.Stars
+			entry.Downloads = existing.Downloads
+			idx.Templates[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		idx.Templates = append(idx.Templates, entry)
+	}
+	sort.Slice(idx.Templates, func(i, j int) bool { return idx.Templates[i].ID < idx.Templates[j].ID })
+	idx.UpdatedAt = now
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		return nil, err
 	}
+	return &entry, nil
 }
 
 // FormatTemplatesTable formats templates as a table (without fake metrics)
 func (m *Marketplace) FormatTemplatesTable(templates []MarketplaceTemplate) string {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("%-18s %-32s %-12s %s\n", "ID", "Name", "Category", "Author"))
-	sb.WriteString(strings.Repeat("─", 80) + "\n")
+	sb.WriteString(fmt.Sprintf("%-18s %-32s %-12s %-8s %s\n", "ID", "Name", "Category", "Version", "Author"))
+	sb.WriteString(strings.Repeat("─", 88) + "\n")
 	for _, t := range templates {
-		sb.WriteString(fmt.Sprintf("%-18s %-32s %-12s %s\n", t.ID, t.Name, t.Category, t.Author))
+		version := t.Version
+		if version == "" {
+			version = "-"
+		}
+		sb.WriteString(fmt.Sprintf("%-18s %-32s %-12s %-8s %s\n", t.ID, t.Name, t.Category, version, t.Author))
+	}
+	if m.source != "" {
+		sb.WriteString("\n📌 Source: " + m.source)
 	}
-	sb.WriteString("\n📌 Source: github.com/devcontainers/templates (Official)")
 	return sb.String()
 }
@@ -0,0 +1,239 @@
+package template
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+)
+
+func TestIndexSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := base64.StdEncoding.EncodeToString(pub)
+	privKey := base64.StdEncoding.EncodeToString(priv)
+
+	data := []byte(`{"version":1,"templates":[]}`)
+	sig, err := SignIndex(data, privKey)
+	if err != nil {
+		t.Fatalf("SignIndex failed: %v", err)
+	}
+
+	if err := VerifyIndexSignature(data, []byte(sig), pubKey); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := VerifyIndexSignature([]byte(`{"version":2}`), []byte(sig), pubKey); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered index: got %v, want ErrBadSignature", err)
+	}
+	if err := VerifyIndexSignature(data, []byte("not base64!"), pubKey); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("garbled signature: got %v, want ErrBadSignature", err)
+	}
+}
+
+// testIndex serves a signed marketplace index with one template, go-dev,
+// and the template file
+type testIndex struct {
+	*httptest.Server
+	pubKey  string
+	privKey string
+	index   []byte
+	sig     []byte
+	content []byte
+}
+
+func newTestIndex(t *testing.T, entries string) *testIndex {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := &testIndex{
+		pubKey:  base64.StdEncoding.EncodeToString(pub),
+		privKey: base64.StdEncoding.EncodeToString(priv),
+		content: []byte(`{"image":"golang:1.22"}`),
+	}
+	ti.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.json":
+			w.Write(ti.index)
+		case "/index.json.sig":
+			w.Write(ti.sig)
+		case "/go-dev.json":
+			w.Write(ti.content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ti.Close)
+	ti.publish(t, strings.ReplaceAll(entries, "$URL", ti.URL))
+	return ti
+}
+
+// publish serves a new index, signed with the index's key
+func (ti *testIndex) publish(t *testing.T, index string) {
+	t.Helper()
+	sig, err := SignIndex([]byte(index), ti.privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti.index, ti.sig = []byte(index), []byte(sig)
+}
+
+func (ti *testIndex) marketplace(key string, refresh bool) *Marketplace {
+	return NewMarketplaceWithOptions(MarketplaceOptions{IndexURL: ti.URL + "/index.json", PublicKey: key, Refresh: refresh})
+}
+
+func contentSum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMarketplaceRequiresSignature(t *testing.T) {
+	ti := newTestIndex(t, `{"version":1,"templates":[{"id":"go-dev","url":"$URL/go-dev.json"}]}`)
+
+	if _, err := ti.marketplace(ti.pubKey, false).Search(""); err != nil {
+		t.Fatalf("signed index rejected: %v", err)
+	}
+
+	// A custom index needs its key
+	if _, err := ti.marketplace("", true).Search(""); err == nil || !strings.Contains(err.Error(), "marketplace.public_key") {
+		t.Errorf("index without a key: got %v, want a request for marketplace.public_key", err)
+	}
+	if m := NewMarketplace(); m.publicKey != DefaultMarketplacePublicKey {
+		t.Errorf("community index key = %q, want the pinned key", m.publicKey)
+	}
+
+	// A bad signature doesn't fall back to the cached index
+	ti.sig = []byte(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)))
+	if _, err := ti.marketplace(ti.pubKey, true).Search(""); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("badly signed index: got %v, want ErrBadSignature", err)
+	}
+
+	// The cache was verified with the key in use, so another key can't use it
+	other, _, _ := ed25519.GenerateKey(nil)
+	otherKey := base64.StdEncoding.EncodeToString(other)
+	if _, err := ti.marketplace(otherKey, false).Search(""); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("cached index under another key: got %v, want ErrBadSignature", err)
+	}
+
+	// Without the network, the verified cache still serves
+	t.Setenv(offline.EnvVar, "1")
+	m := ti.marketplace(ti.pubKey, true)
+	if templates, err := m.Search(""); err != nil || len(templates) != 1 || !strings.HasSuffix(m.Source(), "(stale cache)") {
+		t.Errorf("offline: got %v, %v from %q, want the stale cache", templates, err, m.Source())
+	}
+	if _, err := ti.marketplace(otherKey, true).Search(""); err == nil {
+		t.Error("offline under another key: the cache was used")
+	}
+}
+
+func TestMarketplaceInstall(t *testing.T) {
+	ti := newTestIndex(t, "")
+	sum := contentSum(ti.content)
+	ti.publish(t, `{"version":1,"templates":[
+		{"id":"go-dev","url":"`+ti.URL+`/go-dev.json","sha256":"`+sum+`"},
+		{"id":"no-sum","url":"`+ti.URL+`/go-dev.json"},
+		{"id":"bad-sum","url":"`+ti.URL+`/go-dev.json","sha256":"`+strings.Repeat("0", 64)+`"},
+		{"id":"../evil","url":"`+ti.URL+`/go-dev.json","sha256":"`+sum+`"}
+	]}`)
+	m := ti.marketplace(ti.pubKey, true)
+
+	if _, err := m.Install("go-dev"); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(GetTemplatesDir(), "go-dev.json")); err != nil {
+		t.Errorf("template not saved: %v", err)
+	}
+
+	for id, want := range map[string]string{
+		"no-sum":  "no checksum",
+		"bad-sum": "checksum mismatch",
+		"../evil": "invalid template ID",
+	} {
+		if _, err := m.Install(id); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Install(%q) = %v, want %q", id, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(GetTemplatesDir()), "evil.json")); err == nil {
+		t.Error("Install wrote outside the templates directory")
+	}
+}
+
+func TestValidTemplateID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"go-dev":      true,
+		"ml.pytorch":  true,
+		"":            false,
+		".":           false,
+		"..":          false,
+		"../evil":     false,
+		"a/b":         false,
+		`a\b`:         false,
+		"/etc/passwd": false,
+		"C:evil":      false,
+	} {
+		if got := validTemplateID(id); got != want {
+			t.Errorf("validTemplateID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestPublishTemplate(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.json")
+
+	entry, err := PublishTemplate("python-basic", indexPath, "https://example.com/market/", "me", "1.2.0")
+	if err != nil {
+		t.Fatalf("PublishTemplate failed: %v", err)
+	}
+
+	if entry.URL != "https://example.com/market/templates/python-basic.json" {
+		t.Errorf("unexpected URL: %s", entry.URL)
+	}
+	if len(entry.SHA256) != 64 {
+		t.Errorf("expected sha256 checksum, got %q", entry.SHA256)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "templates", "python-basic.json")); err != nil {
+		t.Errorf("template file not written: %v", err)
+	}
+
+	// Publishing again replaces the entry instead of duplicating it
+	if _, err := PublishTemplate("python-basic", indexPath, "https://example.com/market", "me", "1.3.0"); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := readIndexFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Templates) != 1 || idx.Templates[0].Version != "1.3.0" {
+		t.Errorf("expected single updated entry, got %+v", idx.Templates)
+	}
+}
+
+func TestTemplateFromDevcontainerJSON(t *testing.T) {
+	entry := &MarketplaceTemplate{ID: "go", Category: "Languages", Description: "Go"}
+	content := []byte(`{"name":"Go","image":"mcr.microsoft.com/devcontainers/go:1","postCreateCommand":"go version"}`)
+
+	tmpl, err := templateFromMarketplaceContent(entry, content)
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	if tmpl.Name != "go" || tmpl.Image != "mcr.microsoft.com/devcontainers/go:1" || tmpl.PostCreate != "go version" {
+		t.Errorf("unexpected template: %+v", tmpl)
+	}
+	if !tmpl.IsCustom {
+		t.Error("installed templates should be custom")
+	}
+}
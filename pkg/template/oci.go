@@ -0,0 +1,392 @@
+package template
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+)
+
+// OCITemplateMetadata is the devcontainer-template.json shipped in a Template artifact
+type OCITemplateMetadata struct {
+	ID          string                    `json:"id"`
+	Version     string                    `json:"version"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description,omitempty"`
+	Options     map[string]TemplateOption `json:"options,omitempty"`
+}
+
+// OptionNames returns option names in a stable order
+func (m *OCITemplateMetadata) OptionNames() []string {
+	return sortedOptionNames(m.Options)
+}
+
+// OCITemplate is a pulled Template artifact extracted to a local directory
+type OCITemplate struct {
+	Ref      string
+	Dir      string
+	Metadata OCITemplateMetadata
+}
+
+// ociManifest is the subset of an OCI image manifest we need
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// templateOptionPattern matches ${templateOption:name}
+var templateOptionPattern = regexp.MustCompile(`\$\{templateOption:([^}]+)\}`)
+
+// IsOCIRef reports whether name looks like a registry reference (e.g. ghcr.io/org/templates/go:1)
+func IsOCIRef(name string) bool {
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	host := parts[0]
+	return strings.Contains(host, ".") || strings.Contains(host, ":") || host == "localhost"
+}
+
+// parseOCIRef splits ghcr.io/devcontainers/templates/go:1 into registry, repository and tag
+func parseOCIRef(ref string) (registry, repository, tag string) {
+	tag = "latest"
+	slash := strings.Index(ref, "/")
+	registry, rest := ref[:slash], ref[slash+1:]
+
+	if at := strings.Index(rest, "@"); at != -1 {
+		return registry, rest[:at], rest[at+1:]
+	}
+	if colon := strings.LastIndex(rest, ":"); colon != -1 {
+		rest, tag = rest[:colon], rest[colon+1:]
+	}
+	return registry, rest, tag
+}
+
+// PullOCITemplate downloads a devcontainer Template artifact and extracts it to the cache
+func PullOCITemplate(ctx context.Context, ref string) (*OCITemplate, error) {
+	dir := OCITemplateCachePath(ref)
+	if offline.Enabled() {
+		if tmpl, err := loadOCITemplateDir(ref, dir); err == nil {
+			return tmpl, nil
+		}
+		return nil, offline.Missing("template", ref)
+	}
+
+	client := httpclient.New(httpclient.Options{Timeout: 60 * time.Second})
+	return pullOCITemplate(ctx, &ociRegistry{client: client}, ref, dir)
+}
+
+// pullOCITemplate downloads a Template artifact through a registry client
+// and extracts it to dir
+func pullOCITemplate(ctx context.Context, reg *ociRegistry, ref, dir string) (*OCITemplate, error) {
+	registry, repository, tag := parseOCIRef(ref)
+
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, tag)
+	manifestData, err := reg.get(ctx, manifestURL,
+		"application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json", "")
+	if err != nil {
+		// Offline: reuse a previous pull
+		if tmpl, cacheErr := loadOCITemplateDir(ref, dir); cacheErr == nil {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("failed to fetch template manifest: %w", err)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid template manifest: %w", err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("template manifest has no layers")
+	}
+
+	layer := manifest.Layers[0]
+	blobURL := fmt.Sprintf("https://%s/v2/%s/blobs/%s", registry, repository, layer.Digest)
+	blob, err := reg.get(ctx, blobURL, "", layer.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download template layer: %w", err)
+	}
+
+	_ = os.RemoveAll(dir)
+	if err := extractTemplateArchive(blob, dir); err != nil {
+		return nil, fmt.Errorf("failed to extract template: %w", err)
+	}
+
+	return loadOCITemplateDir(ref, dir)
+}
+
+// OCITemplateCacheDir returns the directory pulled templates are cached in
+func OCITemplateCacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cm", "oci-templates")
+}
+
+// OCITemplateCachePath returns where a template reference is cached
+func OCITemplateCachePath(ref string) string {
+	registry, repository, tag := parseOCIRef(ref)
+	cacheKey := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(registry + "/" + repository + "_" + tag)
+	return filepath.Join(OCITemplateCacheDir(), cacheKey)
+}
+
+// loadOCITemplateDir reads metadata from an extracted template
+func loadOCITemplateDir(ref, dir string) (*OCITemplate, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "devcontainer-template.json"))
+	if err != nil {
+		return nil, fmt.Errorf("devcontainer-template.json not found in %s", ref)
+	}
+
+	tmpl := &OCITemplate{Ref: ref, Dir: dir}
+	if err := json.Unmarshal(data, &tmpl.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid devcontainer-template.json: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ociRegistry fetches from a registry, with an anonymous token once the
+// registry asks for one
+type ociRegistry struct {
+	client *http.Client
+	token  string
+}
+
+// get fetches a registry endpoint. With a digest, the body is hashed while
+// it is read and must match it.
+func (r *ociRegistry) get(ctx context.Context, endpoint, accept, digest string) ([]byte, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return r.client.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if r.token, err = registryToken(ctx, r.client, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = send(); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, endpoint)
+	}
+	if digest == "" {
+		return io.ReadAll(resp.Body)
+	}
+	algorithm, want, _ := strings.Cut(digest, ":")
+	if algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported digest %s", digest)
+	}
+	hash := sha256.New()
+	data, err := io.ReadAll(io.TeeReader(resp.Body, hash))
+	if err != nil {
+		return nil, err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != strings.ToLower(want) {
+		return nil, fmt.Errorf("digest mismatch: got sha256:%s, want %s", got, digest)
+	}
+	return data, nil
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryToken requests an anonymous pull token for a "Bearer realm=..."
+// challenge
+func registryToken(ctx context.Context, client *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("authentication required")
+	}
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid authentication challenge %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("authentication required (HTTP %d from the token service)", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("the token service returned no token")
+}
+
+// extractTemplateArchive unpacks a (optionally gzipped) tar into dir, rejecting path traversal
+func extractTemplateArchive(data []byte, dir string) error {
+	var reader io.Reader = bytes.NewReader(data)
+	if gz, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		defer gz.Close()
+		reader = gz
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// ResolveOptions fills in defaults for options not given and validates enums
+func (t *OCITemplate) ResolveOptions(given map[string]string) (map[string]string, error) {
+	return resolveOptions(t.Metadata.Options, given)
+}
+
+// Apply copies the template files into targetDir, substituting ${templateOption:*}.
+// Existing files are overwritten; template metadata and docs are skipped.
+func (t *OCITemplate) Apply(targetDir string, options map[string]string) ([]string, error) {
+	skip := map[string]bool{
+		"devcontainer-template.json": true,
+		"README.md":                  true,
+		"NOTES.md":                   true,
+	}
+
+	var written []string
+	err := filepath.Walk(t.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(t.Dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if skip[filepath.ToSlash(rel)] {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		data = SubstituteTemplateOptions(data, options)
+
+		dest := filepath.Join(targetDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+		written = append(written, rel)
+		return nil
+	})
+	return written, err
+}
+
+// SubstituteTemplateOptions replaces ${templateOption:name} placeholders.
+// Unknown options are left untouched so they remain visible to the user.
+func SubstituteTemplateOptions(data []byte, options map[string]string) []byte {
+	return templateOptionPattern.ReplaceAllFunc(data, func(m []byte) []byte {
+		name := string(templateOptionPattern.FindSubmatch(m)[1])
+		if val, ok := options[name]; ok {
+			return []byte(val)
+		}
+		return m
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package template
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOCIRef(t *testing.T) {
+	tests := []struct {
+		ref, registry, repo, tag string
+	}{
+		{"ghcr.io/devcontainers/templates/go:1", "ghcr.io", "devcontainers/templates/go", "1"},
+		{"ghcr.io/devcontainers/templates/go", "ghcr.io", "devcontainers/templates/go", "latest"},
+		{"localhost:5000/t/node:2", "localhost:5000", "t/node", "2"},
+	}
+	for _, tt := range tests {
+		registry, repo, tag := parseOCIRef(tt.ref)
+		if registry != tt.registry || repo != tt.repo || tag != tt.tag {
+			t.Errorf("parseOCIRef(%q) = %s %s %s", tt.ref, registry, repo, tag)
+		}
+	}
+
+	if IsOCIRef("go-basic") || !IsOCIRef("ghcr.io/devcontainers/templates/go:1") {
+		t.Error("IsOCIRef misclassified a reference")
+	}
+}
+
+func TestTemplateOptions(t *testing.T) {
+	tmpl := &OCITemplate{Metadata: OCITemplateMetadata{
+		Options: map[string]TemplateOption{
+			"imageVariant": {Type: "string", Default: "1.22"},
+			"installTools": {Type: "boolean", Default: true},
+			"flavor":       {Type: "string", Enum: []string{"a", "b"}, Default: "a"},
+		},
+	}}
+
+	resolved, err := tmpl.ResolveOptions(map[string]string{"imageVariant": "1.23"})
+	if err != nil {
+		t.Fatalf("ResolveOptions failed: %v", err)
+	}
+	if resolved["imageVariant"] != "1.23" || resolved["installTools"] != "true" || resolved["flavor"] != "a" {
+		t.Errorf("unexpected options: %v", resolved)
+	}
+
+	if _, err := tmpl.ResolveOptions(map[string]string{"flavor": "c"}); err == nil {
+		t.Error("expected enum violation to fail")
+	}
+	if _, err := tmpl.ResolveOptions(map[string]string{"bogus": "1"}); err == nil {
+		t.Error("expected unknown option to fail")
+	}
+
+	out := SubstituteTemplateOptions([]byte(`"image": "go:${templateOption:imageVariant}", "x": "${templateOption:missing}"`), resolved)
+	want := `"image": "go:1.23", "x": "${templateOption:missing}"`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
+
+// templateLayer returns a gzipped tar holding a Template's metadata
+func templateLayer(t *testing.T, id string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	body := []byte(`{"id": "` + id + `", "version": "1.0.0", "name": "Go"}`)
+	if err := tw.WriteHeader(&tar.Header{Name: "devcontainer-template.json", Mode: 0644, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestPullOCITemplate(t *testing.T) {
+	layer := templateLayer(t, "go")
+	sum := sha256.Sum256(layer)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	served := layer
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token service lives wherever the challenge says
+		if r.URL.Path == "/auth/issue" {
+			if r.URL.Query().Get("scope") != "repository:org/templates/go:pull" || r.URL.Query().Get("service") != "test" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token": "secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/auth/issue",service="test",scope="repository:org/templates/go:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/templates/go/manifests/1":
+			w.Write([]byte(`{"layers": [{"mediaType": "application/vnd.devcontainers.layer.v1+tar", "digest": "` + digest + `"}]}`))
+		case "/v2/org/templates/go/blobs/" + digest:
+			w.Write(served)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	ref := strings.TrimPrefix(server.URL, "https://") + "/org/templates/go:1"
+	dir := filepath.Join(t.TempDir(), "go")
+	tmpl, err := pullOCITemplate(ctx, &ociRegistry{client: server.Client()}, ref, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Metadata.ID != "go" {
+		t.Errorf("pulled template %q, want go", tmpl.Metadata.ID)
+	}
+
+	// A layer that doesn't match its digest is never extracted
+	served = templateLayer(t, "evil")
+	dir = filepath.Join(t.TempDir(), "evil")
+	if _, err := pullOCITemplate(ctx, &ociRegistry{client: server.Client()}, ref, dir); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("tampered layer: err = %v, want a digest mismatch", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("tampered layer was extracted")
+	}
+}
+
+func TestRegistryToken(t *testing.T) {
+	ctx := context.Background()
+	for _, challenge := range []string{`Basic realm="registry"`, `Bearer service="test"`, ""} {
+		if _, err := registryToken(ctx, http.DefaultClient, challenge); err == nil {
+			t.Errorf("registryToken(%q) succeeded, want an error", challenge)
+		}
+	}
+}
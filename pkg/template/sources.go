@@ -0,0 +1,106 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/UPwith-me/Container-Maker/pkg/team"
+	"github.com/tailscale/hujson"
+)
+
+// LoadSourceTemplates returns templates from the cached clones of git template
+// sources, keyed as source/name. It never touches the network; sources are
+// fetched by 'cm template source add/update'.
+func LoadSourceTemplates() map[string]*Template {
+	templates := make(map[string]*Template)
+
+	repos, err := team.GetAllTeamTemplates()
+	if err != nil {
+		return templates
+	}
+
+	for source, entries := range repos {
+		for _, entry := range entries {
+			t, err := templateFromSourceDir(entry.Path)
+			if err != nil {
+				continue // Build-based or invalid templates are only usable via 'cm init'
+			}
+
+			key := source + "/" + filepath.Base(entry.Path)
+			t.Name = key
+			t.Category = source
+			t.Source = source
+			if entry.Description != "" {
+				t.Description = entry.Description
+			}
+			if t.Description == "" {
+				t.Description = fmt.Sprintf("%s template from %s", entry.Name, source)
+			}
+			if entry.Version != "" {
+				t.Version = entry.Version
+			}
+			templates[key] = t
+		}
+	}
+
+	return templates
+}
+
+// templateFromSourceDir reads a template directory's devcontainer.json
+func templateFromSourceDir(dir string) (*Template, error) {
+	path := filepath.Join(dir, "devcontainer.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	std, err := hujson.Standardize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Image      string                 `json:"image"`
+		Features   map[string]interface{} `json:"features"`
+		RunArgs    []string               `json:"runArgs"`
+		Mounts     []interface{}          `json:"mounts"`
+		Devices    []string               `json:"devices"`
+		PostCreate interface{}            `json:"postCreateCommand"`
+	}
+	if err := json.Unmarshal(std, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Image == "" {
+		return nil, fmt.Errorf("template has no image")
+	}
+
+	t := &Template{
+		Image:    raw.Image,
+		Features: raw.Features,
+		RunArgs:  raw.RunArgs,
+		Devices:  raw.Devices,
+	}
+	for _, m := range raw.Mounts {
+		if s, ok := m.(string); ok {
+			t.Mounts = append(t.Mounts, s)
+		}
+	}
+	if s, ok := raw.PostCreate.(string); ok {
+		t.PostCreate = s
+	}
+
+	// Options follow the devcontainer Templates spec
+	if data, err := os.ReadFile(filepath.Join(dir, "devcontainer-template.json")); err == nil {
+		var meta OCITemplateMetadata
+		if err := json.Unmarshal(data, &meta); err == nil {
+			t.Options = meta.Options
+		}
+	}
+
+	return t, nil
+}
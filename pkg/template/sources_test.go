@@ -0,0 +1,84 @@
+package template
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSourceTemplates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tmplDir := filepath.Join(home, ".cm", "team-cache", "org", "python-service")
+	if err := os.MkdirAll(tmplDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		// comments are allowed
+		"image": "python:${templateOption:version}",
+		"postCreateCommand": "pip install -r requirements.txt",
+	}`
+	if err := os.WriteFile(filepath.Join(tmplDir, "devcontainer.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"id": "python-service", "options": {"version": {"type": "string", "default": "3.12"}}}`
+	if err := os.WriteFile(filepath.Join(tmplDir, "devcontainer-template.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, ok := GetTemplate("org/python-service")
+	if !ok {
+		t.Fatalf("source template not merged into GetAllTemplates")
+	}
+	if tmpl.Source != "org" || tmpl.Category != "org" {
+		t.Errorf("unexpected source metadata: %+v", tmpl)
+	}
+
+	rendered, err := tmpl.Render(nil)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Image != "python:3.12" {
+		t.Errorf("Image = %q, want python:3.12", rendered.Image)
+	}
+}
+
+func TestLoadSourceTemplatesDoesNotFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// A configured source with a template that was never cloned
+	repo := filepath.Join(home, "templates")
+	if err := os.MkdirAll(filepath.Join(repo, "go-service"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "go-service", "devcontainer.json"), []byte(`{"image": "golang:1.22"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-qm", "init"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".cm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := `{"team": {"repositories": [{"name": "org", "url": "file://` + filepath.ToSlash(repo) + `", "auto_update": true}]}}`
+	if err := os.WriteFile(filepath.Join(home, ".cm", "config.json"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := LoadSourceTemplates(); len(got) != 0 {
+		t.Errorf("LoadSourceTemplates() = %d templates, want none before a fetch", len(got))
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cm", "team-cache", "org")); !os.IsNotExist(err) {
+		t.Error("template lookup should only read cached clones, not clone sources")
+	}
+}
@@ -14,35 +14,126 @@ type Template struct {
 	Name        string                 `json:"name"`
 	Category    string                 `json:"category"`
 	Description string                 `json:"description"`
+	Version     string                 `json:"version,omitempty"`
 	Image       string                 `json:"image"`
 	Features    map[string]interface{} `json:"features,omitempty"`
 	RunArgs     []string               `json:"runArgs,omitempty"`
 	Mounts      []string               `json:"mounts,omitempty"`
+	Devices     []string               `json:"devices,omitempty"` // Device classes, e.g. "gpu:amd"
 	Extensions  []string               `json:"extensions,omitempty"`
 	PostCreate  string                 `json:"postCreateCommand,omitempty"`
 	IsCustom    bool                   `json:"isCustom,omitempty"`
+	Source      string                 `json:"-"` // Git template source, empty for built-in/custom
+
+	// Options are substituted into ${templateOption:name} placeholders on apply.
+	// A boolean "gpu" option additionally toggles the --gpus run argument
+	// and the "gpu:*" devices.
+	Options map[string]TemplateOption `json:"options,omitempty"`
+}
+
+// TemplateOption describes a user-tunable template parameter
+type TemplateOption struct {
+	Type        string      `json:"type"` // "string" or "boolean"
+	Description string      `json:"description,omitempty"`
+	Proposals   []string    `json:"proposals,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// DefaultString returns the option default formatted for substitution
+func (o TemplateOption) DefaultString() string {
+	if o.Default == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", o.Default)
+}
+
+// Choices returns the allowed values, or suggestions when any value is accepted
+func (o TemplateOption) Choices() []string {
+	if len(o.Enum) > 0 {
+		return o.Enum
+	}
+	if len(o.Proposals) > 0 {
+		return o.Proposals
+	}
+	if o.Type == "boolean" {
+		return []string{"true", "false"}
+	}
+	return nil
+}
+
+// versionOption builds the common language version option
+func versionOption(def string, proposals ...string) map[string]TemplateOption {
+	return map[string]TemplateOption{
+		"version": {Type: "string", Description: "Language version", Proposals: proposals, Default: def},
+	}
+}
+
+// gpuOption builds the option that toggles NVIDIA GPU passthrough
+func gpuOption() map[string]TemplateOption {
+	return vendorGPUOption("NVIDIA")
+}
+
+// vendorGPUOption builds the option that toggles a vendor's GPU passthrough
+func vendorGPUOption(vendor string) map[string]TemplateOption {
+	return map[string]TemplateOption{
+		"gpu": {Type: "boolean", Description: "Enable " + vendor + " GPU passthrough", Default: true},
+	}
+}
+
+// BuiltInVersion is the version recorded for built-in templates.
+// Bump it whenever a built-in template changes so 'cm template update' picks it up.
+const BuiltInVersion = "1.2.0"
+
+// JupyterStatePath is where 'cm notebook' keeps Jupyter's settings, kernels
+// and history: the cm-jupyter volume, which notebook templates mount so
+// they survive rebuilds
+const JupyterStatePath = "/opt/cm-jupyter"
+
+// jupyterMount is the volume notebook templates mount at JupyterStatePath
+const jupyterMount = "source=cm-jupyter,target=" + JupyterStatePath + ",type=volume"
+
+// isNotebookTemplate reports whether a template is meant for notebooks:
+// the deep learning ones and python-ml
+func isNotebookTemplate(t *Template) bool {
+	return t.Category == "Deep Learning" || t.Name == "python-ml"
 }
 
 // BuiltInTemplates returns all built-in templates
 func BuiltInTemplates() map[string]*Template {
+	templates := builtInTemplates()
+	for _, t := range templates {
+		if t.Version == "" {
+			t.Version = BuiltInVersion
+		}
+		if isNotebookTemplate(t) {
+			t.Mounts = append(t.Mounts, jupyterMount)
+		}
+	}
+	return templates
+}
+
+func builtInTemplates() map[string]*Template {
 	return map[string]*Template{
 		// Go templates
 		"go-basic": {
 			Name:        "go-basic",
 			Category:    "Go",
 			Description: "Go basic development environment",
-			Image:       "golang:1.21-alpine",
+			Image:       "golang:${templateOption:version}-alpine",
 			PostCreate:  "go mod download",
+			Options:     versionOption("1.21", "1.21", "1.22", "1.23"),
 		},
 		"go-api": {
 			Name:        "go-api",
 			Category:    "Go",
 			Description: "Go API development with hot-reload",
-			Image:       "golang:1.21",
+			Image:       "golang:${templateOption:version}",
 			Features: map[string]interface{}{
-				"ghcr.io/devcontainers/features/go:1": map[string]string{"version": "1.21"},
+				"ghcr.io/devcontainers/features/go:1": map[string]string{"version": "${templateOption:version}"},
 			},
 			PostCreate: "go install github.com/cosmtrek/air@latest && go mod download",
+			Options:    versionOption("1.21", "1.21", "1.22", "1.23"),
 		},
 
 		// Python templates
@@ -50,15 +141,17 @@ func BuiltInTemplates() map[string]*Template {
 			Name:        "python-basic",
 			Category:    "Python",
 			Description: "Python basic environment",
-			Image:       "python:3.11-slim",
+			Image:       "python:${templateOption:version}-slim",
 			PostCreate:  "pip install --upgrade pip",
+			Options:     versionOption("3.11", "3.10", "3.11", "3.12"),
 		},
 		"python-ml": {
 			Name:        "python-ml",
 			Category:    "Python",
 			Description: "Python machine learning with Jupyter",
-			Image:       "python:3.11",
+			Image:       "python:${templateOption:version}",
 			PostCreate:  "pip install numpy pandas matplotlib scikit-learn jupyter",
+			Options:     versionOption("3.11", "3.10", "3.11", "3.12"),
 		},
 
 		// Node templates
@@ -66,15 +159,17 @@ func BuiltInTemplates() map[string]*Template {
 			Name:        "node-basic",
 			Category:    "Node.js",
 			Description: "Node.js basic environment",
-			Image:       "node:20-alpine",
+			Image:       "node:${templateOption:version}-alpine",
 			PostCreate:  "npm install",
+			Options:     versionOption("20", "18", "20", "22"),
 		},
 		"node-fullstack": {
 			Name:        "node-fullstack",
 			Category:    "Node.js",
 			Description: "Full-stack development environment",
-			Image:       "node:20",
+			Image:       "node:${templateOption:version}",
 			PostCreate:  "npm install",
+			Options:     versionOption("20", "18", "20", "22"),
 		},
 
 		// Rust template
@@ -103,6 +198,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime",
 			RunArgs:     []string{"--gpus", "all"},
 			PostCreate:  "pip install transformers datasets accelerate wandb",
+			Options:     gpuOption(),
 		},
 		"tensorflow": {
 			Name:        "tensorflow",
@@ -111,6 +207,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "tensorflow/tensorflow:2.15.0-gpu",
 			RunArgs:     []string{"--gpus", "all"},
 			PostCreate:  "pip install keras tensorboard",
+			Options:     gpuOption(),
 		},
 		"huggingface": {
 			Name:        "huggingface",
@@ -119,6 +216,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime",
 			RunArgs:     []string{"--gpus", "all"},
 			PostCreate:  "pip install transformers datasets peft accelerate bitsandbytes trl wandb",
+			Options:     gpuOption(),
 		},
 		"llm-finetune": {
 			Name:        "llm-finetune",
@@ -127,6 +225,31 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime",
 			RunArgs:     []string{"--gpus", "all", "--shm-size=8g"},
 			PostCreate:  "pip install transformers datasets peft accelerate bitsandbytes trl wandb deepspeed",
+			Options:     gpuOption(),
+		},
+
+		// AMD GPUs through ROCm
+		"pytorch-rocm": {
+			Name:        "pytorch-rocm",
+			Category:    "Deep Learning",
+			Description: "PyTorch on AMD GPUs (ROCm)",
+			Image:       "rocm/pytorch:rocm6.2_ubuntu22.04_py3.10_pytorch_release_2.3.0",
+			Devices:     []string{"gpu:amd"},
+			RunArgs:     []string{"--shm-size=8g"},
+			PostCreate:  "pip install transformers datasets accelerate wandb",
+			Options:     vendorGPUOption("AMD"),
+		},
+
+		// Intel GPUs through oneAPI
+		"pytorch-xpu": {
+			Name:        "pytorch-xpu",
+			Category:    "Deep Learning",
+			Description: "PyTorch on Intel GPUs (oneAPI, XPU)",
+			Image:       "intel/oneapi-basekit:2024.2.1-0-devel-ubuntu22.04",
+			Devices:     []string{"gpu:intel"},
+			RunArgs:     []string{"--shm-size=8g"},
+			PostCreate:  "pip install torch torchvision torchaudio --index-url https://download.pytorch.org/whl/xpu && pip install transformers datasets accelerate",
+			Options:     vendorGPUOption("Intel"),
 		},
 
 		// Reinforcement Learning template
@@ -137,6 +260,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime",
 			RunArgs:     []string{"--gpus", "all"},
 			PostCreate:  "pip install gymnasium stable-baselines3 sb3-contrib tensorboard wandb pygame",
+			Options:     gpuOption(),
 		},
 
 		// JAX/Flax for ML research
@@ -147,6 +271,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "nvidia/cuda:12.1.0-cudnn8-devel-ubuntu22.04",
 			RunArgs:     []string{"--gpus", "all"},
 			PostCreate:  "pip install jax[cuda12_pip] flax optax orbax-checkpoint chex wandb -f https://storage.googleapis.com/jax-releases/jax_cuda_releases.html",
+			Options:     gpuOption(),
 		},
 
 		// Computer Vision with Detectron2
@@ -157,6 +282,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-devel",
 			RunArgs:     []string{"--gpus", "all", "--shm-size=8g"},
 			PostCreate:  "pip install opencv-python-headless albumentations timm && pip install 'git+https://github.com/facebookresearch/detectron2.git'",
+			Options:     gpuOption(),
 		},
 
 		// Diffusion Models (Stable Diffusion)
@@ -167,6 +293,7 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "pytorch/pytorch:2.1.0-cuda12.1-cudnn8-runtime",
 			RunArgs:     []string{"--gpus", "all", "--shm-size=16g"},
 			PostCreate:  "pip install diffusers transformers accelerate safetensors xformers wandb",
+			Options:     gpuOption(),
 		},
 
 		// NLP with spaCy
@@ -284,6 +411,41 @@ func BuiltInTemplates() map[string]*Template {
 			Image:       "ruby:3.2-slim",
 			PostCreate:  "if [ -f Gemfile ]; then bundle install; fi",
 		},
+
+		// === Build Systems and Environment Managers ===
+		"bazel": {
+			Name:        "bazel",
+			Category:    "Build Systems",
+			Description: "Bazel with Bazelisk and a persistent build cache",
+			Image:       "mcr.microsoft.com/devcontainers/base:ubuntu",
+			Mounts:      []string{"source=cm-bazel-cache,target=/home/vscode/.cache/bazel,type=volume"},
+			Extensions:  []string{"BazelBuild.vscode-bazel"},
+			PostCreate:  "sudo curl -fsSL -o /usr/local/bin/bazel https://github.com/bazelbuild/bazelisk/releases/latest/download/bazelisk-linux-$(dpkg --print-architecture) && sudo chmod +x /usr/local/bin/bazel && sudo chown vscode /home/vscode/.cache/bazel && bazel version",
+		},
+		"nix-flakes": {
+			Name:        "nix-flakes",
+			Category:    "Build Systems",
+			Description: "Nix with flakes enabled and a persistent store",
+			Image:       "mcr.microsoft.com/devcontainers/base:ubuntu",
+			Features: map[string]interface{}{
+				"ghcr.io/devcontainers/features/nix:1": map[string]string{"extraNixConfig": "experimental-features = nix-command flakes"},
+			},
+			Mounts:     []string{"source=cm-nix-store,target=/nix,type=volume"},
+			Extensions: []string{"jnoortheen.nix-ide"},
+			PostCreate: "if [ -f flake.nix ]; then nix develop --command true; elif [ -f shell.nix ]; then nix-shell --run true; fi",
+		},
+		"devbox": {
+			Name:        "devbox",
+			Category:    "Build Systems",
+			Description: "Devbox environment bootstrapped from devbox.json",
+			Image:       "mcr.microsoft.com/devcontainers/base:ubuntu",
+			Features: map[string]interface{}{
+				"ghcr.io/devcontainers/features/nix:1": map[string]string{"extraNixConfig": "experimental-features = nix-command flakes"},
+			},
+			Mounts:     []string{"source=cm-nix-store,target=/nix,type=volume"},
+			Extensions: []string{"jetpack-io.devbox"},
+			PostCreate: "curl -fsSL https://get.jetify.com/devbox | bash -s -- -f && if [ -f devbox.json ]; then devbox install; else devbox init; fi",
+		},
 	}
 }
 
@@ -333,9 +495,12 @@ func LoadCustomTemplates() (map[string]*Template, error) {
 	return templates, nil
 }
 
-// GetAllTemplates returns both built-in and custom templates
+// GetAllTemplates returns built-in, git source (source/name) and custom templates
 func GetAllTemplates() map[string]*Template {
 	templates := BuiltInTemplates()
+	for name, t := range LoadSourceTemplates() {
+		templates[name] = t
+	}
 	custom, _ := LoadCustomTemplates()
 
 	for name, t := range custom {
@@ -405,46 +570,114 @@ func ListTemplates() string {
 	return sb.String()
 }
 
-// ApplyTemplate creates devcontainer.json from a template
-func ApplyTemplate(name, targetDir string) error {
-	t, ok := GetTemplate(name)
-	if !ok {
-		return fmt.Errorf("template '%s' not found", name)
-	}
+// OptionNames returns the template's option names in a stable order
+func (t *Template) OptionNames() []string {
+	return sortedOptionNames(t.Options)
+}
 
-	// Create .devcontainer directory
-	devcontainerDir := filepath.Join(targetDir, ".devcontainer")
-	if err := os.MkdirAll(devcontainerDir, 0755); err != nil {
-		return err
+// ResolveOptions fills in defaults for options not given and validates values
+func (t *Template) ResolveOptions(given map[string]string) (map[string]string, error) {
+	return resolveOptions(t.Options, given)
+}
+
+// Render returns a copy of the template with option placeholders substituted
+func (t *Template) Render(given map[string]string) (*Template, error) {
+	options, err := t.ResolveOptions(given)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build devcontainer.json content
-	config := map[string]interface{}{
-		"name":  t.Name,
-		"image": t.Image,
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var rendered Template
+	if err := json.Unmarshal(SubstituteTemplateOptions(data, options), &rendered); err != nil {
+		return nil, err
 	}
 
-	if len(t.Features) > 0 {
-		config["features"] = t.Features
+	if gpu, ok := options["gpu"]; ok && gpu == "false" {
+		var args []string
+		for i := 0; i < len(rendered.RunArgs); i++ {
+			arg := rendered.RunArgs[i]
+			if arg == "--gpus" {
+				i++ // skip the value
+				continue
+			}
+			if strings.HasPrefix(arg, "--gpus=") {
+				continue
+			}
+			args = append(args, arg)
+		}
+		rendered.RunArgs = args
+
+		var devices []string
+		for _, d := range rendered.Devices {
+			if !strings.HasPrefix(d, "gpu:") {
+				devices = append(devices, d)
+			}
+		}
+		rendered.Devices = devices
 	}
-	if len(t.RunArgs) > 0 {
-		config["runArgs"] = t.RunArgs
+	return &rendered, nil
+}
+
+// sortedOptionNames returns option names in a stable order
+func sortedOptionNames(options map[string]TemplateOption) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
 	}
-	if len(t.Mounts) > 0 {
-		config["mounts"] = t.Mounts
+	sort.Strings(names)
+	return names
+}
+
+// resolveOptions merges given values over defaults, rejecting unknown options and invalid values
+func resolveOptions(defs map[string]TemplateOption, given map[string]string) (map[string]string, error) {
+	for name := range given {
+		if _, ok := defs[name]; !ok {
+			return nil, fmt.Errorf("unknown template option: %s", name)
+		}
 	}
-	if t.PostCreate != "" {
-		config["postCreateCommand"] = t.PostCreate
+
+	resolved := make(map[string]string)
+	for name, opt := range defs {
+		val, ok := given[name]
+		if !ok {
+			val = opt.DefaultString()
+		}
+		if len(opt.Enum) > 0 && !containsString(opt.Enum, val) {
+			return nil, fmt.Errorf("invalid value %q for option %s (allowed: %s)", val, name, strings.Join(opt.Enum, ", "))
+		}
+		if opt.Type == "boolean" && val != "true" && val != "false" {
+			return nil, fmt.Errorf("invalid value %q for option %s (expected true or false)", val, name)
+		}
+		resolved[name] = val
 	}
+	return resolved, nil
+}
 
-	// Write JSON
-	data, err := json.MarshalIndent(config, "", "  ")
+// ApplyTemplate creates devcontainer.json from a template using option defaults
+func ApplyTemplate(name, targetDir string) error {
+	return ApplyTemplateWithOptions(name, targetDir, nil)
+}
+
+// ApplyTemplateWithOptions creates devcontainer.json from a template, substituting options
+func ApplyTemplateWithOptions(name, targetDir string, options map[string]string) error {
+	tmpl, ok := GetTemplate(name)
+	if !ok {
+		return fmt.Errorf("template '%s' not found", name)
+	}
+	t, err := tmpl.Render(options)
 	if err != nil {
 		return err
 	}
 
-	configPath := filepath.Join(devcontainerDir, "devcontainer.json")
-	return os.WriteFile(configPath, data, 0644)
+	config, err := t.DevcontainerConfig(AppliedTemplate{Name: name, Version: tmpl.Version, Options: options})
+	if err != nil {
+		return err
+	}
+	return writeAppliedConfig(targetDir, config)
 }
 
 // SaveTemplate saves the current devcontainer.json as a custom template
@@ -516,15 +749,22 @@ func RemoveTemplate(name string) error {
 
 // TemplateInfo returns detailed info about a template
 func TemplateInfo(name string) (string, error) {
-	t, ok := GetTemplate(name)
+	tmpl, ok := GetTemplate(name)
 	if !ok {
 		return "", fmt.Errorf("template '%s' not found", name)
 	}
+	t, err := tmpl.Render(nil)
+	if err != nil {
+		t = tmpl
+	}
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("📋 Template: %s\n", t.Name))
 	sb.WriteString(fmt.Sprintf("   Category: %s\n", t.Category))
 	sb.WriteString(fmt.Sprintf("   Description: %s\n", t.Description))
+	if t.Version != "" {
+		sb.WriteString(fmt.Sprintf("   Version: %s\n", t.Version))
+	}
 	sb.WriteString(fmt.Sprintf("   Image: %s\n", t.Image))
 
 	if t.PostCreate != "" {
@@ -536,6 +776,17 @@ func TemplateInfo(name string) (string, error) {
 			sb.WriteString(fmt.Sprintf("     • %s\n", f))
 		}
 	}
+	if len(tmpl.Options) > 0 {
+		sb.WriteString("   Options:\n")
+		for _, name := range tmpl.OptionNames() {
+			opt := tmpl.Options[name]
+			sb.WriteString(fmt.Sprintf("     • %s (default: %s)", name, opt.DefaultString()))
+			if choices := opt.Choices(); len(choices) > 0 {
+				sb.WriteString(fmt.Sprintf(" [%s]", strings.Join(choices, ", ")))
+			}
+			sb.WriteString("\n")
+		}
+	}
 
 	return sb.String(), nil
 }
@@ -548,6 +799,11 @@ func (t *Template) RequiresGPU() bool {
 			return true
 		}
 	}
+	for _, d := range t.Devices {
+		if strings.HasPrefix(d, "gpu:") {
+			return true
+		}
+	}
 	// Check category
 	if t.Category == "Deep Learning" {
 		return true
@@ -184,3 +184,77 @@ func TestRequiresGPU(t *testing.T) {
 		}
 	})
 }
+
+// TestTemplateRender tests option substitution into built-in templates
+func TestTemplateRender(t *testing.T) {
+	builtIn := BuiltInTemplates()
+
+	rendered, err := builtIn["go-api"].Render(map[string]string{"version": "1.22"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Image != "golang:1.22" {
+		t.Errorf("Image = %q, want golang:1.22", rendered.Image)
+	}
+	feature := rendered.Features["ghcr.io/devcontainers/features/go:1"].(map[string]interface{})
+	if feature["version"] != "1.22" {
+		t.Errorf("feature version = %v, want 1.22", feature["version"])
+	}
+
+	defaults, err := builtIn["node-basic"].Render(nil)
+	if err != nil || defaults.Image != "node:20-alpine" {
+		t.Errorf("default render = %v, %v", defaults, err)
+	}
+
+	noGPU, err := builtIn["llm-finetune"].Render(map[string]string{"gpu": "false"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(noGPU.RunArgs) != 1 || noGPU.RunArgs[0] != "--shm-size=8g" {
+		t.Errorf("RunArgs = %v, want only --shm-size=8g", noGPU.RunArgs)
+	}
+
+	if _, err := builtIn["pytorch"].Render(map[string]string{"gpu": "maybe"}); err == nil {
+		t.Error("expected invalid boolean to fail")
+	}
+	if _, err := builtIn["rust-basic"].Render(map[string]string{"version": "1"}); err == nil {
+		t.Error("expected unknown option to fail")
+	}
+}
+
+func TestNotebookTemplatesMountJupyterState(t *testing.T) {
+	templates := BuiltInTemplates()
+	for _, name := range []string{"python-ml", "pytorch", "huggingface"} {
+		if !containsString(templates[name].Mounts, jupyterMount) {
+			t.Errorf("%s should mount the Jupyter state volume: %v", name, templates[name].Mounts)
+		}
+	}
+	if containsString(templates["go-basic"].Mounts, jupyterMount) {
+		t.Error("go-basic should not mount the Jupyter state volume")
+	}
+}
+
+func TestVendorGPUTemplates(t *testing.T) {
+	templates := BuiltInTemplates()
+	for name, device := range map[string]string{"pytorch-rocm": "gpu:amd", "pytorch-xpu": "gpu:intel"} {
+		tmpl := templates[name]
+		if !tmpl.RequiresGPU() {
+			t.Errorf("%s should require a GPU", name)
+		}
+		config, err := tmpl.DevcontainerConfig(AppliedTemplate{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if devices, _ := config["devices"].([]interface{}); len(devices) != 1 || devices[0] != device {
+			t.Errorf("%s devices = %v, want %s", name, config["devices"], device)
+		}
+
+		noGPU, err := tmpl.Render(map[string]string{"gpu": "false"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(noGPU.Devices) != 0 {
+			t.Errorf("%s without a GPU still has devices %v", name, noGPU.Devices)
+		}
+	}
+}
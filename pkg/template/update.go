@@ -0,0 +1,428 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// TemplateBaseFile stores the devcontainer.json exactly as the template generated it,
+// used as the common ancestor for three-way updates
+const TemplateBaseFile = ".cm-template-base.json"
+
+// AppliedTemplate records which template (and options) produced a devcontainer.json.
+// It is stored under customizations.cm.template.
+type AppliedTemplate struct {
+	Name    string            `json:"name"`
+	Version string            `json:"version,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// DevcontainerConfig builds the devcontainer.json content for a rendered template
+func (t *Template) DevcontainerConfig(applied AppliedTemplate) (map[string]interface{}, error) {
+	config := map[string]interface{}{
+		"name":  t.Name,
+		"image": t.Image,
+	}
+
+	if len(t.Features) > 0 {
+		config["features"] = t.Features
+	}
+	if len(t.RunArgs) > 0 {
+		config["runArgs"] = t.RunArgs
+	}
+	if len(t.Mounts) > 0 {
+		config["mounts"] = t.Mounts
+	}
+	if len(t.Devices) > 0 {
+		config["devices"] = t.Devices
+	}
+	if t.PostCreate != "" {
+		config["postCreateCommand"] = t.PostCreate
+	}
+	extensions := t.Extensions
+	if extensions == nil {
+		extensions = []string{}
+	}
+	config["customizations"] = map[string]interface{}{
+		"vscode": map[string]interface{}{"extensions": extensions},
+		"cm":     map[string]interface{}{"template": applied},
+	}
+
+	// Normalize to plain JSON values so configs compare cleanly after a round trip
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// writeAppliedConfig writes devcontainer.json and the merge base next to it
+func writeAppliedConfig(targetDir string, config map[string]interface{}) error {
+	devcontainerDir := filepath.Join(targetDir, ".devcontainer")
+	if err := os.MkdirAll(devcontainerDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.json"), data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(devcontainerDir, TemplateBaseFile), data, 0644)
+}
+
+// SaveTemplateBase records content as the merge base for a later 'cm template update'
+func SaveTemplateBase(targetDir string, content []byte) error {
+	return os.WriteFile(filepath.Join(targetDir, ".devcontainer", TemplateBaseFile), content, 0644)
+}
+
+// readJSONC parses a devcontainer.json that may contain comments
+func readJSONC(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseJSONC(data, path)
+}
+
+// parseJSONC parses the contents of a devcontainer.json read from path
+func parseJSONC(data []byte, path string) (map[string]interface{}, error) {
+	// Standardize blanks out comments in place
+	std, err := hujson.Standardize(append([]byte(nil), data...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(std, &config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	return config, nil
+}
+
+// ReadAppliedTemplate returns the template recorded in a project's devcontainer.json
+func ReadAppliedTemplate(projectDir string) (*AppliedTemplate, error) {
+	config, err := readJSONC(filepath.Join(projectDir, ".devcontainer", "devcontainer.json"))
+	if err != nil {
+		return nil, err
+	}
+	return appliedFromConfig(config)
+}
+
+func appliedFromConfig(config map[string]interface{}) (*AppliedTemplate, error) {
+	custom, _ := config["customizations"].(map[string]interface{})
+	cm, _ := custom["cm"].(map[string]interface{})
+	raw, ok := cm["template"]
+	if !ok {
+		return nil, fmt.Errorf("devcontainer.json was not created from a template (no customizations.cm.template)")
+	}
+
+	data, _ := json.Marshal(raw)
+	var applied AppliedTemplate
+	if err := json.Unmarshal(data, &applied); err != nil || applied.Name == "" {
+		return nil, fmt.Errorf("invalid template metadata in devcontainer.json")
+	}
+	return &applied, nil
+}
+
+// TemplateChange is one differing setting between the local config and the upstream template
+type TemplateChange struct {
+	Path     string
+	Base     interface{}
+	Local    interface{}
+	Upstream interface{}
+	Conflict bool     // changed both locally and upstream
+	keys     []string // Path split into keys, which may contain dots
+}
+
+// TemplateUpdate is a planned three-way merge of a project's config with its template
+type TemplateUpdate struct {
+	Applied       AppliedTemplate
+	LatestVersion string
+	HasBase       bool
+	Changes       []TemplateChange
+	source        []byte // devcontainer.json as planned against
+	nextBase      map[string]interface{}
+}
+
+// Conflicts returns the changes that cannot be applied automatically
+func (u *TemplateUpdate) Conflicts() []TemplateChange {
+	var conflicts []TemplateChange
+	for _, c := range u.Changes {
+		if c.Conflict {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// UpToDate reports whether there is nothing to apply
+func (u *TemplateUpdate) UpToDate() bool {
+	return len(u.Changes) == 0
+}
+
+// PlanTemplateUpdate compares a project's devcontainer.json with the latest version of its template.
+// base is the config as originally generated, local the current file and upstream the freshly
+// rendered template. Settings only changed upstream are taken; local edits are kept.
+func PlanTemplateUpdate(projectDir string) (*TemplateUpdate, error) {
+	devcontainerDir := filepath.Join(projectDir, ".devcontainer")
+	configPath := filepath.Join(devcontainerDir, "devcontainer.json")
+	source, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	local, err := parseJSONC(source, configPath)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedFromConfig(local)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, ok := GetTemplate(applied.Name)
+	if !ok {
+		return nil, fmt.Errorf("template '%s' not found", applied.Name)
+	}
+
+	// Drop options the template no longer has
+	options := make(map[string]string)
+	for k, v := range applied.Options {
+		if _, ok := tmpl.Options[k]; ok {
+			options[k] = v
+		}
+	}
+	rendered, err := tmpl.Render(options)
+	if err != nil {
+		return nil, err
+	}
+	next := AppliedTemplate{Name: applied.Name, Version: tmpl.Version}
+	if len(options) > 0 {
+		next.Options = options
+	}
+	upstream, err := rendered.DevcontainerConfig(next)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a recorded base every difference counts as a conflict
+	base, err := readJSONC(filepath.Join(devcontainerDir, TemplateBaseFile))
+	hasBase := err == nil
+	if !hasBase {
+		base = map[string]interface{}{}
+	}
+
+	u := &TemplateUpdate{
+		Applied:       *applied,
+		LatestVersion: tmpl.Version,
+		HasBase:       hasBase,
+		source:        source,
+		nextBase:      upstream,
+	}
+	mergeValue(nil, base, local, upstream, &u.Changes)
+	sort.Slice(u.Changes, func(i, j int) bool { return u.Changes[i].Path < u.Changes[j].Path })
+	return u, nil
+}
+
+// mergeValue performs a three-way merge, recursing into objects and recording differences
+func mergeValue(keys []string, base, local, upstream interface{}, changes *[]TemplateChange) interface{} {
+	baseMap, baseIsMap := base.(map[string]interface{})
+	localMap, localIsMap := local.(map[string]interface{})
+	upMap, upIsMap := upstream.(map[string]interface{})
+	if localIsMap && upIsMap && (baseIsMap || base == nil) {
+		merged := make(map[string]interface{})
+		names := make(map[string]bool)
+		for k := range localMap {
+			names[k] = true
+		}
+		for k := range upMap {
+			names[k] = true
+		}
+		for k := range names {
+			childKeys := append(keys[:len(keys):len(keys)], k)
+			if v := mergeValue(childKeys, baseMap[k], localMap[k], upMap[k], changes); v != nil {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+
+	path := strings.Join(keys, ".")
+	switch {
+	case reflect.DeepEqual(local, upstream):
+		return local
+	case reflect.DeepEqual(local, base):
+		*changes = append(*changes, TemplateChange{Path: path, Base: base, Local: local, Upstream: upstream, keys: keys})
+		return upstream
+	case reflect.DeepEqual(upstream, base):
+		return local // local edit, upstream unchanged
+	default:
+		*changes = append(*changes, TemplateChange{Path: path, Base: base, Local: local, Upstream: upstream, Conflict: true, keys: keys})
+		return local
+	}
+}
+
+// Apply writes the merged config, keeping local values for conflicts. Only
+// the settings taken from upstream are edited in the file, so its comments
+// and formatting stay.
+func (u *TemplateUpdate) Apply(projectDir string) error {
+	devcontainerDir := filepath.Join(projectDir, ".devcontainer")
+	data, err := patchJSONC(u.source, u.Changes)
+	if err != nil {
+		return fmt.Errorf("can't update devcontainer.json: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.json"), data, 0644); err != nil {
+		return err
+	}
+
+	// The upstream config becomes the new base, so resolved conflicts are not reported again
+	baseData, err := json.MarshalIndent(u.nextBase, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(devcontainerDir, TemplateBaseFile), baseData, 0644)
+}
+
+// patchJSONC edits the changes taken from upstream, the ones that aren't
+// conflicts, into a devcontainer.json's syntax tree
+func patchJSONC(data []byte, changes []TemplateChange) ([]byte, error) {
+	root, err := hujson.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var ops []map[string]interface{}
+	for _, c := range changes {
+		if c.Conflict {
+			continue
+		}
+		op := map[string]interface{}{"op": "replace", "path": jsonPointer(c.keys)}
+		switch {
+		case c.Upstream == nil:
+			op["op"] = "remove"
+		case c.Local == nil:
+			op["op"] = "add"
+		}
+		if c.Upstream != nil {
+			op["value"] = c.Upstream
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return data, nil
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	if err := root.Patch(patch); err != nil {
+		return nil, err
+	}
+
+	// Patched values come out on one line; lay them out like the file
+	unit := indentUnit(&root)
+	for _, c := range changes {
+		if !c.Conflict && c.Upstream != nil {
+			layoutMember(&root, c.keys, c.Upstream, unit, c.Local == nil)
+		}
+	}
+	return root.Pack(), nil
+}
+
+// jsonPointer returns the RFC 6901 pointer to a setting
+func jsonPointer(keys []string) string {
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString("/")
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(k))
+	}
+	return sb.String()
+}
+
+// indentUnit returns the indentation of the file's first setting, two
+// spaces if it has none
+func indentUnit(root *hujson.Value) string {
+	if obj, ok := root.Value.(*hujson.Object); ok && len(obj.Members) > 0 {
+		before := string(obj.Members[0].Name.BeforeExtra)
+		if i := strings.LastIndex(before, "\n"); i >= 0 && strings.Trim(before[i+1:], " \t") == "" && before[i+1:] != "" {
+			return before[i+1:]
+		}
+	}
+	return "  "
+}
+
+// layoutMember indents the patched setting at keys, on a line of its own
+// if it was added
+func layoutMember(root *hujson.Value, keys []string, value interface{}, unit string, added bool) {
+	parent := root
+	if len(keys) > 1 {
+		if parent = root.Find(jsonPointer(keys[:len(keys)-1])); parent == nil {
+			return
+		}
+	}
+	obj, ok := parent.Value.(*hujson.Object)
+	if !ok {
+		return
+	}
+	indent := strings.Repeat(unit, len(keys))
+	for i := range obj.Members {
+		member := &obj.Members[i]
+		if name, _ := member.Name.Value.(hujson.Literal); name.String() != keys[len(keys)-1] {
+			continue
+		}
+		if added {
+			member.Name.BeforeExtra = hujson.Extra("\n" + indent)
+			member.Value.BeforeExtra = hujson.Extra(" ")
+			if !strings.Contains(string(obj.AfterExtra), "\n") {
+				obj.AfterExtra = hujson.Extra("\n" + strings.Repeat(unit, len(keys)-1))
+			}
+		}
+		if formatted, err := json.MarshalIndent(value, indent, unit); err == nil {
+			if v, err := hujson.Parse(formatted); err == nil {
+				member.Value.Value = v.Value
+			}
+		}
+		return
+	}
+}
+
+// FormatTemplateUpdate renders the planned changes as a diff preview
+func FormatTemplateUpdate(u *TemplateUpdate) string {
+	var sb strings.Builder
+	for _, c := range u.Changes {
+		switch {
+		case c.Conflict:
+			sb.WriteString(fmt.Sprintf("  ! %s (conflict)\n", c.Path))
+			if u.HasBase {
+				sb.WriteString(fmt.Sprintf("      base:     %s\n", formatValue(c.Base)))
+			}
+			sb.WriteString(fmt.Sprintf("      local:    %s\n", formatValue(c.Local)))
+			sb.WriteString(fmt.Sprintf("      upstream: %s\n", formatValue(c.Upstream)))
+		case c.Local == nil:
+			sb.WriteString(fmt.Sprintf("  + %s: %s\n", c.Path, formatValue(c.Upstream)))
+		case c.Upstream == nil:
+			sb.WriteString(fmt.Sprintf("  - %s: %s\n", c.Path, formatValue(c.Local)))
+		default:
+			sb.WriteString(fmt.Sprintf("  ~ %s: %s → %s\n", c.Path, formatValue(c.Local), formatValue(c.Upstream)))
+		}
+	}
+	return sb.String()
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "(unset)"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
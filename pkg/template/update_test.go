@@ -0,0 +1,186 @@
+package template
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanTemplateUpdate(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	if err := ApplyTemplateWithOptions("go-basic", dir, map[string]string{"version": "1.22"}); err != nil {
+		t.Fatalf("ApplyTemplateWithOptions failed: %v", err)
+	}
+
+	applied, err := ReadAppliedTemplate(dir)
+	if err != nil {
+		t.Fatalf("ReadAppliedTemplate failed: %v", err)
+	}
+	if applied.Name != "go-basic" || applied.Version != BuiltInVersion || applied.Options["version"] != "1.22" {
+		t.Errorf("unexpected metadata: %+v", applied)
+	}
+
+	update, err := PlanTemplateUpdate(dir)
+	if err != nil {
+		t.Fatalf("PlanTemplateUpdate failed: %v", err)
+	}
+	if !update.UpToDate() {
+		t.Errorf("expected freshly applied config to be up to date, got %v", update.Changes)
+	}
+
+	// Simulate an older template: different image and post-create in the base,
+	// with a local edit to postCreateCommand
+	devDir := filepath.Join(dir, ".devcontainer")
+	base, _ := readJSONC(filepath.Join(devDir, TemplateBaseFile))
+	base["image"] = "golang:1.22-bullseye"
+	base["postCreateCommand"] = "go mod tidy"
+	writeJSON(t, filepath.Join(devDir, TemplateBaseFile), base)
+
+	local, _ := readJSONC(filepath.Join(devDir, "devcontainer.json"))
+	local["image"] = "golang:1.22-bullseye"
+	local["postCreateCommand"] = "make setup"
+	local["forwardPorts"] = []interface{}{8080.0}
+	writeJSON(t, filepath.Join(devDir, "devcontainer.json"), local)
+
+	update, err = PlanTemplateUpdate(dir)
+	if err != nil {
+		t.Fatalf("PlanTemplateUpdate failed: %v", err)
+	}
+	if len(update.Changes) != 2 || len(update.Conflicts()) != 1 || update.Conflicts()[0].Path != "postCreateCommand" {
+		t.Fatalf("unexpected changes: %+v", update.Changes)
+	}
+
+	if err := update.Apply(dir); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	merged, _ := readJSONC(filepath.Join(devDir, "devcontainer.json"))
+	if merged["image"] != "golang:1.22-alpine" || merged["postCreateCommand"] != "make setup" || merged["forwardPorts"] == nil {
+		t.Errorf("unexpected merge result: %v", merged)
+	}
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyKeepsComments(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	if err := ApplyTemplateWithOptions("go-basic", dir, map[string]string{"version": "1.22"}); err != nil {
+		t.Fatalf("ApplyTemplateWithOptions failed: %v", err)
+	}
+
+	// An older template's config, commented and reordered by hand
+	devDir := filepath.Join(dir, ".devcontainer")
+	local := `{
+  // Go development container
+  "name": "go-basic",
+  "image": "golang:1.21-alpine", // From the template
+  /* Local additions */
+  "forwardPorts": [8080],
+  "customizations": {
+    "cm": {
+      "template": {"name": "go-basic", "options": {"version": "1.22"}, "version": "1.0.0"}
+    },
+    "vscode": {
+      "extensions": [] // None yet
+    }
+  }
+}
+`
+	if err := os.WriteFile(filepath.Join(devDir, "devcontainer.json"), []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base, _ := readJSONC(filepath.Join(devDir, TemplateBaseFile))
+	base["image"] = "golang:1.21-alpine"
+	delete(base, "postCreateCommand")
+	base["customizations"].(map[string]interface{})["cm"].(map[string]interface{})["template"].(map[string]interface{})["version"] = "1.0.0"
+	writeJSON(t, filepath.Join(devDir, TemplateBaseFile), base)
+
+	update, err := PlanTemplateUpdate(dir)
+	if err != nil {
+		t.Fatalf("PlanTemplateUpdate failed: %v", err)
+	}
+	if len(update.Changes) != 3 || len(update.Conflicts()) != 0 {
+		t.Fatalf("unexpected changes: %+v", update.Changes)
+	}
+	if err := update.Apply(dir); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(devDir, "devcontainer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  // Go development container
+  "name": "go-basic",
+  "image": "golang:1.22-alpine", // From the template
+  /* Local additions */
+  "forwardPorts": [8080],
+  "customizations": {
+    "cm": {
+      "template": {"name": "go-basic", "options": {"version": "1.22"}, "version": "` + BuiltInVersion + `"}
+    },
+    "vscode": {
+      "extensions": [] // None yet
+    }
+  },
+  "postCreateCommand": "go mod download"
+}
+`
+	if string(data) != want {
+		t.Errorf("devcontainer.json after Apply =\n%s\nwant\n%s", data, want)
+	}
+
+	update, err = PlanTemplateUpdate(dir)
+	if err != nil {
+		t.Fatalf("PlanTemplateUpdate failed: %v", err)
+	}
+	if !update.UpToDate() {
+		t.Errorf("expected the applied config to be up to date, got %+v", update.Changes)
+	}
+}
+
+func TestPatchJSONC(t *testing.T) {
+	src := `{
+	"image": "node:18", // Pinned
+	"features": {
+		"ghcr.io/devcontainers/features/git:1": {},
+		// Removed upstream
+		"ghcr.io/devcontainers/features/python:1": {}
+	}
+}`
+	changes := []TemplateChange{
+		{keys: []string{"features", "ghcr.io/devcontainers/features/node:1"}, Upstream: map[string]interface{}{"version": "20"}},
+		{keys: []string{"features", "ghcr.io/devcontainers/features/python:1"}, Local: map[string]interface{}{}},
+		{keys: []string{"image"}, Local: "node:18", Upstream: "node:20"},
+		{keys: []string{"runArgs"}, Upstream: []interface{}{"--init"}, Local: "--privileged", Conflict: true},
+	}
+	got, err := patchJSONC([]byte(src), changes)
+	if err != nil {
+		t.Fatalf("patchJSONC failed: %v", err)
+	}
+	want := `{
+	"image": "node:20", // Pinned
+	"features": {
+		"ghcr.io/devcontainers/features/git:1": {},
+		"ghcr.io/devcontainers/features/node:1": {
+			"version": "20"
+		}
+	}
+}`
+	if string(got) != want {
+		t.Errorf("patchJSONC =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// smokeFiles are minimal project files, keyed by a command that needs them
+// in postCreateCommand
+var smokeFiles = []struct {
+	Command string
+	Files   map[string]string
+}{
+	{"go mod", map[string]string{
+		"go.mod":  "module example.com/smoke\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	}},
+	{"npm install", map[string]string{
+		"package.json": "{\n  \"name\": \"smoke\",\n  \"version\": \"0.0.0\",\n  \"private\": true\n}\n",
+	}},
+	{"cargo", map[string]string{
+		"Cargo.toml":  "[package]\nname = \"smoke\"\nversion = \"0.0.0\"\nedition = \"2021\"\n",
+		"src/main.rs": "fn main() {}\n",
+	}},
+	{"dotnet restore", map[string]string{
+		"smoke.csproj": "<Project Sdk=\"Microsoft.NET.Sdk\">\n  <PropertyGroup>\n    <OutputType>Exe</OutputType>\n    <TargetFramework>net8.0</TargetFramework>\n  </PropertyGroup>\n</Project>\n",
+		"Program.cs":   "System.Console.WriteLine();\n",
+	}},
+	{"poetry install", map[string]string{
+		"pyproject.toml": "[tool.poetry]\nname = \"smoke\"\nversion = \"0.0.0\"\ndescription = \"\"\nauthors = []\npackage-mode = false\n\n[tool.poetry.dependencies]\npython = \"^3.8\"\n",
+	}},
+}
+
+// SmokeProject returns the files of a minimal project for the template, so
+// its postCreateCommand has something to work on: go.mod for
+// "go mod download", package.json for "npm install", and so on. Commands
+// that guard on a file, like "if [ -f pom.xml ]", need none.
+func (t *Template) SmokeProject() map[string]string {
+	files := map[string]string{}
+	for _, smoke := range smokeFiles {
+		if !strings.Contains(t.PostCreate, smoke.Command) {
+			continue
+		}
+		for name, content := range smoke.Files {
+			files[name] = content
+		}
+	}
+	return files
+}
+
+// WriteSmokeProject writes the template's SmokeProject to dir and returns
+// the files written
+func (t *Template) WriteSmokeProject(dir string) ([]string, error) {
+	files := t.SmokeProject()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
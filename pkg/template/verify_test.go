@@ -0,0 +1,46 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSmokeProject(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{"go-basic", []string{"go.mod", "main.go"}},
+		{"go-api", []string{"go.mod", "main.go"}},
+		{"node-basic", []string{"package.json"}},
+		{"rust-basic", []string{"Cargo.toml", "src/main.rs"}},
+		{"dotnet", []string{"Program.cs", "smoke.csproj"}},
+		{"python-poetry", []string{"pyproject.toml"}},
+		{"java-maven", []string{}},
+		{"python-basic", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, ok := GetTemplate(tt.template)
+			if !ok {
+				t.Fatalf("Template %s not found", tt.template)
+			}
+			dir := t.TempDir()
+			got, err := tmpl.WriteSmokeProject(dir)
+			if err != nil {
+				t.Fatalf("WriteSmokeProject failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WriteSmokeProject() = %v, want %v", got, tt.want)
+			}
+			for _, name := range got {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s was not written: %v", name, err)
+				}
+			}
+		})
+	}
+}
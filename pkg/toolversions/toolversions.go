@@ -0,0 +1,151 @@
+// Package toolversions installs the runtimes a project pins for asdf or
+// mise, in .tool-versions or .mise.toml, inside its dev container, so the
+// container runs the versions the host does without a hand-written
+// Dockerfile.
+package toolversions
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files are the version files looked for in a project, in order
+var Files = []string{".mise.toml", "mise.toml", ".tool-versions"}
+
+// Tool is a pinned runtime, such as nodejs 20.11.0
+type Tool struct {
+	Name    string
+	Version string
+}
+
+// Project is a project's version file and the tools it pins
+type Project struct {
+	File  string // Relative to the project directory
+	Tools []Tool
+}
+
+// Detect returns the version file of the project in dir, or nil when it
+// has none
+func Detect(dir string) (*Project, error) {
+	for _, name := range Files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := &Project{File: name}
+		if name == ".tool-versions" {
+			p.Tools = parseToolVersions(string(data))
+		} else {
+			p.Tools = parseMiseTools(string(data))
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+// parseToolVersions reads asdf's "<tool> <version> [fallback...]" lines
+func parseToolVersions(data string) []Tool {
+	var tools []Tool
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		tools = append(tools, Tool{Name: fields[0], Version: fields[1]})
+	}
+	return tools
+}
+
+// parseMiseTools reads the [tools] table of a mise config: name = "1.2",
+// name = ["1.2", "1.1"] or name = { version = "1.2" }. mise itself reads
+// the file; this is only to show what gets installed.
+func parseMiseTools(data string) []Tool {
+	var tools []Tool
+	inTools := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inTools = line == "[tools]"
+			continue
+		}
+		if !inTools {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name := strings.Trim(strings.TrimSpace(key), `"'`)
+
+		// The first quoted string is the version, in each of the forms
+		version := ""
+		if i := strings.IndexAny(value, `"'`); i >= 0 {
+			rest := value[i+1:]
+			if j := strings.IndexByte(rest, value[i]); j >= 0 {
+				version = rest[:j]
+			}
+		}
+		if name != "" && version != "" {
+			tools = append(tools, Tool{Name: name, Version: version})
+		}
+	}
+	return tools
+}
+
+// String lists the tools, e.g. "nodejs 20.11.0, python 3.12"
+func (p *Project) String() string {
+	parts := make([]string, len(p.Tools))
+	for i, tool := range p.Tools {
+		parts[i] = tool.Name + " " + tool.Version
+	}
+	return strings.Join(parts, ", ")
+}
+
+// InstallScript installs the pinned tools when run as the container user
+// in the project directory. It uses asdf when the image has it and the
+// project only has .tool-versions, and mise otherwise, installing mise to
+// ~/.local/bin first; mise reads .tool-versions too. The shells of the
+// user then activate the tools.
+const InstallScript = `set -e
+if [ ! -f .mise.toml ] && [ ! -f mise.toml ] && command -v asdf >/dev/null 2>&1; then
+  cut -d'#' -f1 .tool-versions | awk 'NF >= 2 { print $1 }' | while read -r tool; do
+    asdf plugin add "$tool" >/dev/null 2>&1 || true
+  done
+  asdf install
+  exit 0
+fi
+
+MISE="$HOME/.local/bin/mise"
+if command -v mise >/dev/null 2>&1; then
+  MISE="$(command -v mise)"
+elif [ ! -x "$MISE" ]; then
+  if command -v curl >/dev/null 2>&1; then
+    curl -fsSL https://mise.run | sh
+  elif command -v wget >/dev/null 2>&1; then
+    wget -qO- https://mise.run | sh
+  else
+    echo "curl or wget is needed to install mise" >&2
+    exit 1
+  fi
+fi
+
+"$MISE" trust --yes >/dev/null 2>&1 || true
+"$MISE" install --yes
+
+grep -qs "mise activate" "$HOME/.bashrc" || echo "eval \"\$($MISE activate bash)\"" >> "$HOME/.bashrc"
+if command -v zsh >/dev/null 2>&1; then
+  grep -qs "mise activate" "$HOME/.zshrc" || echo "eval \"\$($MISE activate zsh)\"" >> "$HOME/.zshrc"
+fi
+grep -qs "mise/shims" "$HOME/.profile" || echo 'export PATH="$HOME/.local/share/mise/shims:$PATH"' >> "$HOME/.profile"
+`
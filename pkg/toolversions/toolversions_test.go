@@ -0,0 +1,57 @@
+package toolversions
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Detect(dir)
+	if err != nil || p != nil {
+		t.Fatalf("Detect() = %v, %v for a project without version files", p, err)
+	}
+
+	toolVersions := "# Pinned runtimes\nnodejs 20.11.0\npython 3.12.1 3.11.7 # fallback\n\ngolang\n"
+	if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(toolVersions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err = Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	want := []Tool{{"nodejs", "20.11.0"}, {"python", "3.12.1"}}
+	if p.File != ".tool-versions" || !reflect.DeepEqual(p.Tools, want) {
+		t.Errorf("Detect() = %+v, want .tool-versions with %v", p, want)
+	}
+	if got := p.String(); got != "nodejs 20.11.0, python 3.12.1" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// A mise config takes precedence
+	mise := `[env]
+NODE_ENV = "development"
+
+[tools]
+node = "20"
+python = ["3.12", "3.11"]
+"npm:prettier" = { version = "3.2.5" }
+go = 'latest' # comment
+
+[settings]
+experimental = true
+`
+	if err := os.WriteFile(filepath.Join(dir, ".mise.toml"), []byte(mise), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err = Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	want = []Tool{{"node", "20"}, {"python", "3.12"}, {"npm:prettier", "3.2.5"}, {"go", "latest"}}
+	if p.File != ".mise.toml" || !reflect.DeepEqual(p.Tools, want) {
+		t.Errorf("Detect() = %+v, want .mise.toml with %v", p, want)
+	}
+}
@@ -11,14 +11,14 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-// --- Color Palette (按用户参考图片) ---
+// --- Color Palette ---
 var (
-	colorPrimary   = lipgloss.Color("#E0AF68") // 橙黄色 (状态指示器)
-	colorSecondary = lipgloss.Color("#7AA2F7") // 蓝色 (选中项高亮)
-	colorSuccess   = lipgloss.Color("#E0AF68") // 橙黄色 (状态OK)
-	colorError     = lipgloss.Color("#F7768E") // 红色 (状态错误)
-	colorText      = lipgloss.Color("#C0CAF5") // 白色 (主文本)
-	colorMuted     = lipgloss.Color("#565F89") // 灰色 (次要文本)
+	colorPrimary   = lipgloss.Color("#E0AF68") // Amber (status indicators)
+	colorSecondary = lipgloss.Color("#7AA2F7") // Blue (selected item)
+	colorSuccess   = lipgloss.Color("#E0AF68") // Amber (status OK)
+	colorError     = lipgloss.Color("#F7768E") // Red (status error)
+	colorText      = lipgloss.Color("#C0CAF5") // White (body text)
+	colorMuted     = lipgloss.Color("#565F89") // Gray (secondary text)
 )
 
 // ============================================================================
@@ -51,7 +51,7 @@ func (m WelcomeModel) View() string {
 	}
 
 	// Welcome badge
-	colorLavender := lipgloss.Color("#BB9AF7") // 淡紫色
+	colorLavender := lipgloss.Color("#BB9AF7") // Lavender
 	badge := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(colorLavender).
@@ -59,7 +59,7 @@ func (m WelcomeModel) View() string {
 		Padding(0, 1).
 		Render("✽ Welcome to Container Maker")
 
-	// ASCII Art Logo - CONTAINER MAKER (淡紫色)
+	// ASCII Art Logo - CONTAINER MAKER (lavender)
 	logo := lipgloss.NewStyle().Foreground(colorLavender).Render(`
   ██████╗ ██████╗ ███╗   ██╗████████╗ █████╗ ██╗███╗   ██╗███████╗██████╗ 
  ██╔════╝██╔═══██╗████╗  ██║╚══██╔══╝██╔══██╗██║████╗  ██║██╔════╝██╔══██╗
@@ -267,13 +267,13 @@ func (m HomeModel) View() string {
 
 	s.WriteString(header + "\n\n")
 
-	// Status Section (无截断，垂直列表)
+	// Status Section (vertical list, not truncated)
 	statusHeader := lipgloss.NewStyle().
 		Foreground(colorMuted).
 		Render("┌ System Status ────────────────────────────────────")
 	s.WriteString(statusHeader + "\n")
 
-	// Status items - 完整显示，不截断
+	// Status items - shown in full, not truncated
 	engineIcon := lipgloss.NewStyle().Foreground(colorSuccess).Render("●")
 	configIcon := lipgloss.NewStyle().Foreground(colorSuccess).Render("●")
 	gpuIcon := lipgloss.NewStyle().Foreground(colorSuccess).Render("●")
@@ -422,7 +422,7 @@ func detectStatus() *StatusInfo {
 		status.HasConfig = false
 	}
 
-	// Detect GPU (完整显示，不截断)
+	// Detect GPU (shown in full, not truncated)
 	gpu := runtime.DetectGPU()
 	if gpu.Available {
 		status.GPUStatus = gpu.Name
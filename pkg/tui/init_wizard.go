@@ -250,6 +250,12 @@ func RunInitWizardWithDetails() (*TemplateChoice, error) {
 
 // GenerateConfig generates the devcontainer.json content based on selection
 func GenerateConfig(templateID string) string {
+	content, _ := GenerateConfigWithOptions(templateID, nil)
+	return content
+}
+
+// GenerateConfigWithOptions generates the devcontainer.json content, substituting template options
+func GenerateConfigWithOptions(templateID string, options map[string]string) (string, error) {
 	// Check if it's a team template
 	if strings.HasPrefix(templateID, "team/") {
 		parts := strings.SplitN(strings.TrimPrefix(templateID, "team/"), "/", 2)
@@ -257,14 +263,18 @@ func GenerateConfig(templateID string) string {
 			repoName, templateName := parts[0], parts[1]
 			if templatePath, err := team.GetTemplatePath(repoName, templateName); err == nil {
 				// Return path instead - caller should copy files
-				return fmt.Sprintf(`{"_teamTemplatePath": "%s"}`, templatePath)
+				return fmt.Sprintf(`{"_teamTemplatePath": "%s"}`, templatePath), nil
 			}
 		}
 	}
 
 	// Official template
 	if t, ok := template.BuiltInTemplates()[templateID]; ok {
-		return generateFromTemplate(t)
+		rendered, err := t.Render(options)
+		if err != nil {
+			return "", err
+		}
+		return generateFromTemplate(rendered, template.AppliedTemplate{Name: templateID, Version: t.Version, Options: options}), nil
 	}
 
 	// Fallback
@@ -280,44 +290,25 @@ func GenerateConfig(templateID string) string {
 
 	switch {
 	case strings.Contains(templateID, "go"):
-		return fmt.Sprintf(base, "Go Project", "mcr.microsoft.com/devcontainers/go:1.21")
+		return fmt.Sprintf(base, "Go Project", "mcr.microsoft.com/devcontainers/go:1.21"), nil
 	case strings.Contains(templateID, "python"):
-		return fmt.Sprintf(base, "Python Project", "mcr.microsoft.com/devcontainers/python:3.11")
+		return fmt.Sprintf(base, "Python Project", "mcr.microsoft.com/devcontainers/python:3.11"), nil
 	case strings.Contains(templateID, "node"):
-		return fmt.Sprintf(base, "Node.js Project", "mcr.microsoft.com/devcontainers/javascript-node:18")
+		return fmt.Sprintf(base, "Node.js Project", "mcr.microsoft.com/devcontainers/javascript-node:18"), nil
 	case strings.Contains(templateID, "rust"):
-		return fmt.Sprintf(base, "Rust Project", "mcr.microsoft.com/devcontainers/rust:latest")
+		return fmt.Sprintf(base, "Rust Project", "mcr.microsoft.com/devcontainers/rust:latest"), nil
 	case strings.Contains(templateID, "cpp"):
-		return fmt.Sprintf(base, "C++ Project", "mcr.microsoft.com/devcontainers/cpp:ubuntu-22.04")
+		return fmt.Sprintf(base, "C++ Project", "mcr.microsoft.com/devcontainers/cpp:ubuntu-22.04"), nil
 	default:
-		return fmt.Sprintf(base, "Dev Container", "mcr.microsoft.com/devcontainers/base:debian")
+		return fmt.Sprintf(base, "Dev Container", "mcr.microsoft.com/devcontainers/base:debian"), nil
 	}
 }
 
 // generateFromTemplate creates devcontainer.json from a template struct
-func generateFromTemplate(t *template.Template) string {
-	config := map[string]interface{}{
-		"name":  t.Name,
-		"image": t.Image,
-	}
-
-	if len(t.RunArgs) > 0 {
-		config["runArgs"] = t.RunArgs
-	}
-
-	if t.PostCreate != "" {
-		config["postCreateCommand"] = t.PostCreate
-	}
-
-	if len(t.Features) > 0 {
-		config["features"] = t.Features
-	}
-
-	// Add VS Code customizations
-	config["customizations"] = map[string]interface{}{
-		"vscode": map[string]interface{}{
-			"extensions": []string{},
-		},
+func generateFromTemplate(t *template.Template, applied template.AppliedTemplate) string {
+	config, err := t.DevcontainerConfig(applied)
+	if err != nil {
+		return ""
 	}
 
 	data, _ := json.MarshalIndent(config, "", "  ")
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/template"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// optionField is one template option being edited
+type optionField struct {
+	name    string
+	option  template.TemplateOption
+	choices []string
+	choice  int // index into choices, -1 for free text
+	input   textinput.Model
+}
+
+// OptionsModel asks for template option values one at a time
+type OptionsModel struct {
+	title     string
+	fields    []optionField
+	current   int
+	values    map[string]string
+	done      bool
+	cancelled bool
+}
+
+// NewOptionsModel builds a prompt for every option not already present in given
+func NewOptionsModel(title string, options map[string]template.TemplateOption, given map[string]string) OptionsModel {
+	values := make(map[string]string)
+	for k, v := range given {
+		values[k] = v
+	}
+
+	var fields []optionField
+	for _, name := range sortedKeys(options) {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		opt := options[name]
+
+		f := optionField{name: name, option: opt, choices: opt.Choices(), choice: -1}
+		f.input = textinput.New()
+		f.input.Placeholder = opt.DefaultString()
+		f.input.Prompt = "> "
+		for i, c := range f.choices {
+			if c == opt.DefaultString() {
+				f.choice = i
+			}
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) > 0 {
+		fields[0].input.Focus()
+	}
+
+	return OptionsModel{title: title, fields: fields, values: values, done: len(fields) == 0}
+}
+
+func sortedKeys(options map[string]template.TemplateOption) []string {
+	t := &template.Template{Options: options}
+	return t.OptionNames()
+}
+
+func (m OptionsModel) Init() tea.Cmd {
+	if m.done {
+		return tea.Quit
+	}
+	return textinput.Blink
+}
+
+func (m OptionsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.done {
+		return m, tea.Quit
+	}
+	f := &m.fields[m.current]
+
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "ctrl+c", "esc":
+			m.cancelled = true
+			return m, tea.Quit
+		case "tab", "down":
+			f.cycle(1)
+			return m, nil
+		case "shift+tab", "up":
+			f.cycle(-1)
+			return m, nil
+		case "enter":
+			m.values[f.name] = f.value()
+			f.input.Blur()
+			m.current++
+			if m.current >= len(m.fields) {
+				m.done = true
+				return m, tea.Quit
+			}
+			m.fields[m.current].input.Focus()
+			return m, textinput.Blink
+		}
+	}
+
+	// Typing switches a field with choices to free text, unless values are restricted
+	if len(f.option.Enum) > 0 || f.option.Type == "boolean" {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	f.input, cmd = f.input.Update(msg)
+	if f.input.Value() != "" {
+		f.choice = -1
+	}
+	return m, cmd
+}
+
+// cycle moves through the field's choices
+func (f *optionField) cycle(delta int) {
+	if len(f.choices) == 0 {
+		return
+	}
+	f.choice = (f.choice + delta + len(f.choices)) % len(f.choices)
+	f.input.SetValue("")
+}
+
+// value returns the typed text, the selected choice, or the default
+func (f *optionField) value() string {
+	if v := strings.TrimSpace(f.input.Value()); v != "" {
+		return v
+	}
+	if f.choice >= 0 {
+		return f.choices[f.choice]
+	}
+	return f.option.DefaultString()
+}
+
+func (m OptionsModel) View() string {
+	if m.done || m.cancelled {
+		return ""
+	}
+
+	s := strings.Builder{}
+	s.WriteString(StyleTitle.Render(m.title))
+	s.WriteString("\n")
+
+	for i, f := range m.fields {
+		switch {
+		case i < m.current:
+			s.WriteString(fmt.Sprintf("  %s %s\n", dimStyle.Render(f.name+":"), m.values[f.name]))
+		case i == m.current:
+			s.WriteString(fmt.Sprintf("\n  %s", selectedStyle.Render(f.name)))
+			if f.option.Description != "" {
+				s.WriteString(" " + descStyle.Render("— "+f.option.Description))
+			}
+			s.WriteString("\n")
+			if len(f.choices) > 0 {
+				var parts []string
+				for j, c := range f.choices {
+					if j == f.choice {
+						parts = append(parts, selectedStyle.Render("["+c+"]"))
+					} else {
+						parts = append(parts, dimStyle.Render(c))
+					}
+				}
+				s.WriteString("  " + strings.Join(parts, " ") + "\n")
+			}
+			s.WriteString("  " + f.input.View() + "\n")
+		}
+	}
+
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("  [tab] next choice  [enter] accept  [esc] cancel"))
+	s.WriteString("\n")
+	return s.String()
+}
+
+// RunOptionsPrompt asks for options missing from given and returns the merged values.
+// A nil map means the user cancelled.
+func RunOptionsPrompt(title string, options map[string]template.TemplateOption, given map[string]string) (map[string]string, error) {
+	p := tea.NewProgram(NewOptionsModel(title, options, given))
+	m, err := p.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	if model, ok := m.(OptionsModel); ok && !model.cancelled {
+		return model.values, nil
+	}
+	return nil, nil
+}
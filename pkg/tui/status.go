@@ -1,119 +1,241 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/i18n"
+	"github.com/UPwith-me/Container-Maker/pkg/runtime"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+const (
+	statusRefreshInterval = 2 * time.Second
+	statusHistoryLen      = 30  // Samples kept per container for the sparklines
+	statusLogTail         = 200 // Log lines fetched for the log pane
+)
+
 // StatusModel represents the status dashboard model
 type StatusModel struct {
-	containers []ContainerInfo
+	rt         runtime.ContainerRuntime
+	containers []runtime.ContainerSummary
+	history    map[string]*statsHistory
+	logs       []string
+	logScroll  int  // Lines scrolled up from the bottom of the log pane
+	logFocus   bool // Arrow keys scroll the log pane instead of the list
 	selected   int
 	width      int
 	height     int
 	quitting   bool
 	loading    bool
+	message    string // Result of the last action
 	err        error
 }
 
-// ContainerInfo holds container display information
-type ContainerInfo struct {
-	ID      string
-	Name    string
-	Image   string
-	Status  string
-	Ports   string
-	Created string
+// statsHistory holds the recent samples of one container
+type statsHistory struct {
+	cpu  []float64
+	mem  []float64
+	last *runtime.ContainerStats
+}
+
+func (h *statsHistory) add(s *runtime.ContainerStats) {
+	h.cpu = appendSample(h.cpu, s.CPUPercent)
+	h.mem = appendSample(h.mem, s.MemoryPercent)
+	h.last = s
+}
+
+func appendSample(samples []float64, v float64) []float64 {
+	samples = append(samples, v)
+	if len(samples) > statusHistoryLen {
+		samples = samples[len(samples)-statusHistoryLen:]
+	}
+	return samples
 }
 
-// NewStatusModel creates a new status dashboard model
-func NewStatusModel() StatusModel {
+// NewStatusModel creates a new status dashboard model on a container runtime
+func NewStatusModel(rt runtime.ContainerRuntime) StatusModel {
 	return StatusModel{
+		rt:      rt,
+		history: make(map[string]*statsHistory),
 		loading: true,
 	}
 }
 
-type containersLoadedMsg []ContainerInfo
+type containersLoadedMsg []runtime.ContainerSummary
+type statsLoadedMsg map[string]*runtime.ContainerStats
+type logsLoadedMsg struct {
+	id   string
+	logs string
+}
+type actionDoneMsg struct {
+	message string
+	err     error
+}
+type statusTickMsg time.Time
 type errMsg error
 
-func loadContainers() tea.Msg {
-	cmd := exec.Command("docker", "ps", "--format", "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Status}}\t{{.Ports}}\t{{.CreatedAt}}")
-	output, err := cmd.Output()
+func (m StatusModel) loadContainers() tea.Msg {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	containers, err := m.rt.ListContainers(ctx, true)
 	if err != nil {
 		return errMsg(err)
 	}
+	return containersLoadedMsg(containers)
+}
+
+// loadStats samples the running containers in parallel
+func (m StatusModel) loadStats() tea.Msg {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	var containers []ContainerInfo
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		if line == "" {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	stats := make(statsLoadedMsg)
+	for _, c := range m.containers {
+		if c.State != "running" {
 			continue
 		}
-		parts := strings.Split(line, "\t")
-		if len(parts) >= 6 {
-			containers = append(containers, ContainerInfo{
-				ID:      parts[0],
-				Name:    parts[1],
-				Image:   parts[2],
-				Status:  parts[3],
-				Ports:   parts[4],
-				Created: parts[5],
-			})
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if s, err := m.rt.ContainerStats(ctx, id); err == nil {
+				mu.Lock()
+				stats[id] = s
+				mu.Unlock()
+			}
+		}(c.ID)
+	}
+	wg.Wait()
+	return stats
+}
+
+func (m StatusModel) loadLogs() tea.Cmd {
+	c, ok := m.current()
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		logs, _ := m.rt.ContainerLogs(ctx, c.ID, statusLogTail)
+		return logsLoadedMsg{id: c.ID, logs: logs}
+	}
+}
+
+func statusTick() tea.Cmd {
+	return tea.Tick(statusRefreshInterval, func(t time.Time) tea.Msg {
+		return statusTickMsg(t)
+	})
+}
+
+// current returns the selected container
+func (m StatusModel) current() (runtime.ContainerSummary, bool) {
+	if m.selected < 0 || m.selected >= len(m.containers) {
+		return runtime.ContainerSummary{}, false
+	}
+	return m.containers[m.selected], true
+}
+
+// action runs a container operation in the background
+func (m StatusModel) action(verb string, fn func(ctx context.Context, id string) error) tea.Cmd {
+	c, ok := m.current()
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := fn(ctx, c.ID); err != nil {
+			return actionDoneMsg{err: fmt.Errorf("%s %s: %w", verb, c.Name, err)}
 		}
+		return actionDoneMsg{message: fmt.Sprintf("%s %s", verb, c.Name)}
 	}
+}
 
-	return containersLoadedMsg(containers)
+func (m StatusModel) restart(ctx context.Context, id string) error {
+	if err := m.rt.StopContainer(ctx, id, 10); err != nil {
+		return err
+	}
+	return m.rt.StartContainer(ctx, id)
+}
+
+// togglePause pauses a running container or resumes a paused one
+func (m StatusModel) togglePause() tea.Cmd {
+	c, ok := m.current()
+	if !ok {
+		return nil
+	}
+	if c.State == "paused" {
+		return m.action(i18n.T("status.resumed"), m.rt.UnpauseContainer)
+	}
+	return m.action(i18n.T("status.paused"), m.rt.PauseContainer)
+}
+
+// cli returns the runtime's command line tool, for the interactive actions
+func (m StatusModel) cli() string {
+	if m.rt.Path() != "" {
+		return m.rt.Path()
+	}
+	return m.rt.Type()
 }
 
 func (m StatusModel) Init() tea.Cmd {
-	return loadContainers
+	return tea.Batch(m.loadContainers, statusTick())
 }
 
 func (m StatusModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
-			m.quitting = true
-			return m, tea.Quit
-		case "up", "k":
-			if m.selected > 0 {
-				m.selected--
-			}
-		case "down", "j":
-			if m.selected < len(m.containers)-1 {
-				m.selected++
-			}
-		case "r":
-			m.loading = true
-			return m, loadContainers
-		case "l":
-			// View logs for selected container
-			if len(m.containers) > 0 && m.selected < len(m.containers) {
-				containerID := m.containers[m.selected].ID
-				return m, tea.ExecProcess(exec.Command("docker", "logs", "-f", containerID), func(err error) tea.Msg {
-					return nil
-				})
-			}
-		case "s":
-			// Shell into selected container
-			if len(m.containers) > 0 && m.selected < len(m.containers) {
-				containerID := m.containers[m.selected].ID
-				return m, tea.ExecProcess(exec.Command("docker", "exec", "-it", containerID, "/bin/sh"), func(err error) tea.Msg {
-					return nil
-				})
-			}
-		}
+		return m.handleKey(msg)
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+	case statusTickMsg:
+		return m, tea.Batch(m.loadContainers, statusTick())
 	case containersLoadedMsg:
 		m.loading = false
+		m.err = nil
 		m.containers = msg
+		if m.selected >= len(m.containers) {
+			m.selected = max(len(m.containers)-1, 0)
+		}
+		keep := make(map[string]bool)
+		for _, c := range m.containers {
+			keep[c.ID] = true
+		}
+		for id := range m.history {
+			if !keep[id] {
+				delete(m.history, id)
+			}
+		}
+		return m, tea.Batch(m.loadStats, m.loadLogs())
+	case statsLoadedMsg:
+		for id, s := range msg {
+			h := m.history[id]
+			if h == nil {
+				h = &statsHistory{}
+				m.history[id] = h
+			}
+			h.add(s)
+		}
+	case logsLoadedMsg:
+		if c, ok := m.current(); ok && c.ID == msg.id {
+			m.logs = strings.Split(strings.TrimRight(msg.logs, "\n"), "\n")
+		}
+	case actionDoneMsg:
+		if msg.err != nil {
+			m.message = StyleError.Render(msg.err.Error())
+		} else {
+			m.message = StyleSuccess.Render(msg.message)
+		}
+		return m, m.loadContainers
 	case errMsg:
 		m.loading = false
 		m.err = msg
@@ -121,6 +243,74 @@ func (m StatusModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m StatusModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+	case "tab":
+		m.logFocus = !m.logFocus
+	case "up", "k":
+		if m.logFocus {
+			m.logScroll = min(m.logScroll+1, max(len(m.logs)-1, 0))
+		} else if m.selected > 0 {
+			m.selected--
+			return m.selectionChanged()
+		}
+	case "down", "j":
+		if m.logFocus {
+			m.logScroll = max(m.logScroll-1, 0)
+		} else if m.selected < len(m.containers)-1 {
+			m.selected++
+			return m.selectionChanged()
+		}
+	case "pgup":
+		m.logScroll = min(m.logScroll+m.logHeight(), max(len(m.logs)-1, 0))
+	case "pgdown":
+		m.logScroll = max(m.logScroll-m.logHeight(), 0)
+	case "r":
+		m.loading = true
+		return m, m.loadContainers
+	case "x":
+		m.message = i18n.T("status.stopping")
+		return m, m.action(i18n.T("status.stopped"), func(ctx context.Context, id string) error {
+			return m.rt.StopContainer(ctx, id, 10)
+		})
+	case "R":
+		m.message = i18n.T("status.restarting")
+		return m, m.action(i18n.T("status.restarted"), m.restart)
+	case "p":
+		return m, m.togglePause()
+	case "l":
+		// Follow the full log of the selected container
+		if c, ok := m.current(); ok {
+			return m, tea.ExecProcess(exec.Command(m.cli(), "logs", "-f", c.ID), func(err error) tea.Msg {
+				return nil
+			})
+		}
+	case "s":
+		// Shell into selected container
+		if c, ok := m.current(); ok {
+			return m, tea.ExecProcess(exec.Command(m.cli(), "exec", "-it", c.ID, "/bin/sh"), func(err error) tea.Msg {
+				return nil
+			})
+		}
+	}
+	return m, nil
+}
+
+func (m StatusModel) selectionChanged() (tea.Model, tea.Cmd) {
+	m.logs = nil
+	m.logScroll = 0
+	return m, m.loadLogs()
+}
+
+// logHeight is the number of log lines that fit below the container list
+func (m StatusModel) logHeight() int {
+	used := len(m.containers) + 12
+	return max(m.height-used, 5)
+}
+
 func (m StatusModel) View() string {
 	if m.quitting {
 		return ""
@@ -136,22 +326,22 @@ func (m StatusModel) View() string {
 		Padding(0, 2).
 		Width(m.width)
 
-	s.WriteString(headerStyle.Render("📦 Container-Make Status Dashboard"))
+	s.WriteString(headerStyle.Render(i18n.T("status.title", m.rt.Name())))
 	s.WriteString("\n\n")
 
-	if m.loading {
-		s.WriteString(StyleInfo.Render("Loading containers..."))
+	if m.loading && len(m.containers) == 0 {
+		s.WriteString(StyleInfo.Render(i18n.T("status.loading")))
 		return s.String()
 	}
 
 	if m.err != nil {
-		s.WriteString(StyleError.Render(fmt.Sprintf("Error: %v", m.err)))
+		s.WriteString(StyleError.Render(i18n.T("status.error", m.err)))
 		return s.String()
 	}
 
 	if len(m.containers) == 0 {
-		s.WriteString(StyleSubtle.Render("No running containers found.\n"))
-		s.WriteString(StyleSubtle.Render("Run 'cm run -- <command>' to start a container."))
+		s.WriteString(StyleSubtle.Render(i18n.T("status.empty") + "\n"))
+		s.WriteString(StyleSubtle.Render(i18n.T("status.empty_hint")))
 		return s.String()
 	}
 
@@ -165,44 +355,129 @@ func (m StatusModel) View() string {
 			style = lipgloss.NewStyle().Foreground(ColorSecondary).Bold(true)
 		}
 
-		// Format container info
-		name := c.Name
-		if len(name) > 20 {
-			name = name[:17] + "..."
-		}
-		image := c.Image
-		if len(image) > 30 {
-			image = image[:27] + "..."
+		line := fmt.Sprintf("%s%-20s  %-30s  %-10s", cursor, truncateText(c.Name, 20), truncateText(c.Image, 30), c.State)
+		if h := m.history[c.ID]; h != nil && c.State == "running" {
+			line += fmt.Sprintf("  CPU %s %5.1f%%  MEM %s %5.1f%%",
+				sparkline(h.cpu, 10), h.last.CPUPercent, sparkline(h.mem, 10), h.last.MemoryPercent)
 		}
-
-		line := fmt.Sprintf("%s%-20s  %-30s  %s", cursor, name, image, c.Status)
 		s.WriteString(style.Render(line))
 		s.WriteString("\n")
+	}
 
-		if i == m.selected {
-			// Show details for selected container
-			detailStyle := lipgloss.NewStyle().Foreground(ColorSubtle).PaddingLeft(4)
-			s.WriteString(detailStyle.Render(fmt.Sprintf("ID: %s", c.ID)))
+	// Details for the selected container
+	if c, ok := m.current(); ok {
+		detailStyle := lipgloss.NewStyle().Foreground(ColorSubtle).PaddingLeft(4)
+		s.WriteString("\n")
+		s.WriteString(detailStyle.Render(fmt.Sprintf("ID: %.12s   Status: %s", c.ID, c.Status)))
+		s.WriteString("\n")
+		if h := m.history[c.ID]; h != nil {
+			s.WriteString(detailStyle.Render(fmt.Sprintf("Memory: %s", h.last.MemoryUsage)))
 			s.WriteString("\n")
-			if c.Ports != "" {
-				s.WriteString(detailStyle.Render(fmt.Sprintf("Ports: %s", c.Ports)))
-				s.WriteString("\n")
-			}
+		}
+		if c.Ports != "" {
+			s.WriteString(detailStyle.Render(fmt.Sprintf("Ports: %s", c.Ports)))
 			s.WriteString("\n")
 		}
+		s.WriteString(m.renderLogs())
+	}
+
+	if m.message != "" {
+		s.WriteString("\n")
+		s.WriteString(m.message)
 	}
 
 	// Help
 	s.WriteString("\n")
 	helpStyle := lipgloss.NewStyle().Foreground(ColorSubtle)
-	s.WriteString(helpStyle.Render("↑/↓: Navigate  r: Refresh  l: Logs  s: Shell  q: Quit"))
+	s.WriteString(helpStyle.Render(i18n.T("status.help")))
 
 	return s.String()
 }
 
-// RunStatusDashboard runs the status dashboard
+// renderLogs renders the log pane of the selected container
+func (m StatusModel) renderLogs() string {
+	height := m.logHeight()
+	end := max(len(m.logs)-m.logScroll, 0)
+	start := max(end-height, 0)
+	lines := m.logs[start:end]
+
+	title := i18n.T("status.logs")
+	if m.logScroll > 0 {
+		title += i18n.T("status.logs_scroll", m.logScroll)
+	}
+	border := ColorSubtle
+	if m.logFocus {
+		border = ColorPrimary
+	}
+	width := m.width - 2
+	if width < 20 {
+		width = 78
+	}
+
+	var body strings.Builder
+	for i := 0; i < height; i++ {
+		if i < len(lines) {
+			body.WriteString(truncateText(lines[i], width-4))
+		}
+		if i < height-1 {
+			body.WriteString("\n")
+		}
+	}
+	if len(m.logs) == 0 {
+		body.Reset()
+		body.WriteString(StyleSubtle.Render(i18n.T("status.no_output")))
+	}
+
+	pane := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(border).
+		Width(width - 2).
+		Render(body.String())
+	return "\n" + StyleSubtle.Render(title) + "\n" + pane + "\n"
+}
+
+// sparkline renders percentages as a bar chart of block characters
+func sparkline(values []float64, width int) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := 100.0
+	for _, v := range values {
+		if v > peak {
+			peak = v // Several CPUs can exceed 100%
+		}
+	}
+
+	var b strings.Builder
+	for i := len(values); i < width; i++ {
+		b.WriteRune(' ')
+	}
+	for _, v := range values {
+		idx := int(v / peak * float64(len(blocks)-1))
+		idx = max(min(idx, len(blocks)-1), 0)
+		b.WriteRune(blocks[idx])
+	}
+	return b.String()
+}
+
+// truncateText shortens s to n runes with an ellipsis
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n || n < 4 {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// RunStatusDashboard runs the status dashboard on the active container
+// runtime, so it works with Podman as well as Docker
 func RunStatusDashboard() error {
-	p := tea.NewProgram(NewStatusModel(), tea.WithAltScreen())
-	_, err := p.Run()
+	rt, err := runtime.GetActiveRuntime()
+	if err != nil {
+		return err
+	}
+	p := tea.NewProgram(NewStatusModel(rt), tea.WithAltScreen())
+	_, err = p.Run()
 	return err
 }
@@ -1,25 +1,24 @@
 package update
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/UPwith-me/Container-Maker/pkg/httpclient"
 	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
 )
 
-const (
-	updateCheckInterval = 24 * time.Hour
-	githubAPIURL        = "https://api.github.com/repos/UPwith-me/Container-Maker/releases/latest"
-)
+const updateCheckInterval = 24 * time.Hour
 
 type Release struct {
-	TagName string `json:"tag_name"`
-	HTMLURL string `json:"html_url"`
-	Body    string `json:"body"`
+	TagName    string  `json:"tag_name"`
+	HTMLURL    string  `json:"html_url"`
+	Body       string  `json:"body"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []Asset `json:"assets"`
 }
 
 // CheckForUpdates performs a non-blocking check for new versions.
@@ -50,34 +49,16 @@ func CheckForUpdates(currentVersion string) {
 		}
 
 		// 5. Compare versions
-		// Naive comparison: if tag != v + currentVersion
-		// In production, use semver library
-		remoteVer := strings.TrimPrefix(rel.TagName, "v")
-		currentVer := strings.TrimPrefix(currentVersion, "v")
-
-		if remoteVer != currentVer && remoteVer != "" {
+		if CompareVersions(rel.TagName, currentVersion) > 0 {
 			// Notify user (print to Stderr to avoid messing up pipes)
 			fmt.Fprintf(os.Stderr, "\n📦 New version available: %s -> %s\n", currentVersion, rel.TagName)
-			fmt.Fprintf(os.Stderr, "   Run 'cm update' or visit: %s\n", rel.HTMLURL)
+			fmt.Fprintf(os.Stderr, "   Run 'cm upgrade' or visit: %s\n", rel.HTMLURL)
 		}
 	}()
 }
 
 func fetchLatestRelease() (*Release, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(githubAPIURL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
-
-	var rel Release
-	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return nil, err
-	}
-	return &rel, nil
+	// A background check is not worth retrying
+	client := httpclient.New(httpclient.Options{Timeout: 5 * time.Second, Retries: -1})
+	return LatestRelease(context.Background(), client, Channel())
 }
@@ -0,0 +1,202 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// Release channels: stable only takes full releases, beta also takes
+// prereleases
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
+
+const githubReleasesURL = "https://api.github.com/repos/UPwith-me/Container-Maker/releases"
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// Asset returns the release's file with the given name
+func (r *Release) Asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// Channel returns the configured release channel, stable by default
+func Channel() string {
+	if cfg, err := userconfig.Load(); err == nil && cfg.Update.Channel != "" {
+		return cfg.Update.Channel
+	}
+	return ChannelStable
+}
+
+// ValidChannel checks a channel name
+func ValidChannel(channel string) error {
+	switch channel {
+	case ChannelStable, ChannelBeta:
+		return nil
+	}
+	return fmt.Errorf("unknown release channel %q: use %s or %s", channel, ChannelStable, ChannelBeta)
+}
+
+// LatestRelease returns the newest release on a channel
+func LatestRelease(ctx context.Context, client *http.Client, channel string) (*Release, error) {
+	if err := ValidChannel(channel); err != nil {
+		return nil, err
+	}
+	if channel == ChannelStable {
+		var rel Release
+		if err := getJSON(ctx, client, githubReleasesURL+"/latest", &rel); err != nil {
+			return nil, err
+		}
+		return &rel, nil
+	}
+
+	var releases []Release
+	if err := getJSON(ctx, client, githubReleasesURL+"?per_page=30", &releases); err != nil {
+		return nil, err
+	}
+	return newestRelease(releases)
+}
+
+// newestRelease returns the release with the highest version, skipping
+// drafts
+func newestRelease(releases []Release) (*Release, error) {
+	var newest *Release
+	for i := range releases {
+		rel := &releases[i]
+		if rel.Draft {
+			continue
+		}
+		if newest == nil || CompareVersions(rel.TagName, newest.TagName) > 0 {
+			newest = rel
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no releases found")
+	}
+	return newest, nil
+}
+
+// ReleaseByTag returns a specific release, e.g. "v2.1.0"
+func ReleaseByTag(ctx context.Context, client *http.Client, tag string) (*Release, error) {
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	var rel Release
+	if err := getJSON(ctx, client, githubReleasesURL+"/tags/"+tag, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("release not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v)
+}
+
+// AssetName returns the release binary for a platform, as the release
+// workflow names it: cm-linux-amd64, cm-windows-amd64.exe, ...
+func AssetName(goos, goarch string) string {
+	name := "cm-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// CurrentAssetName returns the release binary for this platform
+func CurrentAssetName() string {
+	return AssetName(runtime.GOOS, runtime.GOARCH)
+}
+
+// CompareVersions compares versions like "v2.1.0" and "2.1.0-beta.2",
+// returning -1, 0 or 1. A prerelease sorts before its release.
+func CompareVersions(a, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	if c := compareDotted(aCore, bCore, false); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareDotted(aPre, bPre, true)
+}
+
+// compareDotted compares dot-separated parts numerically, or as strings
+// when a part isn't a number and strings are allowed
+func compareDotted(a, b string, strs bool) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		if x == "" && strs {
+			return -1
+		}
+		if y == "" && strs {
+			return 1
+		}
+		switch {
+		case xErr == nil && yErr == nil || !strs:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,267 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Release files that secure the binaries: sha256sum output for every
+// binary, and a cosign bundle signing it from the release workflow
+const (
+	ChecksumsAsset = "checksums.txt"
+	SignatureAsset = "checksums.txt.bundle"
+)
+
+// Signing identity of the release workflow, for keyless cosign verification
+const (
+	signerIdentity = `^https://github\.com/UPwith-me/Container-Maker/\.github/workflows/release\.yml@refs/tags/v`
+	signerIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// Install is how the running binary was installed
+type Install struct {
+	Path    string
+	Manager string // "", or the package manager owning the binary
+	Command string // How to upgrade with that package manager
+}
+
+// Managed reports whether a package manager owns the binary, so cm must
+// not replace it itself
+func (i Install) Managed() bool {
+	return i.Manager != ""
+}
+
+// ownedByPackage asks the system package databases whether a file belongs
+// to a package, returning the manager's name; a var so tests can stub it
+var ownedByPackage = func(path string) string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	for _, probe := range []struct {
+		manager string
+		args    []string
+	}{{"apt", []string{"dpkg", "-S", path}}, {"dnf", []string{"rpm", "-qf", path}}, {"apk", []string{"apk", "info", "--who-owns", path}}, {"pacman", []string{"pacman", "-Qo", path}}} {
+		if _, err := exec.LookPath(probe.args[0]); err != nil {
+			continue
+		}
+		if exec.Command(probe.args[0], probe.args[1:]...).Run() == nil {
+			return probe.manager
+		}
+	}
+	return ""
+}
+
+// managerCommands are the upgrade commands of package managers
+var managerCommands = map[string]string{
+	"homebrew":   "brew upgrade cm",
+	"scoop":      "scoop update cm",
+	"winget":     "winget upgrade Container-Maker",
+	"chocolatey": "choco upgrade cm",
+	"nix":        "update cm through your Nix configuration or profile",
+	"snap":       "snap refresh cm",
+	"go":         "go install github.com/UPwith-me/Container-Maker/cmd/cm@latest",
+	"apt":        "sudo apt update && sudo apt install --only-upgrade cm",
+	"dnf":        "sudo dnf upgrade cm",
+	"apk":        "sudo apk upgrade cm",
+	"pacman":     "sudo pacman -Syu cm",
+}
+
+// DetectInstall works out from the binary's location whether a package
+// manager installed it
+func DetectInstall(path string) Install {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	install := Install{Path: path}
+
+	slashed := strings.ToLower(filepath.ToSlash(path))
+	switch {
+	case strings.Contains(slashed, "/cellar/") || strings.Contains(slashed, "/homebrew/") || strings.Contains(slashed, "/linuxbrew/"):
+		install.Manager = "homebrew"
+	case strings.Contains(slashed, "/scoop/"):
+		install.Manager = "scoop"
+	case strings.Contains(slashed, "/winget/"):
+		install.Manager = "winget"
+	case strings.Contains(slashed, "/chocolatey/"):
+		install.Manager = "chocolatey"
+	case strings.HasPrefix(slashed, "/nix/store/"):
+		install.Manager = "nix"
+	case strings.HasPrefix(slashed, "/snap/"):
+		install.Manager = "snap"
+	case inGoBin(path):
+		install.Manager = "go"
+	case strings.HasPrefix(slashed, "/usr/") && !strings.HasPrefix(slashed, "/usr/local/"):
+		install.Manager = ownedByPackage(path)
+	}
+	install.Command = managerCommands[install.Manager]
+	return install
+}
+
+// inGoBin reports whether path is in the directory 'go install' writes to
+func inGoBin(path string) bool {
+	dirs := []string{os.Getenv("GOBIN")}
+	if gopath := os.Getenv("GOPATH"); gopath != "" {
+		for _, p := range filepath.SplitList(gopath) {
+			dirs = append(dirs, filepath.Join(p, "bin"))
+		}
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "go", "bin"))
+	}
+	for _, dir := range dirs {
+		if dir != "" && filepath.Dir(path) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Download fetches a release file
+func Download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 512<<20))
+}
+
+// ParseChecksums reads sha256sum output into a map of file name to hash
+func ParseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		// Binary mode marks names with '*'; CI artifacts may keep a directory
+		name := filepath.Base(filepath.ToSlash(strings.TrimPrefix(fields[1], "*")))
+		sums[name] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// VerifyChecksum checks a downloaded file against the release checksums
+func VerifyChecksum(data, checksums []byte, name string) error {
+	want, ok := ParseChecksums(checksums)[name]
+	if !ok {
+		return fmt.Errorf("%s has no checksum for %s", ChecksumsAsset, name)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+	return nil
+}
+
+// ErrNoCosign is returned by VerifySignature when cosign isn't installed
+var ErrNoCosign = fmt.Errorf("cosign not found; install it from https://docs.sigstore.dev/cosign/system_config/installation/ to verify release signatures")
+
+// VerifySignature checks that the release workflow signed the checksums,
+// with cosign's keyless verification of the signature bundle
+func VerifySignature(ctx context.Context, checksums, bundle []byte) error {
+	if _, err := exec.LookPath("cosign"); err != nil {
+		return ErrNoCosign
+	}
+	dir, err := os.MkdirTemp("", "cm-upgrade-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	checksumsPath := filepath.Join(dir, ChecksumsAsset)
+	bundlePath := filepath.Join(dir, SignatureAsset)
+	if err := os.WriteFile(checksumsPath, checksums, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(bundlePath, bundle, 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "cosign", "verify-blob",
+		"--bundle", bundlePath,
+		"--certificate-identity-regexp", signerIdentity,
+		"--certificate-oidc-issuer", signerIssuer,
+		checksumsPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if i := strings.LastIndex(msg, "\n"); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return fmt.Errorf("signature verification failed: %s", msg)
+	}
+	return nil
+}
+
+// Replace swaps the binary at path for data. The new binary is written
+// next to it, checked to run, and renamed over it, so the old one stays in
+// place if anything fails. Windows can't overwrite a running program, so
+// there the old binary is moved aside to <path>.old first.
+func Replace(ctx context.Context, path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".cm-upgrade-*")
+	if err != nil {
+		return fmt.Errorf("can't write to %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm() | 0111
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	// A binary for the wrong platform fails here instead of after the swap
+	checkCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(checkCtx, tmpPath, "--version").CombinedOutput(); err != nil {
+		return fmt.Errorf("the downloaded binary doesn't run: %v %s", err, strings.TrimSpace(string(out)))
+	}
+
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			_ = os.Rename(old, path)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,133 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"v2.1.0", "2.0.0", 1},
+		{"2.0.0", "v2.0.0", 0},
+		{"v2.0.10", "v2.0.9", 1},
+		{"v2.1.0-beta.1", "v2.1.0", -1},
+		{"v2.1.0-beta.2", "v2.1.0-beta.10", -1},
+		{"v2.1.0-rc.1", "v2.1.0-beta.3", 1},
+		{"v2.1.0-beta.1", "v2.0.0", 1},
+		{"v3", "v2.9.9", 1},
+	} {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewestRelease(t *testing.T) {
+	rel, err := newestRelease([]Release{
+		{TagName: "v2.0.0"},
+		{TagName: "v2.2.0-beta.1", Draft: true},
+		{TagName: "v2.1.0-beta.2", Prerelease: true},
+		{TagName: "v2.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.TagName != "v2.1.0-beta.2" {
+		t.Errorf("newest = %s, want v2.1.0-beta.2", rel.TagName)
+	}
+	if _, err := newestRelease(nil); err == nil {
+		t.Error("no releases should fail")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	binary := []byte("cm binary")
+	sum := sha256.Sum256(binary)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  cm-linux-amd64\n" +
+		"0000000000000000000000000000000000000000000000000000000000000000 *artifacts/cm-darwin-arm64\n")
+
+	if err := VerifyChecksum(binary, checksums, "cm-linux-amd64"); err != nil {
+		t.Errorf("valid checksum: %v", err)
+	}
+	if err := VerifyChecksum(binary, checksums, "cm-darwin-arm64"); err == nil {
+		t.Error("mismatched checksum should fail")
+	}
+	if err := VerifyChecksum(binary, checksums, "cm-windows-amd64.exe"); err == nil {
+		t.Error("missing checksum should fail")
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	if got := AssetName("windows", "amd64"); got != "cm-windows-amd64.exe" {
+		t.Errorf("AssetName = %s", got)
+	}
+	if got := AssetName("darwin", "arm64"); got != "cm-darwin-arm64" {
+		t.Errorf("AssetName = %s", got)
+	}
+}
+
+func TestDetectInstall(t *testing.T) {
+	defer func(f func(string) string) { ownedByPackage = f }(ownedByPackage)
+	ownedByPackage = func(path string) string {
+		if path == "/usr/bin/cm" {
+			return "apt"
+		}
+		return ""
+	}
+	t.Setenv("GOBIN", "/home/dev/gobin")
+
+	for path, want := range map[string]string{
+		"/opt/homebrew/bin/cm":                      "homebrew",
+		"/usr/local/Cellar/cm/2.0.0/bin/cm":         "homebrew",
+		"C:/Users/dev/scoop/apps/cm/current/cm.exe": "scoop",
+		"/nix/store/abc-cm-2.0.0/bin/cm":            "nix",
+		"/home/dev/gobin/cm":                        "go",
+		"/usr/bin/cm":                               "apt",
+		"/usr/local/bin/cm":                         "",
+		"/home/dev/.local/bin/cm":                   "",
+	} {
+		got := DetectInstall(path)
+		if got.Manager != want {
+			t.Errorf("DetectInstall(%q) = %q, want %q", path, got.Manager, want)
+		}
+		if got.Managed() != (want != "") || (want != "" && got.Command == "") {
+			t.Errorf("DetectInstall(%q) = %+v", path, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as binaries")
+	}
+	path := filepath.Join(t.TempDir(), "cm")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho old\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(context.Background(), path, []byte("#!/bin/sh\nexit 3\n")); err == nil {
+		t.Error("a binary that doesn't run should not replace the old one")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "#!/bin/sh\necho old\n" {
+		t.Errorf("old binary changed: %q", got)
+	}
+
+	if err := Replace(context.Background(), path, []byte("#!/bin/sh\necho new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "#!/bin/sh\necho new\n" {
+		t.Errorf("binary not replaced: %q", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %d entries", len(entries))
+	}
+}
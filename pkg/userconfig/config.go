@@ -2,24 +2,38 @@ package userconfig
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 // UserConfig holds persistent user preferences
 type UserConfig struct {
 	SkipWelcome    bool              `json:"skip_welcome"`
 	DefaultBackend string            `json:"default_backend,omitempty"`
+	Locale         string            `json:"locale,omitempty"` // Language of cm's messages: en, zh or auto
 	AI             AIConfig          `json:"ai,omitempty"`
 	RemoteHosts    map[string]string `json:"remote_hosts,omitempty"`
 	ActiveRemote   string            `json:"active_remote,omitempty"`
 	Team           TeamConfig        `json:"team,omitempty"`
 	Analytics      AnalyticsConfig   `json:"analytics,omitempty"`
-
-	// Cloud Control Plane
-	CloudAPIKey string `json:"cloud_api_key,omitempty"`
-	CloudToken  string `json:"cloud_token,omitempty"`
-	CloudAPIURL string `json:"cloud_api_url,omitempty"`
+	Marketplace    MarketplaceConfig `json:"marketplace,omitempty"`
+	Proxy          ProxyConfig       `json:"proxy,omitempty"`
+	Policy         PolicyConfig      `json:"policy,omitempty"`
+	Verify         VerifyConfig      `json:"verify,omitempty"`
+	Stats          StatsConfig       `json:"stats,omitempty"`
+	Ports          PortsConfig       `json:"ports,omitempty"`
+	Share          ShareConfig       `json:"share,omitempty"`
+	Host           HostConfig        `json:"host,omitempty"`
+	Env            EnvConfig         `json:"env,omitempty"`
+	Update         UpdateConfig      `json:"update,omitempty"`
+
+	// Cloud Control Plane; credentials live here only when no OS keychain is available
+	CloudAPIKey       string `json:"cloud_api_key,omitempty"`
+	CloudToken        string `json:"cloud_token,omitempty"`
+	CloudRefreshToken string `json:"cloud_refresh_token,omitempty"`
+	CloudAPIURL       string `json:"cloud_api_url,omitempty"`
 
 	// System state
 	LastUpdateCheck int64 `json:"last_update_check,omitempty"` // Unix timestamp
@@ -27,10 +41,13 @@ type UserConfig struct {
 
 // AIConfig holds AI-related settings
 type AIConfig struct {
-	Enabled bool   `json:"enabled"`
-	APIKey  string `json:"api_key,omitempty"`
-	APIBase string `json:"api_base,omitempty"`
-	Model   string `json:"model,omitempty"`
+	Enabled    bool   `json:"enabled"`
+	Provider   string `json:"provider,omitempty"` // openai (default), anthropic, azure, ollama or llamacpp
+	APIKey     string `json:"api_key,omitempty"`
+	APIBase    string `json:"api_base,omitempty"`
+	Model      string `json:"model,omitempty"`       // Deployment name on Azure
+	EmbedModel string `json:"embed_model,omitempty"` // Model or deployment for embeddings
+	APIVersion string `json:"api_version,omitempty"` // Azure OpenAI API version
 }
 
 // TeamConfig holds team/org settings for enterprise template management
@@ -64,10 +81,83 @@ type TeamRepository struct {
 	CacheTTL     int    `json:"cache_ttl_hours,omitempty"` // Cache validity (hours)
 }
 
-// AnalyticsConfig holds anonymous usage statistics settings
+// AnalyticsConfig holds the opt-in performance metrics settings
 type AnalyticsConfig struct {
 	Enabled   bool   `json:"enabled"`
 	SessionID string `json:"session_id,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"` // Where 'cm analytics export' sends metrics; empty keeps them local
+}
+
+// MarketplaceConfig holds template marketplace settings
+type MarketplaceConfig struct {
+	IndexURL  string `json:"index_url,omitempty"`  // Empty = default community index
+	PublicKey string `json:"public_key,omitempty"` // Base64 ed25519 key the index must be signed with; empty = the community index's key
+}
+
+// ProxyConfig holds the proxy used for registry, marketplace and AI requests
+// when the HTTP_PROXY family of environment variables is not set
+type ProxyConfig struct {
+	HTTPProxy  string `json:"http,omitempty"`
+	HTTPSProxy string `json:"https,omitempty"`
+	NoProxy    string `json:"no_proxy,omitempty"` // Comma-separated hosts, domains and CIDRs
+}
+
+// PolicyConfig holds the organization policy applied to every project
+type PolicyConfig struct {
+	Source string `json:"source,omitempty"` // Path or URL of the organization's policy file
+}
+
+// VerifyConfig holds image signature verification settings
+type VerifyConfig struct {
+	Strict bool        `json:"strict"`          // Refuse images that cannot be verified
+	Trust  []TrustRule `json:"trust,omitempty"` // Signers trusted per registry
+}
+
+// TrustRule names who must have signed images under a registry prefix.
+// Key-based rules set Key; keyless rules set Identity and Issuer.
+type TrustRule struct {
+	Registry string `json:"registry"`           // e.g. "ghcr.io/acme" or "mcr.microsoft.com"
+	Key      string `json:"key,omitempty"`      // cosign public key: file, URL or KMS URI
+	Identity string `json:"identity,omitempty"` // Certificate identity regexp
+	Issuer   string `json:"issuer,omitempty"`   // OIDC issuer, e.g. https://token.actions.githubusercontent.com
+}
+
+// StatsConfig holds resource monitoring settings
+type StatsConfig struct {
+	IdlePauseMinutes int `json:"idle_pause_minutes,omitempty"` // Pause persistent containers idle this long; 0 = never
+}
+
+// PortsConfig holds port forwarding settings
+type PortsConfig struct {
+	Remap bool `json:"remap"` // Move forwarded ports off busy host ports instead of skipping them
+	HTTPS bool `json:"https"` // Serve forwarded ports at https://<port>.<project>.localhost
+}
+
+// ShareConfig holds 'cm share' settings
+type ShareConfig struct {
+	Relay string `json:"relay,omitempty"` // Self-hosted relay; empty uses CM cloud
+	Token string `json:"token,omitempty"` // Token the self-hosted relay requires
+}
+
+// HostConfig holds the host settings containers inherit; the time zone and
+// locale are passed on unless turned off
+type HostConfig struct {
+	NoTimezone bool `json:"no_timezone"` // Keep the image's time zone (UTC)
+	NoLocale   bool `json:"no_locale"`   // Keep the image's locale
+	CACerts    bool `json:"ca_certs"`    // Mount and install the host's CA certificates
+
+	// Don't install the runtimes pinned in .tool-versions or .mise.toml
+	NoToolVersions bool `json:"no_tool_versions"`
+}
+
+// EnvConfig holds 'cm env' settings
+type EnvConfig struct {
+	Hosts bool `json:"hosts"` // Keep <name>.cm.local entries for running environments in the hosts file
+}
+
+// UpdateConfig holds 'cm upgrade' settings
+type UpdateConfig struct {
+	Channel string `json:"channel,omitempty"` // "stable" (default) or "beta"
 }
 
 // configPath returns the path to the user config file
@@ -120,6 +210,10 @@ func applyEnvOverrides(cfg *UserConfig) {
 	if v := os.Getenv("CM_AI_API_KEY"); v != "" {
 		cfg.AI.APIKey = v
 	}
+	// CM_AI_PROVIDER
+	if v := os.Getenv("CM_AI_PROVIDER"); v != "" {
+		cfg.AI.Provider = v
+	}
 	// CM_AI_MODEL
 	if v := os.Getenv("CM_AI_MODEL"); v != "" {
 		cfg.AI.Model = v
@@ -128,10 +222,26 @@ func applyEnvOverrides(cfg *UserConfig) {
 	if v := os.Getenv("CM_AI_API_BASE"); v != "" {
 		cfg.AI.APIBase = v
 	}
+	// CM_MARKETPLACE_URL
+	if v := os.Getenv("CM_MARKETPLACE_URL"); v != "" {
+		cfg.Marketplace.IndexURL = v
+	}
 	// CM_DEFAULT_BACKEND
 	if v := os.Getenv("CM_DEFAULT_BACKEND"); v != "" {
 		cfg.DefaultBackend = v
 	}
+	// CM_POLICY
+	if v := os.Getenv("CM_POLICY"); v != "" {
+		cfg.Policy.Source = v
+	}
+	// CM_LOCALE
+	if v := os.Getenv("CM_LOCALE"); v != "" {
+		cfg.Locale = v
+	}
+	// CM_CLOUD_URL
+	if v := os.Getenv("CM_CLOUD_URL"); v != "" {
+		cfg.CloudAPIURL = v
+	}
 }
 
 // Save saves the user config to disk
@@ -187,6 +297,8 @@ func Get(key string) (string, error) {
 		return "false", nil
 	case "default_backend":
 		return cfg.DefaultBackend, nil
+	case "locale":
+		return cfg.Locale, nil
 	case "ai.enabled":
 		if cfg.AI.Enabled {
 			return "true", nil
@@ -197,10 +309,89 @@ func Get(key string) (string, error) {
 			return "***hidden***", nil
 		}
 		return "", nil
+	case "ai.provider":
+		return cfg.AI.Provider, nil
 	case "ai.api_base":
 		return cfg.AI.APIBase, nil
 	case "ai.model":
 		return cfg.AI.Model, nil
+	case "ai.embed_model":
+		return cfg.AI.EmbedModel, nil
+	case "ai.api_version":
+		return cfg.AI.APIVersion, nil
+	case "analytics.enabled":
+		if cfg.Analytics.Enabled {
+			return "true", nil
+		}
+		return "false", nil
+	case "analytics.endpoint":
+		return cfg.Analytics.Endpoint, nil
+	case "marketplace.index_url":
+		return cfg.Marketplace.IndexURL, nil
+	case "marketplace.public_key":
+		return cfg.Marketplace.PublicKey, nil
+	case "proxy.http":
+		return cfg.Proxy.HTTPProxy, nil
+	case "proxy.https":
+		return cfg.Proxy.HTTPSProxy, nil
+	case "proxy.no_proxy":
+		return cfg.Proxy.NoProxy, nil
+	case "policy.source":
+		return cfg.Policy.Source, nil
+	case "verify.strict":
+		if cfg.Verify.Strict {
+			return "true", nil
+		}
+		return "false", nil
+	case "share.relay":
+		return cfg.Share.Relay, nil
+	case "share.token":
+		if cfg.Share.Token != "" {
+			return "***hidden***", nil
+		}
+		return "", nil
+	case "ports.remap":
+		if cfg.Ports.Remap {
+			return "true", nil
+		}
+		return "false", nil
+	case "ports.https":
+		if cfg.Ports.HTTPS {
+			return "true", nil
+		}
+		return "false", nil
+	case "host.no_timezone":
+		if cfg.Host.NoTimezone {
+			return "true", nil
+		}
+		return "false", nil
+	case "host.no_locale":
+		if cfg.Host.NoLocale {
+			return "true", nil
+		}
+		return "false", nil
+	case "host.ca_certs":
+		if cfg.Host.CACerts {
+			return "true", nil
+		}
+		return "false", nil
+	case "host.no_tool_versions":
+		if cfg.Host.NoToolVersions {
+			return "true", nil
+		}
+		return "false", nil
+	case "env.hosts":
+		if cfg.Env.Hosts {
+			return "true", nil
+		}
+		return "false", nil
+	case "update.channel":
+		return cfg.Update.Channel, nil
+	case "stats.idle_pause_minutes":
+		if cfg.Stats.IdlePauseMinutes == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(cfg.Stats.IdlePauseMinutes), nil
 	default:
 		return "", nil
 	}
@@ -219,14 +410,76 @@ func Set(key, value string) error {
 		cfg.SkipWelcome = value == "true" || value == "1"
 	case "default_backend":
 		cfg.DefaultBackend = value
+	case "locale":
+		cfg.Locale = value
 	case "ai.enabled":
 		cfg.AI.Enabled = value == "true" || value == "1"
+	case "ai.provider":
+		switch value {
+		case "", "openai", "anthropic", "azure", "ollama", "llamacpp":
+		default:
+			return fmt.Errorf("ai.provider must be openai, anthropic, azure, ollama or llamacpp")
+		}
+		cfg.AI.Provider = value
 	case "ai.api_key":
 		cfg.AI.APIKey = value
 	case "ai.api_base":
 		cfg.AI.APIBase = value
 	case "ai.model":
 		cfg.AI.Model = value
+	case "ai.embed_model":
+		cfg.AI.EmbedModel = value
+	case "ai.api_version":
+		cfg.AI.APIVersion = value
+	case "analytics.enabled":
+		cfg.Analytics.Enabled = value == "true" || value == "1"
+	case "analytics.endpoint":
+		cfg.Analytics.Endpoint = value
+	case "marketplace.index_url":
+		cfg.Marketplace.IndexURL = value
+	case "marketplace.public_key":
+		cfg.Marketplace.PublicKey = value
+	case "proxy.http":
+		cfg.Proxy.HTTPProxy = value
+	case "proxy.https":
+		cfg.Proxy.HTTPSProxy = value
+	case "proxy.no_proxy":
+		cfg.Proxy.NoProxy = value
+	case "policy.source":
+		cfg.Policy.Source = value
+	case "verify.strict":
+		cfg.Verify.Strict = value == "true" || value == "1"
+	case "ports.remap":
+		cfg.Ports.Remap = value == "true" || value == "1"
+	case "ports.https":
+		cfg.Ports.HTTPS = value == "true" || value == "1"
+	case "host.no_timezone":
+		cfg.Host.NoTimezone = value == "true" || value == "1"
+	case "host.no_locale":
+		cfg.Host.NoLocale = value == "true" || value == "1"
+	case "host.ca_certs":
+		cfg.Host.CACerts = value == "true" || value == "1"
+	case "host.no_tool_versions":
+		cfg.Host.NoToolVersions = value == "true" || value == "1"
+	case "env.hosts":
+		cfg.Env.Hosts = value == "true" || value == "1"
+	case "share.relay":
+		cfg.Share.Relay = value
+	case "share.token":
+		cfg.Share.Token = value
+	case "update.channel":
+		switch value {
+		case "", "stable", "beta":
+		default:
+			return fmt.Errorf("update.channel must be stable or beta")
+		}
+		cfg.Update.Channel = value
+	case "stats.idle_pause_minutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 {
+			return fmt.Errorf("stats.idle_pause_minutes must be a number of minutes")
+		}
+		cfg.Stats.IdlePauseMinutes = minutes
 	}
 
 	return Save(cfg)
@@ -0,0 +1,239 @@
+// Package verify checks container image signatures with cosign before cm
+// runs or builds from an image. Verification is opt-in: images are checked
+// against trust rules configured per registry with 'cm verify trust add'.
+// In strict mode (--strict or CM_VERIFY_STRICT=1) an image that cannot be
+// verified stops the command; otherwise a warning is printed.
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+// StrictEnvVar enables strict mode when set to 1 or true
+const StrictEnvVar = "CM_VERIFY_STRICT"
+
+var strict bool
+
+// EnableStrict turns on strict mode for the rest of the process
+func EnableStrict() {
+	strict = true
+}
+
+// StrictEnabled reports whether unverified images are refused, via --strict,
+// CM_VERIFY_STRICT or 'cm config set verify.strict true'
+func StrictEnabled() bool {
+	if strict {
+		return true
+	}
+	switch strings.ToLower(os.Getenv(StrictEnvVar)) {
+	case "1", "true", "yes":
+		return true
+	}
+	cfg, err := userconfig.Load()
+	return err == nil && cfg.Verify.Strict
+}
+
+// Result is the outcome of verifying one image
+type Result struct {
+	Image    string
+	Rule     *userconfig.TrustRule // nil when no rule covers the image
+	Verified bool
+	Digest   string // Digest of the verified manifest
+	Err      error
+}
+
+// Verifier runs cosign against the configured trust rules
+type Verifier struct {
+	Rules  []userconfig.TrustRule
+	Cosign string // cosign binary, default "cosign"
+}
+
+// New returns a verifier with the user's trust rules
+func New() (*Verifier, error) {
+	cfg, err := userconfig.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{Rules: cfg.Verify.Trust, Cosign: "cosign"}, nil
+}
+
+// RuleFor returns the most specific rule whose registry prefix covers the
+// image, or nil
+func (v *Verifier) RuleFor(image string) *userconfig.TrustRule {
+	full := NormalizeRef(image)
+	var best *userconfig.TrustRule
+	for i := range v.Rules {
+		prefix := normalizePrefix(v.Rules[i].Registry)
+		if full != prefix && !strings.HasPrefix(full, prefix+"/") && !strings.HasPrefix(full, prefix+":") && !strings.HasPrefix(full, prefix+"@") {
+			continue
+		}
+		if best == nil || len(v.Rules[i].Registry) > len(best.Registry) {
+			best = &v.Rules[i]
+		}
+	}
+	return best
+}
+
+// Verify checks one image against its trust rule
+func (v *Verifier) Verify(ctx context.Context, image string) Result {
+	res := Result{Image: image, Rule: v.RuleFor(image)}
+	if res.Rule == nil {
+		res.Err = fmt.Errorf("no trust rule covers %s", image)
+		return res
+	}
+	if offline.Enabled() {
+		res.Err = fmt.Errorf("signatures cannot be verified in offline mode")
+		return res
+	}
+
+	args, err := CosignArgs(*res.Rule, image)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	cosign := v.Cosign
+	if cosign == "" {
+		cosign = "cosign"
+	}
+	if _, err := exec.LookPath(cosign); err != nil {
+		res.Err = fmt.Errorf("cosign not found; install it from https://docs.sigstore.dev/cosign/system_config/installation/")
+		return res
+	}
+
+	cmd := exec.CommandContext(ctx, cosign, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// cosign prints the reason on its last line
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndex(msg, "\n"); i >= 0 {
+			msg = msg[i+1:]
+		}
+		res.Err = fmt.Errorf("signature verification failed: %s", msg)
+		return res
+	}
+
+	res.Verified = true
+	res.Digest = parseDigest(out)
+	return res
+}
+
+// CosignArgs returns the 'cosign verify' arguments for a rule: key-based
+// when the rule has a key, keyless (certificate identity and OIDC issuer)
+// otherwise
+func CosignArgs(rule userconfig.TrustRule, image string) ([]string, error) {
+	args := []string{"verify", "--output", "json"}
+	switch {
+	case rule.Key != "":
+		args = append(args, "--key", rule.Key)
+	case rule.Identity != "" && rule.Issuer != "":
+		args = append(args, "--certificate-identity-regexp", rule.Identity, "--certificate-oidc-issuer", rule.Issuer)
+	default:
+		return nil, fmt.Errorf("trust rule for %s needs a key, or an identity and an issuer", rule.Registry)
+	}
+	return append(args, image), nil
+}
+
+// parseDigest extracts the manifest digest from cosign's JSON output
+func parseDigest(out []byte) string {
+	var payloads []struct {
+		Critical struct {
+			Image struct {
+				Digest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(out, &payloads); err != nil || len(payloads) == 0 {
+		return ""
+	}
+	return payloads[0].Critical.Image.Digest
+}
+
+// Images verifies the images cm is about to run or build from. Images not
+// covered by a rule are only checked in strict mode, where they are refused.
+// Failures are warnings unless strict mode is on.
+func Images(ctx context.Context, images []string, log io.Writer) error {
+	if log == nil {
+		log = io.Discard
+	}
+	v, err := New()
+	if err != nil {
+		return err
+	}
+	strictMode := StrictEnabled()
+	if len(v.Rules) == 0 && !strictMode {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var refused []string
+	for _, image := range images {
+		if image == "" || seen[image] {
+			continue
+		}
+		seen[image] = true
+
+		if !strictMode && v.RuleFor(image) == nil {
+			continue
+		}
+		fmt.Fprintf(log, "🔏 Verifying signature of %s...\n", image)
+		res := v.Verify(ctx, image)
+		if res.Verified {
+			if res.Digest != "" {
+				fmt.Fprintf(log, "✅ Signature verified (%s)\n", res.Digest)
+			} else {
+				fmt.Fprintln(log, "✅ Signature verified")
+			}
+			continue
+		}
+		if strictMode {
+			fmt.Fprintf(log, "❌ %s: %v\n", image, res.Err)
+			refused = append(refused, image)
+		} else {
+			fmt.Fprintf(log, "⚠️  %s: %v\n", image, res.Err)
+		}
+	}
+
+	if len(refused) > 0 {
+		sort.Strings(refused)
+		return fmt.Errorf("strict mode: refusing unverified image(s) %s; add a rule with 'cm verify trust add'", strings.Join(refused, ", "))
+	}
+	return nil
+}
+
+// NormalizeRef expands Docker Hub short names so rules and images compare
+// equal: "golang" becomes "docker.io/library/golang"
+func NormalizeRef(ref string) string {
+	first, rest, hasSlash := strings.Cut(ref, "/")
+	if first == "docker.io" && !strings.Contains(rest, "/") {
+		return "docker.io/library/" + rest
+	}
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return ref
+	}
+	if !hasSlash {
+		return "docker.io/library/" + ref
+	}
+	return "docker.io/" + ref
+}
+
+// normalizePrefix normalizes a rule's registry prefix; a bare host such as
+// "ghcr.io" stays a host rather than becoming a Docker Hub image
+func normalizePrefix(prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.Contains(prefix, "/") && (strings.ContainsAny(prefix, ".:") || prefix == "localhost") {
+		return prefix
+	}
+	return NormalizeRef(prefix)
+}
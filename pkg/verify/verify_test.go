@@ -0,0 +1,68 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/UPwith-me/Container-Maker/pkg/userconfig"
+)
+
+func TestRuleFor(t *testing.T) {
+	v := &Verifier{Rules: []userconfig.TrustRule{
+		{Registry: "ghcr.io", Key: "org.pub"},
+		{Registry: "ghcr.io/acme", Key: "acme.pub"},
+		{Registry: "golang", Key: "hub.pub"},
+	}}
+	for image, want := range map[string]string{
+		"ghcr.io/acme/dev:1":   "acme.pub",
+		"ghcr.io/other/dev":    "org.pub",
+		"ghcr.io/acmecorp/dev": "org.pub",
+		"golang:1.22":          "hub.pub",
+		"docker.io/golang":     "hub.pub",
+		"python:3.12":          "",
+	} {
+		got := ""
+		if rule := v.RuleFor(image); rule != nil {
+			got = rule.Key
+		}
+		if got != want {
+			t.Errorf("RuleFor(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as cosign")
+	}
+	dir := t.TempDir()
+	cosign := filepath.Join(dir, "cosign")
+	script := `#!/bin/sh
+case "$*" in
+  *good*) echo '[{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}}]' ;;
+  *) echo "Error: no matching signatures" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(cosign, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Verifier{Cosign: cosign, Rules: []userconfig.TrustRule{
+		{Registry: "ghcr.io/acme", Identity: "^https://github.com/acme/", Issuer: "https://token.actions.githubusercontent.com"},
+	}}
+	res := v.Verify(context.Background(), "ghcr.io/acme/good:1")
+	if !res.Verified || res.Digest != "sha256:abc" {
+		t.Errorf("good image: %+v", res)
+	}
+	res = v.Verify(context.Background(), "ghcr.io/acme/bad:1")
+	if res.Verified || res.Err == nil || res.Err.Error() != "signature verification failed: Error: no matching signatures" {
+		t.Errorf("bad image: %+v", res)
+	}
+
+	if _, err := CosignArgs(userconfig.TrustRule{Registry: "x", Identity: "y"}, "x/img"); err == nil {
+		t.Error("a keyless rule without an issuer should be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// containerAgent streams file events from inside the container using inotifywait.
+// This catches changes the host never sees, e.g. generated code written by
+// tools running in the container on Docker Desktop's virtualized filesystem.
+type containerAgent struct {
+	backend       string
+	containerID   string
+	containerRoot string // Workspace path inside the container
+	hostRoot      string // Matching project directory on the host
+}
+
+func newContainerAgent(backend, containerID, containerRoot, hostRoot string) *containerAgent {
+	return &containerAgent{
+		backend:       backend,
+		containerID:   containerID,
+		containerRoot: containerRoot,
+		hostRoot:      hostRoot,
+	}
+}
+
+// Start launches inotifywait in the container and returns a channel of host paths.
+// The channel is closed when the agent exits.
+func (a *containerAgent) Start(ctx context.Context) (<-chan string, error) {
+	script := fmt.Sprintf(`command -v inotifywait >/dev/null 2>&1 || exit 127
+exec inotifywait -m -r -q -e close_write,create,delete,moved_to --format '%%w%%f' %q`, a.containerRoot)
+
+	cmd := exec.CommandContext(ctx, a.backend, "exec", a.containerID, "sh", "-c", script)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start container watch agent: %w", err)
+	}
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			hostPath, ok := a.toHostPath(scanner.Text())
+			if !ok {
+				continue
+			}
+			select {
+			case events <- hostPath:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 127 {
+				fmt.Println("⚠️  inotifywait not found in container; container-side events disabled")
+				fmt.Println("   Install inotify-tools in the image to enable them")
+				return
+			}
+			fmt.Printf("⚠️  Container watch agent stopped: %v\n", err)
+		}
+	}()
+
+	return events, nil
+}
+
+// toHostPath maps a container path under containerRoot to the host project directory
+func (a *containerAgent) toHostPath(containerPath string) (string, bool) {
+	containerPath = path.Clean(strings.TrimSpace(containerPath))
+	rel := strings.TrimPrefix(containerPath, a.containerRoot)
+	if rel == containerPath || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		return "", false
+	}
+	return filepath.Join(a.hostRoot, filepath.FromSlash(strings.TrimPrefix(rel, "/"))), true
+}
@@ -0,0 +1,160 @@
+package watch
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are read from the project root to exclude paths from watching
+var IgnoreFiles = []string{".gitignore", ".cmignore"}
+
+// ignoreRule is a single compiled gitignore pattern
+type ignoreRule struct {
+	negate  bool
+	dirOnly bool
+	self    *regexp.Regexp // Matches the path itself
+	child   *regexp.Regexp // Matches anything below a matching directory
+}
+
+// IgnoreMatcher evaluates gitignore-style rules against project-relative paths
+type IgnoreMatcher struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreMatcher reads the ignore files found in root.
+// Missing files are skipped; the returned matcher is never nil.
+func LoadIgnoreMatcher(root string, names ...string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		m.AddRules(f)
+		f.Close()
+	}
+	return m
+}
+
+// AddRules parses gitignore syntax from r and appends the rules
+func (m *IgnoreMatcher) AddRules(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// Len returns the number of loaded rules
+func (m *IgnoreMatcher) Len() int {
+	return len(m.rules)
+}
+
+// Match reports whether the slash- or OS-separated relative path is ignored.
+// Later rules override earlier ones, so negations ("!keep.log") work as in git.
+func (m *IgnoreMatcher) Match(relPath string, isDir bool) bool {
+	relPath = strings.TrimPrefix(filepath.ToSlash(relPath), "./")
+	if relPath == "" || relPath == "." {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range m.rules {
+		matched := rule.child.MatchString(relPath)
+		if !matched && rule.self.MatchString(relPath) {
+			matched = isDir || !rule.dirOnly
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreLine compiles one line of a gitignore file
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the root
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	body := globToRegexp(line)
+
+	var err error
+	if rule.self, err = regexp.Compile(prefix + body + "$"); err != nil {
+		return ignoreRule{}, false
+	}
+	if rule.child, err = regexp.Compile(prefix + body + "/.*$"); err != nil {
+		return ignoreRule{}, false
+	}
+	return rule, true
+}
+
+// globToRegexp converts a gitignore glob into a regular expression fragment
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				// "**/" matches zero or more directories, a trailing "**" everything
+				if i+2 < len(glob) && glob[i+2] == '/' {
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				} else {
+					sb.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := &IgnoreMatcher{}
+	m.AddRules(strings.NewReader(`# comment
+*.log
+!keep.log
+build/
+/dist
+docs/**/*.tmp
+`))
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"sub/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false}, // dir-only rule
+		{"pkg/build/out.o", false, true},
+		{"dist", true, true},
+		{"dist/app.js", false, true},
+		{"web/dist/app.js", false, false}, // anchored to root
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"SIGHUP", "HUP", false},
+		{"hup", "HUP", false},
+		{"USR2", "USR2", false},
+		{"", "", false},
+		{"SIGFOO", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSignal(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSignal(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseSignal(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContainerAgentPathMapping(t *testing.T) {
+	a := newContainerAgent("docker", "abc", "/workspaces/proj", "/home/me/proj")
+
+	want := filepath.Join("/home/me/proj", "gen", "api.go")
+	if got, ok := a.toHostPath("/workspaces/proj/gen/api.go"); !ok || got != want {
+		t.Errorf("unexpected mapping: %q %v", got, ok)
+	}
+	if _, ok := a.toHostPath("/workspaces/project2/x.go"); ok {
+		t.Error("paths outside the workspace should not map")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("*.go,go.mod=go test ./...", 0)
+	if err != nil {
+		t.Fatalf("ParseRule failed: %v", err)
+	}
+	if rule.Name != "go" || rule.Command != "go test ./..." {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+	if !rule.Matches("pkg/watch/watch.go") || !rule.Matches("go.mod") {
+		t.Error("rule should match Go sources and go.mod")
+	}
+	if rule.Matches("README.md") {
+		t.Error("rule should not match README.md")
+	}
+
+	if _, err := ParseRule("*.go", 0); err == nil {
+		t.Error("rule without command should fail")
+	}
+}
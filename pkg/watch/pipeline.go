@@ -0,0 +1,317 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the per-project CM settings file read for watch rules
+const ProjectConfigFile = ".cm.yaml"
+
+// Rule maps a set of globs to a command. Each rule is debounced and run independently.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Patterns []string `yaml:"patterns"`
+	Command  string   `yaml:"run"`
+	Delay    int      `yaml:"delay,omitempty"` // Debounce delay in milliseconds (0 = watcher default)
+
+	matchers []*regexp.Regexp
+}
+
+// projectConfig is the subset of .cm.yaml relevant to watch mode
+type projectConfig struct {
+	Watch struct {
+		Rules []Rule `yaml:"rules"`
+	} `yaml:"watch"`
+}
+
+// LoadRules reads watch rules from .cm.yaml in the project directory.
+// A missing file yields no rules and no error.
+func LoadRules(projectDir string) ([]Rule, error) {
+	data, err := os.ReadFile(filepath.Join(projectDir, ProjectConfigFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var cfg projectConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ProjectConfigFile, err)
+	}
+
+	for i := range cfg.Watch.Rules {
+		if err := cfg.Watch.Rules[i].compile(i); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.Watch.Rules, nil
+}
+
+// ParseRule parses a --rule flag of the form "GLOB[,GLOB...]=COMMAND"
+func ParseRule(spec string, index int) (Rule, error) {
+	globs, command, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(globs) == "" || strings.TrimSpace(command) == "" {
+		return Rule{}, fmt.Errorf("invalid rule %q (expected GLOB=COMMAND)", spec)
+	}
+
+	rule := Rule{Command: strings.TrimSpace(command)}
+	for _, g := range strings.Split(globs, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			rule.Patterns = append(rule.Patterns, g)
+		}
+	}
+	if err := rule.compile(index); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// compile validates the rule and prepares its glob matchers
+func (r *Rule) compile(index int) error {
+	if r.Command == "" {
+		return fmt.Errorf("watch rule %d has no command", index+1)
+	}
+	if len(r.Patterns) == 0 {
+		return fmt.Errorf("watch rule %d has no patterns", index+1)
+	}
+	if r.Name == "" {
+		r.Name = strings.Fields(r.Command)[0]
+	}
+
+	r.matchers = nil
+	for _, p := range r.Patterns {
+		p = strings.TrimPrefix(filepath.ToSlash(p), "/")
+		prefix := "^(?:.*/)?"
+		if strings.Contains(p, "/") {
+			prefix = "^"
+		}
+		re, err := regexp.Compile(prefix + globToRegexp(p) + "$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		r.matchers = append(r.matchers, re)
+	}
+	return nil
+}
+
+// Matches reports whether a project-relative path triggers this rule
+func (r *Rule) Matches(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, m := range r.matchers {
+		if m.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// pipeline runs one rule: debounce, then execute, queueing at most one rerun
+type pipeline struct {
+	rule    Rule
+	delay   time.Duration
+	prefix  string
+	backend string
+	cid     string
+	out     io.Writer
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	running bool
+	pending bool
+}
+
+// ruleColors cycles ANSI colors so interleaved output is easy to follow
+var ruleColors = []string{"36", "35", "33", "32", "34", "31"}
+
+// trigger schedules a debounced run
+func (p *pipeline) trigger(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(p.delay, func() { p.run(ctx) })
+}
+
+// run executes the rule command, coalescing triggers that arrive mid-run
+func (p *pipeline) run(ctx context.Context) {
+	p.mu.Lock()
+	if p.running {
+		p.pending = true
+		p.mu.Unlock()
+		return
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	for {
+		if ctx.Err() != nil {
+			break
+		}
+
+		p.logf("🔄 %s", p.rule.Command)
+		start := time.Now()
+
+		w := &prefixWriter{prefix: p.prefix + " ", out: p.out}
+		cmd := exec.CommandContext(ctx, p.backend, "exec", p.cid, "sh", "-c", p.rule.Command)
+		cmd.Stdout = w
+		cmd.Stderr = w
+		err := cmd.Run()
+		w.Flush()
+
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			p.logf("❌ failed after %s: %v", elapsed, err)
+		} else {
+			p.logf("✅ done in %s", elapsed)
+		}
+
+		p.mu.Lock()
+		if !p.pending {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		p.pending = false
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	p.running = false
+	p.mu.Unlock()
+}
+
+// logf prints a status line for this rule
+func (p *pipeline) logf(format string, args ...interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprintf(p.out, "%s %s\n", p.prefix, fmt.Sprintf(format, args...))
+}
+
+// prefixWriter prefixes each complete line written through it.
+// Writes to out are serialized so concurrent rules don't interleave mid-line.
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	buf    bytes.Buffer
+}
+
+var outputMu sync.Mutex
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line for the next write
+			w.buf.Reset()
+			w.buf.Write(line)
+			break
+		}
+		outputMu.Lock()
+		fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+		outputMu.Unlock()
+	}
+	return len(p), nil
+}
+
+// Flush writes any trailing partial line
+func (w *prefixWriter) Flush() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	outputMu.Lock()
+	fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf.String())
+	outputMu.Unlock()
+	w.buf.Reset()
+}
+
+// startPipelines runs every configured rule concurrently off a shared event stream
+func (w *Watcher) startPipelines(ctx context.Context, containerID string, containerEvents <-chan string) error {
+	pipelines := make([]*pipeline, len(w.opts.Rules))
+	for i, rule := range w.opts.Rules {
+		delay := w.opts.Delay
+		if rule.Delay > 0 {
+			delay = time.Duration(rule.Delay) * time.Millisecond
+		}
+		color := ruleColors[i%len(ruleColors)]
+		pipelines[i] = &pipeline{
+			rule:    rule,
+			delay:   delay,
+			prefix:  fmt.Sprintf("\033[%sm[%s]\033[0m", color, rule.Name),
+			backend: w.runner.BackendCommand(),
+			cid:     containerID,
+			out:     os.Stdout,
+		}
+	}
+
+	fmt.Println("📡 Watching for changes...")
+	fmt.Printf("   Directory: %s\n", w.opts.ProjectDir)
+	for _, p := range pipelines {
+		fmt.Printf("   %s %s → %s\n", p.prefix, strings.Join(p.rule.Patterns, ", "), p.rule.Command)
+	}
+	fmt.Println()
+
+	if w.opts.InitialRun {
+		for _, p := range pipelines {
+			go p.run(ctx)
+		}
+	}
+
+	dispatch := func(path string) {
+		if !w.shouldWatch(path) {
+			return
+		}
+		rel, err := filepath.Rel(w.opts.ProjectDir, path)
+		if err != nil {
+			return
+		}
+		for _, p := range pipelines {
+			if p.rule.Matches(rel) {
+				p.trigger(ctx)
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotifyChangeOps != 0 {
+				dispatch(event.Name)
+			}
+
+		case path, ok := <-containerEvents:
+			if !ok {
+				containerEvents = nil
+				continue
+			}
+			dispatch(path)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("⚠️  Watch error: %v\n", err)
+		}
+	}
+}
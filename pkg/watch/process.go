@@ -0,0 +1,174 @@
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pidFilePattern is where the in-container process group leader records
+// its PID; each process group gets its own file, so several watchers can
+// share a container
+const pidFilePattern = "/tmp/.cm-watch-%s.pid"
+
+// signalNames maps accepted --signal values to signal names understood by kill(1)
+var signalNames = map[string]string{
+	"HUP":  "HUP",
+	"INT":  "INT",
+	"QUIT": "QUIT",
+	"TERM": "TERM",
+	"KILL": "KILL",
+	"USR1": "USR1",
+	"USR2": "USR2",
+}
+
+// ParseSignal normalizes a signal name such as "SIGHUP", "hup" or "HUP"
+func ParseSignal(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	n := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if sig, ok := signalNames[n]; ok {
+		return sig, nil
+	}
+	return "", fmt.Errorf("unsupported signal: %s", name)
+}
+
+// processGroup manages a long-running command inside the container.
+// The command is started in its own session (setsid) so the whole
+// process tree can be signalled via its process group ID.
+type processGroup struct {
+	backend     string
+	containerID string
+	command     []string
+	pidFile     string
+	log         io.Writer // Where unexpected exits are reported
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping bool // Stop is terminating the process, so its exit is expected
+}
+
+func newProcessGroup(backend, containerID string, command []string) *processGroup {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return &processGroup{
+		backend:     backend,
+		containerID: containerID,
+		command:     command,
+		pidFile:     fmt.Sprintf(pidFilePattern, hex.EncodeToString(suffix)),
+		log:         os.Stdout,
+	}
+}
+
+// Start launches the command in the background. The local exec client is
+// deliberately not bound to ctx: cancelling it would orphan the in-container
+// process group, so shutdown always goes through Stop.
+func (p *processGroup) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// setsid makes the shell a session (and process group) leader; exec keeps its PID
+	script := fmt.Sprintf(`echo $$ > %s; exec "$@"`, p.pidFile)
+	args := []string{"exec", p.containerID, "setsid", "sh", "-c", script, "sh"}
+	args = append(args, p.command...)
+
+	cmd := exec.Command(p.backend, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start process: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		expected := p.stopping
+		p.mu.Unlock()
+		if err != nil && !expected && ctx.Err() == nil {
+			fmt.Fprintf(p.log, "\n⚠️  Process exited: %v\n", err)
+		}
+		close(done)
+	}()
+
+	p.cmd = cmd
+	p.done = done
+	p.stopping = false
+	return nil
+}
+
+// Running reports whether the managed process is still alive
+func (p *processGroup) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Signal sends a signal to the whole in-container process group
+func (p *processGroup) Signal(ctx context.Context, sig string) error {
+	script := fmt.Sprintf(`[ -f %[1]s ] && kill -%[2]s -$(cat %[1]s)`, p.pidFile, sig)
+	cmd := exec.CommandContext(ctx, p.backend, "exec", p.containerID, "sh", "-c", script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send SIG%s: %s", sig, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Stop terminates the process group, escalating to SIGKILL after the grace period
+func (p *processGroup) Stop(ctx context.Context, grace time.Duration) {
+	if !p.Running() {
+		return
+	}
+	defer p.removePIDFile(ctx)
+
+	p.mu.Lock()
+	p.stopping = true
+	p.mu.Unlock()
+
+	_ = p.Signal(ctx, "TERM")
+
+	select {
+	case <-p.done:
+		return
+	case <-time.After(grace):
+	}
+
+	_ = p.Signal(ctx, "KILL")
+
+	// Make sure the local exec client goes away as well
+	p.mu.Lock()
+	if p.cmd != nil && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	done := p.done
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+	}
+}
+
+// removePIDFile deletes the process group's PID file from the container
+func (p *processGroup) removePIDFile(ctx context.Context) {
+	_ = exec.CommandContext(ctx, p.backend, "exec", p.containerID, "rm", "-f", p.pidFile).Run()
+}
@@ -0,0 +1,159 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProcessGroup returns a process group whose "container" is the
+// host: the fake backend drops "exec <container>" and runs the rest
+func newTestProcessGroup(t *testing.T, script string) (*processGroup, *bytes.Buffer) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups need a POSIX shell")
+	}
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not installed")
+	}
+	dir := t.TempDir()
+	backend := filepath.Join(dir, "backend")
+	if err := os.WriteFile(backend, []byte("#!/bin/sh\nshift 2\nexec \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	p := newProcessGroup(backend, "test", []string{"sh", "-c", script})
+	p.pidFile = filepath.Join(dir, filepath.Base(p.pidFile))
+	p.log = &log
+	t.Cleanup(func() { p.Stop(context.Background(), time.Second) })
+	return p, &log
+}
+
+// waitFor polls until cond holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// waitForPID waits until the process group leader has recorded its PID
+func waitForPID(t *testing.T, p *processGroup) {
+	t.Helper()
+	waitFor(t, "the PID", func() bool {
+		data, _ := os.ReadFile(p.pidFile)
+		return len(data) > 0
+	})
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestProcessGroupPIDFiles(t *testing.T) {
+	a := newProcessGroup("docker", "c", []string{"true"})
+	b := newProcessGroup("docker", "c", []string{"true"})
+	if a.pidFile == b.pidFile {
+		t.Errorf("two watchers share the PID file %s", a.pidFile)
+	}
+	if !strings.HasPrefix(a.pidFile, "/tmp/.cm-watch-") {
+		t.Errorf("PID file = %s, want one in the container's /tmp", a.pidFile)
+	}
+}
+
+func TestProcessGroupLifecycle(t *testing.T) {
+	hups := filepath.Join(t.TempDir(), "hups")
+	p, log := newTestProcessGroup(t, `trap 'echo hup >> `+hups+`' HUP; while :; do sleep 0.05; done`)
+	ctx := context.Background()
+
+	if p.Running() {
+		t.Fatal("Running before Start")
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Running() {
+		t.Fatal("not Running after Start")
+	}
+	waitForPID(t, p)
+
+	// A signal reloads the process instead of ending it
+	if err := p.Signal(ctx, "HUP"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "SIGHUP to be handled", func() bool { return fileExists(hups) })
+	if !p.Running() {
+		t.Error("SIGHUP ended the process")
+	}
+
+	// Restarting stops the process quietly and starts it again
+	p.Stop(ctx, 5*time.Second)
+	if p.Running() {
+		t.Error("Running after Stop")
+	}
+	if fileExists(p.pidFile) {
+		t.Error("Stop left the PID file behind")
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Running() {
+		t.Error("not Running after a restart")
+	}
+	waitForPID(t, p)
+	start := time.Now()
+	p.Stop(ctx, 5*time.Second)
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Stop took %v; SIGTERM should end the process", elapsed)
+	}
+	if log.Len() > 0 {
+		t.Errorf("deliberate stops were reported: %q", log.String())
+	}
+}
+
+func TestProcessGroupStopEscalates(t *testing.T) {
+	// Ignoring SIGTERM, inherited by sleep, leaves only SIGKILL
+	p, log := newTestProcessGroup(t, `trap '' TERM; while :; do sleep 0.05; done`)
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForPID(t, p)
+
+	start := time.Now()
+	p.Stop(ctx, 200*time.Millisecond)
+	if p.Running() {
+		t.Error("Running after Stop")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Stop returned after %v, before the grace period", elapsed)
+	}
+	if log.Len() > 0 {
+		t.Errorf("a deliberate stop was reported: %q", log.String())
+	}
+}
+
+func TestProcessGroupUnexpectedExit(t *testing.T) {
+	p, log := newTestProcessGroup(t, `exit 3`)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the process to exit", func() bool { return !p.Running() })
+	if !strings.Contains(log.String(), "Process exited: exit status 3") {
+		t.Errorf("exit reported as %q, want a warning", log.String())
+	}
+
+	// Stopping a process that already exited does nothing
+	p.Stop(context.Background(), time.Second)
+}
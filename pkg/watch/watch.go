@@ -22,9 +22,20 @@ type Options struct {
 	Clear      bool          // Clear screen before each run
 	InitialRun bool          // Run command on startup
 	ProjectDir string        // Project directory
-	Config     *config.DevContainerConfig
+	Restart    bool          // Kill and restart a long-running process on change
+	Signal     string        // Send this signal instead of restarting (e.g. HUP)
+	StopGrace  time.Duration // Time to wait for graceful shutdown before SIGKILL
+	UseIgnore  bool          // Honor .gitignore/.cmignore in the project root
+	// ContainerEvents merges inotify events from inside the container
+	ContainerEvents bool
+	// Rules run per-glob commands concurrently instead of a single command
+	Rules  []Rule
+	Config *config.DevContainerConfig
 }
 
+// fsnotifyChangeOps are the host events that count as a change
+const fsnotifyChangeOps = fsnotify.Write | fsnotify.Create | fsnotify.Remove
+
 // DefaultOptions returns default watch options
 func DefaultOptions() Options {
 	return Options{
@@ -33,6 +44,8 @@ func DefaultOptions() Options {
 		Delay:      300 * time.Millisecond,
 		Clear:      false,
 		InitialRun: true,
+		StopGrace:  5 * time.Second,
+		UseIgnore:  true,
 	}
 }
 
@@ -42,6 +55,8 @@ type Watcher struct {
 	command []string
 	watcher *fsnotify.Watcher
 	runner  *runner.PersistentRunner
+	process *processGroup // Only set in restart mode
+	ignore  *IgnoreMatcher
 	mu      sync.Mutex
 	lastRun time.Time
 	pending bool
@@ -60,11 +75,17 @@ func New(opts Options, command []string) (*Watcher, error) {
 		return nil, fmt.Errorf("failed to create runner: %w", err)
 	}
 
+	ignore := &IgnoreMatcher{}
+	if opts.UseIgnore {
+		ignore = LoadIgnoreMatcher(opts.ProjectDir, IgnoreFiles...)
+	}
+
 	return &Watcher{
 		opts:    opts,
 		command: command,
 		watcher: watcher,
 		runner:  pr,
+		ignore:  ignore,
 	}, nil
 }
 
@@ -75,6 +96,35 @@ func (w *Watcher) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Restart mode, rules and the container agent need a running container
+	var containerID string
+	var containerEvents <-chan string
+	if w.opts.Restart || w.opts.ContainerEvents || len(w.opts.Rules) > 0 {
+		var err error
+		containerID, err = w.runner.EnsureContainer(ctx, false)
+		if err != nil {
+			return err
+		}
+
+		if w.opts.Restart {
+			w.process = newProcessGroup(w.runner.BackendCommand(), containerID, w.command)
+			defer w.process.Stop(context.Background(), w.opts.StopGrace)
+		}
+
+		if w.opts.ContainerEvents {
+			containerRoot := "/workspaces/" + filepath.Base(w.opts.ProjectDir)
+			agent := newContainerAgent(w.runner.BackendCommand(), containerID, containerRoot, w.opts.ProjectDir)
+			containerEvents, err = agent.Start(ctx)
+			if err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+			}
+		}
+	}
+
+	if len(w.opts.Rules) > 0 {
+		return w.startPipelines(ctx, containerID, containerEvents)
+	}
+
 	// Print startup info
 	w.printStartup()
 
@@ -92,6 +142,16 @@ func (w *Watcher) Start(ctx context.Context) error {
 	debounce := time.NewTimer(w.opts.Delay)
 	debounce.Stop()
 	var changedFiles []string
+	seen := make(map[string]bool)
+
+	// Host and container agents may both report the same change
+	recordChange := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			changedFiles = append(changedFiles, filepath.Base(path))
+		}
+		debounce.Reset(w.opts.Delay)
+	}
 
 	for {
 		select {
@@ -109,9 +169,17 @@ func (w *Watcher) Start(ctx context.Context) error {
 			}
 
 			// Only care about write/create/remove events
-			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) != 0 {
-				changedFiles = append(changedFiles, filepath.Base(event.Name))
-				debounce.Reset(w.opts.Delay)
+			if event.Op&fsnotifyChangeOps != 0 {
+				recordChange(event.Name)
+			}
+
+		case path, ok := <-containerEvents:
+			if !ok {
+				containerEvents = nil
+				continue
+			}
+			if w.shouldWatch(path) {
+				recordChange(path)
 			}
 
 		case <-debounce.C:
@@ -124,6 +192,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 						strings.Join(changedFiles[:2], ", "), len(changedFiles))
 				}
 				changedFiles = nil
+				seen = make(map[string]bool)
 
 				// Clear screen if enabled
 				if w.opts.Clear {
@@ -131,6 +200,10 @@ func (w *Watcher) Start(ctx context.Context) error {
 				}
 
 				// Run command
+				if w.opts.Restart {
+					w.restartCommand(ctx)
+					continue
+				}
 				fmt.Printf("🔄 Re-running: %s\n\n", strings.Join(w.command, " "))
 				w.runCommand(ctx)
 				fmt.Println()
@@ -165,6 +238,9 @@ func (w *Watcher) addWatchPaths(root string) error {
 				return filepath.SkipDir
 			}
 		}
+		if rel, err := filepath.Rel(w.opts.ProjectDir, path); err == nil && w.ignore.Match(rel, true) {
+			return filepath.SkipDir
+		}
 
 		// Add directory to watcher
 		if err := w.watcher.Add(path); err != nil {
@@ -186,6 +262,11 @@ func (w *Watcher) shouldWatch(path string) bool {
 		}
 	}
 
+	// Check .gitignore/.cmignore rules
+	if rel, err := filepath.Rel(w.opts.ProjectDir, path); err == nil && w.ignore.Match(rel, false) {
+		return false
+	}
+
 	// Check extensions if specified
 	if len(w.opts.Extensions) > 0 {
 		ext := strings.TrimPrefix(filepath.Ext(path), ".")
@@ -202,11 +283,32 @@ func (w *Watcher) shouldWatch(path string) bool {
 
 // runCommand executes the command in the container
 func (w *Watcher) runCommand(ctx context.Context) {
+	if w.process != nil {
+		if err := w.process.Start(ctx); err != nil {
+			fmt.Printf("\n❌ Command failed: %v\n", err)
+		}
+		return
+	}
 	if err := w.runner.Exec(ctx, w.command); err != nil {
 		fmt.Printf("\n❌ Command failed: %v\n", err)
 	}
 }
 
+// restartCommand reloads or restarts the long-running process
+func (w *Watcher) restartCommand(ctx context.Context) {
+	if w.opts.Signal != "" && w.process.Running() {
+		fmt.Printf("📨 Sending SIG%s to: %s\n", w.opts.Signal, strings.Join(w.command, " "))
+		if err := w.process.Signal(ctx, w.opts.Signal); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		return
+	}
+
+	fmt.Printf("🔄 Restarting: %s\n\n", strings.Join(w.command, " "))
+	w.process.Stop(ctx, w.opts.StopGrace)
+	w.runCommand(ctx)
+}
+
 // printStartup prints startup information
 func (w *Watcher) printStartup() {
 	fmt.Println("📡 Watching for changes...")
@@ -219,6 +321,19 @@ func (w *Watcher) printStartup() {
 	}
 
 	fmt.Printf("   Command: %s\n", strings.Join(w.command, " "))
+	if w.ignore.Len() > 0 {
+		fmt.Printf("   Ignore rules: %d (from %s)\n", w.ignore.Len(), strings.Join(IgnoreFiles, ", "))
+	}
+	if w.opts.ContainerEvents {
+		fmt.Println("   Container events: enabled")
+	}
+	if w.opts.Restart {
+		mode := "restart on change"
+		if w.opts.Signal != "" {
+			mode = "send SIG" + w.opts.Signal + " on change"
+		}
+		fmt.Printf("   Mode: %s\n", mode)
+	}
 	fmt.Println()
 }
 
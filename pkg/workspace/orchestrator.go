@@ -12,8 +12,11 @@ import (
 	"time"
 
 	"github.com/UPwith-me/Container-Maker/pkg/environment"
+	"github.com/UPwith-me/Container-Maker/pkg/offline"
+	"github.com/UPwith-me/Container-Maker/pkg/verify"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
@@ -65,11 +68,18 @@ func (o *Orchestrator) Up(ctx context.Context, opts StartOptions) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if err := o.refreshLocked(ctx); err != nil {
+		return err
+	}
+
 	// Determine which services to start
 	var toStart []string
 	var err error
 
 	if len(opts.Services) > 0 {
+		if err := o.checkServices(opts.Services); err != nil {
+			return err
+		}
 		if opts.NoDeps {
 			toStart = opts.Services
 		} else {
@@ -85,8 +95,9 @@ func (o *Orchestrator) Up(ctx context.Context, opts StartOptions) error {
 		}
 	}
 
-	// Filter by profile if specified
-	if opts.Profile != "" {
+	// Like compose, services with profiles start with one of them, unless
+	// named
+	if len(opts.Services) == 0 {
 		profileServices := o.workspace.GetServicesByProfile(opts.Profile)
 		profileNames := make(map[string]bool)
 		for _, svc := range profileServices {
@@ -101,30 +112,46 @@ func (o *Orchestrator) Up(ctx context.Context, opts StartOptions) error {
 		toStart = filtered
 	}
 
-	fmt.Printf(" Starting %d services in workspace '%s'\n", len(toStart), o.workspace.Name)
+	networkName, err := o.ensureNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🚀 Starting %d services in workspace '%s'\n", len(toStart), o.workspace.Name)
 	fmt.Println()
 
+	healthTimeout := time.Duration(opts.Timeout) * time.Second
+
 	// Start services in order
 	for i, name := range toStart {
 		svc := o.workspace.Services[name]
 		fmt.Printf("[%d/%d] Starting %s...\n", i+1, len(toStart), name)
 
-		if err := o.startService(ctx, svc, opts); err != nil {
-			fmt.Printf("?Failed to start %s: %v\n", name, err)
+		// Dependencies must pass their healthchecks before dependents start
+		if err := o.waitForDependencies(ctx, svc, healthTimeout); err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			if !opts.Force {
+				return fmt.Errorf("failed to start %s: %w", name, err)
+			}
+			continue
+		}
+
+		if err := o.startService(ctx, svc, networkName, opts); err != nil {
+			fmt.Printf("❌ Failed to start %s: %v\n", name, err)
 			if !opts.Force {
 				return fmt.Errorf("failed to start %s: %w", name, err)
 			}
 			continue
 		}
 
-		fmt.Printf("?%s started\n", name)
+		fmt.Printf("✅ %s started\n", name)
 	}
 
 	o.state.StartedAt = time.Now()
 	o.state.LastUpdateAt = time.Now()
 
 	fmt.Println()
-	fmt.Printf(" Workspace '%s' is up!\n", o.workspace.Name)
+	fmt.Printf("✨ Workspace '%s' is up!\n", o.workspace.Name)
 
 	return nil
 }
@@ -134,11 +161,18 @@ func (o *Orchestrator) Down(ctx context.Context, opts StopOptions) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if err := o.refreshLocked(ctx); err != nil {
+		return err
+	}
+
 	// Determine which services to stop
 	var toStop []string
 	var err error
 
 	if len(opts.Services) > 0 {
+		if err := o.checkServices(opts.Services); err != nil {
+			return err
+		}
 		toStop, err = o.graph.GetStopOrderForServices(opts.Services)
 		if err != nil {
 			return err
@@ -150,7 +184,7 @@ func (o *Orchestrator) Down(ctx context.Context, opts StopOptions) error {
 		}
 	}
 
-	fmt.Printf(" Stopping %d services in workspace '%s'\n", len(toStop), o.workspace.Name)
+	fmt.Printf("🛑 Stopping %d services in workspace '%s'\n", len(toStop), o.workspace.Name)
 	fmt.Println()
 
 	// Stop services in order
@@ -163,19 +197,53 @@ func (o *Orchestrator) Down(ctx context.Context, opts StopOptions) error {
 			continue
 		}
 
-		fmt.Printf("?%s stopped\n", name)
+		fmt.Printf("✅ %s stopped\n", name)
+	}
+
+	// The network and volumes are shared, so only tear them down with the whole workspace
+	if len(opts.Services) == 0 && opts.Remove {
+		if err := o.removeNetwork(ctx); err != nil {
+			fmt.Printf("  Warning: failed to remove network: %v\n", err)
+		}
+		if opts.Volumes {
+			if err := o.removeVolumes(ctx); err != nil {
+				fmt.Printf("  Warning: failed to remove volumes: %v\n", err)
+			}
+		}
 	}
 
 	o.state.LastUpdateAt = time.Now()
 
 	fmt.Println()
-	fmt.Printf(" Workspace '%s' is down\n", o.workspace.Name)
+	fmt.Printf("👋 Workspace '%s' is down\n", o.workspace.Name)
 
 	return nil
 }
 
-// startService starts a single service
-func (o *Orchestrator) startService(ctx context.Context, svc *Service, opts StartOptions) error {
+// startService starts a single service, reusing its container unless Force is set
+func (o *Orchestrator) startService(ctx context.Context, svc *Service, networkName string, opts StartOptions) error {
+	if existing := o.state.Services[svc.Name]; existing != nil && existing.ContainerID != "" {
+		if !opts.Force && !opts.Build {
+			if existing.Status == ServiceStatusRunning {
+				fmt.Printf("   %s is already running\n", svc.Name)
+				return nil
+			}
+			if err := o.dockerClient.ContainerStart(ctx, existing.ContainerID, container.StartOptions{}); err != nil {
+				existing.Status = ServiceStatusError
+				existing.Error = err.Error()
+				return fmt.Errorf("failed to start container: %w", err)
+			}
+			existing.Status = ServiceStatusRunning
+			existing.StartedAt = time.Now()
+			return nil
+		}
+
+		// Recreate from the current configuration
+		if err := o.dockerClient.ContainerRemove(ctx, existing.ContainerID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to remove old container: %w", err)
+		}
+	}
+
 	// Initialize state
 	state := &ServiceState{
 		Name:   svc.Name,
@@ -217,28 +285,62 @@ func (o *Orchestrator) startService(ctx context.Context, svc *Service, opts Star
 	workspaceDir := fmt.Sprintf("/workspaces/%s", filepath.Base(svc.Path))
 
 	containerConfig := &container.Config{
-		Image:      imageName,
-		Cmd:        svc.Command,
-		Entrypoint: svc.Entrypoint,
-		WorkingDir: workspaceDir,
-		Tty:        true,
-		OpenStdin:  true,
+		Image:       imageName,
+		Cmd:         svc.Command,
+		Entrypoint:  svc.Entrypoint,
+		WorkingDir:  workspaceDir,
+		Tty:         true,
+		OpenStdin:   true,
+		User:        svc.User,
+		Healthcheck: healthConfig(svc.HealthCheck),
 		Labels: map[string]string{
-			"cm.managed_by": "container-maker",
-			"cm.workspace":  o.workspace.Name,
-			"cm.service":    svc.Name,
+			labelManagedBy: "container-maker",
+			labelWorkspace: o.workspace.Name,
+			labelService:   svc.Name,
 		},
 	}
+	if svc.WorkingDir != "" {
+		containerConfig.WorkingDir = svc.WorkingDir
+	}
+	for k, v := range svc.Labels {
+		containerConfig.Labels[k] = v
+	}
 
 	// Add environment variables
-	for k, v := range svc.Environment {
+	env, err := o.workspace.ServiceEnvironment(svc)
+	if err != nil {
+		state.Status = ServiceStatusError
+		state.Error = err.Error()
+		return err
+	}
+	for k, v := range env {
 		containerConfig.Env = append(containerConfig.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	binds, err := o.serviceBinds(ctx, svc)
+	if err != nil {
+		state.Status = ServiceStatusError
+		state.Error = err.Error()
+		return err
+	}
+
 	// Host config
 	hostConfig := &container.HostConfig{
-		Binds:       []string{fmt.Sprintf("%s:%s", svc.Path, workspaceDir)},
-		NetworkMode: container.NetworkMode(o.workspace.GenerateNetworkName()),
+		Binds:       append([]string{fmt.Sprintf("%s:%s", svc.Path, workspaceDir)}, binds...),
+		NetworkMode: container.NetworkMode(networkName),
+		Privileged:  svc.Privileged,
+		CapAdd:      svc.CapAdd,
+		CapDrop:     svc.CapDrop,
+	}
+	if svc.RestartPolicy != "" {
+		hostConfig.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyMode(svc.RestartPolicy)}
+	}
+
+	// Other services reach this one by its service name
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			networkName: {Aliases: []string{svc.Name}},
+		},
 	}
 
 	// Add port mappings
@@ -291,7 +393,7 @@ func (o *Orchestrator) startService(ctx context.Context, svc *Service, opts Star
 	}
 
 	// Create container
-	resp, err := o.dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	resp, err := o.dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, nil, containerName)
 	if err != nil {
 		state.Status = ServiceStatusError
 		state.Error = err.Error()
@@ -356,6 +458,12 @@ func (o *Orchestrator) stopService(ctx context.Context, svc *Service, opts StopO
 
 // resolveTemplate maps template names to images
 func (o *Orchestrator) resolveTemplate(template string) string {
+	return ResolveTemplateImage(template)
+}
+
+// ResolveTemplateImage maps a service template name to its image; unknown
+// names are treated as image references
+func ResolveTemplateImage(template string) string {
 	templates := map[string]string{
 		"python":     "mcr.microsoft.com/devcontainers/python:3.11",
 		"node":       "mcr.microsoft.com/devcontainers/javascript-node:20",
@@ -373,10 +481,16 @@ func (o *Orchestrator) resolveTemplate(template string) string {
 
 // ensureImage pulls an image if not available locally
 func (o *Orchestrator) ensureImage(ctx context.Context, imageName string) error {
+	if err := verify.Images(ctx, []string{imageName}, os.Stdout); err != nil {
+		return err
+	}
 	_, _, err := o.dockerClient.ImageInspectWithRaw(ctx, imageName)
 	if err == nil {
 		return nil // Image exists
 	}
+	if offline.Enabled() {
+		return offline.Missing("image", imageName)
+	}
 
 	fmt.Printf("   Pulling %s...\n", imageName)
 	reader, err := o.dockerClient.ImagePull(ctx, imageName, image.PullOptions{})
@@ -443,54 +557,93 @@ func (o *Orchestrator) Status() *WorkspaceState {
 	return o.state
 }
 
-// Restart restarts specific services
+// Restart restarts specific services (all when empty). Services that depend on
+// them are restarted as well so they reconnect; dependencies keep running.
 func (o *Orchestrator) Restart(ctx context.Context, services []string) error {
-	if err := o.Down(ctx, StopOptions{Services: services}); err != nil {
+	if err := o.checkServices(services); err != nil {
 		return err
 	}
-	return o.Up(ctx, StartOptions{Services: services})
-}
 
-// Logs streams logs from a service
-func (o *Orchestrator) Logs(ctx context.Context, service string, follow bool, tail int) error {
-	state := o.state.Services[service]
-	if state == nil || state.ContainerID == "" {
-		return fmt.Errorf("service %s is not running", service)
+	affected, err := o.graph.StartOrder()
+	if err != nil {
+		return err
 	}
-
-	tailStr := fmt.Sprintf("%d", tail)
-	if tail <= 0 {
-		tailStr = "100"
+	if len(services) > 0 {
+		stopOrder, err := o.graph.GetStopOrderForServices(services)
+		if err != nil {
+			return err
+		}
+		affected = reverseStrings(stopOrder)
 	}
 
-	reader, err := o.dockerClient.ContainerLogs(ctx, state.ContainerID, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     follow,
-		Tail:       tailStr,
-		Timestamps: true,
-	})
-	if err != nil {
+	if err := o.Down(ctx, StopOptions{Services: services}); err != nil {
 		return err
 	}
-	defer reader.Close()
+	return o.Up(ctx, StartOptions{Services: affected, NoDeps: true, Timeout: DefaultStartTimeout})
+}
 
-	buf := make([]byte, 8192)
-	for {
-		n, err := reader.Read(buf)
-		if err != nil {
-			break
+// waitForDependencies gates a service on its dependencies' start conditions.
+// Dependencies without an explicit condition must pass their healthcheck if
+// they have one; a per-dependency timeout overrides the default.
+func (o *Orchestrator) waitForDependencies(ctx context.Context, svc *Service, timeout time.Duration) error {
+	for _, dep := range svc.DependsOn {
+		state := o.state.Services[dep]
+		if state == nil || state.ContainerID == "" {
+			continue // skipped with --no-deps
+		}
+
+		cond := svc.DependencyCondition(dep)
+		depTimeout := timeout
+		if cond.Timeout > 0 {
+			depTimeout = cond.Timeout
+		}
+
+		var err error
+		switch cond.Condition {
+		case ConditionServiceStarted:
+			err = o.waitRunning(ctx, dep, state.ContainerID)
+		case ConditionServiceHealthy:
+			err = o.waitHealthy(ctx, dep, state.ContainerID, depTimeout, true)
+		case ConditionServiceCompletedSuccessfully:
+			err = o.waitCompleted(ctx, dep, state.ContainerID, depTimeout)
+		default:
+			err = o.waitHealthy(ctx, dep, state.ContainerID, depTimeout, false)
 		}
-		if n > 8 {
-			os.Stdout.Write(buf[8:n])
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+// checkServices rejects service names that are not in the workspace
+func (o *Orchestrator) checkServices(services []string) error {
+	for _, name := range services {
+		if _, ok := o.workspace.Services[name]; !ok {
+			return fmt.Errorf("service %s not found in workspace '%s'", name, o.workspace.Name)
+		}
+	}
 	return nil
 }
 
+func reverseStrings(s []string) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
+
+// Logs streams logs from a service
+func (o *Orchestrator) Logs(ctx context.Context, service string, follow bool, tail int) error {
+	return o.StreamLogs(ctx, []string{service}, follow, tail, os.Stdout)
+}
+
 // Exec executes a command in a service container
 func (o *Orchestrator) Exec(ctx context.Context, service string, command []string) error {
+	if err := o.Refresh(ctx); err != nil {
+		return err
+	}
 	state := o.state.Services[service]
 	if state == nil || state.ContainerID == "" {
 		return fmt.Errorf("service %s is not running", service)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -150,6 +151,17 @@ func validateService(name string, svc *Service) error {
 		return fmt.Errorf("service %s must have image, template, or build", name)
 	}
 
+	for dep, cond := range svc.DependencyConditions {
+		if cond == nil {
+			continue
+		}
+		switch cond.Condition {
+		case "", ConditionServiceStarted, ConditionServiceHealthy, ConditionServiceCompletedSuccessfully:
+		default:
+			return fmt.Errorf("service %s: invalid condition %q for dependency %s", name, cond.Condition, dep)
+		}
+	}
+
 	// Check dependencies exist (checked later in full context)
 	return nil
 }
@@ -270,6 +282,72 @@ func (ws *Workspace) GetServicesByProfile(profile string) []*Service {
 	return result
 }
 
+// ServiceEnvironment returns a service's environment: the variables of its
+// env_file files, relative to the workspace file and later ones winning,
+// overridden by its environment
+func (ws *Workspace) ServiceEnvironment(svc *Service) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, file := range svc.EnvFile {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(ws.ConfigFile), file)
+		}
+		vars, err := ParseEnvFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	for k, v := range svc.Environment {
+		env[k] = v
+	}
+	return env, nil
+}
+
+// ParseEnvFile reads a .env file as compose does: KEY=VALUE lines, with #
+// comments, an optional export prefix and quoted values. A KEY without a
+// value takes the variable from the environment, if set.
+func ParseEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	vars := make(map[string]string)
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, hasValue := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: invalid line", path, i+1)
+		}
+		if !hasValue {
+			if v, ok := os.LookupEnv(key); ok {
+				vars[key] = v
+			}
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// An unquoted value ends at a comment
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
 // GenerateNetworkName generates a network name for the workspace
 func (ws *Workspace) GenerateNetworkName() string {
 	return fmt.Sprintf("cm-%s-network", sanitizeName(ws.Name))
@@ -283,6 +361,105 @@ func sanitizeName(name string) string {
 	return name
 }
 
+// DependencyCondition returns the start condition for one of the service's dependencies
+func (svc *Service) DependencyCondition(dep string) DependencyCondition {
+	if cond := svc.DependencyConditions[dep]; cond != nil {
+		return *cond
+	}
+	return DependencyCondition{}
+}
+
+// UnmarshalYAML accepts depends_on as a list of names or as a compose-style
+// map of names to conditions
+func (svc *Service) UnmarshalYAML(node *yaml.Node) error {
+	type plain Service
+
+	var conditions map[string]*DependencyCondition
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != "depends_on" || node.Content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+			if err := node.Content[i+1].Decode(&conditions); err != nil {
+				return fmt.Errorf("invalid depends_on: %w", err)
+			}
+
+			names := make([]string, 0, len(conditions))
+			for name := range conditions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for _, name := range names {
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+			}
+
+			// Decode a copy so the caller's node tree is left untouched
+			copied := *node
+			copied.Content = append([]*yaml.Node(nil), node.Content...)
+			copied.Content[i+1] = seq
+			node = &copied
+			break
+		}
+	}
+
+	if err := node.Decode((*plain)(svc)); err != nil {
+		return err
+	}
+	svc.DependencyConditions = conditions
+	return nil
+}
+
+// MarshalYAML writes depends_on in map form when any dependency has a condition
+func (svc Service) MarshalYAML() (interface{}, error) {
+	type plain Service
+	if len(svc.DependencyConditions) == 0 {
+		return plain(svc), nil
+	}
+
+	var node yaml.Node
+	if err := node.Encode(plain(svc)); err != nil {
+		return nil, err
+	}
+	conditions := make(map[string]DependencyCondition, len(svc.DependsOn))
+	for _, dep := range svc.DependsOn {
+		cond := svc.DependencyCondition(dep)
+		if cond.Condition == "" {
+			cond.Condition = ConditionServiceStarted
+		}
+		conditions[dep] = cond
+	}
+	var value yaml.Node
+	if err := value.Encode(conditions); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "depends_on" {
+			node.Content[i+1] = &value
+		}
+	}
+	return &node, nil
+}
+
+// UnmarshalYAML accepts ports as "host:container" strings, numbers or mappings
+func (p *PortConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		type plain PortConfig
+		return node.Decode((*plain)(p))
+	}
+
+	var raw interface{}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	parsed, err := ParsePortConfig(raw)
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
 // ParsePortConfig parses a port string or int into PortConfig
 func ParsePortConfig(port interface{}) (*PortConfig, error) {
 	switch v := port.(type) {
@@ -0,0 +1,109 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDependsOnConditions(t *testing.T) {
+	const config = `services:
+  api:
+    image: api
+    ports:
+      - "8080:80"
+      - 9090
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+      db:
+        condition: service_healthy
+        timeout: 45s
+      cache: {}
+  web:
+    image: web
+    depends_on: [api]
+`
+	var ws Workspace
+	if err := yaml.Unmarshal([]byte(config), &ws); err != nil {
+		t.Fatal(err)
+	}
+
+	api := ws.Services["api"]
+	if got := api.DependsOn; len(got) != 3 || got[0] != "cache" || got[1] != "db" || got[2] != "migrate" {
+		t.Fatalf("depends_on = %v", got)
+	}
+	if cond := api.DependencyCondition("db"); cond.Condition != ConditionServiceHealthy || cond.Timeout != 45*time.Second {
+		t.Errorf("db condition = %+v", cond)
+	}
+	if cond := api.DependencyCondition("cache"); cond.Condition != "" {
+		t.Errorf("cache should have no condition, got %+v", cond)
+	}
+	if len(api.Ports) != 2 || api.Ports[0].Published != 8080 || api.Ports[0].Target != 80 || api.Ports[1].Target != 9090 {
+		t.Errorf("ports = %+v", api.Ports)
+	}
+	if web := ws.Services["web"]; len(web.DependsOn) != 1 || web.DependencyConditions != nil {
+		t.Errorf("list form depends_on = %v, %v", web.DependsOn, web.DependencyConditions)
+	}
+
+	// Conditions survive a save and reload
+	data, err := yaml.Marshal(&ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Workspace
+	if err := yaml.Unmarshal(data, &back); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if cond := back.Services["api"].DependencyCondition("migrate"); cond.Condition != ConditionServiceCompletedSuccessfully {
+		t.Errorf("condition lost on round trip:\n%s", data)
+	}
+	if cond := back.Services["api"].DependencyCondition("cache"); cond.Condition != ConditionServiceStarted {
+		t.Errorf("unset condition should be written as %s, got %+v", ConditionServiceStarted, cond)
+	}
+
+	api.DependencyConditions["db"].Condition = "service_ready"
+	if err := validateService("api", api); err == nil {
+		t.Error("unknown condition should fail validation")
+	}
+}
+
+func TestServiceEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	env := "# comment\nexport A=1\nB=\"two words\" \nC=3 # trailing\nD='#4'\nFROM_HOST\nE=5\n"
+	if err := os.WriteFile(filepath.Join(dir, "base.env"), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "local.env"), []byte("E=local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FROM_HOST", "host")
+
+	ws := &Workspace{ConfigFile: filepath.Join(dir, "cm-workspace.yaml")}
+	svc := &Service{
+		Name:        "api",
+		EnvFile:     []string{"base.env", "local.env"},
+		Environment: map[string]string{"A": "override"},
+	}
+	got, err := ws.ServiceEnvironment(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "override", "B": "two words", "C": "3", "D": "#4", "FROM_HOST": "host", "E": "local"}
+	if len(got) != len(want) {
+		t.Fatalf("environment = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	svc.EnvFile = []string{"missing.env"}
+	if _, err := ws.ServiceEnvironment(svc); err == nil {
+		t.Error("expected an error for a missing env_file")
+	}
+}
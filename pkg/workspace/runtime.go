@@ -0,0 +1,466 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Container labels used to find workspace containers across CLI invocations
+const (
+	labelManagedBy = "cm.managed_by"
+	labelWorkspace = "cm.workspace"
+	labelService   = "cm.service"
+)
+
+// Refresh reloads service state from the containers Docker knows about
+func (o *Orchestrator) Refresh(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.refreshLocked(ctx)
+}
+
+func (o *Orchestrator) refreshLocked(ctx context.Context) error {
+	containers, err := o.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelWorkspace+"="+o.workspace.Name)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list workspace containers: %w", err)
+	}
+
+	services := make(map[string]*ServiceState)
+	for _, c := range containers {
+		name := c.Labels[labelService]
+		if name == "" {
+			continue
+		}
+
+		state := &ServiceState{
+			Name:        name,
+			ContainerID: c.ID,
+			Image:       c.Image,
+			Status:      ServiceStatusStopped,
+		}
+		switch c.State {
+		case "running":
+			state.Status = ServiceStatusRunning
+		case "created", "restarting":
+			state.Status = ServiceStatusStarting
+		case "dead":
+			state.Status = ServiceStatusError
+		}
+		// Status reads e.g. "Up 2 minutes (healthy)"
+		for _, h := range []string{"healthy", "unhealthy", "health: starting"} {
+			if strings.Contains(c.Status, "("+h+")") {
+				state.Health = strings.TrimPrefix(h, "health: ")
+				break
+			}
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort != 0 {
+				state.Ports = append(state.Ports, PortMapping{Container: int(p.PrivatePort), Host: int(p.PublicPort), Protocol: p.Type})
+			}
+		}
+		if c.Created > 0 {
+			state.StartedAt = time.Unix(c.Created, 0)
+		}
+		services[name] = state
+
+		if svc := o.workspace.Services[name]; svc != nil {
+			svc.ContainerID = c.ID
+		}
+	}
+
+	o.state.Services = services
+	o.state.LastUpdateAt = time.Now()
+	return nil
+}
+
+// ensureNetwork creates the shared workspace network services resolve each other on
+func (o *Orchestrator) ensureNetwork(ctx context.Context) (string, error) {
+	name := o.workspace.GenerateNetworkName()
+	if _, err := o.dockerClient.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+		return name, nil
+	}
+
+	opts := network.CreateOptions{
+		Driver: "bridge",
+		Labels: map[string]string{
+			labelManagedBy: "container-maker",
+			labelWorkspace: o.workspace.Name,
+		},
+	}
+	if cfg := o.workspace.Networks["default"]; cfg != nil {
+		if cfg.Driver != "" {
+			opts.Driver = cfg.Driver
+		}
+		for k, v := range cfg.Labels {
+			opts.Labels[k] = v
+		}
+	}
+
+	if _, err := o.dockerClient.NetworkCreate(ctx, name, opts); err != nil {
+		return "", fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	o.state.Networks = append(o.state.Networks, name)
+	return name, nil
+}
+
+// removeNetwork deletes the workspace network once no container uses it
+func (o *Orchestrator) removeNetwork(ctx context.Context) error {
+	err := o.dockerClient.NetworkRemove(ctx, o.workspace.GenerateNetworkName())
+	if err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// volumeName returns the Docker volume for a volume declared in the workspace
+func (o *Orchestrator) volumeName(name string) string {
+	if cfg := o.workspace.Volumes[name]; cfg != nil && cfg.External {
+		return name
+	}
+	return fmt.Sprintf("cm-%s-%s", sanitizeName(o.workspace.Name), name)
+}
+
+// serviceBinds resolves a service's volumes: relative host paths are taken from
+// the workspace directory, and declared named volumes are scoped to the workspace
+func (o *Orchestrator) serviceBinds(ctx context.Context, svc *Service) ([]string, error) {
+	baseDir := filepath.Dir(o.workspace.ConfigFile)
+	var binds []string
+
+	for _, v := range svc.Volumes {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) < 2 {
+			continue // anonymous volume; nothing to bind
+		}
+		source, rest := parts[0], parts[1]
+
+		switch {
+		case strings.HasPrefix(source, "/"):
+		case strings.HasPrefix(source, "~"):
+			home, _ := os.UserHomeDir()
+			source = filepath.Join(home, strings.TrimPrefix(source, "~"))
+		case strings.HasPrefix(source, "."):
+			source = filepath.Join(baseDir, source)
+		default:
+			if _, declared := o.workspace.Volumes[source]; declared {
+				name := o.volumeName(source)
+				if !o.workspace.Volumes[source].External {
+					if err := o.ensureVolume(ctx, name); err != nil {
+						return nil, err
+					}
+				}
+				source = name
+			}
+		}
+		binds = append(binds, source+":"+rest)
+	}
+	return binds, nil
+}
+
+func (o *Orchestrator) ensureVolume(ctx context.Context, name string) error {
+	if _, err := o.dockerClient.VolumeInspect(ctx, name); err == nil {
+		return nil
+	}
+	_, err := o.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+		Name: name,
+		Labels: map[string]string{
+			labelManagedBy: "container-maker",
+			labelWorkspace: o.workspace.Name,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeVolumes deletes the named volumes CM created for this workspace
+func (o *Orchestrator) removeVolumes(ctx context.Context) error {
+	resp, err := o.dockerClient.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelWorkspace+"="+o.workspace.Name)),
+	})
+	if err != nil {
+		return err
+	}
+	for _, v := range resp.Volumes {
+		if err := o.dockerClient.VolumeRemove(ctx, v.Name, true); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+		}
+		fmt.Printf("   Removed volume %s\n", v.Name)
+	}
+	return nil
+}
+
+// healthConfig converts a service healthcheck to Docker's format
+func healthConfig(hc *HealthCheckConfig) *container.HealthConfig {
+	if hc == nil || len(hc.Test) == 0 {
+		return nil
+	}
+	test := hc.Test
+	switch test[0] {
+	case "CMD", "CMD-SHELL", "NONE":
+	default:
+		test = append([]string{"CMD"}, test...)
+	}
+	return &container.HealthConfig{
+		Test:        test,
+		Interval:    hc.Interval,
+		Timeout:     hc.Timeout,
+		Retries:     hc.Retries,
+		StartPeriod: hc.StartPeriod,
+	}
+}
+
+// waitRunning checks that a dependency's container is running
+func (o *Orchestrator) waitRunning(ctx context.Context, service, containerID string) error {
+	inspect, err := o.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if inspect.State == nil || !inspect.State.Running {
+		return fmt.Errorf("service %s is not running", service)
+	}
+	return nil
+}
+
+// waitCompleted blocks until a container exits and fails unless it exited with 0
+func (o *Orchestrator) waitCompleted(ctx context.Context, service, containerID string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout * time.Second
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fmt.Printf("   Waiting for %s to complete...\n", service)
+	statusCh, errCh := o.dockerClient.ContainerWait(waitCtx, containerID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		if status.Error != nil {
+			return fmt.Errorf("service %s: %s", service, status.Error.Message)
+		}
+		if status.StatusCode != 0 {
+			return fmt.Errorf("service %s exited with code %d", service, status.StatusCode)
+		}
+		fmt.Printf("   %s completed\n", service)
+		return nil
+	case err := <-errCh:
+		if waitCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return fmt.Errorf("timed out after %s waiting for %s to complete", timeout, service)
+		}
+		return err
+	}
+}
+
+// waitHealthy blocks until a container's healthcheck passes. Containers
+// without a healthcheck count as healthy once they are running, unless
+// requireHealthcheck is set.
+func (o *Orchestrator) waitHealthy(ctx context.Context, service, containerID string, timeout time.Duration, requireHealthcheck bool) error {
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	announced := false
+
+	for {
+		inspect, err := o.dockerClient.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if inspect.State == nil || !inspect.State.Running {
+			return fmt.Errorf("service %s is not running", service)
+		}
+		if inspect.State.Health == nil {
+			if requireHealthcheck {
+				return fmt.Errorf("service %s has no healthcheck, required by condition %s", service, ConditionServiceHealthy)
+			}
+			return nil
+		}
+
+		switch inspect.State.Health.Status {
+		case container.Healthy:
+			if announced {
+				fmt.Printf("   %s is healthy\n", service)
+			}
+			return nil
+		case container.Unhealthy:
+			msg := ""
+			if logs := inspect.State.Health.Log; len(logs) > 0 {
+				msg = strings.TrimSpace(logs[len(logs)-1].Output)
+			}
+			return fmt.Errorf("service %s is unhealthy: %s", service, msg)
+		}
+
+		if !announced {
+			fmt.Printf("   Waiting for %s to become healthy...\n", service)
+			announced = true
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s to become healthy", timeout, service)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// StreamLogs writes logs of the given services (all when empty) to w. With
+// more than one service every line is prefixed with the service name.
+func (o *Orchestrator) StreamLogs(ctx context.Context, services []string, follow bool, tail int, w io.Writer) error {
+	o.mu.Lock()
+	if err := o.refreshLocked(ctx); err != nil {
+		o.mu.Unlock()
+		return err
+	}
+	if len(services) == 0 {
+		for _, name := range o.workspace.ServiceNames() {
+			if st := o.state.Services[name]; st != nil && st.ContainerID != "" {
+				services = append(services, name)
+			}
+		}
+		sort.Strings(services)
+	}
+	targets := make(map[string]string)
+	for _, name := range services {
+		if _, ok := o.workspace.Services[name]; !ok {
+			o.mu.Unlock()
+			return fmt.Errorf("service %s not found", name)
+		}
+		st := o.state.Services[name]
+		if st == nil || st.ContainerID == "" {
+			o.mu.Unlock()
+			return fmt.Errorf("service %s has no container, run 'cm workspace up %s' first", name, name)
+		}
+		targets[name] = st.ContainerID
+	}
+	o.mu.Unlock()
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no services are running")
+	}
+
+	tailStr := "100"
+	if tail > 0 {
+		tailStr = fmt.Sprintf("%d", tail)
+	}
+
+	width := 0
+	for name := range targets {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		writeMu  sync.Mutex
+		firstErr error
+	)
+	for name, id := range targets {
+		prefix := ""
+		if len(targets) > 1 {
+			prefix = fmt.Sprintf("%-*s | ", width, name)
+		}
+
+		wg.Add(1)
+		go func(name, id, prefix string) {
+			defer wg.Done()
+			err := o.copyLogs(ctx, id, follow, tailStr, &prefixWriter{w: w, prefix: prefix, mu: &writeMu})
+			if err != nil && ctx.Err() == nil {
+				writeMu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", name, err)
+				}
+				writeMu.Unlock()
+			}
+		}(name, id, prefix)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (o *Orchestrator) copyLogs(ctx context.Context, containerID string, follow bool, tail string, w io.Writer) error {
+	inspect, err := o.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	reader, err := o.dockerClient.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Tail:       tail,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// TTY containers stream raw output; others multiplex stdout and stderr
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(w, reader)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, reader)
+	}
+	if f, ok := w.(*prefixWriter); ok {
+		f.Flush()
+	}
+	return err
+}
+
+// prefixWriter prefixes complete lines and serializes writes from several streams
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes a trailing partial line
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := bufio.NewWriter(p.w)
+	_, _ = w.WriteString(p.prefix)
+	_, _ = w.Write(line)
+	_ = w.Flush()
+}
@@ -0,0 +1,41 @@
+package workspace
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthConfig(t *testing.T) {
+	if healthConfig(nil) != nil {
+		t.Error("nil healthcheck should produce no config")
+	}
+
+	hc := healthConfig(&HealthCheckConfig{Test: []string{"pg_isready"}, Interval: 5 * time.Second, Retries: 3})
+	if len(hc.Test) != 2 || hc.Test[0] != "CMD" || hc.Test[1] != "pg_isready" {
+		t.Errorf("bare command should be prefixed with CMD, got %v", hc.Test)
+	}
+	if hc.Interval != 5*time.Second || hc.Retries != 3 {
+		t.Errorf("unexpected timings: %+v", hc)
+	}
+
+	hc = healthConfig(&HealthCheckConfig{Test: []string{"CMD-SHELL", "curl -f localhost"}})
+	if hc.Test[0] != "CMD-SHELL" || len(hc.Test) != 2 {
+		t.Errorf("explicit test type should be kept, got %v", hc.Test)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{w: &out, prefix: "db  | ", mu: &sync.Mutex{}}
+
+	_, _ = w.Write([]byte("ready to accept"))
+	_, _ = w.Write([]byte(" connections\nsecond"))
+	w.Flush()
+
+	want := "db  | ready to accept connections\ndb  | second\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -42,8 +42,10 @@ type Service struct {
 	Path       string `yaml:"path,omitempty" json:"path,omitempty"`          // Relative path to service
 	ConfigFile string `yaml:"config,omitempty" json:"config_file,omitempty"` // devcontainer.json path
 
-	// Dependencies
-	DependsOn []string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// Dependencies. depends_on is written either as a list of names or, like
+	// compose, as a map of names to conditions; conditions are kept here.
+	DependsOn            []string                        `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	DependencyConditions map[string]*DependencyCondition `yaml:"-" json:"dependency_conditions,omitempty"`
 
 	// Networking
 	Ports    []PortConfig `yaml:"ports,omitempty" json:"ports,omitempty"`
@@ -100,6 +102,23 @@ type BuildConfig struct {
 	CacheFrom  []string          `yaml:"cache_from,omitempty" json:"cache_from,omitempty"`
 }
 
+// Dependency conditions, as in docker compose
+const (
+	// ConditionServiceStarted waits until the dependency's container is running
+	ConditionServiceStarted = "service_started"
+	// ConditionServiceHealthy waits until the dependency's healthcheck passes
+	ConditionServiceHealthy = "service_healthy"
+	// ConditionServiceCompletedSuccessfully waits until the dependency exits with code 0
+	ConditionServiceCompletedSuccessfully = "service_completed_successfully"
+)
+
+// DependencyCondition controls when a dependent service may start.
+// Without a condition CM waits for the healthcheck when the dependency has one.
+type DependencyCondition struct {
+	Condition string        `yaml:"condition,omitempty" json:"condition,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"` // CM extension
+}
+
 // PortConfig defines port mapping
 type PortConfig struct {
 	Target    int    `yaml:"target" json:"target"`
@@ -195,6 +214,8 @@ type ServiceState struct {
 	Name        string        `json:"name"`
 	Status      ServiceStatus `json:"status"`
 	ContainerID string        `json:"container_id,omitempty"`
+	Image       string        `json:"image,omitempty"`
+	Health      string        `json:"health,omitempty"` // healthy, unhealthy, starting
 	NetworkID   string        `json:"network_id,omitempty"`
 	Ports       []PortMapping `json:"ports,omitempty"`
 	StartedAt   time.Time     `json:"started_at,omitempty"`
@@ -208,6 +229,10 @@ type PortMapping struct {
 	Protocol  string `json:"protocol"`
 }
 
+// DefaultStartTimeout is how long, in seconds, a service waits for each of
+// its dependencies unless configured otherwise
+const DefaultStartTimeout = 120
+
 // StartOptions defines options for starting services
 type StartOptions struct {
 	Services []string // Specific services to start (empty = all)
@@ -216,7 +241,7 @@ type StartOptions struct {
 	NoDeps   bool     // Don't start dependencies
 	Detach   bool     // Run in background
 	Profile  string   // Activate specific profile
-	Timeout  int      // Startup timeout in seconds
+	Timeout  int      // Per-dependency wait timeout in seconds
 }
 
 // StopOptions defines options for stopping services